@@ -77,7 +77,10 @@ func main() {
 
 	cfg := &config.Config{
 		Server: config.ServerConfig{
-			Port: port,
+			Port:                port,
+			VirtualHostDomains:  config.SplitList(os.Getenv("VAULTAIRE_VIRTUAL_HOST_DOMAINS")),
+			RateLimitStore:      os.Getenv("VAULTAIRE_RATE_LIMIT_STORE"),
+			NotificationTargets: config.SplitMap(os.Getenv("VAULTAIRE_NOTIFICATION_TARGETS")),
 		},
 	}
 
@@ -116,10 +116,9 @@ func (s *AccountDeletionService) ExecuteDeletion(ctx context.Context, userID, te
 	}
 	defer func() { _ = tx.Rollback() }()
 
-	// Order is FK-safe: children before parents. webhook_endpoints goes
-	// before events so deliveries cascade away via webhook_id before the
-	// events they reference are deleted (works even on schemas that predate
-	// migration 056's ON DELETE CASCADE on webhook_deliveries.event_id).
+	// Order is FK-safe: children before parents. delivery_outbox goes
+	// first (bucket notification deliveries reference no parent row), then
+	// webhook_endpoints before events.
 	// quota_usage_events goes before tenant_quotas for the same reason.
 	// tenant_chunk_refs/object_metadata tenant_id columns are TEXT since
 	// migration 058 (WP-C); the ::text casts are no-op-safe either way.
@@ -154,6 +153,7 @@ func (s *AccountDeletionService) ExecuteDeletion(ctx context.Context, userID, te
 		FROM refs
 		WHERE g.dedup_scope = refs.dedup_scope
 		  AND g.plaintext_hash = refs.plaintext_hash`, tenantID},
+		{`DELETE FROM delivery_outbox WHERE tenant_id = $1`, tenantID},
 		{`DELETE FROM webhook_endpoints WHERE tenant_id = $1`, tenantID},
 		{`DELETE FROM events WHERE tenant_id = $1`, tenantID},
 		{`DELETE FROM object_head_cache WHERE tenant_id = $1`, tenantID},
@@ -165,6 +165,8 @@ func (s *AccountDeletionService) ExecuteDeletion(ctx context.Context, userID, te
 		{`DELETE FROM artifacts WHERE tenant_id = $1`, tenantID},
 		{`DELETE FROM buckets WHERE tenant_id = $1`, tenantID},
 		{`DELETE FROM sts_tokens WHERE tenant_id = $1`, tenantID},
+		{`DELETE FROM sts_roles WHERE tenant_id = $1`, tenantID},
+		{`DELETE FROM oidc_providers WHERE tenant_id = $1`, tenantID},
 		{`DELETE FROM api_keys WHERE user_id = $1`, userID},
 		{`DELETE FROM quota_usage_events WHERE tenant_id = $1`, tenantID},
 		{`DELETE FROM tenant_quotas WHERE tenant_id = $1`, tenantID},
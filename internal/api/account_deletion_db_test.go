@@ -54,7 +54,7 @@ func TestExecuteDeletion_RemovesAllTenantData(t *testing.T) {
 	// failed run.
 	t.Cleanup(func() {
 		for _, q := range []string{
-			`DELETE FROM webhook_deliveries WHERE webhook_id LIKE 'wh-wp8-%'`,
+			`DELETE FROM delivery_outbox WHERE tenant_id = $1`,
 			`DELETE FROM webhook_endpoints WHERE tenant_id = $1`,
 			`DELETE FROM events WHERE tenant_id = $1`,
 			`DELETE FROM tenant_chunk_refs WHERE tenant_id::text = $1`,
@@ -132,9 +132,13 @@ func TestExecuteDeletion_RemovesAllTenantData(t *testing.T) {
 	mustExec(`INSERT INTO webhook_endpoints (id, tenant_id, url, secret)
 	          VALUES ($1, $2, 'https://example.com/hook', 'whsec_wp8')`,
 		"wh-wp8-"+suffix, tenantID)
-	mustExec(`INSERT INTO webhook_deliveries (id, webhook_id, event_id, status)
-	          VALUES ($1, $2, $3, 'success')`,
-		"whd-wp8-"+suffix, "wh-wp8-"+suffix, "ev-wp8-"+suffix)
+	mustExec(`INSERT INTO delivery_outbox (id, kind, tenant_id, endpoint_key, webhook_id, url, event_id, event_type, payload, status)
+	          VALUES ($1, 'webhook', $2, $3, $3, 'https://example.com/hook', $4, 'object.created', '{}', 'delivered')`,
+		"whd-wp8-"+suffix, tenantID, "wh-wp8-"+suffix, "ev-wp8-"+suffix)
+	// A bucket notification delivery, which references neither.
+	mustExec(`INSERT INTO delivery_outbox (id, kind, tenant_id, endpoint_key, bucket, url, event_type, payload)
+	          VALUES ($1, 'notification', $2, 'notification:wp8', 'b', 'https://example.com/n', 's3:ObjectCreated:Put', '{}')`,
+		"whn-wp8-"+suffix, tenantID)
 
 	// Credentials + identity residue: STS token, MFA, encryption key, OAuth link, activity.
 	mustExec(`INSERT INTO sts_tokens (access_key, secret_key, tenant_id, parent_key_id, expires_at)
@@ -174,7 +178,10 @@ func TestExecuteDeletion_RemovesAllTenantData(t *testing.T) {
 		"object_metadata":        "tenant_id::text = $1",
 		"events":                 "tenant_id = $1",
 		"webhook_endpoints":      "tenant_id = $1",
+		"delivery_outbox":        "tenant_id = $1",
 		"sts_tokens":             "tenant_id = $1",
+		"sts_roles":              "tenant_id = $1",
+		"oidc_providers":         "tenant_id = $1",
 		"tenant_encryption_keys": "tenant_id = $1",
 		"artifacts":              "tenant_id = $1",
 	}
@@ -200,9 +207,9 @@ func TestExecuteDeletion_RemovesAllTenantData(t *testing.T) {
 	}
 	var deliveries int
 	err = db.QueryRowContext(ctx,
-		"SELECT COUNT(*) FROM webhook_deliveries WHERE webhook_id = $1", "wh-wp8-"+suffix).Scan(&deliveries)
+		"SELECT COUNT(*) FROM delivery_outbox WHERE webhook_id = $1", "wh-wp8-"+suffix).Scan(&deliveries)
 	require.NoError(t, err)
-	assert.Zero(t, deliveries, "webhook_deliveries must be gone (cascade or explicit)")
+	assert.Zero(t, deliveries, "webhook deliveries must be gone (cascade or explicit)")
 
 	// The global dedup index row must survive — other tenants may share it.
 	var gci int
@@ -99,6 +99,7 @@ func TestExecuteDeletion_RemovesAllData(t *testing.T) {
 	// WP-6/F5: GCI ref counts are released set-based before the tenant's
 	// chunk refs are deleted.
 	mock.ExpectExec(`UPDATE global_content_index g`).WithArgs("tenant-1").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`DELETE FROM delivery_outbox WHERE tenant_id`).WithArgs("tenant-1").WillReturnResult(sqlmock.NewResult(0, 0))
 	mock.ExpectExec(`DELETE FROM webhook_endpoints WHERE tenant_id`).WithArgs("tenant-1").WillReturnResult(sqlmock.NewResult(0, 0))
 	mock.ExpectExec(`DELETE FROM events WHERE tenant_id`).WithArgs("tenant-1").WillReturnResult(sqlmock.NewResult(0, 10))
 	mock.ExpectExec(`DELETE FROM object_head_cache WHERE tenant_id`).WithArgs("tenant-1").WillReturnResult(sqlmock.NewResult(0, 5))
@@ -110,6 +111,8 @@ func TestExecuteDeletion_RemovesAllData(t *testing.T) {
 	mock.ExpectExec(`DELETE FROM artifacts WHERE tenant_id`).WithArgs("tenant-1").WillReturnResult(sqlmock.NewResult(0, 1))
 	mock.ExpectExec(`DELETE FROM buckets WHERE tenant_id`).WithArgs("tenant-1").WillReturnResult(sqlmock.NewResult(0, 2))
 	mock.ExpectExec(`DELETE FROM sts_tokens WHERE tenant_id`).WithArgs("tenant-1").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`DELETE FROM sts_roles WHERE tenant_id`).WithArgs("tenant-1").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`DELETE FROM oidc_providers WHERE tenant_id`).WithArgs("tenant-1").WillReturnResult(sqlmock.NewResult(0, 1))
 	mock.ExpectExec(`DELETE FROM api_keys WHERE user_id`).WithArgs("user-1").WillReturnResult(sqlmock.NewResult(0, 1))
 	mock.ExpectExec(`DELETE FROM quota_usage_events WHERE tenant_id`).WithArgs("tenant-1").WillReturnResult(sqlmock.NewResult(0, 3))
 	mock.ExpectExec(`DELETE FROM tenant_quotas WHERE tenant_id`).WithArgs("tenant-1").WillReturnResult(sqlmock.NewResult(0, 1))
@@ -37,6 +37,12 @@ func (cw *countingResponseWriter) Write(b []byte) (int, error) {
 	return n, err
 }
 
+// Unwrap exposes the underlying writer to http.ResponseController, so
+// streaming handlers can flush through the counter.
+func (cw *countingResponseWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
 // bandwidthEvent represents a single ingress/egress event for a tenant.
 // backend is the storage backend that served the bytes ("" when the request
 // never touched one — errors, listings, cache hits without attribution).
@@ -1,23 +1,102 @@
 package api
 
 import (
+	"context"
+	"database/sql"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"strings"
 	"time"
 
+	"github.com/FairForge/vaultaire/internal/auth"
 	"github.com/FairForge/vaultaire/internal/common"
 	"github.com/go-chi/chi/v5"
 	"go.uber.org/zap"
 )
 
+// allows decides whether an anonymous CDN read of key is permitted; obj is
+// its head row, nil when the object does not exist. A bucket policy Deny for
+// the anonymous principal always wins; otherwise the policy must Allow
+// anonymous s3:GetObject on the key, or the bucket must be public-read, or
+// the object's ACL must grant AllUsers READ. The bucket's public access
+// block can discount the policy (RestrictPublicBuckets) and the other two
+// (IgnorePublicAcls). Unparseable policies fail closed.
+func (site *cdnSite) allows(r *http.Request, key string, obj *cdnObject) bool {
+	switch cdnPolicyDecision(r, site.policyJSON, site.bucket, key) {
+	case auth.PolicyDeny:
+		return false
+	case auth.PolicyAllow:
+		if !site.block.RestrictPublicBuckets {
+			return true
+		}
+	}
+	var objectACL *auth.ACL
+	if obj != nil {
+		objectACL = obj.acl
+	}
+	access := bucketAccess{visibility: site.visibility, ownership: site.ownership, block: site.block}
+	return access.objectReadable(objectACL, "")
+}
+
+// cdnPolicyDecision evaluates the bucket policy for an anonymous GET of key.
+func cdnPolicyDecision(r *http.Request, policyJSON sql.NullString, bucket, key string) auth.PolicyDecision {
+	if !policyJSON.Valid || policyJSON.String == "" {
+		return auth.PolicyNoMatch
+	}
+	policy, err := auth.ParseBucketPolicy([]byte(policyJSON.String), bucket)
+	if err != nil {
+		return auth.PolicyDeny
+	}
+	return policy.Evaluate(auth.PolicyRequest{
+		Action:     "s3:GetObject",
+		Resource:   auth.S3PolicyResource(bucket, key),
+		Conditions: policyConditions(r),
+	})
+}
+
+// cdnSite is a bucket as the CDN serves it.
+type cdnSite struct {
+	tenantID        string
+	slug            string
+	bucket          string
+	visibility      string
+	corsOrigins     string
+	cacheMaxAgeSecs int
+	forceDownload   bool
+	policyJSON      sql.NullString
+	ownership       string
+	block           auth.PublicAccessBlock
+	cors            *CORSConfiguration
+	website         *WebsiteConfiguration
+	// base is the URL path the bucket's keys are served under:
+	// "/{slug}/{bucket}/" on the CDN host, "/" on a custom domain.
+	base string
+	// ownOrigin is set on a custom domain. Only there may active content
+	// (HTML, SVG) render inline: on the shared CDN host every tenant's
+	// pages would share one origin (see isInlineRenderable).
+	ownOrigin bool
+}
+
+// cdnObject is the head row of an object served by the CDN.
+type cdnObject struct {
+	size               int64
+	etag               string
+	contentType        string
+	updatedAt          time.Time
+	contentDisposition string
+	backendName        string
+	redirectLocation   string
+	acl                *auth.ACL
+}
+
 func (s *Server) handleCDNRequest(w http.ResponseWriter, r *http.Request) {
 	slug := chi.URLParam(r, "slug")
 	bucket := chi.URLParam(r, "bucket")
 	key := strings.TrimPrefix(chi.URLParam(r, "*"), "/")
 
-	if slug == "" || bucket == "" || key == "" {
+	if slug == "" || bucket == "" {
 		http.NotFound(w, r)
 		return
 	}
@@ -27,30 +106,80 @@ func (s *Server) handleCDNRequest(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	ctx := r.Context()
-
 	var tenantID string
-	err := s.db.QueryRowContext(ctx,
+	err := s.db.QueryRowContext(r.Context(),
 		"SELECT id FROM tenants WHERE slug = $1", slug).Scan(&tenantID)
 	if err != nil {
 		http.NotFound(w, r)
 		return
 	}
 
-	var visibility, corsOrigins string
-	var cacheMaxAgeSecs int
-	var forceDownload bool
-	err = s.db.QueryRowContext(ctx, `
-		SELECT visibility, cors_origins, cache_max_age_secs, COALESCE(cdn_force_download, FALSE)
+	// The CDN host serves /{slug}/{bucket}/*, the API host /cdn/{slug}/...
+	base := "/" + slug + "/" + bucket
+	if i := strings.Index(r.URL.Path, base); i > 0 {
+		base = r.URL.Path[:i] + base
+	}
+	base += "/"
+	s.serveCDN(w, r, tenantID, slug, bucket, base, key, false)
+}
+
+// serveCDN serves key from a tenant's bucket: with website semantics when
+// the bucket has a website configuration, as a plain object otherwise.
+func (s *Server) serveCDN(w http.ResponseWriter, r *http.Request, tenantID, slug, bucket, base, key string, ownOrigin bool) {
+	ctx := r.Context()
+
+	site := &cdnSite{tenantID: tenantID, slug: slug, bucket: bucket, base: base, ownOrigin: ownOrigin}
+	var corsJSON, websiteXML sql.NullString
+	var blockJSON []byte
+	err := s.db.QueryRowContext(ctx, `
+		SELECT visibility, cors_origins, cache_max_age_secs, COALESCE(cdn_force_download, FALSE), policy, cors_config, website_config,
+		       COALESCE(object_ownership, ''), public_access_block
 		FROM buckets WHERE tenant_id = $1 AND name = $2`,
-		tenantID, bucket).Scan(&visibility, &corsOrigins, &cacheMaxAgeSecs, &forceDownload)
-	if err != nil || visibility != "public-read" {
+		tenantID, bucket).Scan(&site.visibility, &site.corsOrigins, &site.cacheMaxAgeSecs, &site.forceDownload,
+		&site.policyJSON, &corsJSON, &websiteXML, &site.ownership, &blockJSON)
+	if err != nil {
 		http.NotFound(w, r)
 		return
 	}
+	if site.block, err = auth.ParsePublicAccessBlock(blockJSON); err != nil {
+		s.logger.Error("cdn: invalid public access block, refusing to serve",
+			zap.String("bucket", bucket), zap.Error(err))
+		http.NotFound(w, r)
+		return
+	}
+	if site.website, err = decodeStoredWebsite(websiteXML); err != nil {
+		s.logger.Warn("cdn: ignoring invalid website config",
+			zap.String("bucket", bucket), zap.Error(err))
+	}
+
+	// An object's own ACL can make it public, so the object is looked up
+	// before access is decided.
+	var obj *cdnObject
+	if site.website == nil {
+		if key == "" {
+			http.NotFound(w, r)
+			return
+		}
+		obj, _ = s.lookupCDNObject(r, site, key)
+		if !site.allows(r, key, obj) {
+			http.NotFound(w, r)
+			return
+		}
+	}
+
+	// A bucket CORS configuration replaces the legacy cors_origins list.
+	site.cors, err = decodeStoredCORS(corsJSON)
+	if err != nil {
+		s.logger.Warn("cdn: ignoring invalid CORS config",
+			zap.String("bucket", bucket), zap.Error(err))
+	}
 
 	if r.Method == http.MethodOptions {
-		handleCDNPreflight(w, r, corsOrigins)
+		if site.cors != nil {
+			handleCDNCORSPreflight(w, r, site.cors)
+		} else {
+			handleCDNPreflight(w, r, site.corsOrigins)
+		}
 		return
 	}
 
@@ -69,117 +198,175 @@ func (s *Server) handleCDNRequest(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	var sizeBytes int64
-	var etag, contentType string
-	var updatedAt time.Time
-	var contentDisposition string
-	var backendName string
-	err = s.db.QueryRowContext(ctx, `
-		SELECT size_bytes, etag, content_type, updated_at, COALESCE(content_disposition, ''), COALESCE(backend_name, '')
+	if site.website != nil {
+		s.serveWebsite(w, r, site, key)
+		return
+	}
+
+	if obj == nil {
+		http.NotFound(w, r)
+		return
+	}
+	s.writeCDNObject(w, r, site, key, obj, http.StatusOK)
+}
+
+// lookupCDNObject reads the head row the CDN serves key from.
+func (s *Server) lookupCDNObject(r *http.Request, site *cdnSite, key string) (*cdnObject, error) {
+	var obj cdnObject
+	var aclJSON []byte
+	err := s.db.QueryRowContext(r.Context(), `
+		SELECT size_bytes, etag, content_type, updated_at, COALESCE(content_disposition, ''), COALESCE(backend_name, ''),
+		       COALESCE(website_redirect_location, ''), acl
 		FROM object_head_cache
 		WHERE tenant_id = $1 AND bucket = $2 AND object_key = $3`,
-		tenantID, bucket, key).Scan(&sizeBytes, &etag, &contentType, &updatedAt, &contentDisposition, &backendName)
+		site.tenantID, site.bucket, key).Scan(&obj.size, &obj.etag, &obj.contentType, &obj.updatedAt,
+		&obj.contentDisposition, &obj.backendName, &obj.redirectLocation, &aclJSON)
 	if err != nil {
-		http.NotFound(w, r)
-		return
+		return nil, err
+	}
+	if obj.acl, err = auth.ParseACL(aclJSON); err != nil {
+		return nil, fmt.Errorf("stored object ACL: %w", err)
 	}
+	return &obj, nil
+}
 
-	cacheControl := fmt.Sprintf("public, max-age=%d, stale-while-revalidate=600", cacheMaxAgeSecs)
+// writeCDNObject streams obj with the given status. Conditional and range
+// requests apply to 200 responses only: an error document is always sent
+// whole.
+func (s *Server) writeCDNObject(w http.ResponseWriter, r *http.Request, site *cdnSite, key string, obj *cdnObject, status int) {
+	ctx := r.Context()
+	cacheControl := fmt.Sprintf("public, max-age=%d, stale-while-revalidate=600", site.cacheMaxAgeSecs)
 
-	if code := evaluateConditionalGET(r, etag, updatedAt); code == http.StatusNotModified {
-		writeNotModified(w, etag, updatedAt, cacheControl)
-		return
-	} else if code == http.StatusPreconditionFailed {
-		w.WriteHeader(http.StatusPreconditionFailed)
-		return
+	if status == http.StatusOK {
+		if code := evaluateConditionalGET(r, obj.etag, obj.updatedAt); code == http.StatusNotModified {
+			writeNotModified(w, obj.etag, obj.updatedAt, cacheControl)
+			return
+		} else if code == http.StatusPreconditionFailed {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			return
+		}
 	}
 
-	w.Header().Set("Content-Type", contentType)
-	w.Header().Set("Content-Disposition", cdnContentDisposition(forceDownload, contentDisposition, contentType, key))
-	w.Header().Set("Content-Length", fmt.Sprintf("%d", sizeBytes))
-	w.Header().Set("ETag", fmt.Sprintf(`"%s"`, etag))
+	w.Header().Set("Content-Type", obj.contentType)
+	if !site.ownOrigin {
+		w.Header().Set("Content-Disposition", cdnContentDisposition(site.forceDownload, obj.contentDisposition, obj.contentType, key))
+		w.Header().Set("X-Robots-Tag", "noindex, nofollow")
+	} else if cd := websiteContentDisposition(site.forceDownload, obj.contentDisposition, key); cd != "" {
+		w.Header().Set("Content-Disposition", cd)
+	}
+	w.Header().Set("Content-Length", fmt.Sprintf("%d", obj.size))
+	w.Header().Set("ETag", fmt.Sprintf(`"%s"`, obj.etag))
 	w.Header().Set("Cache-Control", cacheControl)
 	w.Header().Set("Accept-Ranges", "bytes")
 	w.Header().Set("X-Content-Type-Options", "nosniff")
-	w.Header().Set("X-Robots-Tag", "noindex, nofollow")
-	if !updatedAt.IsZero() {
-		w.Header().Set("Last-Modified", updatedAt.UTC().Format(http.TimeFormat))
+	if !obj.updatedAt.IsZero() {
+		w.Header().Set("Last-Modified", obj.updatedAt.UTC().Format(http.TimeFormat))
 	}
 
-	setCORSHeaders(w, r, corsOrigins)
+	if site.cors != nil {
+		applyCORSRules(w, r, site.cors)
+	} else {
+		setCORSHeaders(w, r, site.corsOrigins)
+	}
 
 	if r.Method == http.MethodHead {
+		if status != http.StatusOK {
+			w.WriteHeader(status)
+		}
 		return
 	}
 
-	container := fmt.Sprintf("%s_%s", tenantID, bucket)
+	container := fmt.Sprintf("%s_%s", site.tenantID, site.bucket)
 	// Tenant-scoped backends (iDrive, Geyser) build their storage keys from
 	// the context tenant, not the container name. Serving without it made
 	// every driver look under tenant "default" — public objects on those
 	// backends 404'd even though the bytes existed (2026-07-31).
-	ctx = common.WithTenantID(ctx, tenantID)
+	ctx = common.WithTenantID(ctx, site.tenantID)
 	// Seed the engine's routing map like the S3 GET path does, so the fetch
 	// goes straight to the backend that holds the object instead of walking
 	// the failover chain after a restart.
-	if backendName != "" && s.engine != nil {
-		s.engine.HintBackend(container, key, backendName)
+	if obj.backendName != "" && s.engine != nil {
+		s.engine.HintBackend(container, key, obj.backendName)
 	}
 	// Backend-attribution slot: the engine records which backend served the
 	// bytes so CDN egress lands in backend_bandwidth_daily too.
 	ctx, _ = common.WithBackendNote(ctx)
-	reader, err := s.engine.Get(ctx, container, key)
-	if err != nil {
-		s.logger.Error("cdn engine.Get failed",
-			zap.String("container", container),
-			zap.String("key", key),
-			zap.Error(err))
-		http.NotFound(w, r)
-		return
-	}
-	defer func() { _ = reader.Close() }()
 
+	// Ranges are read from the backend part by part (engine.GetRange uses
+	// the driver's native range GET where it has one) rather than by
+	// streaming the whole object and discarding what wasn't asked for.
 	rangeHeader := r.Header.Get("Range")
-	if rangeHeader != "" && sizeBytes > 0 {
-		rng, parseErr := parseRangeHeader(rangeHeader, sizeBytes)
+	if status == http.StatusOK && rangeHeader != "" && obj.size > 0 {
+		ranges, parseErr := parseRanges(rangeHeader, obj.size)
 		if parseErr != nil {
-			writeRangeNotSatisfiable(w, sizeBytes)
+			writeRangeNotSatisfiable(w, obj.size)
 			return
 		}
-		if err := serveRange(w, reader, rng, sizeBytes, contentType); err != nil {
-			s.logger.Error("cdn range serve failed",
+		open := func(rng httpRange) (io.ReadCloser, error) {
+			rc, err := s.engine.GetRange(ctx, container, key, rng.start, rng.length)
+			if err != nil {
+				return nil, err
+			}
+			return limitedReadCloser{Reader: s.egressReader(ctx, site.tenantID, rc), c: rc}, nil
+		}
+		n, err := serveRanges(w, ranges, obj.size, obj.contentType, open)
+		if errors.Is(err, errRangeNotStarted) {
+			s.logger.Error("cdn engine.GetRange failed",
+				zap.String("container", container),
 				zap.String("key", key),
 				zap.Error(err))
+			w.Header().Del("Content-Length")
+			http.NotFound(w, r)
 			return
 		}
-		if s.bandwidthTracker != nil {
-			s.bandwidthTracker.RecordWithBackend(ctx, tenantID, common.BackendUsed(ctx), 0, rng.length)
-		}
-		if s.cdnAnalytics != nil {
-			s.cdnAnalytics.Record(ctx, tenantID, bucket, key, rng.length,
-				r.Header.Get("CF-IPCountry"), r.Referer())
+		if err != nil {
+			s.logger.Error("cdn range serve failed",
+				zap.String("key", key),
+				zap.Int("ranges", len(ranges)),
+				zap.Error(err))
 		}
+		s.recordCDNEgress(ctx, r, site, key, n)
 		return
 	}
 
-	written, err := io.Copy(w, reader)
+	reader, err := s.engine.Get(ctx, container, key)
 	if err != nil {
-		s.logger.Error("cdn stream failed",
+		s.logger.Error("cdn engine.Get failed",
+			zap.String("container", container),
 			zap.String("key", key),
 			zap.Error(err))
+		w.Header().Del("Content-Length")
+		http.NotFound(w, r)
 		return
 	}
+	defer func() { _ = reader.Close() }()
 
-	if s.bandwidthTracker != nil {
-		s.bandwidthTracker.RecordWithBackend(ctx, tenantID, common.BackendUsed(ctx), 0, written)
+	if status != http.StatusOK {
+		w.WriteHeader(status)
 	}
-	if s.cdnAnalytics != nil {
-		s.cdnAnalytics.Record(ctx, tenantID, bucket, key, written,
-			r.Header.Get("CF-IPCountry"), r.Referer())
+	written, err := io.Copy(w, s.egressReader(ctx, site.tenantID, reader))
+	if err != nil {
+		s.logger.Error("cdn stream failed",
+			zap.String("key", key),
+			zap.Error(err))
+		return
 	}
+	s.recordCDNEgress(ctx, r, site, key, written)
 
 	s.logger.Debug("cdn served",
-		zap.String("slug", slug),
-		zap.String("bucket", bucket),
+		zap.String("slug", site.slug),
+		zap.String("bucket", site.bucket),
 		zap.String("key", key),
 		zap.Int64("bytes", written))
 }
+
+// recordCDNEgress bills and attributes bytes served by the CDN.
+func (s *Server) recordCDNEgress(ctx context.Context, r *http.Request, site *cdnSite, key string, n int64) {
+	if s.bandwidthTracker != nil {
+		s.bandwidthTracker.RecordWithBackend(ctx, site.tenantID, common.BackendUsed(ctx), 0, n)
+	}
+	if s.cdnAnalytics != nil {
+		s.cdnAnalytics.Record(ctx, site.tenantID, site.bucket, key, n,
+			r.Header.Get("CF-IPCountry"), r.Referer())
+	}
+}
@@ -431,6 +431,23 @@ func TestCDN_RangeRequest_SuffixRange(t *testing.T) {
 	assert.Equal(t, f.content[len(f.content)-3:], body)
 }
 
+func TestCDN_RangeRequest_MultipleRanges(t *testing.T) {
+	f := setupCDNFixture(t)
+
+	req := httptest.NewRequest("GET", "/cdn/"+f.slug+"/"+f.bucket+"/"+f.key, nil)
+	req.Header.Set("Range", "bytes=0-4,-3")
+	w := httptest.NewRecorder()
+	f.router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusPartialContent, w.Code)
+	parts := readByteranges(t, w)
+	require.Len(t, parts, 2)
+	assert.Equal(t, "text/plain", parts[0].contentType)
+	assert.Equal(t, f.content[:5], parts[0].body)
+	assert.Equal(t, fmt.Sprintf("bytes %d-%d/%d", len(f.content)-3, len(f.content)-1, len(f.content)), parts[1].contentRange)
+	assert.Equal(t, f.content[len(f.content)-3:], parts[1].body)
+}
+
 func TestCDN_RangeRequest_Unsatisfiable(t *testing.T) {
 	f := setupCDNFixture(t)
 
@@ -0,0 +1,380 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+)
+
+// Website serving. A bucket with a website configuration (s3_website.go) is
+// served from the CDN host at /{slug}/{bucket}/ and from any custom hostname
+// mapped to it in bucket_website_domains.
+//
+// HTML and SVG only render inline on a custom hostname: the CDN host is one
+// origin shared by every tenant, so there active content is still served as
+// an attachment (isInlineRenderable). A docs site needs a custom hostname;
+// the CDN host path is enough for assets, redirects and previews.
+
+const (
+	// websiteDomainCacheTTL bounds how long a hostname mapping (or its
+	// absence) is cached. Admin changes apply at once on the node that
+	// handled them and within the TTL everywhere else.
+	websiteDomainCacheTTL = time.Minute
+	// maxWebsiteDomainCacheEntries caps the cache: the Host header is
+	// client-controlled, so misses are cached too and must not grow it
+	// without bound.
+	maxWebsiteDomainCacheEntries = 10000
+)
+
+// serveWebsite answers a request for key with website semantics. The
+// rate limit, bandwidth budget and CORS preflight are already handled.
+func (s *Server) serveWebsite(w http.ResponseWriter, r *http.Request, site *cdnSite, key string) {
+	config := site.website
+	if ra := config.RedirectAllRequestsTo; ra != nil {
+		writeWebsiteRedirect(w, ra.redirect(r, key))
+		return
+	}
+
+	// The root without its trailing slash: relative links in the index
+	// document only resolve against the directory with it.
+	if key == "" && !strings.HasSuffix(r.URL.Path, "/") {
+		writeWebsiteRedirect(w, websiteRedirect{location: websitePath(site.base), code: http.StatusFound})
+		return
+	}
+
+	if rule := config.routingRule(key, 0); rule != nil {
+		writeWebsiteRedirect(w, rule.redirect(r, site.base, key))
+		return
+	}
+
+	objectKey := key
+	if objectKey == "" || strings.HasSuffix(objectKey, "/") {
+		objectKey += config.IndexDocument.Suffix
+	}
+	obj, err := s.lookupCDNObject(r, site, objectKey)
+	if err != nil && err != sql.ErrNoRows {
+		s.logger.Error("website: object lookup failed",
+			zap.String("bucket", site.bucket), zap.String("key", objectKey), zap.Error(err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	if !site.allows(r, objectKey, obj) {
+		s.writeWebsiteError(w, r, site, key, http.StatusForbidden)
+		return
+	}
+	if obj != nil {
+		if loc := obj.redirectLocation; loc != "" {
+			// A path is relative to the website root.
+			if strings.HasPrefix(loc, "/") {
+				loc = strings.TrimSuffix(site.base, "/") + loc
+			}
+			writeWebsiteRedirect(w, websiteRedirect{location: loc, code: http.StatusMovedPermanently})
+			return
+		}
+		s.writeCDNObject(w, r, site, objectKey, obj, http.StatusOK)
+		return
+	}
+
+	// A directory requested without its trailing slash redirects to it
+	// when it has an index document.
+	if objectKey == key {
+		indexKey := key + "/" + config.IndexDocument.Suffix
+		if index, err := s.lookupCDNObject(r, site, indexKey); err == nil && site.allows(r, indexKey, index) {
+			writeWebsiteRedirect(w, websiteRedirect{location: websitePath(site.base + key + "/"), code: http.StatusFound})
+			return
+		}
+	}
+	s.writeWebsiteError(w, r, site, key, http.StatusNotFound)
+}
+
+// writeWebsiteError answers a failed website request: through a routing
+// rule for the error code, else with the error document, else with a
+// minimal HTML page.
+func (s *Server) writeWebsiteError(w http.ResponseWriter, r *http.Request, site *cdnSite, key string, code int) {
+	config := site.website
+	if rule := config.routingRule(key, code); rule != nil {
+		writeWebsiteRedirect(w, rule.redirect(r, site.base, key))
+		return
+	}
+	if ed := config.ErrorDocument; ed != nil {
+		if obj, err := s.lookupCDNObject(r, site, ed.Key); err == nil && obj.redirectLocation == "" && site.allows(r, ed.Key, obj) {
+			s.writeCDNObject(w, r, site, ed.Key, obj, code)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(code)
+	if r.Method != http.MethodHead {
+		_, _ = fmt.Fprintf(w, "<html><head><title>%[1]d %[2]s</title></head><body><h1>%[1]d %[2]s</h1></body></html>\n",
+			code, http.StatusText(code))
+	}
+}
+
+func writeWebsiteRedirect(w http.ResponseWriter, rd websiteRedirect) {
+	w.Header().Set("Location", rd.location)
+	w.Header().Set("Content-Length", "0")
+	w.WriteHeader(rd.code)
+}
+
+// websiteContentDisposition is cdnContentDisposition for a custom hostname,
+// where content renders as its type: only the bucket's force-download flag
+// or the object's own Content-Disposition set the header.
+func websiteContentDisposition(forceDownload bool, stored, key string) string {
+	if forceDownload {
+		return attachmentDisposition(key)
+	}
+	return sanitizeContentDisposition(stored)
+}
+
+// websiteDomain is the bucket a custom hostname serves.
+type websiteDomain struct {
+	tenantID string
+	slug     string
+	bucket   string
+}
+
+type websiteDomainEntry struct {
+	domain  websiteDomain
+	found   bool
+	expires time.Time
+}
+
+// websiteDomainCache resolves custom hostnames, caching hits and misses so
+// that routing API traffic past it costs no query per request.
+type websiteDomainCache struct {
+	db      *sql.DB
+	mu      sync.Mutex
+	entries map[string]websiteDomainEntry
+}
+
+func newWebsiteDomainCache(db *sql.DB) *websiteDomainCache {
+	if db == nil {
+		return nil
+	}
+	return &websiteDomainCache{db: db, entries: make(map[string]websiteDomainEntry)}
+}
+
+func (c *websiteDomainCache) lookup(ctx context.Context, host string) (websiteDomain, bool) {
+	c.mu.Lock()
+	e, ok := c.entries[host]
+	c.mu.Unlock()
+	if ok && time.Now().Before(e.expires) {
+		return e.domain, e.found
+	}
+
+	var d websiteDomain
+	err := c.db.QueryRowContext(ctx, `
+		SELECT d.tenant_id, COALESCE(t.slug, ''), d.bucket
+		FROM bucket_website_domains d JOIN tenants t ON t.id = d.tenant_id
+		WHERE d.hostname = $1`, host).Scan(&d.tenantID, &d.slug, &d.bucket)
+	if err != nil && err != sql.ErrNoRows {
+		// Not cached: the next request retries.
+		return websiteDomain{}, false
+	}
+
+	c.mu.Lock()
+	if len(c.entries) >= maxWebsiteDomainCacheEntries {
+		c.entries = make(map[string]websiteDomainEntry)
+	}
+	c.entries[host] = websiteDomainEntry{domain: d, found: err == nil, expires: time.Now().Add(websiteDomainCacheTTL)}
+	c.mu.Unlock()
+	return d, err == nil
+}
+
+func (c *websiteDomainCache) forget(host string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	delete(c.entries, host)
+	c.mu.Unlock()
+}
+
+// normalizeWebsiteHost lowercases a hostname and strips its port and any
+// trailing dot.
+func normalizeWebsiteHost(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.TrimSuffix(strings.ToLower(host), ".")
+}
+
+// websiteHostRouter serves mapped custom hostnames as websites; every other
+// host goes to next.
+func (s *Server) websiteHostRouter(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.websiteDomains != nil {
+			if d, ok := s.websiteDomains.lookup(r.Context(), normalizeWebsiteHost(r.Host)); ok {
+				s.handleWebsiteDomainRequest(w, r, d)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) handleWebsiteDomainRequest(w http.ResponseWriter, r *http.Request, d websiteDomain) {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+	default:
+		w.Header().Set("Allow", cdnAllowMethods)
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	slug := d.slug
+	if slug == "" {
+		slug = d.tenantID
+	}
+	s.serveCDN(w, r, d.tenantID, slug, d.bucket, "/", strings.TrimPrefix(r.URL.Path, "/"), true)
+}
+
+// validWebsiteHostname reports whether host can be mapped: a dotted DNS
+// name that is not one of our own hosts.
+func validWebsiteHostname(host string) bool {
+	if host == "" || len(host) > 253 || !strings.Contains(host, ".") || net.ParseIP(host) != nil {
+		return false
+	}
+	for _, c := range host {
+		if !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '-' || c == '.') {
+			return false
+		}
+	}
+	switch host {
+	case "cdn.stored.ge", "cdn.stored.cloud", "stored.ge", "stored.cloud":
+		return false
+	}
+	return true
+}
+
+type websiteDomainJSON struct {
+	Hostname  string    `json:"hostname"`
+	TenantID  string    `json:"tenant_id"`
+	Bucket    string    `json:"bucket"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// handleAdminWebsiteDomainsList lists the custom website hostnames
+// (admin-only: the operator provisions their DNS and certificates).
+func (s *Server) handleAdminWebsiteDomainsList(w http.ResponseWriter, r *http.Request) {
+	if s.db == nil {
+		http.Error(w, "database not configured", http.StatusServiceUnavailable)
+		return
+	}
+	rows, err := s.db.QueryContext(r.Context(), `
+		SELECT hostname, tenant_id, bucket, created_at
+		FROM bucket_website_domains ORDER BY hostname`)
+	if err != nil {
+		s.logger.Error("list website domains", zap.Error(err))
+		http.Error(w, "query failed", http.StatusInternalServerError)
+		return
+	}
+	defer func() { _ = rows.Close() }()
+
+	domains := []websiteDomainJSON{}
+	for rows.Next() {
+		var d websiteDomainJSON
+		if err := rows.Scan(&d.Hostname, &d.TenantID, &d.Bucket, &d.CreatedAt); err != nil {
+			s.logger.Error("scan website domain", zap.Error(err))
+			http.Error(w, "query failed", http.StatusInternalServerError)
+			return
+		}
+		domains = append(domains, d)
+	}
+	if err := rows.Err(); err != nil {
+		s.logger.Error("website domain rows", zap.Error(err))
+		http.Error(w, "query failed", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{"domains": domains})
+}
+
+// handleAdminWebsiteDomainSet maps a hostname to a tenant's bucket.
+func (s *Server) handleAdminWebsiteDomainSet(w http.ResponseWriter, r *http.Request) {
+	if s.db == nil {
+		http.Error(w, "database not configured", http.StatusServiceUnavailable)
+		return
+	}
+	host := normalizeWebsiteHost(chi.URLParam(r, "hostname"))
+	if !validWebsiteHostname(host) {
+		http.Error(w, "invalid hostname: "+host, http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		TenantID string `json:"tenant_id"`
+		Bucket   string `json:"bucket"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.TenantID == "" || req.Bucket == "" {
+		http.Error(w, `invalid body: expected {"tenant_id": string, "bucket": string}`, http.StatusBadRequest)
+		return
+	}
+
+	var exists bool
+	if err := s.db.QueryRowContext(r.Context(),
+		`SELECT EXISTS(SELECT 1 FROM buckets WHERE tenant_id = $1 AND name = $2)`,
+		req.TenantID, req.Bucket).Scan(&exists); err != nil {
+		s.logger.Error("website domain bucket lookup", zap.Error(err))
+		http.Error(w, "query failed", http.StatusInternalServerError)
+		return
+	}
+	if !exists {
+		http.Error(w, "no such bucket", http.StatusNotFound)
+		return
+	}
+
+	d := websiteDomainJSON{Hostname: host, TenantID: req.TenantID, Bucket: req.Bucket}
+	if err := s.db.QueryRowContext(r.Context(), `
+		INSERT INTO bucket_website_domains (hostname, tenant_id, bucket)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (hostname) DO UPDATE SET tenant_id = EXCLUDED.tenant_id, bucket = EXCLUDED.bucket
+		RETURNING created_at`,
+		host, req.TenantID, req.Bucket).Scan(&d.CreatedAt); err != nil {
+		s.logger.Error("set website domain", zap.String("hostname", host), zap.Error(err))
+		http.Error(w, "failed to set website domain", http.StatusInternalServerError)
+		return
+	}
+	s.websiteDomains.forget(host)
+
+	s.logger.Info("website domain mapped",
+		zap.String("hostname", host),
+		zap.String("tenant_id", req.TenantID),
+		zap.String("bucket", req.Bucket))
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(d)
+}
+
+// handleAdminWebsiteDomainDelete removes a hostname mapping.
+func (s *Server) handleAdminWebsiteDomainDelete(w http.ResponseWriter, r *http.Request) {
+	if s.db == nil {
+		http.Error(w, "database not configured", http.StatusServiceUnavailable)
+		return
+	}
+	host := normalizeWebsiteHost(chi.URLParam(r, "hostname"))
+	res, err := s.db.ExecContext(r.Context(),
+		`DELETE FROM bucket_website_domains WHERE hostname = $1`, host)
+	if err != nil {
+		s.logger.Error("delete website domain", zap.String("hostname", host), zap.Error(err))
+		http.Error(w, "failed to delete website domain", http.StatusInternalServerError)
+		return
+	}
+	s.websiteDomains.forget(host)
+	if n, _ := res.RowsAffected(); n == 0 {
+		http.Error(w, "no such website domain", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -101,3 +101,32 @@ func writeNotModified(w http.ResponseWriter, etag string, lastModified time.Time
 	}
 	w.WriteHeader(http.StatusNotModified)
 }
+
+// copySourcePreconditionFailed evaluates the x-amz-copy-source-if-* headers
+// against the copy source. As in S3, a matching if-match overrides a failed
+// if-unmodified-since, and a present if-none-match overrides
+// if-modified-since.
+func copySourcePreconditionFailed(r *http.Request, etag string, lastModified time.Time) bool {
+	cond := &http.Request{Header: http.Header{}}
+	for std, amz := range map[string]string{
+		"If-Match":            "x-amz-copy-source-if-match",
+		"If-None-Match":       "x-amz-copy-source-if-none-match",
+		"If-Modified-Since":   "x-amz-copy-source-if-modified-since",
+		"If-Unmodified-Since": "x-amz-copy-source-if-unmodified-since",
+	} {
+		if v := r.Header.Get(amz); v != "" {
+			cond.Header.Set(std, v)
+		}
+	}
+
+	if checkIfMatch(cond, etag) {
+		return true
+	}
+	if cond.Header.Get("If-Match") == "" && checkIfUnmodifiedSince(cond, lastModified) {
+		return true
+	}
+	if checkIfNoneMatch(cond, etag) {
+		return true
+	}
+	return cond.Header.Get("If-None-Match") == "" && checkIfModifiedSince(cond, lastModified)
+}
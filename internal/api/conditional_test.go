@@ -237,6 +237,40 @@ func TestEvaluateConditionalGET(t *testing.T) {
 	})
 }
 
+func TestCopySourcePreconditionFailed(t *testing.T) {
+	ref := time.Date(2026, 4, 20, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		headers map[string]string
+		want    bool
+	}{
+		{"no conditions", nil, false},
+		{"if-match hit", map[string]string{"x-amz-copy-source-if-match": `"abc"`}, false},
+		{"if-match miss", map[string]string{"x-amz-copy-source-if-match": `"other"`}, true},
+		{"if-none-match hit", map[string]string{"x-amz-copy-source-if-none-match": `"abc"`}, true},
+		{"unmodified-since fails", map[string]string{
+			"x-amz-copy-source-if-unmodified-since": ref.Add(-time.Hour).Format(http.TimeFormat)}, true},
+		{"if-match overrides unmodified-since", map[string]string{
+			"x-amz-copy-source-if-match":            `"abc"`,
+			"x-amz-copy-source-if-unmodified-since": ref.Add(-time.Hour).Format(http.TimeFormat)}, false},
+		{"modified-since fails", map[string]string{
+			"x-amz-copy-source-if-modified-since": ref.Add(time.Hour).Format(http.TimeFormat)}, true},
+		{"if-none-match overrides modified-since", map[string]string{
+			"x-amz-copy-source-if-none-match":     `"other"`,
+			"x-amz-copy-source-if-modified-since": ref.Add(time.Hour).Format(http.TimeFormat)}, false},
+		{"standard headers ignored", map[string]string{"If-Match": `"other"`}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("PUT", "/", nil)
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			assert.Equal(t, tt.want, copySourcePreconditionFailed(req, "abc", ref))
+		})
+	}
+}
+
 func TestWriteNotModified(t *testing.T) {
 	ref := time.Date(2026, 4, 20, 12, 0, 0, 0, time.UTC)
 	w := httptest.NewRecorder()
@@ -55,3 +55,18 @@ func matchOrigin(origin, allowedOrigins string) string {
 	}
 	return ""
 }
+
+// handleCDNCORSPreflight answers a CDN preflight from a bucket CORS
+// configuration. Denied preflights get a bare 403, like other CDN errors.
+func handleCDNCORSPreflight(w http.ResponseWriter, r *http.Request, config *CORSConfiguration) {
+	w.Header().Set("Content-Length", "0")
+	if code := evaluateCORSPreflight(w, r, config); code != "" {
+		if code == ErrCORSBadRequest {
+			w.WriteHeader(http.StatusBadRequest)
+		} else {
+			w.WriteHeader(http.StatusForbidden)
+		}
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,554 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/FairForge/vaultaire/internal/notify"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+)
+
+// Delivery statuses besides 'delivering' (leased to a worker) and
+// 'delivered'. A delivery is pending until its first attempt and failed
+// while it waits for a retry; dead deliveries are the dead-letter queue and
+// are only sent again by a redelivery or replay.
+const (
+	deliveryPending = "pending"
+	deliveryFailed  = "failed"
+	deliveryDead    = "dead"
+)
+
+const (
+	deliveryKindWebhook      = "webhook"
+	deliveryKindNotification = "notification"
+)
+
+const (
+	// deliveryWorkers bounds concurrent POSTs per replica.
+	deliveryWorkers = 16
+	// deliveryPerEndpoint bounds concurrent POSTs to one endpoint across
+	// all replicas, so a backlog can't overwhelm a receiver that just
+	// came back.
+	deliveryPerEndpoint = 2
+	// deliveryMaxAttempts with the backoff below retries for about a day.
+	deliveryMaxAttempts = 15
+	// deliveryDisableAfter consecutive failed attempts, over any of its
+	// deliveries, disable an endpoint.
+	deliveryDisableAfter = 100
+
+	deliveryBaseBackoff  = 10 * time.Second
+	deliveryMaxBackoff   = 6 * time.Hour
+	deliveryTimeout      = 5 * time.Second
+	deliveryPollInterval = time.Second
+	// deliveryLease is how long a claimed delivery is hidden from other
+	// workers; one whose replica died mid-attempt is retried after it.
+	deliveryLease = 2 * time.Minute
+
+	maxDeliveryResponseBytes = 1024
+)
+
+// claimDeliveriesQuery leases up to $1 due deliveries, keeping each
+// endpoint's leased rows (already in flight plus newly claimed) within $2.
+// Stale leases count as due. Callers hold deliveryClaimLock so concurrent
+// replicas see each other's claims.
+const claimDeliveriesQuery = `
+	WITH active AS (
+		SELECT endpoint_key, COUNT(*) AS n
+		FROM delivery_outbox
+		WHERE status = 'delivering' AND locked_until >= NOW()
+		GROUP BY endpoint_key
+	), due AS (
+		SELECT id FROM (
+			SELECT d.id, d.next_attempt_at,
+				COALESCE(a.n, 0) + ROW_NUMBER() OVER (PARTITION BY d.endpoint_key ORDER BY d.next_attempt_at, d.id) AS slot
+			FROM delivery_outbox d
+			LEFT JOIN active a ON a.endpoint_key = d.endpoint_key
+			WHERE (d.status IN ('pending', 'failed') AND d.next_attempt_at <= NOW())
+			   OR (d.status = 'delivering' AND d.locked_until < NOW())
+		) ranked
+		WHERE slot <= $2
+		ORDER BY next_attempt_at
+		LIMIT $1
+	)
+	UPDATE delivery_outbox o
+	SET status = 'delivering', attempts = o.attempts + 1,
+		locked_until = NOW() + $3 * INTERVAL '1 second', updated_at = NOW()
+	FROM due
+	WHERE o.id = due.id
+	RETURNING o.id, o.kind, o.tenant_id, COALESCE(o.webhook_id, ''), COALESCE(o.bucket, ''),
+		o.url, COALESCE(o.event_id, ''), o.event_type, o.payload, o.attempts`
+
+// deliveryClaimLock is the advisory lock key serializing claims.
+const deliveryClaimLock = 0x7661756c746f7574 // "vaultout"
+
+// outboxEntry is one delivery to enqueue.
+type outboxEntry struct {
+	kind      string
+	tenantID  string
+	webhookID string // webhooks
+	bucket    string // notifications
+	url       string
+	eventID   string // webhooks
+	eventType string
+	payload   []byte
+}
+
+// endpointKey names the receiving endpoint: the webhook, or the bucket's
+// notification target.
+func (e outboxEntry) endpointKey() string {
+	if e.kind == deliveryKindWebhook {
+		return deliveryKindWebhook + ":" + e.webhookID
+	}
+	return deliveryKindNotification + ":" + e.tenantID + "/" + e.bucket + "/" + e.url
+}
+
+// enqueueDelivery stores a delivery for the outbox workers and returns its
+// ID. q may be a transaction.
+func enqueueDelivery(ctx context.Context, q sqlQuerier, e outboxEntry) (string, error) {
+	id := uuid.New().String()
+	_, err := q.ExecContext(ctx, `
+		INSERT INTO delivery_outbox (id, kind, tenant_id, endpoint_key, webhook_id, bucket, url, event_id, event_type, payload)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`,
+		id, e.kind, e.tenantID, e.endpointKey(), nullIfEmpty(e.webhookID), nullIfEmpty(e.bucket),
+		e.url, nullIfEmpty(e.eventID), e.eventType, e.payload)
+	if err != nil {
+		return "", fmt.Errorf("enqueue %s delivery: %w", e.kind, err)
+	}
+	return id, nil
+}
+
+// outboxDelivery is a claimed delivery.
+type outboxDelivery struct {
+	outboxEntry
+	id       string
+	attempts int
+}
+
+// deliveryOutbox sends the deliveries in delivery_outbox: webhooks and S3
+// bucket notifications, the latter POSTed to a URL or published to the
+// queue or stream target configured for an ARN. Failed attempts are retried with exponential
+// backoff; a delivery that exhausts its attempts is dead-lettered, and an
+// endpoint that keeps failing is disabled with its queue dead-lettered.
+type deliveryOutbox struct {
+	db     *sql.DB
+	logger *zap.Logger
+	client *http.Client
+	// targets are the queue and stream targets notifications name by ARN.
+	targets *notify.Registry
+
+	workers      int
+	perEndpoint  int
+	maxAttempts  int
+	disableAfter int
+}
+
+func newDeliveryOutbox(db *sql.DB, logger *zap.Logger) *deliveryOutbox {
+	return &deliveryOutbox{
+		db:           db,
+		logger:       logger,
+		client:       &http.Client{Timeout: deliveryTimeout},
+		workers:      deliveryWorkers,
+		perEndpoint:  deliveryPerEndpoint,
+		maxAttempts:  deliveryMaxAttempts,
+		disableAfter: deliveryDisableAfter,
+	}
+}
+
+// Start runs the worker pool until ctx is cancelled. Deliveries in flight
+// at shutdown keep their lease and are retried once it expires.
+func (o *deliveryOutbox) Start(ctx context.Context) {
+	go func() {
+		slots := make(chan struct{}, o.workers)
+		done := make(chan struct{}, o.workers)
+		ticker := time.NewTicker(deliveryPollInterval)
+		defer ticker.Stop()
+
+		for {
+			claimed := 0
+			if free := o.workers - len(slots); free > 0 {
+				deliveries, err := o.claim(ctx, free)
+				if err != nil && ctx.Err() == nil {
+					o.logger.Error("claim deliveries", zap.Error(err))
+				}
+				claimed = len(deliveries)
+				for _, d := range deliveries {
+					slots <- struct{}{}
+					go func(d outboxDelivery) {
+						defer func() {
+							<-slots
+							select {
+							case done <- struct{}{}:
+							default:
+							}
+						}()
+						o.deliver(ctx, d)
+					}(d)
+				}
+			}
+			// Claimed rows while workers are still free: more may be due.
+			if claimed > 0 && len(slots) < o.workers {
+				continue
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			case <-done:
+			}
+		}
+	}()
+}
+
+// claim leases up to n due deliveries.
+func (o *deliveryOutbox) claim(ctx context.Context, n int) ([]outboxDelivery, error) {
+	tx, err := o.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("begin claim: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1)`, deliveryClaimLock); err != nil {
+		return nil, fmt.Errorf("lock claim: %w", err)
+	}
+	rows, err := tx.QueryContext(ctx, claimDeliveriesQuery, n, o.perEndpoint, int(deliveryLease.Seconds()))
+	if err != nil {
+		return nil, fmt.Errorf("claim deliveries: %w", err)
+	}
+	deliveries, err := scanDeliveries(rows)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit claim: %w", err)
+	}
+	return deliveries, nil
+}
+
+// deliverNow claims the given pending deliveries and sends them before
+// returning, bypassing the pool.
+func (o *deliveryOutbox) deliverNow(ctx context.Context, ids []string) {
+	if len(ids) == 0 {
+		return
+	}
+	rows, err := o.db.QueryContext(ctx, `
+		UPDATE delivery_outbox o
+		SET status = 'delivering', attempts = o.attempts + 1,
+			locked_until = NOW() + $2 * INTERVAL '1 second', updated_at = NOW()
+		WHERE o.id = ANY($1) AND o.status = 'pending'
+		RETURNING o.id, o.kind, o.tenant_id, COALESCE(o.webhook_id, ''), COALESCE(o.bucket, ''),
+			o.url, COALESCE(o.event_id, ''), o.event_type, o.payload, o.attempts`,
+		pq.Array(ids), int(deliveryLease.Seconds()))
+	if err != nil {
+		o.logger.Error("claim deliveries", zap.Error(err))
+		return
+	}
+	deliveries, err := scanDeliveries(rows)
+	if err != nil {
+		o.logger.Error("claim deliveries", zap.Error(err))
+		return
+	}
+	for _, d := range deliveries {
+		o.deliver(ctx, d)
+	}
+}
+
+func scanDeliveries(rows *sql.Rows) ([]outboxDelivery, error) {
+	defer func() { _ = rows.Close() }()
+	var deliveries []outboxDelivery
+	for rows.Next() {
+		var d outboxDelivery
+		if err := rows.Scan(&d.id, &d.kind, &d.tenantID, &d.webhookID, &d.bucket,
+			&d.url, &d.eventID, &d.eventType, &d.payload, &d.attempts); err != nil {
+			return nil, fmt.Errorf("scan delivery: %w", err)
+		}
+		deliveries = append(deliveries, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("claim deliveries: %w", err)
+	}
+	return deliveries, nil
+}
+
+// deliver makes one attempt at d and records the outcome.
+func (o *deliveryOutbox) deliver(ctx context.Context, d outboxDelivery) {
+	if d.kind == deliveryKindNotification && strings.HasPrefix(d.url, "arn:") {
+		o.publish(ctx, d)
+		return
+	}
+	req, ok := o.buildRequest(ctx, d)
+	if !ok {
+		return
+	}
+
+	start := time.Now()
+	resp, err := o.client.Do(req)
+	latencyMs := int(time.Since(start).Milliseconds())
+	if err != nil {
+		if ctx.Err() != nil {
+			return // shutting down; the lease expires and another worker retries
+		}
+		o.fail(ctx, d, 0, "", err.Error(), latencyMs)
+		return
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxDeliveryResponseBytes))
+	_ = resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		o.fail(ctx, d, resp.StatusCode, string(body), fmt.Sprintf("endpoint returned %d", resp.StatusCode), latencyMs)
+		return
+	}
+	o.succeed(ctx, d, resp.StatusCode, string(body), latencyMs)
+}
+
+// buildRequest prepares d's POST against its endpoint's current settings.
+// Deliveries whose endpoint is gone or disabled are dead-lettered.
+func (o *deliveryOutbox) buildRequest(ctx context.Context, d outboxDelivery) (*http.Request, bool) {
+	var headers map[string]string
+	url := d.url
+	switch d.kind {
+	case deliveryKindWebhook:
+		var secret string
+		var enabled bool
+		err := o.db.QueryRowContext(ctx,
+			`SELECT url, secret, enabled FROM webhook_endpoints WHERE id = $1`,
+			d.webhookID).Scan(&url, &secret, &enabled)
+		if err == sql.ErrNoRows || (err == nil && !enabled) {
+			o.bury(ctx, d, "webhook is disabled or deleted")
+			return nil, false
+		}
+		if err != nil {
+			o.logger.Error("load webhook endpoint for delivery", zap.Error(err), zap.String("delivery_id", d.id))
+			return nil, false
+		}
+		headers = map[string]string{
+			"User-Agent":          "Vaultaire-Webhooks/1.0",
+			"X-Webhook-Signature": generateWebhookSignature(d.payload, secret),
+			"X-Event-ID":          d.eventID,
+		}
+	case deliveryKindNotification:
+		if !o.notificationEnabled(ctx, d) {
+			return nil, false
+		}
+		headers = map[string]string{"X-S3-Event": d.eventType}
+	default:
+		o.bury(ctx, d, "unknown delivery kind "+d.kind)
+		return nil, false
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(d.payload))
+	if err != nil {
+		o.fail(ctx, d, 0, "", err.Error(), 0)
+		return nil, false
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	return req, true
+}
+
+// publish sends notification d to the queue or stream target configured
+// for its ARN.
+func (o *deliveryOutbox) publish(ctx context.Context, d outboxDelivery) {
+	if !o.notificationEnabled(ctx, d) {
+		return
+	}
+	target, ok := o.targets.Get(d.url)
+	if !ok {
+		// The target may be configured again; retry until then.
+		o.fail(ctx, d, 0, "", "no notification target is configured for "+d.url, 0)
+		return
+	}
+
+	start := time.Now()
+	err := target.Publish(ctx, notificationObjectKey(d.payload), d.payload)
+	latencyMs := int(time.Since(start).Milliseconds())
+	if err != nil {
+		if ctx.Err() != nil {
+			return // shutting down; the lease expires and another worker retries
+		}
+		o.fail(ctx, d, 0, "", err.Error(), latencyMs)
+		return
+	}
+	o.succeed(ctx, d, 0, "", latencyMs)
+}
+
+// notificationEnabled reports whether d's bucket still sends to its target,
+// dead-lettering d when it does not.
+func (o *deliveryOutbox) notificationEnabled(ctx context.Context, d outboxDelivery) bool {
+	var enabled bool
+	err := o.db.QueryRowContext(ctx, `
+		SELECT EXISTS(SELECT 1 FROM bucket_notifications
+			WHERE tenant_id = $1 AND bucket = $2 AND target_url = $3 AND enabled = TRUE)`,
+		d.tenantID, d.bucket, d.url).Scan(&enabled)
+	if err != nil {
+		o.logger.Error("load notification target for delivery", zap.Error(err), zap.String("delivery_id", d.id))
+		return false
+	}
+	if !enabled {
+		o.bury(ctx, d, "notification target is disabled or removed")
+	}
+	return enabled
+}
+
+// notificationObjectKey is "bucket/key" for a notification payload, the key
+// queue and stream targets partition by.
+func notificationObjectKey(payload []byte) string {
+	var event S3Event
+	if err := json.Unmarshal(payload, &event); err != nil || len(event.Records) == 0 {
+		return ""
+	}
+	s3 := event.Records[0].S3
+	return s3.Bucket.Name + "/" + s3.Object.Key
+}
+
+func (o *deliveryOutbox) succeed(ctx context.Context, d outboxDelivery, code int, body string, latencyMs int) {
+	_, err := o.db.ExecContext(ctx, `
+		UPDATE delivery_outbox
+		SET status = 'delivered', locked_until = NULL, response_code = $2, response_body = $3,
+			latency_ms = $4, last_error = '', delivered_at = NOW(), updated_at = NOW()
+		WHERE id = $1`,
+		d.id, code, body, latencyMs)
+	if err != nil {
+		o.logger.Error("record delivery", zap.Error(err), zap.String("delivery_id", d.id))
+	}
+
+	if d.kind == deliveryKindWebhook {
+		_, err = o.db.ExecContext(ctx, `
+			UPDATE webhook_endpoints SET consecutive_failures = 0
+			WHERE id = $1 AND consecutive_failures <> 0`, d.webhookID)
+	} else {
+		_, err = o.db.ExecContext(ctx, `
+			UPDATE bucket_notifications SET consecutive_failures = 0
+			WHERE tenant_id = $1 AND bucket = $2 AND target_url = $3 AND consecutive_failures <> 0`,
+			d.tenantID, d.bucket, d.url)
+	}
+	if err != nil {
+		o.logger.Error("reset endpoint failures", zap.Error(err), zap.String("endpoint", d.endpointKey()))
+	}
+}
+
+// fail records a failed attempt, scheduling a retry or dead-lettering d,
+// and disables the endpoint once its consecutive failures reach the limit.
+func (o *deliveryOutbox) fail(ctx context.Context, d outboxDelivery, code int, body, reason string, latencyMs int) {
+	status := deliveryFailed
+	if d.attempts >= o.maxAttempts {
+		status = deliveryDead
+	}
+	_, err := o.db.ExecContext(ctx, `
+		UPDATE delivery_outbox
+		SET status = $2, next_attempt_at = $3, locked_until = NULL, response_code = $4,
+			response_body = $5, latency_ms = $6, last_error = $7, updated_at = NOW()
+		WHERE id = $1`,
+		d.id, status, time.Now().Add(deliveryBackoff(d.attempts)), code, body, latencyMs, reason)
+	if err != nil {
+		o.logger.Error("record delivery", zap.Error(err), zap.String("delivery_id", d.id))
+	}
+	o.logger.Warn("delivery failed",
+		zap.String("delivery_id", d.id),
+		zap.String("endpoint", d.endpointKey()),
+		zap.Int("attempt", d.attempts),
+		zap.String("status", status),
+		zap.String("reason", reason))
+
+	var failures int
+	if d.kind == deliveryKindWebhook {
+		err = o.db.QueryRowContext(ctx, `
+			UPDATE webhook_endpoints SET consecutive_failures = consecutive_failures + 1
+			WHERE id = $1 RETURNING consecutive_failures`, d.webhookID).Scan(&failures)
+	} else {
+		err = o.db.QueryRowContext(ctx, `
+			WITH failed AS (
+				UPDATE bucket_notifications SET consecutive_failures = consecutive_failures + 1
+				WHERE tenant_id = $1 AND bucket = $2 AND target_url = $3
+				RETURNING consecutive_failures
+			)
+			SELECT COALESCE(MAX(consecutive_failures), 0) FROM failed`,
+			d.tenantID, d.bucket, d.url).Scan(&failures)
+	}
+	if err != nil {
+		if err != sql.ErrNoRows {
+			o.logger.Error("count endpoint failures", zap.Error(err), zap.String("endpoint", d.endpointKey()))
+		}
+		return
+	}
+	if failures >= o.disableAfter {
+		o.disable(ctx, d, failures)
+	}
+}
+
+// disable turns off d's endpoint and dead-letters everything queued for it;
+// re-enabling the endpoint and replaying the window sends them again.
+func (o *deliveryOutbox) disable(ctx context.Context, d outboxDelivery, failures int) {
+	var res sql.Result
+	var err error
+	if d.kind == deliveryKindWebhook {
+		res, err = o.db.ExecContext(ctx, `
+			UPDATE webhook_endpoints SET enabled = FALSE, updated_at = NOW()
+			WHERE id = $1 AND enabled = TRUE`, d.webhookID)
+	} else {
+		res, err = o.db.ExecContext(ctx, `
+			UPDATE bucket_notifications SET enabled = FALSE
+			WHERE tenant_id = $1 AND bucket = $2 AND target_url = $3 AND enabled = TRUE`,
+			d.tenantID, d.bucket, d.url)
+	}
+	if err != nil {
+		o.logger.Error("disable failing endpoint", zap.Error(err), zap.String("endpoint", d.endpointKey()))
+		return
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return // already disabled
+	}
+	o.logger.Warn("disabled endpoint after consecutive delivery failures",
+		zap.String("endpoint", d.endpointKey()),
+		zap.String("tenant_id", d.tenantID),
+		zap.Int("failures", failures))
+
+	_, err = o.db.ExecContext(ctx, `
+		UPDATE delivery_outbox
+		SET status = 'dead', last_error = $2, locked_until = NULL, updated_at = NOW()
+		WHERE endpoint_key = $1 AND status IN ('pending', 'failed')`,
+		d.endpointKey(), fmt.Sprintf("endpoint disabled after %d consecutive failures", failures))
+	if err != nil {
+		o.logger.Error("dead-letter disabled endpoint deliveries", zap.Error(err), zap.String("endpoint", d.endpointKey()))
+	}
+}
+
+// bury dead-letters d without attempting it.
+func (o *deliveryOutbox) bury(ctx context.Context, d outboxDelivery, reason string) {
+	_, err := o.db.ExecContext(ctx, `
+		UPDATE delivery_outbox
+		SET status = 'dead', last_error = $2, locked_until = NULL, updated_at = NOW()
+		WHERE id = $1`,
+		d.id, reason)
+	if err != nil {
+		o.logger.Error("record delivery", zap.Error(err), zap.String("delivery_id", d.id))
+	}
+}
+
+// deliveryBackoff is the wait after the given failed attempt (1-based):
+// doubling from deliveryBaseBackoff up to deliveryMaxBackoff, with jitter
+// so deliveries that failed together don't retry together.
+func deliveryBackoff(attempt int) time.Duration {
+	delay := deliveryMaxBackoff
+	if attempt < 1 {
+		attempt = 1
+	}
+	if attempt <= 20 {
+		if d := deliveryBaseBackoff << (attempt - 1); d < delay {
+			delay = d
+		}
+	}
+	jitter := 0.5 + rand.Float64() // #nosec G404 — retry jitter, not security
+	return time.Duration(float64(delay) * jitter)
+}
@@ -0,0 +1,307 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/FairForge/vaultaire/internal/notify"
+	"github.com/FairForge/vaultaire/internal/streaming"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func newTestOutbox(t *testing.T) (*deliveryOutbox, sqlmock.Sqlmock) {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	return newDeliveryOutbox(db, zap.NewNop()), mock
+}
+
+func testWebhookDelivery(url string, attempts int) outboxDelivery {
+	return outboxDelivery{
+		outboxEntry: outboxEntry{
+			kind:      deliveryKindWebhook,
+			tenantID:  "tenant-1",
+			webhookID: "wh-1",
+			url:       url,
+			eventID:   "evt-1",
+			eventType: "object.created",
+			payload:   []byte(`{"id":"evt-1"}`),
+		},
+		id:       "del-1",
+		attempts: attempts,
+	}
+}
+
+func TestDeliveryBackoff(t *testing.T) {
+	for attempt, base := range map[int]time.Duration{
+		1:  10 * time.Second,
+		2:  20 * time.Second,
+		5:  160 * time.Second,
+		15: deliveryMaxBackoff,
+		99: deliveryMaxBackoff,
+	} {
+		for i := 0; i < 20; i++ {
+			d := deliveryBackoff(attempt)
+			assert.GreaterOrEqual(t, d, base/2, "attempt %d", attempt)
+			assert.LessOrEqual(t, d, base*3/2, "attempt %d", attempt)
+		}
+	}
+}
+
+func TestDeliveryOutbox_Claim(t *testing.T) {
+	o, mock := newTestOutbox(t)
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`SELECT pg_advisory_xact_lock`).
+		WithArgs(deliveryClaimLock).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`WITH active AS .* UPDATE delivery_outbox o`).
+		WithArgs(4, deliveryPerEndpoint, int(deliveryLease.Seconds())).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "kind", "tenant_id", "webhook_id", "bucket", "url", "event_id", "event_type", "payload", "attempts"}).
+			AddRow("del-1", "webhook", "tenant-1", "wh-1", "", "https://example.com/hook", "evt-1", "object.created", []byte(`{}`), 1).
+			AddRow("del-2", "notification", "tenant-1", "", "photos", "https://example.com/n", "", "s3:ObjectCreated:Put", []byte(`{}`), 3))
+	mock.ExpectCommit()
+
+	deliveries, err := o.claim(context.Background(), 4)
+	require.NoError(t, err)
+	require.Len(t, deliveries, 2)
+	assert.Equal(t, "webhook:wh-1", deliveries[0].endpointKey())
+	assert.Equal(t, "notification:tenant-1/photos/https://example.com/n", deliveries[1].endpointKey())
+	assert.Equal(t, 3, deliveries[1].attempts)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDeliveryOutbox_DeliverWebhook(t *testing.T) {
+	var got *http.Request
+	var body []byte
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer receiver.Close()
+
+	o, mock := newTestOutbox(t)
+	d := testWebhookDelivery("https://stale.example.com", 1)
+
+	// The endpoint's current URL and secret are used.
+	mock.ExpectQuery(`SELECT url, secret, enabled FROM webhook_endpoints`).
+		WithArgs("wh-1").
+		WillReturnRows(sqlmock.NewRows([]string{"url", "secret", "enabled"}).AddRow(receiver.URL, "whsec_test", true))
+	mock.ExpectExec(`UPDATE delivery_outbox\s+SET status = 'delivered'`).
+		WithArgs("del-1", 200, "ok", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE webhook_endpoints SET consecutive_failures = 0`).
+		WithArgs("wh-1").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	o.deliver(context.Background(), d)
+
+	require.NotNil(t, got)
+	assert.Equal(t, `{"id":"evt-1"}`, string(body))
+	assert.Equal(t, generateWebhookSignature(d.payload, "whsec_test"), got.Header.Get("X-Webhook-Signature"))
+	assert.Equal(t, "evt-1", got.Header.Get("X-Event-ID"))
+	assert.Equal(t, "application/json", got.Header.Get("Content-Type"))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDeliveryOutbox_FailureSchedulesRetry(t *testing.T) {
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer receiver.Close()
+
+	o, mock := newTestOutbox(t)
+
+	mock.ExpectQuery(`SELECT url, secret, enabled FROM webhook_endpoints`).
+		WithArgs("wh-1").
+		WillReturnRows(sqlmock.NewRows([]string{"url", "secret", "enabled"}).AddRow(receiver.URL, "whsec_test", true))
+	mock.ExpectExec(`UPDATE delivery_outbox\s+SET status = \$2, next_attempt_at = \$3`).
+		WithArgs("del-1", deliveryFailed, sqlmock.AnyArg(), 503, "", sqlmock.AnyArg(), "endpoint returned 503").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`UPDATE webhook_endpoints SET consecutive_failures = consecutive_failures \+ 1`).
+		WithArgs("wh-1").
+		WillReturnRows(sqlmock.NewRows([]string{"consecutive_failures"}).AddRow(1))
+
+	o.deliver(context.Background(), testWebhookDelivery(receiver.URL, 1))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDeliveryOutbox_DeadLetterAndDisable(t *testing.T) {
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer receiver.Close()
+
+	o, mock := newTestOutbox(t)
+	o.disableAfter = 3
+
+	mock.ExpectQuery(`SELECT url, secret, enabled FROM webhook_endpoints`).
+		WithArgs("wh-1").
+		WillReturnRows(sqlmock.NewRows([]string{"url", "secret", "enabled"}).AddRow(receiver.URL, "whsec_test", true))
+	// The last attempt dead-letters the delivery...
+	mock.ExpectExec(`UPDATE delivery_outbox\s+SET status = \$2`).
+		WithArgs("del-1", deliveryDead, sqlmock.AnyArg(), 500, "", sqlmock.AnyArg(), "endpoint returned 500").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	// ...and the third consecutive failure disables the endpoint and
+	// dead-letters its queue.
+	mock.ExpectQuery(`UPDATE webhook_endpoints SET consecutive_failures = consecutive_failures \+ 1`).
+		WithArgs("wh-1").
+		WillReturnRows(sqlmock.NewRows([]string{"consecutive_failures"}).AddRow(3))
+	mock.ExpectExec(`UPDATE webhook_endpoints SET enabled = FALSE`).
+		WithArgs("wh-1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE delivery_outbox\s+SET status = 'dead'.*WHERE endpoint_key = \$1`).
+		WithArgs("webhook:wh-1", "endpoint disabled after 3 consecutive failures").
+		WillReturnResult(sqlmock.NewResult(0, 7))
+
+	o.deliver(context.Background(), testWebhookDelivery(receiver.URL, deliveryMaxAttempts))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDeliveryOutbox_DisabledEndpointIsNotCalled(t *testing.T) {
+	called := false
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	defer receiver.Close()
+
+	o, mock := newTestOutbox(t)
+
+	mock.ExpectQuery(`SELECT url, secret, enabled FROM webhook_endpoints`).
+		WithArgs("wh-1").
+		WillReturnRows(sqlmock.NewRows([]string{"url", "secret", "enabled"}).AddRow(receiver.URL, "whsec_test", false))
+	mock.ExpectExec(`UPDATE delivery_outbox\s+SET status = 'dead'.*WHERE id = \$1`).
+		WithArgs("del-1", "webhook is disabled or deleted").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	o.deliver(context.Background(), testWebhookDelivery(receiver.URL, 1))
+	assert.False(t, called)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDeliveryOutbox_DeliverNotification(t *testing.T) {
+	var event string
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		event = r.Header.Get("X-S3-Event")
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+
+	o, mock := newTestOutbox(t)
+	d := outboxDelivery{
+		outboxEntry: outboxEntry{
+			kind:      deliveryKindNotification,
+			tenantID:  "tenant-1",
+			bucket:    "photos",
+			url:       receiver.URL,
+			eventType: "s3:ObjectCreated:Put",
+			payload:   []byte(`{"Records":[]}`),
+		},
+		id:       "del-2",
+		attempts: 2,
+	}
+
+	mock.ExpectQuery(`SELECT EXISTS\(SELECT 1 FROM bucket_notifications`).
+		WithArgs("tenant-1", "photos", receiver.URL).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectExec(`UPDATE delivery_outbox\s+SET status = 'delivered'`).
+		WithArgs("del-2", 204, "", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE bucket_notifications SET consecutive_failures = 0`).
+		WithArgs("tenant-1", "photos", receiver.URL).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	o.deliver(context.Background(), d)
+	assert.Equal(t, "s3:ObjectCreated:Put", event)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestDeliveryOutbox_PublishToTarget(t *testing.T) {
+	const arn = "arn:aws:sqs:us-east-1:000000000000:uploads"
+	streams := streaming.NewStreamManager(nil)
+	targets, err := notify.NewRegistry(map[string]string{arn: "stream://uploads"}, streams)
+	require.NoError(t, err)
+	sub, err := streams.Subscribe("uploads", "test", streaming.SubscribeOptions{StartOffset: streaming.OffsetEarliest})
+	require.NoError(t, err)
+	defer func() { _ = sub.Close() }()
+
+	o, mock := newTestOutbox(t)
+	o.targets = targets
+	payload, err := json.Marshal(newS3Event("cfg-1", "tenant-1", "photos", "s3:ObjectCreated:Put", "cat.jpg", 3, "", time.Now()))
+	require.NoError(t, err)
+	d := outboxDelivery{
+		outboxEntry: outboxEntry{
+			kind:      deliveryKindNotification,
+			tenantID:  "tenant-1",
+			bucket:    "photos",
+			url:       arn,
+			eventType: "s3:ObjectCreated:Put",
+			payload:   payload,
+		},
+		id:       "del-3",
+		attempts: 1,
+	}
+
+	mock.ExpectQuery(`SELECT EXISTS\(SELECT 1 FROM bucket_notifications`).
+		WithArgs("tenant-1", "photos", arn).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectExec(`UPDATE delivery_outbox\s+SET status = 'delivered'`).
+		WithArgs("del-3", 0, "", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE bucket_notifications SET consecutive_failures = 0`).
+		WithArgs("tenant-1", "photos", arn).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	o.deliver(context.Background(), d)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	msg, err := sub.Receive(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "photos/cat.jpg", msg.Key)
+	assert.Equal(t, string(payload), string(msg.Value))
+}
+
+func TestDeliveryOutbox_PublishToMissingTarget(t *testing.T) {
+	const arn = "arn:aws:sqs:us-east-1:000000000000:gone"
+	o, mock := newTestOutbox(t)
+	d := outboxDelivery{
+		outboxEntry: outboxEntry{
+			kind:      deliveryKindNotification,
+			tenantID:  "tenant-1",
+			bucket:    "photos",
+			url:       arn,
+			eventType: "s3:ObjectCreated:Put",
+			payload:   []byte(`{"Records":[]}`),
+		},
+		id:       "del-4",
+		attempts: 1,
+	}
+
+	// A target dropped from the configuration is retried, not buried, so
+	// restoring it delivers the backlog.
+	mock.ExpectQuery(`SELECT EXISTS\(SELECT 1 FROM bucket_notifications`).
+		WithArgs("tenant-1", "photos", arn).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectExec(`UPDATE delivery_outbox\s+SET status = \$2, next_attempt_at = \$3`).
+		WithArgs("del-4", deliveryFailed, sqlmock.AnyArg(), 0, "", 0, "no notification target is configured for "+arn).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`WITH failed AS`).
+		WithArgs("tenant-1", "photos", arn).
+		WillReturnRows(sqlmock.NewRows([]string{"max"}).AddRow(1))
+
+	o.deliver(context.Background(), d)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
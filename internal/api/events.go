@@ -1,7 +1,6 @@
 package api
 
 import (
-	"bytes"
 	"context"
 	"crypto/hmac"
 	"crypto/rand"
@@ -29,6 +28,9 @@ var validEventTypes = []string{
 	"key.created",
 	"key.revoked",
 	"sts.token_created",
+	"sts.role_assumed",
+	"member.provisioned",
+	"member.deprovisioned",
 	"webhook.test",
 	"bandwidth.alert",
 }
@@ -87,6 +89,8 @@ func emitEvent(ctx context.Context, db *sql.DB, logger *zap.Logger, eventType, t
 	go dispatchWebhooks(db, logger, eventID, eventType, tenantID, dataJSON) // #nosec G118 -- intentional fire-and-forget after response
 }
 
+// dispatchWebhooks enqueues the event for every enabled endpoint whose
+// filter matches; the delivery outbox sends it.
 func dispatchWebhooks(db *sql.DB, logger *zap.Logger, eventID, eventType, tenantID string, payload []byte) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
@@ -120,81 +124,43 @@ func dispatchWebhooks(db *sql.DB, logger *zap.Logger, eventID, eventType, tenant
 		endpoints = append(endpoints, ep)
 	}
 
-	eventPayload := map[string]interface{}{
-		"id":         eventID,
-		"type":       eventType,
-		"tenant_id":  tenantID,
-		"data":       json.RawMessage(payload),
-		"created_at": time.Now().UTC().Format(time.RFC3339),
-	}
-	body, err := json.Marshal(eventPayload)
+	body, err := webhookPayload(eventID, eventType, tenantID, payload, time.Now())
 	if err != nil {
 		logger.Error("marshal webhook payload", zap.Error(err))
 		return
 	}
 
-	client := &http.Client{Timeout: 5 * time.Second}
 	for _, ep := range endpoints {
 		if !matchesWebhookFilter(ep.filter, eventType) {
 			continue
 		}
-		deliverWebhook(ctx, db, logger, client, ep.id, ep.url, ep.secret, eventID, body)
-	}
-}
-
-func deliverWebhook(ctx context.Context, db *sql.DB, logger *zap.Logger, client *http.Client, webhookID, url, secret, eventID string, body []byte) {
-	deliveryID := uuid.New().String()
-	start := time.Now()
-
-	sig := generateWebhookSignature(body, secret)
-
-	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
-	if err != nil {
-		logger.Error("create webhook request", zap.Error(err), zap.String("url", url))
-		recordDelivery(ctx, db, logger, deliveryID, webhookID, eventID, "failed", 0, err.Error(), 0)
-		return
-	}
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("User-Agent", "Vaultaire-Webhooks/1.0")
-	req.Header.Set("X-Webhook-Signature", sig)
-	req.Header.Set("X-Event-ID", eventID)
-
-	resp, err := client.Do(req)
-	latencyMs := int(time.Since(start).Milliseconds())
-	if err != nil {
-		logger.Warn("webhook delivery failed",
-			zap.Error(err),
-			zap.String("webhook_id", webhookID),
-			zap.String("url", url))
-		recordDelivery(ctx, db, logger, deliveryID, webhookID, eventID, "failed", 0, err.Error(), latencyMs)
-		return
-	}
-	defer func() { _ = resp.Body.Close() }()
-
-	var respBody strings.Builder
-	respBody.Grow(1024)
-	buf := make([]byte, 1024)
-	n, _ := resp.Body.Read(buf)
-	respBody.Write(buf[:n])
-
-	status := "delivered"
-	if resp.StatusCode >= 400 {
-		status = "failed"
+		_, err := enqueueDelivery(ctx, db, outboxEntry{
+			kind:      deliveryKindWebhook,
+			tenantID:  tenantID,
+			webhookID: ep.id,
+			url:       ep.url,
+			eventID:   eventID,
+			eventType: eventType,
+			payload:   body,
+		})
+		if err != nil {
+			logger.Error("enqueue webhook delivery",
+				zap.Error(err),
+				zap.String("webhook_id", ep.id),
+				zap.String("event_id", eventID))
+		}
 	}
-
-	recordDelivery(ctx, db, logger, deliveryID, webhookID, eventID, status, resp.StatusCode, respBody.String(), latencyMs)
 }
 
-func recordDelivery(ctx context.Context, db *sql.DB, logger *zap.Logger, deliveryID, webhookID, eventID, status string, responseCode int, responseBody string, latencyMs int) {
-	_, err := db.ExecContext(ctx, `
-		INSERT INTO webhook_deliveries (id, webhook_id, event_id, status, response_code, response_body, latency_ms)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)`,
-		deliveryID, webhookID, eventID, status, responseCode, responseBody, latencyMs)
-	if err != nil {
-		logger.Error("record webhook delivery",
-			zap.Error(err),
-			zap.String("delivery_id", deliveryID))
-	}
+// webhookPayload is the body POSTed to webhook endpoints for an event.
+func webhookPayload(eventID, eventType, tenantID string, data []byte, createdAt time.Time) ([]byte, error) {
+	return json.Marshal(map[string]interface{}{
+		"id":         eventID,
+		"type":       eventType,
+		"tenant_id":  tenantID,
+		"data":       json.RawMessage(data),
+		"created_at": createdAt.UTC().Format(time.RFC3339),
+	})
 }
 
 func generateWebhookSignature(payload []byte, secret string) string {
@@ -48,6 +48,8 @@ func newWebhookTestServer(t *testing.T) (*Server, sqlmock.Sqlmock, func()) {
 		r.Patch("/{id}", s.handleUpdateWebhook)
 		r.Delete("/{id}", s.handleDeleteWebhook)
 		r.Get("/{id}/deliveries", s.handleListDeliveries)
+		r.Post("/{id}/deliveries/{delivery_id}/redeliver", s.handleRedeliverDelivery)
+		r.Post("/{id}/replay", s.handleReplayWebhook)
 		r.Post("/{id}/test", s.handleTestWebhook)
 	})
 	s.router.Get("/api/v1/events", s.handleListEvents)
@@ -363,11 +365,11 @@ func TestDeliveryHistory(t *testing.T) {
 		WithArgs("wh-123", "test-tenant").
 		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
 
-	mock.ExpectQuery(`SELECT id, event_id, status, response_code, latency_ms, retry_count, created_at FROM webhook_deliveries`).
+	mock.ExpectQuery(`SELECT id, event_id, status, response_code, latency_ms, attempts, next_attempt_at, last_error, created_at FROM delivery_outbox`).
 		WithArgs("wh-123", 21).
-		WillReturnRows(sqlmock.NewRows([]string{"id", "event_id", "status", "response_code", "latency_ms", "retry_count", "created_at"}).
-			AddRow("del-1", "evt-1", "delivered", 200, 42, 0, now).
-			AddRow("del-2", "evt-2", "failed", 500, 150, 1, now.Add(-time.Minute)))
+		WillReturnRows(sqlmock.NewRows([]string{"id", "event_id", "status", "response_code", "latency_ms", "attempts", "next_attempt_at", "last_error", "created_at"}).
+			AddRow("del-1", "evt-1", "delivered", 200, 42, 1, now, "", now).
+			AddRow("del-2", "evt-2", "failed", 500, 150, 2, now.Add(time.Minute), "endpoint returned 500", now.Add(-time.Minute)))
 
 	mock.ExpectQuery(`SELECT COUNT`).
 		WithArgs("wh-123").
@@ -388,6 +390,141 @@ func TestDeliveryHistory(t *testing.T) {
 	assert.Equal(t, "webhook_delivery", first["object"])
 	assert.Equal(t, "delivered", first["status"])
 	assert.Equal(t, float64(200), first["response_code"])
+	assert.NotContains(t, first, "next_attempt_at")
+
+	second := data[1].(map[string]interface{})
+	assert.Equal(t, float64(2), second["attempts"])
+	assert.Equal(t, float64(1), second["retry_count"])
+	assert.Equal(t, "endpoint returned 500", second["last_error"])
+	assert.Equal(t, now.Add(time.Minute).Format(time.RFC3339), second["next_attempt_at"])
+}
+
+func TestDispatchWebhooks_EnqueuesMatchingEndpoints(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() { _ = db.Close() }()
+
+	mock.ExpectQuery(`SELECT id, url, event_filter, secret FROM webhook_endpoints`).
+		WithArgs("tenant-1").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "url", "event_filter", "secret"}).
+			AddRow("wh-1", "https://example.com/a", pq.StringArray{"object.*"}, "whsec_a").
+			AddRow("wh-2", "https://example.com/b", pq.StringArray{"bucket.created"}, "whsec_b"))
+	mock.ExpectExec(`INSERT INTO delivery_outbox`).
+		WithArgs(sqlmock.AnyArg(), deliveryKindWebhook, "tenant-1", "webhook:wh-1", "wh-1", nil,
+			"https://example.com/a", "evt-1", "object.created", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	dispatchWebhooks(db, zap.NewNop(), "evt-1", "object.created", "tenant-1", []byte(`{"key":"a.txt"}`))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRedeliverDelivery(t *testing.T) {
+	s, mock, cleanup := newWebhookTestServer(t)
+	defer cleanup()
+
+	mock.ExpectQuery(`SELECT enabled FROM webhook_endpoints`).
+		WithArgs("wh-123", "test-tenant").
+		WillReturnRows(sqlmock.NewRows([]string{"enabled"}).AddRow(true))
+	mock.ExpectExec(`INSERT INTO delivery_outbox .* SELECT .* FROM delivery_outbox`).
+		WithArgs(sqlmock.AnyArg(), "del-1", "wh-123", "test-tenant").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	req := httptest.NewRequest("POST", "/api/v1/webhooks/wh-123/deliveries/del-1/redeliver", nil)
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	var resp map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "del-1", resp["redelivery_of"])
+	assert.Equal(t, "pending", resp["status"])
+	assert.NotEqual(t, "del-1", resp["id"])
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRedeliverDelivery_Errors(t *testing.T) {
+	s, mock, cleanup := newWebhookTestServer(t)
+	defer cleanup()
+
+	// Unknown delivery.
+	mock.ExpectQuery(`SELECT enabled FROM webhook_endpoints`).
+		WithArgs("wh-123", "test-tenant").
+		WillReturnRows(sqlmock.NewRows([]string{"enabled"}).AddRow(true))
+	mock.ExpectExec(`INSERT INTO delivery_outbox`).
+		WithArgs(sqlmock.AnyArg(), "nope", "wh-123", "test-tenant").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, httptest.NewRequest("POST", "/api/v1/webhooks/wh-123/deliveries/nope/redeliver", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	// Disabled webhook.
+	mock.ExpectQuery(`SELECT enabled FROM webhook_endpoints`).
+		WithArgs("wh-123", "test-tenant").
+		WillReturnRows(sqlmock.NewRows([]string{"enabled"}).AddRow(false))
+
+	w = httptest.NewRecorder()
+	s.router.ServeHTTP(w, httptest.NewRequest("POST", "/api/v1/webhooks/wh-123/deliveries/del-1/redeliver", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "webhook_disabled")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestReplayWebhook(t *testing.T) {
+	s, mock, cleanup := newWebhookTestServer(t)
+	defer cleanup()
+
+	from := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	to := from.Add(time.Hour)
+
+	mock.ExpectQuery(`SELECT url, event_filter, enabled FROM webhook_endpoints`).
+		WithArgs("wh-123", "test-tenant").
+		WillReturnRows(sqlmock.NewRows([]string{"url", "event_filter", "enabled"}).
+			AddRow("https://example.com/hook", pq.StringArray{"object.*"}, true))
+	mock.ExpectQuery(`SELECT id, type, data, created_at FROM events`).
+		WithArgs("test-tenant", from, to, maxReplayEvents+1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "type", "data", "created_at"}).
+			AddRow("evt-1", "object.created", []byte(`{"key":"a"}`), from.Add(time.Minute)).
+			AddRow("evt-2", "key.created", []byte(`{}`), from.Add(2*time.Minute)).
+			AddRow("evt-3", "object.deleted", []byte(`{"key":"a"}`), from.Add(3*time.Minute)))
+	mock.ExpectBegin()
+	for _, e := range []struct{ id, typ string }{{"evt-1", "object.created"}, {"evt-3", "object.deleted"}} {
+		mock.ExpectExec(`INSERT INTO delivery_outbox`).
+			WithArgs(sqlmock.AnyArg(), deliveryKindWebhook, "test-tenant", "webhook:wh-123", "wh-123", nil,
+				"https://example.com/hook", e.id, e.typ, sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+	mock.ExpectCommit()
+
+	body := `{"from":"2026-03-01T00:00:00Z","to":"2026-03-01T01:00:00Z"}`
+	req := httptest.NewRequest("POST", "/api/v1/webhooks/wh-123/replay", bytes.NewBufferString(body))
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	var resp map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "webhook_replay", resp["object"])
+	assert.Equal(t, float64(2), resp["queued"])
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestReplayWebhook_InvalidWindow(t *testing.T) {
+	s, _, cleanup := newWebhookTestServer(t)
+	defer cleanup()
+
+	for body, code := range map[string]string{
+		`{}`:                   "invalid_from",
+		`{"from":"yesterday"}`: "invalid_from",
+		`{"from":"2026-03-01T00:00:00Z","to":"soon"}`:                 "invalid_to",
+		`{"from":"2026-03-01T01:00:00Z","to":"2026-03-01T00:00:00Z"}`: "invalid_window",
+	} {
+		req := httptest.NewRequest("POST", "/api/v1/webhooks/wh-123/replay", bytes.NewBufferString(body))
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code, body)
+		assert.Contains(t, w.Body.String(), code, body)
+	}
 }
 
 func TestMatchesEventFilter(t *testing.T) {
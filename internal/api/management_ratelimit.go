@@ -3,27 +3,42 @@ package api
 import (
 	"fmt"
 	"net/http"
+	"strconv"
 	"sync"
 	"time"
 
+	"github.com/FairForge/vaultaire/internal/ratelimit"
 	"golang.org/x/time/rate"
 )
 
+// managementRequestsPerMinute is the advertised X-RateLimit-Limit.
+const managementRequestsPerMinute = 100
+
 type ManagementRateLimiter struct {
 	mu       sync.Mutex
 	limiters map[string]*rate.Limiter
 	rps      rate.Limit
 	burst    int
+	shared   *ratelimit.SharedLimiter // replaces limiters when set
 }
 
 func NewManagementRateLimiter() *ManagementRateLimiter {
 	return &ManagementRateLimiter{
 		limiters: make(map[string]*rate.Limiter),
-		rps:      rate.Limit(100.0 / 60.0), // 100 requests per minute
+		rps:      rate.Limit(managementRequestsPerMinute / 60.0),
 		burst:    10,
 	}
 }
 
+// UseShared keeps the per-tenant buckets in shared under name, so every
+// replica enforces one limit. A nil shared keeps them in process memory.
+func (rl *ManagementRateLimiter) UseShared(shared *ratelimit.Shared, name string) {
+	if shared == nil {
+		return
+	}
+	rl.shared = ratelimit.NewSharedLimiter(shared, name, float64(rl.rps), rl.burst)
+}
+
 func (rl *ManagementRateLimiter) getLimiter(tenantID string) *rate.Limiter {
 	rl.mu.Lock()
 	defer rl.mu.Unlock()
@@ -48,6 +63,21 @@ func (rl *ManagementRateLimiter) Middleware(next http.Handler) http.Handler {
 			return
 		}
 
+		if rl.shared != nil {
+			res := rl.shared.Take(r.Context(), tenantID)
+			info := res.Info()
+			info.Limit = managementRequestsPerMinute
+			ratelimit.SetHeaders(w, info, false)
+			if !res.Allowed {
+				w.Header().Set("Retry-After", strconv.Itoa(res.RetryAfterSeconds()))
+				writeManagementError(w, ErrTypeRateLimit, "rate_limit_exceeded",
+					"too many requests, please retry later", "")
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
 		lim := rl.getLimiter(tenantID)
 		reservation := lim.Reserve()
 
@@ -57,7 +87,7 @@ func (rl *ManagementRateLimiter) Middleware(next http.Handler) http.Handler {
 		}
 		resetAt := time.Now().Add(time.Duration(float64(time.Second) / float64(rl.rps))).Unix()
 
-		w.Header().Set("X-RateLimit-Limit", "100")
+		w.Header().Set("X-RateLimit-Limit", strconv.Itoa(managementRequestsPerMinute))
 		w.Header().Set("X-RateLimit-Remaining", fmt.Sprintf("%d", remaining))
 		w.Header().Set("X-RateLimit-Reset", fmt.Sprintf("%d", resetAt))
 
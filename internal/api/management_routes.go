@@ -17,6 +17,7 @@ import (
 
 func (s *Server) registerManagementRoutes() {
 	rl := NewManagementRateLimiter()
+	rl.UseShared(s.rateLimits, "manage")
 	im := newIdempotencyMiddleware(s.db, s.logger)
 
 	s.router.Route("/api/v1/manage", func(r chi.Router) {
@@ -136,30 +136,48 @@ func (m *MultipartReaper) abortAbandoned(ctx context.Context) (int, error) {
 
 	var aborted int
 	for _, id := range ids {
-		// Conditional: a concurrent CompleteMultipartUpload may have flipped
-		// the status since the scan — never remove a completed upload's state.
-		res, err := m.db.ExecContext(ctx, `
-			UPDATE multipart_uploads SET status = 'aborted'
-			WHERE upload_id = $1 AND status = 'active'
-		`, id)
+		ok, err := abortActiveUpload(ctx, m.db, m.logger, id)
 		if err != nil {
 			m.logger.Error("abort abandoned upload", zap.String("upload_id", id), zap.Error(err))
 			continue
 		}
-		if n, _ := res.RowsAffected(); n == 0 {
+		if !ok {
 			continue
 		}
-		if err := os.RemoveAll(multipartDir(id)); err != nil {
-			// Dir removal failing leaks disk, not correctness; the terminal
-			// purge retries it before deleting the row.
-			m.logger.Warn("remove abandoned upload dir", zap.String("upload_id", id), zap.Error(err))
-		}
 		aborted++
 		m.logger.Info("aborted abandoned multipart upload", zap.String("upload_id", id))
 	}
 	return aborted, nil
 }
 
+// abortActiveUpload marks a single upload aborted and removes its part
+// directory. It reports false when the upload was no longer active. Shared
+// by the reaper and the lifecycle AbortIncompleteMultipartUpload rule.
+func abortActiveUpload(ctx context.Context, db *sql.DB, logger *zap.Logger, id string) (bool, error) {
+	// Conditional: a concurrent CompleteMultipartUpload may have flipped
+	// the status since the scan — never remove a completed upload's state.
+	res, err := db.ExecContext(ctx, `
+		UPDATE multipart_uploads SET status = 'aborted'
+		WHERE upload_id = $1 AND status = 'active'
+	`, id)
+	if err != nil {
+		return false, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return false, nil
+	}
+	if err := releasePartChunks(ctx, db, nil, id); err != nil {
+		// Left for the terminal purge to retry.
+		logger.Warn("release aborted upload chunk refs", zap.String("upload_id", id), zap.Error(err))
+	}
+	if err := os.RemoveAll(multipartDir(id)); err != nil {
+		// Dir removal failing leaks disk, not correctness; the terminal
+		// purge retries it before deleting the row.
+		logger.Warn("remove aborted upload dir", zap.String("upload_id", id), zap.Error(err))
+	}
+	return true, nil
+}
+
 func (m *MultipartReaper) purgeTerminal(ctx context.Context) (int, error) {
 	retentionSecs := int(m.TerminalRetention.Seconds())
 	rows, err := m.db.QueryContext(ctx, `
@@ -191,6 +209,12 @@ func (m *MultipartReaper) purgeTerminal(ctx context.Context) (int, error) {
 		if err := os.RemoveAll(multipartDir(id)); err != nil {
 			m.logger.Warn("remove terminal upload dir", zap.String("upload_id", id), zap.Error(err))
 		}
+		// Chunk reference rows do not cascade (they hold GCI refs): release
+		// them first, or the upload row cannot be deleted.
+		if err := releasePartChunks(ctx, m.db, nil, id); err != nil {
+			m.logger.Error("release terminal upload chunk refs", zap.String("upload_id", id), zap.Error(err))
+			continue
+		}
 		if _, err := m.db.ExecContext(ctx, `
 			DELETE FROM multipart_uploads WHERE upload_id = $1
 		`, id); err != nil {
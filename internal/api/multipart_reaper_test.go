@@ -137,6 +137,45 @@ func TestMultipartReaper_PurgesTerminalRowsPastRetention(t *testing.T) {
 	assert.True(t, ok, "recent terminal rows stay within retention")
 }
 
+// Part chunk rows hold GCI references, so deleting their upload must not
+// cascade them away: the purge releases each reference with its row.
+func TestMultipartReaper_PurgeReleasesPartChunkRefs(t *testing.T) {
+	f := setupQuotaAccountingFixture(t, 100<<20)
+	reaper := NewMultipartReaper(f.db, zap.NewNop())
+	require.NotNil(t, reaper)
+	reaper.TerminalRetention = 7 * 24 * time.Hour
+
+	uploadID := reaperFixtureUpload(t, f, "aborted", 8*24*time.Hour, 8*24*time.Hour, false)
+	hash := fmt.Sprintf("%064x", uuid.New().ID())
+	_, err := f.db.Exec(`
+		INSERT INTO global_content_index
+			(dedup_scope, plaintext_hash, backend_id, storage_key, size_bytes, ref_count)
+		VALUES ('_global', $1, 'local', $2, 1024, 2)`, hash, "_chunks/"+hash)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_, _ = f.db.Exec(`DELETE FROM global_content_index WHERE plaintext_hash = $1`, hash)
+	})
+	_, err = f.db.Exec(`
+		INSERT INTO multipart_part_chunks
+			(upload_id, part_number, seq, dedup_scope, plaintext_hash, chunk_size, skip_bytes, take_bytes)
+		VALUES ($1, 1, 0, '_global', $2, 1024, 0, 1024)`, uploadID, hash)
+	require.NoError(t, err)
+
+	_, err = f.db.Exec(`DELETE FROM multipart_uploads WHERE upload_id = $1`, uploadID)
+	require.Error(t, err, "an upload holding chunk refs must not be deleted out from under them")
+
+	_, err = reaper.RunOnce(context.Background())
+	require.NoError(t, err)
+
+	_, ok := uploadStatus(t, f, uploadID)
+	assert.False(t, ok, "the purge must still delete the upload row")
+	var refCount int
+	require.NoError(t, f.db.QueryRow(
+		`SELECT ref_count FROM global_content_index WHERE dedup_scope = '_global' AND plaintext_hash = $1`,
+		hash).Scan(&refCount))
+	assert.Equal(t, 1, refCount, "the part's chunk reference must be released")
+}
+
 func TestMultipartReaper_NilGuards(t *testing.T) {
 	assert.Nil(t, NewMultipartReaper(nil, zap.NewNop()), "nil db must return nil reaper")
 }
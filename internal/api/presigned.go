@@ -68,6 +68,92 @@ func (s *Server) handleGetPresignedURL(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+// handleGetPresignedPost returns a signed POST policy and the form fields a
+// browser needs to upload straight to a bucket (S3 POST Object).
+func (s *Server) handleGetPresignedPost(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	bucket := q.Get("bucket")
+	opts := presignedPostOptions{
+		key:                   q.Get("key"),
+		contentType:           q.Get("content_type"),
+		successActionStatus:   q.Get("success_action_status"),
+		successActionRedirect: q.Get("success_action_redirect"),
+	}
+	if prefix := q.Get("key_prefix"); prefix != "" {
+		if opts.key != "" {
+			http.Error(w, `{"error":"key and key_prefix are mutually exclusive"}`, http.StatusBadRequest)
+			return
+		}
+		opts.key, opts.keyIsPrefix = prefix, true
+	}
+	if bucket == "" || opts.key == "" {
+		http.Error(w, `{"error":"bucket and key (or key_prefix) are required"}`, http.StatusBadRequest)
+		return
+	}
+
+	opts.expiresSec = 3600
+	if expiresStr := q.Get("expires"); expiresStr != "" {
+		var err error
+		opts.expiresSec, err = strconv.Atoi(expiresStr)
+		if err != nil || opts.expiresSec < 1 || opts.expiresSec > presignMaxExpires {
+			http.Error(w, `{"error":"expires must be between 1 and 604800 seconds"}`, http.StatusBadRequest)
+			return
+		}
+	}
+	for name, dst := range map[string]*int64{"min_size": &opts.minSize, "max_size": &opts.maxSize} {
+		if v := q.Get(name); v != "" {
+			n, err := strconv.ParseInt(v, 10, 64)
+			if err != nil || n < 0 || n > postObjectMaxSize {
+				http.Error(w, `{"error":"`+name+` must be between 0 and 5368709120 bytes"}`, http.StatusBadRequest)
+				return
+			}
+			*dst = n
+		}
+	}
+	if opts.minSize > 0 && opts.maxSize == 0 {
+		opts.maxSize = postObjectMaxSize
+	}
+	if opts.maxSize > 0 && opts.minSize > opts.maxSize {
+		http.Error(w, `{"error":"min_size must not exceed max_size"}`, http.StatusBadRequest)
+		return
+	}
+	switch opts.successActionStatus {
+	case "", "200", "201", "204":
+	default:
+		http.Error(w, `{"error":"success_action_status must be 200, 201 or 204"}`, http.StatusBadRequest)
+		return
+	}
+
+	tenantID, ok := r.Context().Value(tenantIDKey).(string)
+	if !ok || tenantID == "" {
+		http.Error(w, `{"error":"unauthorized"}`, http.StatusUnauthorized)
+		return
+	}
+
+	if s.db == nil {
+		http.Error(w, `{"error":"database not available"}`, http.StatusInternalServerError)
+		return
+	}
+
+	var accessKey, secretKey string
+	err := s.db.QueryRowContext(r.Context(),
+		`SELECT access_key, secret_key FROM tenants WHERE id = $1`, tenantID,
+	).Scan(&accessKey, &secretKey)
+	if err != nil {
+		http.Error(w, `{"error":"tenant credentials not found"}`, http.StatusNotFound)
+		return
+	}
+
+	postURL, fields, expiresAt := generatePresignedPost(s.getBaseURL(), accessKey, secretKey, bucket, opts)
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"url":        postURL,
+		"fields":     fields,
+		"expires_at": expiresAt.Format(time.RFC3339),
+	})
+}
+
 func (s *Server) getBaseURL() string {
 	port := 8000
 	if s.config != nil {
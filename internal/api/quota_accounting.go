@@ -134,6 +134,8 @@ func manifestReleaser(g *crypto.GlobalContentIndex) chunkManifestReleaser {
 // flip) is_chunked while the old tenant_chunk_refs and their GCI refcounts
 // leak forever — and a row left is_chunked=TRUE over a plain blob makes GET
 // serve the OLD object's bytes from the stale manifest.
+//
+// The new row's replication jobs are queued in the same transaction.
 func atomicHeadUpsertReleasing(ctx context.Context, db *sql.DB, gci chunkManifestReleaser,
 	tenantID, bucket, key string, upsert func(tx *sql.Tx) error) (int64, error) {
 	tx, err := db.BeginTx(ctx, nil)
@@ -142,9 +144,20 @@ func atomicHeadUpsertReleasing(ctx context.Context, db *sql.DB, gci chunkManifes
 	}
 	defer func() { _ = tx.Rollback() }()
 
+	// The key lock a conditional writer holds across its recheck and upsert.
+	if err := lockHeadKey(ctx, tx, tenantID, bucket, key); err != nil {
+		return 0, err
+	}
+	return lockedHeadUpsert(ctx, tx, gci, tenantID, bucket, key, upsert)
+}
+
+// lockedHeadUpsert is atomicHeadUpsertReleasing in tx, which already holds
+// the key's lock; it commits tx.
+func lockedHeadUpsert(ctx context.Context, tx *sql.Tx, gci chunkManifestReleaser,
+	tenantID, bucket, key string, upsert func(tx *sql.Tx) error) (int64, error) {
 	var displaced int64
 	var displacedChunked bool
-	err = tx.QueryRowContext(ctx, `
+	err := tx.QueryRowContext(ctx, `
 		SELECT size_bytes, is_chunked FROM object_head_cache
 		WHERE tenant_id = $1 AND bucket = $2 AND object_key = $3
 		FOR UPDATE`,
@@ -153,19 +166,32 @@ func atomicHeadUpsertReleasing(ctx context.Context, db *sql.DB, gci chunkManifes
 		return 0, fmt.Errorf("lock head-cache row: %w", err)
 	}
 
+	if err := headUpsertTx(ctx, tx, gci, tenantID, bucket, key, displacedChunked, upsert); err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("commit head-cache upsert: %w", err)
+	}
+	return displaced, nil
+}
+
+// headUpsertTx upserts the head row in tx, which holds the key's lock and
+// its current row: it releases the displaced row's chunk manifest when it
+// was chunked, then queues the new row's replication jobs.
+func headUpsertTx(ctx context.Context, tx *sql.Tx, gci chunkManifestReleaser,
+	tenantID, bucket, key string, displacedChunked bool, upsert func(tx *sql.Tx) error) error {
 	if displacedChunked && gci != nil {
-		if relErr := gci.DeleteObjectChunksTx(ctx, tx, tenantID, bucket, key); relErr != nil {
-			return 0, fmt.Errorf("release displaced chunk manifest: %w", relErr)
+		if err := gci.DeleteObjectChunksTx(ctx, tx, tenantID, bucket, key); err != nil {
+			return fmt.Errorf("release displaced chunk manifest: %w", err)
 		}
 	}
-
 	if err := upsert(tx); err != nil {
-		return 0, fmt.Errorf("upsert head-cache row: %w", err)
+		return fmt.Errorf("upsert head-cache row: %w", err)
 	}
-	if err := tx.Commit(); err != nil {
-		return 0, fmt.Errorf("commit head-cache upsert: %w", err)
+	if err := queueReplication(ctx, tx, tenantID, bucket, key); err != nil {
+		return fmt.Errorf("queue replication: %w", err)
 	}
-	return displaced, nil
+	return nil
 }
 
 // storageReconciler is implemented by usage.QuotaManager; the nil quota
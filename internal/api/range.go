@@ -1,33 +1,80 @@
 package api
 
 import (
+	"errors"
 	"fmt"
 	"io"
+	"mime/multipart"
 	"net/http"
+	"net/textproto"
+	"sort"
 	"strconv"
 	"strings"
 )
 
+// maxRanges caps the byte-range specs in one Range header. Every part can
+// cost a backend round trip, and many small or overlapping ranges are a
+// known amplification attack; the cap is applied before coalescing.
+const maxRanges = 16
+
+// errRangeUnsatisfiable marks a well-formed spec that lies past the end of
+// the object. Other specs in the same header may still be served.
+var errRangeUnsatisfiable = errors.New("unsatisfiable range")
+
+// errRangeNotStarted wraps a failure to open the first part: nothing has
+// been written, so the caller can still send an error response.
+var errRangeNotStarted = errors.New("range not started")
+
 type httpRange struct {
 	start  int64
 	end    int64
 	length int64
 }
 
-func parseRangeHeader(header string, totalSize int64) (*httpRange, error) {
+func (rng httpRange) contentRange(totalSize int64) string {
+	return fmt.Sprintf("bytes %d-%d/%d", rng.start, rng.end, totalSize)
+}
+
+// parseRanges parses a Range header into ascending, non-overlapping ranges.
+// Overlapping and adjacent specs are coalesced, and specs past the end of
+// the object are dropped; an error means none is satisfiable (or the header
+// is malformed) and the caller answers 416.
+func parseRanges(header string, totalSize int64) ([]httpRange, error) {
 	if header == "" || !strings.HasPrefix(header, "bytes=") {
 		return nil, fmt.Errorf("invalid range header")
 	}
 
-	spec := strings.TrimPrefix(header, "bytes=")
+	specs := strings.Split(strings.TrimPrefix(header, "bytes="), ",")
+	if len(specs) > maxRanges {
+		return nil, fmt.Errorf("%d ranges exceed the limit of %d", len(specs), maxRanges)
+	}
 
-	if strings.Contains(spec, ",") {
-		return nil, fmt.Errorf("multi-range not supported")
+	var ranges []httpRange
+	for _, spec := range specs {
+		spec = strings.TrimSpace(spec)
+		if spec == "" {
+			continue
+		}
+		rng, err := parseRangeSpec(spec, totalSize)
+		if errors.Is(err, errRangeUnsatisfiable) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		ranges = append(ranges, rng)
+	}
+	if len(ranges) == 0 {
+		return nil, fmt.Errorf("%w: %q of %d bytes", errRangeUnsatisfiable, header, totalSize)
 	}
+	return coalesceRanges(ranges), nil
+}
 
+// parseRangeSpec parses one "start-end", "start-" or "-suffix" spec.
+func parseRangeSpec(spec string, totalSize int64) (httpRange, error) {
 	parts := strings.SplitN(spec, "-", 2)
 	if len(parts) != 2 {
-		return nil, fmt.Errorf("invalid range spec %q", spec)
+		return httpRange{}, fmt.Errorf("invalid range spec %q", spec)
 	}
 
 	var start, end int64
@@ -36,7 +83,7 @@ func parseRangeHeader(header string, totalSize int64) (*httpRange, error) {
 		// Suffix range: bytes=-N (last N bytes)
 		suffix, err := strconv.ParseInt(parts[1], 10, 64)
 		if err != nil || suffix <= 0 {
-			return nil, fmt.Errorf("invalid suffix range %q", spec)
+			return httpRange{}, fmt.Errorf("invalid suffix range %q", spec)
 		}
 		start = totalSize - suffix
 		if start < 0 {
@@ -46,8 +93,8 @@ func parseRangeHeader(header string, totalSize int64) (*httpRange, error) {
 	} else {
 		var err error
 		start, err = strconv.ParseInt(parts[0], 10, 64)
-		if err != nil {
-			return nil, fmt.Errorf("invalid range start %q", parts[0])
+		if err != nil || start < 0 {
+			return httpRange{}, fmt.Errorf("invalid range start %q", parts[0])
 		}
 
 		if parts[1] == "" {
@@ -56,45 +103,174 @@ func parseRangeHeader(header string, totalSize int64) (*httpRange, error) {
 		} else {
 			end, err = strconv.ParseInt(parts[1], 10, 64)
 			if err != nil {
-				return nil, fmt.Errorf("invalid range end %q", parts[1])
+				return httpRange{}, fmt.Errorf("invalid range end %q", parts[1])
+			}
+			if start > end {
+				return httpRange{}, fmt.Errorf("invalid range spec %q", spec)
 			}
 		}
 	}
 
+	if start >= totalSize {
+		return httpRange{}, fmt.Errorf("%w %s/%d", errRangeUnsatisfiable, spec, totalSize)
+	}
 	if end >= totalSize {
 		end = totalSize - 1
 	}
 
-	if start > end || start >= totalSize {
-		return nil, fmt.Errorf("unsatisfiable range %d-%d/%d", start, end, totalSize)
-	}
-
-	return &httpRange{
+	return httpRange{
 		start:  start,
 		end:    end,
 		length: end - start + 1,
 	}, nil
 }
 
-func serveRange(w http.ResponseWriter, reader io.Reader, rng *httpRange, totalSize int64, contentType string) error {
-	if seeker, ok := reader.(io.ReadSeeker); ok {
-		if _, err := seeker.Seek(rng.start, io.SeekStart); err != nil {
-			return fmt.Errorf("seek to %d: %w", rng.start, err)
+// coalesceRanges sorts ranges and merges the ones that overlap or touch, so
+// no byte is served twice and parts can be read in a single forward pass.
+func coalesceRanges(ranges []httpRange) []httpRange {
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i].start < ranges[j].start })
+	merged := ranges[:1]
+	for _, rng := range ranges[1:] {
+		last := &merged[len(merged)-1]
+		if rng.start > last.end+1 {
+			merged = append(merged, rng)
+			continue
+		}
+		if rng.end > last.end {
+			last.end = rng.end
+			last.length = last.end - last.start + 1
 		}
-	} else if rng.start > 0 {
-		if _, err := io.CopyN(io.Discard, reader, rng.start); err != nil {
-			return fmt.Errorf("discard %d prefix bytes: %w", rng.start, err)
+	}
+	return merged
+}
+
+// rangeOpener returns a reader positioned at rng.start. serveRanges reads
+// exactly rng.length bytes from it and closes it.
+type rangeOpener func(rng httpRange) (io.ReadCloser, error)
+
+// sequentialRanges serves ascending ranges (as parseRanges returns them)
+// from one full-object reader, seeking when it can and discarding the gaps
+// otherwise.
+func sequentialRanges(reader io.Reader) rangeOpener {
+	var pos int64
+	return func(rng httpRange) (io.ReadCloser, error) {
+		if seeker, ok := reader.(io.Seeker); ok {
+			if _, err := seeker.Seek(rng.start, io.SeekStart); err != nil {
+				return nil, fmt.Errorf("seek to %d: %w", rng.start, err)
+			}
+		} else {
+			if rng.start < pos {
+				return nil, fmt.Errorf("range %d-%d precedes read offset %d", rng.start, rng.end, pos)
+			}
+			if _, err := io.CopyN(io.Discard, reader, rng.start-pos); err != nil {
+				return nil, fmt.Errorf("discard %d bytes: %w", rng.start-pos, err)
+			}
 		}
+		pos = rng.end + 1
+		return io.NopCloser(io.LimitReader(reader, rng.length)), nil
+	}
+}
+
+// serveRanges writes a 206 for ranges: a single part with Content-Range,
+// or a multipart/byteranges body for several. The first part is opened
+// before anything is written; a failure there is errRangeNotStarted. It
+// returns the number of object bytes served.
+func serveRanges(w http.ResponseWriter, ranges []httpRange, totalSize int64, contentType string, open rangeOpener) (int64, error) {
+	first, err := open(ranges[0])
+	if err != nil {
+		return 0, fmt.Errorf("%w: %w", errRangeNotStarted, err)
 	}
 
-	w.Header().Set("Content-Type", contentType)
-	w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", rng.start, rng.end, totalSize))
-	w.Header().Set("Content-Length", strconv.FormatInt(rng.length, 10))
 	w.Header().Set("Accept-Ranges", "bytes")
+	if len(ranges) == 1 {
+		defer func() { _ = first.Close() }()
+		rng := ranges[0]
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Range", rng.contentRange(totalSize))
+		w.Header().Set("Content-Length", strconv.FormatInt(rng.length, 10))
+		w.WriteHeader(http.StatusPartialContent)
+		return io.CopyN(w, first, rng.length)
+	}
+
+	br := newByteranges(w, contentType, totalSize)
+	br.setHeaders(w.Header(), ranges)
 	w.WriteHeader(http.StatusPartialContent)
 
-	_, err := io.CopyN(w, reader, rng.length)
-	return err
+	var written int64
+	for i, rng := range ranges {
+		part := first
+		if i > 0 {
+			if part, err = open(rng); err != nil {
+				return written, err
+			}
+		}
+		pw, err := br.part(rng)
+		if err != nil {
+			_ = part.Close()
+			return written, err
+		}
+		n, err := io.CopyN(pw, part, rng.length)
+		_ = part.Close()
+		written += n
+		if err != nil {
+			return written, err
+		}
+	}
+	return written, br.close()
+}
+
+// byteranges writes a multipart/byteranges body (RFC 9110 §14.6).
+type byteranges struct {
+	mw          *multipart.Writer
+	contentType string
+	totalSize   int64
+}
+
+func newByteranges(w io.Writer, contentType string, totalSize int64) *byteranges {
+	return &byteranges{mw: multipart.NewWriter(w), contentType: contentType, totalSize: totalSize}
+}
+
+// setHeaders sets the response's Content-Type and its exact Content-Length,
+// measured by writing the part framing to a counter.
+func (b *byteranges) setHeaders(h http.Header, ranges []httpRange) {
+	var framing byteCounter
+	mw := multipart.NewWriter(&framing)
+	_ = mw.SetBoundary(b.mw.Boundary())
+	var size int64
+	for _, rng := range ranges {
+		_, _ = mw.CreatePart(b.partHeader(rng))
+		size += rng.length
+	}
+	_ = mw.Close()
+
+	h.Set("Content-Type", "multipart/byteranges; boundary="+b.mw.Boundary())
+	h.Set("Content-Length", strconv.FormatInt(size+int64(framing), 10))
+	h.Del("Content-Range")
+}
+
+func (b *byteranges) partHeader(rng httpRange) textproto.MIMEHeader {
+	h := textproto.MIMEHeader{}
+	if b.contentType != "" {
+		h.Set("Content-Type", b.contentType)
+	}
+	h.Set("Content-Range", rng.contentRange(b.totalSize))
+	return h
+}
+
+// part starts the next part; its bytes follow on the returned writer.
+func (b *byteranges) part(rng httpRange) (io.Writer, error) {
+	return b.mw.CreatePart(b.partHeader(rng))
+}
+
+func (b *byteranges) close() error {
+	return b.mw.Close()
+}
+
+type byteCounter int64
+
+func (c *byteCounter) Write(p []byte) (int, error) {
+	*c += byteCounter(len(p))
+	return len(p), nil
 }
 
 func writeRangeNotSatisfiable(w http.ResponseWriter, totalSize int64) {
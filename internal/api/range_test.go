@@ -3,8 +3,11 @@ package api
 import (
 	"bytes"
 	"io"
+	"mime"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
 	"strings"
 	"testing"
 
@@ -12,7 +15,7 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
-func TestParseRangeHeader_Valid(t *testing.T) {
+func TestParseRanges_Valid(t *testing.T) {
 	tests := []struct {
 		name       string
 		header     string
@@ -81,8 +84,10 @@ func TestParseRangeHeader_Valid(t *testing.T) {
 
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
-			rng, err := parseRangeHeader(tc.header, tc.totalSize)
+			ranges, err := parseRanges(tc.header, tc.totalSize)
 			require.NoError(t, err)
+			require.Len(t, ranges, 1)
+			rng := ranges[0]
 			assert.Equal(t, tc.wantStart, rng.start)
 			assert.Equal(t, tc.wantEnd, rng.end)
 			assert.Equal(t, tc.wantLength, rng.length)
@@ -90,7 +95,7 @@ func TestParseRangeHeader_Valid(t *testing.T) {
 	}
 }
 
-func TestParseRangeHeader_Invalid(t *testing.T) {
+func TestParseRanges_Invalid(t *testing.T) {
 	tests := []struct {
 		name      string
 		header    string
@@ -98,7 +103,9 @@ func TestParseRangeHeader_Invalid(t *testing.T) {
 	}{
 		{"empty", "", 100},
 		{"no bytes prefix", "0-99", 100},
-		{"multi-range", "bytes=0-50, 100-150", 1000},
+		{"every range past total", "bytes=200-300, 500-", 100},
+		{"one malformed range", "bytes=0-50, x-150", 1000},
+		{"too many ranges", "bytes=" + strings.Repeat("0-1,", maxRanges) + "5-6", 1000},
 		{"start after end", "bytes=100-50", 1000},
 		{"start past total", "bytes=1000-2000", 100},
 		{"negative not a suffix", "bytes=-0", 100},
@@ -107,20 +114,20 @@ func TestParseRangeHeader_Invalid(t *testing.T) {
 
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
-			_, err := parseRangeHeader(tc.header, tc.totalSize)
+			_, err := parseRanges(tc.header, tc.totalSize)
 			assert.Error(t, err)
 		})
 	}
 }
 
-func TestServeRange_WithSeeker(t *testing.T) {
+func TestServeRanges_WithSeeker(t *testing.T) {
 	content := []byte("Hello, World! This is range test content.")
 	reader := bytes.NewReader(content)
 
-	rng := &httpRange{start: 7, end: 11, length: 5}
+	rng := httpRange{start: 7, end: 11, length: 5}
 
 	w := httptest.NewRecorder()
-	err := serveRange(w, reader, rng, int64(len(content)), "text/plain")
+	_, err := serveRanges(w, []httpRange{rng}, int64(len(content)), "text/plain", sequentialRanges(reader))
 	require.NoError(t, err)
 
 	assert.Equal(t, http.StatusPartialContent, w.Code)
@@ -133,14 +140,14 @@ func TestServeRange_WithSeeker(t *testing.T) {
 	assert.Equal(t, "World", string(body))
 }
 
-func TestServeRange_WithNonSeeker(t *testing.T) {
+func TestServeRanges_WithNonSeeker(t *testing.T) {
 	content := []byte("Hello, World! This is range test content.")
 	reader := io.NopCloser(strings.NewReader(string(content)))
 
-	rng := &httpRange{start: 7, end: 11, length: 5}
+	rng := httpRange{start: 7, end: 11, length: 5}
 
 	w := httptest.NewRecorder()
-	err := serveRange(w, reader, rng, int64(len(content)), "application/octet-stream")
+	_, err := serveRanges(w, []httpRange{rng}, int64(len(content)), "application/octet-stream", sequentialRanges(reader))
 	require.NoError(t, err)
 
 	assert.Equal(t, http.StatusPartialContent, w.Code)
@@ -151,14 +158,14 @@ func TestServeRange_WithNonSeeker(t *testing.T) {
 	assert.Equal(t, "World", string(body))
 }
 
-func TestServeRange_EntireFile(t *testing.T) {
+func TestServeRanges_EntireFile(t *testing.T) {
 	content := []byte("ABCDE")
 	reader := bytes.NewReader(content)
 
-	rng := &httpRange{start: 0, end: 4, length: 5}
+	rng := httpRange{start: 0, end: 4, length: 5}
 
 	w := httptest.NewRecorder()
-	err := serveRange(w, reader, rng, 5, "text/plain")
+	_, err := serveRanges(w, []httpRange{rng}, 5, "text/plain", sequentialRanges(reader))
 	require.NoError(t, err)
 
 	assert.Equal(t, http.StatusPartialContent, w.Code)
@@ -168,6 +175,134 @@ func TestServeRange_EntireFile(t *testing.T) {
 	assert.Equal(t, "ABCDE", string(body))
 }
 
+func TestParseRanges_Multiple(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		want   []httpRange
+	}{
+		{
+			name:   "sorted",
+			header: "bytes=500-599, 0-99",
+			want:   []httpRange{{0, 99, 100}, {500, 599, 100}},
+		},
+		{
+			name:   "overlapping and adjacent coalesce",
+			header: "bytes=0-99,50-149,150-199,-100",
+			want:   []httpRange{{0, 199, 200}, {900, 999, 100}},
+		},
+		{
+			name:   "contained range",
+			header: "bytes=0-499,100-199",
+			want:   []httpRange{{0, 499, 500}},
+		},
+		{
+			name:   "unsatisfiable specs dropped",
+			header: "bytes=0-9,5000-6000",
+			want:   []httpRange{{0, 9, 10}},
+		},
+		{
+			name:   "empty list elements",
+			header: "bytes=0-9,,20-29",
+			want:   []httpRange{{0, 9, 10}, {20, 29, 10}},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ranges, err := parseRanges(tc.header, 1000)
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, ranges)
+		})
+	}
+}
+
+func TestServeRanges_Multipart(t *testing.T) {
+	content := []byte("Hello, World! This is range test content.")
+	ranges := []httpRange{{0, 4, 5}, {14, 17, 4}}
+
+	for name, reader := range map[string]io.Reader{
+		"seeker":     bytes.NewReader(content),
+		"non-seeker": io.MultiReader(bytes.NewReader(content)),
+	} {
+		t.Run(name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			n, err := serveRanges(w, ranges, int64(len(content)), "text/plain", sequentialRanges(reader))
+			require.NoError(t, err)
+			assert.Equal(t, int64(9), n)
+
+			assert.Equal(t, http.StatusPartialContent, w.Code)
+			assert.Empty(t, w.Header().Get("Content-Range"))
+			assert.Equal(t, strconv.Itoa(w.Body.Len()), w.Header().Get("Content-Length"))
+
+			parts := readByteranges(t, w)
+			require.Len(t, parts, 2)
+			assert.Equal(t, "text/plain", parts[0].contentType)
+			assert.Equal(t, "bytes 0-4/41", parts[0].contentRange)
+			assert.Equal(t, "Hello", string(parts[0].body))
+			assert.Equal(t, "bytes 14-17/41", parts[1].contentRange)
+			assert.Equal(t, "This", string(parts[1].body))
+		})
+	}
+}
+
+type byterangesPart struct {
+	contentType  string
+	contentRange string
+	body         []byte
+}
+
+// readByteranges decodes a multipart/byteranges response.
+func readByteranges(t *testing.T, w *httptest.ResponseRecorder) []byterangesPart {
+	t.Helper()
+	mediaType, params, err := mime.ParseMediaType(w.Header().Get("Content-Type"))
+	require.NoError(t, err)
+	require.Equal(t, "multipart/byteranges", mediaType)
+
+	var parts []byterangesPart
+	mr := multipart.NewReader(bytes.NewReader(w.Body.Bytes()), params["boundary"])
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			return parts
+		}
+		require.NoError(t, err)
+		body, err := io.ReadAll(part)
+		require.NoError(t, err)
+		parts = append(parts, byterangesPart{
+			contentType:  part.Header.Get("Content-Type"),
+			contentRange: part.Header.Get("Content-Range"),
+			body:         body,
+		})
+	}
+}
+
+func TestServeRanges_OpensPerPart(t *testing.T) {
+	content := []byte("0123456789abcdefghij")
+	var opened []httpRange
+	open := func(rng httpRange) (io.ReadCloser, error) {
+		opened = append(opened, rng)
+		return io.NopCloser(bytes.NewReader(content[rng.start : rng.end+1])), nil
+	}
+
+	ranges, err := parseRanges("bytes=15-,2-3", int64(len(content)))
+	require.NoError(t, err)
+	w := httptest.NewRecorder()
+	_, err = serveRanges(w, ranges, int64(len(content)), "application/octet-stream", open)
+	require.NoError(t, err)
+	assert.Equal(t, []httpRange{{2, 3, 2}, {15, 19, 5}}, opened)
+	assert.Contains(t, w.Body.String(), "fghij")
+
+	// A first part that can't be opened leaves the response unwritten.
+	w = httptest.NewRecorder()
+	_, err = serveRanges(w, ranges, int64(len(content)), "", func(httpRange) (io.ReadCloser, error) {
+		return nil, io.ErrUnexpectedEOF
+	})
+	assert.ErrorIs(t, err, errRangeNotStarted)
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	assert.Empty(t, w.Header())
+	assert.Zero(t, w.Body.Len())
+}
+
 func TestWriteRangeNotSatisfiable(t *testing.T) {
 	w := httptest.NewRecorder()
 	writeRangeNotSatisfiable(w, 500)
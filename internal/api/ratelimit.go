@@ -1,8 +1,13 @@
 package api
 
 import (
+	"database/sql"
+	"fmt"
+	"strings"
 	"sync"
 
+	"github.com/FairForge/vaultaire/internal/ratelimit"
+	"go.uber.org/zap"
 	"golang.org/x/time/rate"
 )
 
@@ -11,6 +16,7 @@ type RateLimiter struct {
 	limiters          map[string]*rate.Limiter
 	requestsPerSecond int
 	burstSize         int
+	shared            *ratelimit.SharedLimiter // replaces limiters when set
 }
 
 func NewRateLimiter() *RateLimiter {
@@ -21,7 +27,20 @@ func NewRateLimiter() *RateLimiter {
 	}
 }
 
+// UseShared keeps the limiter's buckets in shared under name, so every
+// replica enforces one limit. A nil shared keeps them in process memory.
+func (rl *RateLimiter) UseShared(shared *ratelimit.Shared, name string) {
+	if shared == nil {
+		return
+	}
+	rl.shared = ratelimit.NewSharedLimiter(shared, name, float64(rl.requestsPerSecond), rl.burstSize)
+}
+
 func (rl *RateLimiter) Allow(tenant string) bool {
+	if rl.shared != nil {
+		return rl.shared.Allow(tenant)
+	}
+
 	rl.mu.Lock()
 	defer rl.mu.Unlock()
 
@@ -43,3 +62,31 @@ func (rl *RateLimiter) Allow(tenant string) bool {
 
 	return limiter.Allow()
 }
+
+// newRateLimitStore opens the store named by config.ServerConfig's
+// RateLimitStore: a redis:// URL, "postgres", or "" for none.
+func newRateLimitStore(spec string, db *sql.DB) (ratelimit.Store, error) {
+	switch {
+	case spec == "":
+		return nil, nil
+	case spec == "postgres":
+		if db == nil {
+			return nil, fmt.Errorf("rate limit store %q needs a database", spec)
+		}
+		return ratelimit.NewPostgresStore(db), nil
+	case strings.HasPrefix(spec, "redis://"), strings.HasPrefix(spec, "rediss://"):
+		return ratelimit.NewRedisStore(spec)
+	}
+	return nil, fmt.Errorf("unknown rate limit store %q", spec)
+}
+
+// newSharedRateLimits backs the request limiters with the configured store,
+// keeping them per process when there is none or it cannot be opened.
+func newSharedRateLimits(spec string, db *sql.DB, logger *zap.Logger) (*ratelimit.Shared, ratelimit.Store) {
+	store, err := newRateLimitStore(spec, db)
+	if err != nil {
+		logger.Error("rate limits stay per process", zap.Error(err))
+		store = nil
+	}
+	return ratelimit.NewShared(store, logger), store
+}
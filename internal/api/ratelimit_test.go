@@ -1,10 +1,16 @@
 package api
 
 import (
+	"context"
 	"fmt"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/FairForge/vaultaire/internal/ratelimit"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
 )
 
 func TestRateLimiter_Construction(t *testing.T) {
@@ -54,3 +60,70 @@ func TestRateLimiter_MemoryBounds(t *testing.T) {
 		assert.LessOrEqual(t, count, 10000)
 	})
 }
+
+// countingStore is one token bucket per key shared by every caller, like a
+// Redis both replicas talk to.
+type countingStore struct {
+	mu    sync.Mutex
+	taken map[string]int64
+}
+
+func (s *countingStore) Take(_ context.Context, key string, limit ratelimit.Limit, cost int64) (ratelimit.Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.taken[key]+cost > limit.Burst {
+		return ratelimit.Result{Limit: limit.Burst, RetryAfter: time.Second}, nil
+	}
+	s.taken[key] += cost
+	return ratelimit.Result{Allowed: true, Limit: limit.Burst, Remaining: limit.Burst - s.taken[key]}, nil
+}
+
+func TestRateLimiter_UseShared(t *testing.T) {
+	store := &countingStore{taken: map[string]int64{}}
+
+	// Two replicas, each with its own limiter over the same store.
+	var replicas []*RateLimiter
+	for i := 0; i < 2; i++ {
+		rl := NewRateLimiter()
+		rl.burstSize = 3
+		rl.UseShared(ratelimit.NewShared(store, zap.NewNop()), "cdn")
+		replicas = append(replicas, rl)
+	}
+
+	allowed := 0
+	for i := 0; i < 4; i++ {
+		for _, rl := range replicas {
+			if rl.Allow("cdn:slug:bucket") {
+				allowed++
+			}
+		}
+	}
+	assert.Equal(t, 3, allowed, "the replicas share one burst")
+	assert.Equal(t, int64(3), store.taken["cdn:cdn:slug:bucket"])
+
+	// Without a shared store the limiter stays in process memory.
+	rl := NewRateLimiter()
+	rl.UseShared(nil, "cdn")
+	assert.Nil(t, rl.shared)
+	assert.True(t, rl.Allow("cdn:slug:bucket"))
+}
+
+func TestNewRateLimitStore(t *testing.T) {
+	store, err := newRateLimitStore("", nil)
+	require.NoError(t, err)
+	assert.Nil(t, store)
+
+	_, err = newRateLimitStore("postgres", nil)
+	assert.Error(t, err, "postgres needs a database")
+
+	store, err = newRateLimitStore("redis://cache.internal:6379/0", nil)
+	require.NoError(t, err)
+	assert.IsType(t, &ratelimit.RedisStore{}, store)
+
+	_, err = newRateLimitStore("memcached://cache.internal", nil)
+	assert.Error(t, err)
+
+	shared, store := newSharedRateLimits("memcached://cache.internal", nil, zap.NewNop())
+	assert.Nil(t, store)
+	assert.True(t, shared.Degraded(), "a bad store keeps limits per process")
+}
@@ -122,6 +122,26 @@ func (p *S3Parser) determineOperation(req *S3Request, method string) {
 				req.Operation = "GetBucketLogging"
 			} else if _, ok := req.Query["inventory"]; ok {
 				req.Operation = "GetBucketInventory"
+			} else if _, ok := req.Query["lifecycle"]; ok {
+				req.Operation = "GetBucketLifecycleConfiguration"
+			} else if _, ok := req.Query["policy"]; ok {
+				req.Operation = "GetBucketPolicy"
+			} else if _, ok := req.Query["policyStatus"]; ok {
+				req.Operation = "GetBucketPolicyStatus"
+			} else if _, ok := req.Query["cors"]; ok {
+				req.Operation = "GetBucketCors"
+			} else if _, ok := req.Query["encryption"]; ok {
+				req.Operation = "GetBucketEncryption"
+			} else if _, ok := req.Query["replication"]; ok {
+				req.Operation = "GetBucketReplication"
+			} else if _, ok := req.Query["website"]; ok {
+				req.Operation = "GetBucketWebsite"
+			} else if _, ok := req.Query["acl"]; ok {
+				req.Operation = "GetBucketAcl"
+			} else if _, ok := req.Query["ownershipControls"]; ok {
+				req.Operation = "GetBucketOwnershipControls"
+			} else if _, ok := req.Query["publicAccessBlock"]; ok {
+				req.Operation = "GetPublicAccessBlock"
 			} else if _, ok := req.Query["uploads"]; ok {
 				req.Operation = "ListMultipartUploads"
 			} else if _, ok := req.Query["versions"]; ok {
@@ -140,12 +160,46 @@ func (p *S3Parser) determineOperation(req *S3Request, method string) {
 				req.Operation = "PutBucketLogging"
 			} else if _, ok := req.Query["inventory"]; ok {
 				req.Operation = "PutBucketInventory"
+			} else if _, ok := req.Query["lifecycle"]; ok {
+				req.Operation = "PutBucketLifecycleConfiguration"
+			} else if _, ok := req.Query["policy"]; ok {
+				req.Operation = "PutBucketPolicy"
+			} else if _, ok := req.Query["cors"]; ok {
+				req.Operation = "PutBucketCors"
+			} else if _, ok := req.Query["encryption"]; ok {
+				req.Operation = "PutBucketEncryption"
+			} else if _, ok := req.Query["replication"]; ok {
+				req.Operation = "PutBucketReplication"
+			} else if _, ok := req.Query["website"]; ok {
+				req.Operation = "PutBucketWebsite"
+			} else if _, ok := req.Query["acl"]; ok {
+				req.Operation = "PutBucketAcl"
+			} else if _, ok := req.Query["ownershipControls"]; ok {
+				req.Operation = "PutBucketOwnershipControls"
+			} else if _, ok := req.Query["publicAccessBlock"]; ok {
+				req.Operation = "PutPublicAccessBlock"
 			} else {
 				req.Operation = "CreateBucket"
 			}
 		case "DELETE":
 			if _, ok := req.Query["inventory"]; ok {
 				req.Operation = "DeleteBucketInventory"
+			} else if _, ok := req.Query["lifecycle"]; ok {
+				req.Operation = "DeleteBucketLifecycle"
+			} else if _, ok := req.Query["policy"]; ok {
+				req.Operation = "DeleteBucketPolicy"
+			} else if _, ok := req.Query["cors"]; ok {
+				req.Operation = "DeleteBucketCors"
+			} else if _, ok := req.Query["encryption"]; ok {
+				req.Operation = "DeleteBucketEncryption"
+			} else if _, ok := req.Query["replication"]; ok {
+				req.Operation = "DeleteBucketReplication"
+			} else if _, ok := req.Query["website"]; ok {
+				req.Operation = "DeleteBucketWebsite"
+			} else if _, ok := req.Query["ownershipControls"]; ok {
+				req.Operation = "DeleteBucketOwnershipControls"
+			} else if _, ok := req.Query["publicAccessBlock"]; ok {
+				req.Operation = "DeletePublicAccessBlock"
 			} else {
 				req.Operation = "DeleteBucket"
 			}
@@ -173,6 +227,10 @@ func (p *S3Parser) determineOperation(req *S3Request, method string) {
 			req.Operation = "ListParts"
 		} else if _, ok := req.Query["tagging"]; ok {
 			req.Operation = "GetObjectTagging"
+		} else if _, ok := req.Query["attributes"]; ok {
+			req.Operation = "GetObjectAttributes"
+		} else if _, ok := req.Query["acl"]; ok {
+			req.Operation = "GetObjectAcl"
 		} else {
 			req.Operation = "GetObject"
 		}
@@ -185,6 +243,8 @@ func (p *S3Parser) determineOperation(req *S3Request, method string) {
 			req.Operation = "UploadPart"
 		} else if _, ok := req.Query["tagging"]; ok {
 			req.Operation = "PutObjectTagging"
+		} else if _, ok := req.Query["acl"]; ok {
+			req.Operation = "PutObjectAcl"
 		} else {
 			req.Operation = "PutObject"
 		}
@@ -205,6 +265,8 @@ func (p *S3Parser) determineOperation(req *S3Request, method string) {
 			req.Operation = "CompleteMultipartUpload"
 		} else if _, ok := req.Query["restore"]; ok {
 			req.Operation = "RestoreObject"
+		} else if _, ok := req.Query["select"]; ok {
+			req.Operation = "SelectObjectContent"
 		} else {
 			req.Operation = "PostObject"
 		}
@@ -247,24 +309,52 @@ func (s *Server) handleS3Request(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// CORS preflights carry no credentials; they are answered from the
+	// bucket's CORS configuration before authentication.
+	if r.Method == http.MethodOptions {
+		s.handleS3Preflight(w, r)
+		return
+	}
+
 	var tenantID string
 	var scope *auth.KeyScope
 	var err error
 
+	// Browser form uploads carry their credentials in the form fields, which
+	// precede the file, so the form is read before authentication.
+	var postForm *postObjectForm
+	if isPostObjectRequest(r) {
+		if postForm, err = readPostObjectForm(r); err != nil {
+			writePostObjectError(w, r, err)
+			return
+		}
+	}
+
 	if !s.testMode {
-		if isPresignedRequest(r) {
+		if postForm != nil {
+			tenantID, scope, err = s.verifyPostObjectSignature(postForm)
+			if err != nil {
+				s.logger.Error("POST policy verification failed",
+					zap.Error(err),
+					zap.String("path", r.URL.Path))
+				s.applyErrorCORS(w, r)
+				writePostObjectError(w, r, err)
+				return
+			}
+		} else if isPresignedRequest(r) {
 			tenantID, scope, err = s.verifyPresignedURL(r)
 			if err != nil {
 				s.logger.Error("presigned URL verification failed",
 					zap.Error(err),
 					zap.String("path", r.URL.Path))
 
+				s.applyErrorCORS(w, r)
 				errCode := err.Error()
 				reqID := generateRequestID()
 				switch errCode {
 				case ErrExpiredPresignedRequest, ErrSignatureDoesNotMatch,
 					ErrAccessDenied, ErrAuthorizationQueryParametersError,
-					ErrInvalidPresignExpires:
+					ErrInvalidPresignExpires, ErrInvalidToken:
 					WriteS3Error(w, errCode, r.URL.Path, reqID)
 				default:
 					WriteS3Error(w, ErrAccessDenied, r.URL.Path, reqID)
@@ -285,12 +375,15 @@ func (s *Server) handleS3Request(w http.ResponseWriter, r *http.Request) {
 					errCode = ErrSignatureDoesNotMatch
 				case errors.Is(err, auth.ErrRequestTimeSkewed):
 					errCode = ErrRequestTimeTooSkewed
+				case errors.Is(err, auth.ErrInvalidSecurityToken):
+					errCode = ErrInvalidToken
 				case errors.Is(err, auth.ErrInvalidContentSHA256):
 					errCode = ErrInvalidArgument
 				case strings.Contains(err.Error(), "invalid authorization format"),
 					strings.Contains(err.Error(), "parse"):
 					errCode = ErrSignatureDoesNotMatch
 				}
+				s.applyErrorCORS(w, r)
 				reqID := generateRequestID()
 				if hint := authErrorHint(err.Error()); hint != "" {
 					WriteS3ErrorWithContext(w, errCode, r.URL.Path, reqID, WithSuggestion(hint))
@@ -315,10 +408,12 @@ func (s *Server) handleS3Request(w http.ResponseWriter, r *http.Request) {
 	// Enforce key expiration and IP allowlist before any further processing.
 	if scope != nil {
 		if auth.IsKeyExpired(scope.ExpiresAt) {
+			s.applyErrorCORS(w, r)
 			WriteS3Error(w, ErrExpiredPresignedRequest, r.URL.Path, generateRequestID())
 			return
 		}
 		if !auth.CheckIPAllowlist(scope.IPAllowlist, extractClientIP(r)) {
+			s.applyErrorCORS(w, r)
 			WriteS3ErrorWithContext(w, ErrAccessDenied, r.URL.Path, generateRequestID(),
 				WithSuggestion("This key is restricted by IP address."))
 			return
@@ -336,6 +431,7 @@ func (s *Server) handleS3Request(w http.ResponseWriter, r *http.Request) {
 			s.logger.Warn("suspended tenant attempted S3 request",
 				zap.String("tenant_id", tenantID),
 				zap.String("path", r.URL.Path))
+			s.applyErrorCORS(w, r)
 			WriteS3Error(w, ErrAccountSuspended, r.URL.Path, generateRequestID())
 			return
 		}
@@ -359,10 +455,41 @@ func (s *Server) handleS3Request(w http.ResponseWriter, r *http.Request) {
 		r = r.WithContext(ctx)
 	}
 
+	// A form upload targets the key named in the form, so policy and scope
+	// checks below apply to that object rather than to the bucket.
+	if postForm != nil {
+		s3Req.Operation = "PostObject"
+		s3Req.Object = postForm.objectKey()
+	}
+
+	// A bucket the signer does not own may be shared with it through the
+	// owner's bucket policy or ACLs (s3_acl.go).
+	bucketOwner, err := s.resolveBucketOwner(r, s3Req, tenantID)
+	if err != nil {
+		s.logger.Error("bucket owner lookup failed", zap.Error(err))
+		WriteS3Error(w, ErrInternalError, r.URL.Path, generateRequestID())
+		return
+	}
+
 	s3Req.TenantID = tenantID
+	s.applyBucketCORS(w, r, bucketOwner, s3Req.Bucket)
 
-	// Enforce permission and bucket scope now that we know the operation.
-	if scope != nil {
+	// Enforce the bucket policy, then permission and bucket scope, now that
+	// we know the operation. A policy Deny overrides the key scope; a policy
+	// Allow grants access the scope alone would not (e.g. one prefix for a
+	// partner key with no bucket access of its own).
+	policyDecision, err := s.evaluateBucketPolicy(r, s3Req, bucketOwner, tenantID, scope)
+	if err != nil {
+		s.logger.Error("bucket policy lookup failed", zap.Error(err))
+		WriteS3Error(w, ErrInternalError, r.URL.Path, generateRequestID())
+		return
+	}
+	if policyDecision == auth.PolicyDeny {
+		WriteS3ErrorWithContext(w, ErrAccessDenied, r.URL.Path, generateRequestID(),
+			WithSuggestion("Access is denied by the bucket policy."))
+		return
+	}
+	if scope != nil && policyDecision != auth.PolicyAllow {
 		if !auth.CheckPermission(scope.Permissions, s3Req.Operation) {
 			WriteS3ErrorWithContext(w, ErrAccessDenied, r.URL.Path, generateRequestID(),
 				WithSuggestion(fmt.Sprintf("This key does not have %s access.", s3Req.Operation)))
@@ -375,6 +502,37 @@ func (s *Server) handleS3Request(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	// Another tenant's bucket: without a policy Allow the owner's ACLs
+	// decide. The request then runs, and is billed, as the owner. Copies
+	// are refused: their source would resolve in the owner's namespace.
+	if bucketOwner != tenantID {
+		if r.Header.Get("x-amz-copy-source") != "" {
+			WriteS3ErrorWithContext(w, ErrAccessDenied, r.URL.Path, generateRequestID(),
+				WithSuggestion("Copies into another tenant's bucket are not supported; upload the object instead."))
+			return
+		}
+		if policyDecision != auth.PolicyAllow {
+			allowed, err := s.aclAllows(r.Context(), s3Req, bucketOwner, tenantID)
+			if err != nil {
+				s.logger.Error("bucket ACL lookup failed", zap.Error(err))
+				WriteS3Error(w, ErrInternalError, r.URL.Path, generateRequestID())
+				return
+			}
+			if !allowed {
+				WriteS3ErrorWithContext(w, ErrAccessDenied, r.URL.Path, generateRequestID(),
+					WithSuggestion(fmt.Sprintf("The bucket owner has not granted you %s access.", s3Req.Operation)))
+				return
+			}
+		}
+		s.logger.Info("cross-tenant request",
+			zap.String("requester", tenantID),
+			zap.String("bucket_owner", bucketOwner),
+			zap.String("operation", s3Req.Operation))
+		r = switchToBucketOwner(r, bucketOwner, tenantID)
+		tenantID = bucketOwner
+		s3Req.TenantID = bucketOwner
+	}
+
 	if tenantID == "" {
 		tenantID = "default"
 	}
@@ -434,9 +592,20 @@ func (s *Server) handleS3Request(w http.ResponseWriter, r *http.Request) {
 		},
 	})
 
+	// Per-operation request limits and bandwidth, shared across replicas.
+	if tenantID != "" && tenantID != "default" {
+		if !s.allowS3Operation(w, r, tenantID, s3Req.Operation) {
+			return
+		}
+		if s3Req.Operation == "PutObject" || s3Req.Operation == "UploadPart" || s3Req.Operation == "PostObject" {
+			s.throttleIngress(r, tenantID)
+		}
+	}
+
 	// Phase 4.2: check bandwidth limit before data-transfer operations.
 	if tenantID != "" && tenantID != "default" && s.bandwidthTracker != nil {
-		if s3Req.Operation == "GetObject" || s3Req.Operation == "PutObject" || s3Req.Operation == "UploadPart" {
+		if s3Req.Operation == "GetObject" || s3Req.Operation == "PutObject" ||
+			s3Req.Operation == "UploadPart" || s3Req.Operation == "PostObject" {
 			if s.bandwidthTracker.IsOverLimit(r.Context(), tenantID) {
 				s.logger.Warn("bandwidth limit exceeded",
 					zap.String("tenant_id", tenantID),
@@ -458,7 +627,7 @@ func (s *Server) handleS3Request(w http.ResponseWriter, r *http.Request) {
 
 	// Track ingress bytes from PUT/UploadPart request bodies.
 	var ingressBytes int64
-	if s3Req.Operation == "PutObject" || s3Req.Operation == "UploadPart" {
+	if s3Req.Operation == "PutObject" || s3Req.Operation == "UploadPart" || s3Req.Operation == "PostObject" {
 		ingressBytes = r.ContentLength
 		if ingressBytes < 0 {
 			ingressBytes = 0
@@ -467,7 +636,7 @@ func (s *Server) handleS3Request(w http.ResponseWriter, r *http.Request) {
 
 	switch s3Req.Operation {
 	case "GetObject":
-		s.handleGetObject(cw, r, s3Req)
+		s.handleGetObject(s.throttleEgress(r.Context(), cw, tenantID), r, s3Req)
 	case "HeadObject":
 		s.handleHeadObject(cw, r, s3Req)
 	case "PutObject":
@@ -493,7 +662,11 @@ func (s *Server) handleS3Request(w http.ResponseWriter, r *http.Request) {
 	case "InitiateMultipartUpload":
 		s.handleInitiateMultipartUpload(cw, r, s3Req.Bucket, s3Req.Object)
 	case "UploadPart":
-		s.handleUploadPart(cw, r, s3Req.Bucket, s3Req.Object)
+		if r.Header.Get("x-amz-copy-source") != "" {
+			s.handleUploadPartCopy(cw, r, s3Req.Bucket, s3Req.Object)
+		} else {
+			s.handleUploadPart(cw, r, s3Req.Bucket, s3Req.Object)
+		}
 	case "CompleteMultipartUpload":
 		s.handleCompleteMultipartUpload(cw, r, s3Req.Bucket, s3Req.Object)
 	case "AbortMultipartUpload":
@@ -536,14 +709,78 @@ func (s *Server) handleS3Request(w http.ResponseWriter, r *http.Request) {
 		s.handlePutBucketInventory(cw, r, s3Req)
 	case "DeleteBucketInventory":
 		s.handleDeleteBucketInventory(cw, r, s3Req)
+	case "GetBucketLifecycleConfiguration":
+		s.handleGetBucketLifecycle(cw, r, s3Req)
+	case "PutBucketLifecycleConfiguration":
+		s.handlePutBucketLifecycle(cw, r, s3Req)
+	case "DeleteBucketLifecycle":
+		s.handleDeleteBucketLifecycle(cw, r, s3Req)
+	case "GetBucketCors":
+		s.handleGetBucketCors(cw, r, s3Req)
+	case "PutBucketCors":
+		s.handlePutBucketCors(cw, r, s3Req)
+	case "DeleteBucketCors":
+		s.handleDeleteBucketCors(cw, r, s3Req)
+	case "GetBucketEncryption":
+		s.handleGetBucketEncryption(cw, r, s3Req)
+	case "PutBucketEncryption":
+		s.handlePutBucketEncryption(cw, r, s3Req)
+	case "DeleteBucketEncryption":
+		s.handleDeleteBucketEncryption(cw, r, s3Req)
+	case "GetBucketReplication":
+		s.handleGetBucketReplication(cw, r, s3Req)
+	case "PutBucketReplication":
+		s.handlePutBucketReplication(cw, r, s3Req)
+	case "DeleteBucketReplication":
+		s.handleDeleteBucketReplication(cw, r, s3Req)
+	case "GetBucketWebsite":
+		s.handleGetBucketWebsite(cw, r, s3Req)
+	case "PutBucketWebsite":
+		s.handlePutBucketWebsite(cw, r, s3Req)
+	case "DeleteBucketWebsite":
+		s.handleDeleteBucketWebsite(cw, r, s3Req)
+	case "GetBucketPolicy":
+		s.handleGetBucketPolicy(cw, r, s3Req)
+	case "PutBucketPolicy":
+		s.handlePutBucketPolicy(cw, r, s3Req)
+	case "DeleteBucketPolicy":
+		s.handleDeleteBucketPolicy(cw, r, s3Req)
+	case "GetBucketPolicyStatus":
+		s.handleGetBucketPolicyStatus(cw, r, s3Req)
 	case "RestoreObject":
 		s.handleRestoreObject(cw, r, s3Req)
+	case "SelectObjectContent":
+		s.handleSelectObjectContent(cw, r, s3Req)
+	case "PostObject":
+		s.handlePostObject(cw, r, s3Req, postForm)
 	case "GetObjectTagging":
 		s.handleGetObjectTagging(cw, r, s3Req)
 	case "PutObjectTagging":
 		s.handlePutObjectTagging(cw, r, s3Req)
 	case "DeleteObjectTagging":
 		s.handleDeleteObjectTagging(cw, r, s3Req)
+	case "GetObjectAttributes":
+		s.handleGetObjectAttributes(cw, r, s3Req)
+	case "GetBucketAcl":
+		s.handleGetBucketAcl(cw, r, s3Req)
+	case "PutBucketAcl":
+		s.handlePutBucketAcl(cw, r, s3Req)
+	case "GetObjectAcl":
+		s.handleGetObjectAcl(cw, r, s3Req)
+	case "PutObjectAcl":
+		s.handlePutObjectAcl(cw, r, s3Req)
+	case "GetBucketOwnershipControls":
+		s.handleGetBucketOwnershipControls(cw, r, s3Req)
+	case "PutBucketOwnershipControls":
+		s.handlePutBucketOwnershipControls(cw, r, s3Req)
+	case "DeleteBucketOwnershipControls":
+		s.handleDeleteBucketOwnershipControls(cw, r, s3Req)
+	case "GetPublicAccessBlock":
+		s.handleGetPublicAccessBlock(cw, r, s3Req)
+	case "PutPublicAccessBlock":
+		s.handlePutPublicAccessBlock(cw, r, s3Req)
+	case "DeletePublicAccessBlock":
+		s.handleDeletePublicAccessBlock(cw, r, s3Req)
 	default:
 		s.logger.Warn("operation not implemented",
 			zap.String("operation", s3Req.Operation))
@@ -583,6 +820,7 @@ func (s *Server) handleS3Request(w http.ResponseWriter, r *http.Request) {
 func (s *Server) handleGetObject(w http.ResponseWriter, r *http.Request, req *S3Request) {
 	adapter := NewS3ToEngine(s.engine, s.db, s.logger)
 	adapter.sseService = s.sseService
+	adapter.kms = s.kms
 	adapter.chunkEncSvc = s.chunkEncSvc
 	adapter.gci = s.gci
 	if s.chunkGetPrefetch > 0 {
@@ -622,12 +860,16 @@ func (s *Server) handleHeadObject(w http.ResponseWriter, r *http.Request, req *S
 	var encAlgo string
 	var tagsJSON []byte
 	var contentDisposition string
+	var kmsKeyID string
+	var replicationStatus string
+	var redirectLocation string
 
 	err = s.db.QueryRowContext(r.Context(), `
-		SELECT size_bytes, etag, content_type, updated_at, COALESCE(metadata, '{}'), COALESCE(backend_name, ''), COALESCE(encryption_algorithm, ''), COALESCE(tags, '{}'), COALESCE(content_disposition, '')
+		SELECT size_bytes, etag, content_type, updated_at, COALESCE(metadata, '{}'), COALESCE(backend_name, ''), COALESCE(encryption_algorithm, ''), COALESCE(tags, '{}'), COALESCE(content_disposition, ''),
+		       COALESCE(sse_kms_key_id, ''), COALESCE(replication_status, ''), COALESCE(website_redirect_location, '')
 		FROM object_head_cache
 		WHERE tenant_id = $1 AND bucket = $2 AND object_key = $3
-	`, t.ID, req.Bucket, req.Object).Scan(&sizeBytes, &etag, &contentType, &updatedAt, &metadataJSON, &backendName, &encAlgo, &tagsJSON, &contentDisposition)
+	`, t.ID, req.Bucket, req.Object).Scan(&sizeBytes, &etag, &contentType, &updatedAt, &metadataJSON, &backendName, &encAlgo, &tagsJSON, &contentDisposition, &kmsKeyID, &replicationStatus, &redirectLocation)
 
 	if err == sql.ErrNoRows {
 		s.logger.Warn("HEAD: object not in metadata cache",
@@ -690,16 +932,22 @@ func (s *Server) handleHeadObject(w http.ResponseWriter, r *http.Request, req *S
 			return
 		}
 		w.Header().Set("x-amz-server-side-encryption-customer-algorithm", "AES256")
-	} else if encAlgo != "" {
-		w.Header().Set("x-amz-server-side-encryption", "AES256")
+	} else {
+		setSSEResponseHeaders(w, encAlgo, kmsKeyID)
 	}
 	setS3MetadataHeaders(w, metadataJSON)
 	if n := tagCount(tagsJSON); n > 0 {
 		w.Header().Set("x-amz-tagging-count", strconv.Itoa(n))
 	}
+	setReplicationStatusHeader(w, replicationStatus)
+	setWebsiteRedirectHeader(w, redirectLocation)
 	if cd := sanitizeContentDisposition(contentDisposition); cd != "" {
 		w.Header().Set("Content-Disposition", cd)
 	}
+	if checksumModeEnabled(r) {
+		alg, val, typ := lookupObjectChecksum(r.Context(), s.db, t.ID, req.Bucket, req.Object)
+		setChecksumHeaders(w, alg, val, typ)
+	}
 	// HEAD must not write a body.
 	w.WriteHeader(http.StatusOK)
 }
@@ -747,6 +995,7 @@ func (s *Server) handlePutObject(w http.ResponseWriter, r *http.Request, req *S3
 
 	adapter := NewS3ToEngine(s.engine, s.db, s.logger)
 	adapter.sseService = s.sseService
+	adapter.kms = s.kms
 	adapter.chunkEncSvc = s.chunkEncSvc
 	adapter.gci = s.gci
 	adapter.flags = s.flags
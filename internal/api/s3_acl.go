@@ -0,0 +1,1057 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/FairForge/vaultaire/internal/auth"
+	"github.com/FairForge/vaultaire/internal/common"
+	"github.com/FairForge/vaultaire/internal/tenant"
+	"go.uber.org/zap"
+)
+
+// Access control lists (?acl), object ownership controls and public access
+// blocks. The bucket owner always has full access to its own buckets; ACLs
+// (evaluated in auth/acl.go) let other tenants in, and let the CDN serve
+// single public-read objects from an otherwise private bucket. Requests
+// naming a bucket the signer does not own are resolved to its owner in
+// handleS3Request (resolveBucketOwner) and run in the owner's namespace,
+// billed to the owner.
+
+const (
+	maxACLBodyBytes = 65536
+	xsiNamespace    = "http://www.w3.org/2001/XMLSchema-instance"
+)
+
+// AccessControlPolicy is the S3 XML document for GET/PUT ?acl.
+type AccessControlPolicy struct {
+	XMLName           xml.Name    `xml:"AccessControlPolicy"`
+	Xmlns             string      `xml:"xmlns,attr,omitempty"`
+	Owner             *OwnerEntry `xml:"Owner,omitempty"`
+	AccessControlList []Grant     `xml:"AccessControlList>Grant"`
+}
+
+// Grant is one grantee and permission of an AccessControlPolicy.
+type Grant struct {
+	Grantee    Grantee `xml:"Grantee"`
+	Permission string  `xml:"Permission"`
+}
+
+// Grantee is a canonical user (a tenant ID) or a predefined group.
+type Grantee struct {
+	Type         string `xml:"http://www.w3.org/2001/XMLSchema-instance type,attr"`
+	ID           string `xml:"ID,omitempty"`
+	DisplayName  string `xml:"DisplayName,omitempty"`
+	URI          string `xml:"URI,omitempty"`
+	EmailAddress string `xml:"EmailAddress,omitempty"`
+}
+
+// MarshalXML writes the xsi:type attribute with the prefix S3 clients
+// expect; encoding/xml would invent its own.
+func (g Grantee) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	start.Attr = []xml.Attr{
+		{Name: xml.Name{Local: "xmlns:xsi"}, Value: xsiNamespace},
+		{Name: xml.Name{Local: "xsi:type"}, Value: g.Type},
+	}
+	body := struct {
+		ID           string `xml:"ID,omitempty"`
+		DisplayName  string `xml:"DisplayName,omitempty"`
+		URI          string `xml:"URI,omitempty"`
+		EmailAddress string `xml:"EmailAddress,omitempty"`
+	}{g.ID, g.DisplayName, g.URI, g.EmailAddress}
+	return e.EncodeElement(body, start)
+}
+
+// OwnershipControls is the S3 XML document for GET/PUT ?ownershipControls.
+type OwnershipControls struct {
+	XMLName xml.Name                `xml:"OwnershipControls"`
+	Xmlns   string                  `xml:"xmlns,attr,omitempty"`
+	Rules   []OwnershipControlsRule `xml:"Rule"`
+}
+
+// OwnershipControlsRule holds the bucket's ObjectOwnership setting.
+type OwnershipControlsRule struct {
+	ObjectOwnership string `xml:"ObjectOwnership"`
+}
+
+// PublicAccessBlockConfiguration is the S3 XML document for GET/PUT
+// ?publicAccessBlock.
+type PublicAccessBlockConfiguration struct {
+	XMLName               xml.Name `xml:"PublicAccessBlockConfiguration"`
+	Xmlns                 string   `xml:"xmlns,attr,omitempty"`
+	BlockPublicAcls       bool     `xml:"BlockPublicAcls"`
+	IgnorePublicAcls      bool     `xml:"IgnorePublicAcls"`
+	BlockPublicPolicy     bool     `xml:"BlockPublicPolicy"`
+	RestrictPublicBuckets bool     `xml:"RestrictPublicBuckets"`
+}
+
+// aclRequestError is an ACL a request asks for that cannot be applied,
+// with the S3 error code to answer it with.
+type aclRequestError struct {
+	code    string
+	message string
+}
+
+func (e *aclRequestError) Error() string { return e.message }
+
+// writeACLError answers a failed ACL request: with its own code when it
+// is an aclRequestError, InternalError otherwise.
+func writeACLError(w http.ResponseWriter, r *http.Request, logger *zap.Logger, err error) {
+	var ae *aclRequestError
+	if errors.As(err, &ae) {
+		WriteS3ErrorWithContext(w, ae.code, r.URL.Path, generateRequestID(), WithSuggestion(ae.message))
+		return
+	}
+	logger.Error("apply ACL", zap.Error(err))
+	WriteS3Error(w, ErrInternalError, r.URL.Path, generateRequestID())
+}
+
+// requesterIDKey holds the tenant that signed a request served from
+// another tenant's bucket.
+const requesterIDKey contextKey = "requester_id"
+
+func withRequester(ctx context.Context, tenantID string) context.Context {
+	return context.WithValue(ctx, requesterIDKey, tenantID)
+}
+
+// requesterFromContext returns the tenant that signed a request against
+// another tenant's bucket, or "" when the bucket owner signed it.
+func requesterFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requesterIDKey).(string)
+	return id
+}
+
+// bucketAccess is a bucket's ACL state.
+type bucketAccess struct {
+	visibility string
+	acl        *auth.ACL
+	ownership  string
+	block      auth.PublicAccessBlock
+}
+
+// loadBucketAccess reads a bucket's ACL state; sql.ErrNoRows means the
+// bucket does not exist.
+func loadBucketAccess(ctx context.Context, db *sql.DB, tenantID, bucket string) (*bucketAccess, error) {
+	var access bucketAccess
+	var aclJSON, blockJSON []byte
+	err := db.QueryRowContext(ctx, `
+		SELECT visibility, acl, COALESCE(object_ownership, ''), public_access_block
+		FROM buckets WHERE tenant_id = $1 AND name = $2`,
+		tenantID, bucket).Scan(&access.visibility, &aclJSON, &access.ownership, &blockJSON)
+	if err != nil {
+		return nil, err
+	}
+	if access.acl, err = auth.ParseACL(aclJSON); err != nil {
+		return nil, fmt.Errorf("stored bucket ACL: %w", err)
+	}
+	if access.block, err = auth.ParsePublicAccessBlock(blockJSON); err != nil {
+		return nil, fmt.Errorf("stored public access block: %w", err)
+	}
+	return &access, nil
+}
+
+// aclsEnabled reports whether ACLs count on the bucket at all.
+func (b *bucketAccess) aclsEnabled() bool {
+	return b.ownership != auth.OwnershipBucketOwnerEnforced
+}
+
+// bucketACL returns the bucket's ACL as GetBucketAcl reports it: the
+// stored grants, plus AllUsers READ when the bucket is public-read.
+func (b *bucketAccess) bucketACL(owner string) *auth.ACL {
+	acl := &auth.ACL{Owner: owner, Grants: []auth.ACLGrant{{TenantID: owner, Permission: auth.ACLFullControl}}}
+	if b.acl != nil {
+		acl.Grants = b.acl.Grants
+	}
+	if b.visibility == "public-read" {
+		acl.Grants = append(acl.Grants, auth.ACLGrant{Group: auth.GroupAllUsers, Permission: auth.ACLRead})
+	}
+	return acl
+}
+
+// objectReadable reports whether an object's ACL, or the bucket's
+// public-read visibility, lets tenantID ("" for the CDN) read it. A
+// public-read bucket makes every object readable but does not make the
+// bucket listable: visibility has only ever meant serving objects.
+func (b *bucketAccess) objectReadable(objectACL *auth.ACL, tenantID string) bool {
+	if b.visibility == "public-read" && !b.block.IgnorePublicAcls {
+		return true
+	}
+	return b.aclsEnabled() && objectACL.Allows(tenantID, auth.ACLRead, b.block.IgnorePublicAcls)
+}
+
+// defaultACL reports whether acl is the one every resource starts with:
+// private, with the bucket owner holding full control. It is stored as
+// NULL.
+func defaultACL(acl *auth.ACL, bucketOwner string) bool {
+	return acl == nil || (acl.Owner == bucketOwner && len(acl.Grants) == 1 &&
+		acl.Grants[0] == auth.ACLGrant{TenantID: bucketOwner, Permission: auth.ACLFullControl})
+}
+
+// encodeACL returns the stored form of acl: NULL (nil) for the default.
+func encodeACL(acl *auth.ACL, bucketOwner string) []byte {
+	if defaultACL(acl, bucketOwner) {
+		return nil
+	}
+	raw, err := json.Marshal(acl)
+	if err != nil {
+		return nil
+	}
+	return raw
+}
+
+// aclGrantHeaders are the x-amz-grant-* headers and the permission each
+// grants.
+var aclGrantHeaders = []struct {
+	header     string
+	permission string
+}{
+	{"x-amz-grant-read", auth.ACLRead},
+	{"x-amz-grant-write", auth.ACLWrite},
+	{"x-amz-grant-read-acp", auth.ACLReadACP},
+	{"x-amz-grant-write-acp", auth.ACLWriteACP},
+	{"x-amz-grant-full-control", auth.ACLFullControl},
+}
+
+// parseGrantHeader parses an x-amz-grant-* value: a comma-separated list
+// of id="<canonical ID>" or uri="<group URI>" grantees.
+func parseGrantHeader(value, permission string) ([]auth.ACLGrant, error) {
+	var grants []auth.ACLGrant
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		kind, grantee, ok := strings.Cut(item, "=")
+		if !ok {
+			return nil, &aclRequestError{ErrInvalidArgument, fmt.Sprintf("Grantee %q must be id=\"...\" or uri=\"...\".", item)}
+		}
+		grantee = strings.Trim(strings.TrimSpace(grantee), `"`)
+		switch strings.ToLower(strings.TrimSpace(kind)) {
+		case "id":
+			grants = append(grants, auth.ACLGrant{TenantID: grantee, Permission: permission})
+		case "uri":
+			grants = append(grants, auth.ACLGrant{Group: grantee, Permission: permission})
+		case "emailaddress":
+			return nil, &aclRequestError{ErrUnresolvableGrantByEmailAddress, "Grants by email address are not supported; grant to the tenant's canonical ID."}
+		default:
+			return nil, &aclRequestError{ErrInvalidArgument, fmt.Sprintf("Unsupported grantee type %q.", kind)}
+		}
+	}
+	return grants, nil
+}
+
+// requestACL returns the ACL a request sets: from a canned x-amz-acl, from
+// x-amz-grant-* headers, or, when body is set (PutBucketAcl, PutObjectAcl),
+// from an AccessControlPolicy document. owner is the owner the resource
+// will have; the result is nil when the request sets no ACL.
+func requestACL(r *http.Request, owner, bucketOwner string, body []byte) (*auth.ACL, error) {
+	canned := r.Header.Get("x-amz-acl")
+	var grants []auth.ACLGrant
+	hasGrants := false
+	for _, h := range aclGrantHeaders {
+		value := r.Header.Get(h.header)
+		if value == "" {
+			continue
+		}
+		hasGrants = true
+		g, err := parseGrantHeader(value, h.permission)
+		if err != nil {
+			return nil, err
+		}
+		grants = append(grants, g...)
+	}
+	sources := 0
+	for _, set := range []bool{canned != "", hasGrants, len(body) > 0} {
+		if set {
+			sources++
+		}
+	}
+	if sources > 1 {
+		return nil, &aclRequestError{ErrInvalidRequest, "Specify an ACL with one of x-amz-acl, x-amz-grant-* headers or a request body."}
+	}
+
+	var acl *auth.ACL
+	switch {
+	case canned != "":
+		var err error
+		if acl, err = auth.CannedACL(canned, owner, bucketOwner); err != nil {
+			return nil, &aclRequestError{ErrInvalidArgument, err.Error()}
+		}
+	case hasGrants:
+		acl = &auth.ACL{Owner: owner, Grants: grants}
+	case len(body) > 0:
+		var doc AccessControlPolicy
+		if err := xml.Unmarshal(body, &doc); err != nil {
+			return nil, &aclRequestError{ErrMalformedXML, "The AccessControlPolicy document is not well-formed."}
+		}
+		if doc.Owner != nil && doc.Owner.ID != "" && doc.Owner.ID != owner {
+			return nil, &aclRequestError{ErrAccessDenied, "An ACL cannot change the owner of a bucket or object."}
+		}
+		acl = &auth.ACL{Owner: owner}
+		for _, g := range doc.AccessControlList {
+			grant, err := g.Grantee.aclGrant(g.Permission)
+			if err != nil {
+				return nil, err
+			}
+			acl.Grants = append(acl.Grants, grant)
+		}
+	default:
+		return nil, nil
+	}
+	if err := acl.Validate(); err != nil {
+		return nil, &aclRequestError{ErrInvalidArgument, err.Error()}
+	}
+	return acl, nil
+}
+
+// aclGrant converts a document grantee. The xsi:type decides when given;
+// otherwise whichever identifier is present does.
+func (g Grantee) aclGrant(permission string) (auth.ACLGrant, error) {
+	switch {
+	case g.Type == "CanonicalUser" || (g.Type == "" && g.ID != ""):
+		return auth.ACLGrant{TenantID: g.ID, Permission: permission}, nil
+	case g.Type == "Group" || (g.Type == "" && g.URI != ""):
+		return auth.ACLGrant{Group: g.URI, Permission: permission}, nil
+	case g.Type == "AmazonCustomerByEmail" || g.EmailAddress != "":
+		return auth.ACLGrant{}, &aclRequestError{ErrUnresolvableGrantByEmailAddress, "Grants by email address are not supported; grant to the tenant's canonical ID."}
+	}
+	return auth.ACLGrant{}, &aclRequestError{ErrMalformedXML, fmt.Sprintf("Unsupported grantee type %q.", g.Type)}
+}
+
+// renderACL writes acl as an AccessControlPolicy document.
+func renderACL(w http.ResponseWriter, acl *auth.ACL) {
+	doc := AccessControlPolicy{
+		Xmlns: "http://s3.amazonaws.com/doc/2006-03-01/",
+		Owner: &OwnerEntry{ID: acl.Owner, DisplayName: acl.Owner},
+	}
+	for _, g := range acl.Grants {
+		grantee := Grantee{Type: "CanonicalUser", ID: g.TenantID, DisplayName: g.TenantID}
+		if g.Group != "" {
+			grantee = Grantee{Type: "Group", URI: g.Group}
+		}
+		doc.AccessControlList = append(doc.AccessControlList, Grant{Grantee: grantee, Permission: g.Permission})
+	}
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte(xml.Header))
+	_ = xml.NewEncoder(w).Encode(doc)
+}
+
+// checkACLGrantees rejects grants to tenants that do not exist.
+func checkACLGrantees(ctx context.Context, db *sql.DB, acl *auth.ACL) error {
+	seen := map[string]bool{}
+	for _, g := range acl.Grants {
+		if g.TenantID == "" || seen[g.TenantID] || g.TenantID == acl.Owner {
+			continue
+		}
+		seen[g.TenantID] = true
+		var exists bool
+		if err := db.QueryRowContext(ctx,
+			`SELECT EXISTS (SELECT 1 FROM tenants WHERE id = $1)`, g.TenantID).Scan(&exists); err != nil {
+			return err
+		}
+		if !exists {
+			return &aclRequestError{ErrInvalidArgument, fmt.Sprintf("Invalid id: no tenant has canonical ID %q.", g.TenantID)}
+		}
+	}
+	return nil
+}
+
+// checkPublicACL rejects a public ACL the bucket's public access block
+// forbids, and an AllUsers grant on a tier that cannot serve the public.
+func checkPublicACL(ctx context.Context, db *sql.DB, bucketOwner string, access *bucketAccess, acl *auth.ACL) error {
+	if !acl.IsPublic() {
+		return nil
+	}
+	if access.block.BlockPublicAcls {
+		return &aclRequestError{ErrAccessDenied, "The bucket's public access block (BlockPublicAcls) forbids public ACLs."}
+	}
+	if acl.Allows("", auth.ACLRead, false) {
+		var tier string
+		_ = db.QueryRowContext(ctx,
+			`SELECT COALESCE(tier, '') FROM tenant_quotas WHERE tenant_id = $1`,
+			bucketOwner).Scan(&tier)
+		if allowed, reason := auth.CanEnablePublicRead(tier); !allowed {
+			return &aclRequestError{ErrAccessDenied, reason}
+		}
+	}
+	return nil
+}
+
+// objectACLForWrite returns the stored ACL for an object a request creates
+// (PutObject, CopyObject, CreateMultipartUpload): nil when the bucket
+// owner writes it without asking for an ACL. An object another tenant
+// writes is owned by that tenant, unless the bucket prefers or enforces
+// bucket-owner ownership.
+func objectACLForWrite(ctx context.Context, db *sql.DB, r *http.Request, bucketOwner, bucket string) ([]byte, error) {
+	owner := requesterFromContext(ctx)
+	if owner == "" {
+		owner = bucketOwner
+	}
+	canned := r.Header.Get("x-amz-acl")
+	if db == nil || (owner == bucketOwner && canned == "" && !hasGrantHeaders(r)) {
+		// The common case: the default ACL, whatever the bucket's settings.
+		return nil, nil
+	}
+	access, err := loadBucketAccess(ctx, db, bucketOwner, bucket)
+	if err == sql.ErrNoRows {
+		access, err = &bucketAccess{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !access.aclsEnabled() {
+		// Only bucket-owner-full-control, which changes nothing, is accepted.
+		if (canned != "" && canned != auth.CannedBucketOwnerFullControl) || hasGrantHeaders(r) {
+			return nil, &aclRequestError{ErrAccessControlListNotSupported, "ACLs are disabled on this bucket (BucketOwnerEnforced)."}
+		}
+		return nil, nil
+	}
+	if access.ownership == auth.OwnershipBucketOwnerPreferred && canned == auth.CannedBucketOwnerFullControl {
+		owner = bucketOwner
+	}
+
+	acl, err := requestACL(r, owner, bucketOwner, nil)
+	if err != nil {
+		return nil, err
+	}
+	if acl == nil {
+		acl, _ = auth.CannedACL(auth.CannedPrivate, owner, bucketOwner)
+	}
+	if err := checkACLGrantees(ctx, db, acl); err != nil {
+		return nil, err
+	}
+	if err := checkPublicACL(ctx, db, bucketOwner, access, acl); err != nil {
+		return nil, err
+	}
+	return encodeACL(acl, bucketOwner), nil
+}
+
+func hasGrantHeaders(r *http.Request) bool {
+	for _, h := range aclGrantHeaders {
+		if r.Header.Get(h.header) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveBucketOwner returns the tenant owning the bucket a request
+// names. That is the signer unless the signer has no bucket of that name
+// and exactly one other tenant does, for an operation an ACL can grant;
+// handleS3Request then authorizes the request against the owner's policy
+// and ACLs before running it in the owner's namespace.
+func (s *Server) resolveBucketOwner(r *http.Request, s3Req *S3Request, tenantID string) (string, error) {
+	if s.testMode || s.db == nil || s3Req.Bucket == "" || tenantID == "" {
+		return tenantID, nil
+	}
+	if _, _, ok := auth.ACLPermission(s3Req.Operation); !ok {
+		return tenantID, nil
+	}
+	rows, err := s.db.QueryContext(r.Context(),
+		`SELECT tenant_id FROM buckets WHERE name = $1 ORDER BY tenant_id = $2 DESC LIMIT 2`,
+		s3Req.Bucket, tenantID)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = rows.Close() }()
+	var owners []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return "", err
+		}
+		owners = append(owners, id)
+	}
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+	// Ambiguous names stay with the signer, who then gets NoSuchBucket.
+	if len(owners) != 1 {
+		return tenantID, nil
+	}
+	return owners[0], nil
+}
+
+// aclAllows reports whether the owner's ACLs let requester perform a
+// request on its bucket.
+func (s *Server) aclAllows(ctx context.Context, s3Req *S3Request, bucketOwner, requester string) (bool, error) {
+	permission, object, ok := auth.ACLPermission(s3Req.Operation)
+	if !ok {
+		return false, nil
+	}
+	access, err := loadBucketAccess(ctx, s.db, bucketOwner, s3Req.Bucket)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if !object {
+		// Only stored grants: public-read visibility never made a bucket
+		// listable.
+		return access.aclsEnabled() && access.acl.Allows(requester, permission, access.block.IgnorePublicAcls), nil
+	}
+
+	// Object permissions are the current version's.
+	var raw []byte
+	err = s.db.QueryRowContext(ctx,
+		`SELECT acl FROM object_head_cache WHERE tenant_id = $1 AND bucket = $2 AND object_key = $3`,
+		bucketOwner, s3Req.Bucket, s3Req.Object).Scan(&raw)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	acl, err := auth.ParseACL(raw)
+	if err != nil {
+		return false, fmt.Errorf("stored object ACL: %w", err)
+	}
+	if permission == auth.ACLRead {
+		return access.objectReadable(acl, requester), nil
+	}
+	return access.aclsEnabled() && acl.Allows(requester, permission, access.block.IgnorePublicAcls), nil
+}
+
+// switchToBucketOwner runs the rest of a cross-tenant request as the
+// bucket owner, remembering the signer as the requester.
+func switchToBucketOwner(r *http.Request, bucketOwner, requester string) *http.Request {
+	ctx := withRequester(r.Context(), requester)
+	return r.WithContext(context.WithValue(ctx, common.TenantIDKey, bucketOwner))
+}
+
+func (s *Server) handleGetBucketAcl(w http.ResponseWriter, r *http.Request, req *S3Request) {
+	t, err := tenant.FromContext(r.Context())
+	if err != nil || t == nil {
+		WriteS3Error(w, ErrAccessDenied, r.URL.Path, generateRequestID())
+		return
+	}
+
+	if s.db == nil {
+		renderACL(w, (&bucketAccess{}).bucketACL(t.ID))
+		return
+	}
+
+	access, err := loadBucketAccess(r.Context(), s.db, t.ID, req.Bucket)
+	if err == sql.ErrNoRows {
+		s.writeNoSuchBucket(w, r, t.ID, req.Bucket)
+		return
+	}
+	if err != nil {
+		s.logger.Error("query bucket ACL", zap.Error(err))
+		WriteS3Error(w, ErrInternalError, r.URL.Path, generateRequestID())
+		return
+	}
+	renderACL(w, access.bucketACL(t.ID))
+}
+
+func (s *Server) handlePutBucketAcl(w http.ResponseWriter, r *http.Request, req *S3Request) {
+	t, err := tenant.FromContext(r.Context())
+	if err != nil || t == nil {
+		WriteS3Error(w, ErrAccessDenied, r.URL.Path, generateRequestID())
+		return
+	}
+
+	if s.db == nil {
+		WriteS3Error(w, ErrInternalError, r.URL.Path, generateRequestID())
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxACLBodyBytes))
+	if err != nil {
+		WriteS3Error(w, bodyReadErrorCode(err), r.URL.Path, generateRequestID())
+		return
+	}
+
+	access, err := loadBucketAccess(r.Context(), s.db, t.ID, req.Bucket)
+	if err == sql.ErrNoRows {
+		s.writeNoSuchBucket(w, r, t.ID, req.Bucket)
+		return
+	}
+	if err != nil {
+		s.logger.Error("query bucket ACL", zap.Error(err))
+		WriteS3Error(w, ErrInternalError, r.URL.Path, generateRequestID())
+		return
+	}
+
+	acl, err := requestACL(r, t.ID, t.ID, body)
+	if err == nil && acl == nil {
+		err = &aclRequestError{ErrInvalidRequest, "Set the ACL with x-amz-acl, x-amz-grant-* headers or an AccessControlPolicy body."}
+	}
+	if err == nil && !access.aclsEnabled() && !defaultACL(acl, t.ID) {
+		err = &aclRequestError{ErrAccessControlListNotSupported, "ACLs are disabled on this bucket (BucketOwnerEnforced)."}
+	}
+	if err == nil {
+		err = checkACLGrantees(r.Context(), s.db, acl)
+	}
+	if err == nil {
+		err = checkPublicACL(r.Context(), s.db, t.ID, access, acl)
+	}
+	if err != nil {
+		writeACLError(w, r, s.logger, err)
+		return
+	}
+
+	// AllUsers READ is stored as the bucket's visibility, so the dashboard
+	// and the CDN see the same public-read switch.
+	visibility := "private"
+	stored := &auth.ACL{Owner: t.ID}
+	for _, g := range acl.Grants {
+		if g == (auth.ACLGrant{Group: auth.GroupAllUsers, Permission: auth.ACLRead}) {
+			visibility = "public-read"
+			continue
+		}
+		stored.Grants = append(stored.Grants, g)
+	}
+
+	_, err = s.db.ExecContext(r.Context(),
+		`UPDATE buckets SET acl = $3, visibility = $4, updated_at = NOW()
+		 WHERE tenant_id = $1 AND name = $2`,
+		t.ID, req.Bucket, encodeACL(stored, t.ID), visibility)
+	if err != nil {
+		s.logger.Error("update bucket ACL", zap.Error(err))
+		WriteS3Error(w, ErrInternalError, r.URL.Path, generateRequestID())
+		return
+	}
+
+	s.logger.Info("bucket ACL updated",
+		zap.String("tenant_id", t.ID),
+		zap.String("bucket", req.Bucket),
+		zap.String("visibility", visibility),
+		zap.Int("grants", len(acl.Grants)))
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// loadObjectACL reads the current version's ACL, defaulting to private to
+// the bucket owner; sql.ErrNoRows means the object does not exist.
+func (s *Server) loadObjectACL(ctx context.Context, bucketOwner, bucket, key string) (*auth.ACL, error) {
+	var raw []byte
+	err := s.db.QueryRowContext(ctx,
+		`SELECT acl FROM object_head_cache WHERE tenant_id = $1 AND bucket = $2 AND object_key = $3`,
+		bucketOwner, bucket, key).Scan(&raw)
+	if err != nil {
+		return nil, err
+	}
+	acl, err := auth.ParseACL(raw)
+	if err != nil {
+		return nil, fmt.Errorf("stored object ACL: %w", err)
+	}
+	if acl == nil {
+		acl, _ = auth.CannedACL(auth.CannedPrivate, bucketOwner, bucketOwner)
+	}
+	return acl, nil
+}
+
+// objectACLVersionUnsupported rejects ?versionId on the object ACL
+// operations: ACLs are kept for the current version only.
+func objectACLVersionUnsupported(w http.ResponseWriter, r *http.Request) bool {
+	if r.URL.Query().Get("versionId") == "" {
+		return false
+	}
+	WriteS3ErrorWithContext(w, ErrNotImplemented, r.URL.Path, generateRequestID(),
+		WithSuggestion("Object ACLs apply to the current version; omit versionId."))
+	return true
+}
+
+func (s *Server) handleGetObjectAcl(w http.ResponseWriter, r *http.Request, req *S3Request) {
+	t, err := tenant.FromContext(r.Context())
+	if err != nil || t == nil {
+		WriteS3Error(w, ErrAccessDenied, r.URL.Path, generateRequestID())
+		return
+	}
+	if objectACLVersionUnsupported(w, r) {
+		return
+	}
+
+	if s.db == nil {
+		WriteS3Error(w, ErrNoSuchKey, r.URL.Path, generateRequestID())
+		return
+	}
+
+	acl, err := s.loadObjectACL(r.Context(), t.ID, req.Bucket, req.Object)
+	if err == sql.ErrNoRows {
+		WriteS3Error(w, ErrNoSuchKey, r.URL.Path, generateRequestID())
+		return
+	}
+	if err != nil {
+		s.logger.Error("query object ACL", zap.Error(err))
+		WriteS3Error(w, ErrInternalError, r.URL.Path, generateRequestID())
+		return
+	}
+	renderACL(w, acl)
+}
+
+func (s *Server) handlePutObjectAcl(w http.ResponseWriter, r *http.Request, req *S3Request) {
+	t, err := tenant.FromContext(r.Context())
+	if err != nil || t == nil {
+		WriteS3Error(w, ErrAccessDenied, r.URL.Path, generateRequestID())
+		return
+	}
+	if objectACLVersionUnsupported(w, r) {
+		return
+	}
+
+	if s.db == nil {
+		WriteS3Error(w, ErrInternalError, r.URL.Path, generateRequestID())
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxACLBodyBytes))
+	if err != nil {
+		WriteS3Error(w, bodyReadErrorCode(err), r.URL.Path, generateRequestID())
+		return
+	}
+
+	access, err := loadBucketAccess(r.Context(), s.db, t.ID, req.Bucket)
+	if err == sql.ErrNoRows {
+		s.writeNoSuchBucket(w, r, t.ID, req.Bucket)
+		return
+	}
+	if err != nil {
+		s.logger.Error("query bucket ACL", zap.Error(err))
+		WriteS3Error(w, ErrInternalError, r.URL.Path, generateRequestID())
+		return
+	}
+	current, err := s.loadObjectACL(r.Context(), t.ID, req.Bucket, req.Object)
+	if err == sql.ErrNoRows {
+		WriteS3Error(w, ErrNoSuchKey, r.URL.Path, generateRequestID())
+		return
+	}
+	if err != nil {
+		s.logger.Error("query object ACL", zap.Error(err))
+		WriteS3Error(w, ErrInternalError, r.URL.Path, generateRequestID())
+		return
+	}
+
+	acl, err := requestACL(r, current.Owner, t.ID, body)
+	if err == nil && acl == nil {
+		err = &aclRequestError{ErrInvalidRequest, "Set the ACL with x-amz-acl, x-amz-grant-* headers or an AccessControlPolicy body."}
+	}
+	if err == nil && !access.aclsEnabled() && !defaultACL(acl, t.ID) &&
+		r.Header.Get("x-amz-acl") != auth.CannedBucketOwnerFullControl {
+		err = &aclRequestError{ErrAccessControlListNotSupported, "ACLs are disabled on this bucket (BucketOwnerEnforced)."}
+	}
+	if err == nil {
+		err = checkACLGrantees(r.Context(), s.db, acl)
+	}
+	if err == nil {
+		err = checkPublicACL(r.Context(), s.db, t.ID, access, acl)
+	}
+	if err != nil {
+		writeACLError(w, r, s.logger, err)
+		return
+	}
+	if !access.aclsEnabled() {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	// The ACL is not part of the object's data: Last-Modified is unchanged.
+	result, err := s.db.ExecContext(r.Context(),
+		`UPDATE object_head_cache SET acl = $4
+		 WHERE tenant_id = $1 AND bucket = $2 AND object_key = $3`,
+		t.ID, req.Bucket, req.Object, encodeACL(acl, t.ID))
+	if err != nil {
+		s.logger.Error("update object ACL", zap.Error(err))
+		WriteS3Error(w, ErrInternalError, r.URL.Path, generateRequestID())
+		return
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		WriteS3Error(w, ErrNoSuchKey, r.URL.Path, generateRequestID())
+		return
+	}
+
+	s.logger.Info("object ACL updated",
+		zap.String("tenant_id", t.ID),
+		zap.String("bucket", req.Bucket),
+		zap.String("key", req.Object),
+		zap.Bool("public", acl.IsPublic()))
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *Server) handleGetBucketOwnershipControls(w http.ResponseWriter, r *http.Request, req *S3Request) {
+	t, err := tenant.FromContext(r.Context())
+	if err != nil || t == nil {
+		WriteS3Error(w, ErrAccessDenied, r.URL.Path, generateRequestID())
+		return
+	}
+
+	if s.db == nil {
+		WriteS3Error(w, ErrOwnershipControlsNotFound, r.URL.Path, generateRequestID())
+		return
+	}
+
+	access, err := loadBucketAccess(r.Context(), s.db, t.ID, req.Bucket)
+	if err == sql.ErrNoRows {
+		s.writeNoSuchBucket(w, r, t.ID, req.Bucket)
+		return
+	}
+	if err != nil {
+		s.logger.Error("query bucket ownership controls", zap.Error(err))
+		WriteS3Error(w, ErrInternalError, r.URL.Path, generateRequestID())
+		return
+	}
+	if access.ownership == "" {
+		WriteS3Error(w, ErrOwnershipControlsNotFound, r.URL.Path, generateRequestID())
+		return
+	}
+
+	resp := OwnershipControls{
+		Xmlns: "http://s3.amazonaws.com/doc/2006-03-01/",
+		Rules: []OwnershipControlsRule{{ObjectOwnership: access.ownership}},
+	}
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte(xml.Header))
+	_ = xml.NewEncoder(w).Encode(resp)
+}
+
+func (s *Server) handlePutBucketOwnershipControls(w http.ResponseWriter, r *http.Request, req *S3Request) {
+	t, err := tenant.FromContext(r.Context())
+	if err != nil || t == nil {
+		WriteS3Error(w, ErrAccessDenied, r.URL.Path, generateRequestID())
+		return
+	}
+
+	if s.db == nil {
+		WriteS3Error(w, ErrInternalError, r.URL.Path, generateRequestID())
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxACLBodyBytes))
+	if err != nil {
+		WriteS3Error(w, bodyReadErrorCode(err), r.URL.Path, generateRequestID())
+		return
+	}
+	var config OwnershipControls
+	if err := xml.Unmarshal(body, &config); err != nil || len(config.Rules) != 1 {
+		WriteS3Error(w, ErrMalformedXML, r.URL.Path, generateRequestID())
+		return
+	}
+	ownership := config.Rules[0].ObjectOwnership
+	switch ownership {
+	case auth.OwnershipObjectWriter, auth.OwnershipBucketOwnerPreferred, auth.OwnershipBucketOwnerEnforced:
+	default:
+		WriteS3ErrorWithContext(w, ErrMalformedXML, r.URL.Path, generateRequestID(),
+			WithSuggestion("ObjectOwnership must be ObjectWriter, BucketOwnerPreferred or BucketOwnerEnforced."))
+		return
+	}
+
+	access, err := loadBucketAccess(r.Context(), s.db, t.ID, req.Bucket)
+	if err == sql.ErrNoRows {
+		s.writeNoSuchBucket(w, r, t.ID, req.Bucket)
+		return
+	}
+	if err != nil {
+		s.logger.Error("query bucket ACL", zap.Error(err))
+		WriteS3Error(w, ErrInternalError, r.URL.Path, generateRequestID())
+		return
+	}
+	// Disabling ACLs must not silently revoke grants the bucket still
+	// shows: they are removed first, as on S3.
+	if ownership == auth.OwnershipBucketOwnerEnforced && !defaultACL(access.bucketACL(t.ID), t.ID) {
+		WriteS3ErrorWithContext(w, ErrBucketAclWithOwnershipEnforced, r.URL.Path, generateRequestID(),
+			WithSuggestion("Set the bucket ACL to private (and its visibility off public-read) first."))
+		return
+	}
+
+	_, err = s.db.ExecContext(r.Context(),
+		`UPDATE buckets SET object_ownership = $3, updated_at = NOW()
+		 WHERE tenant_id = $1 AND name = $2`,
+		t.ID, req.Bucket, ownership)
+	if err != nil {
+		s.logger.Error("update bucket ownership controls", zap.Error(err))
+		WriteS3Error(w, ErrInternalError, r.URL.Path, generateRequestID())
+		return
+	}
+
+	s.logger.Info("bucket ownership controls updated",
+		zap.String("tenant_id", t.ID),
+		zap.String("bucket", req.Bucket),
+		zap.String("object_ownership", ownership))
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *Server) handleDeleteBucketOwnershipControls(w http.ResponseWriter, r *http.Request, req *S3Request) {
+	t, err := tenant.FromContext(r.Context())
+	if err != nil || t == nil {
+		WriteS3Error(w, ErrAccessDenied, r.URL.Path, generateRequestID())
+		return
+	}
+
+	if s.db == nil {
+		WriteS3Error(w, ErrInternalError, r.URL.Path, generateRequestID())
+		return
+	}
+
+	result, err := s.db.ExecContext(r.Context(),
+		`UPDATE buckets SET object_ownership = NULL, updated_at = NOW()
+		 WHERE tenant_id = $1 AND name = $2`,
+		t.ID, req.Bucket)
+	if err != nil {
+		s.logger.Error("delete bucket ownership controls", zap.Error(err))
+		WriteS3Error(w, ErrInternalError, r.URL.Path, generateRequestID())
+		return
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		s.writeNoSuchBucket(w, r, t.ID, req.Bucket)
+		return
+	}
+
+	s.logger.Info("bucket ownership controls deleted",
+		zap.String("tenant_id", t.ID),
+		zap.String("bucket", req.Bucket))
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleGetPublicAccessBlock(w http.ResponseWriter, r *http.Request, req *S3Request) {
+	t, err := tenant.FromContext(r.Context())
+	if err != nil || t == nil {
+		WriteS3Error(w, ErrAccessDenied, r.URL.Path, generateRequestID())
+		return
+	}
+
+	if s.db == nil {
+		WriteS3Error(w, ErrNoSuchPublicAccessBlock, r.URL.Path, generateRequestID())
+		return
+	}
+
+	var raw []byte
+	err = s.db.QueryRowContext(r.Context(),
+		`SELECT public_access_block FROM buckets WHERE tenant_id = $1 AND name = $2`,
+		t.ID, req.Bucket).Scan(&raw)
+	if err == sql.ErrNoRows {
+		s.writeNoSuchBucket(w, r, t.ID, req.Bucket)
+		return
+	}
+	if err != nil {
+		s.logger.Error("query public access block", zap.Error(err))
+		WriteS3Error(w, ErrInternalError, r.URL.Path, generateRequestID())
+		return
+	}
+	if len(raw) == 0 {
+		WriteS3Error(w, ErrNoSuchPublicAccessBlock, r.URL.Path, generateRequestID())
+		return
+	}
+	block, err := auth.ParsePublicAccessBlock(raw)
+	if err != nil {
+		s.logger.Error("decode public access block", zap.Error(err))
+		WriteS3Error(w, ErrInternalError, r.URL.Path, generateRequestID())
+		return
+	}
+
+	resp := PublicAccessBlockConfiguration{
+		Xmlns:                 "http://s3.amazonaws.com/doc/2006-03-01/",
+		BlockPublicAcls:       block.BlockPublicAcls,
+		IgnorePublicAcls:      block.IgnorePublicAcls,
+		BlockPublicPolicy:     block.BlockPublicPolicy,
+		RestrictPublicBuckets: block.RestrictPublicBuckets,
+	}
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte(xml.Header))
+	_ = xml.NewEncoder(w).Encode(resp)
+}
+
+func (s *Server) handlePutPublicAccessBlock(w http.ResponseWriter, r *http.Request, req *S3Request) {
+	t, err := tenant.FromContext(r.Context())
+	if err != nil || t == nil {
+		WriteS3Error(w, ErrAccessDenied, r.URL.Path, generateRequestID())
+		return
+	}
+
+	if s.db == nil {
+		WriteS3Error(w, ErrInternalError, r.URL.Path, generateRequestID())
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxACLBodyBytes))
+	if err != nil {
+		WriteS3Error(w, bodyReadErrorCode(err), r.URL.Path, generateRequestID())
+		return
+	}
+	var config PublicAccessBlockConfiguration
+	if err := xml.Unmarshal(body, &config); err != nil {
+		WriteS3Error(w, ErrMalformedXML, r.URL.Path, generateRequestID())
+		return
+	}
+
+	// Existing public ACLs and policies stay in place: BlockPublic* guard
+	// new ones, IgnorePublicAcls and RestrictPublicBuckets neutralize them.
+	stored, err := json.Marshal(auth.PublicAccessBlock{
+		BlockPublicAcls:       config.BlockPublicAcls,
+		IgnorePublicAcls:      config.IgnorePublicAcls,
+		BlockPublicPolicy:     config.BlockPublicPolicy,
+		RestrictPublicBuckets: config.RestrictPublicBuckets,
+	})
+	if err != nil {
+		s.logger.Error("encode public access block", zap.Error(err))
+		WriteS3Error(w, ErrInternalError, r.URL.Path, generateRequestID())
+		return
+	}
+
+	result, err := s.db.ExecContext(r.Context(),
+		`UPDATE buckets SET public_access_block = $3, updated_at = NOW()
+		 WHERE tenant_id = $1 AND name = $2`,
+		t.ID, req.Bucket, stored)
+	if err != nil {
+		s.logger.Error("update public access block", zap.Error(err))
+		WriteS3Error(w, ErrInternalError, r.URL.Path, generateRequestID())
+		return
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		s.writeNoSuchBucket(w, r, t.ID, req.Bucket)
+		return
+	}
+
+	s.logger.Info("public access block updated",
+		zap.String("tenant_id", t.ID),
+		zap.String("bucket", req.Bucket))
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *Server) handleDeletePublicAccessBlock(w http.ResponseWriter, r *http.Request, req *S3Request) {
+	t, err := tenant.FromContext(r.Context())
+	if err != nil || t == nil {
+		WriteS3Error(w, ErrAccessDenied, r.URL.Path, generateRequestID())
+		return
+	}
+
+	if s.db == nil {
+		WriteS3Error(w, ErrInternalError, r.URL.Path, generateRequestID())
+		return
+	}
+
+	result, err := s.db.ExecContext(r.Context(),
+		`UPDATE buckets SET public_access_block = NULL, updated_at = NOW()
+		 WHERE tenant_id = $1 AND name = $2`,
+		t.ID, req.Bucket)
+	if err != nil {
+		s.logger.Error("delete public access block", zap.Error(err))
+		WriteS3Error(w, ErrInternalError, r.URL.Path, generateRequestID())
+		return
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		s.writeNoSuchBucket(w, r, t.ID, req.Bucket)
+		return
+	}
+
+	s.logger.Info("public access block deleted",
+		zap.String("tenant_id", t.ID),
+		zap.String("bucket", req.Bucket))
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,415 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/FairForge/vaultaire/internal/auth"
+	"github.com/FairForge/vaultaire/internal/tenant"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+var bucketAccessColumns = []string{"visibility", "acl", "object_ownership", "public_access_block"}
+
+func TestParseGrantHeader(t *testing.T) {
+	grants, err := parseGrantHeader(`id="tenant-2", uri="`+auth.GroupAuthenticatedUsers+`"`, auth.ACLRead)
+	require.NoError(t, err)
+	assert.Equal(t, []auth.ACLGrant{
+		{TenantID: "tenant-2", Permission: auth.ACLRead},
+		{Group: auth.GroupAuthenticatedUsers, Permission: auth.ACLRead},
+	}, grants)
+
+	_, err = parseGrantHeader(`emailAddress="a@example.com"`, auth.ACLRead)
+	var ae *aclRequestError
+	require.ErrorAs(t, err, &ae)
+	assert.Equal(t, ErrUnresolvableGrantByEmailAddress, ae.code)
+
+	_, err = parseGrantHeader(`tenant-2`, auth.ACLRead)
+	assert.Error(t, err)
+}
+
+func TestRequestACL(t *testing.T) {
+	r := httptest.NewRequest("PUT", "/b/k?acl", nil)
+	acl, err := requestACL(r, "tenant-1", "tenant-1", nil)
+	require.NoError(t, err)
+	assert.Nil(t, acl, "no ACL requested")
+
+	r.Header.Set("x-amz-acl", "authenticated-read")
+	acl, err = requestACL(r, "tenant-1", "tenant-1", nil)
+	require.NoError(t, err)
+	assert.True(t, acl.Allows("tenant-2", auth.ACLRead, false))
+
+	// Only one way of setting the ACL per request.
+	r.Header.Set("x-amz-grant-read", `id="tenant-2"`)
+	_, err = requestACL(r, "tenant-1", "tenant-1", nil)
+	var ae *aclRequestError
+	require.ErrorAs(t, err, &ae)
+	assert.Equal(t, ErrInvalidRequest, ae.code)
+
+	// A document cannot hand the resource to someone else.
+	body := []byte(`<AccessControlPolicy><Owner><ID>tenant-2</ID></Owner></AccessControlPolicy>`)
+	_, err = requestACL(httptest.NewRequest("PUT", "/b?acl", nil), "tenant-1", "tenant-1", body)
+	require.ErrorAs(t, err, &ae)
+	assert.Equal(t, ErrAccessDenied, ae.code)
+}
+
+func TestAccessControlPolicy_RoundTrip(t *testing.T) {
+	w := httptest.NewRecorder()
+	renderACL(w, &auth.ACL{Owner: "tenant-1", Grants: []auth.ACLGrant{
+		{TenantID: "tenant-1", Permission: auth.ACLFullControl},
+		{Group: auth.GroupAllUsers, Permission: auth.ACLRead},
+	}})
+	require.Equal(t, http.StatusOK, w.Code)
+	body := w.Body.String()
+	assert.Contains(t, body, `xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance"`)
+	assert.Contains(t, body, `xsi:type="CanonicalUser"`)
+	assert.Contains(t, body, `xsi:type="Group"`)
+
+	// What GetObjectAcl returns is accepted by PutObjectAcl unchanged.
+	acl, err := requestACL(httptest.NewRequest("PUT", "/b/k?acl", nil), "tenant-1", "tenant-1",
+		[]byte(strings.TrimPrefix(body, xml.Header)))
+	require.NoError(t, err)
+	assert.True(t, acl.IsPublic())
+	assert.True(t, acl.Allows("tenant-1", auth.ACLWrite, false))
+}
+
+func TestPutBucketAcl_PublicReadSetsVisibility(t *testing.T) {
+	s, mock := newPolicyTestServer(t)
+	mock.ExpectQuery(`SELECT visibility, acl`).
+		WithArgs("tenant-1", "shared").
+		WillReturnRows(sqlmock.NewRows(bucketAccessColumns).AddRow("private", nil, "", nil))
+	mock.ExpectQuery(`FROM tenant_quotas`).
+		WillReturnRows(sqlmock.NewRows([]string{"tier"}).AddRow("performance"))
+	mock.ExpectExec(`UPDATE buckets SET acl = \$3, visibility = \$4`).
+		WithArgs("tenant-1", "shared", []byte(nil), "public-read").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	ctx := tenant.WithTenant(context.Background(), &tenant.Tenant{ID: "tenant-1"})
+	r := httptest.NewRequest("PUT", "/shared?acl", nil).WithContext(ctx)
+	r.Header.Set("x-amz-acl", "public-read")
+	w := httptest.NewRecorder()
+	s.handlePutBucketAcl(w, r, &S3Request{Bucket: "shared"})
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPutBucketAcl_BlockPublicAcls(t *testing.T) {
+	s, mock := newPolicyTestServer(t)
+	mock.ExpectQuery(`SELECT visibility, acl`).
+		WillReturnRows(sqlmock.NewRows(bucketAccessColumns).
+			AddRow("private", nil, "", []byte(`{"block_public_acls":true}`)))
+
+	ctx := tenant.WithTenant(context.Background(), &tenant.Tenant{ID: "tenant-1"})
+	r := httptest.NewRequest("PUT", "/shared?acl", nil).WithContext(ctx)
+	r.Header.Set("x-amz-acl", "public-read")
+	w := httptest.NewRecorder()
+	s.handlePutBucketAcl(w, r, &S3Request{Bucket: "shared"})
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPutObjectAcl_GrantsTenant(t *testing.T) {
+	s, mock := newPolicyTestServer(t)
+	mock.ExpectQuery(`SELECT visibility, acl`).
+		WillReturnRows(sqlmock.NewRows(bucketAccessColumns).AddRow("private", nil, "", nil))
+	mock.ExpectQuery(`SELECT acl FROM object_head_cache`).
+		WithArgs("tenant-1", "shared", "report.pdf").
+		WillReturnRows(sqlmock.NewRows([]string{"acl"}).AddRow(nil))
+	mock.ExpectQuery(`SELECT EXISTS \(SELECT 1 FROM tenants`).
+		WithArgs("tenant-2").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectExec(`UPDATE object_head_cache SET acl = \$4`).
+		WithArgs("tenant-1", "shared", "report.pdf",
+			[]byte(`{"owner":"tenant-1","grants":[{"id":"tenant-2","perm":"READ"}]}`)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	ctx := tenant.WithTenant(context.Background(), &tenant.Tenant{ID: "tenant-1"})
+	r := httptest.NewRequest("PUT", "/shared/report.pdf?acl", nil).WithContext(ctx)
+	r.Header.Set("x-amz-grant-read", `id="tenant-2"`)
+	w := httptest.NewRecorder()
+	s.handlePutObjectAcl(w, r, &S3Request{Bucket: "shared", Object: "report.pdf"})
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPutObjectAcl_UnknownGrantee(t *testing.T) {
+	s, mock := newPolicyTestServer(t)
+	mock.ExpectQuery(`SELECT visibility, acl`).
+		WillReturnRows(sqlmock.NewRows(bucketAccessColumns).AddRow("private", nil, "", nil))
+	mock.ExpectQuery(`SELECT acl FROM object_head_cache`).
+		WillReturnRows(sqlmock.NewRows([]string{"acl"}).AddRow(nil))
+	mock.ExpectQuery(`SELECT EXISTS \(SELECT 1 FROM tenants`).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+
+	ctx := tenant.WithTenant(context.Background(), &tenant.Tenant{ID: "tenant-1"})
+	r := httptest.NewRequest("PUT", "/shared/report.pdf?acl", nil).WithContext(ctx)
+	r.Header.Set("x-amz-grant-read", `id="nobody"`)
+	w := httptest.NewRecorder()
+	s.handlePutObjectAcl(w, r, &S3Request{Bucket: "shared", Object: "report.pdf"})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "<Code>"+ErrInvalidArgument+"</Code>")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetObjectAcl_DefaultsToPrivate(t *testing.T) {
+	s, mock := newPolicyTestServer(t)
+	mock.ExpectQuery(`SELECT acl FROM object_head_cache`).
+		WillReturnRows(sqlmock.NewRows([]string{"acl"}).AddRow(nil))
+
+	ctx := tenant.WithTenant(context.Background(), &tenant.Tenant{ID: "tenant-1"})
+	r := httptest.NewRequest("GET", "/shared/k?acl", nil).WithContext(ctx)
+	w := httptest.NewRecorder()
+	s.handleGetObjectAcl(w, r, &S3Request{Bucket: "shared", Object: "k"})
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var doc AccessControlPolicy
+	require.NoError(t, xml.Unmarshal(w.Body.Bytes(), &doc))
+	require.NotNil(t, doc.Owner)
+	assert.Equal(t, "tenant-1", doc.Owner.ID)
+	require.Len(t, doc.AccessControlList, 1)
+	assert.Equal(t, auth.ACLFullControl, doc.AccessControlList[0].Permission)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	r = httptest.NewRequest("GET", "/shared/k?acl&versionId=v1", nil).WithContext(ctx)
+	w = httptest.NewRecorder()
+	s.handleGetObjectAcl(w, r, &S3Request{Bucket: "shared", Object: "k"})
+	assert.Equal(t, http.StatusNotImplemented, w.Code)
+}
+
+func TestPutBucketOwnershipControls(t *testing.T) {
+	body := `<OwnershipControls><Rule><ObjectOwnership>BucketOwnerEnforced</ObjectOwnership></Rule></OwnershipControls>`
+	ctx := tenant.WithTenant(context.Background(), &tenant.Tenant{ID: "tenant-1"})
+
+	// Grants must be removed before ACLs are disabled.
+	s, mock := newPolicyTestServer(t)
+	mock.ExpectQuery(`SELECT visibility, acl`).
+		WillReturnRows(sqlmock.NewRows(bucketAccessColumns).AddRow("public-read", nil, "", nil))
+	r := httptest.NewRequest("PUT", "/shared?ownershipControls", strings.NewReader(body)).WithContext(ctx)
+	w := httptest.NewRecorder()
+	s.handlePutBucketOwnershipControls(w, r, &S3Request{Bucket: "shared"})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "<Code>"+ErrBucketAclWithOwnershipEnforced+"</Code>")
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	s, mock = newPolicyTestServer(t)
+	mock.ExpectQuery(`SELECT visibility, acl`).
+		WillReturnRows(sqlmock.NewRows(bucketAccessColumns).AddRow("private", nil, "", nil))
+	mock.ExpectExec(`UPDATE buckets SET object_ownership`).
+		WithArgs("tenant-1", "shared", auth.OwnershipBucketOwnerEnforced).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	r = httptest.NewRequest("PUT", "/shared?ownershipControls", strings.NewReader(body)).WithContext(ctx)
+	w = httptest.NewRecorder()
+	s.handlePutBucketOwnershipControls(w, r, &S3Request{Bucket: "shared"})
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestObjectACLForWrite_Enforced(t *testing.T) {
+	s, mock := newPolicyTestServer(t)
+	mock.ExpectQuery(`SELECT visibility, acl`).
+		WillReturnRows(sqlmock.NewRows(bucketAccessColumns).AddRow("private", nil, auth.OwnershipBucketOwnerEnforced, nil))
+
+	r := httptest.NewRequest("PUT", "/shared/k", nil)
+	r.Header.Set("x-amz-acl", "public-read")
+	_, err := objectACLForWrite(r.Context(), s.db, r, "tenant-1", "shared")
+	var ae *aclRequestError
+	require.ErrorAs(t, err, &ae)
+	assert.Equal(t, ErrAccessControlListNotSupported, ae.code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+
+	// The owner writing without an ACL never looks the bucket up.
+	r = httptest.NewRequest("PUT", "/shared/k", nil)
+	raw, err := objectACLForWrite(r.Context(), s.db, r, "tenant-1", "shared")
+	require.NoError(t, err)
+	assert.Nil(t, raw)
+}
+
+func TestObjectACLForWrite_CrossTenantWriterOwns(t *testing.T) {
+	s, mock := newPolicyTestServer(t)
+	mock.ExpectQuery(`SELECT visibility, acl`).
+		WithArgs("owner", "shared").
+		WillReturnRows(sqlmock.NewRows(bucketAccessColumns).AddRow("private", nil, "", nil))
+
+	r := httptest.NewRequest("PUT", "/shared/k", nil)
+	r = r.WithContext(withRequester(r.Context(), "writer"))
+	raw, err := objectACLForWrite(r.Context(), s.db, r, "owner", "shared")
+	require.NoError(t, err)
+	acl, err := auth.ParseACL(raw)
+	require.NoError(t, err)
+	assert.Equal(t, "writer", acl.Owner)
+	assert.False(t, acl.Allows("owner", auth.ACLRead, false))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPublicAccessBlock_PutGet(t *testing.T) {
+	s, mock := newPolicyTestServer(t)
+	ctx := tenant.WithTenant(context.Background(), &tenant.Tenant{ID: "tenant-1"})
+	stored := []byte(`{"block_public_acls":true,"ignore_public_acls":false,"block_public_policy":true,"restrict_public_buckets":false}`)
+
+	mock.ExpectExec(`UPDATE buckets SET public_access_block`).
+		WithArgs("tenant-1", "shared", stored).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	body := `<PublicAccessBlockConfiguration><BlockPublicAcls>true</BlockPublicAcls><BlockPublicPolicy>true</BlockPublicPolicy></PublicAccessBlockConfiguration>`
+	r := httptest.NewRequest("PUT", "/shared?publicAccessBlock", strings.NewReader(body)).WithContext(ctx)
+	w := httptest.NewRecorder()
+	s.handlePutPublicAccessBlock(w, r, &S3Request{Bucket: "shared"})
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	mock.ExpectQuery(`SELECT public_access_block FROM buckets`).
+		WillReturnRows(sqlmock.NewRows([]string{"public_access_block"}).AddRow(stored))
+	r = httptest.NewRequest("GET", "/shared?publicAccessBlock", nil).WithContext(ctx)
+	w = httptest.NewRecorder()
+	s.handleGetPublicAccessBlock(w, r, &S3Request{Bucket: "shared"})
+	require.Equal(t, http.StatusOK, w.Code)
+	var config PublicAccessBlockConfiguration
+	require.NoError(t, xml.Unmarshal(w.Body.Bytes(), &config))
+	assert.True(t, config.BlockPublicAcls)
+	assert.True(t, config.BlockPublicPolicy)
+	assert.False(t, config.IgnorePublicAcls)
+
+	mock.ExpectQuery(`SELECT public_access_block FROM buckets`).
+		WillReturnRows(sqlmock.NewRows([]string{"public_access_block"}).AddRow(nil))
+	w = httptest.NewRecorder()
+	s.handleGetPublicAccessBlock(w, r, &S3Request{Bucket: "shared"})
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Contains(t, w.Body.String(), ErrNoSuchPublicAccessBlock)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPutBucketPolicy_BlockPublicPolicy(t *testing.T) {
+	s, mock := newPolicyTestServer(t)
+	mock.ExpectQuery(`SELECT visibility, acl`).
+		WillReturnRows(sqlmock.NewRows(bucketAccessColumns).
+			AddRow("private", nil, "", []byte(`{"block_public_policy":true}`)))
+
+	public := `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Principal":"*","Action":"s3:GetObject","Resource":"arn:aws:s3:::shared/*"}]}`
+	ctx := tenant.WithTenant(context.Background(), &tenant.Tenant{ID: "tenant-1"})
+	r := httptest.NewRequest("PUT", "/shared?policy", bytes.NewReader([]byte(public))).WithContext(ctx)
+	w := httptest.NewRecorder()
+	s.handlePutBucketPolicy(w, r, &S3Request{Bucket: "shared"})
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestResolveBucketOwner(t *testing.T) {
+	s, mock := newPolicyTestServer(t)
+	s.testMode = false
+	r := httptest.NewRequest("GET", "/shared/k", nil)
+
+	mock.ExpectQuery(`SELECT tenant_id FROM buckets WHERE name = \$1`).
+		WithArgs("shared", "tenant-2").
+		WillReturnRows(sqlmock.NewRows([]string{"tenant_id"}).AddRow("tenant-1"))
+	owner, err := s.resolveBucketOwner(r, &S3Request{Bucket: "shared", Object: "k", Operation: "GetObject"}, "tenant-2")
+	require.NoError(t, err)
+	assert.Equal(t, "tenant-1", owner)
+
+	// A name several tenants use stays with the signer.
+	mock.ExpectQuery(`SELECT tenant_id FROM buckets WHERE name = \$1`).
+		WillReturnRows(sqlmock.NewRows([]string{"tenant_id"}).AddRow("tenant-1").AddRow("tenant-3"))
+	owner, err = s.resolveBucketOwner(r, &S3Request{Bucket: "shared", Object: "k", Operation: "GetObject"}, "tenant-2")
+	require.NoError(t, err)
+	assert.Equal(t, "tenant-2", owner)
+
+	// Bucket configuration is never resolved across tenants.
+	owner, err = s.resolveBucketOwner(r, &S3Request{Bucket: "shared", Operation: "PutBucketPolicy"}, "tenant-2")
+	require.NoError(t, err)
+	assert.Equal(t, "tenant-2", owner)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestACLAllows_CrossTenant(t *testing.T) {
+	s, mock := newPolicyTestServer(t)
+	ctx := context.Background()
+	grantRead := []byte(`{"owner":"tenant-1","grants":[{"id":"tenant-2","perm":"READ"}]}`)
+
+	// An object grant lets tenant-2 read that object...
+	mock.ExpectQuery(`SELECT visibility, acl`).
+		WithArgs("tenant-1", "shared").
+		WillReturnRows(sqlmock.NewRows(bucketAccessColumns).AddRow("private", nil, "", nil))
+	mock.ExpectQuery(`SELECT acl FROM object_head_cache`).
+		WithArgs("tenant-1", "shared", "report.pdf").
+		WillReturnRows(sqlmock.NewRows([]string{"acl"}).AddRow(grantRead))
+	ok, err := s.aclAllows(ctx, &S3Request{Bucket: "shared", Object: "report.pdf", Operation: "GetObject"}, "tenant-1", "tenant-2")
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	// ...but not list the bucket, and not when ACLs are disabled.
+	mock.ExpectQuery(`SELECT visibility, acl`).
+		WillReturnRows(sqlmock.NewRows(bucketAccessColumns).AddRow("public-read", nil, "", nil))
+	ok, err = s.aclAllows(ctx, &S3Request{Bucket: "shared", Operation: "ListObjects"}, "tenant-1", "tenant-2")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	mock.ExpectQuery(`SELECT visibility, acl`).
+		WillReturnRows(sqlmock.NewRows(bucketAccessColumns).AddRow("private", nil, auth.OwnershipBucketOwnerEnforced, nil))
+	mock.ExpectQuery(`SELECT acl FROM object_head_cache`).
+		WillReturnRows(sqlmock.NewRows([]string{"acl"}).AddRow(grantRead))
+	ok, err = s.aclAllows(ctx, &S3Request{Bucket: "shared", Object: "report.pdf", Operation: "GetObject"}, "tenant-1", "tenant-2")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	// A bucket WRITE grant covers uploads.
+	mock.ExpectQuery(`SELECT visibility, acl`).
+		WillReturnRows(sqlmock.NewRows(bucketAccessColumns).
+			AddRow("private", []byte(`{"owner":"tenant-1","grants":[{"id":"tenant-2","perm":"WRITE"}]}`), "", nil))
+	ok, err = s.aclAllows(ctx, &S3Request{Bucket: "shared", Object: "in/new.csv", Operation: "PutObject"}, "tenant-1", "tenant-2")
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	mock.ExpectQuery(`SELECT visibility, acl`).WillReturnError(sql.ErrNoRows)
+	ok, err = s.aclAllows(ctx, &S3Request{Bucket: "gone", Operation: "ListObjects"}, "tenant-1", "tenant-2")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCDNSiteAllows_ObjectACL(t *testing.T) {
+	r := httptest.NewRequest("GET", "/cdn/slug/shared/logo.png", nil)
+	site := &cdnSite{bucket: "shared", visibility: "private"}
+	public := &cdnObject{acl: &auth.ACL{Owner: "tenant-1", Grants: []auth.ACLGrant{
+		{Group: auth.GroupAllUsers, Permission: auth.ACLRead},
+	}}}
+	assert.True(t, site.allows(r, "logo.png", public), "one public object in a private bucket")
+	assert.False(t, site.allows(r, "other.png", &cdnObject{}))
+
+	site.block.IgnorePublicAcls = true
+	assert.False(t, site.allows(r, "logo.png", public))
+
+	site = &cdnSite{bucket: "shared", visibility: "private", ownership: auth.OwnershipBucketOwnerEnforced}
+	assert.False(t, site.allows(r, "logo.png", public), "ACLs are disabled")
+}
+
+func TestDetermineOperation_ACLs(t *testing.T) {
+	tests := []struct {
+		method, object, query, want string
+	}{
+		{"GET", "", "acl", "GetBucketAcl"},
+		{"PUT", "", "acl", "PutBucketAcl"},
+		{"GET", "k", "acl", "GetObjectAcl"},
+		{"PUT", "k", "acl", "PutObjectAcl"},
+		{"GET", "", "ownershipControls", "GetBucketOwnershipControls"},
+		{"PUT", "", "ownershipControls", "PutBucketOwnershipControls"},
+		{"DELETE", "", "ownershipControls", "DeleteBucketOwnershipControls"},
+		{"GET", "", "publicAccessBlock", "GetPublicAccessBlock"},
+		{"PUT", "", "publicAccessBlock", "PutPublicAccessBlock"},
+		{"DELETE", "", "publicAccessBlock", "DeletePublicAccessBlock"},
+	}
+	parser := NewS3Parser(zap.NewNop())
+	for _, tt := range tests {
+		req := &S3Request{Bucket: "b", Object: tt.object, Query: map[string]string{tt.query: ""}}
+		parser.determineOperation(req, tt.method)
+		assert.Equal(t, tt.want, req.Operation, tt.method+" "+tt.object+"?"+tt.query)
+	}
+}
@@ -128,12 +128,27 @@ func (s *Server) handleDeleteObjects(w http.ResponseWriter, r *http.Request, req
 			continue
 		}
 
+		// Hold the key lock across the byte delete and the row delete, as
+		// single-key HandleDelete does.
+		kl, lockErr := lockKeyForWrite(r.Context(), s.db, t.ID, bucket, key)
+		if lockErr != nil {
+			s.logger.Error("batch delete: lock key failed",
+				zap.Error(lockErr), zap.String("key", key))
+			result.Errors = append(result.Errors, DeleteError{
+				Key:     key,
+				Code:    ErrInternalError,
+				Message: "Internal error while deleting",
+			})
+			continue
+		}
+		q := kl.querier(s.db)
+
 		// Chunked objects live under _chunks/, not container/key: their
 		// delete decrements GCI ref counts (mirrors single-key HandleDelete)
 		// so dedup GC can reclaim the physical chunks.
 		var isChunked bool
 		if s.db != nil {
-			_ = s.db.QueryRowContext(r.Context(),
+			_ = q.QueryRowContext(r.Context(),
 				`SELECT is_chunked FROM object_head_cache WHERE tenant_id = $1 AND bucket = $2 AND object_key = $3`,
 				t.ID, bucket, key).Scan(&isChunked)
 		}
@@ -141,7 +156,11 @@ func (s *Server) handleDeleteObjects(w http.ResponseWriter, r *http.Request, req
 		var delErr error
 		chunkedHandled := false
 		if isChunked && s.gci != nil {
-			delErr = s.gci.DeleteObjectChunks(r.Context(), t.ID, bucket, key)
+			if kl != nil {
+				delErr = s.gci.DeleteObjectChunksTx(r.Context(), kl.tx, t.ID, bucket, key)
+			} else {
+				delErr = s.gci.DeleteObjectChunks(r.Context(), t.ID, bucket, key)
+			}
 			chunkedHandled = true
 		}
 		if !chunkedHandled {
@@ -153,6 +172,7 @@ func (s *Server) handleDeleteObjects(w http.ResponseWriter, r *http.Request, req
 		}
 
 		if delErr != nil {
+			kl.release()
 			s.logger.Error("batch delete: delete failed",
 				zap.Error(delErr),
 				zap.String("container", container),
@@ -169,11 +189,28 @@ func (s *Server) handleDeleteObjects(w http.ResponseWriter, r *http.Request, req
 		// release exactly the bytes it held (atomic via RETURNING, WP-1).
 		if s.db != nil {
 			var deletedSize int64
-			cacheErr := s.db.QueryRowContext(r.Context(), `
+			cacheErr := q.QueryRowContext(r.Context(), `
 				DELETE FROM object_head_cache
 				WHERE tenant_id = $1 AND bucket = $2 AND object_key = $3
 				RETURNING size_bytes
 			`, t.ID, bucket, key).Scan(&deletedSize)
+			if cacheErr == nil {
+				if qErr := queueReplicationDelete(r.Context(), q, t.ID, bucket, key); qErr != nil {
+					s.logger.Error("batch delete: queue replicated delete failed",
+						zap.Error(qErr), zap.String("key", key))
+				}
+			}
+			if err := kl.commit(); err != nil {
+				kl.release()
+				s.logger.Error("batch delete: commit failed",
+					zap.Error(err), zap.String("key", key))
+				result.Errors = append(result.Errors, DeleteError{
+					Key:     key,
+					Code:    ErrInternalError,
+					Message: "Internal error while deleting",
+				})
+				continue
+			}
 			if cacheErr == nil && deletedSize > 0 {
 				ctx, cancel := quotaCtx(r)
 				s.releaseQuota(ctx, t.ID, deletedSize)
@@ -0,0 +1,219 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/FairForge/vaultaire/internal/crypto"
+	"github.com/FairForge/vaultaire/internal/tenant"
+	"go.uber.org/zap"
+)
+
+const maxEncryptionBodyBytes = 8192
+
+// ServerSideEncryptionConfiguration is the S3 XML document for GET/PUT
+// ?encryption. The default is stored as buckets.sse_enabled (AES256) or
+// buckets.sse_kms_key_id (aws:kms).
+type ServerSideEncryptionConfiguration struct {
+	XMLName xml.Name                   `xml:"ServerSideEncryptionConfiguration"`
+	Xmlns   string                     `xml:"xmlns,attr,omitempty"`
+	Rules   []ServerSideEncryptionRule `xml:"Rule"`
+}
+
+// ServerSideEncryptionRule holds the default encryption of new objects.
+type ServerSideEncryptionRule struct {
+	ApplyServerSideEncryptionByDefault *ServerSideEncryptionByDefault `xml:"ApplyServerSideEncryptionByDefault"`
+	BucketKeyEnabled                   bool                           `xml:"BucketKeyEnabled"`
+}
+
+// ServerSideEncryptionByDefault names the algorithm and, for aws:kms, the
+// key; without KMSMasterKeyID the server's default KMS key is used.
+type ServerSideEncryptionByDefault struct {
+	SSEAlgorithm   string `xml:"SSEAlgorithm"`
+	KMSMasterKeyID string `xml:"KMSMasterKeyID,omitempty"`
+}
+
+func (s *Server) handleGetBucketEncryption(w http.ResponseWriter, r *http.Request, req *S3Request) {
+	t, err := tenant.FromContext(r.Context())
+	if err != nil || t == nil {
+		WriteS3Error(w, ErrAccessDenied, r.URL.Path, generateRequestID())
+		return
+	}
+
+	if s.db == nil {
+		WriteS3Error(w, ErrNoSuchEncryptionConfiguration, r.URL.Path, generateRequestID())
+		return
+	}
+
+	var enabled bool
+	var kmsKey sql.NullString
+	err = s.db.QueryRowContext(r.Context(),
+		"SELECT sse_enabled, sse_kms_key_id FROM buckets WHERE tenant_id = $1 AND name = $2",
+		t.ID, req.Bucket).Scan(&enabled, &kmsKey)
+	if err == sql.ErrNoRows {
+		s.writeNoSuchBucket(w, r, t.ID, req.Bucket)
+		return
+	}
+	if err != nil {
+		s.logger.Error("query bucket encryption", zap.Error(err))
+		WriteS3Error(w, ErrInternalError, r.URL.Path, generateRequestID())
+		return
+	}
+
+	var def ServerSideEncryptionByDefault
+	switch {
+	case kmsKey.Valid:
+		def = ServerSideEncryptionByDefault{SSEAlgorithm: crypto.SSEKMSAlgorithm, KMSMasterKeyID: kmsKey.String}
+	case enabled:
+		def = ServerSideEncryptionByDefault{SSEAlgorithm: "AES256"}
+	default:
+		WriteS3Error(w, ErrNoSuchEncryptionConfiguration, r.URL.Path, generateRequestID())
+		return
+	}
+
+	config := ServerSideEncryptionConfiguration{
+		Xmlns: "http://s3.amazonaws.com/doc/2006-03-01/",
+		Rules: []ServerSideEncryptionRule{{ApplyServerSideEncryptionByDefault: &def}},
+	}
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte(xml.Header))
+	_ = xml.NewEncoder(w).Encode(config)
+}
+
+// validateBucketEncryption checks a PUT ?encryption document against what
+// this server can apply and returns the rule's default.
+func (s *Server) validateBucketEncryption(config *ServerSideEncryptionConfiguration) (*ServerSideEncryptionByDefault, error) {
+	if len(config.Rules) != 1 || config.Rules[0].ApplyServerSideEncryptionByDefault == nil {
+		return nil, fmt.Errorf("the configuration must contain exactly one Rule with ApplyServerSideEncryptionByDefault")
+	}
+	def := config.Rules[0].ApplyServerSideEncryptionByDefault
+	switch def.SSEAlgorithm {
+	case "AES256":
+		if def.KMSMasterKeyID != "" {
+			return nil, fmt.Errorf("KMSMasterKeyID is only allowed with SSEAlgorithm aws:kms")
+		}
+		if s.sseService == nil {
+			return nil, fmt.Errorf("SSE-S3 is not configured on this server")
+		}
+	case crypto.SSEKMSAlgorithm:
+		if s.kms.provider == nil {
+			return nil, fmt.Errorf("SSE-KMS is not configured on this server")
+		}
+		def.KMSMasterKeyID = normalizeKMSKeyID(def.KMSMasterKeyID)
+		if def.KMSMasterKeyID == "" && s.kms.defaultKeyID == "" {
+			return nil, fmt.Errorf("KMSMasterKeyID is required: the server has no default KMS key")
+		}
+	default:
+		return nil, fmt.Errorf("unsupported SSEAlgorithm %q; use AES256 or aws:kms", def.SSEAlgorithm)
+	}
+	return def, nil
+}
+
+func (s *Server) handlePutBucketEncryption(w http.ResponseWriter, r *http.Request, req *S3Request) {
+	t, err := tenant.FromContext(r.Context())
+	if err != nil || t == nil {
+		WriteS3Error(w, ErrAccessDenied, r.URL.Path, generateRequestID())
+		return
+	}
+
+	if s.db == nil {
+		WriteS3Error(w, ErrInternalError, r.URL.Path, generateRequestID())
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxEncryptionBodyBytes))
+	if err != nil {
+		WriteS3Error(w, bodyReadErrorCode(err), r.URL.Path, generateRequestID())
+		return
+	}
+
+	var config ServerSideEncryptionConfiguration
+	if err := xml.Unmarshal(body, &config); err != nil {
+		WriteS3Error(w, ErrMalformedXML, r.URL.Path, generateRequestID())
+		return
+	}
+	def, err := s.validateBucketEncryption(&config)
+	if err != nil {
+		WriteS3ErrorWithContext(w, ErrInvalidArgument, r.URL.Path, generateRequestID(),
+			WithSuggestion(err.Error()))
+		return
+	}
+
+	// A named key must be usable now, so a typo or a revoked key fails here
+	// rather than on every later PUT.
+	sseEnabled := def.SSEAlgorithm == "AES256"
+	var kmsKeyID interface{}
+	if def.SSEAlgorithm == crypto.SSEKMSAlgorithm {
+		kmsKeyID = def.KMSMasterKeyID
+		probeKey := def.KMSMasterKeyID
+		if probeKey == "" {
+			probeKey = s.kms.defaultKeyID
+		}
+		encCtx := crypto.EncryptionContext{kmsContextARN: "arn:aws:s3:::" + req.Bucket}
+		dataKey, _, kmsErr := s.kms.provider.GenerateDataKey(r.Context(), probeKey, encCtx)
+		if kmsErr != nil {
+			writeSSEStreamError(w, r, s.logger, &kmsProviderError{err: kmsErr})
+			return
+		}
+		zeroKey(dataKey)
+	}
+
+	result, err := s.db.ExecContext(r.Context(),
+		`UPDATE buckets SET sse_enabled = $3, sse_kms_key_id = $4, updated_at = NOW()
+		 WHERE tenant_id = $1 AND name = $2`,
+		t.ID, req.Bucket, sseEnabled, kmsKeyID)
+	if err != nil {
+		s.logger.Error("update bucket encryption", zap.Error(err))
+		WriteS3Error(w, ErrInternalError, r.URL.Path, generateRequestID())
+		return
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		s.writeNoSuchBucket(w, r, t.ID, req.Bucket)
+		return
+	}
+
+	s.logger.Info("bucket encryption updated",
+		zap.String("tenant_id", t.ID),
+		zap.String("bucket", req.Bucket),
+		zap.String("algorithm", def.SSEAlgorithm),
+		zap.String("kms_key_id", def.KMSMasterKeyID))
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *Server) handleDeleteBucketEncryption(w http.ResponseWriter, r *http.Request, req *S3Request) {
+	t, err := tenant.FromContext(r.Context())
+	if err != nil || t == nil {
+		WriteS3Error(w, ErrAccessDenied, r.URL.Path, generateRequestID())
+		return
+	}
+
+	if s.db == nil {
+		WriteS3Error(w, ErrInternalError, r.URL.Path, generateRequestID())
+		return
+	}
+
+	result, err := s.db.ExecContext(r.Context(),
+		`UPDATE buckets SET sse_enabled = FALSE, sse_kms_key_id = NULL, updated_at = NOW()
+		 WHERE tenant_id = $1 AND name = $2`,
+		t.ID, req.Bucket)
+	if err != nil {
+		s.logger.Error("delete bucket encryption", zap.Error(err))
+		WriteS3Error(w, ErrInternalError, r.URL.Path, generateRequestID())
+		return
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		s.writeNoSuchBucket(w, r, t.ID, req.Bucket)
+		return
+	}
+
+	s.logger.Info("bucket encryption deleted",
+		zap.String("tenant_id", t.ID),
+		zap.String("bucket", req.Bucket))
+
+	w.WriteHeader(http.StatusNoContent)
+}
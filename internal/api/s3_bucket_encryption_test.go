@@ -0,0 +1,98 @@
+package api
+
+import (
+	"context"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/FairForge/vaultaire/internal/tenant"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testKMSEncryptionConfig = `<ServerSideEncryptionConfiguration xmlns="http://s3.amazonaws.com/doc/2006-03-01/">
+  <Rule>
+    <ApplyServerSideEncryptionByDefault>
+      <SSEAlgorithm>aws:kms</SSEAlgorithm>
+      <KMSMasterKeyID>app-key</KMSMasterKeyID>
+    </ApplyServerSideEncryptionByDefault>
+  </Rule>
+</ServerSideEncryptionConfiguration>`
+
+func TestBucketEncryption_PutGetDelete(t *testing.T) {
+	s, mock := newPolicyTestServer(t)
+	s.kms, _ = newTestKMS(t, "app-key")
+	ctx := tenant.WithTenant(context.Background(), &tenant.Tenant{ID: "tenant-1"})
+	s3Req := &S3Request{Bucket: "uploads"}
+
+	mock.ExpectExec(`UPDATE buckets SET sse_enabled = \$3, sse_kms_key_id = \$4`).
+		WithArgs("tenant-1", "uploads", false, "app-key").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	r := httptest.NewRequest("PUT", "/uploads?encryption", strings.NewReader(testKMSEncryptionConfig)).WithContext(ctx)
+	w := httptest.NewRecorder()
+	s.handlePutBucketEncryption(w, r, s3Req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	mock.ExpectQuery(`SELECT sse_enabled, sse_kms_key_id FROM buckets`).
+		WillReturnRows(sqlmock.NewRows([]string{"sse_enabled", "sse_kms_key_id"}).AddRow(false, "app-key"))
+	r = httptest.NewRequest("GET", "/uploads?encryption", nil).WithContext(ctx)
+	w = httptest.NewRecorder()
+	s.handleGetBucketEncryption(w, r, s3Req)
+	require.Equal(t, http.StatusOK, w.Code)
+	var config ServerSideEncryptionConfiguration
+	require.NoError(t, xml.Unmarshal(w.Body.Bytes(), &config))
+	require.Len(t, config.Rules, 1)
+	assert.Equal(t, "aws:kms", config.Rules[0].ApplyServerSideEncryptionByDefault.SSEAlgorithm)
+	assert.Equal(t, "app-key", config.Rules[0].ApplyServerSideEncryptionByDefault.KMSMasterKeyID)
+
+	mock.ExpectExec(`UPDATE buckets SET sse_enabled = FALSE, sse_kms_key_id = NULL`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	r = httptest.NewRequest("DELETE", "/uploads?encryption", nil).WithContext(ctx)
+	w = httptest.NewRecorder()
+	s.handleDeleteBucketEncryption(w, r, s3Req)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	mock.ExpectQuery(`SELECT sse_enabled, sse_kms_key_id FROM buckets`).
+		WillReturnRows(sqlmock.NewRows([]string{"sse_enabled", "sse_kms_key_id"}).AddRow(false, nil))
+	r = httptest.NewRequest("GET", "/uploads?encryption", nil).WithContext(ctx)
+	w = httptest.NewRecorder()
+	s.handleGetBucketEncryption(w, r, s3Req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Contains(t, w.Body.String(), ErrNoSuchEncryptionConfiguration)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestBucketEncryption_PutRejectsUnusableKey(t *testing.T) {
+	s, mock := newPolicyTestServer(t)
+	ctx := tenant.WithTenant(context.Background(), &tenant.Tenant{ID: "tenant-1"})
+	s3Req := &S3Request{Bucket: "uploads"}
+
+	// SSE-KMS not configured.
+	r := httptest.NewRequest("PUT", "/uploads?encryption", strings.NewReader(testKMSEncryptionConfig)).WithContext(ctx)
+	w := httptest.NewRecorder()
+	s.handlePutBucketEncryption(w, r, s3Req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), ErrInvalidArgument)
+
+	// A key the provider does not know.
+	s.kms, _ = newTestKMS(t, "other-key")
+	r = httptest.NewRequest("PUT", "/uploads?encryption", strings.NewReader(testKMSEncryptionConfig)).WithContext(ctx)
+	w = httptest.NewRecorder()
+	s.handlePutBucketEncryption(w, r, s3Req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), ErrKMSNotFound)
+
+	// The algorithm must be one the server applies.
+	r = httptest.NewRequest("PUT", "/uploads?encryption", strings.NewReader(
+		strings.Replace(testKMSEncryptionConfig, "aws:kms", "aws:kms:dsse", 1))).WithContext(ctx)
+	w = httptest.NewRecorder()
+	s.handlePutBucketEncryption(w, r, s3Req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,319 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/FairForge/vaultaire/internal/auth"
+	"github.com/FairForge/vaultaire/internal/tenant"
+	"go.uber.org/zap"
+)
+
+// PolicyStatus is the response body for GET ?policyStatus.
+type PolicyStatus struct {
+	XMLName  xml.Name `xml:"PolicyStatus"`
+	Xmlns    string   `xml:"xmlns,attr,omitempty"`
+	IsPublic bool     `xml:"IsPublic"`
+}
+
+// errStoredPolicyInvalid marks a stored policy that no longer validates.
+var errStoredPolicyInvalid = errors.New("stored bucket policy is invalid")
+
+// bucketPolicyOps are the operations the tenant's primary key may always
+// perform, whatever the policy says, so a mistaken Deny can be undone.
+var bucketPolicyOps = map[string]bool{
+	"GetBucketPolicy":       true,
+	"PutBucketPolicy":       true,
+	"DeleteBucketPolicy":    true,
+	"GetBucketPolicyStatus": true,
+}
+
+// loadBucketPolicy returns the raw and parsed policy attached to a bucket.
+// Both are empty/nil when the bucket has no policy; sql.ErrNoRows means the
+// bucket itself does not exist.
+func loadBucketPolicy(ctx context.Context, db *sql.DB, tenantID, bucket string) (string, *auth.BucketPolicy, error) {
+	var raw sql.NullString
+	err := db.QueryRowContext(ctx,
+		`SELECT policy FROM buckets WHERE tenant_id = $1 AND name = $2`,
+		tenantID, bucket).Scan(&raw)
+	if err != nil {
+		return "", nil, err
+	}
+	if !raw.Valid || raw.String == "" {
+		return "", nil, nil
+	}
+	// Stored policies were validated on PUT; re-validate against the same
+	// bucket so a row edited by hand cannot widen its own resources.
+	policy, err := auth.ParseBucketPolicy([]byte(raw.String), bucket)
+	if err != nil {
+		return raw.String, nil, fmt.Errorf("%w: %v", errStoredPolicyInvalid, err)
+	}
+	return raw.String, policy, nil
+}
+
+// policyConditions collects the condition-key values a bucket policy can
+// test for this request.
+func policyConditions(r *http.Request) map[string]string {
+	conds := map[string]string{
+		auth.CondSourceIP:        extractClientIP(r),
+		auth.CondSecureTransport: strconv.FormatBool(isSecureTransport(r)),
+		auth.CondCurrentTime:     time.Now().UTC().Format(time.RFC3339),
+	}
+	q := r.URL.Query()
+	if q.Has("prefix") {
+		conds[auth.CondPrefix] = q.Get("prefix")
+	}
+	if q.Has("delimiter") {
+		conds[auth.CondDelimiter] = q.Get("delimiter")
+	}
+	return conds
+}
+
+// evaluateBucketPolicy runs the policy of bucketOwner's target bucket for a
+// request authenticated as tenantID (the owner itself, unless the bucket is
+// shared). It returns PolicyNoMatch when the bucket has no policy or does
+// not exist (the handler reports NoSuchBucket). A stored policy that no
+// longer parses denies, so a corrupt row fails closed.
+func (s *Server) evaluateBucketPolicy(r *http.Request, s3Req *S3Request, bucketOwner, tenantID string, scope *auth.KeyScope) (auth.PolicyDecision, error) {
+	if s.db == nil || s3Req.Bucket == "" || tenantID == "" {
+		return auth.PolicyNoMatch, nil
+	}
+	if scope != nil && scope.Primary && bucketOwner == tenantID && bucketPolicyOps[s3Req.Operation] {
+		return auth.PolicyNoMatch, nil
+	}
+
+	_, policy, err := loadBucketPolicy(r.Context(), s.db, bucketOwner, s3Req.Bucket)
+	if err == sql.ErrNoRows {
+		return auth.PolicyNoMatch, nil
+	}
+	if errors.Is(err, errStoredPolicyInvalid) {
+		s.logger.Error("stored bucket policy is invalid, denying request",
+			zap.String("tenant_id", tenantID),
+			zap.String("bucket", s3Req.Bucket),
+			zap.Error(err))
+		return auth.PolicyDeny, nil
+	}
+	if err != nil {
+		return auth.PolicyNoMatch, err
+	}
+	if policy == nil {
+		return auth.PolicyNoMatch, nil
+	}
+
+	principal := auth.PolicyPrincipalInfo{TenantID: tenantID}
+	if scope != nil {
+		principal.AccessKeyID = scope.AccessKeyID
+		principal.ParentKeyID = scope.ParentKeyID
+	}
+	decision := policy.Evaluate(auth.PolicyRequest{
+		Principal:  principal,
+		Action:     auth.S3PolicyAction(s3Req.Operation),
+		Resource:   auth.S3PolicyResource(s3Req.Bucket, s3Req.Object),
+		Conditions: policyConditions(r),
+	})
+
+	// RestrictPublicBuckets: a public policy grants nothing to other
+	// tenants.
+	if decision == auth.PolicyAllow && bucketOwner != tenantID && policy.IsPublic() {
+		access, err := loadBucketAccess(r.Context(), s.db, bucketOwner, s3Req.Bucket)
+		if err != nil {
+			return auth.PolicyNoMatch, err
+		}
+		if access.block.RestrictPublicBuckets {
+			return auth.PolicyNoMatch, nil
+		}
+	}
+	return decision, nil
+}
+
+func (s *Server) handleGetBucketPolicy(w http.ResponseWriter, r *http.Request, req *S3Request) {
+	t, err := tenant.FromContext(r.Context())
+	if err != nil || t == nil {
+		WriteS3Error(w, ErrAccessDenied, r.URL.Path, generateRequestID())
+		return
+	}
+
+	if s.db == nil {
+		WriteS3Error(w, ErrNoSuchBucketPolicy, r.URL.Path, generateRequestID())
+		return
+	}
+
+	var raw sql.NullString
+	err = s.db.QueryRowContext(r.Context(),
+		`SELECT policy FROM buckets WHERE tenant_id = $1 AND name = $2`,
+		t.ID, req.Bucket).Scan(&raw)
+	if err == sql.ErrNoRows {
+		s.writeNoSuchBucket(w, r, t.ID, req.Bucket)
+		return
+	}
+	if err != nil {
+		s.logger.Error("query bucket policy", zap.Error(err))
+		WriteS3Error(w, ErrInternalError, r.URL.Path, generateRequestID())
+		return
+	}
+	if !raw.Valid || raw.String == "" {
+		WriteS3Error(w, ErrNoSuchBucketPolicy, r.URL.Path, generateRequestID())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte(raw.String))
+}
+
+func (s *Server) handlePutBucketPolicy(w http.ResponseWriter, r *http.Request, req *S3Request) {
+	t, err := tenant.FromContext(r.Context())
+	if err != nil || t == nil {
+		WriteS3Error(w, ErrAccessDenied, r.URL.Path, generateRequestID())
+		return
+	}
+
+	if s.db == nil {
+		WriteS3Error(w, ErrInternalError, r.URL.Path, generateRequestID())
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, auth.MaxBucketPolicyBytes+1))
+	if err != nil {
+		WriteS3Error(w, bodyReadErrorCode(err), r.URL.Path, generateRequestID())
+		return
+	}
+	if len(body) > auth.MaxBucketPolicyBytes {
+		WriteS3ErrorWithContext(w, ErrMalformedPolicy, r.URL.Path, generateRequestID(),
+			WithSuggestion("Bucket policies are limited to 20 KB."))
+		return
+	}
+
+	policy, err := auth.ParseBucketPolicy(body, req.Bucket)
+	if err != nil {
+		WriteS3ErrorWithContext(w, ErrMalformedPolicy, r.URL.Path, generateRequestID(),
+			WithSuggestion(err.Error()))
+		return
+	}
+	if policy.IsPublic() {
+		access, err := loadBucketAccess(r.Context(), s.db, t.ID, req.Bucket)
+		if err == sql.ErrNoRows {
+			s.writeNoSuchBucket(w, r, t.ID, req.Bucket)
+			return
+		}
+		if err != nil {
+			s.logger.Error("query public access block", zap.Error(err))
+			WriteS3Error(w, ErrInternalError, r.URL.Path, generateRequestID())
+			return
+		}
+		if access.block.BlockPublicPolicy {
+			WriteS3ErrorWithContext(w, ErrAccessDenied, r.URL.Path, generateRequestID(),
+				WithSuggestion("The bucket's public access block (BlockPublicPolicy) forbids public policies."))
+			return
+		}
+	}
+
+	// Stored as submitted, like S3: GET returns the caller's own document.
+	result, err := s.db.ExecContext(r.Context(),
+		`UPDATE buckets SET policy = $3, updated_at = NOW()
+		 WHERE tenant_id = $1 AND name = $2`,
+		t.ID, req.Bucket, string(body))
+	if err != nil {
+		s.logger.Error("update bucket policy", zap.Error(err))
+		WriteS3Error(w, ErrInternalError, r.URL.Path, generateRequestID())
+		return
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		s.writeNoSuchBucket(w, r, t.ID, req.Bucket)
+		return
+	}
+
+	s.logger.Info("bucket policy updated",
+		zap.String("tenant_id", t.ID),
+		zap.String("bucket", req.Bucket))
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleDeleteBucketPolicy(w http.ResponseWriter, r *http.Request, req *S3Request) {
+	t, err := tenant.FromContext(r.Context())
+	if err != nil || t == nil {
+		WriteS3Error(w, ErrAccessDenied, r.URL.Path, generateRequestID())
+		return
+	}
+
+	if s.db == nil {
+		WriteS3Error(w, ErrInternalError, r.URL.Path, generateRequestID())
+		return
+	}
+
+	result, err := s.db.ExecContext(r.Context(),
+		`UPDATE buckets SET policy = NULL, updated_at = NOW()
+		 WHERE tenant_id = $1 AND name = $2`,
+		t.ID, req.Bucket)
+	if err != nil {
+		s.logger.Error("delete bucket policy", zap.Error(err))
+		WriteS3Error(w, ErrInternalError, r.URL.Path, generateRequestID())
+		return
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		s.writeNoSuchBucket(w, r, t.ID, req.Bucket)
+		return
+	}
+
+	s.logger.Info("bucket policy deleted",
+		zap.String("tenant_id", t.ID),
+		zap.String("bucket", req.Bucket))
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) handleGetBucketPolicyStatus(w http.ResponseWriter, r *http.Request, req *S3Request) {
+	t, err := tenant.FromContext(r.Context())
+	if err != nil || t == nil {
+		WriteS3Error(w, ErrAccessDenied, r.URL.Path, generateRequestID())
+		return
+	}
+
+	if s.db == nil {
+		WriteS3Error(w, ErrNoSuchBucketPolicy, r.URL.Path, generateRequestID())
+		return
+	}
+
+	raw, policy, err := loadBucketPolicy(r.Context(), s.db, t.ID, req.Bucket)
+	if err == sql.ErrNoRows {
+		s.writeNoSuchBucket(w, r, t.ID, req.Bucket)
+		return
+	}
+	if err != nil && !errors.Is(err, errStoredPolicyInvalid) {
+		s.logger.Error("query bucket policy", zap.Error(err))
+		WriteS3Error(w, ErrInternalError, r.URL.Path, generateRequestID())
+		return
+	}
+	if raw == "" {
+		WriteS3Error(w, ErrNoSuchBucketPolicy, r.URL.Path, generateRequestID())
+		return
+	}
+
+	resp := PolicyStatus{
+		Xmlns:    "http://s3.amazonaws.com/doc/2006-03-01/",
+		IsPublic: policy.IsPublic(),
+	}
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte(xml.Header))
+	_ = xml.NewEncoder(w).Encode(resp)
+}
+
+// writeNoSuchBucket writes NoSuchBucket, with a did-you-mean suggestion
+// when the tenant has a similarly named bucket.
+func (s *Server) writeNoSuchBucket(w http.ResponseWriter, r *http.Request, tenantID, bucket string) {
+	reqID := generateRequestID()
+	if suggestion := bucketSuggestion(r.Context(), s.db, tenantID, bucket); suggestion != "" {
+		WriteS3ErrorWithContext(w, ErrNoSuchBucket, r.URL.Path, reqID, WithSuggestion(suggestion))
+	} else {
+		WriteS3Error(w, ErrNoSuchBucket, r.URL.Path, reqID)
+	}
+}
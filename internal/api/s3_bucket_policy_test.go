@@ -0,0 +1,163 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/FairForge/vaultaire/internal/auth"
+	"github.com/FairForge/vaultaire/internal/tenant"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+const testPartnerPolicy = `{"Version":"2012-10-17","Statement":[
+	{"Effect":"Allow","Principal":{"AWS":"VLT_partner"},"Action":"s3:GetObject","Resource":"arn:aws:s3:::shared/partner/*"},
+	{"Effect":"Deny","Principal":"*","Action":"s3:DeleteObject","Resource":"arn:aws:s3:::shared/*"}]}`
+
+func newPolicyTestServer(t *testing.T) (*Server, sqlmock.Sqlmock) {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	return &Server{
+		logger:   zap.NewNop(),
+		router:   chi.NewRouter(),
+		db:       db,
+		testMode: true,
+	}, mock
+}
+
+func TestEvaluateBucketPolicy(t *testing.T) {
+	tests := []struct {
+		name  string
+		op    string
+		key   string
+		scope *auth.KeyScope
+		want  auth.PolicyDecision
+	}{
+		{"partner reads prefix", "GetObject", "partner/a.csv", &auth.KeyScope{AccessKeyID: "VLT_partner"}, auth.PolicyAllow},
+		{"partner outside prefix", "GetObject", "private/a.csv", &auth.KeyScope{AccessKeyID: "VLT_partner"}, auth.PolicyNoMatch},
+		{"HEAD maps to GetObject", "HeadObject", "partner/a.csv", &auth.KeyScope{AccessKeyID: "VLT_partner"}, auth.PolicyAllow},
+		{"delete denied for everyone", "DeleteObject", "partner/a.csv", &auth.KeyScope{AccessKeyID: "VLT_owner", Primary: true}, auth.PolicyDeny},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, mock := newPolicyTestServer(t)
+			mock.ExpectQuery(`SELECT policy FROM buckets`).
+				WithArgs("tenant-1", "shared").
+				WillReturnRows(sqlmock.NewRows([]string{"policy"}).AddRow(testPartnerPolicy))
+
+			r := httptest.NewRequest("GET", "/shared/"+tt.key, nil)
+			req := &S3Request{Bucket: "shared", Object: tt.key, Operation: tt.op}
+			got, err := s.evaluateBucketPolicy(r, req, "tenant-1", "tenant-1", tt.scope)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+			assert.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestEvaluateBucketPolicy_NoPolicyOrBucket(t *testing.T) {
+	s, mock := newPolicyTestServer(t)
+	mock.ExpectQuery(`SELECT policy FROM buckets`).
+		WillReturnRows(sqlmock.NewRows([]string{"policy"}).AddRow(nil))
+	mock.ExpectQuery(`SELECT policy FROM buckets`).
+		WillReturnError(sql.ErrNoRows)
+
+	r := httptest.NewRequest("GET", "/b/k", nil)
+	req := &S3Request{Bucket: "b", Object: "k", Operation: "GetObject"}
+	for i := 0; i < 2; i++ {
+		got, err := s.evaluateBucketPolicy(r, req, "tenant-1", "tenant-1", &auth.KeyScope{})
+		require.NoError(t, err)
+		assert.Equal(t, auth.PolicyNoMatch, got)
+	}
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestEvaluateBucketPolicy_PrimaryKeyCanManagePolicy(t *testing.T) {
+	s, mock := newPolicyTestServer(t)
+	// No query expected: the primary key bypasses the policy for ?policy ops.
+	r := httptest.NewRequest("DELETE", "/shared?policy", nil)
+	req := &S3Request{Bucket: "shared", Operation: "DeleteBucketPolicy"}
+	got, err := s.evaluateBucketPolicy(r, req, "tenant-1", "tenant-1", &auth.KeyScope{Primary: true})
+	require.NoError(t, err)
+	assert.Equal(t, auth.PolicyNoMatch, got)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestEvaluateBucketPolicy_CorruptPolicyDenies(t *testing.T) {
+	s, mock := newPolicyTestServer(t)
+	mock.ExpectQuery(`SELECT policy FROM buckets`).
+		WillReturnRows(sqlmock.NewRows([]string{"policy"}).AddRow(`{not json`))
+
+	r := httptest.NewRequest("GET", "/b/k", nil)
+	req := &S3Request{Bucket: "b", Object: "k", Operation: "GetObject"}
+	got, err := s.evaluateBucketPolicy(r, req, "tenant-1", "tenant-1", &auth.KeyScope{})
+	require.NoError(t, err)
+	assert.Equal(t, auth.PolicyDeny, got)
+}
+
+func TestPutBucketPolicy_Malformed(t *testing.T) {
+	s, _ := newPolicyTestServer(t)
+	ctx := tenant.WithTenant(context.Background(), &tenant.Tenant{ID: "tenant-1"})
+	body := `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Principal":"*","Action":"s3:GetObject","Resource":"arn:aws:s3:::other/*"}]}`
+	r := httptest.NewRequest("PUT", "/shared?policy", bytes.NewReader([]byte(body))).WithContext(ctx)
+	w := httptest.NewRecorder()
+	s.handlePutBucketPolicy(w, r, &S3Request{Bucket: "shared"})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), ErrMalformedPolicy)
+}
+
+func TestPutBucketPolicy_Stores(t *testing.T) {
+	s, mock := newPolicyTestServer(t)
+	mock.ExpectExec(`UPDATE buckets SET policy`).
+		WithArgs("tenant-1", "shared", testPartnerPolicy).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	ctx := tenant.WithTenant(context.Background(), &tenant.Tenant{ID: "tenant-1"})
+	r := httptest.NewRequest("PUT", "/shared?policy", bytes.NewReader([]byte(testPartnerPolicy))).WithContext(ctx)
+	w := httptest.NewRecorder()
+	s.handlePutBucketPolicy(w, r, &S3Request{Bucket: "shared"})
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetBucketPolicyStatus(t *testing.T) {
+	s, mock := newPolicyTestServer(t)
+	public := `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Principal":"*","Action":"s3:GetObject","Resource":"arn:aws:s3:::shared/*"}]}`
+	mock.ExpectQuery(`SELECT policy FROM buckets`).
+		WillReturnRows(sqlmock.NewRows([]string{"policy"}).AddRow(public))
+
+	ctx := tenant.WithTenant(context.Background(), &tenant.Tenant{ID: "tenant-1"})
+	r := httptest.NewRequest("GET", "/shared?policyStatus", nil).WithContext(ctx)
+	w := httptest.NewRecorder()
+	s.handleGetBucketPolicyStatus(w, r, &S3Request{Bucket: "shared"})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "<IsPublic>true</IsPublic>")
+}
+
+func TestCDNSiteAllows(t *testing.T) {
+	r := httptest.NewRequest("GET", "/cdn/slug/shared/k", nil)
+	site := func(visibility string, policy sql.NullString) *cdnSite {
+		return &cdnSite{bucket: "shared", visibility: visibility, policyJSON: policy}
+	}
+	none := sql.NullString{}
+	assert.True(t, site("public-read", none).allows(r, "k", nil))
+	assert.False(t, site("private", none).allows(r, "k", nil))
+
+	publicPrefix := sql.NullString{Valid: true, String: `{"Version":"2012-10-17","Statement":[
+		{"Effect":"Allow","Principal":"*","Action":"s3:GetObject","Resource":"arn:aws:s3:::shared/public/*"}]}`}
+	assert.True(t, site("private", publicPrefix).allows(r, "public/logo.png", nil))
+	assert.False(t, site("private", publicPrefix).allows(r, "secret.txt", nil))
+
+	denyAll := sql.NullString{Valid: true, String: `{"Version":"2012-10-17","Statement":[
+		{"Effect":"Deny","Principal":"*","Action":"s3:GetObject","Resource":"arn:aws:s3:::shared/*"}]}`}
+	assert.False(t, site("public-read", denyAll).allows(r, "k", nil))
+}
@@ -0,0 +1,369 @@
+package api
+
+import (
+	"context"
+	"crypto/sha1" // #nosec G505 — S3 flexible checksums include SHA1
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"hash/crc64"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/FairForge/vaultaire/internal/engine"
+)
+
+// Flexible checksum algorithms (x-amz-checksum-algorithm). Values are the
+// base64 encoding of the big-endian digest, as S3 returns them.
+const (
+	checksumCRC32     = "CRC32"
+	checksumCRC32C    = "CRC32C"
+	checksumCRC64NVME = "CRC64NVME"
+	checksumSHA1      = "SHA1"
+	checksumSHA256    = "SHA256"
+)
+
+// Checksum types for multipart uploads. FULL_OBJECT covers the assembled
+// bytes; COMPOSITE is the checksum of the concatenated part checksums,
+// suffixed with the part count.
+const (
+	checksumTypeFullObject = "FULL_OBJECT"
+	checksumTypeComposite  = "COMPOSITE"
+)
+
+// checksumAlgorithms lists the supported algorithms in header-probe order.
+var checksumAlgorithms = []string{
+	checksumCRC32, checksumCRC32C, checksumCRC64NVME, checksumSHA1, checksumSHA256,
+}
+
+var (
+	crc32cTable = crc32.MakeTable(crc32.Castagnoli)
+	// CRC-64/NVME, polynomial 0xad93d23594c93659 in Go's reflected form.
+	crc64NVMETable = crc64.MakeTable(0x9a6c9329ac4bc9b5)
+)
+
+// errChecksumMismatch signals a body whose computed checksum differs from
+// the one the client declared in a header or trailer.
+var errChecksumMismatch = errors.New("checksum mismatch")
+
+// errMultipleChecksums rejects a request naming more than one algorithm.
+var errMultipleChecksums = errors.New("expecting a single x-amz-checksum- header; multiple checksum types are not allowed")
+
+// newChecksumHash returns a fresh hasher for a supported algorithm, or nil.
+func newChecksumHash(algorithm string) hash.Hash {
+	switch algorithm {
+	case checksumCRC32:
+		return crc32.NewIEEE()
+	case checksumCRC32C:
+		return crc32.New(crc32cTable)
+	case checksumCRC64NVME:
+		return crc64.New(crc64NVMETable)
+	case checksumSHA1:
+		return sha1.New() // #nosec G401 — client-selected S3 checksum
+	case checksumSHA256:
+		return sha256.New()
+	}
+	return nil
+}
+
+// checksumHeader returns the x-amz-checksum-* header carrying an algorithm's
+// value.
+func checksumHeader(algorithm string) string {
+	return "x-amz-checksum-" + strings.ToLower(algorithm)
+}
+
+// normalizeChecksumAlgorithm upper-cases a client-supplied algorithm name and
+// reports whether it is supported.
+func normalizeChecksumAlgorithm(v string) (string, bool) {
+	alg := strings.ToUpper(strings.TrimSpace(v))
+	return alg, newChecksumHash(alg) != nil
+}
+
+// validChecksumType reports whether a multipart upload may use the given
+// algorithm/type pair. CRC64NVME only has a full-object form; the SHA
+// family only has a composite form.
+func validChecksumType(algorithm, checksumType string) bool {
+	switch checksumType {
+	case checksumTypeFullObject:
+		return algorithm == checksumCRC32 || algorithm == checksumCRC32C || algorithm == checksumCRC64NVME
+	case checksumTypeComposite:
+		return algorithm != checksumCRC64NVME
+	}
+	return false
+}
+
+// defaultChecksumType is the multipart checksum type S3 uses when the client
+// names an algorithm but no x-amz-checksum-type.
+func defaultChecksumType(algorithm string) string {
+	if algorithm == checksumCRC64NVME {
+		return checksumTypeFullObject
+	}
+	return checksumTypeComposite
+}
+
+// requestChecksum tracks the flexible checksum for one request body. The
+// hasher is fed the decoded plaintext alongside the MD5 ETag hasher; the
+// expected value comes from a header, from an aws-chunked trailer, or is
+// absent when the client only asked the server to compute and store one.
+type requestChecksum struct {
+	Algorithm string
+	expected  string
+	trailer   string
+	h         hash.Hash
+	source    *awsChunkedReader
+}
+
+// parseChecksumRequest reads the flexible-checksum headers of a request. It
+// returns nil when the client asked for no checksum. More than one checksum
+// header, an unsupported algorithm, or a trailer on a body that is not
+// aws-chunked are client errors.
+func parseChecksumRequest(r *http.Request) (*requestChecksum, error) {
+	var ck *requestChecksum
+	for _, alg := range checksumAlgorithms {
+		v := r.Header.Get(checksumHeader(alg))
+		if v == "" {
+			continue
+		}
+		if ck != nil {
+			return nil, errMultipleChecksums
+		}
+		ck = &requestChecksum{Algorithm: alg, expected: v}
+	}
+
+	if trailer := strings.TrimSpace(r.Header.Get("x-amz-trailer")); trailer != "" {
+		name := strings.ToLower(trailer)
+		alg, ok := normalizeChecksumAlgorithm(strings.TrimPrefix(name, "x-amz-checksum-"))
+		if !strings.HasPrefix(name, "x-amz-checksum-") || !ok {
+			return nil, fmt.Errorf("x-amz-trailer value %q is not a supported checksum header", trailer)
+		}
+		if ck != nil {
+			return nil, errMultipleChecksums
+		}
+		if !isAWSChunked(r) {
+			return nil, errors.New("x-amz-trailer requires an aws-chunked request body")
+		}
+		ck = &requestChecksum{Algorithm: alg, trailer: checksumHeader(alg)}
+	}
+
+	declared := r.Header.Get("x-amz-sdk-checksum-algorithm")
+	if declared == "" {
+		declared = r.Header.Get("x-amz-checksum-algorithm")
+	}
+	if declared != "" {
+		alg, ok := normalizeChecksumAlgorithm(declared)
+		if !ok {
+			return nil, fmt.Errorf("checksum algorithm %q is not supported (use CRC32, CRC32C, CRC64NVME, SHA1 or SHA256)", declared)
+		}
+		if ck == nil {
+			ck = &requestChecksum{Algorithm: alg}
+		} else if ck.Algorithm != alg {
+			return nil, errors.New("x-amz-sdk-checksum-algorithm does not match the checksum header sent")
+		}
+	}
+
+	if ck != nil {
+		ck.h = newChecksumHash(ck.Algorithm)
+	}
+	return ck, nil
+}
+
+// newRequestChecksum returns a checksum the server computes with no declared
+// value to check it against.
+func newRequestChecksum(algorithm string) *requestChecksum {
+	return &requestChecksum{Algorithm: algorithm, h: newChecksumHash(algorithm)}
+}
+
+// Write feeds body bytes to the checksum hasher.
+func (c *requestChecksum) Write(p []byte) (int, error) {
+	return c.h.Write(p)
+}
+
+// bind attaches the aws-chunked decoder whose trailers carry the expected
+// value for trailer-form checksums.
+func (c *requestChecksum) bind(src *awsChunkedReader) {
+	c.source = src
+}
+
+// Sum returns the base64 checksum of the bytes written so far.
+func (c *requestChecksum) Sum() string {
+	return base64.StdEncoding.EncodeToString(c.h.Sum(nil))
+}
+
+// Verify compares the computed checksum with the declared one once the body
+// has been fully consumed, and returns the computed value for storage.
+func (c *requestChecksum) Verify() (string, error) {
+	got := c.Sum()
+	want := c.expected
+	if c.trailer != "" {
+		if c.source == nil || c.source.trailers.Get(c.trailer) == "" {
+			return "", fmt.Errorf("%w: trailer %s was not sent", errChecksumMismatch, c.trailer)
+		}
+		want = c.source.trailers.Get(c.trailer)
+	}
+	if want != "" && want != got {
+		return "", fmt.Errorf("%w: %s", errChecksumMismatch, checksumHeader(c.Algorithm))
+	}
+	return got, nil
+}
+
+// mismatchSuggestion is the BadDigest suggestion for a failed checksum.
+func (c *requestChecksum) mismatchSuggestion() string {
+	return fmt.Sprintf("The %s you specified did not match the calculated checksum.", checksumHeader(c.Algorithm))
+}
+
+// verifyOnEOF wraps a PUT body and runs verify when it reaches io.EOF,
+// returning verify's error in place of the EOF. The read error aborts the
+// backend write before it commits — as payloadVerifyReader does for
+// x-amz-content-sha256 — so a body that fails its checksum or declared
+// length never replaces the bytes already stored under the key. The error
+// wraps engine.ErrInvalidInput so a client's bad body is not charged to the
+// backend's circuit breaker.
+type verifyOnEOF struct {
+	r      io.Reader
+	verify func() error
+	err    error
+}
+
+func (v *verifyOnEOF) Read(p []byte) (int, error) {
+	if v.err != nil {
+		return 0, v.err
+	}
+	n, err := v.r.Read(p)
+	if errors.Is(err, io.EOF) {
+		if verr := v.verify(); verr != nil {
+			v.err = fmt.Errorf("%w: %w", engine.ErrInvalidInput, verr)
+			return n, v.err
+		}
+	}
+	return n, err
+}
+
+// writeBodyVerifyError answers a PUT whose body failed verifyOnEOF and
+// reports whether err was such a failure.
+func writeBodyVerifyError(w http.ResponseWriter, r *http.Request, ck *requestChecksum, err error) bool {
+	switch {
+	case errors.Is(err, errDecodedLengthMismatch):
+		WriteS3Error(w, ErrIncompleteBody, r.URL.Path, generateRequestID())
+	case errors.Is(err, errChecksumMismatch) && ck != nil:
+		WriteS3ErrorWithContext(w, ErrBadDigest, r.URL.Path, generateRequestID(),
+			WithSuggestion(ck.mismatchSuggestion()))
+	default:
+		return false
+	}
+	return true
+}
+
+// compositeChecksum computes a COMPOSITE multipart checksum: the checksum of
+// the concatenated raw part digests, suffixed with the part count.
+func compositeChecksum(algorithm string, partChecksums []string) (string, error) {
+	h := newChecksumHash(algorithm)
+	if h == nil {
+		return "", fmt.Errorf("unsupported checksum algorithm %q", algorithm)
+	}
+	for i, pc := range partChecksums {
+		raw, err := base64.StdEncoding.DecodeString(pc)
+		if err != nil || pc == "" {
+			return "", fmt.Errorf("part %d has no valid %s checksum", i+1, algorithm)
+		}
+		h.Write(raw)
+	}
+	return fmt.Sprintf("%s-%d", base64.StdEncoding.EncodeToString(h.Sum(nil)), len(partChecksums)), nil
+}
+
+// setChecksumHeaders writes the stored checksum of an object as response
+// headers. Nothing is written when the object has none.
+func setChecksumHeaders(w http.ResponseWriter, algorithm, value, checksumType string) {
+	if algorithm == "" || value == "" {
+		return
+	}
+	w.Header().Set(checksumHeader(algorithm), value)
+	if checksumType == "" {
+		checksumType = checksumTypeFullObject
+	}
+	w.Header().Set("x-amz-checksum-type", checksumType)
+}
+
+// checksumModeEnabled reports whether a GET/HEAD asked for stored checksums.
+func checksumModeEnabled(r *http.Request) bool {
+	return strings.EqualFold(r.Header.Get("x-amz-checksum-mode"), "ENABLED")
+}
+
+// lookupObjectChecksum returns the stored checksum of the current object
+// version. All values are empty when the object has none.
+func lookupObjectChecksum(ctx context.Context, db *sql.DB, tenantID, bucket, key string) (algorithm, value, checksumType string) {
+	if db == nil {
+		return "", "", ""
+	}
+	var alg, val, typ sql.NullString
+	if err := db.QueryRowContext(ctx, `
+		SELECT checksum_algorithm, checksum_value, checksum_type FROM object_head_cache
+		WHERE tenant_id = $1 AND bucket = $2 AND object_key = $3`,
+		tenantID, bucket, key).Scan(&alg, &val, &typ); err != nil {
+		return "", "", ""
+	}
+	return alg.String, val.String, typ.String
+}
+
+// checksumTypeFor is the checksum type of a single-part object: FULL_OBJECT
+// when it has a checksum at all.
+func checksumTypeFor(algorithm string) string {
+	if algorithm == "" {
+		return ""
+	}
+	return checksumTypeFullObject
+}
+
+// nullIfEmpty maps "" to SQL NULL for optional text columns.
+func nullIfEmpty(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+// ChecksumFields carries the per-algorithm checksum elements S3 uses in
+// multipart request and response bodies. At most one is set.
+type ChecksumFields struct {
+	ChecksumCRC32     string `xml:"ChecksumCRC32,omitempty"`
+	ChecksumCRC32C    string `xml:"ChecksumCRC32C,omitempty"`
+	ChecksumCRC64NVME string `xml:"ChecksumCRC64NVME,omitempty"`
+	ChecksumSHA1      string `xml:"ChecksumSHA1,omitempty"`
+	ChecksumSHA256    string `xml:"ChecksumSHA256,omitempty"`
+}
+
+// field returns the element for an algorithm, or nil when unsupported.
+func (c *ChecksumFields) field(algorithm string) *string {
+	switch algorithm {
+	case checksumCRC32:
+		return &c.ChecksumCRC32
+	case checksumCRC32C:
+		return &c.ChecksumCRC32C
+	case checksumCRC64NVME:
+		return &c.ChecksumCRC64NVME
+	case checksumSHA1:
+		return &c.ChecksumSHA1
+	case checksumSHA256:
+		return &c.ChecksumSHA256
+	}
+	return nil
+}
+
+// set stores value under its algorithm's element.
+func (c *ChecksumFields) set(algorithm, value string) {
+	if f := c.field(algorithm); f != nil {
+		*f = value
+	}
+}
+
+// get returns the value of an algorithm's element.
+func (c *ChecksumFields) get(algorithm string) string {
+	if f := c.field(algorithm); f != nil {
+		return *f
+	}
+	return ""
+}
@@ -0,0 +1,324 @@
+package api
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/FairForge/vaultaire/internal/tenant"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// doS3RequestWith is doS3Request for a caller-built request (extra headers).
+func doS3RequestWith(srv *Server, t *tenant.Tenant, req *http.Request) *httptest.ResponseRecorder {
+	req = req.WithContext(tenant.WithTenant(req.Context(), t))
+	w := httptest.NewRecorder()
+	srv.handleS3Request(w, req)
+	return w
+}
+
+// checksumOf returns the base64 checksum S3 would report for data.
+func checksumOf(t *testing.T, algorithm string, data []byte) string {
+	t.Helper()
+	h := newChecksumHash(algorithm)
+	require.NotNil(t, h, algorithm)
+	h.Write(data)
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+func TestChecksumAlgorithms_CheckValues(t *testing.T) {
+	// Standard "123456789" check values for each CRC.
+	crcs := map[string]uint64{
+		checksumCRC32:     0xcbf43926,
+		checksumCRC32C:    0xe3069283,
+		checksumCRC64NVME: 0xae8b14860a799888,
+	}
+	for alg, want := range crcs {
+		h := newChecksumHash(alg)
+		h.Write([]byte("123456789"))
+		sum := h.Sum(nil)
+		var got uint64
+		if len(sum) == 4 {
+			got = uint64(binary.BigEndian.Uint32(sum))
+		} else {
+			got = binary.BigEndian.Uint64(sum)
+		}
+		assert.Equal(t, want, got, alg)
+	}
+	assert.Equal(t, "qZk+NkcGgWq6PiVxeFDCbJzQ2J0=", checksumOf(t, checksumSHA1, []byte("abc")))
+	assert.Nil(t, newChecksumHash("MD5"))
+}
+
+func TestParseChecksumRequest(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers map[string]string
+		wantAlg string
+		wantErr bool
+	}{
+		{"none", nil, "", false},
+		{"header form", map[string]string{"x-amz-checksum-crc32c": "AAAAAA=="}, checksumCRC32C, false},
+		{"algorithm only", map[string]string{"x-amz-checksum-algorithm": "sha256"}, checksumSHA256, false},
+		{"sdk algorithm with header", map[string]string{
+			"x-amz-sdk-checksum-algorithm": "CRC32", "x-amz-checksum-crc32": "AAAAAA=="}, checksumCRC32, false},
+		{"trailer form", map[string]string{
+			"x-amz-trailer": "x-amz-checksum-crc64nvme", "x-amz-content-sha256": "STREAMING-UNSIGNED-PAYLOAD-TRAILER"}, checksumCRC64NVME, false},
+		{"two checksums", map[string]string{"x-amz-checksum-crc32": "a", "x-amz-checksum-sha1": "b"}, "", true},
+		{"unsupported algorithm", map[string]string{"x-amz-checksum-algorithm": "MD5"}, "", true},
+		{"algorithm disagrees", map[string]string{
+			"x-amz-sdk-checksum-algorithm": "SHA1", "x-amz-checksum-crc32": "AAAAAA=="}, "", true},
+		{"trailer without aws-chunked", map[string]string{"x-amz-trailer": "x-amz-checksum-crc32"}, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("PUT", "/b/k", nil)
+			for k, v := range tt.headers {
+				r.Header.Set(k, v)
+			}
+			ck, err := parseChecksumRequest(r)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			if tt.wantAlg == "" {
+				assert.Nil(t, ck)
+				return
+			}
+			require.NotNil(t, ck)
+			assert.Equal(t, tt.wantAlg, ck.Algorithm)
+		})
+	}
+}
+
+func TestRequestChecksum_VerifyHeader(t *testing.T) {
+	data := []byte("hello checksum")
+	r := httptest.NewRequest("PUT", "/b/k", nil)
+	r.Header.Set("x-amz-checksum-sha256", checksumOf(t, checksumSHA256, data))
+	ck, err := parseChecksumRequest(r)
+	require.NoError(t, err)
+	_, _ = ck.Write(data)
+	got, err := ck.Verify()
+	require.NoError(t, err)
+	assert.Equal(t, checksumOf(t, checksumSHA256, data), got)
+
+	r.Header.Set("x-amz-checksum-sha256", checksumOf(t, checksumSHA256, []byte("other")))
+	ck, err = parseChecksumRequest(r)
+	require.NoError(t, err)
+	_, _ = ck.Write(data)
+	_, err = ck.Verify()
+	assert.ErrorIs(t, err, errChecksumMismatch)
+}
+
+func TestAWSChunkedReader_Trailers(t *testing.T) {
+	data := []byte("trailer payload")
+	sum := checksumOf(t, checksumCRC32, data)
+	framed := fmt.Sprintf("%x\r\n%s\r\n0\r\nx-amz-checksum-crc32:%s\r\nx-amz-trailer-signature:abc\r\n\r\n", len(data), data, sum)
+
+	r := httptest.NewRequest("PUT", "/b/k", nil)
+	r.Header.Set("x-amz-content-sha256", "STREAMING-UNSIGNED-PAYLOAD-TRAILER")
+	r.Header.Set("x-amz-trailer", "x-amz-checksum-crc32")
+	ck, err := parseChecksumRequest(r)
+	require.NoError(t, err)
+
+	cr := newAWSChunkedReader(strings.NewReader(framed))
+	ck.bind(cr)
+	got, err := io.ReadAll(io.TeeReader(cr, ck))
+	require.NoError(t, err)
+	assert.Equal(t, data, got)
+	assert.Equal(t, sum, cr.trailers.Get("x-amz-checksum-crc32"))
+	assert.Empty(t, cr.trailers.Get("x-amz-trailer-signature"))
+
+	value, err := ck.Verify()
+	require.NoError(t, err)
+	assert.Equal(t, sum, value)
+}
+
+func TestAWSChunkedReader_MissingTrailerFailsVerify(t *testing.T) {
+	r := httptest.NewRequest("PUT", "/b/k", nil)
+	r.Header.Set("x-amz-content-sha256", "STREAMING-UNSIGNED-PAYLOAD-TRAILER")
+	r.Header.Set("x-amz-trailer", "x-amz-checksum-crc32")
+	ck, err := parseChecksumRequest(r)
+	require.NoError(t, err)
+
+	cr := newAWSChunkedReader(strings.NewReader("3\r\nabc\r\n0\r\n\r\n"))
+	ck.bind(cr)
+	_, err = io.ReadAll(io.TeeReader(cr, ck))
+	require.NoError(t, err)
+	_, err = ck.Verify()
+	assert.ErrorIs(t, err, errChecksumMismatch)
+}
+
+func TestPut_BadChecksumKeepsExistingObject(t *testing.T) {
+	srv, tnt, _ := newTestMultipartServer(t)
+
+	original := []byte("original bytes")
+	w := doS3Request(srv, tnt, "PUT", "/test-bucket/keep.bin", bytes.NewReader(original))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	replacement := []byte("replacement bytes")
+	header := httptest.NewRequest("PUT", "/test-bucket/keep.bin", bytes.NewReader(replacement))
+	header.Header.Set("x-amz-checksum-crc32", checksumOf(t, checksumCRC32, []byte("something else")))
+
+	framed := fmt.Sprintf("%x\r\n%s\r\n0\r\nx-amz-checksum-crc32:%s\r\n\r\n",
+		len(replacement), replacement, checksumOf(t, checksumCRC32, []byte("something else")))
+	trailer := httptest.NewRequest("PUT", "/test-bucket/keep.bin", strings.NewReader(framed))
+	trailer.Header.Set("x-amz-content-sha256", "STREAMING-UNSIGNED-PAYLOAD-TRAILER")
+	trailer.Header.Set("x-amz-trailer", "x-amz-checksum-crc32")
+	trailer.Header.Set("x-amz-decoded-content-length", fmt.Sprint(len(replacement)))
+
+	for name, req := range map[string]*http.Request{"header": header, "trailer": trailer} {
+		w := doS3RequestWith(srv, tnt, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code, name)
+		assert.Contains(t, w.Body.String(), ErrBadDigest, name)
+
+		get := doS3Request(srv, tnt, "GET", "/test-bucket/keep.bin", nil)
+		require.Equal(t, http.StatusOK, get.Code, name)
+		assert.Equal(t, original, get.Body.Bytes(), "%s: the failed PUT must not replace the stored bytes", name)
+	}
+}
+
+func TestCompositeChecksum(t *testing.T) {
+	p1, p2 := []byte("part one"), []byte("part two")
+	c1, c2 := checksumOf(t, checksumSHA256, p1), checksumOf(t, checksumSHA256, p2)
+
+	raw1, _ := base64.StdEncoding.DecodeString(c1)
+	raw2, _ := base64.StdEncoding.DecodeString(c2)
+	want := checksumOf(t, checksumSHA256, append(raw1, raw2...)) + "-2"
+
+	got, err := compositeChecksum(checksumSHA256, []string{c1, c2})
+	require.NoError(t, err)
+	assert.Equal(t, want, got)
+
+	_, err = compositeChecksum(checksumSHA256, []string{c1, ""})
+	assert.Error(t, err)
+}
+
+func TestValidChecksumType(t *testing.T) {
+	assert.True(t, validChecksumType(checksumCRC64NVME, checksumTypeFullObject))
+	assert.False(t, validChecksumType(checksumCRC64NVME, checksumTypeComposite))
+	assert.False(t, validChecksumType(checksumSHA256, checksumTypeFullObject))
+	assert.True(t, validChecksumType(checksumSHA1, checksumTypeComposite))
+	assert.Equal(t, checksumTypeFullObject, defaultChecksumType(checksumCRC64NVME))
+	assert.Equal(t, checksumTypeComposite, defaultChecksumType(checksumCRC32))
+}
+
+func TestMultipart_CompositeChecksum(t *testing.T) {
+	srv, tnt, _ := newTestMultipartServer(t)
+
+	req := httptest.NewRequest("POST", "/test-bucket/ck.bin?uploads", nil)
+	req.Header.Set("x-amz-checksum-algorithm", "CRC32")
+	initW := doS3RequestWith(srv, tnt, req)
+	require.Equal(t, http.StatusOK, initW.Code)
+	assert.Equal(t, checksumTypeComposite, initW.Header().Get("x-amz-checksum-type"))
+	var initResult InitiateMultipartUploadResult
+	require.NoError(t, xml.Unmarshal(initW.Body.Bytes(), &initResult))
+	uploadID := initResult.UploadID
+
+	parts := [][]byte{[]byte("first part "), []byte("second part")}
+	var etags, sums []string
+	for i, data := range parts {
+		req := httptest.NewRequest("PUT",
+			fmt.Sprintf("/test-bucket/ck.bin?uploadId=%s&partNumber=%d", uploadID, i+1), bytes.NewReader(data))
+		if i == 0 {
+			req.Header.Set("x-amz-checksum-crc32", checksumOf(t, checksumCRC32, data))
+		} // part 2: the server computes it
+		w := doS3RequestWith(srv, tnt, req)
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, checksumOf(t, checksumCRC32, data), w.Header().Get("x-amz-checksum-crc32"))
+		etags = append(etags, w.Header().Get("ETag"))
+		sums = append(sums, w.Header().Get("x-amz-checksum-crc32"))
+	}
+
+	listW := doS3Request(srv, tnt, "GET", fmt.Sprintf("/test-bucket/ck.bin?uploadId=%s", uploadID), nil)
+	require.Equal(t, http.StatusOK, listW.Code)
+	var listResult ListPartsResult
+	require.NoError(t, xml.Unmarshal(listW.Body.Bytes(), &listResult))
+	assert.Equal(t, checksumCRC32, listResult.ChecksumAlgorithm)
+	require.Len(t, listResult.Parts, 2)
+	assert.Equal(t, sums[1], listResult.Parts[1].ChecksumCRC32)
+
+	completeBody := fmt.Sprintf(`<CompleteMultipartUpload>
+		<Part><PartNumber>1</PartNumber><ETag>%s</ETag><ChecksumCRC32>%s</ChecksumCRC32></Part>
+		<Part><PartNumber>2</PartNumber><ETag>%s</ETag><ChecksumCRC32>%s</ChecksumCRC32></Part>
+	</CompleteMultipartUpload>`, etags[0], sums[0], etags[1], sums[1])
+	completeW := doS3Request(srv, tnt, "POST",
+		fmt.Sprintf("/test-bucket/ck.bin?uploadId=%s", uploadID), strings.NewReader(completeBody))
+	require.Equal(t, http.StatusOK, completeW.Code)
+
+	var result CompleteMultipartUploadResult
+	require.NoError(t, xml.Unmarshal(completeW.Body.Bytes(), &result))
+	want, err := compositeChecksum(checksumCRC32, sums)
+	require.NoError(t, err)
+	assert.Equal(t, want, result.ChecksumCRC32)
+	assert.Equal(t, checksumTypeComposite, result.ChecksumType)
+}
+
+func TestMultipart_FullObjectChecksum(t *testing.T) {
+	srv, tnt, _ := newTestMultipartServer(t)
+
+	req := httptest.NewRequest("POST", "/test-bucket/full.bin?uploads", nil)
+	req.Header.Set("x-amz-checksum-algorithm", "CRC64NVME")
+	initW := doS3RequestWith(srv, tnt, req)
+	require.Equal(t, http.StatusOK, initW.Code)
+	var initResult InitiateMultipartUploadResult
+	require.NoError(t, xml.Unmarshal(initW.Body.Bytes(), &initResult))
+	uploadID := initResult.UploadID
+
+	data := []byte("whole object across two parts")
+	for i, chunk := range [][]byte{data[:10], data[10:]} {
+		w := doS3Request(srv, tnt, "PUT",
+			fmt.Sprintf("/test-bucket/full.bin?uploadId=%s&partNumber=%d", uploadID, i+1), bytes.NewReader(chunk))
+		require.Equal(t, http.StatusOK, w.Code)
+	}
+
+	// A wrong whole-object checksum is rejected before anything is stored.
+	bad := httptest.NewRequest("POST", fmt.Sprintf("/test-bucket/full.bin?uploadId=%s", uploadID), nil)
+	bad.Header.Set("x-amz-checksum-crc64nvme", checksumOf(t, checksumCRC64NVME, []byte("nope")))
+	badW := doS3RequestWith(srv, tnt, bad)
+	assert.Equal(t, http.StatusBadRequest, badW.Code)
+	assert.Contains(t, badW.Body.String(), ErrBadDigest)
+
+	good := httptest.NewRequest("POST", fmt.Sprintf("/test-bucket/full.bin?uploadId=%s", uploadID), nil)
+	good.Header.Set("x-amz-checksum-crc64nvme", checksumOf(t, checksumCRC64NVME, data))
+	goodW := doS3RequestWith(srv, tnt, good)
+	require.Equal(t, http.StatusOK, goodW.Code)
+	var result CompleteMultipartUploadResult
+	require.NoError(t, xml.Unmarshal(goodW.Body.Bytes(), &result))
+	assert.Equal(t, checksumOf(t, checksumCRC64NVME, data), result.ChecksumCRC64NVME)
+	assert.Equal(t, checksumTypeFullObject, result.ChecksumType)
+}
+
+func TestMultipart_UploadPartBadDigest(t *testing.T) {
+	srv, tnt, _ := newTestMultipartServer(t)
+
+	initW := doS3Request(srv, tnt, "POST", "/test-bucket/bad.bin?uploads", nil)
+	require.Equal(t, http.StatusOK, initW.Code)
+	var initResult InitiateMultipartUploadResult
+	require.NoError(t, xml.Unmarshal(initW.Body.Bytes(), &initResult))
+
+	req := httptest.NewRequest("PUT",
+		fmt.Sprintf("/test-bucket/bad.bin?uploadId=%s&partNumber=1", initResult.UploadID), strings.NewReader("payload"))
+	req.Header.Set("x-amz-checksum-sha1", checksumOf(t, checksumSHA1, []byte("different")))
+	w := doS3RequestWith(srv, tnt, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), ErrBadDigest)
+}
+
+func TestMultipart_InitiateRejectsInvalidChecksumType(t *testing.T) {
+	srv, tnt, _ := newTestMultipartServer(t)
+
+	req := httptest.NewRequest("POST", "/test-bucket/x.bin?uploads", nil)
+	req.Header.Set("x-amz-checksum-algorithm", "SHA256")
+	req.Header.Set("x-amz-checksum-type", "FULL_OBJECT")
+	w := doS3RequestWith(srv, tnt, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
@@ -350,6 +350,31 @@ func TestHandleGet_ChunkedObject_MultiChunkRanges(t *testing.T) {
 	}
 }
 
+// TestHandleGet_ChunkedObject_MultipleRanges: several ranges come back as
+// multipart/byteranges, each part cut from the covering chunks — including
+// two ranges inside the same chunk.
+func TestHandleGet_ChunkedObject_MultipleRanges(t *testing.T) {
+	f := setupChunkingFixture(t)
+	content := generateTestData(20 * 1024 * 1024)
+	putChunkedObject(t, f, "multi.bin", content, "application/octet-stream")
+	total := len(content)
+
+	req := httptest.NewRequest("GET", "/test-bucket/multi.bin", nil)
+	req.Header.Set("Range", "bytes=-100,10-19,30-39,5000000-15000000")
+	req = req.WithContext(tenant.WithTenant(req.Context(), f.tenant))
+	w := httptest.NewRecorder()
+	f.adapter.HandleGet(w, req, "test-bucket", "multi.bin")
+
+	require.Equal(t, http.StatusPartialContent, w.Code)
+	assert.Equal(t, strconv.Itoa(w.Body.Len()), w.Header().Get("Content-Length"))
+	parts := readByteranges(t, w)
+	require.Len(t, parts, 4)
+	for i, want := range [][2]int{{10, 20}, {30, 40}, {5000000, 15000001}, {total - 100, total}} {
+		assert.Equal(t, fmt.Sprintf("bytes %d-%d/%d", want[0], want[1]-1, total), parts[i].contentRange)
+		assert.Equal(t, content[want[0]:want[1]], parts[i].body, "part %d", i)
+	}
+}
+
 // TestHandleGet_ChunkedObject_CorruptChunk verifies read-time integrity: if a
 // stored chunk's bytes no longer hash to its plaintext_hash, the corrupt data
 // must NOT be served (200), and the failure is surfaced as 500 (the object
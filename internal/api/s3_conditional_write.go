@@ -0,0 +1,491 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/FairForge/vaultaire/internal/engine"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// Conditional writes (If-None-Match: * on PutObject / CompleteMultipartUpload,
+// If-Match on those and on DeleteObject) are compare-and-swap on the head
+// row. The precondition is checked up front without a lock so a doomed
+// request fails before it sends its body, and the body is written to a
+// private staging artifact with no lock or transaction held. Only then is
+// the key locked: the precondition is rechecked, the staged artifact is
+// renamed onto the key and the new head row committed, all under the lock.
+// Of two concurrent conditional writers exactly one succeeds, and the loser's
+// bytes never touch the key. Clients use this for lock files and manifests.
+//
+// Every writer of a key takes the same per-key advisory lock. Unconditional
+// writers hold it from before their bytes reach the key until their head row
+// commits (keyWriteLock), at the cost of a database connection for the
+// upload; otherwise a conditional writer could promote and commit between
+// their byte write and their upsert, leaving their row over its bytes.
+// Conditional writes never take the chunked path: their bytes must be
+// renamable as one artifact.
+//
+// The object a promotion overwrites is first set aside, and put back if the
+// head row then fails to commit, so a failed write never loses it. A staged
+// or set-aside artifact is removed once the write finishes; one left by a
+// crash is never referenced by a head row.
+
+var (
+	// errWritePreconditionFailed: the object exists (If-None-Match: *) or
+	// has a different ETag (If-Match).
+	errWritePreconditionFailed = errors.New("conditional write: precondition failed")
+	// errWriteNoSuchKey: If-Match named an object that does not exist.
+	errWriteNoSuchKey = errors.New("conditional write: no such key")
+)
+
+// conditionalStagingPrefix names the private artifacts conditional writes
+// stage their bodies under, in the bucket's own container.
+const conditionalStagingPrefix = ".conditional-staging/"
+
+// writeConditionError is a conditional write request the client must fix.
+type writeConditionError struct {
+	code string
+	msg  string
+}
+
+func (e *writeConditionError) Error() string { return e.msg }
+
+// writeCondition is the precondition of a conditional write.
+type writeCondition struct {
+	IfNoneMatch bool   // create only if the key does not exist
+	IfMatch     string // replace only the object with one of these ETags
+}
+
+// parseWriteCondition reads the conditional write headers; nil when there
+// are none. allowCreate is false for DeleteObject, which only takes If-Match.
+func parseWriteCondition(r *http.Request, allowCreate bool) (*writeCondition, error) {
+	ifMatch := strings.TrimSpace(r.Header.Get("If-Match"))
+	ifNoneMatch := ""
+	if allowCreate {
+		ifNoneMatch = strings.TrimSpace(r.Header.Get("If-None-Match"))
+	}
+	if ifMatch == "" && ifNoneMatch == "" {
+		return nil, nil
+	}
+	if ifNoneMatch != "" && ifNoneMatch != "*" {
+		return nil, &writeConditionError{code: ErrNotImplemented,
+			msg: "If-None-Match on a write only supports the value *."}
+	}
+	if ifMatch != "" && ifNoneMatch != "" {
+		return nil, &writeConditionError{code: ErrInvalidArgument,
+			msg: "If-Match and If-None-Match cannot be combined on a write."}
+	}
+	return &writeCondition{IfNoneMatch: ifNoneMatch != "", IfMatch: ifMatch}, nil
+}
+
+// check evaluates the condition against the current head row; exists is
+// false when there is none.
+func (c *writeCondition) check(exists bool, etag string) error {
+	if c.IfNoneMatch {
+		if exists {
+			return errWritePreconditionFailed
+		}
+		return nil
+	}
+	if !exists {
+		return errWriteNoSuchKey
+	}
+	if c.IfMatch == "*" {
+		return nil
+	}
+	for _, candidate := range strings.Split(c.IfMatch, ",") {
+		if etagsMatch(strings.TrimSpace(candidate), etag) {
+			return nil
+		}
+	}
+	return errWritePreconditionFailed
+}
+
+// conditionalWrite is a conditional write in progress. A nil
+// *conditionalWrite is an unconditional write: its methods fall back to the
+// plain database and atomicHeadUpsertReleasing.
+type conditionalWrite struct {
+	cond     *writeCondition
+	db       *sql.DB
+	tenantID string
+	bucket   string
+	key      string
+	// staged is the artifact the body is written to instead of key.
+	staged string
+	// promote moves the staged artifact onto key and discard removes it.
+	// setAside moves the object promote would overwrite to a backup
+	// artifact, putBack undoes a promote (restoring that backup) and
+	// dropAside removes the backup once the head row has committed. All are
+	// nil until stageOn is called.
+	promote   func(ctx context.Context) error
+	discard   func(ctx context.Context)
+	setAside  func(ctx context.Context) error
+	putBack   func(ctx context.Context)
+	dropAside func(ctx context.Context)
+
+	// tx holds the key's advisory lock and head row lock once lock has
+	// run; the displaced fields describe the locked row, if there is one.
+	tx               *sql.Tx
+	displacedExists  bool
+	displaced        int64
+	displacedChunked bool
+	displacedBackend string
+}
+
+// lockHeadKey takes the transaction-scoped advisory lock every writer of
+// bucket/key's head row holds. FOR UPDATE locks nothing while the key does
+// not exist, so without it two creators would both see no row.
+func lockHeadKey(ctx context.Context, tx *sql.Tx, tenantID, bucket, key string) error {
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtextextended($1, 0))`,
+		tenantID+"/"+bucket+"/"+key); err != nil {
+		return fmt.Errorf("lock head-cache key: %w", err)
+	}
+	return nil
+}
+
+// keyWriteLock is the key lock an unconditional writer holds from before
+// its bytes reach the key until its head row commits. A nil *keyWriteLock
+// (no database) locks nothing.
+type keyWriteLock struct {
+	tx *sql.Tx
+}
+
+// lockKeyForWrite begins an unconditional write's transaction and takes the
+// key's lock. The caller must release it (a no-op after headUpsert).
+func lockKeyForWrite(ctx context.Context, db *sql.DB, tenantID, bucket, key string) (*keyWriteLock, error) {
+	if db == nil {
+		return nil, nil
+	}
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("begin key write: %w", err)
+	}
+	if err := lockHeadKey(ctx, tx, tenantID, bucket, key); err != nil {
+		_ = tx.Rollback()
+		return nil, err
+	}
+	return &keyWriteLock{tx: tx}, nil
+}
+
+// headUpsert is atomicHeadUpsertReleasing under the held lock; it commits,
+// releasing the lock.
+func (l *keyWriteLock) headUpsert(ctx context.Context, db *sql.DB, gci chunkManifestReleaser,
+	tenantID, bucket, key string, upsert func(tx *sql.Tx) error) (int64, error) {
+	if l == nil {
+		return atomicHeadUpsertReleasing(ctx, db, gci, tenantID, bucket, key, upsert)
+	}
+	return lockedHeadUpsert(ctx, l.tx, gci, tenantID, bucket, key, upsert)
+}
+
+// querier returns the lock's transaction, or db.
+func (l *keyWriteLock) querier(db *sql.DB) sqlQuerier {
+	if l == nil {
+		return db
+	}
+	return l.tx
+}
+
+func (l *keyWriteLock) commit() error {
+	if l == nil {
+		return nil
+	}
+	return l.tx.Commit()
+}
+
+func (l *keyWriteLock) release() {
+	if l != nil {
+		_ = l.tx.Rollback()
+	}
+}
+
+// precheck evaluates the condition against the head row without locking
+// it, so a request that is bound to fail is refused before its body moves.
+func (c *conditionalWrite) precheck(ctx context.Context) error {
+	var etag string
+	err := c.db.QueryRowContext(ctx, `
+		SELECT etag FROM object_head_cache
+		WHERE tenant_id = $1 AND bucket = $2 AND object_key = $3`,
+		c.tenantID, c.bucket, c.key).Scan(&etag)
+	if err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("check write condition: %w", err)
+	}
+	return c.cond.check(err == nil, etag)
+}
+
+// lock begins the write's transaction, locks the key and rechecks the
+// condition against the locked head row. It is a no-op on a nil
+// *conditionalWrite. The caller must rollback (a no-op after commit).
+func (c *conditionalWrite) lock(ctx context.Context) error {
+	if c == nil {
+		return nil
+	}
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin conditional write: %w", err)
+	}
+	c.tx = tx
+	if err := lockHeadKey(ctx, tx, c.tenantID, c.bucket, c.key); err != nil {
+		c.rollback()
+		return err
+	}
+
+	var etag string
+	err = tx.QueryRowContext(ctx, `
+		SELECT etag, size_bytes, is_chunked, COALESCE(backend_name, '') FROM object_head_cache
+		WHERE tenant_id = $1 AND bucket = $2 AND object_key = $3
+		FOR UPDATE`,
+		c.tenantID, c.bucket, c.key).Scan(&etag, &c.displaced, &c.displacedChunked, &c.displacedBackend)
+	if err != nil && err != sql.ErrNoRows {
+		c.rollback()
+		return fmt.Errorf("lock head-cache row: %w", err)
+	}
+	c.displacedExists = err == nil
+	if err := c.cond.check(c.displacedExists, etag); err != nil {
+		c.rollback()
+		return err
+	}
+	return nil
+}
+
+// artifact is the name the write's body goes to: the staging artifact for
+// a conditional write, key itself otherwise.
+func (c *conditionalWrite) artifact(key string) string {
+	if c == nil {
+		return key
+	}
+	return c.staged
+}
+
+// stageOn records that the body was stored as the staging artifact in
+// container on backend, so headUpsert can promote it onto key. opts are the
+// body's put options, for backends that promote by copying.
+func (c *conditionalWrite) stageOn(eng engine.Engine, backend, container, key string, opts ...engine.PutOption) {
+	if c == nil {
+		return
+	}
+	staged := c.staged
+	aside := staged + ".displaced"
+	ce, _ := eng.(*engine.CoreEngine)
+
+	move := func(ctx context.Context, from, to string, opts ...engine.PutOption) error {
+		if ce != nil {
+			return ce.Rename(ctx, backend, container, from, to, opts...)
+		}
+		rc, err := eng.Get(ctx, container, from)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = rc.Close() }()
+		if _, err := eng.Put(ctx, container, to, rc, opts...); err != nil {
+			return err
+		}
+		_ = eng.Delete(ctx, container, from)
+		return nil
+	}
+	// remove deletes from backend itself: once key is promoted the engine
+	// routes it to backend, wherever the displaced object lives.
+	remove := func(ctx context.Context, name string) {
+		if ce != nil {
+			if d, ok := ce.GetDriver(backend); ok {
+				_ = d.Delete(ctx, container, name)
+				return
+			}
+		}
+		_ = eng.Delete(ctx, container, name)
+	}
+
+	setAside := false
+	c.promote = func(ctx context.Context) error { return move(ctx, staged, key, opts...) }
+	c.discard = func(ctx context.Context) { remove(ctx, staged) }
+	c.setAside = func(ctx context.Context) error {
+		// Only a whole object on this backend is overwritten by promote.
+		if !c.displacedExists || c.displacedChunked || (c.displacedBackend != "" && c.displacedBackend != backend) {
+			return nil
+		}
+		if err := move(ctx, key, aside); err != nil {
+			if isObjectMissingErr(err) {
+				return nil
+			}
+			return err
+		}
+		setAside = true
+		return nil
+	}
+	c.putBack = func(ctx context.Context) {
+		if setAside {
+			if err := move(ctx, aside, key); err == nil {
+				setAside = false
+			}
+			return
+		}
+		remove(ctx, key)
+		if ce != nil && c.displacedExists && c.displacedBackend != "" {
+			ce.HintBackend(container, key, c.displacedBackend)
+		}
+	}
+	c.dropAside = func(ctx context.Context) {
+		if setAside {
+			remove(ctx, aside)
+			setAside = false
+		}
+	}
+}
+
+// abandon removes the staged artifact of a write that will not commit. The
+// request may already be cancelled, so it does not use the request context.
+func (c *conditionalWrite) abandon() {
+	if c == nil || c.discard == nil {
+		return
+	}
+	ctx, cancel := cleanupContext()
+	defer cancel()
+	c.discard(ctx)
+	c.discard = nil
+}
+
+// cleanupContext is for work a write must finish after its request may
+// have been cancelled.
+func cleanupContext() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), 30*time.Second)
+}
+
+// headUpsert is atomicHeadUpsertReleasing for a conditional write: under
+// the key's lock it rechecks the condition, promotes the staged artifact
+// and upserts the head row, then commits. When the condition no longer
+// holds the staged artifact is removed and the key is left untouched; when
+// the upsert or commit fails the displaced object is put back.
+func (c *conditionalWrite) headUpsert(ctx context.Context, db *sql.DB, gci chunkManifestReleaser,
+	tenantID, bucket, key string, upsert func(tx *sql.Tx) error) (int64, error) {
+	if c == nil {
+		return atomicHeadUpsertReleasing(ctx, db, gci, tenantID, bucket, key, upsert)
+	}
+	defer c.rollback()
+
+	if err := c.lock(ctx); err != nil {
+		c.abandon()
+		return 0, err
+	}
+	committed := false
+	if c.promote != nil {
+		if err := c.setAside(ctx); err != nil {
+			c.abandon()
+			return 0, fmt.Errorf("set aside displaced object: %w", err)
+		}
+		if err := c.promote(ctx); err != nil {
+			// A failed rename leaves the staged artifact to abandon.
+			c.putBack(ctx)
+			c.abandon()
+			return 0, fmt.Errorf("promote staged write: %w", err)
+		}
+		c.discard = nil
+		// Runs before the deferred rollback, while the key is still locked.
+		defer func() {
+			cctx, cancel := cleanupContext()
+			defer cancel()
+			if committed {
+				c.dropAside(cctx)
+			} else {
+				c.putBack(cctx)
+			}
+		}()
+	}
+	if err := headUpsertTx(ctx, c.tx, gci, tenantID, bucket, key, c.displacedChunked, upsert); err != nil {
+		return 0, err
+	}
+	if err := c.tx.Commit(); err != nil {
+		return 0, fmt.Errorf("commit conditional write: %w", err)
+	}
+	committed = true
+	return c.displaced, nil
+}
+
+// exec runs a statement in the conditional write's transaction, or on db.
+func (c *conditionalWrite) exec(ctx context.Context, db *sql.DB, query string, args ...interface{}) (sql.Result, error) {
+	if c == nil {
+		return db.ExecContext(ctx, query, args...)
+	}
+	return c.tx.ExecContext(ctx, query, args...)
+}
+
+// queryRow runs a query in the conditional write's transaction, or on db.
+func (c *conditionalWrite) queryRow(ctx context.Context, db *sql.DB, query string, args ...interface{}) *sql.Row {
+	if c == nil {
+		return db.QueryRowContext(ctx, query, args...)
+	}
+	return c.tx.QueryRowContext(ctx, query, args...)
+}
+
+// querier returns the conditional write's transaction, or db.
+func (c *conditionalWrite) querier(db *sql.DB) sqlQuerier {
+	if c == nil {
+		return db
+	}
+	return c.tx
+}
+
+func (c *conditionalWrite) commit() error {
+	if c == nil {
+		return nil
+	}
+	return c.tx.Commit()
+}
+
+func (c *conditionalWrite) rollback() {
+	if c != nil && c.tx != nil {
+		_ = c.tx.Rollback()
+	}
+}
+
+// startConditionalWrite parses the request's write condition and, when it
+// has one, prechecks it. It writes the error response and returns ok=false
+// when the request must stop. No lock is held on return: the caller writes
+// the body to cw.artifact(key), then locks with headUpsert (or lock, for a
+// delete) once every other read is done.
+func startConditionalWrite(w http.ResponseWriter, r *http.Request, logger *zap.Logger, db *sql.DB,
+	tenantID, bucket, key string, allowCreate bool) (cw *conditionalWrite, ok bool) {
+	cond, err := parseWriteCondition(r, allowCreate)
+	if err == nil && cond != nil {
+		if db == nil {
+			err = &writeConditionError{code: ErrNotImplemented,
+				msg: "Conditional writes require the metadata database."}
+		} else {
+			cw = &conditionalWrite{cond: cond, db: db, tenantID: tenantID, bucket: bucket, key: key,
+				staged: conditionalStagingPrefix + uuid.New().String()}
+			err = cw.precheck(r.Context())
+		}
+	}
+	if err != nil {
+		writeConditionalWriteError(w, r, logger, err)
+		return nil, false
+	}
+	return cw, true
+}
+
+// conditionFailed reports whether err is a write condition that did not
+// hold, as opposed to a failure of the write itself.
+func conditionFailed(err error) bool {
+	return errors.Is(err, errWritePreconditionFailed) || errors.Is(err, errWriteNoSuchKey)
+}
+
+// writeConditionalWriteError answers a failed conditional write.
+func writeConditionalWriteError(w http.ResponseWriter, r *http.Request, logger *zap.Logger, err error) {
+	var reqErr *writeConditionError
+	switch {
+	case errors.As(err, &reqErr):
+		WriteS3ErrorWithContext(w, reqErr.code, r.URL.Path, generateRequestID(), WithSuggestion(reqErr.msg))
+	case errors.Is(err, errWritePreconditionFailed):
+		WriteS3Error(w, ErrPreconditionFailed, r.URL.Path, generateRequestID())
+	case errors.Is(err, errWriteNoSuchKey):
+		WriteS3Error(w, ErrNoSuchKey, r.URL.Path, generateRequestID())
+	default:
+		logger.Error("conditional write failed", zap.Error(err))
+		WriteS3Error(w, ErrInternalError, r.URL.Path, generateRequestID())
+	}
+}
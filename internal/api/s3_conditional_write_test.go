@@ -0,0 +1,470 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5" // #nosec G501 — S3 ETags are MD5
+	"database/sql"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestParseWriteCondition(t *testing.T) {
+	tests := []struct {
+		name        string
+		headers     map[string]string
+		allowCreate bool
+		want        *writeCondition
+		wantCode    string
+	}{
+		{"none", nil, true, nil, ""},
+		{"if-none-match star", map[string]string{"If-None-Match": "*"}, true, &writeCondition{IfNoneMatch: true}, ""},
+		{"if-match", map[string]string{"If-Match": `"abc"`}, true, &writeCondition{IfMatch: `"abc"`}, ""},
+		{"if-none-match etag", map[string]string{"If-None-Match": `"abc"`}, true, nil, ErrNotImplemented},
+		{"both", map[string]string{"If-Match": `"abc"`, "If-None-Match": "*"}, true, nil, ErrInvalidArgument},
+		{"delete ignores if-none-match", map[string]string{"If-None-Match": "*"}, false, nil, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("PUT", "/b/k", nil)
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			got, err := parseWriteCondition(req, tt.allowCreate)
+			if tt.wantCode != "" {
+				var reqErr *writeConditionError
+				require.ErrorAs(t, err, &reqErr)
+				assert.Equal(t, tt.wantCode, reqErr.code)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestWriteCondition_Check(t *testing.T) {
+	tests := []struct {
+		name   string
+		cond   writeCondition
+		exists bool
+		etag   string
+		want   error
+	}{
+		{"create when missing", writeCondition{IfNoneMatch: true}, false, "", nil},
+		{"create when present", writeCondition{IfNoneMatch: true}, true, "abc", errWritePreconditionFailed},
+		{"match", writeCondition{IfMatch: `"abc"`}, true, "abc", nil},
+		{"match in list", writeCondition{IfMatch: `"x", "abc"`}, true, "abc", nil},
+		{"mismatch", writeCondition{IfMatch: `"old"`}, true, "abc", errWritePreconditionFailed},
+		{"match missing key", writeCondition{IfMatch: `"abc"`}, false, "", errWriteNoSuchKey},
+		{"match star", writeCondition{IfMatch: "*"}, true, "abc", nil},
+		{"match star missing key", writeCondition{IfMatch: "*"}, false, "", errWriteNoSuchKey},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.cond.check(tt.exists, tt.etag))
+		})
+	}
+}
+
+func TestStartConditionalWrite_PrechecksWithoutLock(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() { _ = db.Close() }()
+
+	mock.ExpectQuery(`SELECT etag FROM object_head_cache`).
+		WithArgs("t1", "bucket", "lock.json").
+		WillReturnRows(sqlmock.NewRows([]string{"etag"}).AddRow("abc"))
+
+	r := httptest.NewRequest("PUT", "/bucket/lock.json", nil)
+	r.Header.Set("If-None-Match", "*")
+	w := httptest.NewRecorder()
+	cw, ok := startConditionalWrite(w, r, zap.NewNop(), db, "t1", "bucket", "lock.json", true)
+	assert.False(t, ok)
+	assert.Nil(t, cw)
+	assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestConditionalWrite_LockRechecks(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() { _ = db.Close() }()
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("SELECT pg_advisory_xact_lock(hashtextextended($1, 0))")).
+		WithArgs("t1/bucket/lock.json").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`SELECT etag, size_bytes, is_chunked, .* FROM object_head_cache .* FOR UPDATE`).
+		WithArgs("t1", "bucket", "lock.json").
+		WillReturnRows(headLockRows().AddRow("abc", 10, false, "local"))
+	mock.ExpectRollback()
+
+	cw := &conditionalWrite{cond: &writeCondition{IfNoneMatch: true}, db: db,
+		tenantID: "t1", bucket: "bucket", key: "lock.json"}
+	assert.ErrorIs(t, cw.lock(context.Background()), errWritePreconditionFailed)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// headLockRows are the columns of the locked head row lock scans.
+func headLockRows() *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"etag", "size_bytes", "is_chunked", "backend_name"})
+}
+
+// stagedWrite is a conditional write whose body is already staged, with
+// promote, discard and the displaced object's moves recording what
+// happened.
+func stagedWrite(db *sql.DB, cond *writeCondition, events *[]string) *conditionalWrite {
+	cw := &conditionalWrite{cond: cond, db: db, tenantID: "t1", bucket: "bucket", key: "lock.json",
+		staged: conditionalStagingPrefix + "x"}
+	record := func(event string) func(context.Context) {
+		return func(context.Context) { *events = append(*events, event) }
+	}
+	cw.promote = func(context.Context) error {
+		*events = append(*events, "promote")
+		return nil
+	}
+	cw.setAside = func(context.Context) error {
+		*events = append(*events, "setAside")
+		return nil
+	}
+	cw.discard = record("discard")
+	cw.putBack = record("putBack")
+	cw.dropAside = record("dropAside")
+	return cw
+}
+
+func TestConditionalWrite_HeadUpsertLosesWithoutTouchingKey(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() { _ = db.Close() }()
+
+	// The key was missing at the precheck, then another writer created it
+	// while this write's body was being staged.
+	mock.ExpectBegin()
+	mock.ExpectExec("pg_advisory_xact_lock").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT etag, size_bytes, is_chunked, .* FROM object_head_cache").
+		WillReturnRows(headLockRows().AddRow("other", 5, false, "local"))
+	mock.ExpectRollback()
+
+	var events []string
+	cw := stagedWrite(db, &writeCondition{IfNoneMatch: true}, &events)
+	_, err = cw.headUpsert(context.Background(), db, nil, "t1", "bucket", "lock.json", func(*sql.Tx) error {
+		events = append(events, "upsert")
+		return nil
+	})
+	assert.ErrorIs(t, err, errWritePreconditionFailed)
+	assert.Equal(t, []string{"discard"}, events, "a losing write must only remove its staged body")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestConditionalWrite_HeadUpsertPromotesUnderLock(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() { _ = db.Close() }()
+
+	mock.ExpectBegin()
+	mock.ExpectExec("pg_advisory_xact_lock").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT etag, size_bytes, is_chunked, .* FROM object_head_cache").
+		WillReturnRows(headLockRows().AddRow("abc", 5, false, "local"))
+	mock.ExpectQuery(`SELECT replication_config FROM buckets`).
+		WillReturnRows(sqlmock.NewRows([]string{"replication_config"}).AddRow(nil))
+	mock.ExpectCommit()
+
+	var events []string
+	cw := stagedWrite(db, &writeCondition{IfMatch: `"abc"`}, &events)
+	displaced, err := cw.headUpsert(context.Background(), db, nil, "t1", "bucket", "lock.json", func(*sql.Tx) error {
+		events = append(events, "upsert")
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), displaced)
+	assert.Equal(t, []string{"setAside", "promote", "upsert", "dropAside"}, events)
+
+	cw.abandon()
+	assert.Equal(t, []string{"setAside", "promote", "upsert", "dropAside"}, events,
+		"a promoted body must not be discarded")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// An upsert that fails after promote must put the displaced object back:
+// the old head row survives the rollback, so its bytes must too.
+func TestConditionalWrite_HeadUpsertFailurePutsBackDisplaced(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() { _ = db.Close() }()
+
+	mock.ExpectBegin()
+	mock.ExpectExec("pg_advisory_xact_lock").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT etag, size_bytes, is_chunked, .* FROM object_head_cache").
+		WillReturnRows(headLockRows().AddRow("abc", 5, false, "local"))
+	mock.ExpectRollback()
+
+	var events []string
+	cw := stagedWrite(db, &writeCondition{IfMatch: `"abc"`}, &events)
+	_, err = cw.headUpsert(context.Background(), db, nil, "t1", "bucket", "lock.json", func(*sql.Tx) error {
+		events = append(events, "upsert")
+		return fmt.Errorf("upsert failed")
+	})
+	require.Error(t, err)
+	assert.Equal(t, []string{"setAside", "promote", "upsert", "putBack"}, events,
+		"the displaced object must be restored, not dropped")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestKeyWriteLock_NilLocksNothing(t *testing.T) {
+	kl, err := lockKeyForWrite(context.Background(), nil, "t1", "bucket", "lock.json")
+	require.NoError(t, err)
+	assert.Nil(t, kl)
+	assert.Nil(t, kl.querier(nil))
+	assert.NoError(t, kl.commit())
+	kl.release()
+}
+
+func TestKeyWriteLock_HoldsLockUntilUpsertCommits(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() { _ = db.Close() }()
+
+	mock.ExpectBegin()
+	mock.ExpectExec("pg_advisory_xact_lock").WithArgs("t1/bucket/lock.json").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT size_bytes, is_chunked FROM object_head_cache .* FOR UPDATE").
+		WillReturnRows(sqlmock.NewRows([]string{"size_bytes", "is_chunked"}).AddRow(7, false))
+	mock.ExpectQuery(`SELECT replication_config FROM buckets`).
+		WillReturnRows(sqlmock.NewRows([]string{"replication_config"}).AddRow(nil))
+	mock.ExpectCommit()
+
+	kl, err := lockKeyForWrite(context.Background(), db, "t1", "bucket", "lock.json")
+	require.NoError(t, err)
+	defer kl.release()
+	upserted := false
+	displaced, err := kl.headUpsert(context.Background(), db, nil, "t1", "bucket", "lock.json", func(*sql.Tx) error {
+		upserted = true
+		return nil
+	})
+	require.NoError(t, err)
+	assert.True(t, upserted)
+	assert.Equal(t, int64(7), displaced)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestConditionalWrite_NilIsUnconditional(t *testing.T) {
+	var cw *conditionalWrite
+	assert.Equal(t, "lock.json", cw.artifact("lock.json"))
+	assert.NoError(t, cw.lock(context.Background()))
+	assert.NoError(t, cw.commit())
+	cw.stageOn(nil, "local", "container", "lock.json")
+	cw.abandon()
+	cw.rollback()
+}
+
+// --- Integration tests (DATABASE_URL) ---
+
+func conditionalPut(f *quotaAccountingFixture, key string, body []byte, header, value string) int {
+	req := httptest.NewRequest("PUT", "/test-bucket/"+key, bytes.NewReader(body))
+	req.ContentLength = int64(len(body))
+	req.Header.Set(header, value)
+	req = req.WithContext(f.ctx(req.Context()))
+	w := httptest.NewRecorder()
+	f.server.handlePutObject(w, req, f.s3Req("test-bucket", key))
+	return w.Code
+}
+
+func headETag(t *testing.T, f *quotaAccountingFixture, key string) string {
+	t.Helper()
+	var etag string
+	require.NoError(t, f.db.QueryRow(`
+		SELECT etag FROM object_head_cache
+		WHERE tenant_id = $1 AND bucket = $2 AND object_key = $3`,
+		f.tenantID, "test-bucket", key).Scan(&etag))
+	return etag
+}
+
+func TestHandlePut_IfNoneMatch_ConcurrentCreatesOnce(t *testing.T) {
+	f := setupQuotaAccountingFixture(t, 100<<20)
+
+	const writers = 8
+	codes := make([]int, writers)
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			codes[i] = conditionalPut(f, "lock.json", []byte(fmt.Sprintf(`{"owner":%d}`, i)), "If-None-Match", "*")
+		}(i)
+	}
+	wg.Wait()
+
+	created := 0
+	for _, code := range codes {
+		switch code {
+		case http.StatusOK:
+			created++
+		default:
+			assert.Equal(t, http.StatusPreconditionFailed, code)
+		}
+	}
+	assert.Equal(t, 1, created, "exactly one If-None-Match writer may create the key")
+}
+
+// interleavingBody runs interleave on the first Read, i.e. while the
+// request's body is being uploaded.
+type interleavingBody struct {
+	r          io.Reader
+	interleave func()
+}
+
+func (b *interleavingBody) Read(p []byte) (int, error) {
+	if b.interleave != nil {
+		b.interleave()
+		b.interleave = nil
+	}
+	return b.r.Read(p)
+}
+
+// An unconditional PUT that lands while an If-None-Match: * body is still
+// uploading wins: the conditional write fails and its bytes never reach the
+// key.
+func TestHandlePut_IfNoneMatch_LosesToUnconditionalPut(t *testing.T) {
+	f := setupQuotaAccountingFixture(t, 100<<20)
+	theirs := []byte(`{"owner":"unconditional"}`)
+	ours := []byte(`{"owner":"conditional"}`)
+
+	req := httptest.NewRequest("PUT", "/test-bucket/lock.json", &interleavingBody{
+		r:          bytes.NewReader(ours),
+		interleave: func() { require.Equal(t, http.StatusOK, f.put(t, "lock.json", theirs)) },
+	})
+	req.ContentLength = int64(len(ours))
+	req.Header.Set("If-None-Match", "*")
+	req = req.WithContext(f.ctx(req.Context()))
+	w := httptest.NewRecorder()
+	f.server.handlePutObject(w, req, f.s3Req("test-bucket", "lock.json"))
+	assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+
+	dir := filepath.Join(f.tempDir, f.tenant.NamespaceContainer("test-bucket"))
+	stored, err := os.ReadFile(filepath.Join(dir, "lock.json")) // #nosec G304 — test path under a temp dir
+	require.NoError(t, err)
+	assert.Equal(t, theirs, stored, "the losing conditional write must not replace the winner's bytes")
+	assert.Equal(t, fmt.Sprintf("%x", md5.Sum(theirs)), headETag(t, f, "lock.json")) // #nosec G401 — S3 ETag
+
+	staged, _ := os.ReadDir(filepath.Join(dir, conditionalStagingPrefix))
+	assert.Empty(t, staged, "the losing write's staged body must be removed")
+}
+
+func TestHandlePut_IfMatch_ConcurrentCompareAndSwap(t *testing.T) {
+	f := setupQuotaAccountingFixture(t, 100<<20)
+
+	require.Equal(t, http.StatusOK, f.put(t, "manifest.json", []byte(`{"v":0}`)))
+	base := headETag(t, f, "manifest.json")
+
+	const writers = 8
+	codes := make([]int, writers)
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			codes[i] = conditionalPut(f, "manifest.json", []byte(fmt.Sprintf(`{"v":%d}`, i+1)), "If-Match", `"`+base+`"`)
+		}(i)
+	}
+	wg.Wait()
+
+	swapped := 0
+	for _, code := range codes {
+		if code == http.StatusOK {
+			swapped++
+		} else {
+			assert.Equal(t, http.StatusPreconditionFailed, code)
+		}
+	}
+	assert.Equal(t, 1, swapped, "exactly one writer may replace the ETag it read")
+	assert.NotEqual(t, base, headETag(t, f, "manifest.json"))
+}
+
+func TestHandlePut_IfMatch_MissingKey404(t *testing.T) {
+	f := setupQuotaAccountingFixture(t, 100<<20)
+
+	assert.Equal(t, http.StatusNotFound, conditionalPut(f, "absent.json", []byte("x"), "If-Match", `"abc"`))
+}
+
+func TestHandleDelete_IfMatch(t *testing.T) {
+	f := setupQuotaAccountingFixture(t, 100<<20)
+
+	require.Equal(t, http.StatusOK, f.put(t, "lease.json", []byte("held")))
+	etag := headETag(t, f, "lease.json")
+
+	del := func(ifMatch string) int {
+		req := httptest.NewRequest("DELETE", "/test-bucket/lease.json", nil)
+		req.Header.Set("If-Match", ifMatch)
+		req = req.WithContext(f.ctx(req.Context()))
+		w := httptest.NewRecorder()
+		f.server.handleDeleteObject(w, req, f.s3Req("test-bucket", "lease.json"))
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusPreconditionFailed, del(`"stale"`))
+	assert.Equal(t, etag, headETag(t, f, "lease.json"), "a failed If-Match must not delete")
+
+	assert.Equal(t, http.StatusNoContent, del(`"`+etag+`"`))
+	assert.Equal(t, int64(0), f.used(t))
+	assert.Equal(t, http.StatusNotFound, del(`"`+etag+`"`))
+}
+
+func TestCompleteMultipart_IfNoneMatch(t *testing.T) {
+	f := setupQuotaAccountingFixture(t, 100<<20)
+
+	require.Equal(t, http.StatusOK, f.put(t, "mp-lock.bin", []byte("existing")))
+
+	initReq := httptest.NewRequest("POST", "/test-bucket/mp-lock.bin?uploads", nil)
+	initReq = initReq.WithContext(f.ctx(initReq.Context()))
+	iw := httptest.NewRecorder()
+	f.server.handleInitiateMultipartUpload(iw, initReq, "test-bucket", "mp-lock.bin")
+	require.Equal(t, http.StatusOK, iw.Code)
+	var initRes InitiateMultipartUploadResult
+	require.NoError(t, xml.Unmarshal(iw.Body.Bytes(), &initRes))
+
+	part := testBytes(1 << 10)
+	pReq := httptest.NewRequest("PUT",
+		fmt.Sprintf("/test-bucket/mp-lock.bin?uploadId=%s&partNumber=1", initRes.UploadID),
+		bytes.NewReader(part))
+	pReq.ContentLength = int64(len(part))
+	pReq = pReq.WithContext(f.ctx(pReq.Context()))
+	pw := httptest.NewRecorder()
+	f.server.handleUploadPart(pw, pReq, "test-bucket", "mp-lock.bin")
+	require.Equal(t, http.StatusOK, pw.Code)
+
+	complete := func() int {
+		cReq := httptest.NewRequest("POST",
+			fmt.Sprintf("/test-bucket/mp-lock.bin?uploadId=%s", initRes.UploadID), nil)
+		cReq.Header.Set("If-None-Match", "*")
+		cReq = cReq.WithContext(f.ctx(cReq.Context()))
+		cw := httptest.NewRecorder()
+		f.server.handleCompleteMultipartUpload(cw, cReq, "test-bucket", "mp-lock.bin")
+		return cw.Code
+	}
+
+	assert.Equal(t, http.StatusPreconditionFailed, complete())
+
+	var status string
+	require.NoError(t, f.db.QueryRow(`SELECT status FROM multipart_uploads WHERE upload_id = $1`,
+		initRes.UploadID).Scan(&status))
+	assert.Equal(t, "active", status, "a failed precondition must leave the upload open")
+
+	// Once the key is gone the same upload completes.
+	require.Equal(t, http.StatusNoContent, f.del(t, "mp-lock.bin"))
+	assert.Equal(t, http.StatusOK, complete())
+}
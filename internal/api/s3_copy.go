@@ -93,6 +93,17 @@ func (s *Server) handleCopyObject(w http.ResponseWriter, r *http.Request, req *S
 	destContainer := t.NamespaceContainer(destBucket)
 
 	directive := r.Header.Get("x-amz-metadata-directive")
+	redirectLocation := r.Header.Get("x-amz-website-redirect-location")
+	if err := validateWebsiteRedirectLocation(redirectLocation); err != nil {
+		WriteS3ErrorWithContext(w, ErrInvalidArgument, r.URL.Path, generateRequestID(),
+			WithSuggestion(err.Error()))
+		return
+	}
+	objectACL, err := objectACLForWrite(r.Context(), s.db, r, t.ID, destBucket)
+	if err != nil {
+		writeACLError(w, r, s.logger, err)
+		return
+	}
 
 	s.logger.Debug("CopyObject",
 		zap.String("tenant_id", t.ID),
@@ -119,7 +130,7 @@ func (s *Server) handleCopyObject(w http.ResponseWriter, r *http.Request, req *S
 			t.ID, srcBucket, srcKey).Scan(&srcSize, &srcEnc, &srcChunked)
 	}
 	if srcChunked && s.gci != nil {
-		s.handleChunkedCopy(w, r, t, srcBucket, srcKey, destBucket, destKey, srcSize, directive)
+		s.handleChunkedCopy(w, r, t, srcBucket, srcKey, destBucket, destKey, srcSize, directive, objectACL)
 		return
 	}
 	if srcEnc != "" || srcChunked {
@@ -185,6 +196,22 @@ func (s *Server) handleCopyObject(w http.ResponseWriter, r *http.Request, req *S
 	hasher := md5.New() // #nosec G401 — S3 spec requires MD5 for ETags
 	tee := io.TeeReader(counter, hasher)
 
+	// The destination key stays locked from the byte write to the head
+	// upsert, as for PutObject (s3_conditional_write.go).
+	kl, err := lockKeyForWrite(r.Context(), s.db, t.ID, destBucket, destKey)
+	if err != nil {
+		if quotaOn {
+			ctx, cancel := quotaCtx(r)
+			s.releaseQuota(ctx, t.ID, reservedBytes)
+			cancel()
+		}
+		s.logger.Error("copy: lock dest key failed", zap.Error(err),
+			zap.String("container", destContainer), zap.String("key", destKey))
+		WriteS3Error(w, ErrInternalError, r.URL.Path, generateRequestID())
+		return
+	}
+	defer kl.release()
+
 	backendName, err := s.engine.Put(r.Context(), destContainer, destKey, tee)
 	if err != nil {
 		if quotaOn {
@@ -219,22 +246,33 @@ func (s *Server) handleCopyObject(w http.ResponseWriter, r *http.Request, req *S
 
 		// atomicHeadUpsert captures the overwritten row's size (WP-1).
 		var dbErr error
-		displacedSize, dbErr = atomicHeadUpsertReleasing(r.Context(), s.db, manifestReleaser(s.gci), t.ID, destBucket, destKey, func(tx *sql.Tx) error {
+		displacedSize, dbErr = kl.headUpsert(r.Context(), s.db, manifestReleaser(s.gci), t.ID, destBucket, destKey, func(tx *sql.Tx) error {
 			// is_chunked=FALSE explicitly: overwriting a chunked destination
 			// must flip the flag (and the releaser above frees its manifest),
-			// or GET keeps reading the stale manifest.
+			// or GET keeps reading the stale manifest. The copy is plaintext,
+			// so an encrypted destination's SSE markers are cleared too.
 			_, execErr := tx.ExecContext(r.Context(), `
 				INSERT INTO object_head_cache
-					(tenant_id, bucket, object_key, size_bytes, etag, content_type, backend_name, is_chunked, updated_at)
-				VALUES ($1, $2, $3, $4, $5, $6, $7, FALSE, $8)
+					(tenant_id, bucket, object_key, size_bytes, etag, content_type, backend_name, is_chunked, website_redirect_location, acl, updated_at)
+				VALUES ($1, $2, $3, $4, $5, $6, $7, FALSE, $9, $10, $8)
 				ON CONFLICT (tenant_id, bucket, object_key) DO UPDATE SET
-					size_bytes   = EXCLUDED.size_bytes,
-					etag         = EXCLUDED.etag,
-					content_type = EXCLUDED.content_type,
-					backend_name = EXCLUDED.backend_name,
-					is_chunked   = FALSE,
-					updated_at   = EXCLUDED.updated_at
-			`, t.ID, destBucket, destKey, counter.n, etag, contentType, backendName, now)
+					size_bytes           = EXCLUDED.size_bytes,
+					etag                 = EXCLUDED.etag,
+					content_type         = EXCLUDED.content_type,
+					backend_name         = EXCLUDED.backend_name,
+					is_chunked           = FALSE,
+					encryption_algorithm = '',
+					sse_segmented        = FALSE,
+					sse_kms_key_id       = NULL,
+					sse_kms_data_key     = NULL,
+					sse_kms_context      = NULL,
+					website_redirect_location = EXCLUDED.website_redirect_location,
+					acl                  = EXCLUDED.acl,
+					part_layout          = NULL,
+					replication_status   = NULL,
+					updated_at           = EXCLUDED.updated_at
+			`, t.ID, destBucket, destKey, counter.n, etag, contentType, backendName, now,
+				nullIfEmpty(redirectLocation), objectACL)
 			return execErr
 		})
 		if dbErr != nil {
@@ -323,7 +361,7 @@ func parseCopySource(source string) (bucket, key string, err error) {
 // handleChunkedPut after review-A).
 func (s *Server) handleChunkedCopy(w http.ResponseWriter, r *http.Request,
 	t *tenant.Tenant, srcBucket, srcKey, destBucket, destKey string,
-	srcSize int64, directive string) {
+	srcSize int64, directive string, objectACL []byte) {
 
 	if srcBucket == destBucket && srcKey == destKey {
 		// AWS requires changed metadata/storage-class for a self-copy; we
@@ -451,8 +489,9 @@ func (s *Server) handleChunkedCopy(w http.ResponseWriter, r *http.Request,
 		}
 		_, execErr := tx.ExecContext(r.Context(), `
 			INSERT INTO object_head_cache
-				(tenant_id, bucket, object_key, size_bytes, etag, content_type, backend_name, metadata, encryption_algorithm, content_disposition, is_chunked, updated_at)
-			VALUES ($1, $2, $3, $4, $5, $6, '', $7, $8, $9, TRUE, NOW())
+				(tenant_id, bucket, object_key, size_bytes, etag, content_type, backend_name, metadata, encryption_algorithm, content_disposition, is_chunked,
+				 website_redirect_location, acl, updated_at)
+			VALUES ($1, $2, $3, $4, $5, $6, '', $7, $8, $9, TRUE, $10, $11, NOW())
 			ON CONFLICT (tenant_id, bucket, object_key) DO UPDATE SET
 				size_bytes            = EXCLUDED.size_bytes,
 				etag                  = EXCLUDED.etag,
@@ -462,9 +501,17 @@ func (s *Server) handleChunkedCopy(w http.ResponseWriter, r *http.Request,
 				encryption_algorithm  = EXCLUDED.encryption_algorithm,
 				content_disposition   = EXCLUDED.content_disposition,
 				is_chunked            = EXCLUDED.is_chunked,
+				sse_segmented         = FALSE,
+				sse_kms_key_id        = NULL,
+				sse_kms_data_key      = NULL,
+				sse_kms_context       = NULL,
+				website_redirect_location = EXCLUDED.website_redirect_location,
+				acl                   = EXCLUDED.acl,
+				part_layout           = NULL,
+				replication_status    = NULL,
 				updated_at            = NOW()
 		`, t.ID, destBucket, destKey, srcMeta.LogicalSize, srcETag, contentType,
-			destUserMeta, srcEncAlgo, destDisposition)
+			destUserMeta, srcEncAlgo, destDisposition, nullIfEmpty(r.Header.Get("x-amz-website-redirect-location")), objectACL)
 		return execErr
 	})
 	if dbErr != nil {
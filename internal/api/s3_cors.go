@@ -0,0 +1,510 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/FairForge/vaultaire/internal/tenant"
+	"go.uber.org/zap"
+)
+
+const (
+	maxCORSBodyBytes = 65536
+	maxCORSRules     = 100
+	maxCORSRuleID    = 255
+)
+
+// corsMethods are the methods a CORSRule may allow.
+var corsMethods = map[string]bool{
+	http.MethodGet:    true,
+	http.MethodPut:    true,
+	http.MethodPost:   true,
+	http.MethodDelete: true,
+	http.MethodHead:   true,
+}
+
+// CORSConfiguration is the S3 XML document for GET/PUT ?cors.
+type CORSConfiguration struct {
+	XMLName xml.Name   `xml:"CORSConfiguration"`
+	Xmlns   string     `xml:"xmlns,attr,omitempty"`
+	Rules   []CORSRule `xml:"CORSRule"`
+}
+
+// CORSRule allows cross-origin requests from matching origins. Origins and
+// allowed headers may contain one "*" wildcard each.
+type CORSRule struct {
+	ID             string   `xml:"ID,omitempty"`
+	AllowedOrigins []string `xml:"AllowedOrigin"`
+	AllowedMethods []string `xml:"AllowedMethod"`
+	AllowedHeaders []string `xml:"AllowedHeader,omitempty"`
+	ExposeHeaders  []string `xml:"ExposeHeader,omitempty"`
+	MaxAgeSeconds  *int     `xml:"MaxAgeSeconds,omitempty"`
+}
+
+func validateCORS(config *CORSConfiguration) error {
+	if len(config.Rules) == 0 {
+		return fmt.Errorf("the CORS configuration must contain at least one CORSRule")
+	}
+	if len(config.Rules) > maxCORSRules {
+		return fmt.Errorf("the CORS configuration may contain at most %d rules", maxCORSRules)
+	}
+	for i, rule := range config.Rules {
+		if len(rule.ID) > maxCORSRuleID {
+			return fmt.Errorf("rule %d: ID is longer than %d characters", i+1, maxCORSRuleID)
+		}
+		if len(rule.AllowedOrigins) == 0 {
+			return fmt.Errorf("rule %d: at least one AllowedOrigin is required", i+1)
+		}
+		if len(rule.AllowedMethods) == 0 {
+			return fmt.Errorf("rule %d: at least one AllowedMethod is required", i+1)
+		}
+		for _, m := range rule.AllowedMethods {
+			if !corsMethods[m] {
+				return fmt.Errorf("rule %d: unsupported AllowedMethod %q; use GET, PUT, POST, DELETE or HEAD", i+1, m)
+			}
+		}
+		for _, o := range rule.AllowedOrigins {
+			if o == "" || strings.Count(o, "*") > 1 {
+				return fmt.Errorf("rule %d: AllowedOrigin %q may contain at most one wildcard", i+1, o)
+			}
+		}
+		for _, h := range rule.AllowedHeaders {
+			if h == "" || strings.Count(h, "*") > 1 {
+				return fmt.Errorf("rule %d: AllowedHeader %q may contain at most one wildcard", i+1, h)
+			}
+		}
+		for _, h := range rule.ExposeHeaders {
+			if h == "" || strings.Contains(h, "*") {
+				return fmt.Errorf("rule %d: ExposeHeader %q must be a header name", i+1, h)
+			}
+		}
+		if rule.MaxAgeSeconds != nil && *rule.MaxAgeSeconds < 0 {
+			return fmt.Errorf("rule %d: MaxAgeSeconds must not be negative", i+1)
+		}
+	}
+	return nil
+}
+
+// corsWildcardMatch reports whether value matches pattern, which may hold
+// one "*" standing for any run of characters. Matching ignores case.
+func corsWildcardMatch(pattern, value string) bool {
+	pattern, value = strings.ToLower(pattern), strings.ToLower(value)
+	prefix, suffix, wild := strings.Cut(pattern, "*")
+	if !wild {
+		return pattern == value
+	}
+	return len(value) >= len(prefix)+len(suffix) &&
+		strings.HasPrefix(value, prefix) && strings.HasSuffix(value, suffix)
+}
+
+func (rule *CORSRule) allowsOrigin(origin string) bool {
+	for _, o := range rule.AllowedOrigins {
+		if corsWildcardMatch(o, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+func (rule *CORSRule) allowsMethod(method string) bool {
+	for _, m := range rule.AllowedMethods {
+		if m == method {
+			return true
+		}
+	}
+	return false
+}
+
+// allowsHeaders reports whether every requested header matches one of the
+// rule's AllowedHeader patterns.
+func (rule *CORSRule) allowsHeaders(headers []string) bool {
+	for _, h := range headers {
+		allowed := false
+		for _, pattern := range rule.AllowedHeaders {
+			if corsWildcardMatch(pattern, h) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return false
+		}
+	}
+	return true
+}
+
+// wildcardOrigin reports whether the rule admits every origin, in which
+// case the response carries "*" rather than echoing the origin.
+func (rule *CORSRule) wildcardOrigin() bool {
+	for _, o := range rule.AllowedOrigins {
+		if o == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// match returns the first rule allowing origin, method and the requested
+// headers, or nil. As in S3, rules are tried in document order.
+func (config *CORSConfiguration) match(origin, method string, headers []string) *CORSRule {
+	for i := range config.Rules {
+		rule := &config.Rules[i]
+		if rule.allowsOrigin(origin) && rule.allowsMethod(method) && rule.allowsHeaders(headers) {
+			return rule
+		}
+	}
+	return nil
+}
+
+// parseCORSRequestHeaders splits Access-Control-Request-Headers into
+// lower-cased header names.
+func parseCORSRequestHeaders(value string) []string {
+	var headers []string
+	for _, h := range strings.Split(value, ",") {
+		if h = strings.TrimSpace(h); h != "" {
+			headers = append(headers, strings.ToLower(h))
+		}
+	}
+	return headers
+}
+
+// addCORSVary marks a response as depending on the CORS request headers, so
+// caches do not serve one origin's answer to another.
+func addCORSVary(w http.ResponseWriter) {
+	w.Header().Add("Vary", "Origin")
+	w.Header().Add("Vary", "Access-Control-Request-Headers")
+	w.Header().Add("Vary", "Access-Control-Request-Method")
+}
+
+// writeCORSRuleHeaders sets the Access-Control-* response headers for a
+// request admitted by rule. requestHeaders is only set for preflights.
+func writeCORSRuleHeaders(w http.ResponseWriter, rule *CORSRule, origin string, requestHeaders []string) {
+	h := w.Header()
+	if rule.wildcardOrigin() {
+		h.Set("Access-Control-Allow-Origin", "*")
+	} else {
+		h.Set("Access-Control-Allow-Origin", origin)
+		h.Set("Access-Control-Allow-Credentials", "true")
+	}
+	h.Set("Access-Control-Allow-Methods", strings.Join(rule.AllowedMethods, ", "))
+	if len(requestHeaders) > 0 {
+		h.Set("Access-Control-Allow-Headers", strings.Join(requestHeaders, ", "))
+	}
+	if len(rule.ExposeHeaders) > 0 {
+		h.Set("Access-Control-Expose-Headers", strings.Join(rule.ExposeHeaders, ", "))
+	}
+	if rule.MaxAgeSeconds != nil {
+		h.Set("Access-Control-Max-Age", strconv.Itoa(*rule.MaxAgeSeconds))
+	}
+}
+
+// evaluateCORSPreflight checks a preflight OPTIONS request against config
+// and sets the response headers. It returns the S3 error code to answer
+// with, or "" when the request is allowed. A nil config allows nothing.
+func evaluateCORSPreflight(w http.ResponseWriter, r *http.Request, config *CORSConfiguration) string {
+	origin := r.Header.Get("Origin")
+	method := r.Header.Get("Access-Control-Request-Method")
+	if origin == "" || method == "" {
+		return ErrCORSBadRequest
+	}
+	if config == nil {
+		return ErrCORSForbidden
+	}
+	addCORSVary(w)
+	headers := parseCORSRequestHeaders(r.Header.Get("Access-Control-Request-Headers"))
+	rule := config.match(origin, method, headers)
+	if rule == nil {
+		return ErrCORSForbidden
+	}
+	writeCORSRuleHeaders(w, rule, origin, headers)
+	return ""
+}
+
+// applyCORSRules decorates an actual (non-preflight) cross-origin request
+// with the headers of the first rule allowing its origin and method.
+func applyCORSRules(w http.ResponseWriter, r *http.Request, config *CORSConfiguration) {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return
+	}
+	addCORSVary(w)
+	if rule := config.match(origin, r.Method, nil); rule != nil {
+		writeCORSRuleHeaders(w, rule, origin, nil)
+	}
+}
+
+// decodeStoredCORS parses a cors_config column value; an empty value means
+// no configuration.
+func decodeStoredCORS(raw sql.NullString) (*CORSConfiguration, error) {
+	if !raw.Valid || raw.String == "" {
+		return nil, nil
+	}
+	var config CORSConfiguration
+	if err := xml.Unmarshal([]byte(raw.String), &config); err != nil {
+		return nil, fmt.Errorf("decode stored CORS config: %w", err)
+	}
+	return &config, nil
+}
+
+func loadBucketCORS(ctx context.Context, db *sql.DB, tenantID, bucket string) (*CORSConfiguration, bool, error) {
+	var raw sql.NullString
+	err := db.QueryRowContext(ctx,
+		`SELECT cors_config FROM buckets WHERE tenant_id = $1 AND name = $2`,
+		tenantID, bucket).Scan(&raw)
+	if err != nil {
+		return nil, false, err
+	}
+	config, err := decodeStoredCORS(raw)
+	if err != nil {
+		return nil, false, err
+	}
+	return config, config != nil, nil
+}
+
+// corsAccessKey returns the access key a request names, from a presigned
+// URL's X-Amz-Credential or a SigV4 Authorization header, or "".
+func corsAccessKey(r *http.Request) string {
+	credential := r.URL.Query().Get("X-Amz-Credential")
+	if credential == "" {
+		_, after, ok := strings.Cut(r.Header.Get("Authorization"), "Credential=")
+		if !ok {
+			return ""
+		}
+		credential = after
+	}
+	accessKey, _, _ := strings.Cut(credential, "/")
+	return accessKey
+}
+
+// corsTenant finds the tenant owning the bucket an unauthenticated request
+// targets: a preflight, or a request that failed authentication. The
+// tenant comes from the access key the request names when there is one,
+// else from the bucket name's first owner. Bucket names are only unique
+// per tenant, so a name another tenant created later must neither answer
+// for the original bucket nor make it ambiguous. It returns "" when no
+// bucket has the name.
+func (s *Server) corsTenant(r *http.Request, bucket string) (string, error) {
+	if s.testMode {
+		if t, err := tenant.FromContext(r.Context()); err == nil && t != nil {
+			return t.ID, nil
+		}
+	}
+	if accessKey := corsAccessKey(r); accessKey != "" {
+		if _, tenantID, _, err := s.lookupSigningCredentials(accessKey); err == nil {
+			return tenantID, nil
+		}
+	}
+
+	var tenantID string
+	err := s.db.QueryRowContext(r.Context(),
+		`SELECT tenant_id FROM buckets WHERE name = $1 ORDER BY created_at, tenant_id LIMIT 1`,
+		bucket).Scan(&tenantID)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return tenantID, err
+}
+
+// handleS3Preflight answers a CORS preflight OPTIONS request on the S3 API
+// from the target bucket's CORS configuration. It runs before
+// authentication, since browsers never send credentials on a preflight.
+func (s *Server) handleS3Preflight(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Origin") == "" || r.Header.Get("Access-Control-Request-Method") == "" {
+		WriteS3Error(w, ErrCORSBadRequest, r.URL.Path, generateRequestID())
+		return
+	}
+
+	var config *CORSConfiguration
+	s3Req, err := NewS3Parser(s.logger).ParseRequest(r)
+	if err == nil && s3Req.Bucket != "" && s.db != nil {
+		tenantID, err := s.corsTenant(r, s3Req.Bucket)
+		if err != nil {
+			s.logger.Error("resolve preflight tenant", zap.Error(err))
+			WriteS3Error(w, ErrInternalError, r.URL.Path, generateRequestID())
+			return
+		}
+		if tenantID != "" {
+			config, _, err = loadBucketCORS(r.Context(), s.db, tenantID, s3Req.Bucket)
+			if err != nil && err != sql.ErrNoRows {
+				s.logger.Error("query bucket CORS config", zap.Error(err))
+				WriteS3Error(w, ErrInternalError, r.URL.Path, generateRequestID())
+				return
+			}
+		}
+	}
+
+	if code := evaluateCORSPreflight(w, r, config); code != "" {
+		WriteS3Error(w, code, r.URL.Path, generateRequestID())
+		return
+	}
+	w.Header().Set("Content-Length", "0")
+	w.WriteHeader(http.StatusOK)
+}
+
+// applyBucketCORS adds the bucket's CORS headers to an authenticated
+// cross-origin request. Lookup failures only cost the headers.
+func (s *Server) applyBucketCORS(w http.ResponseWriter, r *http.Request, tenantID, bucket string) {
+	if s.db == nil || tenantID == "" || bucket == "" || r.Header.Get("Origin") == "" {
+		return
+	}
+	config, ok, err := loadBucketCORS(r.Context(), s.db, tenantID, bucket)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			s.logger.Warn("query bucket CORS config", zap.Error(err))
+		}
+		return
+	}
+	if ok {
+		applyCORSRules(w, r, config)
+	}
+}
+
+// applyErrorCORS adds the target bucket's CORS headers to an error answered
+// before the request authenticated, so a browser can read why it failed.
+func (s *Server) applyErrorCORS(w http.ResponseWriter, r *http.Request) {
+	if s.db == nil || r.Header.Get("Origin") == "" {
+		return
+	}
+	s3Req, err := NewS3Parser(s.logger).ParseRequest(r)
+	if err != nil || s3Req.Bucket == "" {
+		return
+	}
+	tenantID, err := s.corsTenant(r, s3Req.Bucket)
+	if err != nil {
+		s.logger.Warn("resolve bucket owner for CORS", zap.Error(err))
+		return
+	}
+	s.applyBucketCORS(w, r, tenantID, s3Req.Bucket)
+}
+
+func (s *Server) handleGetBucketCors(w http.ResponseWriter, r *http.Request, req *S3Request) {
+	t, err := tenant.FromContext(r.Context())
+	if err != nil || t == nil {
+		WriteS3Error(w, ErrAccessDenied, r.URL.Path, generateRequestID())
+		return
+	}
+
+	if s.db == nil {
+		WriteS3Error(w, ErrNoSuchCORSConfiguration, r.URL.Path, generateRequestID())
+		return
+	}
+
+	config, ok, err := loadBucketCORS(r.Context(), s.db, t.ID, req.Bucket)
+	if err == sql.ErrNoRows {
+		s.writeNoSuchBucket(w, r, t.ID, req.Bucket)
+		return
+	}
+	if err != nil {
+		s.logger.Error("query bucket CORS config", zap.Error(err))
+		WriteS3Error(w, ErrInternalError, r.URL.Path, generateRequestID())
+		return
+	}
+	if !ok {
+		WriteS3Error(w, ErrNoSuchCORSConfiguration, r.URL.Path, generateRequestID())
+		return
+	}
+
+	config.Xmlns = "http://s3.amazonaws.com/doc/2006-03-01/"
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte(xml.Header))
+	_ = xml.NewEncoder(w).Encode(config)
+}
+
+func (s *Server) handlePutBucketCors(w http.ResponseWriter, r *http.Request, req *S3Request) {
+	t, err := tenant.FromContext(r.Context())
+	if err != nil || t == nil {
+		WriteS3Error(w, ErrAccessDenied, r.URL.Path, generateRequestID())
+		return
+	}
+
+	if s.db == nil {
+		WriteS3Error(w, ErrInternalError, r.URL.Path, generateRequestID())
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxCORSBodyBytes))
+	if err != nil {
+		WriteS3Error(w, bodyReadErrorCode(err), r.URL.Path, generateRequestID())
+		return
+	}
+
+	var config CORSConfiguration
+	if err := xml.Unmarshal(body, &config); err != nil {
+		WriteS3Error(w, ErrMalformedXML, r.URL.Path, generateRequestID())
+		return
+	}
+	if err := validateCORS(&config); err != nil {
+		WriteS3ErrorWithContext(w, ErrInvalidArgument, r.URL.Path, generateRequestID(),
+			WithSuggestion(err.Error()))
+		return
+	}
+
+	config.Xmlns = ""
+	stored, err := xml.Marshal(config)
+	if err != nil {
+		s.logger.Error("encode CORS config", zap.Error(err))
+		WriteS3Error(w, ErrInternalError, r.URL.Path, generateRequestID())
+		return
+	}
+
+	result, err := s.db.ExecContext(r.Context(),
+		`UPDATE buckets SET cors_config = $3, updated_at = NOW()
+		 WHERE tenant_id = $1 AND name = $2`,
+		t.ID, req.Bucket, string(stored))
+	if err != nil {
+		s.logger.Error("update bucket CORS config", zap.Error(err))
+		WriteS3Error(w, ErrInternalError, r.URL.Path, generateRequestID())
+		return
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		s.writeNoSuchBucket(w, r, t.ID, req.Bucket)
+		return
+	}
+
+	s.logger.Info("bucket CORS config updated",
+		zap.String("tenant_id", t.ID),
+		zap.String("bucket", req.Bucket),
+		zap.Int("rules", len(config.Rules)))
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *Server) handleDeleteBucketCors(w http.ResponseWriter, r *http.Request, req *S3Request) {
+	t, err := tenant.FromContext(r.Context())
+	if err != nil || t == nil {
+		WriteS3Error(w, ErrAccessDenied, r.URL.Path, generateRequestID())
+		return
+	}
+
+	if s.db == nil {
+		WriteS3Error(w, ErrInternalError, r.URL.Path, generateRequestID())
+		return
+	}
+
+	result, err := s.db.ExecContext(r.Context(),
+		`UPDATE buckets SET cors_config = NULL, updated_at = NOW()
+		 WHERE tenant_id = $1 AND name = $2`,
+		t.ID, req.Bucket)
+	if err != nil {
+		s.logger.Error("delete bucket CORS config", zap.Error(err))
+		WriteS3Error(w, ErrInternalError, r.URL.Path, generateRequestID())
+		return
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		s.writeNoSuchBucket(w, r, t.ID, req.Bucket)
+		return
+	}
+
+	s.logger.Info("bucket CORS config deleted",
+		zap.String("tenant_id", t.ID),
+		zap.String("bucket", req.Bucket))
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,291 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/FairForge/vaultaire/internal/tenant"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testCORSConfig = `<CORSConfiguration xmlns="http://s3.amazonaws.com/doc/2006-03-01/">
+  <CORSRule>
+    <AllowedOrigin>https://*.example.com</AllowedOrigin>
+    <AllowedMethod>PUT</AllowedMethod>
+    <AllowedMethod>GET</AllowedMethod>
+    <AllowedHeader>content-type</AllowedHeader>
+    <AllowedHeader>x-amz-*</AllowedHeader>
+    <ExposeHeader>ETag</ExposeHeader>
+    <MaxAgeSeconds>600</MaxAgeSeconds>
+  </CORSRule>
+  <CORSRule>
+    <AllowedOrigin>*</AllowedOrigin>
+    <AllowedMethod>GET</AllowedMethod>
+  </CORSRule>
+</CORSConfiguration>`
+
+func parseCORSXML(t *testing.T, doc string) *CORSConfiguration {
+	t.Helper()
+	var config CORSConfiguration
+	require.NoError(t, xml.Unmarshal([]byte(doc), &config))
+	return &config
+}
+
+func TestValidateCORS(t *testing.T) {
+	require.NoError(t, validateCORS(parseCORSXML(t, testCORSConfig)))
+
+	tests := []struct {
+		name string
+		doc  string
+	}{
+		{"no rules", `<CORSConfiguration></CORSConfiguration>`},
+		{"no origin", `<CORSConfiguration><CORSRule><AllowedMethod>GET</AllowedMethod></CORSRule></CORSConfiguration>`},
+		{"no method", `<CORSConfiguration><CORSRule><AllowedOrigin>*</AllowedOrigin></CORSRule></CORSConfiguration>`},
+		{"bad method", `<CORSConfiguration><CORSRule><AllowedOrigin>*</AllowedOrigin><AllowedMethod>PATCH</AllowedMethod></CORSRule></CORSConfiguration>`},
+		{"two wildcards", `<CORSConfiguration><CORSRule><AllowedOrigin>https://*.*.com</AllowedOrigin><AllowedMethod>GET</AllowedMethod></CORSRule></CORSConfiguration>`},
+		{"wildcard expose", `<CORSConfiguration><CORSRule><AllowedOrigin>*</AllowedOrigin><AllowedMethod>GET</AllowedMethod><ExposeHeader>x-*</ExposeHeader></CORSRule></CORSConfiguration>`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Error(t, validateCORS(parseCORSXML(t, tt.doc)))
+		})
+	}
+}
+
+func TestCORSConfiguration_Match(t *testing.T) {
+	config := parseCORSXML(t, testCORSConfig)
+
+	tests := []struct {
+		name    string
+		origin  string
+		method  string
+		headers []string
+		want    int // rule index, -1 for no match
+	}{
+		{"wildcard subdomain", "https://app.example.com", "PUT", []string{"content-type", "x-amz-date"}, 0},
+		{"case-insensitive origin", "HTTPS://App.Example.com", "PUT", nil, 0},
+		{"header not allowed", "https://app.example.com", "PUT", []string{"authorization"}, -1},
+		{"falls through to second rule", "https://other.org", "GET", nil, 1},
+		{"method not allowed", "https://other.org", "DELETE", nil, -1},
+		{"bare domain not under wildcard", "https://example.com", "PUT", nil, -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule := config.match(tt.origin, tt.method, tt.headers)
+			if tt.want < 0 {
+				assert.Nil(t, rule)
+				return
+			}
+			assert.Same(t, &config.Rules[tt.want], rule)
+		})
+	}
+}
+
+func TestHandleS3Preflight(t *testing.T) {
+	s, mock := newPolicyTestServer(t)
+	ctx := tenant.WithTenant(context.Background(), &tenant.Tenant{ID: "tenant-1"})
+
+	mock.ExpectQuery(`SELECT cors_config FROM buckets`).
+		WithArgs("tenant-1", "uploads").
+		WillReturnRows(sqlmock.NewRows([]string{"cors_config"}).AddRow(testCORSConfig))
+	r := httptest.NewRequest("OPTIONS", "/uploads/photo.jpg", nil).WithContext(ctx)
+	r.Header.Set("Origin", "https://app.example.com")
+	r.Header.Set("Access-Control-Request-Method", "PUT")
+	r.Header.Set("Access-Control-Request-Headers", "Content-Type, X-Amz-Date")
+	w := httptest.NewRecorder()
+	s.handleS3Request(w, r)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "https://app.example.com", w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "true", w.Header().Get("Access-Control-Allow-Credentials"))
+	assert.Equal(t, "PUT, GET", w.Header().Get("Access-Control-Allow-Methods"))
+	assert.Equal(t, "content-type, x-amz-date", w.Header().Get("Access-Control-Allow-Headers"))
+	assert.Equal(t, "ETag", w.Header().Get("Access-Control-Expose-Headers"))
+	assert.Equal(t, "600", w.Header().Get("Access-Control-Max-Age"))
+	assert.Contains(t, w.Header().Values("Vary"), "Origin")
+
+	// A method no rule allows is refused.
+	mock.ExpectQuery(`SELECT cors_config FROM buckets`).
+		WillReturnRows(sqlmock.NewRows([]string{"cors_config"}).AddRow(testCORSConfig))
+	r = httptest.NewRequest("OPTIONS", "/uploads/photo.jpg", nil).WithContext(ctx)
+	r.Header.Set("Origin", "https://app.example.com")
+	r.Header.Set("Access-Control-Request-Method", "DELETE")
+	w = httptest.NewRecorder()
+	s.handleS3Request(w, r)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), "<Code>"+ErrCORSForbidden+"</Code>")
+	assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+
+	// A bucket without a configuration refuses every preflight.
+	mock.ExpectQuery(`SELECT cors_config FROM buckets`).
+		WillReturnRows(sqlmock.NewRows([]string{"cors_config"}).AddRow(nil))
+	r = httptest.NewRequest("OPTIONS", "/uploads/photo.jpg", nil).WithContext(ctx)
+	r.Header.Set("Origin", "https://app.example.com")
+	r.Header.Set("Access-Control-Request-Method", "GET")
+	w = httptest.NewRecorder()
+	s.handleS3Request(w, r)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	// Missing Origin is a bad request.
+	r = httptest.NewRequest("OPTIONS", "/uploads/photo.jpg", nil).WithContext(ctx)
+	w = httptest.NewRecorder()
+	s.handleS3Request(w, r)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCORSTenant(t *testing.T) {
+	s, mock := newPolicyTestServer(t)
+	s.testMode = false
+
+	// Without credentials the bucket name's first owner answers, however
+	// many tenants created the same name after it.
+	mock.ExpectQuery(`SELECT tenant_id FROM buckets WHERE name = \$1 ORDER BY created_at, tenant_id LIMIT 1`).
+		WithArgs("uploads").
+		WillReturnRows(sqlmock.NewRows([]string{"tenant_id"}).AddRow("tenant-1"))
+	r := httptest.NewRequest("OPTIONS", "/uploads/a", nil)
+	got, err := s.corsTenant(r, "uploads")
+	require.NoError(t, err)
+	assert.Equal(t, "tenant-1", got)
+
+	mock.ExpectQuery(`SELECT tenant_id FROM buckets`).
+		WillReturnRows(sqlmock.NewRows([]string{"tenant_id"}))
+	got, err = s.corsTenant(r, "missing")
+	require.NoError(t, err)
+	assert.Empty(t, got)
+
+	// A named access key decides the tenant outright.
+	mock.ExpectQuery(`SELECT secret_key, id FROM tenants WHERE access_key = \$1`).
+		WithArgs("AKIDTENANT2").
+		WillReturnRows(sqlmock.NewRows([]string{"secret_key", "id"}).AddRow("secret", "tenant-2"))
+	r = httptest.NewRequest("GET", "/uploads/a", nil)
+	r.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential=AKIDTENANT2/20300101/us-east-1/s3/aws4_request, SignedHeaders=host, Signature=00")
+	got, err = s.corsTenant(r, "uploads")
+	require.NoError(t, err)
+	assert.Equal(t, "tenant-2", got)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestApplyErrorCORS(t *testing.T) {
+	s, mock := newPolicyTestServer(t)
+	s.testMode = false
+
+	mock.ExpectQuery(`SELECT tenant_id FROM buckets`).
+		WithArgs("uploads").
+		WillReturnRows(sqlmock.NewRows([]string{"tenant_id"}).AddRow("tenant-1"))
+	mock.ExpectQuery(`SELECT cors_config FROM buckets`).
+		WithArgs("tenant-1", "uploads").
+		WillReturnRows(sqlmock.NewRows([]string{"cors_config"}).AddRow(testCORSConfig))
+
+	r := httptest.NewRequest("GET", "/uploads/a", nil)
+	r.Header.Set("Origin", "https://other.org")
+	w := httptest.NewRecorder()
+	s.applyErrorCORS(w, r)
+	WriteS3Error(w, ErrAccessDenied, r.URL.Path, "req")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Equal(t, "*", w.Header().Get("Access-Control-Allow-Origin"))
+
+	// Same-origin requests need no headers and cost no lookups.
+	r = httptest.NewRequest("GET", "/uploads/a", nil)
+	w = httptest.NewRecorder()
+	s.applyErrorCORS(w, r)
+	assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestApplyBucketCORS_ActualRequest(t *testing.T) {
+	s, mock := newPolicyTestServer(t)
+	mock.ExpectQuery(`SELECT cors_config FROM buckets`).
+		WillReturnRows(sqlmock.NewRows([]string{"cors_config"}).AddRow(testCORSConfig))
+
+	r := httptest.NewRequest("GET", "/uploads/a", nil)
+	r.Header.Set("Origin", "https://other.org")
+	w := httptest.NewRecorder()
+	s.applyBucketCORS(w, r, "tenant-1", "uploads")
+	assert.Equal(t, "*", w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Empty(t, w.Header().Get("Access-Control-Allow-Credentials"))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestBucketCors_PutGetDelete(t *testing.T) {
+	s, mock := newPolicyTestServer(t)
+	ctx := tenant.WithTenant(context.Background(), &tenant.Tenant{ID: "tenant-1"})
+	s3Req := &S3Request{Bucket: "uploads"}
+
+	mock.ExpectExec(`UPDATE buckets SET cors_config`).
+		WithArgs("tenant-1", "uploads", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	r := httptest.NewRequest("PUT", "/uploads?cors", bytes.NewReader([]byte(testCORSConfig))).WithContext(ctx)
+	w := httptest.NewRecorder()
+	s.handlePutBucketCors(w, r, s3Req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	mock.ExpectQuery(`SELECT cors_config FROM buckets`).
+		WillReturnRows(sqlmock.NewRows([]string{"cors_config"}).AddRow(testCORSConfig))
+	r = httptest.NewRequest("GET", "/uploads?cors", nil).WithContext(ctx)
+	w = httptest.NewRecorder()
+	s.handleGetBucketCors(w, r, s3Req)
+	require.Equal(t, http.StatusOK, w.Code)
+	resp := parseCORSXML(t, w.Body.String())
+	require.Len(t, resp.Rules, 2)
+	assert.Equal(t, []string{"PUT", "GET"}, resp.Rules[0].AllowedMethods)
+
+	mock.ExpectExec(`UPDATE buckets SET cors_config = NULL`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	r = httptest.NewRequest("DELETE", "/uploads?cors", nil).WithContext(ctx)
+	w = httptest.NewRecorder()
+	s.handleDeleteBucketCors(w, r, s3Req)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	mock.ExpectQuery(`SELECT cors_config FROM buckets`).
+		WillReturnRows(sqlmock.NewRows([]string{"cors_config"}).AddRow(nil))
+	r = httptest.NewRequest("GET", "/uploads?cors", nil).WithContext(ctx)
+	w = httptest.NewRecorder()
+	s.handleGetBucketCors(w, r, s3Req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Contains(t, w.Body.String(), ErrNoSuchCORSConfiguration)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPutBucketCors_Invalid(t *testing.T) {
+	s, _ := newPolicyTestServer(t)
+	ctx := tenant.WithTenant(context.Background(), &tenant.Tenant{ID: "tenant-1"})
+	body := `<CORSConfiguration><CORSRule><AllowedOrigin>*</AllowedOrigin><AllowedMethod>PATCH</AllowedMethod></CORSRule></CORSConfiguration>`
+	r := httptest.NewRequest("PUT", "/uploads?cors", bytes.NewReader([]byte(body))).WithContext(ctx)
+	w := httptest.NewRecorder()
+	s.handlePutBucketCors(w, r, &S3Request{Bucket: "uploads"})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), ErrInvalidArgument)
+}
+
+func TestHandleCDNCORSPreflight(t *testing.T) {
+	config := parseCORSXML(t, testCORSConfig)
+
+	r := httptest.NewRequest("OPTIONS", "/cdn/acme/uploads/a.jpg", nil)
+	r.Header.Set("Origin", "https://other.org")
+	r.Header.Set("Access-Control-Request-Method", "GET")
+	w := httptest.NewRecorder()
+	handleCDNCORSPreflight(w, r, config)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, "*", w.Header().Get("Access-Control-Allow-Origin"))
+
+	r.Header.Set("Access-Control-Request-Method", "PUT")
+	w = httptest.NewRecorder()
+	handleCDNCORSPreflight(w, r, config)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
+
+func TestDecodeStoredCORS_Empty(t *testing.T) {
+	config, err := decodeStoredCORS(sql.NullString{})
+	require.NoError(t, err)
+	assert.Nil(t, config)
+}
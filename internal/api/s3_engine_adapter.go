@@ -45,6 +45,7 @@ type S3ToEngine struct {
 	logger            *zap.Logger
 	notifySvc         *NotificationDispatcher
 	sseService        *crypto.SSEService
+	kms               kmsConfig
 	chunkEncSvc       *crypto.ChunkEncryptionService
 	gci               *crypto.GlobalContentIndex
 	chunkingThreshold int64 // minimum object size for chunking (default 64 MB)
@@ -109,7 +110,8 @@ func (a *S3ToEngine) TranslateRequest(req *S3Request) engine.Operation {
 // AWS SDK v2. Each chunk is preceded by a hex size line (optionally followed
 // by a semicolon-delimited chunk extension such as a chunk signature), then
 // the payload bytes, then CRLF. A zero-length chunk terminates the stream.
-// Trailing headers (e.g. x-amz-checksum-*) are discarded.
+// Trailing headers (e.g. x-amz-checksum-*) after the terminal chunk are
+// collected into trailers once Read has returned io.EOF.
 //
 // This is distinct from standard HTTP chunked transfer encoding, which Go's
 // net/http server decodes automatically. aws-chunked is an application-level
@@ -119,6 +121,7 @@ type awsChunkedReader struct {
 	r         *bufio.Reader
 	chunkLeft int  // bytes remaining in the current chunk
 	done      bool // true once the terminal 0-size chunk is seen
+	trailers  http.Header
 }
 
 func newAWSChunkedReader(r io.Reader) *awsChunkedReader {
@@ -151,8 +154,9 @@ func (a *awsChunkedReader) Read(p []byte) (int, error) {
 		}
 
 		if size == 0 {
-			// Terminal chunk — drain trailing headers and signal EOF.
+			// Terminal chunk — collect trailing headers and signal EOF.
 			a.done = true
+			a.readTrailers()
 			return 0, io.EOF
 		}
 
@@ -174,6 +178,27 @@ func (a *awsChunkedReader) Read(p []byte) (int, error) {
 	return n, err
 }
 
+// readTrailers parses the "name:value" lines that follow the terminal chunk,
+// up to the blank line that ends the body. The trailer signature is framing,
+// not a header, and is skipped.
+func (a *awsChunkedReader) readTrailers() {
+	a.trailers = make(http.Header)
+	for {
+		line, err := a.r.ReadString('\n')
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			return
+		}
+		if name, value, ok := strings.Cut(line, ":"); ok &&
+			!strings.EqualFold(strings.TrimSpace(name), "x-amz-trailer-signature") {
+			a.trailers.Add(strings.TrimSpace(name), strings.TrimSpace(value))
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
 // isAWSChunked returns true when the request body uses aws-chunked encoding.
 // The AWS SDK v2 signals this via the x-amz-content-sha256 header value or
 // the Content-Encoding header.
@@ -258,6 +283,14 @@ func (a *S3ToEngine) HandleGet(w http.ResponseWriter, r *http.Request, bucket, o
 		}
 	}
 
+	// x-amz-checksum-mode: ENABLED returns the stored full-object checksum.
+	// Like S3, a ranged or versioned read gets none — the stored value only
+	// describes the current object's full bytes.
+	if checksumModeEnabled(r) && reqVersionID == "" && r.Header.Get("Range") == "" {
+		alg, val, typ := lookupObjectChecksum(r.Context(), a.db, t.ID, bucket, artifact)
+		setChecksumHeaders(w, alg, val, typ)
+	}
+
 	var cachedContentType string
 	var cachedSize int64
 	var cachedETag string
@@ -268,13 +301,20 @@ func (a *S3ToEngine) HandleGet(w http.ResponseWriter, r *http.Request, bucket, o
 	var cachedTags []byte
 	var cachedContentDisposition string
 	var cachedIsChunked bool
+	var cachedSSESegmented bool
+	var cachedKMSKeyID, cachedKMSDataKey, cachedKMSContext string
+	var cachedReplicationStatus string
+	var cachedRedirectLocation string
 	var cacheHit bool
 	if a.db != nil {
 		err := a.db.QueryRowContext(r.Context(), `
-			SELECT content_type, size_bytes, etag, updated_at, COALESCE(metadata, '{}'), COALESCE(backend_name, ''), COALESCE(encryption_algorithm, ''), COALESCE(tags, '{}'), COALESCE(content_disposition, ''), is_chunked
+			SELECT content_type, size_bytes, etag, updated_at, COALESCE(metadata, '{}'), COALESCE(backend_name, ''), COALESCE(encryption_algorithm, ''), COALESCE(tags, '{}'), COALESCE(content_disposition, ''), is_chunked, sse_segmented,
+			       COALESCE(sse_kms_key_id, ''), COALESCE(sse_kms_data_key, ''), COALESCE(sse_kms_context, ''), COALESCE(replication_status, ''),
+			       COALESCE(website_redirect_location, '')
 			FROM object_head_cache
 			WHERE tenant_id = $1 AND bucket = $2 AND object_key = $3`,
-			t.ID, bucket, artifact).Scan(&cachedContentType, &cachedSize, &cachedETag, &cachedUpdatedAt, &cachedMetadata, &cachedBackendName, &cachedEncAlgo, &cachedTags, &cachedContentDisposition, &cachedIsChunked)
+			t.ID, bucket, artifact).Scan(&cachedContentType, &cachedSize, &cachedETag, &cachedUpdatedAt, &cachedMetadata, &cachedBackendName, &cachedEncAlgo, &cachedTags, &cachedContentDisposition, &cachedIsChunked, &cachedSSESegmented,
+			&cachedKMSKeyID, &cachedKMSDataKey, &cachedKMSContext, &cachedReplicationStatus, &cachedRedirectLocation)
 		if err == nil {
 			cacheHit = true
 		}
@@ -320,6 +360,8 @@ func (a *S3ToEngine) HandleGet(w http.ResponseWriter, r *http.Request, bucket, o
 	// preflight resolves every chunk before any byte is written, so the 500
 	// is always clean.
 	if cacheHit && cachedIsChunked && a.gci != nil {
+		setReplicationStatusHeader(w, cachedReplicationStatus)
+		setWebsiteRedirectHeader(w, cachedRedirectLocation)
 		chunkErr := a.handleChunkedGet(w, r, t, bucket, artifact,
 			cachedSize, cachedETag, cachedContentType, cachedUpdatedAt,
 			cachedMetadata, cachedTags, cachedContentDisposition, cachedBackendName)
@@ -363,8 +405,22 @@ func (a *S3ToEngine) HandleGet(w http.ResponseWriter, r *http.Request, bucket, o
 	}
 	defer func() { _ = reader.Close() }()
 
+	// Segmented SSE objects decrypt as they stream. Objects written before
+	// the segmented format are single AEAD blobs and must be read whole.
 	var dataReader io.Reader = reader
-	if cachedEncAlgo == crypto.SSECAlgorithm {
+	var stream *crypto.StreamCipher
+	if cachedSSESegmented && cachedEncAlgo != "" {
+		env, openErr := parseKMSEnvelope(cachedKMSKeyID, cachedKMSDataKey, cachedKMSContext)
+		if openErr == nil {
+			stream, openErr = openSSEStream(r.Context(), r, a.sseService, a.kms.provider, t.ID, cachedEncAlgo, env, reader)
+		}
+		if openErr != nil {
+			writeSSEStreamError(w, r, a.logger, openErr)
+			return
+		}
+		dataReader = stream.DecryptRange(reader, cachedSize, 0, cachedSize)
+		setSSEResponseHeaders(w, cachedEncAlgo, cachedKMSKeyID)
+	} else if cachedEncAlgo == crypto.SSECAlgorithm {
 		if !crypto.HasSSECHeaders(r) {
 			WriteS3ErrorWithContext(w, ErrAccessDenied, r.URL.Path, generateRequestID(),
 				WithSuggestion("This object was encrypted with SSE-C. Provide the encryption key."))
@@ -430,44 +486,48 @@ func (a *S3ToEngine) HandleGet(w http.ResponseWriter, r *http.Request, bucket, o
 
 	rangeHeader := r.Header.Get("Range")
 	if rangeHeader != "" && cacheHit && cachedSize > 0 {
-		rng, parseErr := parseRangeHeader(rangeHeader, cachedSize)
+		ranges, parseErr := parseRanges(rangeHeader, cachedSize)
 		if parseErr != nil {
 			writeRangeNotSatisfiable(w, cachedSize)
 			return
 		}
 
-		// Use backend-native range GET when available (avoids downloading the
-		// full object and discarding prefix bytes — 10-50× faster for large files).
-		rangeReader := io.Reader(dataReader)
-		if ce, ok := a.engine.(*engine.CoreEngine); ok {
-			if rr, rangeErr := ce.GetRange(r.Context(), container, artifact, rng.start, rng.length); rangeErr == nil {
-				defer func() { _ = rr.Close() }()
-				rangeReader = rr
-				// rangeReader already positioned at rng.start — write headers and copy directly
-				w.Header().Set("Content-Type", contentType)
-				w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", rng.start, rng.end, cachedSize))
-				w.Header().Set("Content-Length", strconv.FormatInt(rng.length, 10))
-				w.Header().Set("Accept-Ranges", "bytes")
-				w.Header().Set("x-amz-request-id", generateRequestID())
-				if w.Header().Get("x-amz-version-id") == "" {
-					w.Header().Set("x-amz-version-id", "null")
+		// Use backend-native range GET per part when available (avoids
+		// downloading the full object and discarding prefix bytes — 10-50×
+		// faster for large files). Segmented SSE objects fetch and decrypt
+		// only the covering segments; whole-object SSE blobs can only be
+		// decrypted in full, so they (and any part whose range GET fails)
+		// are read from the full-object reader. Ranges are ascending, so
+		// that reader is only ever consumed forward.
+		fromFull := sequentialRanges(dataReader)
+		ce, native := a.engine.(*engine.CoreEngine)
+		native = native && (stream != nil || cachedEncAlgo == "")
+		open := func(rng httpRange) (io.ReadCloser, error) {
+			if native {
+				var rr io.ReadCloser
+				var rangeErr error
+				if stream != nil {
+					rr, rangeErr = sseStreamRange(r.Context(), ce, stream, container, artifact, cachedSize, rng.start, rng.length)
+				} else {
+					rr, rangeErr = ce.GetRange(r.Context(), container, artifact, rng.start, rng.length)
+				}
+				if rangeErr == nil {
+					return rr, nil
 				}
-				w.WriteHeader(http.StatusPartialContent)
-				_, _ = io.CopyN(w, rangeReader, rng.length)
-				return
 			}
+			return fromFull(rng)
 		}
 
-		// Fallback: serveRange with full-object reader (old path)
 		w.Header().Set("x-amz-request-id", generateRequestID())
 		if w.Header().Get("x-amz-version-id") == "" {
 			w.Header().Set("x-amz-version-id", "null")
 		}
-		if err := serveRange(w, rangeReader, rng, cachedSize, contentType); err != nil {
+		if _, err := serveRanges(w, ranges, cachedSize, contentType, open); err != nil {
 			a.logger.Error("range serve failed",
 				zap.Error(err),
 				zap.String("container", container),
-				zap.String("artifact", artifact))
+				zap.String("artifact", artifact),
+				zap.Int("ranges", len(ranges)))
 		}
 		return
 	}
@@ -494,6 +554,8 @@ func (a *S3ToEngine) HandleGet(w http.ResponseWriter, r *http.Request, bucket, o
 		if n := tagCount(cachedTags); n > 0 {
 			w.Header().Set("x-amz-tagging-count", strconv.Itoa(n))
 		}
+		setReplicationStatusHeader(w, cachedReplicationStatus)
+		setWebsiteRedirectHeader(w, cachedRedirectLocation)
 	}
 
 	written, err := io.Copy(w, dataReader)
@@ -593,13 +655,18 @@ func (a *S3ToEngine) HandlePut(w http.ResponseWriter, r *http.Request, bucket, o
 				WriteS3Error(w, ErrObjectLocked, r.URL.Path, generateRequestID())
 				return
 			}
-			if r.Header.Get("If-Match") != "" && checkIfMatch(r, existingETag) {
-				w.WriteHeader(http.StatusPreconditionFailed)
-				return
-			}
 		}
 	}
 
+	// If-None-Match / If-Match: the body is staged and only renamed onto the
+	// key if the condition still holds under the key's lock
+	// (s3_conditional_write.go).
+	cw, ok := startConditionalWrite(w, r, a.logger, a.db, t.ID, bucket, artifact, true)
+	if !ok {
+		return
+	}
+	defer cw.abandon()
+
 	chunked := isAWSChunked(r)
 	a.logger.Debug("PUT with tenant isolation",
 		zap.String("tenant_id", t.ID),
@@ -609,21 +676,68 @@ func (a *S3ToEngine) HandlePut(w http.ResponseWriter, r *http.Request, bucket, o
 		zap.Bool("aws_chunked", chunked),
 		zap.Int64("size", size))
 
+	// Flexible checksum (x-amz-checksum-*): verified against the decoded
+	// plaintext once the body is consumed, then stored with the object.
+	ck, ckErr := parseChecksumRequest(r)
+	if ckErr != nil {
+		WriteS3ErrorWithContext(w, ErrInvalidRequest, r.URL.Path, generateRequestID(),
+			WithSuggestion(ckErr.Error()))
+		return
+	}
+
+	// x-amz-website-redirect-location makes the object a redirect when its
+	// bucket is served as a website (s3_website.go).
+	redirectLocation := r.Header.Get("x-amz-website-redirect-location")
+	if err := validateWebsiteRedirectLocation(redirectLocation); err != nil {
+		WriteS3ErrorWithContext(w, ErrInvalidArgument, r.URL.Path, generateRequestID(),
+			WithSuggestion(err.Error()))
+		return
+	}
+
+	// x-amz-acl / x-amz-grant-* (s3_acl.go); nil for the default ACL.
+	objectACL, aclErr := objectACLForWrite(r.Context(), a.db, r, t.ID, bucket)
+	if aclErr != nil {
+		writeACLError(w, r, a.logger, aclErr)
+		return
+	}
+
 	// Wrap body: decode aws-chunked framing if present, then tee into
 	// MD5 hasher so the ETag is computed in a single streaming pass.
 	// bodyCounter measures the decoded logical bytes actually consumed —
 	// billing must never trust the client-declared size alone.
 	var body io.Reader = r.Body
 	if chunked {
-		body = newAWSChunkedReader(r.Body)
+		cr := newAWSChunkedReader(r.Body)
+		if ck != nil {
+			ck.bind(cr)
+		}
+		body = cr
 	}
 
 	bodyCounter := &countingReader{r: body}
 	hasher := md5.New() // #nosec G401 — S3 spec requires MD5 for ETags
-	hashingBody := io.TeeReader(bodyCounter, hasher)
-
+	var digests io.Writer = hasher
+	if ck != nil {
+		digests = io.MultiWriter(hasher, ck)
+	}
 	metadataSize := size
+
+	// The checksum and the aws-chunked decoded length are checked as the
+	// body ends, so a mismatch fails the backend write instead of
+	// overwriting the existing object with bytes the client did not send.
+	var hashingBody io.Reader = &verifyOnEOF{r: io.TeeReader(bodyCounter, digests), verify: func() error {
+		if chunked && bodyCounter.n != metadataSize {
+			return fmt.Errorf("declared %d, measured %d: %w", metadataSize, bodyCounter.n, errDecodedLengthMismatch)
+		}
+		if ck != nil {
+			_, err := ck.Verify()
+			return err
+		}
+		return nil
+	}}
+
 	var encryptionAlgorithm string
+	var kmsEnv *kmsEnvelope
 
 	// A large object takes the chunked path, which — when per-chunk convergent
 	// encryption is available — encrypts each chunk itself. Whole-object SSE-S3
@@ -631,8 +745,7 @@ func (a *S3ToEngine) HandlePut(w http.ResponseWriter, r *http.Request, bucket, o
 	// (SSE-S3 then per-chunk) and GET, which only peels the per-chunk layer,
 	// returns SSE ciphertext (silent corruption). SSE-S3 is also non-determin-
 	// istic (random KEM ciphertext + nonce), which would defeat the determin-
-	// istic chunk dedup. Objects >256 MiB already skip SSE-S3 via the size cap;
-	// this closes the 64–256 MiB band. (WP-7)
+	// istic chunk dedup. (WP-7)
 	chunkThreshold := a.chunkingThreshold
 	if chunkThreshold <= 0 {
 		chunkThreshold = 64 * 1024 * 1024 // 64 MB default
@@ -665,8 +778,11 @@ func (a *S3ToEngine) HandlePut(w http.ResponseWriter, r *http.Request, bucket, o
 	}
 	chunkingDisabledByTier := resolvedStorageClass == "RESILIENT" ||
 		resolvedStorageClass == "GLACIER" || resolvedStorageClass == "DEEP_ARCHIVE"
-	willChunkEncrypt := a.gci != nil && a.chunkEncSvc != nil &&
-		metadataSize > chunkThreshold && !chunkingDisabledByVersioning && !chunkingDisabledByTier
+	// A conditional write stages one artifact to rename onto the key, which
+	// a chunk manifest is not.
+	chunkingDisabledByCondition := cw != nil
+	willChunkEncrypt := a.gci != nil && a.chunkEncSvc != nil && metadataSize > chunkThreshold &&
+		!chunkingDisabledByVersioning && !chunkingDisabledByTier && !chunkingDisabledByCondition
 
 	if crypto.HasSSECHeaders(r) {
 		if r.Header.Get("x-amz-server-side-encryption") != "" {
@@ -674,96 +790,68 @@ func (a *S3ToEngine) HandlePut(w http.ResponseWriter, r *http.Request, bucket, o
 				WithSuggestion("Cannot use SSE-S3 and SSE-C simultaneously."))
 			return
 		}
-		if size <= 0 || size > crypto.MaxEncryptableSize {
-			WriteS3Error(w, ErrEntityTooLarge, r.URL.Path, generateRequestID())
-			return
-		}
-
 		ssecKey, parseErr := crypto.ParseSSECHeaders(r)
 		if parseErr != nil {
 			WriteS3ErrorWithContext(w, ErrInvalidRequest, r.URL.Path, generateRequestID(),
 				WithSuggestion(parseErr.Error()))
 			return
 		}
-
-		plaintext, readErr := io.ReadAll(hashingBody)
-		if readErr != nil {
-			a.logger.Error("failed to read plaintext for SSE-C encryption", zap.Error(readErr))
-			WriteS3Error(w, bodyReadErrorCode(readErr), r.URL.Path, generateRequestID())
-			return
-		}
-		if chunked && int64(len(plaintext)) != metadataSize {
-			WriteS3Error(w, ErrIncompleteBody, r.URL.Path, generateRequestID())
-			return
+		stream, encErr := crypto.NewSSECStream(ssecKey)
+		for i := range ssecKey {
+			ssecKey[i] = 0
 		}
-
-		ciphertext, encErr := crypto.SSECEncrypt(ssecKey, plaintext)
 		if encErr != nil {
 			a.logger.Error("SSE-C encryption failed", zap.Error(encErr))
 			WriteS3Error(w, ErrInternalError, r.URL.Path, generateRequestID())
 			return
 		}
 
-		for i := range ssecKey {
-			ssecKey[i] = 0
-		}
-
-		hashingBody = bytes.NewReader(ciphertext)
-		size = int64(len(ciphertext))
+		// Segments are sealed as the body streams through, so SSE-C has no
+		// size limit; a short aws-chunked body is caught by the decoded
+		// length check after the put.
+		hashingBody = stream.Encrypt(hashingBody)
+		size = stream.EncryptedSize(metadataSize)
 		encryptionAlgorithm = crypto.SSECAlgorithm
 	} else {
-		shouldEncrypt := a.sseService != nil && !willChunkEncrypt && size > 0 && size <= crypto.MaxEncryptableSize &&
-			(r.Header.Get("x-amz-server-side-encryption") == "AES256" ||
-				isBucketSSEEnabled(r.Context(), a.db, t.ID, bucket))
+		want, sseErr := resolveSSEWrite(r.Context(), r, a.db, a.kms, a.sseService != nil, t.ID, bucket, object)
+		if sseErr != nil {
+			writeSSEStreamError(w, r, a.logger, sseErr)
+			return
+		}
 
-		if shouldEncrypt {
+		// SSE-KMS always applies: the customer named the key. Whole-object
+		// SSE-S3 is skipped for objects that are chunk-encrypted instead.
+		switch {
+		case want.Algorithm == crypto.SSEKMSAlgorithm:
+			stream, envelope, encErr := newKMSStream(r.Context(), a.kms, want)
+			if encErr != nil {
+				writeSSEStreamError(w, r, a.logger, encErr)
+				return
+			}
+			hashingBody = stream.Encrypt(hashingBody)
+			size = stream.EncryptedSize(metadataSize)
+			encryptionAlgorithm = crypto.SSEKMSAlgorithm
+			kmsEnv = envelope
+		case want.Algorithm == crypto.SSEAlgorithm && a.sseService != nil && !willChunkEncrypt:
 			if err := a.sseService.EnsureTenantKey(r.Context(), t.ID); err != nil {
 				a.logger.Error("failed to ensure tenant encryption key", zap.Error(err))
 				WriteS3Error(w, ErrInternalError, r.URL.Path, generateRequestID())
 				return
 			}
 
-			plaintext, readErr := io.ReadAll(hashingBody)
-			if readErr != nil {
-				a.logger.Error("failed to read plaintext for encryption", zap.Error(readErr))
-				WriteS3Error(w, bodyReadErrorCode(readErr), r.URL.Path, generateRequestID())
-				return
-			}
-			if chunked && int64(len(plaintext)) != metadataSize {
-				WriteS3Error(w, ErrIncompleteBody, r.URL.Path, generateRequestID())
-				return
-			}
-
-			ciphertext, encErr := a.sseService.EncryptBytes(r.Context(), t.ID, plaintext)
+			stream, encErr := a.sseService.NewStream(r.Context(), t.ID)
 			if encErr != nil {
 				a.logger.Error("SSE-S3 encryption failed", zap.Error(encErr))
 				WriteS3Error(w, ErrInternalError, r.URL.Path, generateRequestID())
 				return
 			}
 
-			hashingBody = bytes.NewReader(ciphertext)
-			size = int64(len(ciphertext))
+			hashingBody = stream.Encrypt(hashingBody)
+			size = stream.EncryptedSize(metadataSize)
 			encryptionAlgorithm = crypto.SSEAlgorithm
 		}
 	}
 
-	// Encryption-required guard: SSE-S3 encrypts the whole object in memory and
-	// is capped at crypto.MaxEncryptableSize. When encryption is required (an
-	// explicit x-amz-server-side-encryption header, or a bucket that defaults to
-	// SSE) but the object exceeds that cap, SSE was skipped above
-	// (encryptionAlgorithm == ""). Silently storing such an object as plaintext
-	// would violate the bucket's encryption guarantee, so reject it instead.
-	// (SSE-C already rejects oversize objects in its own branch above.)
-	// When chunkEncSvc is set, per-chunk convergent encryption handles oversize
-	// objects via the chunked path — no 256 MiB limit applies.
-	if a.sseService != nil && a.chunkEncSvc == nil && encryptionAlgorithm == "" && metadataSize > crypto.MaxEncryptableSize &&
-		(r.Header.Get("x-amz-server-side-encryption") == "AES256" ||
-			isBucketSSEEnabled(r.Context(), a.db, t.ID, bucket)) {
-		WriteS3ErrorWithContext(w, ErrEntityTooLarge, r.URL.Path, generateRequestID(),
-			WithSuggestion("Objects larger than 256 MiB cannot yet be server-side encrypted. Upload without encryption, or split the object."))
-		return
-	}
-
 	// Chunked upload path: objects above the threshold are split into
 	// content-defined chunks and deduplicated via the GCI. When chunkEncSvc
 	// is set, per-chunk convergent encryption is applied (Phase 10) —
@@ -778,12 +866,12 @@ func (a *S3ToEngine) HandlePut(w http.ResponseWriter, r *http.Request, bucket, o
 	// happens INSIDE handleChunkedPut on plaintext; whole-object SSE-S3 was
 	// deliberately skipped above (willChunkEncrypt) for bodies heading here.
 	if a.gci != nil && metadataSize > chunkThreshold && !chunkingDisabledByVersioning &&
-		!chunkingDisabledByTier && encryptionAlgorithm == "" && a.chunkingEnabled(t.ID) {
+		!chunkingDisabledByTier && !chunkingDisabledByCondition && encryptionAlgorithm == "" && a.chunkingEnabled(t.ID) {
 		{
 			// WP-C: no uuid.Parse gate — tenant IDs are strings ("tenant-<hex>"
 			// from registration). The old gate silently skipped chunking for
 			// every real tenant.
-			chunkErr := a.handleChunkedPut(r, w, t, t.ID, bucket, artifact, metadataSize, hashingBody, hasher)
+			chunkErr := a.handleChunkedPut(r, w, t, t.ID, bucket, artifact, metadataSize, hashingBody, hasher, ck, objectACL)
 			if chunkErr == nil {
 				return
 			}
@@ -791,9 +879,10 @@ func (a *S3ToEngine) HandlePut(w http.ResponseWriter, r *http.Request, bucket, o
 				zap.Error(chunkErr),
 				zap.String("bucket", bucket),
 				zap.String("key", artifact))
+			if writeBodyVerifyError(w, r, ck, chunkErr) {
+				return
+			}
 			switch {
-			case errors.Is(chunkErr, errDecodedLengthMismatch):
-				WriteS3Error(w, ErrIncompleteBody, r.URL.Path, generateRequestID())
 			case errors.Is(chunkErr, engine.ErrAllBackendsUnavailable):
 				WriteS3Error(w, ErrServiceUnavailable, r.URL.Path, generateRequestID())
 			default:
@@ -803,6 +892,21 @@ func (a *S3ToEngine) HandlePut(w http.ResponseWriter, r *http.Request, bucket, o
 		}
 	}
 
+	// An unconditional write holds the key's lock from its byte write to
+	// its head upsert; a conditional one stages unlocked and locks in
+	// headUpsert (s3_conditional_write.go).
+	var kl *keyWriteLock
+	if cw == nil {
+		kl, err = lockKeyForWrite(r.Context(), a.db, t.ID, bucket, artifact)
+		if err != nil {
+			a.logger.Error("lock key for write failed", zap.Error(err),
+				zap.String("tenant_id", t.ID), zap.String("bucket", bucket), zap.String("key", artifact))
+			WriteS3Error(w, ErrInternalError, r.URL.Path, generateRequestID())
+			return
+		}
+		defer kl.release()
+	}
+
 	storageClass := resolvedStorageClass // header ?: bucket tier, computed above
 	putOpts := []engine.PutOption{engine.WithContentLength(size)}
 	if storageClass != "" {
@@ -816,7 +920,10 @@ func (a *S3ToEngine) HandlePut(w http.ResponseWriter, r *http.Request, bucket, o
 	if regionDriver != "" {
 		if ce, ok := a.engine.(*engine.CoreEngine); ok {
 			if drv, exists := ce.GetDriver(regionDriver); exists {
-				putErr := drv.Put(r.Context(), container, artifact, hashingBody, putOpts...)
+				putErr := drv.Put(r.Context(), container, cw.artifact(artifact), hashingBody, putOpts...)
+				if writeBodyVerifyError(w, r, ck, putErr) {
+					return
+				}
 				if putErr != nil {
 					a.logger.Error("region driver put failed",
 						zap.Error(putErr),
@@ -825,15 +932,21 @@ func (a *S3ToEngine) HandlePut(w http.ResponseWriter, r *http.Request, bucket, o
 					return
 				}
 				backendName = regionDriver
-				ce.HintBackend(container, artifact, regionDriver)
+				ce.HintBackend(container, cw.artifact(artifact), regionDriver)
 			}
 		}
 	}
 	if backendName == "" {
 		var putErr error
-		backendName, putErr = a.engine.Put(r.Context(), container, artifact, hashingBody, putOpts...)
+		backendName, putErr = a.engine.Put(r.Context(), container, cw.artifact(artifact), hashingBody, putOpts...)
 		err = putErr
 	}
+	if err == nil {
+		cw.stageOn(a.engine, backendName, container, artifact, putOpts...)
+	}
+	if writeBodyVerifyError(w, r, ck, err) {
+		return
+	}
 	if err != nil {
 		switch {
 		case errors.Is(err, engine.ErrAllBackendsUnavailable):
@@ -857,6 +970,8 @@ func (a *S3ToEngine) HandlePut(w http.ResponseWriter, r *http.Request, bucket, o
 	// reject when the measured decoded bytes disagree, otherwise the stored
 	// object and its billing record would carry a client-invented size.
 	// (Plain Content-Length bodies are length-enforced by the HTTP server.)
+	// verifyOnEOF already failed the put for a body read to its end; this
+	// catches a backend that stopped reading at the declared length.
 	if chunked && bodyCounter.n != metadataSize {
 		a.logger.Warn("aws-chunked decoded length mismatch",
 			zap.String("tenant_id", t.ID),
@@ -866,6 +981,23 @@ func (a *S3ToEngine) HandlePut(w http.ResponseWriter, r *http.Request, bucket, o
 		return
 	}
 
+	// Same rule for the flexible checksum: bytes that do not match what the
+	// client declared are never indexed.
+	var checksumAlgorithm, checksumValue string
+	if ck != nil {
+		v, verr := ck.Verify()
+		if verr != nil {
+			a.logger.Warn("flexible checksum mismatch",
+				zap.String("tenant_id", t.ID),
+				zap.String("algorithm", ck.Algorithm),
+				zap.Error(verr))
+			WriteS3ErrorWithContext(w, ErrBadDigest, r.URL.Path, generateRequestID(),
+				WithSuggestion(ck.mismatchSuggestion()))
+			return
+		}
+		checksumAlgorithm, checksumValue = ck.Algorithm, v
+	}
+
 	etag := fmt.Sprintf("%x", hasher.Sum(nil))
 	a.putLogicalBytes = metadataSize
 
@@ -884,14 +1016,23 @@ func (a *S3ToEngine) HandlePut(w http.ResponseWriter, r *http.Request, bucket, o
 	metaJSON, _ := json.Marshal(userMeta)
 
 	if a.db != nil {
+		kmsKeyID, kmsDataKey, kmsContext := kmsEnv.dbValues()
 		// atomicHeadUpsert locks the previous row and returns its size in
 		// the same transaction as the upsert, so the overwritten bytes are
 		// captured atomically — a concurrent DELETE cannot double-release.
-		displaced, dbErr := atomicHeadUpsertReleasing(r.Context(), a.db, manifestReleaser(a.gci), t.ID, bucket, artifact, func(tx *sql.Tx) error {
+		// A conditional write promotes its staged body in that transaction;
+		// an unconditional one commits the transaction holding its lock.
+		headUpsert := cw.headUpsert
+		if kl != nil {
+			headUpsert = kl.headUpsert
+		}
+		displaced, dbErr := headUpsert(r.Context(), a.db, manifestReleaser(a.gci), t.ID, bucket, artifact, func(tx *sql.Tx) error {
 			_, execErr := tx.ExecContext(r.Context(), `
 				INSERT INTO object_head_cache
-					(tenant_id, bucket, object_key, size_bytes, etag, content_type, backend_name, metadata, encryption_algorithm, content_disposition, is_chunked, updated_at)
-				VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, FALSE, NOW())
+					(tenant_id, bucket, object_key, size_bytes, etag, content_type, backend_name, metadata, encryption_algorithm, content_disposition, is_chunked,
+					 checksum_algorithm, checksum_value, checksum_type, sse_segmented,
+					 sse_kms_key_id, sse_kms_data_key, sse_kms_context, website_redirect_location, acl, updated_at)
+				VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, FALSE, $11, $12, $13, $14, $15, $16, $17, $18, $19, NOW())
 				ON CONFLICT (tenant_id, bucket, object_key) DO UPDATE SET
 					size_bytes            = EXCLUDED.size_bytes,
 					etag                  = EXCLUDED.etag,
@@ -901,11 +1042,30 @@ func (a *S3ToEngine) HandlePut(w http.ResponseWriter, r *http.Request, bucket, o
 					encryption_algorithm  = EXCLUDED.encryption_algorithm,
 					content_disposition   = EXCLUDED.content_disposition,
 					is_chunked            = EXCLUDED.is_chunked,
+					checksum_algorithm    = EXCLUDED.checksum_algorithm,
+					checksum_value        = EXCLUDED.checksum_value,
+					checksum_type         = EXCLUDED.checksum_type,
+					sse_segmented         = EXCLUDED.sse_segmented,
+					sse_kms_key_id        = EXCLUDED.sse_kms_key_id,
+					sse_kms_data_key      = EXCLUDED.sse_kms_data_key,
+					sse_kms_context       = EXCLUDED.sse_kms_context,
+					website_redirect_location = EXCLUDED.website_redirect_location,
+					acl                   = EXCLUDED.acl,
+					part_layout           = NULL,
+					replication_status    = NULL,
 					updated_at            = NOW()
-			`, t.ID, bucket, artifact, metadataSize, etag, contentType, backendName, metaJSON, encryptionAlgorithm, contentDisposition)
+			`, t.ID, bucket, artifact, metadataSize, etag, contentType, backendName, metaJSON, encryptionAlgorithm, contentDisposition,
+				nullIfEmpty(checksumAlgorithm), nullIfEmpty(checksumValue), nullIfEmpty(checksumTypeFor(checksumAlgorithm)),
+				encryptionAlgorithm != "", kmsKeyID, kmsDataKey, kmsContext, nullIfEmpty(redirectLocation), objectACL)
 			return execErr
 		})
 		a.displacedBytes = displaced
+		if conditionFailed(dbErr) {
+			a.displacedBytes = 0
+			a.putLogicalBytes = 0
+			writeConditionalWriteError(w, r, a.logger, dbErr)
+			return
+		}
 		if dbErr != nil {
 			// HEAD serves exclusively from object_head_cache — returning 200
 			// without the row means every subsequent HEAD/GET 404s and the
@@ -952,11 +1112,8 @@ func (a *S3ToEngine) HandlePut(w http.ResponseWriter, r *http.Request, bucket, o
 
 	w.Header().Set("ETag", fmt.Sprintf(`"%s"`, etag))
 	w.Header().Set("x-amz-request-id", generateRequestID())
-	if encryptionAlgorithm == crypto.SSECAlgorithm {
-		w.Header().Set("x-amz-server-side-encryption-customer-algorithm", "AES256")
-	} else if encryptionAlgorithm != "" {
-		w.Header().Set("x-amz-server-side-encryption", "AES256")
-	}
+	setChecksumHeaders(w, checksumAlgorithm, checksumValue, checksumTypeFullObject)
+	setSSEResponseHeaders(w, encryptionAlgorithm, envelopeKeyID(kmsEnv))
 	if versionID != "" {
 		w.Header().Set("x-amz-version-id", versionID)
 	}
@@ -1002,6 +1159,8 @@ func (a *S3ToEngine) handleChunkedPut(
 	metadataSize int64,
 	hashingBody io.Reader,
 	hasher hash.Hash,
+	ck *requestChecksum,
+	objectACL []byte,
 ) error {
 	ctx := r.Context()
 
@@ -1159,6 +1318,14 @@ func (a *S3ToEngine) handleChunkedPut(
 		return fmt.Errorf("declared %d, measured %d: %w",
 			metadataSize, measuredSize, errDecodedLengthMismatch)
 	}
+	var checksumAlgorithm, checksumValue string
+	if ck != nil {
+		v, verr := ck.Verify()
+		if verr != nil {
+			return verr
+		}
+		checksumAlgorithm, checksumValue = ck.Algorithm, v
+	}
 
 	// physicalSize stays truthful: 0 means fully deduplicated — this upload
 	// added no new physical bytes. (It was previously forced to measuredSize,
@@ -1206,8 +1373,9 @@ func (a *S3ToEngine) handleChunkedPut(
 		}
 		_, execErr := tx.ExecContext(ctx, `
 			INSERT INTO object_head_cache
-				(tenant_id, bucket, object_key, size_bytes, etag, content_type, backend_name, metadata, encryption_algorithm, content_disposition, is_chunked, updated_at)
-			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, TRUE, NOW())
+				(tenant_id, bucket, object_key, size_bytes, etag, content_type, backend_name, metadata, encryption_algorithm, content_disposition, is_chunked,
+				 checksum_algorithm, checksum_value, checksum_type, website_redirect_location, acl, updated_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, TRUE, $11, $12, $13, $14, $15, NOW())
 			ON CONFLICT (tenant_id, bucket, object_key) DO UPDATE SET
 				size_bytes            = EXCLUDED.size_bytes,
 				etag                  = EXCLUDED.etag,
@@ -1217,8 +1385,21 @@ func (a *S3ToEngine) handleChunkedPut(
 				encryption_algorithm  = EXCLUDED.encryption_algorithm,
 				content_disposition   = EXCLUDED.content_disposition,
 				is_chunked            = EXCLUDED.is_chunked,
+				sse_segmented         = FALSE,
+				sse_kms_key_id        = NULL,
+				sse_kms_data_key      = NULL,
+				sse_kms_context       = NULL,
+				checksum_algorithm    = EXCLUDED.checksum_algorithm,
+				checksum_value        = EXCLUDED.checksum_value,
+				checksum_type         = EXCLUDED.checksum_type,
+				website_redirect_location = EXCLUDED.website_redirect_location,
+				acl                   = EXCLUDED.acl,
+				part_layout           = NULL,
+				replication_status    = NULL,
 				updated_at            = NOW()
-		`, t.ID, bucket, artifact, measuredSize, etag, contentType, backendName, metaJSON, chunkEncAlgo, contentDisposition)
+		`, t.ID, bucket, artifact, measuredSize, etag, contentType, backendName, metaJSON, chunkEncAlgo, contentDisposition,
+			nullIfEmpty(checksumAlgorithm), nullIfEmpty(checksumValue), nullIfEmpty(checksumTypeFor(checksumAlgorithm)),
+			nullIfEmpty(r.Header.Get("x-amz-website-redirect-location")), objectACL)
 		return execErr
 	})
 	a.displacedBytes = displaced
@@ -1273,6 +1454,7 @@ func (a *S3ToEngine) handleChunkedPut(
 
 	w.Header().Set("ETag", fmt.Sprintf(`"%s"`, etag))
 	w.Header().Set("x-amz-request-id", generateRequestID())
+	setChecksumHeaders(w, checksumAlgorithm, checksumValue, checksumTypeFullObject)
 	if versionID != "" {
 		w.Header().Set("x-amz-version-id", versionID)
 	}
@@ -1398,6 +1580,43 @@ func (a *S3ToEngine) fetchAndVerifyChunk(ctx context.Context, d chunkDesc, tenan
 	return data, nil
 }
 
+// resolveChunkDesc looks up one chunk's location in the index without
+// reading data. The returned desc has a zero offset; callers position it.
+func (a *S3ToEngine) resolveChunkDesc(ctx context.Context, scope, plaintextHash string, refCiphertextHash *string) (chunkDesc, error) {
+	if scope == "" {
+		scope = crypto.GlobalDedupScope
+	}
+	lookup, err := a.gci.LookupChunk(ctx, scope, plaintextHash)
+	if err != nil {
+		return chunkDesc{}, fmt.Errorf("lookup chunk %s: %w", plaintextHash[:16], err)
+	}
+	if lookup == nil || lookup.Entry == nil {
+		return chunkDesc{}, fmt.Errorf("chunk %s missing from index", plaintextHash[:16])
+	}
+	storageKey := lookup.Entry.StorageKey
+	if storageKey == "" {
+		storageKey = "_chunks/" + plaintextHash
+	}
+	// The GCI row's ciphertext hash is authoritative (it was computed from
+	// the blob actually stored); per-ref copies are a fallback for rows
+	// written before the hash lived on the index.
+	var ctHash string
+	if lookup.Entry.CiphertextHash != nil {
+		ctHash = *lookup.Entry.CiphertextHash
+	} else if refCiphertextHash != nil {
+		ctHash = *refCiphertextHash
+	}
+	return chunkDesc{
+		storageKey:     storageKey,
+		backendID:      lookup.Entry.BackendID,
+		plaintextHash:  plaintextHash,
+		size:           lookup.Entry.SizeBytes,
+		compressed:     lookup.Entry.CompressionAlgo != nil,
+		encrypted:      lookup.Entry.Encrypted,
+		ciphertextHash: ctHash,
+	}, nil
+}
+
 // handleChunkedGet serves a chunked object by streaming its content-defined
 // chunks one at a time, in chunk_index order, directly to the response writer.
 // Each chunk is read into a bounded buffer (~16 MB max) and integrity-verified
@@ -1445,40 +1664,12 @@ func (a *S3ToEngine) handleChunkedGet(
 	// error so HandleGet falls through (→ NoSuchKey) before any byte is written.
 	descs := make([]chunkDesc, len(refs))
 	for i, ref := range refs {
-		scope := ref.DedupScope
-		if scope == "" {
-			scope = crypto.GlobalDedupScope
-		}
-		lookup, lookupErr := a.gci.LookupChunk(ctx, scope, ref.PlaintextHash)
-		if lookupErr != nil {
-			return fmt.Errorf("lookup chunk %s: %w", ref.PlaintextHash[:16], lookupErr)
-		}
-		if lookup == nil || lookup.Entry == nil {
-			return fmt.Errorf("chunk %s missing from index", ref.PlaintextHash[:16])
-		}
-		storageKey := lookup.Entry.StorageKey
-		if storageKey == "" {
-			storageKey = "_chunks/" + ref.PlaintextHash
-		}
-		// The GCI row's ciphertext hash is authoritative (it was computed from
-		// the blob actually stored); per-ref copies are a fallback for rows
-		// written before the hash lived on the index.
-		var ctHash string
-		if lookup.Entry.CiphertextHash != nil {
-			ctHash = *lookup.Entry.CiphertextHash
-		} else if ref.CiphertextHash != nil {
-			ctHash = *ref.CiphertextHash
-		}
-		descs[i] = chunkDesc{
-			storageKey:     storageKey,
-			backendID:      lookup.Entry.BackendID,
-			plaintextHash:  ref.PlaintextHash,
-			offset:         ref.ChunkOffset,
-			size:           lookup.Entry.SizeBytes,
-			compressed:     lookup.Entry.CompressionAlgo != nil,
-			encrypted:      lookup.Entry.Encrypted,
-			ciphertextHash: ctHash,
+		d, resolveErr := a.resolveChunkDesc(ctx, ref.DedupScope, ref.PlaintextHash, ref.CiphertextHash)
+		if resolveErr != nil {
+			return resolveErr
 		}
+		d.offset = ref.ChunkOffset
+		descs[i] = d
 	}
 
 	contentType := cachedContentType
@@ -1499,43 +1690,50 @@ func (a *S3ToEngine) handleChunkedGet(
 
 	// Build the byte plan: which chunks to read and the (skip, take) slice within
 	// each. Full GET takes every chunk whole; a range takes only overlapping
-	// chunks, trimmed to the requested bounds.
+	// chunks, trimmed to the requested bounds. With several ranges, part
+	// indexes the range a slice belongs to, and a chunk shared by two
+	// neighbouring ranges is fetched once (reuse).
 	type chunkSlice struct {
-		desc chunkDesc
-		skip int64
-		take int64
+		desc  chunkDesc
+		skip  int64
+		take  int64
+		part  int
+		reuse bool
 	}
 	var (
 		plan    []chunkSlice
-		rng     *httpRange
+		ranges  []httpRange
 		isRange bool
 	)
 	if rh := r.Header.Get("Range"); rh != "" && cachedSize > 0 {
-		parsed, parseErr := parseRangeHeader(rh, cachedSize)
+		parsed, parseErr := parseRanges(rh, cachedSize)
 		if parseErr != nil {
 			writeRangeNotSatisfiable(w, cachedSize)
 			return nil
 		}
-		rng = parsed
+		ranges = parsed
 		isRange = true
-		for _, d := range descs {
-			chunkStart := d.offset
-			chunkEnd := d.offset + d.size - 1
-			if chunkEnd < rng.start {
-				continue
-			}
-			if chunkStart > rng.end {
-				break
-			}
-			skip := int64(0)
-			if rng.start > chunkStart {
-				skip = rng.start - chunkStart
-			}
-			takeEnd := chunkEnd
-			if rng.end < takeEnd {
-				takeEnd = rng.end
+		for part, rng := range ranges {
+			for _, d := range descs {
+				chunkStart := d.offset
+				chunkEnd := d.offset + d.size - 1
+				if chunkEnd < rng.start {
+					continue
+				}
+				if chunkStart > rng.end {
+					break
+				}
+				skip := int64(0)
+				if rng.start > chunkStart {
+					skip = rng.start - chunkStart
+				}
+				takeEnd := chunkEnd
+				if rng.end < takeEnd {
+					takeEnd = rng.end
+				}
+				reuse := len(plan) > 0 && plan[len(plan)-1].desc.offset == d.offset
+				plan = append(plan, chunkSlice{desc: d, skip: skip, take: takeEnd - (chunkStart + skip) + 1, part: part, reuse: reuse})
 			}
-			plan = append(plan, chunkSlice{desc: d, skip: skip, take: takeEnd - (chunkStart + skip) + 1})
 		}
 	} else {
 		for _, d := range descs {
@@ -1566,10 +1764,18 @@ func (a *S3ToEngine) handleChunkedGet(
 			w.Header().Set("x-amz-tagging-count", strconv.Itoa(n))
 		}
 	}
+	var br *byteranges
+	if len(ranges) > 1 {
+		br = newByteranges(w, contentType, cachedSize)
+	}
 	write206Headers := func() {
-		w.Header().Set("Content-Type", contentType)
-		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", rng.start, rng.end, cachedSize))
-		w.Header().Set("Content-Length", strconv.FormatInt(rng.length, 10))
+		if br != nil {
+			br.setHeaders(w.Header(), ranges)
+		} else {
+			w.Header().Set("Content-Type", contentType)
+			w.Header().Set("Content-Range", ranges[0].contentRange(cachedSize))
+			w.Header().Set("Content-Length", strconv.FormatInt(ranges[0].length, 10))
+		}
 		w.Header().Set("Accept-Ranges", "bytes")
 		w.Header().Set("x-amz-request-id", generateRequestID())
 		if w.Header().Get("x-amz-version-id") == "" {
@@ -1610,6 +1816,11 @@ func (a *S3ToEngine) handleChunkedGet(
 			case <-fctx.Done():
 				return
 			}
+			if plan[i].reuse {
+				// The writer serves the previous slice's bytes again.
+				results[i] <- fetchOut{}
+				continue
+			}
 			go func(i int) {
 				data, ferr := a.fetchAndVerifyChunk(fctx, plan[i].desc, t.ID)
 				results[i] <- fetchOut{data: data, err: ferr}
@@ -1622,10 +1833,20 @@ func (a *S3ToEngine) handleChunkedGet(
 	// fixed; a failure aborts the body without serving bad bytes.
 	headersWritten := false
 	var written int64
+	var last []byte
+	out := io.Writer(w)
+	part := -1
 	for i, p := range plan {
-		out := <-results[i]
-		data, ferr := out.data, out.err
+		res := <-results[i]
+		data, ferr := res.data, res.err
 		<-slots
+		if p.reuse {
+			data = last
+		}
+		last = nil
+		if i+1 < len(plan) && plan[i+1].reuse {
+			last = data
+		}
 		if ferr != nil {
 			if !headersWritten {
 				if errors.Is(ferr, errChunkIntegrity) {
@@ -1657,6 +1878,18 @@ func (a *S3ToEngine) handleChunkedGet(
 			}
 			headersWritten = true
 		}
+		if br != nil && p.part != part {
+			part = p.part
+			pw, perr := br.part(ranges[part])
+			if perr != nil {
+				a.logger.Error("failed to start range part",
+					zap.Error(perr),
+					zap.String("container", container),
+					zap.String("artifact", artifact))
+				return nil
+			}
+			out = pw
+		}
 
 		slice := data
 		if p.skip != 0 || p.take != int64(len(data)) {
@@ -1666,7 +1899,7 @@ func (a *S3ToEngine) handleChunkedGet(
 			}
 			slice = data[p.skip:end]
 		}
-		n, werr := w.Write(slice)
+		n, werr := out.Write(slice)
 		written += int64(n)
 		if werr != nil {
 			a.logger.Error("failed to stream chunk to client",
@@ -1677,6 +1910,16 @@ func (a *S3ToEngine) handleChunkedGet(
 		}
 	}
 
+	if br != nil && headersWritten {
+		if err := br.close(); err != nil {
+			a.logger.Error("failed to finish multipart/byteranges body",
+				zap.Error(err),
+				zap.String("container", container),
+				zap.String("artifact", artifact))
+			return nil
+		}
+	}
+
 	// Defensive: a zero-length object/plan still gets a valid response.
 	if !headersWritten {
 		write200Headers()
@@ -1751,15 +1994,28 @@ func (a *S3ToEngine) HandleDelete(w http.ResponseWriter, r *http.Request, bucket
 		return
 	}
 
+	// If-Match deletes the current object only while it still has that
+	// ETag: the key is locked and the condition rechecked right before the
+	// delete, and held until it commits.
+	cw, ok := startConditionalWrite(w, r, a.logger, a.db, t.ID, bucket, object, false)
+	if !ok {
+		return
+	}
+	defer cw.rollback()
+
 	if a.db != nil && vStatus == "Enabled" && reqVersionID == "" {
 		markerID := generateVersionID()
+		if err := cw.lock(r.Context()); err != nil {
+			writeConditionalWriteError(w, r, a.logger, err)
+			return
+		}
 
-		_, _ = a.db.ExecContext(r.Context(), `
+		_, _ = cw.exec(r.Context(), a.db, `
 			UPDATE object_versions SET is_latest = FALSE
 			WHERE tenant_id = $1 AND bucket = $2 AND object_key = $3 AND is_latest = TRUE`,
 			t.ID, bucket, object)
 
-		_, _ = a.db.ExecContext(r.Context(), `
+		_, _ = cw.exec(r.Context(), a.db, `
 			INSERT INTO object_versions
 				(tenant_id, bucket, object_key, version_id, size_bytes, etag, content_type, is_latest, is_delete_marker)
 			VALUES ($1, $2, $3, $4, 0, '', 'application/octet-stream', TRUE, TRUE)`,
@@ -1769,15 +2025,23 @@ func (a *S3ToEngine) HandleDelete(w http.ResponseWriter, r *http.Request, bucket
 		// captures the removed size atomically, so a concurrent writer or
 		// deleter can never cause the same bytes to be released twice.
 		var markedSize int64
-		delErr := a.db.QueryRowContext(r.Context(), `
+		delErr := cw.queryRow(r.Context(), a.db, `
 			DELETE FROM object_head_cache
 			WHERE tenant_id = $1 AND bucket = $2 AND object_key = $3
 			RETURNING size_bytes`,
 			t.ID, bucket, object).Scan(&markedSize)
+		if delErr != nil && delErr != sql.ErrNoRows {
+			a.logger.Error("delete-marker head cache delete failed", zap.Error(delErr))
+		}
+		if delErr == nil {
+			a.queueReplicationDelete(r, cw.querier(a.db), t.ID, bucket, object)
+		}
+		if err := cw.commit(); err != nil {
+			writeConditionalWriteError(w, r, a.logger, err)
+			return
+		}
 		if delErr == nil {
 			a.releaseQuotaForDelete(r, t.ID, markedSize)
-		} else if delErr != sql.ErrNoRows {
-			a.logger.Error("delete-marker head cache delete failed", zap.Error(delErr))
 		}
 
 		w.Header().Set("x-amz-version-id", markerID)
@@ -1794,18 +2058,49 @@ func (a *S3ToEngine) HandleDelete(w http.ResponseWriter, r *http.Request, bucket
 		WriteS3Error(w, ErrObjectLocked, r.URL.Path, generateRequestID())
 		return
 	}
+	if err := cw.lock(r.Context()); err != nil {
+		writeConditionalWriteError(w, r, a.logger, err)
+		return
+	}
+
+	// An unconditional delete holds the key lock across the byte delete and
+	// the row delete, like every other writer to the key.
+	q := cw.querier(a.db)
+	var kl *keyWriteLock
+	if cw == nil {
+		var err error
+		kl, err = lockKeyForWrite(r.Context(), a.db, t.ID, bucket, object)
+		if err != nil {
+			a.logger.Error("lock key for write failed", zap.Error(err),
+				zap.String("tenant_id", t.ID), zap.String("bucket", bucket), zap.String("key", object))
+			WriteS3Error(w, ErrInternalError, r.URL.Path, generateRequestID())
+			return
+		}
+		defer kl.release()
+		q = kl.querier(a.db)
+	}
 
 	// Chunked objects: decrement chunk ref counts via GCI instead of
 	// deleting from the backend. Actual chunk data stays until GC (Phase 8.7).
+	// The refs are released in the locked transaction.
 	var isChunked bool
 	if a.db != nil {
-		_ = a.db.QueryRowContext(r.Context(),
+		_ = q.QueryRowContext(r.Context(),
 			`SELECT is_chunked FROM object_head_cache WHERE tenant_id = $1 AND bucket = $2 AND object_key = $3`,
 			t.ID, bucket, object).Scan(&isChunked)
 	}
 
 	if isChunked && a.gci != nil {
-		if delErr := a.gci.DeleteObjectChunks(r.Context(), t.ID, bucket, object); delErr != nil {
+		var delErr error
+		switch {
+		case cw != nil:
+			delErr = a.gci.DeleteObjectChunksTx(r.Context(), cw.tx, t.ID, bucket, object)
+		case kl != nil:
+			delErr = a.gci.DeleteObjectChunksTx(r.Context(), kl.tx, t.ID, bucket, object)
+		default:
+			delErr = a.gci.DeleteObjectChunks(r.Context(), t.ID, bucket, object)
+		}
+		if delErr != nil {
 			a.logger.Error("chunked delete failed",
 				zap.Error(delErr),
 				zap.String("tenant_id", t.ID),
@@ -1834,15 +2129,28 @@ func (a *S3ToEngine) HandleDelete(w http.ResponseWriter, r *http.Request, bucket
 		// DELETE...RETURNING releases exactly the bytes this request removed
 		// (the row is the billing record — WP-1). Logical size for chunked.
 		var deletedSize int64
-		delErr := a.db.QueryRowContext(r.Context(), `
+		delErr := q.QueryRowContext(r.Context(), `
 			DELETE FROM object_head_cache
 			WHERE tenant_id = $1 AND bucket = $2 AND object_key = $3
 			RETURNING size_bytes
 		`, t.ID, bucket, object).Scan(&deletedSize)
+		if delErr != nil && delErr != sql.ErrNoRows {
+			a.logger.Error("head cache delete failed", zap.Error(delErr))
+		}
+		if delErr == nil {
+			a.queueReplicationDelete(r, q, t.ID, bucket, object)
+		}
+		if err := cw.commit(); err != nil {
+			writeConditionalWriteError(w, r, a.logger, err)
+			return
+		}
+		if err := kl.commit(); err != nil {
+			a.logger.Error("head cache delete commit failed", zap.Error(err))
+			WriteS3Error(w, ErrInternalError, r.URL.Path, generateRequestID())
+			return
+		}
 		if delErr == nil {
 			a.releaseQuotaForDelete(r, t.ID, deletedSize)
-		} else if delErr != sql.ErrNoRows {
-			a.logger.Error("head cache delete failed", zap.Error(delErr))
 		}
 	}
 
@@ -1853,6 +2161,18 @@ func (a *S3ToEngine) HandleDelete(w http.ResponseWriter, r *http.Request, bucket
 	})
 }
 
+// queueReplicationDelete queues the replicated delete of a removed head
+// row through q, the transaction holding the key lock when there is one.
+func (a *S3ToEngine) queueReplicationDelete(r *http.Request, q sqlQuerier, tenantID, bucket, object string) {
+	if err := queueReplicationDelete(r.Context(), q, tenantID, bucket, object); err != nil {
+		a.logger.Error("queue replicated delete failed",
+			zap.Error(err),
+			zap.String("tenant_id", tenantID),
+			zap.String("bucket", bucket),
+			zap.String("object", object))
+	}
+}
+
 // bucketRegionDriver returns the engine driver name for a bucket's region.
 // Returns "" if the bucket uses the default region or if no region-specific
 // driver is registered (non-iDrive backends).
@@ -1899,17 +2219,6 @@ func bucketTierStorageClass(ctx context.Context, db *sql.DB, tenantID, bucket st
 	return tierPreferenceToStorageClass[pref]
 }
 
-func isBucketSSEEnabled(ctx context.Context, db *sql.DB, tenantID, bucket string) bool {
-	if db == nil {
-		return false
-	}
-	var enabled bool
-	err := db.QueryRowContext(ctx,
-		"SELECT sse_enabled FROM buckets WHERE tenant_id = $1 AND name = $2",
-		tenantID, bucket).Scan(&enabled)
-	return err == nil && enabled
-}
-
 // HandleList processes S3 LIST requests
 func (a *S3ToEngine) HandleList(w http.ResponseWriter, r *http.Request, bucket, prefix string) {
 	t, err := tenant.FromContext(r.Context())
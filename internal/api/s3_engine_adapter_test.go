@@ -169,6 +169,38 @@ func TestHandleGet_RangeRequest(t *testing.T) {
 	assert.Equal(t, "ABCDE", string(body))
 }
 
+func TestHandleGet_MultipleRanges(t *testing.T) {
+	f := setupAdapterFixture(t)
+
+	content := []byte("ABCDEFGHIJKLMNOPQRSTUVWXYZ")
+	container := f.tenant.NamespaceContainer("test-bucket")
+	_, err := f.eng.Put(context.Background(), container, "alphabet.txt", bytes.NewReader(content))
+	require.NoError(t, err)
+
+	_, err = f.db.Exec(`
+		INSERT INTO object_head_cache (tenant_id, bucket, object_key, size_bytes, etag, content_type)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (tenant_id, bucket, object_key) DO UPDATE SET
+			size_bytes = $4, etag = $5, content_type = $6
+	`, f.tenantID, "test-bucket", "alphabet.txt", len(content), "alpha123", "text/plain")
+	require.NoError(t, err)
+
+	req := httptest.NewRequest("GET", "/test-bucket/alphabet.txt", nil)
+	req.Header.Set("Range", "bytes=20-,0-2,2-4")
+	req = req.WithContext(tenant.WithTenant(req.Context(), f.tenant))
+
+	w := httptest.NewRecorder()
+	f.adapter.HandleGet(w, req, "test-bucket", "alphabet.txt")
+
+	assert.Equal(t, http.StatusPartialContent, w.Code)
+	parts := readByteranges(t, w)
+	require.Len(t, parts, 2, "overlapping ranges coalesce")
+	assert.Equal(t, "bytes 0-4/26", parts[0].contentRange)
+	assert.Equal(t, "ABCDE", string(parts[0].body))
+	assert.Equal(t, "bytes 20-25/26", parts[1].contentRange)
+	assert.Equal(t, "UVWXYZ", string(parts[1].body))
+}
+
 func TestHandleGet_RangeRequest_Unsatisfiable(t *testing.T) {
 	f := setupAdapterFixture(t)
 
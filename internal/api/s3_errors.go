@@ -66,6 +66,7 @@ const (
 	ErrObjectLocked                      = "ObjectLocked"
 	ErrInvalidRetentionPeriod            = "InvalidRetentionPeriod"
 	ErrExpiredPresignedRequest           = "ExpiredToken"
+	ErrInvalidToken                      = "InvalidToken"
 	ErrAuthorizationQueryParametersError = "AuthorizationQueryParametersError"
 	ErrInvalidPresignExpires             = "AuthorizationQueryParametersError_Expires"
 	ErrQuotaExceeded                     = "QuotaExceeded"
@@ -73,8 +74,42 @@ const (
 	ErrInvalidBucketState                = "InvalidBucketState"
 	ErrInvalidLocationConstraint         = "InvalidLocationConstraint"
 	ErrInvalidTag                        = "InvalidTag"
+	ErrNoSuchLifecycleConfiguration      = "NoSuchLifecycleConfiguration"
+	ErrReplicationConfigurationNotFound  = "ReplicationConfigurationNotFoundError"
+	ErrNoSuchWebsiteConfiguration        = "NoSuchWebsiteConfiguration"
+	ErrNoSuchBucketPolicy                = "NoSuchBucketPolicy"
+	ErrMalformedPolicy                   = "MalformedPolicy"
+	ErrPreconditionFailed                = "PreconditionFailed"
 	ErrInvalidObjectState                = "InvalidObjectState"
 	ErrRestoreAlreadyInProgress          = "RestoreAlreadyInProgress"
+	ErrInvalidExpressionType             = "InvalidExpressionType"
+	ErrMissingRequiredParameter          = "MissingRequiredParameter"
+	ErrInvalidRequestParameter           = "InvalidRequestParameter"
+	ErrObjectSerializationConflict       = "ObjectSerializationConflict"
+	ErrInvalidCompressionFormat          = "InvalidCompressionFormat"
+	ErrInvalidFileHeaderInfo             = "InvalidFileHeaderInfo"
+	ErrInvalidJSONType                   = "InvalidJsonType"
+	ErrInvalidQuoteFields                = "InvalidQuoteFields"
+	ErrExpressionTooLong                 = "ExpressionTooLong"
+	ErrParseUnexpectedToken              = "ParseUnexpectedToken"
+	ErrUnsupportedSQLOperation           = "UnsupportedSqlOperation"
+	ErrUnsupportedFunction               = "UnsupportedFunction"
+	ErrIncorrectSQLFunctionArgumentType  = "IncorrectSqlFunctionArgumentType"
+	ErrMalformedPOSTRequest              = "MalformedPOSTRequest"
+	ErrIncorrectNumberOfFiles            = "IncorrectNumberOfFilesInPostRequest"
+	ErrMaxPostPreDataLengthExceeded      = "MaxPostPreDataLengthExceededError"
+	ErrInvalidPolicyDocument             = "InvalidPolicyDocument"
+	ErrNoSuchCORSConfiguration           = "NoSuchCORSConfiguration"
+	ErrCORSForbidden                     = "AccessForbidden"
+	ErrCORSBadRequest                    = "BadRequest"
+	ErrKMSNotFound                       = "KMS.NotFoundException"
+	ErrKMSDisabled                       = "KMS.DisabledException"
+	ErrNoSuchEncryptionConfiguration     = "ServerSideEncryptionConfigurationNotFoundError"
+	ErrAccessControlListNotSupported     = "AccessControlListNotSupported"
+	ErrBucketAclWithOwnershipEnforced    = "InvalidBucketAclWithObjectOwnership"
+	ErrUnresolvableGrantByEmailAddress   = "UnresolvableGrantByEmailAddress"
+	ErrOwnershipControlsNotFound         = "OwnershipControlsNotFoundError"
+	ErrNoSuchPublicAccessBlock           = "NoSuchPublicAccessBlockConfiguration"
 )
 
 // Error messages
@@ -111,6 +146,7 @@ var errorMessages = map[string]string{
 	ErrObjectLocked:                      "Object is protected by Object Lock",
 	ErrInvalidRetentionPeriod:            "The retention period specified is not valid",
 	ErrExpiredPresignedRequest:           "Request has expired",
+	ErrInvalidToken:                      "The provided token is malformed or otherwise invalid.",
 	ErrAuthorizationQueryParametersError: "Query-string authentication requires the X-Amz-Algorithm, X-Amz-Credential, X-Amz-Date, X-Amz-Expires, X-Amz-SignedHeaders, and X-Amz-Signature parameters",
 	ErrInvalidPresignExpires:             "X-Amz-Expires must be between 1 and 604800 seconds",
 	ErrQuotaExceeded:                     "Storage quota exceeded. Upgrade your plan for more storage.",
@@ -118,8 +154,42 @@ var errorMessages = map[string]string{
 	ErrInvalidBucketState:                "The request is not valid for the current state of the bucket.",
 	ErrInvalidLocationConstraint:         "The specified location constraint is not valid.",
 	ErrInvalidTag:                        "The tag provided was not valid.",
+	ErrNoSuchLifecycleConfiguration:      "The lifecycle configuration does not exist",
+	ErrReplicationConfigurationNotFound:  "The replication configuration was not found",
+	ErrNoSuchWebsiteConfiguration:        "The specified bucket does not have a website configuration",
+	ErrNoSuchBucketPolicy:                "The bucket policy does not exist",
+	ErrMalformedPolicy:                   "Policies must be valid JSON and the first byte must be '{'",
+	ErrPreconditionFailed:                "At least one of the pre-conditions you specified did not hold",
 	ErrInvalidObjectState:                "The operation is not valid for the object's storage class",
 	ErrRestoreAlreadyInProgress:          "Object restore is already in progress",
+	ErrInvalidExpressionType:             "The ExpressionType is invalid. Only SQL expressions are supported",
+	ErrMissingRequiredParameter:          "The SelectRequest entity is missing a required parameter",
+	ErrInvalidRequestParameter:           "The value of a parameter in the SelectRequest entity is invalid",
+	ErrObjectSerializationConflict:       "InputSerialization specifies more than one format",
+	ErrInvalidCompressionFormat:          "The file is not in a supported compression format",
+	ErrInvalidFileHeaderInfo:             "The FileHeaderInfo is invalid",
+	ErrInvalidJSONType:                   "The JsonType is invalid. Only DOCUMENT and LINES are supported",
+	ErrInvalidQuoteFields:                "The QuoteFields is invalid. Only ALWAYS and ASNEEDED are supported",
+	ErrExpressionTooLong:                 "The SQL expression is too long",
+	ErrParseUnexpectedToken:              "The SQL expression contains an unexpected token",
+	ErrUnsupportedSQLOperation:           "Encountered an unsupported SQL operation",
+	ErrUnsupportedFunction:               "Encountered an unsupported SQL function",
+	ErrIncorrectSQLFunctionArgumentType:  "Incorrect type of arguments in a function call",
+	ErrMalformedPOSTRequest:              "The body of your POST request is not well-formed multipart/form-data",
+	ErrIncorrectNumberOfFiles:            "POST requires exactly one file upload per request",
+	ErrMaxPostPreDataLengthExceeded:      "Your POST request fields preceding the upload file were too large",
+	ErrInvalidPolicyDocument:             "The content of the form does not meet the conditions specified in the policy document",
+	ErrNoSuchCORSConfiguration:           "The CORS configuration does not exist",
+	ErrCORSForbidden:                     "CORSResponse: This CORS request is not allowed. This is usually because the evaluation of Origin, request method / Access-Control-Request-Method or Access-Control-Request-Headers are not whitelisted by the resource's CORS spec.",
+	ErrCORSBadRequest:                    "Insufficient information. Origin request header needed.",
+	ErrKMSNotFound:                       "The specified KMS key does not exist",
+	ErrKMSDisabled:                       "The specified KMS key is disabled or pending deletion",
+	ErrNoSuchEncryptionConfiguration:     "The server side encryption configuration was not found",
+	ErrAccessControlListNotSupported:     "The bucket does not allow ACLs",
+	ErrBucketAclWithOwnershipEnforced:    "Bucket cannot have ACLs set with ObjectOwnership's BucketOwnerEnforced setting",
+	ErrUnresolvableGrantByEmailAddress:   "The email address you provided does not match any account on record",
+	ErrOwnershipControlsNotFound:         "The bucket ownership controls were not found",
+	ErrNoSuchPublicAccessBlock:           "The public access block configuration was not found",
 }
 
 // HTTP status codes for errors
@@ -156,6 +226,7 @@ var errorStatusCodes = map[string]int{
 	ErrObjectLocked:                      http.StatusForbidden,
 	ErrInvalidRetentionPeriod:            http.StatusBadRequest,
 	ErrExpiredPresignedRequest:           http.StatusForbidden,
+	ErrInvalidToken:                      http.StatusBadRequest,
 	ErrAuthorizationQueryParametersError: http.StatusBadRequest,
 	ErrInvalidPresignExpires:             http.StatusBadRequest,
 	ErrQuotaExceeded:                     http.StatusForbidden,
@@ -163,8 +234,42 @@ var errorStatusCodes = map[string]int{
 	ErrInvalidBucketState:                http.StatusConflict,
 	ErrInvalidLocationConstraint:         http.StatusBadRequest,
 	ErrInvalidTag:                        http.StatusBadRequest,
+	ErrNoSuchLifecycleConfiguration:      http.StatusNotFound,
+	ErrReplicationConfigurationNotFound:  http.StatusNotFound,
+	ErrNoSuchWebsiteConfiguration:        http.StatusNotFound,
+	ErrNoSuchBucketPolicy:                http.StatusNotFound,
+	ErrMalformedPolicy:                   http.StatusBadRequest,
+	ErrPreconditionFailed:                http.StatusPreconditionFailed,
 	ErrInvalidObjectState:                http.StatusForbidden,
 	ErrRestoreAlreadyInProgress:          http.StatusConflict,
+	ErrInvalidExpressionType:             http.StatusBadRequest,
+	ErrMissingRequiredParameter:          http.StatusBadRequest,
+	ErrInvalidRequestParameter:           http.StatusBadRequest,
+	ErrObjectSerializationConflict:       http.StatusBadRequest,
+	ErrInvalidCompressionFormat:          http.StatusBadRequest,
+	ErrInvalidFileHeaderInfo:             http.StatusBadRequest,
+	ErrInvalidJSONType:                   http.StatusBadRequest,
+	ErrInvalidQuoteFields:                http.StatusBadRequest,
+	ErrExpressionTooLong:                 http.StatusBadRequest,
+	ErrParseUnexpectedToken:              http.StatusBadRequest,
+	ErrUnsupportedSQLOperation:           http.StatusBadRequest,
+	ErrUnsupportedFunction:               http.StatusBadRequest,
+	ErrIncorrectSQLFunctionArgumentType:  http.StatusBadRequest,
+	ErrMalformedPOSTRequest:              http.StatusBadRequest,
+	ErrIncorrectNumberOfFiles:            http.StatusBadRequest,
+	ErrMaxPostPreDataLengthExceeded:      http.StatusBadRequest,
+	ErrInvalidPolicyDocument:             http.StatusBadRequest,
+	ErrNoSuchCORSConfiguration:           http.StatusNotFound,
+	ErrCORSForbidden:                     http.StatusForbidden,
+	ErrCORSBadRequest:                    http.StatusBadRequest,
+	ErrKMSNotFound:                       http.StatusBadRequest,
+	ErrKMSDisabled:                       http.StatusBadRequest,
+	ErrNoSuchEncryptionConfiguration:     http.StatusNotFound,
+	ErrAccessControlListNotSupported:     http.StatusBadRequest,
+	ErrBucketAclWithOwnershipEnforced:    http.StatusBadRequest,
+	ErrUnresolvableGrantByEmailAddress:   http.StatusBadRequest,
+	ErrOwnershipControlsNotFound:         http.StatusNotFound,
+	ErrNoSuchPublicAccessBlock:           http.StatusNotFound,
 }
 
 // WriteS3Error writes an S3-compatible error response
@@ -202,6 +307,7 @@ func WriteS3Error(w http.ResponseWriter, code string, resource string, requestID
 // ErrorContext carries optional context for enriched error messages.
 type ErrorContext struct {
 	Suggestion string
+	Message    string
 }
 
 // ErrorOption configures an ErrorContext.
@@ -212,6 +318,12 @@ func WithSuggestion(s string) ErrorOption {
 	return func(ec *ErrorContext) { ec.Suggestion = s }
 }
 
+// WithMessage replaces the code's default message, for codes like SlowDown
+// that are returned for more than one reason.
+func WithMessage(m string) ErrorOption {
+	return func(ec *ErrorContext) { ec.Message = m }
+}
+
 // WriteS3ErrorWithContext writes an S3-compatible error response with optional
 // enrichment (e.g. Levenshtein-based "Did you mean?" suggestions). Call sites
 // that don't need suggestions can omit the opts — behavior is identical to
@@ -226,6 +338,8 @@ func WriteS3ErrorWithContext(w http.ResponseWriter, code string, resource string
 	if !exists {
 		message = "Unknown error"
 		code = ErrInternalError
+	} else if ec.Message != "" {
+		message = ec.Message
 	}
 
 	if ec.Suggestion != "" {
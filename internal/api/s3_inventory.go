@@ -1,19 +1,19 @@
 package api
 
 import (
-	"bytes"
 	"context"
 	"database/sql"
-	"encoding/csv"
 	"encoding/xml"
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/FairForge/vaultaire/internal/engine"
 	"github.com/FairForge/vaultaire/internal/tenant"
+	"github.com/lib/pq"
 	"go.uber.org/zap"
 )
 
@@ -21,13 +21,15 @@ const maxInventoryBodyBytes = 4096
 
 // InventoryConfiguration is the S3 XML type for inventory config.
 type InventoryConfiguration struct {
-	XMLName     xml.Name              `xml:"InventoryConfiguration"`
-	Xmlns       string                `xml:"xmlns,attr,omitempty"`
-	ID          string                `xml:"Id,omitempty"`
-	IsEnabled   bool                  `xml:"IsEnabled"`
-	Schedule    *InventorySchedule    `xml:"Schedule,omitempty"`
-	Destination *InventoryDestination `xml:"Destination,omitempty"`
-	Format      string                `xml:"Format,omitempty"`
+	XMLName                xml.Name                 `xml:"InventoryConfiguration"`
+	Xmlns                  string                   `xml:"xmlns,attr,omitempty"`
+	ID                     string                   `xml:"Id,omitempty"`
+	IsEnabled              bool                     `xml:"IsEnabled"`
+	Schedule               *InventorySchedule       `xml:"Schedule,omitempty"`
+	Destination            *InventoryDestination    `xml:"Destination,omitempty"`
+	Format                 string                   `xml:"Format,omitempty"`
+	IncludedObjectVersions string                   `xml:"IncludedObjectVersions,omitempty"`
+	OptionalFields         *InventoryOptionalFields `xml:"OptionalFields,omitempty"`
 }
 
 type InventorySchedule struct {
@@ -44,6 +46,10 @@ type S3BucketDestination struct {
 	Format string `xml:"Format,omitempty"`
 }
 
+type InventoryOptionalFields struct {
+	Fields []string `xml:"Field"`
+}
+
 func (s *Server) handleGetBucketInventory(w http.ResponseWriter, r *http.Request, req *S3Request) {
 	t, err := tenant.FromContext(r.Context())
 	if err != nil || t == nil {
@@ -60,12 +66,15 @@ func (s *Server) handleGetBucketInventory(w http.ResponseWriter, r *http.Request
 	}
 
 	var enabled bool
-	var schedule string
+	var id, schedule, includedVersions string
 	var targetBucket, prefix, format sql.NullString
+	var optionalFields pq.StringArray
 	err = s.db.QueryRowContext(r.Context(),
-		`SELECT inventory_enabled, inventory_schedule, inventory_target_bucket, inventory_prefix, inventory_format
+		`SELECT inventory_enabled, inventory_id, inventory_schedule, inventory_target_bucket, inventory_prefix,
+			inventory_format, inventory_included_versions, inventory_optional_fields
 		 FROM buckets WHERE tenant_id = $1 AND name = $2`,
-		t.ID, req.Bucket).Scan(&enabled, &schedule, &targetBucket, &prefix, &format)
+		t.ID, req.Bucket).Scan(&enabled, &id, &schedule, &targetBucket, &prefix,
+		&format, &includedVersions, &optionalFields)
 	if err == sql.ErrNoRows {
 		reqID := generateRequestID()
 		if suggestion := bucketSuggestion(r.Context(), s.db, t.ID, req.Bucket); suggestion != "" {
@@ -81,14 +90,21 @@ func (s *Server) handleGetBucketInventory(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	if id == "" {
+		id = req.Bucket
+	}
 	resp := InventoryConfiguration{
 		Xmlns:     "http://s3.amazonaws.com/doc/2006-03-01/",
-		ID:        req.Bucket,
+		ID:        id,
 		IsEnabled: enabled,
 	}
 	if enabled && targetBucket.Valid && targetBucket.String != "" {
 		resp.Schedule = &InventorySchedule{Frequency: schedule}
 		resp.Format = format.String
+		resp.IncludedObjectVersions = includedVersions
+		if len(optionalFields) > 0 {
+			resp.OptionalFields = &InventoryOptionalFields{Fields: optionalFields}
+		}
 		resp.Destination = &InventoryDestination{
 			S3BucketDestination: &S3BucketDestination{
 				Bucket: targetBucket.String,
@@ -159,7 +175,7 @@ func (s *Server) handlePutBucketInventory(w http.ResponseWriter, r *http.Request
 	}
 
 	dest := config.Destination.S3BucketDestination
-	targetBucket := dest.Bucket
+	targetBucket := strings.TrimPrefix(dest.Bucket, "arn:aws:s3:::")
 
 	// Validate target bucket exists and belongs to same tenant.
 	err = s.db.QueryRowContext(r.Context(),
@@ -182,16 +198,42 @@ func (s *Server) handlePutBucketInventory(w http.ResponseWriter, r *http.Request
 	inventoryFormat := "csv"
 	if dest.Format != "" {
 		f := strings.ToLower(dest.Format)
-		if f == "csv" || f == "orc" || f == "parquet" {
+		if _, ok := inventoryFormats[f]; ok {
 			inventoryFormat = f
 		}
 	}
 
+	includedVersions := inventoryVersionsCurrent
+	switch config.IncludedObjectVersions {
+	case "", inventoryVersionsCurrent:
+	case inventoryVersionsAll:
+		includedVersions = inventoryVersionsAll
+	default:
+		WriteS3ErrorWithContext(w, ErrInvalidArgument, r.URL.Path, generateRequestID(),
+			WithSuggestion("IncludedObjectVersions must be All or Current."))
+		return
+	}
+	var optionalFields []string
+	if config.OptionalFields != nil {
+		optionalFields, err = normalizeInventoryFields(config.OptionalFields.Fields)
+		if err != nil {
+			WriteS3ErrorWithContext(w, ErrInvalidArgument, r.URL.Path, generateRequestID(),
+				WithSuggestion(err.Error()))
+			return
+		}
+	}
+	id := config.ID
+	if id == "" {
+		id = req.Query["id"]
+	}
+
 	_, err = s.db.ExecContext(r.Context(),
-		`UPDATE buckets SET inventory_enabled = TRUE, inventory_schedule = $3,
-			inventory_target_bucket = $4, inventory_prefix = $5, inventory_format = $6, updated_at = NOW()
+		`UPDATE buckets SET inventory_enabled = TRUE, inventory_id = $3, inventory_schedule = $4,
+			inventory_target_bucket = $5, inventory_prefix = $6, inventory_format = $7,
+			inventory_included_versions = $8, inventory_optional_fields = $9, updated_at = NOW()
 		 WHERE tenant_id = $1 AND name = $2`,
-		t.ID, req.Bucket, schedule, targetBucket, dest.Prefix, inventoryFormat)
+		t.ID, req.Bucket, id, schedule, targetBucket, dest.Prefix, inventoryFormat,
+		includedVersions, pq.Array(optionalFields))
 	if err != nil {
 		s.logger.Error("enable bucket inventory", zap.Error(err))
 		WriteS3Error(w, ErrInternalError, r.URL.Path, generateRequestID())
@@ -203,7 +245,8 @@ func (s *Server) handlePutBucketInventory(w http.ResponseWriter, r *http.Request
 		zap.String("bucket", req.Bucket),
 		zap.String("target_bucket", targetBucket),
 		zap.String("schedule", schedule),
-		zap.String("format", inventoryFormat))
+		zap.String("format", inventoryFormat),
+		zap.String("included_versions", includedVersions))
 
 	w.WriteHeader(http.StatusOK)
 }
@@ -294,116 +337,226 @@ func (ir *InventoryRunner) runInventory(ctx context.Context) {
 	runCtx, cancel := context.WithTimeout(ctx, 5*time.Minute)
 	defer cancel()
 
-	rows, err := ir.db.QueryContext(runCtx, `
-		SELECT tenant_id, name, inventory_schedule, inventory_target_bucket,
-			inventory_prefix, inventory_format
-		FROM buckets
-		WHERE inventory_enabled = TRUE AND inventory_target_bucket IS NOT NULL
-	`)
+	jobs, err := ir.inventoryJobs(runCtx, "", "")
 	if err != nil {
 		ir.logger.Error("query inventory-enabled buckets", zap.Error(err))
 		return
 	}
-	defer func() { _ = rows.Close() }()
 
-	type invConfig struct {
-		tenantID     string
-		bucket       string
-		schedule     string
-		targetBucket string
-		prefix       string
-		format       string
-	}
-	var configs []invConfig
-	for rows.Next() {
-		var c invConfig
-		if err := rows.Scan(&c.tenantID, &c.bucket, &c.schedule, &c.targetBucket, &c.prefix, &c.format); err != nil {
-			ir.logger.Error("scan inventory config", zap.Error(err))
-			continue
-		}
-		configs = append(configs, c)
-	}
-
-	for _, c := range configs {
+	for _, job := range jobs {
 		// Weekly runs only on Sunday.
-		if c.schedule == "weekly" && now.Weekday() != time.Sunday {
+		if job.schedule == "weekly" && now.Weekday() != time.Sunday {
 			continue
 		}
-		ir.generateReport(runCtx, c.tenantID, c.bucket, c.targetBucket, c.prefix, c.format)
+		if err := ir.generateReport(runCtx, job); err != nil {
+			ir.logger.Error("generate inventory report",
+				zap.String("tenant_id", job.tenantID),
+				zap.String("bucket", job.bucket),
+				zap.Error(err))
+		}
 	}
 }
 
-func (ir *InventoryRunner) generateReport(ctx context.Context, tenantID, bucket, targetBucket, prefix, format string) {
+// inventoryJob is one bucket's inventory configuration.
+type inventoryJob struct {
+	tenantID         string
+	bucket           string
+	id               string
+	schedule         string
+	targetBucket     string
+	prefix           string
+	format           string
+	includedVersions string
+	optionalFields   []string
+}
+
+// inventoryJobs loads the enabled inventory configurations: every bucket's
+// when tenantID is empty, otherwise just bucket's.
+func (ir *InventoryRunner) inventoryJobs(ctx context.Context, tenantID, bucket string) ([]inventoryJob, error) {
 	rows, err := ir.db.QueryContext(ctx, `
-		SELECT object_key, size_bytes, etag, content_type, updated_at,
-			COALESCE(encryption_algorithm, ''), COALESCE(backend_name, '')
-		FROM object_head_cache
-		WHERE tenant_id = $1 AND bucket = $2
-		ORDER BY object_key ASC
+		SELECT tenant_id, name, inventory_id, inventory_schedule, inventory_target_bucket,
+			inventory_prefix, inventory_format, inventory_included_versions, inventory_optional_fields
+		FROM buckets
+		WHERE inventory_enabled = TRUE AND inventory_target_bucket IS NOT NULL
+		  AND ($1 = '' OR (tenant_id = $1 AND name = $2))
 	`, tenantID, bucket)
 	if err != nil {
-		ir.logger.Error("query objects for inventory",
-			zap.String("bucket", bucket), zap.Error(err))
-		return
+		return nil, err
 	}
 	defer func() { _ = rows.Close() }()
 
-	var buf bytes.Buffer
-	w := csv.NewWriter(&buf)
+	var jobs []inventoryJob
+	for rows.Next() {
+		var j inventoryJob
+		var fields pq.StringArray
+		if err := rows.Scan(&j.tenantID, &j.bucket, &j.id, &j.schedule, &j.targetBucket,
+			&j.prefix, &j.format, &j.includedVersions, &fields); err != nil {
+			return nil, err
+		}
+		if j.id == "" {
+			j.id = j.bucket
+		}
+		j.optionalFields = fields
+		jobs = append(jobs, j)
+	}
+	return jobs, rows.Err()
+}
+
+// inventoryObjectsQuery lists a bucket's current objects with the state
+// report columns draw on. Object lock state is per key, so it is joined to
+// the current version only.
+const inventoryObjectsQuery = `
+	SELECT o.object_key, o.version_id, o.is_latest, o.is_delete_marker, o.size_bytes,
+		o.last_modified, o.etag, o.backend_name, o.encryption_algorithm,
+		o.replication_status, o.checksum_algorithm,
+		COALESCE(l.retention_mode, ''), l.retain_until_date, COALESCE(l.legal_hold, FALSE)
+	FROM (%s) o
+	LEFT JOIN object_locks l
+		ON l.tenant_id = $1 AND l.bucket = $2 AND l.object_key = o.object_key AND o.is_latest
+	ORDER BY o.object_key ASC, o.is_latest DESC, o.last_modified DESC`
+
+// inventoryCurrentObjects selects the head cache: one row per key.
+const inventoryCurrentObjects = `
+	SELECT object_key, 'null' AS version_id, TRUE AS is_latest, FALSE AS is_delete_marker,
+		size_bytes, updated_at AS last_modified, etag,
+		COALESCE(backend_name, '') AS backend_name,
+		COALESCE(encryption_algorithm, '') AS encryption_algorithm,
+		COALESCE(replication_status, '') AS replication_status,
+		COALESCE(checksum_algorithm, '') AS checksum_algorithm
+	FROM object_head_cache
+	WHERE tenant_id = $1 AND bucket = $2`
+
+// inventoryAllVersions selects every version, with head-cache objects that
+// have no version rows as "null" versions, as ListObjectVersions does. The
+// head cache describes only the current version, so older versions have no
+// encryption, replication or checksum state.
+const inventoryAllVersions = `
+	SELECT v.object_key, v.version_id, v.is_latest, v.is_delete_marker,
+		v.size_bytes, v.created_at AS last_modified, v.etag,
+		COALESCE(v.backend_name, '') AS backend_name,
+		COALESCE(h.encryption_algorithm, '') AS encryption_algorithm,
+		COALESCE(h.replication_status, '') AS replication_status,
+		COALESCE(h.checksum_algorithm, '') AS checksum_algorithm
+	FROM object_versions v
+	LEFT JOIN object_head_cache h
+		ON h.tenant_id = v.tenant_id AND h.bucket = v.bucket AND h.object_key = v.object_key
+		AND v.is_latest AND NOT v.is_delete_marker
+	WHERE v.tenant_id = $1 AND v.bucket = $2
+	UNION ALL
+	SELECT h.object_key, 'null', TRUE, FALSE, h.size_bytes, h.updated_at, h.etag,
+		COALESCE(h.backend_name, ''), COALESCE(h.encryption_algorithm, ''),
+		COALESCE(h.replication_status, ''), COALESCE(h.checksum_algorithm, '')
+	FROM object_head_cache h
+	WHERE h.tenant_id = $1 AND h.bucket = $2
+	  AND NOT EXISTS (
+		SELECT 1 FROM object_versions v
+		WHERE v.tenant_id = h.tenant_id AND v.bucket = h.bucket AND v.object_key = h.object_key)`
+
+// generateReport writes job's report to its destination in the S3
+// Inventory layout:
+//
+//	<prefix><bucket>/<id>/data/<uuid>.<csv.gz|orc|parquet>
+//	<prefix><bucket>/<id>/<YYYY-MM-DDTHH-MMZ>/manifest.json
+//	<prefix><bucket>/<id>/<YYYY-MM-DDTHH-MMZ>/manifest.checksum
+//	<prefix><bucket>/<id>/hive/dt=<YYYY-MM-DD-HH-MM>/symlink.txt
+func (ir *InventoryRunner) generateReport(ctx context.Context, job inventoryJob) error {
+	format, ok := inventoryFormats[job.format]
+	if !ok {
+		format = inventoryFormats["csv"]
+	}
+	objects := inventoryCurrentObjects
+	if job.includedVersions == inventoryVersionsAll {
+		objects = inventoryAllVersions
+	}
+	cols := inventoryColumns(job.includedVersions, job.optionalFields)
+
+	prefix := job.prefix
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	now := time.Now().UTC()
+	base := prefix + job.bucket + "/" + job.id + "/"
+	report := &inventoryReport{
+		eng:       ir.eng,
+		container: fmt.Sprintf("tenant/%s/%s", job.tenantID, job.targetBucket),
+		dataDir:   base + "data/",
+		format:    format,
+		cols:      cols,
+	}
 
-	_ = w.Write([]string{"Key", "SizeBytes", "ETag", "ContentType", "LastModified", "EncryptionAlgorithm", "BackendName"})
+	rows, err := ir.db.QueryContext(ctx, fmt.Sprintf(inventoryObjectsQuery, objects), job.tenantID, job.bucket)
+	if err != nil {
+		return fmt.Errorf("query objects: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
 
 	var count int
 	for rows.Next() {
-		var key, etag, contentType, encAlgo, backendName string
-		var sizeBytes int64
-		var updatedAt time.Time
-		if err := rows.Scan(&key, &sizeBytes, &etag, &contentType, &updatedAt, &encAlgo, &backendName); err != nil {
-			ir.logger.Error("scan inventory row", zap.Error(err))
-			continue
+		var o inventoryRow
+		if err := rows.Scan(&o.key, &o.versionID, &o.isLatest, &o.isDeleteMarker, &o.size,
+			&o.lastModified, &o.etag, &o.backendName, &o.encryption,
+			&o.replication, &o.checksum,
+			&o.lockMode, &o.retainUntil, &o.legalHold); err != nil {
+			return fmt.Errorf("scan object: %w", err)
+		}
+		row := make([]any, len(cols))
+		for i, c := range cols {
+			row[i] = c.value(job.bucket, &o)
+		}
+		if err := report.write(ctx, row); err != nil {
+			return err
 		}
-		_ = w.Write([]string{
-			key,
-			fmt.Sprintf("%d", sizeBytes),
-			etag,
-			contentType,
-			updatedAt.UTC().Format(time.RFC3339),
-			encAlgo,
-			backendName,
-		})
 		count++
 	}
-	w.Flush()
-
-	if count == 0 {
-		return
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("query objects: %w", err)
+	}
+	if err := report.finishFile(ctx); err != nil {
+		return err
 	}
 
-	now := time.Now().UTC()
-	objectKey := fmt.Sprintf("%s%s/%sT00-00Z/manifest.csv",
-		prefix, bucket, now.Format("2006-01-02"))
-
-	container := fmt.Sprintf("tenant/%s/%s", tenantID, targetBucket)
-
-	if _, err := ir.eng.Put(ctx, container, objectKey, strings.NewReader(buf.String())); err != nil {
-		ir.logger.Error("write inventory report",
-			zap.String("tenant_id", tenantID),
-			zap.String("target", targetBucket+"/"+objectKey),
-			zap.Error(err))
-		return
+	manifest := inventoryManifest{
+		SourceBucket:      job.bucket,
+		DestinationBucket: "arn:aws:s3:::" + job.targetBucket,
+		Version:           "2016-11-30",
+		CreationTimestamp: strconv.FormatInt(now.UnixMilli(), 10),
+		FileFormat:        format.name,
+		FileSchema:        format.schema(cols),
+		Files:             report.files,
+	}
+	if manifest.Files == nil {
+		manifest.Files = []inventoryManifestFile{}
+	}
+	manifestKey, err := writeInventoryManifest(ctx, ir.eng, report.container, job.targetBucket,
+		base+now.Format("2006-01-02T15-04Z")+"/",
+		base+"hive/dt="+now.Format("2006-01-02-15-04")+"/",
+		manifest)
+	if err != nil {
+		return err
 	}
 
 	ir.logger.Info("inventory report generated",
-		zap.String("tenant_id", tenantID),
-		zap.String("bucket", bucket),
-		zap.String("target", targetBucket+"/"+objectKey),
-		zap.Int("objects", count))
+		zap.String("tenant_id", job.tenantID),
+		zap.String("bucket", job.bucket),
+		zap.String("target", job.targetBucket+"/"+manifestKey),
+		zap.String("format", format.name),
+		zap.Int("objects", count),
+		zap.Int("files", len(manifest.Files)))
+	return nil
 }
 
-// GenerateReportNow is exposed for testing — generates a report immediately.
-func (ir *InventoryRunner) GenerateReportNow(ctx context.Context, tenantID, bucket, targetBucket, prefix, format string) {
+// GenerateReportNow is exposed for testing — generates bucket's report
+// from its stored configuration immediately.
+func (ir *InventoryRunner) GenerateReportNow(ctx context.Context, tenantID, bucket string) error {
 	if ir == nil {
-		return
+		return nil
+	}
+	jobs, err := ir.inventoryJobs(ctx, tenantID, bucket)
+	if err != nil {
+		return err
+	}
+	if len(jobs) == 0 {
+		return fmt.Errorf("inventory is not enabled for bucket %s", bucket)
 	}
-	ir.generateReport(ctx, tenantID, bucket, targetBucket, prefix, format)
+	return ir.generateReport(ctx, jobs[0])
 }
@@ -0,0 +1,450 @@
+package api
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/md5" // #nosec G501 — manifest checksums are MD5 by S3 definition
+	"database/sql"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/FairForge/vaultaire/internal/crypto"
+	"github.com/FairForge/vaultaire/internal/engine"
+	"github.com/FairForge/vaultaire/internal/orc"
+	"github.com/FairForge/vaultaire/internal/parquet"
+	"github.com/google/uuid"
+)
+
+// inventoryRowsPerFile caps the rows in one inventory data file; larger
+// inventories are split across several files listed in the manifest.
+const inventoryRowsPerFile = 250000
+
+// IncludedObjectVersions values.
+const (
+	inventoryVersionsCurrent = "Current"
+	inventoryVersionsAll     = "All"
+)
+
+// inventoryOptionalFields lists the supported OptionalFields values in the
+// order their columns appear in a report.
+var inventoryOptionalFields = []string{
+	"Size",
+	"LastModifiedDate",
+	"ETag",
+	"StorageClass",
+	"IsMultipartUploaded",
+	"ReplicationStatus",
+	"EncryptionStatus",
+	"ObjectLockRetainUntilDate",
+	"ObjectLockMode",
+	"ObjectLockLegalHoldStatus",
+	"ChecksumAlgorithm",
+}
+
+// inventoryRow is one object version as a report lists it.
+type inventoryRow struct {
+	key            string
+	versionID      string
+	isLatest       bool
+	isDeleteMarker bool
+	size           int64
+	lastModified   time.Time
+	etag           string
+	backendName    string
+	encryption     string
+	replication    string
+	checksum       string
+	lockMode       string
+	retainUntil    sql.NullTime
+	legalHold      bool
+}
+
+// inventoryColumn is one report column. name is the CSV schema name; ORC
+// and Parquet files use its snake_case form.
+type inventoryColumn struct {
+	name  string
+	kind  orc.Kind
+	value func(bucket string, r *inventoryRow) any
+}
+
+// inventoryColumnsByName defines every column a report can hold.
+var inventoryColumnsByName = map[string]inventoryColumn{
+	"Bucket": {"Bucket", orc.String, func(b string, _ *inventoryRow) any { return b }},
+	"Key":    {"Key", orc.String, func(_ string, r *inventoryRow) any { return r.key }},
+	"VersionId": {"VersionId", orc.String, func(_ string, r *inventoryRow) any {
+		return r.versionID
+	}},
+	"IsLatest": {"IsLatest", orc.Boolean, func(_ string, r *inventoryRow) any {
+		return r.isLatest
+	}},
+	"IsDeleteMarker": {"IsDeleteMarker", orc.Boolean, func(_ string, r *inventoryRow) any {
+		return r.isDeleteMarker
+	}},
+	"Size": {"Size", orc.Long, func(_ string, r *inventoryRow) any {
+		if r.isDeleteMarker {
+			return nil
+		}
+		return r.size
+	}},
+	"LastModifiedDate": {"LastModifiedDate", orc.Timestamp, func(_ string, r *inventoryRow) any {
+		return r.lastModified.UTC()
+	}},
+	"ETag": {"ETag", orc.String, func(_ string, r *inventoryRow) any {
+		if r.isDeleteMarker {
+			return nil
+		}
+		return strings.Trim(r.etag, `"`)
+	}},
+	"StorageClass": {"StorageClass", orc.String, func(_ string, r *inventoryRow) any {
+		if r.isDeleteMarker {
+			return nil
+		}
+		return engine.BackendToStorageClass(r.backendName)
+	}},
+	"IsMultipartUploaded": {"IsMultipartUploaded", orc.Boolean, func(_ string, r *inventoryRow) any {
+		if r.isDeleteMarker {
+			return nil
+		}
+		return strings.Contains(r.etag, "-")
+	}},
+	"ReplicationStatus": {"ReplicationStatus", orc.String, func(_ string, r *inventoryRow) any {
+		return nullIfEmpty(r.replication)
+	}},
+	"EncryptionStatus": {"EncryptionStatus", orc.String, func(_ string, r *inventoryRow) any {
+		if r.isDeleteMarker {
+			return nil
+		}
+		return inventoryEncryptionStatus(r.encryption)
+	}},
+	"ObjectLockRetainUntilDate": {"ObjectLockRetainUntilDate", orc.Timestamp, func(_ string, r *inventoryRow) any {
+		if !r.retainUntil.Valid {
+			return nil
+		}
+		return r.retainUntil.Time.UTC()
+	}},
+	"ObjectLockMode": {"ObjectLockMode", orc.String, func(_ string, r *inventoryRow) any {
+		return nullIfEmpty(r.lockMode)
+	}},
+	"ObjectLockLegalHoldStatus": {"ObjectLockLegalHoldStatus", orc.String, func(_ string, r *inventoryRow) any {
+		if r.isDeleteMarker {
+			return nil
+		}
+		if r.legalHold {
+			return "ON"
+		}
+		return "OFF"
+	}},
+	"ChecksumAlgorithm": {"ChecksumAlgorithm", orc.String, func(_ string, r *inventoryRow) any {
+		return nullIfEmpty(r.checksum)
+	}},
+}
+
+// inventoryColumns returns a report's columns: Bucket and Key, the version
+// columns when every version is listed, then the optional fields.
+func inventoryColumns(includedVersions string, optionalFields []string) []inventoryColumn {
+	names := []string{"Bucket", "Key"}
+	if includedVersions == inventoryVersionsAll {
+		names = append(names, "VersionId", "IsLatest", "IsDeleteMarker")
+	}
+	wanted := make(map[string]bool, len(optionalFields))
+	for _, f := range optionalFields {
+		wanted[f] = true
+	}
+	for _, f := range inventoryOptionalFields {
+		if wanted[f] {
+			names = append(names, f)
+		}
+	}
+	cols := make([]inventoryColumn, len(names))
+	for i, n := range names {
+		cols[i] = inventoryColumnsByName[n]
+	}
+	return cols
+}
+
+// inventoryConfigError is an InvalidArgument message for a rejected
+// inventory configuration.
+type inventoryConfigError string
+
+func (e inventoryConfigError) Error() string { return string(e) }
+
+// normalizeInventoryFields validates OptionalFields values and returns
+// them deduplicated in column order.
+func normalizeInventoryFields(fields []string) ([]string, error) {
+	seen := make(map[string]bool, len(fields))
+	for _, f := range fields {
+		f = strings.TrimSpace(f)
+		if !slices.Contains(inventoryOptionalFields, f) {
+			return nil, inventoryConfigError(fmt.Sprintf(
+				"Unsupported inventory optional field %q. Supported fields: %s.",
+				f, strings.Join(inventoryOptionalFields, ", ")))
+		}
+		seen[f] = true
+	}
+	out := make([]string, 0, len(seen))
+	for _, f := range inventoryOptionalFields {
+		if seen[f] {
+			out = append(out, f)
+		}
+	}
+	return out, nil
+}
+
+// inventoryEncryptionStatus maps an object's encryption algorithm to the
+// S3 Inventory EncryptionStatus value.
+func inventoryEncryptionStatus(algorithm string) string {
+	switch algorithm {
+	case "":
+		return "NOT-SSE"
+	case crypto.SSECAlgorithm:
+		return "SSE-C"
+	case crypto.SSEKMSAlgorithm:
+		return "SSE-KMS"
+	}
+	return "SSE-S3"
+}
+
+// snakeCase turns a CSV column name into its ORC and Parquet name, as in
+// "LastModifiedDate" → "last_modified_date" and "ETag" → "e_tag".
+func snakeCase(name string) string {
+	var b strings.Builder
+	runes := []rune(name)
+	for i, r := range runes {
+		if unicode.IsUpper(r) {
+			if i > 0 && (unicode.IsLower(runes[i-1]) ||
+				(i+1 < len(runes) && unicode.IsLower(runes[i+1]))) {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// inventoryFormat encodes report data files in one of the S3 Inventory
+// formats.
+type inventoryFormat struct {
+	name      string // manifest fileFormat
+	extension string
+	schema    func(cols []inventoryColumn) string
+	newWriter func(w io.Writer, cols []inventoryColumn) (inventoryWriter, error)
+}
+
+// inventoryWriter encodes report rows; Close flushes the file.
+type inventoryWriter interface {
+	Write(row []any) error
+	Close() error
+}
+
+var inventoryFormats = map[string]inventoryFormat{
+	"csv": {
+		name:      "CSV",
+		extension: ".csv.gz",
+		schema: func(cols []inventoryColumn) string {
+			names := make([]string, len(cols))
+			for i, c := range cols {
+				names[i] = c.name
+			}
+			return strings.Join(names, ", ")
+		},
+		newWriter: func(w io.Writer, _ []inventoryColumn) (inventoryWriter, error) {
+			zw := gzip.NewWriter(w)
+			return &csvInventoryWriter{zw: zw, cw: csv.NewWriter(zw)}, nil
+		},
+	},
+	"orc": {
+		name:      "ORC",
+		extension: ".orc",
+		schema: func(cols []inventoryColumn) string {
+			return orc.Schema(orcInventoryFields(cols))
+		},
+		newWriter: func(w io.Writer, cols []inventoryColumn) (inventoryWriter, error) {
+			return orc.NewWriter(w, orcInventoryFields(cols))
+		},
+	},
+	"parquet": {
+		name:      "Parquet",
+		extension: ".parquet",
+		schema: func(cols []inventoryColumn) string {
+			return parquet.Schema("s3.inventory", parquetInventoryFields(cols))
+		},
+		newWriter: func(w io.Writer, cols []inventoryColumn) (inventoryWriter, error) {
+			return parquet.NewWriter(w, "s3.inventory", parquetInventoryFields(cols))
+		},
+	},
+}
+
+func orcInventoryFields(cols []inventoryColumn) []orc.Field {
+	fields := make([]orc.Field, len(cols))
+	for i, c := range cols {
+		fields[i] = orc.Field{Name: snakeCase(c.name), Kind: c.kind}
+	}
+	return fields
+}
+
+func parquetInventoryFields(cols []inventoryColumn) []parquet.Field {
+	fields := make([]parquet.Field, len(cols))
+	for i, c := range cols {
+		f := parquet.Field{Name: snakeCase(c.name), Optional: i >= 2}
+		switch c.kind {
+		case orc.Boolean:
+			f.Type = parquet.Boolean
+		case orc.Long:
+			f.Type = parquet.Int64
+		case orc.Timestamp:
+			f.Type, f.Timestamp = parquet.Int64, true
+		default:
+			f.Type = parquet.ByteArray
+		}
+		fields[i] = f
+	}
+	return fields
+}
+
+// csvInventoryWriter writes gzipped CSV without a header row, the schema
+// being in the manifest. Keys are URL-encoded as S3 encodes them.
+type csvInventoryWriter struct {
+	zw *gzip.Writer
+	cw *csv.Writer
+}
+
+func (w *csvInventoryWriter) Write(row []any) error {
+	rec := make([]string, len(row))
+	for i, v := range row {
+		switch x := v.(type) {
+		case string:
+			if i == 1 {
+				x = url.QueryEscape(x)
+			}
+			rec[i] = x
+		case bool:
+			rec[i] = strconv.FormatBool(x)
+		case int64:
+			rec[i] = strconv.FormatInt(x, 10)
+		case time.Time:
+			rec[i] = x.UTC().Format("2006-01-02T15:04:05.000Z")
+		}
+	}
+	return w.cw.Write(rec)
+}
+
+func (w *csvInventoryWriter) Close() error {
+	w.cw.Flush()
+	if err := w.cw.Error(); err != nil {
+		return err
+	}
+	return w.zw.Close()
+}
+
+// inventoryManifest is the manifest.json S3 Inventory writes with each
+// report.
+type inventoryManifest struct {
+	SourceBucket      string                  `json:"sourceBucket"`
+	DestinationBucket string                  `json:"destinationBucket"`
+	Version           string                  `json:"version"`
+	CreationTimestamp string                  `json:"creationTimestamp"`
+	FileFormat        string                  `json:"fileFormat"`
+	FileSchema        string                  `json:"fileSchema"`
+	Files             []inventoryManifestFile `json:"files"`
+}
+
+type inventoryManifestFile struct {
+	Key         string `json:"key"`
+	Size        int64  `json:"size"`
+	MD5Checksum string `json:"MD5checksum"`
+}
+
+// inventoryReport accumulates one report's data files in the destination
+// bucket, splitting them every inventoryRowsPerFile rows.
+type inventoryReport struct {
+	eng       *engine.CoreEngine
+	container string
+	dataDir   string
+	format    inventoryFormat
+	cols      []inventoryColumn
+
+	buf   bytes.Buffer
+	w     inventoryWriter
+	rows  int
+	files []inventoryManifestFile
+}
+
+func (r *inventoryReport) write(ctx context.Context, row []any) error {
+	if r.w == nil {
+		r.buf.Reset()
+		w, err := r.format.newWriter(&r.buf, r.cols)
+		if err != nil {
+			return err
+		}
+		r.w, r.rows = w, 0
+	}
+	if err := r.w.Write(row); err != nil {
+		return err
+	}
+	r.rows++
+	if r.rows >= inventoryRowsPerFile {
+		return r.finishFile(ctx)
+	}
+	return nil
+}
+
+// finishFile closes the current data file and stores it.
+func (r *inventoryReport) finishFile(ctx context.Context) error {
+	if r.w == nil {
+		return nil
+	}
+	if err := r.w.Close(); err != nil {
+		return err
+	}
+	r.w = nil
+	key := r.dataDir + uuid.New().String() + r.format.extension
+	sum := md5.Sum(r.buf.Bytes()) // #nosec G401 — S3 manifest checksum
+	if _, err := r.eng.Put(ctx, r.container, key, bytes.NewReader(r.buf.Bytes())); err != nil {
+		return fmt.Errorf("write inventory data file %s: %w", key, err)
+	}
+	r.files = append(r.files, inventoryManifestFile{
+		Key:         key,
+		Size:        int64(r.buf.Len()),
+		MD5Checksum: hex.EncodeToString(sum[:]),
+	})
+	return nil
+}
+
+// writeInventoryManifest stores manifest.json and manifest.checksum under
+// manifestDir, and the Hive symlink.txt listing the data files for
+// Athena-style readers.
+func writeInventoryManifest(ctx context.Context, eng *engine.CoreEngine, container, targetBucket, manifestDir, hiveDir string, m inventoryManifest) (string, error) {
+	body, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	sum := md5.Sum(body) // #nosec G401 — S3 manifest checksum
+	var links strings.Builder
+	for _, f := range m.Files {
+		links.WriteString("s3://" + targetBucket + "/" + f.Key + "\n")
+	}
+
+	// The manifest goes last: its presence marks the report complete.
+	objects := []struct{ key, body string }{
+		{hiveDir + "symlink.txt", links.String()},
+		{manifestDir + "manifest.checksum", hex.EncodeToString(sum[:])},
+		{manifestDir + "manifest.json", string(body)},
+	}
+	for _, o := range objects {
+		if _, err := eng.Put(ctx, container, o.key, strings.NewReader(o.body)); err != nil {
+			return "", fmt.Errorf("write %s: %w", o.key, err)
+		}
+	}
+	return manifestDir + "manifest.json", nil
+}
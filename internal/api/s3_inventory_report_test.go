@@ -0,0 +1,122 @@
+package api
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/csv"
+	"testing"
+	"time"
+
+	"github.com/FairForge/vaultaire/internal/crypto"
+	"github.com/FairForge/vaultaire/internal/parquet"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func columnNames(cols []inventoryColumn) []string {
+	names := make([]string, len(cols))
+	for i, c := range cols {
+		names[i] = c.name
+	}
+	return names
+}
+
+func TestInventoryColumns(t *testing.T) {
+	assert.Equal(t, []string{"Bucket", "Key"}, columnNames(inventoryColumns(inventoryVersionsCurrent, nil)))
+	assert.Equal(t,
+		[]string{"Bucket", "Key", "VersionId", "IsLatest", "IsDeleteMarker", "Size", "ETag"},
+		columnNames(inventoryColumns(inventoryVersionsAll, []string{"ETag", "Size"})))
+}
+
+func TestNormalizeInventoryFields(t *testing.T) {
+	fields, err := normalizeInventoryFields([]string{"ETag", " Size ", "ETag", "ObjectLockMode"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Size", "ETag", "ObjectLockMode"}, fields)
+
+	_, err = normalizeInventoryFields([]string{"Size", "Owner"})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `"Owner"`)
+}
+
+func TestInventoryEncryptionStatus(t *testing.T) {
+	assert.Equal(t, "NOT-SSE", inventoryEncryptionStatus(""))
+	assert.Equal(t, "SSE-C", inventoryEncryptionStatus(crypto.SSECAlgorithm))
+	assert.Equal(t, "SSE-KMS", inventoryEncryptionStatus(crypto.SSEKMSAlgorithm))
+	assert.Equal(t, "SSE-S3", inventoryEncryptionStatus(crypto.SSEAlgorithm))
+}
+
+func TestSnakeCase(t *testing.T) {
+	for in, want := range map[string]string{
+		"Bucket":                    "bucket",
+		"VersionId":                 "version_id",
+		"ETag":                      "e_tag",
+		"LastModifiedDate":          "last_modified_date",
+		"ObjectLockRetainUntilDate": "object_lock_retain_until_date",
+	} {
+		assert.Equal(t, want, snakeCase(in), in)
+	}
+}
+
+// encodeInventory writes rows in format and returns the file.
+func encodeInventory(t *testing.T, format string, cols []inventoryColumn, rows ...*inventoryRow) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := inventoryFormats[format].newWriter(&buf, cols)
+	require.NoError(t, err)
+	for _, r := range rows {
+		row := make([]any, len(cols))
+		for i, c := range cols {
+			row[i] = c.value("photos", r)
+		}
+		require.NoError(t, w.Write(row))
+	}
+	require.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+func TestInventoryFormats(t *testing.T) {
+	modified := time.Date(2026, 3, 4, 5, 6, 7, 890e6, time.UTC)
+	cols := inventoryColumns(inventoryVersionsAll, []string{"Size", "LastModifiedDate", "IsMultipartUploaded"})
+	rows := []*inventoryRow{
+		{key: "a b/c.jpg", versionID: "v1", isLatest: true, size: 42, lastModified: modified, etag: `"abc-2"`},
+		{key: "gone", versionID: "v2", isLatest: true, isDeleteMarker: true, lastModified: modified},
+	}
+
+	t.Run("csv", func(t *testing.T) {
+		assert.Equal(t, "Bucket, Key, VersionId, IsLatest, IsDeleteMarker, Size, LastModifiedDate, IsMultipartUploaded",
+			inventoryFormats["csv"].schema(cols))
+		zr, err := gzip.NewReader(bytes.NewReader(encodeInventory(t, "csv", cols, rows...)))
+		require.NoError(t, err)
+		records, err := csv.NewReader(zr).ReadAll()
+		require.NoError(t, err)
+		assert.Equal(t, [][]string{
+			{"photos", "a+b%2Fc.jpg", "v1", "true", "false", "42", "2026-03-04T05:06:07.890Z", "true"},
+			{"photos", "gone", "v2", "true", "true", "", "2026-03-04T05:06:07.890Z", ""},
+		}, records)
+	})
+
+	t.Run("parquet", func(t *testing.T) {
+		data := encodeInventory(t, "parquet", cols, rows...)
+		f, err := parquet.Open(bytes.NewReader(data), int64(len(data)))
+		require.NoError(t, err)
+		require.Equal(t, int64(2), f.NumRows())
+		got, err := f.ReadRowGroup(0, []int{0, 1, 5, 6, 7})
+		require.NoError(t, err)
+		assert.Equal(t, [][]any{
+			{"photos", "photos"},
+			{"a b/c.jpg", "gone"},
+			{int64(42), nil},
+			{modified, modified},
+			{true, nil},
+		}, got)
+	})
+
+	t.Run("orc", func(t *testing.T) {
+		assert.Equal(t,
+			"struct<bucket:string,key:string,version_id:string,is_latest:boolean,is_delete_marker:boolean,"+
+				"size:bigint,last_modified_date:timestamp,is_multipart_uploaded:boolean>",
+			inventoryFormats["orc"].schema(cols))
+		data := encodeInventory(t, "orc", cols, rows...)
+		assert.Equal(t, "ORC", string(data[:3]))
+	})
+}
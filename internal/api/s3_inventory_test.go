@@ -2,9 +2,13 @@ package api
 
 import (
 	"bytes"
+	"compress/gzip"
 	"context"
+	"crypto/md5" // #nosec G501 — manifest checksums are MD5 by S3 definition
 	"database/sql"
 	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
 	"encoding/xml"
 	"fmt"
 	"net/http"
@@ -13,9 +17,11 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/FairForge/vaultaire/internal/drivers"
 	"github.com/FairForge/vaultaire/internal/engine"
+	"github.com/FairForge/vaultaire/internal/parquet"
 	"github.com/FairForge/vaultaire/internal/tenant"
 	"github.com/go-chi/chi/v5"
 	"github.com/stretchr/testify/assert"
@@ -196,6 +202,75 @@ func TestDeleteBucketInventory(t *testing.T) {
 	assert.False(t, resp.IsEnabled)
 }
 
+// putInventoryConfig enables inventory on the fixture bucket.
+func (f *inventoryFixture) putInventoryConfig(t *testing.T, format, versions string, fields ...string) {
+	t.Helper()
+	var optional strings.Builder
+	for _, field := range fields {
+		optional.WriteString("<Field>" + field + "</Field>")
+	}
+	configXML := fmt.Sprintf(`<InventoryConfiguration xmlns="http://s3.amazonaws.com/doc/2006-03-01/">
+  <Id>report-1</Id>
+  <IsEnabled>true</IsEnabled>
+  <Destination>
+    <S3BucketDestination>
+      <Bucket>arn:aws:s3:::%s</Bucket>
+      <Prefix>inv</Prefix>
+      <Format>%s</Format>
+    </S3BucketDestination>
+  </Destination>
+  <IncludedObjectVersions>%s</IncludedObjectVersions>
+  <OptionalFields>%s</OptionalFields>
+  <Schedule><Frequency>Daily</Frequency></Schedule>
+</InventoryConfiguration>`, f.invBucket, format, versions, optional.String())
+
+	s3Req := &S3Request{Bucket: f.bucket, TenantID: f.tenantID, Query: map[string]string{"id": "report-1"}}
+	ctx := tenant.WithTenant(context.Background(), f.tenant)
+	r := httptest.NewRequest("PUT", "/"+f.bucket+"?inventory&id=report-1", strings.NewReader(configXML)).WithContext(ctx)
+	w := httptest.NewRecorder()
+	f.server.handlePutBucketInventory(w, r, s3Req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+}
+
+// readInventoryManifest returns the report's manifest after checking it
+// against manifest.checksum.
+func (f *inventoryFixture) readInventoryManifest(t *testing.T) inventoryManifest {
+	t.Helper()
+	root := filepath.Join(f.tempDir, "tenant", f.tenantID, f.invBucket)
+	matches, err := filepath.Glob(filepath.Join(root, "inv", f.bucket, "report-1", "*", "manifest.json"))
+	require.NoError(t, err)
+	require.Len(t, matches, 1, "manifest.json not found")
+
+	body, err := os.ReadFile(matches[0])
+	require.NoError(t, err)
+	checksum, err := os.ReadFile(filepath.Join(filepath.Dir(matches[0]), "manifest.checksum"))
+	require.NoError(t, err)
+	sum := md5.Sum(body)
+	assert.Equal(t, hex.EncodeToString(sum[:]), string(checksum))
+
+	var m inventoryManifest
+	require.NoError(t, json.Unmarshal(body, &m))
+	assert.Equal(t, f.bucket, m.SourceBucket)
+	assert.Equal(t, "arn:aws:s3:::"+f.invBucket, m.DestinationBucket)
+	assert.Equal(t, "2016-11-30", m.Version)
+	for _, file := range m.Files {
+		assert.True(t, strings.HasPrefix(file.Key, "inv/"+f.bucket+"/report-1/data/"), file.Key)
+		data, err := os.ReadFile(filepath.Join(root, file.Key))
+		require.NoError(t, err)
+		assert.Equal(t, int64(len(data)), file.Size)
+		sum := md5.Sum(data)
+		assert.Equal(t, hex.EncodeToString(sum[:]), file.MD5Checksum)
+	}
+	return m
+}
+
+func (f *inventoryFixture) readInventoryFile(t *testing.T, key string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(f.tempDir, "tenant", f.tenantID, f.invBucket, key))
+	require.NoError(t, err)
+	return data
+}
+
 func TestInventoryCSV_Format(t *testing.T) {
 	f := setupInventoryFixture(t)
 
@@ -208,52 +283,124 @@ func TestInventoryCSV_Format(t *testing.T) {
 		`, f.tenantID, f.bucket, key, (i+1)*1024, fmt.Sprintf("etag-%d", i), "application/octet-stream", "local")
 		require.NoError(t, err)
 	}
+	f.putInventoryConfig(t, "CSV", "Current", "ETag", "Size", "EncryptionStatus", "Size")
 
 	// Run inventory report
 	logger := zap.NewNop()
 	runner := NewInventoryRunner(f.db, f.eng, logger)
 	require.NotNil(t, runner)
+	require.NoError(t, runner.GenerateReportNow(context.Background(), f.tenantID, f.bucket))
 
-	runner.GenerateReportNow(context.Background(), f.tenantID, f.bucket, f.invBucket, "inv/", "csv")
+	m := f.readInventoryManifest(t)
+	assert.Equal(t, "CSV", m.FileFormat)
+	assert.Equal(t, "Bucket, Key, Size, ETag, EncryptionStatus", m.FileSchema)
+	require.Len(t, m.Files, 1)
+	assert.True(t, strings.HasSuffix(m.Files[0].Key, ".csv.gz"))
 
-	// Read the generated inventory object from the local filesystem
-	container := fmt.Sprintf("tenant/%s/%s", f.tenantID, f.invBucket)
-	containerPath := filepath.Join(f.tempDir, container)
+	zr, err := gzip.NewReader(bytes.NewReader(f.readInventoryFile(t, m.Files[0].Key)))
+	require.NoError(t, err)
+	records, err := csv.NewReader(zr).ReadAll()
+	require.NoError(t, err)
 
-	// Find the CSV file under inv/
-	var csvPath string
-	err := filepath.Walk(containerPath, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-		if !info.IsDir() && strings.Contains(path, "manifest.csv") {
-			csvPath = path
-		}
-		return nil
+	// No header row; data rows are sorted by key, keys URL-encoded.
+	assert.Equal(t, [][]string{
+		{f.bucket, "file-a.txt", "1024", "etag-0", "NOT-SSE"},
+		{f.bucket, "file-b.jpg", "2048", "etag-1", "NOT-SSE"},
+		{f.bucket, "folder%2Ffile-c.pdf", "3072", "etag-2", "NOT-SSE"},
+	}, records)
+}
+
+func TestInventoryParquet_AllVersions(t *testing.T) {
+	f := setupInventoryFixture(t)
+	t.Cleanup(func() {
+		_, _ = f.db.Exec("DELETE FROM object_versions WHERE tenant_id = $1", f.tenantID)
+		_, _ = f.db.Exec("DELETE FROM object_locks WHERE tenant_id = $1", f.tenantID)
 	})
-	require.NoError(t, err)
-	require.NotEmpty(t, csvPath, "inventory CSV file not found")
 
-	data, err := os.ReadFile(csvPath)
+	old := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	_, err := f.db.Exec(`
+		INSERT INTO object_head_cache (tenant_id, bucket, object_key, size_bytes, etag, content_type,
+			backend_name, encryption_algorithm, updated_at)
+		VALUES ($1, $2, 'doc.txt', 20, 'etag-new', 'text/plain', 'local', 'aws:kms', $3),
+		       ($1, $2, 'plain.txt', 5, 'etag-p', 'text/plain', 'local', '', $3)
+	`, f.tenantID, f.bucket, old.Add(time.Hour))
 	require.NoError(t, err)
+	_, err = f.db.Exec(`
+		INSERT INTO object_versions (tenant_id, bucket, object_key, version_id, size_bytes, etag,
+			is_latest, is_delete_marker, backend_name, created_at)
+		VALUES ($1, $2, 'doc.txt', 'v1', 10, 'etag-old', FALSE, FALSE, 'local', $3),
+		       ($1, $2, 'doc.txt', 'v2', 20, 'etag-new', TRUE, FALSE, 'local', $4),
+		       ($1, $2, 'gone.txt', 'v3', 0, '', TRUE, TRUE, NULL, $4)
+	`, f.tenantID, f.bucket, old, old.Add(time.Hour))
+	require.NoError(t, err)
+	_, err = f.db.Exec(`
+		INSERT INTO object_locks (tenant_id, bucket, object_key, retention_mode, retain_until_date, legal_hold)
+		VALUES ($1, $2, 'doc.txt', 'GOVERNANCE', $3, TRUE)
+	`, f.tenantID, f.bucket, old.AddDate(1, 0, 0))
+	require.NoError(t, err)
+
+	f.putInventoryConfig(t, "Parquet", "All", "Size", "EncryptionStatus", "ObjectLockMode",
+		"ObjectLockRetainUntilDate", "ObjectLockLegalHoldStatus")
+	runner := NewInventoryRunner(f.db, f.eng, zap.NewNop())
+	require.NoError(t, runner.GenerateReportNow(context.Background(), f.tenantID, f.bucket))
 
-	reader := csv.NewReader(bytes.NewReader(data))
-	records, err := reader.ReadAll()
+	m := f.readInventoryManifest(t)
+	assert.Equal(t, "Parquet", m.FileFormat)
+	assert.Contains(t, m.FileSchema, "optional int64 object_lock_retain_until_date (TIMESTAMP_MILLIS);")
+	require.Len(t, m.Files, 1)
+
+	data := f.readInventoryFile(t, m.Files[0].Key)
+	pf, err := parquet.Open(bytes.NewReader(data), int64(len(data)))
 	require.NoError(t, err)
+	var names []string
+	for _, c := range pf.Columns() {
+		names = append(names, c.Name)
+	}
+	assert.Equal(t, []string{"bucket", "key", "version_id", "is_latest", "is_delete_marker", "size",
+		"encryption_status", "object_lock_retain_until_date", "object_lock_mode",
+		"object_lock_legal_hold_status"}, names)
 
-	// Header + 3 data rows
-	require.Len(t, records, 4)
+	cols, err := pf.ReadRowGroup(0, []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9})
+	require.NoError(t, err)
+	row := func(i int) []any {
+		out := make([]any, len(cols))
+		for c := range cols {
+			out[c] = cols[c][i]
+		}
+		return out
+	}
+	require.Equal(t, int64(4), pf.NumRows())
+	assert.Equal(t, []any{f.bucket, "doc.txt", "v2", true, false, int64(20), "SSE-KMS",
+		old.AddDate(1, 0, 0), "GOVERNANCE", "ON"}, row(0))
+	assert.Equal(t, []any{f.bucket, "doc.txt", "v1", false, false, int64(10), "NOT-SSE",
+		nil, nil, "OFF"}, row(1))
+	assert.Equal(t, []any{f.bucket, "gone.txt", "v3", true, true, nil, nil, nil, nil, nil}, row(2))
+	assert.Equal(t, []any{f.bucket, "plain.txt", "null", true, false, int64(5), "NOT-SSE",
+		nil, nil, "OFF"}, row(3))
+}
 
-	// Verify header
-	assert.Equal(t, []string{"Key", "SizeBytes", "ETag", "ContentType", "LastModified", "EncryptionAlgorithm", "BackendName"}, records[0])
+func TestPutBucketInventory_InvalidConfig(t *testing.T) {
+	f := setupInventoryFixture(t)
+	ctx := tenant.WithTenant(context.Background(), f.tenant)
+	s3Req := &S3Request{Bucket: f.bucket, TenantID: f.tenantID}
 
-	// Verify data rows are sorted by key
-	assert.Equal(t, "file-a.txt", records[1][0])
-	assert.Equal(t, "1024", records[1][1])
-	assert.Equal(t, "file-b.jpg", records[2][0])
-	assert.Equal(t, "2048", records[2][1])
-	assert.Equal(t, "folder/file-c.pdf", records[3][0])
-	assert.Equal(t, "3072", records[3][1])
+	for name, extra := range map[string]string{
+		"versions": "<IncludedObjectVersions>Some</IncludedObjectVersions>",
+		"field":    "<OptionalFields><Field>Owner</Field></OptionalFields>",
+	} {
+		t.Run(name, func(t *testing.T) {
+			configXML := fmt.Sprintf(`<InventoryConfiguration>
+  <IsEnabled>true</IsEnabled>
+  <Destination><S3BucketDestination><Bucket>%s</Bucket><Format>ORC</Format></S3BucketDestination></Destination>
+  %s
+</InventoryConfiguration>`, f.invBucket, extra)
+			r := httptest.NewRequest("PUT", "/"+f.bucket+"?inventory", strings.NewReader(configXML)).WithContext(ctx)
+			w := httptest.NewRecorder()
+			f.server.handlePutBucketInventory(w, r, s3Req)
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Contains(t, w.Body.String(), "InvalidArgument")
+		})
+	}
 }
 
 func TestCountingResponseWriter_CapturesStatusCode(t *testing.T) {
@@ -0,0 +1,1136 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/FairForge/vaultaire/internal/common"
+	"github.com/FairForge/vaultaire/internal/crypto"
+	"github.com/FairForge/vaultaire/internal/engine"
+	"github.com/FairForge/vaultaire/internal/tenant"
+	"go.uber.org/zap"
+)
+
+const (
+	maxLifecycleBodyBytes = 65536
+	maxLifecycleRules     = 1000
+	maxLifecycleRuleID    = 255
+
+	// lifecycleBatchSize bounds the objects one rule action touches per run,
+	// so a rule matching millions of keys drains over several cycles instead
+	// of holding one scan open for hours.
+	lifecycleBatchSize = 1000
+)
+
+// LifecycleConfiguration is the S3 XML document for GET/PUT ?lifecycle.
+type LifecycleConfiguration struct {
+	XMLName xml.Name        `xml:"LifecycleConfiguration"`
+	Xmlns   string          `xml:"xmlns,attr,omitempty"`
+	Rules   []LifecycleRule `xml:"Rule"`
+}
+
+// LifecycleRule is a single lifecycle rule. Prefix is the legacy
+// (pre-Filter) form; new clients send Filter.
+type LifecycleRule struct {
+	ID                             string                          `xml:"ID,omitempty"`
+	Status                         string                          `xml:"Status"`
+	Prefix                         *string                         `xml:"Prefix"`
+	Filter                         *LifecycleFilter                `xml:"Filter"`
+	Expiration                     *LifecycleExpiration            `xml:"Expiration,omitempty"`
+	Transitions                    []LifecycleTransition           `xml:"Transition,omitempty"`
+	NoncurrentVersionExpiration    *NoncurrentVersionExpiration    `xml:"NoncurrentVersionExpiration,omitempty"`
+	AbortIncompleteMultipartUpload *AbortIncompleteMultipartUpload `xml:"AbortIncompleteMultipartUpload,omitempty"`
+}
+
+// LifecycleFilter selects the objects a rule applies to. At most one of
+// Prefix, Tag, the size bounds or And may be set; And combines them.
+type LifecycleFilter struct {
+	Prefix                *string               `xml:"Prefix"`
+	Tag                   *Tag                  `xml:"Tag,omitempty"`
+	ObjectSizeGreaterThan int64                 `xml:"ObjectSizeGreaterThan,omitempty"`
+	ObjectSizeLessThan    int64                 `xml:"ObjectSizeLessThan,omitempty"`
+	And                   *LifecycleAndOperator `xml:"And,omitempty"`
+}
+
+// LifecycleAndOperator is the conjunction form of a lifecycle filter.
+type LifecycleAndOperator struct {
+	Prefix                string `xml:"Prefix,omitempty"`
+	Tags                  []Tag  `xml:"Tag,omitempty"`
+	ObjectSizeGreaterThan int64  `xml:"ObjectSizeGreaterThan,omitempty"`
+	ObjectSizeLessThan    int64  `xml:"ObjectSizeLessThan,omitempty"`
+}
+
+// LifecycleExpiration expires current object versions.
+type LifecycleExpiration struct {
+	Days                      int    `xml:"Days,omitempty"`
+	Date                      string `xml:"Date,omitempty"`
+	ExpiredObjectDeleteMarker bool   `xml:"ExpiredObjectDeleteMarker,omitempty"`
+}
+
+// LifecycleTransition moves current objects to another storage class.
+type LifecycleTransition struct {
+	Days         int    `xml:"Days,omitempty"`
+	Date         string `xml:"Date,omitempty"`
+	StorageClass string `xml:"StorageClass"`
+}
+
+// NoncurrentVersionExpiration expires noncurrent versions in versioned
+// buckets, optionally retaining the newest NewerNoncurrentVersions.
+type NoncurrentVersionExpiration struct {
+	NoncurrentDays          int `xml:"NoncurrentDays"`
+	NewerNoncurrentVersions int `xml:"NewerNoncurrentVersions,omitempty"`
+}
+
+// AbortIncompleteMultipartUpload aborts uploads left open too long.
+type AbortIncompleteMultipartUpload struct {
+	DaysAfterInitiation int `xml:"DaysAfterInitiation"`
+}
+
+// lifecycleObject is the slice of an object_head_cache row that rule
+// filters and actions need.
+type lifecycleObject struct {
+	key       string
+	size      int64
+	etag      string
+	updatedAt time.Time
+	tags      map[string]string
+	isChunked bool
+	backend   string
+}
+
+// prefix returns the key prefix a rule applies to ("" matches everything).
+func (rule *LifecycleRule) prefix() string {
+	if rule.Filter != nil {
+		switch {
+		case rule.Filter.And != nil:
+			return rule.Filter.And.Prefix
+		case rule.Filter.Prefix != nil:
+			return *rule.Filter.Prefix
+		}
+		return ""
+	}
+	if rule.Prefix != nil {
+		return *rule.Prefix
+	}
+	return ""
+}
+
+// tags returns the tag conditions a rule requires.
+func (rule *LifecycleRule) tags() []Tag {
+	if rule.Filter == nil {
+		return nil
+	}
+	if rule.Filter.And != nil {
+		return rule.Filter.And.Tags
+	}
+	if rule.Filter.Tag != nil {
+		return []Tag{*rule.Filter.Tag}
+	}
+	return nil
+}
+
+// sizeBounds returns the exclusive (greater-than, less-than) object size
+// bounds; 0 means unbounded.
+func (rule *LifecycleRule) sizeBounds() (int64, int64) {
+	if rule.Filter == nil {
+		return 0, 0
+	}
+	if rule.Filter.And != nil {
+		return rule.Filter.And.ObjectSizeGreaterThan, rule.Filter.And.ObjectSizeLessThan
+	}
+	return rule.Filter.ObjectSizeGreaterThan, rule.Filter.ObjectSizeLessThan
+}
+
+// filterSQL renders the rule's size and tag conditions as predicates over
+// object_head_cache, numbering its parameters from next. Filtering in SQL
+// lets each batch fill with matching objects: filtering after the LIMIT
+// never reaches matches behind a batch of older non-matching ones.
+func (rule *LifecycleRule) filterSQL(next int) (string, []any) {
+	var b strings.Builder
+	var args []any
+	gt, lt := rule.sizeBounds()
+	if gt > 0 {
+		args = append(args, gt)
+		fmt.Fprintf(&b, " AND size_bytes > $%d", next+len(args)-1)
+	}
+	if lt > 0 {
+		args = append(args, lt)
+		fmt.Fprintf(&b, " AND size_bytes < $%d", next+len(args)-1)
+	}
+	if tags := rule.tags(); len(tags) > 0 {
+		want := make(map[string]string, len(tags))
+		for _, tag := range tags {
+			want[tag.Key] = tag.Value
+		}
+		raw, _ := json.Marshal(want)
+		args = append(args, string(raw))
+		fmt.Fprintf(&b, " AND tags @> $%d::jsonb", next+len(args)-1)
+	}
+	return b.String(), args
+}
+
+// matches reports whether an object satisfies every filter condition.
+func (rule *LifecycleRule) matches(obj lifecycleObject) bool {
+	if !strings.HasPrefix(obj.key, rule.prefix()) {
+		return false
+	}
+	gt, lt := rule.sizeBounds()
+	if gt > 0 && obj.size <= gt {
+		return false
+	}
+	if lt > 0 && obj.size >= lt {
+		return false
+	}
+	for _, tag := range rule.tags() {
+		if v, ok := obj.tags[tag.Key]; !ok || v != tag.Value {
+			return false
+		}
+	}
+	return true
+}
+
+// parseLifecycleDate parses an S3 lifecycle Date. AWS requires midnight UTC
+// in ISO 8601; both the bare date and the full timestamp forms are accepted.
+func parseLifecycleDate(s string) (time.Time, error) {
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04:05.000Z", "2006-01-02"} {
+		if d, err := time.Parse(layout, s); err == nil {
+			d = d.UTC()
+			if d.Hour() != 0 || d.Minute() != 0 || d.Second() != 0 || d.Nanosecond() != 0 {
+				return time.Time{}, fmt.Errorf("date %q must be at midnight UTC", s)
+			}
+			return d, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("date %q is not ISO 8601", s)
+}
+
+// actionCutoff returns the time before which an object's age qualifies it
+// for a Days/Date action, and false when the action is not yet due (a Date
+// in the future). Days count from the object's last modification.
+func actionCutoff(days int, date string, now time.Time) (time.Time, bool) {
+	if date != "" {
+		d, err := parseLifecycleDate(date)
+		if err != nil || now.Before(d) {
+			return time.Time{}, false
+		}
+		// Past the date every matching object qualifies.
+		return now, true
+	}
+	return now.Add(-time.Duration(days) * 24 * time.Hour), true
+}
+
+// validateLifecycle enforces the S3 schema rules the XML decoder cannot.
+func validateLifecycle(config *LifecycleConfiguration) error {
+	if len(config.Rules) == 0 {
+		return errors.New("at least one lifecycle rule is required")
+	}
+	if len(config.Rules) > maxLifecycleRules {
+		return fmt.Errorf("a lifecycle configuration may contain at most %d rules", maxLifecycleRules)
+	}
+	seen := make(map[string]bool, len(config.Rules))
+	for i := range config.Rules {
+		rule := &config.Rules[i]
+		if len(rule.ID) > maxLifecycleRuleID {
+			return fmt.Errorf("rule ID must be at most %d characters", maxLifecycleRuleID)
+		}
+		if rule.ID != "" {
+			if seen[rule.ID] {
+				return fmt.Errorf("rule ID %q is not unique", rule.ID)
+			}
+			seen[rule.ID] = true
+		}
+		if rule.Status != "Enabled" && rule.Status != "Disabled" {
+			return fmt.Errorf("rule status must be Enabled or Disabled, got %q", rule.Status)
+		}
+		if rule.Prefix != nil && rule.Filter != nil {
+			return errors.New("a rule cannot specify both Prefix and Filter")
+		}
+		if err := validateLifecycleFilter(rule.Filter); err != nil {
+			return err
+		}
+		if rule.Expiration == nil && len(rule.Transitions) == 0 &&
+			rule.NoncurrentVersionExpiration == nil && rule.AbortIncompleteMultipartUpload == nil {
+			return errors.New("each rule must specify at least one action")
+		}
+		if exp := rule.Expiration; exp != nil {
+			set := 0
+			if exp.Days != 0 {
+				set++
+			}
+			if exp.Date != "" {
+				set++
+			}
+			if exp.ExpiredObjectDeleteMarker {
+				set++
+			}
+			if set != 1 {
+				return errors.New("Expiration must specify exactly one of Days, Date or ExpiredObjectDeleteMarker")
+			}
+			if exp.Days < 0 {
+				return errors.New("Expiration Days must be a positive integer")
+			}
+			if exp.Date != "" {
+				if _, err := parseLifecycleDate(exp.Date); err != nil {
+					return err
+				}
+			}
+			if exp.ExpiredObjectDeleteMarker && len(rule.tags()) > 0 {
+				return errors.New("ExpiredObjectDeleteMarker cannot be combined with a tag filter")
+			}
+		}
+		for _, tr := range rule.Transitions {
+			if (tr.Days == 0) == (tr.Date == "") {
+				return errors.New("Transition must specify exactly one of Days or Date")
+			}
+			if tr.Days < 0 {
+				return errors.New("Transition Days must be a non-negative integer")
+			}
+			if tr.Date != "" {
+				if _, err := parseLifecycleDate(tr.Date); err != nil {
+					return err
+				}
+			}
+			// STANDARD is where objects start, not a tier to move them to.
+			if _, ok := engine.StorageClassBackend(tr.StorageClass); !ok || tr.StorageClass == "STANDARD" {
+				return fmt.Errorf("storage class %q is not supported for transitions", tr.StorageClass)
+			}
+			if rule.Expiration != nil && rule.Expiration.Days > 0 && tr.Days > 0 && tr.Days >= rule.Expiration.Days {
+				return errors.New("Transition Days must be less than Expiration Days")
+			}
+		}
+		if nve := rule.NoncurrentVersionExpiration; nve != nil {
+			if nve.NoncurrentDays <= 0 {
+				return errors.New("NoncurrentDays must be a positive integer")
+			}
+			if nve.NewerNoncurrentVersions < 0 || nve.NewerNoncurrentVersions > 100 {
+				return errors.New("NewerNoncurrentVersions must be between 1 and 100")
+			}
+		}
+		if abort := rule.AbortIncompleteMultipartUpload; abort != nil {
+			if abort.DaysAfterInitiation <= 0 {
+				return errors.New("DaysAfterInitiation must be a positive integer")
+			}
+			if len(rule.tags()) > 0 {
+				return errors.New("AbortIncompleteMultipartUpload cannot be combined with a tag filter")
+			}
+		}
+	}
+	return nil
+}
+
+func validateLifecycleFilter(f *LifecycleFilter) error {
+	if f == nil {
+		return nil
+	}
+	set := 0
+	if f.Prefix != nil {
+		set++
+	}
+	if f.Tag != nil {
+		set++
+	}
+	if f.ObjectSizeGreaterThan != 0 || f.ObjectSizeLessThan != 0 {
+		set++
+	}
+	if f.And != nil {
+		set++
+	}
+	if set > 1 {
+		return errors.New("Filter may contain only one of Prefix, Tag, an object size bound or And")
+	}
+	if f.Tag != nil && f.Tag.Key == "" {
+		return errors.New("filter Tag requires a Key")
+	}
+	gt, lt := f.ObjectSizeGreaterThan, f.ObjectSizeLessThan
+	if f.And != nil {
+		for _, tag := range f.And.Tags {
+			if tag.Key == "" {
+				return errors.New("filter Tag requires a Key")
+			}
+		}
+		gt, lt = f.And.ObjectSizeGreaterThan, f.And.ObjectSizeLessThan
+	}
+	if gt < 0 || lt < 0 {
+		return errors.New("object size bounds must be non-negative")
+	}
+	if gt > 0 && lt > 0 && gt >= lt {
+		return errors.New("ObjectSizeGreaterThan must be less than ObjectSizeLessThan")
+	}
+	return nil
+}
+
+// loadBucketLifecycle returns a bucket's stored lifecycle document. The
+// second result is false when the bucket has no configuration; sql.ErrNoRows
+// means the bucket itself does not exist.
+func loadBucketLifecycle(ctx context.Context, db *sql.DB, tenantID, bucket string) (*LifecycleConfiguration, bool, error) {
+	var raw sql.NullString
+	err := db.QueryRowContext(ctx,
+		`SELECT lifecycle_config FROM buckets WHERE tenant_id = $1 AND name = $2`,
+		tenantID, bucket).Scan(&raw)
+	if err != nil {
+		return nil, false, err
+	}
+	if !raw.Valid || raw.String == "" {
+		return nil, false, nil
+	}
+	var config LifecycleConfiguration
+	if err := xml.Unmarshal([]byte(raw.String), &config); err != nil {
+		return nil, false, fmt.Errorf("decode stored lifecycle config: %w", err)
+	}
+	return &config, true, nil
+}
+
+func (s *Server) handleGetBucketLifecycle(w http.ResponseWriter, r *http.Request, req *S3Request) {
+	t, err := tenant.FromContext(r.Context())
+	if err != nil || t == nil {
+		WriteS3Error(w, ErrAccessDenied, r.URL.Path, generateRequestID())
+		return
+	}
+
+	if s.db == nil {
+		WriteS3Error(w, ErrNoSuchLifecycleConfiguration, r.URL.Path, generateRequestID())
+		return
+	}
+
+	config, ok, err := loadBucketLifecycle(r.Context(), s.db, t.ID, req.Bucket)
+	if err == sql.ErrNoRows {
+		reqID := generateRequestID()
+		if suggestion := bucketSuggestion(r.Context(), s.db, t.ID, req.Bucket); suggestion != "" {
+			WriteS3ErrorWithContext(w, ErrNoSuchBucket, r.URL.Path, reqID, WithSuggestion(suggestion))
+		} else {
+			WriteS3Error(w, ErrNoSuchBucket, r.URL.Path, reqID)
+		}
+		return
+	}
+	if err != nil {
+		s.logger.Error("query bucket lifecycle config", zap.Error(err))
+		WriteS3Error(w, ErrInternalError, r.URL.Path, generateRequestID())
+		return
+	}
+	if !ok {
+		WriteS3Error(w, ErrNoSuchLifecycleConfiguration, r.URL.Path, generateRequestID())
+		return
+	}
+
+	config.Xmlns = "http://s3.amazonaws.com/doc/2006-03-01/"
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte(xml.Header))
+	_ = xml.NewEncoder(w).Encode(config)
+}
+
+func (s *Server) handlePutBucketLifecycle(w http.ResponseWriter, r *http.Request, req *S3Request) {
+	t, err := tenant.FromContext(r.Context())
+	if err != nil || t == nil {
+		WriteS3Error(w, ErrAccessDenied, r.URL.Path, generateRequestID())
+		return
+	}
+
+	if s.db == nil {
+		WriteS3Error(w, ErrInternalError, r.URL.Path, generateRequestID())
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxLifecycleBodyBytes))
+	if err != nil {
+		WriteS3Error(w, bodyReadErrorCode(err), r.URL.Path, generateRequestID())
+		return
+	}
+
+	var config LifecycleConfiguration
+	if err := xml.Unmarshal(body, &config); err != nil {
+		WriteS3Error(w, ErrMalformedXML, r.URL.Path, generateRequestID())
+		return
+	}
+	if err := validateLifecycle(&config); err != nil {
+		WriteS3ErrorWithContext(w, ErrInvalidArgument, r.URL.Path, generateRequestID(),
+			WithSuggestion(err.Error()))
+		return
+	}
+
+	config.Xmlns = ""
+	stored, err := xml.Marshal(config)
+	if err != nil {
+		s.logger.Error("encode lifecycle config", zap.Error(err))
+		WriteS3Error(w, ErrInternalError, r.URL.Path, generateRequestID())
+		return
+	}
+
+	result, err := s.db.ExecContext(r.Context(),
+		`UPDATE buckets SET lifecycle_config = $3, updated_at = NOW()
+		 WHERE tenant_id = $1 AND name = $2`,
+		t.ID, req.Bucket, string(stored))
+	if err != nil {
+		s.logger.Error("update bucket lifecycle config", zap.Error(err))
+		WriteS3Error(w, ErrInternalError, r.URL.Path, generateRequestID())
+		return
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		reqID := generateRequestID()
+		if suggestion := bucketSuggestion(r.Context(), s.db, t.ID, req.Bucket); suggestion != "" {
+			WriteS3ErrorWithContext(w, ErrNoSuchBucket, r.URL.Path, reqID, WithSuggestion(suggestion))
+		} else {
+			WriteS3Error(w, ErrNoSuchBucket, r.URL.Path, reqID)
+		}
+		return
+	}
+
+	s.logger.Info("bucket lifecycle config updated",
+		zap.String("tenant_id", t.ID),
+		zap.String("bucket", req.Bucket),
+		zap.Int("rules", len(config.Rules)))
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *Server) handleDeleteBucketLifecycle(w http.ResponseWriter, r *http.Request, req *S3Request) {
+	t, err := tenant.FromContext(r.Context())
+	if err != nil || t == nil {
+		WriteS3Error(w, ErrAccessDenied, r.URL.Path, generateRequestID())
+		return
+	}
+
+	if s.db == nil {
+		WriteS3Error(w, ErrInternalError, r.URL.Path, generateRequestID())
+		return
+	}
+
+	result, err := s.db.ExecContext(r.Context(),
+		`UPDATE buckets SET lifecycle_config = NULL, updated_at = NOW()
+		 WHERE tenant_id = $1 AND name = $2`,
+		t.ID, req.Bucket)
+	if err != nil {
+		s.logger.Error("delete bucket lifecycle config", zap.Error(err))
+		WriteS3Error(w, ErrInternalError, r.URL.Path, generateRequestID())
+		return
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		reqID := generateRequestID()
+		if suggestion := bucketSuggestion(r.Context(), s.db, t.ID, req.Bucket); suggestion != "" {
+			WriteS3ErrorWithContext(w, ErrNoSuchBucket, r.URL.Path, reqID, WithSuggestion(suggestion))
+		} else {
+			WriteS3Error(w, ErrNoSuchBucket, r.URL.Path, reqID)
+		}
+		return
+	}
+
+	s.logger.Info("bucket lifecycle config deleted",
+		zap.String("tenant_id", t.ID),
+		zap.String("bucket", req.Bucket))
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// LifecycleRunner applies bucket lifecycle rules in the background.
+//
+// Each cycle walks every bucket with a configuration and, per enabled rule:
+//   - aborts incomplete multipart uploads (shared with MultipartReaper),
+//   - expires current objects (a delete marker in versioning-enabled
+//     buckets, a real delete otherwise — object-locked keys are skipped),
+//   - removes expired object delete markers,
+//   - expires noncurrent versions past NoncurrentDays, keeping the newest
+//     NewerNoncurrentVersions,
+//   - transitions current objects to the rule's storage class through the
+//     engine's TieringEngine.
+//
+// Expiration runs before transitions so an object due for both is deleted
+// rather than copied and then deleted. Chunked objects never transition:
+// their chunks live in the shared dedup container, not on one backend.
+type LifecycleRunner struct {
+	db     *sql.DB
+	eng    *engine.CoreEngine
+	gci    *crypto.GlobalContentIndex
+	quota  QuotaManager
+	notify *NotificationDispatcher
+	logger *zap.Logger
+}
+
+// LifecycleResult holds the outcome of a single lifecycle cycle.
+type LifecycleResult struct {
+	Expired              int `json:"expired"`
+	DeleteMarkersCreated int `json:"delete_markers_created"`
+	DeleteMarkersRemoved int `json:"delete_markers_removed"`
+	NoncurrentExpired    int `json:"noncurrent_expired"`
+	UploadsAborted       int `json:"uploads_aborted"`
+	Transitioned         int `json:"transitioned"`
+}
+
+func (lr LifecycleResult) total() int {
+	return lr.Expired + lr.DeleteMarkersCreated + lr.DeleteMarkersRemoved +
+		lr.NoncurrentExpired + lr.UploadsAborted + lr.Transitioned
+}
+
+func NewLifecycleRunner(db *sql.DB, eng *engine.CoreEngine, gci *crypto.GlobalContentIndex, qm QuotaManager, logger *zap.Logger) *LifecycleRunner {
+	if db == nil || eng == nil {
+		return nil
+	}
+	return &LifecycleRunner{
+		db:     db,
+		eng:    eng,
+		gci:    gci,
+		quota:  qm,
+		notify: NewNotificationDispatcher(db, logger),
+		logger: logger,
+	}
+}
+
+// Start runs one immediate cycle and then one per hour until ctx is done.
+// Rules are expressed in days, so hourly granularity is ample and a restart
+// never postpones due work by a full day.
+func (lr *LifecycleRunner) Start(ctx context.Context) {
+	if lr == nil {
+		return
+	}
+	go func() {
+		lr.runAndLog(ctx)
+		ticker := time.NewTicker(1 * time.Hour)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				lr.runAndLog(ctx)
+			}
+		}
+	}()
+}
+
+func (lr *LifecycleRunner) runAndLog(ctx context.Context) {
+	result, err := lr.RunOnce(ctx)
+	if err != nil {
+		lr.logger.Error("lifecycle cycle failed", zap.Error(err))
+		return
+	}
+	if result.total() > 0 {
+		lr.logger.Info("lifecycle cycle completed",
+			zap.Int("expired", result.Expired),
+			zap.Int("delete_markers_created", result.DeleteMarkersCreated),
+			zap.Int("delete_markers_removed", result.DeleteMarkersRemoved),
+			zap.Int("noncurrent_expired", result.NoncurrentExpired),
+			zap.Int("uploads_aborted", result.UploadsAborted),
+			zap.Int("transitioned", result.Transitioned))
+	}
+}
+
+// RunOnce performs a single lifecycle cycle at the current time.
+func (lr *LifecycleRunner) RunOnce(ctx context.Context) (LifecycleResult, error) {
+	return lr.runAt(ctx, time.Now().UTC())
+}
+
+type lifecycleBucket struct {
+	tenantID   string
+	name       string
+	versioning string
+	config     LifecycleConfiguration
+}
+
+func (lr *LifecycleRunner) runAt(ctx context.Context, now time.Time) (LifecycleResult, error) {
+	var result LifecycleResult
+
+	rows, err := lr.db.QueryContext(ctx, `
+		SELECT tenant_id, name, versioning_status, lifecycle_config
+		FROM buckets
+		WHERE lifecycle_config IS NOT NULL
+	`)
+	if err != nil {
+		return result, fmt.Errorf("select lifecycle buckets: %w", err)
+	}
+	var buckets []lifecycleBucket
+	for rows.Next() {
+		var b lifecycleBucket
+		var raw string
+		if err := rows.Scan(&b.tenantID, &b.name, &b.versioning, &raw); err != nil {
+			_ = rows.Close()
+			return result, fmt.Errorf("scan lifecycle bucket: %w", err)
+		}
+		if err := xml.Unmarshal([]byte(raw), &b.config); err != nil {
+			lr.logger.Error("lifecycle: undecodable stored config, skipping bucket",
+				zap.String("tenant_id", b.tenantID), zap.String("bucket", b.name), zap.Error(err))
+			continue
+		}
+		buckets = append(buckets, b)
+	}
+	if err := rows.Err(); err != nil {
+		_ = rows.Close()
+		return result, fmt.Errorf("iterate lifecycle buckets: %w", err)
+	}
+	_ = rows.Close()
+
+	for _, b := range buckets {
+		for i := range b.config.Rules {
+			rule := &b.config.Rules[i]
+			if rule.Status != "Enabled" {
+				continue
+			}
+			if ctx.Err() != nil {
+				return result, ctx.Err()
+			}
+			lr.applyRule(ctx, b, rule, now, &result)
+		}
+	}
+	return result, nil
+}
+
+func (lr *LifecycleRunner) applyRule(ctx context.Context, b lifecycleBucket, rule *LifecycleRule, now time.Time, result *LifecycleResult) {
+	log := lr.logger.With(
+		zap.String("tenant_id", b.tenantID),
+		zap.String("bucket", b.name),
+		zap.String("rule", rule.ID))
+
+	if abort := rule.AbortIncompleteMultipartUpload; abort != nil {
+		n, err := lr.abortIncompleteUploads(ctx, b, rule.prefix(), now.Add(-time.Duration(abort.DaysAfterInitiation)*24*time.Hour))
+		if err != nil {
+			log.Error("lifecycle: abort incomplete uploads", zap.Error(err))
+		}
+		result.UploadsAborted += n
+	}
+
+	if exp := rule.Expiration; exp != nil {
+		if exp.ExpiredObjectDeleteMarker {
+			n, err := lr.removeExpiredDeleteMarkers(ctx, b, rule.prefix())
+			if err != nil {
+				log.Error("lifecycle: remove expired delete markers", zap.Error(err))
+			}
+			result.DeleteMarkersRemoved += n
+		} else if cutoff, due := actionCutoff(exp.Days, exp.Date, now); due {
+			lr.expireCurrent(ctx, b, rule, cutoff, result, log)
+		}
+	}
+
+	if nve := rule.NoncurrentVersionExpiration; nve != nil {
+		n, err := lr.expireNoncurrent(ctx, b, rule.prefix(), nve, now)
+		if err != nil {
+			log.Error("lifecycle: expire noncurrent versions", zap.Error(err))
+		}
+		result.NoncurrentExpired += n
+	}
+
+	// Each object gets only the latest transition it is due for: an object
+	// 400 days old under RESILIENT@30 + GLACIER@365 goes straight to
+	// GLACIER, and the RESILIENT transition then skips it — both because it
+	// is old enough for GLACIER and because it is already on GLACIER's
+	// backend. Without that the two would move it back and forth each cycle.
+	transitions := append([]LifecycleTransition(nil), rule.Transitions...)
+	sort.SliceStable(transitions, func(i, j int) bool {
+		return transitionAfter(transitions[i], transitions[j])
+	})
+	var later []dueTransition
+	for _, tr := range transitions {
+		cutoff, due := actionCutoff(tr.Days, tr.Date, now)
+		if !due {
+			continue
+		}
+		lr.transitionCurrent(ctx, b, rule, tr, cutoff, later, result, log)
+		target, _ := engine.StorageClassBackend(tr.StorageClass)
+		later = append(later, dueTransition{cutoff: cutoff, backend: target})
+	}
+}
+
+// dueTransition is a due transition that takes precedence over the rule's
+// earlier ones: objects last modified before cutoff, or already on backend,
+// are its to move.
+type dueTransition struct {
+	cutoff  time.Time
+	backend string
+}
+
+// transitionAfter orders transitions latest-first (Days descending; a Date
+// sorts by its own value).
+func transitionAfter(a, b LifecycleTransition) bool {
+	if a.Date != "" && b.Date != "" {
+		return a.Date > b.Date
+	}
+	return a.Days > b.Days
+}
+
+// likePrefix returns a LIKE pattern matching keys that start with prefix.
+func likePrefix(prefix string) string {
+	escaped := strings.ReplaceAll(prefix, `\`, `\\`)
+	escaped = strings.ReplaceAll(escaped, `%`, `\%`)
+	escaped = strings.ReplaceAll(escaped, `_`, `\_`)
+	return escaped + "%"
+}
+
+func (lr *LifecycleRunner) abortIncompleteUploads(ctx context.Context, b lifecycleBucket, prefix string, cutoff time.Time) (int, error) {
+	rows, err := lr.db.QueryContext(ctx, `
+		SELECT upload_id FROM multipart_uploads
+		WHERE tenant_id = $1 AND bucket = $2 AND status = 'active'
+		  AND object_key LIKE $3 ESCAPE '\'
+		  AND created_at < $4
+		LIMIT $5
+	`, b.tenantID, b.name, likePrefix(prefix), cutoff, lifecycleBatchSize)
+	if err != nil {
+		return 0, fmt.Errorf("select incomplete uploads: %w", err)
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			_ = rows.Close()
+			return 0, fmt.Errorf("scan incomplete upload: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	_ = rows.Close()
+
+	var aborted int
+	for _, id := range ids {
+		ok, err := abortActiveUpload(ctx, lr.db, lr.logger, id)
+		if err != nil {
+			lr.logger.Error("lifecycle: abort upload", zap.String("upload_id", id), zap.Error(err))
+			continue
+		}
+		if ok {
+			aborted++
+		}
+	}
+	return aborted, nil
+}
+
+// candidates returns current objects under the rule's prefix last modified
+// before cutoff that satisfy the rule's tag and size filters. extra is an
+// additional SQL predicate over object_head_cache (parameters start at $5).
+func (lr *LifecycleRunner) candidates(ctx context.Context, b lifecycleBucket, rule *LifecycleRule, cutoff time.Time, extra string, args ...any) ([]lifecycleObject, error) {
+	filter, filterArgs := rule.filterSQL(5 + len(args))
+	query := `
+		SELECT object_key, size_bytes, etag, updated_at, COALESCE(tags, '{}'),
+			is_chunked, COALESCE(backend_name, '')
+		FROM object_head_cache
+		WHERE tenant_id = $1 AND bucket = $2
+		  AND object_key LIKE $3 ESCAPE '\'
+		  AND updated_at < $4` + extra + filter + `
+		ORDER BY updated_at ASC
+		LIMIT ` + fmt.Sprint(lifecycleBatchSize)
+	queryArgs := append([]any{b.tenantID, b.name, likePrefix(rule.prefix()), cutoff}, args...)
+	queryArgs = append(queryArgs, filterArgs...)
+
+	rows, err := lr.db.QueryContext(ctx, query, queryArgs...)
+	if err != nil {
+		return nil, fmt.Errorf("select lifecycle candidates: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var objs []lifecycleObject
+	for rows.Next() {
+		var obj lifecycleObject
+		var tagsJSON []byte
+		if err := rows.Scan(&obj.key, &obj.size, &obj.etag, &obj.updatedAt, &tagsJSON, &obj.isChunked, &obj.backend); err != nil {
+			return nil, fmt.Errorf("scan lifecycle candidate: %w", err)
+		}
+		obj.tags = map[string]string{}
+		_ = json.Unmarshal(tagsJSON, &obj.tags)
+		if rule.matches(obj) {
+			objs = append(objs, obj)
+		}
+	}
+	return objs, rows.Err()
+}
+
+func (lr *LifecycleRunner) expireCurrent(ctx context.Context, b lifecycleBucket, rule *LifecycleRule, cutoff time.Time, result *LifecycleResult, log *zap.Logger) {
+	objs, err := lr.candidates(ctx, b, rule, cutoff, "")
+	if err != nil {
+		log.Error("lifecycle: expiration candidates", zap.Error(err))
+		return
+	}
+	for _, obj := range objs {
+		if b.versioning == "Enabled" {
+			created, err := lr.createDeleteMarker(ctx, b, obj)
+			if err != nil {
+				log.Error("lifecycle: create delete marker", zap.String("key", obj.key), zap.Error(err))
+				continue
+			}
+			if !created {
+				continue
+			}
+			result.DeleteMarkersCreated++
+			lr.notify.Fire(b.tenantID, b.name, "s3:LifecycleExpiration:DeleteMarkerCreated", obj.key, 0, "")
+			continue
+		}
+
+		deleted, err := lr.deleteObject(ctx, b, obj)
+		if err != nil {
+			log.Error("lifecycle: expire object", zap.String("key", obj.key), zap.Error(err))
+			continue
+		}
+		if !deleted {
+			continue
+		}
+		result.Expired++
+		lr.notify.Fire(b.tenantID, b.name, "s3:LifecycleExpiration:Delete", obj.key, obj.size, obj.etag)
+		emitEvent(ctx, lr.db, lr.logger, "object.deleted", b.tenantID, map[string]interface{}{
+			"bucket": b.name, "key": obj.key, "reason": "lifecycle",
+		})
+	}
+}
+
+// lockCandidate takes obj's head-cache key lock in tx and reports whether
+// the head row is still the one obj was selected from. A key overwritten
+// since selection is a new object whose age starts over, and a deleted one
+// has nothing left to expire; either way the candidate is skipped.
+func lockCandidate(ctx context.Context, tx *sql.Tx, b lifecycleBucket, obj lifecycleObject) (bool, error) {
+	if err := lockHeadKey(ctx, tx, b.tenantID, b.name, obj.key); err != nil {
+		return false, err
+	}
+	var etag string
+	var updatedAt time.Time
+	err := tx.QueryRowContext(ctx, `
+		SELECT etag, updated_at FROM object_head_cache
+		WHERE tenant_id = $1 AND bucket = $2 AND object_key = $3`,
+		b.tenantID, b.name, obj.key).Scan(&etag, &updatedAt)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("recheck head cache row: %w", err)
+	}
+	return etag == obj.etag && updatedAt.Equal(obj.updatedAt), nil
+}
+
+// createDeleteMarker expires the current version of a key in a
+// versioning-enabled bucket, mirroring DeleteObject without a versionId.
+// It reports false (and no error) when the key changed since obj was
+// selected.
+func (lr *LifecycleRunner) createDeleteMarker(ctx context.Context, b lifecycleBucket, obj lifecycleObject) (bool, error) {
+	tx, err := lr.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("begin delete marker: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if current, err := lockCandidate(ctx, tx, b, obj); err != nil || !current {
+		return false, err
+	}
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE object_versions SET is_latest = FALSE
+		WHERE tenant_id = $1 AND bucket = $2 AND object_key = $3 AND is_latest = TRUE`,
+		b.tenantID, b.name, obj.key); err != nil {
+		return false, fmt.Errorf("demote latest version: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO object_versions
+			(tenant_id, bucket, object_key, version_id, size_bytes, etag, content_type, is_latest, is_delete_marker)
+		VALUES ($1, $2, $3, $4, 0, '', 'application/octet-stream', TRUE, TRUE)`,
+		b.tenantID, b.name, obj.key, generateVersionID()); err != nil {
+		return false, fmt.Errorf("insert delete marker: %w", err)
+	}
+	var size int64
+	if err := tx.QueryRowContext(ctx, `
+		DELETE FROM object_head_cache
+		WHERE tenant_id = $1 AND bucket = $2 AND object_key = $3
+		RETURNING size_bytes`,
+		b.tenantID, b.name, obj.key).Scan(&size); err != nil {
+		return false, fmt.Errorf("delete head cache row: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("commit delete marker: %w", err)
+	}
+	lr.releaseQuota(ctx, b.tenantID, size)
+	return true, nil
+}
+
+// deleteObject permanently removes an unversioned object. It reports false
+// (and no error) when the object is protected by Object Lock or the key
+// changed since obj was selected.
+//
+// The head row goes first, under the key lock and only while it is still
+// obj's, so a failure leaves the object whole. A chunked object's
+// references are released in the same transaction; backend bytes are
+// removed after commit, and only while no writer has recreated the key.
+func (lr *LifecycleRunner) deleteObject(ctx context.Context, b lifecycleBucket, obj lifecycleObject) (bool, error) {
+	if err := checkObjectLock(ctx, lr.db, b.tenantID, b.name, obj.key, false); err != nil {
+		if errors.Is(err, errObjectLocked) {
+			return false, nil
+		}
+		return false, fmt.Errorf("check object lock: %w", err)
+	}
+	if obj.isChunked && lr.gci == nil {
+		return false, errors.New("chunked object but no content index configured")
+	}
+
+	tx, err := lr.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("begin expiration: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if current, err := lockCandidate(ctx, tx, b, obj); err != nil || !current {
+		return false, err
+	}
+	var size int64
+	if err := tx.QueryRowContext(ctx, `
+		DELETE FROM object_head_cache
+		WHERE tenant_id = $1 AND bucket = $2 AND object_key = $3
+		RETURNING size_bytes`,
+		b.tenantID, b.name, obj.key).Scan(&size); err != nil {
+		return false, fmt.Errorf("delete head cache row: %w", err)
+	}
+	if obj.isChunked {
+		if err := lr.gci.DeleteObjectChunksTx(ctx, tx, b.tenantID, b.name, obj.key); err != nil {
+			return false, fmt.Errorf("release chunk manifest: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("commit expiration: %w", err)
+	}
+	lr.releaseQuota(ctx, b.tenantID, size)
+
+	if !obj.isChunked {
+		lr.deleteBytes(ctx, b, obj.key)
+	}
+	return true, nil
+}
+
+// deleteBytes removes an expired object's backend bytes once its head row
+// is gone. It holds the key lock and leaves the bytes alone if the key has
+// a head row again: a writer recreated it and the bytes are now its own.
+// A failure only leaves unreferenced bytes behind, so it is logged.
+func (lr *LifecycleRunner) deleteBytes(ctx context.Context, b lifecycleBucket, key string) {
+	log := lr.logger.With(zap.String("tenant_id", b.tenantID),
+		zap.String("bucket", b.name), zap.String("key", key))
+
+	tx, err := lr.db.BeginTx(ctx, nil)
+	if err != nil {
+		log.Error("lifecycle: begin backend delete", zap.Error(err))
+		return
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := lockHeadKey(ctx, tx, b.tenantID, b.name, key); err != nil {
+		log.Error("lifecycle: lock key for backend delete", zap.Error(err))
+		return
+	}
+	var recreated bool
+	if err := tx.QueryRowContext(ctx, `
+		SELECT EXISTS (SELECT 1 FROM object_head_cache
+		WHERE tenant_id = $1 AND bucket = $2 AND object_key = $3)`,
+		b.tenantID, b.name, key).Scan(&recreated); err != nil {
+		log.Error("lifecycle: recheck key for backend delete", zap.Error(err))
+		return
+	}
+	if recreated {
+		return
+	}
+	container := fmt.Sprintf("tenant/%s/%s", b.tenantID, b.name)
+	engCtx := common.WithTenantID(ctx, b.tenantID)
+	if err := lr.eng.Delete(engCtx, container, key); err != nil && !isObjectMissingErr(err) {
+		log.Error("lifecycle: delete from backend", zap.Error(err))
+	}
+}
+
+func (lr *LifecycleRunner) releaseQuota(ctx context.Context, tenantID string, size int64) {
+	if lr.quota == nil || size <= 0 {
+		return
+	}
+	if err := lr.quota.ReleaseQuota(ctx, tenantID, size); err != nil {
+		lr.logger.Error("lifecycle: quota release failed",
+			zap.Error(err), zap.String("tenant_id", tenantID), zap.Int64("bytes", size))
+	}
+}
+
+// removeExpiredDeleteMarkers deletes delete markers that are the only
+// remaining version of their key — they hide nothing and only slow listings.
+func (lr *LifecycleRunner) removeExpiredDeleteMarkers(ctx context.Context, b lifecycleBucket, prefix string) (int, error) {
+	res, err := lr.db.ExecContext(ctx, `
+		DELETE FROM object_versions v
+		WHERE v.tenant_id = $1 AND v.bucket = $2
+		  AND v.object_key LIKE $3 ESCAPE '\'
+		  AND v.is_delete_marker = TRUE AND v.is_latest = TRUE
+		  AND NOT EXISTS (
+		      SELECT 1 FROM object_versions o
+		      WHERE o.tenant_id = v.tenant_id AND o.bucket = v.bucket
+		        AND o.object_key = v.object_key AND o.version_id != v.version_id
+		  )
+	`, b.tenantID, b.name, likePrefix(prefix))
+	if err != nil {
+		return 0, err
+	}
+	n, _ := res.RowsAffected()
+	return int(n), nil
+}
+
+// expireNoncurrent deletes noncurrent versions that became noncurrent
+// (their successor was created) more than NoncurrentDays ago, retaining the
+// newest NewerNoncurrentVersions noncurrent versions of each key. Versions
+// are metadata-only (the current bytes live under the key itself), so no
+// backend data is touched.
+func (lr *LifecycleRunner) expireNoncurrent(ctx context.Context, b lifecycleBucket, prefix string, nve *NoncurrentVersionExpiration, now time.Time) (int, error) {
+	cutoff := now.Add(-time.Duration(nve.NoncurrentDays) * 24 * time.Hour)
+	res, err := lr.db.ExecContext(ctx, `
+		DELETE FROM object_versions d
+		USING (
+			SELECT object_key, version_id, is_latest,
+				LAG(created_at) OVER w AS noncurrent_since,
+				ROW_NUMBER() OVER w AS position
+			FROM object_versions
+			WHERE tenant_id = $1 AND bucket = $2
+			  AND object_key LIKE $3 ESCAPE '\'
+			WINDOW w AS (PARTITION BY object_key ORDER BY created_at DESC)
+		) ranked
+		WHERE d.tenant_id = $1 AND d.bucket = $2
+		  AND d.object_key = ranked.object_key AND d.version_id = ranked.version_id
+		  AND NOT ranked.is_latest
+		  AND ranked.noncurrent_since < $4
+		  AND ranked.position - 1 > $5
+	`, b.tenantID, b.name, likePrefix(prefix), cutoff, nve.NewerNoncurrentVersions)
+	if err != nil {
+		return 0, err
+	}
+	n, _ := res.RowsAffected()
+	return int(n), nil
+}
+
+func (lr *LifecycleRunner) transitionCurrent(ctx context.Context, b lifecycleBucket, rule *LifecycleRule, tr LifecycleTransition, cutoff time.Time, later []dueTransition, result *LifecycleResult, log *zap.Logger) {
+	target, _ := engine.StorageClassBackend(tr.StorageClass)
+	if _, ok := lr.eng.GetDriver(target); !ok {
+		log.Debug("lifecycle: transition target backend not registered, skipping",
+			zap.String("storage_class", tr.StorageClass), zap.String("target", target))
+		return
+	}
+	tiering := lr.eng.Tiering()
+	if tiering == nil {
+		return
+	}
+
+	extra := ` AND is_chunked = FALSE AND COALESCE(backend_name, '') NOT IN ('', $5)`
+	args := []any{target}
+	for _, l := range later {
+		args = append(args, l.cutoff, l.backend)
+		extra += fmt.Sprintf(` AND updated_at >= $%d AND COALESCE(backend_name, '') != $%d`, 3+len(args), 4+len(args))
+	}
+	objs, err := lr.candidates(ctx, b, rule, cutoff, extra, args...)
+	if err != nil {
+		log.Error("lifecycle: transition candidates", zap.Error(err))
+		return
+	}
+
+	container := fmt.Sprintf("tenant/%s/%s", b.tenantID, b.name)
+	engCtx := common.WithTenantID(ctx, b.tenantID)
+	for _, obj := range objs {
+		if err := tiering.MigrateObject(engCtx, b.tenantID, container, obj.key, obj.backend, target, tr.StorageClass, obj.size); err != nil {
+			log.Warn("lifecycle: transition failed",
+				zap.String("key", obj.key), zap.String("target", target), zap.Error(err))
+			continue
+		}
+		// Conditional on the ETag: an overwrite that raced the copy wrote a
+		// new row (and new bytes) that must keep its own backend.
+		if _, err := lr.db.ExecContext(ctx, `
+			UPDATE object_head_cache SET backend_name = $4
+			WHERE tenant_id = $1 AND bucket = $2 AND object_key = $3 AND etag = $5`,
+			b.tenantID, b.name, obj.key, target, obj.etag); err != nil {
+			log.Error("lifecycle: record transitioned backend", zap.String("key", obj.key), zap.Error(err))
+			continue
+		}
+		result.Transitioned++
+		lr.notify.Fire(b.tenantID, b.name, "s3:LifecycleTransition", obj.key, obj.size, obj.etag)
+	}
+}
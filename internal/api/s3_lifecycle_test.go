@@ -0,0 +1,462 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/FairForge/vaultaire/internal/drivers"
+	"github.com/FairForge/vaultaire/internal/engine"
+	"github.com/FairForge/vaultaire/internal/tenant"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	_ "github.com/lib/pq"
+)
+
+func parseLifecycleXML(t *testing.T, doc string) *LifecycleConfiguration {
+	t.Helper()
+	var config LifecycleConfiguration
+	require.NoError(t, xml.Unmarshal([]byte(doc), &config))
+	return &config
+}
+
+func TestValidateLifecycle(t *testing.T) {
+	tests := []struct {
+		name    string
+		doc     string
+		wantErr bool
+	}{
+		{
+			name: "expiration days with prefix filter",
+			doc: `<LifecycleConfiguration><Rule><ID>logs</ID><Status>Enabled</Status>
+				<Filter><Prefix>logs/</Prefix></Filter><Expiration><Days>30</Days></Expiration></Rule></LifecycleConfiguration>`,
+		},
+		{
+			name: "legacy prefix with abort upload",
+			doc: `<LifecycleConfiguration><Rule><Status>Enabled</Status><Prefix></Prefix>
+				<AbortIncompleteMultipartUpload><DaysAfterInitiation>7</DaysAfterInitiation></AbortIncompleteMultipartUpload></Rule></LifecycleConfiguration>`,
+		},
+		{
+			name: "transition and expiration",
+			doc: `<LifecycleConfiguration><Rule><Status>Enabled</Status><Filter></Filter>
+				<Transition><Days>30</Days><StorageClass>GLACIER</StorageClass></Transition>
+				<Expiration><Days>365</Days></Expiration></Rule></LifecycleConfiguration>`,
+		},
+		{
+			name:    "no rules",
+			doc:     `<LifecycleConfiguration></LifecycleConfiguration>`,
+			wantErr: true,
+		},
+		{
+			name: "bad status",
+			doc: `<LifecycleConfiguration><Rule><Status>On</Status>
+				<Expiration><Days>1</Days></Expiration></Rule></LifecycleConfiguration>`,
+			wantErr: true,
+		},
+		{
+			name:    "no action",
+			doc:     `<LifecycleConfiguration><Rule><Status>Enabled</Status></Rule></LifecycleConfiguration>`,
+			wantErr: true,
+		},
+		{
+			name: "duplicate IDs",
+			doc: `<LifecycleConfiguration>
+				<Rule><ID>a</ID><Status>Enabled</Status><Expiration><Days>1</Days></Expiration></Rule>
+				<Rule><ID>a</ID><Status>Enabled</Status><Expiration><Days>2</Days></Expiration></Rule></LifecycleConfiguration>`,
+			wantErr: true,
+		},
+		{
+			name: "days and date together",
+			doc: `<LifecycleConfiguration><Rule><Status>Enabled</Status>
+				<Expiration><Days>1</Days><Date>2030-01-01T00:00:00Z</Date></Expiration></Rule></LifecycleConfiguration>`,
+			wantErr: true,
+		},
+		{
+			name: "date not at midnight",
+			doc: `<LifecycleConfiguration><Rule><Status>Enabled</Status>
+				<Expiration><Date>2030-01-01T12:00:00Z</Date></Expiration></Rule></LifecycleConfiguration>`,
+			wantErr: true,
+		},
+		{
+			name: "unknown storage class",
+			doc: `<LifecycleConfiguration><Rule><Status>Enabled</Status>
+				<Transition><Days>1</Days><StorageClass>TAPE</StorageClass></Transition></Rule></LifecycleConfiguration>`,
+			wantErr: true,
+		},
+		{
+			name: "transition to STANDARD",
+			doc: `<LifecycleConfiguration><Rule><Status>Enabled</Status>
+				<Transition><Days>1</Days><StorageClass>STANDARD</StorageClass></Transition></Rule></LifecycleConfiguration>`,
+			wantErr: true,
+		},
+		{
+			name: "inverted size bounds",
+			doc: `<LifecycleConfiguration><Rule><Status>Enabled</Status>
+				<Filter><And><ObjectSizeGreaterThan>100</ObjectSizeGreaterThan><ObjectSizeLessThan>10</ObjectSizeLessThan></And></Filter>
+				<Expiration><Days>1</Days></Expiration></Rule></LifecycleConfiguration>`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateLifecycle(parseLifecycleXML(t, tt.doc))
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestLifecycleRule_Matches(t *testing.T) {
+	config := parseLifecycleXML(t, `<LifecycleConfiguration><Rule><Status>Enabled</Status>
+		<Filter><And><Prefix>logs/</Prefix><Tag><Key>tier</Key><Value>cold</Value></Tag>
+		<ObjectSizeGreaterThan>10</ObjectSizeGreaterThan></And></Filter>
+		<Expiration><Days>1</Days></Expiration></Rule></LifecycleConfiguration>`)
+	rule := &config.Rules[0]
+
+	cold := map[string]string{"tier": "cold"}
+	assert.True(t, rule.matches(lifecycleObject{key: "logs/a", size: 11, tags: cold}))
+	assert.False(t, rule.matches(lifecycleObject{key: "data/a", size: 11, tags: cold}), "prefix")
+	assert.False(t, rule.matches(lifecycleObject{key: "logs/a", size: 10, tags: cold}), "size bound is exclusive")
+	assert.False(t, rule.matches(lifecycleObject{key: "logs/a", size: 11, tags: map[string]string{"tier": "hot"}}), "tag value")
+}
+
+func TestLifecycleRule_FilterSQL(t *testing.T) {
+	config := parseLifecycleXML(t, `<LifecycleConfiguration><Rule><Status>Enabled</Status>
+		<Filter><And><Prefix>logs/</Prefix><Tag><Key>tier</Key><Value>cold</Value></Tag>
+		<ObjectSizeGreaterThan>10</ObjectSizeGreaterThan><ObjectSizeLessThan>100</ObjectSizeLessThan></And></Filter>
+		<Expiration><Days>1</Days></Expiration></Rule></LifecycleConfiguration>`)
+
+	filter, args := config.Rules[0].filterSQL(6)
+	assert.Equal(t, " AND size_bytes > $6 AND size_bytes < $7 AND tags @> $8::jsonb", filter)
+	assert.Equal(t, []any{int64(10), int64(100), `{"tier":"cold"}`}, args)
+
+	config = parseLifecycleXML(t, `<LifecycleConfiguration><Rule><Status>Enabled</Status>
+		<Filter><Prefix>logs/</Prefix></Filter><Expiration><Days>1</Days></Expiration></Rule></LifecycleConfiguration>`)
+	filter, args = config.Rules[0].filterSQL(5)
+	assert.Empty(t, filter, "prefix is matched by the base query")
+	assert.Empty(t, args)
+}
+
+func TestActionCutoff(t *testing.T) {
+	now := time.Date(2030, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	cutoff, due := actionCutoff(30, "", now)
+	assert.True(t, due)
+	assert.Equal(t, now.Add(-30*24*time.Hour), cutoff)
+
+	_, due = actionCutoff(0, "2030-07-01T00:00:00Z", now)
+	assert.False(t, due, "future date is not yet due")
+
+	cutoff, due = actionCutoff(0, "2030-05-01T00:00:00Z", now)
+	assert.True(t, due)
+	assert.Equal(t, now, cutoff)
+}
+
+func TestLikePrefix_EscapesWildcards(t *testing.T) {
+	assert.Equal(t, `a\_b\%c\\%`, likePrefix(`a_b%c\`))
+	assert.Equal(t, "%", likePrefix(""))
+}
+
+func TestNewLifecycleRunner_NilDB(t *testing.T) {
+	assert.Nil(t, NewLifecycleRunner(nil, nil, nil, nil, zap.NewNop()))
+	// Start on a nil runner is a no-op.
+	var lr *LifecycleRunner
+	lr.Start(context.Background())
+}
+
+type lifecycleFixture struct {
+	server   *Server
+	db       *sql.DB
+	eng      *engine.CoreEngine
+	tenantID string
+	tenant   *tenant.Tenant
+	tempDir  string
+	bucket   string
+}
+
+func setupLifecycleFixture(t *testing.T) *lifecycleFixture {
+	t.Helper()
+
+	dsn := os.Getenv("DATABASE_URL")
+	if dsn == "" {
+		t.Skip("DATABASE_URL not set — skipping integration test")
+	}
+	db, err := sql.Open("postgres", dsn)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	require.NoError(t, db.Ping())
+
+	logger := zap.NewNop()
+
+	tempDir, err := os.MkdirTemp("", "vaultaire-lifecycle-test-*")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = os.RemoveAll(tempDir) })
+
+	eng := engine.NewEngine(nil, logger, nil)
+	eng.AddDriver("local", drivers.NewLocalDriver(tempDir, logger))
+	eng.SetPrimary("local")
+
+	tenantID := fmt.Sprintf("lifecycle-%d", os.Getpid())
+	bucket := "lifecycle-bucket"
+
+	_, err = db.Exec(`
+		INSERT INTO tenants (id, name, email, access_key, secret_key)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (id) DO NOTHING
+	`, tenantID, "Lifecycle Test", tenantID+"@test.local", "AK-"+tenantID, "SK-"+tenantID)
+	require.NoError(t, err)
+	_, err = db.Exec(`
+		INSERT INTO buckets (tenant_id, name, visibility)
+		VALUES ($1, $2, 'private')
+		ON CONFLICT (tenant_id, name) DO NOTHING
+	`, tenantID, bucket)
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		_, _ = db.Exec("DELETE FROM object_head_cache WHERE tenant_id = $1", tenantID)
+		_, _ = db.Exec("DELETE FROM object_versions WHERE tenant_id = $1", tenantID)
+		_, _ = db.Exec("DELETE FROM multipart_uploads WHERE tenant_id = $1", tenantID)
+		_, _ = db.Exec("DELETE FROM buckets WHERE tenant_id = $1", tenantID)
+		_, _ = db.Exec("DELETE FROM tenants WHERE id = $1", tenantID)
+	})
+
+	tn := &tenant.Tenant{
+		ID:        tenantID,
+		Namespace: "tenant/" + tenantID + "/",
+	}
+	require.NoError(t, os.MkdirAll(filepath.Join(tempDir, tn.NamespaceContainer(bucket)), 0755))
+
+	srv := &Server{
+		logger:   logger,
+		router:   chi.NewRouter(),
+		engine:   eng,
+		db:       db,
+		testMode: true,
+	}
+
+	return &lifecycleFixture{
+		server:   srv,
+		db:       db,
+		eng:      eng,
+		tenantID: tenantID,
+		tenant:   tn,
+		tempDir:  tempDir,
+		bucket:   bucket,
+	}
+}
+
+func TestBucketLifecycle_PutGetDelete(t *testing.T) {
+	f := setupLifecycleFixture(t)
+
+	configXML := `<?xml version="1.0" encoding="UTF-8"?>
+<LifecycleConfiguration xmlns="http://s3.amazonaws.com/doc/2006-03-01/">
+  <Rule>
+    <ID>expire-logs</ID>
+    <Status>Enabled</Status>
+    <Filter><Prefix>logs/</Prefix></Filter>
+    <Expiration><Days>30</Days></Expiration>
+  </Rule>
+</LifecycleConfiguration>`
+
+	s3Req := &S3Request{Bucket: f.bucket, TenantID: f.tenantID}
+	ctx := tenant.WithTenant(context.Background(), f.tenant)
+
+	// GET before PUT is NoSuchLifecycleConfiguration.
+	r := httptest.NewRequest("GET", "/"+f.bucket+"?lifecycle", nil).WithContext(ctx)
+	w := httptest.NewRecorder()
+	f.server.handleGetBucketLifecycle(w, r, s3Req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Contains(t, w.Body.String(), ErrNoSuchLifecycleConfiguration)
+
+	r = httptest.NewRequest("PUT", "/"+f.bucket+"?lifecycle", bytes.NewReader([]byte(configXML))).WithContext(ctx)
+	w = httptest.NewRecorder()
+	f.server.handlePutBucketLifecycle(w, r, s3Req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	r = httptest.NewRequest("GET", "/"+f.bucket+"?lifecycle", nil).WithContext(ctx)
+	w = httptest.NewRecorder()
+	f.server.handleGetBucketLifecycle(w, r, s3Req)
+	require.Equal(t, http.StatusOK, w.Code)
+	var resp LifecycleConfiguration
+	require.NoError(t, xml.Unmarshal(w.Body.Bytes(), &resp))
+	require.Len(t, resp.Rules, 1)
+	assert.Equal(t, "expire-logs", resp.Rules[0].ID)
+	require.NotNil(t, resp.Rules[0].Expiration)
+	assert.Equal(t, 30, resp.Rules[0].Expiration.Days)
+
+	r = httptest.NewRequest("DELETE", "/"+f.bucket+"?lifecycle", nil).WithContext(ctx)
+	w = httptest.NewRecorder()
+	f.server.handleDeleteBucketLifecycle(w, r, s3Req)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	r = httptest.NewRequest("GET", "/"+f.bucket+"?lifecycle", nil).WithContext(ctx)
+	w = httptest.NewRecorder()
+	f.server.handleGetBucketLifecycle(w, r, s3Req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestPutBucketLifecycle_Invalid(t *testing.T) {
+	f := setupLifecycleFixture(t)
+
+	s3Req := &S3Request{Bucket: f.bucket, TenantID: f.tenantID}
+	ctx := tenant.WithTenant(context.Background(), f.tenant)
+	body := `<LifecycleConfiguration><Rule><Status>Enabled</Status></Rule></LifecycleConfiguration>`
+	r := httptest.NewRequest("PUT", "/"+f.bucket+"?lifecycle", bytes.NewReader([]byte(body))).WithContext(ctx)
+	w := httptest.NewRecorder()
+	f.server.handlePutBucketLifecycle(w, r, s3Req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), ErrInvalidArgument)
+}
+
+func TestLifecycleRunner_ExpiresObjects(t *testing.T) {
+	f := setupLifecycleFixture(t)
+	ctx := context.Background()
+	container := f.tenant.NamespaceContainer(f.bucket)
+
+	for _, key := range []string{"logs/old.txt", "data/old.txt"} {
+		require.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(f.tempDir, container, key)), 0755))
+		require.NoError(t, os.WriteFile(filepath.Join(f.tempDir, container, key), []byte("hello"), 0644))
+		_, err := f.db.Exec(`
+			INSERT INTO object_head_cache (tenant_id, bucket, object_key, size_bytes, etag, content_type, updated_at, backend_name)
+			VALUES ($1, $2, $3, 5, 'etag', 'text/plain', NOW() - INTERVAL '40 days', 'local')
+		`, f.tenantID, f.bucket, key)
+		require.NoError(t, err)
+	}
+
+	_, err := f.db.Exec(`UPDATE buckets SET lifecycle_config = $3 WHERE tenant_id = $1 AND name = $2`,
+		f.tenantID, f.bucket,
+		`<LifecycleConfiguration><Rule><ID>logs</ID><Status>Enabled</Status><Filter><Prefix>logs/</Prefix></Filter><Expiration><Days>30</Days></Expiration></Rule></LifecycleConfiguration>`)
+	require.NoError(t, err)
+
+	lr := NewLifecycleRunner(f.db, f.eng, nil, nil, zap.NewNop())
+	require.NotNil(t, lr)
+	result, err := lr.RunOnce(ctx)
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, result.Expired, 1)
+
+	var count int
+	require.NoError(t, f.db.QueryRow(`SELECT COUNT(*) FROM object_head_cache WHERE tenant_id = $1 AND object_key = 'logs/old.txt'`,
+		f.tenantID).Scan(&count))
+	assert.Equal(t, 0, count, "matching object should be expired")
+	require.NoError(t, f.db.QueryRow(`SELECT COUNT(*) FROM object_head_cache WHERE tenant_id = $1 AND object_key = 'data/old.txt'`,
+		f.tenantID).Scan(&count))
+	assert.Equal(t, 1, count, "object outside the rule prefix must survive")
+	_, err = os.Stat(filepath.Join(f.tempDir, container, "logs/old.txt"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestLifecycleRunner_TransitionsOncePerObject(t *testing.T) {
+	f := setupLifecycleFixture(t)
+	ctx := context.Background()
+	container := f.tenant.NamespaceContainer(f.bucket)
+
+	for _, backend := range []string{"lyve", "geyser"} {
+		dir := filepath.Join(f.tempDir, backend)
+		require.NoError(t, os.MkdirAll(filepath.Join(dir, container), 0755))
+		f.eng.AddDriver(backend, drivers.NewLocalDriver(dir, zap.NewNop()))
+	}
+
+	ages := map[string]string{"cold.txt": "400 days", "warm.txt": "40 days"}
+	for key, age := range ages {
+		require.NoError(t, os.WriteFile(filepath.Join(f.tempDir, container, key), []byte("hello"), 0644))
+		_, err := f.db.Exec(`
+			INSERT INTO object_head_cache (tenant_id, bucket, object_key, size_bytes, etag, content_type, updated_at, backend_name)
+			VALUES ($1, $2, $3, 5, 'etag', 'text/plain', NOW() - $4::interval, 'local')
+		`, f.tenantID, f.bucket, key, age)
+		require.NoError(t, err)
+	}
+
+	// RESILIENT (lyve) at 30 days, GLACIER (geyser) at 365: each object
+	// must land on the latest tier it is due for and stay there.
+	_, err := f.db.Exec(`UPDATE buckets SET lifecycle_config = $3 WHERE tenant_id = $1 AND name = $2`,
+		f.tenantID, f.bucket,
+		`<LifecycleConfiguration><Rule><ID>tiers</ID><Status>Enabled</Status><Filter></Filter>
+			<Transition><Days>30</Days><StorageClass>RESILIENT</StorageClass></Transition>
+			<Transition><Days>365</Days><StorageClass>GLACIER</StorageClass></Transition></Rule></LifecycleConfiguration>`)
+	require.NoError(t, err)
+
+	backendOf := func(key string) string {
+		var backend string
+		require.NoError(t, f.db.QueryRow(`SELECT backend_name FROM object_head_cache WHERE tenant_id = $1 AND bucket = $2 AND object_key = $3`,
+			f.tenantID, f.bucket, key).Scan(&backend))
+		return backend
+	}
+
+	lr := NewLifecycleRunner(f.db, f.eng, nil, nil, zap.NewNop())
+	require.NotNil(t, lr)
+	result, err := lr.RunOnce(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, result.Transitioned)
+	assert.Equal(t, "geyser", backendOf("cold.txt"))
+	assert.Equal(t, "lyve", backendOf("warm.txt"))
+
+	result, err = lr.RunOnce(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 0, result.Transitioned, "a second run must not move objects back to an earlier tier")
+	assert.Equal(t, "geyser", backendOf("cold.txt"))
+	assert.Equal(t, "lyve", backendOf("warm.txt"))
+}
+
+func TestLifecycleRunner_SkipsKeyOverwrittenAfterSelection(t *testing.T) {
+	f := setupLifecycleFixture(t)
+	ctx := context.Background()
+	path := filepath.Join(f.tempDir, f.tenant.NamespaceContainer(f.bucket), "old.txt")
+
+	require.NoError(t, os.WriteFile(path, []byte("hello"), 0644))
+	_, err := f.db.Exec(`
+		INSERT INTO object_head_cache (tenant_id, bucket, object_key, size_bytes, etag, content_type, updated_at, backend_name)
+		VALUES ($1, $2, 'old.txt', 5, 'etag-1', 'text/plain', NOW() - INTERVAL '40 days', 'local')
+	`, f.tenantID, f.bucket)
+	require.NoError(t, err)
+
+	config := parseLifecycleXML(t, `<LifecycleConfiguration><Rule><ID>all</ID><Status>Enabled</Status><Filter></Filter><Expiration><Days>30</Days></Expiration></Rule></LifecycleConfiguration>`)
+	b := lifecycleBucket{tenantID: f.tenantID, name: f.bucket, config: *config}
+	lr := NewLifecycleRunner(f.db, f.eng, nil, nil, zap.NewNop())
+	require.NotNil(t, lr)
+	objs, err := lr.candidates(ctx, b, &config.Rules[0], time.Now().AddDate(0, 0, -30), "")
+	require.NoError(t, err)
+	require.Len(t, objs, 1)
+
+	// A client overwrites the key between selection and expiry.
+	require.NoError(t, os.WriteFile(path, []byte("new body"), 0644))
+	_, err = f.db.Exec(`
+		UPDATE object_head_cache SET size_bytes = 8, etag = 'etag-2', updated_at = NOW()
+		WHERE tenant_id = $1 AND bucket = $2 AND object_key = 'old.txt'`, f.tenantID, f.bucket)
+	require.NoError(t, err)
+
+	deleted, err := lr.deleteObject(ctx, b, objs[0])
+	require.NoError(t, err)
+	assert.False(t, deleted, "the new object must not be expired")
+
+	b.versioning = "Enabled"
+	created, err := lr.createDeleteMarker(ctx, b, objs[0])
+	require.NoError(t, err)
+	assert.False(t, created, "the new object must not be hidden behind a delete marker")
+
+	var etag string
+	require.NoError(t, f.db.QueryRow(`SELECT etag FROM object_head_cache WHERE tenant_id = $1 AND bucket = $2 AND object_key = 'old.txt'`,
+		f.tenantID, f.bucket).Scan(&etag))
+	assert.Equal(t, "etag-2", etag)
+	var markers int
+	require.NoError(t, f.db.QueryRow(`SELECT COUNT(*) FROM object_versions WHERE tenant_id = $1 AND is_delete_marker`,
+		f.tenantID).Scan(&markers))
+	assert.Zero(t, markers)
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "new body", string(data))
+}
@@ -1,9 +1,11 @@
 package api
 
 import (
+	"context"
 	"crypto/md5" // #nosec G501 — S3 spec requires MD5 for ETags
 	"database/sql"
 	"encoding/hex"
+	"encoding/json"
 	"encoding/xml"
 	"fmt"
 	"io"
@@ -35,17 +37,24 @@ var (
 )
 
 type memUpload struct {
-	TenantID string
-	Bucket   string
-	Key      string
-	Status   string // "active", "completed", "aborted"
-	Parts    map[int]memPart
-	Created  time.Time
+	TenantID          string
+	Bucket            string
+	Key               string
+	Status            string // "active", "completed", "aborted"
+	Parts             map[int]memPart
+	Created           time.Time
+	ChecksumAlgorithm string
+	ChecksumType      string
+	Encryption        string
+	SSECKeyMD5        string
+	KMSKeyID          string
+	KMSContext        crypto.EncryptionContext
 }
 
 type memPart struct {
-	ETag string
-	Size int64
+	ETag     string
+	Size     int64
+	Checksum string
 }
 
 // multipartDir returns the temp directory for a specific upload's parts.
@@ -65,9 +74,76 @@ func (s *Server) handleInitiateMultipartUpload(w http.ResponseWriter, r *http.Re
 		return
 	}
 
+	// Server-side encryption is fixed here and applied when the parts are
+	// assembled at completion; until then parts are staged in plaintext on
+	// the server's local disk, like any other upload. An SSE-C upload is
+	// bound to the key's MD5 — every part and the completion must present
+	// the same key. An SSE-KMS upload records its key and context; the data
+	// key is generated at completion.
+	var encryption, ssecKeyMD5 string
+	var kmsWant sseWrite
 	if crypto.HasSSECHeaders(r) {
-		WriteS3ErrorWithContext(w, ErrNotImplemented, r.URL.Path, generateRequestID(),
-			WithSuggestion("SSE-C is not yet supported for multipart uploads."))
+		if r.Header.Get("x-amz-server-side-encryption") != "" {
+			WriteS3ErrorWithContext(w, ErrInvalidRequest, r.URL.Path, generateRequestID(),
+				WithSuggestion("Cannot use SSE-S3 and SSE-C simultaneously."))
+			return
+		}
+		key, parseErr := crypto.ParseSSECHeaders(r)
+		if parseErr != nil {
+			WriteS3ErrorWithContext(w, ErrInvalidRequest, r.URL.Path, generateRequestID(),
+				WithSuggestion(parseErr.Error()))
+			return
+		}
+		for i := range key {
+			key[i] = 0
+		}
+		encryption = crypto.SSECAlgorithm
+		ssecKeyMD5 = r.Header.Get("x-amz-server-side-encryption-customer-key-MD5")
+	} else {
+		want, sseErr := resolveSSEWrite(r.Context(), r, s.db, s.kms, s.sseService != nil, t.ID, bucket, object)
+		if sseErr != nil {
+			writeSSEStreamError(w, r, s.logger, sseErr)
+			return
+		}
+		switch {
+		case want.Algorithm == crypto.SSEKMSAlgorithm:
+			encryption, kmsWant = want.Algorithm, want
+		case want.Algorithm == crypto.SSEAlgorithm && s.sseService != nil:
+			encryption = crypto.SSEAlgorithm
+		}
+	}
+
+	// Flexible checksums: the algorithm chosen here binds every part, and
+	// the type decides how CompleteMultipartUpload derives the object's
+	// checksum (see s3_checksum.go).
+	var checksumAlgorithm, checksumType string
+	if v := r.Header.Get("x-amz-checksum-algorithm"); v != "" {
+		alg, ok := normalizeChecksumAlgorithm(v)
+		if !ok {
+			WriteS3ErrorWithContext(w, ErrInvalidRequest, r.URL.Path, generateRequestID(),
+				WithSuggestion(fmt.Sprintf("Checksum algorithm %q is not supported. Use CRC32, CRC32C, CRC64NVME, SHA1 or SHA256.", v)))
+			return
+		}
+		checksumAlgorithm = alg
+		checksumType = strings.ToUpper(r.Header.Get("x-amz-checksum-type"))
+		if checksumType == "" {
+			checksumType = defaultChecksumType(alg)
+		}
+		if !validChecksumType(alg, checksumType) {
+			WriteS3ErrorWithContext(w, ErrInvalidRequest, r.URL.Path, generateRequestID(),
+				WithSuggestion(fmt.Sprintf("Checksum type %s is not supported for %s.", checksumType, alg)))
+			return
+		}
+	} else if r.Header.Get("x-amz-checksum-type") != "" {
+		WriteS3ErrorWithContext(w, ErrInvalidRequest, r.URL.Path, generateRequestID(),
+			WithSuggestion("x-amz-checksum-type requires x-amz-checksum-algorithm."))
+		return
+	}
+
+	// The requested ACL is checked now and applied when the upload completes.
+	objectACL, aclErr := objectACLForWrite(r.Context(), s.db, r, t.ID, bucket)
+	if aclErr != nil {
+		writeACLError(w, r, s.logger, aclErr)
 		return
 	}
 
@@ -75,10 +151,18 @@ func (s *Server) handleInitiateMultipartUpload(w http.ResponseWriter, r *http.Re
 
 	// Persist upload record
 	if s.db != nil {
+		var kmsKeyID, kmsContext interface{}
+		if kmsWant.KMSKeyID != "" {
+			ctxJSON, _ := json.Marshal(kmsWant.KMSContext)
+			kmsKeyID, kmsContext = kmsWant.KMSKeyID, string(ctxJSON)
+		}
 		_, err := s.db.ExecContext(r.Context(), `
-			INSERT INTO multipart_uploads (upload_id, tenant_id, bucket, object_key, status)
-			VALUES ($1, $2, $3, $4, 'active')
-		`, uploadID, t.ID, bucket, object)
+			INSERT INTO multipart_uploads
+				(upload_id, tenant_id, bucket, object_key, status, checksum_algorithm, checksum_type, encryption_algorithm, sse_customer_key_md5,
+				 sse_kms_key_id, sse_kms_context, acl)
+			VALUES ($1, $2, $3, $4, 'active', $5, $6, $7, $8, $9, $10, $11)
+		`, uploadID, t.ID, bucket, object, nullIfEmpty(checksumAlgorithm), nullIfEmpty(checksumType),
+			nullIfEmpty(encryption), nullIfEmpty(ssecKeyMD5), kmsKeyID, kmsContext, objectACL)
 		if err != nil {
 			s.logger.Error("failed to create multipart upload record", zap.Error(err))
 			WriteS3Error(w, ErrInternalError, r.URL.Path, generateRequestID())
@@ -87,12 +171,18 @@ func (s *Server) handleInitiateMultipartUpload(w http.ResponseWriter, r *http.Re
 	} else {
 		memUploadsMu.Lock()
 		memUploads[uploadID] = &memUpload{
-			TenantID: t.ID,
-			Bucket:   bucket,
-			Key:      object,
-			Status:   "active",
-			Parts:    make(map[int]memPart),
-			Created:  time.Now(),
+			TenantID:          t.ID,
+			Bucket:            bucket,
+			Key:               object,
+			Status:            "active",
+			Parts:             make(map[int]memPart),
+			Created:           time.Now(),
+			ChecksumAlgorithm: checksumAlgorithm,
+			ChecksumType:      checksumType,
+			Encryption:        encryption,
+			SSECKeyMD5:        ssecKeyMD5,
+			KMSKeyID:          kmsWant.KMSKeyID,
+			KMSContext:        kmsWant.KMSContext,
 		}
 		memUploadsMu.Unlock()
 	}
@@ -110,6 +200,11 @@ func (s *Server) handleInitiateMultipartUpload(w http.ResponseWriter, r *http.Re
 		zap.String("uploadID", uploadID),
 		zap.String("tenant_id", t.ID))
 
+	if checksumAlgorithm != "" {
+		w.Header().Set("x-amz-checksum-algorithm", checksumAlgorithm)
+		w.Header().Set("x-amz-checksum-type", checksumType)
+	}
+	setSSEResponseHeaders(w, encryption, kmsWant.KMSKeyID)
 	w.Header().Set("Content-Type", "application/xml")
 	if err := xml.NewEncoder(w).Encode(InitiateMultipartUploadResult{
 		Bucket:   bucket,
@@ -137,27 +232,39 @@ func (s *Server) handleUploadPart(w http.ResponseWriter, r *http.Request, bucket
 	}
 
 	// Verify upload exists, is active, and belongs to this tenant
-	if s.db != nil {
-		var status string
-		err := s.db.QueryRowContext(r.Context(), `
-			SELECT status FROM multipart_uploads
-			WHERE upload_id = $1 AND tenant_id = $2
-		`, uploadID, t.ID).Scan(&status)
-		if err == sql.ErrNoRows || (err == nil && status != "active") {
-			WriteS3Error(w, ErrNoSuchUpload, r.URL.Path, generateRequestID())
-			return
-		}
-		if err != nil {
-			s.logger.Error("failed to query multipart upload", zap.Error(err))
-			WriteS3Error(w, ErrInternalError, r.URL.Path, generateRequestID())
-			return
-		}
-	} else {
-		memUploadsMu.RLock()
-		mu, ok := memUploads[uploadID]
-		memUploadsMu.RUnlock()
-		if !ok || mu.TenantID != t.ID || mu.Status != "active" {
-			WriteS3Error(w, ErrNoSuchUpload, r.URL.Path, generateRequestID())
+	upload, active, err := s.lookupActiveUpload(r.Context(), uploadID, t.ID)
+	if err != nil {
+		s.logger.Error("failed to query multipart upload", zap.Error(err))
+		WriteS3Error(w, ErrInternalError, r.URL.Path, generateRequestID())
+		return
+	}
+	if !active {
+		WriteS3Error(w, ErrNoSuchUpload, r.URL.Path, generateRequestID())
+		return
+	}
+	ssecKey, ok := s.checkUploadSSECKey(w, r, upload)
+	if !ok {
+		return
+	}
+	defer zeroKey(ssecKey)
+	uploadChecksumAlg := upload.ChecksumAlgorithm
+
+	// A part's checksum must use the upload's algorithm; when the client
+	// sends none, the server computes it so CompleteMultipartUpload can
+	// derive the object checksum.
+	ck, ckErr := parseChecksumRequest(r)
+	if ckErr != nil {
+		WriteS3ErrorWithContext(w, ErrInvalidRequest, r.URL.Path, generateRequestID(),
+			WithSuggestion(ckErr.Error()))
+		return
+	}
+	if uploadChecksumAlg != "" {
+		if ck == nil {
+			ck = newRequestChecksum(uploadChecksumAlg)
+		} else if ck.Algorithm != uploadChecksumAlg {
+			WriteS3ErrorWithContext(w, ErrInvalidRequest, r.URL.Path, generateRequestID(),
+				WithSuggestion(fmt.Sprintf("Checksum type mismatch: the upload was initiated with %s, the part was sent with %s.",
+					uploadChecksumAlg, ck.Algorithm)))
 			return
 		}
 	}
@@ -171,10 +278,7 @@ func (s *Server) handleUploadPart(w http.ResponseWriter, r *http.Request, bucket
 	// existing part replaces its bytes, it does not add.
 	var existingBytes int64
 	if s.db != nil && s.multipartMaxUploadBytes > 0 {
-		if err := s.db.QueryRowContext(r.Context(), `
-			SELECT COALESCE(SUM(size_bytes), 0) FROM multipart_parts
-			WHERE upload_id = $1 AND part_number <> $2
-		`, uploadID, partNumber).Scan(&existingBytes); err != nil {
+		if existingBytes, err = s.inFlightPartBytes(r.Context(), uploadID, partNumber); err != nil {
 			s.logger.Error("failed to sum in-flight part bytes", zap.Error(err))
 			WriteS3Error(w, ErrInternalError, r.URL.Path, generateRequestID())
 			return
@@ -201,24 +305,16 @@ func (s *Server) handleUploadPart(w http.ResponseWriter, r *http.Request, bucket
 	// records framed sizes/ETags for the parts.
 	var body io.Reader = r.Body
 	if isAWSChunked(r) {
-		body = newAWSChunkedReader(r.Body)
+		cr := newAWSChunkedReader(r.Body)
+		if ck != nil {
+			ck.bind(cr)
+		}
+		body = cr
 	}
 
 	pp := partFilePath(uploadID, partNumber)
-	f, err := os.Create(pp) // #nosec G304 — path derived from validated uploadID + partNumber
-	if err != nil {
-		s.logger.Error("failed to create part temp file", zap.Error(err))
-		WriteS3Error(w, ErrInternalError, r.URL.Path, generateRequestID())
-		return
-	}
-
-	hasher := md5.New() // #nosec G401 — S3 spec requires MD5 for ETags
-	size, err := io.Copy(f, io.TeeReader(body, hasher))
-	if closeErr := f.Close(); closeErr != nil && err == nil {
-		err = closeErr
-	}
+	size, etag, err := stagePart(uploadID, partNumber, body, ck, ssecKey)
 	if err != nil {
-		_ = os.Remove(pp)
 		s.logger.Error("failed to write part data", zap.Error(err))
 		WriteS3Error(w, bodyReadErrorCode(err), r.URL.Path, generateRequestID())
 		return
@@ -236,29 +332,23 @@ func (s *Server) handleUploadPart(w http.ResponseWriter, r *http.Request, bucket
 		return
 	}
 
-	etag := fmt.Sprintf("\"%x\"", hasher.Sum(nil))
-
-	// Record part metadata
-	if s.db != nil {
-		_, err := s.db.ExecContext(r.Context(), `
-			INSERT INTO multipart_parts (upload_id, part_number, etag, size_bytes)
-			VALUES ($1, $2, $3, $4)
-			ON CONFLICT (upload_id, part_number) DO UPDATE SET
-				etag       = EXCLUDED.etag,
-				size_bytes = EXCLUDED.size_bytes,
-				created_at = NOW()
-		`, uploadID, partNumber, etag, size)
-		if err != nil {
-			s.logger.Error("failed to record part metadata", zap.Error(err))
-			WriteS3Error(w, ErrInternalError, r.URL.Path, generateRequestID())
+	var checksumAlgorithm, checksumValue string
+	if ck != nil {
+		v, verr := ck.Verify()
+		if verr != nil {
+			_ = os.Remove(pp)
+			WriteS3ErrorWithContext(w, ErrBadDigest, r.URL.Path, generateRequestID(),
+				WithSuggestion(ck.mismatchSuggestion()))
 			return
 		}
-	} else {
-		memUploadsMu.Lock()
-		if mu, ok := memUploads[uploadID]; ok {
-			mu.Parts[partNumber] = memPart{ETag: etag, Size: size}
-		}
-		memUploadsMu.Unlock()
+		checksumAlgorithm, checksumValue = ck.Algorithm, v
+	}
+
+	// Record part metadata
+	if err := s.recordPart(r.Context(), uploadID, partNumber, etag, size, checksumAlgorithm, checksumValue); err != nil {
+		s.logger.Error("failed to record part metadata", zap.Error(err))
+		WriteS3Error(w, ErrInternalError, r.URL.Path, generateRequestID())
+		return
 	}
 
 	s.logger.Debug("uploaded part",
@@ -270,14 +360,215 @@ func (s *Server) handleUploadPart(w http.ResponseWriter, r *http.Request, bucket
 		zap.String("etag", etag))
 
 	w.Header().Set("ETag", etag)
+	if checksumAlgorithm != "" {
+		w.Header().Set(checksumHeader(checksumAlgorithm), checksumValue)
+	}
 	w.WriteHeader(http.StatusOK)
 }
 
+// activeUpload is what an upload fixed at initiation and its parts must
+// agree with.
+type activeUpload struct {
+	ChecksumAlgorithm string
+	Encryption        string
+	SSECKeyMD5        string
+}
+
+// lookupActiveUpload reports whether an upload exists, is active and belongs
+// to the tenant, and returns the settings fixed at initiation.
+func (s *Server) lookupActiveUpload(ctx context.Context, uploadID, tenantID string) (upload activeUpload, active bool, err error) {
+	if s.db == nil {
+		memUploadsMu.RLock()
+		mu, ok := memUploads[uploadID]
+		memUploadsMu.RUnlock()
+		if !ok || mu.TenantID != tenantID || mu.Status != "active" {
+			return activeUpload{}, false, nil
+		}
+		return activeUpload{
+			ChecksumAlgorithm: mu.ChecksumAlgorithm,
+			Encryption:        mu.Encryption,
+			SSECKeyMD5:        mu.SSECKeyMD5,
+		}, true, nil
+	}
+	var status string
+	var alg, enc, keyMD5 sql.NullString
+	err = s.db.QueryRowContext(ctx, `
+		SELECT status, checksum_algorithm, encryption_algorithm, sse_customer_key_md5 FROM multipart_uploads
+		WHERE upload_id = $1 AND tenant_id = $2
+	`, uploadID, tenantID).Scan(&status, &alg, &enc, &keyMD5)
+	if err == sql.ErrNoRows {
+		return activeUpload{}, false, nil
+	}
+	if err != nil {
+		return activeUpload{}, false, err
+	}
+	return activeUpload{
+		ChecksumAlgorithm: alg.String,
+		Encryption:        enc.String,
+		SSECKeyMD5:        keyMD5.String,
+	}, status == "active", nil
+}
+
+// uploadSSECKey returns the customer key a request on an SSE-C upload
+// carries, and an error when it is missing, malformed or not the key the
+// upload was initiated with. Other uploads return a nil key.
+func uploadSSECKey(r *http.Request, encryption, keyMD5 string) ([]byte, error) {
+	if encryption != crypto.SSECAlgorithm {
+		return nil, nil
+	}
+	if !crypto.HasSSECHeaders(r) {
+		return nil, fmt.Errorf("this upload was initiated with SSE-C; provide the same encryption key")
+	}
+	key, err := crypto.ParseSSECHeaders(r)
+	if err != nil {
+		return nil, err
+	}
+	if r.Header.Get("x-amz-server-side-encryption-customer-key-MD5") != keyMD5 {
+		for i := range key {
+			key[i] = 0
+		}
+		return nil, fmt.Errorf("the encryption key does not match the one the upload was initiated with")
+	}
+	return key, nil
+}
+
+// checkUploadSSECKey answers a part request on an SSE-C upload that lacks
+// the upload's key. It reports whether the request may proceed and returns
+// the key to stage the part under (nil for other uploads), which the
+// caller zeroes.
+func (s *Server) checkUploadSSECKey(w http.ResponseWriter, r *http.Request, upload activeUpload) ([]byte, bool) {
+	key, err := uploadSSECKey(r, upload.Encryption, upload.SSECKeyMD5)
+	if err != nil {
+		WriteS3ErrorWithContext(w, ErrInvalidRequest, r.URL.Path, generateRequestID(),
+			WithSuggestion(err.Error()))
+		return nil, false
+	}
+	return key, true
+}
+
+// inFlightPartBytes sums the recorded sizes of an upload's parts other than
+// partNumber, for the per-upload in-flight byte cap.
+func (s *Server) inFlightPartBytes(ctx context.Context, uploadID string, partNumber int) (int64, error) {
+	var n int64
+	err := s.db.QueryRowContext(ctx, `
+		SELECT COALESCE(SUM(size_bytes), 0) FROM multipart_parts
+		WHERE upload_id = $1 AND part_number <> $2
+	`, uploadID, partNumber).Scan(&n)
+	return n, err
+}
+
+// stagePart streams part data to its temp file while computing the MD5 ETag
+// and, when ck is set, the flexible checksum. Parts of SSE-C uploads are
+// staged as SSE-C streams under the customer key (ssecKey), so their
+// plaintext never reaches local disk and a staged file is unreadable
+// without the key the client must resend to complete. The returned size is
+// the plaintext size. The file is removed on error.
+func stagePart(uploadID string, partNumber int, body io.Reader, ck *requestChecksum, ssecKey []byte) (int64, string, error) {
+	pp := partFilePath(uploadID, partNumber)
+	f, err := os.Create(pp) // #nosec G304 — path derived from validated uploadID + partNumber
+	if err != nil {
+		return 0, "", fmt.Errorf("create part temp file: %w", err)
+	}
+
+	hasher := md5.New() // #nosec G401 — S3 spec requires MD5 for ETags
+	var digests io.Writer = hasher
+	if ck != nil {
+		digests = io.MultiWriter(hasher, ck)
+	}
+	plain := &countingReader{r: io.TeeReader(body, digests)}
+	var src io.Reader = plain
+	if ssecKey != nil {
+		stream, encErr := crypto.NewSSECStream(ssecKey)
+		if encErr != nil {
+			_ = f.Close()
+			_ = os.Remove(pp)
+			return 0, "", encErr
+		}
+		src = stream.Encrypt(plain)
+	}
+	_, err = io.Copy(f, src)
+	if closeErr := f.Close(); closeErr != nil && err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(pp)
+		return 0, "", err
+	}
+	return plain.n, fmt.Sprintf("\"%x\"", hasher.Sum(nil)), nil
+}
+
+// openStagedPart opens the size plaintext bytes of a staged part,
+// decrypting a part staged under ssecKey. Segment authentication fails the
+// read if the file was altered on disk.
+func openStagedPart(uploadID string, partNumber int, size int64, ssecKey []byte) (io.ReadCloser, error) {
+	f, err := os.Open(partFilePath(uploadID, partNumber)) // #nosec G304 — path derived from validated uploadID
+	if err != nil {
+		return nil, err
+	}
+	if ssecKey == nil {
+		return f, nil
+	}
+	header := make([]byte, crypto.SSECStreamHeaderSize)
+	if _, err := io.ReadFull(f, header); err != nil {
+		_ = f.Close()
+		return nil, fmt.Errorf("read staged part header: %w", err)
+	}
+	stream, err := crypto.OpenSSECStream(ssecKey, header)
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	return limitedReadCloser{Reader: stream.DecryptRange(f, size, 0, size), c: f}, nil
+}
+
+// recordPart upserts a staged part's metadata. A part number previously
+// filled by UploadPartCopy from a chunked source gives up its chunk
+// references in the same transaction.
+func (s *Server) recordPart(ctx context.Context, uploadID string, partNumber int, etag string, size int64, checksumAlgorithm, checksumValue string) error {
+	if s.db == nil {
+		memUploadsMu.Lock()
+		if mu, ok := memUploads[uploadID]; ok {
+			mu.Parts[partNumber] = memPart{ETag: etag, Size: size, Checksum: checksumValue}
+		}
+		memUploadsMu.Unlock()
+		return nil
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin part record: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	released, err := releasePartChunksTx(ctx, tx, uploadID, partNumber)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO multipart_parts (upload_id, part_number, etag, size_bytes, checksum_algorithm, checksum_value)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (upload_id, part_number) DO UPDATE SET
+			etag               = EXCLUDED.etag,
+			size_bytes         = EXCLUDED.size_bytes,
+			checksum_algorithm = EXCLUDED.checksum_algorithm,
+			checksum_value     = EXCLUDED.checksum_value,
+			created_at         = NOW()
+	`, uploadID, partNumber, etag, size, nullIfEmpty(checksumAlgorithm), nullIfEmpty(checksumValue)); err != nil {
+		return fmt.Errorf("upsert part: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit part record: %w", err)
+	}
+	invalidateChunks(s.gci, released)
+	return nil
+}
+
 // partRecord holds part metadata from either DB or in-memory store.
 type partRecord struct {
 	PartNumber int
 	ETag       string
 	Size       int64
+	Checksum   string
 }
 
 func (s *Server) handleCompleteMultipartUpload(w http.ResponseWriter, r *http.Request, bucket, object string) {
@@ -290,12 +581,17 @@ func (s *Server) handleCompleteMultipartUpload(w http.ResponseWriter, r *http.Re
 	uploadID := r.URL.Query().Get("uploadId")
 
 	// Verify upload is active and belongs to this tenant
+	var checksumAlgorithm, checksumType, encryption, ssecKeyMD5 string
+	var kmsWant sseWrite
+	var objectACL []byte
 	if s.db != nil {
 		var status string
+		var alg, typ, enc, keyMD5, kmsKey, kmsCtx sql.NullString
 		err := s.db.QueryRowContext(r.Context(), `
-			SELECT status FROM multipart_uploads
+			SELECT status, checksum_algorithm, checksum_type, encryption_algorithm, sse_customer_key_md5,
+			       sse_kms_key_id, sse_kms_context, acl FROM multipart_uploads
 			WHERE upload_id = $1 AND tenant_id = $2
-		`, uploadID, t.ID).Scan(&status)
+		`, uploadID, t.ID).Scan(&status, &alg, &typ, &enc, &keyMD5, &kmsKey, &kmsCtx, &objectACL)
 		if err == sql.ErrNoRows || (err == nil && status != "active") {
 			WriteS3Error(w, ErrNoSuchUpload, r.URL.Path, generateRequestID())
 			return
@@ -305,6 +601,16 @@ func (s *Server) handleCompleteMultipartUpload(w http.ResponseWriter, r *http.Re
 			WriteS3Error(w, ErrInternalError, r.URL.Path, generateRequestID())
 			return
 		}
+		checksumAlgorithm, checksumType = alg.String, typ.String
+		encryption, ssecKeyMD5 = enc.String, keyMD5.String
+		kmsWant.KMSKeyID = kmsKey.String
+		if kmsCtx.Valid {
+			if err := json.Unmarshal([]byte(kmsCtx.String), &kmsWant.KMSContext); err != nil {
+				s.logger.Error("multipart upload has a malformed KMS context", zap.Error(err))
+				WriteS3Error(w, ErrInternalError, r.URL.Path, generateRequestID())
+				return
+			}
+		}
 	} else {
 		memUploadsMu.RLock()
 		mu, ok := memUploads[uploadID]
@@ -313,6 +619,44 @@ func (s *Server) handleCompleteMultipartUpload(w http.ResponseWriter, r *http.Re
 			WriteS3Error(w, ErrNoSuchUpload, r.URL.Path, generateRequestID())
 			return
 		}
+		checksumAlgorithm, checksumType = mu.ChecksumAlgorithm, mu.ChecksumType
+		encryption, ssecKeyMD5 = mu.Encryption, mu.SSECKeyMD5
+		kmsWant.KMSKeyID, kmsWant.KMSContext = mu.KMSKeyID, mu.KMSContext
+	}
+
+	// The assembled object is encrypted as it streams to the backend.
+	// SSE-C parts were staged under the customer key, which also opens them.
+	var stream *crypto.StreamCipher
+	var kmsEnv *kmsEnvelope
+	var ssecKey []byte
+	switch encryption {
+	case crypto.SSECAlgorithm:
+		key, keyErr := uploadSSECKey(r, encryption, ssecKeyMD5)
+		if keyErr != nil {
+			WriteS3ErrorWithContext(w, ErrInvalidRequest, r.URL.Path, generateRequestID(),
+				WithSuggestion(keyErr.Error()))
+			return
+		}
+		ssecKey = key
+		defer zeroKey(ssecKey)
+		stream, err = crypto.NewSSECStream(key)
+	case crypto.SSEAlgorithm:
+		if s.sseService == nil {
+			err = errSSEUnavailable
+		} else if err = s.sseService.EnsureTenantKey(r.Context(), t.ID); err == nil {
+			stream, err = s.sseService.NewStream(r.Context(), t.ID)
+		}
+	case crypto.SSEKMSAlgorithm:
+		kmsWant.Algorithm = encryption
+		if stream, kmsEnv, err = newKMSStream(r.Context(), s.kms, kmsWant); err != nil {
+			writeSSEStreamError(w, r, s.logger, err)
+			return
+		}
+	}
+	if err != nil {
+		s.logger.Error("multipart complete: failed to start encryption", zap.Error(err))
+		WriteS3Error(w, ErrInternalError, r.URL.Path, generateRequestID())
+		return
 	}
 
 	// Parse the CompleteMultipartUpload XML body (AWS clients send this).
@@ -339,7 +683,7 @@ func (s *Server) handleCompleteMultipartUpload(w http.ResponseWriter, r *http.Re
 	var parts []partRecord
 	if s.db != nil {
 		rows, err := s.db.QueryContext(r.Context(), `
-			SELECT part_number, etag, size_bytes FROM multipart_parts
+			SELECT part_number, etag, size_bytes, COALESCE(checksum_value, '') FROM multipart_parts
 			WHERE upload_id = $1
 			ORDER BY part_number ASC
 		`, uploadID)
@@ -351,7 +695,7 @@ func (s *Server) handleCompleteMultipartUpload(w http.ResponseWriter, r *http.Re
 		defer func() { _ = rows.Close() }()
 		for rows.Next() {
 			var p partRecord
-			if err := rows.Scan(&p.PartNumber, &p.ETag, &p.Size); err != nil {
+			if err := rows.Scan(&p.PartNumber, &p.ETag, &p.Size, &p.Checksum); err != nil {
 				s.logger.Error("failed to scan part row", zap.Error(err))
 				WriteS3Error(w, ErrInternalError, r.URL.Path, generateRequestID())
 				return
@@ -367,7 +711,7 @@ func (s *Server) handleCompleteMultipartUpload(w http.ResponseWriter, r *http.Re
 		memUploadsMu.RLock()
 		mu := memUploads[uploadID]
 		for pn, mp := range mu.Parts {
-			parts = append(parts, partRecord{PartNumber: pn, ETag: mp.ETag, Size: mp.Size})
+			parts = append(parts, partRecord{PartNumber: pn, ETag: mp.ETag, Size: mp.Size, Checksum: mp.Checksum})
 		}
 		memUploadsMu.RUnlock()
 		// Sort by part number (map iteration is random)
@@ -405,11 +749,66 @@ func (s *Server) handleCompleteMultipartUpload(w http.ResponseWriter, r *http.Re
 				WriteS3Error(w, ErrInvalidPart, r.URL.Path, generateRequestID())
 				return
 			}
+			// A part checksum in the request must match the one recorded
+			// when the part was uploaded.
+			if checksumAlgorithm != "" {
+				if want := rp.get(checksumAlgorithm); want != "" && want != up.Checksum {
+					WriteS3ErrorWithContext(w, ErrInvalidPart, r.URL.Path, generateRequestID(),
+						WithSuggestion(fmt.Sprintf("The %s checksum of part %d does not match the uploaded part.",
+							checksumAlgorithm, rp.PartNumber)))
+					return
+				}
+			}
 			selected = append(selected, up)
 		}
 		parts = selected
 	}
 
+	// Parts filled by UploadPartCopy from chunked sources hold chunk
+	// references instead of staged files.
+	partSlices, err := loadPartChunkSlices(r.Context(), s.db, uploadID)
+	if err != nil {
+		s.logger.Error("failed to load part chunk references", zap.Error(err))
+		WriteS3Error(w, ErrInternalError, r.URL.Path, generateRequestID())
+		return
+	}
+
+	// Derive the object checksum before anything reaches the backend, so a
+	// mismatch against the client's x-amz-checksum-* header rejects the
+	// upload without overwriting the current object. COMPOSITE combines the
+	// part checksums; FULL_OBJECT re-reads the staged parts from local disk.
+	var checksumValue string
+	if checksumAlgorithm != "" {
+		if checksumType == checksumTypeFullObject {
+			v, ckErr := fullObjectChecksum(uploadID, parts, checksumAlgorithm, ssecKey)
+			if ckErr != nil {
+				s.logger.Error("failed to compute full-object checksum", zap.String("uploadID", uploadID), zap.Error(ckErr))
+				WriteS3Error(w, ErrInternalError, r.URL.Path, generateRequestID())
+				return
+			}
+			checksumValue = v
+		} else {
+			partChecksums := make([]string, len(parts))
+			for i, p := range parts {
+				partChecksums[i] = p.Checksum
+			}
+			v, ckErr := compositeChecksum(checksumAlgorithm, partChecksums)
+			if ckErr != nil {
+				WriteS3ErrorWithContext(w, ErrInvalidPart, r.URL.Path, generateRequestID(),
+					WithSuggestion(fmt.Sprintf("Every part must carry a %s checksum: %v.", checksumAlgorithm, ckErr)))
+				return
+			}
+			checksumValue = v
+		}
+		if want := r.Header.Get(checksumHeader(checksumAlgorithm)); want != "" &&
+			want != checksumValue && want != strings.SplitN(checksumValue, "-", 2)[0] {
+			WriteS3ErrorWithContext(w, ErrBadDigest, r.URL.Path, generateRequestID(),
+				WithSuggestion(fmt.Sprintf("The %s you specified did not match the calculated checksum.",
+					checksumHeader(checksumAlgorithm))))
+			return
+		}
+	}
+
 	// Compute total size and S3-compatible multipart ETag:
 	// ETag = MD5(concat(MD5_part1 + MD5_part2 + ...))-N
 	var totalSize int64
@@ -423,6 +822,27 @@ func (s *Server) handleCompleteMultipartUpload(w http.ResponseWriter, r *http.Re
 	}
 	finalETag := fmt.Sprintf("\"%x-%d\"", etagHasher.Sum(nil), len(parts))
 
+	// If-None-Match / If-Match are prechecked before the parts are
+	// assembled; the assembled object is staged and only renamed onto the
+	// key if the condition still holds under the key's lock.
+	cw, ok := startConditionalWrite(w, r, s.logger, s.db, t.ID, bucket, object, true)
+	if !ok {
+		return
+	}
+	defer cw.abandon()
+
+	// An upload assembled purely from whole chunks (UploadPartCopy of
+	// chunked sources) completes by installing a manifest — no bytes move.
+	// Uploads with a checksum never hold chunk references, and encrypted
+	// uploads must re-encrypt the chunks' bytes. A conditional complete
+	// assembles the bytes so it has one artifact to stage.
+	if len(partSlices) > 0 && checksumAlgorithm == "" && stream == nil && cw == nil {
+		if merged, surplus, ok := mergeChunkSlices(parts, partSlices); ok && s.chunkManifestAllowed(r.Context(), t, bucket) {
+			s.completeFromChunkRefs(w, r, t, bucket, object, uploadID, parts, merged, surplus, totalSize, finalETag, objectACL)
+			return
+		}
+	}
+
 	// WP-1: multipart bypasses the PUT handler's reservation, so reserve the
 	// assembled size here before streaming to the backend. If the object
 	// overwrites an existing key, the overwritten bytes are captured
@@ -445,22 +865,51 @@ func (s *Server) handleCompleteMultipartUpload(w http.ResponseWriter, r *http.Re
 		reservedBytes = totalSize
 	}
 
+	// An unconditional complete holds the key's lock from the assembled
+	// write to the head upsert (s3_conditional_write.go).
+	var kl *keyWriteLock
+	if cw == nil {
+		var lockErr error
+		if kl, lockErr = lockKeyForWrite(r.Context(), s.db, t.ID, bucket, object); lockErr != nil {
+			if quotaOn {
+				ctx, cancel := quotaCtx(r)
+				s.releaseQuota(ctx, t.ID, reservedBytes)
+				cancel()
+			}
+			s.logger.Error("multipart lock key for write failed", zap.Error(lockErr),
+				zap.String("bucket", bucket), zap.String("key", object))
+			WriteS3Error(w, ErrInternalError, r.URL.Path, generateRequestID())
+			return
+		}
+		defer kl.release()
+	}
+
 	// Stream assembled parts to backend via pipe
 	pr, pw := io.Pipe()
 	containerName := t.NamespaceContainer(bucket)
 
 	errCh := make(chan error, 1)
 
-	// Writer goroutine: read temp files in order, write into pipe
+	// Writer goroutine: read temp files (or chunk slices) in order, write
+	// into pipe. It may outlive the handler after a failed put, so it
+	// zeroes its own copy of the customer key.
+	partKey := append([]byte(nil), ssecKey...)
 	go func() {
+		defer zeroKey(partKey)
 		defer func() {
 			if err := pw.Close(); err != nil {
 				s.logger.Debug("pipe writer close", zap.Error(err))
 			}
 		}()
 		for _, p := range parts {
-			pp := partFilePath(uploadID, p.PartNumber)
-			f, err := os.Open(pp) // #nosec G304 — path derived from validated uploadID
+			if slices := partSlices[p.PartNumber]; len(slices) > 0 {
+				if err := s.writeChunkSlices(r.Context(), t.ID, slices, pw); err != nil {
+					_ = pw.CloseWithError(fmt.Errorf("stream part %d: %w", p.PartNumber, err))
+					return
+				}
+				continue
+			}
+			f, err := openStagedPart(uploadID, p.PartNumber, p.Size, partKey)
 			if err != nil {
 				_ = pw.CloseWithError(fmt.Errorf("open part %d: %w", p.PartNumber, err))
 				return
@@ -478,12 +927,20 @@ func (s *Server) handleCompleteMultipartUpload(w http.ResponseWriter, r *http.Re
 	// plain-PUT path, so resolve the bucket's tier here too — without this,
 	// aws-cli's default multipart uploads would ignore tier placement (a
 	// resilient-tier bucket would silently store on the primary backend).
-	completeOpts := []engine.PutOption{engine.WithContentLength(totalSize)}
+	var assembled io.Reader = pr
+	storedSize := totalSize
+	if stream != nil {
+		assembled = stream.Encrypt(pr)
+		storedSize = stream.EncryptedSize(totalSize)
+	}
+	completeOpts := []engine.PutOption{engine.WithContentLength(storedSize)}
 	if tierClass := bucketTierStorageClass(r.Context(), s.db, t.ID, bucket); tierClass != "" {
 		completeOpts = append(completeOpts, engine.WithStorageClass(tierClass))
 	}
+	var backendName string
 	go func() {
-		_, putErr := s.engine.Put(r.Context(), containerName, object, pr, completeOpts...)
+		var putErr error
+		backendName, putErr = s.engine.Put(r.Context(), containerName, cw.artifact(object), assembled, completeOpts...)
 		_ = pr.Close()
 		errCh <- putErr
 	}()
@@ -501,42 +958,86 @@ func (s *Server) handleCompleteMultipartUpload(w http.ResponseWriter, r *http.Re
 		WriteS3Error(w, ErrInternalError, r.URL.Path, generateRequestID())
 		return
 	}
+	cw.stageOn(s.engine, backendName, containerName, object, completeOpts...)
 
 	// Mark completed and update head cache
 	etagValue := strings.Trim(finalETag, "\"")
 	var displacedSize int64
 	if s.db != nil {
-		_, _ = s.db.ExecContext(r.Context(), `
-			UPDATE multipart_uploads SET status = 'completed' WHERE upload_id = $1
-		`, uploadID)
-
 		contentType := r.Header.Get("Content-Type")
 		if contentType == "" {
 			contentType = "application/octet-stream"
 		}
 		// atomicHeadUpsert captures the overwritten row's size in the same
 		// transaction (WP-1) — released below only if the upsert succeeded.
+		kmsKeyID, kmsDataKey, kmsContext := kmsEnv.dbValues()
 		var dbErr error
-		displacedSize, dbErr = atomicHeadUpsertReleasing(r.Context(), s.db, manifestReleaser(s.gci), t.ID, bucket, object, func(tx *sql.Tx) error {
+		headUpsert := cw.headUpsert
+		if kl != nil {
+			headUpsert = kl.headUpsert
+		}
+		displacedSize, dbErr = headUpsert(r.Context(), s.db, manifestReleaser(s.gci), t.ID, bucket, object, func(tx *sql.Tx) error {
 			// is_chunked=FALSE explicitly: a multipart object overwriting a
 			// chunked one must flip the flag (releaser frees the manifest).
+			// The encryption columns are always written so a plaintext
+			// object never inherits the overwritten one's SSE markers.
+			// The upload is marked completed in the same transaction, so a
+			// failed precondition leaves it active for a retry.
+			if _, execErr := tx.ExecContext(r.Context(), `
+				UPDATE multipart_uploads SET status = 'completed' WHERE upload_id = $1
+			`, uploadID); execErr != nil {
+				return execErr
+			}
 			_, execErr := tx.ExecContext(r.Context(), `
 				INSERT INTO object_head_cache
-					(tenant_id, bucket, object_key, size_bytes, etag, content_type, is_chunked, updated_at)
-				VALUES ($1, $2, $3, $4, $5, $6, FALSE, NOW())
+					(tenant_id, bucket, object_key, size_bytes, etag, content_type, is_chunked,
+					 checksum_algorithm, checksum_value, checksum_type, encryption_algorithm, sse_segmented,
+					 sse_kms_key_id, sse_kms_data_key, sse_kms_context, part_layout, acl, updated_at)
+				VALUES ($1, $2, $3, $4, $5, $6, FALSE, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, NOW())
 				ON CONFLICT (tenant_id, bucket, object_key) DO UPDATE SET
-					size_bytes   = EXCLUDED.size_bytes,
-					etag         = EXCLUDED.etag,
-					content_type = EXCLUDED.content_type,
-					is_chunked   = FALSE,
-					updated_at   = NOW()
-			`, t.ID, bucket, object, totalSize, etagValue, contentType)
+					size_bytes           = EXCLUDED.size_bytes,
+					etag                 = EXCLUDED.etag,
+					content_type         = EXCLUDED.content_type,
+					is_chunked           = FALSE,
+					checksum_algorithm   = EXCLUDED.checksum_algorithm,
+					checksum_value       = EXCLUDED.checksum_value,
+					checksum_type        = EXCLUDED.checksum_type,
+					encryption_algorithm = EXCLUDED.encryption_algorithm,
+					sse_segmented        = EXCLUDED.sse_segmented,
+					sse_kms_key_id       = EXCLUDED.sse_kms_key_id,
+					sse_kms_data_key     = EXCLUDED.sse_kms_data_key,
+					sse_kms_context      = EXCLUDED.sse_kms_context,
+					website_redirect_location = NULL,
+					part_layout          = EXCLUDED.part_layout,
+					acl                  = EXCLUDED.acl,
+					replication_status   = NULL,
+					updated_at           = NOW()
+			`, t.ID, bucket, object, totalSize, etagValue, contentType,
+				nullIfEmpty(checksumAlgorithm), nullIfEmpty(checksumValue), nullIfEmpty(checksumType),
+				encryption, stream != nil, kmsKeyID, kmsDataKey, kmsContext, encodePartLayout(parts), objectACL)
 			return execErr
 		})
+		// A conditional complete whose head row did not commit has no
+		// object under the key (its staged copy was removed): fail it.
+		if dbErr != nil && cw != nil {
+			if quotaOn {
+				ctx, cancel := quotaCtx(r)
+				s.releaseQuota(ctx, t.ID, reservedBytes)
+				cancel()
+			}
+			writeConditionalWriteError(w, r, s.logger, dbErr)
+			return
+		}
 		if dbErr != nil {
 			displacedSize = 0
 			s.logger.Error("failed to update head cache after multipart complete", zap.Error(dbErr))
 		}
+		// The object now has its own copy of any chunk-reference parts.
+		if len(partSlices) > 0 {
+			if relErr := releasePartChunks(r.Context(), s.db, s.gci, uploadID); relErr != nil {
+				s.logger.Warn("failed to release part chunk references", zap.Error(relErr))
+			}
+		}
 	} else {
 		memUploadsMu.Lock()
 		if mu, ok := memUploads[uploadID]; ok {
@@ -562,14 +1063,20 @@ func (s *Server) handleCompleteMultipartUpload(w http.ResponseWriter, r *http.Re
 		zap.Int("parts", len(parts)),
 		zap.String("etag", finalETag))
 
-	location := fmt.Sprintf("http://%s/%s/%s", r.Host, bucket, object)
-	w.Header().Set("Content-Type", "application/xml")
-	if err := xml.NewEncoder(w).Encode(CompleteMultipartUploadResult{
+	location := objectLocation(r, bucket, object)
+	result := CompleteMultipartUploadResult{
 		Location: location,
 		Bucket:   bucket,
 		Key:      object,
 		ETag:     finalETag,
-	}); err != nil {
+	}
+	if checksumAlgorithm != "" {
+		result.set(checksumAlgorithm, checksumValue)
+		result.ChecksumType = checksumType
+	}
+	setSSEResponseHeaders(w, encryption, envelopeKeyID(kmsEnv))
+	w.Header().Set("Content-Type", "application/xml")
+	if err := xml.NewEncoder(w).Encode(result); err != nil {
 		s.logger.Error("failed to encode complete response", zap.Error(err))
 	}
 
@@ -600,6 +1107,10 @@ func (s *Server) handleAbortMultipartUpload(w http.ResponseWriter, r *http.Reque
 			WriteS3Error(w, ErrNoSuchUpload, r.URL.Path, generateRequestID())
 			return
 		}
+		if err := releasePartChunks(r.Context(), s.db, s.gci, uploadID); err != nil {
+			// The terminal purge retries the release.
+			s.logger.Warn("failed to release part chunk references", zap.Error(err))
+		}
 	} else {
 		memUploadsMu.Lock()
 		mu, ok := memUploads[uploadID]
@@ -632,12 +1143,14 @@ func (s *Server) handleListParts(w http.ResponseWriter, r *http.Request, bucket,
 	uploadID := r.URL.Query().Get("uploadId")
 
 	// Verify upload exists and is active
+	var checksumAlgorithm, checksumType string
 	if s.db != nil {
 		var status string
+		var alg, typ sql.NullString
 		err := s.db.QueryRowContext(r.Context(), `
-			SELECT status FROM multipart_uploads
+			SELECT status, checksum_algorithm, checksum_type FROM multipart_uploads
 			WHERE upload_id = $1 AND tenant_id = $2
-		`, uploadID, t.ID).Scan(&status)
+		`, uploadID, t.ID).Scan(&status, &alg, &typ)
 		if err == sql.ErrNoRows || (err == nil && status != "active") {
 			WriteS3Error(w, ErrNoSuchUpload, r.URL.Path, generateRequestID())
 			return
@@ -647,6 +1160,7 @@ func (s *Server) handleListParts(w http.ResponseWriter, r *http.Request, bucket,
 			WriteS3Error(w, ErrInternalError, r.URL.Path, generateRequestID())
 			return
 		}
+		checksumAlgorithm, checksumType = alg.String, typ.String
 	} else {
 		memUploadsMu.RLock()
 		mu, ok := memUploads[uploadID]
@@ -655,13 +1169,16 @@ func (s *Server) handleListParts(w http.ResponseWriter, r *http.Request, bucket,
 			WriteS3Error(w, ErrNoSuchUpload, r.URL.Path, generateRequestID())
 			return
 		}
+		checksumAlgorithm, checksumType = mu.ChecksumAlgorithm, mu.ChecksumType
 	}
 
 	// Fetch parts
 	var items []ListPartItem
 	if s.db != nil {
 		rows, err := s.db.QueryContext(r.Context(), `
-			SELECT part_number, etag, size_bytes, created_at FROM multipart_parts
+			SELECT part_number, etag, size_bytes, created_at,
+				COALESCE(checksum_algorithm, ''), COALESCE(checksum_value, '')
+			FROM multipart_parts
 			WHERE upload_id = $1
 			ORDER BY part_number ASC
 		`, uploadID)
@@ -674,22 +1191,26 @@ func (s *Server) handleListParts(w http.ResponseWriter, r *http.Request, bucket,
 		for rows.Next() {
 			var item ListPartItem
 			var createdAt time.Time
-			if err := rows.Scan(&item.PartNumber, &item.ETag, &item.Size, &createdAt); err != nil {
+			var partAlg, partChecksum string
+			if err := rows.Scan(&item.PartNumber, &item.ETag, &item.Size, &createdAt, &partAlg, &partChecksum); err != nil {
 				continue
 			}
 			item.LastModified = createdAt.UTC().Format(time.RFC3339)
+			item.set(partAlg, partChecksum)
 			items = append(items, item)
 		}
 	} else {
 		memUploadsMu.RLock()
 		mu := memUploads[uploadID]
 		for pn, mp := range mu.Parts {
-			items = append(items, ListPartItem{
+			item := ListPartItem{
 				PartNumber:   pn,
 				ETag:         mp.ETag,
 				Size:         mp.Size,
 				LastModified: time.Now().UTC().Format(time.RFC3339),
-			})
+			}
+			item.set(mu.ChecksumAlgorithm, mp.Checksum)
+			items = append(items, item)
 		}
 		memUploadsMu.RUnlock()
 		sortPartItems(items)
@@ -697,10 +1218,12 @@ func (s *Server) handleListParts(w http.ResponseWriter, r *http.Request, bucket,
 
 	w.Header().Set("Content-Type", "application/xml")
 	if err := xml.NewEncoder(w).Encode(ListPartsResult{
-		Bucket:   bucket,
-		Key:      object,
-		UploadID: uploadID,
-		Parts:    items,
+		Bucket:            bucket,
+		Key:               object,
+		UploadID:          uploadID,
+		ChecksumAlgorithm: checksumAlgorithm,
+		ChecksumType:      checksumType,
+		Parts:             items,
 	}); err != nil {
 		s.logger.Error("failed to encode list parts response", zap.Error(err))
 	}
@@ -758,6 +1281,27 @@ func (s *Server) handleListMultipartUploads(w http.ResponseWriter, r *http.Reque
 	}
 }
 
+// fullObjectChecksum computes a FULL_OBJECT checksum by streaming the staged
+// part files in order; ssecKey opens the parts of an SSE-C upload.
+func fullObjectChecksum(uploadID string, parts []partRecord, algorithm string, ssecKey []byte) (string, error) {
+	ck := newRequestChecksum(algorithm)
+	if ck.h == nil {
+		return "", fmt.Errorf("unsupported checksum algorithm %q", algorithm)
+	}
+	for _, p := range parts {
+		f, err := openStagedPart(uploadID, p.PartNumber, p.Size, ssecKey)
+		if err != nil {
+			return "", fmt.Errorf("open part %d: %w", p.PartNumber, err)
+		}
+		_, copyErr := io.Copy(ck, f)
+		_ = f.Close()
+		if copyErr != nil {
+			return "", fmt.Errorf("read part %d: %w", p.PartNumber, copyErr)
+		}
+	}
+	return ck.Sum(), nil
+}
+
 // sortParts sorts partRecord slices by PartNumber ascending.
 func sortParts(parts []partRecord) {
 	for i := 1; i < len(parts); i++ {
@@ -786,11 +1330,14 @@ type InitiateMultipartUploadResult struct {
 }
 
 type CompleteMultipartUploadRequest struct {
-	XMLName xml.Name `xml:"CompleteMultipartUpload"`
-	Parts   []struct {
-		PartNumber int    `xml:"PartNumber"`
-		ETag       string `xml:"ETag"`
-	} `xml:"Part"`
+	XMLName xml.Name        `xml:"CompleteMultipartUpload"`
+	Parts   []CompletedPart `xml:"Part"`
+}
+
+type CompletedPart struct {
+	PartNumber int    `xml:"PartNumber"`
+	ETag       string `xml:"ETag"`
+	ChecksumFields
 }
 
 type CompleteMultipartUploadResult struct {
@@ -799,14 +1346,18 @@ type CompleteMultipartUploadResult struct {
 	Bucket   string   `xml:"Bucket"`
 	Key      string   `xml:"Key"`
 	ETag     string   `xml:"ETag"`
+	ChecksumFields
+	ChecksumType string `xml:"ChecksumType,omitempty"`
 }
 
 type ListPartsResult struct {
-	XMLName  xml.Name       `xml:"ListPartsResult"`
-	Bucket   string         `xml:"Bucket"`
-	Key      string         `xml:"Key"`
-	UploadID string         `xml:"UploadId"`
-	Parts    []ListPartItem `xml:"Part"`
+	XMLName           xml.Name       `xml:"ListPartsResult"`
+	Bucket            string         `xml:"Bucket"`
+	Key               string         `xml:"Key"`
+	UploadID          string         `xml:"UploadId"`
+	ChecksumAlgorithm string         `xml:"ChecksumAlgorithm,omitempty"`
+	ChecksumType      string         `xml:"ChecksumType,omitempty"`
+	Parts             []ListPartItem `xml:"Part"`
 }
 
 type ListPartItem struct {
@@ -814,6 +1365,7 @@ type ListPartItem struct {
 	ETag         string `xml:"ETag"`
 	Size         int64  `xml:"Size"`
 	LastModified string `xml:"LastModified"`
+	ChecksumFields
 }
 
 type ListMultipartUploadsResult struct {
@@ -1,17 +1,20 @@
 package api
 
 import (
-	"bytes"
 	"context"
 	"database/sql"
 	"encoding/json"
 	"encoding/xml"
+	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"strings"
 	"time"
 
+	"github.com/FairForge/vaultaire/internal/notify"
 	"github.com/FairForge/vaultaire/internal/tenant"
+	"github.com/google/uuid"
 	"go.uber.org/zap"
 )
 
@@ -20,15 +23,177 @@ const maxNotificationBodyBytes = 65536
 // S3 notification XML types
 
 type NotificationConfiguration struct {
-	XMLName xml.Name             `xml:"NotificationConfiguration"`
-	Xmlns   string               `xml:"xmlns,attr,omitempty"`
-	Topics  []TopicConfiguration `xml:"TopicConfiguration,omitempty"`
+	XMLName        xml.Name                     `xml:"NotificationConfiguration"`
+	Xmlns          string                       `xml:"xmlns,attr,omitempty"`
+	Topics         []TopicConfiguration         `xml:"TopicConfiguration,omitempty"`
+	Queues         []QueueConfiguration         `xml:"QueueConfiguration,omitempty"`
+	CloudFunctions []CloudFunctionConfiguration `xml:"CloudFunctionConfiguration,omitempty"`
 }
 
+// TopicConfiguration sends events to an HTTP(S) URL, or to the
+// notification target configured for an ARN.
 type TopicConfiguration struct {
-	ID     string   `xml:"Id,omitempty"`
-	Topic  string   `xml:"Topic"`
-	Events []string `xml:"Event"`
+	ID     string              `xml:"Id,omitempty"`
+	Topic  string              `xml:"Topic"`
+	Events []string            `xml:"Event"`
+	Filter *NotificationFilter `xml:"Filter,omitempty"`
+}
+
+// QueueConfiguration sends events to the notification target configured
+// for a queue ARN.
+type QueueConfiguration struct {
+	ID     string              `xml:"Id,omitempty"`
+	Queue  string              `xml:"Queue"`
+	Events []string            `xml:"Event"`
+	Filter *NotificationFilter `xml:"Filter,omitempty"`
+}
+
+// CloudFunctionConfiguration sends events to the notification target
+// configured for a function ARN.
+type CloudFunctionConfiguration struct {
+	ID            string              `xml:"Id,omitempty"`
+	CloudFunction string              `xml:"CloudFunction"`
+	Events        []string            `xml:"Event"`
+	Filter        *NotificationFilter `xml:"Filter,omitempty"`
+}
+
+// NotificationFilter limits a configuration to keys matching its prefix
+// and suffix rules.
+type NotificationFilter struct {
+	S3Key S3KeyFilter `xml:"S3Key"`
+}
+
+type S3KeyFilter struct {
+	FilterRules []FilterRule `xml:"FilterRule"`
+}
+
+type FilterRule struct {
+	Name  string `xml:"Name"`
+	Value string `xml:"Value"`
+}
+
+// Stored target types: the configuration element a row came from.
+const (
+	notificationTopic         = "topic"
+	notificationQueue         = "queue"
+	notificationCloudFunction = "cloudfunction"
+)
+
+// notificationRule is one configuration of any type, as stored: a row per
+// event name sharing id, target and key filter.
+type notificationRule struct {
+	id         string
+	targetType string
+	target     string
+	events     []string
+	prefix     string
+	suffix     string
+}
+
+func (r notificationRule) filter() *NotificationFilter {
+	var f NotificationFilter
+	if r.prefix != "" {
+		f.S3Key.FilterRules = append(f.S3Key.FilterRules, FilterRule{Name: "Prefix", Value: r.prefix})
+	}
+	if r.suffix != "" {
+		f.S3Key.FilterRules = append(f.S3Key.FilterRules, FilterRule{Name: "Suffix", Value: r.suffix})
+	}
+	if len(f.S3Key.FilterRules) == 0 {
+		return nil
+	}
+	return &f
+}
+
+// matches reports whether an event on key is one r sends.
+func (r notificationRule) matches(eventName, key string) bool {
+	if !strings.HasPrefix(key, r.prefix) || !strings.HasSuffix(key, r.suffix) {
+		return false
+	}
+	for _, filter := range r.events {
+		if matchesEventFilter(filter, eventName) {
+			return true
+		}
+	}
+	return false
+}
+
+// notificationConfigError is a configuration that cannot be set, worded
+// for the InvalidArgument response.
+type notificationConfigError string
+
+func (e notificationConfigError) Error() string { return string(e) }
+
+// notificationRules validates config and flattens its configurations.
+// Configurations without a target or events are ignored. targets holds
+// the ARNs configurations may name.
+func notificationRules(config NotificationConfiguration, targets *notify.Registry) ([]notificationRule, error) {
+	var rules []notificationRule
+	add := func(id, targetType, target string, events []string, filter *NotificationFilter) error {
+		if target == "" || len(events) == 0 {
+			return nil
+		}
+		r := notificationRule{id: id, targetType: targetType, target: target, events: events}
+		if r.id == "" {
+			r.id = uuid.New().String()
+		}
+		for _, event := range events {
+			if !isValidS3EventFilter(event) {
+				return notificationConfigError(fmt.Sprintf("The event %q is not supported for notifications.", event))
+			}
+		}
+		if strings.HasPrefix(target, "arn:") {
+			if _, ok := targets.Get(target); !ok {
+				return notificationConfigError(fmt.Sprintf("Unable to validate the following destination configurations: %s is not a configured notification target.", target))
+			}
+		} else if u, err := url.Parse(target); targetType != notificationTopic || err != nil ||
+			(u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return notificationConfigError(fmt.Sprintf("Unable to validate the following destination configurations: %q is neither an HTTP(S) URL nor a configured notification target ARN.", target))
+		}
+		if filter != nil {
+			for _, rule := range filter.S3Key.FilterRules {
+				var v *string
+				switch strings.ToLower(rule.Name) {
+				case "prefix":
+					v = &r.prefix
+				case "suffix":
+					v = &r.suffix
+				default:
+					return notificationConfigError(fmt.Sprintf("Filter rule name must be either prefix or suffix, not %q.", rule.Name))
+				}
+				if *v != "" {
+					return notificationConfigError(fmt.Sprintf("Cannot specify more than one %s rule in a filter.", strings.ToLower(rule.Name)))
+				}
+				if rule.Value == "" || len(rule.Value) > 1024 {
+					return notificationConfigError(fmt.Sprintf("The %s filter rule value must be 1 to 1024 characters.", strings.ToLower(rule.Name)))
+				}
+				*v = rule.Value
+			}
+		}
+		for _, other := range rules {
+			if other.id == r.id {
+				return notificationConfigError(fmt.Sprintf("Configuration Id %q is used more than once.", r.id))
+			}
+		}
+		rules = append(rules, r)
+		return nil
+	}
+
+	for _, c := range config.Topics {
+		if err := add(c.ID, notificationTopic, c.Topic, c.Events, c.Filter); err != nil {
+			return nil, err
+		}
+	}
+	for _, c := range config.Queues {
+		if err := add(c.ID, notificationQueue, c.Queue, c.Events, c.Filter); err != nil {
+			return nil, err
+		}
+	}
+	for _, c := range config.CloudFunctions {
+		if err := add(c.ID, notificationCloudFunction, c.CloudFunction, c.Events, c.Filter); err != nil {
+			return nil, err
+		}
+	}
+	return rules, nil
 }
 
 func (s *Server) handleGetBucketNotification(w http.ResponseWriter, r *http.Request, req *S3Request) {
@@ -46,51 +211,31 @@ func (s *Server) handleGetBucketNotification(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	rows, err := s.db.QueryContext(r.Context(), `
-		SELECT id, event_filter, target_url
-		FROM bucket_notifications
-		WHERE tenant_id = $1 AND bucket = $2
-		ORDER BY created_at ASC
-	`, t.ID, req.Bucket)
+	rules, err := loadNotificationRules(r.Context(), s.db, t.ID, req.Bucket, false)
 	if err != nil {
 		s.logger.Error("query bucket notifications", zap.Error(err))
 		WriteS3Error(w, ErrInternalError, r.URL.Path, generateRequestID())
 		return
 	}
-	defer func() { _ = rows.Close() }()
-
-	topicMap := make(map[string]*TopicConfiguration)
-	var topicOrder []string
-
-	for rows.Next() {
-		var id, eventFilter, targetURL string
-		if err := rows.Scan(&id, &eventFilter, &targetURL); err != nil {
-			s.logger.Error("scan notification row", zap.Error(err))
-			continue
-		}
-		key := targetURL
-		tc, ok := topicMap[key]
-		if !ok {
-			tc = &TopicConfiguration{
-				ID:    id,
-				Topic: targetURL,
-			}
-			topicMap[key] = tc
-			topicOrder = append(topicOrder, key)
-		}
-		tc.Events = append(tc.Events, eventFilter)
-	}
-	if err := rows.Err(); err != nil {
-		s.logger.Error("notification rows iteration", zap.Error(err))
-		WriteS3Error(w, ErrInternalError, r.URL.Path, generateRequestID())
-		return
-	}
 
 	resp := NotificationConfiguration{
 		Xmlns: "http://s3.amazonaws.com/doc/2006-03-01/",
 	}
-	for _, key := range topicOrder {
-		resp.Topics = append(resp.Topics, *topicMap[key])
+	for _, rule := range rules {
+		switch rule.targetType {
+		case notificationQueue:
+			resp.Queues = append(resp.Queues, QueueConfiguration{
+				ID: rule.id, Queue: rule.target, Events: rule.events, Filter: rule.filter(),
+			})
+		case notificationCloudFunction:
+			resp.CloudFunctions = append(resp.CloudFunctions, CloudFunctionConfiguration{
+				ID: rule.id, CloudFunction: rule.target, Events: rule.events, Filter: rule.filter(),
+			})
+		default:
+			resp.Topics = append(resp.Topics, TopicConfiguration{
+				ID: rule.id, Topic: rule.target, Events: rule.events, Filter: rule.filter(),
+			})
+		}
 	}
 
 	w.Header().Set("Content-Type", "application/xml")
@@ -99,6 +244,42 @@ func (s *Server) handleGetBucketNotification(w http.ResponseWriter, r *http.Requ
 	_ = xml.NewEncoder(w).Encode(resp)
 }
 
+// loadNotificationRules reads a bucket's configurations in the order they
+// were set; enabledOnly skips rows disabled after repeated delivery
+// failures.
+func loadNotificationRules(ctx context.Context, db *sql.DB, tenantID, bucket string, enabledOnly bool) ([]notificationRule, error) {
+	rows, err := db.QueryContext(ctx, `
+		SELECT config_id, target_type, target_url, event_filter, filter_prefix, filter_suffix
+		FROM bucket_notifications
+		WHERE tenant_id = $1 AND bucket = $2 AND (enabled = TRUE OR NOT $3)
+		ORDER BY created_at ASC
+	`, tenantID, bucket, enabledOnly)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var rules []notificationRule
+	index := make(map[string]int)
+	for rows.Next() {
+		var r notificationRule
+		var event string
+		if err := rows.Scan(&r.id, &r.targetType, &r.target, &event, &r.prefix, &r.suffix); err != nil {
+			return nil, err
+		}
+		// A configuration's rows share its id and target.
+		key := r.id + "\x00" + r.target
+		if i, ok := index[key]; ok {
+			rules[i].events = append(rules[i].events, event)
+			continue
+		}
+		r.events = []string{event}
+		index[key] = len(rules)
+		rules = append(rules, r)
+	}
+	return rules, rows.Err()
+}
+
 func (s *Server) handlePutBucketNotification(w http.ResponseWriter, r *http.Request, req *S3Request) {
 	t, err := tenant.FromContext(r.Context())
 	if err != nil || t == nil {
@@ -122,6 +303,11 @@ func (s *Server) handlePutBucketNotification(w http.ResponseWriter, r *http.Requ
 		WriteS3Error(w, ErrMalformedXML, r.URL.Path, generateRequestID())
 		return
 	}
+	rules, err := notificationRules(config, s.notifyTargets)
+	if err != nil {
+		WriteS3ErrorWithContext(w, ErrInvalidArgument, r.URL.Path, generateRequestID(), WithSuggestion(err.Error()))
+		return
+	}
 
 	tx, err := s.db.BeginTx(r.Context(), nil)
 	if err != nil {
@@ -140,19 +326,12 @@ func (s *Server) handlePutBucketNotification(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	for _, tc := range config.Topics {
-		if tc.Topic == "" || len(tc.Events) == 0 {
-			continue
-		}
-		for _, event := range tc.Events {
-			if !isValidS3EventFilter(event) {
-				WriteS3Error(w, ErrInvalidRequest, r.URL.Path, generateRequestID())
-				return
-			}
+	for _, rule := range rules {
+		for _, event := range rule.events {
 			_, err = tx.ExecContext(r.Context(), `
-				INSERT INTO bucket_notifications (tenant_id, bucket, event_filter, target_type, target_url)
-				VALUES ($1, $2, $3, 'webhook', $4)
-			`, t.ID, req.Bucket, event, tc.Topic)
+				INSERT INTO bucket_notifications (tenant_id, bucket, config_id, event_filter, target_type, target_url, filter_prefix, filter_suffix)
+				VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+			`, t.ID, req.Bucket, rule.id, event, rule.targetType, rule.target, rule.prefix, rule.suffix)
 			if err != nil {
 				s.logger.Error("insert notification config", zap.Error(err))
 				WriteS3Error(w, ErrInternalError, r.URL.Path, generateRequestID())
@@ -170,7 +349,7 @@ func (s *Server) handlePutBucketNotification(w http.ResponseWriter, r *http.Requ
 	s.logger.Info("bucket notification config updated",
 		zap.String("tenant_id", t.ID),
 		zap.String("bucket", req.Bucket),
-		zap.Int("topics", len(config.Topics)))
+		zap.Int("configurations", len(rules)))
 
 	w.WriteHeader(http.StatusOK)
 }
@@ -185,6 +364,10 @@ func isValidS3EventFilter(event string) bool {
 		"s3:ObjectRemoved:*",
 		"s3:ObjectRemoved:Delete",
 		"s3:ObjectRemoved:DeleteMarkerCreated",
+		"s3:LifecycleExpiration:*",
+		"s3:LifecycleExpiration:Delete",
+		"s3:LifecycleExpiration:DeleteMarkerCreated",
+		"s3:LifecycleTransition",
 		"s3:*",
 	}
 	for _, v := range valid {
@@ -195,11 +378,13 @@ func isValidS3EventFilter(event string) bool {
 	return false
 }
 
-// NotificationDispatcher fires S3 event notifications asynchronously.
+// NotificationDispatcher queues S3 event notifications on the delivery
+// outbox, which sends and retries them.
 type NotificationDispatcher struct {
-	db     *sql.DB
-	logger *zap.Logger
-	client *http.Client
+	db      *sql.DB
+	logger  *zap.Logger
+	client  *http.Client
+	targets *notify.Registry
 }
 
 func NewNotificationDispatcher(db *sql.DB, logger *zap.Logger) *NotificationDispatcher {
@@ -209,44 +394,95 @@ func NewNotificationDispatcher(db *sql.DB, logger *zap.Logger) *NotificationDisp
 	return &NotificationDispatcher{
 		db:     db,
 		logger: logger,
-		client: &http.Client{Timeout: 5 * time.Second},
+		client: &http.Client{Timeout: deliveryTimeout},
 	}
 }
 
-// S3Event is the S3-compatible notification payload.
+// S3Event is the notification payload, in the Amazon S3 event message
+// format (event version 2.1), so consumers written for S3 events read it
+// unmodified.
 type S3Event struct {
 	Records []S3EventRecord `json:"Records"`
 }
 
 type S3EventRecord struct {
-	EventVersion string          `json:"eventVersion"`
-	EventSource  string          `json:"eventSource"`
-	EventName    string          `json:"eventName"`
-	EventTime    string          `json:"eventTime"`
-	S3           S3EventS3       `json:"s3"`
-	UserIdentity S3EventIdentity `json:"userIdentity"`
+	EventVersion      string                 `json:"eventVersion"`
+	EventSource       string                 `json:"eventSource"`
+	AWSRegion         string                 `json:"awsRegion"`
+	EventTime         string                 `json:"eventTime"`
+	EventName         string                 `json:"eventName"`
+	UserIdentity      S3EventIdentity        `json:"userIdentity"`
+	RequestParameters S3EventRequestParams   `json:"requestParameters"`
+	ResponseElements  S3EventResponseElement `json:"responseElements"`
+	S3                S3EventS3              `json:"s3"`
+}
+
+type S3EventRequestParams struct {
+	SourceIPAddress string `json:"sourceIPAddress"`
+}
+
+type S3EventResponseElement struct {
+	RequestID string `json:"x-amz-request-id"`
+	HostID    string `json:"x-amz-id-2"`
 }
 
 type S3EventS3 struct {
-	Bucket S3EventBucket `json:"bucket"`
-	Object S3EventObject `json:"object"`
+	SchemaVersion   string        `json:"s3SchemaVersion"`
+	ConfigurationID string        `json:"configurationId"`
+	Bucket          S3EventBucket `json:"bucket"`
+	Object          S3EventObject `json:"object"`
 }
 
 type S3EventBucket struct {
-	Name string `json:"name"`
+	Name          string          `json:"name"`
+	OwnerIdentity S3EventIdentity `json:"ownerIdentity"`
+	ARN           string          `json:"arn"`
 }
 
+// S3EventObject describes the object. Key is URL-encoded, as in S3.
 type S3EventObject struct {
-	Key  string `json:"key"`
-	Size int64  `json:"size,omitempty"`
-	ETag string `json:"eTag,omitempty"`
+	Key       string `json:"key"`
+	Size      int64  `json:"size,omitempty"`
+	ETag      string `json:"eTag,omitempty"`
+	Sequencer string `json:"sequencer"`
 }
 
 type S3EventIdentity struct {
 	PrincipalID string `json:"principalId"`
 }
 
-// Fire dispatches a notification event asynchronously.
+// newS3Event builds the payload of one event for one configuration.
+// eventName is the configuration form ("s3:ObjectCreated:Put"); records
+// carry it without the "s3:" prefix, as S3 does.
+func newS3Event(configID, tenantID, bucket, eventName, objectKey string, size int64, etag string, now time.Time) S3Event {
+	return S3Event{
+		Records: []S3EventRecord{{
+			EventVersion: "2.1",
+			EventSource:  "aws:s3",
+			AWSRegion:    presignDefaultRegion,
+			EventTime:    now.UTC().Format("2006-01-02T15:04:05.000Z"),
+			EventName:    strings.TrimPrefix(eventName, "s3:"),
+			UserIdentity: S3EventIdentity{PrincipalID: tenantID},
+			S3: S3EventS3{
+				SchemaVersion:   "1.0",
+				ConfigurationID: configID,
+				Bucket: S3EventBucket{
+					Name:          bucket,
+					OwnerIdentity: S3EventIdentity{PrincipalID: tenantID},
+					ARN:           "arn:aws:s3:::" + bucket,
+				},
+				Object: S3EventObject{
+					Key:       strings.ReplaceAll(url.QueryEscape(objectKey), "%2F", "/"),
+					Size:      size,
+					ETag:      strings.Trim(etag, `"`),
+					Sequencer: fmt.Sprintf("%016X", now.UnixNano()),
+				},
+			},
+		}},
+	}
+}
+
+// Fire queues a notification event for delivery asynchronously.
 func (d *NotificationDispatcher) Fire(tenantID, bucket, eventName, objectKey string, size int64, etag string) {
 	if d == nil {
 		return
@@ -255,64 +491,54 @@ func (d *NotificationDispatcher) Fire(tenantID, bucket, eventName, objectKey str
 	go d.dispatch(tenantID, bucket, eventName, objectKey, size, etag)
 }
 
-func (d *NotificationDispatcher) dispatch(tenantID, bucket, eventName, objectKey string, size int64, etag string) {
+// dispatch enqueues the event for each matching configuration and returns
+// the delivery IDs.
+func (d *NotificationDispatcher) dispatch(tenantID, bucket, eventName, objectKey string, size int64, etag string) []string {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	rows, err := d.db.QueryContext(ctx, `
-		SELECT target_url, event_filter
-		FROM bucket_notifications
-		WHERE tenant_id = $1 AND bucket = $2 AND enabled = TRUE
-	`, tenantID, bucket)
+	rules, err := loadNotificationRules(ctx, d.db, tenantID, bucket, true)
 	if err != nil {
 		d.logger.Error("query notifications for dispatch",
 			zap.Error(err),
 			zap.String("tenant_id", tenantID),
 			zap.String("bucket", bucket))
-		return
+		return nil
 	}
-	defer func() { _ = rows.Close() }()
 
-	type target struct {
-		url    string
-		filter string
-	}
-	var targets []target
-	for rows.Next() {
-		var t target
-		if err := rows.Scan(&t.url, &t.filter); err != nil {
-			d.logger.Error("scan notification target", zap.Error(err))
+	// A target named by several matching configurations gets the event
+	// once, under the first one's ID.
+	now := time.Now()
+	var ids []string
+	queued := make(map[string]bool)
+	for _, rule := range rules {
+		if queued[rule.target] || !rule.matches(eventName, objectKey) {
 			continue
 		}
-		targets = append(targets, t)
-	}
-
-	payload := S3Event{
-		Records: []S3EventRecord{{
-			EventVersion: "2.1",
-			EventSource:  "stored.ge",
-			EventName:    eventName,
-			EventTime:    time.Now().UTC().Format(time.RFC3339),
-			S3: S3EventS3{
-				Bucket: S3EventBucket{Name: bucket},
-				Object: S3EventObject{Key: objectKey, Size: size, ETag: etag},
-			},
-			UserIdentity: S3EventIdentity{PrincipalID: tenantID},
-		}},
-	}
-
-	body, err := json.Marshal(payload)
-	if err != nil {
-		d.logger.Error("marshal notification payload", zap.Error(err))
-		return
-	}
-
-	for _, t := range targets {
-		if !matchesEventFilter(t.filter, eventName) {
+		queued[rule.target] = true
+		body, err := json.Marshal(newS3Event(rule.id, tenantID, bucket, eventName, objectKey, size, etag, now))
+		if err != nil {
+			d.logger.Error("marshal notification payload", zap.Error(err))
+			return ids
+		}
+		id, err := enqueueDelivery(ctx, d.db, outboxEntry{
+			kind:      deliveryKindNotification,
+			tenantID:  tenantID,
+			bucket:    bucket,
+			url:       rule.target,
+			eventType: eventName,
+			payload:   body,
+		})
+		if err != nil {
+			d.logger.Error("enqueue notification delivery",
+				zap.Error(err),
+				zap.String("target", rule.target),
+				zap.String("event", eventName))
 			continue
 		}
-		d.deliver(ctx, t.url, body, eventName)
+		ids = append(ids, id)
 	}
+	return ids
 }
 
 func matchesEventFilter(filter, eventName string) bool {
@@ -331,52 +557,36 @@ func matchesEventFilter(filter, eventName string) bool {
 	return false
 }
 
-func (d *NotificationDispatcher) deliver(ctx context.Context, url string, body []byte, eventName string) {
-	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
-	if err != nil {
-		d.logger.Error("create notification request",
-			zap.Error(err),
-			zap.String("url", url))
-		return
-	}
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("X-S3-Event", eventName)
-
-	resp, err := d.client.Do(req)
-	if err != nil {
-		d.logger.Warn("notification delivery failed",
-			zap.Error(err),
-			zap.String("url", url),
-			zap.String("event", eventName))
+// FireSync queues a notification event and makes the first delivery
+// attempt before returning (for testing). Failed attempts are left to the
+// outbox workers.
+func (d *NotificationDispatcher) FireSync(tenantID, bucket, eventName, objectKey string, size int64, etag string) {
+	if d == nil {
 		return
 	}
-	_ = resp.Body.Close()
+	ids := d.dispatch(tenantID, bucket, eventName, objectKey, size, etag)
 
-	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
-		d.logger.Debug("notification delivered",
-			zap.String("url", url),
-			zap.String("event", eventName),
-			zap.Int("status", resp.StatusCode))
-	} else {
-		d.logger.Warn("notification delivery non-2xx",
-			zap.String("url", url),
-			zap.String("event", eventName),
-			zap.Int("status", resp.StatusCode))
-	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*deliveryTimeout)
+	defer cancel()
+	outbox := newDeliveryOutbox(d.db, d.logger)
+	outbox.client = d.client
+	outbox.targets = d.targets
+	outbox.deliverNow(ctx, ids)
 }
 
-// FireSync dispatches a notification event synchronously (for testing).
-func (d *NotificationDispatcher) FireSync(tenantID, bucket, eventName, objectKey string, size int64, etag string) {
+// SetHTTPClient replaces the HTTP client (for testing).
+func (d *NotificationDispatcher) SetHTTPClient(c *http.Client) {
 	if d == nil {
 		return
 	}
-	d.dispatch(tenantID, bucket, eventName, objectKey, size, etag)
+	d.client = c
 }
 
-// SetHTTPClient replaces the HTTP client (for testing).
-func (d *NotificationDispatcher) SetHTTPClient(c *http.Client) {
+// SetTargets sets the queue and stream targets FireSync delivers to (for
+// testing).
+func (d *NotificationDispatcher) SetTargets(targets *notify.Registry) {
 	if d == nil {
 		return
 	}
-	d.client = c
+	d.targets = targets
 }
@@ -2,6 +2,7 @@ package api
 
 import (
 	"bytes"
+	"context"
 	"database/sql"
 	"encoding/json"
 	"encoding/xml"
@@ -17,6 +18,8 @@ import (
 
 	"github.com/FairForge/vaultaire/internal/drivers"
 	"github.com/FairForge/vaultaire/internal/engine"
+	"github.com/FairForge/vaultaire/internal/notify"
+	"github.com/FairForge/vaultaire/internal/streaming"
 	"github.com/FairForge/vaultaire/internal/tenant"
 	"github.com/go-chi/chi/v5"
 	"github.com/stretchr/testify/assert"
@@ -80,6 +83,7 @@ func setupNotificationFixture(t *testing.T) *notificationFixture {
 
 	t.Cleanup(func() {
 		_, _ = db.Exec("DELETE FROM bucket_notifications WHERE tenant_id = $1", tenantID)
+		_, _ = db.Exec("DELETE FROM delivery_outbox WHERE tenant_id = $1", tenantID)
 		_, _ = db.Exec("DELETE FROM object_head_cache WHERE tenant_id = $1", tenantID)
 		_, _ = db.Exec("DELETE FROM buckets WHERE tenant_id = $1", tenantID)
 		_, _ = db.Exec("DELETE FROM tenants WHERE id = $1", tenantID)
@@ -253,11 +257,11 @@ func TestNotification_EventFired(t *testing.T) {
 
 	event := received[len(received)-1]
 	require.Len(t, event.Records, 1)
-	assert.Equal(t, "s3:ObjectCreated:Put", event.Records[0].EventName)
+	assert.Equal(t, "ObjectCreated:Put", event.Records[0].EventName)
 	assert.Equal(t, f.bucket, event.Records[0].S3.Bucket.Name)
 	assert.Equal(t, "test-file.txt", event.Records[0].S3.Object.Key)
 	assert.Equal(t, f.tenantID, event.Records[0].UserIdentity.PrincipalID)
-	assert.Equal(t, "stored.ge", event.Records[0].EventSource)
+	assert.Equal(t, "aws:s3", event.Records[0].EventSource)
 }
 
 func TestNotification_WildcardMatch(t *testing.T) {
@@ -308,8 +312,8 @@ func TestNotification_WildcardMatch(t *testing.T) {
 	defer mu.Unlock()
 
 	assert.Len(t, eventNames, 2, "wildcard should match Put and Copy but not Delete")
-	assert.Contains(t, eventNames, "s3:ObjectCreated:Put")
-	assert.Contains(t, eventNames, "s3:ObjectCreated:Copy")
+	assert.Contains(t, eventNames, "ObjectCreated:Put")
+	assert.Contains(t, eventNames, "ObjectCreated:Copy")
 }
 
 func TestNotification_DeleteEventFired(t *testing.T) {
@@ -374,7 +378,7 @@ func TestNotification_DeleteEventFired(t *testing.T) {
 
 	event := received[len(received)-1]
 	require.Len(t, event.Records, 1)
-	assert.Equal(t, "s3:ObjectRemoved:Delete", event.Records[0].EventName)
+	assert.Equal(t, "ObjectRemoved:Delete", event.Records[0].EventName)
 	assert.Equal(t, f.bucket, event.Records[0].S3.Bucket.Name)
 	assert.Equal(t, "delete-me.txt", event.Records[0].S3.Object.Key)
 }
@@ -472,3 +476,201 @@ func TestNotification_InvalidEventFilter(t *testing.T) {
 	f.server.handlePutBucketNotification(w, req, &S3Request{Bucket: f.bucket, TenantID: f.tenantID})
 	assert.Equal(t, http.StatusBadRequest, w.Code, "invalid event filter should be rejected")
 }
+
+func TestNotification_QueueWithKeyFilter(t *testing.T) {
+	f := setupNotificationFixture(t)
+
+	const arn = "arn:aws:sqs:us-east-1:000000000000:uploads"
+	streams := streaming.NewStreamManager(nil)
+	targets, err := notify.NewRegistry(map[string]string{arn: "stream://uploads"}, streams)
+	require.NoError(t, err)
+	f.server.notifyTargets = targets
+
+	configXML := `<NotificationConfiguration>
+  <QueueConfiguration>
+    <Id>jpegs</Id>
+    <Queue>` + arn + `</Queue>
+    <Event>s3:ObjectCreated:*</Event>
+    <Filter><S3Key>
+      <FilterRule><Name>prefix</Name><Value>photos/</Value></FilterRule>
+      <FilterRule><Name>Suffix</Name><Value>.jpg</Value></FilterRule>
+    </S3Key></Filter>
+  </QueueConfiguration>
+</NotificationConfiguration>`
+	req := httptest.NewRequest("PUT", "/"+f.bucket+"?notification", bytes.NewReader([]byte(configXML)))
+	req = req.WithContext(tenant.WithTenant(req.Context(), f.tenant))
+	w := httptest.NewRecorder()
+	f.server.handlePutBucketNotification(w, req, &S3Request{Bucket: f.bucket, TenantID: f.tenantID})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	req = httptest.NewRequest("GET", "/"+f.bucket+"?notification", nil)
+	req = req.WithContext(tenant.WithTenant(req.Context(), f.tenant))
+	w = httptest.NewRecorder()
+	f.server.handleGetBucketNotification(w, req, &S3Request{Bucket: f.bucket, TenantID: f.tenantID})
+	require.Equal(t, http.StatusOK, w.Code)
+	var got NotificationConfiguration
+	require.NoError(t, xml.Unmarshal(w.Body.Bytes(), &got))
+	assert.Empty(t, got.Topics)
+	require.Len(t, got.Queues, 1)
+	assert.Equal(t, QueueConfiguration{
+		ID: "jpegs", Queue: arn, Events: []string{"s3:ObjectCreated:*"},
+		Filter: &NotificationFilter{S3Key: S3KeyFilter{FilterRules: []FilterRule{
+			{Name: "Prefix", Value: "photos/"}, {Name: "Suffix", Value: ".jpg"},
+		}}},
+	}, got.Queues[0])
+
+	sub, err := streams.Subscribe("uploads", "test", streaming.SubscribeOptions{StartOffset: streaming.OffsetEarliest})
+	require.NoError(t, err)
+	defer func() { _ = sub.Close() }()
+
+	dispatcher := NewNotificationDispatcher(f.db, zap.NewNop())
+	dispatcher.SetTargets(targets)
+	dispatcher.FireSync(f.tenantID, f.bucket, "s3:ObjectCreated:Put", "photos/a cat.jpg", 100, `"abc"`)
+	dispatcher.FireSync(f.tenantID, f.bucket, "s3:ObjectCreated:Put", "photos/notes.txt", 10, "")
+	dispatcher.FireSync(f.tenantID, f.bucket, "s3:ObjectCreated:Put", "videos/b.jpg", 10, "")
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	msg, err := sub.Receive(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, f.bucket+"/photos/a+cat.jpg", msg.Key)
+	var event S3Event
+	require.NoError(t, json.Unmarshal(msg.Value, &event))
+	require.Len(t, event.Records, 1)
+	assert.Equal(t, "ObjectCreated:Put", event.Records[0].EventName)
+	assert.Equal(t, "jpegs", event.Records[0].S3.ConfigurationID)
+	assert.Equal(t, "photos/a+cat.jpg", event.Records[0].S3.Object.Key)
+	assert.Equal(t, "abc", event.Records[0].S3.Object.ETag)
+
+	// The other keys were filtered out.
+	ctx, cancel = context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, err = sub.Receive(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestNotificationRules(t *testing.T) {
+	const arn = "arn:aws:sqs:us-east-1:000000000000:uploads"
+	targets, err := notify.NewRegistry(map[string]string{arn: "stream://uploads"}, streaming.NewStreamManager(nil))
+	require.NoError(t, err)
+
+	filter := func(rules ...FilterRule) *NotificationFilter {
+		return &NotificationFilter{S3Key: S3KeyFilter{FilterRules: rules}}
+	}
+	events := []string{"s3:ObjectCreated:*"}
+	for _, tt := range []struct {
+		name    string
+		config  NotificationConfiguration
+		wantErr string
+	}{
+		{name: "topic url", config: NotificationConfiguration{Topics: []TopicConfiguration{{Topic: "https://example.com/hook", Events: events}}}},
+		{name: "topic arn", config: NotificationConfiguration{Topics: []TopicConfiguration{{Topic: arn, Events: events}}}},
+		{name: "queue arn", config: NotificationConfiguration{Queues: []QueueConfiguration{{Queue: arn, Events: events}}}},
+		{name: "function arn", config: NotificationConfiguration{CloudFunctions: []CloudFunctionConfiguration{{CloudFunction: arn, Events: events}}}},
+		{
+			name:    "unknown arn",
+			config:  NotificationConfiguration{Queues: []QueueConfiguration{{Queue: "arn:aws:sqs:us-east-1:000000000000:other", Events: events}}},
+			wantErr: "is not a configured notification target",
+		},
+		{
+			name:    "queue url",
+			config:  NotificationConfiguration{Queues: []QueueConfiguration{{Queue: "https://example.com/hook", Events: events}}},
+			wantErr: "neither an HTTP(S) URL nor a configured notification target ARN",
+		},
+		{
+			name:    "topic not a url",
+			config:  NotificationConfiguration{Topics: []TopicConfiguration{{Topic: "example.com", Events: events}}},
+			wantErr: "neither an HTTP(S) URL",
+		},
+		{
+			name:    "bad event",
+			config:  NotificationConfiguration{Queues: []QueueConfiguration{{Queue: arn, Events: []string{"s3:Nope"}}}},
+			wantErr: `The event "s3:Nope" is not supported`,
+		},
+		{
+			name: "bad rule name",
+			config: NotificationConfiguration{Queues: []QueueConfiguration{{Queue: arn, Events: events,
+				Filter: filter(FilterRule{Name: "contains", Value: "x"})}}},
+			wantErr: "must be either prefix or suffix",
+		},
+		{
+			name: "two prefixes",
+			config: NotificationConfiguration{Queues: []QueueConfiguration{{Queue: arn, Events: events,
+				Filter: filter(FilterRule{Name: "prefix", Value: "a/"}, FilterRule{Name: "Prefix", Value: "b/"})}}},
+			wantErr: "more than one prefix rule",
+		},
+		{
+			name: "empty value",
+			config: NotificationConfiguration{Queues: []QueueConfiguration{{Queue: arn, Events: events,
+				Filter: filter(FilterRule{Name: "suffix"})}}},
+			wantErr: "suffix filter rule value must be 1 to 1024 characters",
+		},
+		{
+			name: "duplicate id",
+			config: NotificationConfiguration{
+				Topics: []TopicConfiguration{{ID: "a", Topic: "https://example.com/hook", Events: events}},
+				Queues: []QueueConfiguration{{ID: "a", Queue: arn, Events: events}},
+			},
+			wantErr: `Configuration Id "a" is used more than once`,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			rules, err := notificationRules(tt.config, targets)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Len(t, rules, 1)
+			assert.NotEmpty(t, rules[0].id, "an Id is generated when none is given")
+		})
+	}
+
+	// Configurations without a target or events are ignored, as before.
+	rules, err := notificationRules(NotificationConfiguration{Topics: []TopicConfiguration{
+		{Topic: "https://example.com/hook"}, {Events: events},
+	}}, targets)
+	require.NoError(t, err)
+	assert.Empty(t, rules)
+}
+
+func TestNotificationRule_Matches(t *testing.T) {
+	r := notificationRule{events: []string{"s3:ObjectCreated:*"}, prefix: "photos/", suffix: ".jpg"}
+	assert.True(t, r.matches("s3:ObjectCreated:Put", "photos/cat.jpg"))
+	assert.False(t, r.matches("s3:ObjectRemoved:Delete", "photos/cat.jpg"))
+	assert.False(t, r.matches("s3:ObjectCreated:Put", "photos/cat.png"))
+	assert.False(t, r.matches("s3:ObjectCreated:Put", "videos/cat.jpg"))
+
+	unfiltered := notificationRule{events: []string{"s3:*"}}
+	assert.True(t, unfiltered.matches("s3:ObjectRemoved:Delete", "anything"))
+}
+
+func TestNewS3Event(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 30, 45, 123456789, time.UTC)
+	body, err := json.Marshal(newS3Event("cfg-1", "tenant-1", "photos", "s3:ObjectCreated:Put", "2026/a cat+dog.jpg", 1024, `"d41d8cd98f00b204e9800998ecf8427e"`, now))
+	require.NoError(t, err)
+
+	// The shape consumers of Amazon S3 events expect.
+	assert.JSONEq(t, `{"Records":[{
+		"eventVersion":"2.1",
+		"eventSource":"aws:s3",
+		"awsRegion":"us-east-1",
+		"eventTime":"2026-03-01T12:30:45.123Z",
+		"eventName":"ObjectCreated:Put",
+		"userIdentity":{"principalId":"tenant-1"},
+		"requestParameters":{"sourceIPAddress":""},
+		"responseElements":{"x-amz-request-id":"","x-amz-id-2":""},
+		"s3":{
+			"s3SchemaVersion":"1.0",
+			"configurationId":"cfg-1",
+			"bucket":{"name":"photos","ownerIdentity":{"principalId":"tenant-1"},"arn":"arn:aws:s3:::photos"},
+			"object":{"key":"2026/a+cat%2Bdog.jpg","size":1024,"eTag":"d41d8cd98f00b204e9800998ecf8427e","sequencer":"`+fmt.Sprintf("%016X", now.UnixNano())+`"}
+		}
+	}]}`, string(body))
+
+	// Deletes carry no size or ETag.
+	body, err = json.Marshal(newS3Event("cfg-1", "tenant-1", "photos", "s3:ObjectRemoved:Delete", "a.jpg", 0, "", now))
+	require.NoError(t, err)
+	assert.NotContains(t, string(body), `"size"`)
+	assert.NotContains(t, string(body), `"eTag"`)
+}
@@ -0,0 +1,406 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/FairForge/vaultaire/internal/crypto"
+	"github.com/FairForge/vaultaire/internal/engine"
+	"github.com/FairForge/vaultaire/internal/tenant"
+	"go.uber.org/zap"
+)
+
+// GetObjectAttributes (GET ?attributes) returns the attributes named in
+// x-amz-object-attributes from metadata alone — no backend round trip. The
+// part layout of a multipart object is recorded in part_layout when the
+// upload completes; a chunked object without one reports its manifest
+// chunks as parts, which is the layout ranged reads are verified against.
+
+// maxObjectAttributesParts is the x-amz-max-parts default and ceiling.
+const maxObjectAttributesParts = 1000
+
+// objectAttributeNames are the values x-amz-object-attributes accepts.
+var objectAttributeNames = map[string]bool{
+	"ETag":         true,
+	"Checksum":     true,
+	"ObjectParts":  true,
+	"StorageClass": true,
+	"ObjectSize":   true,
+}
+
+// objectPart is one element of a stored part_layout.
+type objectPart struct {
+	Number   int    `json:"n"`
+	Size     int64  `json:"size"`
+	Checksum string `json:"checksum,omitempty"`
+}
+
+// encodePartLayout returns the part_layout value of a completed upload.
+func encodePartLayout(parts []partRecord) []byte {
+	if len(parts) == 0 {
+		return nil
+	}
+	layout := make([]objectPart, len(parts))
+	for i, p := range parts {
+		layout[i] = objectPart{Number: p.PartNumber, Size: p.Size, Checksum: p.Checksum}
+	}
+	raw, err := json.Marshal(layout)
+	if err != nil {
+		return nil
+	}
+	return raw
+}
+
+// GetObjectAttributesResponse is the S3 XML document for GET ?attributes.
+// Only the requested attributes are present.
+type GetObjectAttributesResponse struct {
+	XMLName      xml.Name                  `xml:"GetObjectAttributesResponse"`
+	Xmlns        string                    `xml:"xmlns,attr,omitempty"`
+	ETag         string                    `xml:"ETag,omitempty"`
+	Checksum     *ObjectAttributesChecksum `xml:"Checksum,omitempty"`
+	ObjectParts  *ObjectAttributesParts    `xml:"ObjectParts,omitempty"`
+	StorageClass string                    `xml:"StorageClass,omitempty"`
+	ObjectSize   *int64                    `xml:"ObjectSize,omitempty"`
+}
+
+type ObjectAttributesChecksum struct {
+	ChecksumFields
+	ChecksumType string `xml:"ChecksumType,omitempty"`
+}
+
+type ObjectAttributesParts struct {
+	TotalPartsCount      int                    `xml:"PartsCount"`
+	PartNumberMarker     int                    `xml:"PartNumberMarker"`
+	NextPartNumberMarker int                    `xml:"NextPartNumberMarker"`
+	MaxParts             int                    `xml:"MaxParts"`
+	IsTruncated          bool                   `xml:"IsTruncated"`
+	Parts                []ObjectAttributesPart `xml:"Part"`
+}
+
+type ObjectAttributesPart struct {
+	PartNumber int   `xml:"PartNumber"`
+	Size       int64 `xml:"Size"`
+	ChecksumFields
+}
+
+// parseObjectAttributes reads x-amz-object-attributes, which may repeat
+// and holds comma-separated names.
+func parseObjectAttributes(r *http.Request) (map[string]bool, error) {
+	want := make(map[string]bool)
+	for _, v := range r.Header.Values("x-amz-object-attributes") {
+		for _, name := range strings.Split(v, ",") {
+			name = strings.TrimSpace(name)
+			if name == "" {
+				continue
+			}
+			if !objectAttributeNames[name] {
+				return nil, fmt.Errorf("invalid attribute name %q: expected ETag, Checksum, ObjectParts, StorageClass or ObjectSize", name)
+			}
+			want[name] = true
+		}
+	}
+	if len(want) == 0 {
+		return nil, fmt.Errorf("the x-amz-object-attributes header is required")
+	}
+	return want, nil
+}
+
+// parsePartsPage reads x-amz-max-parts and x-amz-part-number-marker.
+// Like ListParts, a max-parts above the ceiling is clamped.
+func parsePartsPage(r *http.Request) (maxParts, marker int, err error) {
+	maxParts = maxObjectAttributesParts
+	if v := r.Header.Get("x-amz-max-parts"); v != "" {
+		maxParts, err = strconv.Atoi(v)
+		if err != nil || maxParts < 0 {
+			return 0, 0, fmt.Errorf("x-amz-max-parts must be a non-negative integer, got %q", v)
+		}
+		if maxParts > maxObjectAttributesParts {
+			maxParts = maxObjectAttributesParts
+		}
+	}
+	if v := r.Header.Get("x-amz-part-number-marker"); v != "" {
+		marker, err = strconv.Atoi(v)
+		if err != nil || marker < 0 {
+			return 0, 0, fmt.Errorf("x-amz-part-number-marker must be a non-negative integer, got %q", v)
+		}
+	}
+	return maxParts, marker, nil
+}
+
+// pagePartLayout returns the page of layout after marker. Parts are
+// ordered by number.
+func pagePartLayout(layout []objectPart, algorithm string, maxParts, marker int) *ObjectAttributesParts {
+	out := &ObjectAttributesParts{
+		TotalPartsCount:  len(layout),
+		PartNumberMarker: marker,
+		MaxParts:         maxParts,
+	}
+	for _, p := range layout {
+		if p.Number <= marker {
+			continue
+		}
+		if len(out.Parts) == maxParts {
+			out.IsTruncated = true
+			break
+		}
+		part := ObjectAttributesPart{PartNumber: p.Number, Size: p.Size}
+		if algorithm != "" && p.Checksum != "" {
+			part.set(algorithm, p.Checksum)
+		}
+		out.Parts = append(out.Parts, part)
+		out.NextPartNumberMarker = p.Number
+	}
+	return out
+}
+
+// etagPartsCount returns the part count suffix of a multipart ETag
+// ("<md5>-N"), or 0 for a single-part object.
+func etagPartsCount(etag string) int {
+	i := strings.LastIndexByte(etag, '-')
+	if i < 0 {
+		return 0
+	}
+	n, err := strconv.Atoi(etag[i+1:])
+	if err != nil || n < 1 {
+		return 0
+	}
+	return n
+}
+
+// objectAttributesHead is the head row GetObjectAttributes answers from.
+type objectAttributesHead struct {
+	size              int64
+	etag              string
+	updatedAt         time.Time
+	backendName       string
+	encryption        string
+	checksumAlgorithm string
+	checksumValue     string
+	checksumType      string
+	isChunked         bool
+	partLayout        []byte
+}
+
+func (s *Server) handleGetObjectAttributes(w http.ResponseWriter, r *http.Request, req *S3Request) {
+	t, err := tenant.FromContext(r.Context())
+	if err != nil || t == nil {
+		WriteS3Error(w, ErrAccessDenied, r.URL.Path, generateRequestID())
+		return
+	}
+
+	want, err := parseObjectAttributes(r)
+	if err != nil {
+		WriteS3ErrorWithContext(w, ErrInvalidArgument, r.URL.Path, generateRequestID(),
+			WithSuggestion(err.Error()))
+		return
+	}
+	maxParts, marker, err := parsePartsPage(r)
+	if err != nil {
+		WriteS3ErrorWithContext(w, ErrInvalidArgument, r.URL.Path, generateRequestID(),
+			WithSuggestion(err.Error()))
+		return
+	}
+
+	if s.db == nil {
+		WriteS3Error(w, ErrInternalError, r.URL.Path, generateRequestID())
+		return
+	}
+	ctx := r.Context()
+
+	noSuchKey := func() {
+		reqID := generateRequestID()
+		if suggestion := keySuggestion(ctx, s.db, t.ID, req.Bucket, req.Object); suggestion != "" {
+			WriteS3ErrorWithContext(w, ErrNoSuchKey, r.URL.Path, reqID, WithSuggestion(suggestion))
+		} else {
+			WriteS3Error(w, ErrNoSuchKey, r.URL.Path, reqID)
+		}
+	}
+
+	// A versionId naming an older version answers from object_versions,
+	// which records no checksum or part layout: like a versioned GET, the
+	// checksum is omitted and the part count comes from the ETag.
+	reqVersionID := r.URL.Query().Get("versionId")
+	if reqVersionID != "" {
+		var isDeleteMarker, isLatest bool
+		var etag string
+		var size int64
+		var createdAt time.Time
+		var backendName sql.NullString
+		err := s.db.QueryRowContext(ctx, `
+			SELECT is_delete_marker, is_latest, etag, size_bytes, created_at, backend_name
+			FROM object_versions
+			WHERE tenant_id = $1 AND bucket = $2 AND object_key = $3 AND version_id = $4`,
+			t.ID, req.Bucket, req.Object, reqVersionID).Scan(&isDeleteMarker, &isLatest, &etag, &size, &createdAt, &backendName)
+		if err != nil {
+			WriteS3Error(w, ErrNoSuchVersion, r.URL.Path, generateRequestID())
+			return
+		}
+		w.Header().Set("x-amz-version-id", reqVersionID)
+		if isDeleteMarker {
+			w.Header().Set("x-amz-delete-marker", "true")
+			noSuchKey()
+			return
+		}
+		if !isLatest {
+			resp := GetObjectAttributesResponse{}
+			if want["ETag"] {
+				resp.ETag = etag
+			}
+			if want["StorageClass"] {
+				resp.StorageClass = engine.BackendToStorageClass(backendName.String)
+			}
+			if want["ObjectSize"] {
+				resp.ObjectSize = &size
+			}
+			if n := etagPartsCount(etag); want["ObjectParts"] && n > 0 {
+				resp.ObjectParts = &ObjectAttributesParts{TotalPartsCount: n, PartNumberMarker: marker, MaxParts: maxParts}
+			}
+			writeObjectAttributes(w, createdAt, &resp)
+			return
+		}
+	} else if vs := getBucketVersioningStatus(ctx, s.db, t.ID, req.Bucket); vs == "Enabled" || vs == "Suspended" {
+		var isDeleteMarker bool
+		var latestVersionID string
+		err := s.db.QueryRowContext(ctx, `
+			SELECT version_id, is_delete_marker FROM object_versions
+			WHERE tenant_id = $1 AND bucket = $2 AND object_key = $3 AND is_latest = TRUE`,
+			t.ID, req.Bucket, req.Object).Scan(&latestVersionID, &isDeleteMarker)
+		if err == nil && latestVersionID != "" {
+			w.Header().Set("x-amz-version-id", latestVersionID)
+		}
+		if err == nil && isDeleteMarker {
+			w.Header().Set("x-amz-delete-marker", "true")
+			noSuchKey()
+			return
+		}
+	}
+
+	var head objectAttributesHead
+	var alg, val, typ sql.NullString
+	err = s.db.QueryRowContext(ctx, `
+		SELECT size_bytes, etag, updated_at, COALESCE(backend_name, ''), COALESCE(encryption_algorithm, ''),
+		       checksum_algorithm, checksum_value, checksum_type, is_chunked, part_layout
+		FROM object_head_cache
+		WHERE tenant_id = $1 AND bucket = $2 AND object_key = $3`,
+		t.ID, req.Bucket, req.Object).Scan(&head.size, &head.etag, &head.updatedAt, &head.backendName, &head.encryption,
+		&alg, &val, &typ, &head.isChunked, &head.partLayout)
+	if err == sql.ErrNoRows {
+		noSuchKey()
+		return
+	}
+	if err != nil {
+		s.logger.Error("GetObjectAttributes: metadata query failed", zap.Error(err))
+		WriteS3Error(w, ErrInternalError, r.URL.Path, generateRequestID())
+		return
+	}
+	head.checksumAlgorithm, head.checksumValue, head.checksumType = alg.String, val.String, typ.String
+
+	// Same rule as HEAD: an SSE-C object's metadata needs the key headers.
+	if head.encryption == crypto.SSECAlgorithm && !crypto.HasSSECHeaders(r) {
+		WriteS3ErrorWithContext(w, ErrAccessDenied, r.URL.Path, generateRequestID(),
+			WithSuggestion("This object was encrypted with SSE-C. Provide the encryption key to access metadata."))
+		return
+	}
+
+	resp := GetObjectAttributesResponse{}
+	if want["ETag"] {
+		resp.ETag = head.etag
+	}
+	if want["Checksum"] && head.checksumAlgorithm != "" && head.checksumValue != "" {
+		resp.Checksum = &ObjectAttributesChecksum{ChecksumType: head.checksumType}
+		if resp.Checksum.ChecksumType == "" {
+			resp.Checksum.ChecksumType = checksumTypeFullObject
+		}
+		resp.Checksum.set(head.checksumAlgorithm, head.checksumValue)
+	}
+	if want["StorageClass"] {
+		resp.StorageClass = engine.BackendToStorageClass(head.backendName)
+	}
+	if want["ObjectSize"] {
+		resp.ObjectSize = &head.size
+	}
+	if want["ObjectParts"] {
+		layout, err := s.objectPartLayout(r, t.ID, req.Bucket, req.Object, &head)
+		if err != nil {
+			s.logger.Error("GetObjectAttributes: part layout unavailable",
+				zap.String("bucket", req.Bucket), zap.String("key", req.Object), zap.Error(err))
+			WriteS3Error(w, ErrInternalError, r.URL.Path, generateRequestID())
+			return
+		}
+		switch {
+		case len(layout) > 0:
+			resp.ObjectParts = pagePartLayout(layout, head.checksumAlgorithm, maxParts, marker)
+		case etagPartsCount(head.etag) > 0:
+			// Completed before part layouts were recorded: the count is
+			// all that is known.
+			resp.ObjectParts = &ObjectAttributesParts{
+				TotalPartsCount: etagPartsCount(head.etag), PartNumberMarker: marker, MaxParts: maxParts,
+			}
+		}
+	}
+	writeObjectAttributes(w, head.updatedAt, &resp)
+}
+
+// objectPartLayout returns an object's parts: the layout recorded at
+// multipart completion, else the chunk manifest of a chunked object. It is
+// empty for a single-part object.
+func (s *Server) objectPartLayout(r *http.Request, tenantID, bucket, key string, head *objectAttributesHead) ([]objectPart, error) {
+	if len(head.partLayout) > 0 {
+		var layout []objectPart
+		if err := json.Unmarshal(head.partLayout, &layout); err != nil {
+			return nil, fmt.Errorf("decode part layout: %w", err)
+		}
+		return layout, nil
+	}
+	if !head.isChunked {
+		return nil, nil
+	}
+
+	rows, err := s.db.QueryContext(r.Context(), `
+		SELECT chunk_offset FROM tenant_chunk_refs
+		WHERE tenant_id = $1 AND bucket_name = $2 AND object_key = $3
+		ORDER BY chunk_index`,
+		tenantID, bucket, key)
+	if err != nil {
+		return nil, fmt.Errorf("load chunk manifest: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+	var offsets []int64
+	for rows.Next() {
+		var off int64
+		if err := rows.Scan(&off); err != nil {
+			return nil, fmt.Errorf("scan chunk manifest: %w", err)
+		}
+		offsets = append(offsets, off)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("load chunk manifest: %w", err)
+	}
+
+	layout := make([]objectPart, len(offsets))
+	for i, off := range offsets {
+		end := head.size
+		if i+1 < len(offsets) {
+			end = offsets[i+1]
+		}
+		layout[i] = objectPart{Number: i + 1, Size: end - off}
+	}
+	return layout, nil
+}
+
+func writeObjectAttributes(w http.ResponseWriter, lastModified time.Time, resp *GetObjectAttributesResponse) {
+	resp.Xmlns = "http://s3.amazonaws.com/doc/2006-03-01/"
+	if !lastModified.IsZero() {
+		w.Header().Set("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte(xml.Header))
+	_ = xml.NewEncoder(w).Encode(resp)
+}
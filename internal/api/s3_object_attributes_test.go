@@ -0,0 +1,215 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/FairForge/vaultaire/internal/tenant"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestParseObjectAttributes(t *testing.T) {
+	r := httptest.NewRequest("GET", "/b/k?attributes", nil)
+	r.Header.Add("x-amz-object-attributes", "ETag, ObjectParts")
+	r.Header.Add("x-amz-object-attributes", "ObjectSize")
+	want, err := parseObjectAttributes(r)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]bool{"ETag": true, "ObjectParts": true, "ObjectSize": true}, want)
+
+	r = httptest.NewRequest("GET", "/b/k?attributes", nil)
+	_, err = parseObjectAttributes(r)
+	assert.Error(t, err, "the header is required")
+
+	r.Header.Set("x-amz-object-attributes", "ETag,ContentType")
+	_, err = parseObjectAttributes(r)
+	assert.Error(t, err)
+}
+
+func TestParsePartsPage(t *testing.T) {
+	r := httptest.NewRequest("GET", "/b/k?attributes", nil)
+	maxParts, marker, err := parsePartsPage(r)
+	require.NoError(t, err)
+	assert.Equal(t, maxObjectAttributesParts, maxParts)
+	assert.Zero(t, marker)
+
+	r.Header.Set("x-amz-max-parts", "5000")
+	r.Header.Set("x-amz-part-number-marker", "3")
+	maxParts, marker, err = parsePartsPage(r)
+	require.NoError(t, err)
+	assert.Equal(t, maxObjectAttributesParts, maxParts, "clamped to the ceiling")
+	assert.Equal(t, 3, marker)
+
+	r.Header.Set("x-amz-max-parts", "-1")
+	_, _, err = parsePartsPage(r)
+	assert.Error(t, err)
+}
+
+func TestPagePartLayout(t *testing.T) {
+	layout := []objectPart{
+		{Number: 1, Size: 5 << 20, Checksum: "AAAAAA=="},
+		{Number: 2, Size: 5 << 20, Checksum: "BBBBBB=="},
+		{Number: 3, Size: 1024, Checksum: "CCCCCC=="},
+	}
+
+	page := pagePartLayout(layout, checksumCRC32, 2, 0)
+	assert.Equal(t, 3, page.TotalPartsCount)
+	assert.True(t, page.IsTruncated)
+	assert.Equal(t, 2, page.NextPartNumberMarker)
+	require.Len(t, page.Parts, 2)
+	assert.Equal(t, "AAAAAA==", page.Parts[0].ChecksumCRC32)
+
+	page = pagePartLayout(layout, "", 2, page.NextPartNumberMarker)
+	assert.False(t, page.IsTruncated)
+	require.Len(t, page.Parts, 1)
+	assert.Equal(t, 3, page.Parts[0].PartNumber)
+	assert.Equal(t, int64(1024), page.Parts[0].Size)
+	assert.Empty(t, page.Parts[0].ChecksumCRC32, "no algorithm, no part checksums")
+}
+
+func TestEtagPartsCount(t *testing.T) {
+	assert.Equal(t, 3, etagPartsCount("d41d8cd98f00b204e9800998ecf8427e-3"))
+	assert.Zero(t, etagPartsCount("d41d8cd98f00b204e9800998ecf8427e"))
+	assert.Zero(t, etagPartsCount("abc-x"))
+}
+
+var attributesHeadColumns = []string{"size_bytes", "etag", "updated_at", "backend_name", "encryption_algorithm",
+	"checksum_algorithm", "checksum_value", "checksum_type", "is_chunked", "part_layout"}
+
+func TestGetObjectAttributes_Multipart(t *testing.T) {
+	s, mock := newPolicyTestServer(t)
+	ctx := tenant.WithTenant(context.Background(), &tenant.Tenant{ID: "tenant-1"})
+
+	layout := encodePartLayout([]partRecord{
+		{PartNumber: 1, Size: 5 << 20, Checksum: "AAAAAA=="},
+		{PartNumber: 2, Size: 100, Checksum: "BBBBBB=="},
+	})
+	mock.ExpectQuery(`SELECT versioning_status FROM buckets`).
+		WillReturnRows(sqlmock.NewRows([]string{"versioning_status"}).AddRow("disabled"))
+	mock.ExpectQuery(`FROM object_head_cache`).
+		WithArgs("tenant-1", "b", "big.bin").
+		WillReturnRows(sqlmock.NewRows(attributesHeadColumns).
+			AddRow(5<<20+100, "abc-2", time.Now(), "", "", checksumCRC32, "ZZZZZZ==-2", checksumTypeComposite, false, layout))
+
+	r := httptest.NewRequest("GET", "/b/big.bin?attributes", nil).WithContext(ctx)
+	r.Header.Set("x-amz-object-attributes", "ETag,Checksum,ObjectParts,StorageClass,ObjectSize")
+	r.Header.Set("x-amz-max-parts", "1")
+	w := httptest.NewRecorder()
+	s.handleGetObjectAttributes(w, r, &S3Request{Bucket: "b", Object: "big.bin"})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.NotEmpty(t, w.Header().Get("Last-Modified"))
+
+	var resp GetObjectAttributesResponse
+	require.NoError(t, xml.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "abc-2", resp.ETag)
+	require.NotNil(t, resp.Checksum)
+	assert.Equal(t, "ZZZZZZ==-2", resp.Checksum.ChecksumCRC32)
+	assert.Equal(t, checksumTypeComposite, resp.Checksum.ChecksumType)
+	assert.Equal(t, "STANDARD", resp.StorageClass)
+	require.NotNil(t, resp.ObjectSize)
+	assert.Equal(t, int64(5<<20+100), *resp.ObjectSize)
+	require.NotNil(t, resp.ObjectParts)
+	assert.Equal(t, 2, resp.ObjectParts.TotalPartsCount)
+	assert.True(t, resp.ObjectParts.IsTruncated)
+	require.Len(t, resp.ObjectParts.Parts, 1)
+	assert.Equal(t, "AAAAAA==", resp.ObjectParts.Parts[0].ChecksumCRC32)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetObjectAttributes_ChunkedManifest(t *testing.T) {
+	s, mock := newPolicyTestServer(t)
+	ctx := tenant.WithTenant(context.Background(), &tenant.Tenant{ID: "tenant-1"})
+
+	mock.ExpectQuery(`SELECT versioning_status FROM buckets`).
+		WillReturnRows(sqlmock.NewRows([]string{"versioning_status"}).AddRow("disabled"))
+	mock.ExpectQuery(`FROM object_head_cache`).
+		WillReturnRows(sqlmock.NewRows(attributesHeadColumns).
+			AddRow(250, "abc", time.Now(), "", "", nil, nil, nil, true, nil))
+	mock.ExpectQuery(`SELECT chunk_offset FROM tenant_chunk_refs`).
+		WithArgs("tenant-1", "b", "dedup.bin").
+		WillReturnRows(sqlmock.NewRows([]string{"chunk_offset"}).AddRow(0).AddRow(100).AddRow(200))
+
+	r := httptest.NewRequest("GET", "/b/dedup.bin?attributes", nil).WithContext(ctx)
+	r.Header.Set("x-amz-object-attributes", "ObjectParts")
+	w := httptest.NewRecorder()
+	s.handleGetObjectAttributes(w, r, &S3Request{Bucket: "b", Object: "dedup.bin"})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var resp GetObjectAttributesResponse
+	require.NoError(t, xml.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Empty(t, resp.ETag, "only requested attributes are returned")
+	require.NotNil(t, resp.ObjectParts)
+	require.Len(t, resp.ObjectParts.Parts, 3)
+	assert.Equal(t, int64(100), resp.ObjectParts.Parts[1].Size)
+	assert.Equal(t, int64(50), resp.ObjectParts.Parts[2].Size)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetObjectAttributes_Versions(t *testing.T) {
+	s, mock := newPolicyTestServer(t)
+	ctx := tenant.WithTenant(context.Background(), &tenant.Tenant{ID: "tenant-1"})
+	versionColumns := []string{"is_delete_marker", "is_latest", "etag", "size_bytes", "created_at", "backend_name"}
+
+	// An older version answers from object_versions alone.
+	mock.ExpectQuery(`FROM object_versions`).
+		WithArgs("tenant-1", "b", "k", "v1").
+		WillReturnRows(sqlmock.NewRows(versionColumns).AddRow(false, false, "old-4", 42, time.Now(), "lyve"))
+	r := httptest.NewRequest("GET", "/b/k?attributes&versionId=v1", nil).WithContext(ctx)
+	r.Header.Set("x-amz-object-attributes", "ETag,ObjectSize,ObjectParts")
+	w := httptest.NewRecorder()
+	s.handleGetObjectAttributes(w, r, &S3Request{Bucket: "b", Object: "k"})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, "v1", w.Header().Get("x-amz-version-id"))
+	var resp GetObjectAttributesResponse
+	require.NoError(t, xml.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "old-4", resp.ETag)
+	require.NotNil(t, resp.ObjectParts)
+	assert.Equal(t, 4, resp.ObjectParts.TotalPartsCount)
+	assert.Empty(t, resp.ObjectParts.Parts)
+
+	// A delete marker is reported as such.
+	mock.ExpectQuery(`FROM object_versions`).
+		WillReturnRows(sqlmock.NewRows(versionColumns).AddRow(true, true, "", 0, time.Now(), nil))
+	mock.ExpectQuery(`SELECT object_key FROM object_head_cache`).
+		WillReturnError(sql.ErrNoRows)
+	r = httptest.NewRequest("GET", "/b/k?attributes&versionId=dm", nil).WithContext(ctx)
+	r.Header.Set("x-amz-object-attributes", "ETag")
+	w = httptest.NewRecorder()
+	s.handleGetObjectAttributes(w, r, &S3Request{Bucket: "b", Object: "k"})
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, "true", w.Header().Get("x-amz-delete-marker"))
+
+	// An unknown version.
+	mock.ExpectQuery(`FROM object_versions`).WillReturnError(sql.ErrNoRows)
+	r = httptest.NewRequest("GET", "/b/k?attributes&versionId=nope", nil).WithContext(ctx)
+	r.Header.Set("x-amz-object-attributes", "ETag")
+	w = httptest.NewRecorder()
+	s.handleGetObjectAttributes(w, r, &S3Request{Bucket: "b", Object: "k"})
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Contains(t, w.Body.String(), "<Code>"+ErrNoSuchVersion+"</Code>")
+}
+
+func TestGetObjectAttributes_RequiresAttributes(t *testing.T) {
+	s, _ := newPolicyTestServer(t)
+	ctx := tenant.WithTenant(context.Background(), &tenant.Tenant{ID: "tenant-1"})
+	r := httptest.NewRequest("GET", "/b/k?attributes", nil).WithContext(ctx)
+	w := httptest.NewRecorder()
+	s.handleGetObjectAttributes(w, r, &S3Request{Bucket: "b", Object: "k"})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "<Code>"+ErrInvalidArgument+"</Code>")
+}
+
+func TestDetermineOperation_GetObjectAttributes(t *testing.T) {
+	req := &S3Request{Bucket: "b", Object: "k", Query: map[string]string{"attributes": ""}}
+	NewS3Parser(zap.NewNop()).determineOperation(req, "GET")
+	assert.Equal(t, "GetObjectAttributes", req.Operation)
+}
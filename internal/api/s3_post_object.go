@@ -0,0 +1,526 @@
+package api
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/FairForge/vaultaire/internal/auth"
+	"github.com/FairForge/vaultaire/internal/tenant"
+	"go.uber.org/zap"
+)
+
+const (
+	// postObjectMaxFieldBytes bounds the form fields that precede the file.
+	postObjectMaxFieldBytes = 1 << 20
+	// postObjectMaxSize is the largest file a single POST may upload.
+	postObjectMaxSize = 5 << 30
+)
+
+// postObjectHeaderFields are form fields copied onto the stored object as
+// if they had been sent as PUT headers. Fields starting with one of
+// postObjectHeaderPrefixes are copied as well.
+var postObjectHeaderFields = []string{
+	"content-type", "cache-control", "content-disposition", "content-encoding", "expires",
+}
+
+var postObjectHeaderPrefixes = []string{
+	"x-amz-meta-", "x-amz-server-side-encryption", "x-amz-storage-class", "x-amz-checksum-",
+}
+
+// postObjectError is a POST Object failure carrying its S3 error code.
+type postObjectError struct {
+	code    string
+	message string
+}
+
+func (e *postObjectError) Error() string { return e.code + ": " + e.message }
+
+func postObjectErr(code, format string, args ...any) error {
+	return &postObjectError{code: code, message: fmt.Sprintf(format, args...)}
+}
+
+func writePostObjectError(w http.ResponseWriter, r *http.Request, err error) {
+	var pe *postObjectError
+	if errors.As(err, &pe) {
+		if pe.message == "" {
+			WriteS3Error(w, pe.code, r.URL.Path, generateRequestID())
+			return
+		}
+		WriteS3ErrorWithContext(w, pe.code, r.URL.Path, generateRequestID(), WithSuggestion(pe.message))
+		return
+	}
+	WriteS3Error(w, ErrInternalError, r.URL.Path, generateRequestID())
+}
+
+// postObjectForm is a parsed browser upload form. Field names are
+// case-insensitive and stored lower-cased; file is left unread so the
+// upload can be streamed.
+type postObjectForm struct {
+	fields   map[string]string
+	file     io.Reader
+	filename string
+}
+
+func (f *postObjectForm) get(name string) string { return f.fields[strings.ToLower(name)] }
+
+// objectKey is the key field with ${filename} expanded to the uploaded
+// file's name, minus any directory part some browsers include.
+func (f *postObjectForm) objectKey() string {
+	filename := f.filename
+	if i := strings.LastIndexAny(filename, `/\`); i >= 0 {
+		filename = filename[i+1:]
+	}
+	return strings.ReplaceAll(f.get("key"), "${filename}", filename)
+}
+
+// isPostObjectRequest reports whether r is a browser form upload: an
+// unsigned multipart/form-data POST to a bucket URL.
+func isPostObjectRequest(r *http.Request) bool {
+	if r.Method != http.MethodPost || r.Header.Get("Authorization") != "" || isPresignedRequest(r) {
+		return false
+	}
+	path := strings.Trim(r.URL.Path, "/")
+	if path == "" || strings.Contains(path, "/") {
+		return false
+	}
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return err == nil && mediaType == "multipart/form-data"
+}
+
+// readPostObjectForm reads the form fields up to the file part. As in S3,
+// the file must be the last field; anything after it is ignored.
+func readPostObjectForm(r *http.Request) (*postObjectForm, error) {
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return nil, postObjectErr(ErrMalformedPOSTRequest, "")
+	}
+	form := &postObjectForm{fields: make(map[string]string)}
+	total := 0
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			return nil, postObjectErr(ErrIncorrectNumberOfFiles, "")
+		}
+		if err != nil {
+			return nil, postObjectErr(ErrMalformedPOSTRequest, "")
+		}
+		name := strings.ToLower(part.FormName())
+		if name == "" {
+			continue
+		}
+		if name == "file" {
+			form.file = part
+			form.filename = part.FileName()
+			return form, nil
+		}
+		value, err := io.ReadAll(io.LimitReader(part, int64(postObjectMaxFieldBytes-total+1)))
+		if err != nil {
+			return nil, postObjectErr(ErrMalformedPOSTRequest, "")
+		}
+		if total += len(value); total > postObjectMaxFieldBytes {
+			return nil, postObjectErr(ErrMaxPostPreDataLengthExceeded, "")
+		}
+		if _, dup := form.fields[name]; dup {
+			return nil, postObjectErr(ErrInvalidArgument, "The form field %q appears more than once.", part.FormName())
+		}
+		form.fields[name] = string(value)
+	}
+}
+
+// verifyPostObjectSignature authenticates a form upload: the SigV4
+// signature is an HMAC of the base64 policy document with the signing key
+// of the credential's secret.
+func (s *Server) verifyPostObjectSignature(form *postObjectForm) (string, *auth.KeyScope, error) {
+	if form.get("policy") == "" {
+		return "", nil, postObjectErr(ErrAccessDenied, "Anonymous uploads are not allowed. Include a signed policy.")
+	}
+	algorithm := form.get("x-amz-algorithm")
+	if algorithm == "" && form.get("awsaccesskeyid") != "" {
+		return "", nil, postObjectErr(ErrInvalidArgument, "Signature Version 2 policies are not supported. Sign with %s.", presignAlgorithm)
+	}
+	for _, field := range []string{"X-Amz-Algorithm", "X-Amz-Credential", "X-Amz-Date", "X-Amz-Signature"} {
+		if form.get(field) == "" {
+			return "", nil, postObjectErr(ErrInvalidArgument, "Bucket POST must contain a field named '%s'.", field)
+		}
+	}
+	if algorithm != presignAlgorithm {
+		return "", nil, postObjectErr(ErrInvalidArgument, "Only the %s algorithm is supported.", presignAlgorithm)
+	}
+
+	credParts := strings.Split(form.get("x-amz-credential"), "/")
+	if len(credParts) != 5 || credParts[3] != presignService || credParts[4] != presignAWS4Request {
+		return "", nil, postObjectErr(ErrInvalidArgument, "The X-Amz-Credential field is malformed.")
+	}
+	accessKey, credDate, region := credParts[0], credParts[1], credParts[2]
+	if !strings.HasPrefix(form.get("x-amz-date"), credDate) {
+		return "", nil, postObjectErr(ErrInvalidArgument, "The X-Amz-Date field does not match the credential date.")
+	}
+
+	if s.db == nil {
+		return "", nil, postObjectErr(ErrAccessDenied, "")
+	}
+	secretKey, tenantID, scope, err := s.lookupSigningCredentials(accessKey)
+	if err != nil {
+		return "", nil, postObjectErr(err.Error(), "")
+	}
+
+	signingKey := presignDeriveKey(secretKey, credDate, region)
+	expectedSig := hex.EncodeToString(presignHMAC(signingKey, []byte(form.get("policy"))))
+	if !hmac.Equal([]byte(expectedSig), []byte(strings.ToLower(form.get("x-amz-signature")))) {
+		return "", nil, postObjectErr(ErrSignatureDoesNotMatch, "")
+	}
+	if err := scope.CheckSessionToken(form.get("x-amz-security-token")); err != nil {
+		return "", nil, postObjectErr(ErrInvalidToken, "")
+	}
+	return tenantID, scope, nil
+}
+
+// postPolicy is a decoded POST policy document.
+type postPolicy struct {
+	expiration time.Time
+	conditions []postPolicyCondition
+	hasRange   bool
+	minSize    int64
+	maxSize    int64
+}
+
+// postPolicyCondition is an "eq" or "starts-with" condition on a form
+// field (lower-cased, without the leading "$").
+type postPolicyCondition struct {
+	op    string
+	field string
+	value string
+}
+
+func (c postPolicyCondition) String() string {
+	return fmt.Sprintf(`["%s", "$%s", "%s"]`, c.op, c.field, c.value)
+}
+
+func parsePostPolicy(encoded string) (*postPolicy, error) {
+	raw, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, postObjectErr(ErrInvalidPolicyDocument, "Invalid Policy: the policy is not valid base64.")
+	}
+	var doc struct {
+		Expiration string            `json:"expiration"`
+		Conditions []json.RawMessage `json:"conditions"`
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	if err := dec.Decode(&doc); err != nil {
+		return nil, postObjectErr(ErrInvalidPolicyDocument, "Invalid Policy: the policy is not valid JSON.")
+	}
+	p := &postPolicy{}
+	if p.expiration, err = time.Parse(time.RFC3339, doc.Expiration); err != nil {
+		return nil, postObjectErr(ErrInvalidPolicyDocument, "Invalid Policy: Invalid 'expiration' value: %q.", doc.Expiration)
+	}
+	for _, rawCond := range doc.Conditions {
+		if err := p.addCondition(rawCond); err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+func (p *postPolicy) addCondition(raw json.RawMessage) error {
+	invalid := postObjectErr(ErrInvalidPolicyDocument, "Invalid Policy: Invalid Simple-Condition: %s.", raw)
+
+	// {"field": "value"} is shorthand for an exact match.
+	var simple map[string]any
+	if err := json.Unmarshal(raw, &simple); err == nil {
+		if len(simple) != 1 {
+			return invalid
+		}
+		for field, v := range simple {
+			value, ok := v.(string)
+			if !ok {
+				return invalid
+			}
+			p.conditions = append(p.conditions, postPolicyCondition{
+				op: "eq", field: strings.ToLower(strings.TrimPrefix(field, "$")), value: value,
+			})
+		}
+		return nil
+	}
+
+	var list []json.RawMessage
+	if err := json.Unmarshal(raw, &list); err != nil || len(list) != 3 {
+		return invalid
+	}
+	var op string
+	if err := json.Unmarshal(list[0], &op); err != nil {
+		return invalid
+	}
+	switch op = strings.ToLower(op); op {
+	case "eq", "starts-with":
+		var field, value string
+		if json.Unmarshal(list[1], &field) != nil || json.Unmarshal(list[2], &value) != nil ||
+			!strings.HasPrefix(field, "$") {
+			return invalid
+		}
+		p.conditions = append(p.conditions, postPolicyCondition{
+			op: op, field: strings.ToLower(field[1:]), value: value,
+		})
+	case "content-length-range":
+		var minSize, maxSize int64
+		if json.Unmarshal(list[1], &minSize) != nil || json.Unmarshal(list[2], &maxSize) != nil ||
+			minSize < 0 || maxSize < minSize {
+			return invalid
+		}
+		p.hasRange, p.minSize, p.maxSize = true, minSize, maxSize
+	default:
+		return invalid
+	}
+	return nil
+}
+
+// postPolicyExempt reports whether a form field needs no policy condition.
+func postPolicyExempt(field string) bool {
+	switch field {
+	case "policy", "x-amz-signature", "file":
+		return true
+	}
+	return strings.HasPrefix(field, "x-ignore-")
+}
+
+// check verifies the policy's expiration and every condition against the
+// form. Every submitted field must be covered by some condition.
+func (p *postPolicy) check(now time.Time, bucket string, form *postObjectForm) error {
+	if now.After(p.expiration) {
+		return postObjectErr(ErrAccessDenied, "Invalid according to Policy: Policy expired.")
+	}
+	covered := make(map[string]bool, len(p.conditions))
+	for _, c := range p.conditions {
+		covered[c.field] = true
+		value, present := form.fields[c.field]
+		if c.field == "bucket" {
+			value, present = bucket, true
+		}
+		var ok bool
+		switch c.op {
+		case "eq":
+			ok = present && value == c.value
+		case "starts-with":
+			ok = present && strings.HasPrefix(value, c.value)
+			if ok && c.field == "content-type" {
+				// Content-Type may list several types, each of which must match.
+				for _, v := range strings.Split(value, ",") {
+					ok = ok && strings.HasPrefix(strings.TrimSpace(v), c.value)
+				}
+			}
+		}
+		if !ok {
+			return postObjectErr(ErrAccessDenied, "Invalid according to Policy: Policy Condition failed: %s", c)
+		}
+	}
+	for field := range form.fields {
+		if !covered[field] && !postPolicyExempt(field) {
+			return postObjectErr(ErrAccessDenied, "Invalid according to Policy: Extra input fields: %s", field)
+		}
+	}
+	return nil
+}
+
+// PostResponse is the body returned for success_action_status=201.
+type PostResponse struct {
+	XMLName  xml.Name `xml:"PostResponse"`
+	Location string   `xml:"Location"`
+	Bucket   string   `xml:"Bucket"`
+	Key      string   `xml:"Key"`
+	ETag     string   `xml:"ETag"`
+}
+
+// bufferedResponseWriter holds a response in memory so the POST handler
+// can turn the PUT handler's result into a form upload response.
+type bufferedResponseWriter struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (b *bufferedResponseWriter) Header() http.Header { return b.header }
+
+func (b *bufferedResponseWriter) WriteHeader(code int) {
+	if b.status == 0 {
+		b.status = code
+	}
+}
+
+func (b *bufferedResponseWriter) Write(p []byte) (int, error) {
+	if b.status == 0 {
+		b.status = http.StatusOK
+	}
+	return b.body.Write(p)
+}
+
+// handlePostObject stores a browser form upload. The form was read (and,
+// outside test mode, its signature verified) before dispatch; here the
+// policy conditions are enforced, the file is spooled to disk to learn its
+// size, and the object is written through the regular PUT path.
+func (s *Server) handlePostObject(w http.ResponseWriter, r *http.Request, req *S3Request, form *postObjectForm) {
+	t, err := tenant.FromContext(r.Context())
+	if err != nil || t == nil {
+		WriteS3Error(w, ErrAccessDenied, r.URL.Path, generateRequestID())
+		return
+	}
+	if form == nil {
+		WriteS3Error(w, ErrNotImplemented, r.URL.Path, generateRequestID())
+		return
+	}
+
+	if form.get("key") == "" {
+		writePostObjectError(w, r, postObjectErr(ErrInvalidArgument, "Bucket POST must contain a field named 'key'."))
+		return
+	}
+
+	minSize, maxSize := int64(0), int64(postObjectMaxSize)
+	if encoded := form.get("policy"); encoded != "" {
+		policy, err := parsePostPolicy(encoded)
+		if err == nil {
+			err = policy.check(time.Now().UTC(), req.Bucket, form)
+		}
+		if err != nil {
+			writePostObjectError(w, r, err)
+			return
+		}
+		if policy.hasRange {
+			minSize = policy.minSize
+			maxSize = min(policy.maxSize, maxSize)
+		}
+	}
+
+	objectKey := form.objectKey()
+
+	spool, err := os.CreateTemp("", "vaultaire-post-*")
+	if err != nil {
+		s.logger.Error("POST object: failed to create spool file", zap.Error(err))
+		WriteS3Error(w, ErrInternalError, r.URL.Path, generateRequestID())
+		return
+	}
+	defer func() {
+		_ = spool.Close()
+		_ = os.Remove(spool.Name())
+	}()
+
+	size, err := io.Copy(spool, io.LimitReader(form.file, maxSize+1))
+	if err != nil {
+		WriteS3Error(w, ErrIncompleteBody, r.URL.Path, generateRequestID())
+		return
+	}
+	if size > maxSize {
+		WriteS3ErrorWithContext(w, ErrEntityTooLarge, r.URL.Path, generateRequestID(),
+			WithSuggestion(fmt.Sprintf("The upload may be at most %d bytes.", maxSize)))
+		return
+	}
+	if size < minSize {
+		WriteS3ErrorWithContext(w, ErrEntityTooSmall, r.URL.Path, generateRequestID(),
+			WithSuggestion(fmt.Sprintf("The upload must be at least %d bytes.", minSize)))
+		return
+	}
+	if _, err := spool.Seek(0, io.SeekStart); err != nil {
+		s.logger.Error("POST object: failed to rewind spool file", zap.Error(err))
+		WriteS3Error(w, ErrInternalError, r.URL.Path, generateRequestID())
+		return
+	}
+
+	putReq := r.Clone(r.Context())
+	putReq.Method = http.MethodPut
+	putReq.URL = &url.URL{Path: "/" + req.Bucket + "/" + objectKey}
+	putReq.Body = io.NopCloser(spool)
+	putReq.ContentLength = size
+	putReq.Header = make(http.Header)
+	for field, value := range form.fields {
+		copyField := false
+		for _, h := range postObjectHeaderFields {
+			copyField = copyField || field == h
+		}
+		for _, prefix := range postObjectHeaderPrefixes {
+			copyField = copyField || strings.HasPrefix(field, prefix)
+		}
+		if copyField {
+			putReq.Header.Set(field, value)
+		}
+	}
+	if acl := form.get("acl"); acl != "" {
+		putReq.Header.Set("x-amz-acl", acl)
+	}
+	putReq.Header.Set("Content-Length", strconv.FormatInt(size, 10))
+
+	putS3Req := *req
+	putS3Req.Operation = "PutObject"
+	putS3Req.Object = objectKey
+	putS3Req.Method = http.MethodPut
+
+	rec := &bufferedResponseWriter{header: make(http.Header)}
+	s.handlePutObject(rec, putReq, &putS3Req)
+
+	for name, values := range rec.header {
+		if name != "Content-Length" {
+			w.Header()[name] = values
+		}
+	}
+	if rec.status >= http.StatusMultipleChoices {
+		w.WriteHeader(rec.status)
+		_, _ = w.Write(rec.body.Bytes())
+		return
+	}
+	w.Header().Del("Content-Type")
+
+	etag := rec.header.Get("ETag")
+	s.logger.Info("POST object stored",
+		zap.String("tenant_id", t.ID),
+		zap.String("bucket", req.Bucket),
+		zap.String("key", objectKey),
+		zap.Int64("size", size))
+
+	redirect := form.get("success_action_redirect")
+	if redirect == "" {
+		redirect = form.get("redirect")
+	}
+	if redirect != "" {
+		if u, err := url.Parse(redirect); err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "" {
+			q := u.Query()
+			q.Set("bucket", req.Bucket)
+			q.Set("key", objectKey)
+			q.Set("etag", etag)
+			u.RawQuery = q.Encode()
+			w.Header().Set("Location", u.String())
+			w.WriteHeader(http.StatusSeeOther)
+			return
+		}
+	}
+
+	location := s.getBaseURL() + uriEncodePath("/"+req.Bucket+"/"+objectKey)
+	w.Header().Set("Location", location)
+	switch form.get("success_action_status") {
+	case "200":
+		w.WriteHeader(http.StatusOK)
+	case "201":
+		w.Header().Set("Content-Type", "application/xml")
+		w.WriteHeader(http.StatusCreated)
+		if err := xml.NewEncoder(w).Encode(PostResponse{
+			Location: location,
+			Bucket:   req.Bucket,
+			Key:      objectKey,
+			ETag:     etag,
+		}); err != nil {
+			s.logger.Error("failed to encode POST object response", zap.Error(err))
+		}
+	default:
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
@@ -0,0 +1,371 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// postObjectRequest builds a browser-style form upload to bucket.
+func postObjectRequest(t *testing.T, bucket string, fields map[string]string, filename string, content []byte) *http.Request {
+	t.Helper()
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		require.NoError(t, mw.WriteField(name, fields[name]))
+	}
+	fw, err := mw.CreateFormFile("file", filename)
+	require.NoError(t, err)
+	_, err = fw.Write(content)
+	require.NoError(t, err)
+	require.NoError(t, mw.Close())
+
+	req := httptest.NewRequest("POST", "/"+bucket, &buf)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	return req
+}
+
+func encodePolicy(t *testing.T, expiration time.Time, conditions ...any) string {
+	t.Helper()
+	doc, err := json.Marshal(map[string]any{
+		"expiration": expiration.UTC().Format("2006-01-02T15:04:05.000Z"),
+		"conditions": conditions,
+	})
+	require.NoError(t, err)
+	return base64.StdEncoding.EncodeToString(doc)
+}
+
+func TestPostPolicyCheck(t *testing.T) {
+	future := time.Now().Add(time.Hour)
+	form := &postObjectForm{fields: map[string]string{
+		"key":          "uploads/photo.jpg",
+		"content-type": "image/jpeg",
+		"x-amz-meta-a": "1",
+	}}
+	tests := []struct {
+		name       string
+		expiration time.Time
+		conditions []any
+		wantErr    string
+	}{
+		{"all covered", future, []any{
+			map[string]string{"bucket": "photos"},
+			[]any{"starts-with", "$key", "uploads/"},
+			[]any{"starts-with", "$Content-Type", "image/"},
+			[]any{"eq", "$x-amz-meta-a", "1"},
+		}, ""},
+		{"expired", time.Now().Add(-time.Minute), []any{}, "Policy expired"},
+		{"wrong bucket", future, []any{map[string]string{"bucket": "other"}}, "Policy Condition failed"},
+		{"prefix mismatch", future, []any{
+			[]any{"starts-with", "$key", "private/"},
+		}, "Policy Condition failed"},
+		{"extra field", future, []any{
+			[]any{"starts-with", "$key", ""},
+			[]any{"starts-with", "$content-type", ""},
+		}, "Extra input fields: x-amz-meta-a"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy, err := parsePostPolicy(encodePolicy(t, tt.expiration, tt.conditions...))
+			require.NoError(t, err)
+			err = policy.check(time.Now(), "photos", form)
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
+
+func TestParsePostPolicy_Invalid(t *testing.T) {
+	future := time.Now().Add(time.Hour)
+	for name, encoded := range map[string]string{
+		"not base64":    "%%%",
+		"not json":      base64.StdEncoding.EncodeToString([]byte("{")),
+		"bad range":     encodePolicy(t, future, []any{"content-length-range", 10, 1}),
+		"unknown op":    encodePolicy(t, future, []any{"ends-with", "$key", "x"}),
+		"no expiration": base64.StdEncoding.EncodeToString([]byte(`{"conditions":[]}`)),
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := parsePostPolicy(encoded)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), ErrInvalidPolicyDocument)
+		})
+	}
+
+	policy, err := parsePostPolicy(encodePolicy(t, future, []any{"content-length-range", 1, 100}))
+	require.NoError(t, err)
+	assert.True(t, policy.hasRange)
+	assert.Equal(t, int64(1), policy.minSize)
+	assert.Equal(t, int64(100), policy.maxSize)
+}
+
+func TestPostObject_Upload(t *testing.T) {
+	srv, tnt, _ := newTestMultipartServer(t)
+
+	_, fields, _ := generatePresignedPost("http://localhost:8000", testAccessKey, testSecretKey, "test-bucket",
+		presignedPostOptions{key: "uploads/", keyIsPrefix: true, expiresSec: 300, maxSize: 1024, contentType: "text/plain"})
+
+	content := []byte("hello from the browser")
+	w := doS3RequestWith(srv, tnt, postObjectRequest(t, "test-bucket", fields, `C:\docs\note.txt`, content))
+	require.Equal(t, http.StatusNoContent, w.Code, w.Body.String())
+	assert.NotEmpty(t, w.Header().Get("ETag"))
+	assert.Contains(t, w.Header().Get("Location"), "/test-bucket/uploads/note.txt")
+
+	getW := doS3Request(srv, tnt, "GET", "/test-bucket/uploads/note.txt", nil)
+	require.Equal(t, http.StatusOK, getW.Code)
+	assert.Equal(t, content, getW.Body.Bytes())
+}
+
+func TestPostObject_SuccessActions(t *testing.T) {
+	srv, tnt, _ := newTestMultipartServer(t)
+
+	t.Run("201 returns a PostResponse", func(t *testing.T) {
+		_, fields, _ := generatePresignedPost("http://localhost:8000", testAccessKey, testSecretKey, "test-bucket",
+			presignedPostOptions{key: "a.txt", expiresSec: 300, successActionStatus: "201"})
+		w := doS3RequestWith(srv, tnt, postObjectRequest(t, "test-bucket", fields, "a.txt", []byte("a")))
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+		var resp PostResponse
+		require.NoError(t, xml.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Equal(t, "test-bucket", resp.Bucket)
+		assert.Equal(t, "a.txt", resp.Key)
+		assert.NotEmpty(t, resp.ETag)
+	})
+
+	t.Run("redirect", func(t *testing.T) {
+		_, fields, _ := generatePresignedPost("http://localhost:8000", testAccessKey, testSecretKey, "test-bucket",
+			presignedPostOptions{key: "b.txt", expiresSec: 300, successActionRedirect: "https://app.example.com/done?x=1"})
+		w := doS3RequestWith(srv, tnt, postObjectRequest(t, "test-bucket", fields, "b.txt", []byte("b")))
+		require.Equal(t, http.StatusSeeOther, w.Code, w.Body.String())
+
+		loc, err := url.Parse(w.Header().Get("Location"))
+		require.NoError(t, err)
+		assert.Equal(t, "app.example.com", loc.Host)
+		assert.Equal(t, "1", loc.Query().Get("x"))
+		assert.Equal(t, "test-bucket", loc.Query().Get("bucket"))
+		assert.Equal(t, "b.txt", loc.Query().Get("key"))
+		assert.NotEmpty(t, loc.Query().Get("etag"))
+	})
+}
+
+func TestPostObject_Rejections(t *testing.T) {
+	srv, tnt, _ := newTestMultipartServer(t)
+	future := time.Now().Add(time.Hour)
+
+	t.Run("too large", func(t *testing.T) {
+		_, fields, _ := generatePresignedPost("http://localhost:8000", testAccessKey, testSecretKey, "test-bucket",
+			presignedPostOptions{key: "big.bin", expiresSec: 300, maxSize: 4})
+		w := doS3RequestWith(srv, tnt, postObjectRequest(t, "test-bucket", fields, "big.bin", []byte("too many bytes")))
+		assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+		assert.Contains(t, w.Body.String(), ErrEntityTooLarge)
+
+		getW := doS3Request(srv, tnt, "GET", "/test-bucket/big.bin", nil)
+		assert.Equal(t, http.StatusNotFound, getW.Code)
+	})
+
+	t.Run("too small", func(t *testing.T) {
+		fields := map[string]string{
+			"key":    "small.bin",
+			"policy": encodePolicy(t, future, map[string]string{"key": "small.bin"}, []any{"content-length-range", 10, 100}),
+		}
+		w := doS3RequestWith(srv, tnt, postObjectRequest(t, "test-bucket", fields, "small.bin", []byte("x")))
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), ErrEntityTooSmall)
+	})
+
+	t.Run("uncovered field", func(t *testing.T) {
+		fields := map[string]string{
+			"key":          "c.txt",
+			"x-amz-meta-z": "1",
+			"policy":       encodePolicy(t, future, map[string]string{"key": "c.txt"}),
+		}
+		w := doS3RequestWith(srv, tnt, postObjectRequest(t, "test-bucket", fields, "c.txt", []byte("c")))
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Contains(t, w.Body.String(), "Extra input fields")
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		var buf bytes.Buffer
+		mw := multipart.NewWriter(&buf)
+		require.NoError(t, mw.WriteField("key", "d.txt"))
+		require.NoError(t, mw.Close())
+		req := httptest.NewRequest("POST", "/test-bucket", &buf)
+		req.Header.Set("Content-Type", mw.FormDataContentType())
+
+		w := doS3RequestWith(srv, tnt, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), ErrIncorrectNumberOfFiles)
+	})
+}
+
+func TestIsPostObjectRequest(t *testing.T) {
+	form := func(method, path string) *http.Request {
+		r := httptest.NewRequest(method, path, nil)
+		r.Header.Set("Content-Type", "multipart/form-data; boundary=x")
+		return r
+	}
+	assert.True(t, isPostObjectRequest(form("POST", "/bucket")))
+	assert.True(t, isPostObjectRequest(form("POST", "/bucket/")))
+	assert.False(t, isPostObjectRequest(form("POST", "/bucket/key")))
+	assert.False(t, isPostObjectRequest(form("PUT", "/bucket")))
+
+	signed := form("POST", "/bucket")
+	signed.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential=x")
+	assert.False(t, isPostObjectRequest(signed))
+
+	plain := httptest.NewRequest("POST", "/bucket", strings.NewReader("x"))
+	plain.Header.Set("Content-Type", "application/xml")
+	assert.False(t, isPostObjectRequest(plain))
+}
+
+func TestVerifyPostObjectSignature(t *testing.T) {
+	_, fields, _ := generatePresignedPost("http://localhost:8000", testAccessKey, testSecretKey, "bucket",
+		presignedPostOptions{key: "k", expiresSec: 300})
+	formOf := func(fields map[string]string) *postObjectForm {
+		f := &postObjectForm{fields: map[string]string{}}
+		for k, v := range fields {
+			f.fields[strings.ToLower(k)] = v
+		}
+		return f
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		s, mock, cleanup := newMockDB(t)
+		defer cleanup()
+		expectTenantLookup(mock)
+
+		tenantID, scope, err := s.verifyPostObjectSignature(formOf(fields))
+		require.NoError(t, err)
+		assert.Equal(t, testPresignTenantID, tenantID)
+		require.NotNil(t, scope)
+		assert.True(t, scope.Primary)
+	})
+
+	t.Run("tampered policy", func(t *testing.T) {
+		s, mock, cleanup := newMockDB(t)
+		defer cleanup()
+		expectTenantLookup(mock)
+
+		tampered := formOf(fields)
+		tampered.fields["policy"] = encodePolicy(t, time.Now().Add(time.Hour), []any{"starts-with", "$key", ""})
+		_, _, err := s.verifyPostObjectSignature(tampered)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), ErrSignatureDoesNotMatch)
+	})
+
+	t.Run("unknown key", func(t *testing.T) {
+		s, mock, cleanup := newMockDB(t)
+		defer cleanup()
+		mock.ExpectQuery(`SELECT secret_key, id FROM tenants WHERE access_key`).
+			WithArgs(testAccessKey).
+			WillReturnRows(sqlmock.NewRows([]string{"secret_key", "id"}))
+		mock.ExpectQuery(`SELECT ak.secret_key`).
+			WithArgs(testAccessKey).
+			WillReturnRows(sqlmock.NewRows([]string{"secret_key", "id", "permissions", "bucket_scope", "ip_allowlist", "expires_at"}))
+
+		_, _, err := s.verifyPostObjectSignature(formOf(fields))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), ErrAccessDenied)
+	})
+
+	t.Run("missing fields", func(t *testing.T) {
+		s, _, cleanup := newMockDB(t)
+		defer cleanup()
+
+		partial := formOf(fields)
+		delete(partial.fields, "x-amz-credential")
+		_, _, err := s.verifyPostObjectSignature(partial)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "X-Amz-Credential")
+
+		_, _, err = s.verifyPostObjectSignature(formOf(map[string]string{"key": "k"}))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), ErrAccessDenied)
+	})
+}
+
+func TestHandleGetPresignedPost(t *testing.T) {
+	s, mock, cleanup := newMockDB(t)
+	defer cleanup()
+
+	mock.ExpectQuery(`SELECT access_key, secret_key FROM tenants WHERE id`).
+		WithArgs(testPresignTenantID).
+		WillReturnRows(sqlmock.NewRows([]string{"access_key", "secret_key"}).
+			AddRow(testAccessKey, testSecretKey))
+
+	r := httptest.NewRequest("GET", "/api/v1/presigned/post?bucket=photos&key_prefix=user-1/&max_size=1048576&success_action_status=201", nil)
+	r = r.WithContext(context.WithValue(r.Context(), tenantIDKey, testPresignTenantID))
+	w := httptest.NewRecorder()
+	s.handleGetPresignedPost(w, r)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var resp struct {
+		URL       string            `json:"url"`
+		Fields    map[string]string `json:"fields"`
+		ExpiresAt string            `json:"expires_at"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.True(t, strings.HasSuffix(resp.URL, "/photos"))
+	assert.Equal(t, "user-1/${filename}", resp.Fields["key"])
+	assert.Equal(t, "201", resp.Fields["success_action_status"])
+	assert.Equal(t, presignAlgorithm, resp.Fields["x-amz-algorithm"])
+	assert.NotEmpty(t, resp.Fields["x-amz-signature"])
+
+	// The fields authorize an upload under the prefix.
+	form := &postObjectForm{fields: resp.Fields, filename: "cat.png"}
+	policy, err := parsePostPolicy(resp.Fields["policy"])
+	require.NoError(t, err)
+	require.NoError(t, policy.check(time.Now(), "photos", form))
+	assert.Equal(t, int64(1048576), policy.maxSize)
+	assert.Equal(t, "user-1/cat.png", form.objectKey())
+}
+
+func TestHandleGetPresignedPost_InvalidParams(t *testing.T) {
+	s, _, cleanup := newMockDB(t)
+	defer cleanup()
+
+	for name, query := range map[string]string{
+		"missing key":      "bucket=b",
+		"key and prefix":   "bucket=b&key=k&key_prefix=p/",
+		"bad expires":      "bucket=b&key=k&expires=0",
+		"min above max":    "bucket=b&key=k&min_size=10&max_size=5",
+		"bad status":       "bucket=b&key=k&success_action_status=302",
+		"negative max":     "bucket=b&key=k&max_size=-1",
+		"max above 5 GiB":  "bucket=b&key=k&max_size=6000000000",
+		"missing bucket":   "key=k",
+		"non-numeric size": "bucket=b&key=k&min_size=abc",
+	} {
+		t.Run(name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/api/v1/presigned/post?"+query, nil)
+			r = r.WithContext(context.WithValue(r.Context(), tenantIDKey, testPresignTenantID))
+			w := httptest.NewRecorder()
+			s.handleGetPresignedPost(w, r)
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			body, _ := io.ReadAll(w.Body)
+			assert.Contains(t, string(body), "error")
+		})
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"crypto/hmac"
 	"crypto/sha256"
 	"database/sql"
+	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
@@ -73,10 +74,68 @@ func (s *Server) verifyPresignedURL(r *http.Request) (string, *auth.KeyScope, er
 		return "", nil, fmt.Errorf("%s: database not available", ErrAccessDenied)
 	}
 
-	// Look up credentials and scope. Try primary tenant key first, then scoped API keys.
-	var secretKey, tenantID string
-	var scope *auth.KeyScope
+	secretKey, tenantID, scope, err := s.lookupSigningCredentials(accessKey)
+	if err != nil {
+		return "", nil, err
+	}
+
+	canonicalURI := uriEncodePath(auth.SignedURL(r).Path)
+
+	canonicalQueryString := buildPresignCanonicalQuery(q)
+
+	headers := strings.Split(signedHeaders, ";")
+	sort.Strings(headers)
+	var canonicalHeadersBuf strings.Builder
+	for _, h := range headers {
+		key := strings.ToLower(strings.TrimSpace(h))
+		var val string
+		if key == "host" {
+			val = r.Host
+		} else {
+			val = r.Header.Get(h)
+		}
+		canonicalHeadersBuf.WriteString(key)
+		canonicalHeadersBuf.WriteByte(':')
+		canonicalHeadersBuf.WriteString(strings.TrimSpace(val))
+		canonicalHeadersBuf.WriteByte('\n')
+	}
 
+	canonicalRequest := strings.Join([]string{
+		r.Method,
+		canonicalURI,
+		canonicalQueryString,
+		canonicalHeadersBuf.String(),
+		signedHeaders,
+		"UNSIGNED-PAYLOAD",
+	}, "\n")
+
+	credentialScope := fmt.Sprintf("%s/%s/%s/%s", credDate, region, presignService, presignAWS4Request)
+
+	hash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{
+		presignAlgorithm,
+		amzDate,
+		credentialScope,
+		hex.EncodeToString(hash[:]),
+	}, "\n")
+
+	signingKey := presignDeriveKey(secretKey, credDate, region)
+	expectedSig := hex.EncodeToString(presignHMAC(signingKey, []byte(stringToSign)))
+
+	if !hmac.Equal([]byte(expectedSig), []byte(signature)) {
+		return "", nil, fmt.Errorf("%s", ErrSignatureDoesNotMatch)
+	}
+	if err := scope.CheckSessionToken(q.Get("X-Amz-Security-Token")); err != nil {
+		return "", nil, fmt.Errorf("%s", ErrInvalidToken)
+	}
+
+	return tenantID, scope, nil
+}
+
+// lookupSigningCredentials resolves an access key to its secret, tenant and
+// scope: the tenant's primary key first, then scoped API keys, then STS
+// temporary credentials. Errors carry the S3 error code as their text.
+func (s *Server) lookupSigningCredentials(accessKey string) (secretKey, tenantID string, scope *auth.KeyScope, err error) {
 	err = s.db.QueryRow(
 		`SELECT secret_key, id FROM tenants WHERE access_key = $1`, accessKey,
 	).Scan(&secretKey, &tenantID)
@@ -98,11 +157,12 @@ func (s *Server) verifyPresignedURL(r *http.Request) (string, *auth.KeyScope, er
 		`, accessKey).Scan(&secretKey, &tenantID, &permJSON, &bucketScope, &ipAllowlist, &expiresAtDB)
 		if err == nil {
 			if secretKey == "" {
-				return "", nil, fmt.Errorf("%s", ErrAccessDenied)
+				return "", "", nil, fmt.Errorf("%s", ErrAccessDenied)
 			}
 			scope = &auth.KeyScope{
 				BucketScope: []string(bucketScope),
 				IPAllowlist: []string(ipAllowlist),
+				AccessKeyID: accessKey,
 			}
 			if jsonErr := json.Unmarshal(permJSON, &scope.Permissions); jsonErr != nil {
 				scope.Permissions = []string{"*"}
@@ -115,81 +175,39 @@ func (s *Server) verifyPresignedURL(r *http.Request) (string, *auth.KeyScope, er
 			var stsPermJSON []byte
 			var stsBucketScope, stsIPRestrict pq.StringArray
 			var stsExpiresAt time.Time
+			var parentKeyID, sessionToken string
 			err = s.db.QueryRow(`
-				SELECT secret_key, tenant_id, permissions, bucket_scope, ip_restrict, expires_at
+				SELECT secret_key, tenant_id, permissions, bucket_scope, ip_restrict, expires_at,
+				       COALESCE(parent_key_id, ''), COALESCE(session_token, '')
 				FROM sts_tokens WHERE access_key = $1
-			`, accessKey).Scan(&secretKey, &tenantID, &stsPermJSON, &stsBucketScope, &stsIPRestrict, &stsExpiresAt)
+			`, accessKey).Scan(&secretKey, &tenantID, &stsPermJSON, &stsBucketScope, &stsIPRestrict, &stsExpiresAt, &parentKeyID, &sessionToken)
 			if err != nil {
-				return "", nil, fmt.Errorf("%s", ErrAccessDenied)
+				return "", "", nil, fmt.Errorf("%s", ErrAccessDenied)
 			}
 			if time.Now().After(stsExpiresAt) {
-				return "", nil, fmt.Errorf("%s", ErrExpiredPresignedRequest)
+				return "", "", nil, fmt.Errorf("%s", ErrExpiredPresignedRequest)
 			}
 			scope = &auth.KeyScope{
-				BucketScope: []string(stsBucketScope),
-				IPAllowlist: []string(stsIPRestrict),
-				ExpiresAt:   &stsExpiresAt,
+				BucketScope:  []string(stsBucketScope),
+				IPAllowlist:  []string(stsIPRestrict),
+				ExpiresAt:    &stsExpiresAt,
+				AccessKeyID:  accessKey,
+				ParentKeyID:  parentKeyID,
+				SessionToken: sessionToken,
 			}
 			if jsonErr := json.Unmarshal(stsPermJSON, &scope.Permissions); jsonErr != nil {
 				scope.Permissions = []string{"*"}
 			}
 		} else {
-			return "", nil, fmt.Errorf("%s", ErrAccessDenied)
+			return "", "", nil, fmt.Errorf("%s", ErrAccessDenied)
 		}
 	} else if err != nil {
-		return "", nil, fmt.Errorf("%s", ErrAccessDenied)
+		return "", "", nil, fmt.Errorf("%s", ErrAccessDenied)
 	} else {
-		scope = &auth.KeyScope{Permissions: []string{"*"}}
+		scope = &auth.KeyScope{Permissions: []string{"*"}, AccessKeyID: accessKey, Primary: true}
 	}
 
-	canonicalURI := uriEncodePath(r.URL.Path)
-
-	canonicalQueryString := buildPresignCanonicalQuery(q)
-
-	headers := strings.Split(signedHeaders, ";")
-	sort.Strings(headers)
-	var canonicalHeadersBuf strings.Builder
-	for _, h := range headers {
-		key := strings.ToLower(strings.TrimSpace(h))
-		var val string
-		if key == "host" {
-			val = r.Host
-		} else {
-			val = r.Header.Get(h)
-		}
-		canonicalHeadersBuf.WriteString(key)
-		canonicalHeadersBuf.WriteByte(':')
-		canonicalHeadersBuf.WriteString(strings.TrimSpace(val))
-		canonicalHeadersBuf.WriteByte('\n')
-	}
-
-	canonicalRequest := strings.Join([]string{
-		r.Method,
-		canonicalURI,
-		canonicalQueryString,
-		canonicalHeadersBuf.String(),
-		signedHeaders,
-		"UNSIGNED-PAYLOAD",
-	}, "\n")
-
-	credentialScope := fmt.Sprintf("%s/%s/%s/%s", credDate, region, presignService, presignAWS4Request)
-
-	hash := sha256.Sum256([]byte(canonicalRequest))
-	stringToSign := strings.Join([]string{
-		presignAlgorithm,
-		amzDate,
-		credentialScope,
-		hex.EncodeToString(hash[:]),
-	}, "\n")
-
-	signingKey := presignDeriveKey(secretKey, credDate, region)
-	expectedSig := hex.EncodeToString(presignHMAC(signingKey, []byte(stringToSign)))
-
-	if !hmac.Equal([]byte(expectedSig), []byte(signature)) {
-		return "", nil, fmt.Errorf("%s", ErrSignatureDoesNotMatch)
-	}
-
-	return tenantID, scope, nil
+	return secretKey, tenantID, scope, nil
 }
 
 func buildPresignCanonicalQuery(values url.Values) string {
@@ -304,3 +322,72 @@ func generatePresignedS3URL(baseURL, accessKey, secretKey, bucket, key, method s
 	fullURL := baseURL + path + "?" + q.Encode()
 	return fullURL, expiresAt
 }
+
+// presignedPostOptions are the conditions a generated POST policy enforces.
+type presignedPostOptions struct {
+	key                   string // exact key, or a key prefix when keyIsPrefix
+	keyIsPrefix           bool
+	expiresSec            int
+	minSize               int64
+	maxSize               int64 // 0 means no content-length-range condition
+	contentType           string
+	successActionStatus   string
+	successActionRedirect string
+}
+
+// generatePresignedPost builds a SigV4 POST policy for browser uploads to
+// bucket and returns the form action URL and the fields the form must
+// carry ahead of the file.
+func generatePresignedPost(baseURL, accessKey, secretKey, bucket string, opts presignedPostOptions) (string, map[string]string, time.Time) {
+	now := time.Now().UTC()
+	date := now.Format(presignDateFormat)
+	expiresAt := now.Add(time.Duration(opts.expiresSec) * time.Second)
+	credential := fmt.Sprintf("%s/%s/%s/%s/%s", accessKey, date, presignDefaultRegion, presignService, presignAWS4Request)
+
+	fields := map[string]string{
+		"x-amz-algorithm":  presignAlgorithm,
+		"x-amz-credential": credential,
+		"x-amz-date":       now.Format(presignTimeFormat),
+	}
+	conditions := []any{map[string]string{"bucket": bucket}}
+	if opts.keyIsPrefix {
+		fields["key"] = opts.key + "${filename}"
+		conditions = append(conditions, []any{"starts-with", "$key", opts.key})
+	} else {
+		fields["key"] = opts.key
+		conditions = append(conditions, map[string]string{"key": opts.key})
+	}
+	if opts.maxSize > 0 {
+		conditions = append(conditions, []any{"content-length-range", opts.minSize, opts.maxSize})
+	}
+	for field, value := range map[string]string{
+		"Content-Type":            opts.contentType,
+		"success_action_status":   opts.successActionStatus,
+		"success_action_redirect": opts.successActionRedirect,
+	} {
+		if value != "" {
+			fields[field] = value
+		}
+	}
+	names := make([]string, 0, len(fields))
+	for field := range fields {
+		if field != "key" {
+			names = append(names, field)
+		}
+	}
+	sort.Strings(names)
+	for _, field := range names {
+		conditions = append(conditions, map[string]string{field: fields[field]})
+	}
+
+	doc, _ := json.Marshal(map[string]any{
+		"expiration": expiresAt.Format("2006-01-02T15:04:05.000Z"),
+		"conditions": conditions,
+	})
+	policy := base64.StdEncoding.EncodeToString(doc)
+	fields["policy"] = policy
+	fields["x-amz-signature"] = hex.EncodeToString(
+		presignHMAC(presignDeriveKey(secretKey, date, presignDefaultRegion), []byte(policy)))
+
+	return baseURL + "/" + bucket, fields, expiresAt
+}
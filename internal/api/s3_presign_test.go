@@ -364,19 +364,29 @@ func TestPresignedURL_Integration(t *testing.T) {
 		WithArgs(testPresignTenantID).
 		WillReturnRows(suspendedRow2)
 
+	// Bucket policy lookup (PUT + GET), no policy attached.
+	mock.ExpectQuery(`SELECT policy FROM buckets`).
+		WillReturnRows(sqlmock.NewRows([]string{"policy"}).AddRow(nil))
+	mock.ExpectQuery(`SELECT policy FROM buckets`).
+		WillReturnRows(sqlmock.NewRows([]string{"policy"}).AddRow(nil))
+
 	// HandlePut internals: object_head_cache lookup, versioning check, then the
-	// WP-1 atomicHeadUpsert transaction (Begin → SELECT ... FOR UPDATE →
-	// INSERT → Commit). WP-3 made a failing upsert fail the PUT, so the mock
+	// WP-1 atomicHeadUpsert transaction (Begin → key lock → SELECT ... FOR
+	// UPDATE → INSERT → replication config lookup → Commit). WP-3 made a failing upsert fail the PUT, so the mock
 	// must model the real transactional flow or the handler correctly 500s.
 	mock.ExpectQuery(`SELECT etag FROM object_head_cache`).
 		WillReturnError(sql.ErrNoRows)
 	mock.ExpectQuery(`SELECT versioning_status FROM buckets`).
 		WillReturnError(sql.ErrNoRows)
 	mock.ExpectBegin()
+	mock.ExpectExec(`SELECT pg_advisory_xact_lock`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
 	mock.ExpectQuery(`SELECT size_bytes, is_chunked FROM object_head_cache`).
 		WillReturnError(sql.ErrNoRows)
 	mock.ExpectExec(`INSERT INTO object_head_cache`).
 		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`SELECT replication_config FROM buckets`).
+		WillReturnRows(sqlmock.NewRows([]string{"replication_config"}).AddRow(nil))
 	mock.ExpectCommit()
 
 	// HandleGet internals: versioning check, object_head_cache lookup
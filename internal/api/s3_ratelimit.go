@@ -0,0 +1,104 @@
+package api
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/FairForge/vaultaire/internal/config"
+	"github.com/FairForge/vaultaire/internal/ratelimit"
+)
+
+// newS3OperationLimiter builds the per-tenant S3 operation limits, kept in
+// shared so every replica enforces one limit. It returns nil when no
+// operation is limited.
+func newS3OperationLimiter(limits map[string]config.OperationLimit, shared *ratelimit.Shared) *ratelimit.OperationLimiter {
+	if len(limits) == 0 {
+		return nil
+	}
+	ol := ratelimit.NewOperationLimiter()
+	for op, l := range limits {
+		ol.SetLimit(op, l.Rate, l.Burst)
+	}
+	ol.UseShared(shared)
+	return ol
+}
+
+// newBandwidthLimiter builds the per-tenant object bandwidth limit, kept in
+// shared. It returns nil when bytesPerSecond is not positive.
+func newBandwidthLimiter(bytesPerSecond int64, shared *ratelimit.Shared) *ratelimit.BandwidthLimiter {
+	if bytesPerSecond <= 0 {
+		return nil
+	}
+	return ratelimit.NewBandwidthLimiter(shared, "bandwidth", bytesPerSecond)
+}
+
+// allowS3Operation takes one request from tenantID's limit for op and sets
+// the X-RateLimit headers from it. A throttled request gets SlowDown with
+// Retry-After, and false.
+func (s *Server) allowS3Operation(w http.ResponseWriter, r *http.Request, tenantID, op string) bool {
+	if s.s3OpLimiter == nil {
+		return true
+	}
+	res, limited := s.s3OpLimiter.Take(r.Context(), tenantID, op)
+	if !limited {
+		return true
+	}
+	ratelimit.SetHeaders(w, res.Info(), false)
+	if res.Allowed {
+		return true
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(res.RetryAfterSeconds()))
+	WriteS3ErrorWithContext(w, ErrSlowDown, r.URL.Path, generateRequestID(),
+		WithMessage("Please reduce your request rate."))
+	return false
+}
+
+// throttleIngress limits the rate r's body is read at to tenantID's upload
+// bandwidth.
+func (s *Server) throttleIngress(r *http.Request, tenantID string) {
+	if s.bandwidthLimiter == nil || r.Body == nil || r.Body == http.NoBody {
+		return
+	}
+	r.Body = limitedReadCloser{
+		Reader: s.bandwidthLimiter.Reader(r.Context(), "in:"+tenantID, r.Body),
+		c:      r.Body,
+	}
+}
+
+// throttleEgress returns w with its body writes limited to tenantID's
+// download bandwidth.
+func (s *Server) throttleEgress(ctx context.Context, w http.ResponseWriter, tenantID string) http.ResponseWriter {
+	if s.bandwidthLimiter == nil {
+		return w
+	}
+	return &throttledResponseWriter{
+		ResponseWriter: w,
+		body:           s.bandwidthLimiter.Writer(ctx, "out:"+tenantID, w),
+	}
+}
+
+// egressReader limits the rate r is read at to tenantID's download
+// bandwidth, for handlers that copy a reader to the response.
+func (s *Server) egressReader(ctx context.Context, tenantID string, r io.Reader) io.Reader {
+	if s.bandwidthLimiter == nil {
+		return r
+	}
+	return s.bandwidthLimiter.Reader(ctx, "out:"+tenantID, r)
+}
+
+// throttledResponseWriter sends body writes through a bandwidth limit.
+type throttledResponseWriter struct {
+	http.ResponseWriter
+	body io.Writer
+}
+
+func (tw *throttledResponseWriter) Write(b []byte) (int, error) {
+	return tw.body.Write(b)
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController.
+func (tw *throttledResponseWriter) Unwrap() http.ResponseWriter {
+	return tw.ResponseWriter
+}
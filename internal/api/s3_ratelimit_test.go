@@ -0,0 +1,98 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/FairForge/vaultaire/internal/config"
+	"github.com/FairForge/vaultaire/internal/ratelimit"
+	"github.com/FairForge/vaultaire/internal/tenant"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// newReplicaServers returns two test servers over one engine, each with its
+// own limiters over store, as two replicas behind a load balancer would
+// have.
+func newReplicaServers(t *testing.T, store ratelimit.Store, ops map[string]config.OperationLimit, bandwidth int64) ([]*Server, *tenant.Tenant) {
+	t.Helper()
+	var servers []*Server
+	var tnt *tenant.Tenant
+	for i := 0; i < 2; i++ {
+		srv, tt, _ := newTestMultipartServer(t)
+		shared := ratelimit.NewShared(store, zap.NewNop())
+		srv.s3OpLimiter = newS3OperationLimiter(ops, shared)
+		srv.bandwidthLimiter = newBandwidthLimiter(bandwidth, shared)
+		if len(servers) > 0 {
+			srv.engine = servers[0].engine
+		}
+		servers = append(servers, srv)
+		tnt = tt
+	}
+	return servers, tnt
+}
+
+func TestS3OperationLimit_SharedAcrossServers(t *testing.T) {
+	store := &countingStore{taken: map[string]int64{}}
+	servers, tnt := newReplicaServers(t, store,
+		map[string]config.OperationLimit{"GetObject": {Rate: 1, Burst: 3}}, 0)
+
+	served := 0
+	var throttled *httptest.ResponseRecorder
+	for i := 0; i < 2; i++ {
+		for _, srv := range servers {
+			w := doS3Request(srv, tnt, "GET", "/test-bucket/missing.txt", nil)
+			if w.Code == http.StatusTooManyRequests {
+				throttled = w
+				continue
+			}
+			served++
+			assert.Equal(t, "3", w.Header().Get("X-RateLimit-Limit"))
+		}
+	}
+	assert.Equal(t, 3, served, "both servers together allow one burst")
+	require.NotNil(t, throttled)
+	assert.Equal(t, "1", throttled.Header().Get("Retry-After"))
+	assert.Equal(t, "0", throttled.Header().Get("X-RateLimit-Remaining"))
+
+	var errResp S3Error
+	require.NoError(t, xml.Unmarshal(throttled.Body.Bytes(), &errResp))
+	assert.Equal(t, ErrSlowDown, errResp.Code)
+	assert.Equal(t, "Please reduce your request rate.", errResp.Message)
+
+	// Operations without a limit are not counted or throttled.
+	w := doS3Request(servers[0], tnt, "PUT", "/test-bucket/free.txt", bytes.NewReader([]byte("x")))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get("X-RateLimit-Limit"))
+}
+
+func TestBandwidthLimit_SharedAcrossServers(t *testing.T) {
+	store := &countingStore{taken: map[string]int64{}}
+	servers, tnt := newReplicaServers(t, store, nil, 1000)
+	body := bytes.Repeat([]byte("b"), 800)
+
+	w := doS3Request(servers[0], tnt, "PUT", "/test-bucket/first.bin", bytes.NewReader(body))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, int64(800), store.taken["bandwidth:in:test-tenant"])
+
+	// The other server draws on the same second of upload bandwidth, so an
+	// upload that needs more than is left cannot finish in time.
+	req := httptest.NewRequest("PUT", "/test-bucket/second.bin", bytes.NewReader(body))
+	ctx, cancel := context.WithTimeout(req.Context(), 200*time.Millisecond)
+	defer cancel()
+	w = doS3RequestWith(servers[1], tnt, req.WithContext(ctx))
+	assert.NotEqual(t, http.StatusOK, w.Code)
+	assert.LessOrEqual(t, store.taken["bandwidth:in:test-tenant"], int64(1000))
+
+	// Downloads are limited separately from uploads, through the same store.
+	w = doS3Request(servers[1], tnt, "GET", "/test-bucket/first.bin", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, body, w.Body.Bytes())
+	assert.GreaterOrEqual(t, store.taken["bandwidth:out:test-tenant"], int64(800))
+}
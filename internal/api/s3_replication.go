@@ -0,0 +1,1213 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/FairForge/vaultaire/internal/auth"
+	"github.com/FairForge/vaultaire/internal/common"
+	"github.com/FairForge/vaultaire/internal/crypto"
+	"github.com/FairForge/vaultaire/internal/engine"
+	"github.com/FairForge/vaultaire/internal/tenant"
+	"go.uber.org/zap"
+)
+
+// Bucket replication (?replication) mirrors selected objects of a bucket to
+// another bucket: of the same tenant or, when the destination bucket's
+// policy grants it, of another one. The destination storage class routes
+// replicas to a second backend.
+//
+// Every head-row write in a replicated bucket enqueues its replication jobs
+// in the transaction that writes the row (queueReplication), so a committed
+// object cannot be missed; ReplicationRunner copies them in the background
+// and retries failures. Replicas report REPLICA and are never replicated
+// again, so two buckets may replicate to each other without looping.
+
+const (
+	maxReplicationBodyBytes = 65536
+	maxReplicationRules     = 1000
+	maxReplicationRuleID    = 255
+
+	// replicationBatchSize bounds the jobs one runner cycle claims.
+	replicationBatchSize = 100
+	// replicationMaxAttempts is how often a job is tried before it is
+	// marked failed and its object reports FAILED.
+	replicationMaxAttempts = 8
+	// replicationLease hides a claimed job from other runners; if the
+	// runner dies mid-copy the job becomes due again when it expires.
+	replicationLease = 15 * time.Minute
+	// replicationRetention is how long completed jobs are kept.
+	replicationRetention = 7 * 24 * time.Hour
+)
+
+// x-amz-replication-status values (object_head_cache.replication_status).
+const (
+	replicationPending   = "PENDING"
+	replicationCompleted = "COMPLETED"
+	replicationFailed    = "FAILED"
+	replicationReplica   = "REPLICA"
+)
+
+// ReplicationConfiguration is the S3 XML document for GET/PUT ?replication.
+// Role is kept for compatibility only: the server replicates with its own
+// credentials, authorized by the destination bucket's policy.
+type ReplicationConfiguration struct {
+	XMLName xml.Name          `xml:"ReplicationConfiguration"`
+	Xmlns   string            `xml:"xmlns,attr,omitempty"`
+	Role    string            `xml:"Role,omitempty"`
+	Rules   []ReplicationRule `xml:"Rule"`
+}
+
+// ReplicationRule selects objects and names their destination. Prefix is
+// the legacy (pre-Filter) form. When several enabled rules select an object
+// for the same destination, the one with the highest Priority applies.
+type ReplicationRule struct {
+	ID                      string                   `xml:"ID,omitempty"`
+	Priority                int                      `xml:"Priority,omitempty"`
+	Status                  string                   `xml:"Status"`
+	Prefix                  *string                  `xml:"Prefix"`
+	Filter                  *ReplicationFilter       `xml:"Filter"`
+	DeleteMarkerReplication *DeleteMarkerReplication `xml:"DeleteMarkerReplication,omitempty"`
+	Destination             ReplicationDestination   `xml:"Destination"`
+}
+
+// ReplicationFilter selects the objects a rule applies to. At most one of
+// Prefix, Tag or And may be set; And combines them.
+type ReplicationFilter struct {
+	Prefix *string                 `xml:"Prefix"`
+	Tag    *Tag                    `xml:"Tag,omitempty"`
+	And    *ReplicationAndOperator `xml:"And,omitempty"`
+}
+
+// ReplicationAndOperator is the conjunction form of a replication filter.
+type ReplicationAndOperator struct {
+	Prefix string `xml:"Prefix,omitempty"`
+	Tags   []Tag  `xml:"Tag,omitempty"`
+}
+
+// DeleteMarkerReplication controls whether deletes without a versionId
+// reach the destination: as a delete marker in a versioning-enabled
+// destination bucket, as a delete of the replica otherwise.
+type DeleteMarkerReplication struct {
+	Status string `xml:"Status"`
+}
+
+// ReplicationDestination names the destination bucket by ARN. Account is
+// the destination bucket's tenant ID; empty means the source tenant. An
+// empty StorageClass stores replicas at the destination bucket's tier.
+type ReplicationDestination struct {
+	Bucket       string `xml:"Bucket"`
+	Account      string `xml:"Account,omitempty"`
+	StorageClass string `xml:"StorageClass,omitempty"`
+}
+
+// prefix returns the key prefix a rule applies to ("" matches everything).
+func (rule *ReplicationRule) prefix() string {
+	if rule.Filter != nil {
+		switch {
+		case rule.Filter.And != nil:
+			return rule.Filter.And.Prefix
+		case rule.Filter.Prefix != nil:
+			return *rule.Filter.Prefix
+		}
+		return ""
+	}
+	if rule.Prefix != nil {
+		return *rule.Prefix
+	}
+	return ""
+}
+
+// tags returns the tag conditions a rule requires.
+func (rule *ReplicationRule) tags() []Tag {
+	if rule.Filter == nil {
+		return nil
+	}
+	if rule.Filter.And != nil {
+		return rule.Filter.And.Tags
+	}
+	if rule.Filter.Tag != nil {
+		return []Tag{*rule.Filter.Tag}
+	}
+	return nil
+}
+
+// matches reports whether a key with the given tags satisfies the filter.
+func (rule *ReplicationRule) matches(key string, tags map[string]string) bool {
+	if !strings.HasPrefix(key, rule.prefix()) {
+		return false
+	}
+	for _, tag := range rule.tags() {
+		if v, ok := tags[tag.Key]; !ok || v != tag.Value {
+			return false
+		}
+	}
+	return true
+}
+
+func (rule *ReplicationRule) replicatesDeletes() bool {
+	return rule.DeleteMarkerReplication != nil && rule.DeleteMarkerReplication.Status == "Enabled"
+}
+
+// target returns the destination tenant and bucket; an omitted Account is
+// the source tenant.
+func (d ReplicationDestination) target(sourceTenant string) (tenantID, bucket string) {
+	tenantID = d.Account
+	if tenantID == "" {
+		tenantID = sourceTenant
+	}
+	return tenantID, strings.TrimPrefix(d.Bucket, "arn:aws:s3:::")
+}
+
+// replicationTarget is one destination an object is replicated to and the
+// rule that sends it there.
+type replicationTarget struct {
+	rule     *ReplicationRule
+	tenantID string
+	bucket   string
+}
+
+// targets returns, per destination, the enabled rule that applies to key:
+// the matching rule with the highest Priority, the earliest on a tie. A nil
+// tags map evaluates a delete, which only rules without a tag filter select.
+func (config *ReplicationConfiguration) targets(sourceTenant, key string, tags map[string]string) []replicationTarget {
+	var out []replicationTarget
+	index := make(map[string]int)
+	for i := range config.Rules {
+		rule := &config.Rules[i]
+		if rule.Status != "Enabled" || !rule.matches(key, tags) {
+			continue
+		}
+		tenantID, bucket := rule.Destination.target(sourceTenant)
+		dest := tenantID + "/" + bucket
+		if j, seen := index[dest]; seen {
+			if rule.Priority > out[j].rule.Priority {
+				out[j].rule = rule
+			}
+			continue
+		}
+		index[dest] = len(out)
+		out = append(out, replicationTarget{rule: rule, tenantID: tenantID, bucket: bucket})
+	}
+	return out
+}
+
+// validateReplication enforces the schema rules the XML decoder cannot.
+// The destination buckets are checked separately (authorizeReplication).
+func validateReplication(config *ReplicationConfiguration, tenantID, bucket string) error {
+	if len(config.Rules) == 0 {
+		return errors.New("at least one replication rule is required")
+	}
+	if len(config.Rules) > maxReplicationRules {
+		return fmt.Errorf("a replication configuration may contain at most %d rules", maxReplicationRules)
+	}
+	seen := make(map[string]bool, len(config.Rules))
+	priorities := make(map[string]string, len(config.Rules))
+	for i := range config.Rules {
+		rule := &config.Rules[i]
+		if len(rule.ID) > maxReplicationRuleID {
+			return fmt.Errorf("rule ID must be at most %d characters", maxReplicationRuleID)
+		}
+		if rule.ID != "" {
+			if seen[rule.ID] {
+				return fmt.Errorf("rule ID %q is not unique", rule.ID)
+			}
+			seen[rule.ID] = true
+		}
+		if rule.Status != "Enabled" && rule.Status != "Disabled" {
+			return fmt.Errorf("rule status must be Enabled or Disabled, got %q", rule.Status)
+		}
+		if rule.Priority < 0 {
+			return errors.New("Priority must be a non-negative integer")
+		}
+		if rule.Prefix != nil && rule.Filter != nil {
+			return errors.New("a rule cannot specify both Prefix and Filter")
+		}
+		if err := validateReplicationFilter(rule.Filter); err != nil {
+			return err
+		}
+		if dmr := rule.DeleteMarkerReplication; dmr != nil {
+			if dmr.Status != "Enabled" && dmr.Status != "Disabled" {
+				return fmt.Errorf("DeleteMarkerReplication status must be Enabled or Disabled, got %q", dmr.Status)
+			}
+			// A deleted object has no tags left to evaluate.
+			if rule.replicatesDeletes() && len(rule.tags()) > 0 {
+				return errors.New("DeleteMarkerReplication cannot be combined with a tag filter")
+			}
+		}
+
+		dest := rule.Destination
+		if !strings.HasPrefix(dest.Bucket, "arn:aws:s3:::") || strings.TrimPrefix(dest.Bucket, "arn:aws:s3:::") == "" {
+			return fmt.Errorf("Destination Bucket must be a bucket ARN (arn:aws:s3:::name), got %q", dest.Bucket)
+		}
+		if dest.StorageClass != "" {
+			if _, ok := engine.StorageClassBackend(dest.StorageClass); !ok {
+				return fmt.Errorf("storage class %q is not supported for replication", dest.StorageClass)
+			}
+		}
+		destTenant, destBucket := dest.target(tenantID)
+		if destTenant == tenantID && destBucket == bucket {
+			return errors.New("a bucket cannot replicate to itself")
+		}
+		key := fmt.Sprintf("%s/%s/%d", destTenant, destBucket, rule.Priority)
+		if other, dup := priorities[key]; dup {
+			return fmt.Errorf("rules %q and %q have the same destination and Priority", other, rule.ID)
+		}
+		priorities[key] = rule.ID
+	}
+	return nil
+}
+
+func validateReplicationFilter(f *ReplicationFilter) error {
+	if f == nil {
+		return nil
+	}
+	set := 0
+	if f.Prefix != nil {
+		set++
+	}
+	if f.Tag != nil {
+		set++
+	}
+	if f.And != nil {
+		set++
+	}
+	if set > 1 {
+		return errors.New("Filter may contain only one of Prefix, Tag or And")
+	}
+	if f.Tag != nil && f.Tag.Key == "" {
+		return errors.New("filter Tag requires a Key")
+	}
+	if f.And != nil {
+		for _, tag := range f.And.Tags {
+			if tag.Key == "" {
+				return errors.New("filter Tag requires a Key")
+			}
+		}
+	}
+	return nil
+}
+
+var (
+	// errReplicationNoDestination: the destination bucket does not exist.
+	errReplicationNoDestination = errors.New("the replication destination bucket does not exist")
+	// errReplicationDenied: the destination belongs to another tenant whose
+	// bucket policy does not grant the source tenant the replication action.
+	// A missing bucket of another tenant reports this too, so the check
+	// cannot be used to discover other tenants' buckets.
+	errReplicationDenied = errors.New("the destination bucket policy does not allow replication from this account")
+)
+
+// authorizeReplication checks that the destination bucket exists and, when
+// it belongs to another tenant, that its bucket policy allows the source
+// tenant's root principal action ("s3:ReplicateObject" or
+// "s3:ReplicateDelete") on key. It runs when a configuration is stored and
+// again before every job, so revoking the grant stops replication.
+func authorizeReplication(ctx context.Context, db *sql.DB, sourceTenant, destTenant, destBucket, action, key string) error {
+	_, policy, err := loadBucketPolicy(ctx, db, destTenant, destBucket)
+	if err == sql.ErrNoRows {
+		if destTenant != sourceTenant {
+			return errReplicationDenied
+		}
+		return errReplicationNoDestination
+	}
+	if err != nil {
+		return fmt.Errorf("load destination bucket policy: %w", err)
+	}
+	if destTenant == sourceTenant {
+		return nil
+	}
+	decision := policy.Evaluate(auth.PolicyRequest{
+		Principal: auth.PolicyPrincipalInfo{TenantID: sourceTenant},
+		Action:    action,
+		Resource:  auth.S3PolicyResource(destBucket, key),
+	})
+	if decision != auth.PolicyAllow {
+		return errReplicationDenied
+	}
+	return nil
+}
+
+// sqlQuerier is the part of *sql.DB and *sql.Tx the replication queue
+// uses, so enqueueing can join the transaction that wrote the head row.
+type sqlQuerier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// loadBucketReplication returns a bucket's stored replication document. The
+// second result is false when the bucket has no configuration; sql.ErrNoRows
+// means the bucket itself does not exist.
+func loadBucketReplication(ctx context.Context, q sqlQuerier, tenantID, bucket string) (*ReplicationConfiguration, bool, error) {
+	var raw sql.NullString
+	err := q.QueryRowContext(ctx,
+		`SELECT replication_config FROM buckets WHERE tenant_id = $1 AND name = $2`,
+		tenantID, bucket).Scan(&raw)
+	if err != nil {
+		return nil, false, err
+	}
+	if !raw.Valid || raw.String == "" {
+		return nil, false, nil
+	}
+	var config ReplicationConfiguration
+	if err := xml.Unmarshal([]byte(raw.String), &config); err != nil {
+		return nil, false, fmt.Errorf("decode stored replication config: %w", err)
+	}
+	return &config, true, nil
+}
+
+// queueReplication enqueues a copy of bucket/key to every destination whose
+// rule selects it and marks the object PENDING. It runs in the transaction
+// that wrote the head row; a bucket without a configuration costs one
+// indexed lookup.
+func queueReplication(ctx context.Context, q sqlQuerier, tenantID, bucket, key string) error {
+	config, ok, err := loadBucketReplication(ctx, q, tenantID, bucket)
+	if err == sql.ErrNoRows || (err == nil && !ok) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var etag string
+	var tagsJSON []byte
+	var status sql.NullString
+	err = q.QueryRowContext(ctx, `
+		SELECT etag, COALESCE(tags, '{}'), replication_status FROM object_head_cache
+		WHERE tenant_id = $1 AND bucket = $2 AND object_key = $3`,
+		tenantID, bucket, key).Scan(&etag, &tagsJSON, &status)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read head row: %w", err)
+	}
+	if status.String == replicationReplica {
+		return nil
+	}
+	tags := map[string]string{}
+	_ = json.Unmarshal(tagsJSON, &tags)
+
+	targets := config.targets(tenantID, key, tags)
+	if len(targets) == 0 {
+		return nil
+	}
+	for _, tgt := range targets {
+		if err := insertReplicationJob(ctx, q, tenantID, bucket, key, "put", tgt, etag); err != nil {
+			return err
+		}
+	}
+	if _, err := q.ExecContext(ctx, `
+		UPDATE object_head_cache SET replication_status = $4
+		WHERE tenant_id = $1 AND bucket = $2 AND object_key = $3`,
+		tenantID, bucket, key, replicationPending); err != nil {
+		return fmt.Errorf("mark replication pending: %w", err)
+	}
+	return nil
+}
+
+// queueReplicationDelete enqueues the delete of bucket/key to every
+// destination whose rule replicates delete markers. Callers run it once the
+// head row is gone.
+func queueReplicationDelete(ctx context.Context, q sqlQuerier, tenantID, bucket, key string) error {
+	config, ok, err := loadBucketReplication(ctx, q, tenantID, bucket)
+	if err == sql.ErrNoRows || (err == nil && !ok) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, tgt := range config.targets(tenantID, key, nil) {
+		if !tgt.rule.replicatesDeletes() {
+			continue
+		}
+		if err := insertReplicationJob(ctx, q, tenantID, bucket, key, "delete", tgt, ""); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func insertReplicationJob(ctx context.Context, q sqlQuerier, tenantID, bucket, key, op string, tgt replicationTarget, etag string) error {
+	if _, err := q.ExecContext(ctx, `
+		INSERT INTO replication_queue
+			(tenant_id, bucket, object_key, operation, rule_id, dest_tenant_id, dest_bucket, storage_class, etag)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+		tenantID, bucket, key, op, tgt.rule.ID, tgt.tenantID, tgt.bucket,
+		tgt.rule.Destination.StorageClass, etag); err != nil {
+		return fmt.Errorf("queue replication to %s/%s: %w", tgt.tenantID, tgt.bucket, err)
+	}
+	return nil
+}
+
+// setReplicationStatusHeader reports an object's replication state on HEAD
+// and GET; objects no rule selected carry none.
+func setReplicationStatusHeader(w http.ResponseWriter, status string) {
+	if status != "" {
+		w.Header().Set("x-amz-replication-status", status)
+	}
+}
+
+func (s *Server) handleGetBucketReplication(w http.ResponseWriter, r *http.Request, req *S3Request) {
+	t, err := tenant.FromContext(r.Context())
+	if err != nil || t == nil {
+		WriteS3Error(w, ErrAccessDenied, r.URL.Path, generateRequestID())
+		return
+	}
+
+	if s.db == nil {
+		WriteS3Error(w, ErrReplicationConfigurationNotFound, r.URL.Path, generateRequestID())
+		return
+	}
+
+	config, ok, err := loadBucketReplication(r.Context(), s.db, t.ID, req.Bucket)
+	if err == sql.ErrNoRows {
+		s.writeNoSuchBucket(w, r, t.ID, req.Bucket)
+		return
+	}
+	if err != nil {
+		s.logger.Error("query bucket replication config", zap.Error(err))
+		WriteS3Error(w, ErrInternalError, r.URL.Path, generateRequestID())
+		return
+	}
+	if !ok {
+		WriteS3Error(w, ErrReplicationConfigurationNotFound, r.URL.Path, generateRequestID())
+		return
+	}
+
+	config.Xmlns = "http://s3.amazonaws.com/doc/2006-03-01/"
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte(xml.Header))
+	_ = xml.NewEncoder(w).Encode(config)
+}
+
+func (s *Server) handlePutBucketReplication(w http.ResponseWriter, r *http.Request, req *S3Request) {
+	t, err := tenant.FromContext(r.Context())
+	if err != nil || t == nil {
+		WriteS3Error(w, ErrAccessDenied, r.URL.Path, generateRequestID())
+		return
+	}
+
+	if s.db == nil {
+		WriteS3Error(w, ErrInternalError, r.URL.Path, generateRequestID())
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxReplicationBodyBytes))
+	if err != nil {
+		WriteS3Error(w, bodyReadErrorCode(err), r.URL.Path, generateRequestID())
+		return
+	}
+
+	var config ReplicationConfiguration
+	if err := xml.Unmarshal(body, &config); err != nil {
+		WriteS3Error(w, ErrMalformedXML, r.URL.Path, generateRequestID())
+		return
+	}
+	if err := validateReplication(&config, t.ID, req.Bucket); err != nil {
+		WriteS3ErrorWithContext(w, ErrInvalidArgument, r.URL.Path, generateRequestID(),
+			WithSuggestion(err.Error()))
+		return
+	}
+
+	// The grant must cover each rule's whole prefix: a policy allowing only
+	// part of it would fail jobs later instead of the configuration now.
+	for i := range config.Rules {
+		rule := &config.Rules[i]
+		destTenant, destBucket := rule.Destination.target(t.ID)
+		actions := []string{"s3:ReplicateObject"}
+		if rule.replicatesDeletes() {
+			actions = append(actions, "s3:ReplicateDelete")
+		}
+		for _, action := range actions {
+			authErr := authorizeReplication(r.Context(), s.db, t.ID, destTenant, destBucket, action, rule.prefix()+"*")
+			switch {
+			case authErr == nil:
+				continue
+			case errors.Is(authErr, errReplicationNoDestination):
+				WriteS3ErrorWithContext(w, ErrInvalidRequest, r.URL.Path, generateRequestID(),
+					WithSuggestion(fmt.Sprintf("Destination bucket %q does not exist.", destBucket)))
+			case errors.Is(authErr, errReplicationDenied):
+				WriteS3ErrorWithContext(w, ErrAccessDenied, r.URL.Path, generateRequestID(),
+					WithSuggestion(fmt.Sprintf("The policy of bucket %q must allow %s for arn:aws:iam::%s:root.",
+						destBucket, action, t.ID)))
+			default:
+				s.logger.Error("authorize replication destination", zap.Error(authErr))
+				WriteS3Error(w, ErrInternalError, r.URL.Path, generateRequestID())
+			}
+			return
+		}
+	}
+
+	config.Xmlns = ""
+	stored, err := xml.Marshal(config)
+	if err != nil {
+		s.logger.Error("encode replication config", zap.Error(err))
+		WriteS3Error(w, ErrInternalError, r.URL.Path, generateRequestID())
+		return
+	}
+
+	result, err := s.db.ExecContext(r.Context(),
+		`UPDATE buckets SET replication_config = $3, updated_at = NOW()
+		 WHERE tenant_id = $1 AND name = $2`,
+		t.ID, req.Bucket, string(stored))
+	if err != nil {
+		s.logger.Error("update bucket replication config", zap.Error(err))
+		WriteS3Error(w, ErrInternalError, r.URL.Path, generateRequestID())
+		return
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		s.writeNoSuchBucket(w, r, t.ID, req.Bucket)
+		return
+	}
+
+	s.logger.Info("bucket replication config updated",
+		zap.String("tenant_id", t.ID),
+		zap.String("bucket", req.Bucket),
+		zap.Int("rules", len(config.Rules)))
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// handleDeleteBucketReplication stops queueing new jobs; jobs already
+// queued for committed writes still run.
+func (s *Server) handleDeleteBucketReplication(w http.ResponseWriter, r *http.Request, req *S3Request) {
+	t, err := tenant.FromContext(r.Context())
+	if err != nil || t == nil {
+		WriteS3Error(w, ErrAccessDenied, r.URL.Path, generateRequestID())
+		return
+	}
+
+	if s.db == nil {
+		WriteS3Error(w, ErrInternalError, r.URL.Path, generateRequestID())
+		return
+	}
+
+	result, err := s.db.ExecContext(r.Context(),
+		`UPDATE buckets SET replication_config = NULL, updated_at = NOW()
+		 WHERE tenant_id = $1 AND name = $2`,
+		t.ID, req.Bucket)
+	if err != nil {
+		s.logger.Error("delete bucket replication config", zap.Error(err))
+		WriteS3Error(w, ErrInternalError, r.URL.Path, generateRequestID())
+		return
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		s.writeNoSuchBucket(w, r, t.ID, req.Bucket)
+		return
+	}
+
+	s.logger.Info("bucket replication config deleted",
+		zap.String("tenant_id", t.ID),
+		zap.String("bucket", req.Bucket))
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// openChunkedObject returns the plaintext of a chunked object.
+func (s *Server) openChunkedObject(ctx context.Context, tenantID, bucket, key string, size int64) (io.ReadCloser, error) {
+	slices, err := s.sourceChunkSlices(ctx, tenantID, bucket, key, 0, size)
+	if err != nil {
+		return nil, err
+	}
+	return s.chunkSliceReader(ctx, tenantID, slices), nil
+}
+
+// ReplicationRunner drains replication_queue.
+//
+// Each cycle claims due jobs oldest first, skipping any job whose key has
+// an older job pending for the same destination so a delete never overtakes
+// the copy before it, and leases them for replicationLease. A put copies
+// the source object's bytes and metadata while its ETag is still the one
+// queued (an overwrite queued its own job); a delete removes the destination
+// object only while it is a replica. Failures retry with exponential
+// backoff and are marked failed after replicationMaxAttempts.
+//
+// SSE-C and SSE-KMS objects are copied as stored, with their key metadata.
+// SSE-S3 objects are sealed under their tenant's key and replicate only
+// within the tenant. Chunked objects are copied as plaintext whole objects:
+// their chunks live in the shared dedup container, not on one backend.
+type ReplicationRunner struct {
+	db    *sql.DB
+	eng   *engine.CoreEngine
+	gci   *crypto.GlobalContentIndex
+	quota QuotaManager
+	// deleter applies replicated deletes through the lifecycle delete
+	// paths: a delete marker in a versioning-enabled destination, an
+	// Object-Lock-aware delete otherwise.
+	deleter *LifecycleRunner
+	// openChunked returns a chunked source object's plaintext; without it
+	// chunked objects fail to replicate.
+	openChunked func(ctx context.Context, tenantID, bucket, key string, size int64) (io.ReadCloser, error)
+	logger      *zap.Logger
+	lastPurge   time.Time
+
+	// PollInterval is the pause after a cycle that did not fill a batch.
+	PollInterval time.Duration
+}
+
+// ReplicationResult holds the outcome of a single replication cycle.
+type ReplicationResult struct {
+	Completed int `json:"completed"`
+	Retried   int `json:"retried"`
+	Failed    int `json:"failed"`
+}
+
+func (rr ReplicationResult) total() int {
+	return rr.Completed + rr.Retried + rr.Failed
+}
+
+func NewReplicationRunner(db *sql.DB, eng *engine.CoreEngine, gci *crypto.GlobalContentIndex, qm QuotaManager, logger *zap.Logger) *ReplicationRunner {
+	if db == nil || eng == nil {
+		return nil
+	}
+	return &ReplicationRunner{
+		db:           db,
+		eng:          eng,
+		gci:          gci,
+		quota:        qm,
+		deleter:      NewLifecycleRunner(db, eng, gci, qm, logger),
+		logger:       logger,
+		PollInterval: 10 * time.Second,
+	}
+}
+
+// Start drains the queue until ctx is done: back to back while cycles fill
+// a batch, then every PollInterval.
+func (rr *ReplicationRunner) Start(ctx context.Context) {
+	if rr == nil {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(rr.PollInterval)
+		defer ticker.Stop()
+		for {
+			if rr.runAndLog(ctx) < replicationBatchSize {
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+				}
+			} else if ctx.Err() != nil {
+				return
+			}
+		}
+	}()
+}
+
+// runAndLog runs one cycle and returns the number of jobs it claimed.
+func (rr *ReplicationRunner) runAndLog(ctx context.Context) int {
+	result, err := rr.RunOnce(ctx)
+	if err != nil {
+		rr.logger.Error("replication cycle failed", zap.Error(err))
+		return 0
+	}
+	if result.total() > 0 {
+		rr.logger.Info("replication cycle completed",
+			zap.Int("completed", result.Completed),
+			zap.Int("retried", result.Retried),
+			zap.Int("failed", result.Failed))
+	}
+	return result.total()
+}
+
+type replicationJob struct {
+	id           int64
+	tenantID     string
+	bucket       string
+	key          string
+	operation    string
+	ruleID       string
+	destTenantID string
+	destBucket   string
+	storageClass string
+	etag         string
+	attempts     int
+}
+
+var (
+	// errReplicationSuperseded: the source was overwritten or deleted after
+	// the job was queued; the later write queued its own job.
+	errReplicationSuperseded = errors.New("superseded by a later write")
+	// errReplicaChanged: the destination replica changed between being read
+	// and being deleted.
+	errReplicaChanged = errors.New("destination replica changed while being deleted")
+)
+
+// replicationError is a job failure that retrying cannot fix.
+type replicationError struct {
+	msg string
+}
+
+func (e *replicationError) Error() string { return e.msg }
+
+// RunOnce claims one batch of due jobs and runs them.
+func (rr *ReplicationRunner) RunOnce(ctx context.Context) (ReplicationResult, error) {
+	var result ReplicationResult
+	if time.Since(rr.lastPurge) > time.Hour {
+		rr.purge(ctx)
+	}
+
+	jobs, err := rr.claim(ctx)
+	if err != nil {
+		return result, err
+	}
+	for _, job := range jobs {
+		// Unfinished jobs stay leased and run again once it expires.
+		if ctx.Err() != nil {
+			return result, ctx.Err()
+		}
+		var jobErr error
+		if job.operation == "delete" {
+			jobErr = rr.replicateDelete(ctx, job)
+		} else {
+			jobErr = rr.replicate(ctx, job)
+		}
+		rr.finish(ctx, job, jobErr, &result)
+	}
+	return result, nil
+}
+
+func (rr *ReplicationRunner) claim(ctx context.Context) ([]replicationJob, error) {
+	rows, err := rr.db.QueryContext(ctx, `
+		UPDATE replication_queue
+		SET attempts = attempts + 1,
+		    next_attempt_at = NOW() + make_interval(secs => $2)
+		WHERE id IN (
+			SELECT q.id FROM replication_queue q
+			WHERE q.status = 'pending' AND q.next_attempt_at <= NOW()
+			  AND NOT EXISTS (
+				SELECT 1 FROM replication_queue o
+				WHERE o.status = 'pending' AND o.id < q.id
+				  AND o.tenant_id = q.tenant_id AND o.bucket = q.bucket AND o.object_key = q.object_key
+				  AND o.dest_tenant_id = q.dest_tenant_id AND o.dest_bucket = q.dest_bucket)
+			ORDER BY q.next_attempt_at, q.id
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED)
+		RETURNING id, tenant_id, bucket, object_key, operation, rule_id,
+		          dest_tenant_id, dest_bucket, storage_class, etag, attempts`,
+		replicationBatchSize, replicationLease.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("claim replication jobs: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var jobs []replicationJob
+	for rows.Next() {
+		var j replicationJob
+		if err := rows.Scan(&j.id, &j.tenantID, &j.bucket, &j.key, &j.operation, &j.ruleID,
+			&j.destTenantID, &j.destBucket, &j.storageClass, &j.etag, &j.attempts); err != nil {
+			return nil, fmt.Errorf("scan replication job: %w", err)
+		}
+		jobs = append(jobs, j)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate replication jobs: %w", err)
+	}
+	return jobs, nil
+}
+
+// replicationSource is the source head row a put job copies.
+type replicationSource struct {
+	size               int64
+	etag               string
+	contentType        string
+	metadata           []byte
+	tags               []byte
+	contentDisposition string
+	encryption         string
+	segmented          bool
+	kmsKeyID           sql.NullString
+	kmsDataKey         sql.NullString
+	kmsContext         sql.NullString
+	isChunked          bool
+	checksumAlgorithm  sql.NullString
+	checksumValue      sql.NullString
+	checksumType       sql.NullString
+	redirectLocation   sql.NullString
+	partLayout         []byte
+}
+
+// replicate copies the source object of a put job to its destination.
+func (rr *ReplicationRunner) replicate(ctx context.Context, job replicationJob) error {
+	var src replicationSource
+	err := rr.db.QueryRowContext(ctx, `
+		SELECT size_bytes, etag, content_type, COALESCE(metadata, '{}'), COALESCE(tags, '{}'),
+		       COALESCE(content_disposition, ''), COALESCE(encryption_algorithm, ''), sse_segmented,
+		       sse_kms_key_id, sse_kms_data_key, sse_kms_context, is_chunked,
+		       checksum_algorithm, checksum_value, checksum_type, website_redirect_location, part_layout
+		FROM object_head_cache
+		WHERE tenant_id = $1 AND bucket = $2 AND object_key = $3`,
+		job.tenantID, job.bucket, job.key).Scan(&src.size, &src.etag, &src.contentType, &src.metadata, &src.tags,
+		&src.contentDisposition, &src.encryption, &src.segmented,
+		&src.kmsKeyID, &src.kmsDataKey, &src.kmsContext, &src.isChunked,
+		&src.checksumAlgorithm, &src.checksumValue, &src.checksumType, &src.redirectLocation, &src.partLayout)
+	if err == sql.ErrNoRows || (err == nil && src.etag != job.etag) {
+		return errReplicationSuperseded
+	}
+	if err != nil {
+		return fmt.Errorf("read source head row: %w", err)
+	}
+
+	if err := authorizeReplication(ctx, rr.db, job.tenantID, job.destTenantID, job.destBucket,
+		"s3:ReplicateObject", job.key); err != nil {
+		if errors.Is(err, errReplicationNoDestination) || errors.Is(err, errReplicationDenied) {
+			return &replicationError{msg: err.Error()}
+		}
+		return err
+	}
+	if !src.isChunked && src.encryption == crypto.SSEAlgorithm && job.destTenantID != job.tenantID {
+		return &replicationError{msg: "SSE-S3 objects are sealed under their tenant's key and replicate only within the tenant"}
+	}
+
+	srcCtx := common.WithTenantID(ctx, job.tenantID)
+	var body io.ReadCloser
+	if src.isChunked {
+		if rr.openChunked == nil {
+			return &replicationError{msg: "chunked objects cannot be replicated without the content index"}
+		}
+		body, err = rr.openChunked(srcCtx, job.tenantID, job.bucket, job.key, src.size)
+	} else {
+		body, err = rr.eng.Get(srcCtx, (&tenant.Tenant{ID: job.tenantID}).NamespaceContainer(job.bucket), job.key)
+	}
+	if err != nil {
+		return fmt.Errorf("read source object: %w", err)
+	}
+	defer func() { _ = body.Close() }()
+
+	// A chunked source is read as plaintext: the replica is a plain object.
+	encryption, segmented := src.encryption, src.segmented
+	kmsKeyID, kmsDataKey, kmsContext := src.kmsKeyID, src.kmsDataKey, src.kmsContext
+	if src.isChunked {
+		encryption, segmented = "", false
+		kmsKeyID, kmsDataKey, kmsContext = sql.NullString{}, sql.NullString{}, sql.NullString{}
+	}
+
+	storageClass := job.storageClass
+	if storageClass == "" {
+		storageClass = bucketTierStorageClass(ctx, rr.db, job.destTenantID, job.destBucket)
+	}
+	var putOpts []engine.PutOption
+	if encryption == "" {
+		// Stored ciphertext is longer than size_bytes; only a plaintext
+		// body's length is known up front.
+		putOpts = append(putOpts, engine.WithContentLength(src.size))
+	}
+	if storageClass != "" {
+		putOpts = append(putOpts, engine.WithStorageClass(storageClass))
+	}
+	// The replica's key stays locked from the byte write to the head
+	// upsert, as for PutObject (s3_conditional_write.go).
+	kl, err := lockKeyForWrite(ctx, rr.db, job.destTenantID, job.destBucket, job.key)
+	if err != nil {
+		return err
+	}
+	defer kl.release()
+
+	destCtx := common.WithTenantID(ctx, job.destTenantID)
+	backendName, err := rr.eng.Put(destCtx, (&tenant.Tenant{ID: job.destTenantID}).NamespaceContainer(job.destBucket),
+		job.key, body, putOpts...)
+	if err != nil {
+		return fmt.Errorf("write replica: %w", err)
+	}
+
+	displaced, err := kl.headUpsert(ctx, rr.db, manifestReleaser(rr.gci), job.destTenantID, job.destBucket, job.key, func(tx *sql.Tx) error {
+		_, execErr := tx.ExecContext(ctx, `
+			INSERT INTO object_head_cache
+				(tenant_id, bucket, object_key, size_bytes, etag, content_type, backend_name, metadata, tags,
+				 encryption_algorithm, content_disposition, is_chunked, checksum_algorithm, checksum_value, checksum_type,
+				 sse_segmented, sse_kms_key_id, sse_kms_data_key, sse_kms_context, website_redirect_location, part_layout,
+				 replication_status, updated_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, FALSE, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, NOW())
+			ON CONFLICT (tenant_id, bucket, object_key) DO UPDATE SET
+				size_bytes            = EXCLUDED.size_bytes,
+				etag                  = EXCLUDED.etag,
+				content_type          = EXCLUDED.content_type,
+				backend_name          = EXCLUDED.backend_name,
+				metadata              = EXCLUDED.metadata,
+				tags                  = EXCLUDED.tags,
+				encryption_algorithm  = EXCLUDED.encryption_algorithm,
+				content_disposition   = EXCLUDED.content_disposition,
+				is_chunked            = FALSE,
+				checksum_algorithm    = EXCLUDED.checksum_algorithm,
+				checksum_value        = EXCLUDED.checksum_value,
+				checksum_type         = EXCLUDED.checksum_type,
+				sse_segmented         = EXCLUDED.sse_segmented,
+				sse_kms_key_id        = EXCLUDED.sse_kms_key_id,
+				sse_kms_data_key      = EXCLUDED.sse_kms_data_key,
+				sse_kms_context       = EXCLUDED.sse_kms_context,
+				website_redirect_location = EXCLUDED.website_redirect_location,
+				part_layout           = EXCLUDED.part_layout,
+				acl                   = NULL,
+				replication_status    = EXCLUDED.replication_status,
+				updated_at            = NOW()
+		`, job.destTenantID, job.destBucket, job.key, src.size, src.etag, src.contentType, backendName,
+			src.metadata, src.tags, encryption, src.contentDisposition,
+			src.checksumAlgorithm, src.checksumValue, src.checksumType,
+			segmented, kmsKeyID, kmsDataKey, kmsContext, src.redirectLocation, src.partLayout, replicationReplica)
+		return execErr
+	})
+	if err != nil {
+		return fmt.Errorf("record replica: %w", err)
+	}
+	rr.accountQuota(ctx, job.destTenantID, src.size-displaced)
+
+	if vStatus := getBucketVersioningStatus(ctx, rr.db, job.destTenantID, job.destBucket); vStatus == "Enabled" || vStatus == "Suspended" {
+		versionID := "null"
+		if vStatus == "Enabled" {
+			versionID = generateVersionID()
+		}
+		_, _ = rr.db.ExecContext(ctx, `
+			UPDATE object_versions SET is_latest = FALSE
+			WHERE tenant_id = $1 AND bucket = $2 AND object_key = $3 AND is_latest = TRUE`,
+			job.destTenantID, job.destBucket, job.key)
+		_, _ = rr.db.ExecContext(ctx, `
+			INSERT INTO object_versions
+				(tenant_id, bucket, object_key, version_id, size_bytes, etag, content_type, is_latest, is_delete_marker, backend_name)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, TRUE, FALSE, $8)
+			ON CONFLICT (tenant_id, bucket, object_key, version_id) DO UPDATE SET
+				size_bytes = EXCLUDED.size_bytes, etag = EXCLUDED.etag,
+				content_type = EXCLUDED.content_type, is_latest = TRUE,
+				is_delete_marker = FALSE, backend_name = EXCLUDED.backend_name`,
+			job.destTenantID, job.destBucket, job.key, versionID, src.size, src.etag, src.contentType, backendName)
+	}
+	return nil
+}
+
+// replicateDelete applies a delete job to the destination object if it is
+// still a replica; objects written there directly are never deleted.
+func (rr *ReplicationRunner) replicateDelete(ctx context.Context, job replicationJob) error {
+	if err := authorizeReplication(ctx, rr.db, job.tenantID, job.destTenantID, job.destBucket,
+		"s3:ReplicateDelete", job.key); err != nil {
+		if errors.Is(err, errReplicationNoDestination) || errors.Is(err, errReplicationDenied) {
+			return &replicationError{msg: err.Error()}
+		}
+		return err
+	}
+
+	obj := lifecycleObject{key: job.key}
+	var status sql.NullString
+	err := rr.db.QueryRowContext(ctx, `
+		SELECT size_bytes, etag, updated_at, is_chunked, replication_status FROM object_head_cache
+		WHERE tenant_id = $1 AND bucket = $2 AND object_key = $3`,
+		job.destTenantID, job.destBucket, job.key).Scan(&obj.size, &obj.etag, &obj.updatedAt, &obj.isChunked, &status)
+	if err == sql.ErrNoRows || (err == nil && status.String != replicationReplica) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read destination head row: %w", err)
+	}
+
+	// Both deletes skip a replica that changed since it was read; the
+	// retry reads it again.
+	b := lifecycleBucket{tenantID: job.destTenantID, name: job.destBucket}
+	if getBucketVersioningStatus(ctx, rr.db, job.destTenantID, job.destBucket) == "Enabled" {
+		created, err := rr.deleter.createDeleteMarker(ctx, b, obj)
+		if err != nil {
+			return err
+		}
+		if !created {
+			return errReplicaChanged
+		}
+		return nil
+	}
+	deleted, err := rr.deleter.deleteObject(ctx, b, obj)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		if lockErr := checkObjectLock(ctx, rr.db, job.destTenantID, job.destBucket, job.key, false); errors.Is(lockErr, errObjectLocked) {
+			return &replicationError{msg: "the destination replica is protected by Object Lock"}
+		}
+		return errReplicaChanged
+	}
+	return nil
+}
+
+// finish records a job's outcome and refreshes its source object's status.
+func (rr *ReplicationRunner) finish(ctx context.Context, job replicationJob, jobErr error, result *ReplicationResult) {
+	log := rr.logger.With(
+		zap.Int64("job_id", job.id),
+		zap.String("tenant_id", job.tenantID),
+		zap.String("bucket", job.bucket),
+		zap.String("key", job.key),
+		zap.String("operation", job.operation),
+		zap.String("destination", job.destTenantID+"/"+job.destBucket))
+
+	var permanent *replicationError
+	var err error
+	switch {
+	case jobErr == nil || errors.Is(jobErr, errReplicationSuperseded):
+		note := ""
+		if jobErr != nil {
+			note = jobErr.Error()
+		}
+		_, err = rr.db.ExecContext(ctx, `
+			UPDATE replication_queue SET status = 'completed', completed_at = NOW(), last_error = $2
+			WHERE id = $1`, job.id, nullIfEmpty(note))
+		result.Completed++
+	case errors.As(jobErr, &permanent) || job.attempts >= replicationMaxAttempts:
+		log.Warn("replication: job failed", zap.Int("attempts", job.attempts), zap.Error(jobErr))
+		_, err = rr.db.ExecContext(ctx, `
+			UPDATE replication_queue SET status = 'failed', completed_at = NOW(), last_error = $2
+			WHERE id = $1`, job.id, jobErr.Error())
+		result.Failed++
+	default:
+		log.Debug("replication: job will retry", zap.Int("attempts", job.attempts), zap.Error(jobErr))
+		_, err = rr.db.ExecContext(ctx, `
+			UPDATE replication_queue SET next_attempt_at = NOW() + make_interval(secs => $2), last_error = $3
+			WHERE id = $1`, job.id, replicationBackoff(job.attempts).Seconds(), jobErr.Error())
+		result.Retried++
+	}
+	if err != nil {
+		log.Error("replication: record job outcome", zap.Error(err))
+		return
+	}
+	if job.operation == "put" {
+		rr.refreshStatus(ctx, job, log)
+	}
+}
+
+// replicationBackoff is the delay before retry attempt+1: 30s doubling,
+// capped at an hour.
+func replicationBackoff(attempts int) time.Duration {
+	d := 30 * time.Second
+	for i := 1; i < attempts && d < time.Hour; i++ {
+		d *= 2
+	}
+	if d > time.Hour {
+		d = time.Hour
+	}
+	return d
+}
+
+// refreshStatus derives the source object's replication status from the
+// newest job per destination for its current ETag: PENDING while any is
+// pending, FAILED if any failed, COMPLETED once all completed.
+func (rr *ReplicationRunner) refreshStatus(ctx context.Context, job replicationJob, log *zap.Logger) {
+	if _, err := rr.db.ExecContext(ctx, `
+		WITH latest AS (
+			SELECT DISTINCT ON (dest_tenant_id, dest_bucket) status
+			FROM replication_queue
+			WHERE tenant_id = $1 AND bucket = $2 AND object_key = $3
+			  AND operation = 'put' AND etag = $4
+			ORDER BY dest_tenant_id, dest_bucket, id DESC
+		)
+		UPDATE object_head_cache SET replication_status = CASE
+			WHEN EXISTS (SELECT 1 FROM latest WHERE status = 'pending') THEN $5
+			WHEN EXISTS (SELECT 1 FROM latest WHERE status = 'failed') THEN $6
+			ELSE $7 END
+		WHERE tenant_id = $1 AND bucket = $2 AND object_key = $3 AND etag = $4
+		  AND replication_status IN ($5, $6, $7)`,
+		job.tenantID, job.bucket, job.key, job.etag,
+		replicationPending, replicationFailed, replicationCompleted); err != nil {
+		log.Error("replication: update source status", zap.Error(err))
+	}
+}
+
+// accountQuota charges a tenant delta bytes for a stored replica (negative
+// releases). The bytes are already stored, so the charge is unconditional.
+func (rr *ReplicationRunner) accountQuota(ctx context.Context, tenantID string, delta int64) {
+	if rr.quota == nil || delta == 0 {
+		return
+	}
+	if err := rr.quota.ReleaseQuota(ctx, tenantID, -delta); err != nil {
+		rr.logger.Error("replication: quota update failed",
+			zap.Error(err), zap.String("tenant_id", tenantID), zap.Int64("bytes", delta))
+	}
+}
+
+// purge drops completed jobs past replicationRetention.
+func (rr *ReplicationRunner) purge(ctx context.Context) {
+	rr.lastPurge = time.Now()
+	res, err := rr.db.ExecContext(ctx, `
+		DELETE FROM replication_queue
+		WHERE status = 'completed' AND completed_at < NOW() - make_interval(secs => $1)`,
+		replicationRetention.Seconds())
+	if err != nil {
+		rr.logger.Error("replication: purge completed jobs", zap.Error(err))
+		return
+	}
+	if n, _ := res.RowsAffected(); n > 0 {
+		rr.logger.Info("replication: purged completed jobs", zap.Int64("count", n))
+	}
+}
+
+// replicationLag is the queue state of one source bucket, or of all of them.
+type replicationLag struct {
+	TenantID   string  `json:"tenant_id,omitempty"`
+	Bucket     string  `json:"bucket,omitempty"`
+	Pending    int64   `json:"pending"`
+	Failed     int64   `json:"failed"`
+	LagSeconds float64 `json:"lag_seconds"` // age of the oldest pending job
+}
+
+const replicationLagSelect = `
+	COUNT(*) FILTER (WHERE status = 'pending'),
+	COUNT(*) FILTER (WHERE status = 'failed'),
+	COALESCE(EXTRACT(EPOCH FROM NOW() - MIN(created_at) FILTER (WHERE status = 'pending')), 0)::float8
+	FROM replication_queue WHERE status <> 'completed'`
+
+// handleReplicationMetrics serves the replication queue totals in the same
+// plain-text format as /metrics.
+func (s *Server) handleReplicationMetrics(w http.ResponseWriter, r *http.Request) {
+	var lag replicationLag
+	if s.db != nil {
+		if err := s.db.QueryRowContext(r.Context(), `SELECT `+replicationLagSelect).
+			Scan(&lag.Pending, &lag.Failed, &lag.LagSeconds); err != nil {
+			s.logger.Error("replication metrics", zap.Error(err))
+			http.Error(w, "replication metrics unavailable", http.StatusServiceUnavailable)
+			return
+		}
+	}
+	w.Header().Set("Content-Type", "text/plain")
+	_, _ = fmt.Fprintf(w, "vaultaire_replication_pending_jobs %d\nvaultaire_replication_failed_jobs %d\nvaultaire_replication_lag_seconds %.3f\n",
+		lag.Pending, lag.Failed, lag.LagSeconds)
+}
+
+// handleAdminReplicationLag lists the queue state per source bucket, most
+// lagging first (admin-only).
+func (s *Server) handleAdminReplicationLag(w http.ResponseWriter, r *http.Request) {
+	if s.db == nil {
+		http.Error(w, "database not configured", http.StatusServiceUnavailable)
+		return
+	}
+	rows, err := s.db.QueryContext(r.Context(), `
+		SELECT tenant_id, bucket, `+replicationLagSelect+`
+		GROUP BY tenant_id, bucket
+		ORDER BY 5 DESC, 3 DESC`)
+	if err != nil {
+		s.logger.Error("replication lag", zap.Error(err))
+		http.Error(w, "query failed", http.StatusInternalServerError)
+		return
+	}
+	defer func() { _ = rows.Close() }()
+
+	buckets := []replicationLag{}
+	for rows.Next() {
+		var lag replicationLag
+		if err := rows.Scan(&lag.TenantID, &lag.Bucket, &lag.Pending, &lag.Failed, &lag.LagSeconds); err != nil {
+			s.logger.Error("replication lag scan", zap.Error(err))
+			http.Error(w, "query failed", http.StatusInternalServerError)
+			return
+		}
+		buckets = append(buckets, lag)
+	}
+	if err := rows.Err(); err != nil {
+		s.logger.Error("replication lag rows", zap.Error(err))
+		http.Error(w, "query failed", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{"buckets": buckets})
+}
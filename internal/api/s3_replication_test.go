@@ -0,0 +1,367 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/FairForge/vaultaire/internal/drivers"
+	"github.com/FairForge/vaultaire/internal/engine"
+	"github.com/FairForge/vaultaire/internal/tenant"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func parseReplicationXML(t *testing.T, doc string) *ReplicationConfiguration {
+	t.Helper()
+	var config ReplicationConfiguration
+	require.NoError(t, xml.Unmarshal([]byte(doc), &config))
+	return &config
+}
+
+func TestValidateReplication(t *testing.T) {
+	tests := []struct {
+		name    string
+		rules   string
+		wantErr string
+	}{
+		{"minimal", `<Rule><Status>Enabled</Status><Destination><Bucket>arn:aws:s3:::dst</Bucket></Destination></Rule>`, ""},
+		{"legacy prefix", `<Rule><Status>Enabled</Status><Prefix>logs/</Prefix><Destination><Bucket>arn:aws:s3:::dst</Bucket></Destination></Rule>`, ""},
+		{"and filter with delete markers off", `<Rule><Status>Enabled</Status><Filter><And><Prefix>a/</Prefix><Tag><Key>k</Key><Value>v</Value></Tag></And></Filter><DeleteMarkerReplication><Status>Disabled</Status></DeleteMarkerReplication><Destination><Bucket>arn:aws:s3:::dst</Bucket><StorageClass>GLACIER</StorageClass></Destination></Rule>`, ""},
+		{"other tenant", `<Rule><Status>Enabled</Status><Destination><Bucket>arn:aws:s3:::src</Bucket><Account>t2</Account></Destination></Rule>`, ""},
+		{"no rules", ``, "at least one"},
+		{"bad status", `<Rule><Status>On</Status><Destination><Bucket>arn:aws:s3:::dst</Bucket></Destination></Rule>`, "Enabled or Disabled"},
+		{"not an arn", `<Rule><Status>Enabled</Status><Destination><Bucket>dst</Bucket></Destination></Rule>`, "bucket ARN"},
+		{"self", `<Rule><Status>Enabled</Status><Destination><Bucket>arn:aws:s3:::src</Bucket></Destination></Rule>`, "itself"},
+		{"prefix and filter", `<Rule><Status>Enabled</Status><Prefix>a</Prefix><Filter><Prefix>b</Prefix></Filter><Destination><Bucket>arn:aws:s3:::dst</Bucket></Destination></Rule>`, "both Prefix and Filter"},
+		{"two filter forms", `<Rule><Status>Enabled</Status><Filter><Prefix>b</Prefix><Tag><Key>k</Key><Value>v</Value></Tag></Filter><Destination><Bucket>arn:aws:s3:::dst</Bucket></Destination></Rule>`, "only one of"},
+		{"tag filter replicating deletes", `<Rule><Status>Enabled</Status><Filter><Tag><Key>k</Key><Value>v</Value></Tag></Filter><DeleteMarkerReplication><Status>Enabled</Status></DeleteMarkerReplication><Destination><Bucket>arn:aws:s3:::dst</Bucket></Destination></Rule>`, "tag filter"},
+		{"bad storage class", `<Rule><Status>Enabled</Status><Destination><Bucket>arn:aws:s3:::dst</Bucket><StorageClass>FLOPPY</StorageClass></Destination></Rule>`, "storage class"},
+		{"duplicate id", `<Rule><ID>a</ID><Priority>1</Priority><Status>Enabled</Status><Destination><Bucket>arn:aws:s3:::dst</Bucket></Destination></Rule><Rule><ID>a</ID><Priority>2</Priority><Status>Enabled</Status><Destination><Bucket>arn:aws:s3:::dst</Bucket></Destination></Rule>`, "not unique"},
+		{"same destination and priority", `<Rule><ID>a</ID><Status>Enabled</Status><Destination><Bucket>arn:aws:s3:::dst</Bucket></Destination></Rule><Rule><ID>b</ID><Status>Enabled</Status><Destination><Bucket>arn:aws:s3:::dst</Bucket></Destination></Rule>`, "same destination and Priority"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := parseReplicationXML(t, `<ReplicationConfiguration>`+tt.rules+`</ReplicationConfiguration>`)
+			err := validateReplication(config, "t1", "src")
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
+
+func TestReplicationConfiguration_Targets(t *testing.T) {
+	config := parseReplicationXML(t, `<ReplicationConfiguration>
+  <Rule><ID>all</ID><Priority>1</Priority><Status>Enabled</Status>
+    <Filter><Prefix></Prefix></Filter>
+    <DeleteMarkerReplication><Status>Enabled</Status></DeleteMarkerReplication>
+    <Destination><Bucket>arn:aws:s3:::mirror</Bucket></Destination></Rule>
+  <Rule><ID>hot</ID><Priority>5</Priority><Status>Enabled</Status>
+    <Filter><And><Prefix>logs/</Prefix><Tag><Key>tier</Key><Value>hot</Value></Tag></And></Filter>
+    <Destination><Bucket>arn:aws:s3:::mirror</Bucket><StorageClass>GLACIER</StorageClass></Destination></Rule>
+  <Rule><ID>partner</ID><Status>Enabled</Status>
+    <Filter><Prefix>shared/</Prefix></Filter>
+    <Destination><Bucket>arn:aws:s3:::inbox</Bucket><Account>t2</Account></Destination></Rule>
+  <Rule><ID>off</ID><Status>Disabled</Status>
+    <Destination><Bucket>arn:aws:s3:::unused</Bucket></Destination></Rule>
+</ReplicationConfiguration>`)
+
+	ids := func(targets []replicationTarget) []string {
+		var out []string
+		for _, tgt := range targets {
+			out = append(out, fmt.Sprintf("%s:%s/%s", tgt.rule.ID, tgt.tenantID, tgt.bucket))
+		}
+		return out
+	}
+
+	assert.Equal(t, []string{"all:t1/mirror"}, ids(config.targets("t1", "data/x", map[string]string{})))
+	assert.Equal(t, []string{"hot:t1/mirror"}, ids(config.targets("t1", "logs/x", map[string]string{"tier": "hot"})),
+		"the higher-priority rule wins for a shared destination")
+	assert.Equal(t, []string{"all:t1/mirror", "partner:t2/inbox"}, ids(config.targets("t1", "shared/x", map[string]string{})))
+	assert.Equal(t, []string{"all:t1/mirror"}, ids(config.targets("t1", "logs/x", nil)),
+		"a delete is only selected by rules without a tag filter")
+}
+
+func TestReplicationBackoff(t *testing.T) {
+	assert.Equal(t, 30*time.Second, replicationBackoff(1))
+	assert.Equal(t, 60*time.Second, replicationBackoff(2))
+	assert.Equal(t, 4*time.Minute, replicationBackoff(4))
+	assert.Equal(t, time.Hour, replicationBackoff(20))
+}
+
+func TestQueueReplication_EnqueuesMatchingDestinations(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() { _ = db.Close() }()
+
+	config := `<ReplicationConfiguration><Rule><ID>r</ID><Status>Enabled</Status>` +
+		`<Filter><Prefix>logs/</Prefix></Filter>` +
+		`<Destination><Bucket>arn:aws:s3:::dst</Bucket><StorageClass>GLACIER</StorageClass></Destination>` +
+		`</Rule></ReplicationConfiguration>`
+	mock.ExpectQuery("SELECT replication_config FROM buckets").
+		WithArgs("t1", "src").
+		WillReturnRows(sqlmock.NewRows([]string{"replication_config"}).AddRow(config))
+	mock.ExpectQuery("SELECT etag, COALESCE\\(tags, '\\{\\}'\\), replication_status FROM object_head_cache").
+		WithArgs("t1", "src", "logs/a.txt").
+		WillReturnRows(sqlmock.NewRows([]string{"etag", "tags", "replication_status"}).AddRow("abc", []byte(`{}`), nil))
+	mock.ExpectExec("INSERT INTO replication_queue").
+		WithArgs("t1", "src", "logs/a.txt", "put", "r", "t1", "dst", "GLACIER", "abc").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("UPDATE object_head_cache SET replication_status").
+		WithArgs("t1", "src", "logs/a.txt", replicationPending).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	require.NoError(t, queueReplication(context.Background(), db, "t1", "src", "logs/a.txt"))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestQueueReplication_SkipsReplicas(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() { _ = db.Close() }()
+
+	config := `<ReplicationConfiguration><Rule><Status>Enabled</Status>` +
+		`<Destination><Bucket>arn:aws:s3:::src</Bucket></Destination>` +
+		`</Rule></ReplicationConfiguration>`
+	mock.ExpectQuery("SELECT replication_config FROM buckets").
+		WillReturnRows(sqlmock.NewRows([]string{"replication_config"}).AddRow(config))
+	mock.ExpectQuery("SELECT etag, COALESCE").
+		WillReturnRows(sqlmock.NewRows([]string{"etag", "tags", "replication_status"}).AddRow("abc", []byte(`{}`), replicationReplica))
+
+	require.NoError(t, queueReplication(context.Background(), db, "t1", "dst", "a.txt"))
+	assert.NoError(t, mock.ExpectationsWereMet(), "a replica must not be replicated again")
+}
+
+func TestQueueReplication_NoConfig(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() { _ = db.Close() }()
+
+	mock.ExpectQuery("SELECT replication_config FROM buckets").
+		WillReturnRows(sqlmock.NewRows([]string{"replication_config"}).AddRow(nil))
+	mock.ExpectQuery("SELECT replication_config FROM buckets").
+		WillReturnError(sql.ErrNoRows)
+
+	require.NoError(t, queueReplication(context.Background(), db, "t1", "src", "a.txt"))
+	require.NoError(t, queueReplicationDelete(context.Background(), db, "t1", "gone", "a.txt"))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestNewReplicationRunner_NilDB(t *testing.T) {
+	assert.Nil(t, NewReplicationRunner(nil, nil, nil, nil, zap.NewNop()))
+	var rr *ReplicationRunner
+	rr.Start(context.Background())
+}
+
+// --- Integration tests (DATABASE_URL) ---
+
+type replicationFixture struct {
+	server  *Server
+	db      *sql.DB
+	eng     *engine.CoreEngine
+	src     *tenant.Tenant
+	dst     *tenant.Tenant
+	tempDir string
+}
+
+func setupReplicationFixture(t *testing.T) *replicationFixture {
+	t.Helper()
+
+	dsn := os.Getenv("DATABASE_URL")
+	if dsn == "" {
+		t.Skip("DATABASE_URL not set — skipping integration test")
+	}
+	db, err := sql.Open("postgres", dsn)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	require.NoError(t, db.Ping())
+
+	logger := zap.NewNop()
+
+	tempDir, err := os.MkdirTemp("", "vaultaire-replication-test-*")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = os.RemoveAll(tempDir) })
+
+	eng := engine.NewEngine(nil, logger, nil)
+	eng.AddDriver("local", drivers.NewLocalDriver(tempDir, logger))
+	eng.SetPrimary("local")
+
+	f := &replicationFixture{db: db, eng: eng, tempDir: tempDir}
+	for i, tn := range []**tenant.Tenant{&f.src, &f.dst} {
+		id := fmt.Sprintf("replication-%d-%d", os.Getpid(), i)
+		*tn = &tenant.Tenant{ID: id, Namespace: "tenant/" + id + "/"}
+		_, err = db.Exec(`
+			INSERT INTO tenants (id, name, email, access_key, secret_key)
+			VALUES ($1, $2, $3, $4, $5)
+			ON CONFLICT (id) DO NOTHING
+		`, id, "Replication Test", id+"@test.local", "AK-"+id, "SK-"+id)
+		require.NoError(t, err)
+		for _, bucket := range []string{"src", "dst"} {
+			_, err = db.Exec(`
+				INSERT INTO buckets (tenant_id, name, visibility)
+				VALUES ($1, $2, 'private')
+				ON CONFLICT (tenant_id, name) DO NOTHING
+			`, id, bucket)
+			require.NoError(t, err)
+			require.NoError(t, os.MkdirAll(filepath.Join(tempDir, (*tn).NamespaceContainer(bucket)), 0755))
+		}
+		t.Cleanup(func() {
+			_, _ = db.Exec("DELETE FROM replication_queue WHERE tenant_id = $1", id)
+			_, _ = db.Exec("DELETE FROM object_head_cache WHERE tenant_id = $1", id)
+			_, _ = db.Exec("DELETE FROM object_versions WHERE tenant_id = $1", id)
+			_, _ = db.Exec("DELETE FROM buckets WHERE tenant_id = $1", id)
+			_, _ = db.Exec("DELETE FROM tenants WHERE id = $1", id)
+		})
+	}
+
+	f.server = &Server{
+		logger:   logger,
+		router:   chi.NewRouter(),
+		engine:   eng,
+		db:       db,
+		testMode: true,
+	}
+	return f
+}
+
+func (f *replicationFixture) putConfig(t *testing.T, doc string) *httptest.ResponseRecorder {
+	t.Helper()
+	ctx := tenant.WithTenant(context.Background(), f.src)
+	r := httptest.NewRequest("PUT", "/src?replication", bytes.NewReader([]byte(doc))).WithContext(ctx)
+	w := httptest.NewRecorder()
+	f.server.handlePutBucketReplication(w, r, &S3Request{Bucket: "src", TenantID: f.src.ID})
+	return w
+}
+
+func (f *replicationFixture) put(t *testing.T, key string, body []byte) {
+	t.Helper()
+	r := httptest.NewRequest("PUT", "/src/"+key, bytes.NewReader(body))
+	r.ContentLength = int64(len(body))
+	r = r.WithContext(tenant.WithTenant(r.Context(), f.src))
+	w := httptest.NewRecorder()
+	f.server.handlePutObject(w, r, &S3Request{Bucket: "src", Object: key, TenantID: f.src.ID})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+}
+
+func (f *replicationFixture) status(t *testing.T, tn *tenant.Tenant, bucket, key string) string {
+	t.Helper()
+	var status sql.NullString
+	err := f.db.QueryRow(`
+		SELECT replication_status FROM object_head_cache
+		WHERE tenant_id = $1 AND bucket = $2 AND object_key = $3`,
+		tn.ID, bucket, key).Scan(&status)
+	if err == sql.ErrNoRows {
+		return "<missing>"
+	}
+	require.NoError(t, err)
+	return status.String
+}
+
+func TestBucketReplication_PutGetDelete(t *testing.T) {
+	f := setupReplicationFixture(t)
+	ctx := tenant.WithTenant(context.Background(), f.src)
+	s3Req := &S3Request{Bucket: "src", TenantID: f.src.ID}
+
+	r := httptest.NewRequest("GET", "/src?replication", nil).WithContext(ctx)
+	w := httptest.NewRecorder()
+	f.server.handleGetBucketReplication(w, r, s3Req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Contains(t, w.Body.String(), ErrReplicationConfigurationNotFound)
+
+	w = f.putConfig(t, `<ReplicationConfiguration><Rule><ID>all</ID><Status>Enabled</Status>
+		<Destination><Bucket>arn:aws:s3:::dst</Bucket></Destination></Rule></ReplicationConfiguration>`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	r = httptest.NewRequest("GET", "/src?replication", nil).WithContext(ctx)
+	w = httptest.NewRecorder()
+	f.server.handleGetBucketReplication(w, r, s3Req)
+	require.Equal(t, http.StatusOK, w.Code)
+	var resp ReplicationConfiguration
+	require.NoError(t, xml.Unmarshal(w.Body.Bytes(), &resp))
+	require.Len(t, resp.Rules, 1)
+	assert.Equal(t, "all", resp.Rules[0].ID)
+
+	r = httptest.NewRequest("DELETE", "/src?replication", nil).WithContext(ctx)
+	w = httptest.NewRecorder()
+	f.server.handleDeleteBucketReplication(w, r, s3Req)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+}
+
+func TestPutBucketReplication_Destinations(t *testing.T) {
+	f := setupReplicationFixture(t)
+
+	w := f.putConfig(t, `<ReplicationConfiguration><Rule><Status>Enabled</Status>
+		<Destination><Bucket>arn:aws:s3:::absent</Bucket></Destination></Rule></ReplicationConfiguration>`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), ErrInvalidRequest)
+
+	crossTenant := fmt.Sprintf(`<ReplicationConfiguration><Rule><Status>Enabled</Status>
+		<Destination><Bucket>arn:aws:s3:::dst</Bucket><Account>%s</Account></Destination></Rule></ReplicationConfiguration>`, f.dst.ID)
+	w = f.putConfig(t, crossTenant)
+	assert.Equal(t, http.StatusForbidden, w.Code, "another tenant's bucket needs a policy grant")
+
+	_, err := f.db.Exec(`UPDATE buckets SET policy = $3 WHERE tenant_id = $1 AND name = $2`, f.dst.ID, "dst",
+		fmt.Sprintf(`{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Principal":{"AWS":"arn:aws:iam::%s:root"},"Action":"s3:ReplicateObject","Resource":"arn:aws:s3:::dst/*"}]}`, f.src.ID))
+	require.NoError(t, err)
+	w = f.putConfig(t, crossTenant)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+}
+
+func TestReplicationRunner_ReplicatesAndDeletes(t *testing.T) {
+	f := setupReplicationFixture(t)
+	ctx := context.Background()
+
+	w := f.putConfig(t, `<ReplicationConfiguration><Rule><ID>logs</ID><Status>Enabled</Status>
+		<Filter><Prefix>logs/</Prefix></Filter>
+		<DeleteMarkerReplication><Status>Enabled</Status></DeleteMarkerReplication>
+		<Destination><Bucket>arn:aws:s3:::dst</Bucket></Destination></Rule></ReplicationConfiguration>`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	f.put(t, "logs/a.txt", []byte("hello replica"))
+	f.put(t, "data/b.txt", []byte("not replicated"))
+	assert.Equal(t, replicationPending, f.status(t, f.src, "src", "logs/a.txt"))
+	assert.Equal(t, "", f.status(t, f.src, "src", "data/b.txt"))
+
+	rr := NewReplicationRunner(f.db, f.eng, nil, nil, zap.NewNop())
+	require.NotNil(t, rr)
+	result, err := rr.RunOnce(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.Completed)
+
+	assert.Equal(t, replicationCompleted, f.status(t, f.src, "src", "logs/a.txt"))
+	assert.Equal(t, replicationReplica, f.status(t, f.src, "dst", "logs/a.txt"))
+	assert.Equal(t, "<missing>", f.status(t, f.src, "dst", "data/b.txt"))
+
+	rc, err := f.eng.Get(ctx, f.src.NamespaceContainer("dst"), "logs/a.txt")
+	require.NoError(t, err)
+	data, _ := io.ReadAll(rc)
+	_ = rc.Close()
+	assert.Equal(t, "hello replica", string(data))
+
+	r := httptest.NewRequest("DELETE", "/src/logs/a.txt", nil)
+	r = r.WithContext(tenant.WithTenant(r.Context(), f.src))
+	dw := httptest.NewRecorder()
+	f.server.handleDeleteObject(dw, r, &S3Request{Bucket: "src", Object: "logs/a.txt", TenantID: f.src.ID})
+	require.Equal(t, http.StatusNoContent, dw.Code)
+
+	result, err = rr.RunOnce(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.Completed)
+	assert.Equal(t, "<missing>", f.status(t, f.src, "dst", "logs/a.txt"), "the replica follows the delete")
+}
@@ -0,0 +1,280 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/FairForge/vaultaire/internal/crypto"
+	"github.com/FairForge/vaultaire/internal/engine"
+	"github.com/FairForge/vaultaire/internal/s3select"
+	"github.com/FairForge/vaultaire/internal/tenant"
+	"go.uber.org/zap"
+)
+
+// maxSelectRequestBody bounds the SelectObjectContent request document; the
+// SQL expression itself is limited to 256 KiB.
+const maxSelectRequestBody = 1 << 20
+
+// limitedReadCloser reads at most a fixed number of bytes and closes the
+// underlying reader.
+type limitedReadCloser struct {
+	io.Reader
+	c io.Closer
+}
+
+func (l limitedReadCloser) Close() error { return l.c.Close() }
+
+// handleSelectObjectContent runs an S3 Select query (POST ?select&select-type=2)
+// over a CSV, JSON or Parquet object and streams the matching records back
+// in the event stream framing. The object is read range by range, never
+// buffered whole, except for whole-object encrypted objects, which must be
+// decrypted in memory as GET does.
+func (s *Server) handleSelectObjectContent(w http.ResponseWriter, r *http.Request, req *S3Request) {
+	t, err := tenant.FromContext(r.Context())
+	if err != nil || t == nil {
+		WriteS3Error(w, ErrAccessDenied, r.URL.Path, generateRequestID())
+		return
+	}
+	if r.URL.Query().Get("select-type") != "2" {
+		WriteS3ErrorWithContext(w, ErrInvalidArgument, r.URL.Path, generateRequestID(),
+			WithSuggestion("SelectObjectContent requires select-type=2."))
+		return
+	}
+
+	selReq, err := s3select.ParseRequest(io.LimitReader(r.Body, maxSelectRequestBody))
+	if err != nil {
+		writeSelectError(w, r, err)
+		return
+	}
+	query, err := s3select.Compile(selReq)
+	if err != nil {
+		writeSelectError(w, r, err)
+		return
+	}
+
+	bucket, key := req.Bucket, req.Object
+	container := t.NamespaceContainer(bucket)
+
+	// Head row: size for ranged reads, encryption and chunking to pick the
+	// read path. Without a database (test mode) the object is read whole.
+	size := int64(-1)
+	var encAlgo string
+	var chunked, segmented bool
+	var kmsKeyID, kmsDataKey, kmsContext string
+	if s.db != nil {
+		err := s.db.QueryRowContext(r.Context(), `
+			SELECT size_bytes, COALESCE(encryption_algorithm, ''), is_chunked, sse_segmented,
+			       COALESCE(sse_kms_key_id, ''), COALESCE(sse_kms_data_key, ''), COALESCE(sse_kms_context, '')
+			FROM object_head_cache
+			WHERE tenant_id = $1 AND bucket = $2 AND object_key = $3`,
+			t.ID, bucket, key).Scan(&size, &encAlgo, &chunked, &segmented, &kmsKeyID, &kmsDataKey, &kmsContext)
+		if err == sql.ErrNoRows {
+			WriteS3Error(w, ErrNoSuchKey, r.URL.Path, generateRequestID())
+			return
+		}
+		if err != nil {
+			s.logger.Error("select: head lookup failed", zap.Error(err))
+			WriteS3Error(w, ErrInternalError, r.URL.Path, generateRequestID())
+			return
+		}
+	}
+
+	var src s3select.Source
+	switch {
+	case chunked && s.gci != nil:
+		src = s3select.Source{Size: size, Open: func(ctx context.Context, offset, length int64) (io.ReadCloser, error) {
+			if length < 0 || offset+length > size {
+				length = size - offset
+			}
+			slices, err := s.sourceChunkSlices(ctx, t.ID, bucket, key, offset, length)
+			if err != nil {
+				return nil, err
+			}
+			return s.chunkSliceReader(ctx, t.ID, slices), nil
+		}}
+	case segmented && encAlgo != "":
+		// Segmented SSE objects are decrypted per range like plain ones.
+		env, err := parseKMSEnvelope(kmsKeyID, kmsDataKey, kmsContext)
+		if err != nil {
+			writeSSEStreamError(w, r, s.logger, err)
+			return
+		}
+		hdr, err := s.engine.GetRange(r.Context(), container, key, 0, sseStreamHeaderSize(encAlgo))
+		if err != nil {
+			writeSelectSourceError(w, r, s.logger, err)
+			return
+		}
+		stream, err := openSSEStream(r.Context(), r, s.sseService, s.kms.provider, t.ID, encAlgo, env, hdr)
+		_ = hdr.Close()
+		if err != nil {
+			writeSSEStreamError(w, r, s.logger, err)
+			return
+		}
+		src = s3select.Source{Size: size, Open: func(ctx context.Context, offset, length int64) (io.ReadCloser, error) {
+			if length < 0 || offset+length > size {
+				length = size - offset
+			}
+			if length <= 0 {
+				return io.NopCloser(bytes.NewReader(nil)), nil
+			}
+			return sseStreamRange(ctx, s.engine, stream, container, key, size, offset, length)
+		}}
+	case encAlgo != "" || size < 0:
+		plaintext, ok := s.readSelectObjectWhole(w, r, t, container, key, encAlgo)
+		if !ok {
+			return
+		}
+		src = bytesSelectSource(plaintext)
+	default:
+		src = s3select.Source{Size: size, Open: func(ctx context.Context, offset, length int64) (io.ReadCloser, error) {
+			if length < 0 || offset+length > size {
+				length = size - offset
+			}
+			if offset == 0 && length == size {
+				return s.engine.Get(ctx, container, key)
+			}
+			rc, err := s.engine.GetRange(ctx, container, key, offset, length)
+			if err != nil {
+				return nil, err
+			}
+			// Backends without a native ranged GET return the rest of the
+			// object after skipping the offset.
+			return limitedReadCloser{Reader: io.LimitReader(rc, length), c: rc}, nil
+		}}
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	rc := http.NewResponseController(w)
+	stats, err := query.Run(r.Context(), src, w, func() { _ = rc.Flush() })
+	if err != nil {
+		var se *s3select.Error
+		if !errors.As(err, &se) && r.Context().Err() == nil {
+			// Run writes nothing for source failures before the first
+			// event, so a plain HTTP error can still be sent.
+			writeSelectSourceError(w, r, s.logger, err)
+			return
+		}
+		s.logger.Warn("select query failed",
+			zap.String("tenant_id", t.ID),
+			zap.String("bucket", bucket),
+			zap.String("key", key),
+			zap.Error(err))
+		return
+	}
+
+	s.logger.Debug("select query completed",
+		zap.String("tenant_id", t.ID),
+		zap.String("bucket", bucket),
+		zap.String("key", key),
+		zap.Int64("bytes_scanned", stats.BytesScanned),
+		zap.Int64("bytes_returned", stats.BytesReturned))
+}
+
+// readSelectObjectWhole reads and, if needed, decrypts an object that can
+// only be read in full. It writes the error response itself when it fails.
+func (s *Server) readSelectObjectWhole(w http.ResponseWriter, r *http.Request, t *tenant.Tenant,
+	container, key, encAlgo string) ([]byte, bool) {
+	if encAlgo == crypto.SSECAlgorithm {
+		if !crypto.HasSSECHeaders(r) {
+			WriteS3ErrorWithContext(w, ErrAccessDenied, r.URL.Path, generateRequestID(),
+				WithSuggestion("This object was encrypted with SSE-C. Provide the encryption key."))
+			return nil, false
+		}
+	}
+
+	reader, err := s.engine.Get(r.Context(), container, key)
+	if err != nil {
+		writeSelectSourceError(w, r, s.logger, err)
+		return nil, false
+	}
+	defer func() { _ = reader.Close() }()
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		s.logger.Error("select: failed to read object", zap.Error(err))
+		WriteS3Error(w, ErrInternalError, r.URL.Path, generateRequestID())
+		return nil, false
+	}
+
+	switch {
+	case encAlgo == crypto.SSECAlgorithm:
+		ssecKey, parseErr := crypto.ParseSSECHeaders(r)
+		if parseErr != nil {
+			WriteS3ErrorWithContext(w, ErrInvalidRequest, r.URL.Path, generateRequestID(),
+				WithSuggestion(parseErr.Error()))
+			return nil, false
+		}
+		plaintext, decErr := crypto.SSECDecrypt(ssecKey, data)
+		for i := range ssecKey {
+			ssecKey[i] = 0
+		}
+		if decErr != nil {
+			if errors.Is(decErr, crypto.ErrSSECKeyMismatch) {
+				WriteS3ErrorWithContext(w, ErrAccessDenied, r.URL.Path, generateRequestID(),
+					WithSuggestion("The provided encryption key does not match."))
+				return nil, false
+			}
+			s.logger.Error("select: SSE-C decryption failed", zap.Error(decErr))
+			WriteS3Error(w, ErrInternalError, r.URL.Path, generateRequestID())
+			return nil, false
+		}
+		return plaintext, true
+	case encAlgo != "" && s.sseService != nil:
+		plaintext, decErr := s.sseService.DecryptBytes(r.Context(), t.ID, data)
+		if decErr != nil {
+			s.logger.Error("select: SSE-S3 decryption failed", zap.Error(decErr))
+			WriteS3Error(w, ErrInternalError, r.URL.Path, generateRequestID())
+			return nil, false
+		}
+		return plaintext, true
+	}
+	return data, true
+}
+
+func bytesSelectSource(data []byte) s3select.Source {
+	return s3select.Source{
+		Size: int64(len(data)),
+		Open: func(_ context.Context, offset, length int64) (io.ReadCloser, error) {
+			end := int64(len(data))
+			if length >= 0 && offset+length < end {
+				end = offset + length
+			}
+			if offset > end {
+				offset = end
+			}
+			return io.NopCloser(bytes.NewReader(data[offset:end])), nil
+		},
+	}
+}
+
+// writeSelectError answers a request or SQL error found before streaming.
+func writeSelectError(w http.ResponseWriter, r *http.Request, err error) {
+	var se *s3select.Error
+	if errors.As(err, &se) {
+		WriteS3ErrorWithContext(w, se.Code, r.URL.Path, generateRequestID(), WithSuggestion(se.Message))
+		return
+	}
+	WriteS3Error(w, ErrInternalError, r.URL.Path, generateRequestID())
+}
+
+// writeSelectSourceError maps a failure to open the queried object, as GET
+// does.
+func writeSelectSourceError(w http.ResponseWriter, r *http.Request, logger *zap.Logger, err error) {
+	switch {
+	case errors.Is(err, engine.ErrAllBackendsUnavailable):
+		w.Header().Set("Retry-After", "30")
+		WriteS3Error(w, ErrServiceUnavailable, r.URL.Path, generateRequestID())
+	case errors.Is(err, engine.ErrArchived):
+		w.Header().Set("x-amz-storage-class", "GLACIER")
+		WriteS3ErrorWithContext(w, ErrInvalidObjectState, r.URL.Path, generateRequestID(),
+			WithSuggestion("This object is archived on tape. Restore it before querying it."))
+	case isObjectMissingErr(err):
+		WriteS3Error(w, ErrNoSuchKey, r.URL.Path, generateRequestID())
+	default:
+		logger.Error("select: failed to open object", zap.Error(err))
+		WriteS3Error(w, ErrInternalError, r.URL.Path, generateRequestID())
+	}
+}
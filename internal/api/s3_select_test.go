@@ -0,0 +1,70 @@
+package api
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func selectRequestBody(expression string) string {
+	return `<SelectObjectContentRequest xmlns="http://s3.amazonaws.com/doc/2006-03-01/">
+  <Expression>` + expression + `</Expression>
+  <ExpressionType>SQL</ExpressionType>
+  <InputSerialization><CSV><FileHeaderInfo>USE</FileHeaderInfo></CSV></InputSerialization>
+  <OutputSerialization><CSV/></OutputSerialization>
+</SelectObjectContentRequest>`
+}
+
+func TestSelectObjectContent_CSV(t *testing.T) {
+	srv, tnt, _ := newTestMultipartServer(t)
+
+	csv := "name,age\nalice,31\nbob,17\ncarol,45\n"
+	putW := doS3Request(srv, tnt, "PUT", "/test-bucket/people.csv", strings.NewReader(csv))
+	require.Equal(t, http.StatusOK, putW.Code)
+
+	w := doS3Request(srv, tnt, "POST", "/test-bucket/people.csv?select&select-type=2",
+		strings.NewReader(selectRequestBody("SELECT s.name FROM S3Object s WHERE CAST(s.age AS INT) &gt; 18")))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, "application/octet-stream", w.Header().Get("Content-Type"))
+
+	body := w.Body.Bytes()
+	assert.True(t, bytes.Contains(body, []byte("alice\ncarol\n")))
+	assert.False(t, bytes.Contains(body, []byte("bob")))
+	assert.True(t, bytes.Contains(body, []byte("Records")))
+	assert.True(t, bytes.Contains(body, []byte("End")))
+}
+
+func TestSelectObjectContent_Errors(t *testing.T) {
+	srv, tnt, _ := newTestMultipartServer(t)
+
+	putW := doS3Request(srv, tnt, "PUT", "/test-bucket/data.csv", strings.NewReader("a,b\n1,2\n"))
+	require.Equal(t, http.StatusOK, putW.Code)
+
+	tests := []struct {
+		name     string
+		path     string
+		body     string
+		wantCode int
+		wantErr  string
+	}{
+		{"missing select-type", "/test-bucket/data.csv?select", selectRequestBody("SELECT * FROM S3Object"),
+			http.StatusBadRequest, "InvalidArgument"},
+		{"malformed xml", "/test-bucket/data.csv?select&select-type=2", "<SelectObjectContentRequest>",
+			http.StatusBadRequest, "MalformedXML"},
+		{"bad sql", "/test-bucket/data.csv?select&select-type=2", selectRequestBody("SELECT FROM WHERE"),
+			http.StatusBadRequest, "ParseUnexpectedToken"},
+		{"missing key", "/test-bucket/nope.csv?select&select-type=2", selectRequestBody("SELECT * FROM S3Object"),
+			http.StatusNotFound, "NoSuchKey"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := doS3Request(srv, tnt, "POST", tt.path, strings.NewReader(tt.body))
+			assert.Equal(t, tt.wantCode, w.Code)
+			assert.Contains(t, w.Body.String(), "<Code>"+tt.wantErr+"</Code>")
+		})
+	}
+}
@@ -0,0 +1,283 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/FairForge/vaultaire/internal/crypto"
+)
+
+// SSE-KMS (x-amz-server-side-encryption: aws:kms) encrypts an object as a
+// segmented stream under a per-object data key from the configured
+// crypto.KeyProvider. The data key wrapped under the named customer key, the
+// key ID and the encryption context are kept in object_head_cache
+// (sse_kms_*); every read unwraps the data key at the provider, so revoking
+// the key there makes the object unreadable.
+
+const (
+	kmsKeyIDHeader   = "x-amz-server-side-encryption-aws-kms-key-id"
+	kmsContextHeader = "x-amz-server-side-encryption-context"
+
+	// kmsContextARN is the context entry binding a data key to its object.
+	kmsContextARN = "aws:s3:arn"
+)
+
+var errKMSUnavailable = errors.New("sse-kms: no key provider is configured")
+
+// kmsProviderError is a failure reported by the key provider.
+type kmsProviderError struct{ err error }
+
+func (e *kmsProviderError) Error() string { return e.err.Error() }
+func (e *kmsProviderError) Unwrap() error { return e.err }
+
+// kmsConfig is the server's SSE-KMS setup: the key provider (nil when
+// SSE-KMS is off) and the key used when neither the request nor the bucket
+// default names one (VAULTAIRE_KMS_DEFAULT_KEY_ID).
+type kmsConfig struct {
+	provider     crypto.KeyProvider
+	defaultKeyID string
+}
+
+// kmsEnvelope is what an SSE-KMS object stores about its data key.
+type kmsEnvelope struct {
+	KeyID   string
+	Wrapped []byte
+	Context crypto.EncryptionContext
+}
+
+// dbValues returns the sse_kms_key_id, sse_kms_data_key and sse_kms_context
+// column values; all NULL for a nil envelope.
+func (e *kmsEnvelope) dbValues() (keyID, wrapped, encCtx interface{}) {
+	if e == nil {
+		return nil, nil, nil
+	}
+	ctxJSON, _ := json.Marshal(e.Context)
+	return e.KeyID, base64.StdEncoding.EncodeToString(e.Wrapped), string(ctxJSON)
+}
+
+// envelopeKeyID is the KMS key of an object's envelope, "" when it has none.
+func envelopeKeyID(e *kmsEnvelope) string {
+	if e == nil {
+		return ""
+	}
+	return e.KeyID
+}
+
+// parseKMSEnvelope rebuilds an envelope from its columns (COALESCEd to "").
+// It returns nil when the object is not SSE-KMS.
+func parseKMSEnvelope(keyID, wrapped, encCtx string) (*kmsEnvelope, error) {
+	if keyID == "" {
+		return nil, nil
+	}
+	env := &kmsEnvelope{KeyID: keyID}
+	var err error
+	if env.Wrapped, err = base64.StdEncoding.DecodeString(wrapped); err != nil {
+		return nil, fmt.Errorf("sse-kms: stored data key: %w", err)
+	}
+	if encCtx != "" {
+		if err := json.Unmarshal([]byte(encCtx), &env.Context); err != nil {
+			return nil, fmt.Errorf("sse-kms: stored encryption context: %w", err)
+		}
+	}
+	return env, nil
+}
+
+// bucketEncryption is a bucket's default encryption: SSE-S3 when SSEEnabled,
+// SSE-KMS when KMS is set (KMSKeyID "" meaning the service default key).
+type bucketEncryption struct {
+	SSEEnabled bool
+	KMS        bool
+	KMSKeyID   string
+}
+
+func loadBucketEncryption(ctx context.Context, db *sql.DB, tenantID, bucket string) bucketEncryption {
+	var enc bucketEncryption
+	if db == nil {
+		return enc
+	}
+	var kmsKey sql.NullString
+	err := db.QueryRowContext(ctx,
+		"SELECT sse_enabled, sse_kms_key_id FROM buckets WHERE tenant_id = $1 AND name = $2",
+		tenantID, bucket).Scan(&enc.SSEEnabled, &kmsKey)
+	if err != nil {
+		return bucketEncryption{}
+	}
+	enc.KMS, enc.KMSKeyID = kmsKey.Valid, kmsKey.String
+	return enc
+}
+
+// sseWrite is the SSE-S3 or SSE-KMS encryption a write asks for, from its
+// headers or else the bucket default.
+type sseWrite struct {
+	Algorithm  string // "", crypto.SSEAlgorithm or crypto.SSEKMSAlgorithm
+	KMSKeyID   string
+	KMSContext crypto.EncryptionContext
+}
+
+// sseRequestError is an SSE request the client must fix.
+type sseRequestError struct {
+	code string
+	msg  string
+}
+
+func (e *sseRequestError) Error() string { return e.msg }
+
+// resolveSSEWrite decides the server-side encryption of a write of
+// bucket/key. The bucket default is only looked up when it can matter:
+// sseConfigured reports whether SSE-S3 is available.
+func resolveSSEWrite(ctx context.Context, r *http.Request, db *sql.DB, kms kmsConfig, sseConfigured bool,
+	tenantID, bucket, key string) (sseWrite, error) {
+	algo := r.Header.Get("x-amz-server-side-encryption")
+	keyID := normalizeKMSKeyID(r.Header.Get(kmsKeyIDHeader))
+	ctxHeader := r.Header.Get(kmsContextHeader)
+	if algo != crypto.SSEKMSAlgorithm && (keyID != "" || ctxHeader != "") {
+		return sseWrite{}, &sseRequestError{code: ErrInvalidArgument,
+			msg: "The KMS key ID and encryption context require x-amz-server-side-encryption: aws:kms."}
+	}
+
+	switch algo {
+	case "AES256":
+		return sseWrite{Algorithm: crypto.SSEAlgorithm}, nil
+	case "":
+		if !sseConfigured && kms.provider == nil {
+			return sseWrite{}, nil
+		}
+		def := loadBucketEncryption(ctx, db, tenantID, bucket)
+		if !def.KMS {
+			if def.SSEEnabled {
+				return sseWrite{Algorithm: crypto.SSEAlgorithm}, nil
+			}
+			return sseWrite{}, nil
+		}
+		keyID = def.KMSKeyID
+	case crypto.SSEKMSAlgorithm:
+		if keyID == "" {
+			if def := loadBucketEncryption(ctx, db, tenantID, bucket); def.KMS {
+				keyID = def.KMSKeyID
+			}
+		}
+	default:
+		return sseWrite{}, &sseRequestError{code: ErrInvalidArgument,
+			msg: fmt.Sprintf("Unsupported x-amz-server-side-encryption %q; use AES256 or aws:kms.", algo)}
+	}
+
+	if kms.provider == nil {
+		return sseWrite{}, &sseRequestError{code: ErrInvalidArgument,
+			msg: "SSE-KMS is not configured on this server."}
+	}
+	if keyID == "" {
+		keyID = kms.defaultKeyID
+	}
+	if keyID == "" {
+		return sseWrite{}, &sseRequestError{code: ErrInvalidArgument,
+			msg: "No KMS key was specified and the server has no default key; set x-amz-server-side-encryption-aws-kms-key-id."}
+	}
+	encCtx, err := parseKMSContext(ctxHeader)
+	if err != nil {
+		return sseWrite{}, err
+	}
+	encCtx[kmsContextARN] = "arn:aws:s3:::" + bucket + "/" + key
+	return sseWrite{Algorithm: crypto.SSEKMSAlgorithm, KMSKeyID: keyID, KMSContext: encCtx}, nil
+}
+
+// normalizeKMSKeyID accepts a key ID or a key ARN
+// (arn:aws:kms:region:account:key/ID) and returns the key ID.
+func normalizeKMSKeyID(id string) string {
+	id = strings.TrimSpace(id)
+	if strings.HasPrefix(id, "arn:") {
+		if i := strings.Index(id, ":key/"); i >= 0 {
+			return id[i+len(":key/"):]
+		}
+	}
+	return id
+}
+
+// parseKMSContext decodes x-amz-server-side-encryption-context, a base64
+// JSON object of string pairs. The aws: prefix is reserved for entries the
+// service adds.
+func parseKMSContext(header string) (crypto.EncryptionContext, error) {
+	encCtx := crypto.EncryptionContext{}
+	if header == "" {
+		return encCtx, nil
+	}
+	raw, err := base64.StdEncoding.DecodeString(header)
+	if err != nil {
+		return nil, &sseRequestError{code: ErrInvalidArgument, msg: "The encryption context must be base64-encoded JSON."}
+	}
+	if err := json.Unmarshal(raw, &encCtx); err != nil {
+		return nil, &sseRequestError{code: ErrInvalidArgument, msg: "The encryption context must be a JSON object of string values."}
+	}
+	for k := range encCtx {
+		if strings.HasPrefix(strings.ToLower(k), "aws:") {
+			return nil, &sseRequestError{code: ErrInvalidArgument, msg: `Encryption context keys may not start with "aws:".`}
+		}
+	}
+	return encCtx, nil
+}
+
+// newKMSStream generates the data key for an SSE-KMS write and starts its
+// stream. Only the wrapped form of the data key outlives the call.
+func newKMSStream(ctx context.Context, kms kmsConfig, want sseWrite) (*crypto.StreamCipher, *kmsEnvelope, error) {
+	if kms.provider == nil {
+		return nil, nil, errKMSUnavailable
+	}
+	dataKey, wrapped, err := kms.provider.GenerateDataKey(ctx, want.KMSKeyID, want.KMSContext)
+	if err != nil {
+		return nil, nil, &kmsProviderError{err: err}
+	}
+	defer zeroKey(dataKey)
+	stream, err := crypto.NewKMSStream(dataKey, want.KMSContext)
+	if err != nil {
+		return nil, nil, err
+	}
+	return stream, &kmsEnvelope{KeyID: want.KMSKeyID, Wrapped: wrapped, Context: want.KMSContext}, nil
+}
+
+// openKMSStream unwraps an SSE-KMS object's data key at the provider and
+// opens the stream whose header is at the front of src.
+func openKMSStream(ctx context.Context, provider crypto.KeyProvider, env *kmsEnvelope, src io.Reader) (*crypto.StreamCipher, error) {
+	if provider == nil {
+		return nil, errKMSUnavailable
+	}
+	if env == nil {
+		return nil, errors.New("sse-kms: object has no stored data key")
+	}
+	dataKey, err := provider.DecryptDataKey(ctx, env.KeyID, env.Wrapped, env.Context)
+	if err != nil {
+		return nil, &kmsProviderError{err: err}
+	}
+	defer zeroKey(dataKey)
+	header := make([]byte, crypto.SSECStreamHeaderSize)
+	if _, err := io.ReadFull(src, header); err != nil {
+		return nil, fmt.Errorf("read SSE-KMS stream header: %w", err)
+	}
+	return crypto.OpenKMSStream(dataKey, header, env.Context)
+}
+
+func zeroKey(key []byte) {
+	for i := range key {
+		key[i] = 0
+	}
+}
+
+// kmsErrorCode maps a key provider failure to its S3 error code; ok is
+// false for failures that are not the client's (provider unreachable).
+func kmsErrorCode(err error) (code string, ok bool) {
+	switch {
+	case errors.Is(err, crypto.ErrKMSKeyNotFound):
+		return ErrKMSNotFound, true
+	case errors.Is(err, crypto.ErrKMSKeyDisabled):
+		return ErrKMSDisabled, true
+	case errors.Is(err, crypto.ErrKMSAccessDenied), errors.Is(err, crypto.ErrKMSInvalidCiphertext):
+		return ErrAccessDenied, true
+	default:
+		return ErrServiceUnavailable, false
+	}
+}
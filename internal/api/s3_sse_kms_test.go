@@ -0,0 +1,166 @@
+package api
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/FairForge/vaultaire/internal/crypto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeTestKeyring writes a local keyring holding keyID with fixed
+// material. Disabling moves the mtime forward so the rewrite is noticed on
+// filesystems with coarse timestamps.
+func writeTestKeyring(t *testing.T, path, keyID string, enabled bool) {
+	t.Helper()
+	material := base64.StdEncoding.EncodeToString([]byte("0123456789abcdef0123456789abcdef"))
+	doc := fmt.Sprintf(`{"keys":[{"id":%q,"material":%q,"enabled":%t}]}`, keyID, material, enabled)
+	require.NoError(t, os.WriteFile(path, []byte(doc), 0o600))
+	if !enabled {
+		later := time.Now().Add(time.Hour)
+		require.NoError(t, os.Chtimes(path, later, later))
+	}
+}
+
+func newTestKMS(t *testing.T, keyID string) (kmsConfig, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "keyring.json")
+	writeTestKeyring(t, path, keyID, true)
+	kr, err := crypto.NewLocalKeyring(path)
+	require.NoError(t, err)
+	return kmsConfig{provider: kr}, path
+}
+
+func kmsContextHeaderValue(json string) string {
+	return base64.StdEncoding.EncodeToString([]byte(json))
+}
+
+func TestResolveSSEWrite(t *testing.T) {
+	kms, _ := newTestKMS(t, "app-key")
+	ctx := context.Background()
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+		kms     kmsConfig
+		want    sseWrite
+		errCode string
+	}{
+		{name: "nothing configured", kms: kmsConfig{}},
+		{name: "SSE-S3 header", headers: map[string]string{"x-amz-server-side-encryption": "AES256"},
+			want: sseWrite{Algorithm: crypto.SSEAlgorithm}},
+		{name: "KMS with key and context", kms: kms, headers: map[string]string{
+			"x-amz-server-side-encryption": "aws:kms",
+			kmsKeyIDHeader:                 "arn:aws:kms:us-east-1:111122223333:key/app-key",
+			kmsContextHeader:               kmsContextHeaderValue(`{"department":"finance"}`),
+		}, want: sseWrite{Algorithm: crypto.SSEKMSAlgorithm, KMSKeyID: "app-key", KMSContext: crypto.EncryptionContext{
+			"department": "finance", kmsContextARN: "arn:aws:s3:::b/k",
+		}}},
+		{name: "KMS falls back to the server default key",
+			kms:     kmsConfig{provider: kms.provider, defaultKeyID: "app-key"},
+			headers: map[string]string{"x-amz-server-side-encryption": "aws:kms"},
+			want: sseWrite{Algorithm: crypto.SSEKMSAlgorithm, KMSKeyID: "app-key",
+				KMSContext: crypto.EncryptionContext{kmsContextARN: "arn:aws:s3:::b/k"}}},
+		{name: "KMS without a key", kms: kms, headers: map[string]string{"x-amz-server-side-encryption": "aws:kms"},
+			errCode: ErrInvalidArgument},
+		{name: "KMS not configured", headers: map[string]string{"x-amz-server-side-encryption": "aws:kms", kmsKeyIDHeader: "app-key"},
+			errCode: ErrInvalidArgument},
+		{name: "key ID without aws:kms", kms: kms, headers: map[string]string{kmsKeyIDHeader: "app-key"},
+			errCode: ErrInvalidArgument},
+		{name: "reserved context key", kms: kms, headers: map[string]string{
+			"x-amz-server-side-encryption": "aws:kms", kmsKeyIDHeader: "app-key",
+			kmsContextHeader: kmsContextHeaderValue(`{"aws:s3:arn":"arn:aws:s3:::other/k"}`),
+		}, errCode: ErrInvalidArgument},
+		{name: "unknown algorithm", headers: map[string]string{"x-amz-server-side-encryption": "aws:kms:dsse"},
+			errCode: ErrInvalidArgument},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("PUT", "/b/k", nil)
+			for k, v := range tt.headers {
+				r.Header.Set(k, v)
+			}
+			got, err := resolveSSEWrite(ctx, r, nil, tt.kms, false, "tenant-1", "b", "k")
+			if tt.errCode != "" {
+				var reqErr *sseRequestError
+				require.ErrorAs(t, err, &reqErr)
+				assert.Equal(t, tt.errCode, reqErr.code)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestResolveSSEWrite_BucketDefaultKMSKey(t *testing.T) {
+	kms, _ := newTestKMS(t, "bucket-key")
+	s, mock := newPolicyTestServer(t)
+	mock.ExpectQuery(`SELECT sse_enabled, sse_kms_key_id FROM buckets`).
+		WithArgs("tenant-1", "b").
+		WillReturnRows(sqlmock.NewRows([]string{"sse_enabled", "sse_kms_key_id"}).AddRow(false, "bucket-key"))
+
+	r := httptest.NewRequest("PUT", "/b/k", nil)
+	got, err := resolveSSEWrite(context.Background(), r, s.db, kms, true, "tenant-1", "b", "k")
+	require.NoError(t, err)
+	assert.Equal(t, crypto.SSEKMSAlgorithm, got.Algorithm)
+	assert.Equal(t, "bucket-key", got.KMSKeyID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSSEKMS_RoundTripAndRevocation(t *testing.T) {
+	f := setupAdapterFixture(t)
+	kms, keyring := newTestKMS(t, "app-key")
+	f.adapter.kms = kms
+
+	content := generateTestData(2*crypto.StreamSegmentSize + 31)
+	w := streamPut(t, f, "kms.bin", content, func(r *http.Request) {
+		r.Header.Set("x-amz-server-side-encryption", "aws:kms")
+		r.Header.Set(kmsKeyIDHeader, "app-key")
+	})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, "aws:kms", w.Header().Get("x-amz-server-side-encryption"))
+	assert.Equal(t, "app-key", w.Header().Get(kmsKeyIDHeader))
+
+	// Only the wrapped data key is stored, bound to the object's ARN.
+	var keyID, wrapped, encCtx string
+	require.NoError(t, f.db.QueryRow(`
+		SELECT sse_kms_key_id, sse_kms_data_key, sse_kms_context FROM object_head_cache
+		WHERE tenant_id = $1 AND bucket = $2 AND object_key = $3`,
+		f.tenantID, "test-bucket", "kms.bin").Scan(&keyID, &wrapped, &encCtx))
+	assert.Equal(t, "app-key", keyID)
+	assert.NotEmpty(t, wrapped)
+	assert.Contains(t, encCtx, "arn:aws:s3:::test-bucket/kms.bin")
+
+	gw := streamGet(t, f, "kms.bin", "", nil)
+	require.Equal(t, http.StatusOK, gw.Code, gw.Body.String())
+	assert.Equal(t, content, gw.Body.Bytes())
+	assert.Equal(t, "aws:kms", gw.Header().Get("x-amz-server-side-encryption"))
+
+	rw := streamGet(t, f, "kms.bin", "bytes=70000-70099", nil)
+	require.Equal(t, http.StatusPartialContent, rw.Code)
+	assert.Equal(t, content[70000:70100], rw.Body.Bytes())
+
+	// Disabling the key at the provider makes the object unreadable.
+	writeTestKeyring(t, keyring, "app-key", false)
+	dw := streamGet(t, f, "kms.bin", "", nil)
+	assert.Equal(t, http.StatusBadRequest, dw.Code)
+	assert.Contains(t, dw.Body.String(), ErrKMSDisabled)
+
+	// An unknown key is refused before anything is stored.
+	uw := streamPut(t, f, "other.bin", content[:10], func(r *http.Request) {
+		r.Header.Set("x-amz-server-side-encryption", "aws:kms")
+		r.Header.Set(kmsKeyIDHeader, "no-such-key")
+	})
+	assert.Equal(t, http.StatusBadRequest, uw.Code)
+	assert.Contains(t, uw.Body.String(), ErrKMSNotFound)
+}
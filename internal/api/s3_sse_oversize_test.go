@@ -1,7 +1,10 @@
 package api
 
 import (
+	"bufio"
 	"bytes"
+	"context"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -12,16 +15,44 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
-// 64 hex chars = 32-byte SSE-S3 master key.
-const testSSEMasterKey = "abababababababababababababababababababababababababababababababab"
+// formerSSESizeCap is the 256 MiB limit whole-object SSE used to have.
+// Segmented SSE streams objects of any size; these tests keep the guarantee
+// the cap's guard gave: an object that must be encrypted is never stored
+// in plaintext.
+const formerSSESizeCap = 256 << 20
 
-// oversizePut issues a PUT whose declared Content-Length exceeds the SSE size
-// cap while sending only a tiny body. The reject-guard runs before the body is
-// read, so this exercises the size gate without allocating 256+ MiB.
-func oversizePut(t *testing.T, f *adapterTestFixture, key string, mutate func(*http.Request)) *httptest.ResponseRecorder {
+// oversizeBody yields n bytes repeating block, so an object past the
+// former cap can be PUT without holding it in memory.
+type oversizeBody struct {
+	block  []byte
+	n, off int64
+}
+
+func (b *oversizeBody) Read(p []byte) (int, error) {
+	if b.off >= b.n {
+		return 0, io.EOF
+	}
+	if rem := b.n - b.off; int64(len(p)) > rem {
+		p = p[:rem]
+	}
+	written := 0
+	for written < len(p) {
+		c := copy(p[written:], b.block[(b.off+int64(written))%int64(len(b.block)):])
+		written += c
+	}
+	b.off += int64(written)
+	return written, nil
+}
+
+// oversizePut PUTs an object one byte past the former SSE cap. The
+// fixture has no chunk index, so the object takes the whole-object path.
+func oversizePut(t *testing.T, f *adapterTestFixture, key string, block []byte, mutate func(*http.Request)) *httptest.ResponseRecorder {
 	t.Helper()
-	req := httptest.NewRequest("PUT", "/test-bucket/"+key, bytes.NewReader([]byte("tiny")))
-	req.ContentLength = crypto.MaxEncryptableSize + 1 // just over the cap
+	if testing.Short() {
+		t.Skip("writes a 256 MiB object")
+	}
+	req := httptest.NewRequest("PUT", "/test-bucket/"+key, &oversizeBody{block: block, n: formerSSESizeCap + 1})
+	req.ContentLength = formerSSESizeCap + 1
 	if mutate != nil {
 		mutate(req)
 	}
@@ -31,32 +62,62 @@ func oversizePut(t *testing.T, f *adapterTestFixture, key string, mutate func(*h
 	return w
 }
 
-// TestHandlePut_SSEHeaderOversize_Rejected: an explicit SSE-S3 request for an
-// object larger than the encryptable cap must be rejected, never stored plaintext.
-func TestHandlePut_SSEHeaderOversize_Rejected(t *testing.T) {
-	f := setupChunkingFixture(t)
+// assertStoredEncrypted checks the head cache records key as a segmented
+// SSE object of the oversize length, and that the stored bytes are the
+// ciphertext stream with no run of the plaintext block.
+func assertStoredEncrypted(t *testing.T, f *adapterTestFixture, key string, block []byte) {
+	t.Helper()
+	var size int64
+	var algo string
+	var segmented bool
+	require.NoError(t, f.db.QueryRow(`
+		SELECT size_bytes, COALESCE(encryption_algorithm, ''), sse_segmented FROM object_head_cache
+		WHERE tenant_id = $1 AND bucket = $2 AND object_key = $3`,
+		f.tenantID, "test-bucket", key).Scan(&size, &algo, &segmented))
+	assert.Equal(t, int64(formerSSESizeCap+1), size)
+	assert.Equal(t, crypto.SSEAlgorithm, algo)
+	assert.True(t, segmented)
+
+	rc, err := f.eng.Get(context.Background(), f.tenant.NamespaceContainer("test-bucket"), key)
+	require.NoError(t, err)
+	defer func() { _ = rc.Close() }()
+	segments := int64(formerSSESizeCap/crypto.StreamSegmentSize + 1)
+	var stored int64
+	br := bufio.NewReaderSize(rc, 1<<20)
+	buf := make([]byte, 1<<20)
+	for {
+		n, readErr := br.Read(buf)
+		require.False(t, bytes.Contains(buf[:n], block[:64]), "plaintext found at offset %d", stored)
+		stored += int64(n)
+		if readErr == io.EOF {
+			break
+		}
+		require.NoError(t, readErr)
+	}
+	assert.Equal(t, crypto.SSEStreamHeaderSize+formerSSESizeCap+1+segments*16, stored)
+}
+
+// TestHandlePut_SSEHeaderOversize_Encrypted: an explicit SSE-S3 request for
+// an object past the former cap is stored encrypted.
+func TestHandlePut_SSEHeaderOversize_Encrypted(t *testing.T) {
+	f := setupAdapterFixture(t)
 	svc, err := crypto.NewSSEService(f.db, testSSEMasterKey)
 	require.NoError(t, err)
 	f.adapter.sseService = svc
 
-	w := oversizePut(t, f, "sse-header-big.bin", func(r *http.Request) {
+	block := generateTestData(4096)
+	w := oversizePut(t, f, "sse-header-big.bin", block, func(r *http.Request) {
 		r.Header.Set("x-amz-server-side-encryption", "AES256")
 	})
-
-	require.Equal(t, http.StatusRequestEntityTooLarge, w.Code, "oversize SSE-S3 object must be rejected (EntityTooLarge)")
-
-	// And it must NOT have been stored.
-	var count int
-	require.NoError(t, f.db.QueryRow(`SELECT COUNT(*) FROM object_head_cache
-		WHERE tenant_id=$1 AND bucket=$2 AND object_key=$3`,
-		f.tenantID, "test-bucket", "sse-header-big.bin").Scan(&count))
-	assert.Equal(t, 0, count, "rejected object must not be persisted")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, "AES256", w.Header().Get("x-amz-server-side-encryption"))
+	assertStoredEncrypted(t, f, "sse-header-big.bin", block)
 }
 
-// TestHandlePut_SSEBucketOversize_Rejected: a bucket that defaults to SSE must
-// reject oversize objects rather than silently storing them unencrypted.
-func TestHandlePut_SSEBucketOversize_Rejected(t *testing.T) {
-	f := setupChunkingFixture(t)
+// TestHandlePut_SSEBucketOversize_Encrypted: a bucket that defaults to SSE
+// encrypts oversize objects rather than storing them unencrypted.
+func TestHandlePut_SSEBucketOversize_Encrypted(t *testing.T) {
+	f := setupAdapterFixture(t)
 	svc, err := crypto.NewSSEService(f.db, testSSEMasterKey)
 	require.NoError(t, err)
 	f.adapter.sseService = svc
@@ -71,19 +132,27 @@ func TestHandlePut_SSEBucketOversize_Rejected(t *testing.T) {
 		_, _ = f.db.Exec(`DELETE FROM buckets WHERE tenant_id=$1 AND name=$2`, f.tenantID, "test-bucket")
 	})
 
-	w := oversizePut(t, f, "sse-bucket-big.bin", nil)
-	require.Equal(t, http.StatusRequestEntityTooLarge, w.Code, "oversize object in SSE bucket must be rejected")
+	block := generateTestData(4096)
+	w := oversizePut(t, f, "sse-bucket-big.bin", block, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assertStoredEncrypted(t, f, "sse-bucket-big.bin", block)
 }
 
-// TestHandlePut_NoSSEOversize_NotRejected: large objects WITHOUT encryption must
-// still be accepted (the guard only fires when encryption is required).
+// TestHandlePut_NoSSEOversize_NotRejected: large objects WITHOUT encryption
+// are accepted and stored as sent.
 func TestHandlePut_NoSSEOversize_NotRejected(t *testing.T) {
-	f := setupChunkingFixture(t)
+	f := setupAdapterFixture(t)
 	svc, err := crypto.NewSSEService(f.db, testSSEMasterKey)
 	require.NoError(t, err)
 	f.adapter.sseService = svc // service present, but no SSE requested for this bucket/object
 
-	w := oversizePut(t, f, "plain-big.bin", nil)
-	assert.NotEqual(t, http.StatusRequestEntityTooLarge, w.Code,
-		"large non-encrypted object must not be rejected by the SSE guard")
+	w := oversizePut(t, f, "plain-big.bin", generateTestData(4096), nil)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var algo string
+	require.NoError(t, f.db.QueryRow(`
+		SELECT COALESCE(encryption_algorithm, '') FROM object_head_cache
+		WHERE tenant_id = $1 AND bucket = $2 AND object_key = $3`,
+		f.tenantID, "test-bucket", "plain-big.bin").Scan(&algo))
+	assert.Empty(t, algo)
 }
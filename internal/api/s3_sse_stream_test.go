@@ -0,0 +1,240 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/FairForge/vaultaire/internal/crypto"
+	"github.com/FairForge/vaultaire/internal/tenant"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// 64 hex chars = 32-byte SSE-S3 master key.
+const testSSEMasterKey = "abababababababababababababababababababababababababababababababab"
+
+// streamPut PUTs content through the adapter; mutate adds SSE headers.
+func streamPut(t *testing.T, f *adapterTestFixture, key string, content []byte, mutate func(*http.Request)) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest("PUT", "/test-bucket/"+key, bytes.NewReader(content))
+	req.ContentLength = int64(len(content))
+	if mutate != nil {
+		mutate(req)
+	}
+	req = req.WithContext(tenant.WithTenant(req.Context(), f.tenant))
+	w := httptest.NewRecorder()
+	f.adapter.HandlePut(w, req, "test-bucket", key)
+	return w
+}
+
+// streamGet GETs an object through the adapter, optionally ranged.
+func streamGet(t *testing.T, f *adapterTestFixture, key, rangeHeader string, mutate func(*http.Request)) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest("GET", "/test-bucket/"+key, nil)
+	if rangeHeader != "" {
+		req.Header.Set("Range", rangeHeader)
+	}
+	if mutate != nil {
+		mutate(req)
+	}
+	req = req.WithContext(tenant.WithTenant(req.Context(), f.tenant))
+	w := httptest.NewRecorder()
+	f.adapter.HandleGet(w, req, "test-bucket", key)
+	return w
+}
+
+func TestSSEStream_SSES3_RoundTripAndRange(t *testing.T) {
+	f := setupAdapterFixture(t)
+	svc, err := crypto.NewSSEService(f.db, testSSEMasterKey)
+	require.NoError(t, err)
+	f.adapter.sseService = svc
+
+	content := generateTestData(3*crypto.StreamSegmentSize + 123)
+	w := streamPut(t, f, "stream.bin", content, func(r *http.Request) {
+		r.Header.Set("x-amz-server-side-encryption", "AES256")
+	})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, "AES256", w.Header().Get("x-amz-server-side-encryption"))
+
+	// HEAD/List read the plaintext size from the head cache.
+	var size int64
+	var segmented bool
+	require.NoError(t, f.db.QueryRow(`
+		SELECT size_bytes, sse_segmented FROM object_head_cache
+		WHERE tenant_id = $1 AND bucket = $2 AND object_key = $3`,
+		f.tenantID, "test-bucket", "stream.bin").Scan(&size, &segmented))
+	assert.Equal(t, int64(len(content)), size)
+	assert.True(t, segmented)
+
+	// The backend holds the header plus one tag per segment, never plaintext.
+	rc, err := f.eng.Get(context.Background(), f.tenant.NamespaceContainer("test-bucket"), "stream.bin")
+	require.NoError(t, err)
+	stored, err := io.ReadAll(rc)
+	_ = rc.Close()
+	require.NoError(t, err)
+	assert.Len(t, stored, crypto.SSEStreamHeaderSize+len(content)+4*16)
+	assert.False(t, bytes.Contains(stored, content[:64]))
+
+	gw := streamGet(t, f, "stream.bin", "", nil)
+	require.Equal(t, http.StatusOK, gw.Code)
+	assert.Equal(t, content, gw.Body.Bytes())
+	assert.Equal(t, "AES256", gw.Header().Get("x-amz-server-side-encryption"))
+
+	start, end := crypto.StreamSegmentSize-10, 2*crypto.StreamSegmentSize+10
+	rw := streamGet(t, f, "stream.bin", fmt.Sprintf("bytes=%d-%d", start, end), nil)
+	require.Equal(t, http.StatusPartialContent, rw.Code)
+	assert.Equal(t, fmt.Sprintf("bytes %d-%d/%d", start, end, len(content)), rw.Header().Get("Content-Range"))
+	assert.Equal(t, content[start:end+1], rw.Body.Bytes())
+
+	tw := streamGet(t, f, "stream.bin", "bytes=-5", nil)
+	require.Equal(t, http.StatusPartialContent, tw.Code)
+	assert.Equal(t, content[len(content)-5:], tw.Body.Bytes())
+}
+
+func TestSSEStream_SSEC_RangeRequiresMatchingKey(t *testing.T) {
+	f := setupAdapterFixture(t)
+	key := generateSSECKey(t)
+	content := generateTestData(crypto.StreamSegmentSize + 500)
+
+	w := streamPut(t, f, "ssec-stream.bin", content, func(r *http.Request) { setSSECHeaders(t, r, key) })
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	rw := streamGet(t, f, "ssec-stream.bin", "bytes=100-70000", func(r *http.Request) { setSSECHeaders(t, r, key) })
+	require.Equal(t, http.StatusPartialContent, rw.Code)
+	assert.Equal(t, content[100:70001], rw.Body.Bytes())
+	assert.Equal(t, "AES256", rw.Header().Get("x-amz-server-side-encryption-customer-algorithm"))
+
+	wrong := generateSSECKey(t)
+	ww := streamGet(t, f, "ssec-stream.bin", "bytes=100-200", func(r *http.Request) { setSSECHeaders(t, r, wrong) })
+	assert.Equal(t, http.StatusForbidden, ww.Code)
+	assert.Contains(t, ww.Body.String(), "does not match")
+
+	nw := streamGet(t, f, "ssec-stream.bin", "bytes=100-200", nil)
+	assert.Equal(t, http.StatusForbidden, nw.Code)
+}
+
+func TestSSEStream_SSEC_EmptyObject(t *testing.T) {
+	f := setupAdapterFixture(t)
+	key := generateSSECKey(t)
+
+	w := streamPut(t, f, "ssec-empty.bin", nil, func(r *http.Request) { setSSECHeaders(t, r, key) })
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	gw := streamGet(t, f, "ssec-empty.bin", "", func(r *http.Request) { setSSECHeaders(t, r, key) })
+	require.Equal(t, http.StatusOK, gw.Code)
+	assert.Empty(t, gw.Body.Bytes())
+}
+
+// doSSECRequest is doS3Request with the SSE-C headers for key (nil for none).
+func doSSECRequest(t *testing.T, srv *Server, tnt *tenant.Tenant, method, path string, body io.Reader, key []byte) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, path, body)
+	if key != nil {
+		setSSECHeaders(t, req, key)
+	}
+	req = req.WithContext(tenant.WithTenant(req.Context(), tnt))
+	w := httptest.NewRecorder()
+	srv.handleS3Request(w, req)
+	return w
+}
+
+func TestMultipart_SSEC_EncryptsAssembledObject(t *testing.T) {
+	srv, tnt, _ := newTestMultipartServer(t)
+	key := generateSSECKey(t)
+
+	initW := doSSECRequest(t, srv, tnt, "POST", "/test-bucket/enc.bin?uploads", nil, key)
+	require.Equal(t, http.StatusOK, initW.Code, initW.Body.String())
+	assert.Equal(t, "AES256", initW.Header().Get("x-amz-server-side-encryption-customer-algorithm"))
+	var initResult InitiateMultipartUploadResult
+	require.NoError(t, xml.Unmarshal(initW.Body.Bytes(), &initResult))
+	uploadID := initResult.UploadID
+
+	// A part without the upload's key is refused.
+	noKey := doSSECRequest(t, srv, tnt, "PUT",
+		fmt.Sprintf("/test-bucket/enc.bin?uploadId=%s&partNumber=1", uploadID), strings.NewReader("x"), nil)
+	assert.Equal(t, http.StatusBadRequest, noKey.Code)
+	otherKey := doSSECRequest(t, srv, tnt, "PUT",
+		fmt.Sprintf("/test-bucket/enc.bin?uploadId=%s&partNumber=1", uploadID), strings.NewReader("x"), generateSSECKey(t))
+	assert.Equal(t, http.StatusBadRequest, otherKey.Code)
+
+	part1 := generateTestData(crypto.StreamSegmentSize + 7)
+	part2 := []byte("tail of the object")
+	for i, data := range [][]byte{part1, part2} {
+		pw := doSSECRequest(t, srv, tnt, "PUT",
+			fmt.Sprintf("/test-bucket/enc.bin?uploadId=%s&partNumber=%d", uploadID, i+1), bytes.NewReader(data), key)
+		require.Equal(t, http.StatusOK, pw.Code, pw.Body.String())
+	}
+
+	// Staged parts are encrypted at rest under the customer key.
+	staged, err := os.ReadFile(partFilePath(uploadID, 1))
+	require.NoError(t, err)
+	assert.False(t, bytes.Contains(staged, part1[:64]))
+
+	noKeyComplete := doSSECRequest(t, srv, tnt, "POST", "/test-bucket/enc.bin?uploadId="+uploadID, nil, nil)
+	assert.Equal(t, http.StatusBadRequest, noKeyComplete.Code)
+
+	cw := doSSECRequest(t, srv, tnt, "POST", "/test-bucket/enc.bin?uploadId="+uploadID, nil, key)
+	require.Equal(t, http.StatusOK, cw.Code, cw.Body.String())
+	assert.Equal(t, "AES256", cw.Header().Get("x-amz-server-side-encryption-customer-algorithm"))
+
+	// The stored object is a segmented SSE-C stream of the assembled parts.
+	rc, err := srv.engine.Get(context.Background(), tnt.NamespaceContainer("test-bucket"), "enc.bin")
+	require.NoError(t, err)
+	defer func() { _ = rc.Close() }()
+	header := make([]byte, crypto.SSECStreamHeaderSize)
+	_, err = io.ReadFull(rc, header)
+	require.NoError(t, err)
+	stream, err := crypto.OpenSSECStream(key, header)
+	require.NoError(t, err)
+
+	want := append(append([]byte(nil), part1...), part2...)
+	got, err := io.ReadAll(stream.DecryptRange(rc, int64(len(want)), 0, int64(len(want))))
+	require.NoError(t, err)
+	assert.Equal(t, want, got)
+}
+
+func TestStagePart_SSECEncryptsAtRest(t *testing.T) {
+	uploadID := "stage-ssec-" + fmt.Sprint(os.Getpid())
+	require.NoError(t, os.MkdirAll(multipartDir(uploadID), 0o700))
+	t.Cleanup(func() { _ = os.RemoveAll(multipartDir(uploadID)) })
+	key := generateSSECKey(t)
+	data := generateTestData(2*crypto.StreamSegmentSize + 11)
+
+	size, etag, err := stagePart(uploadID, 1, bytes.NewReader(data), nil, key)
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(data)), size)
+	assert.Equal(t, fmt.Sprintf("\"%x\"", md5.Sum(data)), etag)
+
+	staged, err := os.ReadFile(partFilePath(uploadID, 1))
+	require.NoError(t, err)
+	assert.Len(t, staged, crypto.SSECStreamHeaderSize+len(data)+3*16)
+	assert.False(t, bytes.Contains(staged, data[:64]))
+
+	rc, err := openStagedPart(uploadID, 1, size, key)
+	require.NoError(t, err)
+	got, err := io.ReadAll(rc)
+	_ = rc.Close()
+	require.NoError(t, err)
+	assert.Equal(t, data, got)
+
+	_, err = openStagedPart(uploadID, 1, size, generateSSECKey(t))
+	assert.ErrorIs(t, err, crypto.ErrSSECKeyMismatch)
+
+	// A tampered file fails authentication rather than yielding bytes.
+	staged[crypto.SSECStreamHeaderSize+5] ^= 0xff
+	require.NoError(t, os.WriteFile(partFilePath(uploadID, 1), staged, 0o600))
+	rc, err = openStagedPart(uploadID, 1, size, key)
+	require.NoError(t, err)
+	_, err = io.ReadAll(rc)
+	_ = rc.Close()
+	assert.ErrorIs(t, err, crypto.ErrStreamAuth)
+}
@@ -180,6 +180,7 @@ func (s *Server) handlePutObjectTagging(w http.ResponseWriter, r *http.Request,
 		}
 		return
 	}
+	s.queueTagReplication(r, t.ID, req.Bucket, req.Object)
 
 	w.Header().Set("x-amz-version-id", "null")
 	w.WriteHeader(http.StatusOK)
@@ -207,6 +208,19 @@ func (s *Server) handleDeleteObjectTagging(w http.ResponseWriter, r *http.Reques
 		WriteS3Error(w, ErrInternalError, r.URL.Path, generateRequestID())
 		return
 	}
+	s.queueTagReplication(r, t.ID, req.Bucket, req.Object)
 
 	w.WriteHeader(http.StatusNoContent)
 }
+
+// queueTagReplication re-replicates an object whose tags changed: replicas
+// carry the source's tags, and a tag filter may now select the object.
+func (s *Server) queueTagReplication(r *http.Request, tenantID, bucket, key string) {
+	if err := queueReplication(r.Context(), s.db, tenantID, bucket, key); err != nil {
+		s.logger.Error("queue tag replication",
+			zap.Error(err),
+			zap.String("tenant_id", tenantID),
+			zap.String("bucket", bucket),
+			zap.String("key", key))
+	}
+}
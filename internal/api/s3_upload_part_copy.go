@@ -0,0 +1,834 @@
+package api
+
+import (
+	"context"
+	"crypto/md5" // #nosec G501 — S3 spec requires MD5 for ETags
+	"database/sql"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/FairForge/vaultaire/internal/crypto"
+	"github.com/FairForge/vaultaire/internal/tenant"
+
+	"go.uber.org/zap"
+)
+
+// CopyPartResult is the UploadPartCopy response body.
+type CopyPartResult struct {
+	XMLName      xml.Name `xml:"CopyPartResult"`
+	ETag         string   `xml:"ETag"`
+	LastModified string   `xml:"LastModified"`
+	ChecksumFields
+}
+
+// partChunkSlice is one slice of a deduplicated chunk held by a part that
+// UploadPartCopy filled from a chunked source: Take bytes starting Skip bytes
+// into a ChunkSize-byte chunk. Each slice holds one GCI reference until it
+// is released or handed to the completed object's manifest.
+type partChunkSlice struct {
+	Scope          string
+	Hash           string
+	ChunkSize      int64
+	Skip           int64
+	Take           int64
+	KeyVersion     int
+	CiphertextHash *string
+}
+
+// whole reports whether the slice covers its entire chunk.
+func (c partChunkSlice) whole() bool {
+	return c.Skip == 0 && c.Take == c.ChunkSize
+}
+
+// errCopyRangeOutside rejects a copy-source range that extends past the end
+// of the source object.
+var errCopyRangeOutside = errors.New("the x-amz-copy-source-range is outside the source object")
+
+// parseCopySourceRange parses x-amz-copy-source-range. Unlike a GET Range,
+// both bounds are required ("bytes=first-last", inclusive) and the range
+// must lie inside the source. size < 0 means the source size is unknown;
+// the caller then detects a short source by the byte count.
+func parseCopySourceRange(v string, size int64) (start, length int64, err error) {
+	spec, ok := strings.CutPrefix(strings.TrimSpace(v), "bytes=")
+	if !ok {
+		return 0, 0, fmt.Errorf("range %q must have the form bytes=first-last", v)
+	}
+	firstStr, lastStr, ok := strings.Cut(spec, "-")
+	if !ok {
+		return 0, 0, fmt.Errorf("range %q must have the form bytes=first-last", v)
+	}
+	first, err1 := strconv.ParseInt(firstStr, 10, 64)
+	last, err2 := strconv.ParseInt(lastStr, 10, 64)
+	if err1 != nil || err2 != nil || first < 0 || last < first {
+		return 0, 0, fmt.Errorf("range %q must have the form bytes=first-last", v)
+	}
+	if size >= 0 && last >= size {
+		return 0, 0, errCopyRangeOutside
+	}
+	return first, last - first + 1, nil
+}
+
+// handleUploadPartCopy handles UploadPart with x-amz-copy-source: the part's
+// data comes from an existing object, optionally limited to
+// x-amz-copy-source-range and guarded by x-amz-copy-source-if-* conditions.
+//
+// Plain sources are read through the engine's ranged GET (native
+// RangeGetter where the backend has one) and staged like an uploaded part.
+// Chunked (deduplicated) sources move no bytes: the part records references
+// to the overlapping chunks in multipart_part_chunks, and completion either
+// installs them as the object's manifest or streams them. Uploads with a
+// flexible checksum stage the bytes instead, since the part checksum must be
+// computed over the data.
+func (s *Server) handleUploadPartCopy(w http.ResponseWriter, r *http.Request, bucket, object string) {
+	t, err := tenant.FromContext(r.Context())
+	if err != nil || t == nil {
+		WriteS3Error(w, ErrAccessDenied, r.URL.Path, generateRequestID())
+		return
+	}
+
+	uploadID := r.URL.Query().Get("uploadId")
+	partNumber, err := strconv.Atoi(r.URL.Query().Get("partNumber"))
+	if err != nil || partNumber < 1 || partNumber > maxPartNumber {
+		WriteS3Error(w, ErrInvalidPartNumber, r.URL.Path, generateRequestID())
+		return
+	}
+
+	upload, active, err := s.lookupActiveUpload(r.Context(), uploadID, t.ID)
+	if err != nil {
+		s.logger.Error("failed to query multipart upload", zap.Error(err))
+		WriteS3Error(w, ErrInternalError, r.URL.Path, generateRequestID())
+		return
+	}
+	if !active {
+		WriteS3Error(w, ErrNoSuchUpload, r.URL.Path, generateRequestID())
+		return
+	}
+	ssecKey, ok := s.checkUploadSSECKey(w, r, upload)
+	if !ok {
+		return
+	}
+	defer zeroKey(ssecKey)
+	uploadChecksumAlg := upload.ChecksumAlgorithm
+
+	copySource := r.Header.Get("x-amz-copy-source")
+	srcBucket, srcKey, err := parseCopySource(copySource)
+	if err != nil {
+		s.logger.Warn("invalid x-amz-copy-source",
+			zap.String("copy_source", copySource),
+			zap.Error(err))
+		WriteS3Error(w, ErrInvalidRequest, r.URL.Path, generateRequestID())
+		return
+	}
+
+	// Source head row: size for range validation, ETag and mtime for the
+	// preconditions, encryption/chunking to pick the copy path. Without a
+	// database (test mode) the size is unknown and checked by byte count.
+	srcSize := int64(-1)
+	var srcETag, srcEnc string
+	var srcUpdated time.Time
+	var srcChunked bool
+	if s.db != nil {
+		err := s.db.QueryRowContext(r.Context(), `
+			SELECT size_bytes, etag, updated_at, COALESCE(encryption_algorithm, ''), is_chunked
+			FROM object_head_cache
+			WHERE tenant_id = $1 AND bucket = $2 AND object_key = $3`,
+			t.ID, srcBucket, srcKey).Scan(&srcSize, &srcETag, &srcUpdated, &srcEnc, &srcChunked)
+		if err == sql.ErrNoRows {
+			WriteS3Error(w, ErrNoSuchKey, r.URL.Path, generateRequestID())
+			return
+		}
+		if err != nil {
+			s.logger.Error("upload part copy: source lookup failed", zap.Error(err))
+			WriteS3Error(w, ErrInternalError, r.URL.Path, generateRequestID())
+			return
+		}
+	}
+
+	if copySourcePreconditionFailed(r, srcETag, srcUpdated) {
+		WriteS3Error(w, ErrPreconditionFailed, r.URL.Path, generateRequestID())
+		return
+	}
+
+	start, length := int64(0), srcSize
+	rangeHeader := r.Header.Get("x-amz-copy-source-range")
+	if rangeHeader != "" {
+		if start, length, err = parseCopySourceRange(rangeHeader, srcSize); err != nil {
+			WriteS3ErrorWithContext(w, ErrInvalidArgument, r.URL.Path, generateRequestID(),
+				WithSuggestion(err.Error()))
+			return
+		}
+	}
+
+	// Same restriction as CopyObject: whole-object encrypted sources would
+	// hand back ciphertext.
+	if (srcEnc != "" && !srcChunked) || (srcChunked && s.gci == nil) {
+		WriteS3ErrorWithContext(w, ErrNotImplemented, r.URL.Path, generateRequestID(),
+			WithSuggestion("Copying parts from encrypted objects is not yet supported. Download and upload the part instead."))
+		return
+	}
+
+	// Per-upload in-flight byte cap, as for UploadPart. A chunk-reference
+	// part occupies no local disk but counts the same: it is billed only at
+	// completion.
+	var existingBytes int64
+	if s.db != nil && s.multipartMaxUploadBytes > 0 {
+		if existingBytes, err = s.inFlightPartBytes(r.Context(), uploadID, partNumber); err != nil {
+			s.logger.Error("failed to sum in-flight part bytes", zap.Error(err))
+			WriteS3Error(w, ErrInternalError, r.URL.Path, generateRequestID())
+			return
+		}
+		if length > 0 && existingBytes+length > s.multipartMaxUploadBytes {
+			WriteS3ErrorWithContext(w, ErrEntityTooLarge, r.URL.Path, generateRequestID(),
+				WithSuggestion(fmt.Sprintf(
+					"This part would push the upload past the %d-byte in-flight limit. Complete or abort the upload, or use fewer/smaller parts.",
+					s.multipartMaxUploadBytes)))
+			return
+		}
+	}
+
+	var slices []partChunkSlice
+	if srcChunked {
+		slices, err = s.sourceChunkSlices(r.Context(), t.ID, srcBucket, srcKey, start, length)
+		if err != nil {
+			s.logger.Error("upload part copy: source manifest unavailable",
+				zap.Error(err), zap.String("bucket", srcBucket), zap.String("key", srcKey))
+			WriteS3Error(w, ErrNoSuchKey, r.URL.Path, generateRequestID())
+			return
+		}
+		if uploadChecksumAlg == "" && len(slices) > 0 {
+			s.recordPartChunkRefs(w, r, t, uploadID, partNumber, slices)
+			return
+		}
+	}
+
+	var src io.ReadCloser
+	switch {
+	case srcChunked:
+		src = s.chunkSliceReader(r.Context(), t.ID, slices)
+	case rangeHeader != "":
+		src, err = s.engine.GetRange(r.Context(), t.NamespaceContainer(srcBucket), srcKey, start, length)
+	default:
+		src, err = s.engine.Get(r.Context(), t.NamespaceContainer(srcBucket), srcKey)
+	}
+	if err != nil {
+		if strings.Contains(err.Error(), "no such file or directory") ||
+			strings.Contains(err.Error(), "not found") {
+			WriteS3Error(w, ErrNoSuchKey, r.URL.Path, generateRequestID())
+		} else {
+			s.logger.Error("upload part copy: source get failed",
+				zap.Error(err), zap.String("bucket", srcBucket), zap.String("key", srcKey))
+			WriteS3Error(w, ErrInternalError, r.URL.Path, generateRequestID())
+		}
+		return
+	}
+	defer func() { _ = src.Close() }()
+
+	// Backends without a native ranged GET return the rest of the object
+	// after skipping the offset, so the length is enforced here.
+	var body io.Reader = src
+	if length >= 0 {
+		body = io.LimitReader(src, length)
+	}
+
+	var ck *requestChecksum
+	if uploadChecksumAlg != "" {
+		ck = newRequestChecksum(uploadChecksumAlg)
+	}
+	size, etag, err := stagePart(uploadID, partNumber, body, ck, ssecKey)
+	if err != nil {
+		s.logger.Error("upload part copy: failed to stage part",
+			zap.Error(err), zap.String("bucket", srcBucket), zap.String("key", srcKey))
+		WriteS3Error(w, ErrInternalError, r.URL.Path, generateRequestID())
+		return
+	}
+	pp := partFilePath(uploadID, partNumber)
+	if length >= 0 && size != length {
+		_ = os.Remove(pp)
+		WriteS3ErrorWithContext(w, ErrInvalidArgument, r.URL.Path, generateRequestID(),
+			WithSuggestion(errCopyRangeOutside.Error()))
+		return
+	}
+	if s.db != nil && s.multipartMaxUploadBytes > 0 && existingBytes+size > s.multipartMaxUploadBytes {
+		_ = os.Remove(pp)
+		WriteS3ErrorWithContext(w, ErrEntityTooLarge, r.URL.Path, generateRequestID(),
+			WithSuggestion(fmt.Sprintf(
+				"This part pushed the upload past the %d-byte in-flight limit. Complete or abort the upload, or use fewer/smaller parts.",
+				s.multipartMaxUploadBytes)))
+		return
+	}
+
+	var checksumValue string
+	if ck != nil {
+		checksumValue = ck.Sum()
+	}
+	if err := s.recordPart(r.Context(), uploadID, partNumber, etag, size, uploadChecksumAlg, checksumValue); err != nil {
+		s.logger.Error("failed to record part metadata", zap.Error(err))
+		WriteS3Error(w, ErrInternalError, r.URL.Path, generateRequestID())
+		return
+	}
+
+	s.logger.Debug("copied part",
+		zap.String("tenant_id", t.ID),
+		zap.String("uploadID", uploadID),
+		zap.Int("partNumber", partNumber),
+		zap.String("src_bucket", srcBucket),
+		zap.String("src_key", srcKey),
+		zap.Int64("size", size))
+
+	result := CopyPartResult{ETag: etag, LastModified: time.Now().UTC().Format("2006-01-02T15:04:05.000Z")}
+	if uploadChecksumAlg != "" {
+		result.set(uploadChecksumAlg, checksumValue)
+	}
+	s.writeCopyPartResult(w, r, result)
+}
+
+// recordPartChunkRefs records a part as references to the source's chunks.
+// The part's previous contents (staged file or chunk references) are
+// replaced; every new slice takes a GCI reference in the same transaction.
+func (s *Server) recordPartChunkRefs(w http.ResponseWriter, r *http.Request, t *tenant.Tenant,
+	uploadID string, partNumber int, slices []partChunkSlice) {
+	ctx := r.Context()
+	var size int64
+	for _, sl := range slices {
+		size += sl.Take
+	}
+	etag := chunkSlicesETag(slices)
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		s.logger.Error("upload part copy: begin failed", zap.Error(err))
+		WriteS3Error(w, ErrInternalError, r.URL.Path, generateRequestID())
+		return
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	released, err := releasePartChunksTx(ctx, tx, uploadID, partNumber)
+	if err == nil {
+		err = insertPartChunkRefsTx(ctx, tx, uploadID, partNumber, slices)
+	}
+	if err == nil {
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO multipart_parts (upload_id, part_number, etag, size_bytes, checksum_algorithm, checksum_value)
+			VALUES ($1, $2, $3, $4, NULL, NULL)
+			ON CONFLICT (upload_id, part_number) DO UPDATE SET
+				etag               = EXCLUDED.etag,
+				size_bytes         = EXCLUDED.size_bytes,
+				checksum_algorithm = NULL,
+				checksum_value     = NULL,
+				created_at         = NOW()
+		`, uploadID, partNumber, etag, size)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		s.logger.Error("upload part copy: recording chunk references failed",
+			zap.Error(err), zap.String("uploadID", uploadID), zap.Int("partNumber", partNumber))
+		WriteS3Error(w, ErrInternalError, r.URL.Path, generateRequestID())
+		return
+	}
+	invalidateChunks(s.gci, released)
+	invalidateChunks(s.gci, slices)
+	// A staged file from an earlier upload of this part number is stale.
+	_ = os.Remove(partFilePath(uploadID, partNumber))
+
+	s.logger.Debug("copied part by chunk reference",
+		zap.String("tenant_id", t.ID),
+		zap.String("uploadID", uploadID),
+		zap.Int("partNumber", partNumber),
+		zap.Int("chunks", len(slices)),
+		zap.Int64("size", size))
+
+	s.writeCopyPartResult(w, r, CopyPartResult{
+		ETag:         etag,
+		LastModified: time.Now().UTC().Format("2006-01-02T15:04:05.000Z"),
+	})
+}
+
+// writeCopyPartResult writes a successful UploadPartCopy response.
+func (s *Server) writeCopyPartResult(w http.ResponseWriter, r *http.Request, result CopyPartResult) {
+	xmlData, err := xml.MarshalIndent(result, "", "  ")
+	if err != nil {
+		s.logger.Error("upload part copy: XML marshal failed", zap.Error(err))
+		WriteS3Error(w, ErrInternalError, r.URL.Path, generateRequestID())
+		return
+	}
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte(xml.Header))
+	_, _ = w.Write(xmlData)
+}
+
+// chunkSlicesETag derives the ETag of a chunk-reference part without reading
+// its bytes: the MD5 of the slice descriptors. It identifies the part's
+// content for CompleteMultipartUpload but, unlike a staged part's ETag, is
+// not the MD5 of the data.
+func chunkSlicesETag(slices []partChunkSlice) string {
+	h := md5.New() // #nosec G401 — S3 ETags are MD5
+	for _, sl := range slices {
+		_, _ = fmt.Fprintf(h, "%s/%s:%d:%d\n", sl.Scope, sl.Hash, sl.Skip, sl.Take)
+	}
+	return fmt.Sprintf("\"%x\"", h.Sum(nil))
+}
+
+// chunkAdapter returns an engine adapter able to read chunks.
+func (s *Server) chunkAdapter() *S3ToEngine {
+	adapter := NewS3ToEngine(s.engine, s.db, s.logger)
+	adapter.chunkEncSvc = s.chunkEncSvc
+	adapter.gci = s.gci
+	adapter.flags = s.flags
+	return adapter
+}
+
+// sourceChunkSlices maps bytes [start, start+length) of a chunked object
+// onto slices of its chunks.
+func (s *Server) sourceChunkSlices(ctx context.Context, tenantID, bucket, key string, start, length int64) ([]partChunkSlice, error) {
+	refs, err := s.gci.GetObjectChunks(ctx, tenantID, bucket, key)
+	if err != nil {
+		return nil, err
+	}
+	if len(refs) == 0 && length > 0 {
+		return nil, fmt.Errorf("no chunk references for %s/%s", bucket, key)
+	}
+
+	adapter := s.chunkAdapter()
+	end := start + length
+	var slices []partChunkSlice
+	var covered int64
+	for i, ref := range refs {
+		chunkStart := ref.ChunkOffset
+		if chunkStart >= end {
+			break
+		}
+		// Chunk sizes follow from the next chunk's offset; only the last
+		// chunk needs the index.
+		var chunkSize int64
+		if i+1 < len(refs) {
+			chunkSize = refs[i+1].ChunkOffset - chunkStart
+		} else {
+			d, err := adapter.resolveChunkDesc(ctx, ref.DedupScope, ref.PlaintextHash, ref.CiphertextHash)
+			if err != nil {
+				return nil, err
+			}
+			chunkSize = d.size
+		}
+		chunkEnd := chunkStart + chunkSize
+		if chunkEnd <= start {
+			continue
+		}
+		skip := max(start-chunkStart, 0)
+		take := min(chunkEnd, end) - chunkStart - skip
+		scope := ref.DedupScope
+		if scope == "" {
+			scope = crypto.GlobalDedupScope
+		}
+		slices = append(slices, partChunkSlice{
+			Scope:          scope,
+			Hash:           ref.PlaintextHash,
+			ChunkSize:      chunkSize,
+			Skip:           skip,
+			Take:           take,
+			KeyVersion:     ref.EncryptionKeyVersion,
+			CiphertextHash: ref.CiphertextHash,
+		})
+		covered += take
+	}
+	if covered != length {
+		return nil, fmt.Errorf("manifest of %s/%s covers %d of %d requested bytes", bucket, key, covered, length)
+	}
+	return slices, nil
+}
+
+// writeChunkSlices streams the bytes of chunk slices to dst, verifying each
+// chunk as handleChunkedGet does.
+func (s *Server) writeChunkSlices(ctx context.Context, tenantID string, slices []partChunkSlice, dst io.Writer) error {
+	adapter := s.chunkAdapter()
+	for _, sl := range slices {
+		d, err := adapter.resolveChunkDesc(ctx, sl.Scope, sl.Hash, sl.CiphertextHash)
+		if err != nil {
+			return err
+		}
+		data, err := adapter.fetchAndVerifyChunk(ctx, d, tenantID)
+		if err != nil {
+			return err
+		}
+		if sl.Skip+sl.Take > int64(len(data)) {
+			return fmt.Errorf("chunk %s is %d bytes, slice needs %d", sl.Hash[:16], len(data), sl.Skip+sl.Take)
+		}
+		if _, err := dst.Write(data[sl.Skip : sl.Skip+sl.Take]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// chunkSliceReader returns a reader over the bytes of chunk slices.
+func (s *Server) chunkSliceReader(ctx context.Context, tenantID string, slices []partChunkSlice) io.ReadCloser {
+	pr, pw := io.Pipe()
+	go func() {
+		_ = pw.CloseWithError(s.writeChunkSlices(ctx, tenantID, slices, pw))
+	}()
+	return pr
+}
+
+// insertPartChunkRefsTx records a part's slices, taking one GCI reference per
+// slice.
+func insertPartChunkRefsTx(ctx context.Context, tx *sql.Tx, uploadID string, partNumber int, slices []partChunkSlice) error {
+	for i, sl := range slices {
+		if _, err := tx.ExecContext(ctx,
+			`SELECT increment_chunk_ref($1, $2)`, sl.Scope, sl.Hash); err != nil {
+			return fmt.Errorf("increment chunk ref %s: %w", sl.Hash, err)
+		}
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO multipart_part_chunks
+				(upload_id, part_number, seq, dedup_scope, plaintext_hash, chunk_size,
+				 skip_bytes, take_bytes, encryption_key_version, ciphertext_hash)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		`, uploadID, partNumber, i, sl.Scope, sl.Hash, sl.ChunkSize,
+			sl.Skip, sl.Take, sl.KeyVersion, sl.CiphertextHash); err != nil {
+			return fmt.Errorf("insert part chunk %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// releasePartChunksTx deletes an upload's chunk-reference rows and drops the
+// GCI reference each held. partNumber 0 releases every part. The released
+// slices are returned so the caller can invalidate cached index entries
+// after commit.
+func releasePartChunksTx(ctx context.Context, tx *sql.Tx, uploadID string, partNumber int) ([]partChunkSlice, error) {
+	rows, err := tx.QueryContext(ctx, `
+		DELETE FROM multipart_part_chunks
+		WHERE upload_id = $1 AND ($2 = 0 OR part_number = $2)
+		RETURNING dedup_scope, plaintext_hash
+	`, uploadID, partNumber)
+	if err != nil {
+		return nil, fmt.Errorf("delete part chunks: %w", err)
+	}
+	var released []partChunkSlice
+	for rows.Next() {
+		var sl partChunkSlice
+		if err := rows.Scan(&sl.Scope, &sl.Hash); err != nil {
+			_ = rows.Close()
+			return nil, fmt.Errorf("scan part chunk: %w", err)
+		}
+		released = append(released, sl)
+	}
+	if err := rows.Err(); err != nil {
+		_ = rows.Close()
+		return nil, fmt.Errorf("iterate part chunks: %w", err)
+	}
+	_ = rows.Close()
+
+	if err := decrementChunkRefsTx(ctx, tx, released); err != nil {
+		return nil, err
+	}
+	return released, nil
+}
+
+// decrementChunkRefsTx drops one GCI reference per slice.
+func decrementChunkRefsTx(ctx context.Context, tx *sql.Tx, slices []partChunkSlice) error {
+	for _, sl := range slices {
+		if _, err := tx.ExecContext(ctx,
+			`SELECT decrement_chunk_ref($1, $2)`, sl.Scope, sl.Hash); err != nil {
+			return fmt.Errorf("decrement chunk ref %s: %w", sl.Hash, err)
+		}
+	}
+	return nil
+}
+
+// releasePartChunks is releasePartChunksTx in its own transaction, for
+// abort, purge and post-completion cleanup. gci may be nil; the dedup GC
+// invalidates its cache before sweeping regardless.
+func releasePartChunks(ctx context.Context, db *sql.DB, gci *crypto.GlobalContentIndex, uploadID string) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin release part chunks: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	released, err := releasePartChunksTx(ctx, tx, uploadID, 0)
+	if err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit release part chunks: %w", err)
+	}
+	invalidateChunks(gci, released)
+	return nil
+}
+
+// invalidateChunks drops cached index entries whose refcounts changed.
+func invalidateChunks(gci *crypto.GlobalContentIndex, slices []partChunkSlice) {
+	if gci == nil {
+		return
+	}
+	for _, sl := range slices {
+		gci.InvalidateCache(sl.Scope, sl.Hash)
+	}
+}
+
+// loadPartChunkSlices returns the chunk slices of an upload's
+// chunk-reference parts, keyed by part number. Staged parts are absent.
+func loadPartChunkSlices(ctx context.Context, db *sql.DB, uploadID string) (map[int][]partChunkSlice, error) {
+	if db == nil {
+		return nil, nil
+	}
+	rows, err := db.QueryContext(ctx, `
+		SELECT part_number, dedup_scope, plaintext_hash, chunk_size, skip_bytes, take_bytes,
+		       encryption_key_version, ciphertext_hash
+		FROM multipart_part_chunks
+		WHERE upload_id = $1
+		ORDER BY part_number, seq
+	`, uploadID)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	out := make(map[int][]partChunkSlice)
+	for rows.Next() {
+		var pn int
+		var sl partChunkSlice
+		var ctHash sql.NullString
+		if err := rows.Scan(&pn, &sl.Scope, &sl.Hash, &sl.ChunkSize, &sl.Skip, &sl.Take,
+			&sl.KeyVersion, &ctHash); err != nil {
+			return nil, err
+		}
+		if ctHash.Valid {
+			v := ctHash.String
+			sl.CiphertextHash = &v
+		}
+		out[pn] = append(out[pn], sl)
+	}
+	return out, rows.Err()
+}
+
+// mergeChunkSlices joins the slices of the selected parts in order,
+// re-joining adjacent slices of the same chunk (a chunk split across a part
+// boundary). It reports whether the result is a valid manifest: every part
+// is a chunk-reference part and every merged slice covers a whole chunk.
+// surplus lists the references made redundant by merging.
+func mergeChunkSlices(parts []partRecord, partSlices map[int][]partChunkSlice) (merged, surplus []partChunkSlice, ok bool) {
+	for _, p := range parts {
+		slices := partSlices[p.PartNumber]
+		if len(slices) == 0 {
+			return nil, nil, false
+		}
+		for _, sl := range slices {
+			if n := len(merged); n > 0 {
+				last := &merged[n-1]
+				if last.Scope == sl.Scope && last.Hash == sl.Hash && last.Skip+last.Take == sl.Skip {
+					last.Take += sl.Take
+					surplus = append(surplus, sl)
+					continue
+				}
+			}
+			merged = append(merged, sl)
+		}
+	}
+	for _, sl := range merged {
+		if !sl.whole() {
+			return nil, nil, false
+		}
+	}
+	return merged, surplus, true
+}
+
+// chunkManifestAllowed reports whether a completed object may be stored as a
+// chunk manifest, under the same versioning, tier and rollout gates as a
+// chunked PUT.
+func (s *Server) chunkManifestAllowed(ctx context.Context, t *tenant.Tenant, bucket string) bool {
+	if s.gci == nil || s.db == nil || !s.chunkAdapter().chunkingEnabled(t.ID) {
+		return false
+	}
+	if vs := getBucketVersioningStatus(ctx, s.db, t.ID, bucket); vs == "Enabled" || vs == "Suspended" {
+		return false
+	}
+	tier := bucketTierStorageClass(ctx, s.db, t.ID, bucket)
+	return tier != "RESILIENT" && tier != "GLACIER" && tier != "DEEP_ARCHIVE"
+}
+
+// completeFromChunkRefs completes an upload built entirely from whole-chunk
+// references by installing them as the object's manifest: no bytes move.
+// The references the parts hold transfer to the manifest; those made
+// redundant by merging are dropped in the same transaction.
+func (s *Server) completeFromChunkRefs(w http.ResponseWriter, r *http.Request, t *tenant.Tenant,
+	bucket, object, uploadID string, parts []partRecord, merged, surplus []partChunkSlice, totalSize int64, finalETag string, objectACL []byte) {
+	ctx := r.Context()
+
+	// Resolve every chunk before committing: the manifest must not reference
+	// a chunk the index no longer has.
+	adapter := s.chunkAdapter()
+	encAlgo := ""
+	for _, sl := range merged {
+		d, err := adapter.resolveChunkDesc(ctx, sl.Scope, sl.Hash, sl.CiphertextHash)
+		if err != nil {
+			s.logger.Error("multipart complete: chunk unresolvable",
+				zap.Error(err), zap.String("uploadID", uploadID))
+			WriteS3Error(w, ErrInternalError, r.URL.Path, generateRequestID())
+			return
+		}
+		if d.encrypted {
+			encAlgo = "AES256-CE"
+		}
+	}
+
+	quotaOn := s.quotaManager != nil
+	var reservedBytes int64
+	if quotaOn {
+		ok, qErr := s.quotaManager.CheckAndReserve(ctx, t.ID, totalSize)
+		if qErr != nil {
+			s.logger.Error("multipart complete: quota check failed",
+				zap.Error(qErr), zap.String("tenant_id", t.ID))
+			WriteS3Error(w, ErrInternalError, r.URL.Path, generateRequestID())
+			return
+		}
+		if !ok {
+			WriteS3ErrorWithContext(w, ErrQuotaExceeded, r.URL.Path, generateRequestID(),
+				WithSuggestion("Upgrade at https://stored.ge/dashboard/billing"))
+			return
+		}
+		reservedBytes = totalSize
+	}
+
+	refs := make([]crypto.TenantChunkRef, len(merged))
+	var offset int64
+	for i, sl := range merged {
+		refs[i] = crypto.TenantChunkRef{
+			TenantID:             t.ID,
+			BucketName:           bucket,
+			ObjectKey:            object,
+			ChunkIndex:           i,
+			ChunkOffset:          offset,
+			PlaintextHash:        sl.Hash,
+			DedupScope:           sl.Scope,
+			EncryptionKeyVersion: sl.KeyVersion,
+			CiphertextHash:       sl.CiphertextHash,
+		}
+		offset += sl.Take
+	}
+
+	contentType := r.Header.Get("Content-Type")
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	etagValue := strings.Trim(finalETag, "\"")
+	physicalSize := int64(0) // every chunk already exists
+	dedupRatio := float32(0)
+	displaced, dbErr := atomicHeadUpsert(ctx, s.db, t.ID, bucket, object, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctx, `
+			UPDATE multipart_uploads SET status = 'completed'
+			WHERE upload_id = $1 AND status = 'active'
+		`, uploadID)
+		if err != nil {
+			return fmt.Errorf("mark upload completed: %w", err)
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			return fmt.Errorf("upload %s is no longer active", uploadID)
+		}
+		if _, err := tx.ExecContext(ctx,
+			`DELETE FROM multipart_part_chunks WHERE upload_id = $1`, uploadID); err != nil {
+			return fmt.Errorf("hand over part chunks: %w", err)
+		}
+		if err := decrementChunkRefsTx(ctx, tx, surplus); err != nil {
+			return err
+		}
+		if err := s.gci.ReplaceObjectManifestTx(ctx, tx, t.ID, bucket, object, refs, &crypto.ObjectMeta{
+			TenantID:     t.ID,
+			BucketName:   bucket,
+			ObjectKey:    object,
+			TotalSize:    totalSize,
+			ChunkCount:   len(refs),
+			ContentType:  &contentType,
+			LogicalSize:  totalSize,
+			PhysicalSize: &physicalSize,
+			DedupRatio:   &dedupRatio,
+		}); err != nil {
+			return fmt.Errorf("install manifest: %w", err)
+		}
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO object_head_cache
+				(tenant_id, bucket, object_key, size_bytes, etag, content_type, backend_name,
+				 encryption_algorithm, is_chunked, checksum_algorithm, checksum_value, checksum_type, part_layout, acl, updated_at)
+			VALUES ($1, $2, $3, $4, $5, $6, '', $7, TRUE, NULL, NULL, NULL, $8, $9, NOW())
+			ON CONFLICT (tenant_id, bucket, object_key) DO UPDATE SET
+				size_bytes           = EXCLUDED.size_bytes,
+				etag                 = EXCLUDED.etag,
+				content_type         = EXCLUDED.content_type,
+				backend_name         = EXCLUDED.backend_name,
+				encryption_algorithm = EXCLUDED.encryption_algorithm,
+				is_chunked           = TRUE,
+				sse_segmented        = FALSE,
+				sse_kms_key_id       = NULL,
+				sse_kms_data_key     = NULL,
+				sse_kms_context      = NULL,
+				checksum_algorithm   = NULL,
+				checksum_value       = NULL,
+				checksum_type        = NULL,
+				website_redirect_location = NULL,
+				part_layout          = EXCLUDED.part_layout,
+				acl                  = EXCLUDED.acl,
+				replication_status   = NULL,
+				updated_at           = NOW()
+		`, t.ID, bucket, object, totalSize, etagValue, contentType, encAlgo, encodePartLayout(parts), objectACL)
+		return err
+	})
+	if dbErr != nil {
+		if quotaOn {
+			qctx, cancel := quotaCtx(r)
+			s.releaseQuota(qctx, t.ID, reservedBytes)
+			cancel()
+		}
+		s.logger.Error("multipart complete: manifest install failed",
+			zap.Error(dbErr), zap.String("uploadID", uploadID))
+		WriteS3Error(w, ErrInternalError, r.URL.Path, generateRequestID())
+		return
+	}
+	invalidateChunks(s.gci, surplus)
+
+	// A stale whole-object blob at the key must not survive the overwrite —
+	// same invariant as chunked PUT and copy.
+	if blobErr := s.engine.Delete(ctx, t.NamespaceContainer(bucket), object); blobErr != nil &&
+		!strings.Contains(blobErr.Error(), "no such file or directory") &&
+		!strings.Contains(blobErr.Error(), "not found") {
+		s.logger.Warn("multipart complete: stale blob delete failed",
+			zap.Error(blobErr), zap.String("bucket", bucket), zap.String("key", object))
+	}
+
+	if quotaOn {
+		qctx, cancel := quotaCtx(r)
+		s.settlePutQuota(qctx, t.ID, reservedBytes, totalSize, displaced)
+		cancel()
+	}
+
+	_ = os.RemoveAll(multipartDir(uploadID))
+
+	s.logger.Info("multipart upload completed from chunk references",
+		zap.String("bucket", bucket),
+		zap.String("key", object),
+		zap.String("uploadID", uploadID),
+		zap.Int64("totalSize", totalSize),
+		zap.Int("chunks", len(refs)),
+		zap.String("etag", finalETag))
+
+	w.Header().Set("Content-Type", "application/xml")
+	if err := xml.NewEncoder(w).Encode(CompleteMultipartUploadResult{
+		Location: objectLocation(r, bucket, object),
+		Bucket:   bucket,
+		Key:      object,
+		ETag:     finalETag,
+	}); err != nil {
+		s.logger.Error("failed to encode complete response", zap.Error(err))
+	}
+
+	notifySvc := NewNotificationDispatcher(s.db, s.logger)
+	notifySvc.Fire(t.ID, bucket, "s3:ObjectCreated:CompleteMultipartUpload", object, totalSize, etagValue)
+}
@@ -0,0 +1,160 @@
+package api
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCopySourceRange(t *testing.T) {
+	tests := []struct {
+		name       string
+		header     string
+		size       int64
+		wantStart  int64
+		wantLength int64
+		wantErr    bool
+	}{
+		{"first bytes", "bytes=0-4", 10, 0, 5, false},
+		{"last byte", "bytes=9-9", 10, 9, 1, false},
+		{"unknown size", "bytes=100-199", -1, 100, 100, false},
+		{"past end", "bytes=5-10", 10, 0, 0, true},
+		{"open ended", "bytes=5-", 10, 0, 0, true},
+		{"suffix", "bytes=-5", 10, 0, 0, true},
+		{"reversed", "bytes=5-4", 10, 0, 0, true},
+		{"wrong unit", "items=0-4", 10, 0, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, length, err := parseCopySourceRange(tt.header, tt.size)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantStart, start)
+			assert.Equal(t, tt.wantLength, length)
+		})
+	}
+}
+
+func TestMergeChunkSlices(t *testing.T) {
+	parts := []partRecord{{PartNumber: 1}, {PartNumber: 2}}
+
+	// Chunk b is split across the part boundary and re-joins whole.
+	split := map[int][]partChunkSlice{
+		1: {{Scope: "_global", Hash: "a", ChunkSize: 4, Take: 4}, {Scope: "_global", Hash: "b", ChunkSize: 6, Take: 2}},
+		2: {{Scope: "_global", Hash: "b", ChunkSize: 6, Skip: 2, Take: 4}},
+	}
+	merged, surplus, ok := mergeChunkSlices(parts, split)
+	require.True(t, ok)
+	require.Len(t, merged, 2)
+	assert.Equal(t, int64(6), merged[1].Take)
+	require.Len(t, surplus, 1)
+	assert.Equal(t, "b", surplus[0].Hash)
+
+	// A partial chunk cannot be a manifest entry.
+	partial := map[int][]partChunkSlice{
+		1: {{Scope: "_global", Hash: "a", ChunkSize: 4, Take: 4}},
+		2: {{Scope: "_global", Hash: "b", ChunkSize: 6, Take: 3}},
+	}
+	_, _, ok = mergeChunkSlices(parts, partial)
+	assert.False(t, ok)
+
+	// A staged part (no slices) forces the streaming path.
+	_, _, ok = mergeChunkSlices(parts, map[int][]partChunkSlice{1: split[1]})
+	assert.False(t, ok)
+}
+
+func TestUploadPartCopy_RangesFromPlainSource(t *testing.T) {
+	srv, tnt, _ := newTestMultipartServer(t)
+
+	src := []byte("0123456789abcdefghij")
+	putW := doS3Request(srv, tnt, "PUT", "/test-bucket/source.bin", bytes.NewReader(src))
+	require.Equal(t, http.StatusOK, putW.Code)
+
+	initW := doS3Request(srv, tnt, "POST", "/test-bucket/assembled.bin?uploads", nil)
+	require.Equal(t, http.StatusOK, initW.Code)
+	var initResult InitiateMultipartUploadResult
+	require.NoError(t, xml.Unmarshal(initW.Body.Bytes(), &initResult))
+	uploadID := initResult.UploadID
+
+	copyPart := func(partNumber int, rng string) CopyPartResult {
+		req := httptest.NewRequest("PUT",
+			fmt.Sprintf("/test-bucket/assembled.bin?uploadId=%s&partNumber=%d", uploadID, partNumber), nil)
+		req.Header.Set("x-amz-copy-source", "/test-bucket/source.bin")
+		if rng != "" {
+			req.Header.Set("x-amz-copy-source-range", rng)
+		}
+		w := doS3RequestWith(srv, tnt, req)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var result CopyPartResult
+		require.NoError(t, xml.Unmarshal(w.Body.Bytes(), &result))
+		assert.NotEmpty(t, result.ETag)
+		return result
+	}
+	p1 := copyPart(1, "bytes=10-19")
+	p2 := copyPart(2, "bytes=0-3")
+	p3 := copyPart(3, "")
+
+	completeBody := fmt.Sprintf(`<CompleteMultipartUpload>
+		<Part><PartNumber>1</PartNumber><ETag>%s</ETag></Part>
+		<Part><PartNumber>2</PartNumber><ETag>%s</ETag></Part>
+		<Part><PartNumber>3</PartNumber><ETag>%s</ETag></Part>
+	</CompleteMultipartUpload>`, p1.ETag, p2.ETag, p3.ETag)
+	completeW := doS3Request(srv, tnt, "POST",
+		fmt.Sprintf("/test-bucket/assembled.bin?uploadId=%s", uploadID),
+		bytes.NewReader([]byte(completeBody)))
+	require.Equal(t, http.StatusOK, completeW.Code, completeW.Body.String())
+
+	getW := doS3Request(srv, tnt, "GET", "/test-bucket/assembled.bin", nil)
+	require.Equal(t, http.StatusOK, getW.Code)
+	assert.Equal(t, "abcdefghij0123"+string(src), getW.Body.String())
+}
+
+func TestUploadPartCopy_Rejections(t *testing.T) {
+	srv, tnt, _ := newTestMultipartServer(t)
+
+	putW := doS3Request(srv, tnt, "PUT", "/test-bucket/source.bin", bytes.NewReader([]byte("short")))
+	require.Equal(t, http.StatusOK, putW.Code)
+
+	initW := doS3Request(srv, tnt, "POST", "/test-bucket/assembled.bin?uploads", nil)
+	require.Equal(t, http.StatusOK, initW.Code)
+	var initResult InitiateMultipartUploadResult
+	require.NoError(t, xml.Unmarshal(initW.Body.Bytes(), &initResult))
+
+	tests := []struct {
+		name     string
+		headers  map[string]string
+		wantCode int
+		wantErr  string
+	}{
+		{"precondition", map[string]string{"x-amz-copy-source-if-none-match": "*"}, http.StatusPreconditionFailed, ErrPreconditionFailed},
+		{"malformed range", map[string]string{"x-amz-copy-source-range": "bytes=3-"}, http.StatusBadRequest, ErrInvalidArgument},
+		{"range past source end", map[string]string{"x-amz-copy-source-range": "bytes=2-50"}, http.StatusBadRequest, ErrInvalidArgument},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("PUT",
+				fmt.Sprintf("/test-bucket/assembled.bin?uploadId=%s&partNumber=1", initResult.UploadID), nil)
+			req.Header.Set("x-amz-copy-source", "/test-bucket/source.bin")
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			w := doS3RequestWith(srv, tnt, req)
+			assert.Equal(t, tt.wantCode, w.Code)
+			assert.Contains(t, w.Body.String(), tt.wantErr)
+		})
+	}
+
+	// No part was recorded by the rejected copies.
+	memUploadsMu.RLock()
+	defer memUploadsMu.RUnlock()
+	assert.Empty(t, memUploads[initResult.UploadID].Parts)
+}
@@ -0,0 +1,104 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/FairForge/vaultaire/internal/auth"
+)
+
+// Virtual-hosted-style addressing: bucket.s3.stored.ge/key is the same
+// request as s3.stored.ge/bucket/key. virtualHostMiddleware rewrites the
+// former into the latter before routing, so every handler keeps parsing
+// path-style URLs; signature verification uses the URL as sent
+// (auth.SignedURL). The base domains are config.ServerConfig's
+// VirtualHostDomains; with none configured every request is path-style.
+
+// virtualHostBucketKey holds the bucket a virtual-hosted-style request
+// named in its Host header.
+const virtualHostBucketKey contextKey = "virtual_host_bucket"
+
+// virtualHosts matches Host headers against the configured base domains.
+type virtualHosts struct {
+	// domains are normalized and longest first, so s3.example.com wins
+	// over example.com.
+	domains []string
+}
+
+func newVirtualHosts(domains []string) *virtualHosts {
+	v := &virtualHosts{}
+	for _, d := range domains {
+		if d = normalizeWebsiteHost(d); d != "" {
+			v.domains = append(v.domains, d)
+		}
+	}
+	sort.Slice(v.domains, func(i, j int) bool { return len(v.domains[i]) > len(v.domains[j]) })
+	return v
+}
+
+// bucket returns the bucket host names, and whether host is a subdomain of
+// a base domain at all. The base domain itself is path-style.
+func (v *virtualHosts) bucket(host string) (string, bool) {
+	if v == nil || len(v.domains) == 0 {
+		return "", false
+	}
+	host = normalizeWebsiteHost(host)
+	for _, d := range v.domains {
+		if bucket, ok := strings.CutSuffix(host, "."+d); ok && bucket != "" {
+			return bucket, true
+		}
+	}
+	return "", false
+}
+
+// virtualHostMiddleware routes virtual-hosted-style requests path-style.
+// It runs before the body limits and the router, which both go by path.
+func (s *Server) virtualHostMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		bucket, ok := s.virtualHosts.bucket(r.Host)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if !validateBucketName(bucket) {
+			WriteS3ErrorWithContext(w, ErrInvalidBucketName, r.URL.Path, generateRequestID(),
+				WithSuggestion(fmt.Sprintf("%q in the Host header is not a valid bucket name.", bucket)))
+			return
+		}
+		next.ServeHTTP(w, virtualHostedRequest(r, bucket))
+	})
+}
+
+// virtualHostedRequest returns r addressed path-style, remembering the URL
+// the client signed.
+func virtualHostedRequest(r *http.Request, bucket string) *http.Request {
+	signed := r.URL
+	routed := *r.URL
+	routed.Path = "/" + bucket + signed.Path
+	if signed.RawPath != "" {
+		routed.RawPath = "/" + bucket + signed.RawPath
+	}
+	r = auth.WithSignedURL(r, signed)
+	r = r.WithContext(context.WithValue(r.Context(), virtualHostBucketKey, bucket))
+	r.URL = &routed
+	return r
+}
+
+// virtualHostBucket returns the bucket a virtual-hosted-style request
+// named, or "" for a path-style one.
+func virtualHostBucket(r *http.Request) string {
+	bucket, _ := r.Context().Value(virtualHostBucketKey).(string)
+	return bucket
+}
+
+// objectLocation is an object's URL in the style the client addressed it,
+// for the Location of CompleteMultipartUpload.
+func objectLocation(r *http.Request, bucket, key string) string {
+	if virtualHostBucket(r) != "" {
+		return fmt.Sprintf("http://%s/%s", r.Host, key)
+	}
+	return fmt.Sprintf("http://%s/%s/%s", r.Host, bucket, key)
+}
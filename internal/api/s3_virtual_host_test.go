@@ -0,0 +1,88 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/FairForge/vaultaire/internal/auth"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestVirtualHosts_Bucket(t *testing.T) {
+	v := newVirtualHosts([]string{"stored.ge.", "S3.stored.ge", " "})
+	tests := []struct {
+		host   string
+		bucket string
+		ok     bool
+	}{
+		{"photos.s3.stored.ge", "photos", true},
+		{"Photos.S3.Stored.GE:443", "photos", true},
+		{"my.bucket.s3.stored.ge", "my.bucket", true},
+		{"s3.stored.ge", "", false},
+		{"backups.stored.ge", "backups", true},
+		{"stored.ge", "", false},
+		{"photos.example.com", "", false},
+		{"evilstored.ge", "", false},
+	}
+	for _, tt := range tests {
+		bucket, ok := v.bucket(tt.host)
+		assert.Equal(t, tt.ok, ok, tt.host)
+		assert.Equal(t, tt.bucket, bucket, tt.host)
+	}
+
+	var none *virtualHosts
+	_, ok := none.bucket("photos.s3.stored.ge")
+	assert.False(t, ok, "no base domains: path-style only")
+}
+
+func TestVirtualHostMiddleware(t *testing.T) {
+	s := &Server{logger: zap.NewNop(), virtualHosts: newVirtualHosts([]string{"s3.stored.ge"})}
+	var got *http.Request
+	h := s.virtualHostMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { got = r }))
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "http://photos.s3.stored.ge/2026/a%2Fb.jpg?versionId=v1", nil))
+	require.NotNil(t, got)
+	assert.Equal(t, "/photos/2026/a/b.jpg", got.URL.Path)
+	assert.Equal(t, "/photos/2026/a%2Fb.jpg", got.URL.EscapedPath())
+	assert.Equal(t, "versionId=v1", got.URL.RawQuery)
+	assert.Equal(t, "/2026/a%2Fb.jpg", auth.SignedURL(got).EscapedPath(), "signatures cover the path as sent")
+	assert.Equal(t, "photos", virtualHostBucket(got))
+
+	s3Req, err := NewS3Parser(zap.NewNop()).ParseRequest(got)
+	require.NoError(t, err)
+	assert.Equal(t, "photos", s3Req.Bucket)
+	assert.Equal(t, "2026/a/b.jpg", s3Req.Object)
+	assert.Equal(t, "GetObject", s3Req.Operation)
+
+	// The bucket root lists the bucket.
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "http://photos.s3.stored.ge/", nil))
+	s3Req, err = NewS3Parser(zap.NewNop()).ParseRequest(got)
+	require.NoError(t, err)
+	assert.Equal(t, "photos", s3Req.Bucket)
+	assert.Empty(t, s3Req.Object)
+
+	// Path-style requests pass through untouched.
+	got = nil
+	r := httptest.NewRequest("GET", "http://s3.stored.ge/photos/a.jpg", nil)
+	h.ServeHTTP(httptest.NewRecorder(), r)
+	assert.Same(t, r, got)
+	assert.Empty(t, virtualHostBucket(got))
+
+	got = nil
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "http://bad_bucket.s3.stored.ge/a.jpg", nil))
+	assert.Nil(t, got)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "<Code>"+ErrInvalidBucketName+"</Code>")
+}
+
+func TestObjectLocation(t *testing.T) {
+	r := httptest.NewRequest("POST", "http://s3.stored.ge/photos/a.jpg?uploadId=1", nil)
+	assert.Equal(t, "http://s3.stored.ge/photos/a.jpg", objectLocation(r, "photos", "a.jpg"))
+
+	r = virtualHostedRequest(httptest.NewRequest("POST", "http://photos.s3.stored.ge/a.jpg?uploadId=1", nil), "photos")
+	assert.Equal(t, "http://photos.s3.stored.ge/a.jpg", objectLocation(r, "photos", "a.jpg"))
+}
@@ -0,0 +1,429 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/FairForge/vaultaire/internal/tenant"
+	"go.uber.org/zap"
+)
+
+// Static website hosting (?website). A bucket with a website configuration
+// is served by the CDN with website semantics — index documents, an error
+// document, redirects and routing rules — instead of as plain objects; see
+// cdn_website.go for the serving side.
+
+const (
+	maxWebsiteBodyBytes     = 65536
+	maxWebsiteRoutingRules  = 50
+	maxWebsiteRedirectBytes = 2048
+)
+
+// WebsiteConfiguration is the S3 XML document for GET/PUT ?website. Either
+// RedirectAllRequestsTo is set alone, or IndexDocument is required.
+type WebsiteConfiguration struct {
+	XMLName               xml.Name               `xml:"WebsiteConfiguration"`
+	Xmlns                 string                 `xml:"xmlns,attr,omitempty"`
+	RedirectAllRequestsTo *RedirectAllRequestsTo `xml:"RedirectAllRequestsTo,omitempty"`
+	IndexDocument         *IndexDocument         `xml:"IndexDocument,omitempty"`
+	ErrorDocument         *ErrorDocument         `xml:"ErrorDocument,omitempty"`
+	RoutingRules          []RoutingRule          `xml:"RoutingRules>RoutingRule,omitempty"`
+}
+
+// RedirectAllRequestsTo sends every request to another host, keeping the
+// key. An empty Protocol keeps the request's.
+type RedirectAllRequestsTo struct {
+	HostName string `xml:"HostName"`
+	Protocol string `xml:"Protocol,omitempty"`
+}
+
+// IndexDocument names the object served for a request ending in "/", in
+// the bucket root and in every subdirectory.
+type IndexDocument struct {
+	Suffix string `xml:"Suffix"`
+}
+
+// ErrorDocument names the object served with 4XX errors.
+type ErrorDocument struct {
+	Key string `xml:"Key"`
+}
+
+// RoutingRule redirects requests that meet its Condition; a rule without a
+// Condition applies to every request. The first matching rule wins.
+type RoutingRule struct {
+	Condition *RoutingRuleCondition `xml:"Condition,omitempty"`
+	Redirect  RoutingRuleRedirect   `xml:"Redirect"`
+}
+
+// RoutingRuleCondition matches a key prefix, the error the request would
+// return, or both.
+type RoutingRuleCondition struct {
+	KeyPrefixEquals             string `xml:"KeyPrefixEquals,omitempty"`
+	HttpErrorCodeReturnedEquals string `xml:"HttpErrorCodeReturnedEquals,omitempty"`
+}
+
+// RoutingRuleRedirect describes the redirect. ReplaceKeyPrefixWith is a
+// pointer because replacing the matched prefix with nothing is valid.
+type RoutingRuleRedirect struct {
+	HostName             string  `xml:"HostName,omitempty"`
+	HttpRedirectCode     string  `xml:"HttpRedirectCode,omitempty"`
+	Protocol             string  `xml:"Protocol,omitempty"`
+	ReplaceKeyPrefixWith *string `xml:"ReplaceKeyPrefixWith"`
+	ReplaceKeyWith       string  `xml:"ReplaceKeyWith,omitempty"`
+}
+
+// validateWebsite enforces the schema rules the XML decoder cannot.
+func validateWebsite(config *WebsiteConfiguration) error {
+	if ra := config.RedirectAllRequestsTo; ra != nil {
+		if config.IndexDocument != nil || config.ErrorDocument != nil || len(config.RoutingRules) > 0 {
+			return errors.New("RedirectAllRequestsTo cannot be combined with other website settings")
+		}
+		if err := validateWebsiteHost(ra.HostName, true); err != nil {
+			return err
+		}
+		return validateWebsiteProtocol(ra.Protocol)
+	}
+
+	if config.IndexDocument == nil {
+		return errors.New("IndexDocument is required unless RedirectAllRequestsTo is set")
+	}
+	suffix := config.IndexDocument.Suffix
+	if suffix == "" || strings.Contains(suffix, "/") {
+		return fmt.Errorf("IndexDocument Suffix must be a non-empty name without slashes, got %q", suffix)
+	}
+	if config.ErrorDocument != nil && config.ErrorDocument.Key == "" {
+		return errors.New("ErrorDocument requires a Key")
+	}
+
+	if len(config.RoutingRules) > maxWebsiteRoutingRules {
+		return fmt.Errorf("a website configuration may contain at most %d routing rules", maxWebsiteRoutingRules)
+	}
+	for i, rule := range config.RoutingRules {
+		if c := rule.Condition; c != nil {
+			if c.KeyPrefixEquals == "" && c.HttpErrorCodeReturnedEquals == "" {
+				return fmt.Errorf("routing rule %d: Condition requires KeyPrefixEquals or HttpErrorCodeReturnedEquals", i+1)
+			}
+			if c.HttpErrorCodeReturnedEquals != "" {
+				code, err := strconv.Atoi(c.HttpErrorCodeReturnedEquals)
+				if err != nil || code < 400 || code > 599 {
+					return fmt.Errorf("routing rule %d: HttpErrorCodeReturnedEquals must be a 4XX or 5XX code", i+1)
+				}
+			}
+		}
+		rd := rule.Redirect
+		if rd.HostName == "" && rd.HttpRedirectCode == "" && rd.Protocol == "" &&
+			rd.ReplaceKeyPrefixWith == nil && rd.ReplaceKeyWith == "" {
+			return fmt.Errorf("routing rule %d: Redirect must specify at least one element", i+1)
+		}
+		if rd.ReplaceKeyPrefixWith != nil && rd.ReplaceKeyWith != "" {
+			return fmt.Errorf("routing rule %d: ReplaceKeyPrefixWith and ReplaceKeyWith are mutually exclusive", i+1)
+		}
+		if rd.HttpRedirectCode != "" {
+			switch rd.HttpRedirectCode {
+			case "301", "302", "303", "307", "308":
+			default:
+				return fmt.Errorf("routing rule %d: HttpRedirectCode must be 301, 302, 303, 307 or 308", i+1)
+			}
+		}
+		if err := validateWebsiteHost(rd.HostName, false); err != nil {
+			return fmt.Errorf("routing rule %d: %w", i+1, err)
+		}
+		if err := validateWebsiteProtocol(rd.Protocol); err != nil {
+			return fmt.Errorf("routing rule %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+func validateWebsiteHost(host string, required bool) error {
+	if host == "" {
+		if required {
+			return errors.New("HostName is required")
+		}
+		return nil
+	}
+	if strings.ContainsAny(host, "/\\?#@ \t\r\n") {
+		return fmt.Errorf("HostName must be a bare host name, got %q", host)
+	}
+	return nil
+}
+
+func validateWebsiteProtocol(protocol string) error {
+	if protocol != "" && protocol != "http" && protocol != "https" {
+		return fmt.Errorf("Protocol must be http or https, got %q", protocol)
+	}
+	return nil
+}
+
+// validateWebsiteRedirectLocation checks an x-amz-website-redirect-location
+// header: a path in the same website or an absolute http(s) URL.
+func validateWebsiteRedirectLocation(loc string) error {
+	if loc == "" {
+		return nil
+	}
+	if len(loc) > maxWebsiteRedirectBytes {
+		return fmt.Errorf("x-amz-website-redirect-location must be at most %d bytes", maxWebsiteRedirectBytes)
+	}
+	for _, c := range loc {
+		if c < 0x20 || c == 0x7f {
+			return errors.New("x-amz-website-redirect-location must not contain control characters")
+		}
+	}
+	if !strings.HasPrefix(loc, "/") && !strings.HasPrefix(loc, "http://") && !strings.HasPrefix(loc, "https://") {
+		return errors.New("x-amz-website-redirect-location must start with '/', 'http://' or 'https://'")
+	}
+	return nil
+}
+
+// setWebsiteRedirectHeader reports an object's redirect location on HEAD
+// and GET through the S3 API, which serves the object itself.
+func setWebsiteRedirectHeader(w http.ResponseWriter, loc string) {
+	if loc != "" {
+		w.Header().Set("x-amz-website-redirect-location", loc)
+	}
+}
+
+// routingRule returns the first routing rule matching key. errorCode 0
+// selects the rules evaluated before the object is looked up (those without
+// an error condition); otherwise only rules for that error match.
+func (config *WebsiteConfiguration) routingRule(key string, errorCode int) *RoutingRule {
+	for i := range config.RoutingRules {
+		rule := &config.RoutingRules[i]
+		c := rule.Condition
+		if c == nil {
+			if errorCode == 0 {
+				return rule
+			}
+			continue
+		}
+		if !strings.HasPrefix(key, c.KeyPrefixEquals) {
+			continue
+		}
+		if c.HttpErrorCodeReturnedEquals == "" {
+			if errorCode == 0 {
+				return rule
+			}
+			continue
+		}
+		if errorCode != 0 && c.HttpErrorCodeReturnedEquals == strconv.Itoa(errorCode) {
+			return rule
+		}
+	}
+	return nil
+}
+
+// websiteRedirect is a redirect response to a website request.
+type websiteRedirect struct {
+	location string
+	code     int
+}
+
+// redirect builds the redirect for key. base is the URL path the bucket's
+// keys are served under, used when the rule keeps the host.
+func (rule *RoutingRule) redirect(r *http.Request, base, key string) websiteRedirect {
+	rd := rule.Redirect
+	newKey := key
+	switch {
+	case rd.ReplaceKeyWith != "":
+		newKey = rd.ReplaceKeyWith
+	case rd.ReplaceKeyPrefixWith != nil:
+		prefix := ""
+		if rule.Condition != nil {
+			prefix = rule.Condition.KeyPrefixEquals
+		}
+		newKey = *rd.ReplaceKeyPrefixWith + strings.TrimPrefix(key, prefix)
+	}
+
+	code := http.StatusMovedPermanently
+	if rd.HttpRedirectCode != "" {
+		code, _ = strconv.Atoi(rd.HttpRedirectCode)
+	}
+	if rd.HostName == "" && rd.Protocol == "" {
+		return websiteRedirect{location: websitePath(base + newKey), code: code}
+	}
+	host, path := rd.HostName, "/"+newKey
+	if host == "" {
+		host, path = r.Host, base+newKey
+	}
+	return websiteRedirect{location: websiteURL(r, rd.Protocol, host, path), code: code}
+}
+
+// redirect builds the RedirectAllRequestsTo response for key.
+func (ra *RedirectAllRequestsTo) redirect(r *http.Request, key string) websiteRedirect {
+	loc := websiteURL(r, ra.Protocol, ra.HostName, "/"+key)
+	if r.URL.RawQuery != "" {
+		loc += "?" + r.URL.RawQuery
+	}
+	return websiteRedirect{location: loc, code: http.StatusMovedPermanently}
+}
+
+// websitePath escapes a URL path.
+func websitePath(p string) string {
+	return (&url.URL{Path: p}).EscapedPath()
+}
+
+// websiteURL builds an absolute URL; an empty protocol keeps the request's.
+func websiteURL(r *http.Request, protocol, host, path string) string {
+	if protocol == "" {
+		protocol = "http"
+		if r.TLS != nil || strings.EqualFold(r.Header.Get("X-Forwarded-Proto"), "https") {
+			protocol = "https"
+		}
+	}
+	return (&url.URL{Scheme: protocol, Host: host, Path: path}).String()
+}
+
+// decodeStoredWebsite parses a bucket's stored website document; nil when
+// the bucket has none.
+func decodeStoredWebsite(raw sql.NullString) (*WebsiteConfiguration, error) {
+	if !raw.Valid || raw.String == "" {
+		return nil, nil
+	}
+	var config WebsiteConfiguration
+	if err := xml.Unmarshal([]byte(raw.String), &config); err != nil {
+		return nil, fmt.Errorf("decode stored website config: %w", err)
+	}
+	return &config, nil
+}
+
+func (s *Server) handleGetBucketWebsite(w http.ResponseWriter, r *http.Request, req *S3Request) {
+	t, err := tenant.FromContext(r.Context())
+	if err != nil || t == nil {
+		WriteS3Error(w, ErrAccessDenied, r.URL.Path, generateRequestID())
+		return
+	}
+
+	if s.db == nil {
+		WriteS3Error(w, ErrNoSuchWebsiteConfiguration, r.URL.Path, generateRequestID())
+		return
+	}
+
+	var raw sql.NullString
+	err = s.db.QueryRowContext(r.Context(),
+		`SELECT website_config FROM buckets WHERE tenant_id = $1 AND name = $2`,
+		t.ID, req.Bucket).Scan(&raw)
+	if err == sql.ErrNoRows {
+		s.writeNoSuchBucket(w, r, t.ID, req.Bucket)
+		return
+	}
+	if err != nil {
+		s.logger.Error("query bucket website config", zap.Error(err))
+		WriteS3Error(w, ErrInternalError, r.URL.Path, generateRequestID())
+		return
+	}
+	config, err := decodeStoredWebsite(raw)
+	if err != nil {
+		s.logger.Error("decode bucket website config", zap.Error(err))
+		WriteS3Error(w, ErrInternalError, r.URL.Path, generateRequestID())
+		return
+	}
+	if config == nil {
+		WriteS3Error(w, ErrNoSuchWebsiteConfiguration, r.URL.Path, generateRequestID())
+		return
+	}
+
+	config.Xmlns = "http://s3.amazonaws.com/doc/2006-03-01/"
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte(xml.Header))
+	_ = xml.NewEncoder(w).Encode(config)
+}
+
+func (s *Server) handlePutBucketWebsite(w http.ResponseWriter, r *http.Request, req *S3Request) {
+	t, err := tenant.FromContext(r.Context())
+	if err != nil || t == nil {
+		WriteS3Error(w, ErrAccessDenied, r.URL.Path, generateRequestID())
+		return
+	}
+
+	if s.db == nil {
+		WriteS3Error(w, ErrInternalError, r.URL.Path, generateRequestID())
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxWebsiteBodyBytes))
+	if err != nil {
+		WriteS3Error(w, bodyReadErrorCode(err), r.URL.Path, generateRequestID())
+		return
+	}
+
+	var config WebsiteConfiguration
+	if err := xml.Unmarshal(body, &config); err != nil {
+		WriteS3Error(w, ErrMalformedXML, r.URL.Path, generateRequestID())
+		return
+	}
+	if err := validateWebsite(&config); err != nil {
+		WriteS3ErrorWithContext(w, ErrInvalidArgument, r.URL.Path, generateRequestID(),
+			WithSuggestion(err.Error()))
+		return
+	}
+
+	config.Xmlns = ""
+	stored, err := xml.Marshal(config)
+	if err != nil {
+		s.logger.Error("encode website config", zap.Error(err))
+		WriteS3Error(w, ErrInternalError, r.URL.Path, generateRequestID())
+		return
+	}
+
+	result, err := s.db.ExecContext(r.Context(),
+		`UPDATE buckets SET website_config = $3, updated_at = NOW()
+		 WHERE tenant_id = $1 AND name = $2`,
+		t.ID, req.Bucket, string(stored))
+	if err != nil {
+		s.logger.Error("update bucket website config", zap.Error(err))
+		WriteS3Error(w, ErrInternalError, r.URL.Path, generateRequestID())
+		return
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		s.writeNoSuchBucket(w, r, t.ID, req.Bucket)
+		return
+	}
+
+	s.logger.Info("bucket website config updated",
+		zap.String("tenant_id", t.ID),
+		zap.String("bucket", req.Bucket),
+		zap.Int("routing_rules", len(config.RoutingRules)))
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *Server) handleDeleteBucketWebsite(w http.ResponseWriter, r *http.Request, req *S3Request) {
+	t, err := tenant.FromContext(r.Context())
+	if err != nil || t == nil {
+		WriteS3Error(w, ErrAccessDenied, r.URL.Path, generateRequestID())
+		return
+	}
+
+	if s.db == nil {
+		WriteS3Error(w, ErrInternalError, r.URL.Path, generateRequestID())
+		return
+	}
+
+	result, err := s.db.ExecContext(r.Context(),
+		`UPDATE buckets SET website_config = NULL, updated_at = NOW()
+		 WHERE tenant_id = $1 AND name = $2`,
+		t.ID, req.Bucket)
+	if err != nil {
+		s.logger.Error("delete bucket website config", zap.Error(err))
+		WriteS3Error(w, ErrInternalError, r.URL.Path, generateRequestID())
+		return
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		s.writeNoSuchBucket(w, r, t.ID, req.Bucket)
+		return
+	}
+
+	s.logger.Info("bucket website config deleted",
+		zap.String("tenant_id", t.ID),
+		zap.String("bucket", req.Bucket))
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,268 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/FairForge/vaultaire/internal/tenant"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testWebsiteConfig = `<WebsiteConfiguration xmlns="http://s3.amazonaws.com/doc/2006-03-01/">
+  <IndexDocument><Suffix>index.html</Suffix></IndexDocument>
+  <ErrorDocument><Key>404.html</Key></ErrorDocument>
+  <RoutingRules>
+    <RoutingRule>
+      <Condition><KeyPrefixEquals>docs/</KeyPrefixEquals></Condition>
+      <Redirect><ReplaceKeyPrefixWith>documents/</ReplaceKeyPrefixWith></Redirect>
+    </RoutingRule>
+    <RoutingRule>
+      <Condition><HttpErrorCodeReturnedEquals>404</HttpErrorCodeReturnedEquals></Condition>
+      <Redirect><HostName>fallback.example.com</HostName><Protocol>https</Protocol><HttpRedirectCode>302</HttpRedirectCode></Redirect>
+    </RoutingRule>
+  </RoutingRules>
+</WebsiteConfiguration>`
+
+func parseWebsiteXML(t *testing.T, doc string) *WebsiteConfiguration {
+	t.Helper()
+	var config WebsiteConfiguration
+	require.NoError(t, xml.Unmarshal([]byte(doc), &config))
+	return &config
+}
+
+func TestValidateWebsite(t *testing.T) {
+	require.NoError(t, validateWebsite(parseWebsiteXML(t, testWebsiteConfig)))
+	require.NoError(t, validateWebsite(parseWebsiteXML(t,
+		`<WebsiteConfiguration><RedirectAllRequestsTo><HostName>example.com</HostName></RedirectAllRequestsTo></WebsiteConfiguration>`)))
+
+	tests := []struct {
+		name string
+		doc  string
+	}{
+		{"empty", `<WebsiteConfiguration></WebsiteConfiguration>`},
+		{"redirect all with index", `<WebsiteConfiguration><RedirectAllRequestsTo><HostName>example.com</HostName></RedirectAllRequestsTo><IndexDocument><Suffix>index.html</Suffix></IndexDocument></WebsiteConfiguration>`},
+		{"redirect all without host", `<WebsiteConfiguration><RedirectAllRequestsTo><Protocol>https</Protocol></RedirectAllRequestsTo></WebsiteConfiguration>`},
+		{"bad protocol", `<WebsiteConfiguration><RedirectAllRequestsTo><HostName>example.com</HostName><Protocol>ftp</Protocol></RedirectAllRequestsTo></WebsiteConfiguration>`},
+		{"suffix with slash", `<WebsiteConfiguration><IndexDocument><Suffix>a/index.html</Suffix></IndexDocument></WebsiteConfiguration>`},
+		{"empty condition", `<WebsiteConfiguration><IndexDocument><Suffix>index.html</Suffix></IndexDocument><RoutingRules><RoutingRule><Condition></Condition><Redirect><HostName>example.com</HostName></Redirect></RoutingRule></RoutingRules></WebsiteConfiguration>`},
+		{"empty redirect", `<WebsiteConfiguration><IndexDocument><Suffix>index.html</Suffix></IndexDocument><RoutingRules><RoutingRule><Redirect></Redirect></RoutingRule></RoutingRules></WebsiteConfiguration>`},
+		{"bad error code", `<WebsiteConfiguration><IndexDocument><Suffix>index.html</Suffix></IndexDocument><RoutingRules><RoutingRule><Condition><HttpErrorCodeReturnedEquals>200</HttpErrorCodeReturnedEquals></Condition><Redirect><HostName>example.com</HostName></Redirect></RoutingRule></RoutingRules></WebsiteConfiguration>`},
+		{"bad redirect code", `<WebsiteConfiguration><IndexDocument><Suffix>index.html</Suffix></IndexDocument><RoutingRules><RoutingRule><Redirect><HttpRedirectCode>200</HttpRedirectCode></Redirect></RoutingRule></RoutingRules></WebsiteConfiguration>`},
+		{"both replacements", `<WebsiteConfiguration><IndexDocument><Suffix>index.html</Suffix></IndexDocument><RoutingRules><RoutingRule><Redirect><ReplaceKeyPrefixWith>a/</ReplaceKeyPrefixWith><ReplaceKeyWith>b</ReplaceKeyWith></Redirect></RoutingRule></RoutingRules></WebsiteConfiguration>`},
+		{"host with path", `<WebsiteConfiguration><IndexDocument><Suffix>index.html</Suffix></IndexDocument><RoutingRules><RoutingRule><Redirect><HostName>example.com/x</HostName></Redirect></RoutingRule></RoutingRules></WebsiteConfiguration>`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Error(t, validateWebsite(parseWebsiteXML(t, tt.doc)))
+		})
+	}
+}
+
+func TestWebsiteRoutingRule(t *testing.T) {
+	config := parseWebsiteXML(t, testWebsiteConfig)
+	r := httptest.NewRequest("GET", "/docs/a.html", nil)
+
+	rule := config.routingRule("docs/guide/a b.html", 0)
+	require.Same(t, &config.RoutingRules[0], rule)
+	assert.Equal(t, websiteRedirect{location: "/s/site/documents/guide/a%20b.html", code: http.StatusMovedPermanently},
+		rule.redirect(r, "/s/site/", "docs/guide/a b.html"))
+
+	assert.Nil(t, config.routingRule("blog/a.html", 0), "error rules do not apply before lookup")
+	assert.Nil(t, config.routingRule("blog/a.html", http.StatusForbidden))
+
+	rule = config.routingRule("blog/a.html", http.StatusNotFound)
+	require.Same(t, &config.RoutingRules[1], rule)
+	assert.Equal(t, websiteRedirect{location: "https://fallback.example.com/blog/a.html", code: http.StatusFound},
+		rule.redirect(r, "/s/site/", "blog/a.html"))
+}
+
+func TestRedirectAllRequestsTo(t *testing.T) {
+	ra := &RedirectAllRequestsTo{HostName: "www.example.com"}
+	r := httptest.NewRequest("GET", "/a/b.html?x=1", nil)
+	r.Header.Set("X-Forwarded-Proto", "https")
+	assert.Equal(t, websiteRedirect{location: "https://www.example.com/a/b.html?x=1", code: http.StatusMovedPermanently},
+		ra.redirect(r, "a/b.html"))
+
+	ra.Protocol = "http"
+	assert.Equal(t, "http://www.example.com/", ra.redirect(httptest.NewRequest("GET", "/", nil), "").location)
+}
+
+func TestValidateWebsiteRedirectLocation(t *testing.T) {
+	for _, loc := range []string{"", "/other.html", "https://example.com/x", "http://example.com"} {
+		assert.NoError(t, validateWebsiteRedirectLocation(loc), loc)
+	}
+	for _, loc := range []string{"other.html", "javascript:alert(1)", "/a\r\nSet-Cookie: x=1", "/" + string(bytes.Repeat([]byte("a"), maxWebsiteRedirectBytes))} {
+		assert.Error(t, validateWebsiteRedirectLocation(loc), loc)
+	}
+}
+
+func TestBucketWebsite_PutGetDelete(t *testing.T) {
+	s, mock := newPolicyTestServer(t)
+	ctx := tenant.WithTenant(context.Background(), &tenant.Tenant{ID: "tenant-1"})
+	s3Req := &S3Request{Bucket: "site"}
+
+	mock.ExpectExec(`UPDATE buckets SET website_config`).
+		WithArgs("tenant-1", "site", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	r := httptest.NewRequest("PUT", "/site?website", bytes.NewReader([]byte(testWebsiteConfig))).WithContext(ctx)
+	w := httptest.NewRecorder()
+	s.handlePutBucketWebsite(w, r, s3Req)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	mock.ExpectQuery(`SELECT website_config FROM buckets`).
+		WillReturnRows(sqlmock.NewRows([]string{"website_config"}).AddRow(testWebsiteConfig))
+	r = httptest.NewRequest("GET", "/site?website", nil).WithContext(ctx)
+	w = httptest.NewRecorder()
+	s.handleGetBucketWebsite(w, r, s3Req)
+	require.Equal(t, http.StatusOK, w.Code)
+	resp := parseWebsiteXML(t, w.Body.String())
+	assert.Equal(t, "index.html", resp.IndexDocument.Suffix)
+	require.Len(t, resp.RoutingRules, 2)
+	assert.Equal(t, "documents/", *resp.RoutingRules[0].Redirect.ReplaceKeyPrefixWith)
+
+	mock.ExpectExec(`UPDATE buckets SET website_config = NULL`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	r = httptest.NewRequest("DELETE", "/site?website", nil).WithContext(ctx)
+	w = httptest.NewRecorder()
+	s.handleDeleteBucketWebsite(w, r, s3Req)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	mock.ExpectQuery(`SELECT website_config FROM buckets`).
+		WillReturnRows(sqlmock.NewRows([]string{"website_config"}).AddRow(nil))
+	r = httptest.NewRequest("GET", "/site?website", nil).WithContext(ctx)
+	w = httptest.NewRecorder()
+	s.handleGetBucketWebsite(w, r, s3Req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Contains(t, w.Body.String(), "<Code>"+ErrNoSuchWebsiteConfiguration+"</Code>")
+
+	// An invalid document is refused before touching the database.
+	r = httptest.NewRequest("PUT", "/site?website",
+		bytes.NewReader([]byte(`<WebsiteConfiguration></WebsiteConfiguration>`))).WithContext(ctx)
+	w = httptest.NewRecorder()
+	s.handlePutBucketWebsite(w, r, s3Req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+var websiteHeadColumns = []string{"size_bytes", "etag", "content_type", "updated_at",
+	"content_disposition", "backend_name", "website_redirect_location", "acl"}
+
+func websiteTestSite(t *testing.T) *cdnSite {
+	return &cdnSite{
+		tenantID:   "tenant-1",
+		slug:       "s",
+		bucket:     "site",
+		visibility: "public-read",
+		website:    parseWebsiteXML(t, testWebsiteConfig),
+		base:       "/s/site/",
+	}
+}
+
+func TestServeWebsite_Redirects(t *testing.T) {
+	s, mock := newPolicyTestServer(t)
+	site := websiteTestSite(t)
+
+	// The root without its trailing slash.
+	w := httptest.NewRecorder()
+	s.serveWebsite(w, httptest.NewRequest("GET", "/s/site", nil), site, "")
+	assert.Equal(t, http.StatusFound, w.Code)
+	assert.Equal(t, "/s/site/", w.Header().Get("Location"))
+
+	// A prefix routing rule, before any lookup.
+	w = httptest.NewRecorder()
+	s.serveWebsite(w, httptest.NewRequest("GET", "/s/site/docs/a.html", nil), site, "docs/a.html")
+	assert.Equal(t, http.StatusMovedPermanently, w.Code)
+	assert.Equal(t, "/s/site/documents/a.html", w.Header().Get("Location"))
+
+	// An object's redirect location, relative to the website root.
+	mock.ExpectQuery(`FROM object_head_cache`).
+		WithArgs("tenant-1", "site", "old.html").
+		WillReturnRows(sqlmock.NewRows(websiteHeadColumns).
+			AddRow(0, "e", "text/html", time.Now(), "", "", "/new.html", nil))
+	w = httptest.NewRecorder()
+	s.serveWebsite(w, httptest.NewRequest("GET", "/s/site/old.html", nil), site, "old.html")
+	assert.Equal(t, http.StatusMovedPermanently, w.Code)
+	assert.Equal(t, "/s/site/new.html", w.Header().Get("Location"))
+
+	// A directory without its trailing slash, when it has an index.
+	mock.ExpectQuery(`FROM object_head_cache`).
+		WithArgs("tenant-1", "site", "guide").
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectQuery(`FROM object_head_cache`).
+		WithArgs("tenant-1", "site", "guide/index.html").
+		WillReturnRows(sqlmock.NewRows(websiteHeadColumns).
+			AddRow(10, "e", "text/html", time.Now(), "", "", "", nil))
+	w = httptest.NewRecorder()
+	s.serveWebsite(w, httptest.NewRequest("GET", "/s/site/guide", nil), site, "guide")
+	assert.Equal(t, http.StatusFound, w.Code)
+	assert.Equal(t, "/s/site/guide/", w.Header().Get("Location"))
+
+	// A miss goes through the 404 routing rule.
+	mock.ExpectQuery(`FROM object_head_cache`).
+		WithArgs("tenant-1", "site", "blog/index.html").
+		WillReturnError(sql.ErrNoRows)
+	w = httptest.NewRecorder()
+	s.serveWebsite(w, httptest.NewRequest("GET", "/s/site/blog/", nil), site, "blog/")
+	assert.Equal(t, http.StatusFound, w.Code)
+	assert.Equal(t, "https://fallback.example.com/blog/", w.Header().Get("Location"))
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestServeWebsite_RedirectAllRequestsTo(t *testing.T) {
+	s, _ := newPolicyTestServer(t)
+	site := websiteTestSite(t)
+	site.website = &WebsiteConfiguration{RedirectAllRequestsTo: &RedirectAllRequestsTo{HostName: "example.com", Protocol: "https"}}
+
+	w := httptest.NewRecorder()
+	s.serveWebsite(w, httptest.NewRequest("GET", "/s/site/a.html", nil), site, "a.html")
+	assert.Equal(t, http.StatusMovedPermanently, w.Code)
+	assert.Equal(t, "https://example.com/a.html", w.Header().Get("Location"))
+}
+
+func TestServeWebsite_NotFoundWithoutErrorDocument(t *testing.T) {
+	s, mock := newPolicyTestServer(t)
+	site := websiteTestSite(t)
+	site.website.ErrorDocument = nil
+	site.website.RoutingRules = nil
+
+	mock.ExpectQuery(`FROM object_head_cache`).
+		WithArgs("tenant-1", "site", "missing.html").
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectQuery(`FROM object_head_cache`).
+		WithArgs("tenant-1", "site", "missing.html/index.html").
+		WillReturnError(sql.ErrNoRows)
+	w := httptest.NewRecorder()
+	s.serveWebsite(w, httptest.NewRequest("GET", "/s/site/missing.html", nil), site, "missing.html")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Contains(t, w.Body.String(), "404 Not Found")
+
+	// A private bucket answers 403 unless the object's ACL is public.
+	site.visibility = "private"
+	mock.ExpectQuery(`FROM object_head_cache`).
+		WithArgs("tenant-1", "site", "a.html").
+		WillReturnRows(sqlmock.NewRows(websiteHeadColumns).
+			AddRow(10, "e", "text/html", time.Now(), "", "", "", nil))
+	w = httptest.NewRecorder()
+	s.serveWebsite(w, httptest.NewRequest("GET", "/s/site/a.html", nil), site, "a.html")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestValidWebsiteHostname(t *testing.T) {
+	assert.Equal(t, "docs.example.com", normalizeWebsiteHost("Docs.Example.com.:443"))
+	assert.True(t, validWebsiteHostname("docs.example.com"))
+	for _, host := range []string{"", "localhost", "10.0.0.1", "cdn.stored.ge", "bad_host.example.com"} {
+		assert.False(t, validWebsiteHostname(host), host)
+	}
+}
@@ -0,0 +1,748 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/FairForge/vaultaire/internal/auth"
+	"github.com/FairForge/vaultaire/internal/scim"
+	"github.com/go-chi/chi/v5"
+	"go.uber.org/zap"
+)
+
+// registerSCIMRoutes registers the SCIM 2.0 endpoints a tenant's identity
+// provider provisions members and groups through, and the management API
+// for the tenant's SCIM tokens and group-to-role mapping.
+func (s *Server) registerSCIMRoutes() {
+	s.router.Route("/api/v1/scim", func(r chi.Router) {
+		r.Use(s.requireJWT)
+		r.Use(s.requireTenantOwner)
+		r.Post("/tokens", s.handleCreateSCIMToken)
+		r.Get("/tokens", s.handleListSCIMTokens)
+		r.Delete("/tokens/{id}", s.handleDeleteSCIMToken)
+		r.Get("/config", s.handleGetSCIMConfig)
+		r.Put("/config", s.handlePutSCIMConfig)
+	})
+
+	s.router.Route("/scim/v2", func(r chi.Router) {
+		r.Use(s.requireSCIMToken)
+		r.Get("/ServiceProviderConfig", s.handleSCIMServiceProviderConfig)
+		r.Get("/ResourceTypes", s.handleSCIMResourceTypes)
+		r.Get("/ResourceTypes/{id}", s.handleSCIMResourceTypes)
+		r.Get("/Schemas", s.handleSCIMSchemas)
+		r.Get("/Schemas/{id}", s.handleSCIMSchemas)
+
+		r.Get("/Users", s.handleSCIMListUsers)
+		r.Post("/Users", s.handleSCIMCreateUser)
+		r.Get("/Users/{id}", s.handleSCIMGetUser)
+		r.Put("/Users/{id}", s.handleSCIMReplaceUser)
+		r.Patch("/Users/{id}", s.handleSCIMPatchUser)
+		r.Delete("/Users/{id}", s.handleSCIMDeleteUser)
+
+		r.Get("/Groups", s.handleSCIMListGroups)
+		r.Post("/Groups", s.handleSCIMCreateGroup)
+		r.Get("/Groups/{id}", s.handleSCIMGetGroup)
+		r.Put("/Groups/{id}", s.handleSCIMReplaceGroup)
+		r.Patch("/Groups/{id}", s.handleSCIMPatchGroup)
+		r.Delete("/Groups/{id}", s.handleSCIMDeleteGroup)
+	})
+}
+
+// requireTenantOwner admits only the owner of the tenant in the JWT:
+// members could otherwise provision themselves or deprovision others.
+func (s *Server) requireTenantOwner(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID, _ := r.Context().Value(userIDKey).(string)
+		tenantID, _ := r.Context().Value(tenantIDKey).(string)
+		if tenantID == "" {
+			writeManagementError(w, ErrTypeAuthentication, "missing_tenant", "tenant not found in token", "")
+			return
+		}
+		if userID == "" || s.auth.GetUserIDByTenantID(r.Context(), tenantID) != userID {
+			writeManagementError(w, ErrTypePermission, "owner_required", "only the tenant owner can manage SCIM provisioning", "")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requireSCIMToken authenticates a SCIM bearer token and puts its tenant
+// in the request context.
+func (s *Server) requireSCIMToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := r.Header.Get("Authorization")
+		token := ""
+		if len(header) > 7 && strings.EqualFold(header[:7], "Bearer ") {
+			token = strings.TrimSpace(header[7:])
+		}
+		tenantID, err := auth.ValidateSCIMToken(r.Context(), s.db, token)
+		if errors.Is(err, auth.ErrSCIMTokenNotFound) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="scim"`)
+			writeSCIMError(w, scim.NewError(http.StatusUnauthorized, "", "invalid or missing bearer token"))
+			return
+		}
+		if err != nil {
+			s.logger.Error("scim: validate token", zap.Error(err))
+			writeSCIMError(w, err)
+			return
+		}
+		ctx := context.WithValue(r.Context(), tenantIDKey, tenantID)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func writeSCIM(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", scim.ContentType)
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// writeSCIMError renders err as a SCIM error; errors that are not
+// *scim.Error are internal and their detail is not shown.
+func writeSCIMError(w http.ResponseWriter, err error) {
+	var scimErr *scim.Error
+	if !errors.As(err, &scimErr) {
+		scimErr = scim.NewError(http.StatusInternalServerError, "", "internal error")
+	}
+	writeSCIM(w, scimErr.Status, scimErr)
+}
+
+// scimStoreError maps a provisioning store error to a SCIM error.
+func (s *Server) scimStoreError(w http.ResponseWriter, op string, err error) {
+	switch {
+	case errors.Is(err, auth.ErrSCIMConflict):
+		writeSCIMError(w, scim.NewError(http.StatusConflict, scim.ErrUniqueness, "%v", err))
+	case errors.Is(err, auth.ErrOIDCEmailDomain):
+		writeSCIMError(w, scim.BadRequest(scim.ErrInvalidValue,
+			"%v; add the domain to one of the tenant's single sign-on connections", err))
+	default:
+		s.logger.Error("scim: "+op, zap.Error(err))
+		writeSCIMError(w, err)
+	}
+}
+
+func (s *Server) scimBaseURL() string {
+	return s.baseURL + "/scim/v2"
+}
+
+func scimTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+// decodeSCIMBody reads a JSON object request body.
+func decodeSCIMBody(r *http.Request) (map[string]interface{}, error) {
+	var m map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&m); err != nil || m == nil {
+		return nil, scim.BadRequest(scim.ErrInvalidSyntax, "request body must be a JSON object")
+	}
+	return m, nil
+}
+
+// writeSCIMList filters, pages and projects resources for a list request.
+func writeSCIMList(w http.ResponseWriter, r *http.Request, resources []interface{}) {
+	q := r.URL.Query()
+	var filter scim.Filter
+	if f := q.Get("filter"); f != "" {
+		var err error
+		if filter, err = scim.ParseFilter(f); err != nil {
+			writeSCIMError(w, err)
+			return
+		}
+	}
+	startIndex, count := 1, scim.DefaultCount
+	for _, p := range []struct {
+		name string
+		v    *int
+	}{{"startIndex", &startIndex}, {"count", &count}} {
+		if raw := q.Get(p.name); raw != "" {
+			n, err := strconv.Atoi(raw)
+			if err != nil {
+				writeSCIMError(w, scim.BadRequest(scim.ErrInvalidValue, "%s must be an integer", p.name))
+				return
+			}
+			*p.v = n
+		}
+	}
+	count = min(max(count, 0), scim.MaxCount)
+
+	matched := []interface{}{}
+	for _, res := range resources {
+		m, err := scim.ToMap(res)
+		if err != nil {
+			writeSCIMError(w, err)
+			return
+		}
+		if filter != nil && !filter.Match(m) {
+			continue
+		}
+		scim.Project(m, q.Get("attributes"), q.Get("excludedAttributes"))
+		matched = append(matched, m)
+	}
+	writeSCIM(w, http.StatusOK, scim.NewListResponse(matched, startIndex, count))
+}
+
+// writeSCIMResource writes a single resource, projected as the request
+// asks.
+func writeSCIMResource(w http.ResponseWriter, r *http.Request, status int, res interface{}) {
+	m, err := scim.ToMap(res)
+	if err != nil {
+		writeSCIMError(w, err)
+		return
+	}
+	scim.Project(m, r.URL.Query().Get("attributes"), r.URL.Query().Get("excludedAttributes"))
+	writeSCIM(w, status, m)
+}
+
+// --- Discovery ---
+
+func (s *Server) handleSCIMServiceProviderConfig(w http.ResponseWriter, r *http.Request) {
+	writeSCIM(w, http.StatusOK, scim.ServiceProviderConfig(s.scimBaseURL()))
+}
+
+func (s *Server) handleSCIMResourceTypes(w http.ResponseWriter, r *http.Request) {
+	var resources []interface{}
+	for _, rt := range scim.ResourceTypes(s.scimBaseURL()) {
+		resources = append(resources, rt)
+	}
+	writeSCIMDiscovery(w, chi.URLParam(r, "id"), resources, func(res interface{}) string {
+		return res.(scim.ResourceType).ID
+	})
+}
+
+func (s *Server) handleSCIMSchemas(w http.ResponseWriter, r *http.Request) {
+	var resources []interface{}
+	for _, sc := range scim.Schemas(s.scimBaseURL()) {
+		resources = append(resources, sc)
+	}
+	writeSCIMDiscovery(w, chi.URLParam(r, "id"), resources, func(res interface{}) string {
+		return res.(scim.Schema).ID
+	})
+}
+
+// writeSCIMDiscovery lists discovery resources, or writes the one with id.
+func writeSCIMDiscovery(w http.ResponseWriter, id string, resources []interface{}, idOf func(interface{}) string) {
+	if id == "" {
+		writeSCIM(w, http.StatusOK, scim.NewListResponse(resources, 1, len(resources)))
+		return
+	}
+	for _, res := range resources {
+		if idOf(res) == id {
+			writeSCIM(w, http.StatusOK, res)
+			return
+		}
+	}
+	writeSCIMError(w, scim.NewError(http.StatusNotFound, "", "%s not found", id))
+}
+
+// --- Users ---
+
+func (s *Server) scimUser(m *auth.TenantMember) *scim.User {
+	base := s.scimBaseURL()
+	active := m.Active
+	u := &scim.User{
+		Schemas:     []string{scim.UserSchema},
+		ID:          m.UserID,
+		ExternalID:  m.ExternalID,
+		UserName:    m.UserName,
+		DisplayName: m.DisplayName,
+		Emails:      []scim.Email{{Value: m.Email, Type: "work", Primary: true}},
+		Active:      &active,
+		Meta: &scim.Meta{
+			ResourceType: "User",
+			Created:      scimTime(m.CreatedAt),
+			LastModified: scimTime(m.UpdatedAt),
+			Location:     base + "/Users/" + m.UserID,
+		},
+	}
+	if m.GivenName != "" || m.FamilyName != "" {
+		u.Name = &scim.Name{
+			Formatted:  strings.TrimSpace(m.GivenName + " " + m.FamilyName),
+			GivenName:  m.GivenName,
+			FamilyName: m.FamilyName,
+		}
+	}
+	for _, g := range m.Groups {
+		u.Groups = append(u.Groups, scim.Ref{Value: g.ID, Ref: base + "/Groups/" + g.ID, Display: g.Display})
+	}
+	return u
+}
+
+// memberFromSCIM maps a User to a member. The sign-in address is the
+// primary email, or else an email-shaped userName.
+func memberFromSCIM(body map[string]interface{}, tenantID, userID string) (*auth.TenantMember, error) {
+	u, err := scim.UserFromMap(body)
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(u.UserName) == "" {
+		return nil, scim.BadRequest(scim.ErrInvalidValue, "userName is required")
+	}
+	email := strings.ToLower(strings.TrimSpace(u.PrimaryEmail()))
+	if email == "" && strings.Contains(u.UserName, "@") {
+		email = strings.ToLower(strings.TrimSpace(u.UserName))
+	}
+	if _, domain, ok := strings.Cut(email, "@"); !ok || domain == "" {
+		return nil, scim.BadRequest(scim.ErrInvalidValue, "an email address is required, in emails or as the userName")
+	}
+	m := &auth.TenantMember{
+		UserID:      userID,
+		TenantID:    tenantID,
+		UserName:    strings.TrimSpace(u.UserName),
+		ExternalID:  u.ExternalID,
+		Email:       email,
+		DisplayName: u.DisplayName,
+		Active:      u.Active == nil || *u.Active,
+	}
+	if u.Name != nil {
+		m.GivenName, m.FamilyName = u.Name.GivenName, u.Name.FamilyName
+	}
+	return m, nil
+}
+
+func (s *Server) handleSCIMListUsers(w http.ResponseWriter, r *http.Request) {
+	tenantID, _ := r.Context().Value(tenantIDKey).(string)
+	members, err := s.auth.ListSCIMMembers(r.Context(), tenantID)
+	if err != nil {
+		s.scimStoreError(w, "list users", err)
+		return
+	}
+	var resources []interface{}
+	for _, m := range members {
+		resources = append(resources, s.scimUser(m))
+	}
+	writeSCIMList(w, r, resources)
+}
+
+func (s *Server) handleSCIMGetUser(w http.ResponseWriter, r *http.Request) {
+	tenantID, _ := r.Context().Value(tenantIDKey).(string)
+	m, err := s.auth.GetSCIMMember(r.Context(), tenantID, chi.URLParam(r, "id"))
+	if errors.Is(err, auth.ErrSCIMMemberNotFound) {
+		writeSCIMError(w, scim.NewError(http.StatusNotFound, "", "user not found"))
+		return
+	}
+	if err != nil {
+		s.scimStoreError(w, "get user", err)
+		return
+	}
+	writeSCIMResource(w, r, http.StatusOK, s.scimUser(m))
+}
+
+func (s *Server) handleSCIMCreateUser(w http.ResponseWriter, r *http.Request) {
+	tenantID, _ := r.Context().Value(tenantIDKey).(string)
+	body, err := decodeSCIMBody(r)
+	if err != nil {
+		writeSCIMError(w, err)
+		return
+	}
+	m, err := memberFromSCIM(body, tenantID, "")
+	if err != nil {
+		writeSCIMError(w, err)
+		return
+	}
+	created, err := s.auth.CreateSCIMMember(r.Context(), m)
+	if err != nil {
+		s.scimStoreError(w, "create user", err)
+		return
+	}
+	emitEvent(r.Context(), s.db, s.logger, "member.provisioned", tenantID, map[string]interface{}{
+		"user_id": created.UserID, "user_name": created.UserName,
+	})
+	u := s.scimUser(created)
+	w.Header().Set("Location", u.Meta.Location)
+	writeSCIMResource(w, r, http.StatusCreated, u)
+}
+
+func (s *Server) handleSCIMReplaceUser(w http.ResponseWriter, r *http.Request) {
+	tenantID, _ := r.Context().Value(tenantIDKey).(string)
+	body, err := decodeSCIMBody(r)
+	if err != nil {
+		writeSCIMError(w, err)
+		return
+	}
+	s.replaceSCIMUser(w, r, tenantID, func(*auth.TenantMember) (map[string]interface{}, error) {
+		return body, nil
+	})
+}
+
+func (s *Server) handleSCIMPatchUser(w http.ResponseWriter, r *http.Request) {
+	tenantID, _ := r.Context().Value(tenantIDKey).(string)
+	var req scim.PatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeSCIMError(w, scim.BadRequest(scim.ErrInvalidSyntax, "request body must be a PatchOp message"))
+		return
+	}
+	if err := req.Validate(); err != nil {
+		writeSCIMError(w, err)
+		return
+	}
+	s.replaceSCIMUser(w, r, tenantID, func(cur *auth.TenantMember) (map[string]interface{}, error) {
+		res, err := scim.ToMap(s.scimUser(cur))
+		if err != nil {
+			return nil, err
+		}
+		if err := scim.ApplyPatch(res, req.Operations); err != nil {
+			return nil, err
+		}
+		return res, nil
+	})
+}
+
+// replaceSCIMUser replaces a member with the User body returns, given the
+// member's current state. Deactivating the member deprovisions them.
+func (s *Server) replaceSCIMUser(w http.ResponseWriter, r *http.Request, tenantID string,
+	body func(*auth.TenantMember) (map[string]interface{}, error)) {
+	userID := chi.URLParam(r, "id")
+	cur, err := s.auth.GetSCIMMember(r.Context(), tenantID, userID)
+	if errors.Is(err, auth.ErrSCIMMemberNotFound) {
+		writeSCIMError(w, scim.NewError(http.StatusNotFound, "", "user not found"))
+		return
+	}
+	if err != nil {
+		s.scimStoreError(w, "get user", err)
+		return
+	}
+	res, err := body(cur)
+	if err != nil {
+		writeSCIMError(w, err)
+		return
+	}
+	m, err := memberFromSCIM(res, tenantID, userID)
+	if err != nil {
+		writeSCIMError(w, err)
+		return
+	}
+	updated, err := s.auth.ReplaceSCIMMember(r.Context(), m)
+	if errors.Is(err, auth.ErrSCIMMemberNotFound) {
+		writeSCIMError(w, scim.NewError(http.StatusNotFound, "", "user not found"))
+		return
+	}
+	if err != nil {
+		s.scimStoreError(w, "update user", err)
+		return
+	}
+	if cur.Active && !updated.Active {
+		emitEvent(r.Context(), s.db, s.logger, "member.deprovisioned", tenantID, map[string]interface{}{
+			"user_id": updated.UserID, "user_name": updated.UserName, "deleted": false,
+		})
+	}
+	writeSCIMResource(w, r, http.StatusOK, s.scimUser(updated))
+}
+
+func (s *Server) handleSCIMDeleteUser(w http.ResponseWriter, r *http.Request) {
+	tenantID, _ := r.Context().Value(tenantIDKey).(string)
+	userID := chi.URLParam(r, "id")
+	err := s.auth.DeleteSCIMMember(r.Context(), tenantID, userID)
+	if errors.Is(err, auth.ErrSCIMMemberNotFound) {
+		writeSCIMError(w, scim.NewError(http.StatusNotFound, "", "user not found"))
+		return
+	}
+	if err != nil {
+		s.scimStoreError(w, "delete user", err)
+		return
+	}
+	emitEvent(r.Context(), s.db, s.logger, "member.deprovisioned", tenantID, map[string]interface{}{
+		"user_id": userID, "deleted": true,
+	})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// --- Groups ---
+
+func (s *Server) scimGroup(g *auth.SCIMGroup) *scim.Group {
+	base := s.scimBaseURL()
+	out := &scim.Group{
+		Schemas:     []string{scim.GroupSchema},
+		ID:          g.ID,
+		ExternalID:  g.ExternalID,
+		DisplayName: g.DisplayName,
+		Meta: &scim.Meta{
+			ResourceType: "Group",
+			Created:      scimTime(g.CreatedAt),
+			LastModified: scimTime(g.UpdatedAt),
+			Location:     base + "/Groups/" + g.ID,
+		},
+	}
+	for _, m := range g.Members {
+		out.Members = append(out.Members, scim.Ref{Value: m.ID, Ref: base + "/Users/" + m.ID, Display: m.Display})
+	}
+	return out
+}
+
+func groupFromSCIM(body map[string]interface{}, tenantID, id string) (*auth.SCIMGroup, error) {
+	var g scim.Group
+	if err := scim.FromMap(body, &g); err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(g.DisplayName) == "" {
+		return nil, scim.BadRequest(scim.ErrInvalidValue, "displayName is required")
+	}
+	out := &auth.SCIMGroup{
+		ID:          id,
+		TenantID:    tenantID,
+		DisplayName: strings.TrimSpace(g.DisplayName),
+		ExternalID:  g.ExternalID,
+	}
+	for _, m := range g.Members {
+		out.Members = append(out.Members, auth.MemberRef{ID: m.Value})
+	}
+	return out, nil
+}
+
+// scimGroupStoreError maps group store errors; an unknown member is the
+// client's mistake.
+func (s *Server) scimGroupStoreError(w http.ResponseWriter, op string, err error) {
+	switch {
+	case errors.Is(err, auth.ErrSCIMGroupNotFound):
+		writeSCIMError(w, scim.NewError(http.StatusNotFound, "", "group not found"))
+	case errors.Is(err, auth.ErrSCIMMemberNotFound):
+		writeSCIMError(w, scim.BadRequest(scim.ErrInvalidValue, "%v", err))
+	default:
+		s.scimStoreError(w, op, err)
+	}
+}
+
+func (s *Server) handleSCIMListGroups(w http.ResponseWriter, r *http.Request) {
+	tenantID, _ := r.Context().Value(tenantIDKey).(string)
+	groups, err := s.auth.ListSCIMGroups(r.Context(), tenantID)
+	if err != nil {
+		s.scimGroupStoreError(w, "list groups", err)
+		return
+	}
+	var resources []interface{}
+	for _, g := range groups {
+		resources = append(resources, s.scimGroup(g))
+	}
+	writeSCIMList(w, r, resources)
+}
+
+func (s *Server) handleSCIMGetGroup(w http.ResponseWriter, r *http.Request) {
+	tenantID, _ := r.Context().Value(tenantIDKey).(string)
+	g, err := s.auth.GetSCIMGroup(r.Context(), tenantID, chi.URLParam(r, "id"))
+	if err != nil {
+		s.scimGroupStoreError(w, "get group", err)
+		return
+	}
+	writeSCIMResource(w, r, http.StatusOK, s.scimGroup(g))
+}
+
+func (s *Server) handleSCIMCreateGroup(w http.ResponseWriter, r *http.Request) {
+	tenantID, _ := r.Context().Value(tenantIDKey).(string)
+	body, err := decodeSCIMBody(r)
+	if err != nil {
+		writeSCIMError(w, err)
+		return
+	}
+	g, err := groupFromSCIM(body, tenantID, "")
+	if err != nil {
+		writeSCIMError(w, err)
+		return
+	}
+	created, err := s.auth.CreateSCIMGroup(r.Context(), g)
+	if err != nil {
+		s.scimGroupStoreError(w, "create group", err)
+		return
+	}
+	out := s.scimGroup(created)
+	w.Header().Set("Location", out.Meta.Location)
+	writeSCIMResource(w, r, http.StatusCreated, out)
+}
+
+func (s *Server) handleSCIMReplaceGroup(w http.ResponseWriter, r *http.Request) {
+	tenantID, _ := r.Context().Value(tenantIDKey).(string)
+	body, err := decodeSCIMBody(r)
+	if err != nil {
+		writeSCIMError(w, err)
+		return
+	}
+	g, err := groupFromSCIM(body, tenantID, chi.URLParam(r, "id"))
+	if err != nil {
+		writeSCIMError(w, err)
+		return
+	}
+	s.replaceSCIMGroup(w, r, g)
+}
+
+func (s *Server) handleSCIMPatchGroup(w http.ResponseWriter, r *http.Request) {
+	tenantID, _ := r.Context().Value(tenantIDKey).(string)
+	var req scim.PatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeSCIMError(w, scim.BadRequest(scim.ErrInvalidSyntax, "request body must be a PatchOp message"))
+		return
+	}
+	if err := req.Validate(); err != nil {
+		writeSCIMError(w, err)
+		return
+	}
+	cur, err := s.auth.GetSCIMGroup(r.Context(), tenantID, chi.URLParam(r, "id"))
+	if err != nil {
+		s.scimGroupStoreError(w, "get group", err)
+		return
+	}
+	res, err := scim.ToMap(s.scimGroup(cur))
+	if err == nil {
+		err = scim.ApplyPatch(res, req.Operations)
+	}
+	if err != nil {
+		writeSCIMError(w, err)
+		return
+	}
+	g, err := groupFromSCIM(res, tenantID, cur.ID)
+	if err != nil {
+		writeSCIMError(w, err)
+		return
+	}
+	s.replaceSCIMGroup(w, r, g)
+}
+
+func (s *Server) replaceSCIMGroup(w http.ResponseWriter, r *http.Request, g *auth.SCIMGroup) {
+	updated, err := s.auth.ReplaceSCIMGroup(r.Context(), g)
+	if err != nil {
+		s.scimGroupStoreError(w, "update group", err)
+		return
+	}
+	writeSCIMResource(w, r, http.StatusOK, s.scimGroup(updated))
+}
+
+func (s *Server) handleSCIMDeleteGroup(w http.ResponseWriter, r *http.Request) {
+	tenantID, _ := r.Context().Value(tenantIDKey).(string)
+	if err := s.auth.DeleteSCIMGroup(r.Context(), tenantID, chi.URLParam(r, "id")); err != nil {
+		s.scimGroupStoreError(w, "delete group", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// --- Management ---
+
+type createSCIMTokenRequest struct {
+	Name string `json:"name"`
+}
+
+func (s *Server) handleCreateSCIMToken(w http.ResponseWriter, r *http.Request) {
+	tenantID, _ := r.Context().Value(tenantIDKey).(string)
+
+	var req createSCIMTokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeManagementError(w, ErrTypeInvalidRequest, "invalid_json", "request body must be valid JSON", "")
+		return
+	}
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || len(req.Name) > 100 {
+		writeManagementError(w, ErrTypeInvalidRequest, "invalid_name", "name must be 1-100 characters", "name")
+		return
+	}
+
+	tok, plaintext, err := auth.CreateSCIMToken(r.Context(), s.db, tenantID, req.Name)
+	if err != nil {
+		s.logger.Error("create scim token", zap.Error(err))
+		writeManagementError(w, ErrTypeAPI, "internal_error", "failed to create token", "")
+		return
+	}
+
+	// The token is shown once; only its hash is kept.
+	resp := scimTokenJSON(tok)
+	resp["token"] = plaintext
+	resp["scim_base_url"] = s.scimBaseURL()
+	resp["request_id"] = getRequestID(w)
+	writeJSON(w, http.StatusCreated, resp)
+}
+
+func (s *Server) handleListSCIMTokens(w http.ResponseWriter, r *http.Request) {
+	tenantID, _ := r.Context().Value(tenantIDKey).(string)
+	toks, err := auth.ListSCIMTokens(r.Context(), s.db, tenantID)
+	if err != nil {
+		s.logger.Error("list scim tokens", zap.Error(err))
+		writeManagementError(w, ErrTypeAPI, "internal_error", "failed to list tokens", "")
+		return
+	}
+	var items []interface{}
+	for _, t := range toks {
+		items = append(items, scimTokenJSON(t))
+	}
+	writeListResponse(w, items, false, "", len(items))
+}
+
+func (s *Server) handleDeleteSCIMToken(w http.ResponseWriter, r *http.Request) {
+	tenantID, _ := r.Context().Value(tenantIDKey).(string)
+	err := auth.DeleteSCIMToken(r.Context(), s.db, tenantID, chi.URLParam(r, "id"))
+	if errors.Is(err, auth.ErrSCIMTokenNotFound) {
+		writeManagementError(w, ErrTypeNotFound, "token_not_found", "token not found", "")
+		return
+	}
+	if err != nil {
+		s.logger.Error("delete scim token", zap.Error(err))
+		writeManagementError(w, ErrTypeAPI, "internal_error", "failed to delete token", "")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func scimTokenJSON(t *auth.SCIMToken) map[string]interface{} {
+	resp := map[string]interface{}{
+		"object":       "scim_token",
+		"id":           t.ID,
+		"name":         t.Name,
+		"created_at":   t.CreatedAt.Format(time.RFC3339),
+		"last_used_at": nil,
+	}
+	if t.LastUsedAt != nil {
+		resp["last_used_at"] = t.LastUsedAt.Format(time.RFC3339)
+	}
+	return resp
+}
+
+func (s *Server) handleGetSCIMConfig(w http.ResponseWriter, r *http.Request) {
+	tenantID, _ := r.Context().Value(tenantIDKey).(string)
+	c, err := auth.GetSCIMConfig(r.Context(), s.db, tenantID)
+	if err != nil {
+		s.logger.Error("get scim config", zap.Error(err))
+		writeManagementError(w, ErrTypeAPI, "internal_error", "failed to load SCIM settings", "")
+		return
+	}
+	writeJSON(w, http.StatusOK, scimConfigJSON(c, getRequestID(w)))
+}
+
+type putSCIMConfigRequest struct {
+	GroupRoles  map[string]string `json:"group_roles"`
+	DefaultRole string            `json:"default_role"`
+}
+
+func (s *Server) handlePutSCIMConfig(w http.ResponseWriter, r *http.Request) {
+	tenantID, _ := r.Context().Value(tenantIDKey).(string)
+
+	var req putSCIMConfigRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeManagementError(w, ErrTypeInvalidRequest, "invalid_json", "request body must be valid JSON", "")
+		return
+	}
+	c := &auth.SCIMConfig{GroupRoles: req.GroupRoles, DefaultRole: req.DefaultRole}
+	if err := c.Validate(); err != nil {
+		writeManagementError(w, ErrTypeInvalidRequest, "invalid_config", err.Error(), "")
+		return
+	}
+	if err := auth.PutSCIMConfig(r.Context(), s.db, tenantID, c); err != nil {
+		s.logger.Error("put scim config", zap.Error(err))
+		writeManagementError(w, ErrTypeAPI, "internal_error", "failed to save SCIM settings", "")
+		return
+	}
+	writeJSON(w, http.StatusOK, scimConfigJSON(c, getRequestID(w)))
+}
+
+func scimConfigJSON(c *auth.SCIMConfig, requestID string) map[string]interface{} {
+	resp := map[string]interface{}{
+		"object":       "scim_config",
+		"group_roles":  c.GroupRoles,
+		"default_role": c.DefaultRole,
+		"updated_at":   nil,
+		"request_id":   requestID,
+	}
+	if !c.UpdatedAt.IsZero() {
+		resp["updated_at"] = c.UpdatedAt.Format(time.RFC3339)
+	}
+	return resp
+}
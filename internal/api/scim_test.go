@@ -0,0 +1,151 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+const testSCIMToken = "scim_test-token"
+
+func newSCIMTestServer(t *testing.T) (*Server, sqlmock.Sqlmock) {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+
+	s := &Server{logger: zap.NewNop(), router: chi.NewRouter(), db: db, baseURL: "https://stored.ge"}
+	s.registerSCIMRoutes()
+	return s, mock
+}
+
+func expectSCIMToken(mock sqlmock.Sqlmock) {
+	sum := sha256.Sum256([]byte(testSCIMToken))
+	mock.ExpectQuery(`UPDATE scim_tokens SET last_used_at`).WithArgs(hex.EncodeToString(sum[:])).
+		WillReturnRows(sqlmock.NewRows([]string{"tenant_id"}).AddRow("tenant-acme"))
+}
+
+func scimRequest(method, path, body string) *http.Request {
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	r.Header.Set("Authorization", "bearer "+testSCIMToken)
+	return r
+}
+
+func TestSCIM_RequiresToken(t *testing.T) {
+	s, mock := newSCIMTestServer(t)
+
+	for _, header := range []string{"", "Bearer eyJhbGciOiJIUzI1NiJ9.e30.x", "Basic dXNlcjpwYXNz"} {
+		r := httptest.NewRequest("GET", "/scim/v2/Users", nil)
+		if header != "" {
+			r.Header.Set("Authorization", header)
+		}
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, r)
+		assert.Equal(t, http.StatusUnauthorized, w.Code, header)
+		assert.Contains(t, w.Header().Get("WWW-Authenticate"), "Bearer")
+		assert.Equal(t, "application/scim+json", w.Header().Get("Content-Type"))
+		assert.Contains(t, w.Body.String(), "urn:ietf:params:scim:api:messages:2.0:Error")
+	}
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSCIM_Discovery(t *testing.T) {
+	s, mock := newSCIMTestServer(t)
+
+	expectSCIMToken(mock)
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, scimRequest("GET", "/scim/v2/ServiceProviderConfig", ""))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var cfg map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &cfg))
+	assert.Equal(t, true, cfg["patch"].(map[string]interface{})["supported"])
+	assert.Equal(t, true, cfg["filter"].(map[string]interface{})["supported"])
+	assert.Equal(t, false, cfg["bulk"].(map[string]interface{})["supported"])
+
+	expectSCIMToken(mock)
+	w = httptest.NewRecorder()
+	s.router.ServeHTTP(w, scimRequest("GET", "/scim/v2/Schemas/urn:ietf:params:scim:schemas:core:2.0:Group", ""))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), `"members"`)
+
+	expectSCIMToken(mock)
+	w = httptest.NewRecorder()
+	s.router.ServeHTTP(w, scimRequest("GET", "/scim/v2/ResourceTypes/Device", ""))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSCIM_CreateUserValidation(t *testing.T) {
+	s, mock := newSCIMTestServer(t)
+
+	tests := []struct {
+		name, body, scimType string
+	}{
+		{"not json", `[`, "invalidSyntax"},
+		{"no userName", `{"schemas":["urn:ietf:params:scim:schemas:core:2.0:User"],"emails":[{"value":"a@acme.com"}]}`, "invalidValue"},
+		{"no email", `{"schemas":["urn:ietf:params:scim:schemas:core:2.0:User"],"userName":"jsmith"}`, "invalidValue"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expectSCIMToken(mock)
+			w := httptest.NewRecorder()
+			s.router.ServeHTTP(w, scimRequest("POST", "/scim/v2/Users", tt.body))
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Contains(t, w.Body.String(), `"scimType":"`+tt.scimType+`"`)
+		})
+	}
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSCIM_ListParameters(t *testing.T) {
+	for _, query := range []string{"filter=userName%20sw", "count=ten", "startIndex=x"} {
+		w := httptest.NewRecorder()
+		writeSCIMList(w, httptest.NewRequest("GET", "/scim/v2/Users?"+query, nil), nil)
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+	}
+
+	resources := []interface{}{
+		map[string]interface{}{"id": "1", "userName": "jsmith@acme.com", "active": true},
+		map[string]interface{}{"id": "2", "userName": "bjones@acme.com", "active": false},
+	}
+	w := httptest.NewRecorder()
+	writeSCIMList(w, httptest.NewRequest("GET", `/scim/v2/Users?filter=userName+eq+%22JSmith@acme.com%22&attributes=id`, nil), resources)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.JSONEq(t, `{"schemas":["urn:ietf:params:scim:api:messages:2.0:ListResponse"],
+		"totalResults":1,"startIndex":1,"itemsPerPage":1,"Resources":[{"id":"1"}]}`, w.Body.String())
+}
+
+func TestCreateSCIMToken(t *testing.T) {
+	s, mock := newSSOTestServer(t)
+	s.router.Post("/api/v1/scim/tokens", s.handleCreateSCIMToken)
+
+	mock.ExpectExec(`INSERT INTO scim_tokens`).
+		WithArgs(sqlmock.AnyArg(), "tenant-acme", "Okta", sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, httptest.NewRequest("POST", "/api/v1/scim/tokens", strings.NewReader(`{"name":" Okta "}`)))
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+	var resp map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "scim_token", resp["object"])
+	assert.Equal(t, "https://stored.ge/scim/v2", resp["scim_base_url"])
+	assert.True(t, strings.HasPrefix(resp["token"].(string), "scim_"))
+
+	w = httptest.NewRecorder()
+	s.router.ServeHTTP(w, httptest.NewRequest("POST", "/api/v1/scim/tokens", strings.NewReader(`{"name":""}`)))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
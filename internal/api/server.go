@@ -27,7 +27,10 @@ import (
 	"github.com/FairForge/vaultaire/internal/email"
 	"github.com/FairForge/vaultaire/internal/engine"
 	"github.com/FairForge/vaultaire/internal/flags"
+	"github.com/FairForge/vaultaire/internal/notify"
+	"github.com/FairForge/vaultaire/internal/ratelimit"
 	"github.com/FairForge/vaultaire/internal/rbac"
+	"github.com/FairForge/vaultaire/internal/streaming"
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
 	"go.uber.org/zap"
@@ -49,40 +52,56 @@ var (
 )
 
 type Server struct {
-	config           *config.Config
-	logger           *zap.Logger
-	router           chi.Router
-	httpServer       *http.Server
-	db               *sql.DB
-	events           chan Event
-	engine           *engine.CoreEngine
-	quotaManager     QuotaManager
-	rbacService      *RBACService
-	auth             *auth.AuthService
-	auditLogger      *auth.AuditLogger
-	stripe           *billing.StripeService
-	webhookHandler   *billing.WebhookHandler
-	meteredReporter  *billing.MeteredReporter
-	requestCount     int64
-	testMode         bool
-	errorCount       int64
-	healthChecker    *BackendHealthChecker
-	sessionStore     dashauth.SessionStore
-	bandwidthTracker *BandwidthTracker
-	bandwidthAlerter *BandwidthAlerter
-	googleOAuth      *oauth2.Config
-	githubOAuth      *oauth2.Config
-	mfaService       *auth.MFAService
-	mfaPendingStore  *dashboard.MFAPendingStore
-	sseService       *crypto.SSEService
-	chunkEncSvc      *crypto.ChunkEncryptionService
-	gci              *crypto.GlobalContentIndex
-	cdnRateLimiter   *RateLimiter
-	cdnAnalytics     *CDNAnalyticsTracker
-	accessLogTracker *S3AccessLogTracker
-	inventoryRunner  *InventoryRunner
-	dedupGCRunner    *DedupGCRunner
-	multipartReaper  *MultipartReaper
+	config            *config.Config
+	logger            *zap.Logger
+	router            chi.Router
+	httpServer        *http.Server
+	db                *sql.DB
+	events            chan Event
+	engine            *engine.CoreEngine
+	quotaManager      QuotaManager
+	rbacService       *RBACService
+	auth              *auth.AuthService
+	auditLogger       *auth.AuditLogger
+	stripe            *billing.StripeService
+	webhookHandler    *billing.WebhookHandler
+	meteredReporter   *billing.MeteredReporter
+	requestCount      int64
+	testMode          bool
+	errorCount        int64
+	healthChecker     *BackendHealthChecker
+	sessionStore      dashauth.SessionStore
+	bandwidthTracker  *BandwidthTracker
+	bandwidthAlerter  *BandwidthAlerter
+	googleOAuth       *oauth2.Config
+	githubOAuth       *oauth2.Config
+	directory         *auth.Directory        // LDAP / Active Directory dashboard sign-in; nil when unset
+	samlProvider      *auth.SAMLProvider     // SAML dashboard single sign-on; nil when unset
+	oidcRP            *auth.OIDCRelyingParty // tenants' own OIDC providers; nil without a database
+	mfaService        *auth.MFAService
+	mfaPendingStore   *dashboard.MFAPendingStore
+	sseService        *crypto.SSEService
+	kms               kmsConfig
+	chunkEncSvc       *crypto.ChunkEncryptionService
+	gci               *crypto.GlobalContentIndex
+	cdnRateLimiter    *RateLimiter
+	rateLimits        *ratelimit.Shared
+	rateLimitStore    ratelimit.Store
+	trustedProxies    trustedProxies
+	s3OpLimiter       *ratelimit.OperationLimiter
+	bandwidthLimiter  *ratelimit.BandwidthLimiter
+	notifyStreams     *streaming.StreamManager
+	notifyTargets     *notify.Registry
+	cdnAnalytics      *CDNAnalyticsTracker
+	accessLogTracker  *S3AccessLogTracker
+	inventoryRunner   *InventoryRunner
+	dedupGCRunner     *DedupGCRunner
+	multipartReaper   *MultipartReaper
+	lifecycleRunner   *LifecycleRunner
+	replicationRunner *ReplicationRunner
+	websiteDomains    *websiteDomainCache
+	virtualHosts      *virtualHosts
+	oidcVerifier      *auth.OIDCVerifier
 	// multipartMaxUploadBytes caps a single multipart upload's accumulated
 	// in-flight part bytes (0 = unlimited). Part data lives unbilled on local
 	// disk until complete — without a cap one upload can fill the disk.
@@ -199,7 +218,22 @@ func NewServer(cfg *config.Config, logger *zap.Logger, eng *engine.CoreEngine, q
 		}
 	}
 
+	// Request limits are shared by every replica when a store is configured.
+	s.rateLimits, s.rateLimitStore = newSharedRateLimits(cfg.Server.RateLimitStore, s.db, logger)
 	s.cdnRateLimiter = NewRateLimiter()
+	s.cdnRateLimiter.UseShared(s.rateLimits, "cdn")
+	s.s3OpLimiter = newS3OperationLimiter(cfg.Server.S3OperationLimits, s.rateLimits)
+	s.bandwidthLimiter = newBandwidthLimiter(cfg.Server.BandwidthLimit, s.rateLimits)
+
+	// Queue and stream targets bucket notifications name by ARN. Targets
+	// connect on first use, so an unreachable broker only delays its
+	// deliveries.
+	s.notifyStreams = streaming.NewStreamManager(nil)
+	if targets, err := notify.NewRegistry(cfg.Server.NotificationTargets, s.notifyStreams); err != nil {
+		logger.Error("invalid notification targets — configurations naming an ARN are rejected", zap.Error(err))
+	} else {
+		s.notifyTargets = targets
+	}
 
 	// MFA service for TOTP generation and validation.
 	s.mfaService = auth.NewMFAService("stored.ge")
@@ -224,6 +258,13 @@ func NewServer(cfg *config.Config, logger *zap.Logger, eng *engine.CoreEngine, q
 		}
 	}
 
+	if provider, kmsErr := crypto.KeyProviderFromEnv(os.Getenv); kmsErr != nil {
+		logger.Error("failed to initialize SSE-KMS key provider", zap.Error(kmsErr))
+	} else if provider != nil {
+		s.kms = kmsConfig{provider: provider, defaultKeyID: os.Getenv("VAULTAIRE_KMS_DEFAULT_KEY_ID")}
+		logger.Info("SSE-KMS key provider initialized", zap.String("provider", provider.Name()))
+	}
+
 	if s.db != nil {
 		s.gci = crypto.NewGlobalContentIndex(s.db)
 		logger.Info("global content index initialized (chunking + dedup)")
@@ -257,7 +298,7 @@ func NewServer(cfg *config.Config, logger *zap.Logger, eng *engine.CoreEngine, q
 	s.accessLogTracker.StartFlusher(context.Background(), 5*time.Second)
 	s.accessLogTracker.StartLogDelivery(context.Background(), s.engine)
 
-	// Inventory report runner — generates CSV inventory reports on schedule.
+	// Inventory report runner — generates CSV, ORC and Parquet inventory reports on schedule.
 	s.inventoryRunner = NewInventoryRunner(s.db, s.engine, logger)
 	s.inventoryRunner.StartInventoryJob(context.Background())
 
@@ -312,6 +353,26 @@ func NewServer(cfg *config.Config, logger *zap.Logger, eng *engine.CoreEngine, q
 		s.multipartReaper.Start(context.Background())
 	}
 
+	// Bucket lifecycle executor — expires, transitions and aborts per the
+	// rules stored by PUT ?lifecycle.
+	s.lifecycleRunner = NewLifecycleRunner(s.db, s.engine, s.gci, s.quotaManager, logger)
+	s.lifecycleRunner.Start(context.Background())
+
+	// Bucket replication — drains the jobs queued by writes to buckets
+	// with a PUT ?replication configuration.
+	s.replicationRunner = NewReplicationRunner(s.db, s.engine, s.gci, s.quotaManager, logger)
+	s.websiteDomains = newWebsiteDomainCache(s.db)
+	s.virtualHosts = newVirtualHosts(cfg.Server.VirtualHostDomains)
+	if proxies, err := newTrustedProxies(cfg.Server.TrustedProxies); err != nil {
+		logger.Error("invalid trusted proxies — X-Forwarded-Proto is ignored", zap.Error(err))
+	} else {
+		s.trustedProxies = proxies
+	}
+	if s.replicationRunner != nil && s.gci != nil {
+		s.replicationRunner.openChunked = s.openChunkedObject
+	}
+	s.replicationRunner.Start(context.Background())
+
 	// Stripe billing service. Only active when STRIPE_SECRET_KEY is set.
 	if stripeKey := os.Getenv("STRIPE_SECRET_KEY"); stripeKey != "" {
 		s.stripe = billing.NewStripeService(stripeKey, s.db, logger)
@@ -372,10 +433,42 @@ func NewServer(cfg *config.Config, logger *zap.Logger, eng *engine.CoreEngine, q
 		}
 		logger.Info("github oauth initialized")
 	}
+	if path := os.Getenv("VAULTAIRE_DIRECTORY_CONFIG"); path != "" {
+		dirCfg, err := auth.LoadDirectoryConfig(path)
+		if err == nil {
+			s.directory, err = auth.NewDirectory(dirCfg)
+		}
+		if err != nil {
+			logger.Error("invalid directory config — LDAP/AD sign-in disabled", zap.String("path", path), zap.Error(err))
+		} else {
+			logger.Info("directory sign-in initialized", zap.String("type", dirCfg.Type))
+		}
+	}
+	if path := os.Getenv("VAULTAIRE_SAML_CONFIG"); path != "" {
+		var err error
+		s.samlProvider, err = loadSAMLProvider(path, baseURL)
+		if err != nil {
+			logger.Error("invalid SAML config — SAML sign-in disabled", zap.String("path", path), zap.Error(err))
+		} else {
+			if db != nil {
+				s.samlProvider.SetStateStore(auth.NewSQLSAMLState(db))
+			}
+			logger.Info("saml sign-in initialized", zap.String("idp", s.samlProvider.Config().IDPEntityID))
+		}
+	}
+
+	// Tenants register their OIDC connections through the management API;
+	// every connection shares the one redirect URI.
+	if db != nil {
+		s.oidcVerifier = auth.NewOIDCVerifier(nil)
+		s.oidcRP = auth.NewOIDCRelyingParty(s.oidcVerifier, nil, baseURL+"/oidc/callback")
+	}
 
 	s.rbacService = NewRBACService(logger)
 
 	s.router.Use(s.requestIDMiddleware)
+	s.router.Use(s.forwardedProtoMiddleware)
+	s.router.Use(s.virtualHostMiddleware)
 	s.router.Use(s.requestLimitsMiddleware)
 	s.router.Use(s.versionMiddleware)
 	s.router.Use(s.rbacService.InjectUserContext)
@@ -383,8 +476,11 @@ func NewServer(cfg *config.Config, logger *zap.Logger, eng *engine.CoreEngine, q
 
 	s.setupRoutes()
 
-	// CDN host-based router: cdn.stored.ge → CDN handler, everything else → normal router.
+	// CDN host-based router: cdn.stored.ge → CDN handler, a mapped custom
+	// website hostname → that bucket's website, everything else → normal router.
 	cdnRouter := chi.NewRouter()
+	cdnRouter.Get("/{slug}/{bucket}", s.handleCDNRequest)
+	cdnRouter.Head("/{slug}/{bucket}", s.handleCDNRequest)
 	cdnRouter.Get("/{slug}/{bucket}/*", s.handleCDNRequest)
 	cdnRouter.Head("/{slug}/{bucket}/*", s.handleCDNRequest)
 	cdnRouter.Options("/{slug}/{bucket}/*", s.handleCDNRequest)
@@ -406,7 +502,7 @@ func NewServer(cfg *config.Config, logger *zap.Logger, eng *engine.CoreEngine, q
 	// belong in the handlers via context, not on the whole server.
 	s.httpServer = &http.Server{
 		Addr:              fmt.Sprintf(":%d", cfg.Server.Port),
-		Handler:           CDNHostRouter(cdnRouter, s.router),
+		Handler:           CDNHostRouter(cdnRouter, s.websiteHostRouter(s.router)),
 		ReadHeaderTimeout: 15 * time.Second,
 		IdleTimeout:       120 * time.Second,
 		MaxHeaderBytes:    1 << 20, // 1 MB
@@ -425,6 +521,38 @@ type backendCheck struct {
 	address string // host:port, e.g. "io.quotaless.cloud:8000"
 }
 
+// loadSAMLProvider builds the dashboard's SAML provider from a JSON config.
+// SP endpoints default to the dashboard's /saml routes under baseURL, and
+// IdP settings left empty are read from idp_metadata_url when set.
+func loadSAMLProvider(path, baseURL string) (*auth.SAMLProvider, error) {
+	cfg, err := auth.LoadSAMLConfig(path)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.EntityID == "" {
+		cfg.EntityID = baseURL + "/saml/metadata"
+	}
+	if cfg.ACSURL == "" {
+		cfg.ACSURL = baseURL + "/saml/acs"
+	}
+	if cfg.SLOURL == "" {
+		cfg.SLOURL = baseURL + "/saml/slo"
+	}
+	if cfg.IDPMetadataURL != "" {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		md, err := auth.FetchIDPMetadata(ctx, nil, cfg.IDPMetadataURL)
+		if err != nil {
+			return nil, err
+		}
+		cfg.ApplyIDPMetadata(md)
+	}
+	if cfg.IDPSSOURL == "" || cfg.IDPCertificate == "" {
+		return nil, fmt.Errorf("saml: idp_sso_url and idp_certificate are required without idp_metadata_url")
+	}
+	return auth.NewSAMLProvider(cfg)
+}
+
 // endpointToAddress parses an endpoint URL and returns host:port.
 // If no port is present in the URL, the default for the scheme is used
 // (443 for https, 80 for http).
@@ -552,6 +680,7 @@ func (s *Server) setupRoutes() {
 	s.router.Get("/health/backends", s.handleBackendsHealth)
 	s.router.Get("/ready", s.handleReadiness)
 	s.router.Get("/metrics", s.handleMetrics)
+	s.router.Get("/metrics/replication", s.handleReplicationMetrics)
 	s.router.Get("/version", s.handleVersion)
 
 	s.logger.Info("Registering auth routes")
@@ -587,6 +716,8 @@ func (s *Server) setupRoutes() {
 		Get("/api/v1/usage/alerts", s.handleGetUsageAlerts)
 	s.router.With(s.rbacService.RequirePermission("storage.read")).
 		Get("/api/v1/presigned", s.handleGetPresignedURL)
+	s.router.With(s.rbacService.RequirePermission("storage.write")).
+		Get("/api/v1/presigned/post", s.handleGetPresignedPost)
 
 	s.setupQuotaManagementRoutes()
 	s.setupPatternRoutes()
@@ -614,6 +745,8 @@ func (s *Server) setupRoutes() {
 
 	// CDN path-based routes — before dashboard and S3 catch-all.
 	s.logger.Info("Registering CDN routes")
+	s.router.Get("/cdn/{slug}/{bucket}", s.handleCDNRequest)
+	s.router.Head("/cdn/{slug}/{bucket}", s.handleCDNRequest)
 	s.router.Get("/cdn/{slug}/{bucket}/*", s.handleCDNRequest)
 	s.router.Head("/cdn/{slug}/{bucket}/*", s.handleCDNRequest)
 	s.router.Options("/cdn/{slug}/{bucket}/*", s.handleCDNRequest)
@@ -654,6 +787,9 @@ func (s *Server) setupRoutes() {
 		Engine:        s.engine,
 		HealthChecker: &healthCheckerAdapter{s.healthChecker},
 		Flags:         s.flags,
+		Directory:     s.directory,
+		SAML:          s.samlProvider,
+		OIDC:          s.oidcRP,
 	})
 
 	s.logger.Info("Registering management API routes")
@@ -662,6 +798,12 @@ func (s *Server) setupRoutes() {
 	s.logger.Info("Registering STS routes")
 	s.registerSTSRoutes()
 
+	s.logger.Info("Registering SSO connection routes")
+	s.registerSSORoutes()
+
+	s.logger.Info("Registering SCIM provisioning routes")
+	s.registerSCIMRoutes()
+
 	s.logger.Info("Registering webhook and event routes")
 	s.registerWebhookRoutes()
 
@@ -755,12 +897,16 @@ func (s *Server) registerComplianceRoutes() {
 
 		r.Post("/dedup-gc", s.requireAdmin(s.handleDedupGCTrigger))
 		r.Post("/quota-reconcile", s.requireAdmin(s.handleQuotaReconcile))
+		r.Get("/replication", s.requireAdmin(s.handleAdminReplicationLag))
 
 		// Feature flags (1.13): flip kill-switches / per-tenant enablement
 		// at runtime. updated_by comes from the JWT.
 		r.Get("/flags", s.requireAdmin(s.handleAdminFlagsList))
 		r.Put("/flags/{key}", s.requireAdmin(s.handleAdminFlagSet))
 		r.Delete("/flags/{key}", s.requireAdmin(s.handleAdminFlagUnset))
+		r.Get("/website-domains", s.requireAdmin(s.handleAdminWebsiteDomainsList))
+		r.Put("/website-domains/{hostname}", s.requireAdmin(s.handleAdminWebsiteDomainSet))
+		r.Delete("/website-domains/{hostname}", s.requireAdmin(s.handleAdminWebsiteDomainDelete))
 	})
 }
 
@@ -1045,7 +1191,7 @@ func (s *Server) versionMiddleware(next http.Handler) http.Handler {
 
 // requestLimitsMiddleware caps request body sizes to prevent resource
 // exhaustion. S3 PUT/POST uploads are exempt (bounded by engine + quota).
-// Management API mutations get 10 MB. Everything else gets 64 KB.
+// Management API and SCIM mutations get 10 MB. Everything else gets 64 KB.
 func (s *Server) requestLimitsMiddleware(next http.Handler) http.Handler {
 	const (
 		defaultLimit    int64 = 64 << 10 // 64 KB
@@ -1066,6 +1212,7 @@ func (s *Server) requestLimitsMiddleware(next http.Handler) http.Handler {
 			!strings.HasPrefix(path, "/dashboard") &&
 			!strings.HasPrefix(path, "/admin") &&
 			!strings.HasPrefix(path, "/auth/") &&
+			!strings.HasPrefix(path, "/scim/") &&
 			!strings.HasPrefix(path, "/webhook/")
 
 		if isS3Upload {
@@ -1074,7 +1221,8 @@ func (s *Server) requestLimitsMiddleware(next http.Handler) http.Handler {
 		}
 
 		limit := defaultLimit
-		if strings.HasPrefix(path, "/api/") && (method == "PUT" || method == "POST" || method == "PATCH") {
+		isManagement := strings.HasPrefix(path, "/api/") || strings.HasPrefix(path, "/scim/")
+		if isManagement && (method == "PUT" || method == "POST" || method == "PATCH") {
 			limit = managementLimit
 		}
 
@@ -1132,6 +1280,18 @@ func (s *Server) Start() error {
 		auth.StartSTSCleanup(ctx, s.db, s.logger)
 	}
 
+	// Send queued webhook and bucket notification deliveries, with retries.
+	if s.db != nil {
+		outbox := newDeliveryOutbox(s.db, s.logger)
+		outbox.targets = s.notifyTargets
+		outbox.Start(ctx)
+	}
+
+	// Prune refilled rate limit buckets.
+	if ps, ok := s.rateLimitStore.(*ratelimit.PostgresStore); ok {
+		ps.StartCleanup(ctx, s.logger)
+	}
+
 	// Report metered usage to Stripe daily + check spending caps hourly.
 	if s.meteredReporter != nil {
 		s.meteredReporter.StartMeteredReporting(ctx)
@@ -1164,10 +1324,20 @@ func (s *Server) Shutdown(ctx context.Context) error {
 	if s.accessLogTracker != nil {
 		s.accessLogTracker.Flush()
 	}
+	_ = s.notifyTargets.Close()
+	if s.directory != nil {
+		s.directory.Close()
+	}
 
 	return err
 }
 
+// NotificationStreams holds the in-process streams that stream:// bucket
+// notification targets publish to, for consumers in the same process.
+func (s *Server) NotificationStreams() *streaming.StreamManager {
+	return s.notifyStreams
+}
+
 func (s *Server) GetRouter() chi.Router {
 	return s.router
 }
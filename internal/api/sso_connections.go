@@ -0,0 +1,173 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/FairForge/vaultaire/internal/auth"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// registerSSORoutes registers the management API for a tenant's own
+// OpenID Connect providers, which its members sign in to the dashboard
+// with.
+func (s *Server) registerSSORoutes() {
+	s.router.Route("/api/v1/sso/oidc", func(r chi.Router) {
+		r.Use(s.requireJWT)
+		r.Post("/", s.handleCreateOIDCConnection)
+		r.Get("/", s.handleListOIDCConnections)
+		r.Delete("/{id}", s.handleDeleteOIDCConnection)
+	})
+}
+
+type createOIDCConnectionRequest struct {
+	Name                 string                `json:"name"`
+	Vendor               string                `json:"vendor"`
+	IssuerURL            string                `json:"issuer_url"`
+	ClientID             string                `json:"client_id"`
+	ClientSecret         string                `json:"client_secret"`
+	Scopes               []string              `json:"scopes"`
+	EmailDomains         []string              `json:"email_domains"`
+	Claims               auth.OIDCClaimMapping `json:"claims"`
+	GroupRoles           map[string]string     `json:"group_roles"`
+	DefaultRole          string                `json:"default_role"`
+	LinkExistingAccounts bool                  `json:"link_existing_accounts"`
+}
+
+func (s *Server) handleCreateOIDCConnection(w http.ResponseWriter, r *http.Request) {
+	tenantID, _ := r.Context().Value(tenantIDKey).(string)
+	userID, _ := r.Context().Value(userIDKey).(string)
+	if tenantID == "" || userID == "" {
+		writeManagementError(w, ErrTypeAuthentication, "missing_tenant", "tenant not found in token", "")
+		return
+	}
+
+	var req createOIDCConnectionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeManagementError(w, ErrTypeInvalidRequest, "invalid_json", "request body must be valid JSON", "")
+		return
+	}
+	c := &auth.OIDCConnection{
+		ID:           uuid.New().String(),
+		TenantID:     tenantID,
+		Name:         req.Name,
+		Vendor:       req.Vendor,
+		IssuerURL:    req.IssuerURL,
+		ClientID:     req.ClientID,
+		ClientSecret: req.ClientSecret,
+		Scopes:       req.Scopes,
+		EmailDomains: req.EmailDomains,
+		Claims:       req.Claims,
+		RoleMapping: auth.RoleMapping{
+			GroupRoles:           req.GroupRoles,
+			DefaultRole:          req.DefaultRole,
+			LinkExistingAccounts: req.LinkExistingAccounts,
+		},
+		Enabled:   true,
+		CreatedAt: time.Now().UTC(),
+	}
+	c.ApplyDefaults()
+	if err := c.Validate(); err != nil {
+		writeManagementError(w, ErrTypeInvalidRequest, "invalid_connection", err.Error(), "")
+		return
+	}
+	// Discovering the issuer now catches typos before users hit them.
+	if s.oidcVerifier != nil {
+		if _, err := s.oidcVerifier.Endpoints(r.Context(), c.IssuerURL); err != nil {
+			writeManagementError(w, ErrTypeInvalidRequest, "issuer_unreachable", err.Error(), "issuer_url")
+			return
+		}
+	}
+
+	if err := auth.CreateOIDCConnection(r.Context(), s.db, c, userID); err != nil {
+		switch {
+		case errors.Is(err, auth.ErrOIDCConnectionExists):
+			writeManagementError(w, ErrTypeConflict, "connection_exists", err.Error(), "issuer_url")
+			return
+		case errors.Is(err, auth.ErrOIDCEmailDomainClaimed):
+			writeManagementError(w, ErrTypeConflict, "email_domain_claimed", err.Error(), "email_domains")
+			return
+		case errors.Is(err, auth.ErrOIDCEmailDomainUnverified):
+			writeManagementError(w, ErrTypePermission, "email_domain_unverified", err.Error(), "email_domains")
+			return
+		}
+		s.logger.Error("create oidc connection", zap.Error(err))
+		writeManagementError(w, ErrTypeAPI, "internal_error", "failed to create connection", "")
+		return
+	}
+
+	resp := s.oidcConnectionJSON(c)
+	resp["request_id"] = getRequestID(w)
+	writeJSON(w, http.StatusCreated, resp)
+}
+
+func (s *Server) handleListOIDCConnections(w http.ResponseWriter, r *http.Request) {
+	tenantID, _ := r.Context().Value(tenantIDKey).(string)
+	if tenantID == "" {
+		writeManagementError(w, ErrTypeAuthentication, "missing_tenant", "tenant not found in token", "")
+		return
+	}
+
+	conns, err := auth.ListOIDCConnections(r.Context(), s.db, tenantID)
+	if err != nil {
+		s.logger.Error("list oidc connections", zap.Error(err))
+		writeManagementError(w, ErrTypeAPI, "internal_error", "failed to list connections", "")
+		return
+	}
+	var items []interface{}
+	for _, c := range conns {
+		items = append(items, s.oidcConnectionJSON(c))
+	}
+	writeListResponse(w, items, false, "", len(items))
+}
+
+func (s *Server) handleDeleteOIDCConnection(w http.ResponseWriter, r *http.Request) {
+	tenantID, _ := r.Context().Value(tenantIDKey).(string)
+	if tenantID == "" {
+		writeManagementError(w, ErrTypeAuthentication, "missing_tenant", "tenant not found in token", "")
+		return
+	}
+
+	err := auth.DeleteOIDCConnection(r.Context(), s.db, tenantID, chi.URLParam(r, "id"))
+	if errors.Is(err, auth.ErrOIDCConnectionNotFound) {
+		writeManagementError(w, ErrTypeNotFound, "connection_not_found", "connection not found", "")
+		return
+	}
+	if err != nil {
+		s.logger.Error("delete oidc connection", zap.Error(err))
+		writeManagementError(w, ErrTypeAPI, "internal_error", "failed to delete connection", "")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// oidcConnectionJSON renders a connection without its client secret, with
+// the redirect URI to register at the IdP.
+func (s *Server) oidcConnectionJSON(c *auth.OIDCConnection) map[string]interface{} {
+	redirectURI := s.baseURL + "/oidc/callback"
+	if s.oidcRP != nil {
+		redirectURI = s.oidcRP.RedirectURL()
+	}
+	return map[string]interface{}{
+		"object":                 "oidc_connection",
+		"id":                     c.ID,
+		"name":                   c.Name,
+		"vendor":                 c.Vendor,
+		"issuer_url":             c.IssuerURL,
+		"client_id":              c.ClientID,
+		"has_client_secret":      c.ClientSecret != "",
+		"scopes":                 c.Scopes,
+		"email_domains":          c.EmailDomains,
+		"claims":                 c.Claims,
+		"group_roles":            c.GroupRoles,
+		"default_role":           c.DefaultRole,
+		"link_existing_accounts": c.LinkExistingAccounts,
+		"enabled":                c.Enabled,
+		"redirect_uri":           redirectURI,
+		"created_at":             c.CreatedAt.Format(time.RFC3339),
+	}
+}
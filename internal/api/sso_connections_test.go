@@ -0,0 +1,156 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func newSSOTestServer(t *testing.T) (*Server, sqlmock.Sqlmock) {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+
+	s := &Server{logger: zap.NewNop(), router: chi.NewRouter(), db: db, baseURL: "https://stored.ge"}
+	s.router.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := context.WithValue(r.Context(), tenantIDKey, "tenant-acme")
+			ctx = context.WithValue(ctx, userIDKey, "user-1")
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	})
+	s.router.Route("/api/v1/sso/oidc", func(r chi.Router) {
+		r.Post("/", s.handleCreateOIDCConnection)
+		r.Get("/", s.handleListOIDCConnections)
+		r.Delete("/{id}", s.handleDeleteOIDCConnection)
+	})
+	return s, mock
+}
+
+const testOIDCConnectionBody = `{
+	"name": "Acme Okta", "vendor": "okta", "issuer_url": "https://acme.okta.com",
+	"client_id": "vaultaire", "client_secret": "s3cret", "email_domains": ["acme.com"],
+	"group_roles": {"Storage": "user"}
+}`
+
+// expectDomainOwner expects the lookup of the registering user's address.
+func expectDomainOwner(mock sqlmock.Sqlmock, email string, verified bool) {
+	mock.ExpectQuery(`SELECT email, email_verified FROM users WHERE id = \$1`).WithArgs("user-1").
+		WillReturnRows(sqlmock.NewRows([]string{"email", "email_verified"}).AddRow(email, verified))
+}
+
+func TestCreateOIDCConnection(t *testing.T) {
+	s, mock := newSSOTestServer(t)
+
+	expectDomainOwner(mock, "admin@acme.com", true)
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO oidc_connections`).
+		WithArgs(sqlmock.AnyArg(), "tenant-acme", "Acme Okta", "okta", "https://acme.okta.com", "vaultaire", "s3cret",
+			sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), []byte(`{"Storage":"user"}`), "", false, true, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`INSERT INTO oidc_email_domains`).WithArgs("acme.com", "tenant-acme", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, httptest.NewRequest("POST", "/api/v1/sso/oidc/", strings.NewReader(testOIDCConnectionBody)))
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+	var resp map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "oidc_connection", resp["object"])
+	assert.Equal(t, "https://stored.ge/oidc/callback", resp["redirect_uri"])
+	assert.Equal(t, true, resp["has_client_secret"])
+	assert.NotContains(t, w.Body.String(), "s3cret")
+	assert.Equal(t, []interface{}{"openid", "email", "profile", "groups"}, resp["scopes"])
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCreateOIDCConnection_Errors(t *testing.T) {
+	s, mock := newSSOTestServer(t)
+
+	post := func(body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, httptest.NewRequest("POST", "/api/v1/sso/oidc/", strings.NewReader(body)))
+		return w
+	}
+
+	w := post(strings.Replace(testOIDCConnectionBody, `"Storage": "user"`, `"Storage": "admin"`, 1))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "cannot be granted")
+
+	w = post(strings.Replace(testOIDCConnectionBody, `"email_domains": ["acme.com"],`, "", 1))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "email_domains")
+
+	expectDomainOwner(mock, "admin@acme.com", true)
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO oidc_connections`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+	w = post(testOIDCConnectionBody)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), "connection_exists")
+
+	// A domain another tenant's connection claimed stays with it.
+	expectDomainOwner(mock, "admin@acme.com", true)
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO oidc_connections`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`INSERT INTO oidc_email_domains`).WithArgs("acme.com", "tenant-acme", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+	w = post(testOIDCConnectionBody)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), "email_domain_claimed")
+
+	// Only a verified address in the domain proves the tenant owns it.
+	for _, owner := range []struct {
+		email    string
+		verified bool
+	}{{"admin@acme.com", false}, {"admin@evil.com", true}, {"admin@notacme.com", true}} {
+		expectDomainOwner(mock, owner.email, owner.verified)
+		w = post(testOIDCConnectionBody)
+		assert.Equal(t, http.StatusForbidden, w.Code, owner.email)
+		assert.Contains(t, w.Body.String(), "email_domain_unverified")
+	}
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestListAndDeleteOIDCConnections(t *testing.T) {
+	s, mock := newSSOTestServer(t)
+
+	mock.ExpectQuery(`FROM oidc_connections WHERE tenant_id = \$1`).WithArgs("tenant-acme").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "tenant_id", "name", "vendor", "issuer_url", "client_id", "client_secret",
+			"scopes", "email_domains", "claims", "group_roles", "default_role", "link_existing_accounts", "enabled", "created_at"}).
+			AddRow("conn-1", "tenant-acme", "Acme", "keycloak", "https://sso.acme.com/realms/acme", "vaultaire", "s3cret",
+				"{openid,email}", "{acme.com}", []byte(`{}`), []byte(`{}`), "viewer", false, true, time.Now()))
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/sso/oidc/", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"id":"conn-1"`)
+	assert.Contains(t, w.Body.String(), `"default_role":"viewer"`)
+	assert.NotContains(t, w.Body.String(), "s3cret")
+
+	mock.ExpectExec(`DELETE FROM oidc_connections`).WithArgs("conn-1", "tenant-acme").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	w = httptest.NewRecorder()
+	s.router.ServeHTTP(w, httptest.NewRequest("DELETE", "/api/v1/sso/oidc/conn-1", nil))
+	assert.Equal(t, http.StatusNoContent, w.Code)
+
+	mock.ExpectExec(`DELETE FROM oidc_connections`).WithArgs("conn-2", "tenant-acme").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	w = httptest.NewRecorder()
+	s.router.ServeHTTP(w, httptest.NewRequest("DELETE", "/api/v1/sso/oidc/conn-2", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
@@ -14,7 +14,22 @@ func (s *Server) registerSTSRoutes() {
 	s.router.Route("/api/v1/sts", func(r chi.Router) {
 		r.Use(s.requireJWT)
 		r.Post("/token", s.handleSTSCreateToken)
+
+		r.Post("/providers", s.handleCreateOIDCProvider)
+		r.Get("/providers", s.handleListOIDCProviders)
+		r.Delete("/providers/{id}", s.handleDeleteOIDCProvider)
+		r.Post("/roles", s.handleCreateSTSRole)
+		r.Get("/roles", s.handleListSTSRoles)
+		r.Delete("/roles/{id}", s.handleDeleteSTSRole)
 	})
+
+	// AWS STS query API for SDKs and the CLI. Unauthenticated: the web
+	// identity token is the credential.
+	if s.oidcVerifier == nil {
+		s.oidcVerifier = auth.NewOIDCVerifier(nil)
+	}
+	s.router.Post("/sts", s.handleSTSQuery)
+	s.router.Post("/sts/", s.handleSTSQuery)
 }
 
 func (s *Server) handleSTSCreateToken(w http.ResponseWriter, r *http.Request) {
@@ -39,6 +54,9 @@ func (s *Server) handleSTSCreateToken(w http.ResponseWriter, r *http.Request) {
 	}
 
 	parentKeyID := "tenant:" + tenantID
+	if s.auth.GetUserIDByTenantID(r.Context(), tenantID) != userID {
+		parentKeyID = auth.STSUserParent(userID)
+	}
 	parentScope := &auth.KeyScope{Permissions: []string{"*"}}
 
 	keys, err := s.auth.ListAPIKeys(r.Context(), userID)
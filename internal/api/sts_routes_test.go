@@ -0,0 +1,46 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/FairForge/vaultaire/internal/auth"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// A token signed for a user who has since been deleted (SCIM DELETE drops
+// them from the auth index) must not mint STS credentials.
+func TestSTSCreateToken_RejectsDeletedUser(t *testing.T) {
+	authSvc := auth.NewAuthService(nil, nil)
+	authSvc.SetJWTSecret("test-secret")
+	user, tnt, _, err := authSvc.CreateUserWithTenant(context.Background(), "live@test.com", "pass123", "TestCo")
+	require.NoError(t, err)
+
+	live, err := authSvc.GenerateJWT(user)
+	require.NoError(t, err)
+	deleted, err := authSvc.GenerateJWT(&auth.User{ID: "deleted-member", Email: "gone@test.com", TenantID: tnt.ID})
+	require.NoError(t, err)
+
+	s := &Server{logger: zap.NewNop(), router: chi.NewRouter(), auth: authSvc}
+	s.registerSTSRoutes()
+
+	post := func(token string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("POST", "/api/v1/sts/token", strings.NewReader(`{"ttl":900}`))
+		r.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		s.router.ServeHTTP(w, r)
+		return w
+	}
+
+	_, err = authSvc.ValidateJWT(live)
+	require.NoError(t, err, "a live user's token still validates")
+	w := post(deleted)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.NotContains(t, w.Body.String(), "secret_key")
+}
@@ -0,0 +1,490 @@
+package api
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/FairForge/vaultaire/internal/auth"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+)
+
+// stsMaxRequestBytes caps STS query API form bodies. ID tokens are at most
+// 20000 characters.
+const stsMaxRequestBytes = 64 << 10
+
+// roleSessionNamePattern is the RoleSessionName syntax.
+var roleSessionNamePattern = regexp.MustCompile(`^[\w+=,.@-]{2,64}$`)
+
+// AssumeRoleWithWebIdentityResponse is the STS AssumeRoleWithWebIdentity
+// response.
+type AssumeRoleWithWebIdentityResponse struct {
+	XMLName          xml.Name                        `xml:"https://sts.amazonaws.com/doc/2011-06-15/ AssumeRoleWithWebIdentityResponse"`
+	Result           AssumeRoleWithWebIdentityResult `xml:"AssumeRoleWithWebIdentityResult"`
+	ResponseMetadata STSResponseMetadata             `xml:"ResponseMetadata"`
+}
+
+// AssumeRoleWithWebIdentityResult holds the session's credentials and the
+// identity they were issued to.
+type AssumeRoleWithWebIdentityResult struct {
+	Credentials                 STSCredentials     `xml:"Credentials"`
+	SubjectFromWebIdentityToken string             `xml:"SubjectFromWebIdentityToken"`
+	AssumedRoleUser             STSAssumedRoleUser `xml:"AssumedRoleUser"`
+	Provider                    string             `xml:"Provider"`
+	Audience                    string             `xml:"Audience"`
+}
+
+// STSCredentials are temporary credentials for SigV4 signing.
+type STSCredentials struct {
+	AccessKeyID     string `xml:"AccessKeyId"`
+	SecretAccessKey string `xml:"SecretAccessKey"`
+	SessionToken    string `xml:"SessionToken"`
+	Expiration      string `xml:"Expiration"`
+}
+
+// STSAssumedRoleUser identifies an assumed-role session.
+type STSAssumedRoleUser struct {
+	Arn           string `xml:"Arn"`
+	AssumedRoleID string `xml:"AssumedRoleId"`
+}
+
+// STSResponseMetadata carries the request ID of an STS response.
+type STSResponseMetadata struct {
+	RequestID string `xml:"RequestId"`
+}
+
+type stsErrorResponse struct {
+	XMLName xml.Name `xml:"https://sts.amazonaws.com/doc/2011-06-15/ ErrorResponse"`
+	Error   struct {
+		Type    string `xml:"Type"`
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	} `xml:"Error"`
+	RequestID string `xml:"RequestId"`
+}
+
+// writeSTSError writes an STS query API error: Sender faults for 4xx,
+// Receiver faults otherwise.
+func writeSTSError(w http.ResponseWriter, status int, code, message, requestID string) {
+	var resp stsErrorResponse
+	resp.Error.Type = "Sender"
+	if status >= 500 {
+		resp.Error.Type = "Receiver"
+	}
+	resp.Error.Code = code
+	resp.Error.Message = message
+	resp.RequestID = requestID
+	w.Header().Set("Content-Type", "text/xml")
+	w.Header().Set("x-amzn-RequestId", requestID)
+	w.WriteHeader(status)
+	_, _ = w.Write([]byte(xml.Header))
+	_ = xml.NewEncoder(w).Encode(resp)
+}
+
+// handleSTSQuery serves the AWS STS query API (form-encoded Action
+// requests), so SDKs and the CLI reach it with AWS_ENDPOINT_URL_STS
+// pointed at /sts. Only AssumeRoleWithWebIdentity is supported: it is
+// unauthenticated, the ID token being the credential.
+func (s *Server) handleSTSQuery(w http.ResponseWriter, r *http.Request) {
+	reqID := generateRequestID()
+	r.Body = http.MaxBytesReader(w, r.Body, stsMaxRequestBytes)
+	if err := r.ParseForm(); err != nil {
+		writeSTSError(w, http.StatusBadRequest, "InvalidParameterValue", "The request body could not be parsed.", reqID)
+		return
+	}
+	switch action := r.Form.Get("Action"); action {
+	case "AssumeRoleWithWebIdentity":
+		s.handleAssumeRoleWithWebIdentity(w, r, reqID)
+	case "":
+		writeSTSError(w, http.StatusBadRequest, "MissingAction", "The request must contain the parameter Action.", reqID)
+	default:
+		writeSTSError(w, http.StatusBadRequest, "InvalidAction",
+			"Could not find operation "+action+" for version 2011-06-15.", reqID)
+	}
+}
+
+func (s *Server) handleAssumeRoleWithWebIdentity(w http.ResponseWriter, r *http.Request, reqID string) {
+	req := auth.WebIdentityRequest{
+		RoleARN:          r.Form.Get("RoleArn"),
+		RoleSessionName:  r.Form.Get("RoleSessionName"),
+		WebIdentityToken: r.Form.Get("WebIdentityToken"),
+	}
+	for _, p := range []struct{ name, value string }{
+		{"RoleArn", req.RoleARN},
+		{"RoleSessionName", req.RoleSessionName},
+		{"WebIdentityToken", req.WebIdentityToken},
+	} {
+		if p.value == "" {
+			writeSTSError(w, http.StatusBadRequest, "MissingParameter",
+				"The request must contain the parameter "+p.name+".", reqID)
+			return
+		}
+	}
+	if !roleSessionNamePattern.MatchString(req.RoleSessionName) {
+		writeSTSError(w, http.StatusBadRequest, "ValidationError",
+			"RoleSessionName must be 2-64 letters, digits or +=,.@_-.", reqID)
+		return
+	}
+	if d := r.Form.Get("DurationSeconds"); d != "" {
+		n, err := strconv.Atoi(d)
+		if err != nil {
+			writeSTSError(w, http.StatusBadRequest, "ValidationError", "DurationSeconds must be an integer.", reqID)
+			return
+		}
+		req.DurationSeconds = n
+	}
+	// Session policies would narrow the role; rather than silently grant
+	// the whole role, reject them.
+	if r.Form.Get("Policy") != "" || r.Form.Get("PolicyArns.member.1.arn") != "" {
+		writeSTSError(w, http.StatusBadRequest, "InvalidParameterValue", "Session policies are not supported.", reqID)
+		return
+	}
+
+	sess, err := auth.AssumeRoleWithWebIdentity(r.Context(), s.db, s.oidcVerifier, req)
+	if err != nil {
+		s.logger.Info("assume role with web identity failed",
+			zap.String("role_arn", req.RoleARN), zap.Error(err))
+		switch {
+		case errors.Is(err, auth.ErrInvalidRoleARN):
+			writeSTSError(w, http.StatusBadRequest, "ValidationError",
+				"RoleArn must be arn:aws:iam::<account>:role/<name>.", reqID)
+		case errors.Is(err, auth.ErrInvalidSessionDuration):
+			writeSTSError(w, http.StatusBadRequest, "ValidationError", err.Error(), reqID)
+		case errors.Is(err, auth.ErrWebIdentityDenied):
+			writeSTSError(w, http.StatusForbidden, "AccessDenied",
+				"Not authorized to perform sts:AssumeRoleWithWebIdentity", reqID)
+		case errors.Is(err, auth.ErrIDTokenExpired):
+			writeSTSError(w, http.StatusBadRequest, "ExpiredTokenException", "Token expired.", reqID)
+		case errors.Is(err, auth.ErrInvalidIDToken):
+			writeSTSError(w, http.StatusBadRequest, "InvalidIdentityToken", err.Error(), reqID)
+		case errors.Is(err, auth.ErrIDPUnreachable):
+			writeSTSError(w, http.StatusBadRequest, "IDPCommunicationError", err.Error(), reqID)
+		default:
+			s.logger.Error("assume role with web identity", zap.Error(err))
+			writeSTSError(w, http.StatusInternalServerError, "InternalFailure",
+				"The request processing has failed because of an unknown error.", reqID)
+		}
+		return
+	}
+
+	emitEvent(r.Context(), s.db, s.logger, "sts.role_assumed", sess.Role.TenantID, map[string]interface{}{
+		"access_key":   sess.Token.AccessKey,
+		"role":         sess.Role.Name,
+		"session_name": sess.SessionName,
+		"subject":      sess.Subject,
+		"provider":     sess.Provider,
+	})
+
+	resp := AssumeRoleWithWebIdentityResponse{
+		Result: AssumeRoleWithWebIdentityResult{
+			Credentials: STSCredentials{
+				AccessKeyID:     sess.Token.AccessKey,
+				SecretAccessKey: sess.Token.SecretKey,
+				SessionToken:    sess.Token.SessionToken,
+				Expiration:      sess.Token.ExpiresAt.UTC().Format(time.RFC3339),
+			},
+			SubjectFromWebIdentityToken: sess.Subject,
+			AssumedRoleUser: STSAssumedRoleUser{
+				Arn:           sess.AssumedRoleARN(),
+				AssumedRoleID: sess.Role.ID + ":" + sess.SessionName,
+			},
+			Provider: sess.Provider,
+			Audience: sess.Audience,
+		},
+		ResponseMetadata: STSResponseMetadata{RequestID: reqID},
+	}
+	w.Header().Set("Content-Type", "text/xml")
+	w.Header().Set("x-amzn-RequestId", reqID)
+	_, _ = w.Write([]byte(xml.Header))
+	_ = xml.NewEncoder(w).Encode(resp)
+}
+
+type createOIDCProviderRequest struct {
+	IssuerURL string   `json:"issuer_url"`
+	Audiences []string `json:"audiences"`
+}
+
+func (s *Server) handleCreateOIDCProvider(w http.ResponseWriter, r *http.Request) {
+	tenantID, _ := r.Context().Value(tenantIDKey).(string)
+	if tenantID == "" {
+		writeManagementError(w, ErrTypeAuthentication, "missing_tenant", "tenant not found in token", "")
+		return
+	}
+
+	var req createOIDCProviderRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeManagementError(w, ErrTypeInvalidRequest, "invalid_json", "request body must be valid JSON", "")
+		return
+	}
+	u, err := url.Parse(req.IssuerURL)
+	if err != nil || u.Scheme != "https" || u.Host == "" || u.RawQuery != "" || u.Fragment != "" {
+		writeManagementError(w, ErrTypeInvalidRequest, "invalid_issuer_url",
+			"issuer_url must be an https URL without query or fragment", "issuer_url")
+		return
+	}
+	if len(req.Audiences) == 0 {
+		writeManagementError(w, ErrTypeInvalidRequest, "missing_audiences",
+			"audiences is required and must not be empty", "audiences")
+		return
+	}
+	for _, a := range req.Audiences {
+		if strings.TrimSpace(a) == "" {
+			writeManagementError(w, ErrTypeInvalidRequest, "invalid_audiences",
+				"audiences must not contain empty values", "audiences")
+			return
+		}
+	}
+
+	p := auth.WebIdentityProvider{
+		ID:        uuid.New().String(),
+		TenantID:  tenantID,
+		IssuerURL: req.IssuerURL,
+		Audiences: req.Audiences,
+		CreatedAt: time.Now().UTC(),
+	}
+	result, err := s.db.ExecContext(r.Context(), `
+		INSERT INTO oidc_providers (id, tenant_id, issuer_url, audiences, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (tenant_id, issuer_url) DO NOTHING`,
+		p.ID, p.TenantID, p.IssuerURL, pq.Array(p.Audiences), p.CreatedAt)
+	if err != nil {
+		s.logger.Error("create oidc provider", zap.Error(err))
+		writeManagementError(w, ErrTypeAPI, "internal_error", "failed to create identity provider", "")
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		writeManagementError(w, ErrTypeConflict, "provider_exists",
+			"an identity provider with this issuer_url already exists", "issuer_url")
+		return
+	}
+
+	resp := oidcProviderJSON(p)
+	resp["request_id"] = getRequestID(w)
+	writeJSON(w, http.StatusCreated, resp)
+}
+
+func (s *Server) handleListOIDCProviders(w http.ResponseWriter, r *http.Request) {
+	tenantID, _ := r.Context().Value(tenantIDKey).(string)
+	if tenantID == "" {
+		writeManagementError(w, ErrTypeAuthentication, "missing_tenant", "tenant not found in token", "")
+		return
+	}
+
+	rows, err := s.db.QueryContext(r.Context(), `
+		SELECT id, issuer_url, audiences, created_at
+		FROM oidc_providers WHERE tenant_id = $1
+		ORDER BY created_at, id`, tenantID)
+	if err != nil {
+		s.logger.Error("list oidc providers", zap.Error(err))
+		writeManagementError(w, ErrTypeAPI, "internal_error", "failed to list identity providers", "")
+		return
+	}
+	defer func() { _ = rows.Close() }()
+
+	var items []interface{}
+	for rows.Next() {
+		p := auth.WebIdentityProvider{TenantID: tenantID}
+		if err := rows.Scan(&p.ID, &p.IssuerURL, pq.Array(&p.Audiences), &p.CreatedAt); err != nil {
+			s.logger.Error("scan oidc provider row", zap.Error(err))
+			continue
+		}
+		items = append(items, oidcProviderJSON(p))
+	}
+	writeListResponse(w, items, false, "", len(items))
+}
+
+func (s *Server) handleDeleteOIDCProvider(w http.ResponseWriter, r *http.Request) {
+	tenantID, _ := r.Context().Value(tenantIDKey).(string)
+	if tenantID == "" {
+		writeManagementError(w, ErrTypeAuthentication, "missing_tenant", "tenant not found in token", "")
+		return
+	}
+
+	// Deleting a provider deletes its roles (ON DELETE CASCADE); sessions
+	// already issued run until they expire.
+	result, err := s.db.ExecContext(r.Context(),
+		`DELETE FROM oidc_providers WHERE id = $1 AND tenant_id = $2`,
+		chi.URLParam(r, "id"), tenantID)
+	if err != nil {
+		s.logger.Error("delete oidc provider", zap.Error(err))
+		writeManagementError(w, ErrTypeAPI, "internal_error", "failed to delete identity provider", "")
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		writeManagementError(w, ErrTypeNotFound, "provider_not_found", "identity provider not found", "")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func oidcProviderJSON(p auth.WebIdentityProvider) map[string]interface{} {
+	return map[string]interface{}{
+		"object":     "oidc_provider",
+		"id":         p.ID,
+		"issuer_url": p.IssuerURL,
+		"audiences":  p.Audiences,
+		"created_at": p.CreatedAt.Format(time.RFC3339),
+	}
+}
+
+type createSTSRoleRequest struct {
+	Name               string              `json:"name"`
+	ProviderID         string              `json:"provider_id"`
+	Conditions         map[string][]string `json:"conditions"`
+	Permissions        []string            `json:"permissions"`
+	BucketScope        []string            `json:"bucket_scope"`
+	MaxSessionDuration int                 `json:"max_session_duration"`
+}
+
+func (s *Server) handleCreateSTSRole(w http.ResponseWriter, r *http.Request) {
+	tenantID, _ := r.Context().Value(tenantIDKey).(string)
+	if tenantID == "" {
+		writeManagementError(w, ErrTypeAuthentication, "missing_tenant", "tenant not found in token", "")
+		return
+	}
+
+	var req createSTSRoleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeManagementError(w, ErrTypeInvalidRequest, "invalid_json", "request body must be valid JSON", "")
+		return
+	}
+	role := auth.WebIdentityRole{
+		ID:                 uuid.New().String(),
+		TenantID:           tenantID,
+		Name:               req.Name,
+		ProviderID:         req.ProviderID,
+		Conditions:         req.Conditions,
+		Permissions:        req.Permissions,
+		BucketScope:        req.BucketScope,
+		MaxSessionDuration: req.MaxSessionDuration,
+		CreatedAt:          time.Now().UTC(),
+	}
+	if role.Permissions == nil {
+		role.Permissions = []string{"*"}
+	}
+	if role.MaxSessionDuration == 0 {
+		role.MaxSessionDuration = 3600
+	}
+	if err := role.Validate(); err != nil {
+		writeManagementError(w, ErrTypeInvalidRequest, "invalid_role", err.Error(), "")
+		return
+	}
+
+	var exists bool
+	if err := s.db.QueryRowContext(r.Context(),
+		`SELECT EXISTS (SELECT 1 FROM oidc_providers WHERE id = $1 AND tenant_id = $2)`,
+		role.ProviderID, tenantID).Scan(&exists); err != nil {
+		s.logger.Error("check oidc provider", zap.Error(err))
+		writeManagementError(w, ErrTypeAPI, "internal_error", "failed to create role", "")
+		return
+	}
+	if !exists {
+		writeManagementError(w, ErrTypeInvalidRequest, "provider_not_found",
+			"provider_id does not name one of your identity providers", "provider_id")
+		return
+	}
+
+	conditions, _ := json.Marshal(role.Conditions)
+	permissions, _ := json.Marshal(role.Permissions)
+	result, err := s.db.ExecContext(r.Context(), `
+		INSERT INTO sts_roles (id, tenant_id, name, provider_id, conditions, permissions,
+		                       bucket_scope, max_session_seconds, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		ON CONFLICT (tenant_id, name) DO NOTHING`,
+		role.ID, tenantID, role.Name, role.ProviderID, conditions, permissions,
+		pq.Array(role.BucketScope), role.MaxSessionDuration, role.CreatedAt)
+	if err != nil {
+		s.logger.Error("create sts role", zap.Error(err))
+		writeManagementError(w, ErrTypeAPI, "internal_error", "failed to create role", "")
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		writeManagementError(w, ErrTypeConflict, "role_exists", "a role with this name already exists", "name")
+		return
+	}
+
+	resp := stsRoleJSON(role)
+	resp["request_id"] = getRequestID(w)
+	writeJSON(w, http.StatusCreated, resp)
+}
+
+func (s *Server) handleListSTSRoles(w http.ResponseWriter, r *http.Request) {
+	tenantID, _ := r.Context().Value(tenantIDKey).(string)
+	if tenantID == "" {
+		writeManagementError(w, ErrTypeAuthentication, "missing_tenant", "tenant not found in token", "")
+		return
+	}
+
+	rows, err := s.db.QueryContext(r.Context(), `
+		SELECT id, name, provider_id, conditions, permissions, bucket_scope, max_session_seconds, created_at
+		FROM sts_roles WHERE tenant_id = $1
+		ORDER BY name`, tenantID)
+	if err != nil {
+		s.logger.Error("list sts roles", zap.Error(err))
+		writeManagementError(w, ErrTypeAPI, "internal_error", "failed to list roles", "")
+		return
+	}
+	defer func() { _ = rows.Close() }()
+
+	var items []interface{}
+	for rows.Next() {
+		role := auth.WebIdentityRole{TenantID: tenantID}
+		var conditions, permissions []byte
+		if err := rows.Scan(&role.ID, &role.Name, &role.ProviderID, &conditions, &permissions,
+			pq.Array(&role.BucketScope), &role.MaxSessionDuration, &role.CreatedAt); err != nil {
+			s.logger.Error("scan sts role row", zap.Error(err))
+			continue
+		}
+		_ = json.Unmarshal(conditions, &role.Conditions)
+		_ = json.Unmarshal(permissions, &role.Permissions)
+		items = append(items, stsRoleJSON(role))
+	}
+	writeListResponse(w, items, false, "", len(items))
+}
+
+func (s *Server) handleDeleteSTSRole(w http.ResponseWriter, r *http.Request) {
+	tenantID, _ := r.Context().Value(tenantIDKey).(string)
+	if tenantID == "" {
+		writeManagementError(w, ErrTypeAuthentication, "missing_tenant", "tenant not found in token", "")
+		return
+	}
+
+	// Sessions already issued for the role run until they expire.
+	result, err := s.db.ExecContext(r.Context(),
+		`DELETE FROM sts_roles WHERE id = $1 AND tenant_id = $2`,
+		chi.URLParam(r, "id"), tenantID)
+	if err != nil {
+		s.logger.Error("delete sts role", zap.Error(err))
+		writeManagementError(w, ErrTypeAPI, "internal_error", "failed to delete role", "")
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		writeManagementError(w, ErrTypeNotFound, "role_not_found", "role not found", "")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func stsRoleJSON(role auth.WebIdentityRole) map[string]interface{} {
+	return map[string]interface{}{
+		"object":               "sts_role",
+		"id":                   role.ID,
+		"arn":                  role.ARN(),
+		"name":                 role.Name,
+		"provider_id":          role.ProviderID,
+		"conditions":           role.Conditions,
+		"permissions":          role.Permissions,
+		"bucket_scope":         role.BucketScope,
+		"max_session_duration": role.MaxSessionDuration,
+		"created_at":           role.CreatedAt.Format(time.RFC3339),
+	}
+}
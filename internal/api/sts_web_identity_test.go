@@ -0,0 +1,201 @@
+package api
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/FairForge/vaultaire/internal/auth"
+	"github.com/go-chi/chi/v5"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// newTestOIDCIssuer serves an OpenID discovery document and key set, and
+// returns a function signing ID tokens with its key and a client trusting
+// its certificate.
+func newTestOIDCIssuer(t *testing.T) (string, func(claims jwt.MapClaims) string, *http.Client) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	var srv *httptest.Server
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]string{"issuer": srv.URL, "jwks_uri": srv.URL + "/jwks"})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{{
+			"kty": "RSA", "kid": "k1", "use": "sig", "alg": "RS256",
+			"n": base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e": base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	srv = httptest.NewTLSServer(mux)
+	t.Cleanup(srv.Close)
+
+	return srv.URL, func(claims jwt.MapClaims) string {
+		base := jwt.MapClaims{
+			"iss": srv.URL,
+			"aud": "sts.vaultaire",
+			"sub": "repo:acme/app:ref:refs/heads/main",
+			"exp": time.Now().Add(5 * time.Minute).Unix(),
+		}
+		for k, v := range claims {
+			base[k] = v
+		}
+		tok := jwt.NewWithClaims(jwt.SigningMethodRS256, base)
+		tok.Header["kid"] = "k1"
+		raw, err := tok.SignedString(key)
+		require.NoError(t, err)
+		return raw
+	}, srv.Client()
+}
+
+func newSTSQueryTestServer(t *testing.T, client *http.Client) (*Server, sqlmock.Sqlmock) {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+	s := &Server{
+		logger:       zap.NewNop(),
+		router:       chi.NewRouter(),
+		db:           db,
+		oidcVerifier: auth.NewOIDCVerifier(client),
+	}
+	s.router.Post("/sts", s.handleSTSQuery)
+	return s, mock
+}
+
+func postSTS(s *Server, form url.Values) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/sts", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	s.router.ServeHTTP(rec, req)
+	return rec
+}
+
+func expectSTSRole(mock sqlmock.Sqlmock, issuer string) {
+	mock.ExpectQuery(`FROM sts_roles r`).
+		WithArgs("tenant-1", "ci-deploy").
+		WillReturnRows(sqlmock.NewRows([]string{
+			"id", "tenant_id", "name", "provider_id", "conditions", "permissions",
+			"bucket_scope", "max_session_seconds", "created_at",
+			"id", "tenant_id", "issuer_url", "audiences", "created_at",
+		}).AddRow(
+			"role_1", "tenant-1", "ci-deploy", "oidc_1",
+			[]byte(`{"sub":["repo:acme/app:*"]}`), []byte(`["PutObject"]`),
+			pq.StringArray{"artifacts"}, 3600, time.Now(),
+			"oidc_1", "tenant-1", issuer, pq.StringArray{"sts.vaultaire"}, time.Now(),
+		))
+}
+
+func TestSTSQuery_AssumeRoleWithWebIdentity(t *testing.T) {
+	issuer, sign, client := newTestOIDCIssuer(t)
+	s, mock := newSTSQueryTestServer(t, client)
+
+	expectSTSRole(mock, issuer)
+	mock.ExpectExec(`INSERT INTO sts_tokens`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`INSERT INTO events`).WillReturnError(errors.New("skip webhooks"))
+
+	rec := postSTS(s, url.Values{
+		"Action":           {"AssumeRoleWithWebIdentity"},
+		"Version":          {"2011-06-15"},
+		"RoleArn":          {"arn:aws:iam::tenant-1:role/ci-deploy"},
+		"RoleSessionName":  {"run-42"},
+		"WebIdentityToken": {sign(nil)},
+		"DurationSeconds":  {"900"},
+	})
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+	var resp AssumeRoleWithWebIdentityResponse
+	require.NoError(t, xml.Unmarshal(rec.Body.Bytes(), &resp))
+	creds := resp.Result.Credentials
+	assert.True(t, strings.HasPrefix(creds.AccessKeyID, "ASIA"))
+	assert.NotEmpty(t, creds.SecretAccessKey)
+	assert.NotEmpty(t, creds.SessionToken)
+	exp, err := time.Parse(time.RFC3339, creds.Expiration)
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(15*time.Minute), exp, 5*time.Second)
+	assert.Equal(t, "repo:acme/app:ref:refs/heads/main", resp.Result.SubjectFromWebIdentityToken)
+	assert.Equal(t, "arn:aws:sts::tenant-1:assumed-role/ci-deploy/run-42", resp.Result.AssumedRoleUser.Arn)
+	assert.Equal(t, "sts.vaultaire", resp.Result.Audience)
+	assert.NotEmpty(t, resp.ResponseMetadata.RequestID)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSTSQuery_Errors(t *testing.T) {
+	issuer, sign, client := newTestOIDCIssuer(t)
+	valid := func() url.Values {
+		return url.Values{
+			"Action":           {"AssumeRoleWithWebIdentity"},
+			"RoleArn":          {"arn:aws:iam::tenant-1:role/ci-deploy"},
+			"RoleSessionName":  {"run-42"},
+			"WebIdentityToken": {sign(nil)},
+		}
+	}
+
+	tests := []struct {
+		name       string
+		mutate     func(f url.Values)
+		lookupRole bool
+		wantStatus int
+		wantCode   string
+	}{
+		{"missing action", func(f url.Values) { f.Del("Action") }, false, http.StatusBadRequest, "MissingAction"},
+		{"unknown action", func(f url.Values) { f.Set("Action", "AssumeRole") }, false, http.StatusBadRequest, "InvalidAction"},
+		{"missing token", func(f url.Values) { f.Del("WebIdentityToken") }, false, http.StatusBadRequest, "MissingParameter"},
+		{"bad session name", func(f url.Values) { f.Set("RoleSessionName", "a b") }, false, http.StatusBadRequest, "ValidationError"},
+		{"bad role ARN", func(f url.Values) { f.Set("RoleArn", "ci-deploy") }, false, http.StatusBadRequest, "ValidationError"},
+		{"session policy", func(f url.Values) { f.Set("Policy", "{}") }, false, http.StatusBadRequest, "InvalidParameterValue"},
+		{"duration over role max", func(f url.Values) { f.Set("DurationSeconds", "7200") }, true, http.StatusBadRequest, "ValidationError"},
+		{"garbage token", func(f url.Values) { f.Set("WebIdentityToken", "x.y.z") }, true, http.StatusBadRequest, "InvalidIdentityToken"},
+		{"expired token", func(f url.Values) {
+			f.Set("WebIdentityToken", sign(jwt.MapClaims{"exp": time.Now().Add(-time.Hour).Unix()}))
+		}, true, http.StatusBadRequest, "ExpiredTokenException"},
+		{"subject not trusted", func(f url.Values) {
+			f.Set("WebIdentityToken", sign(jwt.MapClaims{"sub": "repo:evil/app:ref:refs/heads/main"}))
+		}, true, http.StatusForbidden, "AccessDenied"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, mock := newSTSQueryTestServer(t, client)
+			if tt.lookupRole {
+				expectSTSRole(mock, issuer)
+			}
+			form := valid()
+			tt.mutate(form)
+			rec := postSTS(s, form)
+			assert.Equal(t, tt.wantStatus, rec.Code)
+
+			var resp stsErrorResponse
+			require.NoError(t, xml.Unmarshal(rec.Body.Bytes(), &resp), rec.Body.String())
+			assert.Equal(t, tt.wantCode, resp.Error.Code)
+			assert.Equal(t, "Sender", resp.Error.Type)
+			assert.Equal(t, resp.RequestID, rec.Header().Get("x-amzn-RequestId"))
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+
+	t.Run("unknown role", func(t *testing.T) {
+		s, mock := newSTSQueryTestServer(t, client)
+		mock.ExpectQuery(`FROM sts_roles r`).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+		rec := postSTS(s, valid())
+		assert.Equal(t, http.StatusForbidden, rec.Code)
+		assert.Contains(t, rec.Body.String(), "<Code>AccessDenied</Code>")
+	})
+}
@@ -0,0 +1,85 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// secureTransportKey marks a request that reached a trusted proxy over TLS.
+const secureTransportKey contextKey = "secure_transport"
+
+// trustedProxies are the reverse proxies whose X-Forwarded-Proto is
+// believed. Any client can send the header itself, so from anywhere else
+// only the request's own connection counts.
+type trustedProxies []*net.IPNet
+
+// newTrustedProxies parses CIDRs and bare IP addresses.
+func newTrustedProxies(entries []string) (trustedProxies, error) {
+	var tp trustedProxies
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, fmt.Errorf("trusted proxy %q is not an IP address or CIDR", entry)
+			}
+			bits := 128
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 32
+			}
+			tp = append(tp, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, ipNet, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("trusted proxy %q is not an IP address or CIDR", entry)
+		}
+		tp = append(tp, ipNet)
+	}
+	return tp, nil
+}
+
+// contains reports whether remoteAddr (host:port) is a trusted proxy.
+func (tp trustedProxies) contains(remoteAddr string) bool {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	for _, ipNet := range tp {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// forwardedProtoMiddleware records X-Forwarded-Proto: https for requests
+// from a trusted proxy, for isSecureTransport.
+func (s *Server) forwardedProtoMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.EqualFold(r.Header.Get("X-Forwarded-Proto"), "https") && s.trustedProxies.contains(r.RemoteAddr) {
+			r = r.WithContext(context.WithValue(r.Context(), secureTransportKey, true))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// isSecureTransport reports whether the client connected over TLS, to this
+// server or to a trusted proxy in front of it.
+func isSecureTransport(r *http.Request) bool {
+	if r.TLS != nil {
+		return true
+	}
+	secure, _ := r.Context().Value(secureTransportKey).(bool)
+	return secure
+}
@@ -0,0 +1,62 @@
+package api
+
+import (
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/FairForge/vaultaire/internal/auth"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// tlsOnlyPolicy denies every request that did not arrive over TLS.
+const tlsOnlyPolicy = `{
+  "Version": "2012-10-17",
+  "Statement": [
+    {"Effect": "Allow", "Principal": "*", "Action": "s3:GetObject", "Resource": "arn:aws:s3:::site/*"},
+    {"Effect": "Deny", "Principal": "*", "Action": "s3:*", "Resource": "arn:aws:s3:::site/*",
+     "Condition": {"Bool": {"aws:SecureTransport": false}}}
+  ]
+}`
+
+func TestNewTrustedProxies(t *testing.T) {
+	tp, err := newTrustedProxies([]string{"127.0.0.1", " 10.0.0.0/8 ", "::1", ""})
+	require.NoError(t, err)
+	assert.True(t, tp.contains("127.0.0.1:4312"))
+	assert.True(t, tp.contains("10.2.3.4:80"))
+	assert.True(t, tp.contains("[::1]:443"))
+	assert.False(t, tp.contains("192.0.2.1:80"))
+	assert.False(t, tp.contains("not-an-address"))
+
+	_, err = newTrustedProxies([]string{"proxy.internal"})
+	assert.Error(t, err)
+}
+
+func TestSecureTransport_ForwardedProtoOnlyFromTrustedProxy(t *testing.T) {
+	proxies, err := newTrustedProxies([]string{"10.0.0.0/8"})
+	require.NoError(t, err)
+	srv := &Server{trustedProxies: proxies}
+	policy := sql.NullString{String: tlsOnlyPolicy, Valid: true}
+
+	decide := func(remoteAddr string) auth.PolicyDecision {
+		r := httptest.NewRequest("GET", "/site/index.html", nil)
+		r.RemoteAddr = remoteAddr
+		r.Header.Set("X-Forwarded-Proto", "https")
+		var decision auth.PolicyDecision
+		srv.forwardedProtoMiddleware(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+			decision = cdnPolicyDecision(r, policy, "site", "index.html")
+		})).ServeHTTP(httptest.NewRecorder(), r)
+		return decision
+	}
+
+	// A client sending the header itself is still on plaintext.
+	assert.Equal(t, auth.PolicyDeny, decide("192.0.2.10:51234"))
+	// The proxy terminating TLS in front of the server is believed.
+	assert.Equal(t, auth.PolicyAllow, decide("10.1.2.3:51234"))
+
+	// Without any trusted proxy the header is never believed.
+	srv.trustedProxies = nil
+	assert.Equal(t, auth.PolicyDeny, decide("10.1.2.3:51234"))
+}
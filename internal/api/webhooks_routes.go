@@ -3,6 +3,7 @@ package api
 import (
 	"database/sql"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/url"
 	"strconv"
@@ -16,6 +17,7 @@ import (
 
 func (s *Server) registerWebhookRoutes() {
 	rl := NewManagementRateLimiter()
+	rl.UseShared(s.rateLimits, "webhooks")
 	im := newIdempotencyMiddleware(s.db, s.logger)
 
 	s.router.Route("/api/v1/webhooks", func(r chi.Router) {
@@ -28,6 +30,8 @@ func (s *Server) registerWebhookRoutes() {
 		r.Patch("/{id}", s.handleUpdateWebhook)
 		r.Delete("/{id}", s.handleDeleteWebhook)
 		r.Get("/{id}/deliveries", s.handleListDeliveries)
+		r.Post("/{id}/deliveries/{delivery_id}/redeliver", s.handleRedeliverDelivery)
+		r.Post("/{id}/replay", s.handleReplayWebhook)
 		r.Post("/{id}/test", s.handleTestWebhook)
 	})
 
@@ -266,7 +270,7 @@ func (s *Server) handleUpdateWebhook(w http.ResponseWriter, r *http.Request) {
 	now := time.Now().UTC()
 	_, err = s.db.ExecContext(r.Context(), `
 		UPDATE webhook_endpoints
-		SET url = $1, event_filter = $2, enabled = $3, updated_at = $4
+		SET url = $1, event_filter = $2, enabled = $3, updated_at = $4, consecutive_failures = 0
 		WHERE id = $5 AND tenant_id = $6`,
 		currentURL, pq.Array(currentFilter), currentEnabled, now, webhookID, tenantID)
 	if err != nil {
@@ -352,15 +356,15 @@ func (s *Server) handleListDeliveries(w http.ResponseWriter, r *http.Request) {
 	var dbErr error
 	if cursor != "" {
 		rows, dbErr = s.db.QueryContext(r.Context(), `
-			SELECT id, event_id, status, response_code, latency_ms, retry_count, created_at
-			FROM webhook_deliveries
+			SELECT id, event_id, status, response_code, latency_ms, attempts, next_attempt_at, last_error, created_at
+			FROM delivery_outbox
 			WHERE webhook_id = $1 AND created_at < $2
 			ORDER BY created_at DESC LIMIT $3`,
 			webhookID, cursor, limit+1)
 	} else {
 		rows, dbErr = s.db.QueryContext(r.Context(), `
-			SELECT id, event_id, status, response_code, latency_ms, retry_count, created_at
-			FROM webhook_deliveries
+			SELECT id, event_id, status, response_code, latency_ms, attempts, next_attempt_at, last_error, created_at
+			FROM delivery_outbox
 			WHERE webhook_id = $1
 			ORDER BY created_at DESC LIMIT $2`,
 			webhookID, limit+1)
@@ -374,23 +378,35 @@ func (s *Server) handleListDeliveries(w http.ResponseWriter, r *http.Request) {
 
 	var items []interface{}
 	for rows.Next() {
-		var id, eventID, status string
-		var responseCode, latencyMs, retryCount int
-		var createdAt time.Time
-		if err := rows.Scan(&id, &eventID, &status, &responseCode, &latencyMs, &retryCount, &createdAt); err != nil {
+		var id, eventID, status, lastError string
+		var responseCode, latencyMs, attempts int
+		var nextAttemptAt, createdAt time.Time
+		if err := rows.Scan(&id, &eventID, &status, &responseCode, &latencyMs, &attempts, &nextAttemptAt, &lastError, &createdAt); err != nil {
 			s.logger.Error("scan delivery row", zap.Error(err))
 			continue
 		}
-		items = append(items, map[string]interface{}{
+		retryCount := 0
+		if attempts > 1 {
+			retryCount = attempts - 1
+		}
+		item := map[string]interface{}{
 			"object":        "webhook_delivery",
 			"id":            id,
 			"event_id":      eventID,
 			"status":        status,
 			"response_code": responseCode,
 			"latency_ms":    latencyMs,
+			"attempts":      attempts,
 			"retry_count":   retryCount,
 			"created_at":    createdAt.Format(time.RFC3339),
-		})
+		}
+		if lastError != "" {
+			item["last_error"] = lastError
+		}
+		if status == deliveryPending || status == deliveryFailed {
+			item["next_attempt_at"] = nextAttemptAt.Format(time.RFC3339)
+		}
+		items = append(items, item)
 	}
 
 	hasMore := len(items) > limit
@@ -403,12 +419,215 @@ func (s *Server) handleListDeliveries(w http.ResponseWriter, r *http.Request) {
 
 	var total int
 	_ = s.db.QueryRowContext(r.Context(),
-		`SELECT COUNT(*) FROM webhook_deliveries WHERE webhook_id = $1`,
+		`SELECT COUNT(*) FROM delivery_outbox WHERE webhook_id = $1`,
 		webhookID).Scan(&total)
 
 	writeListResponse(w, items, hasMore, nextCursor, total)
 }
 
+// handleRedeliverDelivery queues a fresh copy of one delivery, whatever its
+// status, e.g. to send a dead-lettered delivery again after fixing the
+// receiver.
+func (s *Server) handleRedeliverDelivery(w http.ResponseWriter, r *http.Request) {
+	tenantID, _ := r.Context().Value(tenantIDKey).(string)
+	if tenantID == "" {
+		writeManagementError(w, ErrTypeAuthentication, "missing_tenant", "tenant not found in token", "")
+		return
+	}
+
+	webhookID := chi.URLParam(r, "id")
+	deliveryID := chi.URLParam(r, "delivery_id")
+
+	var enabled bool
+	err := s.db.QueryRowContext(r.Context(), `
+		SELECT enabled FROM webhook_endpoints WHERE id = $1 AND tenant_id = $2`,
+		webhookID, tenantID).Scan(&enabled)
+	if err == sql.ErrNoRows {
+		writeManagementError(w, ErrTypeNotFound, "webhook_not_found", "webhook not found", "")
+		return
+	}
+	if err != nil {
+		s.logger.Error("get webhook for redelivery", zap.Error(err))
+		writeManagementError(w, ErrTypeAPI, "internal_error", "failed to get webhook", "")
+		return
+	}
+	if !enabled {
+		writeManagementError(w, ErrTypeInvalidRequest, "webhook_disabled", "webhook is disabled; enable it before redelivering", "")
+		return
+	}
+
+	id := uuid.New().String()
+	result, err := s.db.ExecContext(r.Context(), `
+		INSERT INTO delivery_outbox (id, kind, tenant_id, endpoint_key, webhook_id, url, event_id, event_type, payload, redelivery_of)
+		SELECT $1, kind, tenant_id, endpoint_key, webhook_id, url, event_id, event_type, payload, id
+		FROM delivery_outbox
+		WHERE id = $2 AND webhook_id = $3 AND tenant_id = $4`,
+		id, deliveryID, webhookID, tenantID)
+	if err != nil {
+		s.logger.Error("redeliver webhook delivery", zap.Error(err))
+		writeManagementError(w, ErrTypeAPI, "internal_error", "failed to redeliver", "")
+		return
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		writeManagementError(w, ErrTypeNotFound, "delivery_not_found", "delivery not found", "")
+		return
+	}
+
+	resp := map[string]interface{}{
+		"object":        "webhook_delivery",
+		"id":            id,
+		"redelivery_of": deliveryID,
+		"status":        deliveryPending,
+		"request_id":    getRequestID(w),
+	}
+	writeJSON(w, http.StatusAccepted, resp)
+}
+
+type replayWebhookRequest struct {
+	From string `json:"from"`
+	To   string `json:"to,omitempty"`
+}
+
+// maxReplayEvents bounds the events one replay request may queue.
+const maxReplayEvents = 1000
+
+// handleReplayWebhook queues every event in [from, to) that matches the
+// webhook's filter, including events emitted while it was disabled.
+func (s *Server) handleReplayWebhook(w http.ResponseWriter, r *http.Request) {
+	tenantID, _ := r.Context().Value(tenantIDKey).(string)
+	if tenantID == "" {
+		writeManagementError(w, ErrTypeAuthentication, "missing_tenant", "tenant not found in token", "")
+		return
+	}
+
+	webhookID := chi.URLParam(r, "id")
+
+	var req replayWebhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeManagementError(w, ErrTypeInvalidRequest, "invalid_json", "request body must be valid JSON", "")
+		return
+	}
+	from, err := time.Parse(time.RFC3339, req.From)
+	if err != nil {
+		writeManagementError(w, ErrTypeInvalidRequest, "invalid_from", "from must be an RFC 3339 timestamp", "from")
+		return
+	}
+	to := time.Now().UTC()
+	if req.To != "" {
+		if to, err = time.Parse(time.RFC3339, req.To); err != nil {
+			writeManagementError(w, ErrTypeInvalidRequest, "invalid_to", "to must be an RFC 3339 timestamp", "to")
+			return
+		}
+	}
+	if !from.Before(to) {
+		writeManagementError(w, ErrTypeInvalidRequest, "invalid_window", "from must be before to", "from")
+		return
+	}
+
+	var whURL string
+	var filter []string
+	var enabled bool
+	err = s.db.QueryRowContext(r.Context(), `
+		SELECT url, event_filter, enabled
+		FROM webhook_endpoints
+		WHERE id = $1 AND tenant_id = $2`,
+		webhookID, tenantID).Scan(&whURL, pq.Array(&filter), &enabled)
+	if err == sql.ErrNoRows {
+		writeManagementError(w, ErrTypeNotFound, "webhook_not_found", "webhook not found", "")
+		return
+	}
+	if err != nil {
+		s.logger.Error("get webhook for replay", zap.Error(err))
+		writeManagementError(w, ErrTypeAPI, "internal_error", "failed to get webhook", "")
+		return
+	}
+	if !enabled {
+		writeManagementError(w, ErrTypeInvalidRequest, "webhook_disabled", "webhook is disabled; enable it before replaying", "")
+		return
+	}
+
+	rows, err := s.db.QueryContext(r.Context(), `
+		SELECT id, type, data, created_at FROM events
+		WHERE tenant_id = $1 AND created_at >= $2 AND created_at < $3
+		ORDER BY created_at ASC LIMIT $4`,
+		tenantID, from, to, maxReplayEvents+1)
+	if err != nil {
+		s.logger.Error("list events for replay", zap.Error(err))
+		writeManagementError(w, ErrTypeAPI, "internal_error", "failed to list events", "")
+		return
+	}
+	defer func() { _ = rows.Close() }()
+
+	type event struct {
+		id, eventType string
+		data          []byte
+		createdAt     time.Time
+	}
+	var events []event
+	for rows.Next() {
+		var e event
+		if err := rows.Scan(&e.id, &e.eventType, &e.data, &e.createdAt); err != nil {
+			s.logger.Error("scan event row", zap.Error(err))
+			continue
+		}
+		events = append(events, e)
+	}
+	_ = rows.Close()
+	if len(events) > maxReplayEvents {
+		writeManagementError(w, ErrTypeInvalidRequest, "replay_window_too_large",
+			fmt.Sprintf("the window holds more than %d events; narrow from and to", maxReplayEvents), "from")
+		return
+	}
+
+	tx, err := s.db.BeginTx(r.Context(), nil)
+	if err != nil {
+		s.logger.Error("begin replay", zap.Error(err))
+		writeManagementError(w, ErrTypeAPI, "internal_error", "failed to replay events", "")
+		return
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	queued := 0
+	for _, e := range events {
+		if !matchesWebhookFilter(filter, e.eventType) {
+			continue
+		}
+		body, err := webhookPayload(e.id, e.eventType, tenantID, e.data, e.createdAt)
+		if err == nil {
+			_, err = enqueueDelivery(r.Context(), tx, outboxEntry{
+				kind:      deliveryKindWebhook,
+				tenantID:  tenantID,
+				webhookID: webhookID,
+				url:       whURL,
+				eventID:   e.id,
+				eventType: e.eventType,
+				payload:   body,
+			})
+		}
+		if err != nil {
+			s.logger.Error("enqueue replayed event", zap.Error(err), zap.String("event_id", e.id))
+			writeManagementError(w, ErrTypeAPI, "internal_error", "failed to replay events", "")
+			return
+		}
+		queued++
+	}
+	if err := tx.Commit(); err != nil {
+		s.logger.Error("commit replay", zap.Error(err))
+		writeManagementError(w, ErrTypeAPI, "internal_error", "failed to replay events", "")
+		return
+	}
+
+	resp := map[string]interface{}{
+		"object":     "webhook_replay",
+		"webhook_id": webhookID,
+		"from":       from.UTC().Format(time.RFC3339),
+		"to":         to.UTC().Format(time.RFC3339),
+		"queued":     queued,
+		"request_id": getRequestID(w),
+	}
+	writeJSON(w, http.StatusAccepted, resp)
+}
+
 func (s *Server) handleTestWebhook(w http.ResponseWriter, r *http.Request) {
 	tenantID, _ := r.Context().Value(tenantIDKey).(string)
 	if tenantID == "" {
@@ -0,0 +1,223 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// ACL permissions.
+const (
+	ACLRead        = "READ"
+	ACLWrite       = "WRITE"
+	ACLReadACP     = "READ_ACP"
+	ACLWriteACP    = "WRITE_ACP"
+	ACLFullControl = "FULL_CONTROL"
+)
+
+// Predefined grantee groups. AllUsers includes anonymous requests (the CDN);
+// AuthenticatedUsers is every tenant holding a valid key.
+const (
+	GroupAllUsers           = "http://acs.amazonaws.com/groups/global/AllUsers"
+	GroupAuthenticatedUsers = "http://acs.amazonaws.com/groups/global/AuthenticatedUsers"
+)
+
+// Canned ACLs accepted in x-amz-acl.
+const (
+	CannedPrivate                = "private"
+	CannedPublicRead             = "public-read"
+	CannedAuthenticatedRead      = "authenticated-read"
+	CannedBucketOwnerFullControl = "bucket-owner-full-control"
+)
+
+// Object ownership settings (PutBucketOwnershipControls). A bucket with
+// none behaves as ObjectWriter.
+const (
+	OwnershipObjectWriter         = "ObjectWriter"
+	OwnershipBucketOwnerPreferred = "BucketOwnerPreferred"
+	OwnershipBucketOwnerEnforced  = "BucketOwnerEnforced"
+)
+
+// MaxACLGrants is the most grants one ACL may hold (the AWS limit).
+const MaxACLGrants = 100
+
+var aclPermissions = map[string]bool{
+	ACLRead: true, ACLWrite: true, ACLReadACP: true, ACLWriteACP: true, ACLFullControl: true,
+}
+
+// ACL is an access control list on a bucket or object. Grantees are tenant
+// IDs, which are the canonical user IDs the S3 API reports as owners.
+type ACL struct {
+	Owner  string     `json:"owner"`
+	Grants []ACLGrant `json:"grants,omitempty"`
+}
+
+// ACLGrant gives one permission to a tenant or to a predefined group.
+// Exactly one of TenantID and Group is set.
+type ACLGrant struct {
+	TenantID   string `json:"id,omitempty"`
+	Group      string `json:"group,omitempty"`
+	Permission string `json:"perm"`
+}
+
+// CannedACL expands a canned ACL for a resource owned by owner in a bucket
+// owned by bucketOwner (the same tenant for a bucket's own ACL).
+func CannedACL(name, owner, bucketOwner string) (*ACL, error) {
+	acl := &ACL{Owner: owner, Grants: []ACLGrant{{TenantID: owner, Permission: ACLFullControl}}}
+	switch name {
+	case CannedPrivate:
+	case CannedPublicRead:
+		acl.Grants = append(acl.Grants, ACLGrant{Group: GroupAllUsers, Permission: ACLRead})
+	case CannedAuthenticatedRead:
+		acl.Grants = append(acl.Grants, ACLGrant{Group: GroupAuthenticatedUsers, Permission: ACLRead})
+	case CannedBucketOwnerFullControl:
+		if bucketOwner != owner {
+			acl.Grants = append(acl.Grants, ACLGrant{TenantID: bucketOwner, Permission: ACLFullControl})
+		}
+	default:
+		return nil, fmt.Errorf("canned ACL %q is not supported", name)
+	}
+	return acl, nil
+}
+
+// ParseACL decodes a stored ACL. An empty value is nil: the resource is
+// private to its bucket's owner.
+func ParseACL(data []byte) (*ACL, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+	var acl ACL
+	if err := json.Unmarshal(data, &acl); err != nil {
+		return nil, err
+	}
+	return &acl, nil
+}
+
+// Validate checks the grants of a submitted ACL.
+func (a *ACL) Validate() error {
+	if len(a.Grants) > MaxACLGrants {
+		return fmt.Errorf("an ACL may hold at most %d grants", MaxACLGrants)
+	}
+	for _, g := range a.Grants {
+		if !aclPermissions[g.Permission] {
+			return fmt.Errorf("unknown permission %q", g.Permission)
+		}
+		switch {
+		case g.TenantID != "" && g.Group != "":
+			return errors.New("a grant names either a canonical user or a group, not both")
+		case g.TenantID == "" && g.Group == "":
+			return errors.New("a grant must name a grantee")
+		case g.Group != "" && g.Group != GroupAllUsers && g.Group != GroupAuthenticatedUsers:
+			return fmt.Errorf("unsupported grantee group %q", g.Group)
+		}
+	}
+	return nil
+}
+
+// Allows reports whether the ACL gives tenantID ("" for an anonymous
+// request) the permission. FULL_CONTROL implies every permission, and the
+// owner may always read and change the ACL itself. With ignorePublic set
+// (a public access block's IgnorePublicAcls) group grants count for nothing.
+func (a *ACL) Allows(tenantID, permission string, ignorePublic bool) bool {
+	if a == nil {
+		return false
+	}
+	if tenantID != "" && tenantID == a.Owner && (permission == ACLReadACP || permission == ACLWriteACP) {
+		return true
+	}
+	for _, g := range a.Grants {
+		if g.Permission != permission && g.Permission != ACLFullControl {
+			continue
+		}
+		switch {
+		case g.TenantID != "":
+			if tenantID != "" && g.TenantID == tenantID {
+				return true
+			}
+		case ignorePublic:
+		case g.Group == GroupAllUsers:
+			return true
+		case g.Group == GroupAuthenticatedUsers:
+			if tenantID != "" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// IsPublic reports whether the ACL grants anything to a group, the test
+// BlockPublicAcls and PolicyStatus apply.
+func (a *ACL) IsPublic() bool {
+	if a == nil {
+		return false
+	}
+	for _, g := range a.Grants {
+		if g.Group != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// aclOperations maps the operations an ACL can authorize to the permission
+// they need and whether it is checked on the object rather than the bucket.
+// Anything else (bucket configuration, DeleteBucket) is the owner's alone.
+var aclOperations = map[string]struct {
+	permission string
+	object     bool
+}{
+	"ListObjects":             {ACLRead, false},
+	"ListObjectVersions":      {ACLRead, false},
+	"ListMultipartUploads":    {ACLRead, false},
+	"HeadBucket":              {ACLRead, false},
+	"GetBucketLocation":       {ACLRead, false},
+	"PutObject":               {ACLWrite, false},
+	"PostObject":              {ACLWrite, false},
+	"DeleteObject":            {ACLWrite, false},
+	"DeleteObjects":           {ACLWrite, false},
+	"InitiateMultipartUpload": {ACLWrite, false},
+	"UploadPart":              {ACLWrite, false},
+	"CompleteMultipartUpload": {ACLWrite, false},
+	"AbortMultipartUpload":    {ACLWrite, false},
+	"ListParts":               {ACLWrite, false},
+	"GetBucketAcl":            {ACLReadACP, false},
+	"PutBucketAcl":            {ACLWriteACP, false},
+	"GetObject":               {ACLRead, true},
+	"HeadObject":              {ACLRead, true},
+	"GetObjectAttributes":     {ACLRead, true},
+	"GetObjectAcl":            {ACLReadACP, true},
+	"PutObjectAcl":            {ACLWriteACP, true},
+}
+
+// ACLPermission returns the permission an operation needs from an ACL and
+// whether the object's ACL (rather than the bucket's) decides. ok is false
+// for operations no ACL can grant.
+func ACLPermission(operation string) (permission string, object, ok bool) {
+	op, ok := aclOperations[operation]
+	return op.permission, op.object, ok
+}
+
+// PublicAccessBlock is a bucket's PutPublicAccessBlock configuration.
+type PublicAccessBlock struct {
+	// BlockPublicAcls rejects requests that would set a public ACL.
+	BlockPublicAcls bool `json:"block_public_acls"`
+	// IgnorePublicAcls disregards public ACLs (and public-read
+	// visibility) when deciding access.
+	IgnorePublicAcls bool `json:"ignore_public_acls"`
+	// BlockPublicPolicy rejects bucket policies that grant public access.
+	BlockPublicPolicy bool `json:"block_public_policy"`
+	// RestrictPublicBuckets disregards the public statements of a
+	// bucket policy, so only named tenants keep policy access.
+	RestrictPublicBuckets bool `json:"restrict_public_buckets"`
+}
+
+// ParsePublicAccessBlock decodes a stored configuration; empty means none.
+func ParsePublicAccessBlock(data []byte) (PublicAccessBlock, error) {
+	var pab PublicAccessBlock
+	if len(data) == 0 {
+		return pab, nil
+	}
+	err := json.Unmarshal(data, &pab)
+	return pab, err
+}
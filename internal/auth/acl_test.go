@@ -0,0 +1,116 @@
+package auth
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCannedACL(t *testing.T) {
+	acl, err := CannedACL(CannedPublicRead, "tenant-1", "tenant-1")
+	require.NoError(t, err)
+	assert.Equal(t, []ACLGrant{
+		{TenantID: "tenant-1", Permission: ACLFullControl},
+		{Group: GroupAllUsers, Permission: ACLRead},
+	}, acl.Grants)
+	assert.True(t, acl.IsPublic())
+
+	// bucket-owner-full-control only adds a grant when someone else owns
+	// the bucket.
+	acl, err = CannedACL(CannedBucketOwnerFullControl, "writer", "owner")
+	require.NoError(t, err)
+	assert.Equal(t, "writer", acl.Owner)
+	assert.True(t, acl.Allows("owner", ACLWrite, false))
+	acl, err = CannedACL(CannedBucketOwnerFullControl, "owner", "owner")
+	require.NoError(t, err)
+	assert.Len(t, acl.Grants, 1)
+
+	_, err = CannedACL("log-delivery-write", "owner", "owner")
+	assert.Error(t, err)
+}
+
+func TestACLAllows(t *testing.T) {
+	acl := &ACL{Owner: "owner", Grants: []ACLGrant{
+		{TenantID: "owner", Permission: ACLFullControl},
+		{TenantID: "reader", Permission: ACLRead},
+		{Group: GroupAuthenticatedUsers, Permission: ACLReadACP},
+	}}
+	assert.True(t, acl.Allows("reader", ACLRead, false))
+	assert.False(t, acl.Allows("reader", ACLWrite, false))
+	assert.True(t, acl.Allows("owner", ACLWrite, false), "FULL_CONTROL implies every permission")
+	assert.True(t, acl.Allows("anyone", ACLReadACP, false))
+	assert.False(t, acl.Allows("", ACLReadACP, false), "AuthenticatedUsers excludes anonymous requests")
+	assert.False(t, acl.Allows("anyone", ACLReadACP, true), "group grants are ignored")
+
+	// The owner may always manage the ACL, even with no grant left.
+	bare := &ACL{Owner: "owner"}
+	assert.True(t, bare.Allows("owner", ACLWriteACP, false))
+	assert.False(t, bare.Allows("owner", ACLRead, false))
+
+	public := &ACL{Owner: "owner", Grants: []ACLGrant{{Group: GroupAllUsers, Permission: ACLRead}}}
+	assert.True(t, public.Allows("", ACLRead, false))
+	assert.False(t, public.Allows("", ACLRead, true))
+
+	var none *ACL
+	assert.False(t, none.Allows("owner", ACLRead, false))
+	assert.False(t, none.IsPublic())
+}
+
+func TestACLValidate(t *testing.T) {
+	ok := &ACL{Owner: "o", Grants: []ACLGrant{{TenantID: "t", Permission: ACLRead}}}
+	assert.NoError(t, ok.Validate())
+
+	for name, g := range map[string]ACLGrant{
+		"unknown permission": {TenantID: "t", Permission: "DELETE"},
+		"two grantees":       {TenantID: "t", Group: GroupAllUsers, Permission: ACLRead},
+		"no grantee":         {Permission: ACLRead},
+		"unknown group":      {Group: "http://acs.amazonaws.com/groups/s3/LogDelivery", Permission: ACLWrite},
+	} {
+		acl := &ACL{Owner: "o", Grants: []ACLGrant{g}}
+		assert.Error(t, acl.Validate(), name)
+	}
+
+	tooMany := &ACL{Owner: "o", Grants: make([]ACLGrant, MaxACLGrants+1)}
+	for i := range tooMany.Grants {
+		tooMany.Grants[i] = ACLGrant{TenantID: "t", Permission: ACLRead}
+	}
+	assert.Error(t, tooMany.Validate())
+}
+
+func TestParseACL(t *testing.T) {
+	acl, err := ParseACL(nil)
+	require.NoError(t, err)
+	assert.Nil(t, acl)
+
+	acl, err = ParseACL([]byte(`{"owner":"o","grants":[{"group":"` + GroupAllUsers + `","perm":"READ"}]}`))
+	require.NoError(t, err)
+	assert.True(t, acl.Allows("", ACLRead, false))
+}
+
+func TestACLPermission(t *testing.T) {
+	perm, object, ok := ACLPermission("GetObject")
+	assert.True(t, ok)
+	assert.True(t, object)
+	assert.Equal(t, ACLRead, perm)
+
+	perm, object, ok = ACLPermission("PutObject")
+	assert.True(t, ok)
+	assert.False(t, object, "writes are granted on the bucket")
+	assert.Equal(t, ACLWrite, perm)
+
+	_, _, ok = ACLPermission("PutBucketPolicy")
+	assert.False(t, ok)
+}
+
+func TestParsePublicAccessBlock(t *testing.T) {
+	pab, err := ParsePublicAccessBlock(nil)
+	require.NoError(t, err)
+	assert.Equal(t, PublicAccessBlock{}, pab)
+
+	pab, err = ParsePublicAccessBlock([]byte(`{"block_public_acls":true,"restrict_public_buckets":true}`))
+	require.NoError(t, err)
+	assert.True(t, pab.BlockPublicAcls)
+	assert.False(t, pab.IgnorePublicAcls)
+	assert.True(t, pab.RestrictPublicBuckets)
+}
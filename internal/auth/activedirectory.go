@@ -5,8 +5,11 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strconv"
 	"strings"
 	"time"
+
+	"github.com/go-ldap/ldap/v3"
 )
 
 // Active Directory userAccountControl flags
@@ -35,10 +38,14 @@ type ADConfig struct {
 	UseTLS             bool          `json:"use_tls"`
 	UseStartTLS        bool          `json:"use_starttls"`
 	InsecureSkipVerify bool          `json:"insecure_skip_verify"`
+	CACertFile         string        `json:"ca_cert_file"` // PEM roots for a private CA
 	Timeout            time.Duration `json:"timeout"`
 	MaxConnections     int           `json:"max_connections"`
+	// AllowPlaintext permits plain ldap:// without StartTLS, which sends
+	// bind passwords in the clear. Only for tests and trusted networks.
+	AllowPlaintext bool `json:"allow_plaintext"`
 
-	// Bind credentials
+	// Bind credentials (a DN or a UPN)
 	BindUser     string `json:"bind_user"`
 	BindPassword string `json:"bind_password"`
 
@@ -70,6 +77,9 @@ func (c *ADConfig) Validate() error {
 	if c.Host == "" && c.Domain == "" {
 		return errors.New("ad: host or domain is required")
 	}
+	if !c.UseTLS && !c.UseStartTLS && !c.AllowPlaintext {
+		return errors.New("ad: use_tls or use_starttls is required; set allow_plaintext to send passwords unencrypted")
+	}
 	return nil
 }
 
@@ -135,7 +145,8 @@ type ADUser struct {
 	Title           string
 	Manager         string
 	Phone           string
-	Groups          []string
+	Groups          []string // group CNs, for display
+	GroupDNs        []string // memberOf DNs
 	UserAccountCtrl int
 	PasswordLastSet time.Time
 	RawAttrs        map[string][]string
@@ -151,7 +162,9 @@ type ADAuthResult struct {
 	DisplayName    string
 	Department     string
 	Title          string
-	Groups         []string
+	Groups         []string // group CNs, for display
+	GroupDNs       []string // group DNs; CNs are not unique, so roles map on these
+	ObjectGUID     string   // stable across renames and moves
 	Error          string
 }
 
@@ -166,6 +179,7 @@ type ADProviderInfo struct {
 // ADProvider handles Active Directory authentication
 type ADProvider struct {
 	config *ADConfig
+	pool   *ldapPool
 }
 
 // NewADProvider creates a new Active Directory authentication provider
@@ -180,11 +194,33 @@ func NewADProvider(config *ADConfig) (*ADProvider, error) {
 		return nil, err
 	}
 
+	pool, err := newLDAPPool(ldapConnConfig{
+		host:         config.Host,
+		port:         config.Port,
+		useTLS:       config.UseTLS,
+		startTLS:     config.UseStartTLS,
+		skipVerify:   config.InsecureSkipVerify,
+		caCertFile:   config.CACertFile,
+		timeout:      config.Timeout,
+		maxConns:     config.MaxConnections,
+		bindDN:       config.BindUser,
+		bindPassword: config.BindPassword,
+	})
+	if err != nil {
+		return nil, err
+	}
+
 	return &ADProvider{
 		config: config,
+		pool:   pool,
 	}, nil
 }
 
+// Close closes the provider's pooled connections.
+func (p *ADProvider) Close() {
+	p.pool.close()
+}
+
 // BuildUPN constructs a User Principal Name
 func (p *ADProvider) BuildUPN(username string) string {
 	// If already a UPN, return as-is
@@ -259,6 +295,7 @@ func (p *ADProvider) MapAttributes(attrs map[string][]string) *ADUser {
 		for _, group := range v {
 			user.Groups = append(user.Groups, extractCNFromDN(group))
 		}
+		user.GroupDNs = v
 	}
 
 	return user
@@ -323,18 +360,133 @@ func (p *ADProvider) GetSearchAttributes() []string {
 	}
 }
 
-// Authenticate attempts to authenticate a user against AD
+// Authenticate attempts to authenticate a user against AD. The user is
+// found by sAMAccountName or UPN as the service account, its account
+// state checked, and then bound as. With ResolveNestedGroups, Groups
+// includes every group the user belongs to transitively. A wrong
+// password or an unusable account is an unsuccessful result, not an
+// error; errors mean the directory could not be queried.
 func (p *ADProvider) Authenticate(ctx context.Context, username, password string) (*ADAuthResult, error) {
 	if username == "" {
 		return nil, errors.New("ad: username is required")
 	}
+	// An empty password would be an unauthenticated bind, which AD
+	// accepts for any DN.
 	if password == "" {
 		return nil, errors.New("ad: password is required")
 	}
 
-	// Note: Actual AD connection would go here
-	// For now, return error indicating no real connection
-	return nil, errors.New("ad: connection not implemented (requires go-ldap/ldap)")
+	search := ldap.NewSearchRequest(p.SearchBase(), ldap.ScopeWholeSubtree, ldap.NeverDerefAliases,
+		2, int(p.config.Timeout.Seconds()), false, p.BuildSearchFilter(username),
+		append(p.GetSearchAttributes(), "objectGUID", adComputedUACAttr), nil)
+	var nested []string
+	entry, err := p.pool.bindUser(ctx, search, password, p.checkAccount, func(conn *ldap.Conn, entry *ldap.Entry) error {
+		if !p.config.ResolveNestedGroups {
+			return nil
+		}
+		var err error
+		nested, err = p.nestedGroups(conn, entry.DN)
+		return err
+	})
+	switch {
+	case errors.Is(err, ErrDirectoryInvalidCredentials), errors.Is(err, ErrDirectoryAccountDisabled),
+		errors.Is(err, ErrDirectoryAccountLocked), errors.Is(err, ErrDirectoryPasswordExpired):
+		return &ADAuthResult{Success: false, Error: err.Error()}, nil
+	case err != nil:
+		return nil, err
+	}
+
+	user := p.MapAttributes(entryAttributes(entry))
+	groupDNs := mergeGroupDNs(user.GroupDNs, nested)
+	groups := make([]string, 0, len(groupDNs))
+	for _, dn := range groupDNs {
+		groups = append(groups, extractCNFromDN(dn))
+	}
+	return &ADAuthResult{
+		Success:        true,
+		SAMAccountName: user.SAMAccountName,
+		UPN:            user.UPN,
+		DN:             entry.DN,
+		Email:          user.Email,
+		DisplayName:    user.DisplayName,
+		Department:     user.Department,
+		Title:          user.Title,
+		Groups:         groups,
+		GroupDNs:       groupDNs,
+		ObjectGUID:     formatObjectGUID(entry.GetRawAttributeValue("objectGUID")),
+	}, nil
+}
+
+// adComputedUACAttr holds the lockout and password-expired flags: AD
+// computes them on read and never sets them in userAccountControl itself.
+const adComputedUACAttr = "msDS-User-Account-Control-Computed"
+
+// checkAccount rejects disabled, locked out and expired accounts before a
+// bind is attempted, so they do not count further failed logons.
+func (p *ADProvider) checkAccount(entry *ldap.Entry) error {
+	uac, _ := strconv.Atoi(entry.GetAttributeValue("userAccountControl"))
+	computed, _ := strconv.Atoi(entry.GetAttributeValue(adComputedUACAttr))
+	uac |= computed
+	switch {
+	case p.IsAccountDisabled(uac):
+		return ErrDirectoryAccountDisabled
+	case p.IsAccountLocked(uac):
+		return ErrDirectoryAccountLocked
+	case p.IsPasswordExpired(uac):
+		return ErrDirectoryPasswordExpired
+	}
+	return nil
+}
+
+// nestedGroups returns the DNs of every group userDN is a member of,
+// directly or through other groups, using LDAP_MATCHING_RULE_IN_CHAIN.
+func (p *ADProvider) nestedGroups(conn *ldap.Conn, userDN string) ([]string, error) {
+	search := ldap.NewSearchRequest(p.config.BaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases,
+		0, int(p.config.Timeout.Seconds()), false,
+		"(&(objectClass=group)"+p.BuildNestedGroupFilter(userDN)+")", []string{"cn"}, nil)
+	res, err := conn.SearchWithPaging(search, 500)
+	if err != nil {
+		return nil, fmt.Errorf("ad: nested group search: %w", err)
+	}
+	groups := make([]string, 0, len(res.Entries))
+	for _, e := range res.Entries {
+		groups = append(groups, e.DN)
+	}
+	return groups, nil
+}
+
+// SearchBase returns the DN user searches start from.
+func (p *ADProvider) SearchBase() string {
+	if p.config.UserSearchBase != "" {
+		return p.config.UserSearchBase + "," + p.config.BaseDN
+	}
+	return p.config.BaseDN
+}
+
+// mergeGroupDNs appends the DNs in extra missing from groups, comparing
+// them normalized, as AD does.
+func mergeGroupDNs(groups, extra []string) []string {
+	seen := make(map[string]bool, len(groups)+len(extra))
+	out := make([]string, 0, len(groups)+len(extra))
+	for _, list := range [][]string{groups, extra} {
+		for _, g := range list {
+			if key := normalizeGroupDN(g); !seen[key] {
+				seen[key] = true
+				out = append(out, g)
+			}
+		}
+	}
+	return out
+}
+
+// formatObjectGUID renders an objectGUID in its usual string form. The
+// first three fields are stored little-endian.
+func formatObjectGUID(b []byte) string {
+	if len(b) != 16 {
+		return ""
+	}
+	return fmt.Sprintf("%02x%02x%02x%02x-%02x%02x-%02x%02x-%x-%x",
+		b[3], b[2], b[1], b[0], b[5], b[4], b[7], b[6], b[8:10], b[10:])
 }
 
 // Info returns information about the provider
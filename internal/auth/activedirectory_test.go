@@ -13,11 +13,12 @@ import (
 func TestADConfig_Validate(t *testing.T) {
 	t.Run("valid config passes validation", func(t *testing.T) {
 		config := &ADConfig{
-			Domain:       "example.com",
-			Host:         "dc.example.com",
-			BaseDN:       "dc=example,dc=com",
-			BindUser:     "admin@example.com",
-			BindPassword: "secret",
+			AllowPlaintext: true,
+			Domain:         "example.com",
+			Host:           "dc.example.com",
+			BaseDN:         "dc=example,dc=com",
+			BindUser:       "admin@example.com",
+			BindPassword:   "secret",
 		}
 		err := config.Validate()
 		assert.NoError(t, err)
@@ -25,8 +26,9 @@ func TestADConfig_Validate(t *testing.T) {
 
 	t.Run("rejects empty domain", func(t *testing.T) {
 		config := &ADConfig{
-			Host:   "dc.example.com",
-			BaseDN: "dc=example,dc=com",
+			AllowPlaintext: true,
+			Host:           "dc.example.com",
+			BaseDN:         "dc=example,dc=com",
 		}
 		err := config.Validate()
 		assert.Error(t, err)
@@ -35,8 +37,9 @@ func TestADConfig_Validate(t *testing.T) {
 
 	t.Run("auto-generates BaseDN from domain", func(t *testing.T) {
 		config := &ADConfig{
-			Domain: "corp.example.com",
-			Host:   "dc.corp.example.com",
+			AllowPlaintext: true,
+			Domain:         "corp.example.com",
+			Host:           "dc.corp.example.com",
 		}
 		config.ApplyDefaults()
 		assert.Equal(t, "dc=corp,dc=example,dc=com", config.BaseDN)
@@ -46,11 +49,12 @@ func TestADConfig_Validate(t *testing.T) {
 func TestNewADProvider(t *testing.T) {
 	t.Run("creates provider with valid config", func(t *testing.T) {
 		config := &ADConfig{
-			Domain:       "example.com",
-			Host:         "dc.example.com",
-			BaseDN:       "dc=example,dc=com",
-			BindUser:     "admin@example.com",
-			BindPassword: "secret",
+			AllowPlaintext: true,
+			Domain:         "example.com",
+			Host:           "dc.example.com",
+			BaseDN:         "dc=example,dc=com",
+			BindUser:       "admin@example.com",
+			BindPassword:   "secret",
 		}
 		provider, err := NewADProvider(config)
 		require.NoError(t, err)
@@ -66,9 +70,10 @@ func TestNewADProvider(t *testing.T) {
 
 func TestADProvider_BuildUPN(t *testing.T) {
 	config := &ADConfig{
-		Domain: "example.com",
-		Host:   "dc.example.com",
-		BaseDN: "dc=example,dc=com",
+		AllowPlaintext: true,
+		Domain:         "example.com",
+		Host:           "dc.example.com",
+		BaseDN:         "dc=example,dc=com",
 	}
 	provider, _ := NewADProvider(config)
 
@@ -85,9 +90,10 @@ func TestADProvider_BuildUPN(t *testing.T) {
 
 func TestADProvider_BuildSearchFilter(t *testing.T) {
 	config := &ADConfig{
-		Domain: "example.com",
-		Host:   "dc.example.com",
-		BaseDN: "dc=example,dc=com",
+		AllowPlaintext: true,
+		Domain:         "example.com",
+		Host:           "dc.example.com",
+		BaseDN:         "dc=example,dc=com",
 	}
 	provider, _ := NewADProvider(config)
 
@@ -109,9 +115,10 @@ func TestADProvider_BuildSearchFilter(t *testing.T) {
 
 func TestADProvider_AttributeMapping(t *testing.T) {
 	config := &ADConfig{
-		Domain: "example.com",
-		Host:   "dc.example.com",
-		BaseDN: "dc=example,dc=com",
+		AllowPlaintext: true,
+		Domain:         "example.com",
+		Host:           "dc.example.com",
+		BaseDN:         "dc=example,dc=com",
 	}
 	provider, _ := NewADProvider(config)
 
@@ -146,6 +153,7 @@ func TestADProvider_AttributeMapping(t *testing.T) {
 
 func TestADProvider_NestedGroups(t *testing.T) {
 	config := &ADConfig{
+		AllowPlaintext:      true,
 		Domain:              "example.com",
 		Host:                "dc.example.com",
 		BaseDN:              "dc=example,dc=com",
@@ -167,9 +175,10 @@ func TestADProvider_NestedGroups(t *testing.T) {
 
 func TestADProvider_DisabledAccounts(t *testing.T) {
 	config := &ADConfig{
-		Domain: "example.com",
-		Host:   "dc.example.com",
-		BaseDN: "dc=example,dc=com",
+		AllowPlaintext: true,
+		Domain:         "example.com",
+		Host:           "dc.example.com",
+		BaseDN:         "dc=example,dc=com",
 	}
 	provider, _ := NewADProvider(config)
 
@@ -197,9 +206,10 @@ func TestADProvider_DisabledAccounts(t *testing.T) {
 
 func TestADProvider_PasswordPolicy(t *testing.T) {
 	config := &ADConfig{
-		Domain: "example.com",
-		Host:   "dc.example.com",
-		BaseDN: "dc=example,dc=com",
+		AllowPlaintext: true,
+		Domain:         "example.com",
+		Host:           "dc.example.com",
+		BaseDN:         "dc=example,dc=com",
 	}
 	provider, _ := NewADProvider(config)
 
@@ -221,6 +231,7 @@ func TestADProvider_PasswordPolicy(t *testing.T) {
 func TestADProvider_GlobalCatalog(t *testing.T) {
 	t.Run("uses port 3268 for GC", func(t *testing.T) {
 		config := &ADConfig{
+			AllowPlaintext:   true,
 			Domain:           "example.com",
 			Host:             "gc.example.com",
 			BaseDN:           "dc=example,dc=com",
@@ -246,8 +257,9 @@ func TestADProvider_GlobalCatalog(t *testing.T) {
 func TestADProvider_DomainController(t *testing.T) {
 	t.Run("discovers DC via DNS SRV", func(t *testing.T) {
 		config := &ADConfig{
-			Domain: "example.com",
-			BaseDN: "dc=example,dc=com",
+			AllowPlaintext: true,
+			Domain:         "example.com",
+			BaseDN:         "dc=example,dc=com",
 		}
 		provider, _ := NewADProvider(config)
 
@@ -260,9 +272,10 @@ func TestADProvider_DomainController(t *testing.T) {
 func TestADProvider_Authenticate(t *testing.T) {
 	t.Run("returns error for empty username", func(t *testing.T) {
 		config := &ADConfig{
-			Domain: "example.com",
-			Host:   "dc.example.com",
-			BaseDN: "dc=example,dc=com",
+			AllowPlaintext: true,
+			Domain:         "example.com",
+			Host:           "dc.example.com",
+			BaseDN:         "dc=example,dc=com",
 		}
 		provider, _ := NewADProvider(config)
 
@@ -273,9 +286,10 @@ func TestADProvider_Authenticate(t *testing.T) {
 
 	t.Run("returns error for empty password", func(t *testing.T) {
 		config := &ADConfig{
-			Domain: "example.com",
-			Host:   "dc.example.com",
-			BaseDN: "dc=example,dc=com",
+			AllowPlaintext: true,
+			Domain:         "example.com",
+			Host:           "dc.example.com",
+			BaseDN:         "dc=example,dc=com",
 		}
 		provider, _ := NewADProvider(config)
 
@@ -323,9 +337,10 @@ func TestADUserAccountControl(t *testing.T) {
 func TestADProvider_ProviderInfo(t *testing.T) {
 	t.Run("returns AD provider info", func(t *testing.T) {
 		config := &ADConfig{
-			Domain: "example.com",
-			Host:   "dc.example.com",
-			BaseDN: "dc=example,dc=com",
+			AllowPlaintext: true,
+			Domain:         "example.com",
+			Host:           "dc.example.com",
+			BaseDN:         "dc=example,dc=com",
 		}
 		provider, _ := NewADProvider(config)
 
@@ -334,3 +349,131 @@ func TestADProvider_ProviderInfo(t *testing.T) {
 		assert.Equal(t, "example.com", info.Domain)
 	})
 }
+
+// seedADUsers adds a domain with jsmith in Developers, which is nested in
+// Engineering, plus a disabled and a locked-out account.
+func seedADUsers(s *testLDAPServer) {
+	guid := string([]byte{0x33, 0x22, 0x11, 0x00, 0x55, 0x44, 0x77, 0x66, 0x88, 0x99, 0xaa, 0xbb, 0xcc, 0xdd, 0xee, 0xff})
+	user := func(sam string, attrs map[string][]string) {
+		dn := "CN=" + sam + ",OU=Staff,DC=example,DC=com"
+		base := map[string][]string{
+			"objectClass":        {"top", "person", "user"},
+			"sAMAccountName":     {sam},
+			"userPrincipalName":  {sam + "@example.com"},
+			"userAccountControl": {"512"},
+		}
+		for k, v := range attrs {
+			base[k] = v
+		}
+		s.add(dn, "P@ssw0rd", base)
+	}
+	user("jsmith", map[string][]string{
+		"mail":        {"jsmith@example.com"},
+		"displayName": {"John Smith"},
+		"department":  {"R&D"},
+		"memberOf":    {"CN=Developers,OU=Groups,DC=example,DC=com"},
+		"objectGUID":  {guid},
+	})
+	user("disabled", map[string][]string{"userAccountControl": {"514"}})
+	user("locked", map[string][]string{adComputedUACAttr: {"16"}})
+	user("expired", map[string][]string{adComputedUACAttr: {"8388608"}})
+
+	s.add("CN=Developers,OU=Groups,DC=example,DC=com", "", map[string][]string{
+		"objectClass": {"top", "group"},
+		"cn":          {"Developers"},
+		"member":      {"CN=jsmith,OU=Staff,DC=example,DC=com"},
+	})
+	s.add("CN=Engineering,OU=Groups,DC=example,DC=com", "", map[string][]string{
+		"objectClass": {"top", "group"},
+		"cn":          {"Engineering"},
+		"member":      {"CN=Developers,OU=Groups,DC=example,DC=com"},
+	})
+	s.add("CN=Sales,OU=Groups,DC=example,DC=com", "", map[string][]string{
+		"objectClass": {"top", "group"},
+		"cn":          {"Sales"},
+		"member":      {"CN=locked,OU=Staff,DC=example,DC=com"},
+	})
+}
+
+func testADConfig(s *testLDAPServer) *ADConfig {
+	return &ADConfig{
+		AllowPlaintext: true,
+		Domain:         "example.com",
+		Host:           "127.0.0.1",
+		Port:           s.port(),
+		BaseDN:         "DC=example,DC=com",
+		BindUser:       s.serviceDN,
+		BindPassword:   s.servicePw,
+		Timeout:        5 * time.Second,
+	}
+}
+
+func TestADProvider_AuthenticateAgainstServer(t *testing.T) {
+	srv := newTestLDAPServer(t, false)
+	seedADUsers(srv)
+	ctx := context.Background()
+
+	t.Run("direct groups", func(t *testing.T) {
+		p, err := NewADProvider(testADConfig(srv))
+		require.NoError(t, err)
+		defer p.Close()
+
+		res, err := p.Authenticate(ctx, "jsmith", "P@ssw0rd")
+		require.NoError(t, err)
+		require.True(t, res.Success, res.Error)
+		assert.Equal(t, "jsmith", res.SAMAccountName)
+		assert.Equal(t, "jsmith@example.com", res.UPN)
+		assert.Equal(t, "CN=jsmith,OU=Staff,DC=example,DC=com", res.DN)
+		assert.Equal(t, "R&D", res.Department)
+		assert.Equal(t, []string{"Developers"}, res.Groups)
+		assert.Equal(t, "00112233-4455-6677-8899-aabbccddeeff", res.ObjectGUID)
+
+		res, err = p.Authenticate(ctx, "jsmith@example.com", "P@ssw0rd")
+		require.NoError(t, err)
+		assert.True(t, res.Success, "UPN sign-in")
+
+		res, err = p.Authenticate(ctx, "jsmith", "wrong")
+		require.NoError(t, err)
+		assert.False(t, res.Success)
+		assert.Equal(t, ErrDirectoryInvalidCredentials.Error(), res.Error)
+	})
+
+	t.Run("nested groups", func(t *testing.T) {
+		cfg := testADConfig(srv)
+		cfg.ResolveNestedGroups = true
+		p, err := NewADProvider(cfg)
+		require.NoError(t, err)
+		defer p.Close()
+
+		res, err := p.Authenticate(ctx, "jsmith", "P@ssw0rd")
+		require.NoError(t, err)
+		require.True(t, res.Success, res.Error)
+		assert.ElementsMatch(t, []string{"Developers", "Engineering"}, res.Groups)
+		assert.ElementsMatch(t, []string{"CN=Developers,OU=Groups,DC=example,DC=com", "CN=Engineering,OU=Groups,DC=example,DC=com"}, res.GroupDNs)
+	})
+
+	t.Run("unusable accounts are refused without a bind", func(t *testing.T) {
+		p, err := NewADProvider(testADConfig(srv))
+		require.NoError(t, err)
+		defer p.Close()
+
+		before := srv.userBinds.Load()
+		for sam, want := range map[string]error{
+			"disabled": ErrDirectoryAccountDisabled,
+			"locked":   ErrDirectoryAccountLocked,
+			"expired":  ErrDirectoryPasswordExpired,
+		} {
+			res, err := p.Authenticate(ctx, sam, "P@ssw0rd")
+			require.NoError(t, err)
+			assert.False(t, res.Success, sam)
+			assert.Equal(t, want.Error(), res.Error, sam)
+		}
+		assert.Equal(t, before, srv.userBinds.Load())
+	})
+}
+
+func TestFormatObjectGUID(t *testing.T) {
+	assert.Equal(t, "", formatObjectGUID([]byte{1, 2, 3}))
+	assert.Equal(t, "04030201-0605-0807-090a-0b0c0d0e0f10",
+		formatObjectGUID([]byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}))
+}
@@ -44,6 +44,9 @@ func (a *AuthService) GenerateAPIKey(ctx context.Context, userID, name string, o
 	if !exists {
 		return nil, fmt.Errorf("user not found")
 	}
+	if user.Deactivated {
+		return nil, ErrUserDeactivated
+	}
 
 	accessKey, err := generateAccessKey()
 	if err != nil {
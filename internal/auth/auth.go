@@ -26,8 +26,11 @@ type User struct {
 	Company       string
 	TenantID      string // Link to their storage tenant
 	EmailVerified bool
-	CreatedAt     time.Time
-	UpdatedAt     time.Time
+	// Deactivated marks a tenant member their organisation deprovisioned:
+	// they keep their account but may not sign in or hold credentials.
+	Deactivated bool
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
 }
 
 // Tenant represents an isolated storage namespace
@@ -226,6 +229,29 @@ func (a *AuthService) LoadFromDB(ctx context.Context) error {
 		return fmt.Errorf("iterate tenants: %w", err)
 	}
 
+	// Members signed in through their organisation's identity provider
+	// own no tenant; link them to the one they belong to.
+	mrows, err := a.sqlDB.QueryContext(ctx, `SELECT tenant_id, user_id, active FROM tenant_members`)
+	if err != nil {
+		return fmt.Errorf("load tenant members: %w", err)
+	}
+	defer func() { _ = mrows.Close() }()
+
+	for mrows.Next() {
+		var tenantID, userID string
+		var active bool
+		if err := mrows.Scan(&tenantID, &userID, &active); err != nil {
+			return fmt.Errorf("scan tenant member: %w", err)
+		}
+		if u, ok := a.userIndex[userID]; ok && u.TenantID == "" {
+			u.TenantID = tenantID
+			u.Deactivated = !active
+		}
+	}
+	if err := mrows.Err(); err != nil {
+		return fmt.Errorf("iterate tenant members: %w", err)
+	}
+
 	// Load API keys with scope data. Adds each key to apiKeys and keyIndex
 	// so that scoped VLT_ keys can authenticate S3 requests.
 	akRows, err := a.sqlDB.QueryContext(ctx, `
@@ -443,7 +469,7 @@ func (a *AuthService) ValidatePassword(ctx context.Context, email, password stri
 	}
 
 	// OAuth-only users have no password — reject login via password form.
-	if user.PasswordHash == "" {
+	if user.PasswordHash == "" || user.Deactivated {
 		return false, nil
 	}
 
@@ -554,6 +580,26 @@ func (a *AuthService) LinkOAuthAccount(ctx context.Context, userID, provider, pr
 	return nil
 }
 
+// GetOAuthProviderID returns the provider ID of the user's most recently
+// linked account at provider, or "" if there is none.
+func (a *AuthService) GetOAuthProviderID(ctx context.Context, userID, provider string) (string, error) {
+	if a.sqlDB == nil {
+		return "", nil
+	}
+	var providerID string
+	err := a.sqlDB.QueryRowContext(ctx,
+		`SELECT provider_id FROM oauth_accounts WHERE user_id = $1 AND provider = $2
+		 ORDER BY created_at DESC LIMIT 1`,
+		userID, provider).Scan(&providerID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("get oauth account: %w", err)
+	}
+	return providerID, nil
+}
+
 // CreateUserFromOAuth creates a new user+tenant via OAuth (no password).
 // Also links the OAuth account. The returned APIKey carries the plaintext
 // secret — the only chance to show it to the user (B2 reveal-once).
@@ -570,6 +616,71 @@ func (a *AuthService) CreateUserFromOAuth(ctx context.Context, email, company, p
 	return user, tenant, apiKey, nil
 }
 
+// CreateTenantMember creates a password-less user in an existing tenant
+// and links it to an account at provider. Members sign in through their
+// organisation's identity provider, so closed signups do not block them,
+// and no API key is minted: they create their own in the dashboard.
+func (a *AuthService) CreateTenantMember(ctx context.Context, tenantID, email, name, provider, providerID string) (*User, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	if !strings.Contains(email, "@") {
+		return nil, fmt.Errorf("invalid email address")
+	}
+	if _, exists := a.users[email]; exists {
+		return nil, fmt.Errorf("user already exists")
+	}
+	if _, exists := a.tenants[tenantID]; !exists {
+		return nil, fmt.Errorf("tenant not found")
+	}
+
+	user := &User{
+		ID:            uuid.New().String(),
+		Email:         email,
+		Company:       name,
+		TenantID:      tenantID,
+		EmailVerified: true,
+		CreatedAt:     time.Now(),
+		UpdatedAt:     time.Now(),
+	}
+
+	if a.sqlDB != nil {
+		tx, err := a.sqlDB.BeginTx(ctx, nil)
+		if err != nil {
+			return nil, fmt.Errorf("create tenant member: %w", err)
+		}
+		defer func() { _ = tx.Rollback() }()
+
+		result, err := tx.ExecContext(ctx, `
+			INSERT INTO users (id, email, password_hash, company, email_verified, created_at, updated_at)
+			VALUES ($1, $2, '', $3, TRUE, $4, $5)
+			ON CONFLICT (email) DO NOTHING
+		`, user.ID, user.Email, user.Company, user.CreatedAt, user.UpdatedAt)
+		if err != nil {
+			return nil, fmt.Errorf("persist user: %w", err)
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			return nil, fmt.Errorf("user already exists")
+		}
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO tenant_members (tenant_id, user_id, created_at) VALUES ($1, $2, $3)
+		`, tenantID, user.ID, user.CreatedAt); err != nil {
+			return nil, fmt.Errorf("persist tenant member: %w", err)
+		}
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO oauth_accounts (user_id, provider, provider_id, email, name)
+			VALUES ($1, $2, $3, $4, $5)
+		`, user.ID, provider, providerID, email, name); err != nil {
+			return nil, fmt.Errorf("link oauth account: %w", err)
+		}
+		if err := tx.Commit(); err != nil {
+			return nil, fmt.Errorf("create tenant member: %w", err)
+		}
+	}
+
+	a.users[email] = user
+	a.userIndex[user.ID] = user
+	return user, nil
+}
+
 // ValidateS3Request validates S3 API requests and returns tenant
 func (a *AuthService) ValidateS3Request(ctx context.Context, accessKey string) (*Tenant, error) {
 	tenant, exists := a.keyIndex[accessKey]
@@ -630,6 +741,15 @@ func (a *AuthService) ValidateJWT(tokenString string) (*JWTClaims, error) {
 	if !ok || !token.Valid {
 		return nil, fmt.Errorf("invalid token")
 	}
+	// Fail closed: a deleted user is gone from the index, and their
+	// unexpired token must not outlive them.
+	u, ok := a.userIndex[claims.UserID]
+	if !ok {
+		return nil, fmt.Errorf("invalid token: unknown user")
+	}
+	if u.Deactivated {
+		return nil, ErrUserDeactivated
+	}
 
 	return claims, nil
 }
@@ -0,0 +1,549 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MaxBucketPolicyBytes is the largest policy document PutBucketPolicy
+// accepts (the AWS limit).
+const MaxBucketPolicyBytes = 20 * 1024
+
+// Condition keys understood by the evaluator. Keys are case-insensitive,
+// so they are stored lowercased.
+const (
+	CondSourceIP        = "aws:sourceip"
+	CondSecureTransport = "aws:securetransport"
+	CondCurrentTime     = "aws:currenttime"
+	CondPrefix          = "s3:prefix"
+	CondDelimiter       = "s3:delimiter"
+)
+
+var knownConditionKeys = map[string]bool{
+	CondSourceIP:        true,
+	CondSecureTransport: true,
+	CondCurrentTime:     true,
+	CondPrefix:          true,
+	CondDelimiter:       true,
+}
+
+// PolicyDecision is the outcome of evaluating a bucket policy.
+type PolicyDecision int
+
+const (
+	// PolicyNoMatch means no statement applied; the key scope decides.
+	PolicyNoMatch PolicyDecision = iota
+	// PolicyAllow means an Allow statement matched and no Deny did.
+	PolicyAllow
+	// PolicyDeny means a Deny statement matched. Deny always wins.
+	PolicyDeny
+)
+
+// BucketPolicy is an IAM-style JSON bucket policy.
+type BucketPolicy struct {
+	Version   string            `json:"Version"`
+	ID        string            `json:"Id,omitempty"`
+	Statement []PolicyStatement `json:"Statement"`
+}
+
+// PolicyStatement is one Allow or Deny statement.
+type PolicyStatement struct {
+	Sid         string                           `json:"Sid,omitempty"`
+	Effect      string                           `json:"Effect"`
+	Principal   *PolicyPrincipal                 `json:"Principal,omitempty"`
+	Action      policyList                       `json:"Action,omitempty"`
+	NotAction   policyList                       `json:"NotAction,omitempty"`
+	Resource    policyList                       `json:"Resource,omitempty"`
+	NotResource policyList                       `json:"NotResource,omitempty"`
+	Condition   map[string]map[string]policyList `json:"Condition,omitempty"`
+}
+
+// PolicyPrincipal is either the bare wildcard "*" or {"AWS": [...]}.
+// Entries are access key IDs (VLT_ scoped keys, ASIA STS sessions),
+// "arn:aws:iam::<tenant>:root" for every key of a tenant,
+// "arn:aws:sts::<tenant>:session/<parent-key>" for every STS session
+// minted from a parent key, or "*".
+type PolicyPrincipal struct {
+	Wildcard bool
+	AWS      policyList
+}
+
+// UnmarshalJSON accepts "*" or an object with an "AWS" member.
+func (p *PolicyPrincipal) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		if s != "*" {
+			return fmt.Errorf("principal string must be \"*\", got %q", s)
+		}
+		p.Wildcard = true
+		return nil
+	}
+	var obj map[string]policyList
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return fmt.Errorf("principal must be \"*\" or an object: %w", err)
+	}
+	for k, v := range obj {
+		if k != "AWS" {
+			return fmt.Errorf("unsupported principal type %q", k)
+		}
+		p.AWS = v
+	}
+	if len(p.AWS) == 0 {
+		return errors.New("principal must name at least one entry")
+	}
+	return nil
+}
+
+// MarshalJSON writes the principal back in the form it was read.
+func (p PolicyPrincipal) MarshalJSON() ([]byte, error) {
+	if p.Wildcard {
+		return json.Marshal("*")
+	}
+	return json.Marshal(map[string]policyList{"AWS": p.AWS})
+}
+
+// policyList is a JSON value that may be a single string or an array. Bool
+// and number literals (common in Bool conditions) are kept as strings.
+type policyList []string
+
+func (l *policyList) UnmarshalJSON(data []byte) error {
+	var raw interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	switch v := raw.(type) {
+	case []interface{}:
+		out := make([]string, 0, len(v))
+		for _, e := range v {
+			s, err := policyScalar(e)
+			if err != nil {
+				return err
+			}
+			out = append(out, s)
+		}
+		*l = out
+	default:
+		s, err := policyScalar(v)
+		if err != nil {
+			return err
+		}
+		*l = policyList{s}
+	}
+	return nil
+}
+
+func policyScalar(v interface{}) (string, error) {
+	switch s := v.(type) {
+	case string:
+		return s, nil
+	case bool:
+		return strconv.FormatBool(s), nil
+	case float64:
+		return strconv.FormatFloat(s, 'f', -1, 64), nil
+	}
+	return "", fmt.Errorf("unexpected policy value %v", v)
+}
+
+// PolicyPrincipalInfo identifies the caller a policy is evaluated for.
+// The zero value is the anonymous principal.
+type PolicyPrincipalInfo struct {
+	TenantID    string
+	AccessKeyID string
+	ParentKeyID string
+}
+
+// PolicyRequest is the request context a policy is evaluated against.
+type PolicyRequest struct {
+	Principal PolicyPrincipalInfo
+	Action    string // e.g. "s3:GetObject"
+	Resource  string // e.g. "arn:aws:s3:::bucket/key"
+	// Conditions holds condition-key values keyed by lowercased key name.
+	// A key absent from the map is treated as not present in the request.
+	Conditions map[string]string
+}
+
+// ParseBucketPolicy decodes and validates a policy document. bucket is the
+// bucket the policy is being attached to: every Resource must name it.
+func ParseBucketPolicy(data []byte, bucket string) (*BucketPolicy, error) {
+	var p BucketPolicy
+	if err := json.Unmarshal(data, &p); err != nil {
+		// A lone statement object is accepted the same way a lone Action
+		// string is.
+		var single struct {
+			Version   string          `json:"Version"`
+			ID        string          `json:"Id,omitempty"`
+			Statement PolicyStatement `json:"Statement"`
+		}
+		if err2 := json.Unmarshal(data, &single); err2 != nil {
+			return nil, fmt.Errorf("invalid policy JSON: %w", err)
+		}
+		p = BucketPolicy{Version: single.Version, ID: single.ID, Statement: []PolicyStatement{single.Statement}}
+	}
+	if err := p.validate(bucket); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+func (p *BucketPolicy) validate(bucket string) error {
+	if p.Version != "2012-10-17" && p.Version != "2008-10-17" {
+		return fmt.Errorf("unsupported policy version %q", p.Version)
+	}
+	if len(p.Statement) == 0 {
+		return errors.New("policy must contain at least one statement")
+	}
+	bucketARN := "arn:aws:s3:::" + bucket
+	for i, st := range p.Statement {
+		if st.Effect != "Allow" && st.Effect != "Deny" {
+			return fmt.Errorf("statement %d: Effect must be Allow or Deny", i)
+		}
+		if st.Principal == nil {
+			return fmt.Errorf("statement %d: Principal is required", i)
+		}
+		if (len(st.Action) == 0) == (len(st.NotAction) == 0) {
+			return fmt.Errorf("statement %d: exactly one of Action or NotAction is required", i)
+		}
+		for _, a := range append(append([]string{}, st.Action...), st.NotAction...) {
+			if a != "*" && !strings.HasPrefix(strings.ToLower(a), "s3:") {
+				return fmt.Errorf("statement %d: action %q is not an s3 action", i, a)
+			}
+		}
+		if (len(st.Resource) == 0) == (len(st.NotResource) == 0) {
+			return fmt.Errorf("statement %d: exactly one of Resource or NotResource is required", i)
+		}
+		for _, res := range append(append([]string{}, st.Resource...), st.NotResource...) {
+			if res != bucketARN && !strings.HasPrefix(res, bucketARN+"/") {
+				return fmt.Errorf("statement %d: resource %q must refer to bucket %q", i, res, bucket)
+			}
+		}
+		for op, kv := range st.Condition {
+			if _, ok := conditionOperators[op]; !ok {
+				return fmt.Errorf("statement %d: unsupported condition operator %q", i, op)
+			}
+			for key, values := range kv {
+				if !knownConditionKeys[strings.ToLower(key)] {
+					return fmt.Errorf("statement %d: unsupported condition key %q", i, key)
+				}
+				if len(values) == 0 {
+					return fmt.Errorf("statement %d: condition %s/%s has no values", i, op, key)
+				}
+				if strings.HasSuffix(op, "IpAddress") {
+					for _, v := range values {
+						if _, _, err := net.ParseCIDR(v); err != nil && net.ParseIP(v) == nil {
+							return fmt.Errorf("statement %d: invalid IP or CIDR %q", i, v)
+						}
+					}
+				}
+				if strings.HasPrefix(op, "Date") {
+					for _, v := range values {
+						if _, err := time.Parse(time.RFC3339, v); err != nil {
+							return fmt.Errorf("statement %d: invalid date %q", i, v)
+						}
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// Evaluate applies the policy to a request. A matching Deny always wins;
+// otherwise a matching Allow grants; otherwise the policy has no opinion.
+func (p *BucketPolicy) Evaluate(req PolicyRequest) PolicyDecision {
+	if p == nil {
+		return PolicyNoMatch
+	}
+	decision := PolicyNoMatch
+	for i := range p.Statement {
+		st := &p.Statement[i]
+		if !st.applies(req) {
+			continue
+		}
+		if st.Effect == "Deny" {
+			return PolicyDeny
+		}
+		decision = PolicyAllow
+	}
+	return decision
+}
+
+// IsPublic reports whether any Allow statement grants the wildcard
+// principal without an aws:SourceIp restriction.
+func (p *BucketPolicy) IsPublic() bool {
+	if p == nil {
+		return false
+	}
+	for _, st := range p.Statement {
+		if st.Effect != "Allow" || st.Principal == nil || !st.Principal.matchesAnyone() {
+			continue
+		}
+		restricted := false
+		for op, kv := range st.Condition {
+			if !strings.HasSuffix(op, "IpAddress") || strings.HasPrefix(op, "Not") {
+				continue
+			}
+			for key := range kv {
+				if strings.ToLower(key) == CondSourceIP {
+					restricted = true
+				}
+			}
+		}
+		if !restricted {
+			return true
+		}
+	}
+	return false
+}
+
+func (pp *PolicyPrincipal) matchesAnyone() bool {
+	if pp.Wildcard {
+		return true
+	}
+	for _, e := range pp.AWS {
+		if e == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+func (pp *PolicyPrincipal) matches(who PolicyPrincipalInfo) bool {
+	if pp.Wildcard {
+		return true
+	}
+	anonymous := who.TenantID == "" && who.AccessKeyID == ""
+	for _, e := range pp.AWS {
+		switch {
+		case e == "*":
+			// {"AWS": "*"} is every authenticated caller; only the bare
+			// "*" admits anonymous requests.
+			if !anonymous {
+				return true
+			}
+		case anonymous:
+			continue
+		case who.AccessKeyID != "" && e == who.AccessKeyID:
+			return true
+		case e == "arn:aws:iam::"+who.TenantID+":root":
+			return true
+		case who.ParentKeyID != "" && e == "arn:aws:sts::"+who.TenantID+":session/"+who.ParentKeyID:
+			return true
+		}
+	}
+	return false
+}
+
+func (st *PolicyStatement) applies(req PolicyRequest) bool {
+	if st.Principal == nil || !st.Principal.matches(req.Principal) {
+		return false
+	}
+	if len(st.Action) > 0 && !anyPolicyMatch(st.Action, req.Action, true) {
+		return false
+	}
+	if len(st.NotAction) > 0 && anyPolicyMatch(st.NotAction, req.Action, true) {
+		return false
+	}
+	if len(st.Resource) > 0 && !anyPolicyMatch(st.Resource, req.Resource, false) {
+		return false
+	}
+	if len(st.NotResource) > 0 && anyPolicyMatch(st.NotResource, req.Resource, false) {
+		return false
+	}
+	for op, kv := range st.Condition {
+		fn := conditionOperators[op]
+		for key, values := range kv {
+			actual, present := req.Conditions[strings.ToLower(key)]
+			if !fn(actual, present, values) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func anyPolicyMatch(patterns []string, value string, foldCase bool) bool {
+	if foldCase {
+		value = strings.ToLower(value)
+	}
+	for _, p := range patterns {
+		if foldCase {
+			p = strings.ToLower(p)
+		}
+		if policyGlob(p, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// policyGlob matches value against a pattern where * matches any run of
+// characters (including "/") and ? matches exactly one.
+func policyGlob(pattern, value string) bool {
+	px, vx := 0, 0
+	star, match := -1, 0
+	for vx < len(value) {
+		switch {
+		case px < len(pattern) && (pattern[px] == '?' || pattern[px] == value[vx]):
+			px++
+			vx++
+		case px < len(pattern) && pattern[px] == '*':
+			star = px
+			match = vx
+			px++
+		case star >= 0:
+			px = star + 1
+			match++
+			vx = match
+		default:
+			return false
+		}
+	}
+	for px < len(pattern) && pattern[px] == '*' {
+		px++
+	}
+	return px == len(pattern)
+}
+
+// conditionFunc reports whether a condition holds. Multiple values are
+// ORed; negated operators hold when the key is absent, as in IAM.
+type conditionFunc func(actual string, present bool, values []string) bool
+
+var conditionOperators = map[string]conditionFunc{
+	"StringEquals": func(a string, ok bool, vs []string) bool {
+		return ok && anyValue(vs, func(v string) bool { return a == v })
+	},
+	"StringNotEquals": func(a string, ok bool, vs []string) bool {
+		return !ok || !anyValue(vs, func(v string) bool { return a == v })
+	},
+	"StringEqualsIgnoreCase": func(a string, ok bool, vs []string) bool {
+		return ok && anyValue(vs, func(v string) bool { return strings.EqualFold(a, v) })
+	},
+	"StringNotEqualsIgnoreCase": func(a string, ok bool, vs []string) bool {
+		return !ok || !anyValue(vs, func(v string) bool { return strings.EqualFold(a, v) })
+	},
+	"StringLike": func(a string, ok bool, vs []string) bool {
+		return ok && anyValue(vs, func(v string) bool { return policyGlob(v, a) })
+	},
+	"StringNotLike": func(a string, ok bool, vs []string) bool {
+		return !ok || !anyValue(vs, func(v string) bool { return policyGlob(v, a) })
+	},
+	"IpAddress": func(a string, ok bool, vs []string) bool {
+		return ok && anyValue(vs, func(v string) bool { return ipInPolicyRange(a, v) })
+	},
+	"NotIpAddress": func(a string, ok bool, vs []string) bool {
+		return !ok || !anyValue(vs, func(v string) bool { return ipInPolicyRange(a, v) })
+	},
+	"Bool": func(a string, ok bool, vs []string) bool {
+		return ok && anyValue(vs, func(v string) bool { return strings.EqualFold(a, v) })
+	},
+	"DateEquals":            dateCondition(func(a, v time.Time) bool { return a.Equal(v) }),
+	"DateNotEquals":         dateCondition(func(a, v time.Time) bool { return !a.Equal(v) }),
+	"DateLessThan":          dateCondition(func(a, v time.Time) bool { return a.Before(v) }),
+	"DateLessThanEquals":    dateCondition(func(a, v time.Time) bool { return !a.After(v) }),
+	"DateGreaterThan":       dateCondition(func(a, v time.Time) bool { return a.After(v) }),
+	"DateGreaterThanEquals": dateCondition(func(a, v time.Time) bool { return !a.Before(v) }),
+}
+
+func anyValue(values []string, fn func(string) bool) bool {
+	for _, v := range values {
+		if fn(v) {
+			return true
+		}
+	}
+	return false
+}
+
+func dateCondition(cmp func(actual, value time.Time) bool) conditionFunc {
+	return func(a string, ok bool, vs []string) bool {
+		if !ok {
+			return false
+		}
+		at, err := time.Parse(time.RFC3339, a)
+		if err != nil {
+			return false
+		}
+		return anyValue(vs, func(v string) bool {
+			vt, err := time.Parse(time.RFC3339, v)
+			return err == nil && cmp(at, vt)
+		})
+	}
+}
+
+func ipInPolicyRange(ip, entry string) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	if _, cidr, err := net.ParseCIDR(entry); err == nil {
+		return cidr.Contains(parsed)
+	}
+	if e := net.ParseIP(entry); e != nil {
+		return e.Equal(parsed)
+	}
+	return false
+}
+
+// s3PolicyActions maps operation names (from determineOperation) whose
+// IAM action differs from "s3:" + name.
+var s3PolicyActions = map[string]string{
+	"HeadObject":              "s3:GetObject",
+	"ListObjects":             "s3:ListBucket",
+	"HeadBucket":              "s3:ListBucket",
+	"ListObjectVersions":      "s3:ListBucketVersions",
+	"ListMultipartUploads":    "s3:ListBucketMultipartUploads",
+	"ListParts":               "s3:ListMultipartUploadParts",
+	"InitiateMultipartUpload": "s3:PutObject",
+	"UploadPart":              "s3:PutObject",
+	"CompleteMultipartUpload": "s3:PutObject",
+	"PostObject":              "s3:PutObject",
+	"SelectObjectContent":     "s3:GetObject",
+	"DeleteObjects":           "s3:DeleteObject",
+	"ListBuckets":             "s3:ListAllMyBuckets",
+	"GetBucketInventory":      "s3:GetInventoryConfiguration",
+	"PutBucketInventory":      "s3:PutInventoryConfiguration",
+	"DeleteBucketInventory":   "s3:PutInventoryConfiguration",
+
+	"GetBucketLifecycleConfiguration": "s3:GetLifecycleConfiguration",
+	"PutBucketLifecycleConfiguration": "s3:PutLifecycleConfiguration",
+	"DeleteBucketLifecycle":           "s3:PutLifecycleConfiguration",
+	"GetBucketCors":                   "s3:GetBucketCORS",
+	"PutBucketCors":                   "s3:PutBucketCORS",
+	"DeleteBucketCors":                "s3:PutBucketCORS",
+	"GetBucketEncryption":             "s3:GetEncryptionConfiguration",
+	"PutBucketEncryption":             "s3:PutEncryptionConfiguration",
+	"DeleteBucketEncryption":          "s3:PutEncryptionConfiguration",
+	"GetBucketReplication":            "s3:GetReplicationConfiguration",
+	"PutBucketReplication":            "s3:PutReplicationConfiguration",
+	"DeleteBucketReplication":         "s3:PutReplicationConfiguration",
+	"GetBucketWebsite":                "s3:GetBucketWebsite",
+	"PutBucketWebsite":                "s3:PutBucketWebsite",
+	"DeleteBucketWebsite":             "s3:DeleteBucketWebsite",
+	"GetObjectLockConfiguration":      "s3:GetBucketObjectLockConfiguration",
+	"PutObjectLockConfiguration":      "s3:PutBucketObjectLockConfiguration",
+	"DeleteBucketOwnershipControls":   "s3:PutBucketOwnershipControls",
+	"GetPublicAccessBlock":            "s3:GetBucketPublicAccessBlock",
+	"PutPublicAccessBlock":            "s3:PutBucketPublicAccessBlock",
+	"DeletePublicAccessBlock":         "s3:PutBucketPublicAccessBlock",
+}
+
+// S3PolicyAction returns the IAM action name for an S3 operation.
+func S3PolicyAction(operation string) string {
+	if a, ok := s3PolicyActions[operation]; ok {
+		return a
+	}
+	return "s3:" + operation
+}
+
+// S3PolicyResource returns the ARN a request targets: the bucket for
+// bucket-level operations, bucket/key for object operations.
+func S3PolicyResource(bucket, key string) string {
+	if key == "" {
+		return "arn:aws:s3:::" + bucket
+	}
+	return "arn:aws:s3:::" + bucket + "/" + key
+}
@@ -0,0 +1,194 @@
+package auth
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const partnerPolicy = `{
+  "Version": "2012-10-17",
+  "Statement": [
+    {
+      "Sid": "PartnerRead",
+      "Effect": "Allow",
+      "Principal": {"AWS": "VLT_partner"},
+      "Action": "s3:GetObject",
+      "Resource": "arn:aws:s3:::shared/partner/*"
+    },
+    {
+      "Sid": "PartnerList",
+      "Effect": "Allow",
+      "Principal": {"AWS": ["VLT_partner"]},
+      "Action": "s3:ListBucket",
+      "Resource": "arn:aws:s3:::shared",
+      "Condition": {"StringLike": {"s3:prefix": "partner/*"}}
+    },
+    {
+      "Sid": "TLSOnly",
+      "Effect": "Deny",
+      "Principal": "*",
+      "Action": "s3:*",
+      "Resource": "arn:aws:s3:::shared/*",
+      "Condition": {"Bool": {"aws:SecureTransport": false}}
+    }
+  ]
+}`
+
+func policyReq(key, action, resource string, conds map[string]string) PolicyRequest {
+	return PolicyRequest{
+		Principal:  PolicyPrincipalInfo{TenantID: "t1", AccessKeyID: key},
+		Action:     action,
+		Resource:   resource,
+		Conditions: conds,
+	}
+}
+
+func TestParseBucketPolicy_Valid(t *testing.T) {
+	p, err := ParseBucketPolicy([]byte(partnerPolicy), "shared")
+	require.NoError(t, err)
+	require.Len(t, p.Statement, 3)
+	assert.Equal(t, []string{"VLT_partner"}, []string(p.Statement[0].Principal.AWS))
+	assert.True(t, p.Statement[2].Principal.Wildcard)
+}
+
+func TestParseBucketPolicy_Invalid(t *testing.T) {
+	tests := map[string]string{
+		"not json":       `{`,
+		"bad version":    `{"Version":"2020-01-01","Statement":[{"Effect":"Allow","Principal":"*","Action":"s3:GetObject","Resource":"arn:aws:s3:::b/*"}]}`,
+		"bad effect":     `{"Version":"2012-10-17","Statement":[{"Effect":"Maybe","Principal":"*","Action":"s3:GetObject","Resource":"arn:aws:s3:::b/*"}]}`,
+		"other bucket":   `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Principal":"*","Action":"s3:GetObject","Resource":"arn:aws:s3:::other/*"}]}`,
+		"non-s3 action":  `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Principal":"*","Action":"iam:PassRole","Resource":"arn:aws:s3:::b/*"}]}`,
+		"no principal":   `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":"s3:GetObject","Resource":"arn:aws:s3:::b/*"}]}`,
+		"unknown op":     `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Principal":"*","Action":"s3:GetObject","Resource":"arn:aws:s3:::b/*","Condition":{"Fuzzy":{"s3:prefix":"x"}}}]}`,
+		"unknown key":    `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Principal":"*","Action":"s3:GetObject","Resource":"arn:aws:s3:::b/*","Condition":{"StringEquals":{"aws:Referer":"x"}}}]}`,
+		"bad cidr":       `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Principal":"*","Action":"s3:GetObject","Resource":"arn:aws:s3:::b/*","Condition":{"IpAddress":{"aws:SourceIp":"not-an-ip"}}}]}`,
+		"action and not": `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Principal":"*","Action":"s3:GetObject","NotAction":"s3:PutObject","Resource":"arn:aws:s3:::b/*"}]}`,
+	}
+	for name, doc := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := ParseBucketPolicy([]byte(doc), "b")
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestBucketPolicy_PartnerPrefixGrant(t *testing.T) {
+	p, err := ParseBucketPolicy([]byte(partnerPolicy), "shared")
+	require.NoError(t, err)
+	tls := map[string]string{CondSecureTransport: "true"}
+
+	assert.Equal(t, PolicyAllow, p.Evaluate(policyReq("VLT_partner", "s3:GetObject", "arn:aws:s3:::shared/partner/a.csv", tls)))
+	assert.Equal(t, PolicyNoMatch, p.Evaluate(policyReq("VLT_partner", "s3:GetObject", "arn:aws:s3:::shared/private/a.csv", tls)))
+	assert.Equal(t, PolicyNoMatch, p.Evaluate(policyReq("VLT_other", "s3:GetObject", "arn:aws:s3:::shared/partner/a.csv", tls)))
+	assert.Equal(t, PolicyNoMatch, p.Evaluate(policyReq("VLT_partner", "s3:PutObject", "arn:aws:s3:::shared/partner/a.csv", tls)))
+
+	// ListBucket is granted only with the partner prefix.
+	list := map[string]string{CondSecureTransport: "true", CondPrefix: "partner/2024/"}
+	assert.Equal(t, PolicyAllow, p.Evaluate(policyReq("VLT_partner", "s3:ListBucket", "arn:aws:s3:::shared", list)))
+	list[CondPrefix] = ""
+	assert.Equal(t, PolicyNoMatch, p.Evaluate(policyReq("VLT_partner", "s3:ListBucket", "arn:aws:s3:::shared", list)))
+}
+
+func TestBucketPolicy_DenyWins(t *testing.T) {
+	p, err := ParseBucketPolicy([]byte(partnerPolicy), "shared")
+	require.NoError(t, err)
+	plain := map[string]string{CondSecureTransport: "false"}
+	assert.Equal(t, PolicyDeny, p.Evaluate(policyReq("VLT_partner", "s3:GetObject", "arn:aws:s3:::shared/partner/a.csv", plain)))
+	assert.Equal(t, PolicyDeny, p.Evaluate(policyReq("", "s3:GetObject", "arn:aws:s3:::shared/x", plain)), "bare * includes anonymous")
+}
+
+func TestBucketPolicy_Principals(t *testing.T) {
+	doc := `{"Version":"2012-10-17","Statement":[{"Effect":"Allow",
+		"Principal":{"AWS":["arn:aws:iam::t1:root","arn:aws:sts::t2:session/VLT_parent"]},
+		"Action":"s3:GetObject","Resource":"arn:aws:s3:::b/*"}]}`
+	p, err := ParseBucketPolicy([]byte(doc), "b")
+	require.NoError(t, err)
+	res := "arn:aws:s3:::b/k"
+
+	assert.Equal(t, PolicyAllow, p.Evaluate(PolicyRequest{
+		Principal: PolicyPrincipalInfo{TenantID: "t1", AccessKeyID: "VLT_any"}, Action: "s3:GetObject", Resource: res}))
+	assert.Equal(t, PolicyAllow, p.Evaluate(PolicyRequest{
+		Principal: PolicyPrincipalInfo{TenantID: "t2", AccessKeyID: "ASIAXYZ", ParentKeyID: "VLT_parent"}, Action: "s3:GetObject", Resource: res}))
+	assert.Equal(t, PolicyNoMatch, p.Evaluate(PolicyRequest{
+		Principal: PolicyPrincipalInfo{TenantID: "t2", AccessKeyID: "VLT_parent"}, Action: "s3:GetObject", Resource: res}),
+		"the session ARN matches sessions, not the parent key itself")
+	assert.Equal(t, PolicyNoMatch, p.Evaluate(PolicyRequest{Action: "s3:GetObject", Resource: res}), "anonymous")
+
+	authed := `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Principal":{"AWS":"*"},"Action":"s3:GetObject","Resource":"arn:aws:s3:::b/*"}]}`
+	p, err = ParseBucketPolicy([]byte(authed), "b")
+	require.NoError(t, err)
+	assert.Equal(t, PolicyNoMatch, p.Evaluate(PolicyRequest{Action: "s3:GetObject", Resource: res}), `{"AWS":"*"} excludes anonymous`)
+	assert.Equal(t, PolicyAllow, p.Evaluate(policyReq("VLT_x", "s3:GetObject", res, nil)))
+}
+
+func TestBucketPolicy_Conditions(t *testing.T) {
+	doc := `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Principal":"*","Action":"s3:Get*",
+		"Resource":"arn:aws:s3:::b/*",
+		"Condition":{
+			"IpAddress":{"aws:SourceIp":["10.0.0.0/8","192.0.2.7"]},
+			"DateLessThan":{"aws:CurrentTime":"2030-01-01T00:00:00Z"}}}]}`
+	p, err := ParseBucketPolicy([]byte(doc), "b")
+	require.NoError(t, err)
+	res := "arn:aws:s3:::b/k"
+
+	ok := map[string]string{CondSourceIP: "10.1.2.3", CondCurrentTime: "2029-06-01T00:00:00Z"}
+	assert.Equal(t, PolicyAllow, p.Evaluate(policyReq("k", "s3:GetObject", res, ok)))
+	assert.Equal(t, PolicyAllow, p.Evaluate(policyReq("k", "s3:getobjecttagging", res, ok)), "actions are case-insensitive")
+
+	ip := map[string]string{CondSourceIP: "192.0.2.7", CondCurrentTime: "2029-06-01T00:00:00Z"}
+	assert.Equal(t, PolicyAllow, p.Evaluate(policyReq("k", "s3:GetObject", res, ip)))
+
+	wrongIP := map[string]string{CondSourceIP: "203.0.113.1", CondCurrentTime: "2029-06-01T00:00:00Z"}
+	assert.Equal(t, PolicyNoMatch, p.Evaluate(policyReq("k", "s3:GetObject", res, wrongIP)))
+
+	late := map[string]string{CondSourceIP: "10.1.2.3", CondCurrentTime: "2031-01-01T00:00:00Z"}
+	assert.Equal(t, PolicyNoMatch, p.Evaluate(policyReq("k", "s3:GetObject", res, late)))
+
+	assert.Equal(t, PolicyNoMatch, p.Evaluate(policyReq("k", "s3:GetObject", res, nil)), "missing keys fail positive operators")
+}
+
+func TestBucketPolicy_NotResource(t *testing.T) {
+	doc := `{"Version":"2012-10-17","Statement":[{"Effect":"Deny","Principal":{"AWS":"VLT_partner"},
+		"Action":"s3:*","NotResource":"arn:aws:s3:::b/partner/*"}]}`
+	p, err := ParseBucketPolicy([]byte(doc), "b")
+	require.NoError(t, err)
+	assert.Equal(t, PolicyDeny, p.Evaluate(policyReq("VLT_partner", "s3:GetObject", "arn:aws:s3:::b/other", nil)))
+	assert.Equal(t, PolicyNoMatch, p.Evaluate(policyReq("VLT_partner", "s3:GetObject", "arn:aws:s3:::b/partner/x", nil)))
+}
+
+func TestBucketPolicy_IsPublic(t *testing.T) {
+	public := `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Principal":"*","Action":"s3:GetObject","Resource":"arn:aws:s3:::b/*"}]}`
+	p, err := ParseBucketPolicy([]byte(public), "b")
+	require.NoError(t, err)
+	assert.True(t, p.IsPublic())
+
+	ipOnly := `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Principal":"*","Action":"s3:GetObject","Resource":"arn:aws:s3:::b/*",
+		"Condition":{"IpAddress":{"aws:SourceIp":"10.0.0.0/8"}}}]}`
+	p, err = ParseBucketPolicy([]byte(ipOnly), "b")
+	require.NoError(t, err)
+	assert.False(t, p.IsPublic())
+
+	p, err = ParseBucketPolicy([]byte(partnerPolicy), "shared")
+	require.NoError(t, err)
+	assert.False(t, p.IsPublic(), "a wildcard Deny does not make a bucket public")
+}
+
+func TestPolicyGlob(t *testing.T) {
+	assert.True(t, policyGlob("*", ""))
+	assert.True(t, policyGlob("a/*", "a/b/c"))
+	assert.True(t, policyGlob("a/?.txt", "a/b.txt"))
+	assert.False(t, policyGlob("a/?.txt", "a/bc.txt"))
+	assert.True(t, policyGlob("*.jpg", "x/y.jpg"))
+	assert.False(t, policyGlob("a/*", "b/a"))
+}
+
+func TestS3PolicyAction(t *testing.T) {
+	assert.Equal(t, "s3:GetObject", S3PolicyAction("GetObject"))
+	assert.Equal(t, "s3:GetObject", S3PolicyAction("HeadObject"))
+	assert.Equal(t, "s3:ListBucket", S3PolicyAction("ListObjects"))
+	assert.Equal(t, "s3:PutObject", S3PolicyAction("UploadPart"))
+	assert.Equal(t, "arn:aws:s3:::b", S3PolicyResource("b", ""))
+	assert.Equal(t, "arn:aws:s3:::b/k/x", S3PolicyResource("b", "k/x"))
+}
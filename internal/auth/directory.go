@@ -0,0 +1,209 @@
+// internal/auth/directory.go
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+// Directory types accepted in DirectoryConfig.Type.
+const (
+	DirectoryLDAP            = "ldap"
+	DirectoryActiveDirectory = "activedirectory"
+)
+
+// DirectoryConfig configures dashboard sign-in against an LDAP server or
+// Active Directory. Users who authenticate are provisioned on first
+// sign-in, and their groups decide their roles at every sign-in.
+type DirectoryConfig struct {
+	Type            string      `json:"type"` // "ldap" or "activedirectory"
+	LDAP            *LDAPConfig `json:"ldap,omitempty"`
+	ActiveDirectory *ADConfig   `json:"active_directory,omitempty"`
+	// TenantID is the tenant directory users are provisioned into.
+	TenantID string `json:"tenant_id"`
+
+	// Groups are full DNs: CNs repeat across OUs, so a CN would let any
+	// group of that name confer the role. LinkExistingAccounts is safe
+	// only if users cannot change their own mail attribute.
+	RoleMapping
+}
+
+// LoadDirectoryConfig reads a DirectoryConfig from a JSON file.
+func LoadDirectoryConfig(path string) (*DirectoryConfig, error) {
+	data, err := os.ReadFile(path) // #nosec G304 -- operator-supplied config path
+	if err != nil {
+		return nil, fmt.Errorf("read directory config: %w", err)
+	}
+	var cfg DirectoryConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("parse directory config: %w", err)
+	}
+	return &cfg, nil
+}
+
+// Validate checks the role mapping; each provider validates its own
+// connection settings.
+func (c *DirectoryConfig) Validate() error {
+	if c.TenantID == "" {
+		return errors.New("directory: tenant_id is required")
+	}
+	if err := c.RoleMapping.Validate(); err != nil {
+		return fmt.Errorf("directory: %w", err)
+	}
+	for group := range c.GroupRoles {
+		if _, err := ldap.ParseDN(group); err != nil || !strings.Contains(group, "=") {
+			return fmt.Errorf("directory: group_roles key %q is not a group DN, e.g. CN=Storage Admins,OU=Groups,DC=example,DC=com", group)
+		}
+	}
+	return nil
+}
+
+// Directory authenticates users against an LDAP or Active Directory
+// provider and maps their groups onto rbac roles. Identities it returns
+// have the entryUUID or objectGUID as ID (the DN when absent) and the DN
+// as Subject.
+type Directory struct {
+	provider     string
+	authenticate func(ctx context.Context, username, password string) (*ExternalIdentity, error)
+	close        func()
+	tenantID     string
+	mapping      RoleMapping
+}
+
+// NewDirectory creates a Directory from cfg. It does not connect:
+// connections are opened on first use and pooled.
+func NewDirectory(cfg *DirectoryConfig) (*Directory, error) {
+	if cfg == nil {
+		return nil, errors.New("directory: config is required")
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	// Mapping keys and users' groups are both compared as normalized DNs.
+	mapping := cfg.RoleMapping
+	mapping.GroupRoles = make(map[string]string, len(cfg.GroupRoles))
+	for group, role := range cfg.GroupRoles {
+		mapping.GroupRoles[normalizeGroupDN(group)] = role
+	}
+	d := &Directory{provider: cfg.Type, tenantID: cfg.TenantID, mapping: mapping}
+
+	switch cfg.Type {
+	case DirectoryLDAP:
+		p, err := NewLDAPProvider(cfg.LDAP)
+		if err != nil {
+			return nil, err
+		}
+		d.close = p.Close
+		d.authenticate = func(ctx context.Context, username, password string) (*ExternalIdentity, error) {
+			res, err := p.Authenticate(ctx, username, password)
+			if err != nil {
+				return nil, err
+			}
+			if !res.Success {
+				return nil, directoryFailure(res.Error)
+			}
+			id := res.EntryUUID
+			if id == "" {
+				id = res.UserDN
+			}
+			return &ExternalIdentity{
+				ID:          id,
+				Subject:     res.UserDN,
+				Username:    res.Username,
+				Email:       res.Email,
+				DisplayName: res.DisplayName,
+				Groups:      res.GroupDNs,
+			}, nil
+		}
+	case DirectoryActiveDirectory:
+		p, err := NewADProvider(cfg.ActiveDirectory)
+		if err != nil {
+			return nil, err
+		}
+		d.close = p.Close
+		d.authenticate = func(ctx context.Context, username, password string) (*ExternalIdentity, error) {
+			res, err := p.Authenticate(ctx, username, password)
+			if err != nil {
+				return nil, err
+			}
+			if !res.Success {
+				return nil, directoryFailure(res.Error)
+			}
+			id := res.ObjectGUID
+			if id == "" {
+				id = res.DN
+			}
+			// Mailbox-less accounts still have a UPN, which is
+			// email-shaped and unique in the forest.
+			email := res.Email
+			if email == "" {
+				email = res.UPN
+			}
+			return &ExternalIdentity{
+				ID:          id,
+				Subject:     res.DN,
+				Username:    res.SAMAccountName,
+				Email:       email,
+				DisplayName: res.DisplayName,
+				Groups:      res.GroupDNs,
+			}, nil
+		}
+	default:
+		return nil, fmt.Errorf("directory: unknown type %q (want %q or %q)", cfg.Type, DirectoryLDAP, DirectoryActiveDirectory)
+	}
+	return d, nil
+}
+
+// directoryFailure turns a provider result's Error back into its sentinel.
+func directoryFailure(msg string) error {
+	for _, err := range []error{ErrDirectoryAccountDisabled, ErrDirectoryAccountLocked, ErrDirectoryPasswordExpired} {
+		if msg == err.Error() {
+			return err
+		}
+	}
+	return ErrDirectoryInvalidCredentials
+}
+
+// Provider returns the directory type, which also names its users' linked
+// accounts.
+func (d *Directory) Provider() string {
+	return d.provider
+}
+
+// Close closes the directory's pooled connections.
+func (d *Directory) Close() {
+	d.close()
+}
+
+// Authenticate checks username and password against the directory and
+// resolves the user's roles. Failed sign-ins return one of the
+// ErrDirectory* errors or ErrNoMappedRole; any other error means the
+// directory could not be queried.
+func (d *Directory) Authenticate(ctx context.Context, username, password string) (*ExternalIdentity, error) {
+	id, err := d.authenticate(ctx, strings.TrimSpace(username), password)
+	if err != nil {
+		return nil, err
+	}
+	id.Provider = d.provider
+	id.Email = strings.ToLower(strings.TrimSpace(id.Email))
+	for i, group := range id.Groups {
+		id.Groups[i] = normalizeGroupDN(group)
+	}
+	id.Roles = d.mapping.ResolveRoles(id.Groups)
+	if len(id.Roles) == 0 {
+		return nil, ErrNoMappedRole
+	}
+	return id, nil
+}
+
+// Provision finds or creates the member of the directory's tenant for id
+// and syncs its roles; see RoleMapping.Provision.
+func (d *Directory) Provision(ctx context.Context, a *AuthService, id *ExternalIdentity) (*User, error) {
+	return d.mapping.Provision(ctx, a, d.tenantID, id)
+}
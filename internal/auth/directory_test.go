@@ -0,0 +1,123 @@
+// internal/auth/directory_test.go
+package auth
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDirectoryConfig_Validate(t *testing.T) {
+	valid := &DirectoryConfig{TenantID: "tenant-1", RoleMapping: RoleMapping{GroupRoles: map[string]string{"CN=Admins,OU=Groups,DC=example,DC=com": "admin"}, DefaultRole: "viewer"}}
+	assert.NoError(t, valid.Validate())
+	bareCN := &DirectoryConfig{TenantID: "tenant-1", RoleMapping: RoleMapping{GroupRoles: map[string]string{"Admins": "admin"}}}
+	assert.ErrorContains(t, bareCN.Validate(), "not a group DN")
+	invalid := &DirectoryConfig{TenantID: "tenant-1", RoleMapping: RoleMapping{DefaultRole: "x"}}
+	assert.ErrorContains(t, invalid.Validate(), "directory: default_role")
+	noTenant := &DirectoryConfig{RoleMapping: RoleMapping{DefaultRole: "viewer"}}
+	assert.ErrorContains(t, noTenant.Validate(), "tenant_id is required")
+
+	_, err := NewDirectory(&DirectoryConfig{Type: "kerberos"})
+	assert.Error(t, err)
+}
+
+func TestLoadDirectoryConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "directory.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{
+		"type": "activedirectory",
+		"active_directory": {"domain": "example.com", "resolve_nested_groups": true},
+		"group_roles": {"CN=Storage Admins,OU=Groups,DC=example,DC=com": "admin"},
+		"default_role": "viewer"
+	}`), 0o600))
+
+	cfg, err := LoadDirectoryConfig(path)
+	require.NoError(t, err)
+	assert.Equal(t, DirectoryActiveDirectory, cfg.Type)
+	require.NotNil(t, cfg.ActiveDirectory)
+	assert.True(t, cfg.ActiveDirectory.ResolveNestedGroups)
+	assert.Equal(t, "admin", cfg.GroupRoles["CN=Storage Admins,OU=Groups,DC=example,DC=com"])
+}
+
+// newStubDirectory returns a Directory whose provider accepts any
+// password "pw" and reports identity.
+func newStubDirectory(identity ExternalIdentity, groupRoles map[string]string, defaultRole string) *Directory {
+	return &Directory{
+		provider: DirectoryLDAP,
+		close:    func() {},
+		mapping:  RoleMapping{GroupRoles: groupRoles, DefaultRole: defaultRole},
+		authenticate: func(_ context.Context, _, password string) (*ExternalIdentity, error) {
+			if password != "pw" {
+				return nil, ErrDirectoryInvalidCredentials
+			}
+			id := identity
+			return &id, nil
+		},
+	}
+}
+
+func TestDirectory_Authenticate(t *testing.T) {
+	identity := ExternalIdentity{ID: "u-1", Subject: "uid=jsmith", Email: " JSmith@Example.com ", Groups: []string{"Storage-Admins", "Staff"}}
+	ctx := context.Background()
+
+	d := newStubDirectory(identity, map[string]string{"storage-admins": "admin", "staff": "user"}, "")
+	id, err := d.Authenticate(ctx, "jsmith", "pw")
+	require.NoError(t, err)
+	assert.Equal(t, DirectoryLDAP, id.Provider)
+	assert.Equal(t, "jsmith@example.com", id.Email)
+	assert.Equal(t, []string{"admin", "user"}, id.Roles)
+
+	_, err = d.Authenticate(ctx, "jsmith", "nope")
+	assert.ErrorIs(t, err, ErrDirectoryInvalidCredentials)
+
+	d = newStubDirectory(identity, map[string]string{"auditors": "viewer"}, "")
+	_, err = d.Authenticate(ctx, "jsmith", "pw")
+	assert.ErrorIs(t, err, ErrNoMappedRole)
+
+	d = newStubDirectory(identity, map[string]string{"auditors": "viewer"}, "guest")
+	id, err = d.Authenticate(ctx, "jsmith", "pw")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"guest"}, id.Roles)
+}
+
+func TestDirectory_AgainstLDAPServer(t *testing.T) {
+	srv := newTestLDAPServer(t, false)
+	seedLDAPUsers(srv)
+	d, err := NewDirectory(&DirectoryConfig{
+		Type:        DirectoryLDAP,
+		LDAP:        testLDAPConfig(srv),
+		TenantID:    "tenant-1",
+		RoleMapping: RoleMapping{GroupRoles: map[string]string{"CN=Storage-Admins, OU=Groups, DC=Example, DC=com": "admin"}},
+	})
+	require.NoError(t, err)
+	defer d.Close()
+
+	id, err := d.Authenticate(context.Background(), " jsmith ", "s3cret")
+	require.NoError(t, err)
+	assert.Equal(t, "5f3c1e2a-0000-4000-8000-000000000001", id.ID)
+	assert.Equal(t, "john.smith@example.com", id.Email)
+	assert.Equal(t, []string{"admin"}, id.Roles)
+
+	_, err = d.Authenticate(context.Background(), "jsmith", "wrong")
+	assert.ErrorIs(t, err, ErrDirectoryInvalidCredentials)
+
+	// A group of the same name elsewhere in the tree confers nothing.
+	srv.add("uid=mallory,ou=people,dc=example,dc=com", "s3cret", map[string][]string{
+		"objectClass": {"inetOrgPerson"},
+		"uid":         {"mallory"},
+		"mail":        {"mallory@example.com"},
+		"memberOf":    {"cn=storage-admins,ou=partners,dc=example,dc=com"},
+	})
+	_, err = d.Authenticate(context.Background(), "mallory", "s3cret")
+	assert.ErrorIs(t, err, ErrNoMappedRole)
+}
+
+func TestNormalizeGroupDN(t *testing.T) {
+	assert.Equal(t, "cn=storage admins,ou=groups,dc=example,dc=com", normalizeGroupDN("CN=Storage Admins, OU=Groups,DC=Example,DC=com"))
+	assert.Equal(t, normalizeGroupDN(`cn=Smith\, J,dc=example`), normalizeGroupDN(`CN=smith\2c j,DC=example`))
+	assert.Equal(t, normalizeGroupDN("cn=a+ou=b,dc=x"), normalizeGroupDN("OU=B+CN=A,DC=X"))
+	assert.NotEqual(t, normalizeGroupDN("cn=admins,ou=groups,dc=x"), normalizeGroupDN("cn=admins,ou=partners,dc=x"))
+}
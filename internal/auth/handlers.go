@@ -104,6 +104,9 @@ func (a *Auth) ValidateRequest(r *http.Request) (string, *KeyScope, error) {
 					zap.Error(err))
 				return "", nil, err
 			}
+			if err := cred.scope.CheckSessionToken(r.Header.Get("X-Amz-Security-Token")); err != nil {
+				return "", nil, err
+			}
 			// The signature proves the DECLARED payload hash is authentic;
 			// wrapping the body makes the received bytes live up to it.
 			if err := wrapPayloadVerification(r); err != nil {
@@ -169,7 +172,11 @@ func (a *Auth) lookupCredential(accessKey string) (*credential, error) {
 		a.logger.Debug("authenticated tenant (primary key)",
 			zap.String("tenant_id", tenantID),
 			zap.String("access_key", accessKey[:min(6, len(accessKey))]+"..."))
-		return &credential{tenantID, secretKey, &KeyScope{Permissions: []string{"*"}}}, nil
+		return &credential{tenantID, secretKey, &KeyScope{
+			Permissions: []string{"*"},
+			AccessKeyID: accessKey,
+			Primary:     true,
+		}}, nil
 	}
 	if err != sql.ErrNoRows {
 		a.logger.Error("database error during auth", zap.Error(err))
@@ -195,6 +202,7 @@ func (a *Auth) lookupCredential(accessKey string) (*credential, error) {
 		scope := &KeyScope{
 			BucketScope: []string(bucketScope),
 			IPAllowlist: []string(ipAllowlist),
+			AccessKeyID: accessKey,
 		}
 		if jsonErr := json.Unmarshal(permJSON, &scope.Permissions); jsonErr != nil {
 			scope.Permissions = []string{"*"}
@@ -219,19 +227,24 @@ func (a *Auth) lookupCredential(accessKey string) (*credential, error) {
 		var stsPermJSON []byte
 		var stsBucketScope, stsIPRestrict pq.StringArray
 		var stsExpiresAt time.Time
+		var parentKeyID, sessionToken string
 		err = a.db.QueryRow(`
-			SELECT tenant_id, COALESCE(secret_key, ''), permissions, bucket_scope, ip_restrict, expires_at
+			SELECT tenant_id, COALESCE(secret_key, ''), permissions, bucket_scope, ip_restrict, expires_at,
+			       COALESCE(parent_key_id, ''), COALESCE(session_token, '')
 			FROM sts_tokens WHERE access_key = $1
-		`, accessKey).Scan(&tenantID, &secretKey, &stsPermJSON, &stsBucketScope, &stsIPRestrict, &stsExpiresAt)
+		`, accessKey).Scan(&tenantID, &secretKey, &stsPermJSON, &stsBucketScope, &stsIPRestrict, &stsExpiresAt, &parentKeyID, &sessionToken)
 		if err == nil {
 			if time.Now().After(stsExpiresAt) {
 				a.logger.Debug("expired STS token", zap.String("access_key", accessKey[:min(6, len(accessKey))]+"..."))
 				return nil, fmt.Errorf("expired STS token")
 			}
 			scope := &KeyScope{
-				BucketScope: []string(stsBucketScope),
-				IPAllowlist: []string(stsIPRestrict),
-				ExpiresAt:   &stsExpiresAt,
+				BucketScope:  []string(stsBucketScope),
+				IPAllowlist:  []string(stsIPRestrict),
+				ExpiresAt:    &stsExpiresAt,
+				AccessKeyID:  accessKey,
+				ParentKeyID:  parentKeyID,
+				SessionToken: sessionToken,
 			}
 			if jsonErr := json.Unmarshal(stsPermJSON, &scope.Permissions); jsonErr != nil {
 				scope.Permissions = []string{"*"}
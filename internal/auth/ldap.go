@@ -5,8 +5,11 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sort"
 	"strings"
 	"time"
+
+	"github.com/go-ldap/ldap/v3"
 )
 
 // LDAPConfig configures the LDAP authentication provider
@@ -17,8 +20,12 @@ type LDAPConfig struct {
 	UseTLS             bool          `json:"use_tls"`
 	UseStartTLS        bool          `json:"use_starttls"`
 	InsecureSkipVerify bool          `json:"insecure_skip_verify"`
+	CACertFile         string        `json:"ca_cert_file"` // PEM roots for a private CA
 	Timeout            time.Duration `json:"timeout"`
 	MaxConnections     int           `json:"max_connections"`
+	// AllowPlaintext permits plain ldap:// without StartTLS, which sends
+	// bind passwords in the clear. Only for tests and trusted networks.
+	AllowPlaintext bool `json:"allow_plaintext"`
 
 	// Bind credentials (for searching)
 	BindDN       string `json:"bind_dn"`
@@ -69,6 +76,9 @@ func (c *LDAPConfig) Validate() error {
 	if c.BaseDN == "" {
 		return errors.New("ldap: base DN is required")
 	}
+	if !c.UseTLS && !c.UseStartTLS && !c.AllowPlaintext {
+		return errors.New("ldap: use_tls or use_starttls is required; set allow_plaintext to send passwords unencrypted")
+	}
 	return nil
 }
 
@@ -105,7 +115,8 @@ type LDAPUser struct {
 	DisplayName string
 	FirstName   string
 	LastName    string
-	Groups      []string
+	Groups      []string // group CNs, for display
+	GroupDNs    []string // group DNs as the directory returned them
 	RawAttrs    map[string][]string
 }
 
@@ -116,7 +127,9 @@ type LDAPAuthResult struct {
 	Username    string
 	Email       string
 	DisplayName string
-	Groups      []string
+	Groups      []string // group CNs, for display
+	GroupDNs    []string // group DNs; CNs are not unique, so roles map on these
+	EntryUUID   string   // RFC 4530 entryUUID, stable across renames; empty if the server lacks it
 	Error       string
 }
 
@@ -130,6 +143,7 @@ type ProviderInfo struct {
 // LDAPProvider handles LDAP authentication
 type LDAPProvider struct {
 	config *LDAPConfig
+	pool   *ldapPool
 }
 
 // NewLDAPProvider creates a new LDAP authentication provider
@@ -144,11 +158,33 @@ func NewLDAPProvider(config *LDAPConfig) (*LDAPProvider, error) {
 		return nil, err
 	}
 
+	pool, err := newLDAPPool(ldapConnConfig{
+		host:         config.Host,
+		port:         config.Port,
+		useTLS:       config.UseTLS,
+		startTLS:     config.UseStartTLS,
+		skipVerify:   config.InsecureSkipVerify,
+		caCertFile:   config.CACertFile,
+		timeout:      config.Timeout,
+		maxConns:     config.MaxConnections,
+		bindDN:       config.BindDN,
+		bindPassword: config.BindPassword,
+	})
+	if err != nil {
+		return nil, err
+	}
+
 	return &LDAPProvider{
 		config: config,
+		pool:   pool,
 	}, nil
 }
 
+// Close closes the provider's pooled connections.
+func (p *LDAPProvider) Close() {
+	p.pool.close()
+}
+
 // BuildUserDN constructs the full DN for a user
 func (p *LDAPProvider) BuildUserDN(username string) string {
 	attr := p.config.UserAttribute
@@ -206,6 +242,7 @@ func (p *LDAPProvider) MapAttributes(attrs map[string][]string) *LDAPUser {
 	}
 	if values, ok := attrs[mapping.Groups]; ok {
 		user.Groups = p.ExtractGroupNames(values)
+		user.GroupDNs = values
 	}
 
 	return user
@@ -232,17 +269,45 @@ func extractCNFromDN(dn string) string {
 		return dn
 	}
 
-	parts := strings.Split(dn, ",")
-	for _, part := range parts {
-		part = strings.TrimSpace(part)
-		if strings.HasPrefix(strings.ToLower(part), "cn=") {
-			return strings.TrimPrefix(part, "cn=")
+	// ParseDN handles escaped separators (CN=Smith\, John) and AD's
+	// upper-case attribute types.
+	parsed, err := ldap.ParseDN(dn)
+	if err != nil {
+		return dn
+	}
+	for _, rdn := range parsed.RDNs {
+		for _, attr := range rdn.Attributes {
+			if strings.EqualFold(attr.Type, "cn") {
+				return attr.Value
+			}
 		}
 	}
 
 	return dn
 }
 
+// normalizeGroupDN canonicalizes a group DN for comparison: attribute
+// types and values lower-cased, whitespace around separators dropped and
+// special characters re-escaped. Values that are not DNs are only
+// lower-cased.
+func normalizeGroupDN(dn string) string {
+	parsed, err := ldap.ParseDN(dn)
+	if err != nil || !strings.Contains(dn, "=") {
+		return strings.ToLower(strings.TrimSpace(dn))
+	}
+	escape := strings.NewReplacer(`\`, `\\`, `,`, `\,`, `+`, `\+`, `=`, `\=`, `"`, `\"`, `<`, `\<`, `>`, `\>`, `;`, `\;`)
+	rdns := make([]string, 0, len(parsed.RDNs))
+	for _, rdn := range parsed.RDNs {
+		attrs := make([]string, 0, len(rdn.Attributes))
+		for _, attr := range rdn.Attributes {
+			attrs = append(attrs, strings.ToLower(attr.Type)+"="+escape.Replace(strings.ToLower(attr.Value)))
+		}
+		sort.Strings(attrs)
+		rdns = append(rdns, strings.Join(attrs, "+"))
+	}
+	return strings.Join(rdns, ",")
+}
+
 // MaxConnections returns the maximum number of connections
 func (p *LDAPProvider) MaxConnections() int {
 	if p.config.MaxConnections == 0 {
@@ -283,19 +348,54 @@ func (p *LDAPProvider) Info() ProviderInfo {
 	}
 }
 
-// Authenticate attempts to authenticate a user against LDAP
+// Authenticate attempts to authenticate a user against LDAP. It searches
+// for the user as the service account (anonymously when BindDN is empty)
+// and then binds as the entry found. A wrong password or unknown user is
+// an unsuccessful result, not an error; errors mean the directory could
+// not be queried.
 func (p *LDAPProvider) Authenticate(ctx context.Context, username, password string) (*LDAPAuthResult, error) {
 	if username == "" {
 		return nil, errors.New("ldap: username is required")
 	}
+	// An empty password would be an unauthenticated bind, which servers
+	// accept for any DN (RFC 4513 §5.1.2).
 	if password == "" {
 		return nil, errors.New("ldap: password is required")
 	}
 
-	// Note: Actual LDAP connection would go here
-	// For now, return error indicating no real connection
-	// In production, use github.com/go-ldap/ldap/v3
-	return nil, errors.New("ldap: connection not implemented (requires go-ldap/ldap)")
+	search := ldap.NewSearchRequest(p.SearchBase(), ldap.ScopeWholeSubtree, ldap.NeverDerefAliases,
+		2, int(p.Timeout().Seconds()), false, p.BuildSearchFilter(username),
+		append(p.GetSearchAttributes(), p.config.UserAttribute, "entryUUID"), nil)
+	entry, err := p.pool.bindUser(ctx, search, password, nil, nil)
+	if errors.Is(err, ErrDirectoryInvalidCredentials) {
+		return &LDAPAuthResult{Success: false, Error: err.Error()}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	user := p.MapAttributes(entryAttributes(entry))
+	if name := entry.GetAttributeValue(p.config.UserAttribute); name != "" {
+		username = name
+	}
+	return &LDAPAuthResult{
+		Success:     true,
+		UserDN:      entry.DN,
+		Username:    username,
+		Email:       user.Email,
+		DisplayName: user.DisplayName,
+		Groups:      user.Groups,
+		GroupDNs:    user.GroupDNs,
+		EntryUUID:   entry.GetAttributeValue("entryUUID"),
+	}, nil
+}
+
+// SearchBase returns the DN user searches start from.
+func (p *LDAPProvider) SearchBase() string {
+	if p.config.UserSearchBase != "" {
+		return p.config.UserSearchBase + "," + p.config.BaseDN
+	}
+	return p.config.BaseDN
 }
 
 // EscapeLDAPFilter escapes special characters in LDAP filter values
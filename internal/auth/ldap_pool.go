@@ -0,0 +1,248 @@
+// internal/auth/ldap_pool.go
+package auth
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+// Directory login failures. Providers report them in their result's Error
+// field; callers should show users one generic message for all of them.
+var (
+	ErrDirectoryInvalidCredentials = errors.New("invalid credentials")
+	ErrDirectoryAccountDisabled    = errors.New("account disabled")
+	ErrDirectoryAccountLocked      = errors.New("account locked")
+	ErrDirectoryPasswordExpired    = errors.New("password expired")
+)
+
+// ldapConnConfig holds the connection settings the LDAP and Active
+// Directory providers share.
+type ldapConnConfig struct {
+	host         string
+	port         int
+	useTLS       bool
+	startTLS     bool
+	skipVerify   bool
+	caCertFile   string
+	timeout      time.Duration
+	maxConns     int
+	bindDN       string
+	bindPassword string
+}
+
+// ldapPool is a bounded pool of connections bound as the service account.
+// At most maxConns connections are open at once; callers beyond that wait
+// for one to be returned.
+type ldapPool struct {
+	cfg       ldapConnConfig
+	tlsConfig *tls.Config
+	slots     chan struct{}
+
+	mu     sync.Mutex
+	idle   []*ldap.Conn
+	closed bool
+}
+
+func newLDAPPool(cfg ldapConnConfig) (*ldapPool, error) {
+	tlsConfig := &tls.Config{
+		ServerName:         cfg.host,
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: cfg.skipVerify, // #nosec G402 -- explicit opt-in for test directories
+	}
+	if cfg.caCertFile != "" {
+		pem, err := os.ReadFile(cfg.caCertFile)
+		if err != nil {
+			return nil, fmt.Errorf("ldap: read CA certificate: %w", err)
+		}
+		roots := x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("ldap: no certificates in %s", cfg.caCertFile)
+		}
+		tlsConfig.RootCAs = roots
+	}
+	if cfg.maxConns <= 0 {
+		cfg.maxConns = 10
+	}
+	return &ldapPool{
+		cfg:       cfg,
+		tlsConfig: tlsConfig,
+		slots:     make(chan struct{}, cfg.maxConns),
+	}, nil
+}
+
+// get returns a connection bound as the service account.
+func (p *ldapPool) get(ctx context.Context) (*ldap.Conn, error) {
+	select {
+	case p.slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	p.mu.Lock()
+	for len(p.idle) > 0 {
+		conn := p.idle[len(p.idle)-1]
+		p.idle = p.idle[:len(p.idle)-1]
+		if !conn.IsClosing() {
+			p.mu.Unlock()
+			return conn, nil
+		}
+		conn.Close()
+	}
+	p.mu.Unlock()
+
+	conn, err := p.dial()
+	if err != nil {
+		<-p.slots
+		return nil, err
+	}
+	if err := p.bindService(conn); err != nil {
+		conn.Close()
+		<-p.slots
+		return nil, err
+	}
+	return conn, nil
+}
+
+// put returns conn to the pool. A connection last bound as a user is
+// rebound as the service account first, and closed if that fails.
+func (p *ldapPool) put(conn *ldap.Conn, rebind bool) {
+	defer func() { <-p.slots }()
+	if rebind {
+		if err := p.bindService(conn); err != nil {
+			conn.Close()
+			return
+		}
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed || conn.IsClosing() {
+		conn.Close()
+		return
+	}
+	p.idle = append(p.idle, conn)
+}
+
+// discard closes conn, which is in an unknown state after an error.
+func (p *ldapPool) discard(conn *ldap.Conn) {
+	conn.Close()
+	<-p.slots
+}
+
+// close closes idle connections; connections in use close when returned.
+func (p *ldapPool) close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closed = true
+	for _, conn := range p.idle {
+		conn.Close()
+	}
+	p.idle = nil
+}
+
+func (p *ldapPool) dial() (*ldap.Conn, error) {
+	addr := net.JoinHostPort(p.cfg.host, strconv.Itoa(p.cfg.port))
+	scheme := "ldap://"
+	if p.cfg.useTLS {
+		scheme = "ldaps://"
+	}
+	conn, err := ldap.DialURL(scheme+addr,
+		ldap.DialWithDialer(&net.Dialer{Timeout: p.cfg.timeout}),
+		ldap.DialWithTLSConfig(p.tlsConfig))
+	if err != nil {
+		return nil, fmt.Errorf("ldap: dial %s: %w", addr, err)
+	}
+	conn.SetTimeout(p.cfg.timeout)
+	if p.cfg.startTLS && !p.cfg.useTLS {
+		if err := conn.StartTLS(p.tlsConfig); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("ldap: StartTLS: %w", err)
+		}
+	}
+	return conn, nil
+}
+
+func (p *ldapPool) bindService(conn *ldap.Conn) error {
+	var err error
+	if p.cfg.bindDN == "" {
+		err = conn.UnauthenticatedBind("")
+	} else {
+		err = conn.Bind(p.cfg.bindDN, p.cfg.bindPassword)
+	}
+	if err != nil {
+		return fmt.Errorf("ldap: service account bind: %w", err)
+	}
+	return nil
+}
+
+// bindUser finds the one entry search matches and binds as it with
+// password. check, when non-nil, vets the entry before the bind; then,
+// when non-nil, runs afterwards on the connection rebound as the service
+// account (for lookups the user may not be allowed to make).
+func (p *ldapPool) bindUser(ctx context.Context, search *ldap.SearchRequest, password string,
+	check func(*ldap.Entry) error, then func(*ldap.Conn, *ldap.Entry) error) (*ldap.Entry, error) {
+	conn, err := p.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := conn.Search(search)
+	if err != nil && !ldap.IsErrorWithCode(err, ldap.LDAPResultSizeLimitExceeded) {
+		p.discard(conn)
+		return nil, fmt.Errorf("ldap: search: %w", err)
+	}
+	// Unknown and ambiguous usernames fail like a wrong password.
+	if err != nil || len(res.Entries) != 1 {
+		p.put(conn, false)
+		return nil, ErrDirectoryInvalidCredentials
+	}
+	entry := res.Entries[0]
+	if check != nil {
+		if err := check(entry); err != nil {
+			p.put(conn, false)
+			return nil, err
+		}
+	}
+
+	if err := conn.Bind(entry.DN, password); err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			p.put(conn, true)
+			return nil, ErrDirectoryInvalidCredentials
+		}
+		p.discard(conn)
+		return nil, fmt.Errorf("ldap: bind: %w", err)
+	}
+
+	if then == nil {
+		p.put(conn, true)
+		return entry, nil
+	}
+	if err := p.bindService(conn); err != nil {
+		p.discard(conn)
+		return nil, err
+	}
+	if err := then(conn, entry); err != nil {
+		p.discard(conn)
+		return nil, err
+	}
+	p.put(conn, false)
+	return entry, nil
+}
+
+// entryAttributes flattens an entry's attributes for MapAttributes.
+func entryAttributes(entry *ldap.Entry) map[string][]string {
+	attrs := make(map[string][]string, len(entry.Attributes))
+	for _, a := range entry.Attributes {
+		attrs[a.Name] = a.Values
+	}
+	return attrs
+}
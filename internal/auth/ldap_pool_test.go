@@ -0,0 +1,547 @@
+// internal/auth/ldap_pool_test.go
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"io"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// LDAP protocol operations (RFC 4511 §4.2) the test server speaks.
+const (
+	ldapOpBind          = 0
+	ldapOpBindResponse  = 1
+	ldapOpUnbind        = 2
+	ldapOpSearch        = 3
+	ldapOpSearchEntry   = 4
+	ldapOpSearchDone    = 5
+	ldapOpExtended      = 23
+	ldapOpExtendedReply = 24
+	ldapStartTLSOID     = "1.3.6.1.4.1.1466.20037"
+)
+
+type testLDAPEntry struct {
+	dn       string
+	password string
+	attrs    map[string][]string
+}
+
+// testLDAPServer is an in-process directory. Only the service account may
+// search, so a connection left bound as a user fails its next search.
+type testLDAPServer struct {
+	t         *testing.T
+	ln        net.Listener
+	tlsConfig *tls.Config // StartTLS, or every connection when ldaps
+	caFile    string
+	serviceDN string
+	servicePw string
+
+	mu      sync.Mutex
+	entries []testLDAPEntry
+
+	accepted   atomic.Int32
+	open       atomic.Int32
+	maxOpen    atomic.Int32
+	userBinds  atomic.Int32
+	searchHold time.Duration
+}
+
+func newTestLDAPServer(t *testing.T, ldaps bool) *testLDAPServer {
+	t.Helper()
+	cert, caFile := newTestLDAPCert(t)
+	s := &testLDAPServer{
+		t:         t,
+		tlsConfig: &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12},
+		caFile:    caFile,
+		serviceDN: "cn=svc,dc=example,dc=com",
+		servicePw: "svc-secret",
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	if ldaps {
+		ln = tls.NewListener(ln, s.tlsConfig)
+	}
+	s.ln = ln
+	t.Cleanup(func() { _ = ln.Close() })
+	go s.serve()
+	return s
+}
+
+func (s *testLDAPServer) port() int {
+	return s.ln.Addr().(*net.TCPAddr).Port
+}
+
+func (s *testLDAPServer) add(dn, password string, attrs map[string][]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries = append(s.entries, testLDAPEntry{dn: dn, password: password, attrs: attrs})
+}
+
+func (s *testLDAPServer) serve() {
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		s.accepted.Add(1)
+		n := s.open.Add(1)
+		for {
+			m := s.maxOpen.Load()
+			if n <= m || s.maxOpen.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		go func() {
+			defer s.open.Add(-1)
+			s.handle(conn)
+		}()
+	}
+}
+
+func (s *testLDAPServer) handle(conn net.Conn) {
+	defer func() { _ = conn.Close() }()
+	bound := ""
+	for {
+		packet, err := ber.ReadPacket(conn)
+		if err != nil {
+			return
+		}
+		msgID := packet.Children[0].Value.(int64)
+		op := packet.Children[1]
+		switch op.Tag {
+		case ldapOpBind:
+			dn := berString(op.Children[1])
+			password := berString(op.Children[2])
+			code := 49
+			if dn == "" && password == "" {
+				bound, code = "", 0
+			} else if dn == s.serviceDN && password == s.servicePw {
+				bound, code = dn, 0
+			} else if e := s.lookup(dn); e != nil {
+				s.userBinds.Add(1)
+				if e.password == password {
+					bound, code = e.dn, 0
+				}
+			}
+			if code != 0 {
+				bound = ""
+			}
+			s.reply(conn, msgID, ldapOpBindResponse, code)
+		case ldapOpUnbind:
+			return
+		case ldapOpSearch:
+			if s.searchHold > 0 {
+				time.Sleep(s.searchHold)
+			}
+			if bound != s.serviceDN {
+				s.reply(conn, msgID, ldapOpSearchDone, 50) // insufficientAccessRights
+				continue
+			}
+			base := strings.ToLower(berString(op.Children[0]))
+			sizeLimit := int(op.Children[3].Value.(int64))
+			var matched []testLDAPEntry
+			s.mu.Lock()
+			for _, e := range s.entries {
+				if strings.HasSuffix(strings.ToLower(e.dn), base) && s.match(op.Children[6], e) {
+					matched = append(matched, e)
+				}
+			}
+			s.mu.Unlock()
+			code := 0
+			if sizeLimit > 0 && len(matched) > sizeLimit {
+				matched, code = matched[:sizeLimit], 4 // sizeLimitExceeded
+			}
+			for _, e := range matched {
+				s.writeEntry(conn, msgID, e)
+			}
+			s.reply(conn, msgID, ldapOpSearchDone, code)
+		case ldapOpExtended:
+			if berString(op.Children[0]) != ldapStartTLSOID {
+				s.reply(conn, msgID, ldapOpExtendedReply, 2) // protocolError
+				continue
+			}
+			s.reply(conn, msgID, ldapOpExtendedReply, 0)
+			tlsConn := tls.Server(conn, s.tlsConfig)
+			if err := tlsConn.Handshake(); err != nil {
+				return
+			}
+			conn = tlsConn
+		}
+	}
+}
+
+func (s *testLDAPServer) lookup(dn string) *testLDAPEntry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.entries {
+		if strings.EqualFold(s.entries[i].dn, dn) {
+			return &s.entries[i]
+		}
+	}
+	return nil
+}
+
+// match evaluates the filters the providers send: and, or, not,
+// equality, presence, and AD's LDAP_MATCHING_RULE_IN_CHAIN on member.
+func (s *testLDAPServer) match(f *ber.Packet, e testLDAPEntry) bool {
+	values := func(attr string) []string {
+		for k, v := range e.attrs {
+			if strings.EqualFold(k, attr) {
+				return v
+			}
+		}
+		return nil
+	}
+	switch f.Tag {
+	case 0: // and
+		for _, c := range f.Children {
+			if !s.match(c, e) {
+				return false
+			}
+		}
+		return true
+	case 1: // or
+		for _, c := range f.Children {
+			if s.match(c, e) {
+				return true
+			}
+		}
+		return false
+	case 2: // not
+		return !s.match(f.Children[0], e)
+	case 3: // equalityMatch
+		want := berString(f.Children[1])
+		for _, v := range values(berString(f.Children[0])) {
+			if strings.EqualFold(v, want) {
+				return true
+			}
+		}
+		return false
+	case 7: // present
+		return len(values(f.Data.String())) > 0
+	case 9: // extensibleMatch
+		var rule, attr, value string
+		for _, c := range f.Children {
+			switch c.Tag {
+			case 1:
+				rule = berString(c)
+			case 2:
+				attr = berString(c)
+			case 3:
+				value = berString(c)
+			}
+		}
+		if rule != ADNestedGroupOID || !strings.EqualFold(attr, "member") {
+			return false
+		}
+		return s.memberInChain(e, value, map[string]bool{})
+	}
+	return false
+}
+
+func (s *testLDAPServer) memberInChain(group testLDAPEntry, dn string, seen map[string]bool) bool {
+	key := strings.ToLower(group.dn)
+	if seen[key] {
+		return false
+	}
+	seen[key] = true
+	for _, m := range group.attrs["member"] {
+		if strings.EqualFold(m, dn) {
+			return true
+		}
+		for _, e := range s.entries {
+			if strings.EqualFold(e.dn, m) && s.memberInChain(e, dn, seen) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (s *testLDAPServer) writeEntry(w io.Writer, msgID int64, e testLDAPEntry) {
+	op := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ldapOpSearchEntry, nil, "")
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, e.dn, ""))
+	attrs := ber.NewSequence("")
+	for name, vals := range e.attrs {
+		attr := ber.NewSequence("")
+		attr.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, name, ""))
+		set := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "")
+		for _, v := range vals {
+			set.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, v, ""))
+		}
+		attr.AppendChild(set)
+		attrs.AppendChild(attr)
+	}
+	op.AppendChild(attrs)
+	s.write(w, msgID, op)
+}
+
+func (s *testLDAPServer) reply(w io.Writer, msgID int64, tag ber.Tag, code int) {
+	op := ber.Encode(ber.ClassApplication, ber.TypeConstructed, tag, nil, "")
+	op.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, int64(code), ""))
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", ""))
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", ""))
+	s.write(w, msgID, op)
+}
+
+func (s *testLDAPServer) write(w io.Writer, msgID int64, op *ber.Packet) {
+	msg := ber.NewSequence("")
+	msg.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, msgID, ""))
+	msg.AppendChild(op)
+	_, _ = w.Write(msg.Bytes())
+}
+
+func berString(p *ber.Packet) string {
+	if s, ok := p.Value.(string); ok {
+		return s
+	}
+	return p.Data.String()
+}
+
+// newTestLDAPCert returns a certificate for 127.0.0.1 and the path of a
+// PEM file holding it, for use as CACertFile.
+func newTestLDAPCert(t *testing.T) (tls.Certificate, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test directory"},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	require.NoError(t, os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, caFile
+}
+
+// seedLDAPUsers adds jsmith (password "s3cret") in two groups.
+func seedLDAPUsers(s *testLDAPServer) {
+	s.add("uid=jsmith,ou=people,dc=example,dc=com", "s3cret", map[string][]string{
+		"objectClass": {"inetOrgPerson"},
+		"uid":         {"jsmith"},
+		"mail":        {"John.Smith@Example.com"},
+		"displayName": {"John Smith"},
+		"entryUUID":   {"5f3c1e2a-0000-4000-8000-000000000001"},
+		"memberOf": {
+			"cn=storage-admins,ou=groups,dc=example,dc=com",
+			"cn=Smith\\, J,ou=groups,dc=example,dc=com",
+		},
+	})
+}
+
+func testLDAPConfig(s *testLDAPServer) *LDAPConfig {
+	return &LDAPConfig{
+		AllowPlaintext: true,
+		Host:           "127.0.0.1",
+		Port:           s.port(),
+		BaseDN:         "dc=example,dc=com",
+		BindDN:         s.serviceDN,
+		BindPassword:   s.servicePw,
+		UserAttribute:  "uid",
+		Timeout:        5 * time.Second,
+	}
+}
+
+func TestLDAPProvider_AuthenticateAgainstServer(t *testing.T) {
+	srv := newTestLDAPServer(t, false)
+	seedLDAPUsers(srv)
+	p, err := NewLDAPProvider(testLDAPConfig(srv))
+	require.NoError(t, err)
+	defer p.Close()
+	ctx := context.Background()
+
+	res, err := p.Authenticate(ctx, "jsmith", "s3cret")
+	require.NoError(t, err)
+	assert.True(t, res.Success)
+	assert.Equal(t, "uid=jsmith,ou=people,dc=example,dc=com", res.UserDN)
+	assert.Equal(t, "jsmith", res.Username)
+	assert.Equal(t, "John.Smith@Example.com", res.Email)
+	assert.Equal(t, "John Smith", res.DisplayName)
+	assert.Equal(t, "5f3c1e2a-0000-4000-8000-000000000001", res.EntryUUID)
+	assert.ElementsMatch(t, []string{"storage-admins", "Smith, J"}, res.Groups)
+
+	res, err = p.Authenticate(ctx, "jsmith", "wrong")
+	require.NoError(t, err)
+	assert.False(t, res.Success)
+	assert.Equal(t, ErrDirectoryInvalidCredentials.Error(), res.Error)
+
+	res, err = p.Authenticate(ctx, "nobody", "s3cret")
+	require.NoError(t, err)
+	assert.False(t, res.Success)
+
+	// A filter-injection attempt matches nothing rather than everyone.
+	res, err = p.Authenticate(ctx, "*", "s3cret")
+	require.NoError(t, err)
+	assert.False(t, res.Success)
+
+	// The connection survives a user bind: it is rebound as the service
+	// account before reuse, or the server would refuse the next search.
+	res, err = p.Authenticate(ctx, "jsmith", "s3cret")
+	require.NoError(t, err)
+	assert.True(t, res.Success)
+	assert.Equal(t, int32(1), srv.accepted.Load())
+}
+
+func TestLDAPProvider_AmbiguousUser(t *testing.T) {
+	srv := newTestLDAPServer(t, false)
+	seedLDAPUsers(srv)
+	srv.add("uid=jsmith,ou=contractors,dc=example,dc=com", "s3cret", map[string][]string{"uid": {"jsmith"}})
+	p, err := NewLDAPProvider(testLDAPConfig(srv))
+	require.NoError(t, err)
+	defer p.Close()
+
+	res, err := p.Authenticate(context.Background(), "jsmith", "s3cret")
+	require.NoError(t, err)
+	assert.False(t, res.Success)
+	assert.Zero(t, srv.userBinds.Load(), "no bind is attempted for an ambiguous name")
+}
+
+func TestLDAPProvider_TLS(t *testing.T) {
+	t.Run("LDAPS", func(t *testing.T) {
+		srv := newTestLDAPServer(t, true)
+		seedLDAPUsers(srv)
+		cfg := testLDAPConfig(srv)
+		cfg.UseTLS = true
+		cfg.CACertFile = srv.caFile
+		p, err := NewLDAPProvider(cfg)
+		require.NoError(t, err)
+		defer p.Close()
+		assert.Equal(t, "ldaps://127.0.0.1:"+strconv.Itoa(srv.port()), p.ConnectionString())
+
+		res, err := p.Authenticate(context.Background(), "jsmith", "s3cret")
+		require.NoError(t, err)
+		assert.True(t, res.Success)
+	})
+
+	t.Run("StartTLS", func(t *testing.T) {
+		srv := newTestLDAPServer(t, false)
+		seedLDAPUsers(srv)
+		cfg := testLDAPConfig(srv)
+		cfg.UseStartTLS = true
+		cfg.CACertFile = srv.caFile
+		p, err := NewLDAPProvider(cfg)
+		require.NoError(t, err)
+		defer p.Close()
+
+		res, err := p.Authenticate(context.Background(), "jsmith", "s3cret")
+		require.NoError(t, err)
+		assert.True(t, res.Success)
+	})
+
+	t.Run("untrusted certificate", func(t *testing.T) {
+		srv := newTestLDAPServer(t, false)
+		seedLDAPUsers(srv)
+		cfg := testLDAPConfig(srv)
+		cfg.UseStartTLS = true
+		p, err := NewLDAPProvider(cfg)
+		require.NoError(t, err)
+		defer p.Close()
+
+		_, err = p.Authenticate(context.Background(), "jsmith", "s3cret")
+		assert.Error(t, err)
+	})
+
+	t.Run("bad CA file", func(t *testing.T) {
+		cfg := &LDAPConfig{Host: "127.0.0.1", BaseDN: "dc=example,dc=com", CACertFile: filepath.Join(t.TempDir(), "missing.pem")}
+		_, err := NewLDAPProvider(cfg)
+		assert.Error(t, err)
+	})
+}
+
+func TestLDAPProvider_ServiceAccountRejected(t *testing.T) {
+	srv := newTestLDAPServer(t, false)
+	seedLDAPUsers(srv)
+	cfg := testLDAPConfig(srv)
+	cfg.BindPassword = "wrong"
+	p, err := NewLDAPProvider(cfg)
+	require.NoError(t, err)
+	defer p.Close()
+
+	// A misconfigured service account is an error, not a failed login.
+	_, err = p.Authenticate(context.Background(), "jsmith", "s3cret")
+	assert.Error(t, err)
+}
+
+func TestLDAPPool_MaxConnections(t *testing.T) {
+	srv := newTestLDAPServer(t, false)
+	seedLDAPUsers(srv)
+	srv.searchHold = 20 * time.Millisecond
+	cfg := testLDAPConfig(srv)
+	cfg.MaxConnections = 2
+	p, err := NewLDAPProvider(cfg)
+	require.NoError(t, err)
+	defer p.Close()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res, err := p.Authenticate(context.Background(), "jsmith", "s3cret")
+			if err == nil && !res.Success {
+				err = errors.New(res.Error)
+			}
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		assert.NoError(t, err)
+	}
+	assert.LessOrEqual(t, srv.maxOpen.Load(), int32(2))
+	assert.LessOrEqual(t, srv.accepted.Load(), int32(2), "connections are reused")
+}
+
+func TestLDAPPool_WaitHonorsContext(t *testing.T) {
+	srv := newTestLDAPServer(t, false)
+	pool, err := newLDAPPool(ldapConnConfig{
+		host: "127.0.0.1", port: srv.port(), timeout: time.Second, maxConns: 1,
+		bindDN: srv.serviceDN, bindPassword: srv.servicePw,
+	})
+	require.NoError(t, err)
+	defer pool.close()
+
+	conn, err := pool.get(context.Background())
+	require.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err = pool.get(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	pool.put(conn, false)
+
+	conn, err = pool.get(context.Background())
+	require.NoError(t, err)
+	pool.put(conn, false)
+	assert.Equal(t, int32(1), srv.accepted.Load())
+}
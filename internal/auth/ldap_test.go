@@ -13,11 +13,12 @@ import (
 func TestLDAPConfig_Validate(t *testing.T) {
 	t.Run("valid config passes validation", func(t *testing.T) {
 		config := &LDAPConfig{
-			Host:         "ldap.example.com",
-			Port:         389,
-			BaseDN:       "dc=example,dc=com",
-			BindDN:       "cn=admin,dc=example,dc=com",
-			BindPassword: "secret",
+			AllowPlaintext: true,
+			Host:           "ldap.example.com",
+			Port:           389,
+			BaseDN:         "dc=example,dc=com",
+			BindDN:         "cn=admin,dc=example,dc=com",
+			BindPassword:   "secret",
 		}
 		err := config.Validate()
 		assert.NoError(t, err)
@@ -25,8 +26,9 @@ func TestLDAPConfig_Validate(t *testing.T) {
 
 	t.Run("rejects empty host", func(t *testing.T) {
 		config := &LDAPConfig{
-			Port:   389,
-			BaseDN: "dc=example,dc=com",
+			AllowPlaintext: true,
+			Port:           389,
+			BaseDN:         "dc=example,dc=com",
 		}
 		err := config.Validate()
 		assert.Error(t, err)
@@ -35,8 +37,9 @@ func TestLDAPConfig_Validate(t *testing.T) {
 
 	t.Run("rejects empty BaseDN", func(t *testing.T) {
 		config := &LDAPConfig{
-			Host: "ldap.example.com",
-			Port: 389,
+			AllowPlaintext: true,
+			Host:           "ldap.example.com",
+			Port:           389,
 		}
 		err := config.Validate()
 		assert.Error(t, err)
@@ -45,8 +48,9 @@ func TestLDAPConfig_Validate(t *testing.T) {
 
 	t.Run("defaults port to 389", func(t *testing.T) {
 		config := &LDAPConfig{
-			Host:   "ldap.example.com",
-			BaseDN: "dc=example,dc=com",
+			AllowPlaintext: true,
+			Host:           "ldap.example.com",
+			BaseDN:         "dc=example,dc=com",
 		}
 		config.ApplyDefaults()
 		assert.Equal(t, 389, config.Port)
@@ -66,11 +70,12 @@ func TestLDAPConfig_Validate(t *testing.T) {
 func TestNewLDAPProvider(t *testing.T) {
 	t.Run("creates provider with valid config", func(t *testing.T) {
 		config := &LDAPConfig{
-			Host:         "ldap.example.com",
-			Port:         389,
-			BaseDN:       "dc=example,dc=com",
-			BindDN:       "cn=admin,dc=example,dc=com",
-			BindPassword: "secret",
+			AllowPlaintext: true,
+			Host:           "ldap.example.com",
+			Port:           389,
+			BaseDN:         "dc=example,dc=com",
+			BindDN:         "cn=admin,dc=example,dc=com",
+			BindPassword:   "secret",
 		}
 		provider, err := NewLDAPProvider(config)
 		require.NoError(t, err)
@@ -93,6 +98,7 @@ func TestNewLDAPProvider(t *testing.T) {
 
 func TestLDAPProvider_BuildUserDN(t *testing.T) {
 	config := &LDAPConfig{
+		AllowPlaintext: true,
 		Host:           "ldap.example.com",
 		Port:           389,
 		BaseDN:         "dc=example,dc=com",
@@ -108,6 +114,7 @@ func TestLDAPProvider_BuildUserDN(t *testing.T) {
 
 	t.Run("uses cn as default attribute", func(t *testing.T) {
 		config2 := &LDAPConfig{
+			AllowPlaintext: true,
 			Host:           "ldap.example.com",
 			Port:           389,
 			BaseDN:         "dc=example,dc=com",
@@ -122,10 +129,11 @@ func TestLDAPProvider_BuildUserDN(t *testing.T) {
 func TestLDAPProvider_BuildSearchFilter(t *testing.T) {
 	t.Run("builds default filter", func(t *testing.T) {
 		config := &LDAPConfig{
-			Host:          "ldap.example.com",
-			Port:          389,
-			BaseDN:        "dc=example,dc=com",
-			UserAttribute: "uid",
+			AllowPlaintext: true,
+			Host:           "ldap.example.com",
+			Port:           389,
+			BaseDN:         "dc=example,dc=com",
+			UserAttribute:  "uid",
 		}
 		provider, _ := NewLDAPProvider(config)
 		filter := provider.BuildSearchFilter("jsmith")
@@ -134,10 +142,11 @@ func TestLDAPProvider_BuildSearchFilter(t *testing.T) {
 
 	t.Run("uses custom filter template", func(t *testing.T) {
 		config := &LDAPConfig{
-			Host:         "ldap.example.com",
-			Port:         389,
-			BaseDN:       "dc=example,dc=com",
-			SearchFilter: "(&(objectClass=person)(sAMAccountName=%s))",
+			AllowPlaintext: true,
+			Host:           "ldap.example.com",
+			Port:           389,
+			BaseDN:         "dc=example,dc=com",
+			SearchFilter:   "(&(objectClass=person)(sAMAccountName=%s))",
 		}
 		provider, _ := NewLDAPProvider(config)
 		filter := provider.BuildSearchFilter("jsmith")
@@ -146,10 +155,11 @@ func TestLDAPProvider_BuildSearchFilter(t *testing.T) {
 
 	t.Run("escapes special characters", func(t *testing.T) {
 		config := &LDAPConfig{
-			Host:          "ldap.example.com",
-			Port:          389,
-			BaseDN:        "dc=example,dc=com",
-			UserAttribute: "uid",
+			AllowPlaintext: true,
+			Host:           "ldap.example.com",
+			Port:           389,
+			BaseDN:         "dc=example,dc=com",
+			UserAttribute:  "uid",
 		}
 		provider, _ := NewLDAPProvider(config)
 		filter := provider.BuildSearchFilter("user*with(special)chars")
@@ -162,9 +172,10 @@ func TestLDAPProvider_BuildSearchFilter(t *testing.T) {
 func TestLDAPProvider_AttributeMapping(t *testing.T) {
 	t.Run("maps LDAP attributes to user fields", func(t *testing.T) {
 		config := &LDAPConfig{
-			Host:   "ldap.example.com",
-			Port:   389,
-			BaseDN: "dc=example,dc=com",
+			AllowPlaintext: true,
+			Host:           "ldap.example.com",
+			Port:           389,
+			BaseDN:         "dc=example,dc=com",
 			AttributeMapping: AttributeMapping{
 				Email:       "mail",
 				DisplayName: "displayName",
@@ -193,9 +204,10 @@ func TestLDAPProvider_AttributeMapping(t *testing.T) {
 
 	t.Run("handles missing attributes", func(t *testing.T) {
 		config := &LDAPConfig{
-			Host:   "ldap.example.com",
-			Port:   389,
-			BaseDN: "dc=example,dc=com",
+			AllowPlaintext: true,
+			Host:           "ldap.example.com",
+			Port:           389,
+			BaseDN:         "dc=example,dc=com",
 			AttributeMapping: AttributeMapping{
 				Email:       "mail",
 				DisplayName: "displayName",
@@ -216,9 +228,10 @@ func TestLDAPProvider_AttributeMapping(t *testing.T) {
 
 func TestLDAPProvider_GroupExtraction(t *testing.T) {
 	config := &LDAPConfig{
-		Host:   "ldap.example.com",
-		Port:   389,
-		BaseDN: "dc=example,dc=com",
+		AllowPlaintext: true,
+		Host:           "ldap.example.com",
+		Port:           389,
+		BaseDN:         "dc=example,dc=com",
 	}
 	provider, _ := NewLDAPProvider(config)
 
@@ -243,6 +256,7 @@ func TestLDAPProvider_GroupExtraction(t *testing.T) {
 func TestLDAPProvider_ConnectionPool(t *testing.T) {
 	t.Run("respects max connections", func(t *testing.T) {
 		config := &LDAPConfig{
+			AllowPlaintext: true,
 			Host:           "ldap.example.com",
 			Port:           389,
 			BaseDN:         "dc=example,dc=com",
@@ -254,9 +268,10 @@ func TestLDAPProvider_ConnectionPool(t *testing.T) {
 
 	t.Run("defaults to 10 connections", func(t *testing.T) {
 		config := &LDAPConfig{
-			Host:   "ldap.example.com",
-			Port:   389,
-			BaseDN: "dc=example,dc=com",
+			AllowPlaintext: true,
+			Host:           "ldap.example.com",
+			Port:           389,
+			BaseDN:         "dc=example,dc=com",
 		}
 		provider, _ := NewLDAPProvider(config)
 		assert.Equal(t, 10, provider.MaxConnections())
@@ -266,10 +281,11 @@ func TestLDAPProvider_ConnectionPool(t *testing.T) {
 func TestLDAPProvider_Timeout(t *testing.T) {
 	t.Run("respects configured timeout", func(t *testing.T) {
 		config := &LDAPConfig{
-			Host:    "ldap.example.com",
-			Port:    389,
-			BaseDN:  "dc=example,dc=com",
-			Timeout: 5 * time.Second,
+			AllowPlaintext: true,
+			Host:           "ldap.example.com",
+			Port:           389,
+			BaseDN:         "dc=example,dc=com",
+			Timeout:        5 * time.Second,
 		}
 		provider, _ := NewLDAPProvider(config)
 		assert.Equal(t, 5*time.Second, provider.Timeout())
@@ -277,9 +293,10 @@ func TestLDAPProvider_Timeout(t *testing.T) {
 
 	t.Run("defaults to 30 seconds", func(t *testing.T) {
 		config := &LDAPConfig{
-			Host:   "ldap.example.com",
-			Port:   389,
-			BaseDN: "dc=example,dc=com",
+			AllowPlaintext: true,
+			Host:           "ldap.example.com",
+			Port:           389,
+			BaseDN:         "dc=example,dc=com",
 		}
 		provider, _ := NewLDAPProvider(config)
 		assert.Equal(t, 30*time.Second, provider.Timeout())
@@ -339,9 +356,10 @@ func TestLDAPAuthResult(t *testing.T) {
 func TestLDAPProvider_Authenticate(t *testing.T) {
 	t.Run("returns error for empty username", func(t *testing.T) {
 		config := &LDAPConfig{
-			Host:   "ldap.example.com",
-			Port:   389,
-			BaseDN: "dc=example,dc=com",
+			AllowPlaintext: true,
+			Host:           "ldap.example.com",
+			Port:           389,
+			BaseDN:         "dc=example,dc=com",
 		}
 		provider, _ := NewLDAPProvider(config)
 
@@ -352,9 +370,10 @@ func TestLDAPProvider_Authenticate(t *testing.T) {
 
 	t.Run("returns error for empty password", func(t *testing.T) {
 		config := &LDAPConfig{
-			Host:   "ldap.example.com",
-			Port:   389,
-			BaseDN: "dc=example,dc=com",
+			AllowPlaintext: true,
+			Host:           "ldap.example.com",
+			Port:           389,
+			BaseDN:         "dc=example,dc=com",
 		}
 		provider, _ := NewLDAPProvider(config)
 
@@ -367,9 +386,10 @@ func TestLDAPProvider_Authenticate(t *testing.T) {
 func TestLDAPProvider_ProviderInfo(t *testing.T) {
 	t.Run("returns provider information", func(t *testing.T) {
 		config := &LDAPConfig{
-			Host:   "ldap.example.com",
-			Port:   389,
-			BaseDN: "dc=example,dc=com",
+			AllowPlaintext: true,
+			Host:           "ldap.example.com",
+			Port:           389,
+			BaseDN:         "dc=example,dc=com",
 		}
 		provider, _ := NewLDAPProvider(config)
 
@@ -0,0 +1,423 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/FairForge/vaultaire/internal/rbac"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/lib/pq"
+)
+
+// OIDC vendors. A connection's vendor picks the default scopes and claim
+// names; discovery supplies everything else.
+const (
+	OIDCVendorGeneric   = "generic"
+	OIDCVendorOkta      = "okta"
+	OIDCVendorKeycloak  = "keycloak"
+	OIDCVendorEntraID   = "entra_id"
+	OIDCVendorAuthentik = "authentik"
+)
+
+var (
+	// ErrOIDCConnectionNotFound means no enabled connection matches.
+	ErrOIDCConnectionNotFound = errors.New("no single sign-on connection found")
+	// ErrOIDCConnectionExists means the tenant already has a connection
+	// for the issuer and client ID.
+	ErrOIDCConnectionExists = errors.New("a connection for this issuer and client already exists")
+	// ErrOIDCEmailDomain means the provider asserted an email address
+	// outside the connection's email domains.
+	ErrOIDCEmailDomain = errors.New("email address is outside the connection's domains")
+	// ErrOIDCEmailDomainClaimed means another connection already signs in
+	// one of the email domains.
+	ErrOIDCEmailDomainClaimed = errors.New("email domain is already claimed by another connection")
+	// ErrOIDCEmailDomainUnverified means the user registering a connection
+	// has no verified email address in one of its domains.
+	ErrOIDCEmailDomainUnverified = errors.New("email domains must be registered by a user with a verified address in each")
+)
+
+// OIDCClaimMapping names the ID token claims a connection reads.
+type OIDCClaimMapping struct {
+	Email    string `json:"email"`
+	Name     string `json:"name"`
+	Username string `json:"username"`
+	Groups   string `json:"groups"`
+}
+
+var defaultOIDCClaims = OIDCClaimMapping{
+	Email:    "email",
+	Name:     "name",
+	Username: "preferred_username",
+	Groups:   "groups",
+}
+
+// oidcVendorScopes are the scopes each vendor needs to put email and
+// groups in the ID token. Okta's org authorization servers release groups
+// only for the groups scope; Keycloak and Entra ID need a groups mapper or
+// optional claim configured at the IdP instead.
+var oidcVendorScopes = map[string][]string{
+	OIDCVendorGeneric:   {"openid", "email", "profile"},
+	OIDCVendorOkta:      {"openid", "email", "profile", "groups"},
+	OIDCVendorKeycloak:  {"openid", "email", "profile"},
+	OIDCVendorEntraID:   {"openid", "email", "profile"},
+	OIDCVendorAuthentik: {"openid", "email", "profile"},
+}
+
+// oidcConnectionRoles are the roles a tenant may grant. Roles are global,
+// so a tenant's IdP must never confer the platform's admin role.
+var oidcConnectionRoles = map[string]bool{
+	rbac.RoleUser:   true,
+	rbac.RoleViewer: true,
+	rbac.RoleGuest:  true,
+}
+
+var emailDomainPattern = regexp.MustCompile(`^([a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?\.)+[a-z]{2,63}$`)
+
+// OIDCConnection is a tenant's own OpenID Connect provider for dashboard
+// sign-in. Users it authenticates become members of the tenant.
+type OIDCConnection struct {
+	ID           string           `json:"id"`
+	TenantID     string           `json:"tenant_id"`
+	Name         string           `json:"name"`
+	Vendor       string           `json:"vendor"`
+	IssuerURL    string           `json:"issuer_url"`
+	ClientID     string           `json:"client_id"`
+	ClientSecret string           `json:"-"`
+	Scopes       []string         `json:"scopes"`
+	EmailDomains []string         `json:"email_domains"`
+	Claims       OIDCClaimMapping `json:"claims"`
+	RoleMapping
+	Enabled   bool      `json:"enabled"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Provider names the linked accounts the connection creates.
+func (c *OIDCConnection) Provider() string {
+	return "oidc:" + c.ID
+}
+
+// ApplyDefaults fills in the vendor's scopes and claim names and
+// normalizes the email domains.
+func (c *OIDCConnection) ApplyDefaults() {
+	if c.Vendor == "" {
+		c.Vendor = OIDCVendorGeneric
+	}
+	if len(c.Scopes) == 0 {
+		c.Scopes = append([]string(nil), oidcVendorScopes[c.Vendor]...)
+	}
+	if c.Claims.Email == "" {
+		c.Claims.Email = defaultOIDCClaims.Email
+	}
+	if c.Claims.Name == "" {
+		c.Claims.Name = defaultOIDCClaims.Name
+	}
+	if c.Claims.Username == "" {
+		c.Claims.Username = defaultOIDCClaims.Username
+	}
+	if c.Claims.Groups == "" {
+		c.Claims.Groups = defaultOIDCClaims.Groups
+	}
+	for i, d := range c.EmailDomains {
+		c.EmailDomains[i] = strings.ToLower(strings.TrimSpace(d))
+	}
+	slices.Sort(c.EmailDomains)
+	c.EmailDomains = slices.Compact(c.EmailDomains)
+}
+
+// Validate checks a connection before it is stored.
+func (c *OIDCConnection) Validate() error {
+	if c.Name == "" || len(c.Name) > 100 {
+		return errors.New("name must be 1-100 characters")
+	}
+	if _, ok := oidcVendorScopes[c.Vendor]; !ok {
+		return fmt.Errorf("unknown vendor %q", c.Vendor)
+	}
+	u, err := url.Parse(c.IssuerURL)
+	if err != nil || u.Scheme != "https" || u.Host == "" || u.RawQuery != "" || u.Fragment != "" {
+		return errors.New("issuer_url must be an https URL without query or fragment")
+	}
+	if c.ClientID == "" {
+		return errors.New("client_id is required")
+	}
+	if !slices.Contains(c.Scopes, "openid") {
+		return errors.New("scopes must include openid")
+	}
+	// Email addresses are unique across tenants, so a connection may only
+	// create users in domains it names.
+	if len(c.EmailDomains) == 0 {
+		return errors.New("email_domains must list at least one domain")
+	}
+	for _, d := range c.EmailDomains {
+		if !emailDomainPattern.MatchString(d) {
+			return fmt.Errorf("invalid email domain %q", d)
+		}
+	}
+	if err := c.RoleMapping.Validate(); err != nil {
+		return err
+	}
+	for _, role := range c.managedRoles() {
+		if !oidcConnectionRoles[role] {
+			return fmt.Errorf("role %q cannot be granted by a tenant connection; use user, viewer or guest", role)
+		}
+	}
+	return nil
+}
+
+// Identity maps verified ID token claims to the user they describe.
+func (c *OIDCConnection) Identity(claims jwt.MapClaims) (*ExternalIdentity, error) {
+	sub, _ := claims["sub"].(string)
+	str := func(name string) string {
+		v, _ := claims[name].(string)
+		return strings.TrimSpace(v)
+	}
+
+	id := &ExternalIdentity{
+		Provider:    c.Provider(),
+		ID:          sub,
+		Subject:     sub,
+		Username:    str(c.Claims.Username),
+		Email:       strings.ToLower(str(c.Claims.Email)),
+		DisplayName: str(c.Claims.Name),
+		Groups:      claimValues(claims[c.Claims.Groups]),
+	}
+	// Entra ID leaves email unset for accounts without a mailbox; their
+	// user principal name is the address they sign in with.
+	if id.Email == "" && strings.Contains(id.Username, "@") {
+		id.Email = strings.ToLower(id.Username)
+	}
+	if verified, ok := claims["email_verified"].(bool); ok && !verified {
+		return nil, fmt.Errorf("oidc: %s has not verified %s", sub, id.Email)
+	}
+	_, domain, ok := strings.Cut(id.Email, "@")
+	if !ok {
+		return nil, fmt.Errorf("oidc: %s has no email address", sub)
+	}
+	if !slices.Contains(c.EmailDomains, domain) {
+		return nil, fmt.Errorf("%w: %s", ErrOIDCEmailDomain, id.Email)
+	}
+
+	id.Roles = c.ResolveRoles(id.Groups)
+	if len(id.Roles) == 0 {
+		return nil, ErrNoMappedRole
+	}
+	return id, nil
+}
+
+// Provision finds or creates the tenant member for id and syncs its
+// roles; see RoleMapping.Provision.
+func (c *OIDCConnection) Provision(ctx context.Context, a *AuthService, id *ExternalIdentity) (*User, error) {
+	return c.RoleMapping.Provision(ctx, a, c.TenantID, id)
+}
+
+const oidcConnectionColumns = `id, tenant_id, name, vendor, issuer_url, client_id, client_secret,
+	scopes, email_domains, claims, group_roles, default_role, link_existing_accounts, enabled, created_at`
+
+func scanOIDCConnection(row interface{ Scan(...interface{}) error }) (*OIDCConnection, error) {
+	var c OIDCConnection
+	var scopes, domains pq.StringArray
+	var claims, groupRoles []byte
+	if err := row.Scan(&c.ID, &c.TenantID, &c.Name, &c.Vendor, &c.IssuerURL, &c.ClientID, &c.ClientSecret,
+		&scopes, &domains, &claims, &groupRoles, &c.DefaultRole, &c.LinkExistingAccounts, &c.Enabled, &c.CreatedAt); err != nil {
+		return nil, err
+	}
+	c.Scopes = []string(scopes)
+	c.EmailDomains = []string(domains)
+	if err := json.Unmarshal(claims, &c.Claims); err != nil {
+		return nil, fmt.Errorf("decode connection claims: %w", err)
+	}
+	if err := json.Unmarshal(groupRoles, &c.GroupRoles); err != nil {
+		return nil, fmt.Errorf("decode connection group roles: %w", err)
+	}
+	c.ApplyDefaults()
+	return &c, nil
+}
+
+// CreateOIDCConnection stores a validated connection registered by userID
+// and claims its email domains. Sign-in routes an email address to the one
+// connection claiming its domain, so each domain is claimed once across
+// all tenants, and only by a user with a verified address in it (or in a
+// parent domain): a tenant must not send another organization's users to
+// its own IdP.
+func CreateOIDCConnection(ctx context.Context, db *sql.DB, c *OIDCConnection, userID string) error {
+	if db == nil {
+		return fmt.Errorf("database not initialized")
+	}
+	claims, err := json.Marshal(c.Claims)
+	if err != nil {
+		return err
+	}
+	groupRoles, err := json.Marshal(c.GroupRoles)
+	if err != nil {
+		return err
+	}
+	if err := checkEmailDomainOwner(ctx, db, userID, c.EmailDomains); err != nil {
+		return err
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	result, err := tx.ExecContext(ctx, `
+		INSERT INTO oidc_connections (`+oidcConnectionColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
+		ON CONFLICT (tenant_id, issuer_url, client_id) DO NOTHING`,
+		c.ID, c.TenantID, c.Name, c.Vendor, c.IssuerURL, c.ClientID, c.ClientSecret,
+		pq.Array(c.Scopes), pq.Array(c.EmailDomains), claims, groupRoles, c.DefaultRole,
+		c.LinkExistingAccounts, c.Enabled, c.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("create oidc connection: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrOIDCConnectionExists
+	}
+	for _, domain := range c.EmailDomains {
+		result, err := tx.ExecContext(ctx, `
+			INSERT INTO oidc_email_domains (domain, tenant_id, connection_id)
+			VALUES ($1, $2, $3)
+			ON CONFLICT (domain) DO NOTHING`,
+			domain, c.TenantID, c.ID)
+		if err != nil {
+			return fmt.Errorf("claim email domain: %w", err)
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			return fmt.Errorf("%w: %s", ErrOIDCEmailDomainClaimed, domain)
+		}
+	}
+	return tx.Commit()
+}
+
+// checkEmailDomainOwner checks that userID has a verified email address in
+// each domain or in a parent of it: a verified jsmith@acme.com may claim
+// acme.com and eu.acme.com, but not acme.org.
+func checkEmailDomainOwner(ctx context.Context, db *sql.DB, userID string, domains []string) error {
+	var email string
+	var verified sql.NullBool
+	err := db.QueryRowContext(ctx,
+		`SELECT email, email_verified FROM users WHERE id = $1`, userID).Scan(&email, &verified)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrOIDCEmailDomainUnverified
+	}
+	if err != nil {
+		return fmt.Errorf("load connection owner: %w", err)
+	}
+	_, owned, _ := strings.Cut(strings.ToLower(email), "@")
+	if !verified.Bool || owned == "" {
+		return ErrOIDCEmailDomainUnverified
+	}
+	for _, d := range domains {
+		if d != owned && !strings.HasSuffix(d, "."+owned) {
+			return fmt.Errorf("%w: %s", ErrOIDCEmailDomainUnverified, d)
+		}
+	}
+	return nil
+}
+
+// ListOIDCConnections returns a tenant's connections, oldest first.
+func ListOIDCConnections(ctx context.Context, db *sql.DB, tenantID string) ([]*OIDCConnection, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+	rows, err := db.QueryContext(ctx, `SELECT `+oidcConnectionColumns+`
+		FROM oidc_connections WHERE tenant_id = $1 ORDER BY created_at, id`, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("list oidc connections: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var out []*OIDCConnection
+	for rows.Next() {
+		c, err := scanOIDCConnection(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan oidc connection: %w", err)
+		}
+		out = append(out, c)
+	}
+	return out, rows.Err()
+}
+
+// GetOIDCConnection loads an enabled connection by ID.
+func GetOIDCConnection(ctx context.Context, db *sql.DB, id string) (*OIDCConnection, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+	c, err := scanOIDCConnection(db.QueryRowContext(ctx, `SELECT `+oidcConnectionColumns+`
+		FROM oidc_connections WHERE id = $1 AND enabled`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrOIDCConnectionNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("load oidc connection: %w", err)
+	}
+	return c, nil
+}
+
+// FindOIDCConnection picks the enabled connection a user signs in with:
+// by the domain of an email address, which oidc_email_domains maps to the
+// one connection that claimed it, or by tenant slug. It fails unless
+// exactly one connection matches.
+func FindOIDCConnection(ctx context.Context, db *sql.DB, emailOrSlug string) (*OIDCConnection, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+	key := strings.ToLower(strings.TrimSpace(emailOrSlug))
+	query := `SELECT ` + oidcConnectionColumns + ` FROM oidc_connections
+		WHERE enabled AND tenant_id = (SELECT id FROM tenants WHERE slug = $1)`
+	if _, domain, ok := strings.Cut(key, "@"); ok {
+		key = domain
+		query = `SELECT ` + oidcConnectionColumns + ` FROM oidc_connections
+			WHERE enabled AND id = (SELECT connection_id FROM oidc_email_domains WHERE domain = $1)`
+	}
+	if key == "" {
+		return nil, ErrOIDCConnectionNotFound
+	}
+
+	rows, err := db.QueryContext(ctx, query+` LIMIT 2`, key)
+	if err != nil {
+		return nil, fmt.Errorf("find oidc connection: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var found []*OIDCConnection
+	for rows.Next() {
+		c, err := scanOIDCConnection(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan oidc connection: %w", err)
+		}
+		found = append(found, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("find oidc connection: %w", err)
+	}
+	if len(found) != 1 {
+		return nil, ErrOIDCConnectionNotFound
+	}
+	return found[0], nil
+}
+
+// DeleteOIDCConnection deletes a tenant's connection. Its members keep
+// their accounts but can no longer sign in through it.
+func DeleteOIDCConnection(ctx context.Context, db *sql.DB, tenantID, id string) error {
+	if db == nil {
+		return fmt.Errorf("database not initialized")
+	}
+	result, err := db.ExecContext(ctx,
+		`DELETE FROM oidc_connections WHERE id = $1 AND tenant_id = $2`, id, tenantID)
+	if err != nil {
+		return fmt.Errorf("delete oidc connection: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrOIDCConnectionNotFound
+	}
+	return nil
+}
@@ -0,0 +1,186 @@
+package auth
+
+import (
+	"context"
+	"database/sql/driver"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testOIDCConnection() *OIDCConnection {
+	c := &OIDCConnection{
+		ID:           "conn-1",
+		TenantID:     "tenant-acme",
+		Name:         "Acme Okta",
+		Vendor:       OIDCVendorOkta,
+		IssuerURL:    "https://acme.okta.com",
+		ClientID:     "vaultaire",
+		ClientSecret: "s3cret",
+		EmailDomains: []string{"Acme.com "},
+		RoleMapping:  RoleMapping{GroupRoles: map[string]string{"Storage": "user", "Auditors": "viewer"}},
+		Enabled:      true,
+	}
+	c.ApplyDefaults()
+	return c
+}
+
+func TestOIDCConnection_Validate(t *testing.T) {
+	c := testOIDCConnection()
+	require.NoError(t, c.Validate())
+	assert.Equal(t, []string{"openid", "email", "profile", "groups"}, c.Scopes)
+	assert.Equal(t, []string{"acme.com"}, c.EmailDomains)
+	assert.Equal(t, "preferred_username", c.Claims.Username)
+
+	for name, mutate := range map[string]func(c *OIDCConnection){
+		"http issuer":       func(c *OIDCConnection) { c.IssuerURL = "http://acme.okta.com" },
+		"issuer with query": func(c *OIDCConnection) { c.IssuerURL = "https://acme.okta.com?x=1" },
+		"no client id":      func(c *OIDCConnection) { c.ClientID = "" },
+		"no openid scope":   func(c *OIDCConnection) { c.Scopes = []string{"email"} },
+		"no domains":        func(c *OIDCConnection) { c.EmailDomains = nil },
+		"bad domain":        func(c *OIDCConnection) { c.EmailDomains = []string{"*.acme.com"} },
+		"unknown vendor":    func(c *OIDCConnection) { c.Vendor = "ping" },
+		"grants admin":      func(c *OIDCConnection) { c.GroupRoles["IT"] = "admin" },
+		"default admin":     func(c *OIDCConnection) { c.DefaultRole = "admin" },
+		"custom role":       func(c *OIDCConnection) { c.DefaultRole = "billing" },
+	} {
+		t.Run(name, func(t *testing.T) {
+			c := testOIDCConnection()
+			mutate(c)
+			assert.Error(t, c.Validate())
+		})
+	}
+}
+
+func TestOIDCConnection_Identity(t *testing.T) {
+	c := testOIDCConnection()
+	claims := func(extra jwt.MapClaims) jwt.MapClaims {
+		m := jwt.MapClaims{"sub": "00u1", "email": "JSmith@acme.com", "name": "Jane Smith", "groups": []interface{}{"Everyone", "storage"}}
+		for k, v := range extra {
+			if v == nil {
+				delete(m, k)
+			} else {
+				m[k] = v
+			}
+		}
+		return m
+	}
+
+	id, err := c.Identity(claims(nil))
+	require.NoError(t, err)
+	assert.Equal(t, "oidc:conn-1", id.Provider)
+	assert.Equal(t, "00u1", id.ID)
+	assert.Equal(t, "jsmith@acme.com", id.Email)
+	assert.Equal(t, "Jane Smith", id.DisplayName)
+	assert.Equal(t, []string{"user"}, id.Roles)
+
+	// Entra ID accounts without a mailbox sign in with their UPN.
+	id, err = c.Identity(claims(jwt.MapClaims{"email": nil, "preferred_username": "jsmith@acme.com"}))
+	require.NoError(t, err)
+	assert.Equal(t, "jsmith@acme.com", id.Email)
+
+	_, err = c.Identity(claims(jwt.MapClaims{"email": "jsmith@other.com"}))
+	assert.ErrorIs(t, err, ErrOIDCEmailDomain)
+	_, err = c.Identity(claims(jwt.MapClaims{"email_verified": false}))
+	assert.ErrorContains(t, err, "not verified")
+	_, err = c.Identity(claims(jwt.MapClaims{"email": nil}))
+	assert.ErrorContains(t, err, "no email")
+	_, err = c.Identity(claims(jwt.MapClaims{"groups": "Everyone"}))
+	assert.ErrorIs(t, err, ErrNoMappedRole)
+}
+
+func TestOIDCConnection_Provision(t *testing.T) {
+	ctx := context.Background()
+	c := testOIDCConnection()
+	id := &ExternalIdentity{Provider: c.Provider(), ID: "00u1", Subject: "00u1", Email: "jsmith@acme.com", Roles: []string{"user"}}
+
+	setup := func(t *testing.T) *AuthService {
+		t.Helper()
+		svc := NewAuthService(nil, nil)
+		_, tenant, _, err := svc.CreateUserWithTenant(ctx, "owner@acme.com", "owner-pw-123", "Acme")
+		require.NoError(t, err)
+		c.TenantID = tenant.ID
+		return svc
+	}
+
+	t.Run("creates a member of the tenant", func(t *testing.T) {
+		svc := setup(t)
+		svc.SetSignupsEnabled(false) // members are not signups
+		user, err := c.Provision(ctx, svc, id)
+		require.NoError(t, err)
+		assert.Equal(t, "jsmith@acme.com", user.Email)
+		assert.Equal(t, c.TenantID, user.TenantID)
+		assert.Empty(t, user.PasswordHash)
+	})
+
+	t.Run("never links another tenant's account", func(t *testing.T) {
+		svc := setup(t)
+		_, _, _, err := svc.CreateUserWithTenant(ctx, "jsmith@acme.com", "local-pw-123", "Other")
+		require.NoError(t, err)
+
+		linking := *c
+		linking.LinkExistingAccounts = true
+		_, err = linking.Provision(ctx, svc, id)
+		assert.ErrorIs(t, err, ErrAccountConflict)
+	})
+
+	t.Run("links the tenant owner when allowed", func(t *testing.T) {
+		svc := setup(t)
+		owner := &ExternalIdentity{Provider: c.Provider(), ID: "00u0", Email: "owner@acme.com", Roles: []string{"user"}}
+		_, err := c.Provision(ctx, svc, owner)
+		assert.ErrorIs(t, err, ErrAccountConflict)
+
+		linking := *c
+		linking.LinkExistingAccounts = true
+		user, err := linking.Provision(ctx, svc, owner)
+		require.NoError(t, err)
+		assert.Equal(t, c.TenantID, user.TenantID)
+	})
+
+	t.Run("refuses deactivated members", func(t *testing.T) {
+		svc := setup(t)
+		user, err := c.Provision(ctx, svc, id)
+		require.NoError(t, err)
+		user.Deactivated = true
+
+		linking := *c
+		linking.LinkExistingAccounts = true
+		_, err = linking.Provision(ctx, svc, id)
+		assert.ErrorIs(t, err, ErrUserDeactivated)
+	})
+}
+
+func TestFindOIDCConnection(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() { _ = db.Close() }()
+
+	cols := []string{"id", "tenant_id", "name", "vendor", "issuer_url", "client_id", "client_secret",
+		"scopes", "email_domains", "claims", "group_roles", "default_role", "link_existing_accounts", "enabled", "created_at"}
+	row := []driver.Value{"conn-1", "tenant-acme", "Acme", "okta", "https://acme.okta.com", "vaultaire", "s3cret",
+		"{openid}", "{acme.com}", []byte(`{}`), []byte(`{"Storage":"user"}`), "", false, true, time.Now()}
+
+	mock.ExpectQuery(`SELECT connection_id FROM oidc_email_domains WHERE domain = \$1`).WithArgs("acme.com").
+		WillReturnRows(sqlmock.NewRows(cols).AddRow(row...))
+	c, err := FindOIDCConnection(context.Background(), db, "JSmith@Acme.com")
+	require.NoError(t, err)
+	assert.Equal(t, "conn-1", c.ID)
+	assert.Equal(t, map[string]string{"Storage": "user"}, c.GroupRoles)
+	assert.Equal(t, "email", c.Claims.Email, "defaults fill unset claims")
+
+	// Two matches are ambiguous.
+	mock.ExpectQuery(`oidc_email_domains`).WithArgs("acme.com").
+		WillReturnRows(sqlmock.NewRows(cols).AddRow(row...).AddRow(row...))
+	_, err = FindOIDCConnection(context.Background(), db, "jsmith@acme.com")
+	assert.ErrorIs(t, err, ErrOIDCConnectionNotFound)
+
+	mock.ExpectQuery(`FROM tenants WHERE slug = \$1`).WithArgs("acme").
+		WillReturnRows(sqlmock.NewRows(cols))
+	_, err = FindOIDCConnection(context.Background(), db, "acme")
+	assert.ErrorIs(t, err, ErrOIDCConnectionNotFound)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,200 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// OIDCLogin is the state of one sign-in attempt, kept in the browser
+// between AuthCodeURL and the callback. State binds the callback to the
+// browser, Nonce binds the ID token to the attempt, and Verifier is the
+// PKCE secret the authorization code is redeemed with.
+type OIDCLogin struct {
+	ConnectionID string `json:"c"`
+	State        string `json:"s"`
+	Nonce        string `json:"n"`
+	Verifier     string `json:"v"`
+}
+
+// OIDCRelyingParty signs users in through OIDC connections with the
+// authorization code flow and PKCE. Issuers are found through discovery;
+// ID tokens are checked by the verifier against the issuer's key set.
+type OIDCRelyingParty struct {
+	verifier    *OIDCVerifier
+	client      *http.Client
+	redirectURL string
+}
+
+// NewOIDCRelyingParty creates a relying party whose connections all
+// register redirectURL as their redirect URI. A nil client is
+// newIDPClient's, which connects only to public addresses.
+func NewOIDCRelyingParty(verifier *OIDCVerifier, client *http.Client, redirectURL string) *OIDCRelyingParty {
+	if client == nil {
+		client = newIDPClient()
+	}
+	return &OIDCRelyingParty{verifier: verifier, client: client, redirectURL: redirectURL}
+}
+
+// RedirectURL is the redirect URI to register with each IdP.
+func (rp *OIDCRelyingParty) RedirectURL() string {
+	return rp.redirectURL
+}
+
+// AuthCodeURL returns the IdP URL that starts a sign-in through conn and
+// the state to keep until the callback.
+func (rp *OIDCRelyingParty) AuthCodeURL(ctx context.Context, conn *OIDCConnection, loginHint string) (string, *OIDCLogin, error) {
+	endpoints, err := rp.verifier.Endpoints(ctx, conn.IssuerURL)
+	if err != nil {
+		return "", nil, err
+	}
+	login := &OIDCLogin{
+		ConnectionID: conn.ID,
+		State:        randomToken(),
+		Nonce:        randomToken(),
+		Verifier:     randomToken(),
+	}
+	challenge := sha256.Sum256([]byte(login.Verifier))
+
+	u, err := url.Parse(endpoints.Authorization)
+	if err != nil {
+		return "", nil, fmt.Errorf("%w: authorization endpoint: %v", ErrIDPUnreachable, err)
+	}
+	q := u.Query()
+	q.Set("response_type", "code")
+	q.Set("client_id", conn.ClientID)
+	q.Set("redirect_uri", rp.redirectURL)
+	q.Set("scope", strings.Join(conn.Scopes, " "))
+	q.Set("state", login.State)
+	q.Set("nonce", login.Nonce)
+	q.Set("code_challenge", base64.RawURLEncoding.EncodeToString(challenge[:]))
+	q.Set("code_challenge_method", "S256")
+	if loginHint != "" {
+		q.Set("login_hint", loginHint)
+	}
+	u.RawQuery = q.Encode()
+	return u.String(), login, nil
+}
+
+// Exchange redeems the authorization code from the callback and returns
+// the raw ID token with its verified claims.
+func (rp *OIDCRelyingParty) Exchange(ctx context.Context, conn *OIDCConnection, login *OIDCLogin, code string) (string, jwt.MapClaims, error) {
+	if code == "" {
+		return "", nil, errors.New("oidc: authorization code is required")
+	}
+	endpoints, err := rp.verifier.Endpoints(ctx, conn.IssuerURL)
+	if err != nil {
+		return "", nil, err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {rp.redirectURL},
+		"code_verifier": {login.Verifier},
+	}
+	basicAuth := false
+	switch {
+	case conn.ClientSecret == "":
+		form.Set("client_id", conn.ClientID)
+	case len(endpoints.TokenAuthMethods) == 0 || slices.Contains(endpoints.TokenAuthMethods, "client_secret_basic"):
+		basicAuth = true
+	case slices.Contains(endpoints.TokenAuthMethods, "client_secret_post"):
+		form.Set("client_id", conn.ClientID)
+		form.Set("client_secret", conn.ClientSecret)
+	default:
+		return "", nil, fmt.Errorf("oidc: %s supports neither client_secret_basic nor client_secret_post", conn.IssuerURL)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoints.Token, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", nil, fmt.Errorf("oidc: token request: %w", err)
+	}
+	if basicAuth {
+		// RFC 6749 §2.3.1: credentials are form-encoded before basic auth.
+		req.SetBasicAuth(url.QueryEscape(conn.ClientID), url.QueryEscape(conn.ClientSecret))
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := rp.client.Do(req)
+	if err != nil {
+		return "", nil, fmt.Errorf("oidc: token request: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	var body struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, oidcMaxDocument)).Decode(&body); err != nil {
+		return "", nil, fmt.Errorf("oidc: token response (status %d): %w", resp.StatusCode, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", nil, fmt.Errorf("oidc: token request failed with status %d: %s %s", resp.StatusCode, body.Error, body.ErrorDescription)
+	}
+	if body.IDToken == "" {
+		return "", nil, errors.New("oidc: token response has no id_token")
+	}
+
+	claims, err := rp.verifier.Verify(ctx, body.IDToken, conn.IssuerURL, []string{conn.ClientID})
+	if err != nil {
+		return "", nil, err
+	}
+	nonce, _ := claims["nonce"].(string)
+	if subtle.ConstantTimeCompare([]byte(nonce), []byte(login.Nonce)) != 1 {
+		return "", nil, fmt.Errorf("%w: nonce does not match the sign-in attempt", ErrInvalidIDToken)
+	}
+	// OpenID Connect Core §3.1.3.7: a token for several audiences must
+	// name this client as its authorized party.
+	azp, _ := claims["azp"].(string)
+	if aud, _ := claims.GetAudience(); (len(aud) > 1 || azp != "") && azp != conn.ClientID {
+		return "", nil, fmt.Errorf("%w: token was issued to %q", ErrInvalidIDToken, azp)
+	}
+	return body.IDToken, claims, nil
+}
+
+// LogoutURL returns the IdP URL that ends the user's session there and
+// returns them to postLogoutURL, or "" when the IdP does not support
+// RP-initiated logout.
+func (rp *OIDCRelyingParty) LogoutURL(ctx context.Context, conn *OIDCConnection, idToken, postLogoutURL string) (string, error) {
+	endpoints, err := rp.verifier.Endpoints(ctx, conn.IssuerURL)
+	if err != nil {
+		return "", err
+	}
+	if endpoints.EndSession == "" {
+		return "", nil
+	}
+	u, err := url.Parse(endpoints.EndSession)
+	if err != nil {
+		return "", fmt.Errorf("%w: end session endpoint: %v", ErrIDPUnreachable, err)
+	}
+	q := u.Query()
+	q.Set("client_id", conn.ClientID)
+	if idToken != "" {
+		q.Set("id_token_hint", idToken)
+	}
+	if postLogoutURL != "" {
+		q.Set("post_logout_redirect_uri", postLogoutURL)
+	}
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+func randomToken() string {
+	b := make([]byte, 32)
+	_, _ = rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
@@ -0,0 +1,117 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testRedirectURL = "https://dash.example.com/oidc/callback"
+
+// startOIDCLogin returns a relying party and connection for idp, and the
+// login AuthCodeURL started along with the parameters it sent.
+func startOIDCLogin(t *testing.T, idp *testIdP) (*OIDCRelyingParty, *OIDCConnection, *OIDCLogin, url.Values) {
+	t.Helper()
+	conn := testOIDCConnection()
+	conn.IssuerURL = idp.issuer()
+	rp := NewOIDCRelyingParty(NewOIDCVerifier(idp.srv.Client()), idp.srv.Client(), testRedirectURL)
+
+	authURL, login, err := rp.AuthCodeURL(context.Background(), conn, "jsmith@acme.com")
+	require.NoError(t, err)
+	u, err := url.Parse(authURL)
+	require.NoError(t, err)
+	assert.Equal(t, idp.srv.URL+"/authorize", u.Scheme+"://"+u.Host+u.Path)
+	return rp, conn, login, u.Query()
+}
+
+func TestOIDCRelyingParty_AuthCodeFlow(t *testing.T) {
+	idp := newTestIdP(t)
+	rp, conn, login, params := startOIDCLogin(t, idp)
+
+	assert.Equal(t, "code", params.Get("response_type"))
+	assert.Equal(t, "vaultaire", params.Get("client_id"))
+	assert.Equal(t, testRedirectURL, params.Get("redirect_uri"))
+	assert.Equal(t, "openid email profile groups", params.Get("scope"))
+	assert.Equal(t, login.State, params.Get("state"))
+	assert.Equal(t, login.Nonce, params.Get("nonce"))
+	assert.Equal(t, "jsmith@acme.com", params.Get("login_hint"))
+	assert.Equal(t, "S256", params.Get("code_challenge_method"))
+	assert.Equal(t, "conn-1", login.ConnectionID)
+
+	var idClaims jwt.MapClaims
+	idp.token = func(r *http.Request) (int, interface{}) {
+		user, pass, ok := r.BasicAuth()
+		if !ok || user != "vaultaire" || pass != "s3cret" {
+			return http.StatusUnauthorized, map[string]string{"error": "invalid_client"}
+		}
+		// The verifier must hash to the challenge sent to /authorize.
+		sum := sha256.Sum256([]byte(r.PostFormValue("code_verifier")))
+		if r.PostFormValue("code") != "code-1" || base64.RawURLEncoding.EncodeToString(sum[:]) != params.Get("code_challenge") {
+			return http.StatusBadRequest, map[string]string{"error": "invalid_grant", "error_description": "PKCE verification failed"}
+		}
+		return http.StatusOK, map[string]string{"access_token": "at", "id_token": idp.sign(t, "rsa-1", idClaims)}
+	}
+	exchange := func(claims jwt.MapClaims, code string) (jwt.MapClaims, error) {
+		idClaims = jwt.MapClaims{"aud": "vaultaire", "sub": "00u1", "nonce": login.Nonce, "email": "jsmith@acme.com"}
+		for k, v := range claims {
+			idClaims[k] = v
+		}
+		_, got, err := rp.Exchange(context.Background(), conn, login, code)
+		return got, err
+	}
+
+	claims, err := exchange(nil, "code-1")
+	require.NoError(t, err)
+	assert.Equal(t, "00u1", claims["sub"])
+
+	_, err = exchange(nil, "code-2")
+	assert.ErrorContains(t, err, "invalid_grant")
+
+	_, err = exchange(jwt.MapClaims{"nonce": "replayed"}, "code-1")
+	assert.ErrorIs(t, err, ErrInvalidIDToken)
+
+	_, err = exchange(jwt.MapClaims{"aud": "other-app"}, "code-1")
+	assert.ErrorIs(t, err, ErrInvalidIDToken)
+
+	_, err = exchange(jwt.MapClaims{"aud": []string{"vaultaire", "other-app"}}, "code-1")
+	assert.ErrorIs(t, err, ErrInvalidIDToken, "several audiences need azp")
+
+	_, err = exchange(jwt.MapClaims{"aud": []string{"vaultaire", "other-app"}, "azp": "vaultaire"}, "code-1")
+	assert.NoError(t, err)
+}
+
+func TestOIDCRelyingParty_ClientSecretPost(t *testing.T) {
+	idp := newTestIdP(t)
+	idp.authMethods = []string{"client_secret_post"}
+	rp, conn, login, _ := startOIDCLogin(t, idp)
+
+	idp.token = func(r *http.Request) (int, interface{}) {
+		if _, _, ok := r.BasicAuth(); ok || r.PostFormValue("client_secret") != "s3cret" {
+			return http.StatusUnauthorized, map[string]string{"error": "invalid_client"}
+		}
+		return http.StatusOK, map[string]string{"id_token": idp.sign(t, "rsa-1", jwt.MapClaims{"aud": "vaultaire", "nonce": login.Nonce})}
+	}
+	_, _, err := rp.Exchange(context.Background(), conn, login, "code-1")
+	assert.NoError(t, err)
+}
+
+func TestOIDCRelyingParty_LogoutURL(t *testing.T) {
+	idp := newTestIdP(t)
+	rp, conn, _, _ := startOIDCLogin(t, idp)
+
+	logoutURL, err := rp.LogoutURL(context.Background(), conn, "id-token", "https://dash.example.com/login")
+	require.NoError(t, err)
+	u, err := url.Parse(logoutURL)
+	require.NoError(t, err)
+	assert.Equal(t, "/logout", u.Path)
+	assert.Equal(t, "id-token", u.Query().Get("id_token_hint"))
+	assert.Equal(t, "vaultaire", u.Query().Get("client_id"))
+	assert.Equal(t, "https://dash.example.com/login", u.Query().Get("post_logout_redirect_uri"))
+}
@@ -0,0 +1,443 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// OIDC verifier tuning.
+const (
+	// oidcCacheTTL is how long discovery documents and key sets are reused.
+	oidcCacheTTL = time.Hour
+	// oidcRefreshInterval rate-limits key set refetches triggered by tokens
+	// naming a key the cached set lacks.
+	oidcRefreshInterval = time.Minute
+	// oidcClockSkew is the leeway allowed on exp, nbf and iat.
+	oidcClockSkew = 30 * time.Second
+	// oidcMaxDocument caps discovery and JWKS response bodies.
+	oidcMaxDocument = 1 << 20
+)
+
+var (
+	// ErrInvalidIDToken means an ID token is malformed, badly signed, or
+	// issued by or for someone else.
+	ErrInvalidIDToken = errors.New("invalid identity token")
+	// ErrIDTokenExpired means an ID token is past its exp claim.
+	ErrIDTokenExpired = errors.New("identity token has expired")
+	// ErrIDPUnreachable means the issuer's discovery document or key set
+	// could not be fetched.
+	ErrIDPUnreachable = errors.New("identity provider could not be reached")
+)
+
+// oidcSigningMethods are the asymmetric algorithms accepted on ID tokens.
+// HMAC is excluded: a shared secret cannot come from a public key set.
+var oidcSigningMethods = []string{
+	"RS256", "RS384", "RS512",
+	"PS256", "PS384", "PS512",
+	"ES256", "ES384", "ES512",
+}
+
+// OIDCVerifier validates OpenID Connect ID tokens against their issuer. It
+// finds each issuer's key set through /.well-known/openid-configuration and
+// caches both documents, refetching the keys when a token names one the
+// cached set lacks (the issuer has rotated keys).
+type OIDCVerifier struct {
+	client *http.Client
+	now    func() time.Time
+
+	mu      sync.Mutex
+	issuers map[string]*oidcIssuer
+}
+
+type oidcIssuer struct {
+	jwksURI   string
+	endpoints OIDCEndpoints
+	keys      map[string]crypto.PublicKey // by kid; "" for keys without one
+	fetched   time.Time
+	refreshed time.Time
+}
+
+// NewOIDCVerifier creates a verifier fetching documents with client, or
+// with newIDPClient's client when client is nil.
+func NewOIDCVerifier(client *http.Client) *OIDCVerifier {
+	if client == nil {
+		client = newIDPClient()
+	}
+	return &OIDCVerifier{
+		client:  client,
+		now:     time.Now,
+		issuers: make(map[string]*oidcIssuer),
+	}
+}
+
+// Verify checks rawToken's signature against issuer's published keys and
+// its iss, aud, exp and nbf claims, and returns its claims. The token must
+// be addressed to at least one of audiences and carry a sub claim.
+func (v *OIDCVerifier) Verify(ctx context.Context, rawToken, issuer string, audiences []string) (jwt.MapClaims, error) {
+	if len(audiences) == 0 {
+		return nil, fmt.Errorf("%w: no audiences configured for %s", ErrInvalidIDToken, issuer)
+	}
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(rawToken, claims, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		return v.keyFor(ctx, issuer, kid)
+	},
+		jwt.WithValidMethods(oidcSigningMethods),
+		jwt.WithIssuer(issuer),
+		jwt.WithAudience(audiences...),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(oidcClockSkew),
+		jwt.WithTimeFunc(v.now),
+	)
+	switch {
+	case err == nil:
+	case errors.Is(err, ErrIDPUnreachable):
+		return nil, err
+	case errors.Is(err, jwt.ErrTokenExpired):
+		return nil, ErrIDTokenExpired
+	default:
+		return nil, fmt.Errorf("%w: %v", ErrInvalidIDToken, err)
+	}
+	if sub, _ := claims["sub"].(string); sub == "" {
+		return nil, fmt.Errorf("%w: token has no sub claim", ErrInvalidIDToken)
+	}
+	return claims, nil
+}
+
+// OIDCEndpoints are the endpoints an issuer's discovery document names
+// for the authorization code flow.
+type OIDCEndpoints struct {
+	Authorization string
+	Token         string
+	// EndSession is empty when the issuer does not support RP-initiated
+	// logout.
+	EndSession string
+	// TokenAuthMethods lists the client authentication methods the token
+	// endpoint accepts; empty means client_secret_basic.
+	TokenAuthMethods []string
+}
+
+// Endpoints returns issuer's authorization and token endpoints from its
+// cached discovery document.
+func (v *OIDCVerifier) Endpoints(ctx context.Context, issuer string) (*OIDCEndpoints, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	iss, err := v.issuerLocked(ctx, issuer)
+	if err != nil {
+		return nil, err
+	}
+	if iss.endpoints.Authorization == "" || iss.endpoints.Token == "" {
+		return nil, fmt.Errorf("%w: discovery document for %s has no authorization or token endpoint", ErrIDPUnreachable, issuer)
+	}
+	// The token endpoint is sent the client secret.
+	if err := requireHTTPS(iss.endpoints.Token); err != nil {
+		return nil, err
+	}
+	endpoints := iss.endpoints
+	return &endpoints, nil
+}
+
+// issuerLocked returns issuer's cached documents, refetching them once the
+// cache expires. v.mu must be held.
+func (v *OIDCVerifier) issuerLocked(ctx context.Context, issuer string) (*oidcIssuer, error) {
+	now := v.now()
+	iss := v.issuers[issuer]
+	if iss == nil || now.Sub(iss.fetched) > oidcCacheTTL {
+		fresh, err := v.discover(ctx, issuer)
+		switch {
+		case err == nil:
+			fresh.fetched, fresh.refreshed = now, now
+			v.issuers[issuer] = fresh
+			iss = fresh
+		case iss == nil:
+			return nil, err
+		default:
+			// An issuer that is briefly unreachable keeps its stale keys
+			// and is retried after oidcRefreshInterval.
+			iss.fetched = now.Add(oidcRefreshInterval - oidcCacheTTL)
+		}
+	}
+	return iss, nil
+}
+
+// keyFor returns the verification key named kid, or every key when the
+// token names none.
+func (v *OIDCVerifier) keyFor(ctx context.Context, issuer, kid string) (interface{}, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	iss, err := v.issuerLocked(ctx, issuer)
+	if err != nil {
+		return nil, err
+	}
+	if now := v.now(); iss.keys[kid] == nil && kid != "" && now.Sub(iss.refreshed) > oidcRefreshInterval {
+		iss.refreshed = now
+		keys, err := v.fetchKeys(ctx, iss.jwksURI)
+		if err != nil {
+			return nil, err
+		}
+		iss.keys = keys
+	}
+
+	if kid == "" {
+		set := jwt.VerificationKeySet{}
+		for _, k := range iss.keys {
+			set.Keys = append(set.Keys, k)
+		}
+		return set, nil
+	}
+	key, ok := iss.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown signing key %q", ErrInvalidIDToken, kid)
+	}
+	return key, nil
+}
+
+// discover fetches issuer's discovery document and the key set it names.
+func (v *OIDCVerifier) discover(ctx context.Context, issuer string) (*oidcIssuer, error) {
+	var doc struct {
+		Issuer                string   `json:"issuer"`
+		JWKSURI               string   `json:"jwks_uri"`
+		AuthorizationEndpoint string   `json:"authorization_endpoint"`
+		TokenEndpoint         string   `json:"token_endpoint"`
+		EndSessionEndpoint    string   `json:"end_session_endpoint"`
+		TokenAuthMethods      []string `json:"token_endpoint_auth_methods_supported"`
+	}
+	if err := v.getJSON(ctx, strings.TrimSuffix(issuer, "/")+"/.well-known/openid-configuration", &doc); err != nil {
+		return nil, err
+	}
+	// OpenID Connect Discovery §4.3: the document must name the issuer it
+	// was fetched for, or a compromised host could vouch for another.
+	if doc.Issuer != issuer {
+		return nil, fmt.Errorf("%w: discovery document for %s names issuer %q", ErrIDPUnreachable, issuer, doc.Issuer)
+	}
+	if doc.JWKSURI == "" {
+		return nil, fmt.Errorf("%w: discovery document for %s has no jwks_uri", ErrIDPUnreachable, issuer)
+	}
+	keys, err := v.fetchKeys(ctx, doc.JWKSURI)
+	if err != nil {
+		return nil, err
+	}
+	return &oidcIssuer{
+		jwksURI: doc.JWKSURI,
+		keys:    keys,
+		endpoints: OIDCEndpoints{
+			Authorization:    doc.AuthorizationEndpoint,
+			Token:            doc.TokenEndpoint,
+			EndSession:       doc.EndSessionEndpoint,
+			TokenAuthMethods: doc.TokenAuthMethods,
+		},
+	}, nil
+}
+
+// requireHTTPS refuses identity provider URLs that are not https: tenants
+// choose them, and documents fetched in the clear could be swapped.
+func requireHTTPS(uri string) error {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		return fmt.Errorf("%w: %q is not an https URL", ErrIDPUnreachable, uri)
+	}
+	return nil
+}
+
+// newIDPClient returns the default client for identity provider documents
+// and token requests. Tenants choose the URLs it fetches, so it connects
+// only to public addresses: discovery must not reach the cloud metadata
+// service, loopback services or the internal network. Redirects must stay
+// on https, and no proxy is used since it would dial on the client's
+// behalf.
+func newIDPClient() *http.Client {
+	dialer := &net.Dialer{Timeout: 10 * time.Second, Control: dialPublicOnly}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{
+		Timeout:   10 * time.Second,
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 10 {
+				return errors.New("stopped after 10 redirects")
+			}
+			return requireHTTPS(req.URL.String())
+		},
+	}
+}
+
+// dialPublicOnly is a net.Dialer Control hook. It sees the resolved
+// address of every connection, so a hostname whose DNS answer is internal
+// (or changes to one after a first check) is refused too.
+func dialPublicOnly(_, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return fmt.Errorf("identity provider address %q: %w", host, err)
+	}
+	if !isPublicAddr(ip) {
+		return fmt.Errorf("identity provider address %s is not public", ip)
+	}
+	return nil
+}
+
+// nonPublicPrefixes are the special-purpose ranges netip's predicates do
+// not cover: "this network", carrier-grade NAT, IETF protocol assignments,
+// benchmarking, reserved, and NAT64, which can embed any IPv4 address.
+var nonPublicPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+}
+
+// isPublicAddr reports whether ip is a globally routable unicast address:
+// not loopback, link-local (which holds 169.254.169.254), private,
+// multicast, unspecified or another special-purpose range.
+func isPublicAddr(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	if !ip.IsGlobalUnicast() || ip.IsPrivate() {
+		return false
+	}
+	for _, p := range nonPublicPrefixes {
+		if p.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// jsonWebKey holds the JWK members of the RSA and EC signing keys.
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (v *OIDCVerifier) fetchKeys(ctx context.Context, uri string) (map[string]crypto.PublicKey, error) {
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := v.getJSON(ctx, uri, &set); err != nil {
+		return nil, err
+	}
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		// Keys of unsupported types are skipped, not fatal: issuers
+		// publish encryption and newer key types alongside signing keys.
+		if key, err := jwk.publicKey(); err == nil {
+			keys[jwk.Kid] = key
+		}
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("%w: key set %s has no usable signing keys", ErrIDPUnreachable, uri)
+	}
+	return keys, nil
+}
+
+func (v *OIDCVerifier) getJSON(ctx context.Context, uri string, dst interface{}) error {
+	if err := requireHTTPS(uri); err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, uri, nil)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrIDPUnreachable, err)
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrIDPUnreachable, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%w: GET %s: status %d", ErrIDPUnreachable, uri, resp.StatusCode)
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, oidcMaxDocument)).Decode(dst); err != nil {
+		return fmt.Errorf("%w: GET %s: %v", ErrIDPUnreachable, uri, err)
+	}
+	return nil
+}
+
+func (k *jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := jwkInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := jwkInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		if n.BitLen() < 2048 || !e.IsInt64() || e.Int64() < 3 || e.Int64() > 1<<31-1 {
+			return nil, errors.New("weak or malformed RSA key")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		size := (curve.Params().BitSize + 7) / 8
+		if len(x) != size || len(y) != size {
+			return nil, errors.New("malformed EC key")
+		}
+		// ParseUncompressedPublicKey rejects points off the curve.
+		point := append(append([]byte{4}, x...), y...)
+		return ecdsa.ParseUncompressedPublicKey(curve, point)
+	}
+	return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+}
+
+func jwkInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || len(b) == 0 {
+		return nil, errors.New("malformed key parameter")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
@@ -0,0 +1,338 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testIdP is an in-process OpenID provider serving discovery and JWKS.
+type testIdP struct {
+	srv         *httptest.Server
+	jwksHits    atomic.Int32
+	mu          sync.Mutex
+	keys        map[string]interface{} // kid → private key
+	published   []string               // kids in the served key set
+	issuerName  string                 // overrides the discovery issuer
+	authMethods []string               // token_endpoint_auth_methods_supported
+	// token answers the token endpoint with a status and JSON body.
+	token func(r *http.Request) (int, interface{})
+}
+
+func newTestIdP(t *testing.T) *testIdP {
+	t.Helper()
+	idp := &testIdP{keys: map[string]interface{}{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		issuer := idp.issuer()
+		if idp.issuerName != "" {
+			issuer = idp.issuerName
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"issuer":                                issuer,
+			"jwks_uri":                              idp.srv.URL + "/jwks",
+			"authorization_endpoint":                idp.srv.URL + "/authorize",
+			"token_endpoint":                        idp.srv.URL + "/token",
+			"end_session_endpoint":                  idp.srv.URL + "/logout",
+			"token_endpoint_auth_methods_supported": idp.authMethods,
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if idp.token == nil {
+			http.NotFound(w, r)
+			return
+		}
+		status, body := idp.token(r)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		_ = json.NewEncoder(w).Encode(body)
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		idp.jwksHits.Add(1)
+		idp.mu.Lock()
+		defer idp.mu.Unlock()
+		var keys []map[string]string
+		for _, kid := range idp.published {
+			keys = append(keys, publicJWK(kid, idp.keys[kid]))
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"keys": keys})
+	})
+	idp.srv = httptest.NewTLSServer(mux)
+	t.Cleanup(idp.srv.Close)
+	idp.addRSAKey(t, "rsa-1")
+	return idp
+}
+
+func (idp *testIdP) issuer() string { return idp.srv.URL }
+
+func (idp *testIdP) addRSAKey(t *testing.T, kid string) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	idp.addKey(kid, key)
+}
+
+func (idp *testIdP) addKey(kid string, key interface{}) {
+	idp.mu.Lock()
+	defer idp.mu.Unlock()
+	idp.keys[kid] = key
+	idp.published = append(idp.published, kid)
+}
+
+// sign issues a token for claims, filling in iss, aud, sub, iat and exp
+// unless they are set. A nil value omits the claim.
+func (idp *testIdP) sign(t *testing.T, kid string, claims jwt.MapClaims) string {
+	t.Helper()
+	defaults := jwt.MapClaims{
+		"iss": idp.issuer(),
+		"aud": "sts.vaultaire",
+		"sub": "repo:acme/app:ref:refs/heads/main",
+		"iat": time.Now().Unix(),
+		"exp": time.Now().Add(5 * time.Minute).Unix(),
+	}
+	for k, v := range defaults {
+		if _, ok := claims[k]; !ok {
+			claims[k] = v
+		}
+	}
+	for k, v := range claims {
+		if v == nil {
+			delete(claims, k)
+		}
+	}
+	idp.mu.Lock()
+	key := idp.keys[kid]
+	idp.mu.Unlock()
+	method := jwt.SigningMethod(jwt.SigningMethodRS256)
+	if _, ok := key.(*ecdsa.PrivateKey); ok {
+		method = jwt.SigningMethodES256
+	}
+	tok := jwt.NewWithClaims(method, claims)
+	tok.Header["kid"] = kid
+	raw, err := tok.SignedString(key)
+	require.NoError(t, err)
+	return raw
+}
+
+func publicJWK(kid string, key interface{}) map[string]string {
+	b64 := func(b []byte) string { return base64.RawURLEncoding.EncodeToString(b) }
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		return map[string]string{
+			"kty": "RSA", "kid": kid, "use": "sig", "alg": "RS256",
+			"n": b64(k.N.Bytes()), "e": b64(big.NewInt(int64(k.E)).Bytes()),
+		}
+	case *ecdsa.PrivateKey:
+		return map[string]string{
+			"kty": "EC", "kid": kid, "use": "sig", "crv": "P-256",
+			"x": b64(k.X.FillBytes(make([]byte, 32))), "y": b64(k.Y.FillBytes(make([]byte, 32))),
+		}
+	}
+	return nil
+}
+
+func TestOIDCVerifier_Verify(t *testing.T) {
+	idp := newTestIdP(t)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	idp.addKey("ec-1", ecKey)
+	v := NewOIDCVerifier(idp.srv.Client())
+	ctx := context.Background()
+	auds := []string{"other", "sts.vaultaire"}
+
+	claims, err := v.Verify(ctx, idp.sign(t, "rsa-1", jwt.MapClaims{"repository": "acme/app"}), idp.issuer(), auds)
+	require.NoError(t, err)
+	assert.Equal(t, "acme/app", claims["repository"])
+
+	_, err = v.Verify(ctx, idp.sign(t, "ec-1", jwt.MapClaims{}), idp.issuer(), auds)
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), idp.jwksHits.Load(), "key set is cached")
+
+	tests := []struct {
+		name   string
+		claims jwt.MapClaims
+		want   error
+	}{
+		{"expired", jwt.MapClaims{"exp": time.Now().Add(-time.Hour).Unix()}, ErrIDTokenExpired},
+		{"wrong audience", jwt.MapClaims{"aud": "someone-else"}, ErrInvalidIDToken},
+		{"wrong issuer", jwt.MapClaims{"iss": "https://evil.example"}, ErrInvalidIDToken},
+		{"no sub", jwt.MapClaims{"sub": ""}, ErrInvalidIDToken},
+		{"no exp", jwt.MapClaims{"exp": nil}, ErrInvalidIDToken},
+		{"not yet valid", jwt.MapClaims{"nbf": time.Now().Add(time.Hour).Unix()}, ErrInvalidIDToken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := v.Verify(ctx, idp.sign(t, "rsa-1", tt.claims), idp.issuer(), auds)
+			assert.ErrorIs(t, err, tt.want)
+		})
+	}
+}
+
+func TestOIDCVerifier_RejectsForgeries(t *testing.T) {
+	idp := newTestIdP(t)
+	v := NewOIDCVerifier(idp.srv.Client())
+	ctx := context.Background()
+	auds := []string{"sts.vaultaire"}
+
+	// HMAC-signed with the public modulus as the secret: the classic
+	// algorithm confusion attack.
+	hs := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"iss": idp.issuer(), "aud": "sts.vaultaire", "sub": "x", "exp": time.Now().Add(time.Minute).Unix(),
+	})
+	hs.Header["kid"] = "rsa-1"
+	raw, err := hs.SignedString(idp.keys["rsa-1"].(*rsa.PrivateKey).N.Bytes())
+	require.NoError(t, err)
+	_, err = v.Verify(ctx, raw, idp.issuer(), auds)
+	assert.ErrorIs(t, err, ErrInvalidIDToken)
+
+	// Signed by a key the issuer does not publish.
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	forged := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss": idp.issuer(), "aud": "sts.vaultaire", "sub": "x", "exp": time.Now().Add(time.Minute).Unix(),
+	})
+	forged.Header["kid"] = "rsa-1"
+	raw, err = forged.SignedString(other)
+	require.NoError(t, err)
+	_, err = v.Verify(ctx, raw, idp.issuer(), auds)
+	assert.ErrorIs(t, err, ErrInvalidIDToken)
+
+	_, err = v.Verify(ctx, "not-a-jwt", idp.issuer(), auds)
+	assert.ErrorIs(t, err, ErrInvalidIDToken)
+}
+
+func TestOIDCVerifier_KeyRotation(t *testing.T) {
+	idp := newTestIdP(t)
+	v := NewOIDCVerifier(idp.srv.Client())
+	now := time.Now()
+	v.now = func() time.Time { return now }
+	ctx := context.Background()
+	auds := []string{"sts.vaultaire"}
+
+	_, err := v.Verify(ctx, idp.sign(t, "rsa-1", jwt.MapClaims{}), idp.issuer(), auds)
+	require.NoError(t, err)
+
+	idp.addRSAKey(t, "rsa-2")
+	token := idp.sign(t, "rsa-2", jwt.MapClaims{})
+
+	// Within the refresh interval an unknown kid does not refetch.
+	_, err = v.Verify(ctx, token, idp.issuer(), auds)
+	assert.ErrorIs(t, err, ErrInvalidIDToken)
+	assert.Equal(t, int32(1), idp.jwksHits.Load())
+
+	now = now.Add(2 * oidcRefreshInterval)
+	_, err = v.Verify(ctx, token, idp.issuer(), auds)
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), idp.jwksHits.Load())
+}
+
+func TestOIDCVerifier_Discovery(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("issuer mismatch", func(t *testing.T) {
+		idp := newTestIdP(t)
+		idp.issuerName = "https://accounts.example.com"
+		_, err := NewOIDCVerifier(idp.srv.Client()).Verify(ctx, idp.sign(t, "rsa-1", jwt.MapClaims{}), idp.issuer(), []string{"sts.vaultaire"})
+		assert.ErrorIs(t, err, ErrIDPUnreachable)
+	})
+
+	t.Run("unreachable", func(t *testing.T) {
+		idp := newTestIdP(t)
+		raw := idp.sign(t, "rsa-1", jwt.MapClaims{})
+		idp.srv.Close()
+		_, err := NewOIDCVerifier(idp.srv.Client()).Verify(ctx, raw, idp.issuer(), []string{"sts.vaultaire"})
+		assert.ErrorIs(t, err, ErrIDPUnreachable)
+	})
+
+	t.Run("stale keys survive an outage", func(t *testing.T) {
+		idp := newTestIdP(t)
+		v := NewOIDCVerifier(idp.srv.Client())
+		now := time.Now()
+		v.now = func() time.Time { return now }
+		raw := idp.sign(t, "rsa-1", jwt.MapClaims{"exp": now.Add(3 * time.Hour).Unix()})
+		_, err := v.Verify(ctx, raw, idp.issuer(), []string{"sts.vaultaire"})
+		require.NoError(t, err)
+
+		idp.srv.Close()
+		now = now.Add(oidcCacheTTL + time.Minute)
+		_, err = v.Verify(ctx, raw, idp.issuer(), []string{"sts.vaultaire"})
+		assert.NoError(t, err)
+	})
+
+	t.Run("refuses http", func(t *testing.T) {
+		idp := newTestIdP(t)
+		plain := strings.Replace(idp.issuer(), "https://", "http://", 1)
+		_, err := NewOIDCVerifier(idp.srv.Client()).Endpoints(ctx, plain)
+		assert.ErrorIs(t, err, ErrIDPUnreachable)
+		assert.ErrorContains(t, err, "not an https URL")
+		assert.Zero(t, idp.jwksHits.Load())
+	})
+
+	t.Run("default client refuses internal addresses", func(t *testing.T) {
+		idp := newTestIdP(t)
+		_, err := NewOIDCVerifier(nil).Endpoints(ctx, idp.issuer())
+		assert.ErrorIs(t, err, ErrIDPUnreachable)
+		assert.ErrorContains(t, err, "is not public")
+	})
+}
+
+func TestIsPublicAddr(t *testing.T) {
+	for addr, want := range map[string]bool{
+		"8.8.8.8":              true,
+		"2606:4700::1111":      true,
+		"127.0.0.1":            false,
+		"::1":                  false,
+		"169.254.169.254":      false,
+		"fe80::1":              false,
+		"10.0.0.5":             false,
+		"172.16.0.1":           false,
+		"192.168.1.1":          false,
+		"fd00::1":              false,
+		"100.64.0.1":           false,
+		"0.0.0.0":              false,
+		"::ffff:127.0.0.1":     false,
+		"::ffff:10.1.2.3":      false,
+		"64:ff9b::a9fe:a9fe":   false,
+		"224.0.0.1":            false,
+		"255.255.255.255":      false,
+		"::ffff:93.184.216.34": true,
+	} {
+		assert.Equal(t, want, isPublicAddr(netip.MustParseAddr(addr)), addr)
+	}
+}
+
+func TestJSONWebKey_PublicKey(t *testing.T) {
+	weak, err := rsa.GenerateKey(rand.Reader, 1024)
+	require.NoError(t, err)
+	jwk := publicJWK("weak", weak)
+	k := jsonWebKey{Kty: jwk["kty"], N: jwk["n"], E: jwk["e"]}
+	_, err = k.publicKey()
+	assert.Error(t, err, "RSA keys under 2048 bits are rejected")
+
+	ec, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	jwk = publicJWK("ec", ec)
+	k = jsonWebKey{Kty: "EC", Crv: "P-256", X: jwk["x"], Y: jwk["x"]}
+	_, err = k.publicKey()
+	assert.Error(t, err, "points off the curve are rejected")
+
+	_, err = (&jsonWebKey{Kty: "OKP"}).publicKey()
+	assert.Error(t, err)
+}
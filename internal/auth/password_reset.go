@@ -39,7 +39,7 @@ func (a *AuthService) RequestPasswordReset(ctx context.Context, email string) (s
 	email = strings.ToLower(strings.TrimSpace(email))
 
 	user, err := a.GetUserByEmail(ctx, email)
-	if err != nil {
+	if err != nil || user.Deactivated {
 		return "", fmt.Errorf("user not found")
 	}
 
@@ -78,7 +78,7 @@ func (a *AuthService) CompletePasswordReset(ctx context.Context, token, newPassw
 	}
 
 	user, exists := a.userIndex[userID]
-	if !exists {
+	if !exists || user.Deactivated {
 		return "", fmt.Errorf("user not found")
 	}
 
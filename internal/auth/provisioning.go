@@ -0,0 +1,225 @@
+// internal/auth/provisioning.go
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/FairForge/vaultaire/internal/rbac"
+	"github.com/lib/pq"
+)
+
+var (
+	// ErrNoMappedRole means the user authenticated but is in no group
+	// mapped to a role and there is no default role.
+	ErrNoMappedRole = errors.New("no role mapped to the user's groups")
+	// ErrAccountConflict means a local account already uses the external
+	// user's email and may not be taken over.
+	ErrAccountConflict = errors.New("email belongs to an existing account")
+	// ErrUserDeactivated means the user's organisation has deprovisioned
+	// them.
+	ErrUserDeactivated = errors.New("user has been deactivated")
+)
+
+// ExternalIdentity is a user an external identity provider (a directory,
+// a SAML IdP or an OIDC provider) has authenticated.
+type ExternalIdentity struct {
+	Provider    string   // names the user's linked account, e.g. "ldap", "saml" or "oidc:<id>"
+	ID          string   // stable ID at the provider
+	Subject     string   // DN or NameID, for logs
+	Username    string   // login name, may be empty
+	Email       string   // lower-cased
+	DisplayName string   // display name, may be empty
+	Groups      []string // group names; normalized DNs for directories
+	Roles       []string // rbac roles the groups map to, sorted
+}
+
+// RoleMapping decides an external user's roles from their groups and
+// provisions their dashboard account in the identity provider's tenant.
+type RoleMapping struct {
+	// GroupRoles maps group names (compared case-insensitively) to rbac
+	// role names.
+	GroupRoles map[string]string `json:"group_roles"`
+	// DefaultRole is granted to users in no mapped group. Empty refuses
+	// them sign-in.
+	DefaultRole string `json:"default_role"`
+	// LinkExistingAccounts lets an external user sign in to a local
+	// account registered with the same email. Leave it off unless users
+	// cannot change the email the provider asserts: otherwise one could
+	// claim another's account.
+	LinkExistingAccounts bool `json:"link_existing_accounts"`
+}
+
+// Validate checks the role names.
+func (m *RoleMapping) Validate() error {
+	for group, role := range m.GroupRoles {
+		if group == "" {
+			return errors.New("group_roles has an empty group name")
+		}
+		if err := rbac.ValidateRoleName(role); err != nil {
+			return fmt.Errorf("group %q: %w", group, err)
+		}
+	}
+	if m.DefaultRole != "" {
+		if err := rbac.ValidateRoleName(m.DefaultRole); err != nil {
+			return fmt.Errorf("default_role: %w", err)
+		}
+	}
+	return nil
+}
+
+// ResolveRoles returns the sorted roles groups map to, or the default role
+// when none are mapped.
+func (m *RoleMapping) ResolveRoles(groups []string) []string {
+	seen := make(map[string]bool)
+	var roles []string
+	for _, g := range groups {
+		for group, role := range m.GroupRoles {
+			if strings.EqualFold(g, group) && !seen[role] {
+				seen[role] = true
+				roles = append(roles, role)
+			}
+		}
+	}
+	if len(roles) == 0 && m.DefaultRole != "" {
+		roles = append(roles, m.DefaultRole)
+	}
+	sort.Strings(roles)
+	return roles
+}
+
+// managedRoles returns every role the mapping can grant. Sign-in replaces
+// a user's grants of these roles; other grants are left alone.
+func (m *RoleMapping) managedRoles() []string {
+	seen := make(map[string]bool)
+	var roles []string
+	for _, role := range m.GroupRoles {
+		if !seen[role] {
+			seen[role] = true
+			roles = append(roles, role)
+		}
+	}
+	if m.DefaultRole != "" && !seen[m.DefaultRole] {
+		roles = append(roles, m.DefaultRole)
+	}
+	sort.Strings(roles)
+	return roles
+}
+
+// DashboardRole returns the users.role value for a set of rbac roles: the
+// dashboard distinguishes only administrators.
+func DashboardRole(roles []string) string {
+	for _, r := range roles {
+		if r == rbac.RoleAdmin {
+			return rbac.RoleAdmin
+		}
+	}
+	return rbac.RoleUser
+}
+
+// Provision finds or creates the member of tenantID for id, then syncs
+// its roles. Every user of an identity provider belongs to the one tenant
+// it is configured for, so closed signups do not block them. A member SCIM
+// provisioned with the same email is linked, as is, with
+// LinkExistingAccounts, any user already in the tenant; a user of another
+// tenant never is. SCIM owns the roles of the members it manages, and
+// deactivated members are refused.
+func (m *RoleMapping) Provision(ctx context.Context, a *AuthService, tenantID string, id *ExternalIdentity) (*User, error) {
+	if !strings.Contains(id.Email, "@") {
+		return nil, fmt.Errorf("%s: %s has no email address", id.Provider, id.Subject)
+	}
+
+	user, err := a.GetUserByOAuth(ctx, id.Provider, id.ID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		if existing, _ := a.GetUserByEmail(ctx, id.Email); existing != nil {
+			if existing.TenantID != tenantID {
+				return nil, ErrAccountConflict
+			}
+			scimManaged, err := a.isSCIMManaged(ctx, tenantID, existing.ID)
+			if err != nil {
+				return nil, err
+			}
+			if !m.LinkExistingAccounts && !scimManaged {
+				return nil, ErrAccountConflict
+			}
+			if err := a.LinkOAuthAccount(ctx, existing.ID, id.Provider, id.ID, id.Email, id.DisplayName); err != nil {
+				return nil, err
+			}
+			user = existing
+		} else {
+			user, err = a.CreateTenantMember(ctx, tenantID, id.Email, id.DisplayName, id.Provider, id.ID)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	if user.TenantID != tenantID {
+		return nil, ErrAccountConflict
+	}
+	if user.Deactivated {
+		return nil, ErrUserDeactivated
+	}
+
+	scimManaged, err := a.isSCIMManaged(ctx, tenantID, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !scimManaged {
+		if err := m.syncRoles(ctx, a, user.ID, id.Roles); err != nil {
+			return nil, err
+		}
+	}
+	return user, nil
+}
+
+// syncRoles makes the user's grants of managed roles match roles and sets
+// the dashboard role to match.
+func (m *RoleMapping) syncRoles(ctx context.Context, a *AuthService, userID string, roles []string) error {
+	if a.sqlDB == nil {
+		return nil
+	}
+	tx, err := a.sqlDB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("sync roles: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := syncUserRoles(ctx, tx, userID, m.managedRoles(), roles); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// syncUserRoles replaces the user's grants of managed roles with roles and
+// sets the dashboard role to match.
+func syncUserRoles(ctx context.Context, tx *sql.Tx, userID string, managed, roles []string) error {
+	if _, err := tx.ExecContext(ctx, `UPDATE users SET role = $2, updated_at = NOW() WHERE id = $1`,
+		userID, DashboardRole(roles)); err != nil {
+		return fmt.Errorf("sync roles: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `
+		DELETE FROM user_roles
+		WHERE user_id = $1
+		  AND role_id IN (SELECT id FROM roles WHERE name = ANY($2))
+		  AND NOT (role_id IN (SELECT id FROM roles WHERE name = ANY($3)))
+	`, userID, pq.Array(managed), pq.Array(roles)); err != nil {
+		return fmt.Errorf("sync roles: %w", err)
+	}
+	// Roles missing from the roles table are skipped rather than failing
+	// sign-in; the dashboard role above still applies.
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO user_roles (user_id, role_id)
+		SELECT $1, id FROM roles WHERE name = ANY($2)
+		ON CONFLICT (user_id, role_id) DO NOTHING
+	`, userID, pq.Array(roles)); err != nil {
+		return fmt.Errorf("sync roles: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+// internal/auth/provisioning_test.go
+package auth
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRoleMapping_Validate(t *testing.T) {
+	assert.NoError(t, (&RoleMapping{GroupRoles: map[string]string{"Admins": "admin"}, DefaultRole: "viewer"}).Validate())
+	assert.Error(t, (&RoleMapping{GroupRoles: map[string]string{"Admins": "Super Admin"}}).Validate())
+	assert.Error(t, (&RoleMapping{GroupRoles: map[string]string{"": "admin"}}).Validate())
+	assert.Error(t, (&RoleMapping{DefaultRole: "x"}).Validate())
+}
+
+func TestRoleMapping_ResolveRoles(t *testing.T) {
+	m := &RoleMapping{GroupRoles: map[string]string{"Storage-Admins": "admin", "staff": "user", "Everyone": "user"}}
+	assert.Equal(t, []string{"admin", "user"}, m.ResolveRoles([]string{"staff", "storage-admins", "everyone"}))
+	assert.Empty(t, m.ResolveRoles([]string{"auditors"}))
+
+	m.DefaultRole = "guest"
+	assert.Equal(t, []string{"guest"}, m.ResolveRoles(nil))
+	assert.Equal(t, []string{"admin", "guest", "user"}, m.managedRoles())
+}
+
+func TestDashboardRole(t *testing.T) {
+	assert.Equal(t, "admin", DashboardRole([]string{"admin", "user"}))
+	assert.Equal(t, "user", DashboardRole([]string{"viewer"}))
+	assert.Equal(t, "user", DashboardRole(nil))
+}
+
+func TestRoleMapping_Provision(t *testing.T) {
+	ctx := context.Background()
+	id := &ExternalIdentity{Provider: DirectoryLDAP, ID: "u-1", Subject: "uid=jsmith", Email: "jsmith@example.com", Roles: []string{"user"}}
+
+	setup := func(t *testing.T) (*AuthService, string) {
+		t.Helper()
+		svc := NewAuthService(nil, nil)
+		_, tenant, _, err := svc.CreateUserWithTenant(ctx, "owner@example.com", "owner-pw-123", "Example")
+		require.NoError(t, err)
+		return svc, tenant.ID
+	}
+
+	t.Run("creates a member of the tenant on first sign-in", func(t *testing.T) {
+		svc, tenantID := setup(t)
+		svc.SetSignupsEnabled(false) // members are not signups
+		user, err := (&RoleMapping{}).Provision(ctx, svc, tenantID, id)
+		require.NoError(t, err)
+		assert.Equal(t, "jsmith@example.com", user.Email)
+		assert.Equal(t, tenantID, user.TenantID)
+		assert.Empty(t, user.PasswordHash)
+	})
+
+	t.Run("puts every user of the provider in one tenant", func(t *testing.T) {
+		svc, tenantID := setup(t)
+		other := &ExternalIdentity{Provider: DirectoryLDAP, ID: "u-2", Subject: "uid=jdoe", Email: "jdoe@example.com", Roles: []string{"user"}}
+
+		first, err := (&RoleMapping{}).Provision(ctx, svc, tenantID, id)
+		require.NoError(t, err)
+		second, err := (&RoleMapping{}).Provision(ctx, svc, tenantID, other)
+		require.NoError(t, err)
+		assert.NotEqual(t, first.ID, second.ID)
+		assert.Equal(t, tenantID, first.TenantID)
+		assert.Equal(t, tenantID, second.TenantID)
+	})
+
+	t.Run("refuses to take over a local account", func(t *testing.T) {
+		svc, tenantID := setup(t)
+		owner := *id
+		owner.Email = "owner@example.com"
+		_, err := (&RoleMapping{}).Provision(ctx, svc, tenantID, &owner)
+		assert.ErrorIs(t, err, ErrAccountConflict)
+	})
+
+	t.Run("links a local account of the tenant when allowed", func(t *testing.T) {
+		svc, tenantID := setup(t)
+		owner := *id
+		owner.Email = "owner@example.com"
+		user, err := (&RoleMapping{LinkExistingAccounts: true}).Provision(ctx, svc, tenantID, &owner)
+		require.NoError(t, err)
+		assert.Equal(t, tenantID, user.TenantID)
+	})
+
+	t.Run("never links another tenant's account", func(t *testing.T) {
+		svc, tenantID := setup(t)
+		_, _, _, err := svc.CreateUserWithTenant(ctx, "jsmith@example.com", "local-pw-123", "Other")
+		require.NoError(t, err)
+
+		_, err = (&RoleMapping{LinkExistingAccounts: true}).Provision(ctx, svc, tenantID, id)
+		assert.ErrorIs(t, err, ErrAccountConflict)
+	})
+
+	t.Run("requires an email", func(t *testing.T) {
+		svc, tenantID := setup(t)
+		noMail := *id
+		noMail.Email = ""
+		_, err := (&RoleMapping{}).Provision(ctx, svc, tenantID, &noMail)
+		assert.Error(t, err)
+	})
+}
+
+func TestRoleMapping_SyncRoles(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() { _ = db.Close() }()
+	svc := NewAuthService(nil, db)
+	m := &RoleMapping{GroupRoles: map[string]string{"storage-admins": "admin", "staff": "user"}, DefaultRole: "viewer"}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE users SET role`).WithArgs("user-1", "user").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`DELETE FROM user_roles`).
+		WithArgs("user-1", pq.Array([]string{"admin", "user", "viewer"}), pq.Array([]string{"user"})).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`INSERT INTO user_roles`).
+		WithArgs("user-1", pq.Array([]string{"user"})).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	require.NoError(t, m.syncRoles(context.Background(), svc, "user-1", []string{"user"}))
+	require.NoError(t, mock.ExpectationsWereMet())
+}
@@ -2,13 +2,22 @@
 package auth
 
 import (
+	"bytes"
 	"compress/flate"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
 	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
 	"encoding/xml"
 	"errors"
 	"fmt"
-	"io"
 	"net/url"
+	"os"
 	"strings"
 	"time"
 
@@ -32,8 +41,10 @@ type SAMLConfig struct {
 	CertificatePEM string `json:"certificate_pem"`
 	PrivateKeyPEM  string `json:"private_key_pem"`
 
-	// Identity Provider settings
+	// Identity Provider settings. IDPCertificate holds one or more PEM
+	// certificates; responses signed by any of them are trusted.
 	IDPMetadataURL string `json:"idp_metadata_url"`
+	IDPEntityID    string `json:"idp_entity_id"`
 	IDPSSOURL      string `json:"idp_sso_url"`
 	IDPSLOURL      string `json:"idp_slo_url"`
 	IDPCertificate string `json:"idp_certificate"`
@@ -44,9 +55,18 @@ type SAMLConfig struct {
 	RequireSignedResponses bool          `json:"require_signed_responses"`
 	RequireSignedAssertion bool          `json:"require_signed_assertion"`
 	ClockSkew              time.Duration `json:"clock_skew"`
+	// AllowIDPInitiated accepts responses that answer no AuthnRequest.
+	// They cannot be tied to the browser that receives them, so leave it
+	// off unless the IdP's portal must launch the dashboard.
+	AllowIDPInitiated bool `json:"allow_idp_initiated"`
 
 	// Attribute mapping
 	AttributeMapping SAMLAttributeMapping `json:"attribute_mapping"`
+
+	// TenantID is the tenant the IdP's users are provisioned into.
+	TenantID string `json:"tenant_id"`
+	// Roles for the groups in AttributeMapping.Groups.
+	RoleMapping
 }
 
 // SAMLAttributeMapping maps SAML attributes to user fields
@@ -75,6 +95,20 @@ func DefaultSAMLConfig() *SAMLConfig {
 	}
 }
 
+// LoadSAMLConfig reads a SAMLConfig from a JSON file and applies defaults.
+func LoadSAMLConfig(path string) (*SAMLConfig, error) {
+	data, err := os.ReadFile(path) // #nosec G304 -- operator-supplied config path
+	if err != nil {
+		return nil, fmt.Errorf("read saml config: %w", err)
+	}
+	var cfg SAMLConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("parse saml config: %w", err)
+	}
+	cfg.ApplyDefaults()
+	return &cfg, nil
+}
+
 // Validate checks if the configuration is valid
 func (c *SAMLConfig) Validate() error {
 	if c.EntityID == "" {
@@ -86,6 +120,12 @@ func (c *SAMLConfig) Validate() error {
 	if c.IDPMetadataURL == "" && c.IDPSSOURL == "" {
 		return errors.New("saml: IDP metadata URL or SSO URL is required")
 	}
+	if c.TenantID == "" {
+		return errors.New("saml: tenant ID is required")
+	}
+	if err := c.RoleMapping.Validate(); err != nil {
+		return fmt.Errorf("saml: %w", err)
+	}
 	return nil
 }
 
@@ -133,6 +173,7 @@ type SAMLResponse struct {
 	Version      string         `xml:"Version,attr"`
 	IssueInstant time.Time      `xml:"IssueInstant,attr"`
 	Destination  string         `xml:"Destination,attr"`
+	Issuer       string         `xml:"Issuer"`
 	Status       SAMLStatus     `xml:"Status"`
 	Assertion    *SAMLAssertion `xml:"Assertion"`
 }
@@ -156,9 +197,16 @@ type SAMLAssertion struct {
 	Issuer             string              `xml:"Issuer"`
 	Subject            SAMLSubject         `xml:"Subject"`
 	Conditions         SAMLConditions      `xml:"Conditions"`
+	AuthnStatement     *AuthnStatement     `xml:"AuthnStatement"`
 	AttributeStatement *AttributeStatement `xml:"AttributeStatement"`
 }
 
+// AuthnStatement records the IdP session the assertion belongs to
+type AuthnStatement struct {
+	AuthnInstant time.Time `xml:"AuthnInstant,attr"`
+	SessionIndex string    `xml:"SessionIndex,attr,omitempty"`
+}
+
 // SAMLSubject represents the subject of an assertion
 type SAMLSubject struct {
 	NameID              SAMLNameID          `xml:"NameID"`
@@ -222,21 +270,29 @@ type SAMLUser struct {
 
 // SAMLAuthResult contains the result of SAML authentication
 type SAMLAuthResult struct {
-	Success     bool
-	NameID      string
-	SessionID   string
-	Email       string
-	DisplayName string
-	FirstName   string
-	LastName    string
-	Groups      []string
-	Attributes  map[string][]string
-	Error       string
-}
-
-// SAMLProvider handles SAML authentication
+	Success      bool
+	NameID       string
+	NameIDFormat string
+	SessionID    string // the IdP's SessionIndex
+	Email        string
+	DisplayName  string
+	FirstName    string
+	LastName     string
+	Groups       []string
+	Attributes   map[string][]string
+	Error        string
+}
+
+// samlRequestTTL bounds how long a user may take at the IdP.
+const samlRequestTTL = 10 * time.Minute
+
+// SAMLProvider handles SAML authentication. It remembers the AuthnRequests
+// it has issued and the assertions it has accepted in its state store, so
+// each response is used once and only in answer to its own request.
 type SAMLProvider struct {
 	config *SAMLConfig
+	now    func() time.Time
+	state  SAMLStateStore
 }
 
 // NewSAMLProvider creates a new SAML provider
@@ -253,12 +309,75 @@ func NewSAMLProvider(config *SAMLConfig) (*SAMLProvider, error) {
 
 	return &SAMLProvider{
 		config: config,
+		now:    time.Now,
+		state:  newMemorySAMLState(),
 	}, nil
 }
 
-// GenerateAuthnRequest creates a new SAML AuthnRequest
-func (p *SAMLProvider) GenerateAuthnRequest() (*SAMLAuthnRequest, error) {
+// SetStateStore replaces the process-local request and replay state, which
+// only works for a single replica, with a shared store.
+func (p *SAMLProvider) SetStateStore(store SAMLStateStore) {
+	p.state = store
+}
+
+// Config returns the provider's configuration.
+func (p *SAMLProvider) Config() *SAMLConfig {
+	return p.config
+}
+
+// idpCertificates parses IDPCertificate. It is parsed on use so that a
+// provider can serve metadata before the IdP is fully configured.
+func (p *SAMLProvider) idpCertificates() ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	rest := []byte(p.config.IDPCertificate)
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("saml: invalid IdP certificate: %w", err)
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, errors.New("saml: no IdP certificate configured")
+	}
+	return certs, nil
+}
+
+// privateKey parses PrivateKeyPEM (PKCS #8 or PKCS #1).
+func (p *SAMLProvider) privateKey() (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode([]byte(p.config.PrivateKeyPEM))
+	if block == nil {
+		return nil, errors.New("saml: no SP private key configured")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("saml: invalid SP private key: %w", err)
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("saml: SP private key must be RSA")
+	}
+	return rsaKey, nil
+}
+
+// GenerateAuthnRequest creates a new SAML AuthnRequest. Its ID is
+// remembered until a response answers it or samlRequestTTL passes.
+func (p *SAMLProvider) GenerateAuthnRequest(ctx context.Context) (*SAMLAuthnRequest, error) {
 	id := "_" + uuid.New().String()
+	if err := p.state.TrackRequest(ctx, id, p.now().Add(samlRequestTTL)); err != nil {
+		return nil, err
+	}
 
 	request := &SAMLAuthnRequest{
 		XMLNS:                       "urn:oasis:names:tc:SAML:2.0:protocol",
@@ -283,50 +402,83 @@ func (p *SAMLProvider) GenerateAuthnRequest() (*SAMLAuthnRequest, error) {
 }
 
 // BuildRedirectURL builds the SSO redirect URL
-func (p *SAMLProvider) BuildRedirectURL(relayState string) (string, error) {
-	request, err := p.GenerateAuthnRequest()
+func (p *SAMLProvider) BuildRedirectURL(ctx context.Context, relayState string) (string, error) {
+	redirectURL, _, err := p.AuthnRequestURL(ctx, relayState)
+	return redirectURL, err
+}
+
+// AuthnRequestURL builds the SSO redirect URL and returns the request's
+// ID, which the caller binds to the browser and passes to
+// ValidateResponse.
+func (p *SAMLProvider) AuthnRequestURL(ctx context.Context, relayState string) (string, string, error) {
+	request, err := p.GenerateAuthnRequest(ctx)
 	if err != nil {
-		return "", fmt.Errorf("saml: failed to generate request: %w", err)
+		return "", "", fmt.Errorf("saml: failed to generate request: %w", err)
 	}
 
 	// Marshal to XML
 	xmlData, err := xml.Marshal(request)
 	if err != nil {
-		return "", fmt.Errorf("saml: failed to marshal request: %w", err)
+		return "", "", fmt.Errorf("saml: failed to marshal request: %w", err)
+	}
+
+	redirectURL, err := p.redirectBindingURL(p.config.IDPSSOURL, "SAMLRequest", xmlData, relayState)
+	if err != nil {
+		return "", "", err
 	}
+	return redirectURL, request.ID, nil
+}
 
+// redirectBindingURL encodes message for the HTTP-Redirect binding: deflated,
+// base64-encoded and, with SignRequests, signed over the query string.
+func (p *SAMLProvider) redirectBindingURL(endpoint, param string, message []byte, relayState string) (string, error) {
 	// Deflate compress
-	var compressed strings.Builder
+	var compressed bytes.Buffer
 	writer, err := flate.NewWriter(&compressed, flate.BestCompression)
 	if err != nil {
 		return "", fmt.Errorf("saml: failed to create compressor: %w", err)
 	}
-	_, err = writer.Write(xmlData)
+	_, err = writer.Write(message)
 	if err != nil {
 		return "", fmt.Errorf("saml: failed to compress: %w", err)
 	}
 	_ = writer.Close()
 
-	// Base64 encode
-	encoded := base64.StdEncoding.EncodeToString([]byte(compressed.String()))
-
 	// Build URL
-	redirectURL, err := url.Parse(p.config.IDPSSOURL)
+	redirectURL, err := url.Parse(endpoint)
 	if err != nil {
-		return "", fmt.Errorf("saml: invalid IDP SSO URL: %w", err)
+		return "", fmt.Errorf("saml: invalid IDP URL: %w", err)
 	}
 
-	query := redirectURL.Query()
-	query.Set("SAMLRequest", encoded)
+	// The signature covers the parameters in this order and encoding, so
+	// the query is assembled by hand.
+	query := param + "=" + url.QueryEscape(base64.StdEncoding.EncodeToString(compressed.Bytes()))
 	if relayState != "" {
-		query.Set("RelayState", relayState)
+		query += "&RelayState=" + url.QueryEscape(relayState)
 	}
-	redirectURL.RawQuery = query.Encode()
+	if p.config.SignRequests {
+		key, err := p.privateKey()
+		if err != nil {
+			return "", err
+		}
+		query += "&SigAlg=" + url.QueryEscape(samlSigAlgRSASHA256)
+		digest := sha256.Sum256([]byte(query))
+		sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+		if err != nil {
+			return "", fmt.Errorf("saml: sign request: %w", err)
+		}
+		query += "&Signature=" + url.QueryEscape(base64.StdEncoding.EncodeToString(sig))
+	}
+	if redirectURL.RawQuery != "" {
+		query = redirectURL.RawQuery + "&" + query
+	}
+	redirectURL.RawQuery = query
 
 	return redirectURL.String(), nil
 }
 
-// ParseResponse parses a SAML response
+// ParseResponse parses a SAML response. It verifies nothing: use
+// ValidateResponse to authenticate a user.
 func (p *SAMLProvider) ParseResponse(samlResponse string) (*SAMLResponse, error) {
 	if samlResponse == "" {
 		return nil, errors.New("saml: empty response")
@@ -349,7 +501,7 @@ func (p *SAMLProvider) ParseResponse(samlResponse string) (*SAMLResponse, error)
 
 // ValidateAssertion validates a SAML assertion
 func (p *SAMLProvider) ValidateAssertion(assertion *SAMLAssertion) error {
-	now := time.Now()
+	now := p.now()
 	clockSkew := p.config.ClockSkew
 
 	// Check NotBefore
@@ -431,13 +583,16 @@ func (p *SAMLProvider) GenerateMetadata() string {
 	sb.WriteString(`" protocolSupportEnumeration="urn:oasis:names:tc:SAML:2.0:protocol">`)
 
 	if p.config.CertificatePEM != "" {
-		sb.WriteString(`<md:KeyDescriptor use="signing"><ds:KeyInfo xmlns:ds="http://www.w3.org/2000/09/xmldsig#"><ds:X509Data><ds:X509Certificate>`)
 		// Strip PEM headers
 		cert := strings.ReplaceAll(p.config.CertificatePEM, "-----BEGIN CERTIFICATE-----", "")
 		cert = strings.ReplaceAll(cert, "-----END CERTIFICATE-----", "")
 		cert = strings.ReplaceAll(cert, "\n", "")
-		sb.WriteString(cert)
-		sb.WriteString(`</ds:X509Certificate></ds:X509Data></ds:KeyInfo></md:KeyDescriptor>`)
+		// The same key signs requests and decrypts assertions.
+		for _, use := range []string{"signing", "encryption"} {
+			sb.WriteString(`<md:KeyDescriptor use="` + use + `"><ds:KeyInfo xmlns:ds="http://www.w3.org/2000/09/xmldsig#"><ds:X509Data><ds:X509Certificate>`)
+			sb.WriteString(cert)
+			sb.WriteString(`</ds:X509Certificate></ds:X509Data></ds:KeyInfo></md:KeyDescriptor>`)
+		}
 	}
 
 	sb.WriteString(`<md:NameIDFormat>`)
@@ -460,6 +615,45 @@ func (p *SAMLProvider) GenerateMetadata() string {
 	return sb.String()
 }
 
+// Provider names the linked accounts of SAML users.
+func (p *SAMLProvider) Provider() string {
+	return "saml"
+}
+
+// Identity turns a validated result into an ExternalIdentity with roles
+// resolved from its groups. The NameID identifies the user, so it should
+// be persistent or an email address, not transient. It returns
+// ErrNoMappedRole when no role applies.
+func (p *SAMLProvider) Identity(res *SAMLAuthResult) (*ExternalIdentity, error) {
+	email := res.Email
+	if email == "" && strings.Contains(res.NameID, "@") {
+		email = res.NameID
+	}
+	displayName := res.DisplayName
+	if displayName == "" {
+		displayName = strings.TrimSpace(res.FirstName + " " + res.LastName)
+	}
+	id := &ExternalIdentity{
+		Provider:    p.Provider(),
+		ID:          res.NameID,
+		Subject:     res.NameID,
+		Email:       strings.ToLower(strings.TrimSpace(email)),
+		DisplayName: displayName,
+		Groups:      res.Groups,
+		Roles:       p.config.RoleMapping.ResolveRoles(res.Groups),
+	}
+	if len(id.Roles) == 0 {
+		return nil, ErrNoMappedRole
+	}
+	return id, nil
+}
+
+// Provision finds or creates the member of the configured tenant for id
+// and syncs its roles; see RoleMapping.Provision.
+func (p *SAMLProvider) Provision(ctx context.Context, a *AuthService, id *ExternalIdentity) (*User, error) {
+	return p.config.RoleMapping.Provision(ctx, a, p.config.TenantID, id)
+}
+
 // NameIDFormat returns the configured NameID format
 func (p *SAMLProvider) NameIDFormat() string {
 	if p.config.NameIDFormat == "" {
@@ -485,6 +679,3 @@ func (p *SAMLProvider) Info() ProviderInfo {
 		Host: p.config.IDPSSOURL,
 	}
 }
-
-// Ensure io import is used
-var _ = io.Discard
@@ -0,0 +1,240 @@
+// internal/auth/saml_logout.go
+package auth
+
+import (
+	"bytes"
+	"compress/flate"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// maxSAMLMessageSize bounds an inflated HTTP-Redirect message.
+const maxSAMLMessageSize = 256 << 10
+
+// samlLogoutRequest is an SP-initiated LogoutRequest.
+type samlLogoutRequest struct {
+	XMLName      xml.Name   `xml:"samlp:LogoutRequest"`
+	XMLNS        string     `xml:"xmlns:samlp,attr"`
+	XMLNSSAML    string     `xml:"xmlns:saml,attr"`
+	ID           string     `xml:"ID,attr"`
+	Version      string     `xml:"Version,attr"`
+	IssueInstant time.Time  `xml:"IssueInstant,attr"`
+	Destination  string     `xml:"Destination,attr"`
+	Issuer       string     `xml:"saml:Issuer"`
+	NameID       SAMLNameID `xml:"saml:NameID"`
+}
+
+// samlLogoutResponse answers an IdP-initiated LogoutRequest.
+type samlLogoutResponse struct {
+	XMLName      xml.Name  `xml:"samlp:LogoutResponse"`
+	XMLNS        string    `xml:"xmlns:samlp,attr"`
+	XMLNSSAML    string    `xml:"xmlns:saml,attr"`
+	ID           string    `xml:"ID,attr"`
+	Version      string    `xml:"Version,attr"`
+	IssueInstant time.Time `xml:"IssueInstant,attr"`
+	Destination  string    `xml:"Destination,attr"`
+	InResponseTo string    `xml:"InResponseTo,attr"`
+	Issuer       string    `xml:"saml:Issuer"`
+	Status       struct {
+		StatusCode struct {
+			Value string `xml:"Value,attr"`
+		} `xml:"samlp:StatusCode"`
+	} `xml:"samlp:Status"`
+}
+
+// SAMLLogoutRequest is a LogoutRequest received from the IdP.
+type SAMLLogoutRequest struct {
+	XMLName      xml.Name   `xml:"LogoutRequest"`
+	ID           string     `xml:"ID,attr"`
+	Destination  string     `xml:"Destination,attr"`
+	NotOnOrAfter time.Time  `xml:"NotOnOrAfter,attr"`
+	Issuer       string     `xml:"Issuer"`
+	NameID       SAMLNameID `xml:"NameID"`
+	SessionIndex []string   `xml:"SessionIndex"`
+	RelayState   string     `xml:"-"`
+}
+
+// LogoutRequestURL returns the IdP URL that ends the IdP session of the
+// user with nameID, over the HTTP-Redirect binding.
+func (p *SAMLProvider) LogoutRequestURL(nameID, nameIDFormat, relayState string) (string, error) {
+	if p.config.IDPSLOURL == "" {
+		return "", errors.New("saml: IdP has no single logout URL")
+	}
+	request := &samlLogoutRequest{
+		XMLNS:        samlProtocolNS,
+		XMLNSSAML:    samlAssertionNS,
+		ID:           "_" + uuid.New().String(),
+		Version:      "2.0",
+		IssueInstant: p.now().UTC(),
+		Destination:  p.config.IDPSLOURL,
+		Issuer:       p.config.EntityID,
+		NameID:       SAMLNameID{Format: nameIDFormat, Value: nameID},
+	}
+	data, err := xml.Marshal(request)
+	if err != nil {
+		return "", fmt.Errorf("saml: failed to marshal logout request: %w", err)
+	}
+	return p.redirectBindingURL(p.config.IDPSLOURL, "SAMLRequest", data, relayState)
+}
+
+// LogoutResponseURL returns the IdP URL reporting that the LogoutRequest
+// with ID inResponseTo was honoured.
+func (p *SAMLProvider) LogoutResponseURL(inResponseTo, relayState string) (string, error) {
+	if p.config.IDPSLOURL == "" {
+		return "", errors.New("saml: IdP has no single logout URL")
+	}
+	response := &samlLogoutResponse{
+		XMLNS:        samlProtocolNS,
+		XMLNSSAML:    samlAssertionNS,
+		ID:           "_" + uuid.New().String(),
+		Version:      "2.0",
+		IssueInstant: p.now().UTC(),
+		Destination:  p.config.IDPSLOURL,
+		InResponseTo: inResponseTo,
+		Issuer:       p.config.EntityID,
+	}
+	response.Status.StatusCode.Value = samlStatusSuccess
+	data, err := xml.Marshal(response)
+	if err != nil {
+		return "", fmt.Errorf("saml: failed to marshal logout response: %w", err)
+	}
+	return p.redirectBindingURL(p.config.IDPSLOURL, "SAMLResponse", data, relayState)
+}
+
+// ParseLogoutRequest verifies and decodes a LogoutRequest received over
+// the HTTP-Redirect binding. rawQuery is the request's query string as
+// sent: the signature, which is required, covers its encoded form.
+func (p *SAMLProvider) ParseLogoutRequest(rawQuery string) (*SAMLLogoutRequest, error) {
+	raw := make(map[string]string)
+	for _, part := range strings.Split(rawQuery, "&") {
+		k, v, _ := strings.Cut(part, "=")
+		if _, dup := raw[k]; dup {
+			return nil, fmt.Errorf("saml: repeated query parameter %s", k)
+		}
+		raw[k] = v
+	}
+	value := func(k string) (string, error) {
+		v, err := url.QueryUnescape(raw[k])
+		if err != nil {
+			return "", fmt.Errorf("saml: invalid %s: %w", k, err)
+		}
+		return v, nil
+	}
+	if raw["SAMLRequest"] == "" {
+		return nil, errors.New("saml: no SAMLRequest")
+	}
+	if raw["SigAlg"] == "" || raw["Signature"] == "" {
+		return nil, errors.New("saml: logout request is not signed")
+	}
+
+	signed := "SAMLRequest=" + raw["SAMLRequest"]
+	if r, ok := raw["RelayState"]; ok {
+		signed += "&RelayState=" + r
+	}
+	signed += "&SigAlg=" + raw["SigAlg"]
+	sigAlg, err := value("SigAlg")
+	if err != nil {
+		return nil, err
+	}
+	sigB64, err := value("Signature")
+	if err != nil {
+		return nil, err
+	}
+	signature, err := base64.StdEncoding.DecodeString(sigB64)
+	if err != nil {
+		return nil, fmt.Errorf("saml: invalid Signature: %w", err)
+	}
+	certs, err := p.idpCertificates()
+	if err != nil {
+		return nil, err
+	}
+	if err := verifyRedirectSignature(certs, sigAlg, signed, signature); err != nil {
+		return nil, err
+	}
+
+	message, err := value("SAMLRequest")
+	if err != nil {
+		return nil, err
+	}
+	data, err := inflateRedirectMessage(message)
+	if err != nil {
+		return nil, err
+	}
+	var request SAMLLogoutRequest
+	if err := xml.Unmarshal(data, &request); err != nil {
+		return nil, fmt.Errorf("saml: invalid logout request: %w", err)
+	}
+	if request.ID == "" || strings.TrimSpace(request.NameID.Value) == "" {
+		return nil, errors.New("saml: logout request has no ID or NameID")
+	}
+	if p.config.IDPEntityID != "" && request.Issuer != p.config.IDPEntityID {
+		return nil, fmt.Errorf("saml: logout request issued by %s", request.Issuer)
+	}
+	if request.Destination != "" && request.Destination != p.config.SLOURL {
+		return nil, fmt.Errorf("saml: logout request is for %s", request.Destination)
+	}
+	if !request.NotOnOrAfter.IsZero() && !p.now().Add(-p.config.ClockSkew).Before(request.NotOnOrAfter) {
+		return nil, errors.New("saml: logout request expired")
+	}
+	request.NameID.Value = strings.TrimSpace(request.NameID.Value)
+	if request.RelayState, err = value("RelayState"); err != nil {
+		return nil, err
+	}
+	return &request, nil
+}
+
+// inflateRedirectMessage decodes an HTTP-Redirect binding message.
+func inflateRedirectMessage(message string) ([]byte, error) {
+	compressed, err := base64.StdEncoding.DecodeString(message)
+	if err != nil {
+		return nil, fmt.Errorf("saml: invalid base64: %w", err)
+	}
+	r := flate.NewReader(bytes.NewReader(compressed))
+	defer func() { _ = r.Close() }()
+	data, err := io.ReadAll(io.LimitReader(r, maxSAMLMessageSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("saml: invalid deflate data: %w", err)
+	}
+	if len(data) > maxSAMLMessageSize {
+		return nil, errors.New("saml: message too large")
+	}
+	return data, nil
+}
+
+// verifyRedirectSignature checks an HTTP-Redirect binding signature over
+// signed against the IdP certificates.
+func verifyRedirectSignature(certs []*x509.Certificate, sigAlg, signed string, signature []byte) error {
+	var (
+		hash   crypto.Hash
+		digest []byte
+	)
+	switch sigAlg {
+	case samlSigAlgRSASHA256:
+		sum := sha256.Sum256([]byte(signed))
+		hash, digest = crypto.SHA256, sum[:]
+	case samlSigAlgRSASHA512:
+		sum := sha512.Sum512([]byte(signed))
+		hash, digest = crypto.SHA512, sum[:]
+	default:
+		return fmt.Errorf("saml: unsupported signature algorithm %q", sigAlg)
+	}
+	for _, cert := range certs {
+		if pub, ok := cert.PublicKey.(*rsa.PublicKey); ok && rsa.VerifyPKCS1v15(pub, hash, digest, signature) == nil {
+			return nil
+		}
+	}
+	return errors.New("saml: invalid logout request signature")
+}
@@ -0,0 +1,131 @@
+// internal/auth/saml_logout_test.go
+package auth
+
+import (
+	"bytes"
+	"compress/flate"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// redirectQuery encodes message for the HTTP-Redirect binding, signed
+// with key when it is non-nil.
+func redirectQuery(t *testing.T, key *rsa.PrivateKey, param, message, relayState string) string {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := flate.NewWriter(&buf, flate.DefaultCompression)
+	require.NoError(t, err)
+	_, _ = w.Write([]byte(message))
+	require.NoError(t, w.Close())
+
+	query := param + "=" + url.QueryEscape(base64.StdEncoding.EncodeToString(buf.Bytes()))
+	if relayState != "" {
+		query += "&RelayState=" + url.QueryEscape(relayState)
+	}
+	if key != nil {
+		query += "&SigAlg=" + url.QueryEscape(samlSigAlgRSASHA256)
+		digest := sha256.Sum256([]byte(query))
+		sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+		require.NoError(t, err)
+		query += "&Signature=" + url.QueryEscape(base64.StdEncoding.EncodeToString(sig))
+	}
+	return query
+}
+
+func testLogoutRequest(issuer string) string {
+	return `<samlp:LogoutRequest xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion" ` +
+		`ID="_lr-1" Version="2.0" IssueInstant="` + time.Now().UTC().Format(time.RFC3339) + `" Destination="https://sp.example.com/saml/slo">` +
+		`<saml:Issuer>` + issuer + `</saml:Issuer><saml:NameID>jsmith</saml:NameID><samlp:SessionIndex>_session-1</samlp:SessionIndex></samlp:LogoutRequest>`
+}
+
+func TestSAMLProvider_ParseLogoutRequest(t *testing.T) {
+	idp := newTestSAMLKey(t, "idp")
+	p, err := NewSAMLProvider(testSAMLConfig(idp))
+	require.NoError(t, err)
+
+	req, err := p.ParseLogoutRequest(redirectQuery(t, idp.key, "SAMLRequest", testLogoutRequest(testIDPEntity), "state 1"))
+	require.NoError(t, err)
+	assert.Equal(t, "_lr-1", req.ID)
+	assert.Equal(t, "jsmith", req.NameID.Value)
+	assert.Equal(t, []string{"_session-1"}, req.SessionIndex)
+	assert.Equal(t, "state 1", req.RelayState)
+
+	_, err = p.ParseLogoutRequest(redirectQuery(t, nil, "SAMLRequest", testLogoutRequest(testIDPEntity), ""))
+	assert.ErrorContains(t, err, "not signed")
+
+	other := newTestSAMLKey(t, "attacker")
+	_, err = p.ParseLogoutRequest(redirectQuery(t, other.key, "SAMLRequest", testLogoutRequest(testIDPEntity), ""))
+	assert.ErrorContains(t, err, "signature")
+
+	// The signature covers RelayState.
+	query := redirectQuery(t, idp.key, "SAMLRequest", testLogoutRequest(testIDPEntity), "a")
+	_, err = p.ParseLogoutRequest(strings.Replace(query, "RelayState=a", "RelayState=b", 1))
+	assert.ErrorContains(t, err, "signature")
+
+	_, err = p.ParseLogoutRequest(redirectQuery(t, idp.key, "SAMLRequest", testLogoutRequest("https://evil.example.com"), ""))
+	assert.ErrorContains(t, err, "issued by")
+}
+
+func TestSAMLProvider_LogoutURLs(t *testing.T) {
+	idp := newTestSAMLKey(t, "idp")
+	sp := newTestSAMLKey(t, "sp")
+	cfg := testSAMLConfig(idp)
+	cfg.SignRequests = true
+	cfg.PrivateKeyPEM = sp.keyPEM
+	p, err := NewSAMLProvider(cfg)
+	require.NoError(t, err)
+	spCert, err := x509.ParseCertificate(sp.der)
+	require.NoError(t, err)
+
+	verify := func(t *testing.T, u, param string) string {
+		t.Helper()
+		parsed, err := url.Parse(u)
+		require.NoError(t, err)
+		assert.Equal(t, "idp.example.com", parsed.Host)
+		signed, sig, ok := strings.Cut(parsed.RawQuery, "&Signature=")
+		require.True(t, ok)
+		sigB64, err := url.QueryUnescape(sig)
+		require.NoError(t, err)
+		signature, err := base64.StdEncoding.DecodeString(sigB64)
+		require.NoError(t, err)
+		require.NoError(t, verifyRedirectSignature([]*x509.Certificate{spCert}, samlSigAlgRSASHA256, signed, signature))
+
+		message, err := inflateRedirectMessage(parsed.Query().Get(param))
+		require.NoError(t, err)
+		return string(message)
+	}
+
+	u, _, err := p.AuthnRequestURL(context.Background(), "state")
+	require.NoError(t, err)
+	assert.Contains(t, verify(t, u, "SAMLRequest"), "AuthnRequest")
+
+	u, err = p.LogoutRequestURL("jsmith", NameIDFormatPersistent, "")
+	require.NoError(t, err)
+	msg := verify(t, u, "SAMLRequest")
+	assert.Contains(t, msg, "LogoutRequest")
+	assert.Contains(t, msg, ">jsmith</saml:NameID>")
+
+	u, err = p.LogoutResponseURL("_lr-1", "state")
+	require.NoError(t, err)
+	msg = verify(t, u, "SAMLResponse")
+	assert.Contains(t, msg, `InResponseTo="_lr-1"`)
+	assert.Contains(t, msg, samlStatusSuccess)
+
+	cfg = testSAMLConfig(idp)
+	cfg.IDPSLOURL = ""
+	p, _ = NewSAMLProvider(cfg)
+	_, err = p.LogoutRequestURL("jsmith", "", "")
+	assert.Error(t, err)
+}
@@ -0,0 +1,169 @@
+// internal/auth/saml_metadata.go
+package auth
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// SAMLBindingHTTPRedirect identifies the HTTP-Redirect binding.
+const SAMLBindingHTTPRedirect = "urn:oasis:names:tc:SAML:2.0:bindings:HTTP-Redirect"
+
+// maxSAMLMetadataSize bounds fetched IdP metadata.
+const maxSAMLMetadataSize = 1 << 20
+
+// SAMLIDPMetadata is what the SP needs from an IdP's metadata.
+type SAMLIDPMetadata struct {
+	EntityID     string
+	SSOURL       string   // HTTP-Redirect SingleSignOnService
+	SLOURL       string   // HTTP-Redirect SingleLogoutService, may be empty
+	Certificates []string // PEM signing certificates
+}
+
+type samlEntityDescriptor struct {
+	XMLName           xml.Name
+	EntityID          string                 `xml:"entityID,attr"`
+	IDPSSODescriptors []samlIDPSSODescriptor `xml:"IDPSSODescriptor"`
+	EntityDescriptors []samlEntityDescriptor `xml:"EntityDescriptor"`
+	Entities          []samlEntityDescriptor `xml:"EntitiesDescriptor"`
+}
+
+type samlIDPSSODescriptor struct {
+	KeyDescriptors []struct {
+		Use          string   `xml:"use,attr"`
+		Certificates []string `xml:"KeyInfo>X509Data>X509Certificate"`
+	} `xml:"KeyDescriptor"`
+	SingleSignOnServices []samlEndpoint `xml:"SingleSignOnService"`
+	SingleLogoutServices []samlEndpoint `xml:"SingleLogoutService"`
+}
+
+type samlEndpoint struct {
+	Binding  string `xml:"Binding,attr"`
+	Location string `xml:"Location,attr"`
+}
+
+// ParseIDPMetadata reads an EntityDescriptor, or an EntitiesDescriptor
+// holding exactly one identity provider.
+func ParseIDPMetadata(data []byte) (*SAMLIDPMetadata, error) {
+	var root samlEntityDescriptor
+	if err := xml.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("saml metadata: invalid XML: %w", err)
+	}
+
+	var idps []*samlEntityDescriptor
+	var collect func(e *samlEntityDescriptor)
+	collect = func(e *samlEntityDescriptor) {
+		if e.XMLName.Local == "EntityDescriptor" && len(e.IDPSSODescriptors) > 0 {
+			idps = append(idps, e)
+		}
+		for i := range e.EntityDescriptors {
+			collect(&e.EntityDescriptors[i])
+		}
+		for i := range e.Entities {
+			collect(&e.Entities[i])
+		}
+	}
+	collect(&root)
+	if len(idps) != 1 {
+		return nil, fmt.Errorf("saml metadata: describes %d identity providers, want 1", len(idps))
+	}
+	entity := idps[0]
+
+	md := &SAMLIDPMetadata{EntityID: entity.EntityID}
+	for _, desc := range entity.IDPSSODescriptors {
+		for _, kd := range desc.KeyDescriptors {
+			if kd.Use != "" && kd.Use != "signing" {
+				continue
+			}
+			for _, c := range kd.Certificates {
+				der, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(c), ""))
+				if err != nil {
+					return nil, fmt.Errorf("saml metadata: invalid certificate: %w", err)
+				}
+				if _, err := x509.ParseCertificate(der); err != nil {
+					return nil, fmt.Errorf("saml metadata: invalid certificate: %w", err)
+				}
+				md.Certificates = append(md.Certificates,
+					string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})))
+			}
+		}
+		for _, ep := range desc.SingleSignOnServices {
+			if ep.Binding == SAMLBindingHTTPRedirect && md.SSOURL == "" {
+				md.SSOURL = ep.Location
+			}
+		}
+		for _, ep := range desc.SingleLogoutServices {
+			if ep.Binding == SAMLBindingHTTPRedirect && md.SLOURL == "" {
+				md.SLOURL = ep.Location
+			}
+		}
+	}
+
+	if md.EntityID == "" {
+		return nil, errors.New("saml metadata: entityID is missing")
+	}
+	if md.SSOURL == "" {
+		return nil, errors.New("saml metadata: no HTTP-Redirect SingleSignOnService")
+	}
+	if len(md.Certificates) == 0 {
+		return nil, errors.New("saml metadata: no signing certificate")
+	}
+	return md, nil
+}
+
+// FetchIDPMetadata downloads and parses IdP metadata. The metadata is
+// trusted as served, so it must come over HTTPS.
+func FetchIDPMetadata(ctx context.Context, client *http.Client, metadataURL string) (*SAMLIDPMetadata, error) {
+	u, err := url.Parse(metadataURL)
+	if err != nil || u.Scheme != "https" {
+		return nil, fmt.Errorf("saml metadata: %q is not an https URL", metadataURL)
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, metadataURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("saml metadata: %w", err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("saml metadata: fetch: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("saml metadata: fetch: %s", resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxSAMLMetadataSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("saml metadata: fetch: %w", err)
+	}
+	if len(data) > maxSAMLMetadataSize {
+		return nil, errors.New("saml metadata: document too large")
+	}
+	return ParseIDPMetadata(data)
+}
+
+// ApplyIDPMetadata fills the IdP settings the config leaves empty from md.
+func (c *SAMLConfig) ApplyIDPMetadata(md *SAMLIDPMetadata) {
+	if c.IDPEntityID == "" {
+		c.IDPEntityID = md.EntityID
+	}
+	if c.IDPSSOURL == "" {
+		c.IDPSSOURL = md.SSOURL
+	}
+	if c.IDPSLOURL == "" {
+		c.IDPSLOURL = md.SLOURL
+	}
+	if c.IDPCertificate == "" {
+		c.IDPCertificate = strings.Join(md.Certificates, "")
+	}
+}
@@ -0,0 +1,90 @@
+// internal/auth/saml_metadata_test.go
+package auth
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testIDPMetadata(idp *testSAMLKey) string {
+	return `<md:EntityDescriptor xmlns:md="urn:oasis:names:tc:SAML:2.0:metadata" entityID="https://idp.example.com">
+  <md:IDPSSODescriptor protocolSupportEnumeration="urn:oasis:names:tc:SAML:2.0:protocol">
+    <md:KeyDescriptor use="signing">
+      <ds:KeyInfo xmlns:ds="http://www.w3.org/2000/09/xmldsig#"><ds:X509Data><ds:X509Certificate>
+        ` + base64.StdEncoding.EncodeToString(idp.der) + `
+      </ds:X509Certificate></ds:X509Data></ds:KeyInfo>
+    </md:KeyDescriptor>
+    <md:KeyDescriptor use="encryption">
+      <ds:KeyInfo xmlns:ds="http://www.w3.org/2000/09/xmldsig#"><ds:X509Data><ds:X509Certificate>bm90IGEgY2VydA==</ds:X509Certificate></ds:X509Data></ds:KeyInfo>
+    </md:KeyDescriptor>
+    <md:SingleLogoutService Binding="urn:oasis:names:tc:SAML:2.0:bindings:HTTP-Redirect" Location="https://idp.example.com/slo"/>
+    <md:SingleSignOnService Binding="urn:oasis:names:tc:SAML:2.0:bindings:HTTP-POST" Location="https://idp.example.com/sso/post"/>
+    <md:SingleSignOnService Binding="urn:oasis:names:tc:SAML:2.0:bindings:HTTP-Redirect" Location="https://idp.example.com/sso"/>
+  </md:IDPSSODescriptor>
+</md:EntityDescriptor>`
+}
+
+func TestParseIDPMetadata(t *testing.T) {
+	idp := newTestSAMLKey(t, "idp")
+
+	md, err := ParseIDPMetadata([]byte(testIDPMetadata(idp)))
+	require.NoError(t, err)
+	assert.Equal(t, "https://idp.example.com", md.EntityID)
+	assert.Equal(t, "https://idp.example.com/sso", md.SSOURL)
+	assert.Equal(t, "https://idp.example.com/slo", md.SLOURL)
+	assert.Equal(t, []string{idp.certPEM}, md.Certificates)
+
+	t.Run("EntitiesDescriptor", func(t *testing.T) {
+		doc := `<md:EntitiesDescriptor xmlns:md="urn:oasis:names:tc:SAML:2.0:metadata">` +
+			`<md:EntityDescriptor entityID="https://sp.example.com"><md:SPSSODescriptor/></md:EntityDescriptor>` +
+			testIDPMetadata(idp) + `</md:EntitiesDescriptor>`
+		md, err := ParseIDPMetadata([]byte(doc))
+		require.NoError(t, err)
+		assert.Equal(t, "https://idp.example.com", md.EntityID)
+
+		doc = `<md:EntitiesDescriptor xmlns:md="urn:oasis:names:tc:SAML:2.0:metadata">` +
+			testIDPMetadata(idp) + testIDPMetadata(idp) + `</md:EntitiesDescriptor>`
+		_, err = ParseIDPMetadata([]byte(doc))
+		assert.ErrorContains(t, err, "2 identity providers")
+	})
+
+	t.Run("rejects incomplete metadata", func(t *testing.T) {
+		_, err := ParseIDPMetadata([]byte(`<md:EntityDescriptor xmlns:md="urn:oasis:names:tc:SAML:2.0:metadata" entityID="x"><md:IDPSSODescriptor/></md:EntityDescriptor>`))
+		assert.ErrorContains(t, err, "SingleSignOnService")
+		_, err = ParseIDPMetadata([]byte(`not xml`))
+		assert.Error(t, err)
+	})
+}
+
+func TestFetchIDPMetadata(t *testing.T) {
+	idp := newTestSAMLKey(t, "idp")
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/metadata" {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(testIDPMetadata(idp)))
+	}))
+	defer srv.Close()
+
+	md, err := FetchIDPMetadata(context.Background(), srv.Client(), srv.URL+"/metadata")
+	require.NoError(t, err)
+
+	cfg := &SAMLConfig{IDPSSOURL: "https://override.example.com/sso"}
+	cfg.ApplyIDPMetadata(md)
+	assert.Equal(t, "https://override.example.com/sso", cfg.IDPSSOURL)
+	assert.Equal(t, "https://idp.example.com", cfg.IDPEntityID)
+	assert.Equal(t, "https://idp.example.com/slo", cfg.IDPSLOURL)
+	assert.Equal(t, idp.certPEM, cfg.IDPCertificate)
+
+	_, err = FetchIDPMetadata(context.Background(), srv.Client(), srv.URL+"/missing")
+	assert.ErrorContains(t, err, "404")
+	_, err = FetchIDPMetadata(context.Background(), nil, "http://idp.example.com/metadata")
+	assert.ErrorContains(t, err, "https")
+}
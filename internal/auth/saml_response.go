@@ -0,0 +1,525 @@
+// internal/auth/saml_response.go
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rsa"
+	_ "crypto/sha1" // #nosec G505 -- SHA-1 is the XML Encryption default OAEP digest
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/beevik/etree"
+	dsig "github.com/russellhaering/goxmldsig"
+	"github.com/russellhaering/goxmldsig/etreeutils"
+)
+
+// SAML and XML Encryption identifiers.
+const (
+	samlProtocolNS  = "urn:oasis:names:tc:SAML:2.0:protocol"
+	samlAssertionNS = "urn:oasis:names:tc:SAML:2.0:assertion"
+	xmlEncNS        = "http://www.w3.org/2001/04/xmlenc#"
+	xmlEnc11NS      = "http://www.w3.org/2009/xmlenc11#"
+
+	samlStatusSuccess = "urn:oasis:names:tc:SAML:2.0:status:Success"
+	samlBearer        = "urn:oasis:names:tc:SAML:2.0:cm:bearer"
+
+	samlSigAlgRSASHA256 = "http://www.w3.org/2001/04/xmldsig-more#rsa-sha256"
+	samlSigAlgRSASHA512 = "http://www.w3.org/2001/04/xmldsig-more#rsa-sha512"
+)
+
+// samlResponseHeader holds the Response fields checked outside the assertion.
+type samlResponseHeader struct {
+	InResponseTo string     `xml:"InResponseTo,attr"`
+	Destination  string     `xml:"Destination,attr"`
+	Issuer       string     `xml:"Issuer"`
+	Status       SAMLStatus `xml:"Status"`
+}
+
+// ValidateResponse authenticates a base64-encoded SAMLResponse from the
+// HTTP-POST binding. requestID is the ID of the AuthnRequest this browser
+// was sent with, or "" if none.
+//
+// The response or its assertion must carry an XML signature by an IdP
+// certificate (both, if so configured); everything in the result is read
+// from the signed elements only, so content wrapped around or beside them
+// is ignored. Encrypted assertions are decrypted with the SP key. Each
+// assertion is accepted once, and only in answer to a pending request
+// unless AllowIDPInitiated is set.
+func (p *SAMLProvider) ValidateResponse(ctx context.Context, samlResponse, requestID string) (*SAMLAuthResult, error) {
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(samlResponse))
+	if err != nil {
+		return nil, fmt.Errorf("saml: invalid base64: %w", err)
+	}
+	doc := etree.NewDocument()
+	if err := doc.ReadFromBytes(decoded); err != nil {
+		return nil, fmt.Errorf("saml: invalid XML: %w", err)
+	}
+	response := doc.Root()
+	if response == nil || response.Tag != "Response" || response.NamespaceURI() != samlProtocolNS {
+		return nil, errors.New("saml: not a SAML response")
+	}
+
+	certs, err := p.idpCertificates()
+	if err != nil {
+		return nil, err
+	}
+	validator := dsig.NewDefaultValidationContext(&dsig.MemoryX509CertificateStore{Roots: certs})
+	validator.Clock = dsig.NewFakeClockAt(p.now()) // validate at the provider's clock
+
+	responseSigned := hasSignature(response)
+	if responseSigned {
+		if response, err = validator.Validate(response); err != nil {
+			return nil, fmt.Errorf("saml: response signature: %w", err)
+		}
+	} else if p.config.RequireSignedResponses {
+		return nil, errors.New("saml: response is not signed")
+	}
+
+	var header samlResponseHeader
+	if err := unmarshalElement(response, &header); err != nil {
+		return nil, err
+	}
+	if header.Status.StatusCode.Value != samlStatusSuccess {
+		return nil, fmt.Errorf("saml: IdP returned status %s", header.Status.StatusCode.Value)
+	}
+	if header.Destination != "" && header.Destination != p.config.ACSURL {
+		return nil, fmt.Errorf("saml: response is for %s", header.Destination)
+	}
+	if p.config.IDPEntityID != "" && header.Issuer != "" && header.Issuer != p.config.IDPEntityID {
+		return nil, fmt.Errorf("saml: response issued by %s", header.Issuer)
+	}
+
+	assertionEl, err := p.responseAssertion(response)
+	if err != nil {
+		return nil, err
+	}
+	assertionSigned := hasSignature(assertionEl)
+	if assertionSigned {
+		if assertionEl, err = validator.Validate(assertionEl); err != nil {
+			return nil, fmt.Errorf("saml: assertion signature: %w", err)
+		}
+	} else if p.config.RequireSignedAssertion || !responseSigned {
+		return nil, errors.New("saml: assertion is not signed")
+	}
+
+	var assertion SAMLAssertion
+	if err := unmarshalElement(assertionEl, &assertion); err != nil {
+		return nil, err
+	}
+	if p.config.IDPEntityID != "" && assertion.Issuer != p.config.IDPEntityID {
+		return nil, fmt.Errorf("saml: assertion issued by %s", assertion.Issuer)
+	}
+	if assertion.ID == "" {
+		return nil, errors.New("saml: assertion has no ID")
+	}
+
+	if header.InResponseTo == "" {
+		if !p.config.AllowIDPInitiated {
+			return nil, errors.New("saml: unsolicited response")
+		}
+	} else if header.InResponseTo != requestID {
+		return nil, errors.New("saml: response does not answer a pending request")
+	} else if pending, err := p.state.TakeRequest(ctx, requestID, p.now()); err != nil {
+		return nil, err
+	} else if !pending {
+		return nil, errors.New("saml: response does not answer a pending request")
+	}
+
+	expiry, err := p.checkSubjectConfirmation(assertionEl, header.InResponseTo)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.checkConditions(assertion.Conditions); err != nil {
+		return nil, err
+	}
+	if err := p.checkAudiences(assertionEl); err != nil {
+		return nil, err
+	}
+	if !assertion.Conditions.NotOnOrAfter.IsZero() && assertion.Conditions.NotOnOrAfter.After(expiry) {
+		expiry = assertion.Conditions.NotOnOrAfter
+	}
+	fresh, err := p.state.ConsumeAssertion(ctx, assertion.ID, expiry.Add(p.config.ClockSkew), p.now())
+	if err != nil {
+		return nil, err
+	}
+	if !fresh {
+		return nil, errors.New("saml: assertion has already been used")
+	}
+
+	nameID := strings.TrimSpace(assertion.Subject.NameID.Value)
+	if nameID == "" {
+		return nil, errors.New("saml: assertion has no NameID")
+	}
+	var attrs []SAMLAttribute
+	if assertion.AttributeStatement != nil {
+		attrs = assertion.AttributeStatement.Attributes
+	}
+	user := p.ExtractAttributes(attrs)
+	result := &SAMLAuthResult{
+		Success:      true,
+		NameID:       nameID,
+		NameIDFormat: assertion.Subject.NameID.Format,
+		Email:        user.Email,
+		DisplayName:  user.DisplayName,
+		FirstName:    user.FirstName,
+		LastName:     user.LastName,
+		Groups:       user.Groups,
+		Attributes:   user.Attributes,
+	}
+	if assertion.AuthnStatement != nil {
+		result.SessionID = assertion.AuthnStatement.SessionIndex
+	}
+	return result, nil
+}
+
+// responseAssertion returns the response's one assertion, decrypted if
+// need be, detached with the namespace declarations it relies on.
+func (p *SAMLProvider) responseAssertion(response *etree.Element) (*etree.Element, error) {
+	ctx, err := etreeutils.NSBuildParentContext(response)
+	if err != nil {
+		return nil, fmt.Errorf("saml: %w", err)
+	}
+	if ctx, err = ctx.SubContext(response); err != nil {
+		return nil, fmt.Errorf("saml: %w", err)
+	}
+
+	var found []*etree.Element
+	for _, child := range response.ChildElements() {
+		if child.NamespaceURI() == samlAssertionNS && (child.Tag == "Assertion" || child.Tag == "EncryptedAssertion") {
+			found = append(found, child)
+		}
+	}
+	if len(found) != 1 {
+		return nil, fmt.Errorf("saml: response has %d assertions, want 1", len(found))
+	}
+	if found[0].Tag == "Assertion" {
+		el, err := etreeutils.NSDetatch(ctx, found[0])
+		if err != nil {
+			return nil, fmt.Errorf("saml: %w", err)
+		}
+		return el, nil
+	}
+	encCtx, err := ctx.SubContext(found[0])
+	if err != nil {
+		return nil, fmt.Errorf("saml: %w", err)
+	}
+	return p.decryptAssertion(encCtx, found[0])
+}
+
+// hasSignature reports whether el has an enveloped XML signature.
+func hasSignature(el *etree.Element) bool {
+	for _, child := range el.ChildElements() {
+		if child.Tag == dsig.SignatureTag && child.NamespaceURI() == dsig.Namespace {
+			return true
+		}
+	}
+	return false
+}
+
+// unmarshalElement decodes el with encoding/xml, which, unlike
+// etree.Element.Text, keeps text split by comments whole.
+func unmarshalElement(el *etree.Element, v any) error {
+	doc := etree.NewDocument()
+	doc.SetRoot(el.Copy())
+	data, err := doc.WriteToBytes()
+	if err != nil {
+		return fmt.Errorf("saml: %w", err)
+	}
+	if err := xml.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("saml: invalid XML: %w", err)
+	}
+	return nil
+}
+
+// checkSubjectConfirmation requires a bearer confirmation addressed to the
+// ACS and returns when it expires.
+func (p *SAMLProvider) checkSubjectConfirmation(assertion *etree.Element, inResponseTo string) (time.Time, error) {
+	now := p.now()
+	for _, subject := range assertion.ChildElements() {
+		if subject.Tag != "Subject" || subject.NamespaceURI() != samlAssertionNS {
+			continue
+		}
+		for _, sc := range subject.ChildElements() {
+			if sc.Tag != "SubjectConfirmation" || sc.NamespaceURI() != samlAssertionNS ||
+				sc.SelectAttrValue("Method", "") != samlBearer {
+				continue
+			}
+			var confirmation SubjectConfirmation
+			if err := unmarshalElement(sc, &confirmation); err != nil {
+				return time.Time{}, err
+			}
+			data := confirmation.SubjectConfirmationData
+			if data.Recipient != p.config.ACSURL || data.InResponseTo != inResponseTo ||
+				data.NotOnOrAfter.IsZero() || !now.Add(-p.config.ClockSkew).Before(data.NotOnOrAfter) {
+				continue
+			}
+			return data.NotOnOrAfter, nil
+		}
+	}
+	return time.Time{}, errors.New("saml: assertion has no valid bearer subject confirmation")
+}
+
+// checkConditions checks the assertion's validity window.
+func (p *SAMLProvider) checkConditions(c SAMLConditions) error {
+	now := p.now()
+	if !c.NotBefore.IsZero() && now.Add(p.config.ClockSkew).Before(c.NotBefore) {
+		return errors.New("saml: assertion not yet valid")
+	}
+	if !c.NotOnOrAfter.IsZero() && !now.Add(-p.config.ClockSkew).Before(c.NotOnOrAfter) {
+		return errors.New("saml: assertion expired")
+	}
+	return nil
+}
+
+// checkAudiences requires every AudienceRestriction to name this SP.
+func (p *SAMLProvider) checkAudiences(assertion *etree.Element) error {
+	for _, conditions := range assertion.ChildElements() {
+		if conditions.Tag != "Conditions" || conditions.NamespaceURI() != samlAssertionNS {
+			continue
+		}
+		for _, restriction := range conditions.ChildElements() {
+			if restriction.Tag != "AudienceRestriction" {
+				continue
+			}
+			var r struct {
+				Audiences []string `xml:"Audience"`
+			}
+			if err := unmarshalElement(restriction, &r); err != nil {
+				return err
+			}
+			ok := false
+			for _, audience := range r.Audiences {
+				ok = ok || strings.TrimSpace(audience) == p.config.EntityID
+			}
+			if !ok {
+				return fmt.Errorf("saml: invalid audience: expected %s, got %s", p.config.EntityID, strings.Join(r.Audiences, ", "))
+			}
+		}
+	}
+	return nil
+}
+
+// decryptAssertion decrypts an EncryptedAssertion with the SP key. The
+// content key must be transported with RSA-OAEP: RSA PKCS #1 v1.5 is
+// refused, as it is open to padding-oracle attacks.
+func (p *SAMLProvider) decryptAssertion(ctx etreeutils.NSContext, encrypted *etree.Element) (*etree.Element, error) {
+	key, err := p.privateKey()
+	if err != nil {
+		return nil, fmt.Errorf("saml: cannot decrypt assertion: %w", err)
+	}
+	encData := findChild(encrypted, xmlEncNS, "EncryptedData")
+	if encData == nil {
+		return nil, errors.New("saml: EncryptedAssertion has no EncryptedData")
+	}
+	encKey := findChild(encrypted, xmlEncNS, "EncryptedKey")
+	if keyInfo := findChild(encData, dsig.Namespace, "KeyInfo"); keyInfo != nil {
+		if k := findChild(keyInfo, xmlEncNS, "EncryptedKey"); k != nil {
+			encKey = k
+		}
+	}
+	if encKey == nil {
+		return nil, errors.New("saml: EncryptedAssertion has no EncryptedKey")
+	}
+
+	cek, err := decryptKey(key, encKey)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := decryptData(cek, encData)
+	if err != nil {
+		return nil, err
+	}
+
+	// The plaintext may use prefixes declared outside it, so it is parsed
+	// inside an element redeclaring them.
+	var wrapped strings.Builder
+	wrapped.WriteString("<wrapper")
+	for prefix, ns := range ctx.Prefixes() {
+		if prefix == "xml" || prefix == "xmlns" {
+			continue
+		}
+		attr := "xmlns"
+		if prefix != "" {
+			attr += ":" + prefix
+		}
+		wrapped.WriteString(" " + attr + `="`)
+		_ = xml.EscapeText(&wrapped, []byte(ns))
+		wrapped.WriteString(`"`)
+	}
+	wrapped.WriteString(">")
+	wrapped.Write(plaintext)
+	wrapped.WriteString("</wrapper>")
+
+	doc := etree.NewDocument()
+	if err := doc.ReadFromString(wrapped.String()); err != nil {
+		return nil, fmt.Errorf("saml: invalid decrypted assertion: %w", err)
+	}
+	children := doc.Root().ChildElements()
+	if len(children) != 1 || children[0].Tag != "Assertion" || children[0].NamespaceURI() != samlAssertionNS {
+		return nil, errors.New("saml: decrypted content is not an assertion")
+	}
+	wrapCtx, err := etreeutils.NSBuildParentContext(children[0])
+	if err != nil {
+		return nil, fmt.Errorf("saml: %w", err)
+	}
+	el, err := etreeutils.NSDetatch(wrapCtx, children[0])
+	if err != nil {
+		return nil, fmt.Errorf("saml: %w", err)
+	}
+	return el, nil
+}
+
+// findChild returns el's first child element with the given namespace and
+// tag.
+func findChild(el *etree.Element, ns, tag string) *etree.Element {
+	for _, child := range el.ChildElements() {
+		if child.Tag == tag && child.NamespaceURI() == ns {
+			return child
+		}
+	}
+	return nil
+}
+
+// cipherValue decodes the CipherData/CipherValue under el.
+func cipherValue(el *etree.Element) ([]byte, error) {
+	data := findChild(el, xmlEncNS, "CipherData")
+	if data == nil {
+		return nil, errors.New("saml: encrypted element has no CipherData")
+	}
+	value := findChild(data, xmlEncNS, "CipherValue")
+	if value == nil {
+		return nil, errors.New("saml: encrypted element has no CipherValue")
+	}
+	decoded, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(value.Text()), ""))
+	if err != nil {
+		return nil, fmt.Errorf("saml: invalid CipherValue: %w", err)
+	}
+	return decoded, nil
+}
+
+// encryptionAlgorithm returns the Algorithm of el's EncryptionMethod.
+func encryptionAlgorithm(el *etree.Element) (*etree.Element, string) {
+	method := findChild(el, xmlEncNS, "EncryptionMethod")
+	if method == nil {
+		return nil, ""
+	}
+	return method, method.SelectAttrValue("Algorithm", "")
+}
+
+var oaepHashes = map[string]crypto.Hash{
+	dsig.Namespace + "sha1": crypto.SHA1,
+	xmlEncNS + "sha256":     crypto.SHA256,
+	xmlEncNS + "sha512":     crypto.SHA512,
+}
+
+var oaepMGFHashes = map[string]crypto.Hash{
+	xmlEnc11NS + "mgf1sha1":   crypto.SHA1,
+	xmlEnc11NS + "mgf1sha256": crypto.SHA256,
+	xmlEnc11NS + "mgf1sha512": crypto.SHA512,
+}
+
+// decryptKey unwraps an EncryptedKey with RSA-OAEP.
+func decryptKey(key *rsa.PrivateKey, encKey *etree.Element) ([]byte, error) {
+	method, alg := encryptionAlgorithm(encKey)
+	opts := &rsa.OAEPOptions{Hash: crypto.SHA1, MGFHash: crypto.SHA1}
+	switch alg {
+	case xmlEncNS + "rsa-oaep-mgf1p", xmlEnc11NS + "rsa-oaep":
+	default:
+		return nil, fmt.Errorf("saml: unsupported key transport algorithm %q", alg)
+	}
+	if digest := findChild(method, dsig.Namespace, "DigestMethod"); digest != nil {
+		h, ok := oaepHashes[digest.SelectAttrValue("Algorithm", "")]
+		if !ok {
+			return nil, fmt.Errorf("saml: unsupported OAEP digest %q", digest.SelectAttrValue("Algorithm", ""))
+		}
+		opts.Hash = h
+		if alg == xmlEncNS+"rsa-oaep-mgf1p" {
+			opts.MGFHash = h
+		}
+	}
+	if mgf := findChild(method, xmlEnc11NS, "MGF"); mgf != nil && alg == xmlEnc11NS+"rsa-oaep" {
+		h, ok := oaepMGFHashes[mgf.SelectAttrValue("Algorithm", "")]
+		if !ok {
+			return nil, fmt.Errorf("saml: unsupported OAEP mask function %q", mgf.SelectAttrValue("Algorithm", ""))
+		}
+		opts.MGFHash = h
+	}
+	ciphertext, err := cipherValue(encKey)
+	if err != nil {
+		return nil, err
+	}
+	cek, err := key.Decrypt(nil, ciphertext, opts)
+	if err != nil {
+		return nil, errors.New("saml: cannot decrypt assertion key")
+	}
+	return cek, nil
+}
+
+var dataCiphers = map[string]struct {
+	keySize int
+	gcm     bool
+}{
+	xmlEncNS + "aes128-cbc":   {16, false},
+	xmlEncNS + "aes192-cbc":   {24, false},
+	xmlEncNS + "aes256-cbc":   {32, false},
+	xmlEnc11NS + "aes128-gcm": {16, true},
+	xmlEnc11NS + "aes192-gcm": {24, true},
+	xmlEnc11NS + "aes256-gcm": {32, true},
+}
+
+// decryptData decrypts EncryptedData with the content key cek.
+func decryptData(cek []byte, encData *etree.Element) ([]byte, error) {
+	_, alg := encryptionAlgorithm(encData)
+	c, ok := dataCiphers[alg]
+	if !ok {
+		return nil, fmt.Errorf("saml: unsupported assertion encryption algorithm %q", alg)
+	}
+	if len(cek) != c.keySize {
+		return nil, errors.New("saml: assertion key has the wrong size")
+	}
+	ciphertext, err := cipherValue(encData)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		return nil, fmt.Errorf("saml: %w", err)
+	}
+
+	if c.gcm {
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, fmt.Errorf("saml: %w", err)
+		}
+		if len(ciphertext) < aead.NonceSize()+aead.Overhead() {
+			return nil, errors.New("saml: encrypted assertion is truncated")
+		}
+		nonce, sealed := ciphertext[:aead.NonceSize()], ciphertext[aead.NonceSize():]
+		plaintext, err := aead.Open(nil, nonce, sealed, nil)
+		if err != nil {
+			return nil, errors.New("saml: cannot decrypt assertion")
+		}
+		return plaintext, nil
+	}
+
+	// CBC, with the IV prepended and XML Encryption padding: only the last
+	// byte, the padding length, is significant.
+	if len(ciphertext) < 2*aes.BlockSize || len(ciphertext)%aes.BlockSize != 0 {
+		return nil, errors.New("saml: cannot decrypt assertion")
+	}
+	iv, body := ciphertext[:aes.BlockSize], ciphertext[aes.BlockSize:]
+	plaintext := make([]byte, len(body))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(plaintext, body)
+	pad := int(plaintext[len(plaintext)-1])
+	if pad == 0 || pad > aes.BlockSize {
+		return nil, errors.New("saml: cannot decrypt assertion")
+	}
+	return plaintext[:len(plaintext)-pad], nil
+}
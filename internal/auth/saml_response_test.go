@@ -0,0 +1,456 @@
+// internal/auth/saml_response_test.go
+package auth
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/beevik/etree"
+	dsig "github.com/russellhaering/goxmldsig"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testSAMLKey is an RSA key with a self-signed certificate.
+type testSAMLKey struct {
+	key     *rsa.PrivateKey
+	der     []byte
+	certPEM string
+	keyPEM  string
+}
+
+func newTestSAMLKey(t *testing.T, cn string) *testSAMLKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+	return &testSAMLKey{
+		key:     key,
+		der:     der,
+		certPEM: string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+		keyPEM:  string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8})),
+	}
+}
+
+// sign returns doc with an enveloped exclusive-C14N signature on its root.
+func (k *testSAMLKey) sign(t *testing.T, doc string) string {
+	t.Helper()
+	d := etree.NewDocument()
+	require.NoError(t, d.ReadFromString(doc))
+	ctx := dsig.NewDefaultSigningContext(dsig.TLSCertKeyStore(tls.Certificate{Certificate: [][]byte{k.der}, PrivateKey: k.key}))
+	ctx.Canonicalizer = dsig.MakeC14N10ExclusiveCanonicalizerWithPrefixList("")
+	signed, err := ctx.SignEnveloped(d.Root())
+	require.NoError(t, err)
+	d.SetRoot(signed)
+	out, err := d.WriteToString()
+	require.NoError(t, err)
+	return out
+}
+
+const (
+	testSPEntityID = "https://sp.example.com/saml/metadata"
+	testSPACSURL   = "https://sp.example.com/saml/acs"
+	testIDPEntity  = "https://idp.example.com"
+)
+
+func testSAMLConfig(idp *testSAMLKey) *SAMLConfig {
+	cfg := &SAMLConfig{
+		EntityID:       testSPEntityID,
+		ACSURL:         testSPACSURL,
+		TenantID:       "tenant-1",
+		SLOURL:         "https://sp.example.com/saml/slo",
+		IDPEntityID:    testIDPEntity,
+		IDPSSOURL:      "https://idp.example.com/sso",
+		IDPSLOURL:      "https://idp.example.com/slo",
+		IDPCertificate: idp.certPEM,
+		RoleMapping:    RoleMapping{GroupRoles: map[string]string{"Storage Admins": "admin"}, DefaultRole: "user"},
+	}
+	cfg.ApplyDefaults()
+	return cfg
+}
+
+// testAssertion returns an assertion for jsmith answering inResponseTo.
+func testAssertion(id, inResponseTo, nameID string) string {
+	now := time.Now().UTC()
+	ts := func(d time.Duration) string { return now.Add(d).Format(time.RFC3339) }
+	return fmt.Sprintf(`<saml:Assertion xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion" ID="%s" Version="2.0" IssueInstant="%s">`+
+		`<saml:Issuer>%s</saml:Issuer>`+
+		`<saml:Subject><saml:NameID Format="%s">%s</saml:NameID>`+
+		`<saml:SubjectConfirmation Method="urn:oasis:names:tc:SAML:2.0:cm:bearer">`+
+		`<saml:SubjectConfirmationData InResponseTo="%s" NotOnOrAfter="%s" Recipient="%s"/></saml:SubjectConfirmation></saml:Subject>`+
+		`<saml:Conditions NotBefore="%s" NotOnOrAfter="%s"><saml:AudienceRestriction><saml:Audience>%s</saml:Audience></saml:AudienceRestriction></saml:Conditions>`+
+		`<saml:AuthnStatement AuthnInstant="%s" SessionIndex="_session-1"/>`+
+		`<saml:AttributeStatement>`+
+		`<saml:Attribute Name="http://schemas.xmlsoap.org/ws/2005/05/identity/claims/emailaddress"><saml:AttributeValue>JSmith@Example.com</saml:AttributeValue></saml:Attribute>`+
+		`<saml:Attribute Name="http://schemas.xmlsoap.org/claims/Group"><saml:AttributeValue>Storage Admins</saml:AttributeValue><saml:AttributeValue>Staff</saml:AttributeValue></saml:Attribute>`+
+		`</saml:AttributeStatement></saml:Assertion>`,
+		id, ts(0), testIDPEntity, NameIDFormatPersistent, nameID,
+		inResponseTo, ts(5*time.Minute), testSPACSURL,
+		ts(-time.Minute), ts(5*time.Minute), testSPEntityID, ts(0))
+}
+
+// testResponse wraps assertion XML in a successful Response.
+func testResponse(inResponseTo, assertion string) string {
+	attr := ""
+	if inResponseTo != "" {
+		attr = fmt.Sprintf(` InResponseTo="%s"`, inResponseTo)
+	}
+	return fmt.Sprintf(`<samlp:Response xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion" ID="_resp-%d" Version="2.0" IssueInstant="%s" Destination="%s"%s>`+
+		`<saml:Issuer>%s</saml:Issuer><samlp:Status><samlp:StatusCode Value="urn:oasis:names:tc:SAML:2.0:status:Success"/></samlp:Status>%s</samlp:Response>`,
+		time.Now().UnixNano(), time.Now().UTC().Format(time.RFC3339), testSPACSURL, attr, testIDPEntity, assertion)
+}
+
+func b64(s string) string {
+	return base64.StdEncoding.EncodeToString([]byte(s))
+}
+
+// pendingRequest issues an AuthnRequest and returns its ID.
+func pendingRequest(t *testing.T, p *SAMLProvider) string {
+	t.Helper()
+	_, id, err := p.AuthnRequestURL(context.Background(), "")
+	require.NoError(t, err)
+	return id
+}
+
+func TestSAMLProvider_ValidateResponse(t *testing.T) {
+	idp := newTestSAMLKey(t, "idp")
+
+	t.Run("accepts a signed assertion", func(t *testing.T) {
+		p, err := NewSAMLProvider(testSAMLConfig(idp))
+		require.NoError(t, err)
+		reqID := pendingRequest(t, p)
+		resp := b64(testResponse(reqID, idp.sign(t, testAssertion("_a1", reqID, "jsmith"))))
+
+		res, err := p.ValidateResponse(context.Background(), resp, reqID)
+		require.NoError(t, err)
+		assert.True(t, res.Success)
+		assert.Equal(t, "jsmith", res.NameID)
+		assert.Equal(t, NameIDFormatPersistent, res.NameIDFormat)
+		assert.Equal(t, "_session-1", res.SessionID)
+		assert.Equal(t, "JSmith@Example.com", res.Email)
+		assert.Equal(t, []string{"Storage Admins", "Staff"}, res.Groups)
+
+		id, err := p.Identity(res)
+		require.NoError(t, err)
+		assert.Equal(t, "saml", id.Provider)
+		assert.Equal(t, "jsmith", id.ID)
+		assert.Equal(t, "jsmith@example.com", id.Email)
+		assert.Equal(t, []string{"admin"}, id.Roles)
+
+		// The request is answered: the same response is refused.
+		_, err = p.ValidateResponse(context.Background(), resp, reqID)
+		assert.ErrorContains(t, err, "pending request")
+	})
+
+	t.Run("replicas sharing a state store", func(t *testing.T) {
+		shared := newMemorySAMLState()
+		issuer, _ := NewSAMLProvider(testSAMLConfig(idp))
+		issuer.SetStateStore(shared)
+		acs, _ := NewSAMLProvider(testSAMLConfig(idp))
+		acs.SetStateStore(shared)
+
+		// The response reaches a replica that did not send the request.
+		reqID := pendingRequest(t, issuer)
+		resp := b64(testResponse(reqID, idp.sign(t, testAssertion("_a1r", reqID, "jsmith"))))
+		_, err := acs.ValidateResponse(context.Background(), resp, reqID)
+		require.NoError(t, err)
+
+		// And its replay reaches the first one.
+		_, err = issuer.ValidateResponse(context.Background(), resp, reqID)
+		assert.ErrorContains(t, err, "pending request")
+	})
+
+	t.Run("accepts a signed response", func(t *testing.T) {
+		p, _ := NewSAMLProvider(testSAMLConfig(idp))
+		reqID := pendingRequest(t, p)
+		resp := b64(idp.sign(t, testResponse(reqID, testAssertion("_a2", reqID, "jsmith"))))
+		_, err := p.ValidateResponse(context.Background(), resp, reqID)
+		require.NoError(t, err)
+
+		cfg := testSAMLConfig(idp)
+		cfg.RequireSignedAssertion = true
+		p, _ = NewSAMLProvider(cfg)
+		reqID = pendingRequest(t, p)
+		resp = b64(idp.sign(t, testResponse(reqID, testAssertion("_a3", reqID, "jsmith"))))
+		_, err = p.ValidateResponse(context.Background(), resp, reqID)
+		assert.ErrorContains(t, err, "assertion is not signed")
+	})
+
+	t.Run("requires a signed response when configured", func(t *testing.T) {
+		cfg := testSAMLConfig(idp)
+		cfg.RequireSignedResponses = true
+		p, _ := NewSAMLProvider(cfg)
+		reqID := pendingRequest(t, p)
+		resp := b64(testResponse(reqID, idp.sign(t, testAssertion("_a4", reqID, "jsmith"))))
+		_, err := p.ValidateResponse(context.Background(), resp, reqID)
+		assert.ErrorContains(t, err, "response is not signed")
+	})
+
+	t.Run("rejects unsigned and forged responses", func(t *testing.T) {
+		p, _ := NewSAMLProvider(testSAMLConfig(idp))
+		reqID := pendingRequest(t, p)
+
+		_, err := p.ValidateResponse(context.Background(), b64(testResponse(reqID, testAssertion("_a5", reqID, "jsmith"))), reqID)
+		assert.ErrorContains(t, err, "not signed")
+
+		other := newTestSAMLKey(t, "attacker")
+		_, err = p.ValidateResponse(context.Background(), b64(testResponse(reqID, other.sign(t, testAssertion("_a6", reqID, "jsmith")))), reqID)
+		assert.ErrorContains(t, err, "assertion signature")
+
+		tampered := strings.Replace(idp.sign(t, testAssertion("_a7", reqID, "jsmith")), ">jsmith<", ">admin<", 1)
+		_, err = p.ValidateResponse(context.Background(), b64(testResponse(reqID, tampered)), reqID)
+		assert.ErrorContains(t, err, "assertion signature")
+	})
+
+	t.Run("rejects signature wrapping", func(t *testing.T) {
+		p, _ := NewSAMLProvider(testSAMLConfig(idp))
+		reqID := pendingRequest(t, p)
+		genuine := idp.sign(t, testAssertion("_a8", reqID, "jsmith"))
+
+		// A second assertion beside the signed one.
+		forged := testAssertion("_a9", reqID, "admin")
+		_, err := p.ValidateResponse(context.Background(), b64(testResponse(reqID, forged+genuine)), reqID)
+		assert.ErrorContains(t, err, "2 assertions")
+
+		// A forged assertion reusing the genuine one's ID and signature,
+		// with the genuine assertion hidden inside it.
+		sigStart := strings.Index(genuine, "<ds:Signature")
+		sigEnd := strings.Index(genuine, "</ds:Signature>") + len("</ds:Signature>")
+		evil := strings.Replace(testAssertion("_a8", reqID, "admin"), "</saml:Issuer>",
+			"</saml:Issuer>"+genuine[sigStart:sigEnd]+"<saml:Advice>"+genuine+"</saml:Advice>", 1)
+		_, err = p.ValidateResponse(context.Background(), b64(testResponse(reqID, evil)), reqID)
+		assert.ErrorContains(t, err, "assertion signature")
+
+		// The signed response holds the genuine assertion; the forged one
+		// is wrapped outside it.
+		signedResp := idp.sign(t, testResponse(reqID, testAssertion("_a10", reqID, "jsmith")))
+		wrapper := strings.Replace(testResponse(reqID, testAssertion("_a11", reqID, "admin")), "</samlp:Response>",
+			"<samlp:Extensions>"+signedResp+"</samlp:Extensions></samlp:Response>", 1)
+		_, err = p.ValidateResponse(context.Background(), b64(wrapper), reqID)
+		assert.ErrorContains(t, err, "not signed")
+	})
+
+	t.Run("reads text split by comments whole", func(t *testing.T) {
+		p, _ := NewSAMLProvider(testSAMLConfig(idp))
+		reqID := pendingRequest(t, p)
+		resp := b64(testResponse(reqID, idp.sign(t, testAssertion("_a12", reqID, "admin@example.com<!---->.evil.test"))))
+		res, err := p.ValidateResponse(context.Background(), resp, reqID)
+		require.NoError(t, err)
+		assert.Equal(t, "admin@example.com.evil.test", res.NameID)
+	})
+
+	t.Run("binds the response to the browser's request", func(t *testing.T) {
+		p, _ := NewSAMLProvider(testSAMLConfig(idp))
+		reqID := pendingRequest(t, p)
+		otherID := pendingRequest(t, p)
+		resp := b64(testResponse(reqID, idp.sign(t, testAssertion("_a13", reqID, "jsmith"))))
+		_, err := p.ValidateResponse(context.Background(), resp, otherID)
+		assert.ErrorContains(t, err, "pending request")
+
+		unknown := b64(testResponse("_never-issued", idp.sign(t, testAssertion("_a14", "_never-issued", "jsmith"))))
+		_, err = p.ValidateResponse(context.Background(), unknown, "_never-issued")
+		assert.ErrorContains(t, err, "pending request")
+
+		// The confirmation must answer the same request as the response.
+		mismatched := b64(testResponse(otherID, idp.sign(t, testAssertion("_a15", reqID, "jsmith"))))
+		_, err = p.ValidateResponse(context.Background(), mismatched, otherID)
+		assert.ErrorContains(t, err, "subject confirmation")
+	})
+
+	t.Run("IdP-initiated responses and replay", func(t *testing.T) {
+		p, _ := NewSAMLProvider(testSAMLConfig(idp))
+		resp := b64(testResponse("", idp.sign(t, testAssertion("_a16", "", "jsmith"))))
+		_, err := p.ValidateResponse(context.Background(), resp, "")
+		assert.ErrorContains(t, err, "unsolicited")
+
+		cfg := testSAMLConfig(idp)
+		cfg.AllowIDPInitiated = true
+		p, _ = NewSAMLProvider(cfg)
+		_, err = p.ValidateResponse(context.Background(), resp, "")
+		require.NoError(t, err)
+		_, err = p.ValidateResponse(context.Background(), resp, "")
+		assert.ErrorContains(t, err, "already been used")
+	})
+
+	t.Run("checks audience, recipient, issuer and time", func(t *testing.T) {
+		cases := map[string]struct {
+			edit func(string) string
+			want string
+		}{
+			"audience": {func(s string) string {
+				return strings.Replace(s, ">"+testSPEntityID+"<", ">https://other.example.com<", 1)
+			}, "audience"},
+			"recipient": {func(s string) string {
+				return strings.Replace(s, `Recipient="`+testSPACSURL, `Recipient="https://other.example.com`, 1)
+			}, "subject confirmation"},
+			"issuer": {func(s string) string {
+				return strings.Replace(s, ">"+testIDPEntity+"<", ">https://evil.example.com<", 1)
+			}, "issued by"},
+		}
+		for name, tc := range cases {
+			t.Run(name, func(t *testing.T) {
+				p, _ := NewSAMLProvider(testSAMLConfig(idp))
+				reqID := pendingRequest(t, p)
+				resp := b64(testResponse(reqID, idp.sign(t, tc.edit(testAssertion("_a17", reqID, "jsmith")))))
+				_, err := p.ValidateResponse(context.Background(), resp, reqID)
+				assert.ErrorContains(t, err, tc.want)
+			})
+		}
+
+		p, _ := NewSAMLProvider(testSAMLConfig(idp))
+		reqID := pendingRequest(t, p)
+		resp := b64(testResponse(reqID, idp.sign(t, testAssertion("_a18", reqID, "jsmith"))))
+		p.now = func() time.Time { return time.Now().Add(10 * time.Minute) }
+		_, err := p.ValidateResponse(context.Background(), resp, reqID)
+		assert.Error(t, err)
+	})
+
+	t.Run("rejects failed status", func(t *testing.T) {
+		p, _ := NewSAMLProvider(testSAMLConfig(idp))
+		reqID := pendingRequest(t, p)
+		resp := strings.Replace(testResponse(reqID, idp.sign(t, testAssertion("_a19", reqID, "jsmith"))),
+			"status:Success", "status:Requester", 1)
+		_, err := p.ValidateResponse(context.Background(), b64(resp), reqID)
+		assert.ErrorContains(t, err, "status")
+	})
+
+	t.Run("requires an IdP certificate", func(t *testing.T) {
+		cfg := testSAMLConfig(idp)
+		cfg.IDPCertificate = ""
+		p, _ := NewSAMLProvider(cfg)
+		_, err := p.ValidateResponse(context.Background(), b64(testResponse("", "")), "")
+		assert.ErrorContains(t, err, "no IdP certificate")
+	})
+}
+
+// encryptAssertion encrypts assertion XML to the SP key as an
+// EncryptedAssertion.
+func encryptAssertion(t *testing.T, sp *testSAMLKey, assertion, dataAlg, keyAlg string) string {
+	t.Helper()
+	var cek []byte
+	var ciphertext []byte
+	switch dataAlg {
+	case xmlEnc11NS + "aes256-gcm":
+		cek = make([]byte, 32)
+		_, _ = rand.Read(cek)
+		block, err := aes.NewCipher(cek)
+		require.NoError(t, err)
+		aead, err := cipher.NewGCM(block)
+		require.NoError(t, err)
+		nonce := make([]byte, aead.NonceSize())
+		_, _ = rand.Read(nonce)
+		ciphertext = aead.Seal(nonce, nonce, []byte(assertion), nil)
+	case xmlEncNS + "aes128-cbc":
+		cek = make([]byte, 16)
+		_, _ = rand.Read(cek)
+		block, err := aes.NewCipher(cek)
+		require.NoError(t, err)
+		pad := aes.BlockSize - len(assertion)%aes.BlockSize
+		plaintext := append([]byte(assertion), make([]byte, pad)...)
+		plaintext[len(plaintext)-1] = byte(pad)
+		ciphertext = make([]byte, aes.BlockSize+len(plaintext))
+		_, _ = rand.Read(ciphertext[:aes.BlockSize])
+		cipher.NewCBCEncrypter(block, ciphertext[:aes.BlockSize]).CryptBlocks(ciphertext[aes.BlockSize:], plaintext)
+	}
+	var wrapped []byte
+	var err error
+	if keyAlg == xmlEncNS+"rsa-1_5" {
+		wrapped, err = rsa.EncryptPKCS1v15(rand.Reader, &sp.key.PublicKey, cek)
+	} else {
+		wrapped, err = rsa.EncryptOAEP(sha1.New(), rand.Reader, &sp.key.PublicKey, cek, nil)
+	}
+	require.NoError(t, err)
+
+	return `<saml:EncryptedAssertion><xenc:EncryptedData xmlns:xenc="http://www.w3.org/2001/04/xmlenc#" Type="http://www.w3.org/2001/04/xmlenc#Element">` +
+		`<xenc:EncryptionMethod Algorithm="` + dataAlg + `"/>` +
+		`<ds:KeyInfo xmlns:ds="http://www.w3.org/2000/09/xmldsig#"><xenc:EncryptedKey>` +
+		`<xenc:EncryptionMethod Algorithm="` + keyAlg + `"><ds:DigestMethod Algorithm="http://www.w3.org/2000/09/xmldsig#sha1"/></xenc:EncryptionMethod>` +
+		`<xenc:CipherData><xenc:CipherValue>` + base64.StdEncoding.EncodeToString(wrapped) + `</xenc:CipherValue></xenc:CipherData>` +
+		`</xenc:EncryptedKey></ds:KeyInfo>` +
+		`<xenc:CipherData><xenc:CipherValue>` + base64.StdEncoding.EncodeToString(ciphertext) + `</xenc:CipherValue></xenc:CipherData>` +
+		`</xenc:EncryptedData></saml:EncryptedAssertion>`
+}
+
+func TestSAMLProvider_EncryptedAssertion(t *testing.T) {
+	idp := newTestSAMLKey(t, "idp")
+	sp := newTestSAMLKey(t, "sp")
+	cfg := testSAMLConfig(idp)
+	cfg.CertificatePEM = sp.certPEM
+	cfg.PrivateKeyPEM = sp.keyPEM
+
+	for _, alg := range []string{xmlEnc11NS + "aes256-gcm", xmlEncNS + "aes128-cbc"} {
+		t.Run(alg, func(t *testing.T) {
+			p, err := NewSAMLProvider(cfg)
+			require.NoError(t, err)
+			reqID := pendingRequest(t, p)
+			// The signed assertion relies on the Response's declaration of
+			// the saml prefix.
+			signed := strings.Replace(idp.sign(t, testAssertion("_e1", reqID, "jsmith")),
+				` xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion"`, "", 1)
+			enc := encryptAssertion(t, sp, signed, alg, xmlEncNS+"rsa-oaep-mgf1p")
+
+			res, err := p.ValidateResponse(context.Background(), b64(testResponse(reqID, enc)), reqID)
+			require.NoError(t, err)
+			assert.Equal(t, "jsmith", res.NameID)
+		})
+	}
+
+	t.Run("refuses RSA PKCS #1 v1.5 key transport", func(t *testing.T) {
+		p, _ := NewSAMLProvider(cfg)
+		reqID := pendingRequest(t, p)
+		enc := encryptAssertion(t, sp, idp.sign(t, testAssertion("_e2", reqID, "jsmith")), xmlEnc11NS+"aes256-gcm", xmlEncNS+"rsa-1_5")
+		_, err := p.ValidateResponse(context.Background(), b64(testResponse(reqID, enc)), reqID)
+		assert.ErrorContains(t, err, "key transport")
+	})
+
+	t.Run("requires the decrypted assertion to be signed", func(t *testing.T) {
+		p, _ := NewSAMLProvider(cfg)
+		reqID := pendingRequest(t, p)
+		enc := encryptAssertion(t, sp, testAssertion("_e3", reqID, "jsmith"), xmlEnc11NS+"aes256-gcm", xmlEncNS+"rsa-oaep-mgf1p")
+		_, err := p.ValidateResponse(context.Background(), b64(testResponse(reqID, enc)), reqID)
+		assert.ErrorContains(t, err, "not signed")
+	})
+}
+
+func TestSAMLProvider_Identity(t *testing.T) {
+	p, _ := NewSAMLProvider(&SAMLConfig{
+		EntityID:    testSPEntityID,
+		ACSURL:      testSPACSURL,
+		TenantID:    "tenant-1",
+		IDPSSOURL:   "https://idp.example.com/sso",
+		RoleMapping: RoleMapping{GroupRoles: map[string]string{"admins": "admin"}},
+	})
+
+	id, err := p.Identity(&SAMLAuthResult{NameID: "JSmith@Example.com", FirstName: "John", LastName: "Smith", Groups: []string{"Admins"}})
+	require.NoError(t, err)
+	assert.Equal(t, "jsmith@example.com", id.Email)
+	assert.Equal(t, "John Smith", id.DisplayName)
+	assert.Equal(t, []string{"admin"}, id.Roles)
+
+	_, err = p.Identity(&SAMLAuthResult{NameID: "jsmith", Groups: []string{"staff"}})
+	assert.ErrorIs(t, err, ErrNoMappedRole)
+}
@@ -0,0 +1,160 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// SAMLStateStore remembers the AuthnRequest IDs a SAMLProvider has issued
+// and the assertion IDs it has accepted. Every replica serving the ACS must
+// share one: a response may reach a replica other than the one that sent
+// its request, and a replayed assertion one that never saw it.
+type SAMLStateStore interface {
+	// TrackRequest records an issued AuthnRequest ID until expiry.
+	TrackRequest(ctx context.Context, id string, expiry time.Time) error
+	// TakeRequest reports whether id is an AuthnRequest ID unexpired at
+	// now, and forgets it so a second response to it is refused.
+	TakeRequest(ctx context.Context, id string, now time.Time) (bool, error)
+	// ConsumeAssertion records an accepted assertion ID until expiry. It
+	// reports false when the ID is already recorded and unexpired at now.
+	ConsumeAssertion(ctx context.Context, id string, expiry, now time.Time) (bool, error)
+}
+
+// samlPruneInterval bounds how often a store sweeps expired IDs.
+const samlPruneInterval = time.Minute
+
+// memorySAMLState is the process-local SAMLStateStore, for a single
+// replica and for tests.
+type memorySAMLState struct {
+	mu        sync.Mutex
+	pending   map[string]time.Time // AuthnRequest ID → expiry
+	consumed  map[string]time.Time // assertion ID → expiry
+	lastPrune time.Time
+}
+
+func newMemorySAMLState() *memorySAMLState {
+	return &memorySAMLState{
+		pending:  make(map[string]time.Time),
+		consumed: make(map[string]time.Time),
+	}
+}
+
+func (m *memorySAMLState) TrackRequest(_ context.Context, id string, expiry time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.pending[id] = expiry
+	return nil
+}
+
+func (m *memorySAMLState) TakeRequest(_ context.Context, id string, now time.Time) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.pruneLocked(now)
+	expiry, ok := m.pending[id]
+	delete(m.pending, id)
+	return ok && now.Before(expiry), nil
+}
+
+func (m *memorySAMLState) ConsumeAssertion(_ context.Context, id string, expiry, now time.Time) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.pruneLocked(now)
+	if prev, seen := m.consumed[id]; seen && !now.After(prev) {
+		return false, nil
+	}
+	m.consumed[id] = expiry
+	return true, nil
+}
+
+func (m *memorySAMLState) pruneLocked(now time.Time) {
+	if now.Sub(m.lastPrune) < samlPruneInterval {
+		return
+	}
+	m.lastPrune = now
+	for id, expiry := range m.pending {
+		if now.After(expiry) {
+			delete(m.pending, id)
+		}
+	}
+	for id, expiry := range m.consumed {
+		if now.After(expiry) {
+			delete(m.consumed, id)
+		}
+	}
+}
+
+// sqlSAMLState keeps SAML state in Postgres (migration 080), shared by
+// every replica.
+type sqlSAMLState struct {
+	db *sql.DB
+
+	mu        sync.Mutex
+	lastPrune time.Time
+}
+
+// NewSQLSAMLState returns a SAMLStateStore backed by the saml_requests and
+// saml_assertions tables.
+func NewSQLSAMLState(db *sql.DB) SAMLStateStore {
+	return &sqlSAMLState{db: db}
+}
+
+func (s *sqlSAMLState) TrackRequest(ctx context.Context, id string, expiry time.Time) error {
+	if _, err := s.db.ExecContext(ctx,
+		`INSERT INTO saml_requests (id, expires_at) VALUES ($1, $2)`, id, expiry); err != nil {
+		return fmt.Errorf("track saml request: %w", err)
+	}
+	return nil
+}
+
+// TakeRequest deletes the row it reads, so of two replicas racing on one
+// response only one sees the request.
+func (s *sqlSAMLState) TakeRequest(ctx context.Context, id string, now time.Time) (bool, error) {
+	s.prune(ctx, now)
+	var expiry time.Time
+	err := s.db.QueryRowContext(ctx,
+		`DELETE FROM saml_requests WHERE id = $1 RETURNING expires_at`, id).Scan(&expiry)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("take saml request: %w", err)
+	}
+	return now.Before(expiry), nil
+}
+
+// ConsumeAssertion inserts the ID, or takes over an expired row for it;
+// the primary key makes a concurrent replay lose.
+func (s *sqlSAMLState) ConsumeAssertion(ctx context.Context, id string, expiry, now time.Time) (bool, error) {
+	s.prune(ctx, now)
+	result, err := s.db.ExecContext(ctx, `
+		INSERT INTO saml_assertions (id, expires_at) VALUES ($1, $2)
+		ON CONFLICT (id) DO UPDATE SET expires_at = EXCLUDED.expires_at
+		WHERE saml_assertions.expires_at < $3`,
+		id, expiry, now)
+	if err != nil {
+		return false, fmt.Errorf("consume saml assertion: %w", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("consume saml assertion: %w", err)
+	}
+	return n == 1, nil
+}
+
+// prune deletes expired rows at most once per samlPruneInterval per
+// replica. Failures only leave the rows for the next sweep.
+func (s *sqlSAMLState) prune(ctx context.Context, now time.Time) {
+	s.mu.Lock()
+	if now.Sub(s.lastPrune) < samlPruneInterval {
+		s.mu.Unlock()
+		return
+	}
+	s.lastPrune = now
+	s.mu.Unlock()
+	_, _ = s.db.ExecContext(ctx, `DELETE FROM saml_requests WHERE expires_at < $1`, now)
+	_, _ = s.db.ExecContext(ctx, `DELETE FROM saml_assertions WHERE expires_at < $1`, now)
+}
@@ -0,0 +1,76 @@
+package auth
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemorySAMLState(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	m := newMemorySAMLState()
+
+	require.NoError(t, m.TrackRequest(ctx, "req-1", now.Add(time.Minute)))
+	ok, _ := m.TakeRequest(ctx, "req-1", now)
+	assert.True(t, ok)
+	ok, _ = m.TakeRequest(ctx, "req-1", now)
+	assert.False(t, ok, "a request is answered once")
+
+	require.NoError(t, m.TrackRequest(ctx, "req-2", now.Add(time.Minute)))
+	ok, _ = m.TakeRequest(ctx, "req-2", now.Add(2*time.Minute))
+	assert.False(t, ok, "expired request")
+
+	ok, _ = m.ConsumeAssertion(ctx, "a-1", now.Add(time.Minute), now)
+	assert.True(t, ok)
+	ok, _ = m.ConsumeAssertion(ctx, "a-1", now.Add(time.Minute), now)
+	assert.False(t, ok, "replay")
+}
+
+func TestSQLSAMLState(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() { _ = db.Close() }()
+	ctx := context.Background()
+	now := time.Now()
+	s := NewSQLSAMLState(db)
+
+	mock.ExpectExec(`INSERT INTO saml_requests`).WithArgs("req-1", now.Add(time.Minute)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	require.NoError(t, s.TrackRequest(ctx, "req-1", now.Add(time.Minute)))
+
+	// The first call of a sweep interval prunes expired rows.
+	mock.ExpectExec(`DELETE FROM saml_requests WHERE expires_at < \$1`).WithArgs(now).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`DELETE FROM saml_assertions WHERE expires_at < \$1`).WithArgs(now).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`DELETE FROM saml_requests WHERE id = \$1 RETURNING expires_at`).WithArgs("req-1").
+		WillReturnRows(sqlmock.NewRows([]string{"expires_at"}).AddRow(now.Add(time.Minute)))
+	ok, err := s.TakeRequest(ctx, "req-1", now)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	// Another replica already took it.
+	mock.ExpectQuery(`DELETE FROM saml_requests WHERE id = \$1`).WithArgs("req-1").
+		WillReturnRows(sqlmock.NewRows([]string{"expires_at"}))
+	ok, err = s.TakeRequest(ctx, "req-1", now)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	mock.ExpectExec(`INSERT INTO saml_assertions`).WithArgs("a-1", now.Add(time.Minute), now).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	ok, err = s.ConsumeAssertion(ctx, "a-1", now.Add(time.Minute), now)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	mock.ExpectExec(`INSERT INTO saml_assertions`).WithArgs("a-1", now.Add(time.Minute), now).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	ok, err = s.ConsumeAssertion(ctx, "a-1", now.Add(time.Minute), now)
+	require.NoError(t, err)
+	assert.False(t, ok, "replay")
+	require.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,952 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// scimTokenPrefix marks SCIM bearer tokens, so a leaked one is easy to
+// recognise.
+const scimTokenPrefix = "scim_"
+
+var (
+	// ErrSCIMTokenNotFound means a bearer token is unknown or was deleted.
+	ErrSCIMTokenNotFound = errors.New("scim token not found")
+	// ErrSCIMMemberNotFound means the tenant has no such member.
+	ErrSCIMMemberNotFound = errors.New("member not found")
+	// ErrSCIMGroupNotFound means the tenant has no such group.
+	ErrSCIMGroupNotFound = errors.New("group not found")
+	// ErrSCIMConflict means a userName, email address or group name is
+	// already taken.
+	ErrSCIMConflict = errors.New("already exists")
+)
+
+// scimRoles are the roles SCIM groups can grant: a tenant's IdP may never
+// confer the platform's admin role. SCIM replaces a member's grants of
+// all of them.
+var scimRoles = func() []string {
+	var roles []string
+	for role := range oidcConnectionRoles {
+		roles = append(roles, role)
+	}
+	sort.Strings(roles)
+	return roles
+}()
+
+// SCIMToken is a bearer token a tenant's IdP provisions users with. Only
+// its hash is stored.
+type SCIMToken struct {
+	ID         string     `json:"id"`
+	TenantID   string     `json:"tenant_id"`
+	Name       string     `json:"name"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
+
+func hashSCIMToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreateSCIMToken issues a SCIM token to a tenant. The plaintext token is
+// returned once and cannot be recovered.
+func CreateSCIMToken(ctx context.Context, db *sql.DB, tenantID, name string) (*SCIMToken, string, error) {
+	if db == nil {
+		return nil, "", fmt.Errorf("database not initialized")
+	}
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return nil, "", fmt.Errorf("generate scim token: %w", err)
+	}
+	plaintext := scimTokenPrefix + base64.RawURLEncoding.EncodeToString(b)
+
+	t := &SCIMToken{ID: uuid.New().String(), TenantID: tenantID, Name: name, CreatedAt: time.Now().UTC()}
+	if _, err := db.ExecContext(ctx, `
+		INSERT INTO scim_tokens (id, tenant_id, name, token_hash, created_at)
+		VALUES ($1, $2, $3, $4, $5)`,
+		t.ID, t.TenantID, t.Name, hashSCIMToken(plaintext), t.CreatedAt); err != nil {
+		return nil, "", fmt.Errorf("create scim token: %w", err)
+	}
+	return t, plaintext, nil
+}
+
+// ListSCIMTokens returns a tenant's SCIM tokens, oldest first.
+func ListSCIMTokens(ctx context.Context, db *sql.DB, tenantID string) ([]*SCIMToken, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+	rows, err := db.QueryContext(ctx, `
+		SELECT id, tenant_id, name, created_at, last_used_at
+		FROM scim_tokens WHERE tenant_id = $1 ORDER BY created_at, id`, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("list scim tokens: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var out []*SCIMToken
+	for rows.Next() {
+		var t SCIMToken
+		var lastUsed sql.NullTime
+		if err := rows.Scan(&t.ID, &t.TenantID, &t.Name, &t.CreatedAt, &lastUsed); err != nil {
+			return nil, fmt.Errorf("scan scim token: %w", err)
+		}
+		if lastUsed.Valid {
+			t.LastUsedAt = &lastUsed.Time
+		}
+		out = append(out, &t)
+	}
+	return out, rows.Err()
+}
+
+// DeleteSCIMToken revokes one of a tenant's SCIM tokens.
+func DeleteSCIMToken(ctx context.Context, db *sql.DB, tenantID, id string) error {
+	if db == nil {
+		return fmt.Errorf("database not initialized")
+	}
+	result, err := db.ExecContext(ctx, `DELETE FROM scim_tokens WHERE id = $1 AND tenant_id = $2`, id, tenantID)
+	if err != nil {
+		return fmt.Errorf("delete scim token: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrSCIMTokenNotFound
+	}
+	return nil
+}
+
+// ValidateSCIMToken returns the tenant a bearer token was issued to and
+// records its use.
+func ValidateSCIMToken(ctx context.Context, db *sql.DB, token string) (string, error) {
+	if db == nil {
+		return "", fmt.Errorf("database not initialized")
+	}
+	if !strings.HasPrefix(token, scimTokenPrefix) {
+		return "", ErrSCIMTokenNotFound
+	}
+	var tenantID string
+	err := db.QueryRowContext(ctx, `
+		UPDATE scim_tokens SET last_used_at = NOW() WHERE token_hash = $1
+		RETURNING tenant_id`, hashSCIMToken(token)).Scan(&tenantID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrSCIMTokenNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("validate scim token: %w", err)
+	}
+	return tenantID, nil
+}
+
+// SCIMConfig maps the groups a tenant's IdP provisions to rbac roles.
+type SCIMConfig struct {
+	// GroupRoles maps group display names (compared case-insensitively)
+	// to user, viewer or guest.
+	GroupRoles map[string]string `json:"group_roles"`
+	// DefaultRole is granted to members in no mapped group. Empty grants
+	// them no role.
+	DefaultRole string    `json:"default_role"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// defaultSCIMConfig applies until a tenant saves its own: every member is
+// a user.
+func defaultSCIMConfig() *SCIMConfig {
+	return &SCIMConfig{GroupRoles: map[string]string{}, DefaultRole: "user"}
+}
+
+func (c *SCIMConfig) mapping() *RoleMapping {
+	return &RoleMapping{GroupRoles: c.GroupRoles, DefaultRole: c.DefaultRole}
+}
+
+// Validate checks that the config grants only roles a tenant may grant.
+func (c *SCIMConfig) Validate() error {
+	m := c.mapping()
+	if err := m.Validate(); err != nil {
+		return err
+	}
+	for _, role := range m.managedRoles() {
+		if !oidcConnectionRoles[role] {
+			return fmt.Errorf("role %q cannot be granted by SCIM; use user, viewer or guest", role)
+		}
+	}
+	return nil
+}
+
+type rowQuerier interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+func loadSCIMConfig(ctx context.Context, q rowQuerier, tenantID string) (*SCIMConfig, error) {
+	c := defaultSCIMConfig()
+	var groupRoles []byte
+	err := q.QueryRowContext(ctx, `
+		SELECT group_roles, default_role, updated_at FROM scim_configs WHERE tenant_id = $1`,
+		tenantID).Scan(&groupRoles, &c.DefaultRole, &c.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("load scim config: %w", err)
+	}
+	if err := json.Unmarshal(groupRoles, &c.GroupRoles); err != nil {
+		return nil, fmt.Errorf("decode scim group roles: %w", err)
+	}
+	return c, nil
+}
+
+// GetSCIMConfig returns a tenant's SCIM config, or the default one.
+func GetSCIMConfig(ctx context.Context, db *sql.DB, tenantID string) (*SCIMConfig, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+	return loadSCIMConfig(ctx, db, tenantID)
+}
+
+// PutSCIMConfig saves a validated config and re-syncs the roles of the
+// members SCIM manages.
+func PutSCIMConfig(ctx context.Context, db *sql.DB, tenantID string, c *SCIMConfig) error {
+	if db == nil {
+		return fmt.Errorf("database not initialized")
+	}
+	if c.GroupRoles == nil {
+		c.GroupRoles = map[string]string{}
+	}
+	groupRoles, err := json.Marshal(c.GroupRoles)
+	if err != nil {
+		return err
+	}
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("save scim config: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	c.UpdatedAt = time.Now().UTC()
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO scim_configs (tenant_id, group_roles, default_role, updated_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (tenant_id) DO UPDATE
+		SET group_roles = EXCLUDED.group_roles, default_role = EXCLUDED.default_role,
+		    updated_at = EXCLUDED.updated_at`,
+		tenantID, groupRoles, c.DefaultRole, c.UpdatedAt); err != nil {
+		return fmt.Errorf("save scim config: %w", err)
+	}
+
+	userIDs, err := queryStrings(ctx, tx, `
+		SELECT user_id FROM tenant_members WHERE tenant_id = $1 AND scim_managed`, tenantID)
+	if err != nil {
+		return fmt.Errorf("save scim config: %w", err)
+	}
+	if err := syncSCIMRoles(ctx, tx, c, userIDs); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// syncSCIMRoles grants members the roles their groups map to.
+func syncSCIMRoles(ctx context.Context, tx *sql.Tx, c *SCIMConfig, userIDs []string) error {
+	m := c.mapping()
+	for _, userID := range userIDs {
+		groups, err := queryStrings(ctx, tx, `
+			SELECT g.display_name FROM scim_group_members gm
+			JOIN scim_groups g ON g.id = gm.group_id
+			WHERE gm.user_id = $1`, userID)
+		if err != nil {
+			return fmt.Errorf("sync roles: %w", err)
+		}
+		if err := syncUserRoles(ctx, tx, userID, scimRoles, m.ResolveRoles(groups)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func queryStrings(ctx context.Context, tx *sql.Tx, query string, args ...interface{}) ([]string, error) {
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rows.Close() }()
+
+	var out []string
+	for rows.Next() {
+		var s string
+		if err := rows.Scan(&s); err != nil {
+			return nil, err
+		}
+		out = append(out, s)
+	}
+	return out, rows.Err()
+}
+
+// MemberRef refers to a group's member or a member's group.
+type MemberRef struct {
+	ID      string
+	Display string
+}
+
+// TenantMember is a tenant member as SCIM provisions it. Members signed
+// in through an OIDC connection before SCIM knew them have their email
+// address as UserName until the IdP updates them.
+type TenantMember struct {
+	UserID      string
+	TenantID    string
+	UserName    string
+	ExternalID  string
+	Email       string
+	GivenName   string
+	FamilyName  string
+	DisplayName string
+	Active      bool
+	Groups      []MemberRef
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+const tenantMemberQuery = `
+	SELECT tm.user_id, tm.tenant_id, COALESCE(tm.user_name, u.email), tm.external_id, u.email,
+	       tm.given_name, tm.family_name, tm.display_name, tm.active, tm.created_at, tm.updated_at
+	FROM tenant_members tm JOIN users u ON u.id = tm.user_id`
+
+// isSCIMManaged reports whether SCIM provisioned or updated a member, and
+// so owns their roles.
+func (a *AuthService) isSCIMManaged(ctx context.Context, tenantID, userID string) (bool, error) {
+	if a.sqlDB == nil {
+		return false, nil
+	}
+	var managed bool
+	err := a.sqlDB.QueryRowContext(ctx, `
+		SELECT scim_managed FROM tenant_members WHERE tenant_id = $1 AND user_id = $2`,
+		tenantID, userID).Scan(&managed)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("load tenant member: %w", err)
+	}
+	return managed, nil
+}
+
+// ListSCIMMembers returns a tenant's members with their groups. The
+// tenant's owner is not a member.
+func (a *AuthService) ListSCIMMembers(ctx context.Context, tenantID string) ([]*TenantMember, error) {
+	if a.sqlDB == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+	return a.queryMembers(ctx, tenantID, "")
+}
+
+// GetSCIMMember returns one of a tenant's members.
+func (a *AuthService) GetSCIMMember(ctx context.Context, tenantID, userID string) (*TenantMember, error) {
+	if a.sqlDB == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+	if _, err := uuid.Parse(userID); err != nil {
+		return nil, ErrSCIMMemberNotFound
+	}
+	members, err := a.queryMembers(ctx, tenantID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(members) == 0 {
+		return nil, ErrSCIMMemberNotFound
+	}
+	return members[0], nil
+}
+
+func (a *AuthService) queryMembers(ctx context.Context, tenantID, userID string) ([]*TenantMember, error) {
+	query, args := tenantMemberQuery+` WHERE tm.tenant_id = $1`, []interface{}{tenantID}
+	if userID != "" {
+		query += ` AND tm.user_id = $2`
+		args = append(args, userID)
+	}
+	rows, err := a.sqlDB.QueryContext(ctx, query+` ORDER BY tm.created_at, tm.user_id`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("list members: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var members []*TenantMember
+	byID := make(map[string]*TenantMember)
+	for rows.Next() {
+		var m TenantMember
+		if err := rows.Scan(&m.UserID, &m.TenantID, &m.UserName, &m.ExternalID, &m.Email,
+			&m.GivenName, &m.FamilyName, &m.DisplayName, &m.Active, &m.CreatedAt, &m.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("scan member: %w", err)
+		}
+		members = append(members, &m)
+		byID[m.UserID] = &m
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("list members: %w", err)
+	}
+	if len(members) == 0 {
+		return nil, nil
+	}
+
+	grows, err := a.sqlDB.QueryContext(ctx, `
+		SELECT gm.user_id, g.id, g.display_name FROM scim_group_members gm
+		JOIN scim_groups g ON g.id = gm.group_id
+		WHERE g.tenant_id = $1 ORDER BY g.display_name, g.id`, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("list member groups: %w", err)
+	}
+	defer func() { _ = grows.Close() }()
+	for grows.Next() {
+		var uid string
+		var ref MemberRef
+		if err := grows.Scan(&uid, &ref.ID, &ref.Display); err != nil {
+			return nil, fmt.Errorf("scan member group: %w", err)
+		}
+		if m, ok := byID[uid]; ok {
+			m.Groups = append(m.Groups, ref)
+		}
+	}
+	return members, grows.Err()
+}
+
+// checkMemberEmail checks that an email address is in a domain one of the
+// tenant's OIDC connections claims: members sign in through them, and
+// addresses are unique across tenants, so a tenant must not claim others'.
+func checkMemberEmail(ctx context.Context, q rowQuerier, tenantID, email string) error {
+	_, domain, ok := strings.Cut(email, "@")
+	if !ok || domain == "" {
+		return fmt.Errorf("invalid email address %q", email)
+	}
+	var allowed bool
+	if err := q.QueryRowContext(ctx, `
+		SELECT EXISTS (SELECT 1 FROM oidc_email_domains WHERE tenant_id = $1 AND domain = $2)`,
+		tenantID, domain).Scan(&allowed); err != nil {
+		return fmt.Errorf("check email domain: %w", err)
+	}
+	if !allowed {
+		return fmt.Errorf("%w: %s", ErrOIDCEmailDomain, email)
+	}
+	return nil
+}
+
+// checkUserName fails with ErrSCIMConflict when another member of the
+// tenant has the userName.
+func checkUserName(ctx context.Context, q rowQuerier, tenantID, userName, userID string) error {
+	var taken bool
+	if err := q.QueryRowContext(ctx, `
+		SELECT EXISTS (SELECT 1 FROM tenant_members
+		               WHERE tenant_id = $1 AND LOWER(user_name) = LOWER($2) AND user_id::text <> $3)`,
+		tenantID, userName, userID).Scan(&taken); err != nil {
+		return fmt.Errorf("check user name: %w", err)
+	}
+	if taken {
+		return fmt.Errorf("%w: userName %s", ErrSCIMConflict, userName)
+	}
+	return nil
+}
+
+// CreateSCIMMember creates a password-less member of the tenant. Like
+// CreateTenantMember it ignores closed signups and mints no API key; the
+// member links their OIDC account the first time they sign in.
+func (a *AuthService) CreateSCIMMember(ctx context.Context, m *TenantMember) (*TenantMember, error) {
+	if a.sqlDB == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+	m.Email = strings.ToLower(strings.TrimSpace(m.Email))
+	if m.UserName == "" {
+		return nil, errors.New("userName is required")
+	}
+	if _, exists := a.tenants[m.TenantID]; !exists {
+		return nil, fmt.Errorf("tenant not found")
+	}
+	if err := checkMemberEmail(ctx, a.sqlDB, m.TenantID, m.Email); err != nil {
+		return nil, err
+	}
+	if _, exists := a.users[m.Email]; exists {
+		return nil, fmt.Errorf("%w: email %s", ErrSCIMConflict, m.Email)
+	}
+
+	now := time.Now()
+	user := &User{
+		ID:            uuid.New().String(),
+		Email:         m.Email,
+		Company:       m.DisplayName,
+		TenantID:      m.TenantID,
+		EmailVerified: true,
+		Deactivated:   !m.Active,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+
+	tx, err := a.sqlDB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("create member: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := checkUserName(ctx, tx, m.TenantID, m.UserName, user.ID); err != nil {
+		return nil, err
+	}
+	result, err := tx.ExecContext(ctx, `
+		INSERT INTO users (id, email, password_hash, company, email_verified, created_at, updated_at)
+		VALUES ($1, $2, '', $3, TRUE, $4, $5)
+		ON CONFLICT (email) DO NOTHING
+	`, user.ID, user.Email, user.Company, user.CreatedAt, user.UpdatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("persist user: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return nil, fmt.Errorf("%w: email %s", ErrSCIMConflict, m.Email)
+	}
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO tenant_members (tenant_id, user_id, user_name, external_id, given_name, family_name,
+		                            display_name, active, scim_managed, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, TRUE, $9, $9)
+	`, m.TenantID, user.ID, m.UserName, m.ExternalID, m.GivenName, m.FamilyName,
+		m.DisplayName, m.Active, now); err != nil {
+		return nil, fmt.Errorf("persist tenant member: %w", err)
+	}
+	cfg, err := loadSCIMConfig(ctx, tx, m.TenantID)
+	if err != nil {
+		return nil, err
+	}
+	if err := syncSCIMRoles(ctx, tx, cfg, []string{user.ID}); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("create member: %w", err)
+	}
+
+	a.users[user.Email] = user
+	a.userIndex[user.ID] = user
+	return a.GetSCIMMember(ctx, m.TenantID, user.ID)
+}
+
+// ReplaceSCIMMember replaces a member's attributes; the member becomes
+// SCIM-managed. Deactivating a member deprovisions them: their API keys,
+// the STS sessions minted from them and their dashboard sessions are
+// revoked, and they can no longer sign in.
+func (a *AuthService) ReplaceSCIMMember(ctx context.Context, m *TenantMember) (*TenantMember, error) {
+	if a.sqlDB == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+	if _, err := uuid.Parse(m.UserID); err != nil {
+		return nil, ErrSCIMMemberNotFound
+	}
+	user, exists := a.userIndex[m.UserID]
+	if !exists {
+		return nil, ErrSCIMMemberNotFound
+	}
+	m.Email = strings.ToLower(strings.TrimSpace(m.Email))
+	if m.UserName == "" {
+		return nil, errors.New("userName is required")
+	}
+
+	tx, err := a.sqlDB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("update member: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var wasActive bool
+	err = tx.QueryRowContext(ctx, `
+		SELECT active FROM tenant_members WHERE tenant_id = $1 AND user_id = $2 FOR UPDATE`,
+		m.TenantID, m.UserID).Scan(&wasActive)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrSCIMMemberNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("update member: %w", err)
+	}
+	if err := checkUserName(ctx, tx, m.TenantID, m.UserName, m.UserID); err != nil {
+		return nil, err
+	}
+
+	emailChanged := m.Email != user.Email
+	if emailChanged {
+		if err := checkMemberEmail(ctx, tx, m.TenantID, m.Email); err != nil {
+			return nil, err
+		}
+		if _, taken := a.users[m.Email]; taken {
+			return nil, fmt.Errorf("%w: email %s", ErrSCIMConflict, m.Email)
+		}
+		result, err := tx.ExecContext(ctx, `
+			UPDATE users SET email = $2, updated_at = NOW()
+			WHERE id = $1 AND NOT EXISTS (SELECT 1 FROM users WHERE email = $2)`, m.UserID, m.Email)
+		if err != nil {
+			return nil, fmt.Errorf("update member email: %w", err)
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			return nil, fmt.Errorf("%w: email %s", ErrSCIMConflict, m.Email)
+		}
+	}
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE tenant_members
+		SET user_name = $3, external_id = $4, given_name = $5, family_name = $6, display_name = $7,
+		    active = $8, scim_managed = TRUE, updated_at = NOW()
+		WHERE tenant_id = $1 AND user_id = $2
+	`, m.TenantID, m.UserID, m.UserName, m.ExternalID, m.GivenName, m.FamilyName,
+		m.DisplayName, m.Active); err != nil {
+		return nil, fmt.Errorf("update member: %w", err)
+	}
+	deprovision := wasActive && !m.Active
+	if deprovision {
+		if err := revokeUserCredentials(ctx, tx, m.UserID); err != nil {
+			return nil, err
+		}
+	}
+	cfg, err := loadSCIMConfig(ctx, tx, m.TenantID)
+	if err != nil {
+		return nil, err
+	}
+	if err := syncSCIMRoles(ctx, tx, cfg, []string{m.UserID}); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("update member: %w", err)
+	}
+
+	if emailChanged {
+		delete(a.users, user.Email)
+		user.Email = m.Email
+		a.users[user.Email] = user
+	}
+	user.Deactivated = !m.Active
+	if deprovision {
+		a.forgetAPIKeys(m.UserID)
+	}
+	return a.GetSCIMMember(ctx, m.TenantID, m.UserID)
+}
+
+// DeleteSCIMMember deprovisions a member and deletes their account.
+func (a *AuthService) DeleteSCIMMember(ctx context.Context, tenantID, userID string) error {
+	if a.sqlDB == nil {
+		return fmt.Errorf("database not initialized")
+	}
+	if _, err := uuid.Parse(userID); err != nil {
+		return ErrSCIMMemberNotFound
+	}
+	tx, err := a.sqlDB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("delete member: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var found bool
+	if err := tx.QueryRowContext(ctx, `
+		SELECT EXISTS (SELECT 1 FROM tenant_members WHERE tenant_id = $1 AND user_id = $2)`,
+		tenantID, userID).Scan(&found); err != nil {
+		return fmt.Errorf("delete member: %w", err)
+	}
+	if !found {
+		return ErrSCIMMemberNotFound
+	}
+	if err := revokeUserCredentials(ctx, tx, userID); err != nil {
+		return err
+	}
+	for _, query := range []string{
+		// oauth_accounts has no foreign key to users.
+		`DELETE FROM oauth_accounts WHERE user_id::text = $1`,
+		`UPDATE user_roles SET granted_by = NULL WHERE granted_by::text = $1`,
+		`DELETE FROM users WHERE id::text = $1`,
+	} {
+		if _, err := tx.ExecContext(ctx, query, userID); err != nil {
+			return fmt.Errorf("delete member: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("delete member: %w", err)
+	}
+
+	a.forgetAPIKeys(userID)
+	if user, ok := a.userIndex[userID]; ok {
+		delete(a.users, user.Email)
+		delete(a.userIndex, userID)
+	}
+	return nil
+}
+
+// revokeUserCredentials deletes a user's API keys, the STS sessions minted
+// from them or by the user, and their dashboard sessions.
+func revokeUserCredentials(ctx context.Context, tx *sql.Tx, userID string) error {
+	if _, err := tx.ExecContext(ctx, `
+		DELETE FROM sts_tokens
+		WHERE parent_key_id = $2
+		   OR parent_key_id IN (SELECT key_id FROM api_keys WHERE user_id::text = $1)`,
+		userID, STSUserParent(userID)); err != nil {
+		return fmt.Errorf("revoke sts tokens: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM api_keys WHERE user_id::text = $1`, userID); err != nil {
+		return fmt.Errorf("revoke api keys: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM dashboard_sessions WHERE user_id::text = $1`, userID); err != nil {
+		return fmt.Errorf("revoke sessions: %w", err)
+	}
+	return nil
+}
+
+// forgetAPIKeys drops a user's keys from memory once they are deleted.
+func (a *AuthService) forgetAPIKeys(userID string) {
+	for key, k := range a.apiKeys {
+		if k.UserID == userID {
+			delete(a.apiKeys, key)
+			delete(a.keyIndex, key)
+		}
+	}
+}
+
+// SCIMGroup is a group a tenant's IdP provisions. The tenant's SCIMConfig
+// maps its display name to a role for its members.
+type SCIMGroup struct {
+	ID          string
+	TenantID    string
+	DisplayName string
+	ExternalID  string
+	Members     []MemberRef
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+// ListSCIMGroups returns a tenant's groups with their members.
+func (a *AuthService) ListSCIMGroups(ctx context.Context, tenantID string) ([]*SCIMGroup, error) {
+	if a.sqlDB == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+	return a.queryGroups(ctx, tenantID, "")
+}
+
+// GetSCIMGroup returns one of a tenant's groups.
+func (a *AuthService) GetSCIMGroup(ctx context.Context, tenantID, id string) (*SCIMGroup, error) {
+	if a.sqlDB == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+	groups, err := a.queryGroups(ctx, tenantID, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(groups) == 0 {
+		return nil, ErrSCIMGroupNotFound
+	}
+	return groups[0], nil
+}
+
+func (a *AuthService) queryGroups(ctx context.Context, tenantID, id string) ([]*SCIMGroup, error) {
+	query, args := `
+		SELECT id, tenant_id, display_name, external_id, created_at, updated_at
+		FROM scim_groups WHERE tenant_id = $1`, []interface{}{tenantID}
+	if id != "" {
+		query += ` AND id = $2`
+		args = append(args, id)
+	}
+	rows, err := a.sqlDB.QueryContext(ctx, query+` ORDER BY created_at, id`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("list groups: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var groups []*SCIMGroup
+	byID := make(map[string]*SCIMGroup)
+	for rows.Next() {
+		var g SCIMGroup
+		if err := rows.Scan(&g.ID, &g.TenantID, &g.DisplayName, &g.ExternalID, &g.CreatedAt, &g.UpdatedAt); err != nil {
+			return nil, fmt.Errorf("scan group: %w", err)
+		}
+		groups = append(groups, &g)
+		byID[g.ID] = &g
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("list groups: %w", err)
+	}
+	if len(groups) == 0 {
+		return nil, nil
+	}
+
+	mrows, err := a.sqlDB.QueryContext(ctx, `
+		SELECT gm.group_id, gm.user_id,
+		       COALESCE(NULLIF(tm.display_name, ''), tm.user_name, u.email)
+		FROM scim_group_members gm
+		JOIN scim_groups g ON g.id = gm.group_id
+		JOIN users u ON u.id = gm.user_id
+		LEFT JOIN tenant_members tm ON tm.user_id = gm.user_id AND tm.tenant_id = g.tenant_id
+		WHERE g.tenant_id = $1 ORDER BY u.email`, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("list group members: %w", err)
+	}
+	defer func() { _ = mrows.Close() }()
+	for mrows.Next() {
+		var gid string
+		var ref MemberRef
+		if err := mrows.Scan(&gid, &ref.ID, &ref.Display); err != nil {
+			return nil, fmt.Errorf("scan group member: %w", err)
+		}
+		if g, ok := byID[gid]; ok {
+			g.Members = append(g.Members, ref)
+		}
+	}
+	return groups, mrows.Err()
+}
+
+// CreateSCIMGroup creates a group and grants its members the role it
+// maps to.
+func (a *AuthService) CreateSCIMGroup(ctx context.Context, g *SCIMGroup) (*SCIMGroup, error) {
+	if a.sqlDB == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+	if g.DisplayName == "" {
+		return nil, errors.New("displayName is required")
+	}
+	g.ID = uuid.New().String()
+
+	tx, err := a.sqlDB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("create group: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	now := time.Now().UTC()
+	result, err := tx.ExecContext(ctx, `
+		INSERT INTO scim_groups (id, tenant_id, display_name, external_id, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $5)
+		ON CONFLICT DO NOTHING`, g.ID, g.TenantID, g.DisplayName, g.ExternalID, now)
+	if err != nil {
+		return nil, fmt.Errorf("create group: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return nil, fmt.Errorf("%w: group %s", ErrSCIMConflict, g.DisplayName)
+	}
+	if err := setGroupMembers(ctx, tx, g, nil); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("create group: %w", err)
+	}
+	return a.GetSCIMGroup(ctx, g.TenantID, g.ID)
+}
+
+// ReplaceSCIMGroup renames a group and replaces its members, re-syncing
+// the roles of everyone who was or is a member.
+func (a *AuthService) ReplaceSCIMGroup(ctx context.Context, g *SCIMGroup) (*SCIMGroup, error) {
+	if a.sqlDB == nil {
+		return nil, fmt.Errorf("database not initialized")
+	}
+	if g.DisplayName == "" {
+		return nil, errors.New("displayName is required")
+	}
+	tx, err := a.sqlDB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("update group: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var taken bool
+	if err := tx.QueryRowContext(ctx, `
+		SELECT EXISTS (SELECT 1 FROM scim_groups
+		               WHERE tenant_id = $1 AND LOWER(display_name) = LOWER($2) AND id <> $3)`,
+		g.TenantID, g.DisplayName, g.ID).Scan(&taken); err != nil {
+		return nil, fmt.Errorf("update group: %w", err)
+	}
+	if taken {
+		return nil, fmt.Errorf("%w: group %s", ErrSCIMConflict, g.DisplayName)
+	}
+	result, err := tx.ExecContext(ctx, `
+		UPDATE scim_groups SET display_name = $3, external_id = $4, updated_at = NOW()
+		WHERE id = $1 AND tenant_id = $2`, g.ID, g.TenantID, g.DisplayName, g.ExternalID)
+	if err != nil {
+		return nil, fmt.Errorf("update group: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return nil, ErrSCIMGroupNotFound
+	}
+	former, err := queryStrings(ctx, tx, `DELETE FROM scim_group_members WHERE group_id = $1 RETURNING user_id`, g.ID)
+	if err != nil {
+		return nil, fmt.Errorf("update group: %w", err)
+	}
+	if err := setGroupMembers(ctx, tx, g, former); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("update group: %w", err)
+	}
+	return a.GetSCIMGroup(ctx, g.TenantID, g.ID)
+}
+
+// DeleteSCIMGroup deletes a group and revokes the role it granted.
+func (a *AuthService) DeleteSCIMGroup(ctx context.Context, tenantID, id string) error {
+	if a.sqlDB == nil {
+		return fmt.Errorf("database not initialized")
+	}
+	tx, err := a.sqlDB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("delete group: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	former, err := queryStrings(ctx, tx, `
+		DELETE FROM scim_group_members WHERE group_id = (
+			SELECT id FROM scim_groups WHERE id = $1 AND tenant_id = $2)
+		RETURNING user_id`, id, tenantID)
+	if err != nil {
+		return fmt.Errorf("delete group: %w", err)
+	}
+	result, err := tx.ExecContext(ctx, `DELETE FROM scim_groups WHERE id = $1 AND tenant_id = $2`, id, tenantID)
+	if err != nil {
+		return fmt.Errorf("delete group: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrSCIMGroupNotFound
+	}
+	cfg, err := loadSCIMConfig(ctx, tx, tenantID)
+	if err != nil {
+		return err
+	}
+	if err := syncSCIMRoles(ctx, tx, cfg, former); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// setGroupMembers adds g's members, which must be members of its tenant
+// and become SCIM-managed, then re-syncs their roles and those of the
+// former members.
+func setGroupMembers(ctx context.Context, tx *sql.Tx, g *SCIMGroup, former []string) error {
+	affected := append([]string(nil), former...)
+	seen := make(map[string]bool)
+	for _, ref := range g.Members {
+		if seen[ref.ID] {
+			continue
+		}
+		seen[ref.ID] = true
+		if _, err := uuid.Parse(ref.ID); err != nil {
+			return fmt.Errorf("%w: %s", ErrSCIMMemberNotFound, ref.ID)
+		}
+		result, err := tx.ExecContext(ctx, `
+			UPDATE tenant_members SET scim_managed = TRUE WHERE tenant_id = $1 AND user_id = $2`,
+			g.TenantID, ref.ID)
+		if err != nil {
+			return fmt.Errorf("add group member: %w", err)
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			return fmt.Errorf("%w: %s", ErrSCIMMemberNotFound, ref.ID)
+		}
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO scim_group_members (group_id, user_id) VALUES ($1, $2)
+			ON CONFLICT DO NOTHING`, g.ID, ref.ID); err != nil {
+			return fmt.Errorf("add group member: %w", err)
+		}
+		affected = append(affected, ref.ID)
+	}
+
+	cfg, err := loadSCIMConfig(ctx, tx, g.TenantID)
+	if err != nil {
+		return err
+	}
+	slices.Sort(affected)
+	return syncSCIMRoles(ctx, tx, cfg, slices.Compact(affected))
+}
@@ -0,0 +1,170 @@
+package auth
+
+import (
+	"context"
+	"database/sql/driver"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSCIMToken_CreateAndValidate(t *testing.T) {
+	ctx := context.Background()
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() { _ = db.Close() }()
+
+	mock.ExpectExec(`INSERT INTO scim_tokens`).
+		WithArgs(sqlmock.AnyArg(), "tenant-1", "Okta", sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	tok, plaintext, err := CreateSCIMToken(ctx, db, "tenant-1", "Okta")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(plaintext, "scim_"))
+	assert.Equal(t, "tenant-1", tok.TenantID)
+
+	mock.ExpectQuery(`UPDATE scim_tokens SET last_used_at`).WithArgs(hashSCIMToken(plaintext)).
+		WillReturnRows(sqlmock.NewRows([]string{"tenant_id"}).AddRow("tenant-1"))
+	tenantID, err := ValidateSCIMToken(ctx, db, plaintext)
+	require.NoError(t, err)
+	assert.Equal(t, "tenant-1", tenantID)
+
+	mock.ExpectQuery(`UPDATE scim_tokens SET last_used_at`).WithArgs(hashSCIMToken("scim_revoked")).
+		WillReturnRows(sqlmock.NewRows([]string{"tenant_id"}))
+	_, err = ValidateSCIMToken(ctx, db, "scim_revoked")
+	assert.ErrorIs(t, err, ErrSCIMTokenNotFound)
+
+	// Other bearer tokens never reach the database.
+	_, err = ValidateSCIMToken(ctx, db, "eyJhbGciOiJIUzI1NiJ9.e30.x")
+	assert.ErrorIs(t, err, ErrSCIMTokenNotFound)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSCIMConfig_Validate(t *testing.T) {
+	assert.NoError(t, defaultSCIMConfig().Validate())
+	assert.NoError(t, (&SCIMConfig{GroupRoles: map[string]string{"Contractors": "guest", "Auditors": "viewer"}}).Validate())
+	assert.ErrorContains(t, (&SCIMConfig{GroupRoles: map[string]string{"IT": "admin"}}).Validate(), "cannot be granted")
+	assert.ErrorContains(t, (&SCIMConfig{DefaultRole: "admin"}).Validate(), "cannot be granted")
+	assert.Error(t, (&SCIMConfig{GroupRoles: map[string]string{"": "user"}}).Validate())
+}
+
+func TestReplaceSCIMMember_Deprovisions(t *testing.T) {
+	ctx := context.Background()
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() { _ = db.Close() }()
+	svc := NewAuthService(nil, db)
+
+	tenant := &Tenant{ID: "tenant-1", UserID: "owner-1"}
+	svc.tenants[tenant.ID] = tenant
+	member := &User{ID: uuid.New().String(), Email: "jsmith@acme.com", TenantID: tenant.ID}
+	svc.users[member.Email] = member
+	svc.userIndex[member.ID] = member
+	for _, k := range []*APIKey{{ID: "k1", UserID: member.ID, Key: "VLT_member"}, {ID: "k2", UserID: "owner-1", Key: "VLT_owner"}} {
+		svc.apiKeys[k.Key] = k
+		svc.keyIndex[k.Key] = tenant
+	}
+	jwt, err := svc.GenerateJWT(member)
+	require.NoError(t, err)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT active FROM tenant_members`).WithArgs(tenant.ID, member.ID).
+		WillReturnRows(sqlmock.NewRows([]string{"active"}).AddRow(true))
+	mock.ExpectQuery(`SELECT EXISTS`).WithArgs(tenant.ID, "jsmith@acme.com", member.ID).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectExec(`UPDATE tenant_members`).
+		WithArgs(tenant.ID, member.ID, "jsmith@acme.com", "00u1", "Jane", "Smith", "Jane Smith", false).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`DELETE FROM sts_tokens`).WithArgs(member.ID, "user:"+member.ID).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec(`DELETE FROM api_keys`).WithArgs(member.ID).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`DELETE FROM dashboard_sessions`).WithArgs(member.ID).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`FROM scim_configs`).WithArgs(tenant.ID).
+		WillReturnRows(sqlmock.NewRows([]string{"group_roles", "default_role", "updated_at"}).
+			AddRow([]byte(`{"Storage":"user"}`), "viewer", time.Now()))
+	mock.ExpectQuery(`SELECT g.display_name FROM scim_group_members`).WithArgs(member.ID).
+		WillReturnRows(sqlmock.NewRows([]string{"display_name"}).AddRow("storage"))
+	mock.ExpectExec(`UPDATE users SET role`).WithArgs(member.ID, "user").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`DELETE FROM user_roles`).
+		WithArgs(member.ID, pq.Array([]string{"guest", "user", "viewer"}), pq.Array([]string{"user"})).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`INSERT INTO user_roles`).WithArgs(member.ID, pq.Array([]string{"user"})).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectQuery(`FROM tenant_members tm JOIN users u`).WithArgs(tenant.ID, member.ID).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "tenant_id", "user_name", "external_id", "email",
+			"given_name", "family_name", "display_name", "active", "created_at", "updated_at"}).
+			AddRow([]driver.Value{member.ID, tenant.ID, "jsmith@acme.com", "00u1", "jsmith@acme.com",
+				"Jane", "Smith", "Jane Smith", false, time.Now(), time.Now()}...))
+	mock.ExpectQuery(`FROM scim_group_members gm`).WithArgs(tenant.ID).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "id", "display_name"}))
+
+	got, err := svc.ReplaceSCIMMember(ctx, &TenantMember{
+		UserID: member.ID, TenantID: tenant.ID, UserName: "jsmith@acme.com", ExternalID: "00u1",
+		Email: "JSmith@acme.com", GivenName: "Jane", FamilyName: "Smith", DisplayName: "Jane Smith",
+	})
+	require.NoError(t, err)
+	assert.False(t, got.Active)
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	assert.True(t, member.Deactivated)
+	assert.NotContains(t, svc.apiKeys, "VLT_member")
+	assert.NotContains(t, svc.keyIndex, "VLT_member")
+	assert.Contains(t, svc.apiKeys, "VLT_owner", "other users' keys are untouched")
+
+	_, err = svc.ValidateJWT(jwt)
+	assert.ErrorIs(t, err, ErrUserDeactivated)
+	_, err = svc.GenerateAPIKey(ctx, member.ID, "new", nil)
+	assert.ErrorIs(t, err, ErrUserDeactivated)
+	_, err = svc.RequestPasswordReset(ctx, member.Email)
+	assert.Error(t, err)
+}
+
+func TestDeleteSCIMMember_InvalidatesJWT(t *testing.T) {
+	ctx := context.Background()
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() { _ = db.Close() }()
+	svc := NewAuthService(nil, db)
+
+	member := &User{ID: uuid.New().String(), Email: "jsmith@acme.com", TenantID: "tenant-1"}
+	svc.users[member.Email] = member
+	svc.userIndex[member.ID] = member
+	jwt, err := svc.GenerateJWT(member)
+	require.NoError(t, err)
+	_, err = svc.ValidateJWT(jwt)
+	require.NoError(t, err)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT EXISTS`).WithArgs("tenant-1", member.ID).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectExec(`DELETE FROM sts_tokens`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`DELETE FROM api_keys`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`DELETE FROM dashboard_sessions`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`DELETE FROM oauth_accounts`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`UPDATE user_roles SET granted_by = NULL`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`DELETE FROM users`).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	require.NoError(t, svc.DeleteSCIMMember(ctx, "tenant-1", member.ID))
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	_, err = svc.ValidateJWT(jwt)
+	assert.Error(t, err, "a deleted member's token must stop validating")
+}
+
+func TestGetSCIMMember_RejectsMalformedIDs(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() { _ = db.Close() }()
+	svc := NewAuthService(nil, db)
+
+	_, err = svc.GetSCIMMember(context.Background(), "tenant-1", "not-a-uuid")
+	assert.ErrorIs(t, err, ErrSCIMMemberNotFound)
+	assert.ErrorIs(t, svc.DeleteSCIMMember(context.Background(), "tenant-1", "1; DROP"), ErrSCIMMemberNotFound)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
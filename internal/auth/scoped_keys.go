@@ -1,6 +1,7 @@
 package auth
 
 import (
+	"crypto/subtle"
 	"fmt"
 	"net"
 	"strings"
@@ -15,6 +16,29 @@ type KeyScope struct {
 	BucketScope []string
 	IPAllowlist []string
 	ExpiresAt   *time.Time
+
+	// AccessKeyID, ParentKeyID (STS sessions only) and Primary identify the
+	// caller to bucket policies. Primary marks the tenant's own key, which
+	// can always manage a bucket's policy so a bad Deny cannot lock it out.
+	AccessKeyID string
+	ParentKeyID string
+	Primary     bool
+
+	// SessionToken is the X-Amz-Security-Token an STS session's requests
+	// must present; empty when the credential needs none.
+	SessionToken string
+}
+
+// CheckSessionToken reports whether presented satisfies the scope's
+// session token requirement.
+func (s *KeyScope) CheckSessionToken(presented string) error {
+	if s == nil || s.SessionToken == "" {
+		return nil
+	}
+	if subtle.ConstantTimeCompare([]byte(presented), []byte(s.SessionToken)) != 1 {
+		return ErrInvalidSecurityToken
+	}
+	return nil
 }
 
 // KeyCreateOptions specifies optional scope constraints when creating
@@ -59,6 +83,38 @@ var ValidPermissions = map[string]bool{
 	"GetObjectLegalHold":         true,
 	"PostObject":                 true,
 	"RestoreObject":              true,
+
+	"GetBucketLifecycleConfiguration": true,
+	"PutBucketLifecycleConfiguration": true,
+	"DeleteBucketLifecycle":           true,
+	"GetBucketPolicy":                 true,
+	"PutBucketPolicy":                 true,
+	"DeleteBucketPolicy":              true,
+	"GetBucketPolicyStatus":           true,
+	"SelectObjectContent":             true,
+	"GetBucketCors":                   true,
+	"PutBucketCors":                   true,
+	"DeleteBucketCors":                true,
+	"GetBucketEncryption":             true,
+	"PutBucketEncryption":             true,
+	"DeleteBucketEncryption":          true,
+	"GetBucketReplication":            true,
+	"PutBucketReplication":            true,
+	"DeleteBucketReplication":         true,
+	"GetBucketWebsite":                true,
+	"PutBucketWebsite":                true,
+	"DeleteBucketWebsite":             true,
+	"GetObjectAttributes":             true,
+	"GetBucketAcl":                    true,
+	"PutBucketAcl":                    true,
+	"GetObjectAcl":                    true,
+	"PutObjectAcl":                    true,
+	"GetBucketOwnershipControls":      true,
+	"PutBucketOwnershipControls":      true,
+	"DeleteBucketOwnershipControls":   true,
+	"GetPublicAccessBlock":            true,
+	"PutPublicAccessBlock":            true,
+	"DeletePublicAccessBlock":         true,
 }
 
 // CheckPermission returns true if keyPerms authorizes the given operation.
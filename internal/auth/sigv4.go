@@ -1,6 +1,7 @@
 package auth
 
 import (
+	"context"
 	"crypto/hmac"
 	"encoding/hex"
 	"errors"
@@ -23,6 +24,11 @@ var ErrSignatureMismatch = errors.New("request signature does not match")
 // clock skew. Maps to the S3 error code RequestTimeTooSkewed (403).
 var ErrRequestTimeSkewed = errors.New("request time too skewed")
 
+// ErrInvalidSecurityToken is returned when a request signed with an STS
+// session's keys omits or misstates the session's X-Amz-Security-Token.
+// Maps to the S3 error code InvalidToken (400).
+var ErrInvalidSecurityToken = errors.New("security token is missing or invalid")
+
 const unsignedPayload = "UNSIGNED-PAYLOAD"
 
 // sigV4Enforced reports whether full signature verification is required.
@@ -130,12 +136,13 @@ func (a *Auth) verifySigV4(r *http.Request, p *sigV4Params, secretKey string) er
 	// Query: spec/botocore encoded-pair sort order, or aws-sdk-go's raw-key
 	// sort order (they diverge when a key/value byte sorts differently from
 	// its %-encoding).
-	uris := []string{canonicalURIV4(r.URL.Path)}
-	if wire := r.URL.EscapedPath(); wire != "" && wire != uris[0] {
+	signed := SignedURL(r)
+	uris := []string{canonicalURIV4(signed.Path)}
+	if wire := signed.EscapedPath(); wire != "" && wire != uris[0] {
 		uris = append(uris, wire)
 	}
-	queries := []string{canonicalQueryV4(r.URL.Query())}
-	if raw := canonicalQueryV4RawSort(r.URL.Query()); raw != queries[0] {
+	queries := []string{canonicalQueryV4(signed.Query())}
+	if raw := canonicalQueryV4RawSort(signed.Query()); raw != queries[0] {
 		queries = append(queries, raw)
 	}
 	for _, u := range uris {
@@ -213,6 +220,25 @@ func trimAWSSpaces(s string) string {
 	return strings.Join(strings.Fields(s), " ")
 }
 
+type signedURLKey struct{}
+
+// WithSignedURL records the URL the client signed on a request the server
+// rewrites before authenticating it: a virtual-hosted-style request
+// (bucket.s3.example.com/key) is routed as /bucket/key, but its signature
+// covers the path as sent.
+func WithSignedURL(r *http.Request, u *url.URL) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), signedURLKey{}, u))
+}
+
+// SignedURL returns the URL a request's signature covers: the one recorded
+// by WithSignedURL, else the request's own.
+func SignedURL(r *http.Request) *url.URL {
+	if u, ok := r.Context().Value(signedURLKey{}).(*url.URL); ok {
+		return u
+	}
+	return r.URL
+}
+
 // canonicalURIV4 re-encodes the decoded request path with AWS URI encoding
 // rules (S3 single-encoding: each segment percent-encoded once, slashes
 // preserved). This reproduces what spec-compliant clients sign regardless
@@ -509,6 +509,23 @@ func TestVerifySigV4_EncodedSlashInKey(t *testing.T) {
 		"wire-form %%2F path signed by the client must verify")
 }
 
+func TestVerifySigV4_VirtualHostedStyle(t *testing.T) {
+	// The client signs the path without the bucket; the server routes the
+	// request path-style and verifies against the recorded signed URL.
+	r := httptest.NewRequest("GET", "http://photos.s3.stored.ge/2026/a.jpg?versionId=v1", nil)
+	signV4(t, r, testAK, testSecret, "us-east-1", sha256Hex(""), time.Now().UTC())
+	signed := r.URL
+
+	routed := *r.URL
+	routed.Path = "/photos" + r.URL.Path
+	r.URL = &routed
+	require.Error(t, verify(t, r, testSecret), "the rewritten path is not what was signed")
+
+	r = WithSignedURL(r, signed)
+	assert.Equal(t, "/photos/2026/a.jpg", r.URL.Path)
+	require.NoError(t, verify(t, r, testSecret))
+}
+
 func TestVerifySigV4_QuerySortOrderVariants(t *testing.T) {
 	// Keys 'a:' and 'a-' sort differently raw vs encoded ('%'=0x25 < '-'=0x2D
 	// < ':'=0x3A). aws-sdk-go signs the raw-key order; botocore signs the
@@ -4,6 +4,7 @@ import (
 	"context"
 	"crypto/rand"
 	"database/sql"
+	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
@@ -23,6 +24,10 @@ type STSToken struct {
 	IPRestrict  []string  `json:"ip_restrict"`
 	ExpiresAt   time.Time `json:"expires_at"`
 	CreatedAt   time.Time `json:"created_at"`
+
+	// SessionToken, when set, must accompany every request signed with the
+	// token's keys as X-Amz-Security-Token.
+	SessionToken string `json:"session_token,omitempty"`
 }
 
 type STSRequest struct {
@@ -84,22 +89,36 @@ func GenerateSTSToken(ctx context.Context, db *sql.DB, tenantID, parentKeyID str
 	}
 
 	if db != nil {
-		permJSON, _ := json.Marshal(token.Permissions)
-		_, err = db.ExecContext(ctx, `
-			INSERT INTO sts_tokens (access_key, secret_key, tenant_id, parent_key_id,
-			                        permissions, bucket_scope, ip_restrict, expires_at, created_at)
-			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
-		`, token.AccessKey, token.SecretKey, token.TenantID, token.ParentKeyID,
-			permJSON, pq.Array(token.BucketScope), pq.Array(token.IPRestrict),
-			token.ExpiresAt, token.CreatedAt)
-		if err != nil {
-			return nil, fmt.Errorf("persist STS token: %w", err)
+		if err := insertSTSToken(ctx, db, token); err != nil {
+			return nil, err
 		}
 	}
 
 	return token, nil
 }
 
+// STSUserParent is the parent of the STS sessions a tenant member mints
+// without an API key, so that deprovisioning the member revokes them.
+func STSUserParent(userID string) string {
+	return "user:" + userID
+}
+
+func insertSTSToken(ctx context.Context, db *sql.DB, token *STSToken) error {
+	permJSON, _ := json.Marshal(token.Permissions)
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO sts_tokens (access_key, secret_key, tenant_id, parent_key_id,
+		                        permissions, bucket_scope, ip_restrict, expires_at, created_at,
+		                        session_token)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	`, token.AccessKey, token.SecretKey, token.TenantID, token.ParentKeyID,
+		permJSON, pq.Array(token.BucketScope), pq.Array(token.IPRestrict),
+		token.ExpiresAt, token.CreatedAt, token.SessionToken)
+	if err != nil {
+		return fmt.Errorf("persist STS token: %w", err)
+	}
+	return nil
+}
+
 func StartSTSCleanup(ctx context.Context, db *sql.DB, logger *zap.Logger) {
 	go func() {
 		ticker := time.NewTicker(1 * time.Hour)
@@ -137,6 +156,14 @@ func generateSTSSecretKey() (string, error) {
 	return secret, nil
 }
 
+func generateSTSSessionToken() (string, error) {
+	b := make([]byte, 48)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
 func intersectPermissions(parent, requested []string) []string {
 	if len(requested) == 0 {
 		return parent
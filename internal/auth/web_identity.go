@@ -0,0 +1,279 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/lib/pq"
+)
+
+// Web identity session limits, in seconds. AWS allows 15 minutes to 12
+// hours; a role's MaxSessionDuration caps what a caller may request.
+const (
+	webIdentityMinDuration     = 900
+	webIdentityDefaultDuration = 3600
+)
+
+var (
+	// ErrWebIdentityDenied means no role matched the request: the role does
+	// not exist or the token's claims fail its conditions. The two are not
+	// distinguished, so callers cannot probe for role names.
+	ErrWebIdentityDenied = errors.New("not authorized to perform sts:AssumeRoleWithWebIdentity")
+	// ErrInvalidRoleARN means a RoleArn is not arn:aws:iam::<tenant>:role/<name>.
+	ErrInvalidRoleARN = errors.New("invalid role ARN")
+	// ErrInvalidSessionDuration means DurationSeconds is outside the range
+	// the role allows.
+	ErrInvalidSessionDuration = errors.New("invalid DurationSeconds")
+)
+
+// roleNamePattern is IAM's role name syntax.
+var roleNamePattern = regexp.MustCompile(`^[\w+=,.@-]{1,64}$`)
+
+// WebIdentityProvider is an OpenID Connect issuer a tenant trusts to vouch
+// for AssumeRoleWithWebIdentity callers, such as GitHub Actions
+// (https://token.actions.githubusercontent.com) or GitLab CI.
+type WebIdentityProvider struct {
+	ID        string    `json:"id"`
+	TenantID  string    `json:"tenant_id"`
+	IssuerURL string    `json:"issuer_url"`
+	Audiences []string  `json:"audiences"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// WebIdentityRole maps tokens from a provider to scoped credentials.
+// Conditions name claims and the patterns they must match; each condition
+// is met when any of the claim's values matches any pattern.
+type WebIdentityRole struct {
+	ID                 string              `json:"id"`
+	TenantID           string              `json:"tenant_id"`
+	Name               string              `json:"name"`
+	ProviderID         string              `json:"provider_id"`
+	Conditions         map[string][]string `json:"conditions"`
+	Permissions        []string            `json:"permissions"`
+	BucketScope        []string            `json:"bucket_scope"`
+	MaxSessionDuration int                 `json:"max_session_duration"`
+	CreatedAt          time.Time           `json:"created_at"`
+}
+
+// ARN returns the role's ARN, the RoleArn callers pass to assume it.
+func (r *WebIdentityRole) ARN() string {
+	return "arn:aws:iam::" + r.TenantID + ":role/" + r.Name
+}
+
+// Scope returns the scope the role's sessions are limited to.
+func (r *WebIdentityRole) Scope() *KeyScope {
+	return &KeyScope{Permissions: r.Permissions, BucketScope: r.BucketScope}
+}
+
+// Validate checks a role before it is stored. A sub condition is required:
+// without one the role would trust every workload the issuer signs tokens
+// for, such as every GitHub repository.
+func (r *WebIdentityRole) Validate() error {
+	if !roleNamePattern.MatchString(r.Name) {
+		return fmt.Errorf("role name must be 1-64 letters, digits or +=,.@_-")
+	}
+	if len(r.Conditions["sub"]) == 0 {
+		return fmt.Errorf("conditions must constrain the sub claim")
+	}
+	for claim, patterns := range r.Conditions {
+		if claim == "" || len(patterns) == 0 {
+			return fmt.Errorf("condition %q must list at least one pattern", claim)
+		}
+	}
+	if len(r.Permissions) == 0 {
+		return fmt.Errorf("permissions must not be empty")
+	}
+	if err := ValidatePermissions(r.Permissions); err != nil {
+		return err
+	}
+	if r.MaxSessionDuration < webIdentityMinDuration || r.MaxSessionDuration > stsMaxTTL {
+		return fmt.Errorf("max_session_duration must be between %d and %d seconds", webIdentityMinDuration, stsMaxTTL)
+	}
+	return nil
+}
+
+// matches reports whether claims satisfy every condition.
+func (r *WebIdentityRole) matches(claims jwt.MapClaims) bool {
+	for claim, patterns := range r.Conditions {
+		if !anyValue(claimValues(claims[claim]), func(v string) bool {
+			return anyPolicyMatch(patterns, v, false)
+		}) {
+			return false
+		}
+	}
+	return true
+}
+
+// claimValues renders a claim as strings: arrays (such as aud or groups)
+// yield one value per element.
+func claimValues(v interface{}) []string {
+	switch x := v.(type) {
+	case string:
+		return []string{x}
+	case bool:
+		return []string{strconv.FormatBool(x)}
+	case float64:
+		return []string{strconv.FormatFloat(x, 'f', -1, 64)}
+	case []interface{}:
+		var out []string
+		for _, e := range x {
+			out = append(out, claimValues(e)...)
+		}
+		return out
+	}
+	return nil
+}
+
+// ParseRoleARN splits arn:aws:iam::<tenant>:role/<name> into its tenant ID
+// and role name. Role paths (role/ci/deploy) are not supported.
+func ParseRoleARN(arn string) (tenantID, name string, err error) {
+	parts := strings.SplitN(arn, ":", 6)
+	if len(parts) != 6 || parts[0] != "arn" || parts[2] != "iam" || parts[4] == "" ||
+		!strings.HasPrefix(parts[5], "role/") {
+		return "", "", ErrInvalidRoleARN
+	}
+	name = strings.TrimPrefix(parts[5], "role/")
+	if !roleNamePattern.MatchString(name) {
+		return "", "", ErrInvalidRoleARN
+	}
+	return parts[4], name, nil
+}
+
+// WebIdentityRequest holds the AssumeRoleWithWebIdentity parameters.
+type WebIdentityRequest struct {
+	RoleARN          string
+	RoleSessionName  string
+	WebIdentityToken string
+	DurationSeconds  int
+}
+
+// WebIdentitySession is an assumed role: the credentials and the identity
+// they were issued to.
+type WebIdentitySession struct {
+	Token       *STSToken
+	Role        *WebIdentityRole
+	SessionName string
+	Subject     string
+	Audience    string
+	Provider    string
+}
+
+// AssumedRoleARN returns the session's ARN in AWS's assumed-role form.
+func (s *WebIdentitySession) AssumedRoleARN() string {
+	return "arn:aws:sts::" + s.Role.TenantID + ":assumed-role/" + s.Role.Name + "/" + s.SessionName
+}
+
+// AssumeRoleWithWebIdentity exchanges an OIDC ID token for temporary
+// credentials scoped to the role named by req.RoleARN.
+func AssumeRoleWithWebIdentity(ctx context.Context, db *sql.DB, v *OIDCVerifier, req WebIdentityRequest) (*WebIdentitySession, error) {
+	tenantID, name, err := ParseRoleARN(req.RoleARN)
+	if err != nil {
+		return nil, err
+	}
+	role, provider, err := LoadWebIdentityRole(ctx, db, tenantID, name)
+	if err != nil {
+		return nil, err
+	}
+	return AssumeWebIdentityRole(ctx, db, v, role, provider, req)
+}
+
+// AssumeWebIdentityRole verifies req's token against provider, checks it
+// against role's conditions and issues the session. With a nil db the
+// credentials are not persisted, as with GenerateSTSToken.
+func AssumeWebIdentityRole(ctx context.Context, db *sql.DB, v *OIDCVerifier, role *WebIdentityRole, provider *WebIdentityProvider, req WebIdentityRequest) (*WebIdentitySession, error) {
+	claims, err := v.Verify(ctx, req.WebIdentityToken, provider.IssuerURL, provider.Audiences)
+	if err != nil {
+		return nil, err
+	}
+	if !role.matches(claims) {
+		return nil, ErrWebIdentityDenied
+	}
+
+	maxTTL := role.MaxSessionDuration
+	if maxTTL <= 0 {
+		maxTTL = webIdentityDefaultDuration
+	}
+	ttl := req.DurationSeconds
+	if ttl == 0 {
+		ttl = min(webIdentityDefaultDuration, maxTTL)
+	}
+	if ttl < webIdentityMinDuration || ttl > maxTTL {
+		return nil, fmt.Errorf("%w: must be between %d and %d", ErrInvalidSessionDuration, webIdentityMinDuration, maxTTL)
+	}
+
+	token, err := GenerateSTSToken(ctx, nil, role.TenantID, "role:"+role.Name, role.Scope(), STSRequest{TTL: ttl})
+	if err != nil {
+		return nil, err
+	}
+	token.SessionToken, err = generateSTSSessionToken()
+	if err != nil {
+		return nil, fmt.Errorf("generate STS session token: %w", err)
+	}
+	if db != nil {
+		if err := insertSTSToken(ctx, db, token); err != nil {
+			return nil, err
+		}
+	}
+
+	sub, _ := claims["sub"].(string)
+	auds, _ := claims.GetAudience()
+	audience := ""
+	for _, a := range auds {
+		if anyValue(provider.Audiences, func(p string) bool { return p == a }) {
+			audience = a
+			break
+		}
+	}
+	return &WebIdentitySession{
+		Token:       token,
+		Role:        role,
+		SessionName: req.RoleSessionName,
+		Subject:     sub,
+		Audience:    audience,
+		Provider:    provider.IssuerURL,
+	}, nil
+}
+
+// LoadWebIdentityRole loads a tenant's role by name with its provider.
+func LoadWebIdentityRole(ctx context.Context, db *sql.DB, tenantID, name string) (*WebIdentityRole, *WebIdentityProvider, error) {
+	if db == nil {
+		return nil, nil, fmt.Errorf("database not initialized")
+	}
+	var role WebIdentityRole
+	var provider WebIdentityProvider
+	var conditions, permissions []byte
+	var bucketScope, audiences pq.StringArray
+	err := db.QueryRowContext(ctx, `
+		SELECT r.id, r.tenant_id, r.name, r.provider_id, r.conditions, r.permissions,
+		       r.bucket_scope, r.max_session_seconds, r.created_at,
+		       p.id, p.tenant_id, p.issuer_url, p.audiences, p.created_at
+		FROM sts_roles r
+		JOIN oidc_providers p ON p.id = r.provider_id AND p.tenant_id = r.tenant_id
+		WHERE r.tenant_id = $1 AND r.name = $2
+	`, tenantID, name).Scan(&role.ID, &role.TenantID, &role.Name, &role.ProviderID, &conditions, &permissions,
+		&bucketScope, &role.MaxSessionDuration, &role.CreatedAt,
+		&provider.ID, &provider.TenantID, &provider.IssuerURL, &audiences, &provider.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil, ErrWebIdentityDenied
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("load role: %w", err)
+	}
+	if err := json.Unmarshal(conditions, &role.Conditions); err != nil {
+		return nil, nil, fmt.Errorf("decode role conditions: %w", err)
+	}
+	if err := json.Unmarshal(permissions, &role.Permissions); err != nil {
+		return nil, nil, fmt.Errorf("decode role permissions: %w", err)
+	}
+	role.BucketScope = []string(bucketScope)
+	provider.Audiences = []string(audiences)
+	return &role, &provider, nil
+}
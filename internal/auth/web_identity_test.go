@@ -0,0 +1,161 @@
+package auth
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRoleARN(t *testing.T) {
+	tenant, name, err := ParseRoleARN("arn:aws:iam::tenant-1:role/ci-deploy")
+	require.NoError(t, err)
+	assert.Equal(t, "tenant-1", tenant)
+	assert.Equal(t, "ci-deploy", name)
+
+	for _, arn := range []string{
+		"",
+		"arn:aws:iam::tenant-1:user/ci-deploy",
+		"arn:aws:s3::tenant-1:role/ci-deploy",
+		"arn:aws:iam:::role/ci-deploy",
+		"arn:aws:iam::tenant-1:role/ci/deploy",
+		"arn:aws:iam::tenant-1:role/",
+	} {
+		_, _, err := ParseRoleARN(arn)
+		assert.ErrorIs(t, err, ErrInvalidRoleARN, arn)
+	}
+}
+
+func TestWebIdentityRole_Validate(t *testing.T) {
+	valid := func() *WebIdentityRole {
+		return &WebIdentityRole{
+			Name:               "ci-deploy",
+			Conditions:         map[string][]string{"sub": {"repo:acme/*"}},
+			Permissions:        []string{"GetObject", "PutObject"},
+			MaxSessionDuration: 3600,
+		}
+	}
+	require.NoError(t, valid().Validate())
+
+	tests := []struct {
+		name   string
+		mutate func(r *WebIdentityRole)
+	}{
+		{"bad name", func(r *WebIdentityRole) { r.Name = "ci deploy" }},
+		{"no sub condition", func(r *WebIdentityRole) { r.Conditions = map[string][]string{"aud": {"x"}} }},
+		{"empty patterns", func(r *WebIdentityRole) { r.Conditions["ref"] = nil }},
+		{"no permissions", func(r *WebIdentityRole) { r.Permissions = nil }},
+		{"bad permission", func(r *WebIdentityRole) { r.Permissions = []string{"Teleport"} }},
+		{"duration too short", func(r *WebIdentityRole) { r.MaxSessionDuration = 60 }},
+		{"duration too long", func(r *WebIdentityRole) { r.MaxSessionDuration = stsMaxTTL + 1 }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := valid()
+			tt.mutate(r)
+			assert.Error(t, r.Validate())
+		})
+	}
+}
+
+func TestWebIdentityRole_Matches(t *testing.T) {
+	role := &WebIdentityRole{Conditions: map[string][]string{
+		"sub":    {"repo:acme/app:ref:refs/heads/*", "repo:acme/app:environment:prod"},
+		"groups": {"deployers"},
+	}}
+
+	assert.True(t, role.matches(jwt.MapClaims{
+		"sub":    "repo:acme/app:ref:refs/heads/main",
+		"groups": []interface{}{"devs", "deployers"},
+	}))
+	assert.True(t, role.matches(jwt.MapClaims{
+		"sub":    "repo:acme/app:environment:prod",
+		"groups": "deployers",
+	}))
+	assert.False(t, role.matches(jwt.MapClaims{
+		"sub":    "repo:acme/other:ref:refs/heads/main",
+		"groups": "deployers",
+	}), "sub must match")
+	assert.False(t, role.matches(jwt.MapClaims{
+		"sub": "repo:acme/app:ref:refs/heads/main",
+	}), "missing claims never match")
+	assert.False(t, role.matches(jwt.MapClaims{
+		"sub":    "repo:ACME/app:ref:refs/heads/main",
+		"groups": "deployers",
+	}), "matching is case-sensitive")
+
+	flag := &WebIdentityRole{Conditions: map[string][]string{"sub": {"*"}, "protected": {"true"}, "run": {"4?"}}}
+	assert.True(t, flag.matches(jwt.MapClaims{"sub": "x", "protected": true, "run": float64(42)}))
+	assert.False(t, flag.matches(jwt.MapClaims{"sub": "x", "protected": false, "run": float64(42)}))
+}
+
+func TestAssumeWebIdentityRole(t *testing.T) {
+	idp := newTestIdP(t)
+	v := NewOIDCVerifier(idp.srv.Client())
+	ctx := context.Background()
+	provider := &WebIdentityProvider{IssuerURL: idp.issuer(), Audiences: []string{"sts.vaultaire"}}
+	role := &WebIdentityRole{
+		ID:                 "role_1",
+		TenantID:           "tenant-1",
+		Name:               "ci-deploy",
+		Conditions:         map[string][]string{"sub": {"repo:acme/app:*"}},
+		Permissions:        []string{"PutObject"},
+		BucketScope:        []string{"artifacts"},
+		MaxSessionDuration: 7200,
+	}
+	request := func(duration int) WebIdentityRequest {
+		return WebIdentityRequest{
+			RoleARN:          role.ARN(),
+			RoleSessionName:  "run-42",
+			WebIdentityToken: idp.sign(t, "rsa-1", jwt.MapClaims{}),
+			DurationSeconds:  duration,
+		}
+	}
+
+	sess, err := AssumeWebIdentityRole(ctx, nil, v, role, provider, request(0))
+	require.NoError(t, err)
+	assert.Equal(t, "tenant-1", sess.Token.TenantID)
+	assert.Equal(t, "role:ci-deploy", sess.Token.ParentKeyID)
+	assert.True(t, strings.HasPrefix(sess.Token.AccessKey, "ASIA"), sess.Token.AccessKey)
+	assert.NotEmpty(t, sess.Token.SecretKey)
+	assert.NotEmpty(t, sess.Token.SessionToken)
+	assert.Equal(t, []string{"PutObject"}, sess.Token.Permissions)
+	assert.Equal(t, []string{"artifacts"}, sess.Token.BucketScope)
+	assert.InDelta(t, 3600, sess.Token.ExpiresAt.Sub(sess.Token.CreatedAt).Seconds(), 1)
+	assert.Equal(t, "repo:acme/app:ref:refs/heads/main", sess.Subject)
+	assert.Equal(t, "sts.vaultaire", sess.Audience)
+	assert.Equal(t, "arn:aws:sts::tenant-1:assumed-role/ci-deploy/run-42", sess.AssumedRoleARN())
+
+	sess, err = AssumeWebIdentityRole(ctx, nil, v, role, provider, request(7200))
+	require.NoError(t, err)
+	assert.InDelta(t, 7200, sess.Token.ExpiresAt.Sub(sess.Token.CreatedAt).Seconds(), 1)
+
+	_, err = AssumeWebIdentityRole(ctx, nil, v, role, provider, request(7201))
+	assert.ErrorIs(t, err, ErrInvalidSessionDuration)
+	_, err = AssumeWebIdentityRole(ctx, nil, v, role, provider, request(899))
+	assert.ErrorIs(t, err, ErrInvalidSessionDuration)
+
+	other := request(0)
+	other.WebIdentityToken = idp.sign(t, "rsa-1", jwt.MapClaims{"sub": "repo:evil/app:ref:refs/heads/main"})
+	_, err = AssumeWebIdentityRole(ctx, nil, v, role, provider, other)
+	assert.ErrorIs(t, err, ErrWebIdentityDenied)
+
+	wrongAud := request(0)
+	wrongAud.WebIdentityToken = idp.sign(t, "rsa-1", jwt.MapClaims{"aud": "sts.amazonaws.com"})
+	_, err = AssumeWebIdentityRole(ctx, nil, v, role, provider, wrongAud)
+	assert.ErrorIs(t, err, ErrInvalidIDToken)
+}
+
+func TestKeyScope_CheckSessionToken(t *testing.T) {
+	var unbound KeyScope
+	assert.NoError(t, unbound.CheckSessionToken(""))
+	assert.NoError(t, unbound.CheckSessionToken("anything"))
+
+	bound := KeyScope{SessionToken: "tok"}
+	assert.NoError(t, bound.CheckSessionToken("tok"))
+	assert.ErrorIs(t, bound.CheckSessionToken(""), ErrInvalidSecurityToken)
+	assert.ErrorIs(t, bound.CheckSessionToken("tok2"), ErrInvalidSecurityToken)
+}
@@ -17,6 +17,54 @@ type ServerConfig struct {
 	Port        int    `yaml:"port" default:"8080"`
 	MetricsPort int    `yaml:"metrics_port" default:"9090"`
 	LogLevel    string `yaml:"log_level" default:"info"`
+
+	// VirtualHostDomains are the S3 endpoints that also accept
+	// virtual-hosted-style requests: bucket.s3.stored.ge/key as well as
+	// s3.stored.ge/bucket/key. Each needs a wildcard DNS record
+	// (*.s3.stored.ge) and a wildcard TLS certificate for it. A wildcard
+	// certificate covers a single label, so buckets with dots in their
+	// names only work over HTTPS path-style. Every subdomain of a listed
+	// domain is read as a bucket name: list only hosts dedicated to the S3
+	// API, never the apex serving the dashboard or the CDN.
+	VirtualHostDomains []string `yaml:"virtual_host_domains"`
+
+	// TrustedProxies are the reverse proxies (IP addresses or CIDRs) whose
+	// X-Forwarded-Proto header is believed when a bucket policy tests
+	// aws:SecureTransport. Behind a TLS-terminating proxy, list it here or
+	// every request counts as plaintext.
+	TrustedProxies []string `yaml:"trusted_proxies"`
+
+	// RateLimitStore is where request rate limits are kept, so replicas
+	// behind a load balancer share one limit: a redis:// (or rediss://)
+	// URL, or "postgres" for the rate_limit_buckets table. Empty keeps
+	// limits per process. Either store falls back to per-process limits
+	// while it is unreachable.
+	RateLimitStore string `yaml:"rate_limit_store"`
+
+	// S3OperationLimits caps how often each tenant may call an S3
+	// operation, keyed by operation name (PutObject, GetObject,
+	// ListObjects, ...). Operations not listed are unlimited. The limits
+	// are kept in RateLimitStore like the request limits.
+	S3OperationLimits map[string]OperationLimit `yaml:"s3_operation_limits"`
+
+	// BandwidthLimit caps each tenant's object data in bytes per second,
+	// with uploads and downloads (S3 and CDN) limited separately. Zero is
+	// unlimited.
+	BandwidthLimit int64 `yaml:"bandwidth_limit"`
+
+	// NotificationTargets maps the ARNs that bucket notification
+	// configurations may name (Queue, CloudFunction or Topic) to where
+	// their events go: a stream://, nats://, kafka://, amqp(s):// or
+	// redis(s):// URL; see internal/notify for each form. Configurations
+	// naming an ARN not listed here are rejected.
+	NotificationTargets map[string]string `yaml:"notification_targets"`
+}
+
+// OperationLimit is a token bucket: Rate requests per second, up to Burst
+// at once.
+type OperationLimit struct {
+	Rate  int `yaml:"rate"`
+	Burst int `yaml:"burst"`
 }
 
 type EngineConfig struct {
@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"strconv"
+	"strings"
 )
 
 // LoadFromEnv loads configuration from environment variables
@@ -24,9 +25,82 @@ func LoadFromEnv(cfg *Config) {
 		}
 	}
 
+	if domains := os.Getenv("VAULTAIRE_VIRTUAL_HOST_DOMAINS"); domains != "" {
+		cfg.Server.VirtualHostDomains = SplitList(domains)
+	}
+	if proxies := os.Getenv("VAULTAIRE_TRUSTED_PROXIES"); proxies != "" {
+		cfg.Server.TrustedProxies = SplitList(proxies)
+	}
+	if store := os.Getenv("VAULTAIRE_RATE_LIMIT_STORE"); store != "" {
+		cfg.Server.RateLimitStore = store
+	}
+	if limits := os.Getenv("VAULTAIRE_S3_OPERATION_LIMITS"); limits != "" {
+		cfg.Server.S3OperationLimits = ParseOperationLimits(limits)
+	}
+	if bw := os.Getenv("VAULTAIRE_BANDWIDTH_LIMIT"); bw != "" {
+		if n, err := strconv.ParseInt(bw, 10, 64); err == nil {
+			cfg.Server.BandwidthLimit = n
+		}
+	}
+	if targets := os.Getenv("VAULTAIRE_NOTIFICATION_TARGETS"); targets != "" {
+		cfg.Server.NotificationTargets = SplitMap(targets)
+	}
+
 	// Add more as needed for production
 }
 
+// SplitList splits a comma-separated environment value, dropping blanks.
+func SplitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// SplitMap splits a semicolon-separated list of key=value pairs, dropping
+// blanks. Only the first "=" of a pair separates, so values may hold more.
+func SplitMap(value string) map[string]string {
+	var items map[string]string
+	for _, item := range strings.Split(value, ";") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+		k, v, _ := strings.Cut(item, "=")
+		if items == nil {
+			items = make(map[string]string)
+		}
+		items[strings.TrimSpace(k)] = strings.TrimSpace(v)
+	}
+	return items
+}
+
+// ParseOperationLimits parses Operation=rate:burst pairs separated by ";".
+// The burst defaults to the rate; pairs that do not parse are dropped.
+func ParseOperationLimits(value string) map[string]OperationLimit {
+	var limits map[string]OperationLimit
+	for op, spec := range SplitMap(value) {
+		rateStr, burstStr, hasBurst := strings.Cut(spec, ":")
+		rate, err := strconv.Atoi(strings.TrimSpace(rateStr))
+		if err != nil || rate <= 0 {
+			continue
+		}
+		burst := rate
+		if hasBurst {
+			if burst, err = strconv.Atoi(strings.TrimSpace(burstStr)); err != nil || burst <= 0 {
+				continue
+			}
+		}
+		if limits == nil {
+			limits = make(map[string]OperationLimit)
+		}
+		limits[op] = OperationLimit{Rate: rate, Burst: burst}
+	}
+	return limits
+}
+
 // GetEnvOrDefault returns environment variable or default value
 func GetEnvOrDefault(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
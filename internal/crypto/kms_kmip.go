@@ -0,0 +1,370 @@
+package crypto
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"time"
+)
+
+// KMIPConfig configures a KMIPClient. Servers authenticate clients by TLS
+// certificate.
+type KMIPConfig struct {
+	Address  string // host:port, usually :5696
+	CertFile string
+	KeyFile  string
+	CAFile   string // optional; system roots when empty
+	Timeout  time.Duration
+	// TLSConfig overrides the certificate files when set.
+	TLSConfig *tls.Config
+}
+
+// KMIPClient is a KeyProvider speaking KMIP 1.4 (TTLV over TLS) to an HSM or
+// key manager. Data keys are generated locally and wrapped with the
+// server-side Encrypt operation under the key's Unique Identifier using
+// AES-GCM, with the encryption context as additional authenticated data;
+// unwrapping uses Decrypt. Revoking or destroying the key on the server
+// makes the wrapped data keys unusable.
+type KMIPClient struct {
+	addr    string
+	tls     *tls.Config
+	timeout time.Duration
+	dial    func(ctx context.Context) (net.Conn, error)
+}
+
+// NewKMIPClient loads the client certificate and returns the provider.
+func NewKMIPClient(cfg KMIPConfig) (*KMIPClient, error) {
+	if cfg.Address == "" {
+		return nil, fmt.Errorf("kms: kmip address is required")
+	}
+	tlsCfg := cfg.TLSConfig
+	if tlsCfg == nil {
+		if cfg.CertFile == "" || cfg.KeyFile == "" {
+			return nil, fmt.Errorf("kms: kmip client certificate and key are required")
+		}
+		cert, err := tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("kms: load kmip client certificate: %w", err)
+		}
+		tlsCfg = &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+		if cfg.CAFile != "" {
+			pem, err := os.ReadFile(cfg.CAFile)
+			if err != nil {
+				return nil, fmt.Errorf("kms: read kmip CA: %w", err)
+			}
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("kms: kmip CA file holds no certificates")
+			}
+			tlsCfg.RootCAs = pool
+		}
+	}
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	c := &KMIPClient{addr: cfg.Address, tls: tlsCfg, timeout: timeout}
+	c.dial = func(ctx context.Context) (net.Conn, error) {
+		d := tls.Dialer{NetDialer: &net.Dialer{Timeout: c.timeout}, Config: c.tls}
+		return d.DialContext(ctx, "tcp", c.addr)
+	}
+	return c, nil
+}
+
+// Name implements KeyProvider.
+func (c *KMIPClient) Name() string { return "kmip" }
+
+// KMIP tags, types and enumerations used by Encrypt and Decrypt.
+const (
+	kmipTagAttrAEADData     = 0x4200FE
+	kmipTagAEADTag          = 0x4200FF
+	kmipTagBatchCount       = 0x42000D
+	kmipTagBatchItem        = 0x42000F
+	kmipTagBlockCipherMode  = 0x420011
+	kmipTagCryptoAlgorithm  = 0x420028
+	kmipTagCryptoParameters = 0x42002B
+	kmipTagData             = 0x4200C2
+	kmipTagIVCounterNonce   = 0x42003D
+	kmipTagOperation        = 0x42005C
+	kmipTagProtocolVersion  = 0x420069
+	kmipTagProtocolMajor    = 0x42006A
+	kmipTagProtocolMinor    = 0x42006B
+	kmipTagRequestHeader    = 0x420077
+	kmipTagRequestMessage   = 0x420078
+	kmipTagRequestPayload   = 0x420079
+	kmipTagResponseMessage  = 0x42007B
+	kmipTagResponsePayload  = 0x42007C
+	kmipTagResultMessage    = 0x42007D
+	kmipTagResultReason     = 0x42007E
+	kmipTagResultStatus     = 0x42007F
+	kmipTagUniqueIdentifier = 0x420094
+
+	kmipTypeStructure   = 0x01
+	kmipTypeInteger     = 0x02
+	kmipTypeEnumeration = 0x05
+	kmipTypeTextString  = 0x07
+	kmipTypeByteString  = 0x08
+
+	kmipOpEncrypt = 0x1F
+	kmipOpDecrypt = 0x20
+
+	kmipModeGCM = 0x09
+	kmipAlgAES  = 0x03
+
+	kmipStatusSuccess = 0x00
+
+	kmipReasonItemNotFound      = 0x01
+	kmipReasonAuthFailed        = 0x03
+	kmipReasonCryptoFailure     = 0x0A
+	kmipReasonPermissionDenied  = 0x0C
+	kmipReasonWrongKeyLifecycle = 0x12
+
+	kmipWrapVersion byte = 0x01
+	kmipNonceSize        = 12
+	kmipMaxMessage       = 1 << 20
+)
+
+var errKMIPMalformed = errors.New("kms: malformed kmip message")
+
+// kmipItem is one decoded TTLV item; structures carry their children.
+type kmipItem struct {
+	tag      uint32
+	typ      byte
+	value    []byte
+	children []kmipItem
+}
+
+func (it kmipItem) find(tag uint32) (kmipItem, bool) {
+	for _, ch := range it.children {
+		if ch.tag == tag {
+			return ch, true
+		}
+	}
+	return kmipItem{}, false
+}
+
+func (it kmipItem) enum() uint32 {
+	if len(it.value) < 4 {
+		return 0
+	}
+	return binary.BigEndian.Uint32(it.value)
+}
+
+func kmipEncode(tag uint32, typ byte, value []byte) []byte {
+	out := make([]byte, 8, 8+len(value)+7)
+	out[0], out[1], out[2] = byte(tag>>16), byte(tag>>8), byte(tag)
+	out[3] = typ
+	binary.BigEndian.PutUint32(out[4:], uint32(len(value)))
+	out = append(out, value...)
+	if pad := (8 - len(value)%8) % 8; pad > 0 {
+		out = append(out, make([]byte, pad)...)
+	}
+	return out
+}
+
+func kmipStructure(tag uint32, children ...[]byte) []byte {
+	var body []byte
+	for _, ch := range children {
+		body = append(body, ch...)
+	}
+	return kmipEncode(tag, kmipTypeStructure, body)
+}
+
+func kmipInteger(tag uint32, v int32) []byte {
+	return kmipEncode(tag, kmipTypeInteger, binary.BigEndian.AppendUint32(nil, uint32(v)))
+}
+
+func kmipEnum(tag uint32, v uint32) []byte {
+	return kmipEncode(tag, kmipTypeEnumeration, binary.BigEndian.AppendUint32(nil, v))
+}
+
+func kmipText(tag uint32, s string) []byte { return kmipEncode(tag, kmipTypeTextString, []byte(s)) }
+
+func kmipBytes(tag uint32, b []byte) []byte { return kmipEncode(tag, kmipTypeByteString, b) }
+
+// kmipDecode parses one TTLV item from the front of b.
+func kmipDecode(b []byte) (kmipItem, []byte, error) {
+	if len(b) < 8 {
+		return kmipItem{}, nil, errKMIPMalformed
+	}
+	it := kmipItem{
+		tag: uint32(b[0])<<16 | uint32(b[1])<<8 | uint32(b[2]),
+		typ: b[3],
+	}
+	n := int(binary.BigEndian.Uint32(b[4:8]))
+	padded := n + (8-n%8)%8
+	if n < 0 || len(b)-8 < padded {
+		return kmipItem{}, nil, errKMIPMalformed
+	}
+	it.value = b[8 : 8+n]
+	if it.typ == kmipTypeStructure {
+		rest := it.value
+		for len(rest) > 0 {
+			ch, r, err := kmipDecode(rest)
+			if err != nil {
+				return kmipItem{}, nil, err
+			}
+			it.children = append(it.children, ch)
+			rest = r
+		}
+	}
+	return it, b[8+padded:], nil
+}
+
+// roundTrip sends one single-item request and returns the item's response
+// payload, mapping a failed result onto the KeyProvider errors.
+func (c *KMIPClient) roundTrip(ctx context.Context, op uint32, payload []byte) (kmipItem, error) {
+	msg := kmipStructure(kmipTagRequestMessage,
+		kmipStructure(kmipTagRequestHeader,
+			kmipStructure(kmipTagProtocolVersion,
+				kmipInteger(kmipTagProtocolMajor, 1),
+				kmipInteger(kmipTagProtocolMinor, 4)),
+			kmipInteger(kmipTagBatchCount, 1)),
+		kmipStructure(kmipTagBatchItem,
+			kmipEnum(kmipTagOperation, op),
+			payload))
+
+	conn, err := c.dial(ctx)
+	if err != nil {
+		return kmipItem{}, fmt.Errorf("kms: kmip connect: %w", err)
+	}
+	defer func() { _ = conn.Close() }()
+	deadline := time.Now().Add(c.timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	_ = conn.SetDeadline(deadline)
+
+	if _, err := conn.Write(msg); err != nil {
+		return kmipItem{}, fmt.Errorf("kms: kmip write: %w", err)
+	}
+	head := make([]byte, 8)
+	if _, err := io.ReadFull(conn, head); err != nil {
+		return kmipItem{}, fmt.Errorf("kms: kmip read: %w", err)
+	}
+	n := binary.BigEndian.Uint32(head[4:])
+	if n > kmipMaxMessage {
+		return kmipItem{}, errKMIPMalformed
+	}
+	raw := make([]byte, 8+int(n)+(8-int(n)%8)%8)
+	copy(raw, head)
+	if _, err := io.ReadFull(conn, raw[8:]); err != nil {
+		return kmipItem{}, fmt.Errorf("kms: kmip read: %w", err)
+	}
+
+	resp, _, err := kmipDecode(raw)
+	if err != nil {
+		return kmipItem{}, err
+	}
+	if resp.tag != kmipTagResponseMessage {
+		return kmipItem{}, errKMIPMalformed
+	}
+	item, ok := resp.find(kmipTagBatchItem)
+	if !ok {
+		return kmipItem{}, errKMIPMalformed
+	}
+	status, ok := item.find(kmipTagResultStatus)
+	if !ok {
+		return kmipItem{}, errKMIPMalformed
+	}
+	if status.enum() != kmipStatusSuccess {
+		return kmipItem{}, kmipResultError(item)
+	}
+	out, ok := item.find(kmipTagResponsePayload)
+	if !ok {
+		return kmipItem{}, errKMIPMalformed
+	}
+	return out, nil
+}
+
+func kmipResultError(item kmipItem) error {
+	var msg string
+	if m, ok := item.find(kmipTagResultMessage); ok {
+		msg = string(m.value)
+	}
+	reason, _ := item.find(kmipTagResultReason)
+	switch reason.enum() {
+	case kmipReasonItemNotFound:
+		return fmt.Errorf("%w: %s", ErrKMSKeyNotFound, msg)
+	case kmipReasonWrongKeyLifecycle:
+		return fmt.Errorf("%w: %s", ErrKMSKeyDisabled, msg)
+	case kmipReasonPermissionDenied, kmipReasonAuthFailed:
+		return fmt.Errorf("%w: %s", ErrKMSAccessDenied, msg)
+	case kmipReasonCryptoFailure:
+		return fmt.Errorf("%w: %s", ErrKMSInvalidCiphertext, msg)
+	default:
+		return fmt.Errorf("kms: kmip operation failed (reason %d): %s", reason.enum(), msg)
+	}
+}
+
+func kmipGCMParameters() []byte {
+	return kmipStructure(kmipTagCryptoParameters,
+		kmipEnum(kmipTagBlockCipherMode, kmipModeGCM),
+		kmipEnum(kmipTagCryptoAlgorithm, kmipAlgAES))
+}
+
+// GenerateDataKey implements KeyProvider. The wrapped form is
+// version | nonce | tag length | tag | ciphertext.
+func (c *KMIPClient) GenerateDataKey(ctx context.Context, keyID string, encCtx EncryptionContext) ([]byte, []byte, error) {
+	dataKey, err := newDataKey()
+	if err != nil {
+		return nil, nil, err
+	}
+	nonce := make([]byte, kmipNonceSize)
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, nil, fmt.Errorf("kms: generate nonce: %w", err)
+	}
+	out, err := c.roundTrip(ctx, kmipOpEncrypt, kmipStructure(kmipTagRequestPayload,
+		kmipText(kmipTagUniqueIdentifier, keyID),
+		kmipGCMParameters(),
+		kmipBytes(kmipTagData, dataKey),
+		kmipBytes(kmipTagIVCounterNonce, nonce),
+		kmipBytes(kmipTagAttrAEADData, encCtx.Canonical())))
+	if err != nil {
+		return nil, nil, err
+	}
+	data, ok := out.find(kmipTagData)
+	tag, hasTag := out.find(kmipTagAEADTag)
+	if !ok || !hasTag || len(tag.value) == 0 || len(tag.value) > 255 {
+		return nil, nil, errKMIPMalformed
+	}
+	wrapped := append([]byte{kmipWrapVersion}, nonce...)
+	wrapped = append(wrapped, byte(len(tag.value)))
+	wrapped = append(wrapped, tag.value...)
+	return dataKey, append(wrapped, data.value...), nil
+}
+
+// DecryptDataKey implements KeyProvider.
+func (c *KMIPClient) DecryptDataKey(ctx context.Context, keyID string, wrapped []byte, encCtx EncryptionContext) ([]byte, error) {
+	if len(wrapped) < 2+kmipNonceSize || wrapped[0] != kmipWrapVersion {
+		return nil, ErrKMSInvalidCiphertext
+	}
+	nonce := wrapped[1 : 1+kmipNonceSize]
+	tagLen := int(wrapped[1+kmipNonceSize])
+	rest := wrapped[2+kmipNonceSize:]
+	if len(rest) < tagLen {
+		return nil, ErrKMSInvalidCiphertext
+	}
+	out, err := c.roundTrip(ctx, kmipOpDecrypt, kmipStructure(kmipTagRequestPayload,
+		kmipText(kmipTagUniqueIdentifier, keyID),
+		kmipGCMParameters(),
+		kmipBytes(kmipTagData, rest[tagLen:]),
+		kmipBytes(kmipTagIVCounterNonce, nonce),
+		kmipBytes(kmipTagAttrAEADData, encCtx.Canonical()),
+		kmipBytes(kmipTagAEADTag, rest[:tagLen])))
+	if err != nil {
+		return nil, err
+	}
+	data, ok := out.find(kmipTagData)
+	if !ok || len(data.value) != DataKeySize {
+		return nil, ErrKMSInvalidCiphertext
+	}
+	return append([]byte(nil), data.value...), nil
+}
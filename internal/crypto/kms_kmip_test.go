@@ -0,0 +1,146 @@
+package crypto
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeKMIPServer answers Encrypt and Decrypt with AES-GCM under in-memory
+// keys; a revoked key fails with Wrong Key Lifecycle State.
+type fakeKMIPServer struct {
+	keys    map[string][]byte
+	revoked map[string]bool
+}
+
+func (f *fakeKMIPServer) client() *KMIPClient {
+	return &KMIPClient{
+		addr:    "fake",
+		timeout: 5 * time.Second,
+		dial: func(context.Context) (net.Conn, error) {
+			c, s := net.Pipe()
+			go f.serve(s)
+			return c, nil
+		},
+	}
+}
+
+func (f *fakeKMIPServer) serve(conn net.Conn) {
+	defer func() { _ = conn.Close() }()
+	head := make([]byte, 8)
+	if _, err := io.ReadFull(conn, head); err != nil {
+		return
+	}
+	n := int(binary.BigEndian.Uint32(head[4:]))
+	raw := make([]byte, 8+n+(8-n%8)%8)
+	copy(raw, head)
+	if _, err := io.ReadFull(conn, raw[8:]); err != nil {
+		return
+	}
+	msg, _, err := kmipDecode(raw)
+	if err != nil {
+		return
+	}
+	batch, _ := msg.find(kmipTagBatchItem)
+	op, _ := batch.find(kmipTagOperation)
+	req, _ := batch.find(kmipTagRequestPayload)
+	_, _ = conn.Write(f.handle(op.enum(), req))
+}
+
+func (f *fakeKMIPServer) handle(op uint32, req kmipItem) []byte {
+	fail := func(reason uint32, msg string) []byte {
+		return kmipStructure(kmipTagResponseMessage, kmipStructure(kmipTagBatchItem,
+			kmipEnum(kmipTagOperation, op),
+			kmipEnum(kmipTagResultStatus, 1),
+			kmipEnum(kmipTagResultReason, reason),
+			kmipText(kmipTagResultMessage, msg)))
+	}
+	uid, _ := req.find(kmipTagUniqueIdentifier)
+	key, ok := f.keys[string(uid.value)]
+	if !ok {
+		return fail(kmipReasonItemNotFound, "no such object")
+	}
+	if f.revoked[string(uid.value)] {
+		return fail(kmipReasonWrongKeyLifecycle, "key is not active")
+	}
+	data, _ := req.find(kmipTagData)
+	nonce, _ := req.find(kmipTagIVCounterNonce)
+	aad, _ := req.find(kmipTagAttrAEADData)
+	block, _ := aes.NewCipher(key)
+	gcm, _ := cipher.NewGCM(block)
+
+	var payload [][]byte
+	payload = append(payload, kmipText(kmipTagUniqueIdentifier, string(uid.value)))
+	switch op {
+	case kmipOpEncrypt:
+		sealed := gcm.Seal(nil, nonce.value, data.value, aad.value)
+		cut := len(sealed) - gcm.Overhead()
+		payload = append(payload, kmipBytes(kmipTagData, sealed[:cut]), kmipBytes(kmipTagAEADTag, sealed[cut:]))
+	case kmipOpDecrypt:
+		tag, _ := req.find(kmipTagAEADTag)
+		plain, err := gcm.Open(nil, nonce.value, append(append([]byte(nil), data.value...), tag.value...), aad.value)
+		if err != nil {
+			return fail(kmipReasonCryptoFailure, "authentication failed")
+		}
+		payload = append(payload, kmipBytes(kmipTagData, plain))
+	}
+	return kmipStructure(kmipTagResponseMessage, kmipStructure(kmipTagBatchItem,
+		kmipEnum(kmipTagOperation, op),
+		kmipEnum(kmipTagResultStatus, kmipStatusSuccess),
+		kmipStructure(kmipTagResponsePayload, payload...)))
+}
+
+func TestKMIP_TTLVRoundTrip(t *testing.T) {
+	msg := kmipStructure(kmipTagRequestPayload,
+		kmipText(kmipTagUniqueIdentifier, "key-1"),
+		kmipBytes(kmipTagData, []byte{1, 2, 3}),
+		kmipEnum(kmipTagOperation, kmipOpEncrypt))
+	assert.Zero(t, len(msg)%8, "items are padded to 8 bytes")
+
+	it, rest, err := kmipDecode(msg)
+	require.NoError(t, err)
+	assert.Empty(t, rest)
+	require.Len(t, it.children, 3)
+	uid, ok := it.find(kmipTagUniqueIdentifier)
+	require.True(t, ok)
+	assert.Equal(t, "key-1", string(uid.value))
+	op, _ := it.find(kmipTagOperation)
+	assert.Equal(t, uint32(kmipOpEncrypt), op.enum())
+
+	_, _, err = kmipDecode(msg[:len(msg)-8])
+	assert.ErrorIs(t, err, errKMIPMalformed)
+}
+
+func TestKMIP_WrapUnwrapAndRevoke(t *testing.T) {
+	fake := &fakeKMIPServer{
+		keys:    map[string][]byte{"hsm-key-1": streamTestKey(t)},
+		revoked: map[string]bool{},
+	}
+	c := fake.client()
+	ctx := context.Background()
+	encCtx := EncryptionContext{"tenant": "t1"}
+
+	plain, wrapped, err := c.GenerateDataKey(ctx, "hsm-key-1", encCtx)
+	require.NoError(t, err)
+	got, err := c.DecryptDataKey(ctx, "hsm-key-1", wrapped, encCtx)
+	require.NoError(t, err)
+	assert.Equal(t, plain, got)
+
+	_, err = c.DecryptDataKey(ctx, "hsm-key-1", wrapped, EncryptionContext{"tenant": "t2"})
+	assert.ErrorIs(t, err, ErrKMSInvalidCiphertext)
+
+	_, _, err = c.GenerateDataKey(ctx, "unknown", nil)
+	assert.ErrorIs(t, err, ErrKMSKeyNotFound)
+
+	fake.revoked["hsm-key-1"] = true
+	_, err = c.DecryptDataKey(ctx, "hsm-key-1", wrapped, encCtx)
+	assert.ErrorIs(t, err, ErrKMSKeyDisabled)
+}
@@ -0,0 +1,177 @@
+package crypto
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// LocalKeyring is a KeyProvider backed by a JSON keyring file, for
+// single-node deployments and tests:
+//
+//	{"keys": [{"id": "app-key", "material": "<base64 32 bytes>", "enabled": true}]}
+//
+// The file is re-read whenever it changes, so removing a key or setting
+// "enabled": false revokes it without a restart. Data keys are wrapped with
+// AES-256-GCM under the key material, bound to the key ID and context.
+type LocalKeyring struct {
+	path string
+
+	mu      sync.Mutex
+	modTime time.Time
+	size    int64
+	keys    map[string]localKey
+}
+
+type localKey struct {
+	material []byte
+	enabled  bool
+}
+
+type keyringFile struct {
+	Keys []struct {
+		ID       string `json:"id"`
+		Material string `json:"material"`
+		Enabled  *bool  `json:"enabled,omitempty"`
+	} `json:"keys"`
+}
+
+const localWrapVersion byte = 0x01
+
+// NewLocalKeyring loads the keyring file at path.
+func NewLocalKeyring(path string) (*LocalKeyring, error) {
+	if path == "" {
+		return nil, fmt.Errorf("kms: local keyring path is required")
+	}
+	k := &LocalKeyring{path: path}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if err := k.refresh(); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// Name implements KeyProvider.
+func (k *LocalKeyring) Name() string { return "local" }
+
+// key returns the named key, reloading the file if it changed.
+func (k *LocalKeyring) key(id string) (localKey, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if err := k.refresh(); err != nil {
+		return localKey{}, err
+	}
+
+	key, ok := k.keys[id]
+	if !ok {
+		return localKey{}, ErrKMSKeyNotFound
+	}
+	if !key.enabled {
+		return localKey{}, ErrKMSKeyDisabled
+	}
+	return key, nil
+}
+
+// refresh re-reads the keyring file when it changed. k.mu must be held.
+func (k *LocalKeyring) refresh() error {
+	info, err := os.Stat(k.path)
+	if err != nil {
+		return fmt.Errorf("kms: stat keyring: %w", err)
+	}
+	if k.keys != nil && info.ModTime().Equal(k.modTime) && info.Size() == k.size {
+		return nil
+	}
+	keys, err := loadKeyring(k.path)
+	if err != nil {
+		return err
+	}
+	k.keys, k.modTime, k.size = keys, info.ModTime(), info.Size()
+	return nil
+}
+
+func loadKeyring(path string) (map[string]localKey, error) {
+	data, err := os.ReadFile(path) // #nosec G304 -- operator-configured keyring path
+	if err != nil {
+		return nil, fmt.Errorf("kms: read keyring: %w", err)
+	}
+	var file keyringFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("kms: parse keyring: %w", err)
+	}
+	keys := make(map[string]localKey, len(file.Keys))
+	for _, e := range file.Keys {
+		material, err := base64.StdEncoding.DecodeString(e.Material)
+		if err != nil || len(material) != 32 {
+			return nil, fmt.Errorf("kms: keyring key %q must be 32 base64-encoded bytes", e.ID)
+		}
+		keys[e.ID] = localKey{material: material, enabled: e.Enabled == nil || *e.Enabled}
+	}
+	return keys, nil
+}
+
+func localWrapAAD(keyID string, encCtx EncryptionContext) []byte {
+	aad := append([]byte("vaultaire-kms-local\x00"), keyID...)
+	aad = append(aad, 0)
+	return append(aad, encCtx.Canonical()...)
+}
+
+func localGCM(material []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(material)
+	if err != nil {
+		return nil, fmt.Errorf("kms: create cipher: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// GenerateDataKey implements KeyProvider.
+func (k *LocalKeyring) GenerateDataKey(_ context.Context, keyID string, encCtx EncryptionContext) ([]byte, []byte, error) {
+	key, err := k.key(keyID)
+	if err != nil {
+		return nil, nil, err
+	}
+	gcm, err := localGCM(key.material)
+	if err != nil {
+		return nil, nil, err
+	}
+	dataKey, err := newDataKey()
+	if err != nil {
+		return nil, nil, err
+	}
+	wrapped := make([]byte, 1+gcm.NonceSize(), 1+gcm.NonceSize()+DataKeySize+gcm.Overhead())
+	wrapped[0] = localWrapVersion
+	if _, err := io.ReadFull(rand.Reader, wrapped[1:]); err != nil {
+		return nil, nil, fmt.Errorf("kms: generate nonce: %w", err)
+	}
+	wrapped = gcm.Seal(wrapped, wrapped[1:], dataKey, localWrapAAD(keyID, encCtx))
+	return dataKey, wrapped, nil
+}
+
+// DecryptDataKey implements KeyProvider.
+func (k *LocalKeyring) DecryptDataKey(_ context.Context, keyID string, wrapped []byte, encCtx EncryptionContext) ([]byte, error) {
+	key, err := k.key(keyID)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := localGCM(key.material)
+	if err != nil {
+		return nil, err
+	}
+	if len(wrapped) < 1+gcm.NonceSize()+gcm.Overhead() || wrapped[0] != localWrapVersion {
+		return nil, ErrKMSInvalidCiphertext
+	}
+	nonce := wrapped[1 : 1+gcm.NonceSize()]
+	dataKey, err := gcm.Open(nil, nonce, wrapped[1+gcm.NonceSize():], localWrapAAD(keyID, encCtx))
+	if err != nil {
+		return nil, ErrKMSInvalidCiphertext
+	}
+	return dataKey, nil
+}
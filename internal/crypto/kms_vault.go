@@ -0,0 +1,152 @@
+package crypto
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// VaultTransitConfig configures a VaultTransit provider.
+type VaultTransitConfig struct {
+	Address   string // e.g. https://vault.internal:8200
+	Token     string
+	Namespace string // Vault Enterprise namespace, optional
+	Mount     string // transit mount path, default "transit"
+	// Derived sends the encryption context to Vault, for transit keys
+	// created with derived=true. Non-derived keys reject a context.
+	Derived bool
+	Client  *http.Client
+}
+
+// VaultTransit is a KeyProvider backed by HashiCorp Vault's Transit secrets
+// engine. Data keys come from datakey/plaintext and are unwrapped with
+// decrypt; the key material never leaves Vault, and revoking the token's
+// policy or deleting the key makes every object under it unreadable.
+type VaultTransit struct {
+	cfg    VaultTransitConfig
+	client *http.Client
+}
+
+// NewVaultTransit validates cfg and returns the provider.
+func NewVaultTransit(cfg VaultTransitConfig) (*VaultTransit, error) {
+	if cfg.Address == "" || cfg.Token == "" {
+		return nil, fmt.Errorf("kms: vault address and token are required")
+	}
+	if _, err := url.Parse(cfg.Address); err != nil {
+		return nil, fmt.Errorf("kms: invalid vault address: %w", err)
+	}
+	cfg.Address = strings.TrimRight(cfg.Address, "/")
+	if cfg.Mount == "" {
+		cfg.Mount = "transit"
+	}
+	cfg.Mount = strings.Trim(cfg.Mount, "/")
+	client := cfg.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &VaultTransit{cfg: cfg, client: client}, nil
+}
+
+// Name implements KeyProvider.
+func (v *VaultTransit) Name() string { return "vault" }
+
+type vaultResponse struct {
+	Data struct {
+		Plaintext  string `json:"plaintext"`
+		Ciphertext string `json:"ciphertext"`
+	} `json:"data"`
+	Errors []string `json:"errors"`
+}
+
+func (v *VaultTransit) call(ctx context.Context, op, keyID string, body map[string]any, encCtx EncryptionContext) (*vaultResponse, error) {
+	if keyID == "" {
+		return nil, ErrKMSKeyNotFound
+	}
+	if v.cfg.Derived {
+		body["context"] = base64.StdEncoding.EncodeToString(encCtx.Canonical())
+	}
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	endpoint := fmt.Sprintf("%s/v1/%s/%s/%s", v.cfg.Address, v.cfg.Mount, op, url.PathEscape(keyID))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Vault-Token", v.cfg.Token)
+	req.Header.Set("Content-Type", "application/json")
+	if v.cfg.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", v.cfg.Namespace)
+	}
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("kms: vault %s: %w", op, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	raw, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("kms: vault %s: read response: %w", op, err)
+	}
+	var out vaultResponse
+	_ = json.Unmarshal(raw, &out)
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, vaultError(op, resp.StatusCode, out.Errors)
+	}
+	return &out, nil
+}
+
+// vaultError maps a Transit failure onto the KeyProvider errors. Vault
+// reports a missing key as 400 "encryption key not found" and a revoked
+// policy as 403.
+func vaultError(op string, status int, errs []string) error {
+	msg := strings.Join(errs, "; ")
+	lower := strings.ToLower(msg)
+	switch {
+	case status == http.StatusForbidden:
+		return fmt.Errorf("%w: %s", ErrKMSAccessDenied, msg)
+	case status == http.StatusNotFound || strings.Contains(lower, "not found"):
+		return fmt.Errorf("%w: %s", ErrKMSKeyNotFound, msg)
+	case strings.Contains(lower, "deletion") || strings.Contains(lower, "disabled"):
+		return fmt.Errorf("%w: %s", ErrKMSKeyDisabled, msg)
+	case status == http.StatusBadRequest && op == "decrypt":
+		return fmt.Errorf("%w: %s", ErrKMSInvalidCiphertext, msg)
+	default:
+		return fmt.Errorf("kms: vault %s failed (HTTP %d): %s", op, status, msg)
+	}
+}
+
+// GenerateDataKey implements KeyProvider.
+func (v *VaultTransit) GenerateDataKey(ctx context.Context, keyID string, encCtx EncryptionContext) ([]byte, []byte, error) {
+	out, err := v.call(ctx, "datakey/plaintext", keyID, map[string]any{"bits": 256}, encCtx)
+	if err != nil {
+		return nil, nil, err
+	}
+	dataKey, err := base64.StdEncoding.DecodeString(out.Data.Plaintext)
+	if err != nil || len(dataKey) != DataKeySize || out.Data.Ciphertext == "" {
+		return nil, nil, fmt.Errorf("kms: vault returned a malformed data key")
+	}
+	return dataKey, []byte(out.Data.Ciphertext), nil
+}
+
+// DecryptDataKey implements KeyProvider.
+func (v *VaultTransit) DecryptDataKey(ctx context.Context, keyID string, wrapped []byte, encCtx EncryptionContext) ([]byte, error) {
+	out, err := v.call(ctx, "decrypt", keyID, map[string]any{"ciphertext": string(wrapped)}, encCtx)
+	if err != nil {
+		return nil, err
+	}
+	dataKey, err := base64.StdEncoding.DecodeString(out.Data.Plaintext)
+	if err != nil || len(dataKey) != DataKeySize {
+		return nil, ErrKMSInvalidCiphertext
+	}
+	return dataKey, nil
+}
@@ -0,0 +1,110 @@
+package crypto
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeTransit mimics the datakey/plaintext and decrypt endpoints of Vault's
+// Transit engine, "wrapping" by prefixing the context.
+type fakeTransit struct {
+	mu       sync.Mutex
+	disabled bool
+	contexts []string
+}
+
+func (f *fakeTransit) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("X-Vault-Token") != "s.token" {
+		w.WriteHeader(http.StatusForbidden)
+		_, _ = w.Write([]byte(`{"errors":["permission denied"]}`))
+		return
+	}
+	var body map[string]any
+	_ = json.NewDecoder(r.Body).Decode(&body)
+	ctxB64, _ := body["context"].(string)
+	f.mu.Lock()
+	f.contexts = append(f.contexts, ctxB64)
+	disabled := f.disabled
+	f.mu.Unlock()
+
+	switch {
+	case !strings.HasSuffix(r.URL.Path, "/orders"):
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"errors":["encryption key not found"]}`))
+	case disabled:
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"errors":["key is scheduled for deletion"]}`))
+	case strings.HasPrefix(r.URL.Path, "/v1/transit/datakey/plaintext/"):
+		plain := base64.StdEncoding.EncodeToString(make([]byte, 32))
+		_ = json.NewEncoder(w).Encode(map[string]any{"data": map[string]string{
+			"plaintext": plain, "ciphertext": "vault:v1:" + ctxB64 + ":" + plain,
+		}})
+	case strings.HasPrefix(r.URL.Path, "/v1/transit/decrypt/"):
+		ct, _ := body["ciphertext"].(string)
+		prefix := "vault:v1:" + ctxB64 + ":"
+		if !strings.HasPrefix(ct, prefix) {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"errors":["cipher: message authentication failed"]}`))
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"data": map[string]string{"plaintext": strings.TrimPrefix(ct, prefix)}})
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestVaultTransit_DerivedContext(t *testing.T) {
+	fake := &fakeTransit{}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	v, err := NewVaultTransit(VaultTransitConfig{Address: srv.URL + "/", Token: "s.token", Derived: true})
+	require.NoError(t, err)
+	ctx := context.Background()
+	encCtx := EncryptionContext{"aws:s3:arn": "arn:aws:s3:::b/k"}
+
+	plain, wrapped, err := v.GenerateDataKey(ctx, "orders", encCtx)
+	require.NoError(t, err)
+	assert.Len(t, plain, DataKeySize)
+	assert.True(t, strings.HasPrefix(string(wrapped), "vault:v1:"))
+
+	got, err := v.DecryptDataKey(ctx, "orders", wrapped, encCtx)
+	require.NoError(t, err)
+	assert.Equal(t, plain, got)
+	assert.Equal(t, base64.StdEncoding.EncodeToString(encCtx.Canonical()), fake.contexts[0])
+
+	_, err = v.DecryptDataKey(ctx, "orders", wrapped, EncryptionContext{"aws:s3:arn": "other"})
+	assert.ErrorIs(t, err, ErrKMSInvalidCiphertext)
+}
+
+func TestVaultTransit_ErrorMapping(t *testing.T) {
+	fake := &fakeTransit{}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+	ctx := context.Background()
+
+	v, err := NewVaultTransit(VaultTransitConfig{Address: srv.URL, Token: "s.token"})
+	require.NoError(t, err)
+	_, _, err = v.GenerateDataKey(ctx, "missing", nil)
+	assert.ErrorIs(t, err, ErrKMSKeyNotFound)
+
+	fake.mu.Lock()
+	fake.disabled = true
+	fake.mu.Unlock()
+	_, _, err = v.GenerateDataKey(ctx, "orders", nil)
+	assert.ErrorIs(t, err, ErrKMSKeyDisabled)
+
+	revoked, err := NewVaultTransit(VaultTransitConfig{Address: srv.URL, Token: "s.revoked"})
+	require.NoError(t, err)
+	_, _, err = revoked.GenerateDataKey(ctx, "orders", nil)
+	assert.ErrorIs(t, err, ErrKMSAccessDenied)
+}
@@ -17,10 +17,9 @@ import (
 )
 
 const (
-	SSEVersion         byte  = 0x01
-	SSEOverheadBytes         = 1 + mlkem.CiphertextSize768 + 12 + 16 // 1117
-	MaxEncryptableSize int64 = 256 << 20                             // 256 MiB
-	SSEAlgorithm             = "ML-KEM-768+AES-256-GCM"
+	SSEVersion       byte = 0x01
+	SSEOverheadBytes      = 1 + mlkem.CiphertextSize768 + 12 + 16 // 1117
+	SSEAlgorithm          = "ML-KEM-768+AES-256-GCM"
 )
 
 type SSEService struct {
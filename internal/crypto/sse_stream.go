@@ -0,0 +1,397 @@
+package crypto
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/mlkem"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+// Segmented SSE stream format (used for new SSE-S3, SSE-C and SSE-KMS objects):
+//
+//	header:   version(1) | mode(1) | segment size(4) | salt(32) | key check(32)
+//	          [| ML-KEM-768 ciphertext(1088), SSE-S3 only]
+//	segments: AES-256-GCM(segment plaintext), each followed by its 16-byte tag
+//
+// SSE-KMS streams use the SSE-C header layout; the wrapped data key is kept
+// in the object's metadata rather than the stream.
+//
+// Every segment holds segment-size plaintext bytes except the last, which
+// may be shorter (an empty object is one empty segment). The nonce carries
+// the segment index and a final-segment flag, so segments cannot be
+// reordered, dropped or the stream truncated without failing
+// authentication. The object key is derived per object from the salt, which
+// keeps the deterministic nonces unique, and the key check lets a wrong
+// SSE-C key be rejected before any byte is served.
+const (
+	SSEStreamVersion  byte = 0x02
+	StreamSegmentSize      = 64 << 10
+
+	streamModeSSEC   byte = 0x01
+	streamModeSSES3  byte = 0x02
+	streamModeSSEKMS byte = 0x03
+
+	streamSaltSize  = 32
+	streamCheckSize = 32
+	streamTagSize   = 16
+	streamAADSize   = 6 // version, mode and segment size
+
+	// SSECStreamHeaderSize and SSEStreamHeaderSize are the header lengths
+	// of SSE-C (and SSE-KMS) and SSE-S3 streams.
+	SSECStreamHeaderSize = streamAADSize + streamSaltSize + streamCheckSize
+	SSEStreamHeaderSize  = SSECStreamHeaderSize + mlkem.CiphertextSize768
+)
+
+var (
+	ErrStreamHeader = errors.New("sse stream: invalid header")
+	ErrStreamAuth   = errors.New("sse stream: segment authentication failed")
+)
+
+// StreamCipher seals or opens the segments of one object's SSE stream.
+type StreamCipher struct {
+	aead    cipher.AEAD
+	segSize int64
+	header  []byte
+}
+
+// NewSSECStream starts a segmented SSE-C stream under a customer key.
+func NewSSECStream(key []byte) (*StreamCipher, error) {
+	if len(key) != 32 {
+		return nil, fmt.Errorf("ssec: key must be 32 bytes, got %d", len(key))
+	}
+	header, err := newStreamHeader(streamModeSSEC, nil)
+	if err != nil {
+		return nil, err
+	}
+	return newStreamCipher(key, header, "vaultaire-sse-c-stream", false)
+}
+
+// OpenSSECStream opens a stored SSE-C stream. It returns ErrSSECKeyMismatch
+// when key is not the one the object was written with.
+func OpenSSECStream(key, header []byte) (*StreamCipher, error) {
+	if len(key) != 32 {
+		return nil, fmt.Errorf("ssec: key must be 32 bytes, got %d", len(key))
+	}
+	if err := checkStreamHeader(header, streamModeSSEC); err != nil {
+		return nil, err
+	}
+	c, err := newStreamCipher(key, header, "vaultaire-sse-c-stream", true)
+	if errors.Is(err, ErrStreamAuth) {
+		return nil, ErrSSECKeyMismatch
+	}
+	return c, err
+}
+
+// NewStream starts a segmented SSE-S3 stream under a fresh ML-KEM
+// encapsulation to the tenant's key.
+func (s *SSEService) NewStream(ctx context.Context, tenantID string) (*StreamCipher, error) {
+	ek, err := s.loadEncapsulationKey(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	sharedKey, kemCiphertext := ek.Encapsulate()
+	header, err := newStreamHeader(streamModeSSES3, kemCiphertext)
+	if err != nil {
+		return nil, err
+	}
+	return newStreamCipher(sharedKey, header, "vaultaire-sse-s3-stream", false)
+}
+
+// OpenStream opens a stored SSE-S3 stream of the tenant.
+func (s *SSEService) OpenStream(ctx context.Context, tenantID string, header []byte) (*StreamCipher, error) {
+	if err := checkStreamHeader(header, streamModeSSES3); err != nil {
+		return nil, err
+	}
+	dk, err := s.loadDecapsulationKey(ctx, tenantID)
+	if err != nil {
+		return nil, err
+	}
+	sharedKey, err := dk.Decapsulate(header[SSECStreamHeaderSize:])
+	if err != nil {
+		return nil, fmt.Errorf("ML-KEM decapsulation failed: %w", err)
+	}
+	return newStreamCipher(sharedKey, header, "vaultaire-sse-s3-stream", true)
+}
+
+// NewKMSStream starts a segmented SSE-KMS stream under a data key from a
+// KeyProvider. The encryption context is bound into the object key, so a
+// stream only opens with the context it was written under.
+func NewKMSStream(dataKey []byte, encCtx EncryptionContext) (*StreamCipher, error) {
+	if len(dataKey) != DataKeySize {
+		return nil, fmt.Errorf("kms: data key must be %d bytes, got %d", DataKeySize, len(dataKey))
+	}
+	header, err := newStreamHeader(streamModeSSEKMS, nil)
+	if err != nil {
+		return nil, err
+	}
+	return newStreamCipher(dataKey, header, kmsStreamInfo(encCtx), false)
+}
+
+// OpenKMSStream opens a stored SSE-KMS stream with its unwrapped data key.
+func OpenKMSStream(dataKey, header []byte, encCtx EncryptionContext) (*StreamCipher, error) {
+	if len(dataKey) != DataKeySize {
+		return nil, fmt.Errorf("kms: data key must be %d bytes, got %d", DataKeySize, len(dataKey))
+	}
+	if err := checkStreamHeader(header, streamModeSSEKMS); err != nil {
+		return nil, err
+	}
+	return newStreamCipher(dataKey, header, kmsStreamInfo(encCtx), true)
+}
+
+func kmsStreamInfo(encCtx EncryptionContext) string {
+	return "vaultaire-sse-kms-stream\x00" + string(encCtx.Canonical())
+}
+
+func newStreamHeader(mode byte, kemCiphertext []byte) ([]byte, error) {
+	header := make([]byte, SSECStreamHeaderSize, SSECStreamHeaderSize+len(kemCiphertext))
+	header[0] = SSEStreamVersion
+	header[1] = mode
+	binary.BigEndian.PutUint32(header[2:streamAADSize], StreamSegmentSize)
+	if _, err := io.ReadFull(rand.Reader, header[streamAADSize:streamAADSize+streamSaltSize]); err != nil {
+		return nil, fmt.Errorf("generate stream salt: %w", err)
+	}
+	return append(header, kemCiphertext...), nil
+}
+
+func checkStreamHeader(header []byte, mode byte) error {
+	want := SSECStreamHeaderSize
+	if mode == streamModeSSES3 {
+		want = SSEStreamHeaderSize
+	}
+	if len(header) != want || header[0] != SSEStreamVersion || header[1] != mode {
+		return ErrStreamHeader
+	}
+	if seg := binary.BigEndian.Uint32(header[2:streamAADSize]); seg == 0 || seg > 16<<20 {
+		return ErrStreamHeader
+	}
+	return nil
+}
+
+// newStreamCipher derives the object key and key check from secret and the
+// header salt. When verify is set the stored key check must match;
+// otherwise it is written into the header.
+func newStreamCipher(secret, header []byte, info string, verify bool) (*StreamCipher, error) {
+	salt := header[streamAADSize : streamAADSize+streamSaltSize]
+	kdf := hkdf.New(sha256.New, secret, salt, []byte(info))
+	material := make([]byte, 32+streamCheckSize)
+	if _, err := io.ReadFull(kdf, material); err != nil {
+		return nil, fmt.Errorf("HKDF key derivation: %w", err)
+	}
+	defer func() {
+		for i := range material {
+			material[i] = 0
+		}
+	}()
+
+	check := header[streamAADSize+streamSaltSize : SSECStreamHeaderSize]
+	if verify {
+		if !hmac.Equal(check, material[32:]) {
+			return nil, ErrStreamAuth
+		}
+	} else {
+		copy(check, material[32:])
+	}
+
+	block, err := aes.NewCipher(material[:32])
+	if err != nil {
+		return nil, fmt.Errorf("create cipher: %w", err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("create GCM: %w", err)
+	}
+	return &StreamCipher{
+		aead:    gcm,
+		segSize: int64(binary.BigEndian.Uint32(header[2:streamAADSize])),
+		header:  header,
+	}, nil
+}
+
+// Header returns the stream header, which precedes the segments in storage.
+func (c *StreamCipher) Header() []byte { return c.header }
+
+func (c *StreamCipher) segments(plainSize int64) int64 {
+	if plainSize <= 0 {
+		return 1
+	}
+	return (plainSize + c.segSize - 1) / c.segSize
+}
+
+// EncryptedSize is the stored size, header included, of a plaintext of
+// plainSize bytes.
+func (c *StreamCipher) EncryptedSize(plainSize int64) int64 {
+	return int64(len(c.header)) + plainSize + c.segments(plainSize)*streamTagSize
+}
+
+// CiphertextRange returns the stored byte range holding the segments that
+// cover plaintext bytes [offset, offset+length) of a plainSize-byte object.
+// length must be positive and the range within the object.
+func (c *StreamCipher) CiphertextRange(plainSize, offset, length int64) (start, n int64) {
+	first := offset / c.segSize
+	last := (offset + length - 1) / c.segSize
+	start = int64(len(c.header)) + first*(c.segSize+streamTagSize)
+	end := int64(len(c.header)) + last*(c.segSize+streamTagSize) + c.segmentLen(plainSize, last) + streamTagSize
+	return start, end - start
+}
+
+func (c *StreamCipher) segmentLen(plainSize, seq int64) int64 {
+	return min(c.segSize, plainSize-seq*c.segSize)
+}
+
+func (c *StreamCipher) nonce(seq int64, final bool) []byte {
+	nonce := make([]byte, c.aead.NonceSize())
+	binary.BigEndian.PutUint64(nonce[3:11], uint64(seq))
+	if final {
+		nonce[11] = 1
+	}
+	return nonce
+}
+
+// Encrypt returns a reader producing the header followed by the sealed
+// segments of plaintext. The final segment is found by reading one byte
+// ahead, so plaintext is never buffered beyond one segment.
+func (c *StreamCipher) Encrypt(plaintext io.Reader) io.Reader {
+	return &streamEncrypter{
+		c:   c,
+		src: plaintext,
+		buf: make([]byte, c.segSize+1),
+		out: append([]byte(nil), c.header...),
+	}
+}
+
+type streamEncrypter struct {
+	c       *StreamCipher
+	src     io.Reader
+	buf     []byte // one segment plus one byte of lookahead
+	carried bool   // buf[0] holds the lookahead byte of the previous fill
+	seq     int64
+	out     []byte
+	done    bool
+	err     error
+}
+
+func (e *streamEncrypter) Read(p []byte) (int, error) {
+	for len(e.out) == 0 {
+		if e.err != nil {
+			return 0, e.err
+		}
+		if e.done {
+			return 0, io.EOF
+		}
+		e.sealNext()
+	}
+	n := copy(p, e.out)
+	e.out = e.out[n:]
+	return n, nil
+}
+
+func (e *streamEncrypter) sealNext() {
+	have := 0
+	if e.carried {
+		have = 1
+	}
+	n, err := io.ReadFull(e.src, e.buf[have:])
+	have += n
+	switch {
+	case err == nil:
+	case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		e.done = true
+	default:
+		e.err = err
+		return
+	}
+
+	segment := e.buf[:have]
+	if !e.done {
+		segment = e.buf[:e.c.segSize]
+	}
+	aad := e.c.header[:streamAADSize]
+	e.out = e.c.aead.Seal(e.out[:0], e.c.nonce(e.seq, e.done), segment, aad)
+	e.seq++
+	if !e.done {
+		e.buf[0] = e.buf[e.c.segSize]
+		e.carried = true
+	}
+}
+
+// DecryptRange returns plaintext bytes [offset, offset+length) of a
+// plainSize-byte object. src must be positioned at the start returned by
+// CiphertextRange for the same range; only the covering segments are read.
+// Authentication failures surface as ErrStreamAuth from Read.
+func (c *StreamCipher) DecryptRange(src io.Reader, plainSize, offset, length int64) io.Reader {
+	return &streamDecrypter{
+		c:         c,
+		src:       src,
+		seq:       offset / c.segSize,
+		last:      c.segments(plainSize) - 1,
+		plainSize: plainSize,
+		skip:      offset % c.segSize,
+		remaining: length,
+		ct:        make([]byte, c.segSize+streamTagSize),
+	}
+}
+
+type streamDecrypter struct {
+	c         *StreamCipher
+	src       io.Reader
+	seq, last int64
+	plainSize int64
+	skip      int64
+	remaining int64
+	ct        []byte
+	out       []byte
+	err       error
+}
+
+func (d *streamDecrypter) Read(p []byte) (int, error) {
+	for len(d.out) == 0 {
+		if d.err != nil {
+			return 0, d.err
+		}
+		if d.remaining <= 0 {
+			return 0, io.EOF
+		}
+		d.openNext()
+	}
+	n := copy(p, d.out)
+	d.out = d.out[n:]
+	return n, nil
+}
+
+func (d *streamDecrypter) openNext() {
+	if d.seq > d.last {
+		d.err = io.ErrUnexpectedEOF
+		return
+	}
+	ct := d.ct[:d.c.segmentLen(d.plainSize, d.seq)+streamTagSize]
+	if _, err := io.ReadFull(d.src, ct); err != nil {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		d.err = err
+		return
+	}
+	aad := d.c.header[:streamAADSize]
+	plain, err := d.c.aead.Open(ct[:0], d.c.nonce(d.seq, d.seq == d.last), ct, aad)
+	if err != nil {
+		d.err = ErrStreamAuth
+		return
+	}
+	d.seq++
+	plain = plain[d.skip:]
+	d.skip = 0
+	if int64(len(plain)) > d.remaining {
+		plain = plain[:d.remaining]
+	}
+	d.remaining -= int64(len(plain))
+	d.out = plain
+}
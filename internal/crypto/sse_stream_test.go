@@ -0,0 +1,190 @@
+package crypto
+
+import (
+	"bytes"
+	"context"
+	"crypto/mlkem"
+	"crypto/rand"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func streamTestKey(t *testing.T) []byte {
+	t.Helper()
+	key := make([]byte, 32)
+	_, err := rand.Read(key)
+	require.NoError(t, err)
+	return key
+}
+
+func encryptAll(t *testing.T, c *StreamCipher, plaintext []byte) []byte {
+	t.Helper()
+	ct, err := io.ReadAll(c.Encrypt(bytes.NewReader(plaintext)))
+	require.NoError(t, err)
+	require.Equal(t, c.EncryptedSize(int64(len(plaintext))), int64(len(ct)))
+	return ct
+}
+
+func decryptRange(c *StreamCipher, stored []byte, plainSize, offset, length int64) ([]byte, error) {
+	start, n := c.CiphertextRange(plainSize, offset, length)
+	return io.ReadAll(c.DecryptRange(bytes.NewReader(stored[start:start+n]), plainSize, offset, length))
+}
+
+func TestSSECStream_RoundTrip(t *testing.T) {
+	key := streamTestKey(t)
+	sizes := []int{0, 1, StreamSegmentSize - 1, StreamSegmentSize, StreamSegmentSize + 1, 3*StreamSegmentSize + 17}
+	for _, size := range sizes {
+		plaintext := make([]byte, size)
+		_, _ = rand.Read(plaintext)
+
+		enc, err := NewSSECStream(key)
+		require.NoError(t, err)
+		stored := encryptAll(t, enc, plaintext)
+
+		dec, err := OpenSSECStream(key, stored[:SSECStreamHeaderSize])
+		require.NoError(t, err)
+		if size == 0 {
+			continue
+		}
+		got, err := decryptRange(dec, stored, int64(size), 0, int64(size))
+		require.NoError(t, err, "size %d", size)
+		assert.True(t, bytes.Equal(plaintext, got), "size %d", size)
+	}
+}
+
+func TestSSECStream_Ranges(t *testing.T) {
+	key := streamTestKey(t)
+	size := int64(3*StreamSegmentSize + 100)
+	plaintext := make([]byte, size)
+	_, _ = rand.Read(plaintext)
+
+	enc, err := NewSSECStream(key)
+	require.NoError(t, err)
+	stored := encryptAll(t, enc, plaintext)
+	dec, err := OpenSSECStream(key, stored[:SSECStreamHeaderSize])
+	require.NoError(t, err)
+
+	ranges := [][2]int64{
+		{0, 1},
+		{10, 100},
+		{StreamSegmentSize - 5, 10},            // spans a segment boundary
+		{StreamSegmentSize, StreamSegmentSize}, // exactly one segment
+		{size - 50, 50},                        // tail of the final segment
+		{StreamSegmentSize / 2, 2 * StreamSegmentSize}, // three segments
+	}
+	for _, rg := range ranges {
+		start, n := dec.CiphertextRange(size, rg[0], rg[1])
+		assert.LessOrEqual(t, start+n, int64(len(stored)))
+		got, err := decryptRange(dec, stored, size, rg[0], rg[1])
+		require.NoError(t, err, "range %v", rg)
+		assert.Equal(t, plaintext[rg[0]:rg[0]+rg[1]], got, "range %v", rg)
+	}
+}
+
+func TestSSECStream_WrongKey(t *testing.T) {
+	enc, err := NewSSECStream(streamTestKey(t))
+	require.NoError(t, err)
+	stored := encryptAll(t, enc, []byte("secret"))
+
+	_, err = OpenSSECStream(streamTestKey(t), stored[:SSECStreamHeaderSize])
+	assert.ErrorIs(t, err, ErrSSECKeyMismatch)
+}
+
+func TestSSECStream_TamperDetected(t *testing.T) {
+	key := streamTestKey(t)
+	size := int64(2*StreamSegmentSize + 10)
+	plaintext := make([]byte, size)
+
+	enc, err := NewSSECStream(key)
+	require.NoError(t, err)
+	stored := encryptAll(t, enc, plaintext)
+	dec, err := OpenSSECStream(key, stored[:SSECStreamHeaderSize])
+	require.NoError(t, err)
+
+	t.Run("flipped byte", func(t *testing.T) {
+		bad := append([]byte(nil), stored...)
+		bad[SSECStreamHeaderSize+100] ^= 1
+		_, err := decryptRange(dec, bad, size, 0, size)
+		assert.ErrorIs(t, err, ErrStreamAuth)
+	})
+
+	t.Run("truncated to a segment boundary", func(t *testing.T) {
+		// Dropping the final segment and claiming a shorter object must
+		// fail: the new last segment was not sealed as final.
+		short := int64(2 * StreamSegmentSize)
+		_, err := decryptRange(dec, stored, short, 0, short)
+		assert.ErrorIs(t, err, ErrStreamAuth)
+	})
+
+	t.Run("swapped segments", func(t *testing.T) {
+		seg := StreamSegmentSize + streamTagSize
+		bad := append([]byte(nil), stored...)
+		first := bad[SSECStreamHeaderSize : SSECStreamHeaderSize+seg]
+		second := append([]byte(nil), bad[SSECStreamHeaderSize+seg:SSECStreamHeaderSize+2*seg]...)
+		copy(bad[SSECStreamHeaderSize+seg:], first)
+		copy(bad[SSECStreamHeaderSize:], second)
+		_, err := decryptRange(dec, bad, size, 0, size)
+		assert.ErrorIs(t, err, ErrStreamAuth)
+	})
+}
+
+func TestSSEServiceStream_RoundTrip(t *testing.T) {
+	svc, err := NewSSEService(nil, testMasterKeyHex())
+	require.NoError(t, err)
+	dk, err := mlkem.GenerateKey768()
+	require.NoError(t, err)
+	svc.ekCache.Store("tenant-1", dk.EncapsulationKey())
+	svc.dkCache.Store("tenant-1", dk)
+	ctx := context.Background()
+
+	size := int64(StreamSegmentSize + 4000)
+	plaintext := make([]byte, size)
+	_, _ = rand.Read(plaintext)
+
+	enc, err := svc.NewStream(ctx, "tenant-1")
+	require.NoError(t, err)
+	stored := encryptAll(t, enc, plaintext)
+	require.Len(t, enc.Header(), SSEStreamHeaderSize)
+
+	dec, err := svc.OpenStream(ctx, "tenant-1", stored[:SSEStreamHeaderSize])
+	require.NoError(t, err)
+	got, err := decryptRange(dec, stored, size, 0, size)
+	require.NoError(t, err)
+	assert.Equal(t, plaintext, got)
+
+	got, err = decryptRange(dec, stored, size, StreamSegmentSize-2, 4)
+	require.NoError(t, err)
+	assert.Equal(t, plaintext[StreamSegmentSize-2:StreamSegmentSize+2], got)
+
+	// An SSE-C header is not an SSE-S3 stream.
+	_, err = svc.OpenStream(ctx, "tenant-1", stored[:SSECStreamHeaderSize])
+	assert.ErrorIs(t, err, ErrStreamHeader)
+}
+
+func TestKMSStream_BindsEncryptionContext(t *testing.T) {
+	dataKey := streamTestKey(t)
+	encCtx := EncryptionContext{"aws:s3:arn": "arn:aws:s3:::bucket/key"}
+	plaintext := make([]byte, StreamSegmentSize+9)
+	_, _ = rand.Read(plaintext)
+
+	enc, err := NewKMSStream(dataKey, encCtx)
+	require.NoError(t, err)
+	stored := encryptAll(t, enc, plaintext)
+	header := stored[:SSECStreamHeaderSize]
+
+	dec, err := OpenKMSStream(dataKey, header, encCtx)
+	require.NoError(t, err)
+	got, err := decryptRange(dec, stored, int64(len(plaintext)), 0, int64(len(plaintext)))
+	require.NoError(t, err)
+	assert.Equal(t, plaintext, got)
+
+	_, err = OpenKMSStream(dataKey, header, EncryptionContext{"aws:s3:arn": "arn:aws:s3:::bucket/other"})
+	assert.ErrorIs(t, err, ErrStreamAuth)
+
+	// An SSE-C opener refuses a KMS header even with the same key.
+	_, err = OpenSSECStream(dataKey, header)
+	assert.ErrorIs(t, err, ErrStreamHeader)
+}
@@ -19,9 +19,12 @@ var auditEventTypes = []string{
 	"bucket.deleted",
 	"key.created",
 	"key.revoked",
+	"member.deprovisioned",
+	"member.provisioned",
 	"object.created",
 	"object.deleted",
 	"object.downloaded",
+	"sts.role_assumed",
 	"sts.token_created",
 	"webhook.test",
 }
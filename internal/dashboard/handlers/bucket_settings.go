@@ -165,6 +165,20 @@ func HandleUpdateBucketSettings(tmpl *template.Template, db *sql.DB, logger *zap
 				http.Redirect(w, r, settingsURL, http.StatusSeeOther) // #nosec G710 -- hardcoded path prefix
 				return
 			}
+
+			// Public-read is the bucket's AllUsers READ grant, so the S3
+			// API's ownership controls and public access block apply here.
+			var ownership string
+			var blockJSON []byte
+			_ = db.QueryRowContext(r.Context(),
+				`SELECT COALESCE(object_ownership, ''), public_access_block FROM buckets WHERE tenant_id = $1 AND name = $2`,
+				sd.TenantID, bucketName).Scan(&ownership, &blockJSON)
+			block, _ := auth.ParsePublicAccessBlock(blockJSON)
+			if block.BlockPublicAcls || ownership == auth.OwnershipBucketOwnerEnforced {
+				middleware.SetFlash(w, "error", "This bucket's public access block or object ownership setting prevents public-read access.")
+				http.Redirect(w, r, settingsURL, http.StatusSeeOther) // #nosec G710 -- hardcoded path prefix
+				return
+			}
 		}
 
 		result, err := db.ExecContext(r.Context(),
@@ -187,18 +187,25 @@ type oauthUser struct {
 // 1. Existing OAuth link → return user
 // 2. Existing email match → link OAuth + return user
 // 3. No match → create new user + link OAuth
+// Members their organisation deactivated get ErrUserDeactivated.
 // The returned APIKey is non-nil ONLY in case 3 (a brand-new account) so
 // the caller can reveal the minted S3 credentials once (B2).
 func findOrCreateOAuthUser(ctx context.Context, authSvc *auth.AuthService, db *sql.DB, provider string, ou oauthUser) (*auth.User, *auth.APIKey, error) {
 	// Check for existing OAuth link.
 	user, err := authSvc.GetUserByOAuth(ctx, provider, ou.ID)
 	if err == nil && user != nil {
+		if user.Deactivated {
+			return nil, nil, auth.ErrUserDeactivated
+		}
 		return user, nil, nil
 	}
 
 	// Check for existing user with same email.
 	user, err = authSvc.GetUserByEmail(ctx, ou.Email)
 	if err == nil && user != nil {
+		if user.Deactivated {
+			return nil, nil, auth.ErrUserDeactivated
+		}
 		// Link this OAuth account to the existing user.
 		if linkErr := authSvc.LinkOAuthAccount(ctx, user.ID, provider, ou.ID, ou.Email, ou.Name); linkErr != nil {
 			return nil, nil, fmt.Errorf("link oauth: %w", linkErr)
@@ -0,0 +1,211 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/FairForge/vaultaire/internal/auth"
+	dashauth "github.com/FairForge/vaultaire/internal/dashboard/auth"
+	"github.com/FairForge/vaultaire/internal/dashboard/middleware"
+	"go.uber.org/zap"
+)
+
+const (
+	oidcLoginCookie   = "oidc_login"
+	oidcSessionCookie = "oidc_session"
+	oidcLoginTTL      = 10 * time.Minute
+)
+
+// setOIDCCookie stores v as base64 JSON. The IdP redirects back with a
+// top-level GET, so SameSite=Lax is enough for the cookie to come back.
+func setOIDCCookie(w http.ResponseWriter, name string, v interface{}, maxAge int) {
+	value := ""
+	if v != nil {
+		b, _ := json.Marshal(v)
+		value = base64.RawURLEncoding.EncodeToString(b)
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     "/oidc/",
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+		MaxAge:   maxAge,
+	})
+}
+
+func readOIDCCookie(r *http.Request, name string, v interface{}) bool {
+	c, err := r.Cookie(name)
+	if err != nil || c.Value == "" {
+		return false
+	}
+	b, err := base64.RawURLEncoding.DecodeString(c.Value)
+	return err == nil && json.Unmarshal(b, v) == nil
+}
+
+// oidcSession remembers which connection signed the user in, and its ID
+// token as the id_token_hint for RP-initiated logout.
+type oidcSession struct {
+	ConnectionID string `json:"c"`
+	IDToken      string `json:"t"`
+}
+
+// HandleOIDCLogin finds the organisation's connection from the "org"
+// value, an email address or tenant slug, and redirects to its IdP.
+func HandleOIDCLogin(rp *auth.OIDCRelyingParty, db *sql.DB, logger *zap.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		org := strings.TrimSpace(r.FormValue("org"))
+		if org == "" {
+			http.Redirect(w, r, "/login", http.StatusSeeOther)
+			return
+		}
+		conn, err := auth.FindOIDCConnection(r.Context(), db, org)
+		if errors.Is(err, auth.ErrOIDCConnectionNotFound) {
+			http.Redirect(w, r, "/login?sso=not_found", http.StatusSeeOther)
+			return
+		}
+		if err != nil {
+			logger.Error("oidc: find connection", zap.Error(err))
+			http.Redirect(w, r, "/login?sso=failed", http.StatusSeeOther)
+			return
+		}
+
+		var loginHint string
+		if strings.Contains(org, "@") {
+			loginHint = org
+		}
+		authURL, login, err := rp.AuthCodeURL(r.Context(), conn, loginHint)
+		if err != nil {
+			logger.Warn("oidc: start sign-in", zap.String("connection_id", conn.ID), zap.Error(err))
+			http.Redirect(w, r, "/login?sso=failed", http.StatusSeeOther)
+			return
+		}
+		setOIDCCookie(w, oidcLoginCookie, login, int(oidcLoginTTL.Seconds()))
+		http.Redirect(w, r, authURL, http.StatusFound)
+	}
+}
+
+// HandleOIDCCallback completes a sign-in HandleOIDCLogin started in this
+// browser: it redeems the code, verifies the ID token, provisions the
+// member in the connection's tenant and starts a dashboard session.
+func HandleOIDCCallback(
+	rp *auth.OIDCRelyingParty,
+	db *sql.DB,
+	authSvc *auth.AuthService,
+	sessions dashauth.SessionStore,
+	logger *zap.Logger,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var login auth.OIDCLogin
+		ok := readOIDCCookie(r, oidcLoginCookie, &login)
+		setOIDCCookie(w, oidcLoginCookie, nil, -1)
+
+		q := r.URL.Query()
+		if !ok || login.State == "" || q.Get("state") != login.State {
+			http.Redirect(w, r, "/login?sso=failed", http.StatusSeeOther)
+			return
+		}
+		if e := q.Get("error"); e != "" {
+			logger.Info("oidc: idp returned error", zap.String("error", e), zap.String("description", q.Get("error_description")))
+			http.Redirect(w, r, "/login?sso=failed", http.StatusSeeOther)
+			return
+		}
+
+		conn, err := auth.GetOIDCConnection(r.Context(), db, login.ConnectionID)
+		if err != nil {
+			logger.Info("oidc: connection gone", zap.String("connection_id", login.ConnectionID), zap.Error(err))
+			http.Redirect(w, r, "/login?sso=failed", http.StatusSeeOther)
+			return
+		}
+		idToken, claims, err := rp.Exchange(r.Context(), conn, &login, q.Get("code"))
+		if err != nil {
+			logger.Info("oidc: rejected sign-in", zap.String("connection_id", conn.ID), zap.Error(err))
+			http.Redirect(w, r, "/login?sso=failed", http.StatusSeeOther)
+			return
+		}
+		id, err := conn.Identity(claims)
+		if err != nil {
+			logger.Info("oidc: unmapped user", zap.String("connection_id", conn.ID), zap.Error(err))
+			http.Redirect(w, r, "/login?sso=denied", http.StatusSeeOther)
+			return
+		}
+		user, err := conn.Provision(r.Context(), authSvc, id)
+		if err != nil {
+			if errors.Is(err, auth.ErrAccountConflict) {
+				logger.Info("oidc: account conflict", zap.String("connection_id", conn.ID), zap.String("email", id.Email))
+				http.Redirect(w, r, "/login?sso=conflict", http.StatusSeeOther)
+				return
+			}
+			if errors.Is(err, auth.ErrUserDeactivated) {
+				logger.Info("oidc: deactivated member", zap.String("connection_id", conn.ID), zap.String("email", id.Email))
+				http.Redirect(w, r, "/login?sso=denied", http.StatusSeeOther)
+				return
+			}
+			logger.Error("oidc: provision user", zap.String("connection_id", conn.ID), zap.Error(err))
+			http.Redirect(w, r, "/login?sso=failed", http.StatusSeeOther)
+			return
+		}
+
+		sessionToken, err := sessions.Create(r.Context(), dashauth.SessionData{
+			UserID:    user.ID,
+			TenantID:  user.TenantID,
+			Email:     user.Email,
+			Role:      auth.DashboardRole(id.Roles),
+			IPAddress: middleware.ClientIP(r),
+			UserAgent: dashauth.TruncateUserAgent(r.UserAgent()),
+		}, sessionTTL)
+		if err != nil {
+			logger.Error("oidc: create session", zap.Error(err))
+			http.Redirect(w, r, "/login?sso=failed", http.StatusSeeOther)
+			return
+		}
+		dashauth.SetSessionCookie(w, sessionToken, sessionTTL)
+		setOIDCCookie(w, oidcSessionCookie, oidcSession{ConnectionID: conn.ID, IDToken: idToken}, int(sessionTTL.Seconds()))
+		http.Redirect(w, r, "/dashboard", http.StatusSeeOther)
+	}
+}
+
+// HandleOIDCLogout signs the user out of the dashboard and, when their
+// IdP supports RP-initiated logout, out of the IdP too. The IdP returns
+// them to postLogoutURL, which must be registered with it.
+func HandleOIDCLogout(rp *auth.OIDCRelyingParty, db *sql.DB, sessions dashauth.SessionStore, postLogoutURL string, logger *zap.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var sd *dashauth.SessionData
+		if c, err := r.Cookie(dashauth.SessionCookieName); err == nil {
+			sd, _ = sessions.Get(r.Context(), c.Value)
+			_ = sessions.Delete(r.Context(), c.Value)
+		}
+		dashauth.ClearSessionCookie(w)
+
+		var last oidcSession
+		ok := readOIDCCookie(r, oidcSessionCookie, &last)
+		setOIDCCookie(w, oidcSessionCookie, nil, -1)
+		if sd == nil || !ok {
+			http.Redirect(w, r, "/login", http.StatusSeeOther)
+			return
+		}
+
+		// Only the connection of the tenant the session belongs to may
+		// receive the ID token.
+		conn, err := auth.GetOIDCConnection(r.Context(), db, last.ConnectionID)
+		if err != nil || conn.TenantID != sd.TenantID {
+			http.Redirect(w, r, "/login", http.StatusSeeOther)
+			return
+		}
+		logoutURL, err := rp.LogoutURL(r.Context(), conn, last.IDToken, postLogoutURL)
+		if err != nil {
+			logger.Warn("oidc: build logout url", zap.String("connection_id", conn.ID), zap.Error(err))
+		}
+		if logoutURL == "" {
+			http.Redirect(w, r, "/login", http.StatusSeeOther)
+			return
+		}
+		http.Redirect(w, r, logoutURL, http.StatusFound)
+	}
+}
@@ -0,0 +1,195 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/FairForge/vaultaire/internal/auth"
+	dashauth "github.com/FairForge/vaultaire/internal/dashboard/auth"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// oidcFixture is a tenant with an OIDC connection to an in-process IdP
+// that signs ID tokens with the nonce of the last authorization request.
+type oidcFixture struct {
+	srv      *httptest.Server
+	rp       *auth.OIDCRelyingParty
+	db       *sql.DB
+	mock     sqlmock.Sqlmock
+	authSvc  *auth.AuthService
+	sessions *dashauth.MemoryStore
+	tenantID string
+	nonce    string
+	email    string
+}
+
+func newOIDCFixture(t *testing.T) *oidcFixture {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	f := &oidcFixture{email: "jsmith@acme.com", sessions: dashauth.NewMemoryStore()}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 f.srv.URL,
+			"jwks_uri":               f.srv.URL + "/jwks",
+			"authorization_endpoint": f.srv.URL + "/authorize",
+			"token_endpoint":         f.srv.URL + "/token",
+			"end_session_endpoint":   f.srv.URL + "/logout",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		b64 := func(b []byte) string { return base64.RawURLEncoding.EncodeToString(b) }
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{{
+			"kty": "RSA", "kid": "k1", "alg": "RS256",
+			"n": b64(key.N.Bytes()), "e": b64(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		tok := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+			"iss": f.srv.URL, "aud": "vaultaire", "sub": "00u1", "nonce": f.nonce, "email": f.email,
+			"groups": []string{"Storage"}, "iat": time.Now().Unix(), "exp": time.Now().Add(5 * time.Minute).Unix(),
+		})
+		tok.Header["kid"] = "k1"
+		raw, err := tok.SignedString(key)
+		require.NoError(t, err)
+		_ = json.NewEncoder(w).Encode(map[string]string{"id_token": raw})
+	})
+	f.srv = httptest.NewTLSServer(mux)
+	t.Cleanup(f.srv.Close)
+
+	f.rp = auth.NewOIDCRelyingParty(auth.NewOIDCVerifier(f.srv.Client()), f.srv.Client(), "https://dash.example.com/oidc/callback")
+	f.db, f.mock, err = sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = f.db.Close() })
+
+	f.authSvc = createTestAuthSvc(t)
+	_, tenant, _, err := f.authSvc.CreateUserWithTenant(context.Background(), "owner@acme.com", "owner-pw-123", "Acme")
+	require.NoError(t, err)
+	f.tenantID = tenant.ID
+	return f
+}
+
+func (f *oidcFixture) connectionRows() *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"id", "tenant_id", "name", "vendor", "issuer_url", "client_id", "client_secret",
+		"scopes", "email_domains", "claims", "group_roles", "default_role", "link_existing_accounts", "enabled", "created_at"}).
+		AddRow([]driver.Value{"conn-1", f.tenantID, "Acme", "okta", f.srv.URL, "vaultaire", "s3cret",
+			"{openid,email}", "{acme.com}", []byte(`{}`), []byte(`{"Storage":"user"}`), "", false, true, time.Now()}...)
+}
+
+// login starts a sign-in and returns the state sent to the IdP and the
+// login cookie.
+func (f *oidcFixture) login(t *testing.T) (string, *http.Cookie) {
+	t.Helper()
+	f.mock.ExpectQuery(`oidc_email_domains`).WithArgs("acme.com").WillReturnRows(f.connectionRows())
+	w := httptest.NewRecorder()
+	HandleOIDCLogin(f.rp, f.db, zap.NewNop())(w, httptest.NewRequest("GET", "/oidc/login?org="+url.QueryEscape(f.email), nil))
+	require.Equal(t, http.StatusFound, w.Code)
+
+	u, err := url.Parse(w.Header().Get("Location"))
+	require.NoError(t, err)
+	assert.Equal(t, "/authorize", u.Path)
+	assert.Equal(t, f.email, u.Query().Get("login_hint"))
+	f.nonce = u.Query().Get("nonce")
+	return u.Query().Get("state"), findCookie(w, oidcLoginCookie)
+}
+
+func (f *oidcFixture) callback(state string, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", "/oidc/callback?code=c1&state="+url.QueryEscape(state), nil)
+	for _, c := range cookies {
+		req.AddCookie(c)
+	}
+	w := httptest.NewRecorder()
+	HandleOIDCCallback(f.rp, f.db, f.authSvc, f.sessions, zap.NewNop())(w, req)
+	return w
+}
+
+func findCookie(w *httptest.ResponseRecorder, name string) *http.Cookie {
+	for _, c := range w.Result().Cookies() {
+		if c.Name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+func TestOIDCSignInAndLogout(t *testing.T) {
+	f := newOIDCFixture(t)
+	state, loginCookie := f.login(t)
+	require.NotNil(t, loginCookie)
+
+	f.mock.ExpectQuery(`FROM oidc_connections WHERE id = \$1`).WithArgs("conn-1").WillReturnRows(f.connectionRows())
+	w := f.callback(state, loginCookie)
+	require.Equal(t, http.StatusSeeOther, w.Code)
+	assert.Equal(t, "/dashboard", w.Header().Get("Location"))
+
+	session := findCookie(w, dashauth.SessionCookieName)
+	require.NotNil(t, session)
+	sd, err := f.sessions.Get(context.Background(), session.Value)
+	require.NoError(t, err)
+	assert.Equal(t, f.tenantID, sd.TenantID, "members sign in to the connection's tenant")
+	assert.Equal(t, f.email, sd.Email)
+	assert.Equal(t, "user", sd.Role)
+
+	// Logout ends the session here and at the IdP.
+	f.mock.ExpectQuery(`FROM oidc_connections WHERE id = \$1`).WithArgs("conn-1").WillReturnRows(f.connectionRows())
+	req := httptest.NewRequest("GET", "/oidc/logout", nil)
+	req.AddCookie(session)
+	req.AddCookie(findCookie(w, oidcSessionCookie))
+	w = httptest.NewRecorder()
+	HandleOIDCLogout(f.rp, f.db, f.sessions, "https://dash.example.com/login", zap.NewNop())(w, req)
+	require.Equal(t, http.StatusFound, w.Code)
+	u, err := url.Parse(w.Header().Get("Location"))
+	require.NoError(t, err)
+	assert.Equal(t, "/logout", u.Path)
+	assert.NotEmpty(t, u.Query().Get("id_token_hint"))
+	assert.Equal(t, "https://dash.example.com/login", u.Query().Get("post_logout_redirect_uri"))
+	sd, err = f.sessions.Get(context.Background(), session.Value)
+	require.NoError(t, err)
+	assert.Nil(t, sd, "session must be gone")
+	require.NoError(t, f.mock.ExpectationsWereMet())
+}
+
+func TestOIDCCallback_Rejected(t *testing.T) {
+	f := newOIDCFixture(t)
+	state, loginCookie := f.login(t)
+
+	w := f.callback(state)
+	assert.Equal(t, "/login?sso=failed", w.Header().Get("Location"), "no login cookie")
+	w = f.callback("forged", loginCookie)
+	assert.Equal(t, "/login?sso=failed", w.Header().Get("Location"), "state mismatch")
+
+	// An email outside the connection's domains gets no account.
+	f.email = "jsmith@other.com"
+	f.mock.ExpectQuery(`FROM oidc_connections WHERE id = \$1`).WithArgs("conn-1").WillReturnRows(f.connectionRows())
+	w = f.callback(state, loginCookie)
+	assert.Equal(t, "/login?sso=denied", w.Header().Get("Location"))
+	assert.Nil(t, findCookie(w, dashauth.SessionCookieName))
+	require.NoError(t, f.mock.ExpectationsWereMet())
+}
+
+func TestOIDCLogin_UnknownOrganisation(t *testing.T) {
+	f := newOIDCFixture(t)
+	f.mock.ExpectQuery(`FROM tenants WHERE slug = \$1`).WithArgs("globex").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	w := httptest.NewRecorder()
+	HandleOIDCLogin(f.rp, f.db, zap.NewNop())(w, httptest.NewRequest("GET", "/oidc/login?org=Globex", nil))
+	assert.Equal(t, http.StatusSeeOther, w.Code)
+	assert.Equal(t, "/login?sso=not_found", w.Header().Get("Location"))
+}
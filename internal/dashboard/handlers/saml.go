@@ -0,0 +1,184 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/FairForge/vaultaire/internal/auth"
+	dashauth "github.com/FairForge/vaultaire/internal/dashboard/auth"
+	"github.com/FairForge/vaultaire/internal/dashboard/middleware"
+	"go.uber.org/zap"
+)
+
+const (
+	samlRequestCookie = "saml_request"
+	samlRequestTTL    = 10 * time.Minute
+)
+
+// setSAMLRequestCookie binds an AuthnRequest ID to the browser. The IdP
+// posts its response cross-site, so the cookie must be SameSite=None to
+// come back with it.
+func setSAMLRequestCookie(w http.ResponseWriter, requestID string, maxAge int) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     samlRequestCookie,
+		Value:    requestID,
+		Path:     "/saml/",
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteNoneMode,
+		MaxAge:   maxAge,
+	})
+}
+
+// HandleSAMLLogin redirects the user to the IdP with a new AuthnRequest.
+func HandleSAMLLogin(provider *auth.SAMLProvider, logger *zap.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		redirectURL, requestID, err := provider.AuthnRequestURL(r.Context(), "")
+		if err != nil {
+			logger.Error("saml: build authn request", zap.Error(err))
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		setSAMLRequestCookie(w, requestID, int(samlRequestTTL.Seconds()))
+		http.Redirect(w, r, redirectURL, http.StatusFound)
+	}
+}
+
+// HandleSAMLMetadata serves the SP metadata to register with the IdP.
+func HandleSAMLMetadata(provider *auth.SAMLProvider) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/samlmetadata+xml")
+		_, _ = w.Write([]byte(provider.GenerateMetadata()))
+	}
+}
+
+// HandleSAMLACS is the assertion consumer service. It validates the IdP's
+// response against the AuthnRequest bound to this browser, provisions the
+// user as a member of the configured tenant with roles from their groups,
+// and starts a dashboard session.
+func HandleSAMLACS(
+	provider *auth.SAMLProvider,
+	authSvc *auth.AuthService,
+	sessions dashauth.SessionStore,
+	logger *zap.Logger,
+) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var requestID string
+		if c, err := r.Cookie(samlRequestCookie); err == nil {
+			requestID = c.Value
+		}
+		setSAMLRequestCookie(w, "", -1)
+
+		res, err := provider.ValidateResponse(r.Context(), r.PostFormValue("SAMLResponse"), requestID)
+		if err != nil {
+			logger.Info("saml: rejected response", zap.Error(err))
+			http.Redirect(w, r, "/login", http.StatusSeeOther)
+			return
+		}
+		id, err := provider.Identity(res)
+		if err != nil {
+			logger.Info("saml: no role for user", zap.String("subject", res.NameID), zap.Error(err))
+			http.Redirect(w, r, "/login", http.StatusSeeOther)
+			return
+		}
+
+		user, err := provider.Provision(r.Context(), authSvc, id)
+		if err != nil {
+			logger.Error("saml: provision user", zap.String("subject", id.Subject), zap.Error(err))
+			http.Redirect(w, r, "/login", http.StatusSeeOther)
+			return
+		}
+
+		sessionToken, err := sessions.Create(r.Context(), dashauth.SessionData{
+			UserID:    user.ID,
+			TenantID:  user.TenantID,
+			Email:     user.Email,
+			Role:      auth.DashboardRole(id.Roles),
+			IPAddress: middleware.ClientIP(r),
+			UserAgent: dashauth.TruncateUserAgent(r.UserAgent()),
+		}, sessionTTL)
+		if err != nil {
+			logger.Error("saml: create session", zap.Error(err))
+			http.Redirect(w, r, "/login", http.StatusSeeOther)
+			return
+		}
+		dashauth.SetSessionCookie(w, sessionToken, sessionTTL)
+		http.Redirect(w, r, "/dashboard", http.StatusSeeOther)
+	}
+}
+
+// HandleSAMLLogout signs the user out of the dashboard and, when the IdP
+// supports single logout, out of the IdP too.
+func HandleSAMLLogout(provider *auth.SAMLProvider, authSvc *auth.AuthService, sessions dashauth.SessionStore, logger *zap.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		c, err := r.Cookie(dashauth.SessionCookieName)
+		if err != nil {
+			http.Redirect(w, r, "/login", http.StatusSeeOther)
+			return
+		}
+		sd, _ := sessions.Get(r.Context(), c.Value)
+		_ = sessions.Delete(r.Context(), c.Value)
+		dashauth.ClearSessionCookie(w)
+		if sd == nil || provider.Config().IDPSLOURL == "" {
+			http.Redirect(w, r, "/login", http.StatusSeeOther)
+			return
+		}
+
+		// The NameID comes from the user's linked account, never from the
+		// request, so a user can only end their own IdP session.
+		nameID, err := authSvc.GetOAuthProviderID(r.Context(), sd.UserID, provider.Provider())
+		if err != nil || nameID == "" {
+			if err != nil {
+				logger.Error("saml: look up name id", zap.Error(err))
+			}
+			http.Redirect(w, r, "/login", http.StatusSeeOther)
+			return
+		}
+		logoutURL, err := provider.LogoutRequestURL(nameID, provider.NameIDFormat(), "")
+		if err != nil {
+			logger.Error("saml: build logout request", zap.Error(err))
+			http.Redirect(w, r, "/login", http.StatusSeeOther)
+			return
+		}
+		http.Redirect(w, r, logoutURL, http.StatusFound)
+	}
+}
+
+// HandleSAMLSLO is the single logout service. A signed LogoutRequest from
+// the IdP ends all of the user's dashboard sessions; a LogoutResponse
+// finishes a logout HandleSAMLLogout started.
+func HandleSAMLSLO(provider *auth.SAMLProvider, authSvc *auth.AuthService, sessions dashauth.SessionStore, logger *zap.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("SAMLRequest") == "" {
+			// The local session was already ended before the redirect
+			// to the IdP.
+			http.Redirect(w, r, "/login", http.StatusSeeOther)
+			return
+		}
+
+		req, err := provider.ParseLogoutRequest(r.URL.RawQuery)
+		if err != nil {
+			logger.Info("saml: rejected logout request", zap.Error(err))
+			http.Error(w, "Bad Request", http.StatusBadRequest)
+			return
+		}
+		user, err := authSvc.GetUserByOAuth(r.Context(), provider.Provider(), req.NameID.Value)
+		if err == nil && user != nil {
+			if err := sessions.DeleteByUserID(r.Context(), user.ID); err != nil {
+				logger.Error("saml: end sessions", zap.String("user_id", user.ID), zap.Error(err))
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+		}
+		dashauth.ClearSessionCookie(w)
+
+		responseURL, err := provider.LogoutResponseURL(req.ID, req.RelayState)
+		if err != nil {
+			logger.Error("saml: build logout response", zap.Error(err))
+			http.Redirect(w, r, "/login", http.StatusSeeOther)
+			return
+		}
+		http.Redirect(w, r, responseURL, http.StatusFound)
+	}
+}
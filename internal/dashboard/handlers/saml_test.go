@@ -0,0 +1,220 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/FairForge/vaultaire/internal/auth"
+	dashauth "github.com/FairForge/vaultaire/internal/dashboard/auth"
+	"github.com/beevik/etree"
+	dsig "github.com/russellhaering/goxmldsig"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// testIdP signs SAML responses for the SP at https://sp.example.com.
+type testIdP struct {
+	key  *rsa.PrivateKey
+	der  []byte
+	prov *auth.SAMLProvider
+}
+
+func newTestIdP(t *testing.T, tenantID string) *testIdP {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "idp"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	cfg := &auth.SAMLConfig{
+		EntityID:       "https://sp.example.com/saml/metadata",
+		ACSURL:         "https://sp.example.com/saml/acs",
+		SLOURL:         "https://sp.example.com/saml/slo",
+		IDPEntityID:    "https://idp.example.com",
+		IDPSSOURL:      "https://idp.example.com/sso",
+		IDPSLOURL:      "https://idp.example.com/slo",
+		IDPCertificate: string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+		TenantID:       tenantID,
+		RoleMapping:    auth.RoleMapping{GroupRoles: map[string]string{"Storage Admins": "admin"}},
+	}
+	cfg.ApplyDefaults()
+	prov, err := auth.NewSAMLProvider(cfg)
+	require.NoError(t, err)
+	return &testIdP{key: key, der: der, prov: prov}
+}
+
+// response returns a base64 response with a signed assertion for email in
+// group, answering requestID.
+func (idp *testIdP) response(t *testing.T, requestID, email, group string) string {
+	t.Helper()
+	now := time.Now().UTC()
+	ts := func(d time.Duration) string { return now.Add(d).Format(time.RFC3339) }
+	assertion := fmt.Sprintf(`<saml:Assertion xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion" ID="_a-%d" Version="2.0" IssueInstant="%s">`+
+		`<saml:Issuer>https://idp.example.com</saml:Issuer>`+
+		`<saml:Subject><saml:NameID>%s</saml:NameID><saml:SubjectConfirmation Method="urn:oasis:names:tc:SAML:2.0:cm:bearer">`+
+		`<saml:SubjectConfirmationData InResponseTo="%s" NotOnOrAfter="%s" Recipient="https://sp.example.com/saml/acs"/></saml:SubjectConfirmation></saml:Subject>`+
+		`<saml:Conditions NotBefore="%s" NotOnOrAfter="%s"><saml:AudienceRestriction><saml:Audience>https://sp.example.com/saml/metadata</saml:Audience></saml:AudienceRestriction></saml:Conditions>`+
+		`<saml:AttributeStatement><saml:Attribute Name="http://schemas.xmlsoap.org/claims/Group"><saml:AttributeValue>%s</saml:AttributeValue></saml:Attribute></saml:AttributeStatement>`+
+		`</saml:Assertion>`, now.UnixNano(), ts(0), email, requestID, ts(5*time.Minute), ts(-time.Minute), ts(5*time.Minute), group)
+
+	doc := etree.NewDocument()
+	require.NoError(t, doc.ReadFromString(assertion))
+	ctx := dsig.NewDefaultSigningContext(dsig.TLSCertKeyStore(tls.Certificate{Certificate: [][]byte{idp.der}, PrivateKey: idp.key}))
+	ctx.Canonicalizer = dsig.MakeC14N10ExclusiveCanonicalizerWithPrefixList("")
+	signed, err := ctx.SignEnveloped(doc.Root())
+	require.NoError(t, err)
+	doc.SetRoot(signed)
+	signedXML, err := doc.WriteToString()
+	require.NoError(t, err)
+
+	response := `<samlp:Response xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" ID="_r1" Version="2.0" IssueInstant="` + ts(0) +
+		`" InResponseTo="` + requestID + `"><samlp:Status><samlp:StatusCode Value="urn:oasis:names:tc:SAML:2.0:status:Success"/></samlp:Status>` +
+		signedXML + `</samlp:Response>`
+	return base64.StdEncoding.EncodeToString([]byte(response))
+}
+
+func samlCookie(t *testing.T, w *httptest.ResponseRecorder) *http.Cookie {
+	t.Helper()
+	for _, c := range w.Result().Cookies() {
+		if c.Name == samlRequestCookie {
+			return c
+		}
+	}
+	t.Fatal("no saml_request cookie")
+	return nil
+}
+
+func postACS(handler http.HandlerFunc, samlResponse string, cookie *http.Cookie) *httptest.ResponseRecorder {
+	form := url.Values{"SAMLResponse": {samlResponse}}
+	req := httptest.NewRequest("POST", "/saml/acs", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if cookie != nil {
+		req.AddCookie(cookie)
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	return w
+}
+
+func TestHandleSAMLLogin_Redirect(t *testing.T) {
+	idp := newTestIdP(t, "tenant-1")
+	w := httptest.NewRecorder()
+	HandleSAMLLogin(idp.prov, zap.NewNop()).ServeHTTP(w, httptest.NewRequest("GET", "/saml/login", nil))
+
+	assert.Equal(t, http.StatusFound, w.Code)
+	assert.True(t, strings.HasPrefix(w.Header().Get("Location"), "https://idp.example.com/sso?SAMLRequest="))
+	c := samlCookie(t, w)
+	assert.True(t, c.HttpOnly)
+	assert.True(t, c.Secure)
+	assert.Equal(t, http.SameSiteNoneMode, c.SameSite)
+	assert.NotEmpty(t, c.Value)
+}
+
+func TestHandleSAMLMetadata(t *testing.T) {
+	idp := newTestIdP(t, "tenant-1")
+	w := httptest.NewRecorder()
+	HandleSAMLMetadata(idp.prov).ServeHTTP(w, httptest.NewRequest("GET", "/saml/metadata", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `Location="https://sp.example.com/saml/acs"`)
+}
+
+func TestHandleSAMLACS(t *testing.T) {
+	authSvc := createTestAuthSvc(t)
+	_, tenant, _, err := authSvc.CreateUserWithTenant(t.Context(), "owner@example.com", "owner-pw-123", "Example")
+	require.NoError(t, err)
+	idp := newTestIdP(t, tenant.ID)
+	sessions := dashauth.NewMemoryStore()
+	acs := HandleSAMLACS(idp.prov, authSvc, sessions, zap.NewNop())
+
+	login := func() *http.Cookie {
+		w := httptest.NewRecorder()
+		HandleSAMLLogin(idp.prov, zap.NewNop()).ServeHTTP(w, httptest.NewRequest("GET", "/saml/login", nil))
+		return samlCookie(t, w)
+	}
+
+	t.Run("first sign-in provisions a member of the tenant", func(t *testing.T) {
+		c := login()
+		w := postACS(acs, idp.response(t, c.Value, "jsmith@example.com", "Storage Admins"), c)
+		assert.Equal(t, "/dashboard", w.Header().Get("Location"))
+
+		var token string
+		for _, ck := range w.Result().Cookies() {
+			if ck.Name == dashauth.SessionCookieName {
+				token = ck.Value
+			}
+		}
+		sd, err := sessions.Get(t.Context(), token)
+		require.NoError(t, err)
+		assert.Equal(t, "jsmith@example.com", sd.Email)
+		assert.Equal(t, tenant.ID, sd.TenantID)
+		assert.Equal(t, "admin", sd.Role)
+	})
+
+	t.Run("rejects a response without the request cookie", func(t *testing.T) {
+		c := login()
+		w := postACS(acs, idp.response(t, c.Value, "jsmith@example.com", "Storage Admins"), nil)
+		assert.Equal(t, "/login", w.Header().Get("Location"))
+	})
+
+	t.Run("rejects a user with no mapped role", func(t *testing.T) {
+		c := login()
+		w := postACS(acs, idp.response(t, c.Value, "guest@example.com", "Visitors"), c)
+		assert.Equal(t, "/login", w.Header().Get("Location"))
+	})
+
+	t.Run("rejects garbage", func(t *testing.T) {
+		w := postACS(acs, "not-base64!", login())
+		assert.Equal(t, "/login", w.Header().Get("Location"))
+	})
+}
+
+func TestHandleSAMLSLO_RequiresSignature(t *testing.T) {
+	idp := newTestIdP(t, "tenant-1")
+	handler := HandleSAMLSLO(idp.prov, createTestAuthSvc(t), dashauth.NewMemoryStore(), zap.NewNop())
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/saml/slo?SAMLRequest=abc", nil))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// A LogoutResponse finishes an SP-initiated logout.
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/saml/slo?SAMLResponse=abc", nil))
+	assert.Equal(t, "/login", w.Header().Get("Location"))
+}
+
+func TestHandleSAMLLogout_EndsLocalSession(t *testing.T) {
+	idp := newTestIdP(t, "tenant-1")
+	sessions := dashauth.NewMemoryStore()
+	token, err := sessions.Create(t.Context(), dashauth.SessionData{UserID: "u1", Email: "a@example.com"}, time.Hour)
+	require.NoError(t, err)
+
+	req := httptest.NewRequest("GET", "/saml/logout", nil)
+	req.AddCookie(&http.Cookie{Name: dashauth.SessionCookieName, Value: token})
+	w := httptest.NewRecorder()
+	HandleSAMLLogout(idp.prov, createTestAuthSvc(t), sessions, zap.NewNop()).ServeHTTP(w, req)
+
+	// Without a linked SAML account there is no NameID to log out at the IdP.
+	assert.Equal(t, "/login", w.Header().Get("Location"))
+	sd, _ := sessions.Get(t.Context(), token)
+	assert.Nil(t, sd)
+}
@@ -41,6 +41,9 @@ type Deps struct {
 	Engine        *engine.CoreEngine     // Nil-safe; used by admin backends page.
 	HealthChecker handlers.HealthChecker // Nil-safe; backend health state provider.
 	Flags         *flags.Service         // Nil-safe; admin feature-flags page (1.13).
+	Directory     *auth.Directory        // Nil when VAULTAIRE_DIRECTORY_CONFIG is not set.
+	SAML          *auth.SAMLProvider     // Nil when VAULTAIRE_SAML_CONFIG is not set.
+	OIDC          *auth.OIDCRelyingParty // Nil without a database; tenants' own IdPs.
 }
 
 // RegisterRoutes mounts the dashboard, auth, admin, and static-asset
@@ -105,6 +108,27 @@ func RegisterRoutes(r chi.Router, deps Deps) {
 			deps.Auth, deps.Sessions, deps.DB, deps.Logger, oauthCreds))
 	}
 
+	// --- SAML single sign-on ---
+	// The IdP posts to the ACS cross-site, so it sits outside CSRF
+	// protection; the signed response and its InResponseTo stand in.
+	if deps.SAML != nil {
+		r.Get("/saml/login", handlers.HandleSAMLLogin(deps.SAML, deps.Logger))
+		r.Get("/saml/metadata", handlers.HandleSAMLMetadata(deps.SAML))
+		r.Post("/saml/acs", handlers.HandleSAMLACS(deps.SAML, deps.Auth, deps.Sessions, deps.Logger))
+		r.Get("/saml/slo", handlers.HandleSAMLSLO(deps.SAML, deps.Auth, deps.Sessions, deps.Logger))
+		r.Get("/saml/logout", handlers.HandleSAMLLogout(deps.SAML, deps.Auth, deps.Sessions, deps.Logger))
+	}
+
+	// --- Organisation single sign-on (OIDC) ---
+	// Each tenant brings its own IdP; the login form names the
+	// organisation by email address or slug. Sign-in starts with a GET so
+	// it needs no CSRF token; the state cookie binds the callback.
+	if deps.OIDC != nil {
+		r.Get("/oidc/login", loginRL.Limit(handlers.HandleOIDCLogin(deps.OIDC, deps.DB, deps.Logger)).ServeHTTP)
+		r.Get("/oidc/callback", handlers.HandleOIDCCallback(deps.OIDC, deps.DB, deps.Auth, deps.Sessions, deps.Logger))
+		r.Get("/oidc/logout", handlers.HandleOIDCLogout(deps.OIDC, deps.DB, deps.Sessions, deps.BaseURL+"/login", deps.Logger))
+	}
+
 	// --- Legal pages (public) ---
 	legalPages := map[string]string{
 		"privacy":  "templates/legal/privacy.html",
@@ -357,6 +381,15 @@ func RegisterRoutes(r chi.Router, deps Deps) {
 	})
 }
 
+// ssoErrors are the messages the login page shows when organisation
+// sign-on sends the user back with ?sso=<code>.
+var ssoErrors = map[string]string{
+	"not_found": "No single sign-on is set up for that organisation.",
+	"failed":    "Single sign-on failed. Please try again.",
+	"denied":    "Your organisation has not granted you access to stored.ge.",
+	"conflict":  "An account with that email already exists. Sign in with your password.",
+}
+
 // renderAuthPage renders a public auth page (login, register) with OAuth flags.
 func renderAuthPage(base *template.Template, page string, deps Deps) http.HandlerFunc {
 	tmpl := template.Must(base.Clone())
@@ -364,9 +397,15 @@ func renderAuthPage(base *template.Template, page string, deps Deps) http.Handle
 
 	return func(w http.ResponseWriter, r *http.Request) {
 		data := map[string]any{
-			"Page":      page,
-			"HasGoogle": deps.Google != nil,
-			"HasGithub": deps.GitHub != nil,
+			"Page":         page,
+			"HasGoogle":    deps.Google != nil,
+			"HasGithub":    deps.GitHub != nil,
+			"HasDirectory": deps.Directory != nil,
+			"HasSAML":      deps.SAML != nil,
+			"HasOIDC":      deps.OIDC != nil,
+		}
+		if page == "login" {
+			data["Error"] = ssoErrors[r.URL.Query().Get("sso")]
 		}
 		w.Header().Set("Content-Type", "text/html; charset=utf-8")
 		if err := tmpl.ExecuteTemplate(w, "base", data); err != nil {
@@ -387,28 +426,40 @@ func handleLogin(baseTmpl *template.Template, deps Deps) http.HandlerFunc {
 			w.Header().Set("Content-Type", "text/html; charset=utf-8")
 			w.WriteHeader(http.StatusUnauthorized)
 			_ = errTmpl.ExecuteTemplate(w, "base", map[string]any{
-				"Error":     msg,
-				"Email":     email,
-				"Page":      "login",
-				"HasGoogle": deps.Google != nil,
-				"HasGithub": deps.GitHub != nil,
+				"Error":        msg,
+				"Email":        email,
+				"Page":         "login",
+				"HasGoogle":    deps.Google != nil,
+				"HasGithub":    deps.GitHub != nil,
+				"HasDirectory": deps.Directory != nil,
+				"HasSAML":      deps.SAML != nil,
+				"HasOIDC":      deps.OIDC != nil,
 			})
 		}
 
-		valid, err := deps.Auth.ValidatePassword(r.Context(), email, password)
-		if err != nil || !valid {
-			renderErr("Invalid email or password.")
-			return
-		}
-
-		user, err := deps.Auth.GetUserByEmail(r.Context(), email)
-		if err != nil {
+		// Determine role — default to "user" if the DB column isn't loaded yet.
+		role := "user"
+		var user *auth.User
+		if valid, err := deps.Auth.ValidatePassword(r.Context(), email, password); err == nil && valid {
+			user, err = deps.Auth.GetUserByEmail(r.Context(), email)
+			if err != nil {
+				renderErr("Invalid email or password.")
+				return
+			}
+		} else if deps.Directory != nil {
+			// Not a local password: try the corporate directory, where
+			// the "email" field may hold a username.
+			var msg string
+			user, role, msg = directoryLogin(r, deps, email, password)
+			if msg != "" {
+				renderErr(msg)
+				return
+			}
+		} else {
 			renderErr("Invalid email or password.")
 			return
 		}
 
-		// Determine role — default to "user" if the DB column isn't loaded yet.
-		role := "user"
 		if deps.DB != nil {
 			_ = deps.DB.QueryRowContext(r.Context(),
 				`SELECT role FROM users WHERE id = $1`, user.ID).Scan(&role)
@@ -461,6 +512,36 @@ func handleLogin(baseTmpl *template.Template, deps Deps) http.HandlerFunc {
 	}
 }
 
+// directoryLogin authenticates against deps.Directory and provisions the
+// user as a member of the directory's tenant, with roles that follow their
+// directory groups. On failure it returns the message to show instead;
+// every credential or account-state failure shows the same one.
+func directoryLogin(r *http.Request, deps Deps, username, password string) (*auth.User, string, string) {
+	id, err := deps.Directory.Authenticate(r.Context(), username, password)
+	switch {
+	case errors.Is(err, auth.ErrNoMappedRole):
+		return nil, "", "Your directory account has not been granted access."
+	case errors.Is(err, auth.ErrDirectoryInvalidCredentials), errors.Is(err, auth.ErrDirectoryAccountDisabled),
+		errors.Is(err, auth.ErrDirectoryAccountLocked), errors.Is(err, auth.ErrDirectoryPasswordExpired):
+		return nil, "", "Invalid email or password."
+	case err != nil:
+		deps.Logger.Error("directory login", zap.String("provider", deps.Directory.Provider()), zap.Error(err))
+		return nil, "", "Sign-in is temporarily unavailable. Please try again."
+	}
+
+	user, err := deps.Directory.Provision(r.Context(), deps.Auth, id)
+	switch {
+	case errors.Is(err, auth.ErrAccountConflict):
+		return nil, "", "An account with that email already exists. Sign in with its password."
+	case errors.Is(err, auth.ErrUserDeactivated):
+		return nil, "", "Invalid email or password."
+	case err != nil:
+		deps.Logger.Error("directory provision", zap.String("subject", id.Subject), zap.Error(err))
+		return nil, "", "Something went wrong. Please try again."
+	}
+	return user, auth.DashboardRole(id.Roles), ""
+}
+
 // signupCredsRenderer builds the reveal-once S3 credentials renderer (B2),
 // shared by the web register handler and the OAuth signup callbacks. The
 // secret exists only in the rendered response — never persisted, never
@@ -800,18 +881,30 @@ func pageContent(page string) string {
 			`<div class="auth-brand">stored.ge</div>` +
 			`<h1>Sign In</h1>` +
 			`{{if .Error}}<div class="alert alert-error">{{.Error}}</div>{{end}}` +
-			`{{if or .HasGoogle .HasGithub}}` +
+			`{{if or .HasGoogle .HasGithub .HasSAML}}` +
 			`<div class="oauth-buttons">` +
+			`{{if .HasSAML}}<a href="/saml/login" class="btn btn-oauth btn-sso">Sign in with SSO</a>{{end}}` +
 			`{{if .HasGoogle}}<a href="/auth/google" class="btn btn-oauth btn-google">Sign in with Google</a>{{end}}` +
 			`{{if .HasGithub}}<a href="/auth/github" class="btn btn-oauth btn-github">Sign in with GitHub</a>{{end}}` +
 			`</div>` +
 			`<div class="auth-divider"><span>or</span></div>` +
 			`{{end}}` +
 			`<form method="POST" action="/login">` +
+			`{{if .HasDirectory}}` +
+			`<div class="form-group"><label>Email or username</label><input type="text" name="email" value="{{.Email}}" autocomplete="username" required></div>` +
+			`{{else}}` +
 			`<div class="form-group"><label>Email</label><input type="email" name="email" value="{{.Email}}" required></div>` +
+			`{{end}}` +
 			`<div class="form-group"><label>Password</label><input type="password" name="password" required></div>` +
 			`<button type="submit" class="btn btn-primary btn-block">Sign In</button>` +
 			`</form>` +
+			`{{if .HasOIDC}}` +
+			`<div class="auth-divider"><span>or</span></div>` +
+			`<form method="GET" action="/oidc/login">` +
+			`<div class="form-group"><label>Work email or organisation</label><input type="text" name="org" placeholder="you@company.com" required></div>` +
+			`<button type="submit" class="btn btn-oauth btn-sso btn-block">Sign in with your organisation</button>` +
+			`</form>` +
+			`{{end}}` +
 			`<div class="auth-footer">No account? <a href="/register">Create one</a></div>` +
 			`</div></div>{{end}}`
 	case "register":
@@ -2,6 +2,7 @@ package dashboard
 
 import (
 	"context"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
@@ -194,6 +195,55 @@ func TestPostLogin_NoSuchUser(t *testing.T) {
 	assert.Contains(t, w.Body.String(), "Invalid email or password")
 }
 
+func TestPostLogin_Directory(t *testing.T) {
+	// A directory nobody is listening for: sign-in falls back to it only
+	// after the local password check fails.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	port := ln.Addr().(*net.TCPAddr).Port
+	require.NoError(t, ln.Close())
+	dir, err := auth.NewDirectory(&auth.DirectoryConfig{
+		Type:        auth.DirectoryLDAP,
+		LDAP:        &auth.LDAPConfig{Host: "127.0.0.1", Port: port, BaseDN: "dc=example,dc=com", Timeout: time.Second, AllowPlaintext: true},
+		TenantID:    "tenant-1",
+		RoleMapping: auth.RoleMapping{DefaultRole: "user"},
+	})
+	require.NoError(t, err)
+	defer dir.Close()
+
+	logger := zap.NewNop()
+	authSvc := auth.NewAuthService(nil, nil)
+	r := chi.NewRouter()
+	RegisterRoutes(r, Deps{
+		Auth:      authSvc,
+		Sessions:  dashauth.NewMemoryStore(),
+		Logger:    logger,
+		Email:     email.NewSender(logger),
+		Directory: dir,
+	})
+	_, _ = authSvc.CreateUser(context.Background(), "login@stored.ge", "securepass123")
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/login", nil))
+	assert.Contains(t, w.Body.String(), "Email or username")
+
+	post := func(user, password string) *httptest.ResponseRecorder {
+		form := url.Values{"email": {user}, "password": {password}}
+		req := httptest.NewRequest("POST", "/login", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	w = post("login@stored.ge", "securepass123")
+	assert.Equal(t, http.StatusSeeOther, w.Code, "local accounts do not depend on the directory")
+
+	w = post("jsmith", "whatever123")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Body.String(), "temporarily unavailable")
+}
+
 func TestDashboard_RequiresSession(t *testing.T) {
 	r, _, _ := setupTestRouter(t)
 
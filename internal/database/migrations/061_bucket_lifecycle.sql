@@ -0,0 +1,14 @@
+-- 061_bucket_lifecycle.sql: S3 bucket lifecycle configuration (?lifecycle).
+--
+-- The validated LifecycleConfiguration document is stored as XML on the
+-- bucket row; NULL means no lifecycle rules. LifecycleRunner scans buckets
+-- with a configuration hourly and applies expiration, noncurrent-version
+-- expiration, incomplete-multipart abort and storage-class transitions.
+ALTER TABLE buckets ADD COLUMN IF NOT EXISTS lifecycle_config TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_buckets_lifecycle
+    ON buckets (tenant_id, name) WHERE lifecycle_config IS NOT NULL;
+
+-- Expiration and transition scans select by (tenant, bucket, age).
+CREATE INDEX IF NOT EXISTS idx_object_head_cache_updated
+    ON object_head_cache (tenant_id, bucket, updated_at);
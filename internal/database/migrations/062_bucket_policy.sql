@@ -0,0 +1,6 @@
+-- 062_bucket_policy.sql: IAM-style bucket policies (?policy).
+--
+-- The validated JSON policy document is stored on the bucket row; NULL means
+-- no policy. It is evaluated on every S3 request next to the key scope, and
+-- on CDN requests for the anonymous principal.
+ALTER TABLE buckets ADD COLUMN IF NOT EXISTS policy TEXT;
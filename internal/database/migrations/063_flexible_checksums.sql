@@ -0,0 +1,16 @@
+-- 063_flexible_checksums.sql: flexible checksums (x-amz-checksum-*).
+--
+-- Objects record the algorithm, the base64 value and whether it covers the
+-- full object or is a COMPOSITE of part checksums ("<b64>-<parts>").
+-- Multipart uploads fix the algorithm and type at initiation; each part
+-- stores its own checksum so CompleteMultipartUpload can derive the object's.
+
+ALTER TABLE object_head_cache ADD COLUMN IF NOT EXISTS checksum_algorithm TEXT;
+ALTER TABLE object_head_cache ADD COLUMN IF NOT EXISTS checksum_value TEXT;
+ALTER TABLE object_head_cache ADD COLUMN IF NOT EXISTS checksum_type TEXT;
+
+ALTER TABLE multipart_uploads ADD COLUMN IF NOT EXISTS checksum_algorithm TEXT;
+ALTER TABLE multipart_uploads ADD COLUMN IF NOT EXISTS checksum_type TEXT;
+
+ALTER TABLE multipart_parts ADD COLUMN IF NOT EXISTS checksum_algorithm TEXT;
+ALTER TABLE multipart_parts ADD COLUMN IF NOT EXISTS checksum_value TEXT;
@@ -0,0 +1,22 @@
+-- 064_multipart_part_copy.sql: UploadPartCopy from chunked sources.
+--
+-- A part copied from a deduplicated (chunked) object holds references to the
+-- source's chunks instead of staged bytes. Each row is one slice of a chunk:
+-- skip_bytes/take_bytes select the part's bytes within the chunk. Every row
+-- holds one global_content_index reference, released on part overwrite,
+-- abort, purge, or handed to the object manifest on completion. (081 drops
+-- the cascade below: deleting an upload must not drop rows holding refs.)
+
+CREATE TABLE IF NOT EXISTS multipart_part_chunks (
+    upload_id              TEXT NOT NULL REFERENCES multipart_uploads(upload_id) ON DELETE CASCADE,
+    part_number            INT NOT NULL,
+    seq                    INT NOT NULL,
+    dedup_scope            TEXT NOT NULL,
+    plaintext_hash         TEXT NOT NULL,
+    chunk_size             BIGINT NOT NULL,
+    skip_bytes             BIGINT NOT NULL,
+    take_bytes             BIGINT NOT NULL,
+    encryption_key_version INT NOT NULL DEFAULT 0,
+    ciphertext_hash        TEXT,
+    PRIMARY KEY (upload_id, part_number, seq)
+);
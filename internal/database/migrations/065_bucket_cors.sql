@@ -0,0 +1,8 @@
+-- 065_bucket_cors.sql: per-bucket CORS configuration (?cors).
+--
+-- The validated CORSConfiguration XML is stored on the bucket row; NULL means
+-- no configuration. It answers preflight OPTIONS requests and decorates
+-- actual requests on both the S3 API and the CDN. When it is NULL the CDN
+-- keeps using the legacy cors_origins column.
+
+ALTER TABLE buckets ADD COLUMN IF NOT EXISTS cors_config TEXT;
@@ -0,0 +1,15 @@
+-- 066_sse_streaming.sql: segmented SSE streams and multipart SSE.
+--
+-- SSE-S3 and SSE-C objects are now written as a stream of authenticated
+-- fixed-size segments instead of one whole-object AEAD blob, so they are
+-- encrypted and decrypted without buffering and range GETs decrypt only the
+-- covering segments. sse_segmented marks objects in the new format; rows
+-- written before it keep the whole-object format and the old read path.
+--
+-- A multipart upload records the encryption requested at initiation, and for
+-- SSE-C the key MD5 every part and the completion must present.
+
+ALTER TABLE object_head_cache ADD COLUMN IF NOT EXISTS sse_segmented BOOLEAN NOT NULL DEFAULT FALSE;
+
+ALTER TABLE multipart_uploads ADD COLUMN IF NOT EXISTS encryption_algorithm TEXT;
+ALTER TABLE multipart_uploads ADD COLUMN IF NOT EXISTS sse_customer_key_md5 TEXT;
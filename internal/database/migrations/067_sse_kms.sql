@@ -0,0 +1,20 @@
+-- 067_sse_kms.sql: SSE-KMS with customer-managed keys held by an external
+-- key provider (local keyring, Vault Transit or KMIP).
+--
+-- An SSE-KMS object is a segmented stream (066) under a per-object data key.
+-- Only the data key wrapped under the named KMS key is stored, with the key
+-- ID and the encryption context it is bound to; revoking the key at the
+-- provider makes the object unreadable.
+--
+-- buckets.sse_kms_key_id names the default KMS key of a bucket whose default
+-- encryption is aws:kms. NULL means the default is not SSE-KMS; an empty
+-- string means aws:kms with the service's default key.
+
+ALTER TABLE object_head_cache ADD COLUMN IF NOT EXISTS sse_kms_key_id TEXT;
+ALTER TABLE object_head_cache ADD COLUMN IF NOT EXISTS sse_kms_data_key TEXT;
+ALTER TABLE object_head_cache ADD COLUMN IF NOT EXISTS sse_kms_context TEXT;
+
+ALTER TABLE buckets ADD COLUMN IF NOT EXISTS sse_kms_key_id TEXT;
+
+ALTER TABLE multipart_uploads ADD COLUMN IF NOT EXISTS sse_kms_key_id TEXT;
+ALTER TABLE multipart_uploads ADD COLUMN IF NOT EXISTS sse_kms_context TEXT;
@@ -0,0 +1,49 @@
+-- 068_bucket_replication.sql: S3 bucket replication (?replication).
+--
+-- The validated ReplicationConfiguration document is stored as XML on the
+-- bucket row; NULL means the bucket is not replicated. Every write to a
+-- replicated bucket enqueues one replication_queue row per destination in
+-- the same transaction as its head row, so a committed object is never
+-- missed; ReplicationRunner drains the queue with retries.
+--
+-- object_head_cache.replication_status is what HEAD/GET report as
+-- x-amz-replication-status: PENDING, COMPLETED or FAILED on a source
+-- object, REPLICA on a destination object. NULL means not replicated.
+ALTER TABLE buckets ADD COLUMN IF NOT EXISTS replication_config TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_buckets_replication
+    ON buckets (tenant_id, name) WHERE replication_config IS NOT NULL;
+
+ALTER TABLE object_head_cache ADD COLUMN IF NOT EXISTS replication_status TEXT;
+
+CREATE TABLE IF NOT EXISTS replication_queue (
+    id              BIGSERIAL PRIMARY KEY,
+    tenant_id       TEXT NOT NULL,
+    bucket          TEXT NOT NULL,
+    object_key      TEXT NOT NULL,
+    operation       TEXT NOT NULL CHECK (operation IN ('put', 'delete')),
+    rule_id         TEXT NOT NULL DEFAULT '',
+    dest_tenant_id  TEXT NOT NULL,
+    dest_bucket     TEXT NOT NULL,
+    storage_class   TEXT NOT NULL DEFAULT '',
+    etag            TEXT NOT NULL DEFAULT '',
+    status          TEXT NOT NULL DEFAULT 'pending'
+                    CHECK (status IN ('pending', 'completed', 'failed')),
+    attempts        INT NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_error      TEXT,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    completed_at    TIMESTAMPTZ
+);
+
+-- The runner claims due jobs oldest first.
+CREATE INDEX IF NOT EXISTS idx_replication_queue_due
+    ON replication_queue (next_attempt_at) WHERE status = 'pending';
+
+-- Jobs of one key run in order; its object's status is derived from them.
+CREATE INDEX IF NOT EXISTS idx_replication_queue_key
+    ON replication_queue (tenant_id, bucket, object_key);
+
+-- Completed jobs are purged after a retention period.
+CREATE INDEX IF NOT EXISTS idx_replication_queue_completed
+    ON replication_queue (completed_at) WHERE status = 'completed';
@@ -0,0 +1,24 @@
+-- 069_bucket_website.sql: static website hosting (?website) on the CDN host.
+--
+-- The validated WebsiteConfiguration document is stored as XML on the
+-- bucket row; NULL means the CDN serves the bucket as plain objects.
+--
+-- object_head_cache.website_redirect_location holds the object's
+-- x-amz-website-redirect-location: a website request for the key is
+-- answered with a 301 to it instead of the object's bytes.
+--
+-- bucket_website_domains maps a custom hostname (CNAMEd to the CDN by the
+-- operator, who also provisions its certificate) to the bucket it serves.
+ALTER TABLE buckets ADD COLUMN IF NOT EXISTS website_config TEXT;
+
+ALTER TABLE object_head_cache ADD COLUMN IF NOT EXISTS website_redirect_location TEXT;
+
+CREATE TABLE IF NOT EXISTS bucket_website_domains (
+    hostname   TEXT PRIMARY KEY,
+    tenant_id  TEXT NOT NULL,
+    bucket     TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_bucket_website_domains_bucket
+    ON bucket_website_domains (tenant_id, bucket);
@@ -0,0 +1,8 @@
+-- 070_object_part_layout.sql: GetObjectAttributes part listings.
+--
+-- multipart_parts rows are purged with their upload after completion, so the
+-- completed object keeps its own copy of the part layout: a JSON array of
+-- {"n": part number, "size": bytes, "checksum": base64 part checksum}.
+-- NULL for single-part objects; every head-cache write that is not a
+-- multipart completion clears it.
+ALTER TABLE object_head_cache ADD COLUMN IF NOT EXISTS part_layout JSONB;
@@ -0,0 +1,27 @@
+-- 071_acls.sql: bucket and object ACLs, ownership controls and public
+-- access blocks.
+--
+-- ACLs are stored as JSON {"owner": tenant, "grants": [{"id"|"group",
+-- "perm"}]}; grantee IDs are tenant IDs, the canonical IDs the S3 API
+-- reports as owners. NULL means private to the bucket's owner. A bucket's
+-- AllUsers READ grant is its visibility column, so buckets.acl never holds
+-- it: public-read buckets keep serving every object on the CDN.
+--
+-- The bucket owner keeps full access to everything in its buckets; ACLs
+-- decide what other tenants may do there, and which single objects the CDN
+-- serves from an otherwise private bucket.
+--
+-- object_ownership is ObjectWriter when NULL; BucketOwnerEnforced disables
+-- ACLs. public_access_block holds the four PutPublicAccessBlock flags.
+ALTER TABLE buckets ADD COLUMN IF NOT EXISTS acl JSONB;
+ALTER TABLE buckets ADD COLUMN IF NOT EXISTS object_ownership TEXT;
+ALTER TABLE buckets ADD COLUMN IF NOT EXISTS public_access_block JSONB;
+
+ALTER TABLE object_head_cache ADD COLUMN IF NOT EXISTS acl JSONB;
+
+-- The ACL requested by CreateMultipartUpload, applied at completion.
+ALTER TABLE multipart_uploads ADD COLUMN IF NOT EXISTS acl JSONB;
+
+-- Requests naming a bucket the signer does not own look up its owner by
+-- name alone.
+CREATE INDEX IF NOT EXISTS idx_buckets_name ON buckets (name);
@@ -0,0 +1,16 @@
+-- 072_rate_limit_buckets.sql: token buckets shared by every replica, for
+-- deployments without Redis (internal/ratelimit PostgresStore).
+--
+-- tokens is what the bucket held at updated_at; the refill since then is
+-- computed on the next take. allowed records whether that take succeeded,
+-- so one upsert both updates the bucket and reports the outcome. Rows past
+-- expires_at are full buckets again and are pruned periodically.
+CREATE TABLE IF NOT EXISTS rate_limit_buckets (
+    bucket_key TEXT PRIMARY KEY,
+    tokens DOUBLE PRECISION NOT NULL,
+    allowed BOOLEAN NOT NULL DEFAULT TRUE,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_rate_limit_buckets_expires ON rate_limit_buckets (expires_at);
@@ -0,0 +1,82 @@
+-- 073_delivery_outbox.sql: durable webhook and S3 notification delivery.
+--
+-- Producers insert one row per (event, endpoint); workers on every replica
+-- claim due rows with a lease (locked_until), POST them, and either mark
+-- them delivered or schedule the next attempt with backoff. A row whose
+-- attempts run out, or whose endpoint is disabled, becomes 'dead' and stays
+-- until it is redelivered or its window replayed. endpoint_key groups rows
+-- by receiving endpoint for the per-endpoint concurrency limit.
+-- Idempotent — safe to re-run on every deploy.
+CREATE TABLE IF NOT EXISTS delivery_outbox (
+    id               TEXT PRIMARY KEY,
+    kind             TEXT NOT NULL,                -- 'webhook' or 'notification'
+    tenant_id        TEXT NOT NULL,
+    endpoint_key     TEXT NOT NULL,
+    webhook_id       TEXT REFERENCES webhook_endpoints(id) ON DELETE CASCADE,
+    bucket           TEXT,                         -- notifications only
+    url              TEXT NOT NULL,
+    event_id         TEXT REFERENCES events(id) ON DELETE CASCADE,
+    event_type       TEXT NOT NULL,
+    payload          BYTEA NOT NULL,
+    status           TEXT NOT NULL DEFAULT 'pending',
+    attempts         INT NOT NULL DEFAULT 0,
+    next_attempt_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    locked_until     TIMESTAMPTZ,
+    response_code    INT NOT NULL DEFAULT 0,
+    response_body    TEXT NOT NULL DEFAULT '',
+    latency_ms       INT NOT NULL DEFAULT 0,
+    last_error       TEXT NOT NULL DEFAULT '',
+    redelivery_of    TEXT,
+    created_at       TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at       TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    delivered_at     TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_delivery_outbox_due
+    ON delivery_outbox(next_attempt_at) WHERE status IN ('pending', 'failed');
+CREATE INDEX IF NOT EXISTS idx_delivery_outbox_leased
+    ON delivery_outbox(endpoint_key, locked_until) WHERE status = 'delivering';
+CREATE INDEX IF NOT EXISTS idx_delivery_outbox_webhook
+    ON delivery_outbox(webhook_id, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_delivery_outbox_event
+    ON delivery_outbox(event_id);
+CREATE INDEX IF NOT EXISTS idx_delivery_outbox_tenant
+    ON delivery_outbox(tenant_id);
+
+-- Consecutive failed attempts per endpoint; reaching the limit disables it.
+ALTER TABLE webhook_endpoints
+    ADD COLUMN IF NOT EXISTS consecutive_failures INT NOT NULL DEFAULT 0;
+ALTER TABLE bucket_notifications
+    ADD COLUMN IF NOT EXISTS consecutive_failures INT NOT NULL DEFAULT 0;
+
+-- webhook_deliveries (migration 033) recorded single attempts. Carry its
+-- history over, rebuilding each payload from the event so old failures can
+-- be redelivered, then drop it. Failed rows were never retried: they are
+-- dead letters now.
+DO $$
+BEGIN
+    IF to_regclass('webhook_deliveries') IS NOT NULL THEN
+        INSERT INTO delivery_outbox (id, kind, tenant_id, endpoint_key, webhook_id, url,
+            event_id, event_type, payload, status, attempts, response_code, response_body,
+            latency_ms, created_at, updated_at, delivered_at)
+        SELECT d.id, 'webhook', w.tenant_id, 'webhook:' || w.id, w.id, w.url,
+            e.id, e.type,
+            convert_to(jsonb_build_object(
+                'id', e.id,
+                'type', e.type,
+                'tenant_id', e.tenant_id,
+                'data', e.data,
+                'created_at', to_char(e.created_at AT TIME ZONE 'UTC', 'YYYY-MM-DD"T"HH24:MI:SS"Z"')
+            )::text, 'UTF8'),
+            CASE WHEN d.status = 'delivered' THEN 'delivered' ELSE 'dead' END,
+            d.retry_count + 1, COALESCE(d.response_code, 0), COALESCE(d.response_body, ''),
+            COALESCE(d.latency_ms, 0), d.created_at, d.created_at,
+            CASE WHEN d.status = 'delivered' THEN d.created_at END
+        FROM webhook_deliveries d
+        JOIN webhook_endpoints w ON w.id = d.webhook_id
+        JOIN events e ON e.id = d.event_id
+        ON CONFLICT (id) DO NOTHING;
+
+        DROP TABLE webhook_deliveries;
+    END IF;
+END $$;
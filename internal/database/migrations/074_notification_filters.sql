@@ -0,0 +1,28 @@
+-- 074_notification_filters.sql: S3 notification key filters and queue,
+-- stream and function destinations.
+--
+-- Each row is one event name of one configuration. config_id groups a
+-- configuration's rows (its Id); filter_prefix and filter_suffix are its
+-- S3Key filter rules. target_type is 'topic', 'queue' or 'cloudfunction'
+-- for the element the configuration came from, and target_url holds the
+-- Topic URL or the ARN of a configured notification target.
+-- Idempotent — safe to re-run on every deploy.
+ALTER TABLE bucket_notifications
+    ADD COLUMN IF NOT EXISTS config_id TEXT NOT NULL DEFAULT '';
+ALTER TABLE bucket_notifications
+    ADD COLUMN IF NOT EXISTS filter_prefix TEXT NOT NULL DEFAULT '';
+ALTER TABLE bucket_notifications
+    ADD COLUMN IF NOT EXISTS filter_suffix TEXT NOT NULL DEFAULT '';
+
+-- Earlier rows were grouped by target URL, under the Id of the first row.
+UPDATE bucket_notifications n
+SET config_id = f.first_id
+FROM (
+    SELECT DISTINCT ON (tenant_id, bucket, target_url) tenant_id, bucket, target_url, id AS first_id
+    FROM bucket_notifications
+    ORDER BY tenant_id, bucket, target_url, created_at, id
+) f
+WHERE n.config_id = ''
+  AND n.tenant_id = f.tenant_id AND n.bucket = f.bucket AND n.target_url = f.target_url;
+
+UPDATE bucket_notifications SET target_type = 'topic' WHERE target_type = 'webhook';
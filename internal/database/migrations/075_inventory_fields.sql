@@ -0,0 +1,11 @@
+-- 075_inventory_fields.sql: S3 Inventory configuration id, object versions
+-- and optional fields.
+--
+-- inventory_included_versions is 'Current' or 'All'; with 'All' reports
+-- list every version with VersionId, IsLatest and IsDeleteMarker columns.
+-- inventory_optional_fields holds the OptionalFields values, whose columns
+-- follow Bucket and Key.
+-- Idempotent — safe to re-run on every deploy.
+ALTER TABLE buckets ADD COLUMN IF NOT EXISTS inventory_id TEXT NOT NULL DEFAULT '';
+ALTER TABLE buckets ADD COLUMN IF NOT EXISTS inventory_included_versions TEXT NOT NULL DEFAULT 'Current';
+ALTER TABLE buckets ADD COLUMN IF NOT EXISTS inventory_optional_fields TEXT[] NOT NULL DEFAULT '{}';
@@ -0,0 +1,33 @@
+-- 076_web_identity.sql: AssumeRoleWithWebIdentity.
+--
+-- oidc_providers are the OpenID Connect issuers a tenant trusts; a token is
+-- accepted only when addressed to one of the provider's audiences.
+-- sts_roles map verified token claims to scoped STS credentials: every
+-- claim named in conditions must match one of its patterns (* and ?
+-- wildcards), and a role's sessions carry its permissions and bucket scope.
+-- sts_tokens.session_token binds web-identity sessions to the
+-- X-Amz-Security-Token their requests must present.
+-- Idempotent — safe to re-run on every deploy.
+CREATE TABLE IF NOT EXISTS oidc_providers (
+    id          TEXT PRIMARY KEY,
+    tenant_id   TEXT NOT NULL,
+    issuer_url  TEXT NOT NULL,
+    audiences   TEXT[] NOT NULL,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (tenant_id, issuer_url)
+);
+
+CREATE TABLE IF NOT EXISTS sts_roles (
+    id                   TEXT PRIMARY KEY,
+    tenant_id            TEXT NOT NULL,
+    name                 TEXT NOT NULL,
+    provider_id          TEXT NOT NULL REFERENCES oidc_providers(id) ON DELETE CASCADE,
+    conditions           JSONB NOT NULL DEFAULT '{}',
+    permissions          JSONB NOT NULL DEFAULT '["*"]',
+    bucket_scope         TEXT[] NOT NULL DEFAULT '{}',
+    max_session_seconds  INT NOT NULL DEFAULT 3600,
+    created_at           TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (tenant_id, name)
+);
+
+ALTER TABLE sts_tokens ADD COLUMN IF NOT EXISTS session_token TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,38 @@
+-- 077_oidc_connections.sql: dashboard sign-in through a tenant's own
+-- OpenID Connect provider (Okta, Keycloak, Entra ID, Authentik, ...).
+--
+-- oidc_connections hold a tenant's relying-party registration: the issuer
+-- is discovered through /.well-known/openid-configuration, users choose
+-- the connection by tenant slug or by an email address in email_domains,
+-- and claims map them to rbac roles through group_roles / default_role.
+-- tenant_members are users provisioned by a connection: they own no
+-- tenant and work in the connection's.
+-- Idempotent — safe to re-run on every deploy.
+CREATE TABLE IF NOT EXISTS oidc_connections (
+    id                      TEXT PRIMARY KEY,
+    tenant_id               TEXT NOT NULL,
+    name                    TEXT NOT NULL,
+    vendor                  TEXT NOT NULL DEFAULT 'generic',
+    issuer_url              TEXT NOT NULL,
+    client_id               TEXT NOT NULL,
+    client_secret           TEXT NOT NULL DEFAULT '',
+    scopes                  TEXT[] NOT NULL DEFAULT '{}',
+    email_domains           TEXT[] NOT NULL DEFAULT '{}',
+    claims                  JSONB NOT NULL DEFAULT '{}',
+    group_roles             JSONB NOT NULL DEFAULT '{}',
+    default_role            TEXT NOT NULL DEFAULT '',
+    link_existing_accounts  BOOLEAN NOT NULL DEFAULT FALSE,
+    enabled                 BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at              TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (tenant_id, issuer_url, client_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_oidc_connections_domains ON oidc_connections USING GIN (email_domains);
+
+CREATE TABLE IF NOT EXISTS tenant_members (
+    tenant_id   VARCHAR(255) NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    user_id     UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (tenant_id, user_id),
+    UNIQUE (user_id)
+);
@@ -0,0 +1,56 @@
+-- 078_scim.sql: SCIM 2.0 provisioning of tenant members and groups.
+--
+-- scim_tokens are the bearer tokens a tenant's IdP authenticates with;
+-- only their SHA-256 is stored. scim_configs map a tenant's SCIM group
+-- names to rbac roles. tenant_members gain the SCIM User attributes, and
+-- active = FALSE marks a deprovisioned member. scim_groups and
+-- scim_group_members hold the groups the IdP pushes.
+-- Idempotent — safe to re-run on every deploy.
+CREATE TABLE IF NOT EXISTS scim_tokens (
+    id            TEXT PRIMARY KEY,
+    tenant_id     VARCHAR(255) NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    name          TEXT NOT NULL,
+    token_hash    TEXT NOT NULL UNIQUE,
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_used_at  TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_scim_tokens_tenant ON scim_tokens(tenant_id);
+
+CREATE TABLE IF NOT EXISTS scim_configs (
+    tenant_id     VARCHAR(255) PRIMARY KEY REFERENCES tenants(id) ON DELETE CASCADE,
+    group_roles   JSONB NOT NULL DEFAULT '{}',
+    default_role  TEXT NOT NULL DEFAULT 'user',
+    updated_at    TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+ALTER TABLE tenant_members ADD COLUMN IF NOT EXISTS user_name    TEXT;
+ALTER TABLE tenant_members ADD COLUMN IF NOT EXISTS external_id  TEXT NOT NULL DEFAULT '';
+ALTER TABLE tenant_members ADD COLUMN IF NOT EXISTS given_name   TEXT NOT NULL DEFAULT '';
+ALTER TABLE tenant_members ADD COLUMN IF NOT EXISTS family_name  TEXT NOT NULL DEFAULT '';
+ALTER TABLE tenant_members ADD COLUMN IF NOT EXISTS display_name TEXT NOT NULL DEFAULT '';
+ALTER TABLE tenant_members ADD COLUMN IF NOT EXISTS active       BOOLEAN NOT NULL DEFAULT TRUE;
+ALTER TABLE tenant_members ADD COLUMN IF NOT EXISTS scim_managed BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE tenant_members ADD COLUMN IF NOT EXISTS updated_at   TIMESTAMPTZ NOT NULL DEFAULT NOW();
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_tenant_members_user_name
+    ON tenant_members (tenant_id, LOWER(user_name)) WHERE user_name IS NOT NULL;
+
+CREATE TABLE IF NOT EXISTS scim_groups (
+    id            TEXT PRIMARY KEY,
+    tenant_id     VARCHAR(255) NOT NULL REFERENCES tenants(id) ON DELETE CASCADE,
+    display_name  TEXT NOT NULL,
+    external_id   TEXT NOT NULL DEFAULT '',
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at    TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_scim_groups_name ON scim_groups (tenant_id, LOWER(display_name));
+
+CREATE TABLE IF NOT EXISTS scim_group_members (
+    group_id  TEXT NOT NULL REFERENCES scim_groups(id) ON DELETE CASCADE,
+    user_id   UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    PRIMARY KEY (group_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_scim_group_members_user ON scim_group_members(user_id);
@@ -0,0 +1,29 @@
+-- 079_oidc_email_domains.sql: one OIDC connection per email domain.
+--
+-- Sign-in routes an email address to the connection naming its domain, so
+-- a domain named by two tenants let either send the other's users to its
+-- own IdP. oidc_email_domains claims each domain for exactly one
+-- connection; a connection's email_domains are the domains it claims.
+-- Existing domains go to their oldest connection and are dropped from the
+-- others.
+-- Idempotent — safe to re-run on every deploy.
+CREATE TABLE IF NOT EXISTS oidc_email_domains (
+    domain         TEXT PRIMARY KEY,
+    tenant_id      TEXT NOT NULL,
+    connection_id  TEXT NOT NULL REFERENCES oidc_connections(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_oidc_email_domains_connection ON oidc_email_domains(connection_id);
+
+INSERT INTO oidc_email_domains (domain, tenant_id, connection_id)
+SELECT DISTINCT ON (d.domain) d.domain, c.tenant_id, c.id
+FROM oidc_connections c, unnest(c.email_domains) AS d(domain)
+ORDER BY d.domain, c.created_at, c.id
+ON CONFLICT (domain) DO NOTHING;
+
+UPDATE oidc_connections c SET email_domains = ARRAY(
+    SELECT d FROM unnest(c.email_domains) AS d
+    WHERE EXISTS (SELECT 1 FROM oidc_email_domains e WHERE e.domain = d AND e.connection_id = c.id))
+WHERE EXISTS (
+    SELECT 1 FROM unnest(c.email_domains) AS d
+    WHERE NOT EXISTS (SELECT 1 FROM oidc_email_domains e WHERE e.domain = d AND e.connection_id = c.id));
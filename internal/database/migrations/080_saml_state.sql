@@ -0,0 +1,21 @@
+-- 080_saml_state.sql: SAML sign-in state shared by every replica.
+--
+-- saml_requests are the AuthnRequest IDs the SP has issued and not yet
+-- seen answered; saml_assertions are the assertion IDs it has accepted,
+-- kept until they expire so a replayed response is refused. A response
+-- may reach any replica, so neither can live in process memory. Expired
+-- rows are swept by the ACS.
+-- Idempotent — safe to re-run on every deploy.
+CREATE TABLE IF NOT EXISTS saml_requests (
+    id          TEXT PRIMARY KEY,
+    expires_at  TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_saml_requests_expires ON saml_requests(expires_at);
+
+CREATE TABLE IF NOT EXISTS saml_assertions (
+    id          TEXT PRIMARY KEY,
+    expires_at  TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_saml_assertions_expires ON saml_assertions(expires_at);
@@ -0,0 +1,28 @@
+-- 081_multipart_part_chunks_no_cascade.sql: part chunk rows no longer
+-- cascade with their upload.
+--
+-- Each multipart_part_chunks row holds a global_content_index reference
+-- (064). A cascade deletes the row without dropping that reference, so
+-- an upload deleted with chunk rows left leaked the chunks from dedup GC
+-- forever. Without the cascade the delete fails instead: the rows must go
+-- through releasePartChunks, which drops each reference with its row.
+-- Idempotent — safe to re-run on every deploy.
+DO $$
+BEGIN
+    IF EXISTS (
+        SELECT 1 FROM pg_constraint
+        WHERE conname = 'multipart_part_chunks_upload_id_fkey'
+          AND conrelid = 'multipart_part_chunks'::regclass
+          AND confdeltype = 'c'
+    ) THEN
+        ALTER TABLE multipart_part_chunks DROP CONSTRAINT multipart_part_chunks_upload_id_fkey;
+    END IF;
+    IF NOT EXISTS (
+        SELECT 1 FROM pg_constraint
+        WHERE conname = 'multipart_part_chunks_upload_id_fkey'
+          AND conrelid = 'multipart_part_chunks'::regclass
+    ) THEN
+        ALTER TABLE multipart_part_chunks ADD CONSTRAINT multipart_part_chunks_upload_id_fkey
+            FOREIGN KEY (upload_id) REFERENCES multipart_uploads(upload_id);
+    END IF;
+END $$;
@@ -324,6 +324,32 @@ func generatePaths() map[string]*PathItem {
 					},
 				},
 			},
+			Post: &Operation{
+				Tags:        []string{"Objects"},
+				Summary:     "Upload object from a browser form",
+				Description: "Uploads the form's file field to the key named in the form, authorized by a signed POST policy (see /api/v1/presigned/post)",
+				OperationID: "PostObject",
+				RequestBody: &RequestBody{
+					Description: "Form fields (key, policy, x-amz-algorithm, x-amz-credential, x-amz-date, x-amz-signature, ...) followed by the file",
+					Required:    true,
+					Content: map[string]MediaType{
+						"multipart/form-data": {
+							Schema: &Schema{Type: "object"},
+						},
+					},
+				},
+				Responses: map[string]Response{
+					"204": {
+						Description: "Object uploaded (default success_action_status)",
+					},
+					"303": {
+						Description: "Object uploaded; redirecting to success_action_redirect",
+					},
+					"403": {
+						Description: "Signature or policy condition check failed",
+					},
+				},
+			},
 			Delete: &Operation{
 				Tags:        []string{"Buckets"},
 				Summary:     "Delete bucket",
@@ -343,6 +369,46 @@ func generatePaths() map[string]*PathItem {
 			},
 		},
 
+		"/api/v1/presigned/post": {
+			Get: &Operation{
+				Tags:        []string{"Auth"},
+				Summary:     "Generate a browser upload policy",
+				Description: "Returns the form action URL and signed POST policy fields for uploading straight from a browser",
+				OperationID: "GetPresignedPost",
+				Parameters: []Parameter{
+					{Name: "bucket", In: "query", Required: true, Schema: &Schema{Type: "string"}},
+					{Name: "key", In: "query", Description: "Exact object key", Schema: &Schema{Type: "string"}},
+					{Name: "key_prefix", In: "query", Description: "Allow any key under this prefix; the form key defaults to prefix + ${filename}", Schema: &Schema{Type: "string"}},
+					{Name: "expires", In: "query", Description: "Policy lifetime in seconds (default 3600, max 604800)", Schema: &Schema{Type: "integer"}},
+					{Name: "min_size", In: "query", Description: "Smallest accepted upload in bytes", Schema: &Schema{Type: "integer"}},
+					{Name: "max_size", In: "query", Description: "Largest accepted upload in bytes", Schema: &Schema{Type: "integer"}},
+					{Name: "content_type", In: "query", Description: "Required Content-Type of the upload", Schema: &Schema{Type: "string"}},
+					{Name: "success_action_status", In: "query", Description: "200, 201 or 204", Schema: &Schema{Type: "string"}},
+					{Name: "success_action_redirect", In: "query", Description: "URL to redirect the browser to after the upload", Schema: &Schema{Type: "string"}},
+				},
+				Responses: map[string]Response{
+					"200": {
+						Description: "Form action URL, fields and expiry",
+						Content: map[string]MediaType{
+							"application/json": {
+								Schema: &Schema{
+									Type: "object",
+									Properties: map[string]*Schema{
+										"url":        {Type: "string"},
+										"fields":     {Type: "object"},
+										"expires_at": {Type: "string", Format: "date-time"},
+									},
+								},
+							},
+						},
+					},
+					"400": {
+						Description: "Invalid parameters",
+					},
+				},
+			},
+		},
+
 		"/{bucket}/{key}": {
 			Get: &Operation{
 				Tags:        []string{"Objects"},
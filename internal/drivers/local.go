@@ -708,6 +708,10 @@ func (d *LocalDriver) AtomicWrite(ctx context.Context, container, artifact strin
 	return nil
 }
 
+// Compile-time check: LocalDriver renames in place, so a staged conditional
+// write is promoted without copying its bytes.
+var _ engine.Renamer = (*LocalDriver)(nil)
+
 // AtomicRename performs an atomic rename operation
 func (d *LocalDriver) AtomicRename(ctx context.Context, container, oldName, newName string) error {
 	oldPath := filepath.Join(d.basePath, container, oldName)
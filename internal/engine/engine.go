@@ -293,7 +293,7 @@ func (e *CoreEngine) GetRange(ctx context.Context, container, artifact string, o
 	candidates := e.buildCandidateList(preferredBackend)
 
 	var reader io.ReadCloser
-	_, err := e.failover.Execute(ctx, candidates, func(driverName string) error {
+	usedBackend, err := e.failover.Execute(ctx, candidates, func(driverName string) error {
 		d, ok := e.drivers[driverName]
 		if !ok {
 			return fmt.Errorf("driver %s not found", driverName)
@@ -320,6 +320,7 @@ func (e *CoreEngine) GetRange(ctx context.Context, container, artifact string, o
 	if err != nil {
 		return nil, fmt.Errorf("get range %s/%s [%d-%d]: %w", container, artifact, offset, offset+length-1, err)
 	}
+	common.SetBackendUsed(ctx, usedBackend)
 	return reader, nil
 }
 
@@ -798,6 +799,57 @@ func (e *CoreEngine) HintBackend(container, artifact, backend string) {
 	}
 }
 
+// Rename moves an artifact to a new name on the backend that holds it,
+// replacing whatever the new name held. Drivers implementing Renamer move
+// it in place; others get a copy followed by a delete of the old name, with
+// opts passed to the copy's Put.
+func (e *CoreEngine) Rename(ctx context.Context, backend, container, oldName, newName string, opts ...PutOption) error {
+	d, ok := e.GetDriver(backend)
+	if !ok {
+		return fmt.Errorf("driver %s not found", backend)
+	}
+	if rn, ok := d.(Renamer); ok {
+		if err := rn.AtomicRename(ctx, container, oldName, newName); err != nil {
+			return fmt.Errorf("rename %s/%s: %w", container, oldName, err)
+		}
+	} else {
+		rc, err := d.Get(ctx, container, oldName)
+		if err != nil {
+			return fmt.Errorf("rename %s/%s: %w", container, oldName, err)
+		}
+		err = d.Put(ctx, container, newName, rc, opts...)
+		_ = rc.Close()
+		if err != nil {
+			return fmt.Errorf("rename %s/%s: %w", container, oldName, err)
+		}
+		if err := d.Delete(ctx, container, oldName); err != nil {
+			e.logger.Warn("rename left the old artifact behind",
+				zap.String("container", container),
+				zap.String("artifact", oldName),
+				zap.Error(err))
+		}
+	}
+
+	tenantID := common.GetTenantID(ctx)
+	e.objectBackends.Delete(objectKey(container, oldName))
+	e.objectBackends.Store(objectKey(container, newName), backend)
+	if e.locations != nil {
+		options := ApplyPutOptions(opts...)
+		resolvedClass := options.StorageClass
+		if resolvedClass == "" {
+			resolvedClass = "STANDARD"
+		}
+		go func() { // #nosec G118 -- fire-and-forget location record, as in Put
+			_ = e.locations.RemoveLocation(context.Background(), tenantID, container, oldName)
+			_ = e.locations.RecordLocation(context.Background(), tenantID, container, newName, backend, resolvedClass, options.ContentLength)
+		}()
+	}
+	if e.cache != nil {
+		_ = e.cache.Delete(fmt.Sprintf("%s/%s/%s", tenantID, container, newName))
+	}
+	return nil
+}
+
 // GetFailoverStatus returns circuit breaker states for all backends.
 func (e *CoreEngine) GetFailoverStatus() map[string]string {
 	return e.failover.GetAllStatuses()
@@ -811,6 +863,12 @@ func (e *CoreEngine) StartTiering(ctx context.Context) {
 	}
 }
 
+// Tiering returns the engine's tiering engine, used by bucket lifecycle
+// transitions to move objects between backends.
+func (e *CoreEngine) Tiering() *TieringEngine {
+	return e.tiering
+}
+
 // Shutdown gracefully shuts down the engine
 func (e *CoreEngine) Shutdown(ctx context.Context) error {
 	e.logger.Info("shutting down engine")
@@ -4,8 +4,13 @@ import (
 	"bytes"
 	"context"
 	"io"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
 )
 
@@ -105,3 +110,48 @@ func TestHintBackend(t *testing.T) {
 		t.Errorf("expected %q, got %q", "hello", string(b))
 	}
 }
+
+// renamingDriver is a fileWritingDriver that also implements Renamer.
+type renamingDriver struct {
+	fileWritingDriver
+	renames int
+}
+
+func (d *renamingDriver) AtomicRename(_ context.Context, container, oldName, newName string) error {
+	d.renames++
+	return os.Rename(filepath.Join(d.dir, container, oldName), filepath.Join(d.dir, container, newName))
+}
+
+func TestRename(t *testing.T) {
+	ctx := context.Background()
+	for _, tc := range []struct {
+		name   string
+		driver Driver
+	}{
+		{"copy fallback", &fileWritingDriver{dir: t.TempDir()}},
+		{"renamer", &renamingDriver{fileWritingDriver: fileWritingDriver{dir: t.TempDir()}}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			eng := NewEngine(nil, zap.NewNop(), nil)
+			eng.AddDriver("remote", tc.driver)
+
+			require.NoError(t, tc.driver.Put(ctx, "bucket", "staged", strings.NewReader("new")))
+			require.NoError(t, tc.driver.Put(ctx, "bucket", "key", strings.NewReader("old")))
+			require.NoError(t, eng.Rename(ctx, "remote", "bucket", "staged", "key"))
+
+			exists, _ := tc.driver.Exists(ctx, "bucket", "staged")
+			assert.False(t, exists, "the old name must be gone")
+			rc, err := eng.Get(ctx, "bucket", "key")
+			require.NoError(t, err)
+			defer func() { _ = rc.Close() }()
+			b, _ := io.ReadAll(rc)
+			assert.Equal(t, "new", string(b))
+			if rn, ok := tc.driver.(*renamingDriver); ok {
+				assert.Equal(t, 1, rn.renames)
+			}
+		})
+	}
+
+	eng := NewEngine(nil, zap.NewNop(), nil)
+	assert.Error(t, eng.Rename(ctx, "missing", "bucket", "staged", "key"))
+}
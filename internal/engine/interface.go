@@ -53,6 +53,14 @@ type RangeGetter interface {
 	GetRange(ctx context.Context, container, artifact string, offset, length int64) (io.ReadCloser, error)
 }
 
+// Renamer is an optional interface for drivers that can move an artifact
+// to a new name within a container without copying its bytes. Conditional
+// writes stage their body under a private name and rename it onto the key
+// only once the precondition holds.
+type Renamer interface {
+	AtomicRename(ctx context.Context, container, oldName, newName string) error
+}
+
 // Restorer is an optional interface for archive-class drivers (Geyser tape)
 // whose objects can be evicted to cold storage and need an explicit recall
 // before Get succeeds (V18.2 minimum recall slice). Wire semantics mirror
@@ -57,6 +57,15 @@ func ResolveStorageClass(class string, primaryBackend string, availableDrivers m
 	return primaryBackend, canonical
 }
 
+// StorageClassBackend returns the backend driver an S3 storage class routes
+// to, and false for classes we do not offer (STANDARD_IA, INTELLIGENT_TIERING,
+// ...). Unlike ResolveStorageClass it does not fall back to the primary, so
+// callers can reject an unknown class instead of silently storing STANDARD.
+func StorageClassBackend(class string) (string, bool) {
+	backend, ok := storageClassToBackend[class]
+	return backend, ok
+}
+
 func BackendToStorageClass(backendName string) string {
 	if class, ok := backendToStorageClass[backendName]; ok {
 		return class
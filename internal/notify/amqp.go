@@ -0,0 +1,345 @@
+// internal/notify/amqp.go
+package notify
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// AMQP 0-9-1 frame types, and the class and method IDs the target uses.
+const (
+	amqpFrameMethod    = 1
+	amqpFrameHeader    = 2
+	amqpFrameBody      = 3
+	amqpFrameHeartbeat = 8
+	amqpFrameEnd       = 0xCE
+
+	amqpConnectionStart   = 10<<16 | 10
+	amqpConnectionStartOk = 10<<16 | 11
+	amqpConnectionTune    = 10<<16 | 30
+	amqpConnectionTuneOk  = 10<<16 | 31
+	amqpConnectionOpen    = 10<<16 | 40
+	amqpConnectionOpenOk  = 10<<16 | 41
+	amqpConnectionClose   = 10<<16 | 50
+	amqpChannelOpen       = 20<<16 | 10
+	amqpChannelOpenOk     = 20<<16 | 11
+	amqpChannelClose      = 20<<16 | 40
+	amqpBasicPublish      = 60<<16 | 40
+	amqpBasicAck          = 60<<16 | 80
+	amqpBasicNack         = 60<<16 | 120
+	amqpConfirmSelect     = 85<<16 | 10
+	amqpConfirmSelectOk   = 85<<16 | 11
+
+	amqpBasicClass      = 60
+	amqpDefaultFrameMax = 131072
+	amqpMaxFrameBytes   = 1 << 20
+)
+
+var amqpProtocolHeader = []byte("AMQP\x00\x00\x09\x01")
+
+// AMQPTarget publishes events to an AMQP 0-9-1 exchange (RabbitMQ and
+// compatible brokers) as persistent messages. The channel is in confirm
+// mode, so Publish returns only once the broker has acked the message.
+type AMQPTarget struct {
+	addr       string
+	tls        *tls.Config
+	user       string
+	pass       string
+	vhost      string
+	exchange   string
+	routingKey string
+
+	mu       sync.Mutex
+	conn     net.Conn
+	r        *bufio.Reader
+	frameMax uint32
+}
+
+func newAMQPTarget(u *url.URL) (*AMQPTarget, error) {
+	if u.Hostname() == "" {
+		return nil, errors.New("amqp target: missing host")
+	}
+	q := u.Query()
+	t := &AMQPTarget{
+		user:       "guest",
+		pass:       "guest",
+		vhost:      "/",
+		exchange:   q.Get("exchange"),
+		routingKey: q.Get("routing_key"),
+	}
+	if t.exchange == "" && t.routingKey == "" {
+		return nil, errors.New("amqp target: need an exchange or a routing_key")
+	}
+	port := u.Port()
+	if u.Scheme == "amqps" {
+		t.tls = &tls.Config{ServerName: u.Hostname(), MinVersion: tls.VersionTLS12}
+		if port == "" {
+			port = "5671"
+		}
+	} else if port == "" {
+		port = "5672"
+	}
+	t.addr = net.JoinHostPort(u.Hostname(), port)
+	if u.User != nil {
+		t.user = u.User.Username()
+		t.pass, _ = u.User.Password()
+	}
+	// As in other AMQP URLs, the path names the vhost; "%2f" is "/".
+	if path := strings.TrimPrefix(u.Path, "/"); path != "" {
+		t.vhost = path
+	}
+	return t, nil
+}
+
+// Publish publishes the event and waits for the broker's confirm.
+func (t *AMQPTarget) Publish(ctx context.Context, key string, payload []byte) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if err := t.publish(ctx, payload); err != nil {
+		t.reset()
+		return fmt.Errorf("amqp publish %s/%s: %w", t.exchange, t.routingKey, err)
+	}
+	return nil
+}
+
+func (t *AMQPTarget) publish(ctx context.Context, payload []byte) error {
+	if t.conn == nil {
+		if err := t.connect(ctx); err != nil {
+			return err
+		}
+	}
+	if err := t.conn.SetDeadline(deadline(ctx)); err != nil {
+		return err
+	}
+
+	var m amqpWriter
+	m.uint16(0) // reserved
+	m.shortstr(t.exchange)
+	m.shortstr(t.routingKey)
+	m.b = append(m.b, 0) // mandatory, immediate
+	if err := t.writeMethod(1, amqpBasicPublish, m.b); err != nil {
+		return err
+	}
+
+	var h amqpWriter
+	h.uint16(amqpBasicClass)
+	h.uint16(0) // weight
+	h.b = binary.BigEndian.AppendUint64(h.b, uint64(len(payload)))
+	h.uint16(1<<15 | 1<<12) // content-type, delivery-mode
+	h.shortstr("application/json")
+	h.b = append(h.b, 2) // persistent
+	if err := t.writeFrame(amqpFrameHeader, 1, h.b); err != nil {
+		return err
+	}
+	chunk := int(t.frameMax) - 8
+	for len(payload) > 0 {
+		n := min(chunk, len(payload))
+		if err := t.writeFrame(amqpFrameBody, 1, payload[:n]); err != nil {
+			return err
+		}
+		payload = payload[n:]
+	}
+
+	method, _, err := t.readMethod()
+	if err != nil {
+		return err
+	}
+	switch method {
+	case amqpBasicAck:
+		return nil
+	case amqpBasicNack:
+		return errors.New("broker nacked the message")
+	}
+	return fmt.Errorf("unexpected method %d.%d", method>>16, method&0xFFFF)
+}
+
+func (t *AMQPTarget) connect(ctx context.Context) error {
+	d := net.Dialer{}
+	var conn net.Conn
+	var err error
+	if t.tls != nil {
+		conn, err = (&tls.Dialer{NetDialer: &d, Config: t.tls}).DialContext(ctx, "tcp", t.addr)
+	} else {
+		conn, err = d.DialContext(ctx, "tcp", t.addr)
+	}
+	if err != nil {
+		return err
+	}
+	t.conn, t.r, t.frameMax = conn, bufio.NewReader(conn), amqpDefaultFrameMax
+	if err := conn.SetDeadline(deadline(ctx)); err != nil {
+		return err
+	}
+	if _, err := conn.Write(amqpProtocolHeader); err != nil {
+		return err
+	}
+
+	if err := t.expect(amqpConnectionStart); err != nil {
+		return err
+	}
+	var startOk amqpWriter
+	startOk.uint32(0) // client-properties: empty table
+	startOk.shortstr("PLAIN")
+	startOk.longstr("\x00" + t.user + "\x00" + t.pass)
+	startOk.shortstr("en_US")
+	if err := t.writeMethod(0, amqpConnectionStartOk, startOk.b); err != nil {
+		return err
+	}
+
+	method, args, err := t.readMethod()
+	if err != nil {
+		return err
+	}
+	if method != amqpConnectionTune || len(args) < 8 {
+		return fmt.Errorf("expected connection.tune, got %d.%d", method>>16, method&0xFFFF)
+	}
+	channelMax := binary.BigEndian.Uint16(args)
+	if fm := binary.BigEndian.Uint32(args[2:]); fm != 0 && fm < t.frameMax {
+		t.frameMax = fm
+	}
+	var tuneOk amqpWriter
+	tuneOk.uint16(channelMax)
+	tuneOk.uint32(t.frameMax)
+	tuneOk.uint16(0) // no heartbeats: a dead idle connection fails the next publish, which is retried
+	if err := t.writeMethod(0, amqpConnectionTuneOk, tuneOk.b); err != nil {
+		return err
+	}
+
+	var open amqpWriter
+	open.shortstr(t.vhost)
+	open.shortstr("")          // reserved
+	open.b = append(open.b, 0) // reserved
+	if err := t.writeMethod(0, amqpConnectionOpen, open.b); err != nil {
+		return err
+	}
+	if err := t.expect(amqpConnectionOpenOk); err != nil {
+		return err
+	}
+	if err := t.writeMethod(1, amqpChannelOpen, []byte{0}); err != nil {
+		return err
+	}
+	if err := t.expect(amqpChannelOpenOk); err != nil {
+		return err
+	}
+	if err := t.writeMethod(1, amqpConfirmSelect, []byte{0}); err != nil {
+		return err
+	}
+	return t.expect(amqpConfirmSelectOk)
+}
+
+func (t *AMQPTarget) expect(want uint32) error {
+	method, _, err := t.readMethod()
+	if err != nil {
+		return err
+	}
+	if method != want {
+		return fmt.Errorf("expected method %d.%d, got %d.%d", want>>16, want&0xFFFF, method>>16, method&0xFFFF)
+	}
+	return nil
+}
+
+// readMethod returns the next method frame, skipping heartbeats and turning
+// connection.close and channel.close into errors.
+func (t *AMQPTarget) readMethod() (uint32, []byte, error) {
+	for {
+		typ, payload, err := t.readFrame()
+		if err != nil {
+			return 0, nil, err
+		}
+		if typ == amqpFrameHeartbeat {
+			continue
+		}
+		if typ != amqpFrameMethod || len(payload) < 4 {
+			return 0, nil, fmt.Errorf("unexpected frame type %d", typ)
+		}
+		method := binary.BigEndian.Uint32(payload)
+		args := payload[4:]
+		if method == amqpConnectionClose || method == amqpChannelClose {
+			return 0, nil, amqpCloseError(args)
+		}
+		return method, args, nil
+	}
+}
+
+// amqpCloseError reads the reply code and text of a close method.
+func amqpCloseError(args []byte) error {
+	if len(args) < 3 || len(args) < 3+int(args[2]) {
+		return errors.New("closed by broker")
+	}
+	return fmt.Errorf("closed by broker: %d %s", binary.BigEndian.Uint16(args), args[3:3+int(args[2])])
+}
+
+func (t *AMQPTarget) readFrame() (byte, []byte, error) {
+	var hdr [7]byte
+	if _, err := io.ReadFull(t.r, hdr[:]); err != nil {
+		return 0, nil, err
+	}
+	size := binary.BigEndian.Uint32(hdr[3:])
+	if size > amqpMaxFrameBytes {
+		return 0, nil, fmt.Errorf("frame of %d bytes too large", size)
+	}
+	buf := make([]byte, size+1)
+	if _, err := io.ReadFull(t.r, buf); err != nil {
+		return 0, nil, err
+	}
+	if buf[size] != amqpFrameEnd {
+		return 0, nil, errors.New("malformed frame")
+	}
+	return hdr[0], buf[:size], nil
+}
+
+func (t *AMQPTarget) writeMethod(channel uint16, method uint32, args []byte) error {
+	payload := binary.BigEndian.AppendUint32(nil, method)
+	return t.writeFrame(amqpFrameMethod, channel, append(payload, args...))
+}
+
+func (t *AMQPTarget) writeFrame(typ byte, channel uint16, payload []byte) error {
+	frame := make([]byte, 0, 8+len(payload))
+	frame = append(frame, typ)
+	frame = binary.BigEndian.AppendUint16(frame, channel)
+	frame = binary.BigEndian.AppendUint32(frame, uint32(len(payload)))
+	frame = append(frame, payload...)
+	frame = append(frame, amqpFrameEnd)
+	_, err := t.conn.Write(frame)
+	return err
+}
+
+func (t *AMQPTarget) reset() {
+	if t.conn != nil {
+		_ = t.conn.Close()
+	}
+	t.conn, t.r = nil, nil
+}
+
+// Close closes the connection.
+func (t *AMQPTarget) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.reset()
+	return nil
+}
+
+// amqpWriter appends method argument primitives.
+type amqpWriter struct{ b []byte }
+
+func (w *amqpWriter) uint16(v uint16) { w.b = binary.BigEndian.AppendUint16(w.b, v) }
+func (w *amqpWriter) uint32(v uint32) { w.b = binary.BigEndian.AppendUint32(w.b, v) }
+
+func (w *amqpWriter) shortstr(s string) {
+	w.b = append(w.b, byte(len(s)))
+	w.b = append(w.b, s...)
+}
+
+func (w *amqpWriter) longstr(s string) {
+	w.uint32(uint32(len(s)))
+	w.b = append(w.b, s...)
+}
@@ -0,0 +1,188 @@
+package notify
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeAMQP is a RabbitMQ stand-in: it runs the 0-9-1 handshake, records
+// published messages and confirms them, or closes the channel for
+// publishes to a missing exchange.
+type fakeAMQP struct {
+	t *testing.T
+
+	mu       sync.Mutex
+	auth     []string
+	vhosts   []string
+	messages []amqpMessage
+}
+
+type amqpMessage struct {
+	exchange, routingKey, contentType string
+	deliveryMode                      byte
+	body                              string
+	bodyFrames                        int
+}
+
+func (f *fakeAMQP) serve(conn net.Conn) {
+	r := bufio.NewReader(conn)
+	hdr := make([]byte, 8)
+	if _, err := io.ReadFull(r, hdr); err != nil || string(hdr) != string(amqpProtocolHeader) {
+		return
+	}
+	s := &AMQPTarget{conn: conn, r: r}
+
+	var start amqpWriter
+	start.b = append(start.b, 0, 9)
+	start.uint32(0)
+	start.longstr("PLAIN AMQPLAIN")
+	start.longstr("en_US")
+	_ = s.writeMethod(0, amqpConnectionStart, start.b)
+
+	args := f.expect(s, amqpConnectionStartOk)
+	if args == nil {
+		return
+	}
+	args = args[4+binary.BigEndian.Uint32(args):] // client-properties
+	mechanism := string(args[1 : 1+args[0]])
+	args = args[1+args[0]:]
+	response := string(args[4 : 4+binary.BigEndian.Uint32(args)])
+	f.mu.Lock()
+	f.auth = append(f.auth, mechanism+" "+strings.ReplaceAll(response, "\x00", "|"))
+	f.mu.Unlock()
+
+	var tune amqpWriter
+	tune.uint16(2047)
+	tune.uint32(4096) // small, so bodies span frames
+	tune.uint16(60)
+	_ = s.writeMethod(0, amqpConnectionTune, tune.b)
+	if f.expect(s, amqpConnectionTuneOk) == nil {
+		return
+	}
+	args = f.expect(s, amqpConnectionOpen)
+	if args == nil {
+		return
+	}
+	f.mu.Lock()
+	f.vhosts = append(f.vhosts, string(args[1:1+args[0]]))
+	f.mu.Unlock()
+	_ = s.writeMethod(0, amqpConnectionOpenOk, []byte{0})
+	if f.expect(s, amqpChannelOpen) == nil {
+		return
+	}
+	_ = s.writeMethod(1, amqpChannelOpenOk, []byte{0, 0, 0, 0})
+	if f.expect(s, amqpConfirmSelect) == nil {
+		return
+	}
+	_ = s.writeMethod(1, amqpConfirmSelectOk, nil)
+
+	for tag := uint64(1); ; tag++ {
+		args := f.expect(s, amqpBasicPublish)
+		if args == nil {
+			return
+		}
+		args = args[2:]
+		var msg amqpMessage
+		msg.exchange = string(args[1 : 1+args[0]])
+		args = args[1+args[0]:]
+		msg.routingKey = string(args[1 : 1+args[0]])
+
+		typ, header, err := s.readFrame()
+		require.NoError(f.t, err)
+		require.Equal(f.t, byte(amqpFrameHeader), typ)
+		size := binary.BigEndian.Uint64(header[4:])
+		assert.Equal(f.t, uint16(1<<15|1<<12), binary.BigEndian.Uint16(header[12:]))
+		props := header[14:]
+		msg.contentType = string(props[1 : 1+props[0]])
+		msg.deliveryMode = props[1+props[0]]
+		for uint64(len(msg.body)) < size {
+			typ, body, err := s.readFrame()
+			require.NoError(f.t, err)
+			require.Equal(f.t, byte(amqpFrameBody), typ)
+			assert.LessOrEqual(f.t, len(body)+8, 4096)
+			msg.body += string(body)
+			msg.bodyFrames++
+		}
+
+		if msg.exchange == "missing" {
+			var closing amqpWriter
+			closing.uint16(404)
+			closing.shortstr("NOT_FOUND - no exchange 'missing' in vhost '/'")
+			closing.uint16(60)
+			closing.uint16(40)
+			_ = s.writeMethod(1, amqpChannelClose, closing.b)
+			return
+		}
+		f.mu.Lock()
+		f.messages = append(f.messages, msg)
+		f.mu.Unlock()
+		_ = s.writeFrame(amqpFrameHeartbeat, 0, nil)
+		_ = s.writeMethod(1, amqpBasicAck, append(binary.BigEndian.AppendUint64(nil, tag), 0))
+	}
+}
+
+func (f *fakeAMQP) expect(s *AMQPTarget, want uint32) []byte {
+	method, args, err := s.readMethod()
+	if err != nil {
+		return nil
+	}
+	if !assert.Equal(f.t, want, method) {
+		return nil
+	}
+	if args == nil {
+		args = []byte{}
+	}
+	return args
+}
+
+func TestAMQPTarget_Publish(t *testing.T) {
+	f := &fakeAMQP{t: t}
+	addr := standIn(t, f.serve)
+
+	target, err := New("amqp://app:secret@"+addr+"/events?exchange=s3&routing_key=objects", nil)
+	require.NoError(t, err)
+	defer func() { _ = target.Close() }()
+
+	ctx := context.Background()
+	big := `{"Records":["` + strings.Repeat("x", 10000) + `"]}`
+	require.NoError(t, target.Publish(ctx, "photos/a.jpg", []byte(`{"Records":[]}`)))
+	require.NoError(t, target.Publish(ctx, "photos/b.jpg", []byte(big)))
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	assert.Equal(t, []string{"PLAIN |app|secret"}, f.auth, "one connection, PLAIN auth")
+	assert.Equal(t, []string{"events"}, f.vhosts)
+	require.Len(t, f.messages, 2)
+	assert.Equal(t, amqpMessage{
+		exchange: "s3", routingKey: "objects", contentType: "application/json",
+		deliveryMode: 2, body: `{"Records":[]}`, bodyFrames: 1,
+	}, f.messages[0])
+	assert.Equal(t, big, f.messages[1].body)
+	assert.Equal(t, 3, f.messages[1].bodyFrames)
+}
+
+func TestAMQPTarget_ChannelClosed(t *testing.T) {
+	f := &fakeAMQP{t: t}
+	addr := standIn(t, f.serve)
+
+	target, err := New("amqp://"+addr+"?exchange=missing", nil)
+	require.NoError(t, err)
+	defer func() { _ = target.Close() }()
+
+	err = target.Publish(context.Background(), "k", []byte(`{}`))
+	assert.ErrorContains(t, err, "closed by broker: 404 NOT_FOUND")
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	assert.Equal(t, []string{"PLAIN |guest|guest"}, f.auth)
+	assert.Equal(t, []string{"/"}, f.vhosts)
+}
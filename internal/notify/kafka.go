@@ -0,0 +1,436 @@
+// internal/notify/kafka.go
+package notify
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"hash/fnv"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Kafka API keys and the versions the target speaks. Metadata v4 and
+// Produce v3 (the first with record batches) are the oldest versions
+// brokers still accept and predate the flexible encodings.
+const (
+	kafkaAPIProduce         = 0
+	kafkaAPIMetadata        = 3
+	kafkaProduceVersion     = 3
+	kafkaMetadataVersion    = 4
+	kafkaAcksAll            = -1
+	kafkaMetadataTTL        = time.Minute
+	kafkaMaxResponseBytes   = 16 << 20
+	kafkaProduceTimeoutMsec = 5000
+)
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// kafkaErrors names the error codes a producer is likely to see.
+var kafkaErrors = map[int16]string{
+	3:  "UNKNOWN_TOPIC_OR_PARTITION",
+	5:  "LEADER_NOT_AVAILABLE",
+	6:  "NOT_LEADER_OR_FOLLOWER",
+	7:  "REQUEST_TIMED_OUT",
+	10: "MESSAGE_TOO_LARGE",
+	19: "NOT_ENOUGH_REPLICAS",
+	20: "NOT_ENOUGH_REPLICAS_AFTER_APPEND",
+	29: "TOPIC_AUTHORIZATION_FAILED",
+}
+
+func kafkaError(code int16) error {
+	if name, ok := kafkaErrors[code]; ok {
+		return fmt.Errorf("kafka error %d (%s)", code, name)
+	}
+	return fmt.Errorf("kafka error %d", code)
+}
+
+// KafkaTarget produces events to a Kafka topic with acks=all, one record
+// per event keyed by object. Records go to the partition the key hashes to
+// unless the URL pins one.
+type KafkaTarget struct {
+	brokers   []string
+	topic     string
+	partition int32 // -1 hashes the key
+
+	mu          sync.Mutex
+	conns       map[string]*kafkaConn
+	leaders     []string // leader address by partition
+	leadersAt   time.Time
+	correlation int32
+}
+
+type kafkaConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func newKafkaTarget(u *url.URL) (*KafkaTarget, error) {
+	t := &KafkaTarget{
+		topic:     strings.Trim(u.Path, "/"),
+		partition: -1,
+		conns:     make(map[string]*kafkaConn),
+	}
+	if t.topic == "" {
+		return nil, errors.New("kafka target: missing topic")
+	}
+	for _, host := range strings.Split(u.Host, ",") {
+		if host == "" {
+			continue
+		}
+		if _, _, err := net.SplitHostPort(host); err != nil {
+			host = net.JoinHostPort(host, "9092")
+		}
+		t.brokers = append(t.brokers, host)
+	}
+	if len(t.brokers) == 0 {
+		return nil, errors.New("kafka target: missing brokers")
+	}
+	if v := u.Query().Get("partition"); v != "" {
+		p, err := strconv.ParseInt(v, 10, 32)
+		if err != nil || p < 0 {
+			return nil, fmt.Errorf("kafka target: invalid partition %q", v)
+		}
+		t.partition = int32(p)
+	}
+	return t, nil
+}
+
+// Publish produces the event and waits for all in-sync replicas to have it.
+func (t *KafkaTarget) Publish(ctx context.Context, key string, payload []byte) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if err := t.produce(ctx, key, payload); err != nil {
+		// Leadership may have moved; look it up again next time.
+		t.leaders = nil
+		return fmt.Errorf("kafka produce %s: %w", t.topic, err)
+	}
+	return nil
+}
+
+func (t *KafkaTarget) produce(ctx context.Context, key string, payload []byte) error {
+	leaders, err := t.metadata(ctx)
+	if err != nil {
+		return err
+	}
+	p := t.partition
+	if p < 0 {
+		h := fnv.New32a()
+		_, _ = h.Write([]byte(key))
+		p = int32(h.Sum32() % uint32(len(leaders)))
+	}
+	if int(p) >= len(leaders) {
+		return fmt.Errorf("partition %d does not exist", p)
+	}
+	if leaders[p] == "" {
+		return kafkaError(5)
+	}
+
+	var w kafkaWriter
+	w.int16(-1) // transactional_id: null
+	w.int16(kafkaAcksAll)
+	w.int32(kafkaProduceTimeoutMsec)
+	w.int32(1)
+	w.string(t.topic)
+	w.int32(1)
+	w.int32(p)
+	batch := recordBatch(key, payload, time.Now())
+	w.int32(int32(len(batch)))
+	w.b = append(w.b, batch...)
+
+	body, err := t.roundTrip(ctx, leaders[p], kafkaAPIProduce, kafkaProduceVersion, w.b)
+	if err != nil {
+		return err
+	}
+	r := kafkaReader{b: body}
+	for i, n := 0, r.int32(); i < int(n) && r.err == nil; i++ {
+		r.string()
+		for j, m := 0, r.int32(); j < int(m) && r.err == nil; j++ {
+			r.int32()
+			code := r.int16()
+			r.int64() // base_offset
+			r.int64() // log_append_time_ms
+			if r.err == nil && code != 0 {
+				return kafkaError(code)
+			}
+		}
+	}
+	return r.err
+}
+
+// metadata returns the topic's partition leaders, cached for
+// kafkaMetadataTTL.
+func (t *KafkaTarget) metadata(ctx context.Context) ([]string, error) {
+	if t.leaders != nil && time.Since(t.leadersAt) < kafkaMetadataTTL {
+		return t.leaders, nil
+	}
+	var w kafkaWriter
+	w.int32(1)
+	w.string(t.topic)
+	w.int8(0) // allow_auto_topic_creation: false
+
+	var lastErr error
+	for _, broker := range t.brokers {
+		body, err := t.roundTrip(ctx, broker, kafkaAPIMetadata, kafkaMetadataVersion, w.b)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		leaders, err := parseKafkaMetadata(body, t.topic)
+		if err != nil {
+			return nil, err
+		}
+		t.leaders, t.leadersAt = leaders, time.Now()
+		return leaders, nil
+	}
+	return nil, lastErr
+}
+
+func parseKafkaMetadata(body []byte, topic string) ([]string, error) {
+	r := kafkaReader{b: body}
+	r.int32() // throttle_time_ms
+	brokers := make(map[int32]string)
+	for i, n := 0, r.int32(); i < int(n) && r.err == nil; i++ {
+		id := r.int32()
+		host := r.string()
+		port := r.int32()
+		r.string() // rack
+		brokers[id] = net.JoinHostPort(host, strconv.Itoa(int(port)))
+	}
+	r.string() // cluster_id
+	r.int32()  // controller_id
+
+	var leaders []string
+	for i, n := 0, r.int32(); i < int(n) && r.err == nil; i++ {
+		code := r.int16()
+		name := r.string()
+		r.int8() // is_internal
+		np := r.int32()
+		if r.err != nil || np < 0 || int(np) > len(r.b) {
+			break
+		}
+		if name == topic && code != 0 {
+			return nil, kafkaError(code)
+		}
+		parts := make([]string, np)
+		for j := 0; j < int(np) && r.err == nil; j++ {
+			r.int16() // partition error_code
+			idx := r.int32()
+			leader := r.int32()
+			for k, m := 0, r.int32(); k < int(m) && r.err == nil; k++ {
+				r.int32() // replica_nodes
+			}
+			for k, m := 0, r.int32(); k < int(m) && r.err == nil; k++ {
+				r.int32() // isr_nodes
+			}
+			if idx >= 0 && idx < np {
+				parts[idx] = brokers[leader]
+			}
+		}
+		if name == topic {
+			leaders = parts
+		}
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	if len(leaders) == 0 {
+		return nil, kafkaError(3)
+	}
+	return leaders, nil
+}
+
+// recordBatch encodes a magic v2 record batch holding one record.
+func recordBatch(key string, value []byte, now time.Time) []byte {
+	var rec []byte
+	rec = append(rec, 0)              // attributes
+	rec = binary.AppendVarint(rec, 0) // timestamp delta
+	rec = binary.AppendVarint(rec, 0) // offset delta
+	rec = binary.AppendVarint(rec, int64(len(key)))
+	rec = append(rec, key...)
+	rec = binary.AppendVarint(rec, int64(len(value)))
+	rec = append(rec, value...)
+	rec = binary.AppendVarint(rec, 1) // headers
+	rec = binary.AppendVarint(rec, int64(len("content-type")))
+	rec = append(rec, "content-type"...)
+	rec = binary.AppendVarint(rec, int64(len("application/json")))
+	rec = append(rec, "application/json"...)
+
+	// Everything the CRC covers: attributes through the records.
+	var w kafkaWriter
+	ts := now.UnixMilli()
+	w.int16(0) // attributes
+	w.int32(0) // last_offset_delta
+	w.int64(ts)
+	w.int64(ts)
+	w.int64(-1) // producer_id
+	w.int16(-1) // producer_epoch
+	w.int32(-1) // base_sequence
+	w.int32(1)  // records
+	w.b = binary.AppendVarint(w.b, int64(len(rec)))
+	w.b = append(w.b, rec...)
+	crc := crc32.Checksum(w.b, castagnoli)
+
+	var batch kafkaWriter
+	batch.int64(0)                           // base_offset
+	batch.int32(int32(4 + 1 + 4 + len(w.b))) // batch_length: from partition_leader_epoch on
+	batch.int32(-1)                          // partition_leader_epoch
+	batch.int8(2)                            // magic
+	batch.b = binary.BigEndian.AppendUint32(batch.b, crc)
+	batch.b = append(batch.b, w.b...)
+	return batch.b
+}
+
+// roundTrip sends one request to addr and returns the response body after
+// the correlation ID.
+func (t *KafkaTarget) roundTrip(ctx context.Context, addr string, apiKey, version int16, body []byte) ([]byte, error) {
+	c, err := t.conn(ctx, addr)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := t.exchange(ctx, c, apiKey, version, body)
+	if err != nil {
+		_ = c.Close()
+		delete(t.conns, addr)
+		return nil, fmt.Errorf("broker %s: %w", addr, err)
+	}
+	return resp, nil
+}
+
+func (t *KafkaTarget) exchange(ctx context.Context, c *kafkaConn, apiKey, version int16, body []byte) ([]byte, error) {
+	if err := c.SetDeadline(deadline(ctx)); err != nil {
+		return nil, err
+	}
+	t.correlation++
+	var w kafkaWriter
+	w.int32(0) // size, filled in below
+	w.int16(apiKey)
+	w.int16(version)
+	w.int32(t.correlation)
+	w.string("vaultaire")
+	w.b = append(w.b, body...)
+	binary.BigEndian.PutUint32(w.b, uint32(len(w.b)-4))
+	if _, err := c.Write(w.b); err != nil {
+		return nil, err
+	}
+
+	var size [4]byte
+	if _, err := io.ReadFull(c.r, size[:]); err != nil {
+		return nil, err
+	}
+	n := binary.BigEndian.Uint32(size[:])
+	if n < 4 || n > kafkaMaxResponseBytes {
+		return nil, fmt.Errorf("invalid response size %d", n)
+	}
+	resp := make([]byte, n)
+	if _, err := io.ReadFull(c.r, resp); err != nil {
+		return nil, err
+	}
+	if got := int32(binary.BigEndian.Uint32(resp)); got != t.correlation {
+		return nil, fmt.Errorf("correlation id %d, want %d", got, t.correlation)
+	}
+	return resp[4:], nil
+}
+
+func (t *KafkaTarget) conn(ctx context.Context, addr string) (*kafkaConn, error) {
+	if c, ok := t.conns[addr]; ok {
+		return c, nil
+	}
+	nc, err := (&net.Dialer{}).DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	c := &kafkaConn{Conn: nc, r: bufio.NewReader(nc)}
+	t.conns[addr] = c
+	return c, nil
+}
+
+// Close closes the broker connections.
+func (t *KafkaTarget) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for addr, c := range t.conns {
+		_ = c.Close()
+		delete(t.conns, addr)
+	}
+	return nil
+}
+
+// kafkaWriter appends big-endian protocol primitives.
+type kafkaWriter struct{ b []byte }
+
+func (w *kafkaWriter) int8(v int8)   { w.b = append(w.b, byte(v)) }
+func (w *kafkaWriter) int16(v int16) { w.b = binary.BigEndian.AppendUint16(w.b, uint16(v)) }
+func (w *kafkaWriter) int32(v int32) { w.b = binary.BigEndian.AppendUint32(w.b, uint32(v)) }
+func (w *kafkaWriter) int64(v int64) { w.b = binary.BigEndian.AppendUint64(w.b, uint64(v)) }
+
+func (w *kafkaWriter) string(s string) {
+	w.int16(int16(len(s)))
+	w.b = append(w.b, s...)
+}
+
+// kafkaReader consumes big-endian protocol primitives; after the first
+// short read every call returns zero and err is set.
+type kafkaReader struct {
+	b   []byte
+	err error
+}
+
+func (r *kafkaReader) take(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if n < 0 || n > len(r.b) {
+		r.err = errors.New("kafka: truncated response")
+		return nil
+	}
+	v := r.b[:n]
+	r.b = r.b[n:]
+	return v
+}
+
+func (r *kafkaReader) int8() int8 {
+	if v := r.take(1); v != nil {
+		return int8(v[0])
+	}
+	return 0
+}
+
+func (r *kafkaReader) int16() int16 {
+	if v := r.take(2); v != nil {
+		return int16(binary.BigEndian.Uint16(v))
+	}
+	return 0
+}
+
+func (r *kafkaReader) int32() int32 {
+	if v := r.take(4); v != nil {
+		return int32(binary.BigEndian.Uint32(v))
+	}
+	return 0
+}
+
+func (r *kafkaReader) int64() int64 {
+	if v := r.take(8); v != nil {
+		return int64(binary.BigEndian.Uint64(v))
+	}
+	return 0
+}
+
+// string reads a (nullable) string; null reads as "".
+func (r *kafkaReader) string() string {
+	n := r.int16()
+	if n < 0 {
+		return ""
+	}
+	return string(r.take(int(n)))
+}
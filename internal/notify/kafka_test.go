@@ -0,0 +1,266 @@
+package notify
+
+import (
+	"context"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeKafka is a single-broker Kafka stand-in serving Metadata v4 and
+// Produce v3 for one topic.
+type fakeKafka struct {
+	t          *testing.T
+	addr       string
+	topic      string
+	partitions int
+
+	mu        sync.Mutex
+	metadata  int
+	produced  map[int32][]kafkaRecord
+	failNext  int16
+	acks      []int16
+	clientIDs []string
+}
+
+type kafkaRecord struct {
+	key, value string
+	headers    map[string]string
+}
+
+func (f *fakeKafka) serve(conn net.Conn) {
+	for {
+		var size [4]byte
+		if _, err := io.ReadFull(conn, size[:]); err != nil {
+			return
+		}
+		req := make([]byte, binary.BigEndian.Uint32(size[:]))
+		if _, err := io.ReadFull(conn, req); err != nil {
+			return
+		}
+		r := kafkaReader{b: req}
+		apiKey, version, correlation := r.int16(), r.int16(), r.int32()
+		clientID := r.string()
+
+		var w kafkaWriter
+		w.int32(0)
+		w.int32(correlation)
+		f.mu.Lock()
+		f.clientIDs = append(f.clientIDs, clientID)
+		switch {
+		case apiKey == kafkaAPIMetadata && version == 4:
+			f.metadata++
+			f.writeMetadata(&w)
+		case apiKey == kafkaAPIProduce && version == 3:
+			f.produce(&r, &w)
+		default:
+			f.mu.Unlock()
+			f.t.Errorf("unexpected request %d v%d", apiKey, version)
+			return
+		}
+		f.mu.Unlock()
+		binary.BigEndian.PutUint32(w.b, uint32(len(w.b)-4))
+		if _, err := conn.Write(w.b); err != nil {
+			return
+		}
+	}
+}
+
+func (f *fakeKafka) writeMetadata(w *kafkaWriter) {
+	host, port, _ := net.SplitHostPort(f.addr)
+	p, _ := strconv.Atoi(port)
+	w.int32(0) // throttle
+	w.int32(1)
+	w.int32(1) // node_id
+	w.string(host)
+	w.int32(int32(p))
+	w.int16(-1) // rack
+	w.int16(-1) // cluster_id
+	w.int32(1)  // controller
+	w.int32(2)
+	// An unrelated topic first, to check it is skipped.
+	w.int16(0)
+	w.string("other")
+	w.int8(0)
+	w.int32(1)
+	w.int16(0)
+	w.int32(0)
+	w.int32(7) // leader on an unknown node
+	w.int32(0)
+	w.int32(0)
+	w.int16(0)
+	w.string(f.topic)
+	w.int8(0)
+	w.int32(int32(f.partitions))
+	for i := 0; i < f.partitions; i++ {
+		w.int16(0)
+		w.int32(int32(i))
+		w.int32(1)
+		w.int32(1)
+		w.int32(1)
+		w.int32(1)
+		w.int32(1)
+	}
+}
+
+func (f *fakeKafka) produce(r *kafkaReader, w *kafkaWriter) {
+	assert.Equal(f.t, "", r.string(), "transactional_id")
+	f.acks = append(f.acks, r.int16())
+	r.int32() // timeout
+	require.Equal(f.t, int32(1), r.int32())
+	topic := r.string()
+	require.Equal(f.t, int32(1), r.int32())
+	partition := r.int32()
+	batch := r.take(int(r.int32()))
+	require.NoError(f.t, r.err)
+
+	code := f.failNext
+	f.failNext = 0
+	if code == 0 {
+		f.produced[partition] = append(f.produced[partition], decodeBatch(f.t, batch))
+	}
+	w.int32(1)
+	w.string(topic)
+	w.int32(1)
+	w.int32(partition)
+	w.int16(code)
+	w.int64(int64(len(f.produced[partition]) - 1))
+	w.int64(-1)
+	w.int32(0) // throttle
+}
+
+// decodeBatch checks a v2 record batch's framing and CRC and returns its
+// only record.
+func decodeBatch(t *testing.T, b []byte) kafkaRecord {
+	r := kafkaReader{b: b}
+	assert.Equal(t, int64(0), r.int64(), "base_offset")
+	assert.Equal(t, int32(len(b)-12), r.int32(), "batch_length")
+	r.int32() // partition_leader_epoch
+	assert.Equal(t, int8(2), r.int8(), "magic")
+	crc := uint32(r.int32())
+	assert.Equal(t, crc32.Checksum(r.b, crc32.MakeTable(crc32.Castagnoli)), crc, "crc")
+	r.int16()
+	assert.Equal(t, int32(0), r.int32(), "last_offset_delta")
+	first, maxTS := r.int64(), r.int64()
+	assert.Equal(t, first, maxTS)
+	assert.Equal(t, int64(-1), r.int64(), "producer_id")
+	r.int16()
+	r.int32()
+	require.Equal(t, int32(1), r.int32(), "records")
+
+	rec := r.b
+	varint := func() int64 {
+		v, n := binary.Varint(rec)
+		require.Positive(t, n)
+		rec = rec[n:]
+		return v
+	}
+	str := func() string {
+		n := varint()
+		s := string(rec[:n])
+		rec = rec[n:]
+		return s
+	}
+	assert.Equal(t, int64(len(rec)-1), varint(), "record length")
+	rec = rec[1:] // attributes
+	varint()      // timestamp delta
+	varint()      // offset delta
+	out := kafkaRecord{key: str(), value: str(), headers: map[string]string{}}
+	for n := varint(); n > 0; n-- {
+		k := str()
+		out.headers[k] = str()
+	}
+	assert.Empty(t, rec)
+	return out
+}
+
+func newFakeKafka(t *testing.T, partitions int) *fakeKafka {
+	f := &fakeKafka{t: t, topic: "s3-events", partitions: partitions, produced: map[int32][]kafkaRecord{}}
+	f.addr = standIn(t, f.serve)
+	return f
+}
+
+func TestKafkaTarget_Publish(t *testing.T) {
+	f := newFakeKafka(t, 4)
+	// The first broker is unreachable; metadata comes from the second.
+	dead, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	deadAddr := dead.Addr().String()
+	require.NoError(t, dead.Close())
+
+	target, err := New("kafka://"+deadAddr+","+f.addr+"/s3-events", nil)
+	require.NoError(t, err)
+	defer func() { _ = target.Close() }()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, target.Publish(ctx, "photos/a.jpg", []byte(`{"n":1}`)))
+	require.NoError(t, target.Publish(ctx, "photos/a.jpg", []byte(`{"n":2}`)))
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	assert.Equal(t, 1, f.metadata, "metadata is cached")
+	assert.Equal(t, []int16{kafkaAcksAll, kafkaAcksAll}, f.acks)
+	assert.Equal(t, "vaultaire", f.clientIDs[0])
+	// Both events for one key land in one partition, in order.
+	require.Len(t, f.produced, 1)
+	for _, records := range f.produced {
+		require.Len(t, records, 2)
+		assert.Equal(t, "photos/a.jpg", records[0].key)
+		assert.Equal(t, `{"n":1}`, records[0].value)
+		assert.Equal(t, `{"n":2}`, records[1].value)
+		assert.Equal(t, map[string]string{"content-type": "application/json"}, records[0].headers)
+	}
+}
+
+func TestKafkaTarget_FixedPartition(t *testing.T) {
+	f := newFakeKafka(t, 4)
+	target, err := New("kafka://"+f.addr+"/s3-events?partition=3", nil)
+	require.NoError(t, err)
+	defer func() { _ = target.Close() }()
+
+	for _, key := range []string{"a", "b", "c"} {
+		require.NoError(t, target.Publish(context.Background(), key, []byte(`{}`)))
+	}
+	f.mu.Lock()
+	assert.Len(t, f.produced[3], 3)
+	f.mu.Unlock()
+
+	bad, err := New("kafka://"+f.addr+"/s3-events?partition=9", nil)
+	require.NoError(t, err)
+	defer func() { _ = bad.Close() }()
+	assert.ErrorContains(t, bad.Publish(context.Background(), "a", []byte(`{}`)), "partition 9 does not exist")
+}
+
+func TestKafkaTarget_Errors(t *testing.T) {
+	f := newFakeKafka(t, 1)
+	ctx := context.Background()
+
+	missing, err := New("kafka://"+f.addr+"/nope", nil)
+	require.NoError(t, err)
+	defer func() { _ = missing.Close() }()
+	assert.ErrorContains(t, missing.Publish(ctx, "k", []byte(`{}`)), "UNKNOWN_TOPIC_OR_PARTITION")
+
+	target, err := New("kafka://"+f.addr+"/s3-events", nil)
+	require.NoError(t, err)
+	defer func() { _ = target.Close() }()
+	f.mu.Lock()
+	f.failNext = 6
+	f.mu.Unlock()
+	assert.ErrorContains(t, target.Publish(ctx, "k", []byte(`{}`)), "NOT_LEADER_OR_FOLLOWER")
+
+	// A produce error refreshes the partition leaders.
+	require.NoError(t, target.Publish(ctx, "k", []byte(`{}`)))
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	assert.Equal(t, 3, f.metadata)
+	assert.Len(t, f.produced[0], 1)
+}
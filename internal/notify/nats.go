@@ -0,0 +1,194 @@
+// internal/notify/nats.go
+package notify
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultTimeout bounds a publish whose context has no deadline.
+const defaultTimeout = 5 * time.Second
+
+// NATSTarget publishes events to a NATS subject over the core protocol.
+// Each PUB is followed by a PING, so Publish returns only after the server
+// has processed the message (or rejected it with -ERR).
+type NATSTarget struct {
+	addr     string
+	host     string
+	subject  string
+	user     string
+	pass     string
+	token    string
+	mu       sync.Mutex
+	conn     net.Conn
+	r        *bufio.Reader
+	maxBytes int
+}
+
+func newNATSTarget(u *url.URL) (*NATSTarget, error) {
+	if u.Hostname() == "" {
+		return nil, errors.New("nats target: missing host")
+	}
+	subject := strings.Trim(u.Path, "/")
+	if subject == "" || strings.ContainsAny(subject, " \t\r\n*>") {
+		return nil, fmt.Errorf("nats target: invalid subject %q", subject)
+	}
+	port := u.Port()
+	if port == "" {
+		port = "4222"
+	}
+	t := &NATSTarget{
+		addr:    net.JoinHostPort(u.Hostname(), port),
+		host:    u.Hostname(),
+		subject: subject,
+	}
+	if u.User != nil {
+		if pass, ok := u.User.Password(); ok {
+			t.user, t.pass = u.User.Username(), pass
+		} else {
+			t.token = u.User.Username()
+		}
+	}
+	return t, nil
+}
+
+// natsInfo is the part of the server's INFO line the target uses.
+type natsInfo struct {
+	TLSRequired bool `json:"tls_required"`
+	MaxPayload  int  `json:"max_payload"`
+}
+
+// Publish sends the event and waits for the server to confirm it.
+func (t *NATSTarget) Publish(ctx context.Context, key string, payload []byte) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if err := t.publish(ctx, payload); err != nil {
+		t.reset()
+		return fmt.Errorf("nats publish %s: %w", t.subject, err)
+	}
+	return nil
+}
+
+func (t *NATSTarget) publish(ctx context.Context, payload []byte) error {
+	if t.conn == nil {
+		if err := t.connect(ctx); err != nil {
+			return err
+		}
+	}
+	if t.maxBytes > 0 && len(payload) > t.maxBytes {
+		return fmt.Errorf("payload of %d bytes exceeds the server's max_payload %d", len(payload), t.maxBytes)
+	}
+	if err := t.conn.SetDeadline(deadline(ctx)); err != nil {
+		return err
+	}
+	msg := fmt.Sprintf("PUB %s %d\r\n%s\r\nPING\r\n", t.subject, len(payload), payload)
+	if _, err := t.conn.Write([]byte(msg)); err != nil {
+		return err
+	}
+	return t.awaitPong()
+}
+
+func (t *NATSTarget) connect(ctx context.Context) error {
+	conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", t.addr)
+	if err != nil {
+		return err
+	}
+	t.conn, t.r = conn, bufio.NewReader(conn)
+	if err := conn.SetDeadline(deadline(ctx)); err != nil {
+		return err
+	}
+
+	line, err := t.r.ReadString('\n')
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(line, "INFO ") {
+		return fmt.Errorf("expected INFO, got %q", strings.TrimSpace(line))
+	}
+	var info natsInfo
+	if err := json.Unmarshal([]byte(strings.TrimSpace(line[len("INFO "):])), &info); err != nil {
+		return fmt.Errorf("parse INFO: %w", err)
+	}
+	t.maxBytes = info.MaxPayload
+	if info.TLSRequired {
+		tc := tls.Client(conn, &tls.Config{ServerName: t.host, MinVersion: tls.VersionTLS12})
+		if err := tc.HandshakeContext(ctx); err != nil {
+			return err
+		}
+		t.conn, t.r = tc, bufio.NewReader(tc)
+	}
+
+	connect, err := json.Marshal(map[string]interface{}{
+		"verbose":      false,
+		"pedantic":     false,
+		"tls_required": info.TLSRequired,
+		"name":         "vaultaire",
+		"lang":         "go",
+		"version":      "1.0.0",
+		"user":         t.user,
+		"pass":         t.pass,
+		"auth_token":   t.token,
+	})
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(t.conn, "CONNECT %s\r\nPING\r\n", connect); err != nil {
+		return err
+	}
+	return t.awaitPong()
+}
+
+// awaitPong reads until the PONG answering our PING, answering the
+// server's own PINGs on the way.
+func (t *NATSTarget) awaitPong() error {
+	for {
+		line, err := t.r.ReadString('\n')
+		if err != nil {
+			return err
+		}
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "PONG":
+			return nil
+		case line == "PING":
+			if _, err := t.conn.Write([]byte("PONG\r\n")); err != nil {
+				return err
+			}
+		case strings.HasPrefix(line, "-ERR"):
+			return fmt.Errorf("server error: %s", strings.TrimSpace(line[len("-ERR"):]))
+		}
+	}
+}
+
+func (t *NATSTarget) reset() {
+	if t.conn != nil {
+		_ = t.conn.Close()
+	}
+	t.conn, t.r = nil, nil
+}
+
+// Close closes the connection.
+func (t *NATSTarget) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.reset()
+	return nil
+}
+
+// deadline is ctx's deadline, or defaultTimeout from now.
+func deadline(ctx context.Context) time.Time {
+	if d, ok := ctx.Deadline(); ok {
+		return d
+	}
+	return time.Now().Add(defaultTimeout)
+}
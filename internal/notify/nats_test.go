@@ -0,0 +1,117 @@
+package notify
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeNATS is a NATS server stand-in that records CONNECT options and
+// published messages, and rejects publishes to subjects in deny.
+type fakeNATS struct {
+	mu       sync.Mutex
+	connects []map[string]interface{}
+	msgs     map[string][]string
+	deny     string
+}
+
+func (f *fakeNATS) serve(conn net.Conn) {
+	r := bufio.NewReader(conn)
+	if _, err := io.WriteString(conn, `INFO {"server_id":"fake","max_payload":64}`+"\r\n"); err != nil {
+		return
+	}
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		var reply string
+		switch fields[0] {
+		case "CONNECT":
+			var opts map[string]interface{}
+			_ = json.Unmarshal([]byte(strings.TrimPrefix(strings.TrimSpace(line), "CONNECT ")), &opts)
+			f.mu.Lock()
+			f.connects = append(f.connects, opts)
+			f.mu.Unlock()
+		case "PUB":
+			n, _ := strconv.Atoi(fields[2])
+			buf := make([]byte, n+2)
+			if _, err := io.ReadFull(r, buf); err != nil {
+				return
+			}
+			if fields[1] == f.deny {
+				reply = "-ERR 'Permissions Violation for Publish to " + fields[1] + "'\r\n"
+				break
+			}
+			f.mu.Lock()
+			f.msgs[fields[1]] = append(f.msgs[fields[1]], string(buf[:n]))
+			f.mu.Unlock()
+			// Servers ping idle clients; the target must answer mid-publish.
+			reply = "PING\r\n"
+		case "PING":
+			reply = "PONG\r\n"
+		case "PONG":
+		}
+		if reply != "" {
+			if _, err := io.WriteString(conn, reply); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func TestNATSTarget_Publish(t *testing.T) {
+	f := &fakeNATS{msgs: map[string][]string{}}
+	addr := standIn(t, f.serve)
+
+	target, err := New("nats://app:secret@"+addr+"/s3.events", nil)
+	require.NoError(t, err)
+	defer func() { _ = target.Close() }()
+
+	ctx := context.Background()
+	require.NoError(t, target.Publish(ctx, "photos/a.jpg", []byte(`{"n":1}`)))
+	require.NoError(t, target.Publish(ctx, "photos/b.jpg", []byte(`{"n":2}`)))
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	require.Len(t, f.connects, 1, "the connection is reused")
+	assert.Equal(t, "app", f.connects[0]["user"])
+	assert.Equal(t, "secret", f.connects[0]["pass"])
+	assert.Equal(t, false, f.connects[0]["verbose"])
+	assert.Equal(t, []string{`{"n":1}`, `{"n":2}`}, f.msgs["s3.events"])
+}
+
+func TestNATSTarget_Errors(t *testing.T) {
+	f := &fakeNATS{msgs: map[string][]string{}, deny: "s3.denied"}
+	addr := standIn(t, f.serve)
+	ctx := context.Background()
+
+	denied, err := New("nats://"+addr+"/s3.denied", nil)
+	require.NoError(t, err)
+	defer func() { _ = denied.Close() }()
+	assert.ErrorContains(t, denied.Publish(ctx, "k", []byte(`{}`)), "Permissions Violation")
+
+	big, err := New("nats://"+addr+"/s3.events", nil)
+	require.NoError(t, err)
+	defer func() { _ = big.Close() }()
+	assert.ErrorContains(t, big.Publish(ctx, "k", []byte(strings.Repeat("x", 65))), "max_payload 64")
+
+	// After an error the target reconnects.
+	require.NoError(t, big.Publish(ctx, "k", []byte(`{}`)))
+	f.mu.Lock()
+	assert.Len(t, f.connects, 3)
+	f.mu.Unlock()
+}
@@ -0,0 +1,64 @@
+// internal/notify/redis.go
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+
+	"github.com/FairForge/vaultaire/internal/resp"
+)
+
+// RedisStreamTarget appends events to a Redis stream with XADD, one entry
+// per event with "key" and "event" fields.
+type RedisStreamTarget struct {
+	client *resp.Client
+	stream string
+	maxLen int
+}
+
+func newRedisStreamTarget(u *url.URL) (*RedisStreamTarget, error) {
+	q := u.Query()
+	t := &RedisStreamTarget{stream: q.Get("stream")}
+	if t.stream == "" {
+		return nil, errors.New("redis target: missing stream parameter")
+	}
+	if v := q.Get("maxlen"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("redis target: invalid maxlen %q", v)
+		}
+		t.maxLen = n
+	}
+	conn := *u
+	conn.RawQuery = ""
+	client, err := resp.NewClient(conn.String())
+	if err != nil {
+		return nil, err
+	}
+	t.client = client
+	return t, nil
+}
+
+// Publish adds the event to the stream, trimming it to about maxlen entries
+// when one is set.
+func (t *RedisStreamTarget) Publish(ctx context.Context, key string, payload []byte) error {
+	args := []string{"XADD", t.stream}
+	if t.maxLen > 0 {
+		args = append(args, "MAXLEN", "~", strconv.Itoa(t.maxLen))
+	}
+	args = append(args, "*", "key", key, "event", string(payload))
+	reply, err := t.client.Do(ctx, args...)
+	if err != nil {
+		return fmt.Errorf("redis XADD %s: %w", t.stream, err)
+	}
+	if _, ok := reply.(string); !ok {
+		return fmt.Errorf("redis XADD %s: unexpected reply %v", t.stream, reply)
+	}
+	return nil
+}
+
+// Close closes the idle connections.
+func (t *RedisStreamTarget) Close() error { return t.client.Close() }
@@ -0,0 +1,75 @@
+package notify
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"testing"
+
+	"github.com/FairForge/vaultaire/internal/resp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedisStreamTarget_Publish(t *testing.T) {
+	var mu sync.Mutex
+	var commands [][]interface{}
+	addr := standIn(t, func(conn net.Conn) {
+		r := bufio.NewReader(conn)
+		for {
+			v, err := resp.ReadReply(r)
+			if err != nil {
+				return
+			}
+			args := v.([]interface{})
+			mu.Lock()
+			commands = append(commands, args)
+			mu.Unlock()
+			reply := "+OK\r\n"
+			if args[0] == "XADD" {
+				reply = fmt.Sprintf("$15\r\n1700000000000-%d\r\n", len(commands)-1)
+			}
+			if _, err := io.WriteString(conn, reply); err != nil {
+				return
+			}
+		}
+	})
+
+	target, err := New("redis://:pw@"+addr+"/2?stream=s3-events&maxlen=1000", nil)
+	require.NoError(t, err)
+	defer func() { _ = target.Close() }()
+
+	require.NoError(t, target.Publish(context.Background(), "photos/cat.jpg", []byte(`{"Records":[]}`)))
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Len(t, commands, 3)
+	assert.Equal(t, []interface{}{"AUTH", "pw"}, commands[0])
+	assert.Equal(t, []interface{}{"SELECT", "2"}, commands[1])
+	assert.Equal(t, []interface{}{"XADD", "s3-events", "MAXLEN", "~", "1000", "*",
+		"key", "photos/cat.jpg", "event", `{"Records":[]}`}, commands[2])
+}
+
+func TestRedisStreamTarget_Error(t *testing.T) {
+	addr := standIn(t, func(conn net.Conn) {
+		r := bufio.NewReader(conn)
+		for {
+			if _, err := resp.ReadReply(r); err != nil {
+				return
+			}
+			if _, err := io.WriteString(conn, "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"); err != nil {
+				return
+			}
+		}
+	})
+
+	target, err := New("redis://"+addr+"?stream=s3-events", nil)
+	require.NoError(t, err)
+	defer func() { _ = target.Close() }()
+
+	err = target.Publish(context.Background(), "photos/cat.jpg", []byte(`{}`))
+	assert.ErrorContains(t, err, "WRONGTYPE")
+}
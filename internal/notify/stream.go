@@ -0,0 +1,48 @@
+// internal/notify/stream.go
+package notify
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+
+	"github.com/FairForge/vaultaire/internal/streaming"
+)
+
+// StreamTarget appends events to an in-process stream, for consumers in the
+// same process. Events published while no replica-local consumer reads the
+// stream are kept only until its retention drops them.
+type StreamTarget struct {
+	streams *streaming.StreamManager
+	name    string
+}
+
+func newStreamTarget(streams *streaming.StreamManager, u *url.URL) (*StreamTarget, error) {
+	if streams == nil {
+		return nil, errors.New("stream target: no stream manager")
+	}
+	name := u.Host + strings.TrimSuffix(u.Path, "/")
+	if name == "" {
+		return nil, errors.New("stream target: missing stream name")
+	}
+	if streams.GetStream(name) == nil {
+		// Losing a creation race to another target is fine.
+		if _, err := streams.CreateStream(&streaming.StreamConfig{Name: name}); err != nil && streams.GetStream(name) == nil {
+			return nil, err
+		}
+	}
+	return &StreamTarget{streams: streams, name: name}, nil
+}
+
+// Publish appends the event, keyed by object so one object's events stay in
+// one partition.
+func (t *StreamTarget) Publish(ctx context.Context, key string, payload []byte) error {
+	msg := streaming.NewMessage(key, payload)
+	msg.Headers["content-type"] = "application/json"
+	_, err := t.streams.Publish(ctx, t.name, msg)
+	return err
+}
+
+// Close is a no-op; the stream belongs to the manager.
+func (t *StreamTarget) Close() error { return nil }
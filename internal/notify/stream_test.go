@@ -0,0 +1,41 @@
+package notify
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/FairForge/vaultaire/internal/streaming"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStreamTarget_Publish(t *testing.T) {
+	streams := streaming.NewStreamManager(nil)
+	target, err := New("stream://s3-events", streams)
+	require.NoError(t, err)
+	require.NotNil(t, streams.GetStream("s3-events"), "the stream is created on demand")
+
+	// A second target on the same stream shares it.
+	_, err = New("stream://s3-events", streams)
+	require.NoError(t, err)
+
+	sub, err := streams.Subscribe("s3-events", "consumers", streaming.SubscribeOptions{StartOffset: streaming.OffsetEarliest})
+	require.NoError(t, err)
+	defer func() { _ = sub.Close() }()
+
+	require.NoError(t, target.Publish(context.Background(), "photos/cat.jpg", []byte(`{"Records":[]}`)))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	msg, err := sub.Receive(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "photos/cat.jpg", msg.Key)
+	assert.Equal(t, `{"Records":[]}`, string(msg.Value))
+	assert.Equal(t, "application/json", msg.Headers["content-type"])
+}
+
+func TestStreamTarget_NoManager(t *testing.T) {
+	_, err := New("stream://s3-events", nil)
+	assert.ErrorContains(t, err, "no stream manager")
+}
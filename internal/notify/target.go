@@ -0,0 +1,114 @@
+// Package notify publishes S3 event notifications to queue and stream
+// targets: the in-process streaming.StreamManager, NATS, Kafka, AMQP 0-9-1
+// brokers and Redis Streams. Each protocol client speaks just enough of its
+// protocol to publish, which keeps client libraries out of the build.
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/FairForge/vaultaire/internal/streaming"
+)
+
+// Target receives notification payloads.
+type Target interface {
+	// Publish sends one event; it returns nil only once the target has
+	// accepted it. key names the object ("bucket/key"), for targets that
+	// partition by key.
+	Publish(ctx context.Context, key string, payload []byte) error
+	Close() error
+}
+
+// New creates the target for rawURL:
+//
+//	stream://name                                     in-process stream
+//	nats://[user:pass@|token@]host[:4222]/subject
+//	kafka://host1[:9092][,host2...]/topic[?partition=N]
+//	amqp[s]://[user:pass@]host[:5672]/[vhost]?exchange=x&routing_key=k
+//	redis[s]://[:pass@]host[:6379][/db]?stream=key[&maxlen=N]
+//
+// streams backs stream:// targets.
+func New(rawURL string, streams *streaming.StreamManager) (Target, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("notification target: %w", err)
+	}
+	switch u.Scheme {
+	case "stream":
+		return newStreamTarget(streams, u)
+	case "nats":
+		return newNATSTarget(u)
+	case "kafka":
+		return newKafkaTarget(u)
+	case "amqp", "amqps":
+		return newAMQPTarget(u)
+	case "redis", "rediss":
+		return newRedisStreamTarget(u)
+	}
+	return nil, fmt.Errorf("notification target: unsupported scheme %q", u.Scheme)
+}
+
+// Registry maps the ARNs that bucket notification configurations name to
+// their targets.
+type Registry struct {
+	targets map[string]Target
+}
+
+// NewRegistry creates a target for each ARN → URL in specs.
+func NewRegistry(specs map[string]string, streams *streaming.StreamManager) (*Registry, error) {
+	r := &Registry{targets: make(map[string]Target, len(specs))}
+	for arn, rawURL := range specs {
+		if !strings.HasPrefix(arn, "arn:") {
+			_ = r.Close()
+			return nil, fmt.Errorf("notification target %q: ARN must start with \"arn:\"", arn)
+		}
+		t, err := New(rawURL, streams)
+		if err != nil {
+			_ = r.Close()
+			return nil, fmt.Errorf("notification target %s: %w", arn, err)
+		}
+		r.targets[arn] = t
+	}
+	return r, nil
+}
+
+// Get returns the target for arn. A nil Registry has none.
+func (r *Registry) Get(arn string) (Target, bool) {
+	if r == nil {
+		return nil, false
+	}
+	t, ok := r.targets[arn]
+	return t, ok
+}
+
+// ARNs lists the configured ARNs in order.
+func (r *Registry) ARNs() []string {
+	if r == nil {
+		return nil
+	}
+	arns := make([]string, 0, len(r.targets))
+	for arn := range r.targets {
+		arns = append(arns, arn)
+	}
+	sort.Strings(arns)
+	return arns
+}
+
+// Close closes every target.
+func (r *Registry) Close() error {
+	if r == nil {
+		return nil
+	}
+	var errs []error
+	for _, t := range r.targets {
+		if err := t.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
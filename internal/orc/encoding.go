@@ -0,0 +1,99 @@
+package orc
+
+import "math/bits"
+
+// maxRunLength is the longest integer RLE v2 run.
+const maxRunLength = 512
+
+// encodeInts encodes values with integer RLE v2, using only DIRECT runs.
+// Signed streams zigzag their values first.
+func encodeInts(values []uint64) []byte {
+	var out []byte
+	for len(values) > 0 {
+		n := min(len(values), maxRunLength)
+		run := values[:n]
+		values = values[n:]
+
+		var all uint64
+		for _, v := range run {
+			all |= v
+		}
+		width, code := directWidth(bits.Len64(all))
+		out = append(out, 0x40|code<<1|byte((n-1)>>8), byte(n-1))
+		out = packBits(out, run, width)
+	}
+	return out
+}
+
+// directWidth rounds a bit count up to a width DIRECT runs use and
+// returns it with its 5-bit header code.
+func directWidth(n int) (int, byte) {
+	switch {
+	case n <= 1:
+		return 1, 0
+	case n <= 2:
+		return 2, 1
+	case n <= 4:
+		return 4, 3
+	case n <= 8:
+		return 8, 7
+	case n <= 16:
+		return 16, 15
+	case n <= 24:
+		return 24, 23
+	case n <= 32:
+		return 32, 27
+	case n <= 40:
+		return 40, 28
+	case n <= 48:
+		return 48, 29
+	case n <= 56:
+		return 56, 30
+	}
+	return 64, 31
+}
+
+// packBits appends values of the given width, most significant bit first.
+func packBits(out []byte, values []uint64, width int) []byte {
+	var cur byte
+	n := 0
+	for _, v := range values {
+		for b := width - 1; b >= 0; b-- {
+			cur = cur<<1 | byte(v>>b&1)
+			n++
+			if n == 8 {
+				out = append(out, cur)
+				cur, n = 0, 0
+			}
+		}
+	}
+	if n > 0 {
+		out = append(out, cur<<(8-n))
+	}
+	return out
+}
+
+// encodeBooleans packs values most significant bit first and encodes the
+// bytes with byte RLE.
+func encodeBooleans(values []bool) []byte {
+	packed := make([]byte, (len(values)+7)/8)
+	for i, v := range values {
+		if v {
+			packed[i/8] |= 0x80 >> (i % 8)
+		}
+	}
+	return encodeBytes(packed)
+}
+
+// encodeBytes encodes data with byte RLE, using only literal runs of up
+// to 128 bytes, each headed by its negated length.
+func encodeBytes(data []byte) []byte {
+	out := make([]byte, 0, len(data)+len(data)/128+1)
+	for len(data) > 0 {
+		n := min(len(data), 128)
+		out = append(out, byte(256-n))
+		out = append(out, data[:n]...)
+		data = data[n:]
+	}
+	return out
+}
@@ -0,0 +1,399 @@
+// Package orc writes flat Apache ORC files.
+//
+// It covers what inventory reports need: a struct of boolean, bigint,
+// string and timestamp columns, every column nullable, stored without
+// compression or row indexes in DIRECT_V2 encoding.
+package orc
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+const magic = "ORC"
+
+// Writer defaults.
+const (
+	defaultStripeRows = 128 << 10
+	writerTimezone    = "UTC"
+)
+
+// Kind is a column type.
+type Kind int
+
+// Column types, with the Go values Write takes for them.
+const (
+	Boolean   Kind = iota // bool
+	Long                  // int64
+	String                // string
+	Timestamp             // time.Time
+)
+
+func (k Kind) String() string {
+	switch k {
+	case Boolean:
+		return "boolean"
+	case Long:
+		return "bigint"
+	case String:
+		return "string"
+	case Timestamp:
+		return "timestamp"
+	}
+	return "unknown"
+}
+
+// Type kinds, stream kinds and column encodings from orc_proto.proto.
+const (
+	typeBoolean   = 0
+	typeLong      = 4
+	typeString    = 7
+	typeTimestamp = 9
+	typeStruct    = 12
+
+	streamPresent   = 0
+	streamData      = 1
+	streamLength    = 2
+	streamSecondary = 5
+
+	encodingDirect   = 0
+	encodingDirectV2 = 2
+)
+
+// typeKinds maps each Kind to its ORC type kind.
+var typeKinds = [...]uint64{Boolean: typeBoolean, Long: typeLong, String: typeString, Timestamp: typeTimestamp}
+
+// writerVersion is ORC-135: timestamp statistics are in UTC. The writer
+// writes no min/max statistics, but readers key their workarounds off it.
+const writerVersion = 6
+
+// timestampEpoch is the base ORC timestamps count seconds from.
+var timestampEpoch = time.Date(2015, 1, 1, 0, 0, 0, 0, time.UTC).Unix()
+
+// Field describes a column.
+type Field struct {
+	Name string
+	Kind Kind
+}
+
+// Writer writes an ORC file: one stripe per StripeRows rows.
+type Writer struct {
+	// StripeRows is the number of rows buffered before a stripe is
+	// written. It may be changed before the first Write.
+	StripeRows int
+
+	w       *countingWriter
+	fields  []Field
+	rows    [][]any
+	stripes [][]byte // encoded StripeInformation messages
+	values  []uint64 // non-null values per column, root first
+	hasNull []bool
+	total   uint64
+	closed  bool
+}
+
+// NewWriter starts an ORC file on w with a struct of fields.
+func NewWriter(w io.Writer, fields []Field) (*Writer, error) {
+	if len(fields) == 0 {
+		return nil, errors.New("orc: no fields")
+	}
+	for _, f := range fields {
+		if f.Kind < Boolean || f.Kind > Timestamp {
+			return nil, fmt.Errorf("orc: field %s: unknown kind %d", f.Name, f.Kind)
+		}
+	}
+	cw := &countingWriter{w: w}
+	if _, err := io.WriteString(cw, magic); err != nil {
+		return nil, err
+	}
+	return &Writer{
+		StripeRows: defaultStripeRows,
+		w:          cw,
+		fields:     fields,
+		values:     make([]uint64, len(fields)+1),
+		hasNull:    make([]bool, len(fields)+1),
+	}, nil
+}
+
+// Write buffers one row, holding one value per field, and writes a stripe
+// once StripeRows rows are buffered. nil marks a null.
+func (w *Writer) Write(row []any) error {
+	if w.closed {
+		return errors.New("orc: write to closed writer")
+	}
+	if len(row) != len(w.fields) {
+		return fmt.Errorf("orc: row has %d values, schema has %d fields", len(row), len(w.fields))
+	}
+	for i, f := range w.fields {
+		if err := f.check(row[i]); err != nil {
+			return err
+		}
+	}
+	w.rows = append(w.rows, row)
+	if len(w.rows) >= w.StripeRows {
+		return w.flush()
+	}
+	return nil
+}
+
+// Close writes any buffered rows, the footer and the postscript. It does
+// not close the underlying writer.
+func (w *Writer) Close() error {
+	if w.closed {
+		return nil
+	}
+	if err := w.flush(); err != nil {
+		return err
+	}
+	w.closed = true
+
+	footer := w.footer()
+	var ps []byte
+	ps = appendUint(ps, 1, uint64(len(footer)))
+	ps = appendUint(ps, 2, 0) // CompressionKind NONE
+	ps = protowire.AppendTag(ps, 4, protowire.BytesType)
+	ps = protowire.AppendBytes(ps, []byte{0, 12}) // packed version 0.12
+	ps = appendUint(ps, 5, 0)                     // no metadata section
+	ps = appendUint(ps, 6, writerVersion)
+	ps = protowire.AppendTag(ps, 8000, protowire.BytesType)
+	ps = protowire.AppendString(ps, magic)
+
+	tail := append(footer, ps...)
+	tail = append(tail, byte(len(ps)))
+	_, err := w.w.Write(tail)
+	return err
+}
+
+// Schema renders fields as an ORC type description, as in
+// "struct<key:string,size:bigint>".
+func Schema(fields []Field) string {
+	parts := make([]string, len(fields))
+	for i, f := range fields {
+		parts[i] = f.Name + ":" + f.Kind.String()
+	}
+	return "struct<" + strings.Join(parts, ",") + ">"
+}
+
+func (f Field) check(v any) error {
+	if v == nil {
+		return nil
+	}
+	var ok bool
+	switch f.Kind {
+	case Boolean:
+		_, ok = v.(bool)
+	case Long:
+		_, ok = v.(int64)
+	case String:
+		_, ok = v.(string)
+	case Timestamp:
+		_, ok = v.(time.Time)
+	}
+	if !ok {
+		return fmt.Errorf("orc: field %s: unexpected value of type %T", f.Name, v)
+	}
+	return nil
+}
+
+type stream struct {
+	kind   uint64
+	column uint64
+	data   []byte
+}
+
+// flush writes the buffered rows as a stripe.
+func (w *Writer) flush() error {
+	if len(w.rows) == 0 {
+		return nil
+	}
+	offset := w.w.n
+	var streams []stream
+	var encodings []byte
+	encodings = appendMessage(encodings, 2, appendUint(nil, 1, encodingDirect))
+
+	for i, f := range w.fields {
+		col := uint64(i + 1)
+		present := make([]bool, len(w.rows))
+		nulls := false
+		var bools []bool
+		var ints, lengths, nanos []uint64
+		var data []byte
+		for r, row := range w.rows {
+			switch v := row[i].(type) {
+			case bool:
+				bools = append(bools, v)
+			case int64:
+				ints = append(ints, zigzag(v))
+			case string:
+				data = append(data, v...)
+				lengths = append(lengths, uint64(len(v)))
+			case time.Time:
+				secs, ns := timestampParts(v)
+				ints = append(ints, zigzag(secs))
+				nanos = append(nanos, ns)
+			case nil:
+				nulls = true
+				continue
+			}
+			present[r] = true
+			w.values[col]++
+		}
+
+		if nulls {
+			w.hasNull[col] = true
+			streams = append(streams, stream{streamPresent, col, encodeBooleans(present)})
+		}
+		encoding := uint64(encodingDirectV2)
+		switch f.Kind {
+		case Boolean:
+			encoding = encodingDirect
+			streams = append(streams, stream{streamData, col, encodeBooleans(bools)})
+		case Long:
+			streams = append(streams, stream{streamData, col, encodeInts(ints)})
+		case String:
+			streams = append(streams,
+				stream{streamData, col, data},
+				stream{streamLength, col, encodeInts(lengths)})
+		case Timestamp:
+			streams = append(streams,
+				stream{streamData, col, encodeInts(ints)},
+				stream{streamSecondary, col, encodeInts(nanos)})
+		}
+		encodings = appendMessage(encodings, 2, appendUint(nil, 1, encoding))
+	}
+
+	var stripeFooter []byte
+	var dataLength uint64
+	for _, s := range streams {
+		if _, err := w.w.Write(s.data); err != nil {
+			return err
+		}
+		dataLength += uint64(len(s.data))
+		var m []byte
+		m = appendUint(m, 1, s.kind)
+		m = appendUint(m, 2, s.column)
+		m = appendUint(m, 3, uint64(len(s.data)))
+		stripeFooter = appendMessage(stripeFooter, 1, m)
+	}
+	stripeFooter = append(stripeFooter, encodings...)
+	stripeFooter = protowire.AppendTag(stripeFooter, 3, protowire.BytesType)
+	stripeFooter = protowire.AppendString(stripeFooter, writerTimezone)
+	if _, err := w.w.Write(stripeFooter); err != nil {
+		return err
+	}
+
+	var info []byte
+	info = appendUint(info, 1, uint64(offset))
+	info = appendUint(info, 2, 0) // no row index
+	info = appendUint(info, 3, dataLength)
+	info = appendUint(info, 4, uint64(len(stripeFooter)))
+	info = appendUint(info, 5, uint64(len(w.rows)))
+	w.stripes = append(w.stripes, info)
+	w.values[0] += uint64(len(w.rows))
+	w.total += uint64(len(w.rows))
+	w.rows = w.rows[:0]
+	return nil
+}
+
+func (w *Writer) footer() []byte {
+	var f []byte
+	f = appendUint(f, 1, uint64(len(magic)))
+	f = appendUint(f, 2, uint64(w.w.n))
+	for _, s := range w.stripes {
+		f = appendMessage(f, 3, s)
+	}
+
+	var root []byte
+	root = appendUint(root, 1, typeStruct)
+	subtypes := make([]byte, 0, len(w.fields))
+	for i := range w.fields {
+		subtypes = protowire.AppendVarint(subtypes, uint64(i+1))
+	}
+	root = protowire.AppendTag(root, 2, protowire.BytesType)
+	root = protowire.AppendBytes(root, subtypes)
+	for _, fl := range w.fields {
+		root = protowire.AppendTag(root, 3, protowire.BytesType)
+		root = protowire.AppendString(root, fl.Name)
+	}
+	f = appendMessage(f, 4, root)
+	for _, fl := range w.fields {
+		f = appendMessage(f, 4, appendUint(nil, 1, typeKinds[fl.Kind]))
+	}
+
+	f = appendUint(f, 6, w.total)
+	for col := range w.values {
+		var s []byte
+		s = appendUint(s, 1, w.values[col])
+		s = appendUint(s, 10, boolUint(w.hasNull[col]))
+		f = appendMessage(f, 7, s)
+	}
+	f = appendUint(f, 8, 0) // rowIndexStride: no row indexes
+	return f
+}
+
+// timestampParts splits t into seconds from timestampEpoch and nanoseconds
+// in ORC's trailing-zero form. Like the Java writer, seconds are truncated
+// toward zero before 1970, and readers step back a second when nanoseconds
+// are set.
+func timestampParts(t time.Time) (int64, uint64) {
+	secs := t.Unix()
+	ns := t.Nanosecond()
+	if secs < 0 && ns > 0 {
+		secs++
+	}
+	return secs - timestampEpoch, formatNanos(ns)
+}
+
+// formatNanos stores nanoseconds with their trailing decimal zeros folded
+// into the low three bits: n<<3 | z means n * 10^(z+1).
+func formatNanos(ns int) uint64 {
+	if ns == 0 {
+		return 0
+	}
+	if ns%100 != 0 {
+		return uint64(ns) << 3
+	}
+	ns /= 100
+	zeros := 1
+	for ns%10 == 0 && zeros < 7 {
+		ns /= 10
+		zeros++
+	}
+	return uint64(ns)<<3 | uint64(zeros)
+}
+
+func zigzag(v int64) uint64 { return protowire.EncodeZigZag(v) }
+
+func boolUint(b bool) uint64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func appendUint(b []byte, num protowire.Number, v uint64) []byte {
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, v)
+}
+
+func appendMessage(b []byte, num protowire.Number, m []byte) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, m)
+}
+
+// countingWriter tracks the file offset for stripe metadata.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
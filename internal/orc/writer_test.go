@@ -0,0 +1,302 @@
+package orc
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+type pbField struct {
+	num protowire.Number
+	v   uint64
+	b   []byte
+}
+
+// pbFields splits a protobuf message into its varint and bytes fields.
+func pbFields(t *testing.T, b []byte) []pbField {
+	t.Helper()
+	var out []pbField
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		require.Greater(t, n, 0)
+		b = b[n:]
+		f := pbField{num: num}
+		switch typ {
+		case protowire.VarintType:
+			f.v, n = protowire.ConsumeVarint(b)
+		case protowire.BytesType:
+			f.b, n = protowire.ConsumeBytes(b)
+		default:
+			t.Fatalf("unexpected wire type %d", typ)
+		}
+		require.Greater(t, n, 0)
+		b = b[n:]
+		out = append(out, f)
+	}
+	return out
+}
+
+func pbUint(t *testing.T, b []byte, num protowire.Number) uint64 {
+	for _, f := range pbFields(t, b) {
+		if f.num == num {
+			return f.v
+		}
+	}
+	return 0
+}
+
+// decodeInts decodes n integer RLE v2 values from DIRECT runs.
+func decodeInts(t *testing.T, b []byte, n int) []uint64 {
+	t.Helper()
+	var out []uint64
+	for len(out) < n {
+		require.GreaterOrEqual(t, len(b), 2)
+		require.Equal(t, byte(1), b[0]>>6, "DIRECT run")
+		code := int(b[0] >> 1 & 0x1f)
+		width := []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16,
+			17, 18, 19, 20, 21, 22, 23, 24, 26, 28, 30, 32, 40, 48, 56, 64}[code]
+		count := int(b[0]&1)<<8 | int(b[1]) + 1
+		b = b[2:]
+		pos := 0
+		for i := 0; i < count; i++ {
+			var v uint64
+			for k := 0; k < width; k++ {
+				v = v<<1 | uint64(b[pos/8]>>(7-pos%8)&1)
+				pos++
+			}
+			out = append(out, v)
+		}
+		b = b[(pos+7)/8:]
+	}
+	return out
+}
+
+// decodeBooleans decodes n byte RLE encoded booleans.
+func decodeBooleans(t *testing.T, b []byte, n int) []bool {
+	t.Helper()
+	var packed []byte
+	for len(packed)*8 < n {
+		require.NotEmpty(t, b)
+		if c := int8(b[0]); c < 0 {
+			packed = append(packed, b[1:1+int(-c)]...)
+			b = b[1+int(-c):]
+		} else {
+			packed = append(packed, bytes.Repeat(b[1:2], int(c)+3)...)
+			b = b[2:]
+		}
+	}
+	out := make([]bool, n)
+	for i := range out {
+		out[i] = packed[i/8]&(0x80>>(i%8)) != 0
+	}
+	return out
+}
+
+// readFile decodes a file written by Writer into its type kinds and rows.
+func readFile(t *testing.T, data []byte) ([]uint64, [][]any) {
+	t.Helper()
+	require.True(t, bytes.HasPrefix(data, []byte(magic)))
+	psLen := int(data[len(data)-1])
+	ps := data[len(data)-1-psLen : len(data)-1]
+	assert.Equal(t, uint64(0), pbUint(t, ps, 2), "uncompressed")
+	footerLen := int(pbUint(t, ps, 1))
+	footer := data[len(data)-1-psLen-footerLen : len(data)-1-psLen]
+
+	var kinds []uint64
+	var stripes [][]byte
+	for _, f := range pbFields(t, footer) {
+		switch f.num {
+		case 3:
+			stripes = append(stripes, f.b)
+		case 4:
+			kinds = append(kinds, pbUint(t, f.b, 1))
+		}
+	}
+	require.Equal(t, uint64(typeStruct), kinds[0])
+
+	var rows [][]any
+	for _, s := range stripes {
+		offset := int(pbUint(t, s, 1))
+		dataLen := int(pbUint(t, s, 3))
+		n := int(pbUint(t, s, 5))
+		sf := data[offset+dataLen : offset+dataLen+int(pbUint(t, s, 4))]
+		streams := map[[2]uint64][]byte{}
+		pos := offset
+		for _, f := range pbFields(t, sf) {
+			switch f.num {
+			case 1:
+				l := int(pbUint(t, f.b, 3))
+				streams[[2]uint64{pbUint(t, f.b, 2), pbUint(t, f.b, 1)}] = data[pos : pos+l]
+				pos += l
+			case 3:
+				assert.Equal(t, "UTC", string(f.b))
+			}
+		}
+		require.Equal(t, offset+dataLen, pos)
+
+		stripeRows := make([][]any, n)
+		for r := range stripeRows {
+			stripeRows[r] = make([]any, len(kinds)-1)
+		}
+		for c := 1; c < len(kinds); c++ {
+			col := uint64(c)
+			present := make([]bool, n)
+			for i := range present {
+				present[i] = true
+			}
+			if p, ok := streams[[2]uint64{col, streamPresent}]; ok {
+				present = decodeBooleans(t, p, n)
+			}
+			count := 0
+			for _, p := range present {
+				if p {
+					count++
+				}
+			}
+			var vals []any
+			dataStream := streams[[2]uint64{col, streamData}]
+			switch kinds[c] {
+			case typeBoolean:
+				for _, v := range decodeBooleans(t, dataStream, count) {
+					vals = append(vals, v)
+				}
+			case typeLong:
+				for _, v := range decodeInts(t, dataStream, count) {
+					vals = append(vals, protowire.DecodeZigZag(v))
+				}
+			case typeString:
+				for _, l := range decodeInts(t, streams[[2]uint64{col, streamLength}], count) {
+					vals = append(vals, string(dataStream[:l]))
+					dataStream = dataStream[l:]
+				}
+			case typeTimestamp:
+				secs := decodeInts(t, dataStream, count)
+				nanos := decodeInts(t, streams[[2]uint64{col, streamSecondary}], count)
+				for i := range secs {
+					s := protowire.DecodeZigZag(secs[i]) + timestampEpoch
+					ns := int64(nanos[i] >> 3)
+					if z := nanos[i] & 7; z != 0 {
+						ns *= int64(math.Pow10(int(z) + 1))
+					}
+					if s < 0 && ns > 999999 {
+						s--
+					}
+					vals = append(vals, time.Unix(s, ns).UTC())
+				}
+			}
+			k := 0
+			for r := 0; r < n; r++ {
+				if present[r] {
+					stripeRows[r][c-1] = vals[k]
+					k++
+				}
+			}
+		}
+		rows = append(rows, stripeRows...)
+	}
+	return kinds, rows
+}
+
+var testFields = []Field{
+	{Name: "bucket", Kind: String},
+	{Name: "key", Kind: String},
+	{Name: "is_latest", Kind: Boolean},
+	{Name: "size", Kind: Long},
+	{Name: "last_modified_date", Kind: Timestamp},
+}
+
+func TestWriter_RoundTrip(t *testing.T) {
+	mod := time.Date(2026, 3, 4, 5, 6, 7, 8e6, time.UTC)
+	rows := [][]any{
+		{"photos", "a.jpg", true, int64(1024), mod},
+		{"photos", "b.jpg", nil, nil, nil},
+		{"photos", "ünïcode/c", false, int64(-1), time.Date(1969, 12, 31, 23, 59, 58, 5e8, time.UTC)},
+		{"photos", "", true, int64(math.MaxInt64), time.Date(2015, 1, 1, 0, 0, 0, 123456789, time.UTC)},
+	}
+
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, testFields)
+	require.NoError(t, err)
+	for _, r := range rows {
+		require.NoError(t, w.Write(r))
+	}
+	require.NoError(t, w.Close())
+
+	kinds, got := readFile(t, buf.Bytes())
+	assert.Equal(t, []uint64{typeStruct, typeString, typeString, typeBoolean, typeLong, typeTimestamp}, kinds)
+	assert.Equal(t, rows, got)
+}
+
+func TestWriter_Stripes(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, testFields[1:4])
+	require.NoError(t, err)
+	w.StripeRows = 400
+	var rows [][]any
+	for i := 0; i < 1000; i++ {
+		row := []any{strings.Repeat("k", i%7), i%2 == 0, int64(i * 1000)}
+		if i%5 == 0 {
+			row[1] = nil
+		}
+		rows = append(rows, row)
+		require.NoError(t, w.Write(row))
+	}
+	require.NoError(t, w.Close())
+
+	_, got := readFile(t, buf.Bytes())
+	assert.Equal(t, rows, got)
+}
+
+func TestWriter_Errors(t *testing.T) {
+	_, err := NewWriter(&bytes.Buffer{}, nil)
+	assert.Error(t, err)
+	_, err = NewWriter(&bytes.Buffer{}, []Field{{Name: "x", Kind: Kind(9)}})
+	assert.Error(t, err)
+
+	w, err := NewWriter(&bytes.Buffer{}, testFields)
+	require.NoError(t, err)
+	assert.ErrorContains(t, w.Write([]any{"b"}), "1 values")
+	assert.ErrorContains(t, w.Write([]any{"b", "k", nil, 12, nil}), "unexpected value of type int")
+	require.NoError(t, w.Close())
+	assert.Error(t, w.Write([]any{"b", "k", nil, nil, nil}))
+}
+
+func TestEncodeInts(t *testing.T) {
+	// The DIRECT example from the ORC specification.
+	assert.Equal(t,
+		[]byte{0x5e, 0x03, 0x5c, 0xa1, 0xab, 0x1e, 0xde, 0xad, 0xbe, 0xef},
+		encodeInts([]uint64{23713, 43806, 57005, 48879}))
+
+	long := make([]uint64, 600)
+	for i := range long {
+		long[i] = uint64(i)
+	}
+	enc := encodeInts(long)
+	assert.Equal(t, []byte{0x40 | 15<<1 | 1, 0xff}, enc[:2], "512-value run of 16-bit values")
+	assert.Equal(t, long, decodeInts(t, enc, len(long)))
+}
+
+func TestEncodeBooleans(t *testing.T) {
+	assert.Equal(t, []byte{0xfe, 0x44, 0x45}, encodeBytes([]byte{0x44, 0x45}))
+	assert.Equal(t, []byte{0xff, 0xa0}, encodeBooleans([]bool{true, false, true}))
+	assert.Len(t, encodeBytes(make([]byte, 300)), 303)
+}
+
+func TestFormatNanos(t *testing.T) {
+	assert.Equal(t, uint64(0), formatNanos(0))
+	assert.Equal(t, uint64(1001)<<3, formatNanos(1001))
+	assert.Equal(t, uint64(8)<<3|5, formatNanos(8e6))
+	assert.Equal(t, uint64(1)<<3|7, formatNanos(1e8))
+}
+
+func TestSchema(t *testing.T) {
+	assert.Equal(t,
+		"struct<bucket:string,key:string,is_latest:boolean,size:bigint,last_modified_date:timestamp>",
+		Schema(testFields))
+}
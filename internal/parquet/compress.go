@@ -0,0 +1,39 @@
+package parquet
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+
+	"github.com/klauspost/compress/snappy"
+	"github.com/klauspost/compress/zstd"
+)
+
+// zstdDecoder is shared; DecodeAll is safe for concurrent use.
+var zstdDecoder, _ = zstd.NewReader(nil, zstd.WithDecoderConcurrency(0))
+
+// decompress expands a page body compressed with codec. size is the
+// uncompressed size recorded in the page header.
+func decompress(codec int32, src []byte, size int) ([]byte, error) {
+	switch codec {
+	case codecUncompressed:
+		return src, nil
+	case codecSnappy:
+		return snappy.Decode(nil, src)
+	case codecGzip:
+		zr, err := gzip.NewReader(bytes.NewReader(src))
+		if err != nil {
+			return nil, err
+		}
+		defer func() { _ = zr.Close() }()
+		out := bytes.NewBuffer(make([]byte, 0, size))
+		if _, err := io.Copy(out, io.LimitReader(zr, int64(size)+1)); err != nil {
+			return nil, err
+		}
+		return out.Bytes(), nil
+	case codecZstd:
+		return zstdDecoder.DecodeAll(src, make([]byte, 0, size))
+	}
+	return nil, fmt.Errorf("parquet: unsupported compression codec %d", codec)
+}
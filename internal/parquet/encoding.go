@@ -0,0 +1,344 @@
+package parquet
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"math/bits"
+)
+
+var errPageTruncated = errors.New("parquet: truncated page")
+
+// bitWidth returns the number of bits needed to store values up to max.
+func bitWidth(max int) int {
+	return bits.Len(uint(max))
+}
+
+// unpackBits reads n values of the given width, least significant bit
+// first, as used by the bit-packed runs of the RLE hybrid and delta
+// encodings.
+func unpackBits(buf []byte, width, n int, fn func(uint64)) error {
+	if width == 0 {
+		for i := 0; i < n; i++ {
+			fn(0)
+		}
+		return nil
+	}
+	if width > 64 {
+		return fmt.Errorf("parquet: bit width %d out of range", width)
+	}
+	if (n*width+7)/8 > len(buf) {
+		return errPageTruncated
+	}
+	bitPos := 0
+	for i := 0; i < n; i++ {
+		var v uint64
+		for got := 0; got < width; {
+			byteIdx := bitPos / 8
+			off := bitPos % 8
+			take := 8 - off
+			if take > width-got {
+				take = width - got
+			}
+			chunk := uint64(buf[byteIdx]>>off) & (1<<take - 1)
+			v |= chunk << got
+			got += take
+			bitPos += take
+		}
+		fn(v)
+	}
+	return nil
+}
+
+// decodeRLEHybrid decodes n values of the RLE/bit-packed hybrid encoding
+// (without a length prefix). It returns the number of bytes consumed.
+func decodeRLEHybrid(buf []byte, width, n int) ([]int32, int, error) {
+	out := make([]int32, 0, n)
+	pos := 0
+	for len(out) < n {
+		header, k := binary.Uvarint(buf[pos:])
+		if k <= 0 {
+			return nil, 0, errPageTruncated
+		}
+		pos += k
+		if header&1 == 0 {
+			count := int(header >> 1)
+			byteWidth := (width + 7) / 8
+			if pos+byteWidth > len(buf) {
+				return nil, 0, errPageTruncated
+			}
+			var v uint64
+			for i := 0; i < byteWidth; i++ {
+				v |= uint64(buf[pos+i]) << (8 * i)
+			}
+			pos += byteWidth
+			if count > n-len(out) {
+				count = n - len(out)
+			}
+			for i := 0; i < count; i++ {
+				out = append(out, int32(v))
+			}
+			continue
+		}
+		groups := int(header >> 1)
+		size := groups * width
+		if groups == 0 || pos+size > len(buf) {
+			return nil, 0, errPageTruncated
+		}
+		count := groups * 8
+		err := unpackBits(buf[pos:pos+size], width, count, func(v uint64) {
+			if len(out) < n {
+				out = append(out, int32(v))
+			}
+		})
+		if err != nil {
+			return nil, 0, err
+		}
+		pos += size
+	}
+	return out, pos, nil
+}
+
+// decodeLevels decodes n repetition or definition levels with the given
+// maximum.
+func decodeLevels(buf []byte, max, n int) ([]int32, error) {
+	levels, _, err := decodeRLEHybrid(buf, bitWidth(max), n)
+	return levels, err
+}
+
+// decodePlain decodes n PLAIN-encoded values of physical type t. It
+// returns the values in their raw Go form: bool, int32, int64, [12]byte,
+// float32, float64 or []byte.
+func decodePlain(t Type, typeLen int, buf []byte, n int) ([]any, error) {
+	out := make([]any, 0, n)
+	fixed := func(width int) error {
+		if n*width > len(buf) {
+			return errPageTruncated
+		}
+		return nil
+	}
+	switch t {
+	case Boolean:
+		if (n+7)/8 > len(buf) {
+			return nil, errPageTruncated
+		}
+		for i := 0; i < n; i++ {
+			out = append(out, buf[i/8]&(1<<(i%8)) != 0)
+		}
+	case Int32:
+		if err := fixed(4); err != nil {
+			return nil, err
+		}
+		for i := 0; i < n; i++ {
+			out = append(out, int32(binary.LittleEndian.Uint32(buf[i*4:])))
+		}
+	case Int64:
+		if err := fixed(8); err != nil {
+			return nil, err
+		}
+		for i := 0; i < n; i++ {
+			out = append(out, int64(binary.LittleEndian.Uint64(buf[i*8:])))
+		}
+	case Int96:
+		if err := fixed(12); err != nil {
+			return nil, err
+		}
+		for i := 0; i < n; i++ {
+			var v [12]byte
+			copy(v[:], buf[i*12:])
+			out = append(out, v)
+		}
+	case Float:
+		if err := fixed(4); err != nil {
+			return nil, err
+		}
+		for i := 0; i < n; i++ {
+			out = append(out, math.Float32frombits(binary.LittleEndian.Uint32(buf[i*4:])))
+		}
+	case Double:
+		if err := fixed(8); err != nil {
+			return nil, err
+		}
+		for i := 0; i < n; i++ {
+			out = append(out, math.Float64frombits(binary.LittleEndian.Uint64(buf[i*8:])))
+		}
+	case ByteArray:
+		pos := 0
+		for i := 0; i < n; i++ {
+			if pos+4 > len(buf) {
+				return nil, errPageTruncated
+			}
+			l := int(binary.LittleEndian.Uint32(buf[pos:]))
+			pos += 4
+			if l < 0 || pos+l > len(buf) {
+				return nil, errPageTruncated
+			}
+			out = append(out, buf[pos:pos+l])
+			pos += l
+		}
+	case FixedLenByteArray:
+		if typeLen <= 0 {
+			return nil, fmt.Errorf("parquet: invalid fixed length %d", typeLen)
+		}
+		if err := fixed(typeLen); err != nil {
+			return nil, err
+		}
+		for i := 0; i < n; i++ {
+			out = append(out, buf[i*typeLen:(i+1)*typeLen])
+		}
+	default:
+		return nil, fmt.Errorf("parquet: unknown physical type %d", t)
+	}
+	return out, nil
+}
+
+// decodeDeltaBinaryPacked decodes a DELTA_BINARY_PACKED run. It returns
+// the values and the number of bytes consumed.
+func decodeDeltaBinaryPacked(buf []byte) ([]int64, int, error) {
+	pos := 0
+	next := func() (uint64, error) {
+		v, k := binary.Uvarint(buf[pos:])
+		if k <= 0 {
+			return 0, errPageTruncated
+		}
+		pos += k
+		return v, nil
+	}
+	blockSize, err := next()
+	if err != nil {
+		return nil, 0, err
+	}
+	miniBlocks, err := next()
+	if err != nil {
+		return nil, 0, err
+	}
+	total, err := next()
+	if err != nil {
+		return nil, 0, err
+	}
+	firstZZ, err := next()
+	if err != nil {
+		return nil, 0, err
+	}
+	if miniBlocks == 0 || blockSize%miniBlocks != 0 || total > uint64(len(buf))*64 {
+		return nil, 0, errors.New("parquet: invalid delta header")
+	}
+	perMini := int(blockSize / miniBlocks)
+
+	out := make([]int64, 0, total)
+	if total == 0 {
+		return out, pos, nil
+	}
+	last := int64(firstZZ>>1) ^ -int64(firstZZ&1)
+	out = append(out, last)
+	for uint64(len(out)) < total {
+		minZZ, err := next()
+		if err != nil {
+			return nil, 0, err
+		}
+		minDelta := int64(minZZ>>1) ^ -int64(minZZ&1)
+		if pos+int(miniBlocks) > len(buf) {
+			return nil, 0, errPageTruncated
+		}
+		widths := buf[pos : pos+int(miniBlocks)]
+		pos += int(miniBlocks)
+		for _, w := range widths {
+			if uint64(len(out)) >= total {
+				break
+			}
+			size := perMini * int(w) / 8
+			if pos+size > len(buf) {
+				return nil, 0, errPageTruncated
+			}
+			err := unpackBits(buf[pos:pos+size], int(w), perMini, func(d uint64) {
+				if uint64(len(out)) < total {
+					last += minDelta + int64(d)
+					out = append(out, last)
+				}
+			})
+			if err != nil {
+				return nil, 0, err
+			}
+			pos += size
+		}
+	}
+	return out, pos, nil
+}
+
+// decodeDeltaLengthByteArray decodes n DELTA_LENGTH_BYTE_ARRAY values and
+// returns the number of bytes consumed.
+func decodeDeltaLengthByteArray(buf []byte, n int) ([][]byte, int, error) {
+	lengths, pos, err := decodeDeltaBinaryPacked(buf)
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(lengths) < n {
+		return nil, 0, errPageTruncated
+	}
+	out := make([][]byte, n)
+	for i := 0; i < n; i++ {
+		l := int(lengths[i])
+		if l < 0 || pos+l > len(buf) {
+			return nil, 0, errPageTruncated
+		}
+		out[i] = buf[pos : pos+l]
+		pos += l
+	}
+	return out, pos, nil
+}
+
+// decodeDeltaByteArray decodes n DELTA_BYTE_ARRAY (incremental) values.
+func decodeDeltaByteArray(buf []byte, n int) ([][]byte, error) {
+	prefixes, pos, err := decodeDeltaBinaryPacked(buf)
+	if err != nil {
+		return nil, err
+	}
+	suffixes, _, err := decodeDeltaLengthByteArray(buf[pos:], n)
+	if err != nil {
+		return nil, err
+	}
+	if len(prefixes) < n {
+		return nil, errPageTruncated
+	}
+	out := make([][]byte, n)
+	var prev []byte
+	for i := 0; i < n; i++ {
+		p := int(prefixes[i])
+		if p < 0 || p > len(prev) {
+			return nil, errors.New("parquet: invalid delta prefix")
+		}
+		v := make([]byte, 0, p+len(suffixes[i]))
+		v = append(v, prev[:p]...)
+		v = append(v, suffixes[i]...)
+		out[i] = v
+		prev = v
+	}
+	return out, nil
+}
+
+// decodeByteStreamSplit decodes n BYTE_STREAM_SPLIT values of a fixed-width
+// type.
+func decodeByteStreamSplit(t Type, typeLen int, buf []byte, n int) ([]any, error) {
+	var width int
+	switch t {
+	case Int32, Float:
+		width = 4
+	case Int64, Double:
+		width = 8
+	case FixedLenByteArray:
+		width = typeLen
+	default:
+		return nil, fmt.Errorf("parquet: BYTE_STREAM_SPLIT not valid for %s", t)
+	}
+	if width <= 0 || n*width > len(buf) {
+		return nil, errPageTruncated
+	}
+	joined := make([]byte, n*width)
+	for i := 0; i < n; i++ {
+		for k := 0; k < width; k++ {
+			joined[i*width+k] = buf[k*n+i]
+		}
+	}
+	return decodePlain(t, typeLen, joined, n)
+}
@@ -0,0 +1,387 @@
+package parquet
+
+// Type is a Parquet physical type.
+type Type int32
+
+// Physical types.
+const (
+	Boolean           Type = 0
+	Int32             Type = 1
+	Int64             Type = 2
+	Int96             Type = 3
+	Float             Type = 4
+	Double            Type = 5
+	ByteArray         Type = 6
+	FixedLenByteArray Type = 7
+)
+
+func (t Type) String() string {
+	switch t {
+	case Boolean:
+		return "BOOLEAN"
+	case Int32:
+		return "INT32"
+	case Int64:
+		return "INT64"
+	case Int96:
+		return "INT96"
+	case Float:
+		return "FLOAT"
+	case Double:
+		return "DOUBLE"
+	case ByteArray:
+		return "BYTE_ARRAY"
+	case FixedLenByteArray:
+		return "FIXED_LEN_BYTE_ARRAY"
+	}
+	return "UNKNOWN"
+}
+
+// Field repetition types.
+const (
+	repRequired = 0
+	repOptional = 1
+	repRepeated = 2
+)
+
+// Converted (legacy logical) types used by the reader.
+const (
+	convUTF8            = 0
+	convDecimal         = 5
+	convDate            = 6
+	convTimestampMillis = 9
+	convTimestampMicros = 10
+	convJSON            = 19
+)
+
+// Logical type union members, keyed by their thrift field id.
+const (
+	logicalString    = 1
+	logicalDecimal   = 5
+	logicalDate      = 6
+	logicalTimestamp = 8
+	logicalJSON      = 12
+	logicalUUID      = 14
+)
+
+// Time units for logical TIMESTAMP, keyed by their thrift field id.
+const (
+	unitMillis = 1
+	unitMicros = 2
+	unitNanos  = 3
+)
+
+// Compression codecs.
+const (
+	codecUncompressed = 0
+	codecSnappy       = 1
+	codecGzip         = 2
+	codecZstd         = 6
+)
+
+// Page types.
+const (
+	pageData       = 0
+	pageDictionary = 2
+	pageDataV2     = 3
+)
+
+// Encodings.
+const (
+	encPlain                = 0
+	encPlainDictionary      = 2
+	encRLE                  = 3
+	encDeltaBinaryPacked    = 5
+	encDeltaLengthByteArray = 6
+	encDeltaByteArray       = 7
+	encRLEDictionary        = 8
+	encByteStreamSplit      = 9
+)
+
+type fileMetaData struct {
+	Version   int32
+	Schema    []schemaElement
+	NumRows   int64
+	RowGroups []rowGroup
+	CreatedBy string
+}
+
+type schemaElement struct {
+	Type          Type
+	HasType       bool
+	TypeLength    int32
+	Repetition    int32
+	Name          string
+	NumChildren   int32
+	ConvertedType int32 // -1 when absent
+	Scale         int32
+	Precision     int32
+	Logical       int16 // logical type union member, 0 when absent
+	TimeUnit      int16
+}
+
+type rowGroup struct {
+	Columns       []columnChunk
+	TotalByteSize int64
+	NumRows       int64
+}
+
+type columnChunk struct {
+	FileOffset int64
+	Meta       columnMetaData
+}
+
+type columnMetaData struct {
+	Type                 Type
+	Encodings            []int32
+	Path                 []string
+	Codec                int32
+	NumValues            int64
+	TotalUncompressed    int64
+	TotalCompressedSize  int64
+	DataPageOffset       int64
+	DictionaryPageOffset int64
+	HasDictionary        bool
+}
+
+type pageHeader struct {
+	Type             int32
+	UncompressedSize int32
+	CompressedSize   int32
+	Data             *dataPageHeader
+	Dictionary       *dictionaryPageHeader
+	DataV2           *dataPageHeaderV2
+}
+
+type dataPageHeader struct {
+	NumValues int32
+	Encoding  int32
+}
+
+type dictionaryPageHeader struct {
+	NumValues int32
+	Encoding  int32
+}
+
+type dataPageHeaderV2 struct {
+	NumValues    int32
+	NumNulls     int32
+	NumRows      int32
+	Encoding     int32
+	DefLevelsLen int32
+	RepLevelsLen int32
+	IsCompressed bool
+}
+
+func readFileMetaData(r *compactReader) fileMetaData {
+	var m fileMetaData
+	r.readStruct(func(id int16, typ byte) {
+		switch id {
+		case 1:
+			m.Version = r.i32()
+		case 2:
+			r.list(func(byte) { m.Schema = append(m.Schema, readSchemaElement(r)) })
+		case 3:
+			m.NumRows = r.i64()
+		case 4:
+			r.list(func(byte) { m.RowGroups = append(m.RowGroups, readRowGroup(r)) })
+		case 6:
+			m.CreatedBy = r.string()
+		default:
+			r.skip(typ)
+		}
+	})
+	return m
+}
+
+func readSchemaElement(r *compactReader) schemaElement {
+	e := schemaElement{ConvertedType: -1}
+	r.readStruct(func(id int16, typ byte) {
+		switch id {
+		case 1:
+			e.Type = Type(r.i32())
+			e.HasType = true
+		case 2:
+			e.TypeLength = r.i32()
+		case 3:
+			e.Repetition = r.i32()
+		case 4:
+			e.Name = r.string()
+		case 5:
+			e.NumChildren = r.i32()
+		case 6:
+			e.ConvertedType = r.i32()
+		case 7:
+			e.Scale = r.i32()
+		case 8:
+			e.Precision = r.i32()
+		case 10:
+			readLogicalType(r, &e)
+		default:
+			r.skip(typ)
+		}
+	})
+	return e
+}
+
+// readLogicalType reads the LogicalType union. Only the members the reader
+// converts are decoded; the rest are recorded by id and skipped.
+func readLogicalType(r *compactReader, e *schemaElement) {
+	r.readStruct(func(id int16, typ byte) {
+		e.Logical = id
+		switch id {
+		case logicalDecimal:
+			r.readStruct(func(id int16, typ byte) {
+				switch id {
+				case 1:
+					e.Scale = r.i32()
+				case 2:
+					e.Precision = r.i32()
+				default:
+					r.skip(typ)
+				}
+			})
+		case logicalTimestamp:
+			r.readStruct(func(id int16, typ byte) {
+				if id == 2 {
+					r.readStruct(func(unit int16, typ byte) {
+						e.TimeUnit = unit
+						r.skip(typ)
+					})
+					return
+				}
+				r.skip(typ)
+			})
+		default:
+			r.skip(typ)
+		}
+	})
+}
+
+func readRowGroup(r *compactReader) rowGroup {
+	var g rowGroup
+	r.readStruct(func(id int16, typ byte) {
+		switch id {
+		case 1:
+			r.list(func(byte) { g.Columns = append(g.Columns, readColumnChunk(r)) })
+		case 2:
+			g.TotalByteSize = r.i64()
+		case 3:
+			g.NumRows = r.i64()
+		default:
+			r.skip(typ)
+		}
+	})
+	return g
+}
+
+func readColumnChunk(r *compactReader) columnChunk {
+	var c columnChunk
+	r.readStruct(func(id int16, typ byte) {
+		switch id {
+		case 2:
+			c.FileOffset = r.i64()
+		case 3:
+			c.Meta = readColumnMetaData(r)
+		default:
+			r.skip(typ)
+		}
+	})
+	return c
+}
+
+func readColumnMetaData(r *compactReader) columnMetaData {
+	var m columnMetaData
+	r.readStruct(func(id int16, typ byte) {
+		switch id {
+		case 1:
+			m.Type = Type(r.i32())
+		case 2:
+			r.list(func(byte) { m.Encodings = append(m.Encodings, r.i32()) })
+		case 3:
+			r.list(func(byte) { m.Path = append(m.Path, r.string()) })
+		case 4:
+			m.Codec = r.i32()
+		case 5:
+			m.NumValues = r.i64()
+		case 6:
+			m.TotalUncompressed = r.i64()
+		case 7:
+			m.TotalCompressedSize = r.i64()
+		case 9:
+			m.DataPageOffset = r.i64()
+		case 11:
+			m.DictionaryPageOffset = r.i64()
+			m.HasDictionary = true
+		default:
+			r.skip(typ)
+		}
+	})
+	return m
+}
+
+func readPageHeader(r *compactReader) pageHeader {
+	var h pageHeader
+	r.readStruct(func(id int16, typ byte) {
+		switch id {
+		case 1:
+			h.Type = r.i32()
+		case 2:
+			h.UncompressedSize = r.i32()
+		case 3:
+			h.CompressedSize = r.i32()
+		case 5:
+			d := &dataPageHeader{}
+			r.readStruct(func(id int16, typ byte) {
+				switch id {
+				case 1:
+					d.NumValues = r.i32()
+				case 2:
+					d.Encoding = r.i32()
+				default:
+					r.skip(typ)
+				}
+			})
+			h.Data = d
+		case 7:
+			d := &dictionaryPageHeader{}
+			r.readStruct(func(id int16, typ byte) {
+				switch id {
+				case 1:
+					d.NumValues = r.i32()
+				case 2:
+					d.Encoding = r.i32()
+				default:
+					r.skip(typ)
+				}
+			})
+			h.Dictionary = d
+		case 8:
+			d := &dataPageHeaderV2{IsCompressed: true}
+			r.readStruct(func(id int16, typ byte) {
+				switch id {
+				case 1:
+					d.NumValues = r.i32()
+				case 2:
+					d.NumNulls = r.i32()
+				case 3:
+					d.NumRows = r.i32()
+				case 4:
+					d.Encoding = r.i32()
+				case 5:
+					d.DefLevelsLen = r.i32()
+				case 6:
+					d.RepLevelsLen = r.i32()
+				case 7:
+					d.IsCompressed = boolField(typ)
+				default:
+					r.skip(typ)
+				}
+			})
+			h.DataV2 = d
+		default:
+			r.skip(typ)
+		}
+	})
+	return h
+}
@@ -0,0 +1,472 @@
+// Package parquet reads and writes flat Parquet files.
+//
+// The reader covers what S3 Select needs: the footer, the schema's leaf
+// columns and their values, with PLAIN, dictionary, RLE, delta and
+// byte-stream-split encodings and the UNCOMPRESSED, SNAPPY, GZIP and ZSTD
+// codecs. Columns nested inside repeated groups (lists and maps) are
+// reported but cannot be read. The writer covers what inventory reports
+// need: required and optional primitive columns in PLAIN encoding.
+package parquet
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"strings"
+	"time"
+)
+
+const magic = "PAR1"
+
+// Bounds that keep a hostile file from forcing huge allocations.
+const (
+	maxFooterSize = 64 << 20
+	maxPageSize   = 256 << 20
+)
+
+// ErrNotParquet is returned by Open when the input lacks the Parquet magic.
+var ErrNotParquet = errors.New("parquet: not a parquet file")
+
+// File is an open Parquet file.
+type File struct {
+	r       io.ReaderAt
+	size    int64
+	meta    fileMetaData
+	columns []Column
+}
+
+// Column describes a leaf column of the schema.
+type Column struct {
+	Name     string // dotted path from the root
+	Path     []string
+	Type     Type
+	Optional bool
+	Repeated bool // inside a repeated group; not readable
+
+	elem   schemaElement
+	maxDef int
+}
+
+// Open reads the footer of the Parquet file in r, which is size bytes long.
+func Open(r io.ReaderAt, size int64) (*File, error) {
+	if size < int64(len(magic))*2+4 {
+		return nil, ErrNotParquet
+	}
+	head := make([]byte, 4)
+	if _, err := r.ReadAt(head, 0); err != nil {
+		return nil, fmt.Errorf("parquet: read header: %w", err)
+	}
+	tail := make([]byte, 8)
+	if _, err := r.ReadAt(tail, size-8); err != nil {
+		return nil, fmt.Errorf("parquet: read footer: %w", err)
+	}
+	if string(head) != magic || string(tail[4:]) != magic {
+		return nil, ErrNotParquet
+	}
+	footerLen := int64(binary.LittleEndian.Uint32(tail))
+	if footerLen > maxFooterSize || footerLen > size-12 {
+		return nil, fmt.Errorf("parquet: invalid footer length %d", footerLen)
+	}
+	footer := make([]byte, footerLen)
+	if _, err := r.ReadAt(footer, size-8-footerLen); err != nil {
+		return nil, fmt.Errorf("parquet: read footer: %w", err)
+	}
+	cr := &compactReader{buf: footer}
+	meta := readFileMetaData(cr)
+	if cr.err != nil {
+		return nil, fmt.Errorf("parquet: decode footer: %w", cr.err)
+	}
+
+	f := &File{r: r, size: size, meta: meta}
+	if err := f.buildColumns(); err != nil {
+		return nil, err
+	}
+	for i, rg := range meta.RowGroups {
+		if len(rg.Columns) != len(f.columns) {
+			return nil, fmt.Errorf("parquet: row group %d has %d columns, schema has %d",
+				i, len(rg.Columns), len(f.columns))
+		}
+	}
+	return f, nil
+}
+
+// buildColumns flattens the schema tree into its leaf columns.
+func (f *File) buildColumns() error {
+	schema := f.meta.Schema
+	if len(schema) == 0 {
+		return errors.New("parquet: empty schema")
+	}
+	pos := 1
+	var walk func(n int, path []string, maxDef int, repeated bool) error
+	walk = func(n int, path []string, maxDef int, repeated bool) error {
+		for i := 0; i < n; i++ {
+			if pos >= len(schema) {
+				return errors.New("parquet: schema children exceed elements")
+			}
+			e := schema[pos]
+			pos++
+			def, rep := maxDef, repeated
+			switch e.Repetition {
+			case repOptional:
+				def++
+			case repRepeated:
+				def++
+				rep = true
+			}
+			p := append(path[:len(path):len(path)], e.Name)
+			if e.NumChildren > 0 {
+				if err := walk(int(e.NumChildren), p, def, rep); err != nil {
+					return err
+				}
+				continue
+			}
+			f.columns = append(f.columns, Column{
+				Name:     strings.Join(p, "."),
+				Path:     p,
+				Type:     e.Type,
+				Optional: def > 0,
+				Repeated: rep,
+				elem:     e,
+				maxDef:   def,
+			})
+		}
+		return nil
+	}
+	if err := walk(int(schema[0].NumChildren), nil, 0, false); err != nil {
+		return err
+	}
+	if pos != len(schema) {
+		return errors.New("parquet: schema has unreachable elements")
+	}
+	return nil
+}
+
+// Columns returns the leaf columns in schema order.
+func (f *File) Columns() []Column { return f.columns }
+
+// NumRows returns the total number of rows.
+func (f *File) NumRows() int64 { return f.meta.NumRows }
+
+// NumRowGroups returns the number of row groups.
+func (f *File) NumRowGroups() int { return len(f.meta.RowGroups) }
+
+// RowGroupNumRows returns the number of rows in row group i.
+func (f *File) RowGroupNumRows(i int) int64 { return f.meta.RowGroups[i].NumRows }
+
+// ReadRowGroup reads the given columns of row group i. It returns one slice
+// per requested column, each holding one value per row: nil for nulls,
+// otherwise bool, int64, float64, string or time.Time.
+func (f *File) ReadRowGroup(i int, columns []int) ([][]any, error) {
+	if i < 0 || i >= len(f.meta.RowGroups) {
+		return nil, fmt.Errorf("parquet: row group %d out of range", i)
+	}
+	out := make([][]any, len(columns))
+	for k, c := range columns {
+		vals, err := f.readColumnChunk(i, c)
+		if err != nil {
+			return nil, err
+		}
+		out[k] = vals
+	}
+	return out, nil
+}
+
+func (f *File) readColumnChunk(group, col int) ([]any, error) {
+	if col < 0 || col >= len(f.columns) {
+		return nil, fmt.Errorf("parquet: column %d out of range", col)
+	}
+	c := &f.columns[col]
+	if c.Repeated {
+		return nil, fmt.Errorf("parquet: column %s is inside a repeated group, which is not supported", c.Name)
+	}
+	rg := f.meta.RowGroups[group]
+	m := rg.Columns[col].Meta
+
+	start := m.DataPageOffset
+	if m.HasDictionary && m.DictionaryPageOffset > 0 && m.DictionaryPageOffset < start {
+		start = m.DictionaryPageOffset
+	}
+	if start < int64(len(magic)) || m.TotalCompressedSize < 0 || start+m.TotalCompressedSize > f.size {
+		return nil, fmt.Errorf("parquet: column %s chunk out of bounds", c.Name)
+	}
+	buf := make([]byte, m.TotalCompressedSize)
+	if _, err := f.r.ReadAt(buf, start); err != nil {
+		return nil, fmt.Errorf("parquet: read column %s: %w", c.Name, err)
+	}
+
+	out := make([]any, 0, rg.NumRows)
+	var dict []any
+	pos := 0
+	for int64(len(out)) < m.NumValues && pos < len(buf) {
+		cr := &compactReader{buf: buf[pos:]}
+		h := readPageHeader(cr)
+		if cr.err != nil {
+			return nil, fmt.Errorf("parquet: column %s page header: %w", c.Name, cr.err)
+		}
+		pos += cr.pos
+		if h.CompressedSize < 0 || int(h.CompressedSize) > len(buf)-pos ||
+			h.UncompressedSize < 0 || h.UncompressedSize > maxPageSize {
+			return nil, fmt.Errorf("parquet: column %s has an invalid page size", c.Name)
+		}
+		body := buf[pos : pos+int(h.CompressedSize)]
+		pos += int(h.CompressedSize)
+
+		var err error
+		switch {
+		case h.Type == pageDictionary && h.Dictionary != nil:
+			dict, err = c.readDictionaryPage(m.Codec, h, body)
+		case h.Type == pageData && h.Data != nil:
+			out, err = c.readDataPage(m.Codec, h, body, dict, out)
+		case h.Type == pageDataV2 && h.DataV2 != nil:
+			out, err = c.readDataPageV2(m.Codec, h, body, dict, out)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("parquet: column %s: %w", c.Name, err)
+		}
+	}
+	if int64(len(out)) != rg.NumRows {
+		return nil, fmt.Errorf("parquet: column %s has %d values, row group has %d rows",
+			c.Name, len(out), rg.NumRows)
+	}
+	return out, nil
+}
+
+func (c *Column) readDictionaryPage(codec int32, h pageHeader, body []byte) ([]any, error) {
+	data, err := decompress(codec, body, int(h.UncompressedSize))
+	if err != nil {
+		return nil, err
+	}
+	return decodePlain(c.Type, int(c.elem.TypeLength), data, int(h.Dictionary.NumValues))
+}
+
+func (c *Column) readDataPage(codec int32, h pageHeader, body []byte, dict, out []any) ([]any, error) {
+	data, err := decompress(codec, body, int(h.UncompressedSize))
+	if err != nil {
+		return nil, err
+	}
+	n := int(h.Data.NumValues)
+	var levels []int32
+	if c.maxDef > 0 {
+		if len(data) < 4 {
+			return nil, errPageTruncated
+		}
+		l := int(binary.LittleEndian.Uint32(data))
+		if l < 0 || 4+l > len(data) {
+			return nil, errPageTruncated
+		}
+		if levels, err = decodeLevels(data[4:4+l], c.maxDef, n); err != nil {
+			return nil, err
+		}
+		data = data[4+l:]
+	}
+	return c.appendValues(out, levels, n, h.Data.Encoding, data, dict)
+}
+
+func (c *Column) readDataPageV2(codec int32, h pageHeader, body []byte, dict, out []any) ([]any, error) {
+	d := h.DataV2
+	levelsLen := int(d.RepLevelsLen) + int(d.DefLevelsLen)
+	if d.RepLevelsLen < 0 || d.DefLevelsLen < 0 || levelsLen > len(body) {
+		return nil, errPageTruncated
+	}
+	n := int(d.NumValues)
+	var levels []int32
+	if c.maxDef > 0 {
+		var err error
+		defBytes := body[d.RepLevelsLen:levelsLen]
+		if levels, err = decodeLevels(defBytes, c.maxDef, n); err != nil {
+			return nil, err
+		}
+	}
+	data := body[levelsLen:]
+	if d.IsCompressed {
+		var err error
+		if data, err = decompress(codec, data, int(h.UncompressedSize)-levelsLen); err != nil {
+			return nil, err
+		}
+	}
+	return c.appendValues(out, levels, n, d.Encoding, data, dict)
+}
+
+// appendValues decodes a page's values and appends n entries to out,
+// placing nil where the definition levels mark a null.
+func (c *Column) appendValues(out []any, levels []int32, n int, encoding int32, data []byte, dict []any) ([]any, error) {
+	present := n
+	if levels != nil {
+		present = 0
+		for _, l := range levels {
+			if int(l) == c.maxDef {
+				present++
+			}
+		}
+	}
+	vals, err := c.decodeValues(encoding, data, present, dict)
+	if err != nil {
+		return nil, err
+	}
+	if len(vals) < present {
+		return nil, errPageTruncated
+	}
+	k := 0
+	for i := 0; i < n; i++ {
+		if levels != nil && int(levels[i]) != c.maxDef {
+			out = append(out, nil)
+			continue
+		}
+		out = append(out, c.convert(vals[k]))
+		k++
+	}
+	return out, nil
+}
+
+func (c *Column) decodeValues(encoding int32, data []byte, n int, dict []any) ([]any, error) {
+	typeLen := int(c.elem.TypeLength)
+	switch encoding {
+	case encPlain:
+		return decodePlain(c.Type, typeLen, data, n)
+	case encPlainDictionary, encRLEDictionary:
+		if n == 0 {
+			return nil, nil
+		}
+		if len(data) < 1 {
+			return nil, errPageTruncated
+		}
+		idx, _, err := decodeRLEHybrid(data[1:], int(data[0]), n)
+		if err != nil {
+			return nil, err
+		}
+		out := make([]any, n)
+		for i, j := range idx {
+			if j < 0 || int(j) >= len(dict) {
+				return nil, fmt.Errorf("parquet: dictionary index %d out of range", j)
+			}
+			out[i] = dict[j]
+		}
+		return out, nil
+	case encRLE:
+		if c.Type != Boolean {
+			return nil, fmt.Errorf("parquet: RLE values not supported for %s", c.Type)
+		}
+		if len(data) < 4 {
+			return nil, errPageTruncated
+		}
+		vals, _, err := decodeRLEHybrid(data[4:], 1, n)
+		if err != nil {
+			return nil, err
+		}
+		out := make([]any, n)
+		for i, v := range vals {
+			out[i] = v != 0
+		}
+		return out, nil
+	case encDeltaBinaryPacked:
+		vals, _, err := decodeDeltaBinaryPacked(data)
+		if err != nil {
+			return nil, err
+		}
+		out := make([]any, len(vals))
+		for i, v := range vals {
+			if c.Type == Int32 {
+				out[i] = int32(v)
+			} else {
+				out[i] = v
+			}
+		}
+		return out, nil
+	case encDeltaLengthByteArray:
+		vals, _, err := decodeDeltaLengthByteArray(data, n)
+		if err != nil {
+			return nil, err
+		}
+		return bytesToAny(vals), nil
+	case encDeltaByteArray:
+		vals, err := decodeDeltaByteArray(data, n)
+		if err != nil {
+			return nil, err
+		}
+		return bytesToAny(vals), nil
+	case encByteStreamSplit:
+		return decodeByteStreamSplit(c.Type, typeLen, data, n)
+	}
+	return nil, fmt.Errorf("parquet: unsupported encoding %d", encoding)
+}
+
+func bytesToAny(vals [][]byte) []any {
+	out := make([]any, len(vals))
+	for i, v := range vals {
+		out[i] = v
+	}
+	return out
+}
+
+// julianUnixEpoch is the Julian day number of 1970-01-01.
+const julianUnixEpoch = 2440588
+
+// convert maps a raw physical value to its logical Go value.
+func (c *Column) convert(v any) any {
+	e := &c.elem
+	isDecimal := e.ConvertedType == convDecimal || e.Logical == logicalDecimal
+	switch x := v.(type) {
+	case int32:
+		switch {
+		case e.ConvertedType == convDate || e.Logical == logicalDate:
+			return time.Unix(int64(x)*86400, 0).UTC()
+		case isDecimal:
+			return scaleDecimal(big.NewInt(int64(x)), e.Scale)
+		}
+		return int64(x)
+	case int64:
+		switch {
+		case e.Logical == logicalTimestamp:
+			switch e.TimeUnit {
+			case unitMillis:
+				return time.UnixMilli(x).UTC()
+			case unitMicros:
+				return time.UnixMicro(x).UTC()
+			case unitNanos:
+				return time.Unix(0, x).UTC()
+			}
+		case e.ConvertedType == convTimestampMillis:
+			return time.UnixMilli(x).UTC()
+		case e.ConvertedType == convTimestampMicros:
+			return time.UnixMicro(x).UTC()
+		case isDecimal:
+			return scaleDecimal(big.NewInt(x), e.Scale)
+		}
+		return x
+	case [12]byte:
+		nanos := int64(binary.LittleEndian.Uint64(x[:8]))
+		day := int64(binary.LittleEndian.Uint32(x[8:]))
+		return time.Unix((day-julianUnixEpoch)*86400, nanos).UTC()
+	case float32:
+		return float64(x)
+	case []byte:
+		switch {
+		case isDecimal:
+			return scaleDecimal(signedBigInt(x), e.Scale)
+		case e.Logical == logicalUUID && len(x) == 16:
+			return fmt.Sprintf("%x-%x-%x-%x-%x", x[0:4], x[4:6], x[6:8], x[8:10], x[10:16])
+		}
+		return string(x)
+	}
+	return v
+}
+
+// signedBigInt decodes a big-endian two's complement integer.
+func signedBigInt(b []byte) *big.Int {
+	i := new(big.Int).SetBytes(b)
+	if len(b) > 0 && b[0]&0x80 != 0 {
+		i.Sub(i, new(big.Int).Lsh(big.NewInt(1), uint(len(b))*8))
+	}
+	return i
+}
+
+func scaleDecimal(unscaled *big.Int, scale int32) float64 {
+	f, _ := new(big.Float).SetInt(unscaled).Float64()
+	if scale != 0 {
+		f /= math.Pow10(int(scale))
+	}
+	return f
+}
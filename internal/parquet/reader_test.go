@@ -0,0 +1,262 @@
+package parquet
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fixtureColumn struct {
+	name      string
+	typ       Type
+	codec     int32
+	encodings []int32
+	pages     [][]byte // encoded page headers followed by bodies
+	hasDict   bool
+}
+
+// page encodes a page header and body.
+func page(typ int32, uncompressed int, body []byte, header func(w *compactWriter)) []byte {
+	w := &compactWriter{}
+	w.begin()
+	w.i32(1, typ)
+	w.i32(2, int32(uncompressed))
+	w.i32(3, int32(len(body)))
+	header(w)
+	w.end()
+	return append(w.buf.Bytes(), body...)
+}
+
+func le32(v int) []byte {
+	b := make([]byte, 4)
+	binary.LittleEndian.PutUint32(b, uint32(v))
+	return b
+}
+
+// buildFixture writes a three-row file with an INT64 id, a dictionary
+// encoded optional string, a DOUBLE in a v2 data page and a gzipped
+// optional DATE.
+func buildFixture(t *testing.T) []byte {
+	t.Helper()
+
+	ids := make([]byte, 0, 24)
+	for _, v := range []int64{1, 2, 3} {
+		ids = binary.LittleEndian.AppendUint64(ids, uint64(v))
+	}
+	idCol := fixtureColumn{name: "id", typ: Int64, encodings: []int32{encPlain},
+		pages: [][]byte{page(pageData, len(ids), ids, func(w *compactWriter) {
+			w.structField(5, func() { w.i32(1, 3); w.i32(2, encPlain); w.i32(3, encRLE); w.i32(4, encRLE) })
+		})}}
+
+	dict := append(append(le32(5), "alpha"...), append(le32(4), "beta"...)...)
+	// Definition levels 1,0,1 then dictionary indices 0,1.
+	nameBody := append(append(le32(2), 3, 0b101), 1, 3, 0b10)
+	nameCol := fixtureColumn{name: "name", typ: ByteArray, hasDict: true,
+		encodings: []int32{encPlain, encRLEDictionary},
+		pages: [][]byte{
+			page(pageDictionary, len(dict), dict, func(w *compactWriter) {
+				w.structField(7, func() { w.i32(1, 2); w.i32(2, encPlain) })
+			}),
+			page(pageData, len(nameBody), nameBody, func(w *compactWriter) {
+				w.structField(5, func() { w.i32(1, 3); w.i32(2, encRLEDictionary); w.i32(3, encRLE); w.i32(4, encRLE) })
+			}),
+		}}
+
+	scores := make([]byte, 0, 24)
+	for _, v := range []float64{1.5, 2.5, -3} {
+		scores = binary.LittleEndian.AppendUint64(scores, math.Float64bits(v))
+	}
+	scoreCol := fixtureColumn{name: "score", typ: Double, codec: codecGzip, encodings: []int32{encPlain},
+		pages: [][]byte{page(pageDataV2, len(scores), scores, func(w *compactWriter) {
+			w.structField(8, func() {
+				w.i32(1, 3)
+				w.i32(2, 0)
+				w.i32(3, 3)
+				w.i32(4, encPlain)
+				w.i32(5, 0)
+				w.i32(6, 0)
+				w.field(7, ctFalse)
+			})
+		})}}
+
+	// Definition levels 1,1,0 then two PLAIN dates.
+	days := append(append(le32(2), 3, 0b011), append(le32(19000), le32(19001)...)...)
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	_, err := zw.Write(days)
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+	dayCol := fixtureColumn{name: "day", typ: Int32, codec: codecGzip, encodings: []int32{encPlain},
+		pages: [][]byte{page(pageData, len(days), gz.Bytes(), func(w *compactWriter) {
+			w.structField(5, func() { w.i32(1, 3); w.i32(2, encPlain); w.i32(3, encRLE); w.i32(4, encRLE) })
+		})}}
+
+	cols := []fixtureColumn{idCol, nameCol, scoreCol, dayCol}
+	var file bytes.Buffer
+	file.WriteString(magic)
+	offsets := make([]int64, len(cols))
+	sizes := make([]int64, len(cols))
+	for i, c := range cols {
+		offsets[i] = int64(file.Len())
+		for _, p := range c.pages {
+			file.Write(p)
+		}
+		sizes[i] = int64(file.Len()) - offsets[i]
+	}
+
+	w := &compactWriter{}
+	w.begin()
+	w.i32(1, 1)
+	w.list(2, ctStruct, 5)
+	schema := []func(){
+		func() { w.str(4, "schema"); w.i32(5, 4) },
+		func() { w.i32(1, int32(Int64)); w.i32(3, repRequired); w.str(4, "id") },
+		func() { w.i32(1, int32(ByteArray)); w.i32(3, repOptional); w.str(4, "name"); w.i32(6, convUTF8) },
+		func() { w.i32(1, int32(Double)); w.i32(3, repRequired); w.str(4, "score") },
+		func() { w.i32(1, int32(Int32)); w.i32(3, repOptional); w.str(4, "day"); w.i32(6, convDate) },
+	}
+	for _, fn := range schema {
+		w.begin()
+		fn()
+		w.end()
+	}
+	w.i64(3, 3)
+	w.list(4, ctStruct, 1)
+	w.begin()
+	w.list(1, ctStruct, len(cols))
+	for i, c := range cols {
+		w.begin()
+		w.i64(2, offsets[i])
+		w.structField(3, func() {
+			w.i32(1, int32(c.typ))
+			w.list(2, ctI32, len(c.encodings))
+			for _, e := range c.encodings {
+				w.varint(int64(e))
+			}
+			w.list(3, ctBinary, 1)
+			w.uvarint(uint64(len(c.name)))
+			w.buf.WriteString(c.name)
+			w.i32(4, c.codec)
+			w.i64(5, 3)
+			w.i64(6, sizes[i])
+			w.i64(7, sizes[i])
+			if c.hasDict {
+				w.i64(9, offsets[i]+int64(len(c.pages[0])))
+				w.i64(11, offsets[i])
+			} else {
+				w.i64(9, offsets[i])
+			}
+		})
+		w.end()
+	}
+	w.i64(2, sizes[0]+sizes[1]+sizes[2]+sizes[3])
+	w.i64(3, 3)
+	w.end()
+	w.end()
+
+	file.Write(w.buf.Bytes())
+	file.Write(le32(w.buf.Len()))
+	file.WriteString(magic)
+	return file.Bytes()
+}
+
+func TestReadFlatFile(t *testing.T) {
+	data := buildFixture(t)
+	f, err := Open(bytes.NewReader(data), int64(len(data)))
+	require.NoError(t, err)
+
+	assert.Equal(t, int64(3), f.NumRows())
+	require.Equal(t, 1, f.NumRowGroups())
+	cols := f.Columns()
+	require.Len(t, cols, 4)
+	assert.Equal(t, "name", cols[1].Name)
+	assert.True(t, cols[1].Optional)
+	assert.False(t, cols[0].Optional)
+
+	vals, err := f.ReadRowGroup(0, []int{0, 1, 2, 3})
+	require.NoError(t, err)
+	assert.Equal(t, []any{int64(1), int64(2), int64(3)}, vals[0])
+	assert.Equal(t, []any{"alpha", nil, "beta"}, vals[1])
+	assert.Equal(t, []any{1.5, 2.5, -3.0}, vals[2])
+	assert.Equal(t, []any{
+		time.Date(2022, 1, 8, 0, 0, 0, 0, time.UTC),
+		time.Date(2022, 1, 9, 0, 0, 0, 0, time.UTC),
+		nil,
+	}, vals[3])
+}
+
+func TestOpenRejectsBadInput(t *testing.T) {
+	_, err := Open(bytes.NewReader([]byte("not a parquet file")), 18)
+	assert.ErrorIs(t, err, ErrNotParquet)
+
+	data := buildFixture(t)
+	// Corrupt the footer length so it points before the start of the file.
+	bad := append([]byte(nil), data...)
+	binary.LittleEndian.PutUint32(bad[len(bad)-8:], uint32(len(bad)))
+	_, err = Open(bytes.NewReader(bad), int64(len(bad)))
+	assert.Error(t, err)
+}
+
+func TestDecodeRLEHybrid(t *testing.T) {
+	// An RLE run of four 5s followed by a bit-packed group of 0..7.
+	buf := []byte{4 << 1, 5, 1<<1 | 1, 0x88, 0xc6, 0xfa}
+	vals, n, err := decodeRLEHybrid(buf, 3, 12)
+	require.NoError(t, err)
+	assert.Equal(t, len(buf), n)
+	assert.Equal(t, []int32{5, 5, 5, 5, 0, 1, 2, 3, 4, 5, 6, 7}, vals)
+
+	_, _, err = decodeRLEHybrid(buf[:3], 3, 12)
+	assert.Error(t, err)
+}
+
+func TestDecodeDeltaBinaryPacked(t *testing.T) {
+	// Block size 128 with 4 miniblocks, 8 values, first value 7; min delta
+	// -2 and one 2-bit miniblock holding the adjusted deltas 0,0,0,3,3,3,3.
+	buf := []byte{0x80, 0x01, 4, 8, 14, 3, 2, 0, 0, 0, 0xc0, 0x3f, 0, 0, 0, 0, 0, 0}
+	vals, n, err := decodeDeltaBinaryPacked(buf)
+	require.NoError(t, err)
+	assert.Equal(t, len(buf), n)
+	assert.Equal(t, []int64{7, 5, 3, 1, 2, 3, 4, 5}, vals)
+}
+
+func TestDecodeDeltaByteArray(t *testing.T) {
+	enc := func(vals ...int64) []byte {
+		// One block of 128 values split into 4 miniblocks of 32 at 8 bits.
+		minDelta := int64(math.MaxInt64)
+		for i := 1; i < len(vals); i++ {
+			if d := vals[i] - vals[i-1]; d < minDelta {
+				minDelta = d
+			}
+		}
+		if len(vals) < 2 {
+			minDelta = 0
+		}
+		w := &compactWriter{}
+		w.uvarint(128)
+		w.uvarint(4)
+		w.uvarint(uint64(len(vals)))
+		w.varint(vals[0])
+		w.varint(minDelta)
+		w.buf.Write([]byte{8, 0, 0, 0})
+		mini := make([]byte, 32)
+		for i := 1; i < len(vals); i++ {
+			mini[i-1] = byte(vals[i] - vals[i-1] - minDelta)
+		}
+		w.buf.Write(mini)
+		return w.buf.Bytes()
+	}
+	var buf []byte
+	buf = append(buf, enc(0, 4, 4)...)
+	buf = append(buf, enc(5, 3, 2)...)
+	buf = append(buf, "applepieed"...)
+
+	vals, err := decodeDeltaByteArray(buf, 3)
+	require.NoError(t, err)
+	assert.Equal(t, [][]byte{[]byte("apple"), []byte("applpie"), []byte("appled")}, vals)
+}
@@ -0,0 +1,273 @@
+package parquet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Thrift compact protocol type codes.
+const (
+	ctStop   = 0
+	ctTrue   = 1
+	ctFalse  = 2
+	ctByte   = 3
+	ctI16    = 4
+	ctI32    = 5
+	ctI64    = 6
+	ctDouble = 7
+	ctBinary = 8
+	ctList   = 9
+	ctSet    = 10
+	ctMap    = 11
+	ctStruct = 12
+)
+
+// maxThriftDepth bounds struct nesting so a hostile footer cannot recurse
+// without limit.
+const maxThriftDepth = 64
+
+var errThriftTruncated = errors.New("parquet: truncated thrift data")
+
+// compactReader decodes the Thrift compact protocol used by Parquet
+// footers and page headers. The first error sticks; later reads return
+// zero values.
+type compactReader struct {
+	buf   []byte
+	pos   int
+	err   error
+	depth int
+}
+
+func (r *compactReader) fail(err error) {
+	if r.err == nil {
+		r.err = err
+	}
+}
+
+func (r *compactReader) byte() byte {
+	if r.err != nil {
+		return 0
+	}
+	if r.pos >= len(r.buf) {
+		r.fail(errThriftTruncated)
+		return 0
+	}
+	b := r.buf[r.pos]
+	r.pos++
+	return b
+}
+
+func (r *compactReader) uvarint() uint64 {
+	if r.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(r.buf[r.pos:])
+	if n <= 0 {
+		r.fail(errThriftTruncated)
+		return 0
+	}
+	r.pos += n
+	return v
+}
+
+func (r *compactReader) varint() int64 {
+	u := r.uvarint()
+	return int64(u>>1) ^ -int64(u&1)
+}
+
+func (r *compactReader) i32() int32 { return int32(r.varint()) }
+func (r *compactReader) i64() int64 { return r.varint() }
+
+func (r *compactReader) double() float64 {
+	if r.err != nil {
+		return 0
+	}
+	if r.pos+8 > len(r.buf) {
+		r.fail(errThriftTruncated)
+		return 0
+	}
+	v := math.Float64frombits(binary.LittleEndian.Uint64(r.buf[r.pos:]))
+	r.pos += 8
+	return v
+}
+
+func (r *compactReader) binary() []byte {
+	n := r.uvarint()
+	if r.err != nil {
+		return nil
+	}
+	if n > uint64(len(r.buf)-r.pos) {
+		r.fail(errThriftTruncated)
+		return nil
+	}
+	b := r.buf[r.pos : r.pos+int(n)]
+	r.pos += int(n)
+	return b
+}
+
+func (r *compactReader) string() string { return string(r.binary()) }
+
+// boolField returns the value of a boolean struct field, which the compact
+// protocol carries in the field's type code.
+func boolField(typ byte) bool { return typ == ctTrue }
+
+// listHeader reads a list or set header.
+func (r *compactReader) listHeader() (elemType byte, size int) {
+	h := r.byte()
+	elemType = h & 0x0f
+	size = int(h >> 4)
+	if size == 15 {
+		size = int(r.uvarint())
+	}
+	if size < 0 || size > len(r.buf)-r.pos {
+		// Every element takes at least one byte.
+		r.fail(errThriftTruncated)
+		return 0, 0
+	}
+	return elemType, size
+}
+
+// list reads a list, calling fn once per element.
+func (r *compactReader) list(fn func(elemType byte)) {
+	elemType, size := r.listHeader()
+	for i := 0; i < size && r.err == nil; i++ {
+		fn(elemType)
+	}
+}
+
+// readStruct reads struct fields until STOP, calling fn for each. fn must
+// consume the field's value or call skip.
+func (r *compactReader) readStruct(fn func(id int16, typ byte)) {
+	r.depth++
+	defer func() { r.depth-- }()
+	if r.depth > maxThriftDepth {
+		r.fail(errors.New("parquet: thrift nesting too deep"))
+		return
+	}
+	var lastID int16
+	for r.err == nil {
+		h := r.byte()
+		typ := h & 0x0f
+		if typ == ctStop {
+			return
+		}
+		var id int16
+		if delta := int16(h >> 4); delta != 0 {
+			id = lastID + delta
+		} else {
+			id = int16(r.varint())
+		}
+		lastID = id
+		fn(id, typ)
+	}
+}
+
+// skip consumes a value of the given type.
+func (r *compactReader) skip(typ byte) {
+	switch typ {
+	case ctTrue, ctFalse:
+		// Struct-field booleans live in the type code. List elements are
+		// handled by the list case below.
+	case ctByte:
+		r.byte()
+	case ctI16, ctI32, ctI64:
+		r.uvarint()
+	case ctDouble:
+		r.double()
+	case ctBinary:
+		r.binary()
+	case ctList, ctSet:
+		elemType, size := r.listHeader()
+		for i := 0; i < size && r.err == nil; i++ {
+			if elemType == ctTrue || elemType == ctFalse {
+				r.byte()
+			} else {
+				r.skip(elemType)
+			}
+		}
+	case ctMap:
+		size := int(r.uvarint())
+		if size == 0 {
+			return
+		}
+		kv := r.byte()
+		for i := 0; i < size && r.err == nil; i++ {
+			r.skip(kv >> 4)
+			r.skip(kv & 0x0f)
+		}
+	case ctStruct:
+		r.readStruct(func(_ int16, t byte) { r.skip(t) })
+	default:
+		r.fail(fmt.Errorf("parquet: unknown thrift type %d", typ))
+	}
+}
+
+// compactWriter encodes the Thrift compact protocol. Structs are opened
+// with begin and closed with end, which writes the STOP byte.
+type compactWriter struct {
+	buf  bytes.Buffer
+	last []int16
+}
+
+func (w *compactWriter) uvarint(v uint64) {
+	var tmp [binary.MaxVarintLen64]byte
+	w.buf.Write(tmp[:binary.PutUvarint(tmp[:], v)])
+}
+
+func (w *compactWriter) varint(v int64) { w.uvarint(uint64(v<<1) ^ uint64(v>>63)) }
+
+func (w *compactWriter) begin() { w.last = append(w.last, 0) }
+
+func (w *compactWriter) end() {
+	w.buf.WriteByte(ctStop)
+	w.last = w.last[:len(w.last)-1]
+}
+
+func (w *compactWriter) field(id int16, typ byte) {
+	top := len(w.last) - 1
+	if d := id - w.last[top]; d > 0 && d <= 15 {
+		w.buf.WriteByte(byte(d)<<4 | typ)
+	} else {
+		w.buf.WriteByte(typ)
+		w.varint(int64(id))
+	}
+	w.last[top] = id
+}
+
+func (w *compactWriter) i32(id int16, v int32) { w.field(id, ctI32); w.varint(int64(v)) }
+func (w *compactWriter) i64(id int16, v int64) { w.field(id, ctI64); w.varint(v) }
+
+func (w *compactWriter) bool(id int16, v bool) {
+	if v {
+		w.field(id, ctTrue)
+	} else {
+		w.field(id, ctFalse)
+	}
+}
+
+func (w *compactWriter) str(id int16, s string) {
+	w.field(id, ctBinary)
+	w.uvarint(uint64(len(s)))
+	w.buf.WriteString(s)
+}
+
+// list writes a list field header; the caller writes the n elements.
+func (w *compactWriter) list(id int16, elem byte, n int) {
+	w.field(id, ctList)
+	if n < 15 {
+		w.buf.WriteByte(byte(n)<<4 | elem)
+		return
+	}
+	w.buf.WriteByte(0xf0 | elem)
+	w.uvarint(uint64(n))
+}
+
+func (w *compactWriter) structField(id int16, fn func()) {
+	w.field(id, ctStruct)
+	w.begin()
+	fn()
+	w.end()
+}
@@ -0,0 +1,400 @@
+package parquet
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"time"
+)
+
+// Writer defaults.
+const (
+	defaultRowGroupRows = 128 << 10
+	targetPageSize      = 1 << 20
+	createdBy           = "vaultaire"
+)
+
+// Field describes a column written by Writer. Boolean, Int64 and Double
+// columns take bool, int64 and float64 values; ByteArray columns take
+// strings and are annotated as UTF8. Int64 columns with Timestamp set take
+// time.Time values, stored as UTC milliseconds.
+type Field struct {
+	Name      string
+	Type      Type
+	Optional  bool
+	Timestamp bool
+}
+
+// Writer writes a flat Parquet file: one row group per RowGroupRows rows,
+// PLAIN-encoded uncompressed v1 data pages.
+type Writer struct {
+	// RowGroupRows is the number of rows buffered before a row group is
+	// written. It may be changed before the first Write.
+	RowGroupRows int
+
+	w      *countingWriter
+	name   string
+	fields []Field
+	rows   [][]any
+	groups []rowGroup
+	total  int64
+	closed bool
+}
+
+// NewWriter starts a Parquet file on w whose schema, named name, holds
+// fields.
+func NewWriter(w io.Writer, name string, fields []Field) (*Writer, error) {
+	if len(fields) == 0 {
+		return nil, errors.New("parquet: no fields")
+	}
+	for _, f := range fields {
+		switch f.Type {
+		case Boolean, Double, ByteArray:
+			if f.Timestamp {
+				return nil, fmt.Errorf("parquet: field %s: timestamps must be INT64", f.Name)
+			}
+		case Int64:
+		default:
+			return nil, fmt.Errorf("parquet: field %s: cannot write %s columns", f.Name, f.Type)
+		}
+	}
+	cw := &countingWriter{w: w}
+	if _, err := io.WriteString(cw, magic); err != nil {
+		return nil, err
+	}
+	return &Writer{RowGroupRows: defaultRowGroupRows, w: cw, name: name, fields: fields}, nil
+}
+
+// Write buffers one row, holding one value per field, and writes a row
+// group once RowGroupRows rows are buffered. nil marks a null in an
+// optional column.
+func (w *Writer) Write(row []any) error {
+	if w.closed {
+		return errors.New("parquet: write to closed writer")
+	}
+	if len(row) != len(w.fields) {
+		return fmt.Errorf("parquet: row has %d values, schema has %d fields", len(row), len(w.fields))
+	}
+	for i, f := range w.fields {
+		if err := f.check(row[i]); err != nil {
+			return err
+		}
+	}
+	w.rows = append(w.rows, row)
+	if len(w.rows) >= w.RowGroupRows {
+		return w.flush()
+	}
+	return nil
+}
+
+// Close writes any buffered rows and the footer. It does not close the
+// underlying writer.
+func (w *Writer) Close() error {
+	if w.closed {
+		return nil
+	}
+	if err := w.flush(); err != nil {
+		return err
+	}
+	w.closed = true
+
+	cw := &compactWriter{}
+	w.writeFileMetaData(cw)
+	footer := cw.buf.Bytes()
+	if _, err := w.w.Write(footer); err != nil {
+		return err
+	}
+	if _, err := w.w.Write(binary.LittleEndian.AppendUint32(nil, uint32(len(footer)))); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w.w, magic)
+	return err
+}
+
+// Schema renders fields as a Parquet message definition, the form tools
+// such as parquet-tools print.
+func Schema(name string, fields []Field) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "message %s {", name)
+	for _, f := range fields {
+		rep := "required"
+		if f.Optional {
+			rep = "optional"
+		}
+		fmt.Fprintf(&b, " %s %s %s", rep, strings.ToLower(schemaType(f.Type)), f.Name)
+		switch {
+		case f.Type == ByteArray:
+			b.WriteString(" (UTF8)")
+		case f.Timestamp:
+			b.WriteString(" (TIMESTAMP_MILLIS)")
+		}
+		b.WriteString(";")
+	}
+	b.WriteString(" }")
+	return b.String()
+}
+
+// schemaType names a physical type as message definitions spell it.
+func schemaType(t Type) string {
+	if t == ByteArray {
+		return "binary"
+	}
+	return t.String()
+}
+
+func (f Field) check(v any) error {
+	if v == nil {
+		if !f.Optional {
+			return fmt.Errorf("parquet: field %s is required", f.Name)
+		}
+		return nil
+	}
+	var ok bool
+	switch f.Type {
+	case Boolean:
+		_, ok = v.(bool)
+	case Int64:
+		if f.Timestamp {
+			_, ok = v.(time.Time)
+		} else {
+			_, ok = v.(int64)
+		}
+	case Double:
+		_, ok = v.(float64)
+	case ByteArray:
+		_, ok = v.(string)
+	}
+	if !ok {
+		return fmt.Errorf("parquet: field %s: unexpected value of type %T", f.Name, v)
+	}
+	return nil
+}
+
+// flush writes the buffered rows as a row group.
+func (w *Writer) flush() error {
+	if len(w.rows) == 0 {
+		return nil
+	}
+	g := rowGroup{NumRows: int64(len(w.rows))}
+	for i, f := range w.fields {
+		chunk, err := w.writeColumn(i, f)
+		if err != nil {
+			return err
+		}
+		g.Columns = append(g.Columns, chunk)
+		g.TotalByteSize += chunk.Meta.TotalUncompressed
+	}
+	w.groups = append(w.groups, g)
+	w.total += g.NumRows
+	w.rows = w.rows[:0]
+	return nil
+}
+
+// writeColumn writes column i of the buffered rows as one column chunk,
+// split into pages of about targetPageSize bytes.
+func (w *Writer) writeColumn(i int, f Field) (columnChunk, error) {
+	start := w.w.n
+	var levels []byte
+	var values []byte
+	var bits []bool
+	pageRows := 0
+
+	writePage := func() error {
+		if pageRows == 0 {
+			return nil
+		}
+		var body []byte
+		if f.Optional {
+			rle := encodeLevels(levels)
+			body = binary.LittleEndian.AppendUint32(body, uint32(len(rle)))
+			body = append(body, rle...)
+		}
+		if f.Type == Boolean {
+			values = packBools(bits)
+		}
+		body = append(body, values...)
+
+		h := &compactWriter{}
+		h.begin()
+		h.i32(1, pageData)
+		h.i32(2, int32(len(body)))
+		h.i32(3, int32(len(body)))
+		h.structField(5, func() {
+			h.i32(1, int32(pageRows))
+			h.i32(2, encPlain)
+			h.i32(3, encRLE)
+			h.i32(4, encRLE)
+		})
+		h.end()
+		if _, err := w.w.Write(h.buf.Bytes()); err != nil {
+			return err
+		}
+		if _, err := w.w.Write(body); err != nil {
+			return err
+		}
+		levels, values, bits, pageRows = levels[:0], values[:0], bits[:0], 0
+		return nil
+	}
+
+	for _, row := range w.rows {
+		v := row[i]
+		if f.Optional {
+			if v == nil {
+				levels = append(levels, 0)
+			} else {
+				levels = append(levels, 1)
+			}
+		}
+		switch x := v.(type) {
+		case bool:
+			bits = append(bits, x)
+		case int64:
+			values = binary.LittleEndian.AppendUint64(values, uint64(x))
+		case time.Time:
+			values = binary.LittleEndian.AppendUint64(values, uint64(x.UnixMilli()))
+		case float64:
+			values = binary.LittleEndian.AppendUint64(values, math.Float64bits(x))
+		case string:
+			values = binary.LittleEndian.AppendUint32(values, uint32(len(x)))
+			values = append(values, x...)
+		}
+		pageRows++
+		if len(values) >= targetPageSize || len(bits) >= targetPageSize*8 {
+			if err := writePage(); err != nil {
+				return columnChunk{}, err
+			}
+		}
+	}
+	if err := writePage(); err != nil {
+		return columnChunk{}, err
+	}
+
+	size := w.w.n - start
+	return columnChunk{
+		FileOffset: start,
+		Meta: columnMetaData{
+			Type:                f.Type,
+			Encodings:           []int32{encPlain, encRLE},
+			Path:                []string{f.Name},
+			Codec:               codecUncompressed,
+			NumValues:           int64(len(w.rows)),
+			TotalUncompressed:   size,
+			TotalCompressedSize: size,
+			DataPageOffset:      start,
+		},
+	}, nil
+}
+
+// encodeLevels encodes definition levels of a column with maximum level 1
+// as runs of the RLE hybrid encoding.
+func encodeLevels(levels []byte) []byte {
+	var out []byte
+	for i := 0; i < len(levels); {
+		j := i + 1
+		for j < len(levels) && levels[j] == levels[i] {
+			j++
+		}
+		out = binary.AppendUvarint(out, uint64(j-i)<<1)
+		out = append(out, levels[i])
+		i = j
+	}
+	return out
+}
+
+// packBools bit-packs PLAIN booleans, least significant bit first.
+func packBools(bits []bool) []byte {
+	out := make([]byte, (len(bits)+7)/8)
+	for i, b := range bits {
+		if b {
+			out[i/8] |= 1 << (i % 8)
+		}
+	}
+	return out
+}
+
+func (w *Writer) writeFileMetaData(cw *compactWriter) {
+	cw.begin()
+	cw.i32(1, 1)
+	cw.list(2, ctStruct, len(w.fields)+1)
+	cw.begin()
+	cw.str(4, w.name)
+	cw.i32(5, int32(len(w.fields)))
+	cw.end()
+	for _, f := range w.fields {
+		cw.begin()
+		cw.i32(1, int32(f.Type))
+		rep := int32(repRequired)
+		if f.Optional {
+			rep = repOptional
+		}
+		cw.i32(3, rep)
+		cw.str(4, f.Name)
+		switch {
+		case f.Type == ByteArray:
+			cw.i32(6, convUTF8)
+			cw.structField(10, func() {
+				cw.structField(logicalString, func() {})
+			})
+		case f.Timestamp:
+			cw.i32(6, convTimestampMillis)
+			cw.structField(10, func() {
+				cw.structField(logicalTimestamp, func() {
+					cw.bool(1, true) // isAdjustedToUTC
+					cw.structField(2, func() {
+						cw.structField(unitMillis, func() {})
+					})
+				})
+			})
+		}
+		cw.end()
+	}
+	cw.i64(3, w.total)
+	cw.list(4, ctStruct, len(w.groups))
+	for _, g := range w.groups {
+		cw.begin()
+		cw.list(1, ctStruct, len(g.Columns))
+		for _, c := range g.Columns {
+			cw.begin()
+			cw.i64(2, c.FileOffset)
+			cw.structField(3, func() {
+				m := c.Meta
+				cw.i32(1, int32(m.Type))
+				cw.list(2, ctI32, len(m.Encodings))
+				for _, e := range m.Encodings {
+					cw.varint(int64(e))
+				}
+				cw.list(3, ctBinary, len(m.Path))
+				for _, p := range m.Path {
+					cw.uvarint(uint64(len(p)))
+					cw.buf.WriteString(p)
+				}
+				cw.i32(4, m.Codec)
+				cw.i64(5, m.NumValues)
+				cw.i64(6, m.TotalUncompressed)
+				cw.i64(7, m.TotalCompressedSize)
+				cw.i64(9, m.DataPageOffset)
+			})
+			cw.end()
+		}
+		cw.i64(2, g.TotalByteSize)
+		cw.i64(3, g.NumRows)
+		cw.end()
+	}
+	cw.str(6, createdBy)
+	cw.end()
+}
+
+// countingWriter tracks the file offset for column chunk metadata.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,119 @@
+package parquet
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testFields = []Field{
+	{Name: "bucket", Type: ByteArray},
+	{Name: "key", Type: ByteArray},
+	{Name: "is_latest", Type: Boolean, Optional: true},
+	{Name: "size", Type: Int64, Optional: true},
+	{Name: "last_modified_date", Type: Int64, Optional: true, Timestamp: true},
+	{Name: "ratio", Type: Double, Optional: true},
+}
+
+func TestWriter_RoundTrip(t *testing.T) {
+	mod := time.Date(2026, 3, 4, 5, 6, 7, 8e6, time.UTC)
+	rows := [][]any{
+		{"photos", "a.jpg", true, int64(1024), mod, 0.5},
+		{"photos", "b.jpg", nil, nil, nil, nil},
+		{"photos", "ünïcode/c", false, int64(-1), mod.Add(time.Hour), 1.0},
+	}
+
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, "s3.inventory", testFields)
+	require.NoError(t, err)
+	for _, r := range rows {
+		require.NoError(t, w.Write(r))
+	}
+	require.NoError(t, w.Close())
+
+	f, err := Open(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), f.NumRows())
+	cols := f.Columns()
+	require.Len(t, cols, len(testFields))
+	for i, c := range cols {
+		assert.Equal(t, testFields[i].Name, c.Name)
+		assert.Equal(t, testFields[i].Type, c.Type)
+		assert.Equal(t, testFields[i].Optional, c.Optional)
+	}
+
+	vals, err := f.ReadRowGroup(0, []int{0, 1, 2, 3, 4, 5})
+	require.NoError(t, err)
+	for i, r := range rows {
+		for k := range testFields {
+			assert.Equal(t, r[k], vals[k][i], "row %d column %s", i, testFields[k].Name)
+		}
+	}
+}
+
+func TestWriter_RowGroupsAndPages(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, "schema", testFields[:4])
+	require.NoError(t, err)
+	w.RowGroupRows = 300
+	long := strings.Repeat("k", 5000)
+	for i := 0; i < 700; i++ {
+		var latest any
+		if i%3 != 0 {
+			latest = i%2 == 0
+		}
+		require.NoError(t, w.Write([]any{"b", long, latest, int64(i)}))
+	}
+	require.NoError(t, w.Close())
+
+	f, err := Open(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+	require.Equal(t, 3, f.NumRowGroups())
+	assert.Equal(t, int64(700), f.NumRows())
+
+	var n int64
+	for g := 0; g < f.NumRowGroups(); g++ {
+		vals, err := f.ReadRowGroup(g, []int{1, 2, 3})
+		require.NoError(t, err)
+		for i := range vals[0] {
+			assert.Equal(t, long, vals[0][i])
+			if n%3 == 0 {
+				assert.Nil(t, vals[1][i])
+			} else {
+				assert.Equal(t, n%2 == 0, vals[1][i])
+			}
+			assert.Equal(t, n, vals[2][i])
+			n++
+		}
+	}
+	assert.Equal(t, int64(700), n)
+}
+
+func TestWriter_Errors(t *testing.T) {
+	_, err := NewWriter(&bytes.Buffer{}, "schema", nil)
+	assert.Error(t, err)
+	_, err = NewWriter(&bytes.Buffer{}, "schema", []Field{{Name: "x", Type: Int96}})
+	assert.Error(t, err)
+	_, err = NewWriter(&bytes.Buffer{}, "schema", []Field{{Name: "x", Type: ByteArray, Timestamp: true}})
+	assert.Error(t, err)
+
+	w, err := NewWriter(&bytes.Buffer{}, "schema", testFields)
+	require.NoError(t, err)
+	assert.ErrorContains(t, w.Write([]any{"b"}), "1 values")
+	assert.ErrorContains(t, w.Write([]any{nil, "k", nil, nil, nil, nil}), "bucket is required")
+	assert.ErrorContains(t, w.Write([]any{"b", "k", nil, 12, nil, nil}), "unexpected value of type int")
+	require.NoError(t, w.Close())
+	assert.Error(t, w.Write([]any{"b", "k", nil, nil, nil, nil}))
+}
+
+func TestSchema(t *testing.T) {
+	assert.Equal(t,
+		"message s3.inventory { required binary bucket (UTF8); required binary key (UTF8); "+
+			"optional boolean is_latest; optional int64 size; "+
+			"optional int64 last_modified_date (TIMESTAMP_MILLIS); optional double ratio; }",
+		Schema("s3.inventory", testFields))
+}
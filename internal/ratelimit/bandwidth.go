@@ -0,0 +1,110 @@
+// internal/ratelimit/bandwidth.go
+package ratelimit
+
+import (
+	"context"
+	"io"
+)
+
+// BandwidthLimiter limits bytes per second per key. Its buckets live in
+// the Shared store like request limits, so a tenant streaming through
+// several replicas at once still gets one allowance.
+type BandwidthLimiter struct {
+	shared *Shared
+	name   string
+	limit  Limit
+}
+
+// NewBandwidthLimiter allows bytesPerSecond per key, with a burst of one
+// second's worth.
+func NewBandwidthLimiter(shared *Shared, name string, bytesPerSecond int64) *BandwidthLimiter {
+	return &BandwidthLimiter{
+		shared: shared,
+		name:   name,
+		limit:  Limit{Rate: float64(bytesPerSecond), Burst: bytesPerSecond},
+	}
+}
+
+// WaitN blocks until n bytes may pass for key. Amounts over the burst are
+// taken a burst at a time.
+func (b *BandwidthLimiter) WaitN(ctx context.Context, key string, n int64) error {
+	for n > 0 {
+		take := n
+		if take > b.limit.Burst {
+			take = b.limit.Burst
+		}
+		if err := b.shared.Wait(ctx, b.name+":"+key, b.limit, take); err != nil {
+			return err
+		}
+		n -= take
+	}
+	return nil
+}
+
+// Reader throttles r to the limit for key. Bytes are accounted in batches
+// of an eighth of the burst, which keeps store round trips to about eight
+// a second per stream.
+func (b *BandwidthLimiter) Reader(ctx context.Context, key string, r io.Reader) io.Reader {
+	quantum := b.limit.Burst / 8
+	if quantum < 1 {
+		quantum = 1
+	}
+	return &bandwidthReader{ctx: ctx, limiter: b, key: key, r: r, quantum: quantum}
+}
+
+type bandwidthReader struct {
+	ctx     context.Context
+	limiter *BandwidthLimiter
+	key     string
+	r       io.Reader
+	quantum int64
+	pending int64
+}
+
+func (br *bandwidthReader) Read(p []byte) (int, error) {
+	n, err := br.r.Read(p)
+	br.pending += int64(n)
+	if br.pending >= br.quantum || (err != nil && br.pending > 0) {
+		if werr := br.limiter.WaitN(br.ctx, br.key, br.pending); werr != nil {
+			return n, werr
+		}
+		br.pending = 0
+	}
+	return n, err
+}
+
+// Writer throttles writes to w to the limit for key, for response bodies
+// written from several places. Bytes are paid for before they are written,
+// a quantum at a time like Reader; a stream overpays by less than a
+// quantum.
+func (b *BandwidthLimiter) Writer(ctx context.Context, key string, w io.Writer) io.Writer {
+	quantum := b.limit.Burst / 8
+	if quantum < 1 {
+		quantum = 1
+	}
+	return &bandwidthWriter{ctx: ctx, limiter: b, key: key, w: w, quantum: quantum}
+}
+
+type bandwidthWriter struct {
+	ctx     context.Context
+	limiter *BandwidthLimiter
+	key     string
+	w       io.Writer
+	quantum int64
+	credit  int64
+}
+
+func (bw *bandwidthWriter) Write(p []byte) (int, error) {
+	if need := int64(len(p)) - bw.credit; need > 0 {
+		take := need
+		if take < bw.quantum {
+			take = bw.quantum
+		}
+		if err := bw.limiter.WaitN(bw.ctx, bw.key, take); err != nil {
+			return 0, err
+		}
+		bw.credit += take
+	}
+	bw.credit -= int64(len(p))
+	return bw.w.Write(p)
+}
@@ -0,0 +1,54 @@
+package ratelimit
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBandwidthLimiter_Reader(t *testing.T) {
+	bl := NewBandwidthLimiter(NewShared(nil, nil), "egress", 1000)
+	data := bytes.Repeat([]byte("x"), 1500)
+
+	start := time.Now()
+	n, err := io.Copy(io.Discard, bl.Reader(context.Background(), "tenant-1", bytes.NewReader(data)))
+	require.NoError(t, err)
+	assert.Equal(t, int64(1500), n)
+	// The first second's worth is the burst; the other 500 bytes wait.
+	assert.GreaterOrEqual(t, time.Since(start), 400*time.Millisecond)
+}
+
+func TestBandwidthLimiter_WaitNOverBurst(t *testing.T) {
+	bl := NewBandwidthLimiter(NewShared(nil, nil), "egress", 100)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	// 250 bytes are taken a burst at a time; the second burst outlasts ctx.
+	assert.ErrorIs(t, bl.WaitN(ctx, "tenant-1", 250), context.DeadlineExceeded)
+}
+
+func TestBandwidthLimiter_Writer(t *testing.T) {
+	bl := NewBandwidthLimiter(NewShared(nil, nil), "egress", 1000)
+	var out bytes.Buffer
+	w := bl.Writer(context.Background(), "tenant-1", &out)
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		_, err := w.Write(bytes.Repeat([]byte("x"), 500))
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 1500, out.Len())
+	// As with Reader, the burst covers the first 1000 bytes.
+	assert.GreaterOrEqual(t, time.Since(start), 400*time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	// tenant-1's bucket is empty, so the next write has to wait.
+	_, err := bl.Writer(ctx, "tenant-1", io.Discard).Write([]byte("x"))
+	assert.ErrorIs(t, err, context.Canceled)
+}
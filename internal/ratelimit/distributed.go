@@ -5,7 +5,8 @@ import (
 	"time"
 )
 
-// SlidingWindowLimiter implements sliding window algorithm
+// SlidingWindowLimiter implements sliding window algorithm. Its state is
+// per process; SharedLimiter (store.go) enforces one limit across replicas.
 type SlidingWindowLimiter struct {
 	mu       sync.Mutex
 	limit    int
@@ -130,9 +131,3 @@ func (s *SlidingWindowLimiter) Cleanup() {
 		}
 	}
 }
-
-// RedisLimiter for true distributed rate limiting (placeholder)
-type RedisLimiter struct {
-	// This would use Redis for distributed state
-	// For MVP, the in-memory version is sufficient
-}
@@ -12,6 +12,7 @@ type OperationLimiter struct {
 	mu       sync.RWMutex
 	limits   map[string]*OperationConfig // operation -> config
 	limiters map[string]*rate.Limiter    // tenantID:operation -> limiter
+	shared   *Shared                     // replaces limiters when set
 }
 
 // OperationConfig defines limits for an operation
@@ -20,6 +21,10 @@ type OperationConfig struct {
 	Burst         int
 }
 
+func (c *OperationConfig) limit() Limit {
+	return Limit{Rate: float64(c.RatePerSecond), Burst: int64(c.Burst)}
+}
+
 // NewOperationLimiter creates a new operation-aware limiter
 func NewOperationLimiter() *OperationLimiter {
 	return &OperationLimiter{
@@ -39,27 +44,48 @@ func (ol *OperationLimiter) SetLimit(operation string, ratePerSecond, burst int)
 	}
 }
 
+// UseShared keeps the per-tenant buckets in shared, so every replica
+// enforces one limit
+func (ol *OperationLimiter) UseShared(shared *Shared) {
+	ol.mu.Lock()
+	defer ol.mu.Unlock()
+
+	ol.shared = shared
+}
+
 // Allow checks if a tenant can perform an operation
 func (ol *OperationLimiter) Allow(tenantID, operation string) bool {
+	res, _ := ol.Take(context.Background(), tenantID, operation)
+	return res.Allowed
+}
+
+// Take takes one request from tenantID's bucket for operation and returns
+// the bucket's state for SetHeaders. limited is false when the operation
+// has no limit configured.
+func (ol *OperationLimiter) Take(ctx context.Context, tenantID, operation string) (res Result, limited bool) {
 	ol.mu.Lock()
-	defer ol.mu.Unlock()
 
-	// Get operation config
 	config, exists := ol.limits[operation]
 	if !exists {
-		// No limit configured for this operation
-		return true
+		ol.mu.Unlock()
+		return Result{Allowed: true}, false
 	}
+	limit := config.limit()
+
+	if shared := ol.shared; shared != nil {
+		ol.mu.Unlock()
+		return shared.Take(ctx, "op:"+tenantID+":"+operation, limit, 1), true
+	}
+	defer ol.mu.Unlock()
 
-	// Get or create limiter for this tenant+operation
 	key := tenantID + ":" + operation
 	limiter, exists := ol.limiters[key]
 	if !exists {
 		limiter = rate.NewLimiter(rate.Limit(config.RatePerSecond), config.Burst)
 		ol.limiters[key] = limiter
 	}
-
-	return limiter.Allow()
+	allowed := limiter.Allow()
+	return limit.result(allowed, limiter.Tokens(), 1), true
 }
 
 // Wait blocks until the operation can proceed
@@ -72,6 +98,12 @@ func (ol *OperationLimiter) Wait(ctx context.Context, tenantID, operation string
 		return nil // No limit
 	}
 
+	if shared := ol.shared; shared != nil {
+		limit := config.limit()
+		ol.mu.Unlock()
+		return shared.Wait(ctx, "op:"+tenantID+":"+operation, limit, 1)
+	}
+
 	key := tenantID + ":" + operation
 	limiter, exists := ol.limiters[key]
 	if !exists {
@@ -0,0 +1,90 @@
+// internal/ratelimit/postgres.go
+package ratelimit
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// refilled is a bucket's tokens after the refill since its last take.
+// UPDATE evaluates every SET expression against the old row, so the three
+// uses below all see the same value.
+const refilled = `LEAST($2::float8, b.tokens + GREATEST(EXTRACT(EPOCH FROM NOW() - b.updated_at), 0) * $3::float8)`
+
+// takeQuery takes $4 tokens from bucket $1 (burst $2, $3 tokens/s) in one
+// atomic upsert; the row lock serializes concurrent takes across replicas.
+const takeQuery = `
+	INSERT INTO rate_limit_buckets AS b (bucket_key, tokens, allowed, updated_at, expires_at)
+	VALUES ($1, CASE WHEN $4::float8 <= $2::float8 THEN $2::float8 - $4::float8 ELSE $2::float8 END,
+		$4::float8 <= $2::float8, NOW(), NOW() + $5::float8 * INTERVAL '1 second')
+	ON CONFLICT (bucket_key) DO UPDATE SET
+		tokens = CASE WHEN ` + refilled + ` >= $4::float8 THEN ` + refilled + ` - $4::float8 ELSE ` + refilled + ` END,
+		allowed = ` + refilled + ` >= $4::float8,
+		updated_at = NOW(),
+		expires_at = EXCLUDED.expires_at
+	RETURNING tokens, allowed`
+
+// PostgresStore keeps token buckets in the rate_limit_buckets table, for
+// deployments without Redis. Each take is one upsert, so it suits request
+// rates of a few hundred per second per deployment, not per-chunk
+// bandwidth accounting at scale.
+type PostgresStore struct {
+	db *sql.DB
+}
+
+// NewPostgresStore creates a store over db.
+func NewPostgresStore(db *sql.DB) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+// Take takes cost tokens from the bucket at key.
+func (s *PostgresStore) Take(ctx context.Context, key string, limit Limit, cost int64) (Result, error) {
+	// A bucket left alone for this long is full again.
+	ttl := 1.0
+	if limit.Rate > 0 {
+		ttl += float64(limit.Burst) / limit.Rate
+	}
+
+	var tokens float64
+	var allowed bool
+	err := s.db.QueryRowContext(ctx, takeQuery, key, limit.Burst, limit.Rate, cost, ttl).Scan(&tokens, &allowed)
+	if err != nil {
+		return Result{}, fmt.Errorf("take rate limit tokens: %w", err)
+	}
+	return limit.result(allowed, tokens, cost), nil
+}
+
+// Prune deletes buckets that have refilled completely.
+func (s *PostgresStore) Prune(ctx context.Context) (int64, error) {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM rate_limit_buckets WHERE expires_at < NOW()`)
+	if err != nil {
+		return 0, fmt.Errorf("prune rate limit buckets: %w", err)
+	}
+	return res.RowsAffected()
+}
+
+// StartCleanup prunes refilled buckets every ten minutes until ctx is
+// cancelled.
+func (s *PostgresStore) StartCleanup(ctx context.Context, logger *zap.Logger) {
+	go func() {
+		ticker := time.NewTicker(10 * time.Minute)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				n, err := s.Prune(ctx)
+				if err != nil {
+					logger.Error("rate limit bucket cleanup", zap.Error(err))
+				} else if n > 0 {
+					logger.Debug("pruned rate limit buckets", zap.Int64("count", n))
+				}
+			}
+		}
+	}()
+}
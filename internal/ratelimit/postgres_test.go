@@ -0,0 +1,46 @@
+package ratelimit
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPostgresStore_Take(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() { _ = db.Close() }()
+
+	store := NewPostgresStore(db)
+	limit := Limit{Rate: 10, Burst: 100}
+
+	mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO rate_limit_buckets AS b`)).
+		WithArgs("cdn:slug:bucket", int64(100), 10.0, int64(1), 11.0).
+		WillReturnRows(sqlmock.NewRows([]string{"tokens", "allowed"}).AddRow(99.5, true))
+	res, err := store.Take(context.Background(), "cdn:slug:bucket", limit, 1)
+	require.NoError(t, err)
+	assert.True(t, res.Allowed)
+	assert.Equal(t, int64(99), res.Remaining)
+	assert.Equal(t, 50*time.Millisecond, res.Reset)
+
+	mock.ExpectQuery(regexp.QuoteMeta(`INSERT INTO rate_limit_buckets AS b`)).
+		WithArgs("cdn:slug:bucket", int64(100), 10.0, int64(1), 11.0).
+		WillReturnRows(sqlmock.NewRows([]string{"tokens", "allowed"}).AddRow(0.5, false))
+	res, err = store.Take(context.Background(), "cdn:slug:bucket", limit, 1)
+	require.NoError(t, err)
+	assert.False(t, res.Allowed)
+	assert.Equal(t, 50*time.Millisecond, res.RetryAfter)
+
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM rate_limit_buckets WHERE expires_at < NOW()`)).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+	n, err := store.Prune(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), n)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
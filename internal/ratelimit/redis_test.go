@@ -0,0 +1,224 @@
+package ratelimit
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1" // #nosec G505 — matches EVALSHA script names
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/FairForge/vaultaire/internal/resp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeRedis is an in-process RESP server that runs tokenBucketScript's
+// algorithm natively, enough to exercise RedisStore's protocol handling.
+type fakeRedis struct {
+	t        *testing.T
+	ln       net.Listener
+	password string
+
+	mu       sync.Mutex
+	scripts  map[string]bool
+	buckets  map[string][2]float64 // tokens, ms timestamp
+	commands []string
+}
+
+func newFakeRedis(t *testing.T, password string) *fakeRedis {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	f := &fakeRedis{t: t, ln: ln, password: password, scripts: map[string]bool{}, buckets: map[string][2]float64{}}
+	t.Cleanup(func() { _ = ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(conn)
+		}
+	}()
+	return f
+}
+
+func (f *fakeRedis) url(userinfo, db string) string {
+	return "redis://" + userinfo + f.ln.Addr().String() + db
+}
+
+func (f *fakeRedis) serve(conn net.Conn) {
+	defer func() { _ = conn.Close() }()
+	r := bufio.NewReader(conn)
+	authed := f.password == ""
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			return
+		}
+		f.mu.Lock()
+		f.commands = append(f.commands, strings.ToUpper(args[0]))
+		f.mu.Unlock()
+
+		var reply string
+		switch strings.ToUpper(args[0]) {
+		case "AUTH":
+			if args[1] == f.password {
+				authed = true
+				reply = "+OK\r\n"
+			} else {
+				reply = "-WRONGPASS invalid password\r\n"
+			}
+		case "SELECT":
+			reply = "+OK\r\n"
+		case "EVAL", "EVALSHA":
+			if !authed {
+				reply = "-NOAUTH Authentication required.\r\n"
+				break
+			}
+			reply = f.eval(args)
+		default:
+			reply = "-ERR unknown command\r\n"
+		}
+		if _, err := io.WriteString(conn, reply); err != nil {
+			return
+		}
+	}
+}
+
+func (f *fakeRedis) eval(args []string) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	sha := args[1]
+	if strings.ToUpper(args[0]) == "EVAL" {
+		sum := sha1.Sum([]byte(args[1])) // #nosec G401 — script name
+		sha = hex.EncodeToString(sum[:])
+		f.scripts[sha] = true
+	}
+	if !f.scripts[sha] {
+		return "-NOSCRIPT No matching script. Please use EVAL.\r\n"
+	}
+
+	key := args[3]
+	rate, _ := strconv.ParseFloat(args[4], 64)
+	burst, _ := strconv.ParseFloat(args[5], 64)
+	cost, _ := strconv.ParseFloat(args[6], 64)
+	now := float64(time.Now().UnixMilli())
+	b, ok := f.buckets[key]
+	if !ok {
+		b = [2]float64{burst, now}
+	}
+	tokens := b[0]
+	if now > b[1] {
+		tokens = math.Min(burst, tokens+(now-b[1])/1000*rate)
+	}
+	allowed := 0
+	if tokens >= cost {
+		tokens -= cost
+		allowed = 1
+	}
+	f.buckets[key] = [2]float64{tokens, now}
+	return fmt.Sprintf("*2\r\n:%d\r\n:%d\r\n", allowed, int64(math.Floor(tokens*1000)))
+}
+
+func readCommand(r *bufio.Reader) ([]string, error) {
+	v, err := resp.ReadReply(r)
+	if err != nil {
+		return nil, err
+	}
+	vals, ok := v.([]interface{})
+	if !ok || len(vals) == 0 {
+		return nil, fmt.Errorf("not a command: %v", v)
+	}
+	args := make([]string, len(vals))
+	for i, a := range vals {
+		args[i], _ = a.(string)
+	}
+	return args, nil
+}
+
+func TestNewRedisStore(t *testing.T) {
+	_, err := NewRedisStore("rediss://:secret@cache.internal:6380/2")
+	require.NoError(t, err)
+	_, err = NewRedisStore("http://cache.internal")
+	assert.Error(t, err)
+}
+
+func TestRedisStore_Take(t *testing.T) {
+	f := newFakeRedis(t, "secret")
+	s, err := NewRedisStore(f.url(":secret@", "/1"))
+	require.NoError(t, err)
+	defer func() { _ = s.Close() }()
+
+	ctx := context.Background()
+	limit := Limit{Rate: 1, Burst: 3}
+	for i := 2; i >= 0; i-- {
+		res, err := s.Take(ctx, "tenant-1", limit, 1)
+		require.NoError(t, err)
+		assert.True(t, res.Allowed)
+		assert.Equal(t, int64(i), res.Remaining)
+		assert.Equal(t, int64(3), res.Limit)
+	}
+	res, err := s.Take(ctx, "tenant-1", limit, 1)
+	require.NoError(t, err)
+	assert.False(t, res.Allowed)
+	assert.InDelta(t, time.Second, res.RetryAfter, float64(50*time.Millisecond))
+
+	// Keys are namespaced; other keys have their own bucket.
+	res, err = s.Take(ctx, "tenant-2", limit, 1)
+	require.NoError(t, err)
+	assert.True(t, res.Allowed)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	assert.Contains(t, f.buckets, redisKeyPrefix+"tenant-1")
+	// One connection: AUTH and SELECT once, then the script is loaded by
+	// the first EVAL and run by SHA from then on.
+	assert.Equal(t, []string{"AUTH", "SELECT", "EVALSHA", "EVAL", "EVALSHA", "EVALSHA", "EVALSHA", "EVALSHA"}, f.commands)
+}
+
+func TestRedisStore_Errors(t *testing.T) {
+	f := newFakeRedis(t, "secret")
+	s, err := NewRedisStore(f.url(":wrong@", ""))
+	require.NoError(t, err)
+	_, err = s.Take(context.Background(), "k", Limit{Rate: 1, Burst: 1}, 1)
+	assert.ErrorContains(t, err, "WRONGPASS")
+
+	// Nothing listening.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := ln.Addr().String()
+	require.NoError(t, ln.Close())
+	s, err = NewRedisStore("redis://" + addr)
+	require.NoError(t, err)
+	_, err = s.Take(context.Background(), "k", Limit{Rate: 1, Burst: 1}, 1)
+	assert.Error(t, err)
+}
+
+func TestSharedOverRedis_TwoReplicas(t *testing.T) {
+	f := newFakeRedis(t, "")
+	limit := Limit{Rate: 0.001, Burst: 4}
+
+	var allowed int
+	for i := 0; i < 2; i++ {
+		store, err := NewRedisStore(f.url("", ""))
+		require.NoError(t, err)
+		replica := NewShared(store, nil)
+		for j := 0; j < 4; j++ {
+			if replica.Take(context.Background(), "cdn:slug:bucket", limit, 1).Allowed {
+				allowed++
+			}
+		}
+		assert.False(t, replica.Degraded())
+	}
+	assert.Equal(t, 4, allowed, "two replicas share one burst")
+}
@@ -0,0 +1,236 @@
+// internal/ratelimit/store.go
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"golang.org/x/time/rate"
+)
+
+// Limit is a token bucket: Burst tokens, refilled at Rate tokens per second.
+// A token is a request for request limits and a byte for bandwidth limits.
+type Limit struct {
+	Rate  float64
+	Burst int64
+}
+
+// Result is the outcome of taking tokens from a bucket.
+type Result struct {
+	Allowed   bool
+	Limit     int64
+	Remaining int64
+	// RetryAfter is how long until the tokens asked for are available,
+	// when they were not.
+	RetryAfter time.Duration
+	// Reset is how long until the bucket is full again.
+	Reset time.Duration
+}
+
+// Info converts the result for SetHeaders.
+func (r Result) Info() RateLimitInfo {
+	return RateLimitInfo{
+		Limit:     int(r.Limit),
+		Remaining: int(r.Remaining),
+		Reset:     time.Now().Add(r.Reset).Unix(),
+	}
+}
+
+// RetryAfterSeconds rounds RetryAfter up to whole seconds (at least one),
+// for the Retry-After header.
+func (r Result) RetryAfterSeconds() int {
+	secs := int(math.Ceil(r.RetryAfter.Seconds()))
+	if secs < 1 {
+		secs = 1
+	}
+	return secs
+}
+
+// result builds a Result from the tokens left in the bucket after a take.
+func (l Limit) result(allowed bool, tokens float64, cost int64) Result {
+	res := Result{Allowed: allowed, Limit: l.Burst, Remaining: int64(math.Max(tokens, 0))}
+	if l.Rate > 0 {
+		res.Reset = secondsDuration((float64(l.Burst) - tokens) / l.Rate)
+		if !allowed {
+			res.RetryAfter = secondsDuration((float64(cost) - tokens) / l.Rate)
+		}
+	}
+	return res
+}
+
+func secondsDuration(secs float64) time.Duration {
+	if secs <= 0 {
+		return 0
+	}
+	return time.Duration(secs * float64(time.Second))
+}
+
+// Store keeps token buckets where every replica sees them, so N replicas
+// behind a load balancer enforce one limit instead of N.
+type Store interface {
+	// Take removes cost tokens from the bucket at key if it holds that
+	// many, creating it full on first use.
+	Take(ctx context.Context, key string, limit Limit, cost int64) (Result, error)
+}
+
+const (
+	// storeTimeout bounds one round trip to the store; a limiter must never
+	// be what makes a request slow.
+	storeTimeout = 100 * time.Millisecond
+	// storeRetryAfter is how long limits stay local after the store fails,
+	// so an outage costs one timeout per interval rather than per request.
+	storeRetryAfter = 5 * time.Second
+	// maxLocalBuckets mirrors the memory protection of the in-memory
+	// limiters in internal/api.
+	maxLocalBuckets = 10000
+)
+
+// Shared takes tokens from a Store and degrades to per-process buckets
+// while the store is unreachable (or when there is none). Degraded limits
+// are per replica again, which errs towards allowing traffic.
+type Shared struct {
+	store  Store
+	logger *zap.Logger
+
+	mu        sync.Mutex
+	local     map[string]*rate.Limiter
+	downUntil time.Time
+}
+
+// NewShared creates a Shared over store. A nil store keeps every limit in
+// process memory.
+func NewShared(store Store, logger *zap.Logger) *Shared {
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	return &Shared{store: store, logger: logger, local: make(map[string]*rate.Limiter)}
+}
+
+// Take removes cost tokens from the bucket at key.
+func (s *Shared) Take(ctx context.Context, key string, limit Limit, cost int64) Result {
+	if s.store != nil && s.storeUp() {
+		ctx, cancel := context.WithTimeout(ctx, storeTimeout)
+		res, err := s.store.Take(ctx, key, limit, cost)
+		cancel()
+		if err == nil {
+			return res
+		}
+		s.storeDown(err)
+	}
+	return s.takeLocal(key, limit, cost)
+}
+
+// Wait blocks until cost tokens can be taken from the bucket at key.
+func (s *Shared) Wait(ctx context.Context, key string, limit Limit, cost int64) error {
+	if cost > limit.Burst {
+		return fmt.Errorf("rate limit: %d tokens exceed the burst of %d", cost, limit.Burst)
+	}
+	for {
+		res := s.Take(ctx, key, limit, cost)
+		if res.Allowed {
+			return nil
+		}
+		wait := res.RetryAfter
+		if wait < time.Millisecond {
+			wait = time.Millisecond
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// Degraded reports whether limits are currently enforced per process.
+func (s *Shared) Degraded() bool {
+	return s.store == nil || !s.storeUp()
+}
+
+func (s *Shared) storeUp() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return time.Now().After(s.downUntil)
+}
+
+func (s *Shared) storeDown(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if time.Now().After(s.downUntil) {
+		s.logger.Warn("rate limit store unreachable; using local limits",
+			zap.Error(err), zap.Duration("retry_in", storeRetryAfter))
+	}
+	s.downUntil = time.Now().Add(storeRetryAfter)
+}
+
+func (s *Shared) takeLocal(key string, limit Limit, cost int64) Result {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.local) >= maxLocalBuckets {
+		s.local = make(map[string]*rate.Limiter)
+	}
+	lim, ok := s.local[key]
+	if !ok {
+		lim = rate.NewLimiter(rate.Limit(limit.Rate), int(limit.Burst))
+		s.local[key] = lim
+	} else {
+		lim.SetLimit(rate.Limit(limit.Rate))
+		lim.SetBurst(int(limit.Burst))
+	}
+
+	now := time.Now()
+	allowed := lim.AllowN(now, int(cost))
+	return limit.result(allowed, lim.TokensAt(now), cost)
+}
+
+// SharedLimiter applies one limit to every key, with the Allow(key) shape
+// of the in-memory limiters.
+type SharedLimiter struct {
+	shared *Shared
+	name   string
+	limit  Limit
+}
+
+// NewSharedLimiter creates a limiter whose buckets are named name:key.
+func NewSharedLimiter(shared *Shared, name string, ratePerSecond float64, burst int) *SharedLimiter {
+	return &SharedLimiter{shared: shared, name: name, limit: Limit{Rate: ratePerSecond, Burst: int64(burst)}}
+}
+
+// Allow checks if a request for key can proceed.
+func (l *SharedLimiter) Allow(key string) bool {
+	return l.Take(context.Background(), key).Allowed
+}
+
+// Take takes one request's token for key.
+func (l *SharedLimiter) Take(ctx context.Context, key string) Result {
+	return l.shared.Take(ctx, l.name+":"+key, l.limit, 1)
+}
+
+// Middleware limits requests by keyFunc's key (requests without one pass),
+// adding the rate limit headers and answering 429 when over the limit.
+func (l *SharedLimiter) Middleware(keyFunc func(*http.Request) string, useIETF bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := keyFunc(r)
+			if key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			res := l.Take(r.Context(), key)
+			SetHeaders(w, res.Info(), useIETF)
+			if !res.Allowed {
+				FormatRateLimitError(w, res.RetryAfterSeconds())
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stubStore answers every take with res, or fails with err.
+type stubStore struct {
+	res   Result
+	err   error
+	calls int
+}
+
+func (s *stubStore) Take(ctx context.Context, key string, limit Limit, cost int64) (Result, error) {
+	s.calls++
+	if _, ok := ctx.Deadline(); !ok {
+		return Result{}, errors.New("take without a deadline")
+	}
+	return s.res, s.err
+}
+
+func TestShared_Local(t *testing.T) {
+	shared := NewShared(nil, nil)
+	assert.True(t, shared.Degraded())
+
+	limit := Limit{Rate: 1, Burst: 2}
+	res := shared.Take(context.Background(), "k", limit, 1)
+	assert.True(t, res.Allowed)
+	assert.Equal(t, int64(1), res.Remaining)
+	assert.True(t, shared.Take(context.Background(), "k", limit, 1).Allowed)
+
+	res = shared.Take(context.Background(), "k", limit, 1)
+	assert.False(t, res.Allowed)
+	assert.Equal(t, int64(2), res.Limit)
+	assert.InDelta(t, time.Second, res.RetryAfter, float64(50*time.Millisecond))
+	assert.Equal(t, 1, res.RetryAfterSeconds())
+
+	assert.True(t, shared.Take(context.Background(), "other", limit, 1).Allowed)
+}
+
+func TestShared_DegradesWhileStoreIsDown(t *testing.T) {
+	store := &stubStore{err: errors.New("connection refused")}
+	shared := NewShared(store, nil)
+
+	limit := Limit{Rate: 1, Burst: 1}
+	assert.True(t, shared.Take(context.Background(), "k", limit, 1).Allowed, "falls back to a local bucket")
+	assert.False(t, shared.Take(context.Background(), "k", limit, 1).Allowed, "the local bucket still limits")
+	assert.Equal(t, 1, store.calls, "the store is not retried on every request")
+	assert.True(t, shared.Degraded())
+
+	// Once the retry interval has passed the store is used again.
+	store.err = nil
+	store.res = Result{Allowed: true, Limit: 1}
+	shared.mu.Lock()
+	shared.downUntil = time.Now().Add(-time.Millisecond)
+	shared.mu.Unlock()
+	assert.True(t, shared.Take(context.Background(), "k", limit, 1).Allowed)
+	assert.Equal(t, 2, store.calls)
+	assert.False(t, shared.Degraded())
+}
+
+func TestShared_Wait(t *testing.T) {
+	shared := NewShared(nil, nil)
+	limit := Limit{Rate: 20, Burst: 1}
+
+	start := time.Now()
+	require.NoError(t, shared.Wait(context.Background(), "k", limit, 1))
+	require.NoError(t, shared.Wait(context.Background(), "k", limit, 1))
+	assert.GreaterOrEqual(t, time.Since(start), 40*time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.ErrorIs(t, shared.Wait(ctx, "k", limit, 1), context.Canceled)
+	assert.Error(t, shared.Wait(context.Background(), "k", limit, 2), "more than the burst can never be taken")
+}
+
+func TestSharedLimiter_Middleware(t *testing.T) {
+	limiter := NewSharedLimiter(NewShared(nil, nil), "api", 1, 1)
+	handler := limiter.Middleware(func(r *http.Request) string {
+		return r.Header.Get("X-Tenant-ID")
+	}, false)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Tenant-ID", "t1")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "1", w.Header().Get("X-RateLimit-Limit"))
+	assert.Equal(t, "0", w.Header().Get("X-RateLimit-Remaining"))
+	assert.NotEmpty(t, w.Header().Get("X-RateLimit-Reset"))
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "1", w.Header().Get("Retry-After"))
+
+	// Requests without a key are not limited.
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get("X-RateLimit-Limit"))
+}
+
+func TestOperationLimiter_UseShared(t *testing.T) {
+	store := &stubStore{res: Result{Allowed: false}}
+	ol := NewOperationLimiter()
+	ol.SetLimit("PUT", 10, 10)
+	ol.UseShared(NewShared(store, nil))
+
+	assert.False(t, ol.Allow("tenant-1", "PUT"), "the shared bucket decides")
+	assert.True(t, ol.Allow("tenant-1", "GET"), "unlimited operations skip the store")
+	assert.Equal(t, 1, store.calls)
+}
@@ -2,6 +2,7 @@
 package ratelimit
 
 import (
+	"context"
 	"sync"
 
 	"golang.org/x/time/rate"
@@ -15,6 +16,7 @@ type TenantLimiter struct {
 	tierLimits    map[string]map[string]*OperationConfig // tier -> operation -> config
 	tenantTiers   map[string]string                      // tenant -> tier
 	limiters      map[string]*rate.Limiter               // tenant:operation -> limiter
+	shared        *Shared                                // replaces limiters when set
 }
 
 // NewTenantLimiter creates a new tenant-aware limiter
@@ -77,10 +79,18 @@ func (tl *TenantLimiter) SetTenantTier(tenantID, tier string) {
 	tl.tenantTiers[tenantID] = tier
 }
 
+// UseShared keeps the per-tenant buckets in shared, so every replica
+// enforces one limit
+func (tl *TenantLimiter) UseShared(shared *Shared) {
+	tl.mu.Lock()
+	defer tl.mu.Unlock()
+
+	tl.shared = shared
+}
+
 // Allow checks if a tenant can perform an operation
 func (tl *TenantLimiter) Allow(tenantID, operation string) bool {
 	tl.mu.Lock()
-	defer tl.mu.Unlock()
 
 	// Find the appropriate config (tenant-specific > tier > default)
 	var config *OperationConfig
@@ -110,9 +120,17 @@ func (tl *TenantLimiter) Allow(tenantID, operation string) bool {
 
 	// No limit configured
 	if config == nil {
+		tl.mu.Unlock()
 		return true
 	}
 
+	if shared := tl.shared; shared != nil {
+		limit := config.limit()
+		tl.mu.Unlock()
+		return shared.Take(context.Background(), "tenant:"+tenantID+":"+operation, limit, 1).Allowed
+	}
+	defer tl.mu.Unlock()
+
 	// Get or create limiter
 	key := tenantID + ":" + operation
 	limiter, exists := tl.limiters[key]
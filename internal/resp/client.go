@@ -0,0 +1,236 @@
+// internal/resp/client.go
+package resp
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultTimeout bounds a command whose context has no deadline.
+const defaultTimeout = 5 * time.Second
+
+// maxIdle is how many connections a Client keeps open between commands.
+const maxIdle = 16
+
+// Client speaks just enough RESP (the Redis protocol) to send commands and
+// read their replies, which keeps a Redis client library out of the build.
+// It is safe for concurrent use; each command borrows a pooled connection.
+type Client struct {
+	addr     string
+	password string
+	db       int
+	tls      *tls.Config
+	idle     chan *conn
+}
+
+// NewClient connects lazily to a redis:// or rediss:// URL
+// (redis://[:password@]host[:port][/db]).
+func NewClient(rawURL string) (*Client, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("parse redis url: %w", err)
+	}
+	c := &Client{idle: make(chan *conn, maxIdle)}
+	switch u.Scheme {
+	case "redis":
+	case "rediss":
+		c.tls = &tls.Config{ServerName: u.Hostname(), MinVersion: tls.VersionTLS12}
+	default:
+		return nil, fmt.Errorf("redis url: unsupported scheme %q", u.Scheme)
+	}
+	if u.Hostname() == "" {
+		return nil, errors.New("redis url: missing host")
+	}
+	port := u.Port()
+	if port == "" {
+		port = "6379"
+	}
+	c.addr = net.JoinHostPort(u.Hostname(), port)
+	if u.User != nil {
+		c.password, _ = u.User.Password()
+	}
+	if path := strings.Trim(u.Path, "/"); path != "" {
+		if c.db, err = strconv.Atoi(path); err != nil || c.db < 0 {
+			return nil, fmt.Errorf("redis url: invalid database %q", path)
+		}
+	}
+	return c, nil
+}
+
+// Do sends one command and returns its reply: string, int64, nil,
+// []interface{}, or an Error for an error reply.
+func (c *Client) Do(ctx context.Context, args ...string) (interface{}, error) {
+	cn, err := c.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	reply, err := cn.do(ctx, args...)
+	var rerr Error
+	if err != nil && !errors.As(err, &rerr) {
+		// The stream may be mid-reply; never reuse it.
+		_ = cn.Close()
+		return nil, err
+	}
+	c.put(cn)
+	return reply, err
+}
+
+// Close closes the idle connections.
+func (c *Client) Close() error {
+	for {
+		select {
+		case cn := <-c.idle:
+			_ = cn.Close()
+		default:
+			return nil
+		}
+	}
+}
+
+func (c *Client) get(ctx context.Context) (*conn, error) {
+	select {
+	case cn := <-c.idle:
+		return cn, nil
+	default:
+	}
+
+	d := net.Dialer{}
+	var nc net.Conn
+	var err error
+	if c.tls != nil {
+		nc, err = (&tls.Dialer{NetDialer: &d, Config: c.tls}).DialContext(ctx, "tcp", c.addr)
+	} else {
+		nc, err = d.DialContext(ctx, "tcp", c.addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("redis dial %s: %w", c.addr, err)
+	}
+	cn := &conn{Conn: nc, r: bufio.NewReader(nc)}
+	if c.password != "" {
+		if _, err := cn.do(ctx, "AUTH", c.password); err != nil {
+			_ = nc.Close()
+			return nil, fmt.Errorf("redis auth: %w", err)
+		}
+	}
+	if c.db != 0 {
+		if _, err := cn.do(ctx, "SELECT", strconv.Itoa(c.db)); err != nil {
+			_ = nc.Close()
+			return nil, fmt.Errorf("redis select %d: %w", c.db, err)
+		}
+	}
+	return cn, nil
+}
+
+func (c *Client) put(cn *conn) {
+	select {
+	case c.idle <- cn:
+	default:
+		_ = cn.Close()
+	}
+}
+
+// Error is an error reply; the connection is still usable after one.
+type Error string
+
+func (e Error) Error() string { return "redis: " + string(e) }
+
+type conn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (cn *conn) do(ctx context.Context, args ...string) (interface{}, error) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(defaultTimeout)
+	}
+	if err := cn.SetDeadline(deadline); err != nil {
+		return nil, err
+	}
+	if err := WriteCommand(cn, args...); err != nil {
+		return nil, err
+	}
+	return ReadReply(cn.r)
+}
+
+// WriteCommand writes args as a RESP array of bulk strings.
+func WriteCommand(w io.Writer, args ...string) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, a := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(a), a)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// ReadReply reads one RESP value. Commands sent by WriteCommand read back
+// as []interface{} of strings.
+func ReadReply(r *bufio.Reader) (interface{}, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, errors.New("redis: empty reply")
+	}
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, Error(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("redis: bad bulk length %q", line)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		return string(buf[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("redis: bad array length %q", line)
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		// Read every element even past an error element, so the stream
+		// stays aligned for the next command.
+		vals := make([]interface{}, n)
+		var replyErr error
+		for i := range vals {
+			v, err := ReadReply(r)
+			var rerr Error
+			if err != nil && !errors.As(err, &rerr) {
+				return nil, err
+			}
+			if err != nil && replyErr == nil {
+				replyErr = err
+			}
+			vals[i] = v
+		}
+		if replyErr != nil {
+			return nil, replyErr
+		}
+		return vals, nil
+	}
+	return nil, fmt.Errorf("redis: unexpected reply %q", line)
+}
@@ -0,0 +1,102 @@
+package resp
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewClient(t *testing.T) {
+	c, err := NewClient("redis://:secret@cache.internal/2")
+	require.NoError(t, err)
+	assert.Equal(t, "cache.internal:6379", c.addr)
+	assert.Equal(t, "secret", c.password)
+	assert.Equal(t, 2, c.db)
+	assert.Nil(t, c.tls)
+
+	c, err = NewClient("rediss://cache.internal:6380")
+	require.NoError(t, err)
+	assert.Equal(t, "cache.internal:6380", c.addr)
+	require.NotNil(t, c.tls)
+	assert.Equal(t, "cache.internal", c.tls.ServerName)
+
+	for _, bad := range []string{"http://cache.internal", "redis://", "redis://cache.internal/x"} {
+		_, err := NewClient(bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestReadReply(t *testing.T) {
+	r := bufio.NewReader(strings.NewReader("+OK\r\n:42\r\n$5\r\nhello\r\n$-1\r\n*2\r\n$1\r\na\r\n:1\r\n-ERR nope\r\n*2\r\n-ERR first\r\n:2\r\n+next\r\n"))
+	for _, want := range []interface{}{"OK", int64(42), "hello", nil, []interface{}{"a", int64(1)}} {
+		got, err := ReadReply(r)
+		require.NoError(t, err)
+		assert.Equal(t, want, got)
+	}
+	_, err := ReadReply(r)
+	assert.Equal(t, Error("ERR nope"), err)
+
+	// An error inside an array is returned after the whole array is read.
+	_, err = ReadReply(r)
+	assert.Equal(t, Error("ERR first"), err)
+	got, err := ReadReply(r)
+	require.NoError(t, err)
+	assert.Equal(t, "next", got)
+}
+
+func TestClient_Do(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer func() { _ = ln.Close() }()
+
+	commands := make(chan []interface{}, 8)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer func() { _ = conn.Close() }()
+		r := bufio.NewReader(conn)
+		for {
+			cmd, err := ReadReply(r)
+			if err != nil {
+				return
+			}
+			args := cmd.([]interface{})
+			commands <- args
+			reply := "+OK\r\n"
+			if args[0] == "PING" {
+				reply = "-ERR unknown command\r\n"
+			}
+			if _, err := io.WriteString(conn, reply); err != nil {
+				return
+			}
+		}
+	}()
+
+	c, err := NewClient("redis://:pw@" + ln.Addr().String() + "/3")
+	require.NoError(t, err)
+	defer func() { _ = c.Close() }()
+
+	reply, err := c.Do(context.Background(), "SET", "k", "v")
+	require.NoError(t, err)
+	assert.Equal(t, "OK", reply)
+
+	// An error reply leaves the connection in the pool.
+	_, err = c.Do(context.Background(), "PING")
+	assert.Equal(t, Error("ERR unknown command"), err)
+	_, err = c.Do(context.Background(), "SET", "k", "w")
+	require.NoError(t, err)
+
+	assert.Equal(t, []interface{}{"AUTH", "pw"}, <-commands)
+	assert.Equal(t, []interface{}{"SELECT", "3"}, <-commands)
+	assert.Equal(t, []interface{}{"SET", "k", "v"}, <-commands)
+	assert.Equal(t, []interface{}{"PING"}, <-commands)
+	assert.Equal(t, []interface{}{"SET", "k", "w"}, <-commands)
+}
@@ -0,0 +1,42 @@
+package s3select
+
+import "fmt"
+
+// Error codes returned by S3 Select. Codes raised before the response
+// starts streaming become HTTP 400 errors; later ones are sent as event
+// stream error messages.
+const (
+	ErrMalformedXML                 = "MalformedXML"
+	ErrInvalidExpressionType        = "InvalidExpressionType"
+	ErrMissingRequiredParameter     = "MissingRequiredParameter"
+	ErrInvalidRequestParameter      = "InvalidRequestParameter"
+	ErrObjectSerializationConflict  = "ObjectSerializationConflict"
+	ErrInvalidCompressionFormat     = "InvalidCompressionFormat"
+	ErrInvalidFileHeaderInfo        = "InvalidFileHeaderInfo"
+	ErrInvalidJSONType              = "InvalidJsonType"
+	ErrInvalidQuoteFields           = "InvalidQuoteFields"
+	ErrExpressionTooLong            = "ExpressionTooLong"
+	ErrParseUnexpectedToken         = "ParseUnexpectedToken"
+	ErrUnsupportedSQLOperation      = "UnsupportedSqlOperation"
+	ErrUnsupportedFunction          = "UnsupportedFunction"
+	ErrIncorrectSQLFunctionArgument = "IncorrectSqlFunctionArgumentType"
+	ErrCastFailed                   = "CastFailed"
+	ErrEvaluatorInvalidArguments    = "EvaluatorInvalidArguments"
+	ErrCSVParsingError              = "CSVParsingError"
+	ErrJSONParsingError             = "JSONParsingError"
+	ErrParquetParsingError          = "ParquetParsingError"
+	ErrOverMaxRecordSize            = "OverMaxRecordSize"
+	ErrInternalError                = "InternalError"
+)
+
+// Error is an S3 Select error with its S3 error code.
+type Error struct {
+	Code    string
+	Message string
+}
+
+func (e *Error) Error() string { return e.Code + ": " + e.Message }
+
+func errorf(code, format string, args ...any) *Error {
+	return &Error{Code: code, Message: fmt.Sprintf(format, args...)}
+}
@@ -0,0 +1,641 @@
+package s3select
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// evaluator evaluates expressions against records.
+type evaluator struct {
+	now time.Time
+	// aggResults holds final aggregate values while projecting an
+	// aggregate query's single output row.
+	aggResults []any
+}
+
+func (ev *evaluator) eval(e expr, rec any) (any, error) {
+	switch x := e.(type) {
+	case *literalExpr:
+		return x.v, nil
+	case *pathExpr:
+		return resolvePath(rec, x.steps), nil
+	case *unaryExpr:
+		v, err := ev.eval(x.x, rec)
+		if err != nil {
+			return nil, err
+		}
+		if x.op == "NOT" {
+			b, known, err := truth(v)
+			if err != nil || !known {
+				return nil, err
+			}
+			return !b, nil
+		}
+		if isAbsent(v) {
+			return nil, nil
+		}
+		n, ok := toNumber(v)
+		if !ok {
+			return nil, errorf(ErrCastFailed, "cannot negate %q", toString(v))
+		}
+		if i, isInt := n.(int64); isInt {
+			return -i, nil
+		}
+		return -n.(float64), nil
+	case *binaryExpr:
+		return ev.binary(x, rec)
+	case *likeExpr:
+		return ev.like(x, rec)
+	case *betweenExpr:
+		v, err := ev.eval(x.x, rec)
+		if err != nil {
+			return nil, err
+		}
+		lo, err := ev.eval(x.lo, rec)
+		if err != nil {
+			return nil, err
+		}
+		hi, err := ev.eval(x.hi, rec)
+		if err != nil {
+			return nil, err
+		}
+		if isAbsent(v) || isAbsent(lo) || isAbsent(hi) {
+			return nil, nil
+		}
+		c1, ok1 := compareValues(v, lo)
+		c2, ok2 := compareValues(v, hi)
+		if !ok1 || !ok2 {
+			return nil, nil
+		}
+		return (c1 >= 0 && c2 <= 0) != x.not, nil
+	case *inExpr:
+		v, err := ev.eval(x.x, rec)
+		if err != nil {
+			return nil, err
+		}
+		if isAbsent(v) {
+			return nil, nil
+		}
+		sawNull := false
+		for _, item := range x.list {
+			iv, err := ev.eval(item, rec)
+			if err != nil {
+				return nil, err
+			}
+			if isAbsent(iv) {
+				sawNull = true
+				continue
+			}
+			if c, ok := compareValues(v, iv); ok && c == 0 {
+				return !x.not, nil
+			}
+		}
+		if sawNull {
+			return nil, nil
+		}
+		return x.not, nil
+	case *isExpr:
+		v, err := ev.eval(x.x, rec)
+		if err != nil {
+			return nil, err
+		}
+		var res bool
+		if x.missing {
+			_, res = v.(missing)
+		} else {
+			res = isAbsent(v)
+		}
+		return res != x.not, nil
+	case *funcExpr:
+		return ev.call(x, rec)
+	case *castExpr:
+		v, err := ev.eval(x.x, rec)
+		if err != nil {
+			return nil, err
+		}
+		return castValue(v, x.typ)
+	case *caseExpr:
+		return ev.caseValue(x, rec)
+	case *aggExpr:
+		if x.slot < len(ev.aggResults) {
+			return ev.aggResults[x.slot], nil
+		}
+		return nil, errorf(ErrUnsupportedSQLOperation, "aggregate used outside the select list")
+	}
+	return nil, errorf(ErrInternalError, "unknown expression %T", e)
+}
+
+// resolvePath walks steps from v. Paths that do not exist are MISSING.
+func resolvePath(v any, steps []pathStep) any {
+	for _, s := range steps {
+		switch x := v.(type) {
+		case *object:
+			if s.isIndex {
+				return missing{}
+			}
+			val, ok := x.get(s.name, s.quoted)
+			if !ok {
+				return missing{}
+			}
+			v = val
+		case []any:
+			if !s.isIndex || s.index >= len(x) {
+				return missing{}
+			}
+			v = x[s.index]
+		default:
+			return missing{}
+		}
+	}
+	return v
+}
+
+// truth interprets v as a boolean. known is false for NULL and MISSING.
+func truth(v any) (b, known bool, err error) {
+	switch x := v.(type) {
+	case nil, missing:
+		return false, false, nil
+	case bool:
+		return x, true, nil
+	case string:
+		switch strings.ToLower(strings.TrimSpace(x)) {
+		case "true":
+			return true, true, nil
+		case "false":
+			return false, true, nil
+		}
+	}
+	return false, false, errorf(ErrEvaluatorInvalidArguments, "%q is not a boolean", toString(v))
+}
+
+func (ev *evaluator) binary(x *binaryExpr, rec any) (any, error) {
+	l, err := ev.eval(x.l, rec)
+	if err != nil {
+		return nil, err
+	}
+	switch x.op {
+	case "AND", "OR":
+		lb, lk, err := truth(l)
+		if err != nil {
+			return nil, err
+		}
+		// Short-circuit on a known deciding value.
+		if lk && (lb == (x.op == "OR")) {
+			return lb, nil
+		}
+		r, err := ev.eval(x.r, rec)
+		if err != nil {
+			return nil, err
+		}
+		rb, rk, err := truth(r)
+		if err != nil {
+			return nil, err
+		}
+		if rk && (rb == (x.op == "OR")) {
+			return rb, nil
+		}
+		if !lk || !rk {
+			return nil, nil
+		}
+		return rb, nil
+	}
+
+	r, err := ev.eval(x.r, rec)
+	if err != nil {
+		return nil, err
+	}
+	if isAbsent(l) || isAbsent(r) {
+		return nil, nil
+	}
+	switch x.op {
+	case "=", "!=":
+		c, ok := compareValues(l, r)
+		return (ok && c == 0) == (x.op == "="), nil
+	case "<", "<=", ">", ">=":
+		c, ok := compareValues(l, r)
+		if !ok {
+			return nil, nil
+		}
+		switch x.op {
+		case "<":
+			return c < 0, nil
+		case "<=":
+			return c <= 0, nil
+		case ">":
+			return c > 0, nil
+		}
+		return c >= 0, nil
+	case "||":
+		return toString(l) + toString(r), nil
+	}
+	return arithmetic(x.op, l, r)
+}
+
+func arithmetic(op string, l, r any) (any, error) {
+	ln, ok1 := toNumber(l)
+	rn, ok2 := toNumber(r)
+	if !ok1 || !ok2 {
+		bad := l
+		if ok1 {
+			bad = r
+		}
+		return nil, errorf(ErrCastFailed, "%q is not a number", toString(bad))
+	}
+	li, lInt := ln.(int64)
+	ri, rInt := rn.(int64)
+	if lInt && rInt {
+		switch op {
+		case "+":
+			if s := li + ri; (s > li) == (ri > 0) {
+				return s, nil
+			}
+		case "-":
+			if d := li - ri; (d < li) == (ri > 0) {
+				return d, nil
+			}
+		case "*":
+			if li == 0 || ri == 0 {
+				return int64(0), nil
+			}
+			if p := li * ri; p/ri == li && !(li == -1 && ri == math.MinInt64) && !(ri == -1 && li == math.MinInt64) {
+				return p, nil
+			}
+		case "/":
+			if ri == 0 {
+				return nil, errorf(ErrEvaluatorInvalidArguments, "division by zero")
+			}
+			return li / ri, nil
+		case "%":
+			if ri == 0 {
+				return nil, errorf(ErrEvaluatorInvalidArguments, "division by zero")
+			}
+			return li % ri, nil
+		}
+		// Overflow falls through to floating point.
+	}
+	lf, rf := toFloat(ln), toFloat(rn)
+	switch op {
+	case "+":
+		return lf + rf, nil
+	case "-":
+		return lf - rf, nil
+	case "*":
+		return lf * rf, nil
+	case "/":
+		if rf == 0 {
+			return nil, errorf(ErrEvaluatorInvalidArguments, "division by zero")
+		}
+		return lf / rf, nil
+	case "%":
+		if rf == 0 {
+			return nil, errorf(ErrEvaluatorInvalidArguments, "division by zero")
+		}
+		return math.Mod(lf, rf), nil
+	}
+	return nil, errorf(ErrInternalError, "unknown operator %s", op)
+}
+
+func (ev *evaluator) like(x *likeExpr, rec any) (any, error) {
+	v, err := ev.eval(x.x, rec)
+	if err != nil {
+		return nil, err
+	}
+	pat, err := ev.eval(x.pattern, rec)
+	if err != nil {
+		return nil, err
+	}
+	if isAbsent(v) || isAbsent(pat) {
+		return nil, nil
+	}
+	esc := rune(-1)
+	if x.escape != nil {
+		escV, err := ev.eval(x.escape, rec)
+		if err != nil {
+			return nil, err
+		}
+		s := toString(escV)
+		if utf8.RuneCountInString(s) != 1 {
+			return nil, errorf(ErrEvaluatorInvalidArguments, "LIKE escape must be a single character")
+		}
+		esc, _ = utf8.DecodeRuneInString(s)
+	}
+	return likeMatch([]rune(toString(v)), []rune(toString(pat)), esc) != x.not, nil
+}
+
+// likeMatch matches s against a LIKE pattern where % matches any run and
+// _ matches one character.
+func likeMatch(s, p []rune, esc rune) bool {
+	si, pi := 0, 0
+	star, mark := -1, 0
+	for si < len(s) {
+		if pi < len(p) {
+			c := p[pi]
+			switch {
+			case c == esc && pi+1 < len(p):
+				if p[pi+1] == s[si] {
+					si++
+					pi += 2
+					continue
+				}
+			case c == '%':
+				star, mark = pi, si
+				pi++
+				continue
+			case c == '_' || c == s[si]:
+				si++
+				pi++
+				continue
+			}
+		}
+		if star < 0 {
+			return false
+		}
+		mark++
+		si, pi = mark, star+1
+	}
+	for pi < len(p) && p[pi] == '%' {
+		pi++
+	}
+	return pi == len(p)
+}
+
+func (ev *evaluator) call(x *funcExpr, rec any) (any, error) {
+	args := make([]any, len(x.args))
+	for i, a := range x.args {
+		v, err := ev.eval(a, rec)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = v
+	}
+	switch x.name {
+	case "COALESCE":
+		for _, a := range args {
+			if !isAbsent(a) {
+				return a, nil
+			}
+		}
+		return nil, nil
+	case "NULLIF":
+		if isAbsent(args[0]) {
+			return nil, nil
+		}
+		if c, ok := compareValues(args[0], args[1]); ok && c == 0 && !isAbsent(args[1]) {
+			return nil, nil
+		}
+		return args[0], nil
+	case "UTCNOW":
+		return ev.now, nil
+	}
+	for _, a := range args {
+		if isAbsent(a) {
+			return nil, nil
+		}
+	}
+	switch x.name {
+	case "LOWER":
+		return strings.ToLower(toString(args[0])), nil
+	case "UPPER":
+		return strings.ToUpper(toString(args[0])), nil
+	case "TRIM":
+		return strings.TrimSpace(toString(args[0])), nil
+	case "CHAR_LENGTH", "CHARACTER_LENGTH":
+		return int64(utf8.RuneCountInString(toString(args[0]))), nil
+	case "ABS":
+		n, ok := toNumber(args[0])
+		if !ok {
+			return nil, errorf(ErrCastFailed, "%q is not a number", toString(args[0]))
+		}
+		if i, isInt := n.(int64); isInt {
+			if i < 0 {
+				return -i, nil
+			}
+			return i, nil
+		}
+		return math.Abs(n.(float64)), nil
+	case "SUBSTRING":
+		return substring(args)
+	}
+	return nil, errorf(ErrUnsupportedFunction, "function %s is not supported", x.name)
+}
+
+// substring implements SUBSTRING(s, start[, length]) with 1-based, SQL
+// standard bounds.
+func substring(args []any) (any, error) {
+	rs := []rune(toString(args[0]))
+	start, err := castValue(args[1], "INT")
+	if err != nil {
+		return nil, err
+	}
+	from := start.(int64)
+	to := int64(len(rs)) + 1
+	if len(args) == 3 {
+		l, err := castValue(args[2], "INT")
+		if err != nil {
+			return nil, err
+		}
+		if l.(int64) < 0 {
+			return nil, errorf(ErrEvaluatorInvalidArguments, "SUBSTRING length must not be negative")
+		}
+		if end := from + l.(int64); end < to {
+			to = end
+		}
+	}
+	if from < 1 {
+		from = 1
+	}
+	if from >= to {
+		return "", nil
+	}
+	return string(rs[from-1 : to-1]), nil
+}
+
+func (ev *evaluator) caseValue(x *caseExpr, rec any) (any, error) {
+	var operand any
+	if x.operand != nil {
+		v, err := ev.eval(x.operand, rec)
+		if err != nil {
+			return nil, err
+		}
+		operand = v
+	}
+	for _, w := range x.whens {
+		c, err := ev.eval(w.cond, rec)
+		if err != nil {
+			return nil, err
+		}
+		var hit bool
+		if x.operand != nil {
+			if !isAbsent(operand) && !isAbsent(c) {
+				cmp, ok := compareValues(operand, c)
+				hit = ok && cmp == 0
+			}
+		} else {
+			b, known, err := truth(c)
+			if err != nil {
+				return nil, err
+			}
+			hit = known && b
+		}
+		if hit {
+			return ev.eval(w.then, rec)
+		}
+	}
+	if x.els != nil {
+		return ev.eval(x.els, rec)
+	}
+	return nil, nil
+}
+
+// castValue converts v to a CAST target type.
+func castValue(v any, typ string) (any, error) {
+	if isAbsent(v) {
+		return nil, nil
+	}
+	fail := func() (any, error) {
+		return nil, errorf(ErrCastFailed, "cannot cast %q to %s", toString(v), typ)
+	}
+	switch typ {
+	case "INT":
+		switch x := v.(type) {
+		case int64:
+			return x, nil
+		case float64:
+			if math.IsNaN(x) || math.Abs(x) >= math.MaxInt64 {
+				return fail()
+			}
+			return int64(x), nil
+		case bool:
+			return boolInt(x), nil
+		case string:
+			n, ok := toNumber(x)
+			if !ok {
+				return fail()
+			}
+			return castValue(n, typ)
+		}
+	case "FLOAT":
+		switch x := v.(type) {
+		case bool:
+			return float64(boolInt(x)), nil
+		default:
+			n, ok := toNumber(x)
+			if !ok {
+				return fail()
+			}
+			return toFloat(n), nil
+		}
+	case "STRING":
+		return toString(v), nil
+	case "BOOL":
+		switch x := v.(type) {
+		case bool:
+			return x, nil
+		case int64:
+			return x != 0, nil
+		case float64:
+			return x != 0, nil
+		case string:
+			b, err := strconv.ParseBool(strings.TrimSpace(x))
+			if err != nil {
+				return fail()
+			}
+			return b, nil
+		}
+	case "TIMESTAMP":
+		switch x := v.(type) {
+		case time.Time:
+			return x, nil
+		case string:
+			t, ok := parseTimestamp(x)
+			if !ok {
+				return fail()
+			}
+			return t, nil
+		}
+	}
+	return fail()
+}
+
+// aggState accumulates one aggregate.
+type aggState struct {
+	count   int64
+	sumInt  int64
+	sumF    float64
+	isFloat bool
+	best    any
+}
+
+func (a *aggState) add(fn string, v any) error {
+	if fn == "COUNT" {
+		if !isAbsent(v) {
+			a.count++
+		}
+		return nil
+	}
+	if isAbsent(v) {
+		return nil
+	}
+	switch fn {
+	case "SUM", "AVG":
+		n, ok := toNumber(v)
+		if !ok {
+			return errorf(ErrCastFailed, "%s over non-numeric value %q", fn, toString(v))
+		}
+		a.count++
+		if i, isInt := n.(int64); isInt && !a.isFloat {
+			if s := a.sumInt + i; (s > a.sumInt) == (i > 0) {
+				a.sumInt = s
+				return nil
+			}
+			a.isFloat = true
+			a.sumF = float64(a.sumInt)
+		} else if !a.isFloat {
+			a.isFloat = true
+			a.sumF = float64(a.sumInt)
+		}
+		a.sumF += toFloat(n)
+	case "MIN", "MAX":
+		if a.count == 0 {
+			a.best = v
+			a.count++
+			return nil
+		}
+		c, ok := compareValues(v, a.best)
+		if !ok {
+			return errorf(ErrEvaluatorInvalidArguments, "%s over values that cannot be compared", fn)
+		}
+		if (fn == "MIN" && c < 0) || (fn == "MAX" && c > 0) {
+			a.best = v
+		}
+		a.count++
+	}
+	return nil
+}
+
+func (a *aggState) result(fn string) any {
+	switch fn {
+	case "COUNT":
+		return a.count
+	case "SUM":
+		if a.count == 0 {
+			return nil
+		}
+		if a.isFloat {
+			return a.sumF
+		}
+		return a.sumInt
+	case "AVG":
+		if a.count == 0 {
+			return nil
+		}
+		if a.isFloat {
+			return a.sumF / float64(a.count)
+		}
+		return float64(a.sumInt) / float64(a.count)
+	}
+	return a.best
+}
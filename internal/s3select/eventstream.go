@@ -0,0 +1,65 @@
+package s3select
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+)
+
+// eventHeader is a string-valued event stream header.
+type eventHeader struct {
+	name, value string
+}
+
+// headerTypeString is the event stream header value type for strings.
+const headerTypeString = 7
+
+// encodeMessage frames one event stream message:
+//
+//	total length | headers length | prelude CRC | headers | payload | message CRC
+//
+// with big-endian uint32 lengths and CRC32 (IEEE) checksums.
+func encodeMessage(headers []eventHeader, payload []byte) []byte {
+	hlen := 0
+	for _, h := range headers {
+		hlen += 1 + len(h.name) + 1 + 2 + len(h.value)
+	}
+	total := 12 + hlen + len(payload) + 4
+	msg := make([]byte, 0, total)
+	msg = binary.BigEndian.AppendUint32(msg, uint32(total))
+	msg = binary.BigEndian.AppendUint32(msg, uint32(hlen))
+	msg = binary.BigEndian.AppendUint32(msg, crc32.ChecksumIEEE(msg[:8]))
+	for _, h := range headers {
+		msg = append(msg, byte(len(h.name)))
+		msg = append(msg, h.name...)
+		msg = append(msg, headerTypeString)
+		msg = binary.BigEndian.AppendUint16(msg, uint16(len(h.value)))
+		msg = append(msg, h.value...)
+	}
+	msg = append(msg, payload...)
+	return binary.BigEndian.AppendUint32(msg, crc32.ChecksumIEEE(msg))
+}
+
+func eventMessage(eventType, contentType string, payload []byte) []byte {
+	headers := []eventHeader{{":event-type", eventType}}
+	if contentType != "" {
+		headers = append(headers, eventHeader{":content-type", contentType})
+	}
+	headers = append(headers, eventHeader{":message-type", "event"})
+	return encodeMessage(headers, payload)
+}
+
+func errorMessage(code, message string) []byte {
+	return encodeMessage([]eventHeader{
+		{":error-code", code},
+		{":error-message", message},
+		{":message-type", "error"},
+	}, nil)
+}
+
+// statsXML renders the body of Stats and Progress events.
+func statsXML(root string, scanned, processed, returned int64) []byte {
+	return []byte(fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?><%s><BytesScanned>%d</BytesScanned>`+
+		`<BytesProcessed>%d</BytesProcessed><BytesReturned>%d</BytesReturned></%s>`,
+		root, scanned, processed, returned, root))
+}
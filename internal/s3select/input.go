@@ -0,0 +1,427 @@
+package s3select
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/FairForge/vaultaire/internal/parquet"
+)
+
+// maxRecordSize is the largest CSV or JSON LINES record S3 Select accepts.
+const maxRecordSize = 1 << 20
+
+// recordReader yields input records. offset is the position of the
+// record's first byte in the (decompressed) input, or -1 when unknown.
+type recordReader interface {
+	next() (rec any, offset int64, err error)
+}
+
+// csvReader parses CSV with arbitrary field and record delimiters.
+type csvReader struct {
+	r           *bufio.Reader
+	offset      int64
+	fieldDelim  []byte
+	recordDelim []byte
+	quote       int // -1 when quoting is disabled
+	escape      int
+	comment     int
+	names       []string
+	field       bytes.Buffer
+}
+
+func singleChar(s string, def int) (int, error) {
+	switch len(s) {
+	case 0:
+		return def, nil
+	case 1:
+		return int(s[0]), nil
+	}
+	return 0, errorf(ErrInvalidRequestParameter, "%q must be a single character", s)
+}
+
+func newCSVReader(r *bufio.Reader, offset int64, in *CSVInput) (*csvReader, error) {
+	c := &csvReader{r: r, offset: offset, fieldDelim: []byte(","), recordDelim: []byte("\n")}
+	if in.FieldDelimiter != "" {
+		c.fieldDelim = []byte(in.FieldDelimiter)
+	}
+	if in.RecordDelimiter != "" {
+		c.recordDelim = []byte(in.RecordDelimiter)
+	}
+	var err error
+	if c.quote, err = singleChar(in.QuoteCharacter, '"'); err != nil {
+		return nil, err
+	}
+	if c.escape, err = singleChar(in.QuoteEscapeCharacter, c.quote); err != nil {
+		return nil, err
+	}
+	if c.comment, err = singleChar(in.Comments, -1); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// matchDelim reports whether b (already read) starts delim, consuming the
+// rest of the delimiter when it does.
+func (c *csvReader) matchDelim(b byte, delim []byte) bool {
+	if b != delim[0] {
+		return false
+	}
+	if len(delim) == 1 {
+		return true
+	}
+	rest, err := c.r.Peek(len(delim) - 1)
+	if err != nil || !bytes.Equal(rest, delim[1:]) {
+		return false
+	}
+	_, _ = c.r.Discard(len(delim) - 1)
+	c.offset += int64(len(delim) - 1)
+	return true
+}
+
+// readRecord reads one record's fields. Blank lines and comments are
+// skipped.
+func (c *csvReader) readRecord() ([]string, int64, error) {
+	for {
+		start := c.offset
+		fields, empty, err := c.readFields()
+		if err != nil {
+			return nil, 0, err
+		}
+		if fields == nil {
+			return nil, 0, io.EOF
+		}
+		if !empty {
+			return fields, start, nil
+		}
+	}
+}
+
+func (c *csvReader) readFields() (fields []string, empty bool, err error) {
+	c.field.Reset()
+	size := 0
+	inQuotes, quoted, started := false, false, false
+	endField := func() {
+		s := c.field.String()
+		if !quoted && len(c.recordDelim) == 1 && c.recordDelim[0] == '\n' {
+			s = strings.TrimSuffix(s, "\r")
+		}
+		fields = append(fields, s)
+		c.field.Reset()
+		quoted = false
+	}
+	for {
+		b, err := c.r.ReadByte()
+		if err == io.EOF {
+			if !started {
+				return nil, false, nil
+			}
+			if inQuotes {
+				return nil, false, errorf(ErrCSVParsingError, "unterminated quoted field at byte %d", c.offset)
+			}
+			endField()
+			return fields, false, nil
+		}
+		if err != nil {
+			return nil, false, err
+		}
+		c.offset++
+		if size++; size > maxRecordSize {
+			return nil, false, errorf(ErrOverMaxRecordSize, "a record exceeds %d bytes", maxRecordSize)
+		}
+		if !started && int(b) == c.comment {
+			if err := c.skipLine(); err != nil {
+				return nil, false, err
+			}
+			return []string{}, true, nil
+		}
+		started = true
+
+		if inQuotes {
+			switch {
+			case int(b) == c.escape && c.escape != c.quote:
+				nb, err := c.r.ReadByte()
+				if err != nil {
+					return nil, false, errorf(ErrCSVParsingError, "unterminated quoted field at byte %d", c.offset)
+				}
+				c.offset++
+				c.field.WriteByte(nb)
+			case int(b) == c.quote:
+				if next, err := c.r.Peek(1); err == nil && int(next[0]) == c.quote && c.escape == c.quote {
+					_, _ = c.r.Discard(1)
+					c.offset++
+					c.field.WriteByte(b)
+					continue
+				}
+				inQuotes = false
+			default:
+				c.field.WriteByte(b)
+			}
+			continue
+		}
+		switch {
+		case int(b) == c.quote && c.field.Len() == 0 && !quoted:
+			inQuotes, quoted = true, true
+		case c.matchDelim(b, c.fieldDelim):
+			endField()
+		case c.matchDelim(b, c.recordDelim):
+			if len(fields) == 0 && c.field.Len() == 0 && !quoted {
+				return []string{}, true, nil
+			}
+			endField()
+			return fields, false, nil
+		default:
+			c.field.WriteByte(b)
+		}
+	}
+}
+
+func (c *csvReader) skipLine() error {
+	for {
+		b, err := c.r.ReadByte()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		c.offset++
+		if c.matchDelim(b, c.recordDelim) {
+			return nil
+		}
+	}
+}
+
+func (c *csvReader) next() (any, int64, error) {
+	fields, offset, err := c.readRecord()
+	if err != nil {
+		return nil, 0, err
+	}
+	obj := &object{vals: make([]any, len(fields)), positional: true}
+	obj.keys = make([]string, len(fields))
+	for i, f := range fields {
+		obj.vals[i] = f
+		if i < len(c.names) {
+			obj.keys[i] = c.names[i]
+		} else {
+			obj.keys[i] = "_" + strconv.Itoa(i+1)
+		}
+	}
+	return obj, offset, nil
+}
+
+// jsonLinesReader reads one JSON value per line.
+type jsonLinesReader struct {
+	r      *bufio.Reader
+	offset int64
+}
+
+func (j *jsonLinesReader) next() (any, int64, error) {
+	for {
+		start := j.offset
+		line, err := j.readLine()
+		if err != nil {
+			return nil, 0, err
+		}
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		v, err := decodeJSON(json.NewDecoder(bytes.NewReader(line)))
+		if err != nil {
+			return nil, 0, errorf(ErrJSONParsingError, "invalid JSON at byte %d: %v", start, err)
+		}
+		return v, start, nil
+	}
+}
+
+func (j *jsonLinesReader) readLine() ([]byte, error) {
+	var line []byte
+	for {
+		chunk, err := j.r.ReadSlice('\n')
+		j.offset += int64(len(chunk))
+		line = append(line, chunk...)
+		if len(line) > maxRecordSize {
+			return nil, errorf(ErrOverMaxRecordSize, "a record exceeds %d bytes", maxRecordSize)
+		}
+		switch {
+		case err == nil:
+			return line, nil
+		case errors.Is(err, bufio.ErrBufferFull):
+			continue
+		case err == io.EOF && len(line) > 0:
+			return line, nil
+		}
+		return nil, err
+	}
+}
+
+// jsonDocumentReader reads a stream of top-level JSON values.
+type jsonDocumentReader struct {
+	dec *json.Decoder
+}
+
+func (j *jsonDocumentReader) next() (any, int64, error) {
+	offset := j.dec.InputOffset()
+	v, err := decodeJSON(j.dec)
+	if err == io.EOF {
+		return nil, 0, io.EOF
+	}
+	if err != nil {
+		return nil, 0, errorf(ErrJSONParsingError, "invalid JSON near byte %d: %v", offset, err)
+	}
+	return v, offset, nil
+}
+
+// decodeJSON reads one JSON value, keeping object keys in order.
+func decodeJSON(dec *json.Decoder) (any, error) {
+	dec.UseNumber()
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	return decodeJSONToken(dec, tok, 0)
+}
+
+const maxJSONDepth = 256
+
+func decodeJSONToken(dec *json.Decoder, tok json.Token, depth int) (any, error) {
+	if depth > maxJSONDepth {
+		return nil, errors.New("nesting too deep")
+	}
+	switch t := tok.(type) {
+	case json.Delim:
+		switch t {
+		case '{':
+			obj := &object{}
+			for dec.More() {
+				kt, err := dec.Token()
+				if err != nil {
+					return nil, err
+				}
+				key, _ := kt.(string)
+				vt, err := dec.Token()
+				if err != nil {
+					return nil, err
+				}
+				v, err := decodeJSONToken(dec, vt, depth+1)
+				if err != nil {
+					return nil, err
+				}
+				obj.set(key, v)
+			}
+			if _, err := dec.Token(); err != nil {
+				return nil, err
+			}
+			return obj, nil
+		case '[':
+			arr := []any{}
+			for dec.More() {
+				vt, err := dec.Token()
+				if err != nil {
+					return nil, err
+				}
+				v, err := decodeJSONToken(dec, vt, depth+1)
+				if err != nil {
+					return nil, err
+				}
+				arr = append(arr, v)
+			}
+			if _, err := dec.Token(); err != nil {
+				return nil, err
+			}
+			return arr, nil
+		}
+		return nil, fmt.Errorf("unexpected %v", t)
+	case json.Number:
+		if n, err := t.Int64(); err == nil {
+			return n, nil
+		}
+		f, err := t.Float64()
+		if err != nil {
+			return nil, err
+		}
+		return f, nil
+	}
+	return tok, nil
+}
+
+// parquetReader yields the rows of a Parquet file, reading only the
+// columns the query references.
+type parquetReader struct {
+	f       *parquet.File
+	columns []int
+	group   int
+	rows    int
+	row     int
+	values  [][]any
+}
+
+func newParquetReader(f *parquet.File, st *statement) (*parquetReader, error) {
+	needed := map[string]bool{}
+	all := st.star
+	visit := func(e expr) {
+		pe, ok := e.(*pathExpr)
+		if !ok {
+			return
+		}
+		if len(pe.steps) == 0 || pe.steps[0].isIndex {
+			all = true
+			return
+		}
+		needed[strings.ToLower(pe.steps[0].name)] = true
+	}
+	for _, it := range st.items {
+		walk(it.e, visit)
+	}
+	walk(st.where, visit)
+	if len(st.from) > 0 && !(len(st.from) == 1 && st.from[0].wildcard) {
+		all = true
+	}
+
+	pr := &parquetReader{f: f}
+	for i, c := range f.Columns() {
+		if all || needed[strings.ToLower(c.Path[0])] {
+			pr.columns = append(pr.columns, i)
+		}
+	}
+	return pr, nil
+}
+
+func (p *parquetReader) next() (any, int64, error) {
+	for p.row >= p.rows {
+		if p.group >= p.f.NumRowGroups() {
+			return nil, 0, io.EOF
+		}
+		vals, err := p.f.ReadRowGroup(p.group, p.columns)
+		if err != nil {
+			return nil, 0, errorf(ErrParquetParsingError, "%v", err)
+		}
+		p.values = vals
+		p.rows = int(p.f.RowGroupNumRows(p.group))
+		p.row = 0
+		p.group++
+	}
+	cols := p.f.Columns()
+	obj := &object{}
+	for k, ci := range p.columns {
+		path := cols[ci].Path
+		parent := obj
+		for _, name := range path[:len(path)-1] {
+			child, ok := parent.get(name, true)
+			o, isObj := child.(*object)
+			if !ok || !isObj {
+				o = &object{}
+				parent.set(name, o)
+			}
+			parent = o
+		}
+		parent.set(path[len(path)-1], p.values[k][p.row])
+	}
+	p.row++
+	return obj, -1, nil
+}
@@ -0,0 +1,133 @@
+package s3select
+
+import (
+	"strings"
+	"unicode"
+)
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokQuotedIdent
+	tokNumber
+	tokString
+	tokOp
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+// is reports whether t is the given keyword or operator. Keywords match
+// case-insensitively; quoted identifiers never match.
+func (t token) is(s string) bool {
+	switch t.kind {
+	case tokIdent:
+		return strings.EqualFold(t.text, s)
+	case tokOp:
+		return t.text == s
+	}
+	return false
+}
+
+// lex splits a SQL expression into tokens.
+func lex(src string) ([]token, error) {
+	var toks []token
+	rs := []rune(src)
+	i := 0
+	for i < len(rs) {
+		c := rs[i]
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '\'':
+			start := i
+			var sb strings.Builder
+			i++
+			for {
+				if i >= len(rs) {
+					return nil, errorf(ErrParseUnexpectedToken, "unterminated string literal at position %d", start)
+				}
+				if rs[i] == '\'' {
+					if i+1 < len(rs) && rs[i+1] == '\'' {
+						sb.WriteRune('\'')
+						i += 2
+						continue
+					}
+					i++
+					break
+				}
+				sb.WriteRune(rs[i])
+				i++
+			}
+			toks = append(toks, token{kind: tokString, text: sb.String(), pos: start})
+		case c == '"':
+			start := i
+			var sb strings.Builder
+			i++
+			for {
+				if i >= len(rs) {
+					return nil, errorf(ErrParseUnexpectedToken, "unterminated quoted identifier at position %d", start)
+				}
+				if rs[i] == '"' {
+					if i+1 < len(rs) && rs[i+1] == '"' {
+						sb.WriteRune('"')
+						i += 2
+						continue
+					}
+					i++
+					break
+				}
+				sb.WriteRune(rs[i])
+				i++
+			}
+			toks = append(toks, token{kind: tokQuotedIdent, text: sb.String(), pos: start})
+		case unicode.IsDigit(c) || (c == '.' && i+1 < len(rs) && unicode.IsDigit(rs[i+1])):
+			start := i
+			for i < len(rs) && (unicode.IsDigit(rs[i]) || rs[i] == '.') {
+				i++
+			}
+			if i < len(rs) && (rs[i] == 'e' || rs[i] == 'E') {
+				j := i + 1
+				if j < len(rs) && (rs[j] == '+' || rs[j] == '-') {
+					j++
+				}
+				if j < len(rs) && unicode.IsDigit(rs[j]) {
+					i = j
+					for i < len(rs) && unicode.IsDigit(rs[i]) {
+						i++
+					}
+				}
+			}
+			toks = append(toks, token{kind: tokNumber, text: string(rs[start:i]), pos: start})
+		case c == '_' || unicode.IsLetter(c):
+			start := i
+			for i < len(rs) && (rs[i] == '_' || unicode.IsLetter(rs[i]) || unicode.IsDigit(rs[i])) {
+				i++
+			}
+			toks = append(toks, token{kind: tokIdent, text: string(rs[start:i]), pos: start})
+		default:
+			start := i
+			two := ""
+			if i+1 < len(rs) {
+				two = string(rs[i : i+2])
+			}
+			switch two {
+			case "<=", ">=", "<>", "!=", "||":
+				toks = append(toks, token{kind: tokOp, text: two, pos: start})
+				i += 2
+				continue
+			}
+			if !strings.ContainsRune("=<>+-*/%(),.[];", c) {
+				return nil, errorf(ErrParseUnexpectedToken, "unexpected character %q at position %d", c, start)
+			}
+			toks = append(toks, token{kind: tokOp, text: string(c), pos: start})
+			i++
+		}
+	}
+	return append(toks, token{kind: tokEOF, pos: len(rs)}), nil
+}
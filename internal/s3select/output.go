@@ -0,0 +1,131 @@
+package s3select
+
+import (
+	"bytes"
+	"strconv"
+)
+
+// recordWriter serializes result rows. names are the output column names
+// and vals their values; a *object row (SELECT *) is passed as vals[0]
+// with names nil.
+type recordWriter interface {
+	write(buf *bytes.Buffer, names []string, vals []any) error
+}
+
+type csvWriter struct {
+	fieldDelim  string
+	recordDelim string
+	quote       string
+	escape      string
+	always      bool
+}
+
+func newCSVWriter(out *CSVOutput) *csvWriter {
+	w := &csvWriter{fieldDelim: ",", recordDelim: "\n", quote: `"`, always: out.QuoteFields == "ALWAYS"}
+	if out.FieldDelimiter != "" {
+		w.fieldDelim = out.FieldDelimiter
+	}
+	if out.RecordDelimiter != "" {
+		w.recordDelim = out.RecordDelimiter
+	}
+	if out.QuoteCharacter != "" {
+		w.quote = out.QuoteCharacter
+	}
+	w.escape = w.quote
+	if out.QuoteEscapeCharacter != "" {
+		w.escape = out.QuoteEscapeCharacter
+	}
+	return w
+}
+
+func (w *csvWriter) write(buf *bytes.Buffer, names []string, vals []any) error {
+	if names == nil && len(vals) == 1 {
+		if obj, ok := vals[0].(*object); ok {
+			vals = obj.vals
+		}
+	}
+	for i, v := range vals {
+		if i > 0 {
+			buf.WriteString(w.fieldDelim)
+		}
+		s := toString(v)
+		if w.always || w.needsQuotes(s) {
+			buf.WriteString(w.quote)
+			for k := 0; k < len(s); {
+				if bytes.HasPrefix([]byte(s[k:]), []byte(w.quote)) {
+					buf.WriteString(w.escape)
+					buf.WriteString(w.quote)
+					k += len(w.quote)
+					continue
+				}
+				buf.WriteByte(s[k])
+				k++
+			}
+			buf.WriteString(w.quote)
+			continue
+		}
+		buf.WriteString(s)
+	}
+	buf.WriteString(w.recordDelim)
+	return nil
+}
+
+func (w *csvWriter) needsQuotes(s string) bool {
+	return s != "" && (bytes.Contains([]byte(s), []byte(w.fieldDelim)) ||
+		bytes.Contains([]byte(s), []byte(w.recordDelim)) ||
+		bytes.Contains([]byte(s), []byte(w.quote)) ||
+		bytes.ContainsAny([]byte(s), "\r\n"))
+}
+
+type jsonWriter struct {
+	recordDelim string
+}
+
+func newJSONWriter(out *JSONOutput) *jsonWriter {
+	w := &jsonWriter{recordDelim: "\n"}
+	if out.RecordDelimiter != "" {
+		w.recordDelim = out.RecordDelimiter
+	}
+	return w
+}
+
+func (w *jsonWriter) write(buf *bytes.Buffer, names []string, vals []any) error {
+	var b []byte
+	var err error
+	if names == nil && len(vals) == 1 {
+		if obj, ok := vals[0].(*object); ok {
+			b, err = obj.MarshalJSON()
+		} else {
+			// SELECT * over a scalar or array record.
+			b, err = (&object{keys: []string{"_1"}, vals: vals}).MarshalJSON()
+		}
+	} else {
+		b, err = (&object{keys: names, vals: vals}).MarshalJSON()
+	}
+	if err != nil {
+		return err
+	}
+	buf.Write(b)
+	buf.WriteString(w.recordDelim)
+	return nil
+}
+
+// outputNames derives the output column names of a projection: the alias,
+// else the last field name of a column path, else _N by position.
+func outputNames(items []selectItem) []string {
+	names := make([]string, len(items))
+	for i, it := range items {
+		switch {
+		case it.alias != "":
+			names[i] = it.alias
+		default:
+			names[i] = "_" + strconv.Itoa(i+1)
+			if pe, ok := it.e.(*pathExpr); ok && len(pe.steps) > 0 {
+				if last := pe.steps[len(pe.steps)-1]; !last.isIndex {
+					names[i] = last.name
+				}
+			}
+		}
+	}
+	return names
+}
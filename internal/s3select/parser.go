@@ -0,0 +1,794 @@
+package s3select
+
+import (
+	"strconv"
+	"strings"
+)
+
+type expr interface{}
+
+// pathStep is one step of a column or FROM path: a field name, an array
+// index, or the [*] wildcard (FROM only).
+type pathStep struct {
+	name     string
+	quoted   bool // quoted names match case-sensitively
+	index    int
+	isIndex  bool
+	wildcard bool
+}
+
+type (
+	literalExpr struct{ v any }
+	pathExpr    struct{ steps []pathStep }
+	unaryExpr   struct {
+		op string
+		x  expr
+	}
+	binaryExpr struct {
+		op   string
+		l, r expr
+	}
+	likeExpr struct {
+		x, pattern, escape expr
+		not                bool
+	}
+	betweenExpr struct {
+		x, lo, hi expr
+		not       bool
+	}
+	inExpr struct {
+		x    expr
+		list []expr
+		not  bool
+	}
+	isExpr struct {
+		x       expr
+		missing bool // IS MISSING rather than IS NULL
+		not     bool
+	}
+	funcExpr struct {
+		name string
+		args []expr
+	}
+	castExpr struct {
+		x   expr
+		typ string
+	}
+	whenClause struct{ cond, then expr }
+	caseExpr   struct {
+		operand expr
+		whens   []whenClause
+		els     expr
+	}
+	aggExpr struct {
+		fn   string // COUNT, SUM, AVG, MIN, MAX
+		arg  expr   // nil for COUNT(*)
+		slot int
+	}
+)
+
+type selectItem struct {
+	e     expr
+	alias string
+}
+
+// statement is a parsed SELECT.
+type statement struct {
+	star  bool
+	items []selectItem
+	from  []pathStep
+	alias string
+	where expr
+	limit int64 // -1 when absent
+	aggs  []*aggExpr
+}
+
+var reservedWords = map[string]bool{
+	"SELECT": true, "FROM": true, "WHERE": true, "LIMIT": true, "AS": true,
+	"AND": true, "OR": true, "NOT": true, "IS": true, "IN": true, "LIKE": true,
+	"BETWEEN": true, "ESCAPE": true, "CASE": true, "WHEN": true, "THEN": true,
+	"ELSE": true, "END": true, "NULL": true, "MISSING": true, "TRUE": true,
+	"FALSE": true, "CAST": true, "GROUP": true, "ORDER": true, "BY": true,
+	"HAVING": true, "JOIN": true, "UNION": true,
+}
+
+var scalarFunctions = map[string]int{ // name -> arity, -1 for variadic
+	"LOWER": 1, "UPPER": 1, "TRIM": 1, "CHAR_LENGTH": 1, "CHARACTER_LENGTH": 1,
+	"SUBSTRING": -1, "COALESCE": -1, "NULLIF": 2, "UTCNOW": 0, "ABS": 1,
+}
+
+var aggregateFunctions = map[string]bool{"COUNT": true, "SUM": true, "AVG": true, "MIN": true, "MAX": true}
+
+var castTypes = map[string]string{
+	"INT": "INT", "INTEGER": "INT", "BIGINT": "INT", "SMALLINT": "INT",
+	"FLOAT": "FLOAT", "REAL": "FLOAT", "DOUBLE": "FLOAT", "DECIMAL": "FLOAT", "NUMERIC": "FLOAT",
+	"STRING": "STRING", "VARCHAR": "STRING", "CHAR": "STRING",
+	"BOOL": "BOOL", "BOOLEAN": "BOOL",
+	"TIMESTAMP": "TIMESTAMP",
+}
+
+type parser struct {
+	toks []token
+	pos  int
+	aggs []*aggExpr
+	// inAggregate and noAggregates reject nested aggregates and aggregates
+	// in WHERE.
+	inAggregate  bool
+	noAggregates bool
+}
+
+// parse parses a SELECT statement.
+func parse(src string) (*statement, error) {
+	toks, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{toks: toks}
+	return p.statement()
+}
+
+func (p *parser) peek() token { return p.toks[p.pos] }
+
+func (p *parser) next() token {
+	t := p.toks[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) accept(s string) bool {
+	if p.peek().is(s) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(s string) error {
+	if !p.accept(s) {
+		return p.unexpected("expected " + s)
+	}
+	return nil
+}
+
+func (p *parser) unexpected(hint string) error {
+	t := p.peek()
+	if t.kind == tokEOF {
+		return errorf(ErrParseUnexpectedToken, "unexpected end of expression: %s", hint)
+	}
+	return errorf(ErrParseUnexpectedToken, "unexpected token %q at position %d: %s", t.text, t.pos, hint)
+}
+
+func (p *parser) statement() (*statement, error) {
+	st := &statement{limit: -1}
+	if err := p.expect("SELECT"); err != nil {
+		return nil, err
+	}
+	if p.accept("*") {
+		st.star = true
+	} else {
+		for {
+			e, err := p.expr()
+			if err != nil {
+				return nil, err
+			}
+			item := selectItem{e: e}
+			if p.accept("AS") {
+				t := p.next()
+				if t.kind != tokIdent && t.kind != tokQuotedIdent {
+					return nil, p.unexpected("expected an alias")
+				}
+				item.alias = t.text
+			} else if t := p.peek(); t.kind == tokQuotedIdent || (t.kind == tokIdent && !reservedWords[strings.ToUpper(t.text)]) {
+				item.alias = p.next().text
+			}
+			st.items = append(st.items, item)
+			if !p.accept(",") {
+				break
+			}
+		}
+	}
+	st.aggs = p.aggs
+
+	if err := p.expect("FROM"); err != nil {
+		return nil, err
+	}
+	if t := p.next(); !t.is("S3Object") {
+		return nil, errorf(ErrParseUnexpectedToken, "FROM must name S3Object")
+	}
+	for {
+		switch {
+		case p.accept("["):
+			if p.accept("*") {
+				st.from = append(st.from, pathStep{wildcard: true})
+			} else {
+				step, err := p.bracketStep()
+				if err != nil {
+					return nil, err
+				}
+				st.from = append(st.from, step)
+				continue
+			}
+			if err := p.expect("]"); err != nil {
+				return nil, err
+			}
+			continue
+		case p.accept("."):
+			t := p.next()
+			if t.kind != tokIdent && t.kind != tokQuotedIdent {
+				return nil, p.unexpected("expected a field name")
+			}
+			st.from = append(st.from, pathStep{name: t.text, quoted: t.kind == tokQuotedIdent})
+			continue
+		}
+		break
+	}
+	if p.accept("AS") {
+		t := p.next()
+		if t.kind != tokIdent && t.kind != tokQuotedIdent {
+			return nil, p.unexpected("expected a table alias")
+		}
+		st.alias = t.text
+	} else if t := p.peek(); t.kind == tokIdent && !reservedWords[strings.ToUpper(t.text)] {
+		st.alias = p.next().text
+	}
+
+	if p.accept("WHERE") {
+		p.noAggregates = true
+		w, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		p.noAggregates = false
+		st.where = w
+	}
+	if p.accept("LIMIT") {
+		t := p.next()
+		n, err := strconv.ParseInt(t.text, 10, 64)
+		if t.kind != tokNumber || err != nil || n < 0 {
+			return nil, errorf(ErrParseUnexpectedToken, "LIMIT must be a non-negative integer")
+		}
+		st.limit = n
+	}
+	p.accept(";")
+	if t := p.peek(); t.kind != tokEOF {
+		switch strings.ToUpper(t.text) {
+		case "GROUP", "ORDER", "HAVING", "JOIN", "UNION":
+			return nil, errorf(ErrUnsupportedSQLOperation, "%s is not supported", strings.ToUpper(t.text))
+		}
+		return nil, p.unexpected("expected end of expression")
+	}
+
+	if len(st.aggs) > 0 {
+		for _, it := range st.items {
+			if !containsAggregate(it.e) && !isConstant(it.e) {
+				return nil, errorf(ErrUnsupportedSQLOperation,
+					"aggregate and non-aggregate expressions cannot be mixed without GROUP BY")
+			}
+		}
+	}
+	st.stripAlias()
+	return st, nil
+}
+
+// bracketStep parses the inside of [n] or ['name'] and the closing bracket.
+func (p *parser) bracketStep() (pathStep, error) {
+	t := p.next()
+	var step pathStep
+	switch t.kind {
+	case tokNumber:
+		n, err := strconv.Atoi(t.text)
+		if err != nil || n < 0 {
+			return step, errorf(ErrParseUnexpectedToken, "invalid array index %q", t.text)
+		}
+		step = pathStep{index: n, isIndex: true}
+	case tokString:
+		step = pathStep{name: t.text, quoted: true}
+	default:
+		return step, p.unexpected("expected an index or a quoted field name")
+	}
+	return step, p.expect("]")
+}
+
+func (p *parser) expr() (expr, error) { return p.or() }
+
+func (p *parser) or() (expr, error) {
+	l, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("OR") {
+		r, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		l = &binaryExpr{op: "OR", l: l, r: r}
+	}
+	return l, nil
+}
+
+func (p *parser) and() (expr, error) {
+	l, err := p.not()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("AND") {
+		r, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		l = &binaryExpr{op: "AND", l: l, r: r}
+	}
+	return l, nil
+}
+
+func (p *parser) not() (expr, error) {
+	if p.accept("NOT") {
+		x, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		return &unaryExpr{op: "NOT", x: x}, nil
+	}
+	return p.comparison()
+}
+
+func (p *parser) comparison() (expr, error) {
+	l, err := p.concat()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.peek()
+		switch {
+		case t.kind == tokOp && (t.text == "=" || t.text == "!=" || t.text == "<>" ||
+			t.text == "<" || t.text == "<=" || t.text == ">" || t.text == ">="):
+			p.next()
+			r, err := p.concat()
+			if err != nil {
+				return nil, err
+			}
+			op := t.text
+			if op == "<>" {
+				op = "!="
+			}
+			l = &binaryExpr{op: op, l: l, r: r}
+		case t.is("IS"):
+			p.next()
+			not := p.accept("NOT")
+			switch {
+			case p.accept("NULL"):
+				l = &isExpr{x: l, not: not}
+			case p.accept("MISSING"):
+				l = &isExpr{x: l, missing: true, not: not}
+			default:
+				return nil, p.unexpected("expected NULL or MISSING")
+			}
+		case t.is("NOT") || t.is("LIKE") || t.is("BETWEEN") || t.is("IN"):
+			p.next()
+			not := t.is("NOT")
+			if not {
+				t = p.next()
+			}
+			switch {
+			case t.is("LIKE"):
+				pat, err := p.concat()
+				if err != nil {
+					return nil, err
+				}
+				le := &likeExpr{x: l, pattern: pat, not: not}
+				if p.accept("ESCAPE") {
+					if le.escape, err = p.concat(); err != nil {
+						return nil, err
+					}
+				}
+				l = le
+			case t.is("BETWEEN"):
+				lo, err := p.concat()
+				if err != nil {
+					return nil, err
+				}
+				if err := p.expect("AND"); err != nil {
+					return nil, err
+				}
+				hi, err := p.concat()
+				if err != nil {
+					return nil, err
+				}
+				l = &betweenExpr{x: l, lo: lo, hi: hi, not: not}
+			case t.is("IN"):
+				if err := p.expect("("); err != nil {
+					return nil, err
+				}
+				ie := &inExpr{x: l, not: not}
+				for {
+					e, err := p.expr()
+					if err != nil {
+						return nil, err
+					}
+					ie.list = append(ie.list, e)
+					if !p.accept(",") {
+						break
+					}
+				}
+				if err := p.expect(")"); err != nil {
+					return nil, err
+				}
+				l = ie
+			default:
+				return nil, p.unexpected("expected LIKE, BETWEEN or IN")
+			}
+		default:
+			return l, nil
+		}
+	}
+}
+
+func (p *parser) concat() (expr, error) {
+	l, err := p.additive()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("||") {
+		r, err := p.additive()
+		if err != nil {
+			return nil, err
+		}
+		l = &binaryExpr{op: "||", l: l, r: r}
+	}
+	return l, nil
+}
+
+func (p *parser) additive() (expr, error) {
+	l, err := p.multiplicative()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.peek()
+		if !t.is("+") && !t.is("-") {
+			return l, nil
+		}
+		p.next()
+		r, err := p.multiplicative()
+		if err != nil {
+			return nil, err
+		}
+		l = &binaryExpr{op: t.text, l: l, r: r}
+	}
+}
+
+func (p *parser) multiplicative() (expr, error) {
+	l, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.peek()
+		if !t.is("*") && !t.is("/") && !t.is("%") {
+			return l, nil
+		}
+		p.next()
+		r, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		l = &binaryExpr{op: t.text, l: l, r: r}
+	}
+}
+
+func (p *parser) unary() (expr, error) {
+	if p.accept("-") {
+		x, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		if lit, ok := x.(*literalExpr); ok {
+			switch v := lit.v.(type) {
+			case int64:
+				return &literalExpr{v: -v}, nil
+			case float64:
+				return &literalExpr{v: -v}, nil
+			}
+		}
+		return &unaryExpr{op: "-", x: x}, nil
+	}
+	if p.accept("+") {
+		return p.unary()
+	}
+	return p.primary()
+}
+
+func (p *parser) primary() (expr, error) {
+	t := p.next()
+	switch t.kind {
+	case tokNumber:
+		if !strings.ContainsAny(t.text, ".eE") {
+			if n, err := strconv.ParseInt(t.text, 10, 64); err == nil {
+				return &literalExpr{v: n}, nil
+			}
+		}
+		f, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, errorf(ErrParseUnexpectedToken, "invalid number %q", t.text)
+		}
+		return &literalExpr{v: f}, nil
+	case tokString:
+		return &literalExpr{v: t.text}, nil
+	case tokQuotedIdent:
+		return p.path(pathStep{name: t.text, quoted: true})
+	case tokOp:
+		if t.text == "(" {
+			e, err := p.expr()
+			if err != nil {
+				return nil, err
+			}
+			return e, p.expect(")")
+		}
+	case tokIdent:
+		upper := strings.ToUpper(t.text)
+		switch upper {
+		case "NULL":
+			return &literalExpr{v: nil}, nil
+		case "MISSING":
+			return &literalExpr{v: missing{}}, nil
+		case "TRUE":
+			return &literalExpr{v: true}, nil
+		case "FALSE":
+			return &literalExpr{v: false}, nil
+		case "CAST":
+			return p.cast()
+		case "CASE":
+			return p.caseExpr()
+		}
+		if p.peek().is("(") {
+			return p.call(upper)
+		}
+		if reservedWords[upper] {
+			p.pos--
+			return nil, p.unexpected("expected an expression")
+		}
+		return p.path(pathStep{name: t.text})
+	}
+	p.pos--
+	return nil, p.unexpected("expected an expression")
+}
+
+func (p *parser) path(first pathStep) (expr, error) {
+	steps := []pathStep{first}
+	for {
+		switch {
+		case p.accept("."):
+			t := p.next()
+			if t.kind != tokIdent && t.kind != tokQuotedIdent {
+				return nil, p.unexpected("expected a field name")
+			}
+			steps = append(steps, pathStep{name: t.text, quoted: t.kind == tokQuotedIdent})
+		case p.accept("["):
+			step, err := p.bracketStep()
+			if err != nil {
+				return nil, err
+			}
+			steps = append(steps, step)
+		default:
+			return &pathExpr{steps: steps}, nil
+		}
+	}
+}
+
+func (p *parser) cast() (expr, error) {
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	x, err := p.expr()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect("AS"); err != nil {
+		return nil, err
+	}
+	t := p.next()
+	typ, ok := castTypes[strings.ToUpper(t.text)]
+	if t.kind != tokIdent || !ok {
+		return nil, errorf(ErrParseUnexpectedToken, "unsupported CAST type %q", t.text)
+	}
+	if strings.EqualFold(t.text, "DOUBLE") {
+		p.accept("PRECISION")
+	}
+	// Ignore precision and length arguments such as DECIMAL(10,2).
+	if p.accept("(") {
+		for !p.accept(")") {
+			if p.next().kind == tokEOF {
+				return nil, p.unexpected("expected )")
+			}
+		}
+	}
+	return &castExpr{x: x, typ: typ}, p.expect(")")
+}
+
+func (p *parser) caseExpr() (expr, error) {
+	ce := &caseExpr{}
+	if !p.peek().is("WHEN") {
+		operand, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		ce.operand = operand
+	}
+	for p.accept("WHEN") {
+		cond, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect("THEN"); err != nil {
+			return nil, err
+		}
+		then, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		ce.whens = append(ce.whens, whenClause{cond: cond, then: then})
+	}
+	if len(ce.whens) == 0 {
+		return nil, p.unexpected("expected WHEN")
+	}
+	if p.accept("ELSE") {
+		els, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		ce.els = els
+	}
+	return ce, p.expect("END")
+}
+
+func (p *parser) call(name string) (expr, error) {
+	p.next() // (
+	if aggregateFunctions[name] {
+		if p.noAggregates {
+			return nil, errorf(ErrUnsupportedSQLOperation, "aggregate %s is not allowed in WHERE", name)
+		}
+		if p.inAggregate {
+			return nil, errorf(ErrUnsupportedSQLOperation, "aggregates cannot be nested")
+		}
+		agg := &aggExpr{fn: name, slot: len(p.aggs)}
+		if name == "COUNT" && p.accept("*") {
+			p.aggs = append(p.aggs, agg)
+			return agg, p.expect(")")
+		}
+		p.inAggregate = true
+		arg, err := p.expr()
+		p.inAggregate = false
+		if err != nil {
+			return nil, err
+		}
+		agg.arg = arg
+		p.aggs = append(p.aggs, agg)
+		return agg, p.expect(")")
+	}
+
+	arity, ok := scalarFunctions[name]
+	if !ok {
+		return nil, errorf(ErrUnsupportedFunction, "function %s is not supported", name)
+	}
+	fe := &funcExpr{name: name}
+	if !p.accept(")") {
+		for {
+			a, err := p.expr()
+			if err != nil {
+				return nil, err
+			}
+			fe.args = append(fe.args, a)
+			// SUBSTRING(s FROM n [FOR m]) is the standard spelling.
+			if name == "SUBSTRING" && (p.accept("FROM") || p.accept("FOR")) {
+				continue
+			}
+			if !p.accept(",") {
+				break
+			}
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+	}
+	switch {
+	case arity >= 0 && len(fe.args) != arity,
+		name == "SUBSTRING" && (len(fe.args) < 2 || len(fe.args) > 3),
+		name == "COALESCE" && len(fe.args) == 0:
+		return nil, errorf(ErrIncorrectSQLFunctionArgument, "wrong number of arguments to %s", name)
+	}
+	return fe, nil
+}
+
+// stripAlias removes the table alias (or S3Object) prefix from column
+// paths, leaving them relative to the record.
+func (st *statement) stripAlias() {
+	walk(st.where, st.stripPath)
+	for _, it := range st.items {
+		walk(it.e, st.stripPath)
+	}
+}
+
+func (st *statement) stripPath(e expr) {
+	pe, ok := e.(*pathExpr)
+	if !ok {
+		return
+	}
+	first := pe.steps[0]
+	if first.isIndex {
+		return
+	}
+	alias := st.alias
+	if alias == "" {
+		alias = "S3Object"
+	}
+	if strings.EqualFold(first.name, alias) && (len(pe.steps) > 1 || st.alias != "") {
+		pe.steps = pe.steps[1:]
+	}
+}
+
+// walk calls fn for e and every sub-expression.
+func walk(e expr, fn func(expr)) {
+	if e == nil {
+		return
+	}
+	fn(e)
+	switch x := e.(type) {
+	case *unaryExpr:
+		walk(x.x, fn)
+	case *binaryExpr:
+		walk(x.l, fn)
+		walk(x.r, fn)
+	case *likeExpr:
+		walk(x.x, fn)
+		walk(x.pattern, fn)
+		walk(x.escape, fn)
+	case *betweenExpr:
+		walk(x.x, fn)
+		walk(x.lo, fn)
+		walk(x.hi, fn)
+	case *inExpr:
+		walk(x.x, fn)
+		for _, a := range x.list {
+			walk(a, fn)
+		}
+	case *isExpr:
+		walk(x.x, fn)
+	case *funcExpr:
+		for _, a := range x.args {
+			walk(a, fn)
+		}
+	case *castExpr:
+		walk(x.x, fn)
+	case *caseExpr:
+		walk(x.operand, fn)
+		for _, w := range x.whens {
+			walk(w.cond, fn)
+			walk(w.then, fn)
+		}
+		walk(x.els, fn)
+	case *aggExpr:
+		walk(x.arg, fn)
+	}
+}
+
+func containsAggregate(e expr) bool {
+	found := false
+	walk(e, func(x expr) {
+		if _, ok := x.(*aggExpr); ok {
+			found = true
+		}
+	})
+	return found
+}
+
+func isConstant(e expr) bool {
+	constant := true
+	walk(e, func(x expr) {
+		if _, ok := x.(*pathExpr); ok {
+			constant = false
+		}
+	})
+	return constant
+}
@@ -0,0 +1,180 @@
+package s3select
+
+import (
+	"encoding/xml"
+	"io"
+	"strings"
+)
+
+// maxExpressionLength is the longest SQL expression S3 accepts.
+const maxExpressionLength = 256 * 1024
+
+// Request is the body of a SelectObjectContent request.
+type Request struct {
+	XMLName             xml.Name            `xml:"SelectObjectContentRequest"`
+	Expression          string              `xml:"Expression"`
+	ExpressionType      string              `xml:"ExpressionType"`
+	RequestProgress     RequestProgress     `xml:"RequestProgress"`
+	InputSerialization  InputSerialization  `xml:"InputSerialization"`
+	OutputSerialization OutputSerialization `xml:"OutputSerialization"`
+	ScanRange           *ScanRange          `xml:"ScanRange"`
+}
+
+// RequestProgress enables Progress events.
+type RequestProgress struct {
+	Enabled bool `xml:"Enabled"`
+}
+
+// InputSerialization describes the object's format.
+type InputSerialization struct {
+	CompressionType string        `xml:"CompressionType"`
+	CSV             *CSVInput     `xml:"CSV"`
+	JSON            *JSONInput    `xml:"JSON"`
+	Parquet         *ParquetInput `xml:"Parquet"`
+}
+
+// CSVInput configures CSV parsing.
+type CSVInput struct {
+	FileHeaderInfo             string `xml:"FileHeaderInfo"`
+	Comments                   string `xml:"Comments"`
+	QuoteEscapeCharacter       string `xml:"QuoteEscapeCharacter"`
+	RecordDelimiter            string `xml:"RecordDelimiter"`
+	FieldDelimiter             string `xml:"FieldDelimiter"`
+	QuoteCharacter             string `xml:"QuoteCharacter"`
+	AllowQuotedRecordDelimiter bool   `xml:"AllowQuotedRecordDelimiter"`
+}
+
+// JSONInput configures JSON parsing.
+type JSONInput struct {
+	Type string `xml:"Type"`
+}
+
+// ParquetInput selects Parquet input. It has no options.
+type ParquetInput struct{}
+
+// OutputSerialization describes the result format.
+type OutputSerialization struct {
+	CSV  *CSVOutput  `xml:"CSV"`
+	JSON *JSONOutput `xml:"JSON"`
+}
+
+// CSVOutput configures CSV results.
+type CSVOutput struct {
+	QuoteFields          string `xml:"QuoteFields"`
+	QuoteEscapeCharacter string `xml:"QuoteEscapeCharacter"`
+	RecordDelimiter      string `xml:"RecordDelimiter"`
+	FieldDelimiter       string `xml:"FieldDelimiter"`
+	QuoteCharacter       string `xml:"QuoteCharacter"`
+}
+
+// JSONOutput configures JSON results.
+type JSONOutput struct {
+	RecordDelimiter string `xml:"RecordDelimiter"`
+}
+
+// ScanRange limits the scan to records that start within [Start, End].
+// Either bound may be omitted.
+type ScanRange struct {
+	Start *int64 `xml:"Start"`
+	End   *int64 `xml:"End"`
+}
+
+// Compression types.
+const (
+	CompressionNone  = "NONE"
+	CompressionGzip  = "GZIP"
+	CompressionBzip2 = "BZIP2"
+)
+
+// ParseRequest decodes and validates a request body.
+func ParseRequest(body io.Reader) (*Request, error) {
+	var req Request
+	if err := xml.NewDecoder(body).Decode(&req); err != nil {
+		return nil, errorf(ErrMalformedXML, "the request body is not valid XML")
+	}
+	if err := req.validate(); err != nil {
+		return nil, err
+	}
+	return &req, nil
+}
+
+func (r *Request) validate() error {
+	if strings.TrimSpace(r.Expression) == "" {
+		return errorf(ErrMissingRequiredParameter, "Expression is required")
+	}
+	if len(r.Expression) > maxExpressionLength {
+		return errorf(ErrExpressionTooLong, "the SQL expression is longer than %d bytes", maxExpressionLength)
+	}
+	if !strings.EqualFold(r.ExpressionType, "SQL") {
+		return errorf(ErrInvalidExpressionType, "ExpressionType must be SQL")
+	}
+
+	in := &r.InputSerialization
+	formats := 0
+	for _, set := range []bool{in.CSV != nil, in.JSON != nil, in.Parquet != nil} {
+		if set {
+			formats++
+		}
+	}
+	if formats != 1 {
+		return errorf(ErrObjectSerializationConflict, "InputSerialization must specify exactly one of CSV, JSON or Parquet")
+	}
+	in.CompressionType = strings.ToUpper(in.CompressionType)
+	switch in.CompressionType {
+	case "":
+		in.CompressionType = CompressionNone
+	case CompressionNone, CompressionGzip, CompressionBzip2:
+	default:
+		return errorf(ErrInvalidCompressionFormat, "unsupported CompressionType %q", in.CompressionType)
+	}
+	if in.Parquet != nil && in.CompressionType != CompressionNone {
+		return errorf(ErrInvalidCompressionFormat, "Parquet input does not take a CompressionType")
+	}
+	if in.CSV != nil {
+		in.CSV.FileHeaderInfo = strings.ToUpper(in.CSV.FileHeaderInfo)
+		switch in.CSV.FileHeaderInfo {
+		case "":
+			in.CSV.FileHeaderInfo = "NONE"
+		case "NONE", "USE", "IGNORE":
+		default:
+			return errorf(ErrInvalidFileHeaderInfo, "FileHeaderInfo must be NONE, USE or IGNORE")
+		}
+	}
+	if in.JSON != nil {
+		in.JSON.Type = strings.ToUpper(in.JSON.Type)
+		switch in.JSON.Type {
+		case "DOCUMENT", "LINES":
+		default:
+			return errorf(ErrInvalidJSONType, "JSON Type must be DOCUMENT or LINES")
+		}
+	}
+
+	out := &r.OutputSerialization
+	if (out.CSV == nil) == (out.JSON == nil) {
+		return errorf(ErrObjectSerializationConflict, "OutputSerialization must specify exactly one of CSV or JSON")
+	}
+	if out.CSV != nil {
+		out.CSV.QuoteFields = strings.ToUpper(out.CSV.QuoteFields)
+		switch out.CSV.QuoteFields {
+		case "":
+			out.CSV.QuoteFields = "ASNEEDED"
+		case "ASNEEDED", "ALWAYS":
+		default:
+			return errorf(ErrInvalidQuoteFields, "QuoteFields must be ASNEEDED or ALWAYS")
+		}
+	}
+
+	if sr := r.ScanRange; sr != nil {
+		if in.Parquet != nil || in.CompressionType != CompressionNone ||
+			(in.JSON != nil && in.JSON.Type != "LINES") ||
+			(in.CSV != nil && in.CSV.AllowQuotedRecordDelimiter) {
+			return errorf(ErrInvalidRequestParameter,
+				"ScanRange is only supported for uncompressed CSV and JSON LINES input")
+		}
+		if (sr.Start != nil && *sr.Start < 0) || (sr.End != nil && *sr.End < 0) ||
+			(sr.Start != nil && sr.End != nil && *sr.End < *sr.Start) {
+			return errorf(ErrInvalidRequestParameter, "ScanRange is invalid")
+		}
+	}
+	return nil
+}
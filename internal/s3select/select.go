@@ -0,0 +1,492 @@
+// Package s3select implements S3 SelectObjectContent: a SQL subset
+// evaluated over CSV, JSON and Parquet objects, with results streamed in
+// the AWS event stream framing.
+package s3select
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/FairForge/vaultaire/internal/parquet"
+)
+
+const (
+	// recordsBatchSize is the payload size at which buffered result rows
+	// are sent as a Records event.
+	recordsBatchSize = 256 << 10
+	// keepAliveInterval is how long a scan may run without sending an
+	// event before a Cont message keeps the connection open.
+	keepAliveInterval = 2 * time.Second
+	// checkEvery is how many records are scanned between context and
+	// keep-alive checks.
+	checkEvery = 1024
+)
+
+// Source is the object being queried.
+type Source struct {
+	Size int64
+	// Open returns the object's bytes from offset; length -1 reads to the
+	// end.
+	Open func(ctx context.Context, offset, length int64) (io.ReadCloser, error)
+}
+
+// Query is a validated request with its parsed SQL.
+type Query struct {
+	req  *Request
+	stmt *statement
+}
+
+// Compile parses the request's SQL expression.
+func Compile(req *Request) (*Query, error) {
+	st, err := parse(req.Expression)
+	if err != nil {
+		return nil, err
+	}
+	if req.InputSerialization.CSV != nil {
+		for _, s := range st.from {
+			if !s.wildcard {
+				return nil, errorf(ErrUnsupportedSQLOperation, "CSV input supports only FROM S3Object or S3Object[*]")
+			}
+		}
+	}
+	return &Query{req: req, stmt: st}, nil
+}
+
+// Stats are the byte counters reported in Stats and Progress events.
+type Stats struct {
+	BytesScanned   int64
+	BytesProcessed int64
+	BytesReturned  int64
+}
+
+type countingReader struct {
+	r io.Reader
+	n *int64
+}
+
+func (c countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	*c.n += int64(n)
+	return n, err
+}
+
+// sourceReaderAt serves ReadAt from ranged opens of the source, so a
+// Parquet scan fetches only the footer and the column chunks it needs.
+type sourceReaderAt struct {
+	ctx     context.Context
+	src     Source
+	scanned *int64
+	// openErr is the first failure to open the source, told apart from
+	// Parquet format errors.
+	openErr error
+}
+
+func (s *sourceReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	rc, err := s.src.Open(s.ctx, off, int64(len(p)))
+	if err != nil {
+		if s.openErr == nil {
+			s.openErr = err
+		}
+		return 0, err
+	}
+	defer func() { _ = rc.Close() }()
+	n, err := io.ReadFull(io.LimitReader(rc, int64(len(p))), p)
+	*s.scanned += int64(n)
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		err = io.EOF
+	}
+	return n, err
+}
+
+// eventWriter sends event stream messages, flushing after each.
+type eventWriter struct {
+	w     io.Writer
+	flush func()
+	last  time.Time
+	sent  bool
+}
+
+func (e *eventWriter) send(msg []byte) error {
+	if _, err := e.w.Write(msg); err != nil {
+		return err
+	}
+	if e.flush != nil {
+		e.flush()
+	}
+	e.last = time.Now()
+	e.sent = true
+	return nil
+}
+
+// Run executes the query against src and streams the result to w as
+// event stream messages, calling flush (when non-nil) after each one.
+// Query failures are sent to the client as an error message and also
+// returned. A source failure before anything was written (such as the
+// object failing to open) is returned without writing, so the caller can
+// still answer with an HTTP error.
+func (q *Query) Run(ctx context.Context, src Source, w io.Writer, flush func()) (Stats, error) {
+	r := &run{q: q, ctx: ctx, ev: &eventWriter{w: w, flush: flush, last: time.Now()},
+		eval: &evaluator{now: time.Now().UTC()}}
+	err := r.execute(src)
+	if err != nil {
+		var se *Error
+		if !errors.As(err, &se) {
+			if ctx.Err() != nil || !r.ev.sent {
+				return r.stats, err
+			}
+			se = errorf(ErrInternalError, "%v", err)
+		}
+		// Best effort: the client may already be gone.
+		_ = r.ev.send(errorMessage(se.Code, se.Message))
+		return r.stats, se
+	}
+	return r.stats, nil
+}
+
+type run struct {
+	q     *Query
+	ctx   context.Context
+	ev    *eventWriter
+	eval  *evaluator
+	stats Stats
+	out   recordWriter
+	buf   bytes.Buffer
+}
+
+func (r *run) execute(src Source) error {
+	req := r.q.req
+	st := r.q.stmt
+	if req.OutputSerialization.CSV != nil {
+		r.out = newCSVWriter(req.OutputSerialization.CSV)
+	} else {
+		r.out = newJSONWriter(req.OutputSerialization.JSON)
+	}
+
+	reader, end, closeFn, err := r.openReader(src)
+	if err != nil {
+		return err
+	}
+	defer closeFn()
+
+	var names []string
+	if !st.star {
+		names = outputNames(st.items)
+	}
+	var aggs []aggState
+	if len(st.aggs) > 0 {
+		aggs = make([]aggState, len(st.aggs))
+	}
+	var emitted int64
+	scanned := 0
+	for st.limit < 0 || emitted < st.limit || aggs != nil {
+		if scanned++; scanned%checkEvery == 0 {
+			if err := r.ctx.Err(); err != nil {
+				return err
+			}
+			if time.Since(r.ev.last) >= keepAliveInterval {
+				if err := r.ev.send(eventMessage("Cont", "", nil)); err != nil {
+					return err
+				}
+			}
+		}
+		rec, offset, err := reader.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if end >= 0 && offset > end {
+			break
+		}
+		for _, row := range expandFrom(rec, st.from) {
+			if st.where != nil {
+				v, err := r.eval.eval(st.where, row)
+				if err != nil {
+					return err
+				}
+				ok, known, err := truth(v)
+				if err != nil {
+					return err
+				}
+				if !known || !ok {
+					continue
+				}
+			}
+			if aggs != nil {
+				for i, a := range st.aggs {
+					var v any = true // COUNT(*) counts every row
+					if a.arg != nil {
+						if v, err = r.eval.eval(a.arg, row); err != nil {
+							return err
+						}
+					}
+					if err := aggs[i].add(a.fn, v); err != nil {
+						return err
+					}
+				}
+				continue
+			}
+			if st.limit >= 0 && emitted >= st.limit {
+				break
+			}
+			if err := r.project(row, names); err != nil {
+				return err
+			}
+			emitted++
+		}
+	}
+
+	if aggs != nil && st.limit != 0 {
+		r.eval.aggResults = make([]any, len(aggs))
+		for i, a := range st.aggs {
+			r.eval.aggResults[i] = aggs[i].result(a.fn)
+		}
+		if err := r.project(nil, names); err != nil {
+			return err
+		}
+	}
+	if err := r.flushRecords(); err != nil {
+		return err
+	}
+	if err := r.ev.send(eventMessage("Stats", "text/xml",
+		statsXML("Stats", r.stats.BytesScanned, r.stats.BytesProcessed, r.stats.BytesReturned))); err != nil {
+		return err
+	}
+	return r.ev.send(eventMessage("End", "", nil))
+}
+
+func (r *run) project(row any, names []string) error {
+	var vals []any
+	if r.q.stmt.star {
+		vals = []any{row}
+	} else {
+		vals = make([]any, len(r.q.stmt.items))
+		for i, it := range r.q.stmt.items {
+			v, err := r.eval.eval(it.e, row)
+			if err != nil {
+				return err
+			}
+			vals[i] = v
+		}
+	}
+	if err := r.out.write(&r.buf, names, vals); err != nil {
+		return err
+	}
+	if r.buf.Len() >= recordsBatchSize {
+		return r.flushRecords()
+	}
+	return nil
+}
+
+func (r *run) flushRecords() error {
+	if r.buf.Len() == 0 {
+		return nil
+	}
+	r.stats.BytesReturned += int64(r.buf.Len())
+	if err := r.ev.send(eventMessage("Records", "application/octet-stream", r.buf.Bytes())); err != nil {
+		return err
+	}
+	r.buf.Reset()
+	if r.q.req.RequestProgress.Enabled {
+		return r.ev.send(eventMessage("Progress", "text/xml",
+			statsXML("Progress", r.stats.BytesScanned, r.stats.BytesProcessed, r.stats.BytesReturned)))
+	}
+	return nil
+}
+
+// openReader opens the input and returns its record reader. end is the
+// last offset at which a record may start (ScanRange), or -1.
+func (r *run) openReader(src Source) (recordReader, int64, func(), error) {
+	in := &r.q.req.InputSerialization
+	if in.Parquet != nil {
+		ra := &sourceReaderAt{ctx: r.ctx, src: src, scanned: &r.stats.BytesScanned}
+		f, err := parquet.Open(ra, src.Size)
+		r.stats.BytesProcessed = r.stats.BytesScanned
+		if err != nil {
+			if ra.openErr != nil {
+				return nil, 0, nil, ra.openErr
+			}
+			return nil, 0, nil, errorf(ErrParquetParsingError, "%v", err)
+		}
+		pr, err := newParquetReader(f, r.q.stmt)
+		if err != nil {
+			return nil, 0, nil, err
+		}
+		return &countingParquet{pr: pr, stats: &r.stats}, -1, func() {}, nil
+	}
+
+	start, end := int64(0), int64(-1)
+	if sr := r.q.req.ScanRange; sr != nil {
+		switch {
+		case sr.Start != nil:
+			start = *sr.Start
+			if sr.End != nil {
+				end = *sr.End
+			}
+		case sr.End != nil:
+			// Only End: scan the last End bytes.
+			start = max(src.Size-*sr.End, 0)
+		}
+	}
+	// Reading from the byte before start tells whether a record begins
+	// exactly at start.
+	readFrom := max(start-1, 0)
+	if start >= src.Size && src.Size > 0 {
+		return emptyReader{}, -1, func() {}, nil
+	}
+	rc, err := src.Open(r.ctx, readFrom, -1)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	var stream io.Reader = countingReader{r: rc, n: &r.stats.BytesScanned}
+	switch in.CompressionType {
+	case CompressionGzip:
+		zr, err := gzip.NewReader(stream)
+		if err != nil {
+			_ = rc.Close()
+			return nil, 0, nil, errorf(ErrInvalidCompressionFormat, "the object is not valid GZIP: %v", err)
+		}
+		stream = zr
+	case CompressionBzip2:
+		stream = bzip2.NewReader(stream)
+	}
+	br := bufio.NewReaderSize(countingReader{r: stream, n: &r.stats.BytesProcessed}, 64<<10)
+	closeFn := func() { _ = rc.Close() }
+
+	offset := readFrom
+	if start > 0 {
+		delim := []byte("\n")
+		if in.CSV != nil && in.CSV.RecordDelimiter != "" {
+			delim = []byte(in.CSV.RecordDelimiter)
+		}
+		n, err := skipPast(br, delim)
+		if err != nil && err != io.EOF {
+			closeFn()
+			return nil, 0, nil, err
+		}
+		offset += n
+	}
+
+	switch {
+	case in.CSV != nil:
+		c, err := newCSVReader(br, offset, in.CSV)
+		if err != nil {
+			closeFn()
+			return nil, 0, nil, err
+		}
+		if in.CSV.FileHeaderInfo != "NONE" {
+			header, err := r.readHeader(src, c, start)
+			if err != nil {
+				closeFn()
+				return nil, 0, nil, err
+			}
+			if in.CSV.FileHeaderInfo == "USE" {
+				c.names = header
+			}
+		}
+		return c, end, closeFn, nil
+	case in.JSON.Type == "LINES":
+		return &jsonLinesReader{r: br, offset: offset}, end, closeFn, nil
+	}
+	return &jsonDocumentReader{dec: json.NewDecoder(br)}, end, closeFn, nil
+}
+
+// readHeader returns the CSV header row. A scan starting past the header
+// reads it separately from the start of the object.
+func (r *run) readHeader(src Source, c *csvReader, start int64) ([]string, error) {
+	if start == 0 {
+		fields, _, err := c.readRecord()
+		if err == io.EOF {
+			return nil, nil
+		}
+		return fields, err
+	}
+	rc, err := src.Open(r.ctx, 0, -1)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rc.Close() }()
+	hc, err := newCSVReader(bufio.NewReader(rc), 0, r.q.req.InputSerialization.CSV)
+	if err != nil {
+		return nil, err
+	}
+	fields, _, err := hc.readRecord()
+	if err == io.EOF {
+		return nil, nil
+	}
+	return fields, err
+}
+
+// skipPast consumes bytes up to and including the first delim.
+func skipPast(br *bufio.Reader, delim []byte) (int64, error) {
+	var n int64
+	matched := 0
+	for {
+		b, err := br.ReadByte()
+		if err != nil {
+			return n, err
+		}
+		n++
+		switch {
+		case b == delim[matched]:
+			matched++
+		case b == delim[0]:
+			matched = 1
+		default:
+			matched = 0
+		}
+		if matched == len(delim) {
+			return n, nil
+		}
+	}
+}
+
+type emptyReader struct{}
+
+func (emptyReader) next() (any, int64, error) { return nil, 0, io.EOF }
+
+// countingParquet keeps BytesProcessed in step with BytesScanned as row
+// groups are fetched.
+type countingParquet struct {
+	pr    *parquetReader
+	stats *Stats
+}
+
+func (c *countingParquet) next() (any, int64, error) {
+	rec, off, err := c.pr.next()
+	c.stats.BytesProcessed = c.stats.BytesScanned
+	return rec, off, err
+}
+
+// expandFrom applies the FROM path to a record. [*] iterates an array (and
+// is a no-op on a non-array record); .name and [n] descend.
+func expandFrom(rec any, steps []pathStep) []any {
+	rows := []any{rec}
+	for _, s := range steps {
+		var next []any
+		for _, v := range rows {
+			switch {
+			case s.wildcard:
+				if arr, ok := v.([]any); ok {
+					next = append(next, arr...)
+				} else {
+					next = append(next, v)
+				}
+			default:
+				if got := resolvePath(v, []pathStep{s}); !isAbsent(got) {
+					next = append(next, got)
+				}
+			}
+		}
+		rows = next
+	}
+	return rows
+}
@@ -0,0 +1,329 @@
+package s3select
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// decodeMessage parses one message from r. It is the inverse of
+// encodeMessage and validates both checksums.
+func decodeMessage(r io.Reader) (map[string]string, []byte, error) {
+	prelude := make([]byte, 12)
+	if _, err := io.ReadFull(r, prelude); err != nil {
+		return nil, nil, err
+	}
+	if crc32.ChecksumIEEE(prelude[:8]) != binary.BigEndian.Uint32(prelude[8:]) {
+		return nil, nil, fmt.Errorf("event stream: prelude checksum mismatch")
+	}
+	total := binary.BigEndian.Uint32(prelude)
+	hlen := binary.BigEndian.Uint32(prelude[4:])
+	if total < 16 || hlen > total-16 {
+		return nil, nil, fmt.Errorf("event stream: invalid lengths")
+	}
+	rest := make([]byte, total-12)
+	if _, err := io.ReadFull(r, rest); err != nil {
+		return nil, nil, err
+	}
+	body := rest[:len(rest)-4]
+	crc := crc32.NewIEEE()
+	_, _ = crc.Write(prelude)
+	_, _ = crc.Write(body)
+	if crc.Sum32() != binary.BigEndian.Uint32(rest[len(rest)-4:]) {
+		return nil, nil, fmt.Errorf("event stream: message checksum mismatch")
+	}
+	headers := map[string]string{}
+	hb := body[:hlen]
+	for len(hb) > 0 {
+		n := int(hb[0])
+		if len(hb) < 1+n+3 || hb[1+n] != headerTypeString {
+			return nil, nil, fmt.Errorf("event stream: malformed header")
+		}
+		name := string(hb[1 : 1+n])
+		vlen := int(binary.BigEndian.Uint16(hb[2+n:]))
+		if len(hb) < 4+n+vlen {
+			return nil, nil, fmt.Errorf("event stream: malformed header")
+		}
+		headers[name] = string(hb[4+n : 4+n+vlen])
+		hb = hb[4+n+vlen:]
+	}
+	return headers, body[hlen:], nil
+}
+
+type event struct {
+	headers map[string]string
+	payload []byte
+}
+
+func bytesSource(data []byte) Source {
+	return Source{
+		Size: int64(len(data)),
+		Open: func(_ context.Context, offset, length int64) (io.ReadCloser, error) {
+			end := int64(len(data))
+			if length >= 0 && offset+length < end {
+				end = offset + length
+			}
+			return io.NopCloser(bytes.NewReader(data[offset:end])), nil
+		},
+	}
+}
+
+func requestXML(expr, input, output string) string {
+	return fmt.Sprintf(`<SelectObjectContentRequest>
+		<Expression>%s</Expression>
+		<ExpressionType>SQL</ExpressionType>
+		<InputSerialization>%s</InputSerialization>
+		<OutputSerialization>%s</OutputSerialization>
+	</SelectObjectContentRequest>`, expr, input, output)
+}
+
+// runSelect runs a request and returns the concatenated Records payloads
+// and every event.
+func runSelect(t *testing.T, body string, data []byte) (string, []event, error) {
+	t.Helper()
+	req, err := ParseRequest(strings.NewReader(body))
+	require.NoError(t, err)
+	q, err := Compile(req)
+	require.NoError(t, err)
+
+	var out bytes.Buffer
+	_, runErr := q.Run(context.Background(), bytesSource(data), &out, nil)
+
+	var records strings.Builder
+	var events []event
+	for out.Len() > 0 {
+		headers, payload, err := decodeMessage(&out)
+		require.NoError(t, err)
+		events = append(events, event{headers: headers, payload: payload})
+		if headers[":event-type"] == "Records" {
+			records.Write(payload)
+		}
+	}
+	return records.String(), events, runErr
+}
+
+const people = "name,age,city\n" +
+	"alice,34,Berlin\n" +
+	"bob,27,\"Paris, France\"\n" +
+	"carol,41,Oslo\n"
+
+func TestSelectCSV(t *testing.T) {
+	csvIn := `<CSV><FileHeaderInfo>USE</FileHeaderInfo></CSV>`
+	csvOut := `<CSV/>`
+	tests := []struct {
+		name string
+		expr string
+		want string
+	}{
+		{"star", "SELECT * FROM S3Object", "alice,34,Berlin\nbob,27,\"Paris, France\"\ncarol,41,Oslo\n"},
+		{"projection and filter", "SELECT s.name, s.city FROM S3Object s WHERE s.age &gt; 30", "alice,Berlin\ncarol,Oslo\n"},
+		{"positional", "SELECT _1 FROM S3Object WHERE _2 &lt; 30", "bob\n"},
+		{"limit", "SELECT name FROM S3Object LIMIT 2", "alice\nbob\n"},
+		{"like and upper", "SELECT UPPER(name) FROM S3Object WHERE city LIKE '%France'", "BOB\n"},
+		{"in and between", "SELECT name FROM S3Object WHERE age BETWEEN 30 AND 40 OR name IN ('carol')", "alice\ncarol\n"},
+		{"aggregates", "SELECT COUNT(*), SUM(CAST(age AS INT)), AVG(age), MIN(age), MAX(name) FROM S3Object", "3,102,34,27,carol\n"},
+		{"aggregate with filter", "SELECT COUNT(*) FROM S3Object s WHERE s.city = 'Oslo'", "1\n"},
+		{"arithmetic and concat", "SELECT name || '-' || CAST(age + 1 AS STRING) FROM S3Object LIMIT 1", "alice-35\n"},
+		{"case", "SELECT CASE WHEN age &gt; 30 THEN 'senior' ELSE 'junior' END FROM S3Object", "senior\njunior\nsenior\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, events, err := runSelect(t, requestXML(tt.expr, csvIn, csvOut), []byte(people))
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+			last := events[len(events)-1]
+			assert.Equal(t, "End", last.headers[":event-type"])
+			stats := events[len(events)-2]
+			assert.Equal(t, "Stats", stats.headers[":event-type"])
+			assert.Contains(t, string(stats.payload), fmt.Sprintf("<BytesScanned>%d</BytesScanned>", len(people)))
+		})
+	}
+}
+
+func TestSelectCSVOptions(t *testing.T) {
+	data := "# comment\n1|'a|b'\n2|'it''s'\n"
+	in := `<CSV><FieldDelimiter>|</FieldDelimiter><QuoteCharacter>'</QuoteCharacter><Comments>#</Comments></CSV>`
+	out := `<CSV><FieldDelimiter>;</FieldDelimiter><QuoteFields>ALWAYS</QuoteFields></CSV>`
+	got, _, err := runSelect(t, requestXML("SELECT _2, _1 FROM S3Object", in, out), []byte(data))
+	require.NoError(t, err)
+	assert.Equal(t, "\"a|b\";\"1\"\n\"it's\";\"2\"\n", got)
+
+	// IGNORE skips the header but keeps positional names.
+	in = `<CSV><FileHeaderInfo>IGNORE</FileHeaderInfo></CSV>`
+	got, _, err = runSelect(t, requestXML("SELECT _1 FROM S3Object", in, `<JSON/>`), []byte(people))
+	require.NoError(t, err)
+	assert.Equal(t, "{\"_1\":\"alice\"}\n{\"_1\":\"bob\"}\n{\"_1\":\"carol\"}\n", got)
+}
+
+func TestSelectGzipInput(t *testing.T) {
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	_, err := zw.Write([]byte(people))
+	require.NoError(t, err)
+	require.NoError(t, zw.Close())
+
+	in := `<CompressionType>GZIP</CompressionType><CSV><FileHeaderInfo>USE</FileHeaderInfo></CSV>`
+	got, events, err := runSelect(t, requestXML("SELECT COUNT(*) FROM S3Object", in, `<CSV/>`), gz.Bytes())
+	require.NoError(t, err)
+	assert.Equal(t, "3\n", got)
+	stats := string(events[len(events)-2].payload)
+	assert.Contains(t, stats, fmt.Sprintf("<BytesScanned>%d</BytesScanned>", gz.Len()))
+	assert.Contains(t, stats, fmt.Sprintf("<BytesProcessed>%d</BytesProcessed>", len(people)))
+}
+
+func TestSelectJSON(t *testing.T) {
+	lines := `{"id":1,"user":{"name":"ann"},"tags":["a","b"],"score":9.5}
+{"id":2,"user":{"name":"ben"},"score":null}
+{"id":3,"user":{"name":"cy"},"tags":[],"score":4}
+`
+	in := `<JSON><Type>LINES</Type></JSON>`
+	tests := []struct {
+		name string
+		expr string
+		want string
+	}{
+		{"star keeps key order", "SELECT * FROM S3Object s WHERE s.id = 1",
+			`{"id":1,"user":{"name":"ann"},"tags":["a","b"],"score":9.5}` + "\n"},
+		{"nested path and alias", "SELECT s.user.name AS who, s.tags[1] FROM S3Object s WHERE s.score IS NOT NULL",
+			`{"who":"ann","_2":"b"}` + "\n" + `{"who":"cy"}` + "\n"},
+		{"missing vs null", "SELECT s.id FROM S3Object s WHERE s.tags IS MISSING", `{"id":2}` + "\n"},
+		{"sum skips nulls", "SELECT SUM(s.score) AS total FROM S3Object s", `{"total":13.5}` + "\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, _, err := runSelect(t, requestXML(tt.expr, in, `<JSON/>`), []byte(lines))
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	doc := `{"rows":[{"v":1},{"v":2},{"v":3}]}`
+	got, _, err := runSelect(t, requestXML("SELECT r.v FROM S3Object[*].rows[*] r WHERE r.v &gt;= 2",
+		`<JSON><Type>DOCUMENT</Type></JSON>`, `<CSV/>`), []byte(doc))
+	require.NoError(t, err)
+	assert.Equal(t, "2\n3\n", got)
+}
+
+func TestSelectScanRange(t *testing.T) {
+	data := "a,1\nb,2\nc,3\nd,4\n"
+	body := func(start, end int) string {
+		return fmt.Sprintf(`<SelectObjectContentRequest>
+			<Expression>SELECT _1 FROM S3Object</Expression>
+			<ExpressionType>SQL</ExpressionType>
+			<InputSerialization><CSV/></InputSerialization>
+			<OutputSerialization><CSV/></OutputSerialization>
+			<ScanRange><Start>%d</Start><End>%d</End></ScanRange>
+		</SelectObjectContentRequest>`, start, end)
+	}
+	// Records starting at offsets 4 and 8 fall in [4, 8]; the one at 12
+	// does not, and the partial record at the start of [5, 12] is skipped.
+	got, _, err := runSelect(t, body(4, 8), []byte(data))
+	require.NoError(t, err)
+	assert.Equal(t, "b\nc\n", got)
+	got, _, err = runSelect(t, body(5, 12), []byte(data))
+	require.NoError(t, err)
+	assert.Equal(t, "c\nd\n", got)
+}
+
+func TestSelectRuntimeErrorIsAnEvent(t *testing.T) {
+	got, events, err := runSelect(t,
+		requestXML("SELECT SUM(city) FROM S3Object", `<CSV><FileHeaderInfo>USE</FileHeaderInfo></CSV>`, `<CSV/>`),
+		[]byte(people))
+	require.Error(t, err)
+	assert.Empty(t, got)
+	last := events[len(events)-1]
+	assert.Equal(t, "error", last.headers[":message-type"])
+	assert.Equal(t, ErrCastFailed, last.headers[":error-code"])
+}
+
+func TestParseRequestValidation(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		code string
+	}{
+		{"malformed", "<nope", ErrMalformedXML},
+		{"expression type", strings.Replace(requestXML("SELECT * FROM S3Object", "<CSV/>", "<CSV/>"), ">SQL<", ">XPATH<", 1), ErrInvalidExpressionType},
+		{"two inputs", requestXML("SELECT * FROM S3Object", "<CSV/><JSON><Type>LINES</Type></JSON>", "<CSV/>"), ErrObjectSerializationConflict},
+		{"no output", requestXML("SELECT * FROM S3Object", "<CSV/>", ""), ErrObjectSerializationConflict},
+		{"bad compression", requestXML("SELECT * FROM S3Object", "<CompressionType>ZIP</CompressionType><CSV/>", "<CSV/>"), ErrInvalidCompressionFormat},
+		{"bad header info", requestXML("SELECT * FROM S3Object", "<CSV><FileHeaderInfo>MAYBE</FileHeaderInfo></CSV>", "<CSV/>"), ErrInvalidFileHeaderInfo},
+		{"bad json type", requestXML("SELECT * FROM S3Object", "<JSON><Type>XML</Type></JSON>", "<CSV/>"), ErrInvalidJSONType},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseRequest(strings.NewReader(tt.body))
+			var se *Error
+			require.ErrorAs(t, err, &se)
+			assert.Equal(t, tt.code, se.Code)
+		})
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	tests := []struct {
+		expr string
+		code string
+	}{
+		{"SELECT FROM S3Object", ErrParseUnexpectedToken},
+		{"SELECT * FROM Objects", ErrParseUnexpectedToken},
+		{"SELECT name, COUNT(*) FROM S3Object", ErrUnsupportedSQLOperation},
+		{"SELECT * FROM S3Object WHERE COUNT(*) > 1", ErrUnsupportedSQLOperation},
+		{"SELECT SUM(MAX(a)) FROM S3Object", ErrUnsupportedSQLOperation},
+		{"SELECT * FROM S3Object GROUP BY a", ErrUnsupportedSQLOperation},
+		{"SELECT FOO(a) FROM S3Object", ErrUnsupportedFunction},
+		{"SELECT * FROM S3Object WHERE a = 'open", ErrParseUnexpectedToken},
+		{"SELECT * FROM S3Object[*].a", ErrUnsupportedSQLOperation},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			req := &Request{Expression: tt.expr, InputSerialization: InputSerialization{CSV: &CSVInput{}}}
+			_, err := Compile(req)
+			var se *Error
+			require.ErrorAs(t, err, &se)
+			assert.Equal(t, tt.code, se.Code)
+		})
+	}
+}
+
+func TestLikeMatch(t *testing.T) {
+	tests := []struct {
+		s, p string
+		want bool
+	}{
+		{"error: disk full", "error%", true},
+		{"warn", "error%", false},
+		{"abc", "a_c", true},
+		{"abbbc", "a%c", true},
+		{"ac", "a_c", false},
+		{"100%", `100\%`, true},
+		{"1000", `100\%`, false},
+		{"", "%", true},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, likeMatch([]rune(tt.s), []rune(tt.p), '\\'), "%q LIKE %q", tt.s, tt.p)
+	}
+}
+
+func TestEncodeMessageFraming(t *testing.T) {
+	msg := eventMessage("Records", "application/octet-stream", []byte("a,b\n"))
+	total := binary.BigEndian.Uint32(msg)
+	assert.Equal(t, uint32(len(msg)), total)
+	assert.Equal(t, crc32.ChecksumIEEE(msg[:8]), binary.BigEndian.Uint32(msg[8:]))
+	assert.Equal(t, crc32.ChecksumIEEE(msg[:len(msg)-4]), binary.BigEndian.Uint32(msg[len(msg)-4:]))
+
+	headers, payload, err := decodeMessage(bytes.NewReader(msg))
+	require.NoError(t, err)
+	assert.Equal(t, "Records", headers[":event-type"])
+	assert.Equal(t, "event", headers[":message-type"])
+	assert.Equal(t, []byte("a,b\n"), payload)
+}
@@ -0,0 +1,269 @@
+package s3select
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Values are nil (NULL), missing{} (MISSING), bool, int64, float64,
+// string, time.Time, *object or []any.
+
+// missing is the value of a path that does not exist in the record.
+type missing struct{}
+
+func isAbsent(v any) bool {
+	if v == nil {
+		return true
+	}
+	_, ok := v.(missing)
+	return ok
+}
+
+// object is a record or JSON object with its keys in input order.
+type object struct {
+	keys []string
+	vals []any
+	// positional allows _1, _2, ... to address fields by position, as CSV
+	// records do.
+	positional bool
+}
+
+func (o *object) set(key string, v any) {
+	for i, k := range o.keys {
+		if k == key {
+			o.vals[i] = v
+			return
+		}
+	}
+	o.keys = append(o.keys, key)
+	o.vals = append(o.vals, v)
+}
+
+// get looks up a field. Unquoted names fall back to a case-insensitive
+// match.
+func (o *object) get(name string, exact bool) (any, bool) {
+	for i, k := range o.keys {
+		if k == name {
+			return o.vals[i], true
+		}
+	}
+	if !exact {
+		for i, k := range o.keys {
+			if strings.EqualFold(k, name) {
+				return o.vals[i], true
+			}
+		}
+	}
+	if o.positional && len(name) > 1 && name[0] == '_' {
+		if n, err := strconv.Atoi(name[1:]); err == nil && n >= 1 && n <= len(o.vals) {
+			return o.vals[n-1], true
+		}
+	}
+	return nil, false
+}
+
+// MarshalJSON writes the object with its keys in order. Missing values are
+// omitted.
+func (o *object) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	first := true
+	for i, k := range o.keys {
+		if _, ok := o.vals[i].(missing); ok {
+			continue
+		}
+		if !first {
+			buf.WriteByte(',')
+		}
+		first = false
+		kb, _ := json.Marshal(k)
+		buf.Write(kb)
+		buf.WriteByte(':')
+		vb, err := marshalValue(o.vals[i])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(vb)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+func marshalValue(v any) ([]byte, error) {
+	switch x := v.(type) {
+	case missing:
+		return []byte("null"), nil
+	case float64:
+		if math.IsNaN(x) || math.IsInf(x, 0) {
+			return json.Marshal(formatFloat(x))
+		}
+		return []byte(formatFloat(x)), nil
+	case time.Time:
+		return json.Marshal(x.Format(time.RFC3339Nano))
+	case []any:
+		var buf bytes.Buffer
+		buf.WriteByte('[')
+		for i, e := range x {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			eb, err := marshalValue(e)
+			if err != nil {
+				return nil, err
+			}
+			buf.Write(eb)
+		}
+		buf.WriteByte(']')
+		return buf.Bytes(), nil
+	}
+	return json.Marshal(v)
+}
+
+func formatFloat(f float64) string {
+	if abs := math.Abs(f); f == 0 || (abs >= 1e-6 && abs < 1e21) {
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// toString renders a value as text; NULL and MISSING become "".
+func toString(v any) string {
+	switch x := v.(type) {
+	case nil, missing:
+		return ""
+	case string:
+		return x
+	case int64:
+		return strconv.FormatInt(x, 10)
+	case float64:
+		return formatFloat(x)
+	case bool:
+		return strconv.FormatBool(x)
+	case time.Time:
+		return x.Format(time.RFC3339Nano)
+	}
+	b, err := marshalValue(v)
+	if err != nil {
+		return ""
+	}
+	return string(b)
+}
+
+// toNumber returns v as an int64 or float64, parsing strings.
+func toNumber(v any) (any, bool) {
+	switch x := v.(type) {
+	case int64, float64:
+		return x, true
+	case string:
+		s := strings.TrimSpace(x)
+		if n, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return n, true
+		}
+		if f, err := strconv.ParseFloat(s, 64); err == nil {
+			return f, true
+		}
+	}
+	return nil, false
+}
+
+func toFloat(v any) float64 {
+	switch x := v.(type) {
+	case int64:
+		return float64(x)
+	case float64:
+		return x
+	}
+	return 0
+}
+
+var timestampLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+}
+
+func parseTimestamp(s string) (time.Time, bool) {
+	s = strings.TrimSpace(s)
+	for _, layout := range timestampLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// compareValues orders two non-null values. Numbers compare numerically,
+// and a string compared with a number is parsed as one. ok is false when
+// the values are not comparable.
+func compareValues(a, b any) (int, bool) {
+	_, aNum := a.(int64)
+	_, aF := a.(float64)
+	_, bNum := b.(int64)
+	_, bF := b.(float64)
+	aIsNum, bIsNum := aNum || aF, bNum || bF
+	if aIsNum || bIsNum {
+		na, ok1 := toNumber(a)
+		nb, ok2 := toNumber(b)
+		if !ok1 || !ok2 {
+			return 0, false
+		}
+		ia, aInt := na.(int64)
+		ib, bInt := nb.(int64)
+		if aInt && bInt {
+			return cmpOrdered(ia, ib), true
+		}
+		return cmpOrdered(toFloat(na), toFloat(nb)), true
+	}
+	switch x := a.(type) {
+	case string:
+		switch y := b.(type) {
+		case string:
+			return strings.Compare(x, y), true
+		case time.Time:
+			t, ok := parseTimestamp(x)
+			if !ok {
+				return 0, false
+			}
+			return t.Compare(y), true
+		}
+	case bool:
+		if y, ok := b.(bool); ok {
+			return cmpOrdered(boolInt(x), boolInt(y)), true
+		}
+	case time.Time:
+		switch y := b.(type) {
+		case time.Time:
+			return x.Compare(y), true
+		case string:
+			t, ok := parseTimestamp(y)
+			if !ok {
+				return 0, false
+			}
+			return x.Compare(t), true
+		}
+	}
+	return 0, false
+}
+
+func cmpOrdered[T int64 | float64](a, b T) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func boolInt(b bool) int64 {
+	if b {
+		return 1
+	}
+	return 0
+}
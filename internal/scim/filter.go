@@ -0,0 +1,468 @@
+package scim
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Filter is a parsed filter expression (RFC 7644 §3.4.2.2).
+type Filter interface {
+	// Match reports whether a resource, in its JSON form, matches.
+	Match(resource map[string]interface{}) bool
+}
+
+// ParseFilter parses a filter such as
+//
+//	userName eq "jsmith@acme.com" and emails[type eq "work" and value co "@acme.com"]
+//
+// Attribute names are case-insensitive and may carry their schema URN.
+// Strings compare case-insensitively except for id and externalId, and
+// timestamps compare as times.
+func ParseFilter(s string) (Filter, error) {
+	toks, err := lexFilter(s)
+	if err != nil {
+		return nil, err
+	}
+	p := &filterParser{toks: toks}
+	f, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, BadRequest(ErrInvalidFilter, "unexpected %q", t.text)
+	}
+	return f, nil
+}
+
+type tokKind int
+
+const (
+	tokEOF tokKind = iota
+	tokWord
+	tokString
+	tokLParen
+	tokRParen
+	tokLBracket
+	tokRBracket
+)
+
+type token struct {
+	kind tokKind
+	text string
+	str  string // decoded value of a string token
+}
+
+func lexFilter(s string) ([]token, error) {
+	var toks []token
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(' || c == ')' || c == '[' || c == ']':
+			kind := map[byte]tokKind{'(': tokLParen, ')': tokRParen, '[': tokLBracket, ']': tokRBracket}[c]
+			toks = append(toks, token{kind: kind, text: string(c)})
+			i++
+		case c == '"':
+			j := i + 1
+			for ; j < len(s) && s[j] != '"'; j++ {
+				if s[j] == '\\' {
+					j++
+				}
+			}
+			if j >= len(s) {
+				return nil, BadRequest(ErrInvalidFilter, "unterminated string")
+			}
+			var str string
+			if err := json.Unmarshal([]byte(s[i:j+1]), &str); err != nil {
+				return nil, BadRequest(ErrInvalidFilter, "invalid string %s", s[i:j+1])
+			}
+			toks = append(toks, token{kind: tokString, text: s[i : j+1], str: str})
+			i = j + 1
+		default:
+			j := i
+			for j < len(s) && !strings.ContainsRune(" \t\n\r()[]\"", rune(s[j])) {
+				j++
+			}
+			toks = append(toks, token{kind: tokWord, text: s[i:j]})
+			i = j
+		}
+	}
+	return append(toks, token{kind: tokEOF}), nil
+}
+
+type filterParser struct {
+	toks []token
+	pos  int
+}
+
+func (p *filterParser) peek() token { return p.toks[p.pos] }
+
+func (p *filterParser) next() token {
+	t := p.toks[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *filterParser) isWord(w string) bool {
+	t := p.peek()
+	return t.kind == tokWord && strings.EqualFold(t.text, w)
+}
+
+func (p *filterParser) expect(kind tokKind, what string) error {
+	if t := p.next(); t.kind != kind {
+		return BadRequest(ErrInvalidFilter, "expected %s, got %q", what, t.text)
+	}
+	return nil
+}
+
+func (p *filterParser) parseOr() (Filter, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.isWord("or") {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = &logicalFilter{and: false, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *filterParser) parseAnd() (Filter, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.isWord("and") {
+		p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = &logicalFilter{and: true, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *filterParser) parseUnary() (Filter, error) {
+	negate := false
+	if p.isWord("not") {
+		p.next()
+		negate = true
+		if p.peek().kind != tokLParen {
+			return nil, BadRequest(ErrInvalidFilter, "not must be followed by (")
+		}
+	}
+	var f Filter
+	if p.peek().kind == tokLParen {
+		p.next()
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(tokRParen, ")"); err != nil {
+			return nil, err
+		}
+		f = inner
+	} else {
+		attr, err := p.parseAttrExp()
+		if err != nil {
+			return nil, err
+		}
+		f = attr
+	}
+	if negate {
+		return &notFilter{f}, nil
+	}
+	return f, nil
+}
+
+func (p *filterParser) parseAttrExp() (Filter, error) {
+	t := p.next()
+	if t.kind != tokWord {
+		return nil, BadRequest(ErrInvalidFilter, "expected attribute, got %q", t.text)
+	}
+	path := parseAttrPath(t.text)
+
+	if p.peek().kind == tokLBracket {
+		p.next()
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(tokRBracket, "]"); err != nil {
+			return nil, err
+		}
+		return &valuePathFilter{attr: path.attr, filter: inner}, nil
+	}
+
+	opTok := p.next()
+	op := strings.ToLower(opTok.text)
+	if opTok.kind != tokWord {
+		return nil, BadRequest(ErrInvalidFilter, "expected operator after %s", t.text)
+	}
+	if op == "pr" {
+		return &presentFilter{path: path}, nil
+	}
+	switch op {
+	case "eq", "ne", "co", "sw", "ew", "gt", "ge", "lt", "le":
+	default:
+		return nil, BadRequest(ErrInvalidFilter, "unknown operator %q", opTok.text)
+	}
+
+	vt := p.next()
+	var value interface{}
+	switch vt.kind {
+	case tokString:
+		value = vt.str
+	case tokWord:
+		switch strings.ToLower(vt.text) {
+		case "true":
+			value = true
+		case "false":
+			value = false
+		case "null":
+			value = nil
+		default:
+			n, err := strconv.ParseFloat(vt.text, 64)
+			if err != nil {
+				return nil, BadRequest(ErrInvalidFilter, "invalid value %q", vt.text)
+			}
+			value = n
+		}
+	default:
+		return nil, BadRequest(ErrInvalidFilter, "expected value after %s %s", t.text, op)
+	}
+	return &compareFilter{path: path, op: op, value: value}, nil
+}
+
+// attrPath is an attribute name with an optional sub-attribute.
+type attrPath struct {
+	attr string
+	sub  string
+}
+
+// parseAttrPath splits "name.givenName" and drops a schema URN prefix.
+func parseAttrPath(s string) attrPath {
+	if strings.HasPrefix(strings.ToLower(s), "urn:") {
+		s = s[strings.LastIndexByte(s, ':')+1:]
+	}
+	attr, sub, _ := strings.Cut(s, ".")
+	return attrPath{attr: attr, sub: sub}
+}
+
+// caseExact reports whether values of the attribute compare exactly.
+func (p attrPath) caseExact() bool {
+	return p.sub == "" && (strings.EqualFold(p.attr, "id") || strings.EqualFold(p.attr, "externalId"))
+}
+
+// lookup finds a key case-insensitively.
+func lookup(m map[string]interface{}, name string) (string, interface{}, bool) {
+	if v, ok := m[name]; ok {
+		return name, v, true
+	}
+	for k, v := range m {
+		if strings.EqualFold(k, name) {
+			return k, v, true
+		}
+	}
+	return name, nil, false
+}
+
+// values returns the attribute's values in a resource. A multi-valued
+// complex attribute without a sub-attribute yields each element's
+// "value".
+func (p attrPath) values(res map[string]interface{}) []interface{} {
+	_, v, ok := lookup(res, p.attr)
+	if !ok || v == nil {
+		return nil
+	}
+	sub := p.sub
+	pick := func(x interface{}) (interface{}, bool) {
+		m, isMap := x.(map[string]interface{})
+		switch {
+		case isMap && sub != "":
+			_, sv, ok := lookup(m, sub)
+			return sv, ok && sv != nil
+		case isMap:
+			_, sv, ok := lookup(m, "value")
+			return sv, ok && sv != nil
+		case sub != "":
+			return nil, false
+		}
+		return x, true
+	}
+	var out []interface{}
+	if arr, isArr := v.([]interface{}); isArr {
+		for _, x := range arr {
+			if sv, ok := pick(x); ok {
+				out = append(out, sv)
+			}
+		}
+		return out
+	}
+	if _, isMap := v.(map[string]interface{}); isMap && sub == "" {
+		return nil
+	}
+	if sv, ok := pick(v); ok {
+		out = append(out, sv)
+	}
+	return out
+}
+
+type logicalFilter struct {
+	and         bool
+	left, right Filter
+}
+
+func (f *logicalFilter) Match(res map[string]interface{}) bool {
+	if f.and {
+		return f.left.Match(res) && f.right.Match(res)
+	}
+	return f.left.Match(res) || f.right.Match(res)
+}
+
+type notFilter struct{ f Filter }
+
+func (f *notFilter) Match(res map[string]interface{}) bool { return !f.f.Match(res) }
+
+type presentFilter struct{ path attrPath }
+
+func (f *presentFilter) Match(res map[string]interface{}) bool {
+	for _, v := range f.path.values(res) {
+		switch x := v.(type) {
+		case string:
+			if x != "" {
+				return true
+			}
+		case []interface{}:
+			if len(x) > 0 {
+				return true
+			}
+		case map[string]interface{}:
+			if len(x) > 0 {
+				return true
+			}
+		default:
+			return true
+		}
+	}
+	return false
+}
+
+// valuePathFilter matches when an element of a multi-valued attribute
+// matches the inner filter, e.g. emails[type eq "work"].
+type valuePathFilter struct {
+	attr   string
+	filter Filter
+}
+
+func (f *valuePathFilter) Match(res map[string]interface{}) bool {
+	_, v, _ := lookup(res, f.attr)
+	elems, isArr := v.([]interface{})
+	if !isArr {
+		elems = []interface{}{v}
+	}
+	for _, e := range elems {
+		if m, ok := e.(map[string]interface{}); ok && f.filter.Match(m) {
+			return true
+		}
+	}
+	return false
+}
+
+type compareFilter struct {
+	path  attrPath
+	op    string
+	value interface{}
+}
+
+func (f *compareFilter) Match(res map[string]interface{}) bool {
+	values := f.path.values(res)
+	if f.value == nil {
+		// "eq null" asks for an absent attribute.
+		return (f.op == "eq") == (len(values) == 0)
+	}
+	if f.op == "ne" {
+		for _, v := range values {
+			if compareValues("eq", v, f.value, f.path.caseExact()) {
+				return false
+			}
+		}
+		return true
+	}
+	for _, v := range values {
+		if compareValues(f.op, v, f.value, f.path.caseExact()) {
+			return true
+		}
+	}
+	return false
+}
+
+func compareValues(op string, actual, want interface{}, caseExact bool) bool {
+	switch w := want.(type) {
+	case bool:
+		a, ok := actual.(bool)
+		return ok && op == "eq" && a == w
+	case float64:
+		a, ok := actual.(float64)
+		return ok && orderedMatch(op, cmpFloat(a, w))
+	case string:
+		a, ok := actual.(string)
+		if !ok {
+			return false
+		}
+		if at, err := time.Parse(time.RFC3339, a); err == nil {
+			if wt, err := time.Parse(time.RFC3339, w); err == nil {
+				return orderedMatch(op, at.Compare(wt))
+			}
+		}
+		if !caseExact {
+			a, w = strings.ToLower(a), strings.ToLower(w)
+		}
+		switch op {
+		case "co":
+			return strings.Contains(a, w)
+		case "sw":
+			return strings.HasPrefix(a, w)
+		case "ew":
+			return strings.HasSuffix(a, w)
+		}
+		return orderedMatch(op, strings.Compare(a, w))
+	}
+	return false
+}
+
+func cmpFloat(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func orderedMatch(op string, c int) bool {
+	switch op {
+	case "eq":
+		return c == 0
+	case "gt":
+		return c > 0
+	case "ge":
+		return c >= 0
+	case "lt":
+		return c < 0
+	case "le":
+		return c <= 0
+	}
+	return false
+}
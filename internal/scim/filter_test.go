@@ -0,0 +1,90 @@
+package scim
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testUser(t *testing.T) map[string]interface{} {
+	t.Helper()
+	active := true
+	m, err := ToMap(&User{
+		Schemas:    []string{UserSchema},
+		ID:         "3f1c",
+		ExternalID: "00uAbC",
+		UserName:   "JSmith@acme.com",
+		Name:       &Name{GivenName: "Jane", FamilyName: "Smith"},
+		Emails: []Email{
+			{Value: "jsmith@acme.com", Type: "work", Primary: true},
+			{Value: "jane@example.org", Type: "home"},
+		},
+		Active: &active,
+		Groups: []Ref{{Value: "g1", Display: "Storage"}},
+		Meta:   &Meta{ResourceType: "User", LastModified: "2026-03-01T10:00:00Z"},
+	})
+	require.NoError(t, err)
+	return m
+}
+
+func TestParseFilter_Match(t *testing.T) {
+	user := testUser(t)
+	tests := []struct {
+		filter string
+		want   bool
+	}{
+		{`userName eq "jsmith@acme.com"`, true},
+		{`USERNAME Eq "JSMITH@ACME.COM"`, true},
+		{`urn:ietf:params:scim:schemas:core:2.0:User:userName eq "jsmith@acme.com"`, true},
+		{`userName eq "other@acme.com"`, false},
+		{`externalId eq "00uabc"`, false},
+		{`externalId eq "00uAbC"`, true},
+		{`id ne "3f1c"`, false},
+		{`userName sw "jsmith" and userName ew "@acme.com"`, true},
+		{`name.givenName co "an"`, true},
+		{`name.middleName pr`, false},
+		{`title pr`, false},
+		{`emails pr`, true},
+		{`emails eq "jane@example.org"`, true},
+		{`emails.type eq "home"`, true},
+		{`emails[type eq "work" and value co "@acme.com"]`, true},
+		{`emails[type eq "home" and value co "@acme.com"]`, false},
+		{`active eq true`, true},
+		{`active eq false`, false},
+		{`title eq null`, true},
+		{`groups.display eq "storage"`, true},
+		{`meta.lastModified gt "2026-01-01T00:00:00Z"`, true},
+		{`meta.lastModified lt "2026-01-01T00:00:00+01:00"`, false},
+		{`userName eq "x" or (active eq true and not (emails.type eq "other"))`, true},
+		{`not (userName pr)`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.filter, func(t *testing.T) {
+			f, err := ParseFilter(tt.filter)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, f.Match(user))
+		})
+	}
+}
+
+func TestParseFilter_Invalid(t *testing.T) {
+	for _, s := range []string{
+		``,
+		`userName`,
+		`userName eq`,
+		`userName like "x"`,
+		`userName eq "unterminated`,
+		`userName eq jsmith`,
+		`(userName pr`,
+		`emails[type eq "work"`,
+		`not userName pr`,
+		`userName pr extra`,
+	} {
+		_, err := ParseFilter(s)
+		var scimErr *Error
+		if assert.ErrorAs(t, err, &scimErr, "%q", s) {
+			assert.Equal(t, ErrInvalidFilter, scimErr.ScimType)
+		}
+	}
+}
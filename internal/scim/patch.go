@@ -0,0 +1,316 @@
+package scim
+
+import (
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// PatchOp is one operation of a PATCH request (RFC 7644 §3.5.2).
+type PatchOp struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path,omitempty"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+// PatchRequest is the body of a PATCH request.
+type PatchRequest struct {
+	Schemas    []string  `json:"schemas"`
+	Operations []PatchOp `json:"Operations"`
+}
+
+// Validate checks the message schema and that there is something to do.
+func (r *PatchRequest) Validate() error {
+	if !slices.Contains(r.Schemas, PatchOpSchema) {
+		return BadRequest(ErrInvalidSyntax, "schemas must contain %s", PatchOpSchema)
+	}
+	if len(r.Operations) == 0 {
+		return BadRequest(ErrInvalidSyntax, "Operations is empty")
+	}
+	return nil
+}
+
+// patchPath is a PATCH target: an attribute, optionally narrowed to the
+// elements a filter selects, and optionally one of their sub-attributes,
+// as in emails[type eq "work"].value.
+type patchPath struct {
+	attr   string
+	filter Filter
+	sub    string
+}
+
+func parsePatchPath(s string) (*patchPath, error) {
+	s = strings.TrimSpace(s)
+	head, rest := s, ""
+	if i := strings.IndexByte(s, '['); i >= 0 {
+		head, rest = s[:i], s[i:]
+	}
+	p := parseAttrPath(head)
+	pp := &patchPath{attr: p.attr, sub: p.sub}
+	if rest != "" {
+		end := strings.LastIndexByte(rest, ']')
+		if p.sub != "" || end < 0 {
+			return nil, BadRequest(ErrInvalidPath, "invalid path %q", s)
+		}
+		f, err := ParseFilter(rest[1:end])
+		if err != nil {
+			return nil, BadRequest(ErrInvalidPath, "invalid path %q: %v", s, err)
+		}
+		pp.filter = f
+		if after := rest[end+1:]; after != "" {
+			if !strings.HasPrefix(after, ".") || len(after) == 1 {
+				return nil, BadRequest(ErrInvalidPath, "invalid path %q", s)
+			}
+			pp.sub = after[1:]
+		}
+	}
+	if pp.attr == "" {
+		return nil, BadRequest(ErrInvalidPath, "invalid path %q", s)
+	}
+	return pp, nil
+}
+
+// ApplyPatch applies ops in order to a resource in its JSON form. Op
+// names are case-insensitive, as some IdPs capitalise them.
+func ApplyPatch(res map[string]interface{}, ops []PatchOp) error {
+	for _, op := range ops {
+		name := strings.ToLower(op.Op)
+		switch name {
+		case "add", "replace":
+			if op.Path == "" {
+				obj, ok := op.Value.(map[string]interface{})
+				if !ok {
+					return BadRequest(ErrInvalidValue, "%s without a path needs an object value", name)
+				}
+				for k, v := range obj {
+					p, err := parsePatchPath(k)
+					if err != nil {
+						return err
+					}
+					if err := setPath(res, p, v, name == "add"); err != nil {
+						return err
+					}
+				}
+				continue
+			}
+			p, err := parsePatchPath(op.Path)
+			if err != nil {
+				return err
+			}
+			if err := setPath(res, p, op.Value, name == "add"); err != nil {
+				return err
+			}
+		case "remove":
+			if op.Path == "" {
+				return BadRequest(ErrNoTarget, "remove needs a path")
+			}
+			p, err := parsePatchPath(op.Path)
+			if err != nil {
+				return err
+			}
+			if err := removePath(res, p, op.Value); err != nil {
+				return err
+			}
+		default:
+			return BadRequest(ErrInvalidSyntax, "unknown op %q", op.Op)
+		}
+	}
+	return nil
+}
+
+func setPath(res map[string]interface{}, p *patchPath, value interface{}, add bool) error {
+	key, cur, exists := lookup(res, p.attr)
+
+	if p.filter != nil {
+		elems, _ := cur.([]interface{})
+		matched := false
+		for i, e := range elems {
+			m, ok := e.(map[string]interface{})
+			if !ok || !p.filter.Match(m) {
+				continue
+			}
+			matched = true
+			elems[i] = setElement(m, p.sub, value, add)
+		}
+		if !matched {
+			// Create the element the filter describes, so that replacing
+			// emails[type eq "work"].value works on a user with no emails.
+			m, ok := filterEqualities(p.filter)
+			if !ok || p.sub == "" {
+				return BadRequest(ErrNoTarget, "no %s matches the filter", p.attr)
+			}
+			elems = append(elems, setElement(m, p.sub, value, true))
+		}
+		res[key] = elems
+		return nil
+	}
+
+	if p.sub != "" {
+		m, _ := cur.(map[string]interface{})
+		if exists && cur != nil && m == nil {
+			return BadRequest(ErrInvalidPath, "%s has no sub-attributes", p.attr)
+		}
+		if m == nil {
+			m = map[string]interface{}{}
+		}
+		subKey, _, _ := lookup(m, p.sub)
+		m[subKey] = value
+		res[key] = m
+		return nil
+	}
+
+	if add {
+		switch c := cur.(type) {
+		case []interface{}:
+			res[key] = appendUnique(c, value)
+			return nil
+		case map[string]interface{}:
+			if v, ok := value.(map[string]interface{}); ok {
+				for k, x := range v {
+					subKey, _, _ := lookup(c, k)
+					c[subKey] = x
+				}
+				return nil
+			}
+		}
+	}
+	res[key] = value
+	return nil
+}
+
+// setElement sets sub (or, without sub, merges or replaces the element)
+// in one element of a multi-valued attribute.
+func setElement(elem map[string]interface{}, sub string, value interface{}, merge bool) map[string]interface{} {
+	if sub != "" {
+		k, _, _ := lookup(elem, sub)
+		elem[k] = value
+		return elem
+	}
+	v, ok := value.(map[string]interface{})
+	if !ok {
+		return elem
+	}
+	if !merge {
+		return v
+	}
+	for k, x := range v {
+		ek, _, _ := lookup(elem, k)
+		elem[ek] = x
+	}
+	return elem
+}
+
+// appendUnique adds values to a multi-valued attribute, skipping those
+// already present. Complex values are the same when their "value" is.
+func appendUnique(elems []interface{}, value interface{}) []interface{} {
+	add, ok := value.([]interface{})
+	if !ok {
+		add = []interface{}{value}
+	}
+	for _, v := range add {
+		if !slices.ContainsFunc(elems, func(e interface{}) bool { return sameValue(e, v) }) {
+			elems = append(elems, v)
+		}
+	}
+	return elems
+}
+
+func sameValue(a, b interface{}) bool {
+	am, aok := a.(map[string]interface{})
+	bm, bok := b.(map[string]interface{})
+	if aok && bok {
+		_, av, ahas := lookup(am, "value")
+		_, bv, bhas := lookup(bm, "value")
+		if ahas && bhas {
+			return reflect.DeepEqual(av, bv)
+		}
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func removePath(res map[string]interface{}, p *patchPath, value interface{}) error {
+	key, cur, exists := lookup(res, p.attr)
+	if !exists {
+		return nil
+	}
+
+	if p.filter != nil {
+		elems, ok := cur.([]interface{})
+		if !ok {
+			return nil
+		}
+		kept := elems[:0]
+		for _, e := range elems {
+			m, ok := e.(map[string]interface{})
+			if !ok || !p.filter.Match(m) {
+				kept = append(kept, e)
+				continue
+			}
+			if p.sub != "" {
+				k, _, _ := lookup(m, p.sub)
+				delete(m, k)
+				kept = append(kept, m)
+			}
+		}
+		res[key] = kept
+		return nil
+	}
+
+	if p.sub != "" {
+		m, ok := cur.(map[string]interface{})
+		if !ok {
+			return BadRequest(ErrInvalidPath, "%s has no sub-attributes", p.attr)
+		}
+		k, _, _ := lookup(m, p.sub)
+		delete(m, k)
+		return nil
+	}
+
+	// Some IdPs remove group members by listing them as the value rather
+	// than with a filter.
+	if elems, ok := cur.([]interface{}); ok && value != nil {
+		remove, ok := value.([]interface{})
+		if !ok {
+			remove = []interface{}{value}
+		}
+		kept := elems[:0]
+		for _, e := range elems {
+			if !slices.ContainsFunc(remove, func(r interface{}) bool { return sameValue(e, r) }) {
+				kept = append(kept, e)
+			}
+		}
+		res[key] = kept
+		return nil
+	}
+	delete(res, key)
+	return nil
+}
+
+// filterEqualities returns the attribute values a filter made only of
+// "eq" comparisons joined by "and" requires.
+func filterEqualities(f Filter) (map[string]interface{}, bool) {
+	switch x := f.(type) {
+	case *compareFilter:
+		if x.op != "eq" || x.path.sub != "" || x.value == nil {
+			return nil, false
+		}
+		return map[string]interface{}{x.path.attr: x.value}, true
+	case *logicalFilter:
+		if !x.and {
+			return nil, false
+		}
+		l, ok := filterEqualities(x.left)
+		if !ok {
+			return nil, false
+		}
+		r, ok := filterEqualities(x.right)
+		if !ok {
+			return nil, false
+		}
+		for k, v := range r {
+			l[k] = v
+		}
+		return l, true
+	}
+	return nil, false
+}
@@ -0,0 +1,145 @@
+package scim
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func decodeOps(t *testing.T, body string) []PatchOp {
+	t.Helper()
+	var req PatchRequest
+	require.NoError(t, json.Unmarshal([]byte(body), &req))
+	require.NoError(t, req.Validate())
+	return req.Operations
+}
+
+func TestApplyPatch_User(t *testing.T) {
+	user := testUser(t)
+	ops := decodeOps(t, `{
+		"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"],
+		"Operations": [
+			{"op": "Replace", "path": "active", "value": "False"},
+			{"op": "replace", "path": "name.givenName", "value": "Janet"},
+			{"op": "replace", "path": "emails[type eq \"work\"].value", "value": "janet@acme.com"},
+			{"op": "add", "path": "emails[type eq \"other\"].value", "value": "js@acme.com"},
+			{"op": "remove", "path": "emails[type eq \"home\"]"},
+			{"op": "add", "value": {"displayName": "Janet Smith", "name.familyName": "Smyth"}}
+		]
+	}`)
+	require.NoError(t, ApplyPatch(user, ops))
+
+	u, err := UserFromMap(user)
+	require.NoError(t, err)
+	require.NotNil(t, u.Active)
+	assert.False(t, *u.Active)
+	assert.Equal(t, &Name{GivenName: "Janet", FamilyName: "Smyth"}, u.Name)
+	assert.Equal(t, "Janet Smith", u.DisplayName)
+	assert.Equal(t, []Email{
+		{Value: "janet@acme.com", Type: "work", Primary: true},
+		{Value: "js@acme.com", Type: "other"},
+	}, u.Emails)
+	assert.Equal(t, "janet@acme.com", u.PrimaryEmail())
+}
+
+func TestApplyPatch_GroupMembers(t *testing.T) {
+	group, err := ToMap(&Group{
+		Schemas:     []string{GroupSchema},
+		DisplayName: "Storage",
+		Members:     []Ref{{Value: "u1"}, {Value: "u2"}},
+	})
+	require.NoError(t, err)
+
+	members := func() []string {
+		var g Group
+		require.NoError(t, FromMap(group, &g))
+		var ids []string
+		for _, m := range g.Members {
+			ids = append(ids, m.Value)
+		}
+		return ids
+	}
+
+	// Adding an existing member is a no-op.
+	require.NoError(t, ApplyPatch(group, []PatchOp{{Op: "add", Path: "members",
+		Value: []interface{}{map[string]interface{}{"value": "u2"}, map[string]interface{}{"value": "u3"}}}}))
+	assert.Equal(t, []string{"u1", "u2", "u3"}, members())
+
+	// Okta removes with a filter, Entra ID with a value list.
+	require.NoError(t, ApplyPatch(group, []PatchOp{{Op: "remove", Path: `members[value eq "u1"]`}}))
+	assert.Equal(t, []string{"u2", "u3"}, members())
+	require.NoError(t, ApplyPatch(group, []PatchOp{{Op: "Remove", Path: "members",
+		Value: []interface{}{map[string]interface{}{"value": "u3"}}}}))
+	assert.Equal(t, []string{"u2"}, members())
+
+	require.NoError(t, ApplyPatch(group, []PatchOp{{Op: "replace", Path: "members",
+		Value: []interface{}{map[string]interface{}{"value": "u9"}}}}))
+	assert.Equal(t, []string{"u9"}, members())
+
+	require.NoError(t, ApplyPatch(group, []PatchOp{{Op: "remove", Path: "members"}}))
+	assert.Empty(t, members())
+}
+
+func TestApplyPatch_Errors(t *testing.T) {
+	tests := []struct {
+		name     string
+		op       PatchOp
+		scimType string
+	}{
+		{"unknown op", PatchOp{Op: "move", Path: "userName"}, ErrInvalidSyntax},
+		{"remove without path", PatchOp{Op: "remove"}, ErrNoTarget},
+		{"add without path or object", PatchOp{Op: "add", Value: "x"}, ErrInvalidValue},
+		{"bad filter", PatchOp{Op: "replace", Path: `emails[type eq]`, Value: "x"}, ErrInvalidPath},
+		{"unclosed filter", PatchOp{Op: "replace", Path: `emails[type eq "work"`, Value: "x"}, ErrInvalidPath},
+		{"no element to replace", PatchOp{Op: "replace", Path: `emails[type eq "fax"]`, Value: map[string]interface{}{"value": "x"}}, ErrNoTarget},
+		{"sub of a simple attribute", PatchOp{Op: "replace", Path: "userName.first", Value: "x"}, ErrInvalidPath},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ApplyPatch(testUser(t), []PatchOp{tt.op})
+			var scimErr *Error
+			require.ErrorAs(t, err, &scimErr)
+			assert.Equal(t, tt.scimType, scimErr.ScimType)
+		})
+	}
+
+	req := PatchRequest{Operations: []PatchOp{{Op: "add"}}}
+	assert.Error(t, req.Validate(), "schemas are required")
+}
+
+func TestNewListResponse(t *testing.T) {
+	all := []interface{}{"a", "b", "c"}
+	lr := NewListResponse(all, 2, 5)
+	assert.Equal(t, 3, lr.TotalResults)
+	assert.Equal(t, []interface{}{"b", "c"}, lr.Resources)
+	assert.Equal(t, 2, lr.ItemsPerPage)
+
+	lr = NewListResponse(all, 10, 5)
+	assert.Empty(t, lr.Resources)
+	assert.Equal(t, 3, lr.TotalResults)
+
+	lr = NewListResponse(all, 1, 0)
+	assert.Empty(t, lr.Resources, "count=0 returns only the total")
+
+	b, err := json.Marshal(BadRequest(ErrTooMany, "too many"))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"schemas":["urn:ietf:params:scim:api:messages:2.0:Error"],"status":"400","scimType":"tooMany","detail":"too many"}`, string(b))
+}
+
+func TestProject(t *testing.T) {
+	user := testUser(t)
+	Project(user, "", "groups,Emails")
+	assert.NotContains(t, user, "groups")
+	assert.NotContains(t, user, "emails")
+	assert.Contains(t, user, "userName")
+
+	user = testUser(t)
+	Project(user, "userName,name.givenName", "userName")
+	var keys []string
+	for k := range user {
+		keys = append(keys, k)
+	}
+	assert.ElementsMatch(t, []string{"schemas", "id", "userName", "name"}, keys)
+}
@@ -0,0 +1,170 @@
+package scim
+
+// Attribute describes one attribute of a resource schema (RFC 7643 §7).
+type Attribute struct {
+	Name           string      `json:"name"`
+	Type           string      `json:"type"`
+	MultiValued    bool        `json:"multiValued"`
+	Description    string      `json:"description,omitempty"`
+	Required       bool        `json:"required"`
+	CaseExact      bool        `json:"caseExact"`
+	Mutability     string      `json:"mutability"`
+	Returned       string      `json:"returned"`
+	Uniqueness     string      `json:"uniqueness"`
+	ReferenceTypes []string    `json:"referenceTypes,omitempty"`
+	SubAttributes  []Attribute `json:"subAttributes,omitempty"`
+}
+
+// Schema is a resource schema served at /Schemas.
+type Schema struct {
+	Schemas     []string    `json:"schemas"`
+	ID          string      `json:"id"`
+	Name        string      `json:"name"`
+	Description string      `json:"description"`
+	Attributes  []Attribute `json:"attributes"`
+	Meta        *Meta       `json:"meta,omitempty"`
+}
+
+// ResourceType is a resource endpoint served at /ResourceTypes.
+type ResourceType struct {
+	Schemas     []string `json:"schemas"`
+	ID          string   `json:"id"`
+	Name        string   `json:"name"`
+	Endpoint    string   `json:"endpoint"`
+	Description string   `json:"description"`
+	Schema      string   `json:"schema"`
+	Meta        *Meta    `json:"meta,omitempty"`
+}
+
+// attr returns a single-valued, optional, read-write string attribute.
+func attr(name, description string) Attribute {
+	return Attribute{
+		Name:        name,
+		Type:        "string",
+		Description: description,
+		Mutability:  "readWrite",
+		Returned:    "default",
+		Uniqueness:  "none",
+	}
+}
+
+func (a Attribute) with(f func(*Attribute)) Attribute {
+	f(&a)
+	return a
+}
+
+// refAttrs are the sub-attributes of a reference to another resource.
+func refAttrs(mutability string, refType string) []Attribute {
+	set := func(a *Attribute) { a.Mutability = mutability }
+	return []Attribute{
+		attr("value", "Identifier of the referenced resource.").with(set),
+		attr("$ref", "URI of the referenced resource.").with(func(a *Attribute) {
+			set(a)
+			a.Type = "reference"
+			a.ReferenceTypes = []string{refType}
+		}),
+		attr("display", "Human-readable name of the referenced resource.").with(func(a *Attribute) {
+			a.Mutability = "readOnly"
+		}),
+	}
+}
+
+// Schemas returns the User and Group schemas, with what this server
+// stores of them.
+func Schemas(baseURL string) []Schema {
+	user := Schema{
+		Schemas:     []string{SchemaSchema},
+		ID:          UserSchema,
+		Name:        "User",
+		Description: "Tenant member",
+		Attributes: []Attribute{
+			attr("userName", "Unique identifier for the user, typically their sign-in name.").with(func(a *Attribute) {
+				a.Required = true
+				a.Uniqueness = "server"
+			}),
+			attr("name", "The components of the user's name.").with(func(a *Attribute) {
+				a.Type = "complex"
+				a.SubAttributes = []Attribute{
+					attr("formatted", "The full name."),
+					attr("familyName", "The family name."),
+					attr("givenName", "The given name."),
+				}
+			}),
+			attr("displayName", "The name displayed to end-users."),
+			attr("emails", "Email addresses; the primary one, or else the userName, is the sign-in address.").with(func(a *Attribute) {
+				a.Type = "complex"
+				a.MultiValued = true
+				a.SubAttributes = []Attribute{
+					attr("value", "Email address."),
+					attr("type", "Label for the address, e.g. \"work\"."),
+					attr("primary", "Whether this is the primary address.").with(func(a *Attribute) { a.Type = "boolean" }),
+				}
+			}),
+			attr("active", "Whether the user may sign in. Deactivating revokes their credentials.").with(func(a *Attribute) {
+				a.Type = "boolean"
+			}),
+			attr("groups", "Groups the user belongs to; change them through the Group resource.").with(func(a *Attribute) {
+				a.Type = "complex"
+				a.MultiValued = true
+				a.Mutability = "readOnly"
+				a.SubAttributes = refAttrs("readOnly", "Group")
+			}),
+		},
+	}
+	group := Schema{
+		Schemas:     []string{SchemaSchema},
+		ID:          GroupSchema,
+		Name:        "Group",
+		Description: "Group of tenant members, mapped to roles",
+		Attributes: []Attribute{
+			attr("displayName", "Name of the group; the tenant's SCIM settings map it to a role.").with(func(a *Attribute) {
+				a.Required = true
+				a.Uniqueness = "server"
+			}),
+			attr("members", "Members of the group.").with(func(a *Attribute) {
+				a.Type = "complex"
+				a.MultiValued = true
+				a.SubAttributes = refAttrs("immutable", "User")
+			}),
+		},
+	}
+	for _, s := range []*Schema{&user, &group} {
+		s.Meta = &Meta{ResourceType: "Schema", Location: baseURL + "/Schemas/" + s.ID}
+	}
+	return []Schema{user, group}
+}
+
+// ResourceTypes returns the User and Group resource types.
+func ResourceTypes(baseURL string) []ResourceType {
+	types := []ResourceType{
+		{ID: "User", Name: "User", Endpoint: "/Users", Description: "Tenant member", Schema: UserSchema},
+		{ID: "Group", Name: "Group", Endpoint: "/Groups", Description: "Group of tenant members", Schema: GroupSchema},
+	}
+	for i := range types {
+		types[i].Schemas = []string{ResourceTypeSchema}
+		types[i].Meta = &Meta{ResourceType: "ResourceType", Location: baseURL + "/ResourceTypes/" + types[i].ID}
+	}
+	return types
+}
+
+// ServiceProviderConfig returns the features this server supports: PATCH
+// and filtering, with bearer token authentication.
+func ServiceProviderConfig(baseURL string) map[string]interface{} {
+	unsupported := map[string]bool{"supported": false}
+	return map[string]interface{}{
+		"schemas":        []string{ServiceProviderConfigSchema},
+		"patch":          map[string]bool{"supported": true},
+		"bulk":           map[string]interface{}{"supported": false, "maxOperations": 0, "maxPayloadSize": 0},
+		"filter":         map[string]interface{}{"supported": true, "maxResults": MaxCount},
+		"changePassword": unsupported,
+		"sort":           unsupported,
+		"etag":           unsupported,
+		"authenticationSchemes": []map[string]interface{}{{
+			"type":        "oauthbearertoken",
+			"name":        "Bearer token",
+			"description": "A SCIM token issued to the tenant, sent as Authorization: Bearer <token>.",
+			"primary":     true,
+		}},
+		"meta": &Meta{ResourceType: "ServiceProviderConfig", Location: baseURL + "/ServiceProviderConfig"},
+	}
+}
@@ -0,0 +1,257 @@
+// Package scim implements the protocol side of SCIM 2.0 (RFC 7643 and
+// RFC 7644): the User and Group resources, list responses and errors,
+// filter expressions, PATCH operations and the discovery documents an
+// identity provider reads before provisioning.
+//
+// Resources are filtered and patched in their JSON form, so the same code
+// serves every resource type.
+package scim
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Schema and message URNs.
+const (
+	UserSchema                  = "urn:ietf:params:scim:schemas:core:2.0:User"
+	GroupSchema                 = "urn:ietf:params:scim:schemas:core:2.0:Group"
+	ListResponseSchema          = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	PatchOpSchema               = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+	ErrorSchema                 = "urn:ietf:params:scim:api:messages:2.0:Error"
+	ServiceProviderConfigSchema = "urn:ietf:params:scim:schemas:core:2.0:ServiceProviderConfig"
+	ResourceTypeSchema          = "urn:ietf:params:scim:schemas:core:2.0:ResourceType"
+	SchemaSchema                = "urn:ietf:params:scim:schemas:core:2.0:Schema"
+)
+
+// ContentType is the media type of SCIM requests and responses.
+const ContentType = "application/scim+json"
+
+// Page size limits for list requests.
+const (
+	DefaultCount = 100
+	MaxCount     = 200
+)
+
+// Meta is the metadata every resource carries.
+type Meta struct {
+	ResourceType string `json:"resourceType"`
+	Created      string `json:"created,omitempty"`
+	LastModified string `json:"lastModified,omitempty"`
+	Location     string `json:"location,omitempty"`
+}
+
+// Name is a user's name.
+type Name struct {
+	Formatted  string `json:"formatted,omitempty"`
+	FamilyName string `json:"familyName,omitempty"`
+	GivenName  string `json:"givenName,omitempty"`
+}
+
+// Email is one of a user's email addresses.
+type Email struct {
+	Value   string `json:"value"`
+	Type    string `json:"type,omitempty"`
+	Primary bool   `json:"primary,omitempty"`
+}
+
+// Ref references another resource: a group's member or a user's group.
+type Ref struct {
+	Value   string `json:"value"`
+	Ref     string `json:"$ref,omitempty"`
+	Display string `json:"display,omitempty"`
+}
+
+// User is the core User resource.
+type User struct {
+	Schemas     []string `json:"schemas"`
+	ID          string   `json:"id,omitempty"`
+	ExternalID  string   `json:"externalId,omitempty"`
+	UserName    string   `json:"userName"`
+	Name        *Name    `json:"name,omitempty"`
+	DisplayName string   `json:"displayName,omitempty"`
+	Emails      []Email  `json:"emails,omitempty"`
+	// Active is nil when a request leaves it out.
+	Active *bool `json:"active,omitempty"`
+	Groups []Ref `json:"groups,omitempty"`
+	Meta   *Meta `json:"meta,omitempty"`
+}
+
+// PrimaryEmail returns the primary email address, else the first one.
+func (u *User) PrimaryEmail() string {
+	for _, e := range u.Emails {
+		if e.Primary {
+			return e.Value
+		}
+	}
+	if len(u.Emails) > 0 {
+		return u.Emails[0].Value
+	}
+	return ""
+}
+
+// UserFromMap decodes a User from its JSON object form. Some IdPs send
+// active as the string "True" or "False"; it is read as a boolean.
+func UserFromMap(m map[string]interface{}) (*User, error) {
+	for k, v := range m {
+		if s, ok := v.(string); ok && strings.EqualFold(k, "active") {
+			b, err := strconv.ParseBool(s)
+			if err != nil {
+				return nil, BadRequest(ErrInvalidValue, "active must be a boolean")
+			}
+			m[k] = b
+		}
+	}
+	var u User
+	if err := FromMap(m, &u); err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+// Group is the core Group resource.
+type Group struct {
+	Schemas     []string `json:"schemas"`
+	ID          string   `json:"id,omitempty"`
+	ExternalID  string   `json:"externalId,omitempty"`
+	DisplayName string   `json:"displayName"`
+	Members     []Ref    `json:"members,omitempty"`
+	Meta        *Meta    `json:"meta,omitempty"`
+}
+
+// ListResponse is the body of a query.
+type ListResponse struct {
+	Schemas      []string      `json:"schemas"`
+	TotalResults int           `json:"totalResults"`
+	StartIndex   int           `json:"startIndex"`
+	ItemsPerPage int           `json:"itemsPerPage"`
+	Resources    []interface{} `json:"Resources"`
+}
+
+// NewListResponse returns the page of resources that starts at the
+// 1-based startIndex and holds at most count of them.
+func NewListResponse(resources []interface{}, startIndex, count int) *ListResponse {
+	if startIndex < 1 {
+		startIndex = 1
+	}
+	page := []interface{}{}
+	if from := startIndex - 1; from < len(resources) && count > 0 {
+		to := min(from+count, len(resources))
+		page = resources[from:to]
+	}
+	return &ListResponse{
+		Schemas:      []string{ListResponseSchema},
+		TotalResults: len(resources),
+		StartIndex:   startIndex,
+		ItemsPerPage: len(page),
+		Resources:    page,
+	}
+}
+
+// Error types (RFC 7644 §3.12).
+const (
+	ErrInvalidFilter = "invalidFilter"
+	ErrTooMany       = "tooMany"
+	ErrUniqueness    = "uniqueness"
+	ErrMutability    = "mutability"
+	ErrInvalidSyntax = "invalidSyntax"
+	ErrInvalidPath   = "invalidPath"
+	ErrNoTarget      = "noTarget"
+	ErrInvalidValue  = "invalidValue"
+)
+
+// Error is a SCIM error response.
+type Error struct {
+	Status   int
+	ScimType string
+	Detail   string
+}
+
+// NewError returns an error with the HTTP status and SCIM error type.
+func NewError(status int, scimType, format string, args ...interface{}) *Error {
+	return &Error{Status: status, ScimType: scimType, Detail: fmt.Sprintf(format, args...)}
+}
+
+// BadRequest returns a 400 error of scimType.
+func BadRequest(scimType, format string, args ...interface{}) *Error {
+	return NewError(http.StatusBadRequest, scimType, format, args...)
+}
+
+func (e *Error) Error() string {
+	if e.ScimType != "" {
+		return fmt.Sprintf("scim: %s: %s", e.ScimType, e.Detail)
+	}
+	return "scim: " + e.Detail
+}
+
+// MarshalJSON renders the error response body; the status is a string.
+func (e *Error) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Schemas  []string `json:"schemas"`
+		Status   string   `json:"status"`
+		ScimType string   `json:"scimType,omitempty"`
+		Detail   string   `json:"detail,omitempty"`
+	}{[]string{ErrorSchema}, strconv.Itoa(e.Status), e.ScimType, e.Detail})
+}
+
+// ToMap returns the JSON object form of a resource, as filters and PATCH
+// operations see it.
+func ToMap(resource interface{}) (map[string]interface{}, error) {
+	b, err := json.Marshal(resource)
+	if err != nil {
+		return nil, err
+	}
+	var m map[string]interface{}
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// FromMap decodes the JSON object form of a resource into v.
+func FromMap(m map[string]interface{}, v interface{}) error {
+	b, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(b, v); err != nil {
+		return BadRequest(ErrInvalidValue, "%v", err)
+	}
+	return nil
+}
+
+// Project applies the attributes and excludedAttributes query parameters,
+// comma-separated attribute names, to a resource in its JSON form.
+// attributes keeps only the named top-level attributes and takes
+// precedence; excludedAttributes drops them. id and schemas are always
+// returned.
+func Project(res map[string]interface{}, attributes, excludedAttributes string) {
+	always := func(k string) bool { return strings.EqualFold(k, "id") || strings.EqualFold(k, "schemas") }
+	names := func(list string) map[string]bool {
+		out := make(map[string]bool)
+		for _, n := range strings.Split(list, ",") {
+			if n = strings.TrimSpace(n); n != "" {
+				out[strings.ToLower(parseAttrPath(n).attr)] = true
+			}
+		}
+		return out
+	}
+	if attributes != "" {
+		keep := names(attributes)
+		for k := range res {
+			if !always(k) && !keep[strings.ToLower(k)] {
+				delete(res, k)
+			}
+		}
+		return
+	}
+	drop := names(excludedAttributes)
+	for k := range res {
+		if !always(k) && drop[strings.ToLower(k)] {
+			delete(res, k)
+		}
+	}
+}